@@ -50,6 +50,7 @@ validate-chroot: $(go-validatechroot) $(chroot_worker)
 	--tmp-dir="$(BUILD_DIR)/validatechroot" \
 	--worker-chroot="$(chroot_worker)" \
 	--worker-manifest="$(WORKER_CHROOT_MANIFEST)" \
+	$(if $(filter y,$(VALIDATE_CHROOT_ROOTLESS)),--rootless) \
 	--log-file="$(LOGS_DIR)/worker/validate.log" \
 	--log-level="$(LOG_LEVEL)" \
 	--log-color="$(LOG_COLOR)"
@@ -44,8 +44,22 @@ meta_user_data_tmp_dir               = $(IMAGEGEN_DIR)/meta-user-data_tmp
 image_package_cache_summary          = $(imggen_config_dir)/image_deps.json
 image_external_package_cache_summary = $(imggen_config_dir)/image_external_deps.json
 image_package_manifest               = $(imggen_config_dir)/image_pkg_manifest.json
+image_package_signatures             = $(imggen_config_dir)/image_pkg_signatures.json
 license_results_file_img             = $(imggen_config_dir)/license_check_results.json
 
+# Batch workspace, cache and outputs
+batch_config_name           = $(notdir $(BATCH_CONFIG_FILE:%.json=%))
+batch_dir                   = $(IMAGEGEN_DIR)/batch-$(batch_config_name)
+batch_workspace_dir         = $(batch_dir)/workspace
+batch_rpm_cache             = $(batch_dir)/package_repo
+batch_fetcher_tmp_dir       = $(batch_dir)/fetcher_tmp
+batch_roaster_tmp_dir       = $(batch_dir)/roaster_tmp
+batch_imager_output_dir     = $(batch_dir)/imager_output
+batch_package_cache_summary = $(batch_dir)/image_deps.json
+batch_package_manifest      = $(batch_dir)/image_pkg_manifest.json
+batch_package_signatures    = $(batch_dir)/image_pkg_signatures.json
+batch_artifact_dir          = $(IMAGES_DIR)/$(batch_config_name)
+
 # Outputs
 artifact_dir             = $(IMAGES_DIR)/$(config_name)
 imager_disk_output_dir   = $(imggen_config_dir)/imager_output
@@ -62,7 +76,7 @@ $(call create_folder,$(imager_disk_output_dir))
 $(call create_folder,$(artifact_dir))
 $(call create_folder,$(meta_user_data_tmp_dir))
 
-.PHONY: fetch-image-packages fetch-external-image-packages make-raw-image image iso installer-initrd validate-image-config clean-imagegen
+.PHONY: fetch-image-packages fetch-external-image-packages make-raw-image image image-batch iso installer-initrd validate-image-config clean-imagegen
 
 clean: clean-imagegen
 clean-imagegen:
@@ -194,6 +208,7 @@ $(STATUS_FLAGS_DIR)/imager_disk_output.flag: $(go-imager) $(image_package_cache_
 		--repo-snapshot-time=$(REPO_SNAPSHOT_TIME) \
 		--repo-file=$(imggen_local_repo) \
 		--output-image-contents=$(image_package_manifest) \
+		--output-package-signatures=$(image_package_signatures) \
 		--assets $(assets_dir) \
 		--output-dir $(imager_disk_output_dir) \
 		--cpu-prof-file=$(PROFILE_DIR)/imager.cpu.pprof \
@@ -231,6 +246,70 @@ image: $(imager_disk_output_dir) $(imager_disk_output_files) $(go-roast) $(depen
 		$(if $(filter y,$(ENABLE_TRACE)),--enable-trace) \
 		--timestamp-file=$(TIMESTAMP_DIR)/roast.jsonl
 
+##help:target:image-batch=Generate all of the images of a batch config (BATCH_CONFIG_FILE=), with one package download and one extraction of the chroot worker for all of them.
+# The images are validated, their packages downloaded, and the images built and converted, in one recipe, as the
+# images' outputs are only known from the batch config.
+image-batch: $(go-imageconfigvalidator) $(go-imagepkgfetcher) $(go-imager) $(go-roast) $(chroot_worker) $(toolchain_rpms) $(imggen_local_repo) $(depend_REPO_LIST) $(REPO_LIST) $(RPMS_DIR) $(imggen_rpms) $(assets_files) $(BATCH_CONFIG_FILE) $(STATUS_FLAGS_DIR)/imagegen_cleanup.flag
+	$(if $(BATCH_CONFIG_FILE),,$(error Must set BATCH_CONFIG_FILE=))
+	mkdir -p $(batch_workspace_dir) $(batch_imager_output_dir) $(batch_artifact_dir) && \
+	rm -rf $(batch_imager_output_dir)/* && \
+	$(go-imageconfigvalidator) \
+		--input=$(BATCH_CONFIG_FILE) \
+		--dir=$(BATCH_CONFIG_BASE_DIR) \
+		--batch \
+		--log-level=$(LOG_LEVEL) \
+		--log-file=$(LOGS_DIR)/imggen/batchimageconfigvalidator.log \
+		--log-color=$(LOG_COLOR) && \
+	$(go-imagepkgfetcher) \
+		--input=$(BATCH_CONFIG_FILE) \
+		--base-dir=$(BATCH_CONFIG_BASE_DIR) \
+		--batch \
+		--log-level=$(LOG_LEVEL) \
+		--log-file=$(LOGS_DIR)/imggen/batchimagepkgfetcher.log \
+		--log-color=$(LOG_COLOR) \
+		--rpm-dir=$(RPMS_DIR) \
+		--tmp-dir=$(batch_fetcher_tmp_dir) \
+		--toolchain-rpms-dir="$(TOOLCHAIN_RPMS_DIR)" \
+		--tdnf-worker=$(chroot_worker) \
+		--tls-cert=$(TLS_CERT) \
+		--tls-key=$(TLS_KEY) \
+		$(foreach repo, $(imagefetcher_local_repo) $(imagefetcher_cloned_repo) $(REPO_LIST),--repo-file="$(repo)" ) \
+		$(imagepkgfetcher_extra_flags) \
+		--output-summary-file=$(batch_package_cache_summary) \
+		--output-dir=$(batch_rpm_cache) \
+		--timestamp-file=$(TIMESTAMP_DIR)/batchimagepkgfetcher.jsonl && \
+	$(go-imager) \
+		--build-dir $(batch_workspace_dir) \
+		--input $(BATCH_CONFIG_FILE) \
+		--base-dir=$(BATCH_CONFIG_BASE_DIR) \
+		--batch \
+		--log-level=$(LOG_LEVEL) \
+		--log-file=$(LOGS_DIR)/imggen/batchimager.log \
+		--log-color=$(LOG_COLOR) \
+		--local-repo $(batch_rpm_cache) \
+		--tdnf-worker $(chroot_worker) \
+		--repo-snapshot-time=$(REPO_SNAPSHOT_TIME) \
+		--repo-file=$(imggen_local_repo) \
+		--output-image-contents=$(batch_package_manifest) \
+		--output-package-signatures=$(batch_package_signatures) \
+		--assets $(assets_dir) \
+		--output-dir $(batch_imager_output_dir) \
+		--timestamp-file=$(TIMESTAMP_DIR)/batchimager.jsonl \
+		--build-number=$(BUILD_ID) && \
+	VMXTEMPLATE=$(ova_vmxtemplate) OVFINFO=$(ova_ovfinfo) \
+	$(go-roast) \
+		--dir=$(batch_imager_output_dir) \
+		--config $(BATCH_CONFIG_FILE) \
+		--batch \
+		--output-dir $(batch_artifact_dir) \
+		--tmp-dir $(batch_roaster_tmp_dir) \
+		--release-version $(RELEASE_VERSION) \
+		--log-level=$(LOG_LEVEL) \
+		--log-file=$(LOGS_DIR)/imggen/batchroast.log \
+		--log-color=$(LOG_COLOR) \
+		--image-tag=$(IMAGE_TAG) \
+		--timestamp-file=$(TIMESTAMP_DIR)/batchroast.jsonl
+
 $(image_external_package_cache_summary): $(cached_file) $(go-imagepkgfetcher) $(chroot_worker) $(graph_file) $(depend_REPO_LIST) $(REPO_LIST) $(depend_CONFIG_FILE) $(CONFIG_FILE) $(validate-config) $(depend_REPO_SNAPSHOT_TIME) $(STATUS_FLAGS_DIR)/imagegen_cleanup.flag
 	$(if $(CONFIG_FILE),,$(error Must set CONFIG_FILE=))
 	$(go-imagepkgfetcher) \
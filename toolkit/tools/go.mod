@@ -19,6 +19,7 @@ require (
 	github.com/klauspost/pgzip v1.2.5
 	github.com/moby/sys/mountinfo v0.6.2
 	github.com/muesli/crunchy v0.4.0
+	github.com/pmezard/go-difflib v1.0.0
 	github.com/rivo/tview v0.0.0-20200219135020-0ba8301b415c
 	github.com/sirupsen/logrus v1.9.3
 	github.com/stretchr/testify v1.9.0
@@ -47,7 +48,6 @@ require (
 	github.com/mattn/go-runewidth v0.0.7 // indirect
 	github.com/pelletier/go-toml/v2 v2.1.1 // indirect
 	github.com/pkg/browser v0.0.0-20240102092130-5ac0b6a4141c // indirect
-	github.com/pmezard/go-difflib v1.0.0 // indirect
 	github.com/rivo/uniseg v0.1.0 // indirect
 	github.com/xrash/smetrics v0.0.0-20201216005158-039620a65673 // indirect
 	golang.org/x/crypto v0.36.0 // indirect
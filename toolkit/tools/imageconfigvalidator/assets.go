@@ -0,0 +1,157 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/imagegen/configuration"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/timestamp"
+)
+
+// validateLocalAssets checks that the local files that the config references (raw binaries, additional files, install
+// scripts, SSH public key files, preset files, registry credentials, kernel modules, CA certificates and repo GPG keys)
+// exist and are readable, and reports all of the files that aren't. Otherwise, the image build fails on the first of
+// them, which may be hours into the build. The config's paths are expected to be absolute (see
+// configuration.LoadWithAbsolutePaths). Package lists are checked by validatePackages.
+func validateLocalAssets(config configuration.Config) (results []ValidationResult) {
+	timestamp.StartEvent("validate local assets", nil)
+	defer timestamp.StopEvent(nil)
+
+	for i, disk := range config.Disks {
+		for j, rawBinary := range disk.RawBinaries {
+			results = append(results, validateAssetFile(ruleFileSource,
+				fmt.Sprintf("Disks[%d].RawBinaries[%d].BinPath", i, j), "raw binary", rawBinary.BinPath, false)...)
+		}
+	}
+
+	for i, systemConfig := range config.SystemConfigs {
+		// Sort the additional files, so that the results are in a stable order.
+		additionalFiles := make([]string, 0, len(systemConfig.AdditionalFiles))
+		for localPath := range systemConfig.AdditionalFiles {
+			additionalFiles = append(additionalFiles, localPath)
+		}
+		sort.Strings(additionalFiles)
+
+		for _, localPath := range additionalFiles {
+			results = append(results, validateAssetFile(ruleFileSource,
+				systemConfigPath(i, fmt.Sprintf("AdditionalFiles[%s]", localPath)), "additional file", localPath, false)...)
+		}
+
+		for _, scriptSet := range []struct {
+			field   string
+			scripts []configuration.InstallScript
+		}{
+			{"PreInstallScripts", systemConfig.PreInstallScripts},
+			{"PostInstallScripts", systemConfig.PostInstallScripts},
+			{"FinalizeImageScripts", systemConfig.FinalizeImageScripts},
+		} {
+			for j, script := range scriptSet.scripts {
+				// imager runs the scripts by their path, so they must be executable.
+				results = append(results, validateAssetFile(ruleScriptPath,
+					systemConfigPath(i, fmt.Sprintf("%s[%d].Path", scriptSet.field, j)), "script", script.Path, true)...)
+			}
+		}
+
+		for j, presetFile := range systemConfig.Services.PresetFiles {
+			results = append(results, validateAssetFile(ruleFileSource,
+				systemConfigPath(i, fmt.Sprintf("Services.PresetFiles[%d]", j)), "preset file", presetFile, false)...)
+		}
+
+		if systemConfig.ContainerImages.AuthFile != "" {
+			results = append(results, validateAssetFile(ruleFileSource, systemConfigPath(i, "ContainerImages.AuthFile"),
+				"registry credentials file", systemConfig.ContainerImages.AuthFile, false)...)
+		}
+
+		if systemConfig.KernelModules.IsSigned() {
+			results = append(results, validateAssetFile(ruleFileSource, systemConfigPath(i, "KernelModules.SigningKey"),
+				"kernel module signing key", systemConfig.KernelModules.SigningKey, false)...)
+			results = append(results, validateAssetFile(ruleFileSource, systemConfigPath(i, "KernelModules.SigningCert"),
+				"kernel module signing certificate", systemConfig.KernelModules.SigningCert, false)...)
+		}
+
+		for j, module := range systemConfig.KernelModules.Modules {
+			results = append(results, validateAssetFile(ruleFileSource,
+				systemConfigPath(i, fmt.Sprintf("KernelModules.Modules[%d].Path", j)), "kernel module", module.Path, false)...)
+		}
+
+		for j, caCertificate := range systemConfig.CACertificates {
+			results = append(results, validateAssetFileOrDir(ruleFileSource,
+				systemConfigPath(i, fmt.Sprintf("CACertificates[%d]", j)), "CA certificate", caCertificate)...)
+		}
+
+		for j, repo := range systemConfig.Repos {
+			for k, gpgKey := range repo.GPGKeys {
+				results = append(results, validateAssetFile(ruleFileSource,
+					systemConfigPath(i, fmt.Sprintf("Repos[%d].GPGKeys[%d]", j, k)), "repo GPG key", gpgKey, false)...)
+			}
+		}
+
+		for j, gpgKey := range systemConfig.PackageSignatures.GPGKeys {
+			results = append(results, validateAssetFile(ruleFileSource,
+				systemConfigPath(i, fmt.Sprintf("PackageSignatures.GPGKeys[%d]", j)), "package signature GPG key", gpgKey,
+				false)...)
+		}
+
+		for j, user := range systemConfig.Users {
+			for k, sshPubKeyPath := range user.SSHPubKeyPaths {
+				results = append(results, validateAssetFile(ruleFileSource,
+					systemConfigPath(i, fmt.Sprintf("Users[%d].SSHPubKeyPaths[%d]", j, k)), "SSH public key file",
+					sshPubKeyPath, false)...)
+			}
+		}
+	}
+
+	return
+}
+
+func validateAssetFile(ruleId string, path string, description string, assetPath string, executable bool,
+) []ValidationResult {
+	err := checkReadableFile(assetPath, executable)
+	if err != nil {
+		return []ValidationResult{newValidationResult(ruleId, path,
+			fmt.Errorf("invalid %s (%s):\n%w", description, assetPath, err))}
+	}
+	return nil
+}
+
+// validateAssetFileOrDir is validateAssetFile for the assets that may also be directories of files.
+func validateAssetFileOrDir(ruleId string, path string, description string, assetPath string) []ValidationResult {
+	fileInfo, err := os.Stat(assetPath)
+	if err != nil || !fileInfo.IsDir() {
+		return validateAssetFile(ruleId, path, description, assetPath, false)
+	}
+
+	_, err = os.ReadDir(assetPath)
+	if err != nil {
+		return []ValidationResult{newValidationResult(ruleId, path,
+			fmt.Errorf("invalid %s (%s):\n%w", description, assetPath, err))}
+	}
+	return nil
+}
+
+// checkReadableFile returns an error if a path isn't a regular file that can be read (and, optionally, executed).
+func checkReadableFile(path string, executable bool) error {
+	fileInfo, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	if !fileInfo.Mode().IsRegular() {
+		return fmt.Errorf("not a file")
+	}
+
+	if executable && fileInfo.Mode().Perm()&0o111 == 0 {
+		return fmt.Errorf("not executable")
+	}
+
+	// Opening the file checks that the current user can read it, which its permissions alone don't tell.
+	fileHandle, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	return fileHandle.Close()
+}
@@ -0,0 +1,111 @@
+// Copyright Microsoft Corporation.
+// Licensed under the MIT License.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/imagegen/configuration"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLocalAssetsReportsAllMissingFiles(t *testing.T) {
+	assetsDir := t.TempDir()
+	existingFile := filepath.Join(assetsDir, "file.txt")
+	script := filepath.Join(assetsDir, "script.sh")
+	nonExecutableScript := filepath.Join(assetsDir, "nonexecutable.sh")
+	for path, perm := range map[string]os.FileMode{
+		existingFile:        0o644,
+		script:              0o755,
+		nonExecutableScript: 0o644,
+	} {
+		err := os.WriteFile(path, []byte("#!/bin/sh\n"), perm)
+		if !assert.NoError(t, err) {
+			return
+		}
+	}
+	missingFile := filepath.Join(assetsDir, "missing.txt")
+
+	config := configuration.Config{
+		Disks: []configuration.Disk{{
+			RawBinaries: []configuration.RawBinary{{BinPath: missingFile}},
+		}},
+		SystemConfigs: []configuration.SystemConfig{{
+			AdditionalFiles: map[string]configuration.FileConfigList{
+				existingFile: {{Path: "/file.txt"}},
+				assetsDir:    {{Path: "/dir"}},
+			},
+			PreInstallScripts:  []configuration.InstallScript{{Path: script}},
+			PostInstallScripts: []configuration.InstallScript{{Path: nonExecutableScript}},
+			Services: configuration.Services{
+				PresetFiles: []string{missingFile},
+			},
+			ContainerImages: configuration.ContainerImages{
+				AuthFile: missingFile,
+				Images:   []configuration.ContainerImage{{Reference: "mcr.microsoft.com/azurelinux/base/core:3.0"}},
+			},
+			KernelModules: configuration.KernelModules{
+				SigningKey:  existingFile,
+				SigningCert: existingFile,
+				Modules:     []configuration.KernelModule{{Path: existingFile}, {Path: missingFile}},
+			},
+			CACertificates: []string{assetsDir, existingFile, missingFile},
+			Repos: []configuration.Repo{{
+				ID:       "contoso-extras",
+				BaseURL:  "https://packages.contoso.com/azurelinux",
+				GPGKeys:  []string{existingFile, missingFile},
+				GPGCheck: true,
+			}},
+			PackageSignatures: configuration.PackageSignatures{
+				Enforce: true,
+				GPGKeys: []string{missingFile, existingFile},
+			},
+			Users: []configuration.User{{
+				Name:           "test",
+				SSHPubKeyPaths: []string{existingFile, missingFile},
+			}},
+		}},
+	}
+
+	results := validateLocalAssets(config)
+	if !assert.Len(t, results, 10) {
+		return
+	}
+
+	assert.Equal(t, ruleFileSource, results[0].RuleId)
+	assert.Equal(t, "Disks[0].RawBinaries[0].BinPath", results[0].Path)
+	assert.Contains(t, results[0].Message, "no such file or directory")
+
+	assert.Equal(t, ruleFileSource, results[1].RuleId)
+	assert.Equal(t, "SystemConfigs[0].AdditionalFiles["+assetsDir+"]", results[1].Path)
+	assert.Equal(t, "invalid additional file ("+assetsDir+"):\nnot a file", results[1].Message)
+
+	assert.Equal(t, ruleScriptPath, results[2].RuleId)
+	assert.Equal(t, "SystemConfigs[0].PostInstallScripts[0].Path", results[2].Path)
+	assert.Equal(t, "invalid script ("+nonExecutableScript+"):\nnot executable", results[2].Message)
+
+	assert.Equal(t, ruleFileSource, results[3].RuleId)
+	assert.Equal(t, "SystemConfigs[0].Services.PresetFiles[0]", results[3].Path)
+
+	assert.Equal(t, ruleFileSource, results[4].RuleId)
+	assert.Equal(t, "SystemConfigs[0].ContainerImages.AuthFile", results[4].Path)
+
+	assert.Equal(t, ruleFileSource, results[5].RuleId)
+	assert.Equal(t, "SystemConfigs[0].KernelModules.Modules[1].Path", results[5].Path)
+
+	assert.Equal(t, ruleFileSource, results[6].RuleId)
+	assert.Equal(t, "SystemConfigs[0].CACertificates[2]", results[6].Path)
+
+	assert.Equal(t, ruleFileSource, results[7].RuleId)
+	assert.Equal(t, "SystemConfigs[0].Repos[0].GPGKeys[1]", results[7].Path)
+
+	assert.Equal(t, ruleFileSource, results[8].RuleId)
+	assert.Equal(t, "SystemConfigs[0].PackageSignatures.GPGKeys[0]", results[8].Path)
+
+	assert.Equal(t, ruleFileSource, results[9].RuleId)
+	assert.Equal(t, "SystemConfigs[0].Users[0].SSHPubKeyPaths[1]", results[9].Path)
+}
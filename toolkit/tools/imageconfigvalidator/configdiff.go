@@ -0,0 +1,534 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/imagecustomizerapi"
+	"github.com/microsoft/azurelinux/toolkit/tools/imagegen/configuration"
+	"github.com/microsoft/azurelinux/toolkit/tools/imagegen/installutils"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/file"
+)
+
+const (
+	diffFormatText = "text"
+	diffFormatJson = "json"
+
+	diffStatusAdded   = "added"
+	diffStatusRemoved = "removed"
+	diffStatusChanged = "changed"
+
+	// The value of the fields that are secrets (i.e. passwords) in the diff.
+	redactedValue = "<redacted>"
+)
+
+// The fields of the config summaries whose values are not shown in the diff.
+var redactedFields = map[string]bool{
+	"Password": true,
+}
+
+// summaryItems are the items (e.g. packages) of a config summary, by name. Each item's fields (e.g. a partition's
+// mount point) are its values as strings, so that both config types summarize to the same form.
+type summaryItems map[string]map[string]string
+
+// systemConfigSummary is the part of a system config that reviewers of image changes care about.
+type systemConfigSummary struct {
+	name       string
+	packages   summaryItems
+	partitions summaryItems
+	users      summaryItems
+	kernelArgs summaryItems
+}
+
+func newSystemConfigSummary(name string) *systemConfigSummary {
+	return &systemConfigSummary{
+		name:       name,
+		packages:   make(summaryItems),
+		partitions: make(summaryItems),
+		users:      make(summaryItems),
+		kernelArgs: make(summaryItems),
+	}
+}
+
+// ItemChange is a changed field of an item (e.g. the size of a partition).
+type ItemChange struct {
+	Item  string `json:"Item"`
+	Field string `json:"Field"`
+	Old   string `json:"Old"`
+	New   string `json:"New"`
+}
+
+// ItemChanges are the changes to a kind of item (e.g. packages) of a system config.
+type ItemChanges struct {
+	Added   []string     `json:"Added,omitempty"`
+	Removed []string     `json:"Removed,omitempty"`
+	Changed []ItemChange `json:"Changed,omitempty"`
+}
+
+// SystemConfigDiff is the diff of a system config between two configs.
+type SystemConfigDiff struct {
+	Name string `json:"Name"`
+	// One of 'added', 'removed' or 'changed'.
+	Status     string      `json:"Status"`
+	Packages   ItemChanges `json:"Packages"`
+	Partitions ItemChanges `json:"Partitions"`
+	Users      ItemChanges `json:"Users"`
+	KernelArgs ItemChanges `json:"KernelArgs"`
+}
+
+// ConfigDiff is the machine-readable output of the diff command. Only the system configs that changed are listed.
+type ConfigDiff struct {
+	OldConfig     string             `json:"OldConfig"`
+	NewConfig     string             `json:"NewConfig"`
+	SystemConfigs []SystemConfigDiff `json:"SystemConfigs"`
+}
+
+// loadConfigSummaries summarizes the system configs of a config file. An imagecustomizer config has a single, unnamed,
+// system config. The relative paths of an imagegen config are relative to baseDir (or, if empty, to the config file's
+// dir).
+func loadConfigSummaries(configPath string, configType string, baseDir string,
+) (summaries []*systemConfigSummary, err error) {
+	switch detectConfigType(configPath, configType) {
+	case configTypeImageCustomizer:
+		config, err := loadCustomizerConfig(configPath)
+		if err != nil {
+			return nil, err
+		}
+
+		summary, err := summarizeCustomizerConfig(config, filepath.Dir(configPath))
+		if err != nil {
+			return nil, err
+		}
+		return []*systemConfigSummary{summary}, nil
+
+	default:
+		config, err := configuration.LoadWithAbsolutePaths(configPath, baseDir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load config file (%s):\n%w", configPath, err)
+		}
+
+		for _, systemConfig := range config.SystemConfigs {
+			summary, err := summarizeSystemConfig(config, systemConfig)
+			if err != nil {
+				return nil, err
+			}
+			summaries = append(summaries, summary)
+		}
+		return summaries, nil
+	}
+}
+
+func summarizeSystemConfig(config configuration.Config, systemConfig configuration.SystemConfig,
+) (summary *systemConfigSummary, err error) {
+	summary = newSystemConfigSummary(systemConfig.Name)
+
+	packages, err := installutils.PackageNamesFromSingleSystemConfig(systemConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the package lists of system config (%s):\n%w", systemConfig.Name, err)
+	}
+	for _, pkg := range packages {
+		summary.packages[pkg] = map[string]string{}
+	}
+
+	for _, disk := range config.Disks {
+		for _, partition := range disk.Partitions {
+			size := "grow"
+			if partition.End != 0 {
+				size = fmt.Sprintf("%d MiB", partition.End-partition.Start)
+			}
+
+			flags := []string(nil)
+			for _, flag := range partition.Flags {
+				flags = append(flags, string(flag))
+			}
+
+			summary.partitions[partition.ID] = map[string]string{
+				"Size":   size,
+				"FsType": partition.FsType,
+				"Type":   partition.Type,
+				"Name":   partition.Name,
+				"Flags":  strings.Join(flags, ","),
+			}
+		}
+	}
+	for _, partitionSetting := range systemConfig.PartitionSettings {
+		fields, found := summary.partitions[partitionSetting.ID]
+		if !found {
+			fields = map[string]string{}
+			summary.partitions[partitionSetting.ID] = fields
+		}
+		fields["MountPoint"] = partitionSetting.MountPoint
+		fields["MountOptions"] = partitionSetting.MountOptions
+		fields["MountIdentifier"] = string(partitionSetting.MountIdentifier)
+	}
+
+	for _, user := range systemConfig.Users {
+		fields := map[string]string{
+			"UID":             user.UID,
+			"Password":        user.Password,
+			"SSHPubKeyPaths":  strings.Join(user.SSHPubKeyPaths, ","),
+			"SSHPubKeys":      strings.Join(user.SSHPubKeys, ","),
+			"PrimaryGroup":    user.PrimaryGroup,
+			"SecondaryGroups": strings.Join(user.SecondaryGroups, ","),
+			"StartupCommand":  user.StartupCommand,
+			"HomeDirectory":   user.HomeDirectory,
+		}
+		if user.PasswordExpiresDays != 0 {
+			fields["PasswordExpiresDays"] = strconv.FormatInt(user.PasswordExpiresDays, 10)
+		}
+		summary.users[user.Name] = fields
+	}
+
+	kernelCommandLine := systemConfig.KernelCommandLine
+	addKernelArgs(summary.kernelArgs, kernelCommandLine.ExtraCommandLine)
+	// The kernel arguments that imager adds for the dedicated settings.
+	addKernelSetting(summary.kernelArgs, "SELinux", string(kernelCommandLine.SELinux))
+	addKernelSetting(summary.kernelArgs, "SELinuxPolicy", kernelCommandLine.SELinuxPolicy)
+	addKernelSetting(summary.kernelArgs, "CGroup", string(kernelCommandLine.CGroup))
+	if kernelCommandLine.EnableFIPS {
+		addKernelSetting(summary.kernelArgs, "EnableFIPS", "true")
+	}
+	imaPolicies := []string(nil)
+	for _, imaPolicy := range kernelCommandLine.ImaPolicy {
+		imaPolicies = append(imaPolicies, string(imaPolicy))
+	}
+	addKernelSetting(summary.kernelArgs, "ImaPolicy", strings.Join(imaPolicies, ","))
+
+	return summary, nil
+}
+
+func summarizeCustomizerConfig(config *imagecustomizerapi.Config, baseConfigPath string,
+) (summary *systemConfigSummary, err error) {
+	summary = newSystemConfigSummary("")
+
+	for _, disk := range config.Storage.Disks {
+		for _, partition := range disk.Partitions {
+			summary.partitions[partition.Id] = map[string]string{
+				"Size": customizerPartitionSize(partition),
+				"Type": string(partition.Type),
+				"Name": partition.Label,
+			}
+		}
+	}
+	for _, filesystem := range config.Storage.FileSystems {
+		fields, found := summary.partitions[filesystem.DeviceId]
+		if !found {
+			fields = map[string]string{}
+			summary.partitions[filesystem.DeviceId] = fields
+		}
+		fields["FsType"] = string(filesystem.Type)
+		if filesystem.MountPoint != nil {
+			fields["MountPoint"] = filesystem.MountPoint.Path
+			fields["MountOptions"] = filesystem.MountPoint.Options
+			fields["MountIdentifier"] = string(filesystem.MountPoint.IdType)
+		}
+	}
+
+	if config.OS == nil {
+		return summary, nil
+	}
+
+	for _, packageSet := range []struct {
+		action       string
+		packageLists []string
+		packages     []string
+	}{
+		{"install", config.OS.Packages.InstallLists, config.OS.Packages.Install},
+		{"update", config.OS.Packages.UpdateLists, config.OS.Packages.Update},
+		{"remove", config.OS.Packages.RemoveLists, config.OS.Packages.Remove},
+	} {
+		packages := packageSet.packages
+		for _, packageListPath := range packageSet.packageLists {
+			packageList, err := readCustomizerPackageList(baseConfigPath, packageListPath)
+			if err != nil {
+				return nil, err
+			}
+			packages = append(packages, packageList...)
+		}
+
+		for _, pkg := range packages {
+			summary.packages[pkg] = map[string]string{"Action": packageSet.action}
+		}
+	}
+
+	for _, user := range config.OS.Users {
+		// The fields have the names of the imagegen config's fields, so that both config types can be diffed.
+		fields := map[string]string{
+			"SSHPubKeyPaths":  strings.Join(user.SSHPublicKeyPaths, ","),
+			"SSHPubKeys":      strings.Join(user.SSHPublicKeys, ","),
+			"PrimaryGroup":    user.PrimaryGroup,
+			"SecondaryGroups": strings.Join(user.SecondaryGroups, ","),
+			"StartupCommand":  user.StartupCommand,
+			"HomeDirectory":   user.HomeDirectory,
+		}
+		if user.UID != nil {
+			fields["UID"] = strconv.Itoa(*user.UID)
+		}
+		if user.Password != nil {
+			fields["Password"] = string(user.Password.Type) + ":" + user.Password.Value
+		}
+		if user.PasswordExpiresDays != nil {
+			fields["PasswordExpiresDays"] = strconv.FormatInt(*user.PasswordExpiresDays, 10)
+		}
+		summary.users[user.Name] = fields
+	}
+
+	addKernelArgs(summary.kernelArgs, string(config.OS.KernelCommandLine.ExtraCommandLine))
+	addKernelSetting(summary.kernelArgs, "SELinux", string(config.OS.SELinux.Mode))
+
+	return summary, nil
+}
+
+func customizerPartitionSize(partition imagecustomizerapi.Partition) string {
+	switch {
+	case partition.Size.Type == imagecustomizerapi.PartitionSizeTypeGrow:
+		return "grow"
+
+	case partition.Size.Type == imagecustomizerapi.PartitionSizeTypeExplicit:
+		return partition.Size.Size.HumanReadable()
+
+	case partition.End != nil:
+		start := imagecustomizerapi.DiskSize(0)
+		if partition.Start != nil {
+			start = *partition.Start
+		}
+		return (*partition.End - start).HumanReadable()
+
+	default:
+		return "grow"
+	}
+}
+
+// addKernelArgs adds the arguments of a kernel command line to the summary. The arguments are the items, so that a
+// changed value (e.g. 'console=ttyS0' to 'console=tty1') shows as a removed and an added argument.
+func addKernelArgs(kernelArgs summaryItems, commandLine string) {
+	for _, arg := range strings.Fields(commandLine) {
+		kernelArgs[arg] = map[string]string{}
+	}
+}
+
+// addKernelSetting adds a setting that adds kernel arguments (e.g. 'EnableFIPS') to the summary, as '[name]=value'
+// (so that it's told apart from the arguments). Unset settings are not added.
+func addKernelSetting(kernelArgs summaryItems, name string, value string) {
+	if value == "" {
+		return
+	}
+	kernelArgs["["+name+"]="+value] = map[string]string{}
+}
+
+// diffConfigSummaries diffs the system configs of two configs by name. If both configs have a single system config,
+// they are diffed regardless of their names (e.g. when a system config is renamed, or when an imagegen config is
+// diffed with an imagecustomizer config).
+func diffConfigSummaries(oldSummaries []*systemConfigSummary, newSummaries []*systemConfigSummary,
+) (diffs []SystemConfigDiff) {
+	if len(oldSummaries) == 1 && len(newSummaries) == 1 {
+		diff := diffSystemConfigs(oldSummaries[0], newSummaries[0])
+		if diff.Name == "" {
+			diff.Name = oldSummaries[0].name
+		}
+		if hasItemChanges(diff) {
+			diffs = append(diffs, diff)
+		}
+		return diffs
+	}
+
+	newByName := make(map[string]*systemConfigSummary)
+	for _, summary := range newSummaries {
+		newByName[summary.name] = summary
+	}
+	oldByName := make(map[string]*systemConfigSummary)
+	for _, summary := range oldSummaries {
+		oldByName[summary.name] = summary
+	}
+
+	for _, oldSummary := range oldSummaries {
+		newSummary, found := newByName[oldSummary.name]
+		if !found {
+			diff := diffSystemConfigs(oldSummary, newSystemConfigSummary(oldSummary.name))
+			diff.Status = diffStatusRemoved
+			diffs = append(diffs, diff)
+			continue
+		}
+
+		diff := diffSystemConfigs(oldSummary, newSummary)
+		if hasItemChanges(diff) {
+			diffs = append(diffs, diff)
+		}
+	}
+
+	for _, newSummary := range newSummaries {
+		if _, found := oldByName[newSummary.name]; found {
+			continue
+		}
+
+		diff := diffSystemConfigs(newSystemConfigSummary(newSummary.name), newSummary)
+		diff.Status = diffStatusAdded
+		diffs = append(diffs, diff)
+	}
+
+	return diffs
+}
+
+func diffSystemConfigs(oldSummary *systemConfigSummary, newSummary *systemConfigSummary) SystemConfigDiff {
+	return SystemConfigDiff{
+		Name:       newSummary.name,
+		Status:     diffStatusChanged,
+		Packages:   diffSummaryItems(oldSummary.packages, newSummary.packages),
+		Partitions: diffSummaryItems(oldSummary.partitions, newSummary.partitions),
+		Users:      diffSummaryItems(oldSummary.users, newSummary.users),
+		KernelArgs: diffSummaryItems(oldSummary.kernelArgs, newSummary.kernelArgs),
+	}
+}
+
+func hasItemChanges(diff SystemConfigDiff) bool {
+	for _, changes := range []ItemChanges{diff.Packages, diff.Partitions, diff.Users, diff.KernelArgs} {
+		if len(changes.Added) > 0 || len(changes.Removed) > 0 || len(changes.Changed) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// diffSummaryItems returns the added, removed and changed items, sorted by name.
+func diffSummaryItems(oldItems summaryItems, newItems summaryItems) (changes ItemChanges) {
+	for _, name := range sortedKeys(oldItems) {
+		newFields, found := newItems[name]
+		if !found {
+			changes.Removed = append(changes.Removed, name)
+			continue
+		}
+
+		oldFields := oldItems[name]
+		fieldNames := make(map[string]bool)
+		for fieldName := range oldFields {
+			fieldNames[fieldName] = true
+		}
+		for fieldName := range newFields {
+			fieldNames[fieldName] = true
+		}
+
+		for _, fieldName := range sortedKeys(fieldNames) {
+			oldValue, newValue := oldFields[fieldName], newFields[fieldName]
+			if oldValue == newValue {
+				continue
+			}
+
+			if redactedFields[fieldName] {
+				oldValue, newValue = redactValue(oldValue), redactValue(newValue)
+			}
+			changes.Changed = append(changes.Changed, ItemChange{
+				Item:  name,
+				Field: fieldName,
+				Old:   oldValue,
+				New:   newValue,
+			})
+		}
+	}
+
+	for _, name := range sortedKeys(newItems) {
+		if _, found := oldItems[name]; !found {
+			changes.Added = append(changes.Added, name)
+		}
+	}
+
+	return changes
+}
+
+func redactValue(value string) string {
+	if value == "" {
+		return ""
+	}
+	return redactedValue
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// writeConfigDiff writes the diff as text or as JSON to the output path (or to stdout, for "-").
+func writeConfigDiff(diff ConfigDiff, format string, outputPath string) (err error) {
+	var output string
+	switch format {
+	case diffFormatJson:
+		if diff.SystemConfigs == nil {
+			diff.SystemConfigs = []SystemConfigDiff{}
+		}
+
+		outputBytes, err := json.MarshalIndent(diff, "", " ")
+		if err != nil {
+			return fmt.Errorf("failed to serialize the config diff:\n%w", err)
+		}
+		output = string(outputBytes) + "\n"
+
+	default:
+		builder := strings.Builder{}
+		formatConfigDiff(&builder, diff)
+		output = builder.String()
+	}
+
+	if outputPath == "-" {
+		_, err = fmt.Fprint(os.Stdout, output)
+		if err != nil {
+			return fmt.Errorf("failed to write the config diff to stdout:\n%w", err)
+		}
+		return nil
+	}
+
+	err = file.Write(output, outputPath)
+	if err != nil {
+		return fmt.Errorf("failed to write the config diff (%s):\n%w", outputPath, err)
+	}
+	return nil
+}
+
+// formatConfigDiff writes the diff as text, with '+' for added items, '-' for removed items and '~' for changed items.
+func formatConfigDiff(w io.Writer, diff ConfigDiff) {
+	fmt.Fprintf(w, "--- %s\n+++ %s\n", diff.OldConfig, diff.NewConfig)
+	if len(diff.SystemConfigs) == 0 {
+		fmt.Fprintln(w, "No changes")
+		return
+	}
+
+	for _, systemConfigDiff := range diff.SystemConfigs {
+		fmt.Fprintf(w, "\nSystem config (%s): %s\n", systemConfigDiff.Name, systemConfigDiff.Status)
+		for _, section := range []struct {
+			title   string
+			changes ItemChanges
+		}{
+			{"Packages", systemConfigDiff.Packages},
+			{"Partitions", systemConfigDiff.Partitions},
+			{"Users", systemConfigDiff.Users},
+			{"Kernel args", systemConfigDiff.KernelArgs},
+		} {
+			changes := section.changes
+			if len(changes.Added) == 0 && len(changes.Removed) == 0 && len(changes.Changed) == 0 {
+				continue
+			}
+
+			fmt.Fprintf(w, "  %s:\n", section.title)
+			for _, name := range changes.Added {
+				fmt.Fprintf(w, "    + %s\n", name)
+			}
+			for _, name := range changes.Removed {
+				fmt.Fprintf(w, "    - %s\n", name)
+			}
+			for _, change := range changes.Changed {
+				fmt.Fprintf(w, "    ~ %s: %s: %q -> %q\n", change.Item, change.Field, change.Old, change.New)
+			}
+		}
+	}
+}
@@ -0,0 +1,99 @@
+// Copyright Microsoft Corporation.
+// Licensed under the MIT License.
+
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffSummaryItems(t *testing.T) {
+	oldItems := summaryItems{
+		"root":    {"UID": "0", "Password": "old"},
+		"removed": {},
+	}
+	newItems := summaryItems{
+		"root":  {"UID": "0", "Password": "new", "HomeDirectory": "/root"},
+		"added": {},
+	}
+
+	changes := diffSummaryItems(oldItems, newItems)
+	assert.Equal(t, []string{"added"}, changes.Added)
+	assert.Equal(t, []string{"removed"}, changes.Removed)
+	assert.Equal(t, []ItemChange{
+		{Item: "root", Field: "HomeDirectory", Old: "", New: "/root"},
+		{Item: "root", Field: "Password", Old: redactedValue, New: redactedValue},
+	}, changes.Changed)
+}
+
+func TestDiffImageGenConfigs(t *testing.T) {
+	oldSummaries, err := loadConfigSummaries("../../imageconfigs/core-efi.json", configTypeAuto, "")
+	if !assert.NoError(t, err) {
+		return
+	}
+	newSummaries, err := loadConfigSummaries("../../imageconfigs/core-fips.json", configTypeAuto, "")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	diffs := diffConfigSummaries(oldSummaries, newSummaries)
+	if !assert.Len(t, diffs, 1) {
+		return
+	}
+	assert.Equal(t, "Standard", diffs[0].Name)
+	assert.Equal(t, diffStatusChanged, diffs[0].Status)
+	assert.Contains(t, diffs[0].Packages.Added, "dracut-fips")
+	assert.Contains(t, diffs[0].KernelArgs.Added, "fips=1")
+	assert.Empty(t, diffs[0].Users.Added)
+}
+
+func TestDiffSameConfigHasNoChanges(t *testing.T) {
+	summaries, err := loadConfigSummaries("../../imageconfigs/core-efi.json", configTypeAuto, "")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Empty(t, diffConfigSummaries(summaries, summaries))
+
+	output := strings.Builder{}
+	formatConfigDiff(&output, ConfigDiff{OldConfig: "a.json", NewConfig: "b.json"})
+	assert.Equal(t, "--- a.json\n+++ b.json\nNo changes\n", output.String())
+}
+
+func TestDiffAddedAndRemovedSystemConfigs(t *testing.T) {
+	oldSummaries := []*systemConfigSummary{newSystemConfigSummary("A"), newSystemConfigSummary("B")}
+	oldSummaries[1].packages["kernel"] = map[string]string{}
+	newSummaries := []*systemConfigSummary{newSystemConfigSummary("A"), newSystemConfigSummary("C")}
+
+	diffs := diffConfigSummaries(oldSummaries, newSummaries)
+	if !assert.Len(t, diffs, 2) {
+		return
+	}
+	assert.Equal(t, "B", diffs[0].Name)
+	assert.Equal(t, diffStatusRemoved, diffs[0].Status)
+	assert.Equal(t, []string{"kernel"}, diffs[0].Packages.Removed)
+	assert.Equal(t, "C", diffs[1].Name)
+	assert.Equal(t, diffStatusAdded, diffs[1].Status)
+
+	output := strings.Builder{}
+	formatConfigDiff(&output, ConfigDiff{OldConfig: "old.json", NewConfig: "new.json", SystemConfigs: diffs})
+	assert.Equal(t, "--- old.json\n+++ new.json\n\nSystem config (B): removed\n  Packages:\n    - kernel\n\n"+
+		"System config (C): added\n", output.String())
+}
+
+func TestDiffCustomizerConfig(t *testing.T) {
+	summaries, err := loadConfigSummaries(filepath.Join(testCustomizerConfigDir, "config.yaml"), configTypeAuto, "")
+	if !assert.NoError(t, err) || !assert.Len(t, summaries, 1) {
+		return
+	}
+
+	assert.Equal(t, summaryItems{
+		"words":              {"Action": "install"},
+		"shadow-utils>=4.14": {"Action": "install"},
+		"useradd-tools":      {"Action": "install"},
+	}, summaries[0].packages)
+}
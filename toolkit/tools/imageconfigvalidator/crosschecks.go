@@ -0,0 +1,467 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/imagegen/configuration"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/pkgjson"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/sliceutils"
+)
+
+const (
+	packageListsValidateError = "failed to validate package lists in config"
+
+	kernelPkgName         = "kernel"
+	dracutFipsPkgName     = "dracut-fips"
+	userAddPkgName        = "shadow-utils"
+	sshServerPkgName      = "openssh-server"
+	veritySetupPkgName    = "veritysetup"
+	iscsiPkgName          = "iscsi-initiator-utils"
+	networkdPkgName       = "systemd-networkd"
+	networkManagerPkgName = "NetworkManager"
+	tpm2PkgName           = "tpm2-tss"
+	nftablesPkgName       = "nftables"
+	containerdPkgName     = "containerd2"
+	dockerPkgName         = "moby-engine"
+	develPkgSuffix        = "-devel"
+	glibcLocalesPkgName   = "glibc-locales-all"
+	tzdataPkgName         = "tzdata"
+	kbdPkgName            = "kbd"
+	// Both ca-certificates packages install update-ca-trust, which builds the trust store.
+	caCertificatesPkgName     = "ca-certificates"
+	caCertificatesBasePkgName = "ca-certificates-base"
+
+	fipsKernelCmdLine = "fips=1"
+
+	networkManagerConfigDir = "/etc/NetworkManager"
+)
+
+// crossCheckContext is a system config, with its package list and kernel command line parsed, that the cross check
+// rules check.
+type crossCheckContext struct {
+	systemConfig configuration.SystemConfig
+	packages     map[string]bool
+	cmdlineArgs  []string
+}
+
+// crossCheckRule is a cross check between the features of a system config (e.g. its kernel command line) and its
+// other settings (e.g. its package lists).
+type crossCheckRule struct {
+	id       string
+	severity ValidationSeverity
+	// The location of the checked setting, relative to the system config.
+	path string
+	// check returns the failure message of the rule, or "" if the system config passes the rule.
+	check func(ctx *crossCheckContext) string
+}
+
+// crossCheckRules are the cross check rules, in the order that they are checked.
+var crossCheckRules = []crossCheckRule{
+	{
+		id:       rulePackagesKernel,
+		severity: SeverityError,
+		path:     "PackageLists",
+		check: func(ctx *crossCheckContext) string {
+			if ctx.hasPackage(kernelPkgName) {
+				return packageListsValidateError + ": kernel should not be included in a package list, add via config " +
+					"file's [KernelOptions] entry"
+			}
+			return ""
+		},
+	},
+	{
+		id:       rulePackagesFips,
+		severity: SeverityError,
+		path:     "KernelCommandLine",
+		check: requirePackage(
+			func(ctx *crossCheckContext) string {
+				if strings.Contains(ctx.systemConfig.KernelCommandLine.ExtraCommandLine, fipsKernelCmdLine) ||
+					ctx.systemConfig.KernelCommandLine.EnableFIPS {
+					return "'fips=1' provided on kernel cmdline"
+				}
+				return ""
+			},
+			constantPackage(dracutFipsPkgName)),
+	},
+	{
+		id:       rulePackagesSELinux,
+		severity: SeverityError,
+		path:     "KernelCommandLine.SELinux",
+		check: requirePackage(
+			func(ctx *crossCheckContext) string {
+				if ctx.systemConfig.KernelCommandLine.SELinux != configuration.SELinuxOff {
+					return "[SELinux] selected"
+				}
+				return ""
+			},
+			selinuxPolicyPackage),
+	},
+	{
+		id:       rulePackagesUserAdd,
+		severity: SeverityError,
+		path:     "PackageLists",
+		check: func(ctx *crossCheckContext) string {
+			if (len(ctx.systemConfig.Users) > 0 || len(ctx.systemConfig.Groups) > 0) && !ctx.hasPackage(userAddPkgName) {
+				return fmt.Sprintf("%s: the '%s' package must be included in the package lists when the image is "+
+					"configured to add users or groups", packageListsValidateError, userAddPkgName)
+			}
+			return ""
+		},
+	},
+	{
+		id:       rulePackagesSshServer,
+		severity: SeverityWarning,
+		path:     "Users",
+		check: func(ctx *crossCheckContext) string {
+			if ctx.hasPackage(sshServerPkgName) {
+				return ""
+			}
+			for _, user := range ctx.systemConfig.Users {
+				if len(user.SSHPubKeyPaths) > 0 || len(user.SSHPubKeys) > 0 {
+					return fmt.Sprintf("user (%s) has SSH public keys, but the '%s' package is not included in the "+
+						"package lists, so the user can't log in over SSH", user.Name, sshServerPkgName)
+				}
+			}
+			return ""
+		},
+	},
+	{
+		id:       rulePackagesVerity,
+		severity: SeverityError,
+		path:     "KernelCommandLine.ExtraCommandLine",
+		check: requirePackage(
+			cmdlineArgReason("roothash", "systemd.verity", "rd.systemd.verity", "systemd.verity_root_data",
+				"systemd.verity_root_hash", "systemd.verity_root_options"),
+			constantPackage(veritySetupPkgName)),
+	},
+	{
+		id:       rulePackagesIscsi,
+		severity: SeverityError,
+		path:     "KernelCommandLine.ExtraCommandLine",
+		check: requirePackage(
+			func(ctx *crossCheckContext) string {
+				for _, arg := range ctx.cmdlineArgs {
+					if strings.HasPrefix(arg, "netroot=iscsi:") || strings.HasPrefix(arg, "rd.iscsi.") {
+						return fmt.Sprintf("iSCSI root ('%s') provided on kernel cmdline", arg)
+					}
+				}
+				return ""
+			},
+			constantPackage(iscsiPkgName)),
+	},
+	{
+		id:       ruleSELinuxCmdlinePackage,
+		severity: SeverityError,
+		path:     "KernelCommandLine.ExtraCommandLine",
+		check:    requirePackage(selinuxCmdlineReason, selinuxPolicyPackage),
+	},
+	{
+		id:       ruleSELinuxCmdlineMode,
+		severity: SeverityError,
+		path:     "KernelCommandLine.SELinux",
+		check: func(ctx *crossCheckContext) string {
+			reason := selinuxCmdlineReason(ctx)
+			if reason != "" && ctx.systemConfig.KernelCommandLine.SELinux == configuration.SELinuxOff {
+				return fmt.Sprintf("%s, but [SELinux] is not set, so the image's files are not labeled with the SELinux "+
+					"policy (set [SELinux] to 'enforcing' or 'permissive' instead)", reason)
+			}
+			return ""
+		},
+	},
+	{
+		id:       rulePackagesNetworkd,
+		severity: SeverityError,
+		path:     "Networks",
+		check: requirePackage(
+			func(ctx *crossCheckContext) string {
+				if len(ctx.systemConfig.Networks) > 0 {
+					return "[Networks] configured (as systemd-networkd config files)"
+				}
+				return ""
+			},
+			constantPackage(networkdPkgName)),
+	},
+	{
+		id:       rulePackagesNetworkManager,
+		severity: SeverityWarning,
+		path:     "AdditionalFiles",
+		check: requirePackage(
+			func(ctx *crossCheckContext) string {
+				for _, fileConfigs := range ctx.systemConfig.AdditionalFiles {
+					for _, fileConfig := range fileConfigs {
+						if isPathUnder(fileConfig.Path, networkManagerConfigDir) {
+							return fmt.Sprintf("NetworkManager config file (%s) added", fileConfig.Path)
+						}
+					}
+				}
+				return ""
+			},
+			constantPackage(networkManagerPkgName)),
+	},
+	{
+		id:       rulePackagesSudo,
+		severity: SeverityWarning,
+		path:     "Users",
+		check:    requirePackage(sudoReason, constantPackage(sudoPkgName)),
+	},
+	{
+		id:       rulePackagesTpm2,
+		severity: SeverityError,
+		path:     "Encryption.TPM2",
+		check: requirePackage(
+			func(ctx *crossCheckContext) string {
+				if ctx.systemConfig.Encryption.Enable && ctx.systemConfig.Encryption.TPM2.Enable {
+					return "[Encryption] unlocks with a [TPM2] token (the initramfs needs the 'tpm2-tss' dracut module)"
+				}
+				return ""
+			},
+			constantPackage(tpm2PkgName)),
+	},
+	{
+		id:       rulePackagesNftables,
+		severity: SeverityError,
+		path:     "Firewall",
+		check: requirePackage(
+			func(ctx *crossCheckContext) string {
+				if ctx.systemConfig.Firewall.Enable {
+					return "[Firewall] enabled (as an nftables ruleset)"
+				}
+				return ""
+			},
+			constantPackage(nftablesPkgName)),
+	},
+	{
+		id:       rulePackagesContainerRuntime,
+		severity: SeverityError,
+		path:     "ContainerImages",
+		check: requirePackage(
+			func(ctx *crossCheckContext) string {
+				if !ctx.systemConfig.ContainerImages.IsEmpty() {
+					return fmt.Sprintf("[ContainerImages] preloaded (into %s)", ctx.systemConfig.ContainerImages.GetRuntime())
+				}
+				return ""
+			},
+			containerRuntimePackage),
+	},
+	{
+		id:       rulePackagesKernelDevel,
+		severity: SeverityError,
+		path:     "KernelModules.SigningKey",
+		check: requirePackage(
+			func(ctx *crossCheckContext) string {
+				if ctx.systemConfig.KernelModules.IsSigned() {
+					return "[KernelModules] signed (with the kernel's 'sign-file' tool)"
+				}
+				return ""
+			},
+			kernelDevelPackage),
+	},
+	{
+		id:       rulePackagesLocales,
+		severity: SeverityError,
+		path:     "Localization.Locale",
+		check: requirePackage(
+			func(ctx *crossCheckContext) string {
+				if !ctx.systemConfig.Localization.IsBuiltinLocale() {
+					return fmt.Sprintf("[Locale] (%s) selected", ctx.systemConfig.Localization.Locale)
+				}
+				return ""
+			},
+			constantPackage(glibcLocalesPkgName)),
+	},
+	{
+		id:       rulePackagesTzdata,
+		severity: SeverityError,
+		path:     "Localization.Timezone",
+		check: requirePackage(
+			func(ctx *crossCheckContext) string {
+				if ctx.systemConfig.Localization.Timezone != "" {
+					return fmt.Sprintf("[Timezone] (%s) selected", ctx.systemConfig.Localization.Timezone)
+				}
+				return ""
+			},
+			constantPackage(tzdataPkgName)),
+	},
+	{
+		id:       rulePackagesKbd,
+		severity: SeverityError,
+		path:     "Localization.Keymap",
+		check: requirePackage(
+			func(ctx *crossCheckContext) string {
+				if ctx.systemConfig.Localization.Keymap != "" {
+					return fmt.Sprintf("[Keymap] (%s) selected", ctx.systemConfig.Localization.Keymap)
+				}
+				return ""
+			},
+			constantPackage(kbdPkgName)),
+	},
+	{
+		id:       rulePackagesCaCertificates,
+		severity: SeverityError,
+		path:     "CACertificates",
+		check: func(ctx *crossCheckContext) string {
+			if len(ctx.systemConfig.CACertificates) == 0 || ctx.hasPackage(caCertificatesPkgName) ||
+				ctx.hasPackage(caCertificatesBasePkgName) {
+				return ""
+			}
+			return fmt.Sprintf("%s: [CACertificates] added to the trust store, but neither the '%s' nor the '%s' "+
+				"package is included in the package lists", packageListsValidateError, caCertificatesPkgName,
+				caCertificatesBasePkgName)
+		},
+	},
+	{
+		id:       rulePackagesRemoved,
+		severity: SeverityError,
+		path:     "RemovePackages",
+		check: func(ctx *crossCheckContext) string {
+			for _, pkg := range ctx.systemConfig.RemovePackages {
+				if ctx.packages[pkg] {
+					return fmt.Sprintf("%s: package (%s) is in [RemovePackages], but it is also included in the "+
+						"package lists", packageListsValidateError, pkg)
+				}
+			}
+			return ""
+		},
+	},
+	{
+		id:       rulePackagesSshHostKeys,
+		severity: SeverityWarning,
+		path:     "InstanceIdentity.SSHHostKeys",
+		check: requirePackage(
+			func(ctx *crossCheckContext) string {
+				if ctx.systemConfig.InstanceIdentity.SSHHostKeys.IsPreserved() {
+					return "[SSHHostKeys] preserved"
+				}
+				return ""
+			},
+			constantPackage(sshServerPkgName)),
+	},
+}
+
+// requirePackage returns a check that the package is included in the package lists if the reason (e.g. a kernel
+// argument) applies to the system config.
+func requirePackage(reason func(ctx *crossCheckContext) string, pkgName func(ctx *crossCheckContext) string,
+) func(ctx *crossCheckContext) string {
+	return func(ctx *crossCheckContext) string {
+		reasonString := reason(ctx)
+		if reasonString == "" || ctx.hasPackage(pkgName(ctx)) {
+			return ""
+		}
+		return fmt.Sprintf("%s: %s, but '%s' package is not included in the package lists", packageListsValidateError,
+			reasonString, pkgName(ctx))
+	}
+}
+
+func constantPackage(pkgName string) func(ctx *crossCheckContext) string {
+	return func(ctx *crossCheckContext) string {
+		return pkgName
+	}
+}
+
+func containerRuntimePackage(ctx *crossCheckContext) string {
+	if ctx.systemConfig.ContainerImages.GetRuntime() == configuration.ContainerRuntimeDocker {
+		return dockerPkgName
+	}
+	return containerdPkgName
+}
+
+func kernelDevelPackage(ctx *crossCheckContext) string {
+	if kernelPkg := ctx.systemConfig.KernelOptions["default"]; kernelPkg != "" {
+		return kernelPkg + develPkgSuffix
+	}
+	return kernelPkgName + develPkgSuffix
+}
+
+func selinuxPolicyPackage(ctx *crossCheckContext) string {
+	if ctx.systemConfig.KernelCommandLine.SELinuxPolicy != "" {
+		return ctx.systemConfig.KernelCommandLine.SELinuxPolicy
+	}
+	return configuration.SELinuxPolicyDefault
+}
+
+// cmdlineArgReason returns a reason that applies if any of the kernel arguments is provided.
+func cmdlineArgReason(argNames ...string) func(ctx *crossCheckContext) string {
+	return func(ctx *crossCheckContext) string {
+		for _, argName := range argNames {
+			if ctx.hasCmdlineArg(argName, "") {
+				return fmt.Sprintf("'%s' provided on kernel cmdline", argName)
+			}
+		}
+		return ""
+	}
+}
+
+func selinuxCmdlineReason(ctx *crossCheckContext) string {
+	if ctx.hasCmdlineArg("selinux", "1") {
+		return "'selinux=1' provided on kernel cmdline"
+	}
+	if ctx.hasCmdlineArg("security", "selinux") {
+		return "'security=selinux' provided on kernel cmdline"
+	}
+	return ""
+}
+
+func isPathUnder(path string, dir string) bool {
+	relativePath, err := filepath.Rel(dir, filepath.Clean(path))
+	return err == nil && filepath.IsLocal(relativePath)
+}
+
+// newCrossCheckContext parses the package list and the kernel command line of a system config.
+func newCrossCheckContext(systemConfig configuration.SystemConfig, packageList []string) (*crossCheckContext, error) {
+	ctx := &crossCheckContext{
+		systemConfig: systemConfig,
+		packages:     make(map[string]bool),
+		cmdlineArgs:  strings.Fields(systemConfig.KernelCommandLine.ExtraCommandLine),
+	}
+
+	for _, pkg := range packageList {
+		pkgVer, err := pkgjson.PackageStringToPackageVer(pkg)
+		if err != nil {
+			return nil, err
+		}
+		ctx.packages[pkgVer.Name] = true
+	}
+
+	return ctx, nil
+}
+
+// hasPackage returns true if the package lists include the package, and it isn't removed by [RemovePackages].
+func (ctx *crossCheckContext) hasPackage(pkgName string) bool {
+	return ctx.packages[pkgName] && !sliceutils.ContainsValue(ctx.systemConfig.RemovePackages, pkgName)
+}
+
+// hasCmdlineArg returns true if the kernel argument is provided, with the value (or with any value, if the value is
+// empty).
+func (ctx *crossCheckContext) hasCmdlineArg(name string, value string) bool {
+	for _, arg := range ctx.cmdlineArgs {
+		argName, argValue, _ := strings.Cut(arg, "=")
+		if argName == name && (value == "" || argValue == value) {
+			return true
+		}
+	}
+	return false
+}
+
+// runCrossCheckRules runs the rules against a system config.
+func runCrossCheckRules(rules []crossCheckRule, systemConfigIndex int, ctx *crossCheckContext,
+) (results []ValidationResult) {
+	for _, rule := range rules {
+		message := rule.check(ctx)
+		if message == "" {
+			continue
+		}
+
+		path := systemConfigPath(systemConfigIndex, rule.path)
+		if rule.severity == SeverityWarning {
+			results = append(results, newValidationWarning(rule.id, path, message))
+		} else {
+			results = append(results, newValidationResult(rule.id, path, fmt.Errorf("%s", message)))
+		}
+	}
+	return
+}
@@ -0,0 +1,335 @@
+// Copyright Microsoft Corporation.
+// Licensed under the MIT License.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/imagegen/configuration"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCrossCheckRules(t *testing.T) {
+	tests := []struct {
+		name         string
+		systemConfig configuration.SystemConfig
+		packages     []string
+		ruleIds      []string
+		path         string
+		message      string
+	}{
+		{
+			name:         "verity args without veritysetup",
+			systemConfig: configuration.SystemConfig{KernelCommandLine: configuration.KernelCommandLine{ExtraCommandLine: "roothash=0123 ro"}},
+			ruleIds:      []string{rulePackagesVerity},
+			path:         "SystemConfigs[0].KernelCommandLine.ExtraCommandLine",
+			message: "failed to validate package lists in config: 'roothash' provided on kernel cmdline, but 'veritysetup' " +
+				"package is not included in the package lists",
+		},
+		{
+			name:         "verity args with veritysetup",
+			systemConfig: configuration.SystemConfig{KernelCommandLine: configuration.KernelCommandLine{ExtraCommandLine: "systemd.verity=1"}},
+			packages:     []string{"veritysetup"},
+		},
+		{
+			name:         "iscsi root without iscsi-initiator-utils",
+			systemConfig: configuration.SystemConfig{KernelCommandLine: configuration.KernelCommandLine{ExtraCommandLine: "netroot=iscsi:10.0.0.1::::iqn.2024-01.com.example:root"}},
+			ruleIds:      []string{rulePackagesIscsi},
+			path:         "SystemConfigs[0].KernelCommandLine.ExtraCommandLine",
+			message: "failed to validate package lists in config: iSCSI root ('netroot=iscsi:10.0.0.1::::iqn.2024-01.com.example:root') " +
+				"provided on kernel cmdline, but 'iscsi-initiator-utils' package is not included in the package lists",
+		},
+		{
+			name:         "iscsi root with iscsi-initiator-utils",
+			systemConfig: configuration.SystemConfig{KernelCommandLine: configuration.KernelCommandLine{ExtraCommandLine: "rd.iscsi.firmware=1"}},
+			packages:     []string{"iscsi-initiator-utils"},
+		},
+		{
+			name:         "selinux=1 without a policy or a mode",
+			systemConfig: configuration.SystemConfig{KernelCommandLine: configuration.KernelCommandLine{ExtraCommandLine: "selinux=1"}},
+			ruleIds:      []string{ruleSELinuxCmdlinePackage, ruleSELinuxCmdlineMode},
+			path:         "SystemConfigs[0].KernelCommandLine.ExtraCommandLine",
+			message: "failed to validate package lists in config: 'selinux=1' provided on kernel cmdline, but 'selinux-policy' " +
+				"package is not included in the package lists",
+		},
+		{
+			name:         "security=selinux with a policy but without a mode",
+			systemConfig: configuration.SystemConfig{KernelCommandLine: configuration.KernelCommandLine{ExtraCommandLine: "security=selinux"}},
+			packages:     []string{"selinux-policy"},
+			ruleIds:      []string{ruleSELinuxCmdlineMode},
+			path:         "SystemConfigs[0].KernelCommandLine.SELinux",
+			message: "'security=selinux' provided on kernel cmdline, but [SELinux] is not set, so the image's files are not " +
+				"labeled with the SELinux policy (set [SELinux] to 'enforcing' or 'permissive' instead)",
+		},
+		{
+			name: "selinux=1 with a custom policy and a mode",
+			systemConfig: configuration.SystemConfig{KernelCommandLine: configuration.KernelCommandLine{
+				ExtraCommandLine: "selinux=1",
+				SELinux:          configuration.SELinuxPermissive,
+				SELinuxPolicy:    "custom-policy",
+			}},
+			packages: []string{"custom-policy"},
+		},
+		{
+			name:         "selinux=0",
+			systemConfig: configuration.SystemConfig{KernelCommandLine: configuration.KernelCommandLine{ExtraCommandLine: "selinux=0"}},
+		},
+		{
+			name:         "networks without systemd-networkd",
+			systemConfig: configuration.SystemConfig{Networks: []configuration.Network{{Device: "eth0"}}},
+			ruleIds:      []string{rulePackagesNetworkd},
+			path:         "SystemConfigs[0].Networks",
+			message: "failed to validate package lists in config: [Networks] configured (as systemd-networkd config files), " +
+				"but 'systemd-networkd' package is not included in the package lists",
+		},
+		{
+			name: "NetworkManager config without NetworkManager",
+			systemConfig: configuration.SystemConfig{AdditionalFiles: map[string]configuration.FileConfigList{
+				"eth0.nmconnection": {{Path: "/etc/NetworkManager/system-connections/eth0.nmconnection"}},
+			}},
+			ruleIds: []string{rulePackagesNetworkManager},
+			path:    "SystemConfigs[0].AdditionalFiles",
+			message: "failed to validate package lists in config: NetworkManager config file " +
+				"(/etc/NetworkManager/system-connections/eth0.nmconnection) added, but 'NetworkManager' package is not " +
+				"included in the package lists",
+		},
+		{
+			name: "file outside of the NetworkManager config dir",
+			systemConfig: configuration.SystemConfig{AdditionalFiles: map[string]configuration.FileConfigList{
+				"NetworkManager.conf": {{Path: "/etc/NetworkManager.conf"}},
+			}},
+		},
+		{
+			name: "wheel user without sudo",
+			systemConfig: configuration.SystemConfig{Users: []configuration.User{
+				{Name: "admin", SecondaryGroups: []string{"wheel"}},
+			}},
+			packages: []string{"shadow-utils"},
+			ruleIds:  []string{rulePackagesSudo},
+			path:     "SystemConfigs[0].Users",
+			message: "failed to validate package lists in config: user (admin) is in the 'wheel' group, but 'sudo' " +
+				"package is not included in the package lists",
+		},
+		{
+			name: "sudoers file with sudo",
+			systemConfig: configuration.SystemConfig{AdditionalFiles: map[string]configuration.FileConfigList{
+				"admin": {{Path: "/etc/sudoers.d/admin"}},
+			}},
+			packages: []string{"sudo"},
+		},
+		{
+			name: "tpm2 unlock without tpm2-tss",
+			systemConfig: configuration.SystemConfig{Encryption: configuration.RootEncryption{
+				Enable: true,
+				TPM2:   configuration.TPM2Unlock{Enable: true},
+			}},
+			ruleIds: []string{rulePackagesTpm2},
+			path:    "SystemConfigs[0].Encryption.TPM2",
+			message: "failed to validate package lists in config: [Encryption] unlocks with a [TPM2] token (the initramfs " +
+				"needs the 'tpm2-tss' dracut module), but 'tpm2-tss' package is not included in the package lists",
+		},
+		{
+			name: "tpm2 unlock with tpm2-tss",
+			systemConfig: configuration.SystemConfig{Encryption: configuration.RootEncryption{
+				Enable: true,
+				TPM2:   configuration.TPM2Unlock{Enable: true},
+			}},
+			packages: []string{"tpm2-tss"},
+		},
+		{
+			name:         "firewall without nftables",
+			systemConfig: configuration.SystemConfig{Firewall: configuration.Firewall{Enable: true}},
+			ruleIds:      []string{rulePackagesNftables},
+			path:         "SystemConfigs[0].Firewall",
+			message: "failed to validate package lists in config: [Firewall] enabled (as an nftables ruleset), but " +
+				"'nftables' package is not included in the package lists",
+		},
+		{
+			name:         "firewall with nftables",
+			systemConfig: configuration.SystemConfig{Firewall: configuration.Firewall{Enable: true}},
+			packages:     []string{"nftables"},
+		},
+		{
+			name: "container images without containerd",
+			systemConfig: configuration.SystemConfig{ContainerImages: configuration.ContainerImages{
+				Images: []configuration.ContainerImage{{Reference: "mcr.microsoft.com/azurelinux/base/core:3.0"}},
+			}},
+			ruleIds: []string{rulePackagesContainerRuntime},
+			path:    "SystemConfigs[0].ContainerImages",
+			message: "failed to validate package lists in config: [ContainerImages] preloaded (into containerd), but " +
+				"'containerd2' package is not included in the package lists",
+		},
+		{
+			name: "container images with docker",
+			systemConfig: configuration.SystemConfig{ContainerImages: configuration.ContainerImages{
+				Runtime: "docker",
+				Images:  []configuration.ContainerImage{{Reference: "mcr.microsoft.com/azurelinux/base/core:3.0"}},
+			}},
+			packages: []string{"moby-engine"},
+		},
+		{
+			name: "signed kernel modules without kernel devel",
+			systemConfig: configuration.SystemConfig{
+				KernelOptions: map[string]string{"default": "kernel-hwe"},
+				KernelModules: configuration.KernelModules{
+					SigningKey:  "signing_key.pem",
+					SigningCert: "signing_key.x509",
+					Modules:     []configuration.KernelModule{{Path: "nvidia.ko"}},
+				},
+			},
+			packages: []string{"kernel-devel"},
+			ruleIds:  []string{rulePackagesKernelDevel},
+			path:     "SystemConfigs[0].KernelModules.SigningKey",
+			message: "failed to validate package lists in config: [KernelModules] signed (with the kernel's 'sign-file' " +
+				"tool), but 'kernel-hwe-devel' package is not included in the package lists",
+		},
+		{
+			name: "unsigned kernel modules",
+			systemConfig: configuration.SystemConfig{KernelModules: configuration.KernelModules{
+				Modules: []configuration.KernelModule{{Path: "nvidia.ko"}},
+			}},
+		},
+		{
+			name: "locale without locales package",
+			systemConfig: configuration.SystemConfig{Localization: configuration.Localization{
+				Locale: "de_DE.UTF-8",
+			}},
+			ruleIds: []string{rulePackagesLocales},
+			path:    "SystemConfigs[0].Localization.Locale",
+			message: "failed to validate package lists in config: [Locale] (de_DE.UTF-8) selected, but " +
+				"'glibc-locales-all' package is not included in the package lists",
+		},
+		{
+			name: "builtin locale",
+			systemConfig: configuration.SystemConfig{Localization: configuration.Localization{
+				Locale: "en_US.UTF-8",
+			}},
+		},
+		{
+			name: "timezone and keymap without packages",
+			systemConfig: configuration.SystemConfig{Localization: configuration.Localization{
+				Timezone: "Europe/Berlin",
+				Keymap:   "de-latin1",
+			}},
+			ruleIds: []string{rulePackagesTzdata, rulePackagesKbd},
+			path:    "SystemConfigs[0].Localization.Timezone",
+			message: "failed to validate package lists in config: [Timezone] (Europe/Berlin) selected, but " +
+				"'tzdata' package is not included in the package lists",
+		},
+		{
+			name: "timezone and keymap",
+			systemConfig: configuration.SystemConfig{Localization: configuration.Localization{
+				Timezone: "Europe/Berlin",
+				Keymap:   "de-latin1",
+			}},
+			packages: []string{"tzdata", "kbd"},
+		},
+		{
+			name:         "removed package in the package lists",
+			systemConfig: configuration.SystemConfig{RemovePackages: []string{"man-db"}},
+			packages:     []string{"man-db"},
+			ruleIds:      []string{rulePackagesRemoved},
+			path:         "SystemConfigs[0].RemovePackages",
+			message: "failed to validate package lists in config: package (man-db) is in [RemovePackages], but it " +
+				"is also included in the package lists",
+		},
+		{
+			name: "timezone with removed tzdata",
+			systemConfig: configuration.SystemConfig{
+				Localization:   configuration.Localization{Timezone: "Europe/Berlin"},
+				RemovePackages: []string{"tzdata"},
+			},
+			packages: []string{"tzdata"},
+			ruleIds:  []string{rulePackagesTzdata, rulePackagesRemoved},
+			path:     "SystemConfigs[0].Localization.Timezone",
+			message: "failed to validate package lists in config: [Timezone] (Europe/Berlin) selected, but " +
+				"'tzdata' package is not included in the package lists",
+		},
+		{
+			name: "preserved SSH host keys without openssh-server",
+			systemConfig: configuration.SystemConfig{InstanceIdentity: configuration.InstanceIdentity{
+				SSHHostKeys: configuration.IdentityPolicyPreserve,
+			}},
+			ruleIds: []string{rulePackagesSshHostKeys},
+			path:    "SystemConfigs[0].InstanceIdentity.SSHHostKeys",
+			message: "failed to validate package lists in config: [SSHHostKeys] preserved, but 'openssh-server' " +
+				"package is not included in the package lists",
+		},
+		{
+			name: "preserved SSH host keys with openssh-server",
+			systemConfig: configuration.SystemConfig{InstanceIdentity: configuration.InstanceIdentity{
+				SSHHostKeys: configuration.IdentityPolicyPreserve,
+			}},
+			packages: []string{"openssh-server"},
+		},
+		{
+			name:         "CA certificates without ca-certificates",
+			systemConfig: configuration.SystemConfig{CACertificates: []string{"certs/contoso-root.pem"}},
+			ruleIds:      []string{rulePackagesCaCertificates},
+			path:         "SystemConfigs[0].CACertificates",
+			message: "failed to validate package lists in config: [CACertificates] added to the trust store, but " +
+				"neither the 'ca-certificates' nor the 'ca-certificates-base' package is included in the package lists",
+		},
+		{
+			name:         "CA certificates with ca-certificates-base",
+			systemConfig: configuration.SystemConfig{CACertificates: []string{"certs/contoso-root.pem"}},
+			packages:     []string{"ca-certificates-base"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx, err := newCrossCheckContext(test.systemConfig, test.packages)
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			results := runCrossCheckRules(crossCheckRules, 0, ctx)
+			ruleIds := []string{}
+			for _, result := range results {
+				ruleIds = append(ruleIds, result.RuleId)
+			}
+			if test.ruleIds == nil {
+				test.ruleIds = []string{}
+			}
+			if !assert.Equal(t, test.ruleIds, ruleIds) || len(results) == 0 {
+				return
+			}
+
+			assert.Equal(t, test.path, results[0].Path)
+			assert.Equal(t, test.message, results[0].Message)
+		})
+	}
+}
+
+func TestCrossCheckRuleSeverity(t *testing.T) {
+	rules := []crossCheckRule{
+		{
+			id:       "test-warning",
+			severity: SeverityWarning,
+			path:     "Name",
+			check: requirePackage(func(ctx *crossCheckContext) string {
+				return "test reason"
+			}, constantPackage("test-package")),
+		},
+	}
+
+	ctx, err := newCrossCheckContext(configuration.SystemConfig{}, nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	results := runCrossCheckRules(rules, 1, ctx)
+	if !assert.Len(t, results, 1) {
+		return
+	}
+	assert.Equal(t, SeverityWarning, results[0].Severity)
+	assert.Equal(t, "SystemConfigs[1].Name", results[0].Path)
+}
+
+func TestCrossCheckContextRejectsInvalidPackages(t *testing.T) {
+	_, err := newCrossCheckContext(configuration.SystemConfig{}, []string{"words >"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,277 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/imagecustomizerapi"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/file"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/timestamp"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// The config type is detected from the config file's extension.
+	configTypeAuto = "auto"
+	// An imagegen/configuration JSON config (e.g. for imager).
+	configTypeImageGen = "imagegen"
+	// An imagecustomizerapi YAML config (i.e. for imagecustomizer).
+	configTypeImageCustomizer = "imagecustomizer"
+)
+
+// detectConfigType returns the type of the config file, detecting it from the file's extension if the config type is
+// 'auto'.
+func detectConfigType(configPath string, configType string) string {
+	if configType != configTypeAuto {
+		return configType
+	}
+
+	switch strings.ToLower(filepath.Ext(configPath)) {
+	case ".yaml", ".yml":
+		return configTypeImageCustomizer
+	default:
+		return configTypeImageGen
+	}
+}
+
+// loadCustomizerConfig reads an imagecustomizer config, without validating it. Unlike
+// imagecustomizerapi.UnmarshalYamlFile, a config that can't be parsed is told apart from an invalid one.
+func loadCustomizerConfig(configPath string) (config *imagecustomizerapi.Config, err error) {
+	yamlData, err := os.ReadFile(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file (%s):\n%w", configPath, err)
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(yamlData))
+	// Unknown fields are errors, as they are for imagecustomizer.
+	decoder.KnownFields(true)
+
+	config = &imagecustomizerapi.Config{}
+	err = decoder.Decode(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config file (%s):\n%w", configPath, err)
+	}
+
+	return config, nil
+}
+
+// ValidateCustomizerConfigResults runs the same checks on an imagecustomizer config as imagecustomizer does before
+// customizing an image, and checks that the files that the config references exist. Unlike imagecustomizer, it
+// reports all of the missing files. Relative paths are relative to the config file's directory (baseConfigPath).
+func ValidateCustomizerConfigResults(config *imagecustomizerapi.Config, baseConfigPath string,
+) (results []ValidationResult) {
+	timestamp.StartEvent("validate customizer config", nil)
+	defer timestamp.StopEvent(nil)
+
+	err := config.IsValid()
+	if err != nil {
+		return []ValidationResult{newValidationResult(ruleConfigInvalid, "", err)}
+	}
+
+	if config.OS != nil {
+		results = append(results, validateCustomizerPackageLists(baseConfigPath, config.OS.Packages)...)
+		results = append(results, validateAdditionalFileSources(baseConfigPath, "os.additionalFiles",
+			config.OS.AdditionalFiles)...)
+
+		for i, dirConfig := range config.OS.AdditionalDirs {
+			results = append(results, validateSourceIsDir(baseConfigPath,
+				fmt.Sprintf("os.additionalDirs[%d].source", i), "additionalDirs source dir", dirConfig.Source)...)
+		}
+
+		for i, user := range config.OS.Users {
+			results = append(results, validateUserFiles(baseConfigPath, fmt.Sprintf("os.users[%d]", i), user)...)
+		}
+	}
+
+	if config.Iso != nil {
+		results = append(results, validateAdditionalFileSources(baseConfigPath, "iso.additionalFiles",
+			config.Iso.AdditionalFiles)...)
+
+		for i, rootfsImage := range config.Iso.AdditionalRootfsImages {
+			results = append(results, validateSourceIsFile(baseConfigPath,
+				fmt.Sprintf("iso.additionalRootfsImages[%d].source", i), "additionalRootfsImages source file",
+				rootfsImage.Source)...)
+		}
+
+		if config.Iso.ABUpdate != nil {
+			results = append(results, validateSourceIsFile(baseConfigPath, "iso.abUpdate.slotBSource",
+				"abUpdate slotBSource file", config.Iso.ABUpdate.SlotBSource)...)
+		}
+	}
+
+	if config.Pxe != nil {
+		for i, answerFile := range config.Pxe.AnswerFiles {
+			results = append(results, validateSourceIsFile(baseConfigPath, fmt.Sprintf("pxe.answerFiles[%d].source", i),
+				"answerFiles source file", answerFile.Source)...)
+		}
+	}
+
+	for i, script := range config.Scripts.PostCustomization {
+		results = append(results, validateScriptPath(baseConfigPath,
+			fmt.Sprintf("scripts.postCustomization[%d].path", i), script)...)
+	}
+	for i, script := range config.Scripts.FinalizeCustomization {
+		results = append(results, validateScriptPath(baseConfigPath,
+			fmt.Sprintf("scripts.finalizeCustomization[%d].path", i), script)...)
+	}
+
+	return
+}
+
+func validateAdditionalFileSources(baseConfigPath string, path string,
+	additionalFiles imagecustomizerapi.AdditionalFileList,
+) (results []ValidationResult) {
+	for i, additionalFile := range additionalFiles {
+		results = append(results, validateSourceIsFile(baseConfigPath, fmt.Sprintf("%s[%d].source", path, i),
+			"additionalFiles source file", additionalFile.Source)...)
+	}
+	return
+}
+
+// validateSourceIsFile checks that a source file of the config exists and is readable. An empty source is not checked.
+func validateSourceIsFile(baseConfigPath string, path string, description string, source string) []ValidationResult {
+	if source == "" {
+		return nil
+	}
+
+	err := checkReadableFile(file.GetAbsPathWithBase(baseConfigPath, source), false)
+	if err != nil {
+		return []ValidationResult{newValidationResult(ruleFileSource, path,
+			fmt.Errorf("invalid %s (%s):\n%w", description, source, err))}
+	}
+
+	return nil
+}
+
+// validateUserFiles checks that the SSH public key files and the password file of a user exist.
+func validateUserFiles(baseConfigPath string, path string, user imagecustomizerapi.User) (results []ValidationResult) {
+	for i, sshPublicKeyPath := range user.SSHPublicKeyPaths {
+		results = append(results, validateSourceIsFile(baseConfigPath, fmt.Sprintf("%s.sshPublicKeyPaths[%d]", path, i),
+			"SSH public key file", sshPublicKeyPath)...)
+	}
+
+	if user.Password != nil && (user.Password.Type == imagecustomizerapi.PasswordTypePlainTextFile ||
+		user.Password.Type == imagecustomizerapi.PasswordTypeHashedFile) {
+		results = append(results, validateSourceIsFile(baseConfigPath, path+".password.value", "password file",
+			user.Password.Value)...)
+	}
+
+	return
+}
+
+// validateSourceIsDir checks that a source dir of the config exists.
+func validateSourceIsDir(baseConfigPath string, path string, description string, source string) []ValidationResult {
+	isDir, err := file.IsDir(file.GetAbsPathWithBase(baseConfigPath, source))
+	switch {
+	case err != nil:
+		return []ValidationResult{newValidationResult(ruleFileSource, path,
+			fmt.Errorf("invalid %s (%s):\n%w", description, source, err))}
+
+	case !isDir:
+		return []ValidationResult{newValidationResult(ruleFileSource, path,
+			fmt.Errorf("invalid %s (%s):\nnot a directory", description, source))}
+	}
+
+	return nil
+}
+
+// validateScriptPath checks that a script file exists under the config file's dir, which imagecustomizer bind mounts
+// into the image's chroot to run the script.
+func validateScriptPath(baseConfigPath string, path string, script imagecustomizerapi.Script) []ValidationResult {
+	if script.Path == "" {
+		return nil
+	}
+
+	if !filepath.IsLocal(script.Path) {
+		return []ValidationResult{newValidationResult(ruleScriptPath, path,
+			fmt.Errorf("script file (%s) is not under config directory (%s)", script.Path, baseConfigPath))}
+	}
+
+	_, err := os.Stat(filepath.Join(baseConfigPath, script.Path))
+	if err != nil {
+		return []ValidationResult{newValidationResult(ruleScriptPath, path,
+			fmt.Errorf("couldn't read script file (%s):\n%w", script.Path, err))}
+	}
+
+	return nil
+}
+
+// readCustomizerPackageList reads a package list file of an imagecustomizer config.
+func readCustomizerPackageList(baseConfigPath string, packageListPath string) ([]string, error) {
+	var packageList imagecustomizerapi.PackageList
+	err := imagecustomizerapi.UnmarshalYamlFile(file.GetAbsPathWithBase(baseConfigPath, packageListPath), &packageList)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read package list file (%s):\n%w", packageListPath, err)
+	}
+	return packageList.Packages, nil
+}
+
+func validateCustomizerPackageLists(baseConfigPath string, packages imagecustomizerapi.Packages,
+) (results []ValidationResult) {
+	for _, packageListSet := range []struct {
+		field        string
+		packageLists []string
+	}{
+		{"installLists", packages.InstallLists},
+		{"removeLists", packages.RemoveLists},
+		{"updateLists", packages.UpdateLists},
+	} {
+		for i, packageListPath := range packageListSet.packageLists {
+			_, err := readCustomizerPackageList(baseConfigPath, packageListPath)
+			if err != nil {
+				results = append(results, newValidationResult(rulePackageList,
+					fmt.Sprintf("os.packages.%s[%d]", packageListSet.field, i), err))
+			}
+		}
+	}
+
+	return
+}
+
+// validateCustomizerPackageAvailability checks that the packages that an imagecustomizer config installs or updates
+// are available in the package repos. The packages that it removes are in the base image, so they are not checked.
+func validateCustomizerPackageAvailability(config *imagecustomizerapi.Config, baseConfigPath string,
+	packages *availablePackages,
+) (results []ValidationResult) {
+	timestamp.StartEvent("validate package availability", nil)
+	defer timestamp.StopEvent(nil)
+
+	if config.OS == nil {
+		return
+	}
+
+	for _, packageSet := range []struct {
+		field        string
+		packageLists []string
+		packages     []string
+	}{
+		{"install", config.OS.Packages.InstallLists, config.OS.Packages.Install},
+		{"update", config.OS.Packages.UpdateLists, config.OS.Packages.Update},
+	} {
+		for i, packageListPath := range packageSet.packageLists {
+			// Package lists that can't be read are reported by validateCustomizerPackageLists.
+			packageList, err := readCustomizerPackageList(baseConfigPath, packageListPath)
+			if err != nil {
+				continue
+			}
+
+			path := fmt.Sprintf("os.packages.%sLists[%d]", packageSet.field, i)
+			for _, pkg := range packageList {
+				results = append(results, validatePackageIsAvailable(pkg, path, packages)...)
+			}
+		}
+
+		for _, pkg := range packageSet.packages {
+			results = append(results, validatePackageIsAvailable(pkg, "os.packages."+packageSet.field, packages)...)
+		}
+	}
+
+	return
+}
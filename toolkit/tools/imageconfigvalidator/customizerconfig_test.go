@@ -0,0 +1,149 @@
+// Copyright Microsoft Corporation.
+// Licensed under the MIT License.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/imagecustomizerapi"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testCustomizerConfigDir = "./testdata/customizer"
+
+func loadTestCustomizerConfig(t *testing.T) *imagecustomizerapi.Config {
+	config, err := loadCustomizerConfig(filepath.Join(testCustomizerConfigDir, "config.yaml"))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	return config
+}
+
+func TestDetectConfigType(t *testing.T) {
+	assert.Equal(t, configTypeImageCustomizer, detectConfigType("config.yaml", configTypeAuto))
+	assert.Equal(t, configTypeImageCustomizer, detectConfigType("config.YML", configTypeAuto))
+	assert.Equal(t, configTypeImageGen, detectConfigType("config.json", configTypeAuto))
+	assert.Equal(t, configTypeImageGen, detectConfigType("config.yaml", configTypeImageGen))
+}
+
+func TestCustomizerConfigIsValid(t *testing.T) {
+	config := loadTestCustomizerConfig(t)
+
+	results := ValidateCustomizerConfigResults(config, testCustomizerConfigDir)
+	assert.Empty(t, results)
+}
+
+func TestCustomizerConfigReportsAllMissingFiles(t *testing.T) {
+	config := loadTestCustomizerConfig(t)
+	config.OS.Packages.RemoveLists = []string{"lists/missing.yaml"}
+	config.OS.AdditionalFiles[0].Source = "files/missing.txt"
+	config.OS.AdditionalDirs[0].Source = "files/a.txt"
+	config.Iso = &imagecustomizerapi.Iso{
+		AdditionalFiles: imagecustomizerapi.AdditionalFileList{
+			{Source: "files", Destination: "/files"},
+		},
+	}
+	config.Scripts.FinalizeCustomization = []imagecustomizerapi.Script{
+		{Path: "../outside.sh"},
+		{Path: "scripts/missing.sh"},
+	}
+
+	results := ValidateCustomizerConfigResults(config, testCustomizerConfigDir)
+	if !assert.Len(t, results, 6) {
+		return
+	}
+
+	assert.Equal(t, rulePackageList, results[0].RuleId)
+	assert.Equal(t, "os.packages.removeLists[0]", results[0].Path)
+	assert.Contains(t, results[0].Message, "failed to read package list file (lists/missing.yaml)")
+
+	assert.Equal(t, ruleFileSource, results[1].RuleId)
+	assert.Equal(t, "os.additionalFiles[0].source", results[1].Path)
+	assert.Contains(t, results[1].Message, "invalid additionalFiles source file (files/missing.txt)")
+
+	assert.Equal(t, ruleFileSource, results[2].RuleId)
+	assert.Equal(t, "os.additionalDirs[0].source", results[2].Path)
+	assert.Equal(t, "invalid additionalDirs source dir (files/a.txt):\nnot a directory", results[2].Message)
+
+	assert.Equal(t, ruleFileSource, results[3].RuleId)
+	assert.Equal(t, "iso.additionalFiles[0].source", results[3].Path)
+	assert.Equal(t, "invalid additionalFiles source file (files):\nnot a file", results[3].Message)
+
+	assert.Equal(t, ruleScriptPath, results[4].RuleId)
+	assert.Equal(t, "scripts.finalizeCustomization[0].path", results[4].Path)
+	assert.Equal(t, "script file (../outside.sh) is not under config directory (./testdata/customizer)",
+		results[4].Message)
+
+	assert.Equal(t, ruleScriptPath, results[5].RuleId)
+	assert.Equal(t, "scripts.finalizeCustomization[1].path", results[5].Path)
+	assert.Contains(t, results[5].Message, "couldn't read script file (scripts/missing.sh)")
+}
+
+func TestCustomizerConfigInvalid(t *testing.T) {
+	config := loadTestCustomizerConfig(t)
+	config.OS.AdditionalFiles[0].Destination = ""
+
+	results := ValidateCustomizerConfigResults(config, testCustomizerConfigDir)
+	if !assert.Len(t, results, 1) {
+		return
+	}
+	assert.Equal(t, ruleConfigInvalid, results[0].RuleId)
+	assert.Equal(t, SeverityError, results[0].Severity)
+}
+
+func TestCustomizerConfigUnknownField(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	err := os.WriteFile(configPath, []byte("os:\n  notAField: true\n"), 0o644)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	_, err = loadCustomizerConfig(configPath)
+	assert.ErrorContains(t, err, "failed to parse config file")
+	assert.ErrorContains(t, err, "field notAField not found")
+}
+
+func TestCustomizerPackageAvailability(t *testing.T) {
+	packages, err := loadAvailablePackages([]string{createTestRepo(t)}, nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	config := loadTestCustomizerConfig(t)
+	config.OS.Packages.Update = []string{"missing-package"}
+	// Removed packages are in the base image, not in the package repos.
+	config.OS.Packages.Remove = []string{"kernel"}
+
+	results := validateCustomizerPackageAvailability(config, testCustomizerConfigDir, packages)
+	if !assert.Len(t, results, 1) {
+		return
+	}
+	assert.Equal(t, rulePackageUnavailable, results[0].RuleId)
+	assert.Equal(t, "os.packages.update", results[0].Path)
+	assert.Equal(t, "package (missing-package) is not available in the package repos", results[0].Message)
+}
+
+func TestCustomizerConfigUserFiles(t *testing.T) {
+	config := loadTestCustomizerConfig(t)
+	config.OS.Users = []imagecustomizerapi.User{{
+		Name:              "test",
+		SSHPublicKeyPaths: []string{"files/a.txt", "files/missing.pub"},
+		Password: &imagecustomizerapi.Password{
+			Type:  imagecustomizerapi.PasswordTypeHashedFile,
+			Value: "files/missing-password",
+		},
+	}}
+
+	results := ValidateCustomizerConfigResults(config, testCustomizerConfigDir)
+	if !assert.Len(t, results, 2) {
+		return
+	}
+	assert.Equal(t, "os.users[0].sshPublicKeyPaths[1]", results[0].Path)
+	assert.Contains(t, results[0].Message, "invalid SSH public key file (files/missing.pub)")
+	assert.Equal(t, "os.users[0].password.value", results[1].Path)
+	assert.Contains(t, results[1].Message, "invalid password file (files/missing-password)")
+}
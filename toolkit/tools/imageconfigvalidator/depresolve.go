@@ -0,0 +1,274 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/imagegen/configuration"
+	"github.com/microsoft/azurelinux/toolkit/tools/imagegen/installutils"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/file"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/logger"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/packagerepo/repocloner"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/shell"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/tdnf"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/timestamp"
+)
+
+const (
+	// tdnf aborts the transaction when it's run with --assumeno.
+	tdnfAssumeNoStdErr = "Error(1032) : Operation aborted."
+
+	tdnfObsoletingSection = "Obsoleting"
+)
+
+var (
+	// tdnf (libsolv) reports the problems that prevent resolving a transaction as:
+	//
+	//	Found 2 problem(s) while resolving
+	//	1. package a-1.0-1.azl3.x86_64 requires b, but none of the providers can be installed
+	//	2. ...
+	tdnfProblemRegex = regexp.MustCompile(`^\s*\d+\.\s+(.+)$`)
+
+	// The header of a section of a tdnf transaction (e.g. 'Installing:').
+	tdnfSectionRegex = regexp.MustCompile(`^([[:alpha:]]+):\s*$`)
+)
+
+// InstallTransaction is the transaction that tdnf would run to install the packages of a system config.
+type InstallTransaction struct {
+	SystemConfig string                    `json:"SystemConfig"`
+	Install      []*repocloner.RepoPackage `json:"Install"`
+	Obsolete     []*repocloner.RepoPackage `json:"Obsolete"`
+}
+
+// writeResolveReposDir writes a repos dir (for tdnf's 'reposdir' option) with the local RPM dirs, the remote repos and
+// the repo files.
+func writeResolveReposDir(rpmDirs []string, repoUrls []string, repoFiles []string) (reposDir string, err error) {
+	reposDir, err = os.MkdirTemp("", "imageconfigvalidator-repos")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary dir:\n%w", err)
+	}
+
+	repoContent := strings.Builder{}
+	for i, rpmDir := range rpmDirs {
+		absRpmDir, err := filepath.Abs(rpmDir)
+		if err != nil {
+			return reposDir, fmt.Errorf("failed to get the absolute path of (%s):\n%w", rpmDir, err)
+		}
+
+		repoMetadataPath := filepath.Join(absRpmDir, repoMetadataDir, repoMetadataFile)
+		_, err = os.Stat(repoMetadataPath)
+		if err != nil {
+			return reposDir, fmt.Errorf("RPM dir (%s) has no repo metadata, which is required to resolve dependencies "+
+				"(run 'createrepo' on it):\n%w", rpmDir, err)
+		}
+
+		fmt.Fprintf(&repoContent, "[validator-local-%d]\nname=validator-local-%d\nbaseurl=file://%s\nenabled=1\n"+
+			"gpgcheck=0\nskip_if_unavailable=0\n\n", i, i, absRpmDir)
+	}
+
+	for i, repoUrl := range repoUrls {
+		fmt.Fprintf(&repoContent, "[validator-remote-%d]\nname=validator-remote-%d\nbaseurl=%s\nenabled=1\n"+
+			"gpgcheck=0\nskip_if_unavailable=0\n\n", i, i, repoUrl)
+	}
+
+	if repoContent.Len() > 0 {
+		repoPath := filepath.Join(reposDir, "validator.repo")
+		err = file.Write(repoContent.String(), repoPath)
+		if err != nil {
+			return reposDir, fmt.Errorf("failed to write repo file (%s):\n%w", repoPath, err)
+		}
+	}
+
+	for i, repoFile := range repoFiles {
+		// Keep the file names unique (and ending with '.repo').
+		repoPath := filepath.Join(reposDir, fmt.Sprintf("%d-%s", i, filepath.Base(repoFile)))
+		err = file.Copy(repoFile, repoPath)
+		if err != nil {
+			return reposDir, fmt.Errorf("failed to copy repo file (%s):\n%w", repoFile, err)
+		}
+	}
+
+	return reposDir, nil
+}
+
+// resolveDependencies resolves the dependencies of the config's packages against the package repos.
+func resolveDependencies(config configuration.Config, rpmDirs []string, repoUrls []string, repoFiles []string) (
+	results []ValidationResult, transactions []InstallTransaction, err error,
+) {
+	if len(rpmDirs) == 0 && len(repoUrls) == 0 && len(repoFiles) == 0 {
+		return nil, nil, fmt.Errorf("resolving dependencies requires at least one package repo (--rpm-dir, --repo-url or " +
+			"--repo-file)")
+	}
+
+	reposDir, err := writeResolveReposDir(rpmDirs, repoUrls, repoFiles)
+	if reposDir != "" {
+		defer os.RemoveAll(reposDir)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return validateDependencyResolution(config, reposDir)
+}
+
+// validateDependencyResolution resolves the full dependency closure of each system config's packages (including its
+// kernel) with tdnf, as a single transaction, without installing anything. It reports the problems (e.g. conflicts,
+// missing dependencies) that prevent the resolution, and returns the transactions that tdnf would run.
+func validateDependencyResolution(config configuration.Config, reposDir string) (results []ValidationResult,
+	transactions []InstallTransaction, err error,
+) {
+	timestamp.StartEvent("resolve package dependencies", nil)
+	defer timestamp.StopEvent(nil)
+
+	releaseverCliArg, err := tdnf.GetReleaseverCliArg()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for i, systemConfig := range config.SystemConfigs {
+		// Package lists that can't be read are reported by validatePackages.
+		packageList, err := installutils.PackageNamesFromSingleSystemConfig(systemConfig)
+		if err != nil {
+			continue
+		}
+
+		kernelPkg := systemConfig.KernelOptions["default"]
+		if kernelPkg != "" {
+			packageList = append(packageList, kernelPkg)
+		}
+		if len(packageList) == 0 {
+			continue
+		}
+
+		logger.Log.Infof("Resolving the dependencies of system config (%s)", systemConfig.Name)
+
+		stdout, stderr, err := runTdnfAssumeNoInstall(packageList, reposDir, releaseverCliArg)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		transaction, problems := parseTdnfTransaction(stdout, stderr)
+		transaction.SystemConfig = systemConfig.Name
+		transactions = append(transactions, transaction)
+
+		path := systemConfigPath(i, "PackageLists")
+		for _, problem := range problems {
+			results = append(results, newValidationResult(classifyResolveProblem(problem), path,
+				fmt.Errorf("failed to resolve the packages of system config (%s): %s", systemConfig.Name, problem)))
+		}
+
+		for _, pkg := range transaction.Obsolete {
+			results = append(results, newValidationWarning(rulePackageObsoleted, path,
+				fmt.Sprintf("installing the packages of system config (%s) obsoletes package (%s-%s.%s)",
+					systemConfig.Name, pkg.Name, pkg.Version, pkg.Distribution)))
+		}
+	}
+
+	return results, transactions, nil
+}
+
+// runTdnfAssumeNoInstall runs a tdnf install of the packages into a scratch install root, which tdnf aborts right
+// before installing anything.
+func runTdnfAssumeNoInstall(packageList []string, reposDir string, releaseverCliArg string) (stdout string,
+	stderr string, err error,
+) {
+	installRoot, err := os.MkdirTemp("", "imageconfigvalidator-installroot")
+	if err != nil {
+		return "", "", fmt.Errorf("failed to create temporary dir:\n%w", err)
+	}
+	defer os.RemoveAll(installRoot)
+
+	args := []string{
+		"install", "--assumeno", "--nogpgcheck", "--installroot", installRoot, "--setopt", "reposdir=" + reposDir,
+		releaseverCliArg,
+	}
+	args = append(args, packageList...)
+
+	// A failed resolution is reported by the output, which the caller parses. So, only tdnf itself failing to run is
+	// an error.
+	stdout, stderr, err = shell.Execute("tdnf", args...)
+	if err != nil && stdout == "" && stderr == "" {
+		return "", "", fmt.Errorf("failed to run tdnf:\n%w", err)
+	}
+
+	logger.Log.Debugf("stdout: %s", stdout)
+	logger.Log.Debugf("stderr: %s", stderr)
+	return stdout, stderr, nil
+}
+
+// parseTdnfTransaction parses the output of a tdnf install that was run with --assumeno. It returns the transaction
+// that tdnf would have run, and the problems that prevent running it.
+func parseTdnfTransaction(stdout string, stderr string) (transaction InstallTransaction, problems []string) {
+	section := ""
+	for _, line := range strings.Split(stdout, "\n") {
+		sectionMatches := tdnfSectionRegex.FindStringSubmatch(line)
+		if sectionMatches != nil {
+			section = sectionMatches[1]
+			continue
+		}
+
+		matches := tdnf.InstallPackageRegex.FindStringSubmatch(line)
+		if len(matches) != tdnf.InstallPackageMaxMatchLen {
+			// This line contains output other than a package information; skip it
+			continue
+		}
+
+		pkg := &repocloner.RepoPackage{
+			Name:         matches[tdnf.InstallPackageName],
+			Version:      matches[tdnf.InstallPackageVersion],
+			Architecture: matches[tdnf.InstallPackageArch],
+			Distribution: matches[tdnf.InstallPackageDist],
+		}
+
+		if section == tdnfObsoletingSection {
+			transaction.Obsolete = append(transaction.Obsolete, pkg)
+		} else {
+			transaction.Install = append(transaction.Install, pkg)
+		}
+	}
+
+	inProblems := false
+	for _, line := range strings.Split(stdout+"\n"+stderr, "\n") {
+		trimmedLine := strings.TrimSpace(line)
+		switch {
+		case strings.Contains(trimmedLine, "problem(s) while resolving"):
+			inProblems = true
+
+		case inProblems && tdnfProblemRegex.MatchString(trimmedLine):
+			problems = append(problems, tdnfProblemRegex.FindStringSubmatch(trimmedLine)[1])
+
+		case strings.HasPrefix(trimmedLine, "No package") && strings.HasSuffix(trimmedLine, "available"):
+			problems = append(problems, trimmedLine)
+
+		case strings.HasPrefix(trimmedLine, "Error(") && trimmedLine != tdnfAssumeNoStdErr:
+			inProblems = false
+			if len(problems) == 0 {
+				// The transaction failed without any specific problems.
+				problems = append(problems, trimmedLine)
+			}
+		}
+	}
+
+	return transaction, problems
+}
+
+// classifyResolveProblem returns the rule id of a problem reported by tdnf (libsolv).
+func classifyResolveProblem(problem string) string {
+	switch {
+	case strings.Contains(problem, "conflicts with") || strings.Contains(problem, "conflicting requests"):
+		return ruleDependencyConflict
+	case strings.Contains(problem, "obsoletes"):
+		return rulePackageObsoleted
+	case strings.HasPrefix(problem, "No package") || strings.Contains(problem, "nothing provides") ||
+		strings.Contains(problem, "none of the providers can be installed"):
+		return ruleDependencyMissing
+	default:
+		return ruleDependencyResolution
+	}
+}
@@ -0,0 +1,105 @@
+// Copyright Microsoft Corporation.
+// Licensed under the MIT License.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/packagerepo/repocloner"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTdnfTransaction(t *testing.T) {
+	const stdout = `
+Installing:
+shadow-utils                x86_64   2:4.14.3-2.azl3     validator-local-0   3.45M 1234567
+words                       noarch   3.0-40.azl3         validator-local-0   4.76M 4990000
+
+Obsoleting:
+old-words                   noarch   2.0-1.azl3          @System             1.00M 1048576
+
+Total installed size:   8.21M 8611367
+`
+
+	transaction, problems := parseTdnfTransaction(stdout, tdnfAssumeNoStdErr+"\n")
+	assert.Empty(t, problems)
+	assert.Equal(t, []*repocloner.RepoPackage{
+		{Name: "shadow-utils", Version: "2:4.14.3-2", Architecture: "x86_64", Distribution: "azl3"},
+		{Name: "words", Version: "3.0-40", Architecture: "noarch", Distribution: "azl3"},
+	}, transaction.Install)
+	assert.Equal(t, []*repocloner.RepoPackage{
+		{Name: "old-words", Version: "2.0-1", Architecture: "noarch", Distribution: "azl3"},
+	}, transaction.Obsolete)
+}
+
+func TestParseTdnfTransactionProblems(t *testing.T) {
+	const stderr = `Found 2 problem(s) while resolving
+1. package a-1.0-1.azl3.x86_64 requires b, but none of the providers can be installed
+2. package c-1.0-1.azl3.x86_64 conflicts with d provided by d-1.0-1.azl3.x86_64
+Error(1301) : Solv general runtime error
+`
+
+	transaction, problems := parseTdnfTransaction("", stderr)
+	assert.Empty(t, transaction.Install)
+	assert.Equal(t, []string{
+		"package a-1.0-1.azl3.x86_64 requires b, but none of the providers can be installed",
+		"package c-1.0-1.azl3.x86_64 conflicts with d provided by d-1.0-1.azl3.x86_64",
+	}, problems)
+
+	assert.Equal(t, ruleDependencyMissing, classifyResolveProblem(problems[0]))
+	assert.Equal(t, ruleDependencyConflict, classifyResolveProblem(problems[1]))
+}
+
+func TestParseTdnfTransactionMissingPackage(t *testing.T) {
+	const stdout = "No package not-a-package available\n"
+	const stderr = "Error(1011) : No matching packages\n"
+
+	_, problems := parseTdnfTransaction(stdout, stderr)
+	assert.Equal(t, []string{"No package not-a-package available"}, problems)
+	assert.Equal(t, ruleDependencyMissing, classifyResolveProblem(problems[0]))
+}
+
+func TestParseTdnfTransactionUnknownError(t *testing.T) {
+	_, problems := parseTdnfTransaction("", "Error(1602) : Could not resolve host\n")
+	assert.Equal(t, []string{"Error(1602) : Could not resolve host"}, problems)
+	assert.Equal(t, ruleDependencyResolution, classifyResolveProblem(problems[0]))
+}
+
+func TestWriteResolveReposDir(t *testing.T) {
+	repoDir := createTestRepo(t)
+
+	repoFile := filepath.Join(t.TempDir(), "extra.repo")
+	err := os.WriteFile(repoFile, []byte("[extra]\nbaseurl=https://example.com/extra\n"), 0o644)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	reposDir, err := writeResolveReposDir([]string{repoDir}, []string{"https://example.com/base"}, []string{repoFile})
+	if reposDir != "" {
+		defer os.RemoveAll(reposDir)
+	}
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	repoContent, err := os.ReadFile(filepath.Join(reposDir, "validator.repo"))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Contains(t, string(repoContent), "[validator-local-0]\nname=validator-local-0\nbaseurl=file://"+repoDir+"\n")
+	assert.Contains(t, string(repoContent), "[validator-remote-0]\nname=validator-remote-0\nbaseurl=https://example.com/base\n")
+
+	assert.FileExists(t, filepath.Join(reposDir, "0-extra.repo"))
+}
+
+func TestWriteResolveReposDirRequiresRepoMetadata(t *testing.T) {
+	reposDir, err := writeResolveReposDir([]string{t.TempDir()}, nil, nil)
+	if reposDir != "" {
+		defer os.RemoveAll(reposDir)
+	}
+	assert.ErrorContains(t, err, "has no repo metadata")
+}
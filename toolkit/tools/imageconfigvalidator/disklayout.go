@@ -0,0 +1,247 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/imagegen/configuration"
+	"github.com/microsoft/azurelinux/toolkit/tools/imagegen/installutils"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/pkgjson"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/timestamp"
+)
+
+const (
+	// The backup GPT header and partition entries are at the end of the disk, so partitions can't use its last MiB.
+	gptBackupSizeMiB = 1
+
+	// Enough for shim, grub and grub's config.
+	minEspSizeMiB = 8
+	// Enough for a couple of unified kernel images (e.g. while updating the kernel).
+	minUkiEspSizeMiB = 128
+	ukiPkgName       = "kernel-uki"
+
+	realDiskType = "path"
+)
+
+// validateDiskLayout checks that the partitions of each disk are well-formed and fit in the disk, and that the EFI
+// system partition is large enough for the bootloader.
+func validateDiskLayout(config configuration.Config) (results []ValidationResult) {
+	timestamp.StartEvent("validate disk layout", nil)
+	defer timestamp.StopEvent(nil)
+
+	for diskIndex, disk := range config.Disks {
+		results = append(results, validatePartitionRanges(diskIndex, disk)...)
+	}
+
+	results = append(results, validateEspSize(config)...)
+	return
+}
+
+func partitionPath(diskIndex int, partitionIndex int) string {
+	return fmt.Sprintf("Disks[%d].Partitions[%d]", diskIndex, partitionIndex)
+}
+
+func validatePartitionRanges(diskIndex int, disk configuration.Disk) (results []ValidationResult) {
+	// Sort the partitions by their start, keeping their indexes for the results.
+	partitionIndexes := make([]int, len(disk.Partitions))
+	for i := range disk.Partitions {
+		partitionIndexes[i] = i
+	}
+	sort.SliceStable(partitionIndexes, func(i, j int) bool {
+		return disk.Partitions[partitionIndexes[i]].Start < disk.Partitions[partitionIndexes[j]].Start
+	})
+
+	for i, partitionIndex := range partitionIndexes {
+		partition := disk.Partitions[partitionIndex]
+		path := partitionPath(diskIndex, partitionIndex)
+
+		if partition.End != 0 && partition.End <= partition.Start {
+			results = append(results, newValidationResult(ruleDiskPartitionRange, path,
+				fmt.Errorf("partition (%s) ends (at %d MiB) before it starts (at %d MiB)", partition.ID, partition.End,
+					partition.Start)))
+			continue
+		}
+
+		// An 'End' of 0 means that the partition fills the rest of the disk. So, it must be the last partition.
+		if partition.End == 0 && i < len(partitionIndexes)-1 {
+			nextPartition := disk.Partitions[partitionIndexes[i+1]]
+			results = append(results, newValidationResult(ruleDiskPartitionOverlap, path,
+				fmt.Errorf("partition (%s) fills the rest of the disk, so it overlaps partition (%s), which starts after it "+
+					"(at %d MiB)", partition.ID, nextPartition.ID, nextPartition.Start)))
+		}
+	}
+
+	// MaxSize is not used if the target disk is a real disk.
+	if disk.TargetDisk.Type == realDiskType || disk.MaxSize == 0 || len(partitionIndexes) == 0 {
+		return
+	}
+
+	usableSize := disk.MaxSize
+	if disk.PartitionTableType == configuration.PartitionTableTypeGpt {
+		usableSize -= gptBackupSizeMiB
+	}
+
+	lastPartitionIndex := partitionIndexes[len(partitionIndexes)-1]
+	lastPartition := disk.Partitions[lastPartitionIndex]
+	switch {
+	case lastPartition.End == 0 && lastPartition.Start >= usableSize:
+		results = append(results, newValidationResult(ruleDiskSize, partitionPath(diskIndex, lastPartitionIndex),
+			fmt.Errorf("partition (%s) fills the rest of the disk, but starts (at %d MiB) after the usable space of the "+
+				"disk ends (at %d MiB)", lastPartition.ID, lastPartition.Start, usableSize)))
+
+	case lastPartition.End > usableSize:
+		results = append(results, newValidationResult(ruleDiskSize, partitionPath(diskIndex, lastPartitionIndex),
+			fmt.Errorf("partition (%s) ends (at %d MiB) after the usable space of the disk ends (at %d MiB, with a MaxSize "+
+				"of %d MiB)", lastPartition.ID, lastPartition.End, usableSize, disk.MaxSize)))
+	}
+
+	return
+}
+
+// partitionSizeMiB returns the size of a partition, or 0 if it's unknown (i.e. the partition fills the rest of a real
+// disk).
+func partitionSizeMiB(disk configuration.Disk, partition configuration.Partition) uint64 {
+	if partition.End != 0 {
+		if partition.End <= partition.Start {
+			return 0
+		}
+		return partition.End - partition.Start
+	}
+
+	if disk.TargetDisk.Type == realDiskType || disk.MaxSize <= partition.Start {
+		return 0
+	}
+
+	size := disk.MaxSize - partition.Start
+	if disk.PartitionTableType == configuration.PartitionTableTypeGpt && size > gptBackupSizeMiB {
+		size -= gptBackupSizeMiB
+	}
+	return size
+}
+
+// findPartition returns the disk and the indexes of the partition with the ID, or false if there is none.
+func findPartition(config configuration.Config, id string) (configuration.Disk, int, int, bool) {
+	for diskIndex, disk := range config.Disks {
+		for partitionIndex, partition := range disk.Partitions {
+			if partition.ID == id {
+				return disk, diskIndex, partitionIndex, true
+			}
+		}
+	}
+	return configuration.Disk{}, 0, 0, false
+}
+
+func validateEspSize(config configuration.Config) (results []ValidationResult) {
+	for _, systemConfig := range config.SystemConfigs {
+		if systemConfig.BootType != "efi" {
+			continue
+		}
+
+		minSize := uint64(minEspSizeMiB)
+		bootloader := "the bootloader"
+
+		// Package lists that can't be read are reported by validatePackages.
+		packageList, err := installutils.PackageNamesFromSingleSystemConfig(systemConfig)
+		if err == nil {
+			foundUkiPackage, err := installutils.PackagelistContainsPackage(packageList, ukiPkgName)
+			if err == nil && foundUkiPackage {
+				minSize = minUkiEspSizeMiB
+				bootloader = "the unified kernel images"
+			}
+		}
+
+		for _, partitionSetting := range systemConfig.PartitionSettings {
+			disk, diskIndex, partitionIndex, found := findPartition(config, partitionSetting.ID)
+			if !found {
+				continue
+			}
+
+			partition := disk.Partitions[partitionIndex]
+			if !partition.HasFlag(configuration.PartitionFlagESP) {
+				continue
+			}
+
+			size := partitionSizeMiB(disk, partition)
+			if size != 0 && size < minSize {
+				results = append(results, newValidationResult(ruleEspSize, partitionPath(diskIndex, partitionIndex),
+					fmt.Errorf("the EFI system partition (%s) of system config (%s) is only %d MiB, which is too small for "+
+						"%s (at least %d MiB)", partition.ID, systemConfig.Name, size, bootloader, minSize)))
+			}
+		}
+	}
+
+	return
+}
+
+// validateInstalledSize checks that the estimated installed size of each system config's packages fits in the system
+// config's partitions.
+//
+// The estimate is a lower bound: it's the installed size of the packages of the transaction that tdnf would run (in
+// the deep dependency resolution mode), or else of just the listed packages, without file system overhead.
+func validateInstalledSize(config configuration.Config, packages *availablePackages,
+	transactions []InstallTransaction,
+) (results []ValidationResult) {
+	const bytesPerMiB = 1024 * 1024
+
+	for i, systemConfig := range config.SystemConfigs {
+		var pkgNames []string
+		transactionFound := false
+		for _, transaction := range transactions {
+			if transaction.SystemConfig == systemConfig.Name && len(transaction.Install) > 0 {
+				for _, pkg := range transaction.Install {
+					pkgNames = append(pkgNames, pkg.Name)
+				}
+				transactionFound = true
+				break
+			}
+		}
+
+		if !transactionFound {
+			// Package lists that can't be read are reported by validatePackages.
+			packageList, err := installutils.PackageNamesFromSingleSystemConfig(systemConfig)
+			if err != nil {
+				continue
+			}
+			for _, pkg := range packageList {
+				pkgVer, err := pkgjson.PackageStringToPackageVer(pkg)
+				if err != nil {
+					continue
+				}
+				pkgNames = append(pkgNames, pkgVer.Name)
+			}
+		}
+
+		installedSize := uint64(0)
+		for _, pkgName := range pkgNames {
+			installedSize += packages.installedSizes[pkgName]
+		}
+
+		partitionsSize := uint64(0)
+		for _, partitionSetting := range systemConfig.PartitionSettings {
+			disk, _, partitionIndex, found := findPartition(config, partitionSetting.ID)
+			if !found {
+				continue
+			}
+
+			size := partitionSizeMiB(disk, disk.Partitions[partitionIndex])
+			if size == 0 {
+				// The size of the system config's partitions is unknown.
+				partitionsSize = 0
+				break
+			}
+			partitionsSize += size
+		}
+
+		installedSizeMiB := (installedSize + bytesPerMiB - 1) / bytesPerMiB
+		if partitionsSize != 0 && installedSizeMiB > partitionsSize {
+			results = append(results, newValidationResult(ruleInstalledSize, systemConfigPath(i, "PartitionSettings"),
+				fmt.Errorf("the estimated installed size (%d MiB) of the packages of system config (%s) exceeds the size "+
+					"of its partitions (%d MiB)", installedSizeMiB, systemConfig.Name, partitionsSize)))
+		}
+	}
+
+	return
+}
@@ -0,0 +1,134 @@
+// Copyright Microsoft Corporation.
+// Licensed under the MIT License.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/imagegen/configuration"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/packagerepo/repocloner"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiskLayoutOfTestConfigIsValid(t *testing.T) {
+	config := loadTestConfig(t)
+	assert.Empty(t, validateDiskLayout(config))
+}
+
+func TestDiskLayoutPartitionEndsBeforeStart(t *testing.T) {
+	disk := configuration.Disk{
+		PartitionTableType: configuration.PartitionTableTypeGpt,
+		MaxSize:            4096,
+		Partitions: []configuration.Partition{
+			{ID: "rootfs", Start: 100, End: 50},
+		},
+	}
+
+	results := validatePartitionRanges(0, disk)
+	if assert.Len(t, results, 1) {
+		assert.Equal(t, ruleDiskPartitionRange, results[0].RuleId)
+		assert.Equal(t, "Disks[0].Partitions[0]", results[0].Path)
+		assert.Equal(t, "partition (rootfs) ends (at 50 MiB) before it starts (at 100 MiB)", results[0].Message)
+	}
+}
+
+func TestDiskLayoutGrowPartitionMustBeLast(t *testing.T) {
+	disk := configuration.Disk{
+		PartitionTableType: configuration.PartitionTableTypeGpt,
+		MaxSize:            4096,
+		Partitions: []configuration.Partition{
+			{ID: "home", Start: 2048, End: 3072},
+			{ID: "rootfs", Start: 9, End: 0},
+		},
+	}
+
+	results := validatePartitionRanges(0, disk)
+	if assert.Len(t, results, 1) {
+		assert.Equal(t, ruleDiskPartitionOverlap, results[0].RuleId)
+		assert.Equal(t, "Disks[0].Partitions[1]", results[0].Path)
+		assert.Equal(t, "partition (rootfs) fills the rest of the disk, so it overlaps partition (home), which starts "+
+			"after it (at 2048 MiB)", results[0].Message)
+	}
+}
+
+func TestDiskLayoutLeavesSpaceForBackupGpt(t *testing.T) {
+	disk := configuration.Disk{
+		PartitionTableType: configuration.PartitionTableTypeGpt,
+		MaxSize:            4096,
+		Partitions: []configuration.Partition{
+			{ID: "boot", Start: 1, End: 9},
+			{ID: "rootfs", Start: 9, End: 4096},
+		},
+	}
+
+	results := validatePartitionRanges(0, disk)
+	if assert.Len(t, results, 1) {
+		assert.Equal(t, ruleDiskSize, results[0].RuleId)
+		assert.Equal(t, "Disks[0].Partitions[1]", results[0].Path)
+	}
+
+	// MBR has no backup partition table.
+	disk.PartitionTableType = configuration.PartitionTableTypeMbr
+	assert.Empty(t, validatePartitionRanges(0, disk))
+
+	// The size of a real disk is unknown.
+	disk.PartitionTableType = configuration.PartitionTableTypeGpt
+	disk.TargetDisk = configuration.TargetDisk{Type: "path", Value: "/dev/sda"}
+	assert.Empty(t, validatePartitionRanges(0, disk))
+}
+
+func TestDiskLayoutEspSize(t *testing.T) {
+	config := loadTestConfig(t)
+
+	// The test config's ESP is 8 MiB, which is enough for grub.
+	assert.Empty(t, validateEspSize(config))
+
+	config.SystemConfigs[0].Packages = []string{"kernel-uki"}
+	results := validateEspSize(config)
+	if assert.Len(t, results, 1) {
+		assert.Equal(t, ruleEspSize, results[0].RuleId)
+		assert.Equal(t, "Disks[0].Partitions[0]", results[0].Path)
+		assert.Equal(t, "the EFI system partition (boot) of system config (Standard) is only 8 MiB, which is too small "+
+			"for the unified kernel images (at least 128 MiB)", results[0].Message)
+	}
+
+	config.Disks[0].Partitions[0].End = 1 + minUkiEspSizeMiB
+	config.Disks[0].Partitions[1].Start = 1 + minUkiEspSizeMiB
+	assert.Empty(t, validateEspSize(config))
+}
+
+func TestInstalledSizeFitsPartitions(t *testing.T) {
+	const bytesPerMiB = 1024 * 1024
+
+	config := loadTestConfig(t)
+	config.SystemConfigs[0].Packages = []string{"big-package"}
+
+	packages := newAvailablePackages()
+	packages.addInstalledSize("words", 1*bytesPerMiB)
+	packages.addInstalledSize("big-package", 4000*bytesPerMiB)
+	packages.addInstalledSize("big-dependency", 200*bytesPerMiB)
+
+	// The test config's partitions are 4094 MiB (the 4096 MiB disk, without its first MiB and the backup GPT's MiB).
+	assert.Empty(t, validateInstalledSize(config, packages, nil))
+
+	transactions := []InstallTransaction{
+		{
+			SystemConfig: "Standard",
+			Install: []*repocloner.RepoPackage{
+				{Name: "words"},
+				{Name: "big-package"},
+				{Name: "big-dependency"},
+			},
+		},
+	}
+
+	results := validateInstalledSize(config, packages, transactions)
+	if assert.Len(t, results, 1) {
+		assert.Equal(t, ruleInstalledSize, results[0].RuleId)
+		assert.Equal(t, "SystemConfigs[0].PartitionSettings", results[0].Path)
+		assert.Equal(t, "the estimated installed size (4201 MiB) of the packages of system config (Standard) exceeds "+
+			"the size of its partitions (4094 MiB)", results[0].Message)
+	}
+}
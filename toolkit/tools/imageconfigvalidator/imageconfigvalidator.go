@@ -9,11 +9,11 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-	"strings"
 
 	"github.com/microsoft/azurelinux/toolkit/tools/imagegen/configuration"
 	"github.com/microsoft/azurelinux/toolkit/tools/imagegen/installutils"
 	"github.com/microsoft/azurelinux/toolkit/tools/internal/exe"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/file"
 	"github.com/microsoft/azurelinux/toolkit/tools/internal/logger"
 	"github.com/microsoft/azurelinux/toolkit/tools/internal/timestamp"
 	"github.com/microsoft/azurelinux/toolkit/tools/pkg/profile"
@@ -27,19 +27,249 @@ var (
 	logFlags  = exe.SetupLogFlags(app)
 	profFlags = exe.SetupProfileFlags(app)
 
-	input       = exe.InputStringFlag(app, "Path to the image config file.")
-	baseDirPath = exe.InputDirFlag(app, "Base directory for relative file paths from the config.")
-
 	timestampFile = app.Flag("timestamp-file", "File that stores timestamps for this program.").String()
+
+	validateCmd = app.Command("validate", "Validate an image config file (the default command).").Default()
+
+	input       = validateCmd.Flag("input", "Path to the image config file.").Required().String()
+	baseDirPath = validateCmd.Flag("dir", "Base directory for relative file paths from the config.").Required().ExistingDir()
+
+	outputJson  = validateCmd.Flag("output-json", "Path to write the validation results to as JSON ('-' for stdout).").String()
+	maxSeverity = validateCmd.Flag("max-severity", "The highest severity of the validation results that doesn't fail the "+
+		"validation ('none' fails on warnings too).").Default(string(SeverityWarning)).
+		Enum(string(SeverityNone), string(SeverityWarning), string(SeverityError))
+
+	rpmDirs  = validateCmd.Flag("rpm-dir", "Directory of RPMs (or a local repo) to check that the config's packages are available in.").ExistingDirs()
+	repoUrls = validateCmd.Flag("repo-url", "Base URL of a remote repo to check that the config's packages are available in.").Strings()
+
+	deepResolve = validateCmd.Flag("deep-resolve", "Resolve the full dependency closure of each system config's packages with tdnf against the package repos, to detect conflicts and missing dependencies.").Bool()
+	repoFiles   = validateCmd.Flag("repo-file", "Repo file of a repo to resolve the dependencies of the config's packages against (deep resolution mode only).").ExistingFiles()
+
+	profiles = validateCmd.Flag("profile", "Security profile to check the config against: 'baseline' (loosely based on the CIS benchmarks), 'fips' or 'fedramp'. Can be repeated.").Enums(securityProfileNames()...)
+
+	batch = validateCmd.Flag("batch", "The input config is a batch config: validate the imagegen config of each of its images, with the batch's base packages added.").Bool()
+
+	configType = validateCmd.Flag("config-type", "The type of the config file: 'imagegen' (JSON), 'imagecustomizer' (YAML, whose relative paths are relative to the config file's dir) or 'auto' (by the file's extension).").Default(configTypeAuto).Enum(configTypeAuto, configTypeImageGen, configTypeImageCustomizer)
+
+	schemaCmd = app.Command("schema", "Print the JSON Schema of a config format, for editor integration (e.g. autocompletion).")
+
+	schemaConfigType = schemaCmd.Flag("config-type", "The config format: 'imagegen' (JSON) or 'imagecustomizer' (YAML).").Default(configTypeImageGen).Enum(configTypeImageGen, configTypeImageCustomizer)
+	schemaFormat     = schemaCmd.Flag("format", "The format to write the schema in: 'json' or 'yaml'.").Default(schemaFormatJson).Enum(schemaFormatJson, schemaFormatYaml)
+	schemaOutput     = schemaCmd.Flag("output", "Path to write the schema to ('-' for stdout).").Default("-").String()
+
+	lintCmd = app.Command("lint", "Check a config (or a package list) file against the lint rules, and optionally fix the findings.")
+
+	lintInput  = lintCmd.Flag("input", "Path to the config (or package list) file.").Required().ExistingFile()
+	lintFix    = lintCmd.Flag("fix", "Fix the findings that can be fixed automatically.").Bool()
+	lintDiff   = lintCmd.Flag("diff", "With --fix, write a unified diff of the fixes instead of the fixed file.").Bool()
+	lintOutput = lintCmd.Flag("output", "With --fix, path to write the fixed file (or the diff) to ('-' for stdout). Defaults to fixing the input file in place (or, with --diff, to stdout).").String()
+
+	diffCmd = app.Command("diff", "Print the semantic differences (packages, partitions, users and kernel arguments) between two config files.")
+
+	diffOldInput   = diffCmd.Arg("old", "Path to the old config file.").Required().ExistingFile()
+	diffNewInput   = diffCmd.Arg("new", "Path to the new config file.").Required().ExistingFile()
+	diffBaseDir    = diffCmd.Flag("dir", "Base directory for relative file paths from the imagegen configs. Defaults to each config file's dir.").ExistingDir()
+	diffConfigType = diffCmd.Flag("config-type", "The type of the config files: 'imagegen' (JSON), 'imagecustomizer' (YAML) or 'auto' (by each file's extension).").Default(configTypeAuto).Enum(configTypeAuto, configTypeImageGen, configTypeImageCustomizer)
+	diffFormat     = diffCmd.Flag("format", "The format to write the differences in: 'text' or 'json'.").Default(diffFormatText).Enum(diffFormatText, diffFormatJson)
+	diffOutput     = diffCmd.Flag("output", "Path to write the differences to ('-' for stdout).").Default("-").String()
+
+	kickstartCmd = app.Command("kickstart", "Import a kickstart file (its partitions, packages, users and networks) into an imagegen config, and report the kickstart directives that aren't imported.")
+
+	kickstartInput       = kickstartCmd.Flag("input", "Path to the kickstart file.").Required().ExistingFile()
+	kickstartOutput      = kickstartCmd.Flag("output", "Path to write the imported imagegen config to ('-' for stdout). Without it, the kickstart file is only checked.").String()
+	kickstartOutputJson  = kickstartCmd.Flag("output-json", "Path to write the unsupported directives and the imported config's validation results to as JSON ('-' for stdout).").String()
+	kickstartMaxSeverity = kickstartCmd.Flag("max-severity", "The highest severity of the results that doesn't fail the "+
+		"import ('none' fails on unsupported directives too).").Default(string(SeverityWarning)).
+		Enum(string(SeverityNone), string(SeverityWarning), string(SeverityError))
 )
 
 func main() {
-	const returnCodeOnError = 1
-
 	app.Version(exe.ToolkitVersion)
-	kingpin.MustParse(app.Parse(os.Args[1:]))
+	command := kingpin.MustParse(app.Parse(os.Args[1:]))
 	logger.InitBestEffort(logFlags)
 
+	switch command {
+	case schemaCmd.FullCommand():
+		os.Exit(runSchema())
+	case lintCmd.FullCommand():
+		os.Exit(runLint())
+	case diffCmd.FullCommand():
+		os.Exit(runDiff())
+	case kickstartCmd.FullCommand():
+		os.Exit(runKickstart())
+	default:
+		os.Exit(run())
+	}
+}
+
+func runSchema() (exitCode int) {
+	schema, unhandledTypes, err := configSchema(*schemaConfigType)
+	if err != nil {
+		logger.Log.Errorf("Failed to generate the schema: %s", err)
+		return exitCodeInternalError
+	}
+
+	for _, unhandledType := range unhandledTypes {
+		logger.Log.Warnf("The schema of type (%s) may be wrong, as the type has a custom unmarshaler", unhandledType)
+	}
+
+	err = writeSchema(schema, *schemaFormat, *schemaOutput)
+	if err != nil {
+		logger.Log.Errorf("%s", err)
+		return exitCodeInternalError
+	}
+
+	return exitCodeValid
+}
+
+func runLint() (exitCode int) {
+	document, err := loadLintDocument(*lintInput)
+	if err != nil {
+		logger.Log.Errorf("%s", err)
+		return exitCodeLoadFailure
+	}
+
+	results := document.lint(*lintFix)
+	for _, result := range results {
+		switch {
+		case result.Severity == SeverityError:
+			logger.Log.Errorf("Lint error '%s': [%s] %s", document.path, result.RuleId, result.Message)
+		case *lintFix:
+			logger.Log.Infof("Fixed '%s': [%s] %s", document.path, result.RuleId, result.Message)
+		default:
+			logger.Log.Warnf("Lint warning '%s': [%s] %s", document.path, result.RuleId, result.Message)
+		}
+	}
+
+	if !*lintFix {
+		// Without --fix, any finding fails the lint.
+		return validationExitCode(results, SeverityNone)
+	}
+
+	output, err := document.marshal()
+	if err != nil {
+		logger.Log.Errorf("%s", err)
+		return exitCodeInternalError
+	}
+
+	outputPath := *lintOutput
+	if *lintDiff {
+		output, err = lintUnifiedDiff(document.path, document.content, output)
+		if err != nil {
+			logger.Log.Errorf("%s", err)
+			return exitCodeInternalError
+		}
+		if outputPath == "" {
+			outputPath = "-"
+		}
+	} else if outputPath == "" {
+		if len(results) == 0 {
+			// Don't rewrite a file that has nothing to fix.
+			return exitCodeValid
+		}
+		outputPath = document.path
+	}
+
+	if outputPath == "-" {
+		_, err = fmt.Fprint(os.Stdout, output)
+	} else {
+		err = file.Write(output, outputPath)
+	}
+	if err != nil {
+		logger.Log.Errorf("Failed to write the fixed file (%s): %s", outputPath, err)
+		return exitCodeInternalError
+	}
+
+	// The findings that can't be fixed still fail the lint.
+	return validationExitCode(results, SeverityWarning)
+}
+
+// runDiff diffs two configs. Like diff, the exit code is exitCodeInvalid if the configs differ.
+func runDiff() (exitCode int) {
+	oldSummaries, err := loadConfigSummaries(*diffOldInput, *diffConfigType, *diffBaseDir)
+	if err != nil {
+		logger.Log.Errorf("%s", err)
+		return exitCodeLoadFailure
+	}
+
+	newSummaries, err := loadConfigSummaries(*diffNewInput, *diffConfigType, *diffBaseDir)
+	if err != nil {
+		logger.Log.Errorf("%s", err)
+		return exitCodeLoadFailure
+	}
+
+	diff := ConfigDiff{
+		OldConfig:     *diffOldInput,
+		NewConfig:     *diffNewInput,
+		SystemConfigs: diffConfigSummaries(oldSummaries, newSummaries),
+	}
+	err = writeConfigDiff(diff, *diffFormat, *diffOutput)
+	if err != nil {
+		logger.Log.Errorf("%s", err)
+		return exitCodeInternalError
+	}
+
+	if len(diff.SystemConfigs) > 0 {
+		return exitCodeInvalid
+	}
+	return exitCodeValid
+}
+
+// runKickstart imports a kickstart file into an imagegen config. Each kickstart directive that isn't imported is a
+// warning, and the imported config is validated like any other config.
+func runKickstart() (exitCode int) {
+	logger.Log.Infof("Importing kickstart file (%s)", *kickstartInput)
+	config, unsupported, err := configuration.ImportKickstart(*kickstartInput)
+	if err != nil {
+		logger.Log.Errorf("Failed while importing kickstart file '%s': %s", *kickstartInput, err)
+		return exitCodeLoadFailure
+	}
+
+	results := kickstartResults(unsupported)
+	results = append(results, ValidateConfigurationResults(config)...)
+	for _, result := range results {
+		switch {
+		case result.RuleId == ruleKickstartUnsupported:
+			logger.Log.Warnf("Unsupported kickstart directive '%s' (%s): %s", *kickstartInput, result.Path, result.Message)
+		case result.Severity == SeverityWarning:
+			logger.Log.Warnf("Risky imported configuration '%s': [%s] %s", *kickstartInput, result.RuleId, result.Message)
+		default:
+			logger.Log.Errorf("Invalid imported configuration '%s': [%s] %s", *kickstartInput, result.RuleId, result.Message)
+		}
+	}
+	exitCode = validationExitCode(results, ValidationSeverity(*kickstartMaxSeverity))
+
+	if *kickstartOutput != "" {
+		output, err := marshalImportedConfig(config)
+		if err != nil {
+			logger.Log.Errorf("%s", err)
+			return exitCodeInternalError
+		}
+
+		if *kickstartOutput == "-" {
+			_, err = fmt.Fprint(os.Stdout, output)
+		} else {
+			err = file.Write(output, *kickstartOutput)
+		}
+		if err != nil {
+			logger.Log.Errorf("Failed to write the imported config (%s): %s", *kickstartOutput, err)
+			return exitCodeInternalError
+		}
+	}
+
+	if *kickstartOutputJson != "" {
+		report := newValidationReport(*kickstartInput, results, exitCode)
+		err = writeValidationReport(report, *kickstartOutputJson)
+		if err != nil {
+			logger.Log.Errorf("%s", err)
+			exitCode = exitCodeInternalError
+		}
+	}
+
+	return exitCode
+}
+
+func run() (exitCode int) {
 	prof, err := profile.StartProfiling(profFlags)
 	if err != nil {
 		logger.Log.Warnf("Could not start profiling: %s", err)
@@ -54,42 +284,266 @@ func main() {
 	baseDir, err := filepath.Abs(*baseDirPath)
 	logger.PanicOnError(err, "Error when calculating input directory")
 
+	var (
+		results        []ValidationResult
+		transactions   []InstallTransaction
+		profileReports []ProfileReport
+	)
+
+	switch {
+	case *batch:
+		results, transactions, profileReports, exitCode = validateBatchConfigFile(inPath, baseDir)
+	case detectConfigType(inPath, *configType) == configTypeImageCustomizer:
+		results, exitCode = validateCustomizerConfigFile(inPath)
+	default:
+		results, transactions, profileReports, exitCode = validateImageGenConfigFile(inPath, baseDir)
+	}
+
+	if exitCode == exitCodeValid {
+		for _, result := range results {
+			// Log an error here as opposed to panicing to keep the output simple
+			// and only contain the error with the config file.
+			if result.Severity == SeverityWarning {
+				logger.Log.Warnf("Risky configuration '%s': [%s] %s", inPath, result.RuleId, result.Message)
+			} else {
+				logger.Log.Errorf("Invalid configuration '%s': [%s] %s", inPath, result.RuleId, result.Message)
+			}
+		}
+		exitCode = validationExitCode(results, ValidationSeverity(*maxSeverity))
+	}
+
+	if exitCode == exitCodeInternalError {
+		return exitCode
+	}
+
+	if *outputJson != "" {
+		report := newValidationReport(inPath, results, exitCode)
+		report.Transactions = transactions
+		report.Profiles = profileReports
+		err = writeValidationReport(report, *outputJson)
+		if err != nil {
+			logger.Log.Errorf("%s", err)
+			exitCode = exitCodeInternalError
+		}
+	}
+
+	return exitCode
+}
+
+// validateImageGenConfigFile loads and validates an imagegen config. The exit code is exitCodeValid, unless the
+// config can't be loaded or the validator itself fails.
+func validateImageGenConfigFile(inPath string, baseDir string) (results []ValidationResult,
+	transactions []InstallTransaction, profileReports []ProfileReport, exitCode int,
+) {
 	logger.Log.Infof("Reading configuration file (%s)", inPath)
 	config, err := configuration.LoadWithAbsolutePaths(inPath, baseDir)
 	if err != nil {
-		logger.Log.Fatalf("Failed while loading image configuration '%s': %s", inPath, err)
+		logger.Log.Errorf("Failed while loading image configuration '%s': %s", inPath, err)
+		return []ValidationResult{newValidationResult(ruleConfigLoad, "", err)}, nil, nil, exitCodeLoadFailure
+	}
+
+	packages, err := loadAvailablePackagesIfSelected()
+	if err != nil {
+		logger.Log.Errorf("Failed to read the available packages: %s", err)
+		return nil, nil, nil, exitCodeInternalError
+	}
+
+	return validateImageGenConfig(config, packages)
+}
+
+// validateBatchConfigFile loads a batch config, and validates the config of each of its images (with the batch's base
+// packages) like an imagegen config. The paths of the results are prefixed with the image's path in the batch config.
+func validateBatchConfigFile(inPath string, baseDir string) (results []ValidationResult,
+	transactions []InstallTransaction, profileReports []ProfileReport, exitCode int,
+) {
+	logger.Log.Infof("Reading batch configuration file (%s)", inPath)
+	batchConfig, configs, err := configuration.LoadBatchWithAbsolutePaths(inPath, baseDir)
+	if err != nil {
+		logger.Log.Errorf("Failed while loading batch configuration '%s': %s", inPath, err)
+		return []ValidationResult{newValidationResult(ruleConfigLoad, "", err)}, nil, nil, exitCodeLoadFailure
+	}
+
+	// The available packages are only read once for all of the images.
+	packages, err := loadAvailablePackagesIfSelected()
+	if err != nil {
+		logger.Log.Errorf("Failed to read the available packages: %s", err)
+		return nil, nil, nil, exitCodeInternalError
+	}
+
+	for i, config := range configs {
+		logger.Log.Infof("Validating image (%s)", batchConfig.Images[i].Name)
+		imageResults, imageTransactions, imageProfileReports, imageExitCode := validateImageGenConfig(config, packages)
+		if imageExitCode != exitCodeValid {
+			return nil, nil, nil, imageExitCode
+		}
+
+		for _, result := range imageResults {
+			result.Path = batchImagePath(i, result.Path)
+			results = append(results, result)
+		}
+		transactions = append(transactions, imageTransactions...)
+		profileReports = append(profileReports, imageProfileReports...)
+	}
+
+	return results, transactions, profileReports, exitCodeValid
+}
+
+// batchImagePath returns the path of a setting of an image's config in a batch config.
+func batchImagePath(imageIndex int, path string) string {
+	imagePath := fmt.Sprintf("Images[%d]", imageIndex)
+	if path == "" {
+		return imagePath
+	}
+	return imagePath + "." + path
+}
+
+// loadAvailablePackagesIfSelected reads the available packages, if RPM dirs or repo URLs are selected. Otherwise, the
+// packages are nil.
+func loadAvailablePackagesIfSelected() (packages *availablePackages, err error) {
+	if len(*rpmDirs) == 0 && len(*repoUrls) == 0 {
+		return nil, nil
 	}
+	return loadAvailablePackages(*rpmDirs, *repoUrls)
+}
+
+// validateImageGenConfig validates a loaded imagegen config, and checks its packages against the available packages,
+// if any. The exit code is exitCodeValid, unless the validator itself fails.
+func validateImageGenConfig(config configuration.Config, packages *availablePackages) (results []ValidationResult,
+	transactions []InstallTransaction, profileReports []ProfileReport, exitCode int,
+) {
+	var err error
+
 	// Basic validation will occur during load, but we can add additional checking here.
-	err = ValidateConfiguration(config)
+	results = ValidateConfigurationResults(config)
+	if packages != nil {
+		results = append(results, validatePackageAvailability(config, packages)...)
+	}
+
+	if *deepResolve {
+		var resolveResults []ValidationResult
+		resolveResults, transactions, err = resolveDependencies(config, *rpmDirs, *repoUrls, *repoFiles)
+		if err != nil {
+			logger.Log.Errorf("Failed to resolve the dependencies of the config's packages: %s", err)
+			return nil, nil, nil, exitCodeInternalError
+		}
+		results = append(results, resolveResults...)
+	}
+
+	if packages != nil {
+		results = append(results, validateInstalledSize(config, packages, transactions)...)
+	}
+
+	if len(*profiles) > 0 {
+		var profileResults []ValidationResult
+		profileResults, profileReports = validateSecurityProfiles(config, *profiles)
+		results = append(results, profileResults...)
+		logProfileReports(profileReports)
+	}
+
+	return results, transactions, profileReports, exitCodeValid
+}
+
+// validateCustomizerConfigFile loads and validates an imagecustomizer config. Like imagecustomizer, relative paths are
+// relative to the config file's directory.
+func validateCustomizerConfigFile(inPath string) (results []ValidationResult, exitCode int) {
+	if *deepResolve {
+		logger.Log.Errorf("Resolving dependencies (--deep-resolve) is not supported for imagecustomizer configs, as " +
+			"their packages are installed on top of a base image")
+		return nil, exitCodeInternalError
+	}
+	if len(*profiles) > 0 {
+		logger.Log.Errorf("Security profiles (--profile) are not supported for imagecustomizer configs")
+		return nil, exitCodeInternalError
+	}
+
+	logger.Log.Infof("Reading imagecustomizer configuration file (%s)", inPath)
+	config, err := loadCustomizerConfig(inPath)
 	if err != nil {
-		// Log an error here as opposed to panicing to keep the output simple
-		// and only contain the error with the config file.
-		logger.Log.Fatalf("Invalid configuration '%s': %s", inPath, err)
+		logger.Log.Errorf("Failed while loading imagecustomizer configuration '%s': %s", inPath, err)
+		return []ValidationResult{newValidationResult(ruleConfigLoad, "", err)}, exitCodeLoadFailure
 	}
 
-	return
+	baseConfigPath := filepath.Dir(inPath)
+	results = ValidateCustomizerConfigResults(config, baseConfigPath)
+	if len(*rpmDirs) > 0 || len(*repoUrls) > 0 {
+		packages, err := loadAvailablePackages(*rpmDirs, *repoUrls)
+		if err != nil {
+			logger.Log.Errorf("Failed to read the available packages: %s", err)
+			return nil, exitCodeInternalError
+		}
+		results = append(results, validateCustomizerPackageAvailability(config, baseConfigPath, packages)...)
+	}
+
+	return results, exitCodeValid
 }
 
-// ValidateConfiguration will run sanity checks on a configuration structure
+// ValidateConfiguration will run sanity checks on a configuration structure, and returns the first error (warnings
+// are ignored)
 func ValidateConfiguration(config configuration.Config) (err error) {
+	results := ValidateConfigurationResults(config)
+	for _, result := range results {
+		if result.Severity == SeverityError {
+			return result.err
+		}
+	}
+	return nil
+}
+
+// ValidateConfigurationResults will run sanity checks on a configuration structure, and returns all the errors and
+// warnings
+func ValidateConfigurationResults(config configuration.Config) (results []ValidationResult) {
 	timestamp.StartEvent("validating config", nil)
 	defer timestamp.StopEvent(nil)
 
-	err = config.IsValid()
+	err := config.IsValid()
 	if err != nil {
-		return
+		// The remaining checks assume a structurally valid config.
+		return []ValidationResult{newValidationResult(ruleConfigInvalid, "", err)}
 	}
 
-	err = validatePackages(config)
-	if err != nil {
-		return
+	results = append(results, validatePackages(config)...)
+	results = append(results, validateKickStartInstall(config)...)
+	results = append(results, validateBootPartitionSize(config)...)
+	results = append(results, validateDiskLayout(config)...)
+	results = append(results, validateUsers(config)...)
+	results = append(results, validateLocalAssets(config)...)
+	return
+}
+
+func validateBootPartitionSize(config configuration.Config) (results []ValidationResult) {
+	// Enough for a couple of kernels and their initrds (e.g. while updating the kernel).
+	const minBootPartitionSizeMiB = 256
+
+	for _, systemConfig := range config.SystemConfigs {
+		for _, partitionSetting := range systemConfig.PartitionSettings {
+			if partitionSetting.MountPoint != "/boot" {
+				continue
+			}
+
+			for diskIndex, disk := range config.Disks {
+				for partitionIndex, partition := range disk.Partitions {
+					// An 'End' of 0 means that the partition fills the rest of the disk.
+					if partition.ID != partitionSetting.ID || partition.End == 0 {
+						continue
+					}
+
+					sizeMiB := partition.End - partition.Start
+					if sizeMiB < minBootPartitionSizeMiB {
+						results = append(results, newValidationWarning(ruleBootPartitionSize,
+							fmt.Sprintf("Disks[%d].Partitions[%d]", diskIndex, partitionIndex),
+							fmt.Sprintf("the /boot partition (%s) is only %d MiB, which may not fit the kernels and initrds "+
+								"installed by updates (recommended: at least %d MiB)", partition.ID, sizeMiB,
+								minBootPartitionSizeMiB)))
+					}
+				}
+			}
+		}
 	}
 
-	err = validateKickStartInstall(config)
 	return
 }
 
-func validateKickStartInstall(config configuration.Config) (err error) {
+func validateKickStartInstall(config configuration.Config) (results []ValidationResult) {
 	timestamp.StartEvent("validate kickstart", nil)
 	defer timestamp.StopEvent(nil)
 
@@ -97,10 +551,15 @@ func validateKickStartInstall(config configuration.Config) (err error) {
 	// must not have any partitioning info because that will be provided
 	// by the preinstall script
 
-	for _, systemConfig := range config.SystemConfigs {
+	for i, systemConfig := range config.SystemConfigs {
 		if systemConfig.IsKickStartBoot {
 			if len(config.Disks) > 0 || len(systemConfig.PartitionSettings) > 0 {
-				return fmt.Errorf("partition should not be specified in image config file when performing kickstart installation")
+				path := "Disks"
+				if len(systemConfig.PartitionSettings) > 0 {
+					path = systemConfigPath(i, "PartitionSettings")
+				}
+				results = append(results, newValidationResult(ruleKickStartPartitions, path,
+					fmt.Errorf("partition should not be specified in image config file when performing kickstart installation")))
 			}
 		}
 	}
@@ -108,71 +567,29 @@ func validateKickStartInstall(config configuration.Config) (err error) {
 	return
 }
 
-func validatePackages(config configuration.Config) (err error) {
+// validatePackages checks the system configs' package lists against the cross check rules (see crossCheckRules).
+func validatePackages(config configuration.Config) (results []ValidationResult) {
 	timestamp.StartEvent("validate packages", nil)
 	defer timestamp.StopEvent(nil)
 
-	const (
-		validateError     = "failed to validate package lists in config"
-		kernelPkgName     = "kernel"
-		dracutFipsPkgName = "dracut-fips"
-		fipsKernelCmdLine = "fips=1"
-		userAddPkgName    = "shadow-utils"
-	)
+	for i, systemConfig := range config.SystemConfigs {
+		packageListPath := systemConfigPath(i, "PackageLists")
 
-	for _, systemConfig := range config.SystemConfigs {
 		packageList, err := installutils.PackageNamesFromSingleSystemConfig(systemConfig)
 		if err != nil {
-			return fmt.Errorf("%s: %w", validateError, err)
-		}
-		foundSELinuxPackage := false
-		foundDracutFipsPackage := false
-		foundUserAddPackage := false
-		kernelCmdLineString := systemConfig.KernelCommandLine.ExtraCommandLine
-		selinuxPkgName := systemConfig.KernelCommandLine.SELinuxPolicy
-		if selinuxPkgName == "" {
-			selinuxPkgName = configuration.SELinuxPolicyDefault
-		}
-
-		foundKernelPackage, err := installutils.PackagelistContainsPackage(packageList, kernelPkgName)
-		if err != nil {
-			return fmt.Errorf("%s: %w", validateError, err)
-		}
-
-		foundDracutFipsPackage, err = installutils.PackagelistContainsPackage(packageList, dracutFipsPkgName)
-		if err != nil {
-			return fmt.Errorf("%s: %w", validateError, err)
+			results = append(results, newValidationResult(rulePackageList, packageListPath,
+				fmt.Errorf("%s: %w", packageListsValidateError, err)))
+			continue
 		}
 
-		foundSELinuxPackage, err = installutils.PackagelistContainsPackage(packageList, selinuxPkgName)
+		ctx, err := newCrossCheckContext(systemConfig, packageList)
 		if err != nil {
-			return fmt.Errorf("%s: %w", validateError, err)
+			results = append(results, newValidationResult(rulePackageList, packageListPath,
+				fmt.Errorf("%s: %w", packageListsValidateError, err)))
+			continue
 		}
 
-		foundUserAddPackage, err = installutils.PackagelistContainsPackage(packageList, userAddPkgName)
-		if err != nil {
-			return fmt.Errorf("%s: %w", validateError, err)
-		}
-
-		if foundKernelPackage {
-			return fmt.Errorf("%s: kernel should not be included in a package list, add via config file's [KernelOptions] entry", validateError)
-		}
-
-		if strings.Contains(kernelCmdLineString, fipsKernelCmdLine) || systemConfig.KernelCommandLine.EnableFIPS {
-			if !foundDracutFipsPackage {
-				return fmt.Errorf("%s: 'fips=1' provided on kernel cmdline, but '%s' package is not included in the package lists", validateError, dracutFipsPkgName)
-			}
-		}
-		if systemConfig.KernelCommandLine.SELinux != configuration.SELinuxOff {
-			if !foundSELinuxPackage {
-				return fmt.Errorf("%s: [SELinux] selected, but '%s' package is not included in the package lists", validateError, selinuxPkgName)
-			}
-		}
-		if len(systemConfig.Users) > 0 || len(systemConfig.Groups) > 0 {
-			if !foundUserAddPackage {
-				return fmt.Errorf("%s: the '%s' package must be included in the package lists when the image is configured to add users or groups", validateError, userAddPkgName)
-			}
-		}
+		results = append(results, runCrossCheckRules(crossCheckRules, i, ctx)...)
 	}
 
 	return
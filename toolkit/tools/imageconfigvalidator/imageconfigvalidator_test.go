@@ -230,3 +230,37 @@ func TestValidationAgainstTestConfig(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateBatchConfigFile(t *testing.T) {
+	configPath, err := filepath.Abs("./testdata/test-config.json")
+	assert.NoError(t, err)
+
+	batchDir := t.TempDir()
+	batchPath := filepath.Join(batchDir, "batch.json")
+	err = os.WriteFile(batchPath, []byte(`{
+	"Packages": ["selinux-policy"],
+	"Images": [
+		{"Name": "first", "Config": "`+configPath+`"},
+		{"Name": "second", "Config": "`+configPath+`"}
+	]
+}`), 0o644)
+	assert.NoError(t, err)
+
+	_, _, _, exitCode := validateBatchConfigFile(batchPath, filepath.Dir(configPath))
+	assert.Equal(t, exitCodeValid, exitCode)
+
+	missingPath := filepath.Join(batchDir, "missing.json")
+	err = os.WriteFile(missingPath, []byte(`{"Images": [{"Name": "missing", "Config": "missing.json"}]}`), 0o644)
+	assert.NoError(t, err)
+
+	results, _, _, exitCode := validateBatchConfigFile(missingPath, batchDir)
+	assert.Equal(t, exitCodeLoadFailure, exitCode)
+	if assert.Len(t, results, 1) {
+		assert.Equal(t, ruleConfigLoad, results[0].RuleId)
+	}
+}
+
+func TestBatchImagePath(t *testing.T) {
+	assert.Equal(t, "Images[1]", batchImagePath(1, ""))
+	assert.Equal(t, "Images[0].SystemConfigs[0].PackageLists", batchImagePath(0, "SystemConfigs[0].PackageLists"))
+}
@@ -0,0 +1,87 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/imagegen/configuration"
+
+	"gopkg.in/yaml.v3"
+)
+
+// kickstartResults returns a warning for each kickstart directive that wasn't imported into the config.
+func kickstartResults(unsupported []configuration.UnsupportedKickstartDirective) (results []ValidationResult) {
+	for _, directive := range unsupported {
+		results = append(results, newValidationWarning(ruleKickstartUnsupported, fmt.Sprintf("line %d", directive.Line),
+			fmt.Sprintf("(%s) isn't imported: %s", directive.Directive, directive.Reason)))
+	}
+	return
+}
+
+// marshalImportedConfig marshals a config imported from a kickstart file as JSON. The unset (zero) values are left out,
+// so that the config only has the kickstart file's settings, and the loader's defaults apply to the rest.
+func marshalImportedConfig(config configuration.Config) (content string, err error) {
+	configBytes, err := json.Marshal(config)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal the imported config:\n%w", err)
+	}
+
+	// Parse the JSON as YAML nodes, which keep the order of the config's fields.
+	root := yaml.Node{}
+	err = yaml.Unmarshal(configBytes, &root)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse the imported config:\n%w", err)
+	}
+
+	node := root.Content[0]
+	pruneUnsetJsonNodes(node)
+
+	buffer := bytes.Buffer{}
+	err = writeJsonNode(&buffer, node, defaultJsonIndent, 0)
+	if err != nil {
+		return "", err
+	}
+	buffer.WriteString("\n")
+
+	return buffer.String(), nil
+}
+
+// pruneUnsetJsonNodes removes the fields of the mappings under the node that are null, false, zero, empty or only have
+// unset fields. It returns true if the node itself is unset. The items of a sequence are kept, as their index matters.
+func pruneUnsetJsonNodes(node *yaml.Node) (unset bool) {
+	switch node.Kind {
+	case yaml.MappingNode:
+		content := node.Content[:0]
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if !pruneUnsetJsonNodes(node.Content[i+1]) {
+				content = append(content, node.Content[i], node.Content[i+1])
+			}
+		}
+		node.Content = content
+		return len(node.Content) == 0
+
+	case yaml.SequenceNode:
+		for _, item := range node.Content {
+			pruneUnsetJsonNodes(item)
+		}
+		return len(node.Content) == 0
+
+	case yaml.ScalarNode:
+		switch node.Tag {
+		case "!!null":
+			return true
+		case "!!bool":
+			return node.Value == "false"
+		case "!!int", "!!float":
+			return node.Value == "0"
+		case "!!str":
+			return node.Value == ""
+		}
+	}
+
+	return false
+}
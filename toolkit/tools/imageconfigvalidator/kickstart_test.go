@@ -0,0 +1,73 @@
+// Copyright Microsoft Corporation.
+// Licensed under the MIT License.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/imagegen/configuration"
+	"github.com/stretchr/testify/assert"
+)
+
+const testKickstart = `clearpart --all --disklabel=gpt
+part /boot/efi --fstype=efi --size=100
+part / --fstype=ext4 --size=4096 --grow
+bootloader --append="console=ttyS0"
+rootpw --lock
+user --name=azureuser --groups=wheel --password=$6$salt$hash --iscrypted
+network --device=eth0 --bootproto=dhcp
+lang en_US.UTF-8
+%packages
+core-packages-base-image
+openssh-server
+%end
+`
+
+func TestKickstartResults(t *testing.T) {
+	results := kickstartResults([]configuration.UnsupportedKickstartDirective{
+		{Line: 8, Directive: "lang", Reason: "the directive isn't supported"},
+	})
+
+	assert.Equal(t, []ValidationResult{
+		newValidationWarning(ruleKickstartUnsupported, "line 8", "(lang) isn't imported: the directive isn't supported"),
+	}, results)
+}
+
+func TestMarshalImportedConfig(t *testing.T) {
+	config, unsupported, err := configuration.ParseKickstart(testKickstart)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Len(t, unsupported, 1)
+
+	output, err := marshalImportedConfig(config)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// The unset fields are left out.
+	assert.NotContains(t, output, "null")
+	assert.NotContains(t, output, "false")
+	assert.NotContains(t, output, `""`)
+	assert.Contains(t, output, "\n    \"Disks\": [\n")
+
+	// The loader reads the config back as it was imported.
+	configPath := filepath.Join(t.TempDir(), "kickstart.json")
+	err = os.WriteFile(configPath, []byte(output), 0o644)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	loadedConfig, err := configuration.Load(configPath)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, config.Disks, loadedConfig.Disks)
+	assert.Equal(t, config.SystemConfigs[0].PartitionSettings, loadedConfig.SystemConfigs[0].PartitionSettings)
+	assert.Equal(t, config.SystemConfigs[0].Users, loadedConfig.SystemConfigs[0].Users)
+	assert.Equal(t, config.SystemConfigs[0].Networks, loadedConfig.SystemConfigs[0].Networks)
+	assert.Equal(t, config.SystemConfigs[0].Packages, loadedConfig.SystemConfigs[0].Packages)
+}
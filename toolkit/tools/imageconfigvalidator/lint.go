@@ -0,0 +1,393 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/imagegen/configuration"
+
+	"github.com/pmezard/go-difflib/difflib"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// A package list file (e.g. imageconfigs/packagelists/*.json), which both config types reference.
+	lintKindPackageList = "packagelist"
+
+	cgroupCmdlineArg  = "systemd.unified_cgroup_hierarchy"
+	defaultJsonIndent = "    "
+	yamlIndent        = 2
+)
+
+var jsonIndentRegex = regexp.MustCompile(`(?m)^([ \t]+)\S`)
+
+// lintDocument is a config (or a package list) file, parsed into YAML nodes, which keep the order (and, for YAML, the
+// comments) of the file, so that fixing the file only changes what the lint rules fix. JSON files are parsed as YAML,
+// which JSON is a subset of.
+type lintDocument struct {
+	path    string
+	kind    string
+	isJson  bool
+	content string
+	root    *yaml.Node
+}
+
+// loadLintDocument parses a config (or a package list) file for linting.
+func loadLintDocument(path string) (document *lintDocument, err error) {
+	contentBytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file (%s):\n%w", path, err)
+	}
+
+	var node yaml.Node
+	err = yaml.Unmarshal(contentBytes, &node)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse file (%s):\n%w", path, err)
+	}
+
+	if node.Kind != yaml.DocumentNode || len(node.Content) != 1 || node.Content[0].Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("file (%s) is not a config file (or a package list file)", path)
+	}
+
+	document = &lintDocument{
+		path:    path,
+		isJson:  strings.EqualFold(filepath.Ext(path), ".json"),
+		content: string(contentBytes),
+		root:    &node,
+	}
+
+	root := node.Content[0]
+	switch {
+	case mappingValue(root, "packages") != nil && len(root.Content) == 2:
+		document.kind = lintKindPackageList
+	case document.isJson:
+		document.kind = configTypeImageGen
+	default:
+		document.kind = configTypeImageCustomizer
+	}
+
+	return document, nil
+}
+
+// lint checks the document against the lint rules, and fixes the findings if fix is true. Findings that can be fixed
+// are warnings, and the ones that can't are errors.
+func (d *lintDocument) lint(fix bool) (results []ValidationResult) {
+	root := d.root.Content[0]
+
+	switch d.kind {
+	case lintKindPackageList:
+		results = append(results, lintPackages(mappingValue(root, "packages"), "packages", fix)...)
+
+	case configTypeImageGen:
+		for i, systemConfig := range sequenceItems(mappingValue(root, "SystemConfigs")) {
+			results = append(results, lintPackages(mappingValue(systemConfig, "Packages"),
+				systemConfigPath(i, "Packages"), fix)...)
+
+			for j, partitionSetting := range sequenceItems(mappingValue(systemConfig, "PartitionSettings")) {
+				results = append(results, lintMountOptions(mappingValue(partitionSetting, "MountOptions"),
+					systemConfigPath(i, fmt.Sprintf("PartitionSettings[%d].MountOptions", j)), fix)...)
+			}
+
+			results = append(results, lintDeprecatedCmdline(systemConfig, i, fix)...)
+		}
+
+	case configTypeImageCustomizer:
+		packages := mappingValue(mappingValue(root, "os"), "packages")
+		for _, field := range []string{"install", "remove", "update"} {
+			results = append(results, lintPackages(mappingValue(packages, field), "os.packages."+field, fix)...)
+		}
+
+		filesystems := mappingValue(mappingValue(root, "storage"), "filesystems")
+		for i, filesystem := range sequenceItems(filesystems) {
+			mountPoint := mappingValue(filesystem, "mountPoint")
+			if mountPoint == nil || mountPoint.Kind != yaml.MappingNode {
+				// A mount point that is just a path has no options.
+				continue
+			}
+			results = append(results, lintMountOptions(mappingValue(mountPoint, "options"),
+				fmt.Sprintf("storage.filesystems[%d].mountPoint.options", i), fix)...)
+		}
+	}
+
+	return results
+}
+
+// lintPackages checks that a package list has no duplicate packages, and that it's sorted.
+func lintPackages(packages *yaml.Node, path string, fix bool) (results []ValidationResult) {
+	if packages == nil || packages.Kind != yaml.SequenceNode {
+		return nil
+	}
+
+	seen := make(map[string]bool)
+	uniquePackages := []*yaml.Node(nil)
+	for _, pkg := range packages.Content {
+		pkgString := strings.TrimSpace(pkg.Value)
+		if seen[pkgString] {
+			results = append(results, newValidationWarning(ruleLintPackagesDuplicate, path,
+				fmt.Sprintf("package (%s) is listed more than once", pkgString)))
+			continue
+		}
+		seen[pkgString] = true
+		uniquePackages = append(uniquePackages, pkg)
+	}
+
+	isSorted := sort.SliceIsSorted(uniquePackages, func(i, j int) bool {
+		return uniquePackages[i].Value < uniquePackages[j].Value
+	})
+	if !isSorted {
+		results = append(results, newValidationWarning(ruleLintPackagesUnsorted, path,
+			"packages are not sorted alphabetically"))
+	}
+
+	if fix {
+		sort.SliceStable(uniquePackages, func(i, j int) bool {
+			return uniquePackages[i].Value < uniquePackages[j].Value
+		})
+		packages.Content = uniquePackages
+	}
+
+	return results
+}
+
+// canonicalMountOptions returns the mount options without white spaces, empty options and duplicate options.
+func canonicalMountOptions(mountOptions string) string {
+	seen := make(map[string]bool)
+	options := []string(nil)
+	for _, option := range strings.Split(mountOptions, ",") {
+		option = strings.TrimSpace(option)
+		if option == "" || seen[option] {
+			continue
+		}
+		seen[option] = true
+		options = append(options, option)
+	}
+	return strings.Join(options, ",")
+}
+
+func lintMountOptions(mountOptions *yaml.Node, path string, fix bool) (results []ValidationResult) {
+	if mountOptions == nil || mountOptions.Kind != yaml.ScalarNode {
+		return nil
+	}
+
+	canonicalOptions := canonicalMountOptions(mountOptions.Value)
+	if canonicalOptions == mountOptions.Value {
+		return nil
+	}
+
+	results = append(results, newValidationWarning(ruleLintMountOptions, path,
+		fmt.Sprintf("mount options (%s) are not canonical (%s)", mountOptions.Value, canonicalOptions)))
+	if fix {
+		mountOptions.Value = canonicalOptions
+	}
+	return results
+}
+
+// lintDeprecatedCmdline migrates the kernel arguments of [ExtraCommandLine] that have a dedicated setting in
+// [KernelCommandLine] to the setting.
+func lintDeprecatedCmdline(systemConfig *yaml.Node, systemConfigIndex int, fix bool) (results []ValidationResult) {
+	kernelCommandLine := mappingValue(systemConfig, "KernelCommandLine")
+	extraCommandLine := mappingValue(kernelCommandLine, "ExtraCommandLine")
+	if extraCommandLine == nil || extraCommandLine.Kind != yaml.ScalarNode {
+		return nil
+	}
+
+	path := systemConfigPath(systemConfigIndex, "KernelCommandLine.ExtraCommandLine")
+	keptArgs := []string(nil)
+	settings := [][2]string(nil)
+	for _, arg := range strings.Fields(extraCommandLine.Value) {
+		argName, argValue, _ := strings.Cut(arg, "=")
+
+		switch {
+		case arg == fipsKernelCmdLine:
+			results = append(results, newValidationWarning(ruleLintDeprecatedCmdline, path,
+				fmt.Sprintf("kernel argument (%s) should be set with [EnableFIPS]", arg)))
+			settings = append(settings, [2]string{"EnableFIPS", "true"})
+
+		case argName == cgroupCmdlineArg && (argValue == "0" || argValue == "1"):
+			cgroup := string(configuration.CGroupV2)
+			if argValue == "0" {
+				cgroup = string(configuration.CGroupV1)
+			}
+
+			existingCGroup := mappingValue(kernelCommandLine, "CGroup")
+			if existingCGroup != nil && existingCGroup.Value != "" && existingCGroup.Value != cgroup {
+				results = append(results, newValidationResult(ruleLintDeprecatedCmdline, path,
+					fmt.Errorf("kernel argument (%s) conflicts with [CGroup] (%s)", arg, existingCGroup.Value)))
+				keptArgs = append(keptArgs, arg)
+				continue
+			}
+
+			results = append(results, newValidationWarning(ruleLintDeprecatedCmdline, path,
+				fmt.Sprintf("kernel argument (%s) should be set with [CGroup] (%s)", arg, cgroup)))
+			settings = append(settings, [2]string{"CGroup", cgroup})
+
+		default:
+			keptArgs = append(keptArgs, arg)
+		}
+	}
+
+	if fix && len(settings) > 0 {
+		extraCommandLine.Value = strings.Join(keptArgs, " ")
+		for _, setting := range settings {
+			setMappingValue(kernelCommandLine, setting[0], setting[1])
+		}
+	}
+
+	return results
+}
+
+// mappingValue returns the value of a key of a mapping node, or nil if the node is not a mapping or has no such key.
+func mappingValue(mapping *yaml.Node, key string) *yaml.Node {
+	if mapping == nil || mapping.Kind != yaml.MappingNode {
+		return nil
+	}
+
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// setMappingValue sets the value of a key of a mapping node to a scalar, adding the key if it's missing.
+func setMappingValue(mapping *yaml.Node, key string, value string) {
+	valueNode := &yaml.Node{}
+	valueNode.SetString(value)
+	if value == "true" || value == "false" {
+		valueNode.Tag = "!!bool"
+	}
+
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			mapping.Content[i+1] = valueNode
+			return
+		}
+	}
+
+	keyNode := &yaml.Node{}
+	keyNode.SetString(key)
+	mapping.Content = append(mapping.Content, keyNode, valueNode)
+}
+
+// sequenceItems returns the items of a sequence node, or nil if the node is not a sequence.
+func sequenceItems(sequence *yaml.Node) []*yaml.Node {
+	if sequence == nil || sequence.Kind != yaml.SequenceNode {
+		return nil
+	}
+	return sequence.Content
+}
+
+// marshal returns the content of the document, in the format (and, for JSON, with the indentation) of its file.
+func (d *lintDocument) marshal() (content string, err error) {
+	buffer := bytes.Buffer{}
+
+	if d.isJson {
+		indent := defaultJsonIndent
+		matches := jsonIndentRegex.FindStringSubmatch(d.content)
+		if matches != nil {
+			indent = matches[1]
+		}
+
+		err = writeJsonNode(&buffer, d.root.Content[0], indent, 0)
+		if err != nil {
+			return "", err
+		}
+		if strings.HasSuffix(d.content, "\n") {
+			buffer.WriteString("\n")
+		}
+		return buffer.String(), nil
+	}
+
+	encoder := yaml.NewEncoder(&buffer)
+	encoder.SetIndent(yamlIndent)
+	err = encoder.Encode(d.root)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal (%s):\n%w", d.path, err)
+	}
+	return buffer.String(), nil
+}
+
+// writeJsonNode writes a YAML node (parsed from JSON) as indented JSON.
+func writeJsonNode(buffer *bytes.Buffer, node *yaml.Node, indent string, level int) error {
+	switch node.Kind {
+	case yaml.MappingNode, yaml.SequenceNode:
+		open, close, step := "{", "}", 2
+		if node.Kind == yaml.SequenceNode {
+			open, close, step = "[", "]", 1
+		}
+
+		if len(node.Content) == 0 {
+			buffer.WriteString(open + close)
+			return nil
+		}
+
+		buffer.WriteString(open + "\n")
+		for i := 0; i < len(node.Content); i += step {
+			buffer.WriteString(strings.Repeat(indent, level+1))
+			if node.Kind == yaml.MappingNode {
+				err := writeJsonNode(buffer, node.Content[i], indent, level+1)
+				if err != nil {
+					return err
+				}
+				buffer.WriteString(": ")
+			}
+
+			err := writeJsonNode(buffer, node.Content[i+step-1], indent, level+1)
+			if err != nil {
+				return err
+			}
+
+			if i+step < len(node.Content) {
+				buffer.WriteString(",")
+			}
+			buffer.WriteString("\n")
+		}
+		buffer.WriteString(strings.Repeat(indent, level) + close)
+
+	case yaml.ScalarNode:
+		if node.Tag != "!!str" {
+			// Numbers, booleans and null are written as they are.
+			buffer.WriteString(node.Value)
+			return nil
+		}
+
+		encoder := json.NewEncoder(buffer)
+		encoder.SetEscapeHTML(false)
+		err := encoder.Encode(node.Value)
+		if err != nil {
+			return fmt.Errorf("failed to marshal string (%s):\n%w", node.Value, err)
+		}
+		// Drop the new line that the encoder adds.
+		buffer.Truncate(buffer.Len() - 1)
+
+	default:
+		return fmt.Errorf("unsupported JSON node (line %d)", node.Line)
+	}
+
+	return nil
+}
+
+// lintUnifiedDiff returns a unified diff between the original and the fixed content of a file.
+func lintUnifiedDiff(path string, original string, fixed string) (string, error) {
+	diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(original),
+		B:        difflib.SplitLines(fixed),
+		FromFile: path,
+		ToFile:   path,
+		Context:  3,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to diff (%s):\n%w", path, err)
+	}
+	return diff, nil
+}
@@ -0,0 +1,179 @@
+// Copyright Microsoft Corporation.
+// Licensed under the MIT License.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func loadTestLintDocument(t *testing.T, fileName string, content string) *lintDocument {
+	path := filepath.Join(t.TempDir(), fileName)
+	err := os.WriteFile(path, []byte(content), 0o644)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	document, err := loadLintDocument(path)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	return document
+}
+
+func TestLintPackageListDuplicatesAndOrder(t *testing.T) {
+	document := loadTestLintDocument(t, "packages.json", `{
+    "packages": [
+        "vim",
+        "bash",
+        "vim",
+        "core-packages-base-image"
+    ]
+}
+`)
+	assert.Equal(t, lintKindPackageList, document.kind)
+
+	results := document.lint(true)
+	if !assert.Len(t, results, 2) {
+		return
+	}
+	assert.Equal(t, ruleLintPackagesDuplicate, results[0].RuleId)
+	assert.Equal(t, ruleLintPackagesUnsorted, results[1].RuleId)
+	assert.Equal(t, SeverityWarning, results[1].Severity)
+
+	content, err := document.marshal()
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, `{
+    "packages": [
+        "bash",
+        "core-packages-base-image",
+        "vim"
+    ]
+}
+`, content)
+}
+
+func TestLintUnchangedJsonConfigRoundTrips(t *testing.T) {
+	configPath := "../../imageconfigs/core-legacy.json"
+	configBytes, err := os.ReadFile(configPath)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	document, err := loadLintDocument(configPath)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, configTypeImageGen, document.kind)
+
+	content, err := document.marshal()
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, string(configBytes), content)
+}
+
+func TestLintMountOptions(t *testing.T) {
+	// The order of mount options matters, so it's kept.
+	assert.Equal(t, "ro,nosuid,noexec", canonicalMountOptions(" ro, nosuid,noexec,ro"))
+	assert.Equal(t, "", canonicalMountOptions(""))
+
+	document := loadTestLintDocument(t, "config.yaml", `storage:
+  filesystems:
+  - deviceId: esp
+    mountPoint: /boot/efi
+  - deviceId: rootfs
+    mountPoint:
+      path: /
+      options: ro,defaults,ro
+`)
+	assert.Equal(t, configTypeImageCustomizer, document.kind)
+
+	results := document.lint(true)
+	if !assert.Len(t, results, 1) {
+		return
+	}
+	assert.Equal(t, ruleLintMountOptions, results[0].RuleId)
+	assert.Equal(t, "storage.filesystems[1].mountPoint.options", results[0].Path)
+
+	content, err := document.marshal()
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Contains(t, content, "options: ro,defaults\n")
+}
+
+func TestLintDeprecatedCmdline(t *testing.T) {
+	document := loadTestLintDocument(t, "config.json", `{
+    "SystemConfigs": [
+        {
+            "KernelCommandLine": {
+                "ExtraCommandLine": "console=ttyS0 fips=1 systemd.unified_cgroup_hierarchy=1"
+            }
+        }
+    ]
+}
+`)
+
+	results := document.lint(true)
+	if !assert.Len(t, results, 2) {
+		return
+	}
+	assert.Equal(t, ruleLintDeprecatedCmdline, results[0].RuleId)
+	assert.Equal(t, "SystemConfigs[0].KernelCommandLine.ExtraCommandLine", results[0].Path)
+
+	content, err := document.marshal()
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, `{
+    "SystemConfigs": [
+        {
+            "KernelCommandLine": {
+                "ExtraCommandLine": "console=ttyS0",
+                "EnableFIPS": true,
+                "CGroup": "version_two"
+            }
+        }
+    ]
+}
+`, content)
+}
+
+func TestLintDeprecatedCmdlineConflict(t *testing.T) {
+	document := loadTestLintDocument(t, "config.json", `{
+    "SystemConfigs": [
+        {
+            "KernelCommandLine": {
+                "CGroup": "version_two",
+                "ExtraCommandLine": "systemd.unified_cgroup_hierarchy=0"
+            }
+        }
+    ]
+}
+`)
+
+	results := document.lint(true)
+	if !assert.Len(t, results, 1) {
+		return
+	}
+	assert.Equal(t, SeverityError, results[0].Severity)
+	assert.Equal(t, "kernel argument (systemd.unified_cgroup_hierarchy=0) conflicts with [CGroup] (version_two)",
+		results[0].Message)
+}
+
+func TestLintUnifiedDiff(t *testing.T) {
+	diff, err := lintUnifiedDiff("packages.json", "a\nc\nb\n", "a\nb\nc\n")
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Contains(t, diff, "--- packages.json\n")
+	assert.Contains(t, diff, "+++ packages.json\n")
+	assert.Contains(t, diff, " a\n+b\n c\n-b\n")
+}
@@ -0,0 +1,383 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package main
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/imagegen/configuration"
+	"github.com/microsoft/azurelinux/toolkit/tools/imagegen/installutils"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/logger"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/network"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/pkgjson"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/timestamp"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/versioncompare"
+)
+
+const (
+	repoMetadataDir  = "repodata"
+	repoMetadataFile = "repomd.xml"
+)
+
+// availablePackages holds the package names (and the capabilities the packages provide) that are available in the
+// package repos, with their versions.
+type availablePackages struct {
+	versions map[string][]string
+	// The installed size (in bytes) of each package (its largest version), if known. For RPMs that are read from
+	// their file names, it's the (compressed) size of the RPM file.
+	installedSizes map[string]uint64
+}
+
+type repoMetadata struct {
+	Data []struct {
+		Type     string `xml:"type,attr"`
+		Location struct {
+			Href string `xml:"href,attr"`
+		} `xml:"location"`
+	} `xml:"data"`
+}
+
+type repoPrimaryVersion struct {
+	Epoch   string `xml:"epoch,attr"`
+	Version string `xml:"ver,attr"`
+	Release string `xml:"rel,attr"`
+}
+
+type repoPrimaryProvide struct {
+	Name string `xml:"name,attr"`
+	repoPrimaryVersion
+}
+
+type repoPrimaryPackage struct {
+	Name    string             `xml:"name"`
+	Arch    string             `xml:"arch"`
+	Version repoPrimaryVersion `xml:"version"`
+	Size    struct {
+		Installed uint64 `xml:"installed,attr"`
+	} `xml:"size"`
+	Provides []repoPrimaryProvide `xml:"format>provides>entry"`
+}
+
+func newAvailablePackages() *availablePackages {
+	return &availablePackages{
+		versions:       make(map[string][]string),
+		installedSizes: make(map[string]uint64),
+	}
+}
+
+func (a *availablePackages) add(name string, version string) {
+	a.versions[name] = append(a.versions[name], version)
+}
+
+func (a *availablePackages) addInstalledSize(name string, installedSize uint64) {
+	a.installedSizes[name] = max(a.installedSizes[name], installedSize)
+}
+
+// find returns whether the package is available at all, and whether it's available in a version that satisfies the
+// package's version condition.
+func (a *availablePackages) find(pkgVer *pkgjson.PackageVer) (found bool, versionFound bool, err error) {
+	versions, found := a.versions[pkgVer.Name]
+	if !found {
+		return false, false, nil
+	}
+
+	if pkgVer.Version == "" {
+		return true, true, nil
+	}
+
+	condition := pkgVer.Condition
+	if condition == "" {
+		condition = "="
+	}
+
+	requestedVersion := versioncompare.New(pkgVer.Version)
+	for _, version := range versions {
+		// An unversioned capability satisfies any version.
+		if version == "" {
+			return true, true, nil
+		}
+
+		versionFound, err = versioncompare.New(version).CompareWithConditional(condition, requestedVersion)
+		if err != nil {
+			return true, false, fmt.Errorf("invalid version condition of package (%s):\n%w", pkgVer.Name, err)
+		}
+		if versionFound {
+			return true, true, nil
+		}
+	}
+
+	return true, false, nil
+}
+
+// loadAvailablePackages reads the packages that are available in the local RPM dirs and in the remote repos.
+//
+// A local RPM dir is read from its repo metadata if it has any. Otherwise, its RPMs (recursively) are read from their
+// file names, which don't include the capabilities that the RPMs provide.
+func loadAvailablePackages(rpmDirs []string, repoUrls []string) (packages *availablePackages, err error) {
+	timestamp.StartEvent("load available packages", nil)
+	defer timestamp.StopEvent(nil)
+
+	packages = newAvailablePackages()
+
+	for _, rpmDir := range rpmDirs {
+		_, err = os.Stat(filepath.Join(rpmDir, repoMetadataDir, repoMetadataFile))
+		if err == nil {
+			err = packages.loadRepoMetadata(rpmDir)
+		} else if os.IsNotExist(err) {
+			err = packages.loadRpmFileNames(rpmDir)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the packages of RPM dir (%s):\n%w", rpmDir, err)
+		}
+	}
+
+	for _, repoUrl := range repoUrls {
+		err = packages.loadRemoteRepoMetadata(repoUrl)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the packages of repo (%s):\n%w", repoUrl, err)
+		}
+	}
+
+	return packages, nil
+}
+
+// loadRpmFileNames reads the RPMs of a dir from their '<name>-<version>-<release>.<arch>.rpm' file names.
+func (a *availablePackages) loadRpmFileNames(rpmDir string) error {
+	return filepath.WalkDir(rpmDir, func(path string, entry os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		fileName := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(fileName, ".rpm") || strings.HasSuffix(fileName, ".src.rpm") {
+			return nil
+		}
+
+		nameVersionReleaseArch := strings.TrimSuffix(fileName, ".rpm")
+		nameVersionRelease := strings.TrimSuffix(nameVersionReleaseArch, filepath.Ext(nameVersionReleaseArch))
+		releaseIndex := strings.LastIndex(nameVersionRelease, "-")
+		if releaseIndex <= 0 {
+			logger.Log.Warnf("Ignoring RPM with an unexpected file name (%s)", path)
+			return nil
+		}
+		versionIndex := strings.LastIndex(nameVersionRelease[:releaseIndex], "-")
+		if versionIndex <= 0 {
+			logger.Log.Warnf("Ignoring RPM with an unexpected file name (%s)", path)
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+
+		name := nameVersionRelease[:versionIndex]
+		a.add(name, nameVersionRelease[versionIndex+1:])
+		a.addInstalledSize(name, uint64(info.Size()))
+		return nil
+	})
+}
+
+// loadRemoteRepoMetadata downloads the repo metadata of a remote repo, and reads its packages.
+func (a *availablePackages) loadRemoteRepoMetadata(repoUrl string) (err error) {
+	repoDir, err := os.MkdirTemp("", "imageconfigvalidator-repo")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary dir:\n%w", err)
+	}
+	defer os.RemoveAll(repoDir)
+
+	repoUrl = strings.TrimSuffix(repoUrl, "/")
+	err = downloadRepoFile(repoUrl, repoDir, repoMetadataDir+"/"+repoMetadataFile)
+	if err != nil {
+		return err
+	}
+
+	primaryHref, err := readPrimaryMetadataHref(repoDir)
+	if err != nil {
+		return err
+	}
+
+	err = downloadRepoFile(repoUrl, repoDir, primaryHref)
+	if err != nil {
+		return err
+	}
+
+	return a.loadRepoMetadata(repoDir)
+}
+
+func downloadRepoFile(repoUrl string, repoDir string, relativePath string) error {
+	path := filepath.Join(repoDir, filepath.FromSlash(relativePath))
+	if !filepath.IsLocal(filepath.FromSlash(relativePath)) {
+		return fmt.Errorf("invalid repo metadata path (%s)", relativePath)
+	}
+
+	err := os.MkdirAll(filepath.Dir(path), os.ModePerm)
+	if err != nil {
+		return fmt.Errorf("failed to create dir (%s):\n%w", filepath.Dir(path), err)
+	}
+
+	_, err = network.DownloadFileWithRetry(context.Background(), network.JoinURL(repoUrl, relativePath), path, nil, nil,
+		network.DefaultTimeout)
+	if err != nil {
+		return fmt.Errorf("failed to download (%s):\n%w", relativePath, err)
+	}
+	return nil
+}
+
+func readPrimaryMetadataHref(repoDir string) (string, error) {
+	repoMetadataPath := filepath.Join(repoDir, repoMetadataDir, repoMetadataFile)
+	repoMetadataContent, err := os.ReadFile(repoMetadataPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read repo metadata (%s):\n%w", repoMetadataPath, err)
+	}
+
+	var metadata repoMetadata
+	err = xml.Unmarshal(repoMetadataContent, &metadata)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse repo metadata (%s):\n%w", repoMetadataPath, err)
+	}
+
+	for _, data := range metadata.Data {
+		if data.Type == "primary" {
+			return data.Location.Href, nil
+		}
+	}
+	return "", fmt.Errorf("repo metadata (%s) has no primary metadata", repoMetadataPath)
+}
+
+// loadRepoMetadata reads the packages, and the capabilities that they provide, from the primary metadata of a repo.
+func (a *availablePackages) loadRepoMetadata(repoDir string) error {
+	primaryHref, err := readPrimaryMetadataHref(repoDir)
+	if err != nil {
+		return err
+	}
+
+	primaryPath := filepath.Join(repoDir, filepath.FromSlash(primaryHref))
+	primaryFile, err := os.Open(primaryPath)
+	if err != nil {
+		return fmt.Errorf("failed to open primary repo metadata (%s):\n%w", primaryPath, err)
+	}
+	defer primaryFile.Close()
+
+	var primaryReader io.Reader
+	switch filepath.Ext(primaryPath) {
+	case ".gz":
+		gzipReader, err := gzip.NewReader(primaryFile)
+		if err != nil {
+			return fmt.Errorf("failed to decompress primary repo metadata (%s):\n%w", primaryPath, err)
+		}
+		defer gzipReader.Close()
+		primaryReader = gzipReader
+
+	case ".xml":
+		primaryReader = primaryFile
+
+	default:
+		return fmt.Errorf("unsupported compression of primary repo metadata (%s)", primaryPath)
+	}
+
+	// The primary metadata of a full repo is large, so read it a package at a time.
+	decoder := xml.NewDecoder(primaryReader)
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to parse primary repo metadata (%s):\n%w", primaryPath, err)
+		}
+
+		startElement, ok := token.(xml.StartElement)
+		if !ok || startElement.Name.Local != "package" {
+			continue
+		}
+
+		var pkg repoPrimaryPackage
+		err = decoder.DecodeElement(&pkg, &startElement)
+		if err != nil {
+			return fmt.Errorf("failed to parse primary repo metadata (%s):\n%w", primaryPath, err)
+		}
+
+		if pkg.Arch == "src" {
+			continue
+		}
+
+		a.add(pkg.Name, pkg.Version.versionRelease())
+		a.addInstalledSize(pkg.Name, pkg.Size.Installed)
+		for _, provide := range pkg.Provides {
+			if provide.Name != pkg.Name {
+				a.add(provide.Name, provide.versionRelease())
+			}
+		}
+	}
+
+	return nil
+}
+
+func (v repoPrimaryVersion) versionRelease() string {
+	if v.Version == "" || v.Release == "" {
+		return v.Version
+	}
+	return v.Version + "-" + v.Release
+}
+
+// validatePackageAvailability checks that the packages of each system config (including its kernel) are available,
+// in a version that satisfies the package's version condition.
+func validatePackageAvailability(config configuration.Config, packages *availablePackages) (results []ValidationResult) {
+	timestamp.StartEvent("validate package availability", nil)
+	defer timestamp.StopEvent(nil)
+
+	for i, systemConfig := range config.SystemConfigs {
+		// Package lists that can't be read are reported by validatePackages.
+		packageList, err := installutils.PackageNamesFromSingleSystemConfig(systemConfig)
+		if err != nil {
+			continue
+		}
+
+		path := systemConfigPath(i, "PackageLists")
+		for _, pkg := range packageList {
+			results = append(results, validatePackageIsAvailable(pkg, path, packages)...)
+		}
+
+		kernelPkg := systemConfig.KernelOptions["default"]
+		if kernelPkg != "" {
+			results = append(results, validatePackageIsAvailable(kernelPkg, systemConfigPath(i, "KernelOptions"),
+				packages)...)
+		}
+	}
+
+	return
+}
+
+func validatePackageIsAvailable(pkg string, path string, packages *availablePackages) []ValidationResult {
+	pkgVer, err := pkgjson.PackageStringToPackageVer(pkg)
+	if err != nil {
+		// Reported by validatePackages.
+		return nil
+	}
+
+	found, versionFound, err := packages.find(pkgVer)
+	switch {
+	case err != nil:
+		return []ValidationResult{newValidationResult(rulePackageVersionUnavailable, path, err)}
+
+	case !found:
+		return []ValidationResult{newValidationResult(rulePackageUnavailable, path,
+			fmt.Errorf("package (%s) is not available in the package repos", pkgVer.Name))}
+
+	case !versionFound:
+		return []ValidationResult{newValidationResult(rulePackageVersionUnavailable, path,
+			fmt.Errorf("no version of package (%s) that satisfies (%s) is available in the package repos "+
+				"(available: %s)", pkgVer.Name, strings.TrimSpace(pkg), strings.Join(packages.versions[pkgVer.Name], ", ")))}
+	}
+
+	return nil
+}
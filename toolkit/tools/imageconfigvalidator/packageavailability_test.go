@@ -0,0 +1,207 @@
+// Copyright Microsoft Corporation.
+// Licensed under the MIT License.
+
+package main
+
+import (
+	"compress/gzip"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/imagegen/configuration"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testRepoMetadata = `<?xml version="1.0" encoding="UTF-8"?>
+<repomd xmlns="http://linux.duke.edu/metadata/repo" xmlns:rpm="http://linux.duke.edu/metadata/rpm">
+  <data type="primary">
+    <location href="repodata/0123-primary.xml.gz"/>
+  </data>
+  <data type="filelists">
+    <location href="repodata/4567-filelists.xml.gz"/>
+  </data>
+</repomd>
+`
+
+	testRepoPrimaryMetadata = `<?xml version="1.0" encoding="UTF-8"?>
+<metadata xmlns="http://linux.duke.edu/metadata/common" xmlns:rpm="http://linux.duke.edu/metadata/rpm" packages="3">
+<package type="rpm">
+  <name>words</name>
+  <arch>noarch</arch>
+  <version epoch="0" ver="3.0" rel="40.azl3"/>
+  <size package="1725632" installed="4990000" archive="5000000"/>
+  <format>
+    <rpm:provides>
+      <rpm:entry name="words" flags="EQ" epoch="0" ver="3.0" rel="40.azl3"/>
+    </rpm:provides>
+  </format>
+</package>
+<package type="rpm">
+  <name>shadow-utils</name>
+  <arch>x86_64</arch>
+  <version epoch="2" ver="4.14.3" rel="2.azl3"/>
+  <format>
+    <rpm:provides>
+      <rpm:entry name="shadow-utils" flags="EQ" epoch="2" ver="4.14.3" rel="2.azl3"/>
+      <rpm:entry name="useradd-tools"/>
+    </rpm:provides>
+  </format>
+</package>
+<package type="rpm">
+  <name>kernel</name>
+  <arch>src</arch>
+  <version epoch="0" ver="6.6.1" rel="1.azl3"/>
+</package>
+</metadata>
+`
+)
+
+func createTestRepo(t *testing.T) string {
+	repoDir := t.TempDir()
+	err := os.MkdirAll(filepath.Join(repoDir, repoMetadataDir), os.ModePerm)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	err = os.WriteFile(filepath.Join(repoDir, repoMetadataDir, repoMetadataFile), []byte(testRepoMetadata), 0o644)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	primaryFile, err := os.Create(filepath.Join(repoDir, repoMetadataDir, "0123-primary.xml.gz"))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer primaryFile.Close()
+
+	gzipWriter := gzip.NewWriter(primaryFile)
+	_, err = gzipWriter.Write([]byte(testRepoPrimaryMetadata))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	err = gzipWriter.Close()
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	return repoDir
+}
+
+func loadTestConfig(t *testing.T) configuration.Config {
+	config, err := configuration.LoadWithAbsolutePaths(filepath.Join("./testdata/", "test-config.json"), "./testdata/")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	return config
+}
+
+func TestPackageAvailabilityFromRepoMetadata(t *testing.T) {
+	packages, err := loadAvailablePackages([]string{createTestRepo(t)}, nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, []string{"3.0-40.azl3"}, packages.versions["words"])
+	assert.Equal(t, []string{"4.14.3-2.azl3"}, packages.versions["shadow-utils"])
+	assert.Equal(t, []string{""}, packages.versions["useradd-tools"])
+	assert.Equal(t, uint64(4990000), packages.installedSizes["words"])
+	// Source packages are not installable.
+	assert.NotContains(t, packages.versions, "kernel")
+
+	config := loadTestConfig(t)
+	config.SystemConfigs[0].Packages = []string{"shadow-utils>=4.14", "useradd-tools", "words=3.0-40.azl3"}
+
+	results := validatePackageAvailability(config, packages)
+	if !assert.Len(t, results, 1) {
+		return
+	}
+	assert.Equal(t, rulePackageUnavailable, results[0].RuleId)
+	assert.Equal(t, SeverityError, results[0].Severity)
+	assert.Equal(t, "SystemConfigs[0].KernelOptions", results[0].Path)
+	assert.Equal(t, "package (kernel) is not available in the package repos", results[0].Message)
+}
+
+func TestPackageAvailabilityReportsUnsatisfiedVersions(t *testing.T) {
+	packages, err := loadAvailablePackages([]string{createTestRepo(t)}, nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	config := loadTestConfig(t)
+	config.SystemConfigs[0].KernelOptions = nil
+	config.SystemConfigs[0].Packages = []string{"shadow-utils < 4.0", "missing-package"}
+
+	results := validatePackageAvailability(config, packages)
+	if !assert.Len(t, results, 2) {
+		return
+	}
+
+	assert.Equal(t, rulePackageVersionUnavailable, results[0].RuleId)
+	assert.Equal(t, "SystemConfigs[0].PackageLists", results[0].Path)
+	assert.Equal(t, "no version of package (shadow-utils) that satisfies (shadow-utils < 4.0) is available in the "+
+		"package repos (available: 4.14.3-2.azl3)", results[0].Message)
+
+	assert.Equal(t, rulePackageUnavailable, results[1].RuleId)
+	assert.Equal(t, "package (missing-package) is not available in the package repos", results[1].Message)
+}
+
+func TestPackageAvailabilityFromRpmFileNames(t *testing.T) {
+	rpmDir := t.TempDir()
+	for _, rpmPath := range []string{
+		"x86_64/words-3.0-40.azl3.noarch.rpm",
+		"x86_64/kernel-6.6.1-1.azl3.x86_64.rpm",
+		"x86_64/kernel-6.6.2-1.azl3.x86_64.rpm",
+		"x86_64/kernel-6.6.2-1.azl3.src.rpm",
+		"x86_64/not-an-rpm.txt",
+	} {
+		err := os.MkdirAll(filepath.Dir(filepath.Join(rpmDir, rpmPath)), os.ModePerm)
+		if !assert.NoError(t, err) {
+			return
+		}
+		err = os.WriteFile(filepath.Join(rpmDir, rpmPath), nil, 0o644)
+		if !assert.NoError(t, err) {
+			return
+		}
+	}
+
+	packages, err := loadAvailablePackages([]string{rpmDir}, nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Len(t, packages.versions, 2)
+	assert.Equal(t, []string{"3.0-40.azl3"}, packages.versions["words"])
+	assert.ElementsMatch(t, []string{"6.6.1-1.azl3", "6.6.2-1.azl3"}, packages.versions["kernel"])
+
+	config := loadTestConfig(t)
+	config.SystemConfigs[0].KernelOptions["default"] = "kernel=6.6.2"
+
+	results := validatePackageAvailability(config, packages)
+	assert.Empty(t, results)
+}
+
+func TestPackageAvailabilityFromRemoteRepo(t *testing.T) {
+	server := httptest.NewServer(http.FileServer(http.Dir(createTestRepo(t))))
+	defer server.Close()
+
+	packages, err := loadAvailablePackages(nil, []string{server.URL + "/"})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, []string{"3.0-40.azl3"}, packages.versions["words"])
+	assert.Equal(t, []string{"4.14.3-2.azl3"}, packages.versions["shadow-utils"])
+}
+
+func TestPackageAvailabilityFromMissingRemoteRepo(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	_, err := loadAvailablePackages(nil, []string{server.URL})
+	assert.ErrorContains(t, err, "failed to download (repodata/repomd.xml)")
+}
@@ -0,0 +1,326 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/imagegen/configuration"
+	"github.com/microsoft/azurelinux/toolkit/tools/imagegen/installutils"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/logger"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/timestamp"
+)
+
+const (
+	auditPkgName = "audit"
+
+	// The max password age that the fedramp profile requires.
+	maxPasswordExpiresDays = 60
+)
+
+// Names of the security profiles.
+const (
+	profileBaseline = "baseline"
+	profileFips     = "fips"
+	profileFedRamp  = "fedramp"
+)
+
+// profileRule is a security property of a system config, which security profiles require. Unlike the cross check
+// rules, the profile rules aren't about whether the image builds and works, but about whether it's hardened.
+type profileRule struct {
+	id string
+	// The location of the checked setting, relative to the system config.
+	path string
+	// check returns the failure message of the rule, or "" if the system config passes the rule.
+	check func(ctx *crossCheckContext) string
+}
+
+// securityProfile is a set of profile rules. The profiles are loosely based on the CIS benchmarks and on the FIPS
+// and FedRAMP requirements. They only check the image config, so passing them doesn't make an image compliant.
+type securityProfile struct {
+	name    string
+	ruleIds []string
+}
+
+// ProfileReport is which rules of a security profile a system config passes and fails.
+type ProfileReport struct {
+	Profile      string   `json:"Profile"`
+	SystemConfig string   `json:"SystemConfig"`
+	Passed       []string `json:"Passed"`
+	Failed       []string `json:"Failed"`
+}
+
+var profileRules = []profileRule{
+	{
+		id:   ruleProfileSELinuxEnforcing,
+		path: "KernelCommandLine.SELinux",
+		check: func(ctx *crossCheckContext) string {
+			selinux := ctx.systemConfig.KernelCommandLine.SELinux
+			if selinux != configuration.SELinuxEnforcing && selinux != configuration.SELinuxForceEnforcing {
+				return fmt.Sprintf("[SELinux] is (%s), not 'enforcing'", selinuxModeName(selinux))
+			}
+			return ""
+		},
+	},
+	{
+		id:   ruleProfileAuditd,
+		path: "PackageLists",
+		check: func(ctx *crossCheckContext) string {
+			if !ctx.hasPackage(auditPkgName) {
+				return fmt.Sprintf("the '%s' package (auditd) is not included in the package lists", auditPkgName)
+			}
+			return ""
+		},
+	},
+	{
+		id:    ruleProfileSudoers,
+		path:  "AdditionalFiles",
+		check: checkSudoersFiles,
+	},
+	{
+		id:   ruleProfileNoPlainTextPasswords,
+		path: "Users",
+		check: func(ctx *crossCheckContext) string {
+			for _, user := range ctx.systemConfig.Users {
+				if !user.PasswordHashed && user.Password != "" {
+					return fmt.Sprintf("the password of user (%s) is in plain text in the config", user.Name)
+				}
+			}
+			return ""
+		},
+	},
+	{
+		id:   ruleProfileFipsEnabled,
+		path: "KernelCommandLine",
+		check: func(ctx *crossCheckContext) string {
+			if !ctx.systemConfig.KernelCommandLine.EnableFIPS && !ctx.hasCmdlineArg("fips", "1") {
+				return "FIPS mode is not enabled ([EnableFIPS])"
+			}
+			if !ctx.hasPackage(dracutFipsPkgName) {
+				return fmt.Sprintf("the '%s' package is not included in the package lists", dracutFipsPkgName)
+			}
+			return ""
+		},
+	},
+	{
+		id:   ruleProfilePasswordLoginDisabled,
+		path: "Users",
+		check: func(ctx *crossCheckContext) string {
+			for _, user := range ctx.systemConfig.Users {
+				if user.Password != "" && !isLockedPassword(user) {
+					return fmt.Sprintf("user (%s) has a password, so it can log in with a password (use SSH keys "+
+						"instead)", user.Name)
+				}
+			}
+			return ""
+		},
+	},
+	{
+		id:   ruleProfilePasswordExpiry,
+		path: "Users",
+		check: func(ctx *crossCheckContext) string {
+			for _, user := range ctx.systemConfig.Users {
+				if user.Password == "" || isLockedPassword(user) {
+					continue
+				}
+				if user.PasswordExpiresDays <= 0 || user.PasswordExpiresDays > maxPasswordExpiresDays {
+					return fmt.Sprintf("the password of user (%s) doesn't expire within %d days ([PasswordExpiresDays])",
+						user.Name, maxPasswordExpiresDays)
+				}
+			}
+			return ""
+		},
+	},
+}
+
+var securityProfiles = []securityProfile{
+	{
+		name: profileBaseline,
+		ruleIds: []string{ruleProfileSELinuxEnforcing, ruleProfileAuditd, ruleProfileSudoers,
+			ruleProfileNoPlainTextPasswords},
+	},
+	{
+		name:    profileFips,
+		ruleIds: []string{ruleProfileFipsEnabled},
+	},
+	{
+		name: profileFedRamp,
+		ruleIds: []string{ruleProfileSELinuxEnforcing, ruleProfileAuditd, ruleProfileSudoers,
+			ruleProfileNoPlainTextPasswords, ruleProfileFipsEnabled, ruleProfilePasswordLoginDisabled,
+			ruleProfilePasswordExpiry},
+	},
+}
+
+// securityProfileNames returns the names of the security profiles, for the --profile flag.
+func securityProfileNames() (names []string) {
+	for _, profile := range securityProfiles {
+		names = append(names, profile.name)
+	}
+	return
+}
+
+func selinuxModeName(selinux configuration.SELinux) string {
+	if selinux == configuration.SELinuxOff {
+		return "off"
+	}
+	return string(selinux)
+}
+
+func isLockedPassword(user configuration.User) bool {
+	return user.PasswordHashed && (strings.HasPrefix(user.Password, "!") || user.Password == "*")
+}
+
+// checkSudoersFiles checks that the sudoers files that the system config adds don't grant passwordless sudo for all
+// commands, or sudo to all users.
+func checkSudoersFiles(ctx *crossCheckContext) string {
+	// Sort the files, so that the failure is stable.
+	localPaths := make([]string, 0, len(ctx.systemConfig.AdditionalFiles))
+	for localPath := range ctx.systemConfig.AdditionalFiles {
+		localPaths = append(localPaths, localPath)
+	}
+	sort.Strings(localPaths)
+
+	for _, localPath := range localPaths {
+		isSudoersFile := false
+		for _, fileConfig := range ctx.systemConfig.AdditionalFiles[localPath] {
+			isSudoersFile = isSudoersFile || fileConfig.Path == sudoersFile || isPathUnder(fileConfig.Path, sudoersDir)
+		}
+		if !isSudoersFile {
+			continue
+		}
+
+		content, err := os.ReadFile(localPath)
+		if err != nil {
+			// Missing files are reported by validateLocalAssets.
+			continue
+		}
+
+		for _, line := range strings.Split(string(content), "\n") {
+			if isWideOpenSudoersEntry(line) {
+				return fmt.Sprintf("sudoers file (%s) has a wide open entry (%s)", localPath, strings.TrimSpace(line))
+			}
+		}
+	}
+
+	return ""
+}
+
+// isWideOpenSudoersEntry returns true if a sudoers line grants all commands without a password, or grants sudo to
+// all users.
+func isWideOpenSudoersEntry(line string) bool {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "Defaults") {
+		return false
+	}
+
+	who, spec, found := strings.Cut(line, "=")
+	if !found {
+		return false
+	}
+
+	whoFields := strings.Fields(who)
+	if len(whoFields) > 0 && whoFields[0] == "ALL" {
+		return true
+	}
+
+	spec = strings.Join(strings.Fields(spec), " ")
+	return strings.Contains(spec, "NOPASSWD: ALL") || strings.Contains(spec, "NOPASSWD:ALL")
+}
+
+// validateSecurityProfiles checks the system configs against the profile rules of the selected security profiles. The
+// failed rules are errors. Each rule is only checked (and reported) once, even if several profiles select it.
+func validateSecurityProfiles(config configuration.Config, profileNames []string,
+) (results []ValidationResult, reports []ProfileReport) {
+	timestamp.StartEvent("validate security profiles", nil)
+	defer timestamp.StopEvent(nil)
+
+	selectedProfiles := []securityProfile(nil)
+	selectedRuleIds := make(map[string]bool)
+	for _, profile := range securityProfiles {
+		for _, profileName := range profileNames {
+			if profile.name != profileName {
+				continue
+			}
+
+			selectedProfiles = append(selectedProfiles, profile)
+			for _, ruleId := range profile.ruleIds {
+				selectedRuleIds[ruleId] = true
+			}
+		}
+	}
+
+	for i, systemConfig := range config.SystemConfigs {
+		packageList, err := installutils.PackageNamesFromSingleSystemConfig(systemConfig)
+		if err != nil {
+			// Package lists that can't be read are reported by validatePackages.
+			continue
+		}
+		ctx, err := newCrossCheckContext(systemConfig, packageList)
+		if err != nil {
+			continue
+		}
+
+		failedRuleIds := make(map[string]bool)
+		for _, rule := range profileRules {
+			if !selectedRuleIds[rule.id] {
+				continue
+			}
+
+			message := rule.check(ctx)
+			if message == "" {
+				continue
+			}
+
+			failedRuleIds[rule.id] = true
+			results = append(results, newValidationResult(rule.id, systemConfigPath(i, rule.path),
+				fmt.Errorf("%s: %s", profilesRequiringRule(selectedProfiles, rule.id), message)))
+		}
+
+		for _, profile := range selectedProfiles {
+			report := ProfileReport{
+				Profile:      profile.name,
+				SystemConfig: systemConfig.Name,
+				Passed:       []string{},
+				Failed:       []string{},
+			}
+			for _, ruleId := range profile.ruleIds {
+				if failedRuleIds[ruleId] {
+					report.Failed = append(report.Failed, ruleId)
+				} else {
+					report.Passed = append(report.Passed, ruleId)
+				}
+			}
+			reports = append(reports, report)
+		}
+	}
+
+	return
+}
+
+// profilesRequiringRule returns a description of the profiles that select a rule, for the rule's failure message.
+func profilesRequiringRule(profiles []securityProfile, ruleId string) string {
+	names := []string(nil)
+	for _, profile := range profiles {
+		for _, profileRuleId := range profile.ruleIds {
+			if profileRuleId == ruleId {
+				names = append(names, profile.name)
+			}
+		}
+	}
+	return fmt.Sprintf("required by security profile (%s)", strings.Join(names, ", "))
+}
+
+func logProfileReports(reports []ProfileReport) {
+	for _, report := range reports {
+		if len(report.Failed) == 0 {
+			logger.Log.Infof("System config (%s) passes security profile (%s)", report.SystemConfig, report.Profile)
+		} else {
+			logger.Log.Infof("System config (%s) fails security profile (%s): failed rules (%s), passed rules (%s)",
+				report.SystemConfig, report.Profile, strings.Join(report.Failed, ", "), strings.Join(report.Passed, ", "))
+		}
+	}
+}
@@ -0,0 +1,130 @@
+// Copyright Microsoft Corporation.
+// Licensed under the MIT License.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/imagegen/configuration"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSecurityProfiles(t *testing.T) {
+	testDir := t.TempDir()
+	packageListPath := filepath.Join(testDir, "packages.json")
+	err := os.WriteFile(packageListPath, []byte(`{"packages": ["audit", "dracut-fips"]}`), 0o644)
+	if !assert.NoError(t, err) {
+		return
+	}
+	sudoersPath := filepath.Join(testDir, "sudoers")
+	err = os.WriteFile(sudoersPath, []byte("# comment\nDefaults env_reset\nadmin ALL=(ALL) NOPASSWD: ALL\n"), 0o644)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	config := configuration.Config{SystemConfigs: []configuration.SystemConfig{{
+		Name:         "test",
+		PackageLists: []string{packageListPath},
+		AdditionalFiles: map[string]configuration.FileConfigList{
+			sudoersPath: {{Path: "/etc/sudoers.d/admin"}},
+		},
+		KernelCommandLine: configuration.KernelCommandLine{
+			SELinux:    configuration.SELinuxEnforcing,
+			EnableFIPS: true,
+		},
+		Users: []configuration.User{
+			{Name: "root", PasswordHashed: true, Password: testSha512Hash, PasswordExpiresDays: 30},
+			{Name: "test", PasswordHashed: true, Password: "!" + testSha512Hash},
+		},
+	}}}
+
+	results, reports := validateSecurityProfiles(config, []string{profileBaseline, profileFedRamp})
+
+	// The sudoers rule is selected by both profiles, but is only reported once.
+	if !assert.Len(t, results, 2) {
+		return
+	}
+	assert.Equal(t, ruleProfileSudoers, results[0].RuleId)
+	assert.Equal(t, SeverityError, results[0].Severity)
+	assert.Equal(t, "SystemConfigs[0].AdditionalFiles", results[0].Path)
+	assert.Equal(t, "required by security profile (baseline, fedramp): sudoers file ("+sudoersPath+") has a wide "+
+		"open entry (admin ALL=(ALL) NOPASSWD: ALL)", results[0].Message)
+	assert.Equal(t, ruleProfilePasswordLoginDisabled, results[1].RuleId)
+	assert.Equal(t, "required by security profile (fedramp): user (root) has a password, so it can log in with a "+
+		"password (use SSH keys instead)", results[1].Message)
+
+	assert.Equal(t, []ProfileReport{
+		{
+			Profile:      profileBaseline,
+			SystemConfig: "test",
+			Passed:       []string{ruleProfileSELinuxEnforcing, ruleProfileAuditd, ruleProfileNoPlainTextPasswords},
+			Failed:       []string{ruleProfileSudoers},
+		},
+		{
+			Profile:      profileFedRamp,
+			SystemConfig: "test",
+			Passed: []string{ruleProfileSELinuxEnforcing, ruleProfileAuditd, ruleProfileNoPlainTextPasswords,
+				ruleProfileFipsEnabled, ruleProfilePasswordExpiry},
+			Failed: []string{ruleProfileSudoers, ruleProfilePasswordLoginDisabled},
+		},
+	}, reports)
+}
+
+func TestSecurityProfilesMinimalConfig(t *testing.T) {
+	packageListPath := filepath.Join(t.TempDir(), "packages.json")
+	err := os.WriteFile(packageListPath, []byte(`{"packages": ["core-packages-base-image"]}`), 0o644)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	config := configuration.Config{SystemConfigs: []configuration.SystemConfig{{
+		Name:         "test",
+		PackageLists: []string{packageListPath},
+		Users:        []configuration.User{{Name: "root", Password: "hunter2"}},
+	}}}
+
+	results, reports := validateSecurityProfiles(config, []string{profileFips, profileBaseline})
+
+	ruleIds := []string(nil)
+	for _, result := range results {
+		ruleIds = append(ruleIds, result.RuleId)
+	}
+	assert.Equal(t, []string{ruleProfileSELinuxEnforcing, ruleProfileAuditd, ruleProfileNoPlainTextPasswords,
+		ruleProfileFipsEnabled}, ruleIds)
+	assert.Equal(t, "required by security profile (baseline): [SELinux] is (off), not 'enforcing'", results[0].Message)
+
+	// The reports are in the order of the profiles, not in the order that they were selected in.
+	if !assert.Len(t, reports, 2) {
+		return
+	}
+	assert.Equal(t, profileBaseline, reports[0].Profile)
+	assert.Equal(t, []string{ruleProfileSudoers}, reports[0].Passed)
+	assert.Equal(t, profileFips, reports[1].Profile)
+	assert.Equal(t, []string{}, reports[1].Passed)
+	assert.Equal(t, []string{ruleProfileFipsEnabled}, reports[1].Failed)
+}
+
+func TestIsWideOpenSudoersEntry(t *testing.T) {
+	tests := []struct {
+		line     string
+		wideOpen bool
+	}{
+		{line: "root ALL=(ALL) ALL"},
+		{line: "%wheel ALL=(ALL) ALL"},
+		{line: "%wheel ALL=(ALL)   NOPASSWD:  ALL", wideOpen: true},
+		{line: "admin ALL=(ALL) NOPASSWD:ALL", wideOpen: true},
+		{line: "admin ALL=(ALL) NOPASSWD: /usr/bin/systemctl"},
+		{line: "ALL ALL=(ALL) ALL", wideOpen: true},
+		{line: "# %wheel ALL=(ALL) NOPASSWD: ALL"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.line, func(t *testing.T) {
+			assert.Equal(t, test.wideOpen, isWideOpenSudoersEntry(test.line))
+		})
+	}
+}
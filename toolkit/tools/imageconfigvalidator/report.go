@@ -0,0 +1,210 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/jsonutils"
+)
+
+// Exit codes of the validator. These are stable, so that CI gates can rely on them.
+const (
+	exitCodeValid = 0
+	// The config was loaded, but failed one or more validation rules.
+	exitCodeInvalid = 1
+	// The config could not be read or parsed.
+	exitCodeLoadFailure = 2
+	// The validator itself failed (e.g. the report could not be written).
+	exitCodeInternalError = 3
+)
+
+// Rule ids of the validation results. These are stable, so that CI gates can rely on them.
+const (
+	ruleConfigLoad                = "config-load"
+	ruleConfigInvalid             = "config-invalid"
+	rulePackageList               = "package-list"
+	rulePackagesKernel            = "packages-kernel"
+	rulePackagesFips              = "packages-fips"
+	rulePackagesSELinux           = "packages-selinux"
+	rulePackagesUserAdd           = "packages-useradd"
+	ruleKickStartPartitions       = "kickstart-partitions"
+	rulePackagesSshServer         = "packages-openssh-server"
+	ruleBootPartitionSize         = "boot-partition-size"
+	rulePackageUnavailable        = "package-unavailable"
+	rulePackageVersionUnavailable = "package-version-unavailable"
+	ruleDependencyConflict        = "dependency-conflict"
+	ruleDependencyMissing         = "dependency-missing"
+	ruleDependencyResolution      = "dependency-resolution"
+	rulePackageObsoleted          = "package-obsoleted"
+	ruleDiskPartitionRange        = "disk-partition-range"
+	ruleDiskPartitionOverlap      = "disk-partition-overlap"
+	ruleDiskSize                  = "disk-size"
+	ruleEspSize                   = "esp-size"
+	ruleInstalledSize             = "installed-size"
+	rulePackagesVerity            = "packages-veritysetup"
+	rulePackagesIscsi             = "packages-iscsi"
+	ruleSELinuxCmdlinePackage     = "selinux-cmdline-policy"
+	ruleSELinuxCmdlineMode        = "selinux-cmdline-mode"
+	rulePackagesNetworkd          = "packages-networkd"
+	rulePackagesNetworkManager    = "packages-networkmanager"
+	ruleFileSource                = "file-source"
+	ruleScriptPath                = "script-path"
+	ruleLintPackagesDuplicate     = "lint-packages-duplicate"
+	ruleLintPackagesUnsorted      = "lint-packages-unsorted"
+	ruleLintMountOptions          = "lint-mount-options"
+	ruleLintDeprecatedCmdline     = "lint-deprecated-cmdline"
+	rulePackagesSudo              = "packages-sudo"
+	ruleUserPasswordHash          = "user-password-hash"
+	ruleUserPasswordWeakHash      = "user-password-weak-hash"
+	ruleUserPasswordPlainText     = "user-password-plaintext"
+	ruleUserIdRange               = "user-id-range"
+	ruleUserIdDuplicate           = "user-id-duplicate"
+	ruleUserSshKey                = "user-ssh-key"
+	ruleUserHomeDirectory         = "user-home-directory"
+	rulePackagesTpm2              = "packages-tpm2"
+	rulePackagesNftables          = "packages-nftables"
+	rulePackagesContainerRuntime  = "packages-container-runtime"
+	rulePackagesKernelDevel       = "packages-kernel-devel"
+	rulePackagesLocales           = "packages-locales"
+	rulePackagesTzdata            = "packages-tzdata"
+	rulePackagesKbd               = "packages-kbd"
+	rulePackagesCaCertificates    = "packages-ca-certificates"
+	rulePackagesSshHostKeys       = "packages-ssh-host-keys"
+	rulePackagesRemoved           = "packages-removed"
+	ruleKickstartUnsupported      = "kickstart-unsupported"
+)
+
+// Rule ids of the security profile rules (see profiles.go).
+const (
+	ruleProfileSELinuxEnforcing      = "profile-selinux-enforcing"
+	ruleProfileAuditd                = "profile-auditd"
+	ruleProfileSudoers               = "profile-sudoers"
+	ruleProfileNoPlainTextPasswords  = "profile-no-plaintext-passwords"
+	ruleProfileFipsEnabled           = "profile-fips"
+	ruleProfilePasswordLoginDisabled = "profile-password-login-disabled"
+	ruleProfilePasswordExpiry        = "profile-password-expiry"
+)
+
+// ValidationSeverity is the severity of a validation result.
+type ValidationSeverity string
+
+const (
+	// SeverityNone is only used as a --max-severity value, to fail on any result.
+	SeverityNone ValidationSeverity = "none"
+	// A risky, but not invalid, setting.
+	SeverityWarning ValidationSeverity = "warning"
+	// An invalid setting, which would fail (or break) the image build.
+	SeverityError ValidationSeverity = "error"
+)
+
+var severityLevels = map[ValidationSeverity]int{
+	SeverityNone:    0,
+	SeverityWarning: 1,
+	SeverityError:   2,
+}
+
+// Exceeds returns true if the severity is higher than the max severity.
+func (s ValidationSeverity) Exceeds(maxSeverity ValidationSeverity) bool {
+	return severityLevels[s] > severityLevels[maxSeverity]
+}
+
+// ValidationResult is a single failed validation rule (an error or a warning).
+type ValidationResult struct {
+	RuleId   string             `json:"RuleId"`
+	Severity ValidationSeverity `json:"Severity"`
+	// The location of the offending setting in the config (e.g. 'SystemConfigs[0].PackageLists'). Empty if the
+	// result applies to the config as a whole.
+	Path    string `json:"Path"`
+	Message string `json:"Message"`
+
+	err error
+}
+
+// ValidationReport is the machine-readable output of the validator.
+type ValidationReport struct {
+	Config   string             `json:"Config"`
+	Valid    bool               `json:"Valid"`
+	ExitCode int                `json:"ExitCode"`
+	Results  []ValidationResult `json:"Results"`
+	// The transactions that tdnf would run to install the packages of each system config. Only set in the deep
+	// dependency resolution mode.
+	Transactions []InstallTransaction `json:"Transactions,omitempty"`
+	// Which rules of each selected security profile each system config passes and fails. Only set if security
+	// profiles are selected.
+	Profiles []ProfileReport `json:"Profiles,omitempty"`
+}
+
+func newValidationResult(ruleId string, path string, err error) ValidationResult {
+	return ValidationResult{
+		RuleId:   ruleId,
+		Severity: SeverityError,
+		Path:     path,
+		Message:  err.Error(),
+		err:      err,
+	}
+}
+
+func newValidationWarning(ruleId string, path string, message string) ValidationResult {
+	return ValidationResult{
+		RuleId:   ruleId,
+		Severity: SeverityWarning,
+		Path:     path,
+		Message:  message,
+		err:      fmt.Errorf("%s", message),
+	}
+}
+
+// validationExitCode returns the exit code for the results: invalid if any result is more severe than the max
+// severity.
+func validationExitCode(results []ValidationResult, maxSeverity ValidationSeverity) int {
+	for _, result := range results {
+		if result.Severity.Exceeds(maxSeverity) {
+			return exitCodeInvalid
+		}
+	}
+	return exitCodeValid
+}
+
+func systemConfigPath(index int, field string) string {
+	return fmt.Sprintf("SystemConfigs[%d].%s", index, field)
+}
+
+// newValidationReport builds the report of a config from its validation results.
+func newValidationReport(configPath string, results []ValidationResult, exitCode int) ValidationReport {
+	if results == nil {
+		results = []ValidationResult{}
+	}
+
+	return ValidationReport{
+		Config:   configPath,
+		Valid:    exitCode == exitCodeValid,
+		ExitCode: exitCode,
+		Results:  results,
+	}
+}
+
+// writeValidationReport writes the report as JSON to a file, or to stdout if the path is '-'.
+func writeValidationReport(report ValidationReport, outputPath string) error {
+	if outputPath == "-" {
+		outputBytes, err := json.MarshalIndent(report, "", " ")
+		if err != nil {
+			return fmt.Errorf("failed to serialize the validation report:\n%w", err)
+		}
+
+		_, err = fmt.Fprintln(os.Stdout, string(outputBytes))
+		if err != nil {
+			return fmt.Errorf("failed to write the validation report to stdout:\n%w", err)
+		}
+		return nil
+	}
+
+	err := jsonutils.WriteJSONFile(outputPath, report)
+	if err != nil {
+		return fmt.Errorf("failed to write the validation report (%s):\n%w", outputPath, err)
+	}
+	return nil
+}
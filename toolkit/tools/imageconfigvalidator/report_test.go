@@ -0,0 +1,154 @@
+// Copyright Microsoft Corporation.
+// Licensed under the MIT License.
+
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/imagegen/configuration"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/jsonutils"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidationResultsReportAllFailures(t *testing.T) {
+	config, err := configuration.LoadWithAbsolutePaths(filepath.Join("./testdata/", "test-config.json"), "./testdata/")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	config.SystemConfigs[0].KernelCommandLine.EnableFIPS = true
+	config.SystemConfigs[0].KernelCommandLine.SELinux = "enforcing"
+
+	results := ValidateConfigurationResults(config)
+	if !assert.Len(t, results, 2) {
+		return
+	}
+
+	assert.Equal(t, rulePackagesFips, results[0].RuleId)
+	assert.Equal(t, SeverityError, results[0].Severity)
+	assert.Equal(t, "SystemConfigs[0].KernelCommandLine", results[0].Path)
+
+	assert.Equal(t, rulePackagesSELinux, results[1].RuleId)
+	assert.Equal(t, "SystemConfigs[0].KernelCommandLine.SELinux", results[1].Path)
+
+	// ValidateConfiguration reports the first failure.
+	err = ValidateConfiguration(config)
+	assert.EqualError(t, err, results[0].Message)
+}
+
+func TestValidationResultsStopAtInvalidConfig(t *testing.T) {
+	config := configuration.Config{}
+	config.SystemConfigs = []configuration.SystemConfig{{}}
+
+	results := ValidateConfigurationResults(config)
+	if !assert.Len(t, results, 1) {
+		return
+	}
+	assert.Equal(t, ruleConfigInvalid, results[0].RuleId)
+	assert.Equal(t, "", results[0].Path)
+	assert.Equal(t, "invalid [SystemConfigs]:\nmissing [Name] field", results[0].Message)
+}
+
+func TestWriteValidationReport(t *testing.T) {
+	config, err := configuration.LoadWithAbsolutePaths(filepath.Join("./testdata/", "test-config.json"), "./testdata/")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	config.SystemConfigs[0].KernelCommandLine.SELinux = "enforcing"
+
+	reportPath := filepath.Join(t.TempDir(), "report.json")
+	report := newValidationReport("test-config.json", ValidateConfigurationResults(config), exitCodeInvalid)
+	err = writeValidationReport(report, reportPath)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	var readReport ValidationReport
+	err = jsonutils.ReadJSONFile(reportPath, &readReport)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, "test-config.json", readReport.Config)
+	assert.False(t, readReport.Valid)
+	assert.Equal(t, exitCodeInvalid, readReport.ExitCode)
+	if assert.Len(t, readReport.Results, 1) {
+		assert.Equal(t, rulePackagesSELinux, readReport.Results[0].RuleId)
+		assert.Equal(t, SeverityError, readReport.Results[0].Severity)
+		assert.Equal(t, "SystemConfigs[0].KernelCommandLine.SELinux", readReport.Results[0].Path)
+		assert.Equal(t, "failed to validate package lists in config: [SELinux] selected, but 'selinux-policy' package "+
+			"is not included in the package lists", readReport.Results[0].Message)
+	}
+}
+
+func TestValidationReportOfValidConfigHasEmptyResults(t *testing.T) {
+	report := newValidationReport("test-config.json", nil, exitCodeValid)
+	assert.True(t, report.Valid)
+	assert.NotNil(t, report.Results)
+	assert.Empty(t, report.Results)
+}
+
+func TestWarningsDoNotFailValidationByDefault(t *testing.T) {
+	config, err := configuration.LoadWithAbsolutePaths(filepath.Join("./testdata/", "test-config.json"), "./testdata/")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// A small /boot partition.
+	config.Disks[0].Partitions = append(config.Disks[0].Partitions[:1],
+		configuration.Partition{ID: "bootpart", Start: 9, End: 109, FsType: "ext4"},
+		configuration.Partition{ID: "rootfs", Start: 109, End: 0, FsType: "ext4"})
+	config.SystemConfigs[0].PartitionSettings = append(config.SystemConfigs[0].PartitionSettings,
+		configuration.PartitionSetting{ID: "bootpart", MountPoint: "/boot"})
+
+	// A user with an SSH key, but no SSH server.
+	shadowUtilsListPath, err := filepath.Abs("./testdata/shadowutils-list.json")
+	if !assert.NoError(t, err) {
+		return
+	}
+	config.SystemConfigs[0].PackageLists = append(config.SystemConfigs[0].PackageLists, shadowUtilsListPath)
+	config.SystemConfigs[0].Users = []configuration.User{
+		{
+			Name:       "testuser",
+			SSHPubKeys: []string{"ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIAABAgMEBQYHCAkKCwwNDg8QERITFBUWFxgZGhscHR4f testuser"},
+		},
+	}
+
+	results := ValidateConfigurationResults(config)
+	if !assert.Len(t, results, 2) {
+		return
+	}
+
+	assert.Equal(t, rulePackagesSshServer, results[0].RuleId)
+	assert.Equal(t, SeverityWarning, results[0].Severity)
+	assert.Equal(t, "SystemConfigs[0].Users", results[0].Path)
+
+	assert.Equal(t, ruleBootPartitionSize, results[1].RuleId)
+	assert.Equal(t, SeverityWarning, results[1].Severity)
+	assert.Equal(t, "Disks[0].Partitions[1]", results[1].Path)
+
+	// Warnings are not errors.
+	err = ValidateConfiguration(config)
+	assert.NoError(t, err)
+
+	assert.Equal(t, exitCodeValid, validationExitCode(results, SeverityWarning))
+	assert.Equal(t, exitCodeValid, validationExitCode(results, SeverityError))
+	assert.Equal(t, exitCodeInvalid, validationExitCode(results, SeverityNone))
+}
+
+func TestValidationExitCodeOfErrors(t *testing.T) {
+	results := []ValidationResult{
+		newValidationWarning(ruleBootPartitionSize, "", "warning"),
+		newValidationResult(rulePackagesKernel, "", fmt.Errorf("error")),
+	}
+
+	assert.Equal(t, exitCodeInvalid, validationExitCode(results, SeverityNone))
+	assert.Equal(t, exitCodeInvalid, validationExitCode(results, SeverityWarning))
+	assert.Equal(t, exitCodeValid, validationExitCode(results, SeverityError))
+	assert.Equal(t, exitCodeValid, validationExitCode(nil, SeverityNone))
+}
@@ -0,0 +1,268 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"reflect"
+	"strings"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/imagecustomizerapi"
+	"github.com/microsoft/azurelinux/toolkit/tools/imagegen/configuration"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/file"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	jsonSchemaDialect = "https://json-schema.org/draft/2020-12/schema"
+
+	schemaFormatJson = "json"
+	schemaFormatYaml = "yaml"
+)
+
+// schemaGenerator generates the JSON Schema of a config format from its Go types, so that the schema can't drift from
+// the types.
+type schemaGenerator struct {
+	// The struct tag that names the fields (i.e. 'json' or 'yaml').
+	tagName string
+	// Whether unknown fields are errors.
+	strict      bool
+	unmarshaler reflect.Type
+
+	defs     map[string]any
+	defTypes map[string]reflect.Type
+	// The types that have custom unmarshalers, but no schema override. Their schema may be wrong.
+	unhandledTypes []reflect.Type
+}
+
+// configSchema returns the JSON Schema of a config format.
+func configSchema(configType string) (schema map[string]any, unhandledTypes []reflect.Type, err error) {
+	var (
+		g        *schemaGenerator
+		rootType reflect.Type
+		title    string
+	)
+
+	switch configType {
+	case configTypeImageGen:
+		g = newSchemaGenerator("json", false, reflect.TypeOf((*json.Unmarshaler)(nil)).Elem())
+		rootType = reflect.TypeOf(configuration.Config{})
+		title = "Azure Linux image config"
+
+	case configTypeImageCustomizer:
+		// imagecustomizer rejects unknown fields.
+		g = newSchemaGenerator("yaml", true, reflect.TypeOf((*yaml.Unmarshaler)(nil)).Elem())
+		rootType = reflect.TypeOf(imagecustomizerapi.Config{})
+		title = "Azure Linux image customizer config"
+
+	default:
+		return nil, nil, fmt.Errorf("unsupported config type (%s)", configType)
+	}
+
+	schema = g.typeSchema(rootType)
+	schema["$schema"] = jsonSchemaDialect
+	schema["title"] = title
+	schema["$defs"] = g.defs
+	return schema, g.unhandledTypes, nil
+}
+
+func newSchemaGenerator(tagName string, strict bool, unmarshaler reflect.Type) *schemaGenerator {
+	return &schemaGenerator{
+		tagName:     tagName,
+		strict:      strict,
+		unmarshaler: unmarshaler,
+		defs:        make(map[string]any),
+		defTypes:    make(map[string]reflect.Type),
+	}
+}
+
+func (g *schemaGenerator) typeSchema(t reflect.Type) map[string]any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	schema, hasOverride := g.overrideSchema(t)
+	if hasOverride {
+		return schema
+	}
+
+	hasUnmarshaler := reflect.PointerTo(t).Implements(g.unmarshaler)
+	if hasUnmarshaler && t.Kind() != reflect.Struct && t.Kind() != reflect.String {
+		g.unhandledTypes = append(g.unhandledTypes, t)
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		return g.structRef(t)
+
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": g.typeSchema(t.Elem())}
+
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": g.typeSchema(t.Elem())}
+
+	case reflect.String:
+		return map[string]any{"type": "string"}
+
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return map[string]any{"type": "integer"}
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer", "minimum": 0}
+
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+
+	default:
+		// Any value.
+		return map[string]any{}
+	}
+}
+
+// overrideSchema returns the schema of the types whose JSON/YAML form differs from their Go type (i.e. that have
+// custom unmarshalers).
+func (g *schemaGenerator) overrideSchema(t reflect.Type) (schema map[string]any, found bool) {
+	switch t {
+	case reflect.TypeOf(configuration.FilePermissions(0)):
+		return map[string]any{"type": "string", "pattern": `^[0-7]{1,4}$`}, true
+
+	case reflect.TypeOf(configuration.FileConfig{}):
+		// A file config is either its path or the full struct.
+		return map[string]any{"anyOf": []any{
+			map[string]any{"type": "string"},
+			g.structRef(t),
+		}}, true
+
+	case reflect.TypeOf(configuration.FileConfigList(nil)):
+		fileConfigSchema := g.typeSchema(reflect.TypeOf(configuration.FileConfig{}))
+		return map[string]any{"anyOf": []any{
+			fileConfigSchema,
+			map[string]any{"type": "array", "items": fileConfigSchema},
+		}}, true
+
+	case reflect.TypeOf(imagecustomizerapi.FilePermissions(0)):
+		return map[string]any{"type": []string{"string", "integer"}, "pattern": `^[0-7]{1,4}$`}, true
+
+	case reflect.TypeOf(imagecustomizerapi.DiskSize(0)):
+		return map[string]any{"type": []string{"string", "integer"}, "pattern": `^\d+[KMGT]?$`}, true
+
+	case reflect.TypeOf(imagecustomizerapi.PartitionSize{}):
+		return map[string]any{"type": []string{"string", "integer"}, "pattern": `^(grow|\d+[KMGT]?)$`}, true
+
+	case reflect.TypeOf(imagecustomizerapi.MountPoint{}):
+		// A mount point is either its path or the full struct.
+		return map[string]any{"anyOf": []any{
+			map[string]any{"type": "string"},
+			g.structRef(t),
+		}}, true
+	}
+
+	return nil, false
+}
+
+// structRef returns a reference to the schema of a struct, adding the schema to the definitions the first time that
+// the struct is referenced.
+func (g *schemaGenerator) structRef(t reflect.Type) map[string]any {
+	name := t.Name()
+	if existingType, found := g.defTypes[name]; found && existingType != t {
+		// Two packages have a type with the same name.
+		name = path.Base(t.PkgPath()) + "." + name
+	}
+
+	ref := map[string]any{"$ref": "#/$defs/" + name}
+	if _, found := g.defTypes[name]; found {
+		return ref
+	}
+
+	// Add the type before generating its schema, so that recursive types refer to it.
+	g.defTypes[name] = t
+	g.defs[name] = nil
+
+	properties := make(map[string]any)
+	g.addStructProperties(t, properties)
+
+	schema := map[string]any{
+		"type":       "object",
+		"properties": properties,
+	}
+	if g.strict {
+		schema["additionalProperties"] = false
+	}
+
+	g.defs[name] = schema
+	return ref
+}
+
+func (g *schemaGenerator) addStructProperties(t reflect.Type, properties map[string]any) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		tag := field.Tag.Get(g.tagName)
+		name, options, _ := strings.Cut(tag, ",")
+		if name == "-" {
+			continue
+		}
+
+		// Embedded structs (for 'json') and inlined structs (for 'yaml') add their fields to the struct.
+		isInline := (g.tagName == "json" && field.Anonymous && tag == "") || strings.Contains(options, "inline")
+		if isInline && field.Type.Kind() == reflect.Struct {
+			g.addStructProperties(field.Type, properties)
+			continue
+		}
+
+		switch {
+		case tag == "":
+			// The config types tag all of their fields. So, untagged fields are computed values, which are not in the
+			// config files.
+			continue
+
+		case name == "" && g.tagName == "yaml":
+			name = strings.ToLower(field.Name)
+
+		case name == "":
+			name = field.Name
+		}
+
+		properties[name] = g.typeSchema(field.Type)
+	}
+}
+
+// writeSchema writes a schema as JSON or as YAML to the output path (or to stdout, for "-" or "").
+func writeSchema(schema map[string]any, format string, outputPath string) (err error) {
+	var schemaBytes []byte
+	switch format {
+	case schemaFormatYaml:
+		schemaBytes, err = yaml.Marshal(schema)
+	default:
+		schemaBytes, err = json.MarshalIndent(schema, "", "  ")
+		schemaBytes = append(schemaBytes, '\n')
+	}
+	if err != nil {
+		return fmt.Errorf("failed to marshal schema:\n%w", err)
+	}
+
+	if outputPath == "" || outputPath == "-" {
+		_, err = os.Stdout.Write(schemaBytes)
+		if err != nil {
+			return fmt.Errorf("failed to write schema to stdout:\n%w", err)
+		}
+		return nil
+	}
+
+	err = file.Write(string(schemaBytes), outputPath)
+	if err != nil {
+		return fmt.Errorf("failed to write schema to (%s):\n%w", outputPath, err)
+	}
+	return nil
+}
@@ -0,0 +1,197 @@
+// Copyright Microsoft Corporation.
+// Licensed under the MIT License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
+)
+
+// checkSchema checks a config value against the subset of JSON Schema that configSchema generates, and returns the
+// paths of the values that don't match.
+func checkSchema(schema map[string]any, defs map[string]any, value any, path string) (mismatches []string) {
+	if value == nil {
+		// A null value leaves the field unset.
+		return nil
+	}
+
+	if ref, hasRef := schema["$ref"].(string); hasRef {
+		return checkSchema(defs[strings.TrimPrefix(ref, "#/$defs/")].(map[string]any), defs, value, path)
+	}
+
+	if anyOf, hasAnyOf := schema["anyOf"].([]any); hasAnyOf {
+		for _, subschema := range anyOf {
+			if len(checkSchema(subschema.(map[string]any), defs, value, path)) == 0 {
+				return nil
+			}
+		}
+		return []string{path}
+	}
+
+	var types []string
+	switch schemaType := schema["type"].(type) {
+	case string:
+		types = []string{schemaType}
+	case []string:
+		types = schemaType
+	}
+
+	valueType := ""
+	switch value.(type) {
+	case map[string]any:
+		valueType = "object"
+	case []any:
+		valueType = "array"
+	case string:
+		valueType = "string"
+	case bool:
+		valueType = "boolean"
+	case int, float64:
+		valueType = "number"
+	}
+
+	typeMatches := len(types) == 0
+	for _, schemaType := range types {
+		typeMatches = typeMatches || schemaType == valueType ||
+			(valueType == "number" && (schemaType == "integer" || schemaType == "number"))
+	}
+	if !typeMatches {
+		return []string{fmt.Sprintf("%s (%s, expected %v)", path, valueType, types)}
+	}
+
+	switch typedValue := value.(type) {
+	case map[string]any:
+		properties, _ := schema["properties"].(map[string]any)
+		for key, propertyValue := range typedValue {
+			propertySchema, found := properties[key].(map[string]any)
+			if !found {
+				additionalProperties, hasAdditionalProperties := schema["additionalProperties"].(map[string]any)
+				if !hasAdditionalProperties {
+					mismatches = append(mismatches, fmt.Sprintf("%s.%s (unknown field)", path, key))
+					continue
+				}
+				propertySchema = additionalProperties
+			}
+			mismatches = append(mismatches, checkSchema(propertySchema, defs, propertyValue, path+"."+key)...)
+		}
+
+	case []any:
+		for i, item := range typedValue {
+			mismatches = append(mismatches, checkSchema(schema["items"].(map[string]any), defs, item,
+				fmt.Sprintf("%s[%d]", path, i))...)
+		}
+
+	case string:
+		pattern, hasPattern := schema["pattern"].(string)
+		if hasPattern && !regexp.MustCompile(pattern).MatchString(typedValue) {
+			mismatches = append(mismatches, fmt.Sprintf("%s (%s doesn't match %s)", path, typedValue, pattern))
+		}
+	}
+
+	return mismatches
+}
+
+func TestImageGenSchemaMatchesConfigs(t *testing.T) {
+	schema, unhandledTypes, err := configSchema(configTypeImageGen)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Empty(t, unhandledTypes)
+	assert.Equal(t, jsonSchemaDialect, schema["$schema"])
+
+	configPaths, err := filepath.Glob("../../imageconfigs/*.json")
+	if !assert.NoError(t, err) || !assert.NotEmpty(t, configPaths) {
+		return
+	}
+
+	for _, configPath := range configPaths {
+		configBytes, err := os.ReadFile(configPath)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		var config any
+		err = json.Unmarshal(configBytes, &config)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		mismatches := checkSchema(schema, schema["$defs"].(map[string]any), config, "")
+		assert.Empty(t, mismatches, configPath)
+	}
+}
+
+func TestImageCustomizerSchemaMatchesConfigs(t *testing.T) {
+	schema, unhandledTypes, err := configSchema(configTypeImageCustomizer)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Empty(t, unhandledTypes)
+
+	configPaths, err := filepath.Glob("../pkg/imagecustomizerlib/testdata/*.yaml")
+	if !assert.NoError(t, err) || !assert.NotEmpty(t, configPaths) {
+		return
+	}
+	configPaths = append(configPaths, filepath.Join(testCustomizerConfigDir, "config.yaml"))
+
+	for _, configPath := range configPaths {
+		configBytes, err := os.ReadFile(configPath)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		var config any
+		err = yaml.Unmarshal(configBytes, &config)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		mismatches := checkSchema(schema, schema["$defs"].(map[string]any), config, "")
+		assert.Empty(t, mismatches, configPath)
+	}
+}
+
+func TestImageCustomizerSchemaRejectsUnknownFields(t *testing.T) {
+	schema, _, err := configSchema(configTypeImageCustomizer)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	config := map[string]any{"os": map[string]any{"notAField": true}}
+	mismatches := checkSchema(schema, schema["$defs"].(map[string]any), config, "")
+	assert.Equal(t, []string{".os.notAField (unknown field)"}, mismatches)
+}
+
+func TestWriteSchemaAsYaml(t *testing.T) {
+	schema, _, err := configSchema(configTypeImageCustomizer)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	outputPath := filepath.Join(t.TempDir(), "schema.yaml")
+	err = writeSchema(schema, schemaFormatYaml, outputPath)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	schemaBytes, err := os.ReadFile(outputPath)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	var writtenSchema map[string]any
+	err = yaml.Unmarshal(schemaBytes, &writtenSchema)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "#/$defs/Config", writtenSchema["$ref"])
+}
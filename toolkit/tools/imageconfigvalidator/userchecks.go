@@ -0,0 +1,412 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/imagegen/configuration"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/timestamp"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/userutils"
+)
+
+const (
+	sudoPkgName = "sudo"
+
+	sudoersFile = "/etc/sudoers"
+	sudoersDir  = "/etc/sudoers.d"
+
+	// The IDs below this one are for system users and groups (see SYS_UID_MAX in /etc/login.defs), which the image's
+	// packages create.
+	minRegularId = 1000
+	// The highest ID that the imager accepts (see userutils.UIDIsValid).
+	maxId = 60000
+)
+
+var (
+	// The groups that the default sudoers file grants sudo to.
+	sudoGroups = []string{"wheel", "sudo"}
+
+	// The dirs that a home directory must not be (or be under), as they are managed by the OS.
+	systemDirs = []string{"/bin", "/boot", "/dev", "/etc", "/lib", "/lib64", "/proc", "/run", "/sbin", "/sys", "/usr"}
+
+	// The crypt(5) formats of hashed passwords, by their prefix.
+	passwordHashFormats = []struct {
+		prefix string
+		regex  *regexp.Regexp
+	}{
+		{"$1$", regexp.MustCompile(`^\$1\$[./0-9A-Za-z]{0,8}\$[./0-9A-Za-z]{22}$`)},
+		{"$5$", regexp.MustCompile(`^\$5\$(rounds=\d+\$)?[^$:\n]{0,16}\$[./0-9A-Za-z]{43}$`)},
+		{"$6$", regexp.MustCompile(`^\$6\$(rounds=\d+\$)?[^$:\n]{0,16}\$[./0-9A-Za-z]{86}$`)},
+		{"$y$", regexp.MustCompile(`^\$y\$[./0-9A-Za-z]+\$[./0-9A-Za-z]{0,86}\$[./0-9A-Za-z]{43}$`)},
+		{"$2", regexp.MustCompile(`^\$2[aby]\$\d{2}\$[./0-9A-Za-z]{53}$`)},
+	}
+
+	// The key types of SSH public keys that sshd accepts.
+	sshKeyTypes = map[string]bool{
+		"ssh-ed25519":                        true,
+		"ssh-rsa":                            true,
+		"ecdsa-sha2-nistp256":                true,
+		"ecdsa-sha2-nistp384":                true,
+		"ecdsa-sha2-nistp521":                true,
+		"sk-ssh-ed25519@openssh.com":         true,
+		"sk-ecdsa-sha2-nistp256@openssh.com": true,
+	}
+)
+
+// userCheckRule is a check of each user of a system config.
+type userCheckRule struct {
+	id       string
+	severity ValidationSeverity
+	// The location of the checked setting, relative to the user.
+	field string
+	// check returns the failure message of the rule, or "" if the user passes the rule.
+	check func(user configuration.User) string
+}
+
+// userCheckRules are the user check rules, in the order that they are checked.
+var userCheckRules = []userCheckRule{
+	{
+		id:       ruleUserPasswordHash,
+		severity: SeverityError,
+		field:    "Password",
+		check: func(user configuration.User) string {
+			if !user.PasswordHashed || user.Password == "" {
+				return ""
+			}
+			return checkPasswordHash(user.Name, user.Password)
+		},
+	},
+	{
+		id:       ruleUserPasswordWeakHash,
+		severity: SeverityWarning,
+		field:    "Password",
+		check: func(user configuration.User) string {
+			if user.PasswordHashed && strings.HasPrefix(strings.TrimLeft(user.Password, "!"), "$1$") {
+				return fmt.Sprintf("the password of user (%s) is hashed with MD5, which is weak (use SHA-512 instead, "+
+					"e.g. with 'openssl passwd -6')", user.Name)
+			}
+			return ""
+		},
+	},
+	{
+		id:       ruleUserPasswordPlainText,
+		severity: SeverityWarning,
+		field:    "Password",
+		check: func(user configuration.User) string {
+			if !user.PasswordHashed && user.Password != "" {
+				return fmt.Sprintf("the password of user (%s) is in plain text in the config (set [PasswordHashed] "+
+					"and a hashed password instead)", user.Name)
+			}
+			return ""
+		},
+	},
+	{
+		id:       ruleUserIdRange,
+		severity: SeverityError,
+		field:    "UID",
+		check: func(user configuration.User) string {
+			if user.UID == "" || user.Name == userutils.RootUser {
+				return ""
+			}
+
+			uid, err := strconv.Atoi(user.UID)
+			if err != nil {
+				// The config's own validation rejects UIDs that aren't numbers.
+				return ""
+			}
+			if uid == 0 {
+				return fmt.Sprintf("user (%s) has the UID of root (0), which gives it root's privileges", user.Name)
+			}
+			return ""
+		},
+	},
+	{
+		id:       ruleUserIdRange,
+		severity: SeverityWarning,
+		field:    "UID",
+		check: func(user configuration.User) string {
+			if user.UID == "" {
+				return ""
+			}
+
+			if user.Name == userutils.RootUser {
+				return fmt.Sprintf("the UID (%s) of user (%s) is ignored", user.UID, user.Name)
+			}
+
+			uid, err := strconv.Atoi(user.UID)
+			if err == nil && uid > 0 && uid < minRegularId {
+				return fmt.Sprintf("the UID (%d) of user (%s) is in the system range (1-%d), so it may collide with "+
+					"a user that a package creates", uid, user.Name, minRegularId-1)
+			}
+			return ""
+		},
+	},
+	{
+		id:       ruleUserSshKey,
+		severity: SeverityError,
+		field:    "SSHPubKeys",
+		check: func(user configuration.User) string {
+			for i, key := range user.SSHPubKeys {
+				err := checkSshPublicKey(key)
+				if err != nil {
+					return fmt.Sprintf("invalid SSH public key (%d) of user (%s):\n%s", i, user.Name, err)
+				}
+			}
+			return ""
+		},
+	},
+	{
+		id:       ruleUserSshKey,
+		severity: SeverityError,
+		field:    "SSHPubKeyPaths",
+		check: func(user configuration.User) string {
+			for _, keyPath := range user.SSHPubKeyPaths {
+				keyFile, err := os.ReadFile(keyPath)
+				if err != nil {
+					// Missing files are reported by validateLocalAssets.
+					continue
+				}
+
+				for lineNumber, line := range strings.Split(string(keyFile), "\n") {
+					line = strings.TrimSpace(line)
+					if line == "" || strings.HasPrefix(line, "#") {
+						continue
+					}
+
+					err = checkSshPublicKey(line)
+					if err != nil {
+						return fmt.Sprintf("invalid SSH public key in file (%s), line (%d), of user (%s):\n%s", keyPath,
+							lineNumber+1, user.Name, err)
+					}
+				}
+			}
+			return ""
+		},
+	},
+	{
+		id:       ruleUserHomeDirectory,
+		severity: SeverityError,
+		field:    "HomeDirectory",
+		check: func(user configuration.User) string {
+			// The imager doesn't set root's home directory.
+			if user.HomeDirectory == "" || user.Name == userutils.RootUser {
+				return ""
+			}
+			return checkHomeDirectory(user.Name, user.HomeDirectory)
+		},
+	},
+}
+
+// checkPasswordHash returns the failure message if a hashed password is not in a crypt(5) format that the image's
+// PAM supports. A password that starts with '!' (or is '*') is locked.
+func checkPasswordHash(userName string, password string) string {
+	hash := strings.TrimLeft(password, "!")
+	if hash == "" || hash == "*" {
+		return ""
+	}
+
+	for _, format := range passwordHashFormats {
+		if !strings.HasPrefix(hash, format.prefix) {
+			continue
+		}
+		if format.regex.MatchString(hash) {
+			return ""
+		}
+		return fmt.Sprintf("the hashed password of user (%s) is not a valid '%s' hash", userName, format.prefix)
+	}
+
+	return fmt.Sprintf("the hashed password of user (%s) is not in a supported format (expected a SHA-512 hash, e.g. "+
+		"from 'openssl passwd -6')", userName)
+}
+
+// checkSshPublicKey returns an error if a line of an authorized_keys file is not a valid public key. The key may be
+// preceded by options (e.g. 'no-pty').
+func checkSshPublicKey(key string) error {
+	fields := strings.Fields(key)
+	keyTypeIndex := -1
+	for i, field := range fields {
+		if sshKeyTypes[field] {
+			keyTypeIndex = i
+			break
+		}
+	}
+
+	switch {
+	case len(fields) == 0:
+		return fmt.Errorf("key is empty")
+
+	case keyTypeIndex < 0 && fields[0] == "ssh-dss":
+		return fmt.Errorf("DSA keys (ssh-dss) are not supported by sshd")
+
+	case keyTypeIndex < 0:
+		return fmt.Errorf("unknown key type")
+
+	case keyTypeIndex+1 >= len(fields):
+		return fmt.Errorf("key has no key data")
+	}
+
+	keyType := fields[keyTypeIndex]
+	keyData, err := base64.StdEncoding.DecodeString(fields[keyTypeIndex+1])
+	if err != nil {
+		return fmt.Errorf("key data is not base64:\n%w", err)
+	}
+
+	// The key data starts with the key type, as a length-prefixed string.
+	if len(keyData) < 4 {
+		return fmt.Errorf("key data is too short")
+	}
+	keyTypeLength := binary.BigEndian.Uint32(keyData)
+	if uint64(keyTypeLength) > uint64(len(keyData)-4) || !bytes.Equal(keyData[4:4+keyTypeLength], []byte(keyType)) {
+		return fmt.Errorf("key data doesn't match the key type (%s)", keyType)
+	}
+
+	return nil
+}
+
+// checkHomeDirectory returns the failure message if a home directory is not a clean, absolute, path outside of the
+// system dirs.
+func checkHomeDirectory(userName string, homeDirectory string) string {
+	if !path.IsAbs(homeDirectory) {
+		return fmt.Sprintf("the home directory (%s) of user (%s) is not an absolute path", homeDirectory, userName)
+	}
+
+	if path.Clean(homeDirectory) != homeDirectory {
+		return fmt.Sprintf("the home directory (%s) of user (%s) is not a clean path (%s)", homeDirectory, userName,
+			path.Clean(homeDirectory))
+	}
+
+	if homeDirectory == "/" {
+		return fmt.Sprintf("the home directory of user (%s) is the root directory", userName)
+	}
+
+	for _, systemDir := range systemDirs {
+		if isPathUnder(homeDirectory, systemDir) {
+			return fmt.Sprintf("the home directory (%s) of user (%s) is in a system directory (%s)", homeDirectory,
+				userName, systemDir)
+		}
+	}
+
+	return ""
+}
+
+// validateUsers checks the users and groups of the system configs.
+func validateUsers(config configuration.Config) (results []ValidationResult) {
+	timestamp.StartEvent("validate users", nil)
+	defer timestamp.StopEvent(nil)
+
+	for i, systemConfig := range config.SystemConfigs {
+		results = append(results, runUserCheckRules(userCheckRules, i, systemConfig)...)
+		results = append(results, validateUserAndGroupIds(i, systemConfig)...)
+	}
+	return
+}
+
+// runUserCheckRules runs the rules against each user of a system config.
+func runUserCheckRules(rules []userCheckRule, systemConfigIndex int, systemConfig configuration.SystemConfig,
+) (results []ValidationResult) {
+	for i, user := range systemConfig.Users {
+		for _, rule := range rules {
+			message := rule.check(user)
+			if message == "" {
+				continue
+			}
+
+			path := systemConfigPath(systemConfigIndex, fmt.Sprintf("Users[%d].%s", i, rule.field))
+			if rule.severity == SeverityWarning {
+				results = append(results, newValidationWarning(rule.id, path, message))
+			} else {
+				results = append(results, newValidationResult(rule.id, path, fmt.Errorf("%s", message)))
+			}
+		}
+	}
+	return
+}
+
+// validateUserAndGroupIds checks that the users' UIDs and the groups' GIDs are unique, and that the GIDs are in range.
+func validateUserAndGroupIds(systemConfigIndex int, systemConfig configuration.SystemConfig,
+) (results []ValidationResult) {
+	uidUsers := make(map[string]string)
+	for i, user := range systemConfig.Users {
+		if user.UID == "" || user.Name == userutils.RootUser {
+			continue
+		}
+
+		otherUser, found := uidUsers[user.UID]
+		if found {
+			results = append(results, newValidationResult(ruleUserIdDuplicate,
+				systemConfigPath(systemConfigIndex, fmt.Sprintf("Users[%d].UID", i)),
+				fmt.Errorf("users (%s) and (%s) have the same UID (%s)", otherUser, user.Name, user.UID)))
+			continue
+		}
+		uidUsers[user.UID] = user.Name
+	}
+
+	gidGroups := make(map[int]string)
+	for i, group := range systemConfig.Groups {
+		if group.GID == "" {
+			continue
+		}
+
+		path := systemConfigPath(systemConfigIndex, fmt.Sprintf("Groups[%d].GID", i))
+		gid, err := strconv.Atoi(group.GID)
+		switch {
+		case err != nil || gid < 0 || gid > maxId:
+			results = append(results, newValidationResult(ruleUserIdRange, path,
+				fmt.Errorf("invalid GID (%s) of group (%s), not within [0, %d]", group.GID, group.Name, maxId)))
+			continue
+
+		case gid == 0:
+			results = append(results, newValidationResult(ruleUserIdRange, path,
+				fmt.Errorf("group (%s) has the GID of root (0)", group.Name)))
+
+		case gid < minRegularId:
+			results = append(results, newValidationWarning(ruleUserIdRange, path,
+				fmt.Sprintf("the GID (%d) of group (%s) is in the system range (1-%d), so it may collide with a group "+
+					"that a package creates", gid, group.Name, minRegularId-1)))
+		}
+
+		otherGroup, found := gidGroups[gid]
+		if found {
+			results = append(results, newValidationResult(ruleUserIdDuplicate, path,
+				fmt.Errorf("groups (%s) and (%s) have the same GID (%d)", otherGroup, group.Name, gid)))
+			continue
+		}
+		gidGroups[gid] = group.Name
+	}
+
+	return
+}
+
+// sudoReason is the reason that a system config needs the sudo package: a user that's in a sudo group, or a sudoers
+// file.
+func sudoReason(ctx *crossCheckContext) string {
+	for _, user := range ctx.systemConfig.Users {
+		for _, group := range append([]string{user.PrimaryGroup}, user.SecondaryGroups...) {
+			for _, sudoGroup := range sudoGroups {
+				if group == sudoGroup {
+					return fmt.Sprintf("user (%s) is in the '%s' group", user.Name, group)
+				}
+			}
+		}
+	}
+
+	for _, fileConfigs := range ctx.systemConfig.AdditionalFiles {
+		for _, fileConfig := range fileConfigs {
+			if fileConfig.Path == sudoersFile || isPathUnder(fileConfig.Path, sudoersDir) {
+				return fmt.Sprintf("sudoers file (%s) added", fileConfig.Path)
+			}
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,217 @@
+// Copyright Microsoft Corporation.
+// Licensed under the MIT License.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/imagegen/configuration"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testSha512Hash = "$6$saltsalt$JcVDtuB6d1BHhCd5RPBh8g8xX/1CbY8EU2PN0MTaj2/Mypw4P./C6dN4j0HALhzBDTocyW1Jm.gYaTPjFGCV40"
+	testMd5Hash    = "$1$saltsalt$tTWg0JeO/sYmHvtKmZE8c."
+	testSshKey     = "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIAABAgMEBQYHCAkKCwwNDg8QERITFBUWFxgZGhscHR4f test@example"
+)
+
+func TestUserCheckRules(t *testing.T) {
+	tests := []struct {
+		name     string
+		user     configuration.User
+		ruleIds  []string
+		severity ValidationSeverity
+		path     string
+		message  string
+	}{
+		{
+			name: "sha-512 hash",
+			user: configuration.User{Name: "test", PasswordHashed: true, Password: testSha512Hash},
+		},
+		{
+			name: "locked password",
+			user: configuration.User{Name: "test", PasswordHashed: true, Password: "!" + testSha512Hash},
+		},
+		{
+			name:     "truncated sha-512 hash",
+			user:     configuration.User{Name: "test", PasswordHashed: true, Password: testSha512Hash[:40]},
+			ruleIds:  []string{ruleUserPasswordHash},
+			severity: SeverityError,
+			path:     "SystemConfigs[0].Users[0].Password",
+			message:  "the hashed password of user (test) is not a valid '$6$' hash",
+		},
+		{
+			name:     "plain text password marked as hashed",
+			user:     configuration.User{Name: "test", PasswordHashed: true, Password: "hunter2"},
+			ruleIds:  []string{ruleUserPasswordHash},
+			severity: SeverityError,
+			path:     "SystemConfigs[0].Users[0].Password",
+			message: "the hashed password of user (test) is not in a supported format (expected a SHA-512 hash, e.g. " +
+				"from 'openssl passwd -6')",
+		},
+		{
+			name:     "md5 hash",
+			user:     configuration.User{Name: "test", PasswordHashed: true, Password: testMd5Hash},
+			ruleIds:  []string{ruleUserPasswordWeakHash},
+			severity: SeverityWarning,
+			path:     "SystemConfigs[0].Users[0].Password",
+		},
+		{
+			name:     "plain text password",
+			user:     configuration.User{Name: "test", Password: "hunter2"},
+			ruleIds:  []string{ruleUserPasswordPlainText},
+			severity: SeverityWarning,
+			path:     "SystemConfigs[0].Users[0].Password",
+		},
+		{
+			name:     "uid of root",
+			user:     configuration.User{Name: "test", UID: "0"},
+			ruleIds:  []string{ruleUserIdRange},
+			severity: SeverityError,
+			path:     "SystemConfigs[0].Users[0].UID",
+			message:  "user (test) has the UID of root (0), which gives it root's privileges",
+		},
+		{
+			name:     "uid in the system range",
+			user:     configuration.User{Name: "test", UID: "500"},
+			ruleIds:  []string{ruleUserIdRange},
+			severity: SeverityWarning,
+			path:     "SystemConfigs[0].Users[0].UID",
+			message: "the UID (500) of user (test) is in the system range (1-999), so it may collide with a user that a " +
+				"package creates",
+		},
+		{
+			name: "regular uid",
+			user: configuration.User{Name: "test", UID: "1000"},
+		},
+		{
+			name: "valid ssh key with options",
+			user: configuration.User{Name: "test", SSHPubKeys: []string{"no-pty,no-port-forwarding " + testSshKey}},
+		},
+		{
+			name:     "ssh key with the wrong key type",
+			user:     configuration.User{Name: "test", SSHPubKeys: []string{"ssh-rsa" + testSshKey[len("ssh-ed25519"):]}},
+			ruleIds:  []string{ruleUserSshKey},
+			severity: SeverityError,
+			path:     "SystemConfigs[0].Users[0].SSHPubKeys",
+			message:  "invalid SSH public key (0) of user (test):\nkey data doesn't match the key type (ssh-rsa)",
+		},
+		{
+			name:     "dsa ssh key",
+			user:     configuration.User{Name: "test", SSHPubKeys: []string{"ssh-dss AAAAB3NzaC1kc3M="}},
+			ruleIds:  []string{ruleUserSshKey},
+			severity: SeverityError,
+			path:     "SystemConfigs[0].Users[0].SSHPubKeys",
+			message:  "invalid SSH public key (0) of user (test):\nDSA keys (ssh-dss) are not supported by sshd",
+		},
+		{
+			name:     "relative home directory",
+			user:     configuration.User{Name: "test", HomeDirectory: "home/test"},
+			ruleIds:  []string{ruleUserHomeDirectory},
+			severity: SeverityError,
+			path:     "SystemConfigs[0].Users[0].HomeDirectory",
+			message:  "the home directory (home/test) of user (test) is not an absolute path",
+		},
+		{
+			name:     "home directory in a system directory",
+			user:     configuration.User{Name: "test", HomeDirectory: "/usr/local/test"},
+			ruleIds:  []string{ruleUserHomeDirectory},
+			severity: SeverityError,
+			path:     "SystemConfigs[0].Users[0].HomeDirectory",
+			message:  "the home directory (/usr/local/test) of user (test) is in a system directory (/usr)",
+		},
+		{
+			name:     "unclean home directory",
+			user:     configuration.User{Name: "test", HomeDirectory: "/home/../etc"},
+			ruleIds:  []string{ruleUserHomeDirectory},
+			severity: SeverityError,
+			path:     "SystemConfigs[0].Users[0].HomeDirectory",
+			message:  "the home directory (/home/../etc) of user (test) is not a clean path (/etc)",
+		},
+		{
+			name: "home directory of root",
+			user: configuration.User{Name: "root", HomeDirectory: "/"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			systemConfig := configuration.SystemConfig{Users: []configuration.User{test.user}}
+			results := runUserCheckRules(userCheckRules, 0, systemConfig)
+
+			ruleIds := []string(nil)
+			for _, result := range results {
+				ruleIds = append(ruleIds, result.RuleId)
+			}
+			assert.Equal(t, test.ruleIds, ruleIds)
+
+			if len(results) > 0 {
+				assert.Equal(t, test.severity, results[0].Severity)
+				assert.Equal(t, test.path, results[0].Path)
+				if test.message != "" {
+					assert.Equal(t, test.message, results[0].Message)
+				}
+			}
+		})
+	}
+}
+
+func TestUserSshKeyFile(t *testing.T) {
+	keyPath := filepath.Join(t.TempDir(), "keys.pub")
+	err := os.WriteFile(keyPath, []byte("# comment\n"+testSshKey+"\n\nssh-ed25519 AAAA\n"), 0o644)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	systemConfig := configuration.SystemConfig{Users: []configuration.User{
+		{Name: "test", SSHPubKeyPaths: []string{keyPath}},
+	}}
+	results := runUserCheckRules(userCheckRules, 0, systemConfig)
+	if !assert.Len(t, results, 1) {
+		return
+	}
+	assert.Equal(t, ruleUserSshKey, results[0].RuleId)
+	assert.Equal(t, "SystemConfigs[0].Users[0].SSHPubKeyPaths", results[0].Path)
+	assert.Equal(t, "invalid SSH public key in file ("+keyPath+"), line (4), of user (test):\nkey data is too short",
+		results[0].Message)
+}
+
+func TestUserAndGroupIds(t *testing.T) {
+	systemConfig := configuration.SystemConfig{
+		Users: []configuration.User{
+			{Name: "a", UID: "1000"},
+			{Name: "b", UID: "1000"},
+			{Name: "root", UID: "1000"},
+		},
+		Groups: []configuration.Group{
+			{Name: "a", GID: "1000"},
+			{Name: "b", GID: "1000"},
+			{Name: "c", GID: "100"},
+			{Name: "d", GID: "70000"},
+		},
+	}
+
+	results := validateUserAndGroupIds(0, systemConfig)
+	if !assert.Len(t, results, 4) {
+		return
+	}
+
+	assert.Equal(t, ruleUserIdDuplicate, results[0].RuleId)
+	assert.Equal(t, "SystemConfigs[0].Users[1].UID", results[0].Path)
+	assert.Equal(t, "users (a) and (b) have the same UID (1000)", results[0].Message)
+
+	assert.Equal(t, ruleUserIdDuplicate, results[1].RuleId)
+	assert.Equal(t, "SystemConfigs[0].Groups[1].GID", results[1].Path)
+
+	assert.Equal(t, ruleUserIdRange, results[2].RuleId)
+	assert.Equal(t, SeverityWarning, results[2].Severity)
+	assert.Equal(t, "SystemConfigs[0].Groups[2].GID", results[2].Path)
+
+	assert.Equal(t, ruleUserIdRange, results[3].RuleId)
+	assert.Equal(t, SeverityError, results[3].Severity)
+	assert.Equal(t, "invalid GID (70000) of group (d), not within [0, 60000]", results[3].Message)
+}
@@ -0,0 +1,117 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/exe"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/logger"
+	"github.com/microsoft/azurelinux/toolkit/tools/pkg/imagecustomizerlib"
+	"golang.org/x/sys/unix"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+const (
+	diffCommand = "diff"
+
+	diffOutputFormatText = "text"
+	diffOutputFormatJson = "json"
+)
+
+var (
+	diffApp = kingpin.New("imagecustomizer "+diffCommand,
+		"Compares two images (or isos) and reports the differences in their packages, config files, kernels and "+
+			"systemd units")
+
+	diffBuildDir       = diffApp.Flag("build-dir", "Directory to run the comparison in.").Required().String()
+	diffImageFile      = diffApp.Flag("image-file", "Path of the first image to compare.").Required().ExistingFile()
+	diffOtherImageFile = diffApp.Flag("other-image-file", "Path of the second image to compare.").Required().ExistingFile()
+	diffOutput         = diffApp.Flag("output", "Path to write the differences to. Defaults to stdout.").String()
+	diffOutputFormat   = diffApp.Flag("output-format", "Format of the differences.").Default(diffOutputFormatText).Enum(diffOutputFormatText, diffOutputFormatJson)
+	diffLogFlags       = exe.SetupLogFlags(diffApp)
+)
+
+func diffMain(args []string) {
+	diffApp.Version(imagecustomizerlib.ToolVersion)
+	kingpin.MustParse(diffApp.Parse(args))
+
+	logger.InitBestEffort(diffLogFlags)
+
+	ctx, stop := signal.NotifyContext(context.Background(), unix.SIGINT, unix.SIGTERM)
+	defer stop()
+
+	diff, err := imagecustomizerlib.DiffImages(ctx, *diffBuildDir, *diffImageFile, *diffOtherImageFile)
+	if err != nil {
+		log.Fatalf("image diff failed:\n%v", err)
+	}
+
+	output := ""
+	switch *diffOutputFormat {
+	case diffOutputFormatJson:
+		outputBytes, err := json.MarshalIndent(diff, "", "  ")
+		if err != nil {
+			log.Fatalf("failed to format differences:\n%v", err)
+		}
+		output = string(outputBytes) + "\n"
+
+	default:
+		output = formatImageDiffText(diff)
+	}
+
+	if *diffOutput == "" {
+		fmt.Print(output)
+		return
+	}
+
+	err = os.WriteFile(*diffOutput, []byte(output), 0o644)
+	if err != nil {
+		log.Fatalf("failed to write differences (%s):\n%v", *diffOutput, err)
+	}
+}
+
+// formatImageDiffText formats the differences in a diff-like way: '-' for the first image's values and '+' for the
+// other image's values.
+func formatImageDiffText(diff *imagecustomizerlib.ImageDiff) string {
+	builder := strings.Builder{}
+
+	writeSection := func(name string, valueDiffs ...imagecustomizerlib.ValueDiff) {
+		if len(valueDiffs) == 0 {
+			return
+		}
+
+		builder.WriteString(fmt.Sprintf("%s:\n", name))
+		for _, valueDiff := range valueDiffs {
+			builder.WriteString(fmt.Sprintf("  %s\n", valueDiff.Name))
+			if valueDiff.Value != "" {
+				builder.WriteString(fmt.Sprintf("    - %s\n", valueDiff.Value))
+			}
+			if valueDiff.OtherValue != "" {
+				builder.WriteString(fmt.Sprintf("    + %s\n", valueDiff.OtherValue))
+			}
+		}
+	}
+
+	writeSection("Packages", diff.Packages...)
+	writeSection("Config files", diff.ConfigFiles...)
+	if diff.KernelVersions != nil {
+		writeSection("Kernel versions", *diff.KernelVersions)
+	}
+	if diff.KernelCommandLine != nil {
+		writeSection("Kernel command line", *diff.KernelCommandLine)
+	}
+	writeSection("Systemd units", diff.SystemdUnits...)
+
+	if !diff.HasDifferences() {
+		builder.WriteString("The images have no differences.\n")
+	}
+
+	return builder.String()
+}
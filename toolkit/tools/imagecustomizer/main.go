@@ -4,14 +4,17 @@
 package main
 
 import (
+	"context"
 	"log"
 	"os"
+	"os/signal"
 
 	"github.com/microsoft/azurelinux/toolkit/tools/internal/exe"
 	"github.com/microsoft/azurelinux/toolkit/tools/internal/logger"
 	"github.com/microsoft/azurelinux/toolkit/tools/internal/timestamp"
 	"github.com/microsoft/azurelinux/toolkit/tools/pkg/imagecustomizerlib"
 	"github.com/microsoft/azurelinux/toolkit/tools/pkg/profile"
+	"golang.org/x/sys/unix"
 	"gopkg.in/alecthomas/kingpin.v2"
 )
 
@@ -21,13 +24,19 @@ var (
 	buildDir                    = app.Flag("build-dir", "Directory to run build out of.").Required().String()
 	imageFile                   = app.Flag("image-file", "Path of the base Azure Linux image which the customization will be applied to.").Required().String()
 	outputImageFile             = app.Flag("output-image-file", "Path to write the customized image to.").Required().String()
-	outputImageFormat           = app.Flag("output-image-format", "Format of output image. Supported: vhd, vhdx, qcow2, raw, iso.").Enum("vhd", "vhd-fixed", "vhdx", "qcow2", "raw", "iso")
+	outputImageFormat           = app.Flag("output-image-format", "Format of output image. Supported: vhd, vhdx, qcow2, raw, iso, oci, wsl.").Enum("vhd", "vhd-fixed", "vhdx", "qcow2", "raw", "iso", "oci", "wsl")
 	outputSplitPartitionsFormat = app.Flag("output-split-partitions-format", "Format of partition files. Supported: raw, raw-zst").Enum("raw", "raw-zst")
 	configFile                  = app.Flag("config-file", "Path of the image customization config file.").Required().String()
 	rpmSources                  = app.Flag("rpm-source", "Path to a RPM repo config file or a directory containing RPMs.").Strings()
 	disableBaseImageRpmRepos    = app.Flag("disable-base-image-rpm-repos", "Disable the base image's RPM repos as an RPM source").Bool()
 	enableShrinkFilesystems     = app.Flag("shrink-filesystems", "Enable shrinking of filesystems to minimum size. Supports ext2, ext3, ext4 filesystem types.").Bool()
 	outputPXEArtifactsDir       = app.Flag("output-pxe-artifacts-dir", "Create a directory with customized image PXE booting artifacts. '--output-image-format' must be set to 'iso'.").String()
+	maxParallelWorkers          = app.Flag("max-parallel-workers", "Maximum number of parallel workers used by the copy and squashfs steps. Defaults to the number of CPUs.").Default("0").Int()
+	niceness                    = app.Flag("nice", "Niceness (-20 to 19) of the copy, squashfs, and dracut steps.").Default("0").Int()
+	ioniceClass                 = app.Flag("ionice-class", "I/O scheduling class of the copy, squashfs, and dracut steps. Supported: idle, best-effort, realtime.").Enum("idle", "best-effort", "realtime")
+	keepWorkDir                 = app.Flag("keep-workdir", "Keep the outputs of the completed build phases in the build directory if the build fails.").Bool()
+	resume                      = app.Flag("resume", "Resume from the last successful build phase of a previous build that used the same build directory and inputs. Implies --keep-workdir.").Bool()
+	timeout                     = app.Flag("timeout", "Maximum duration of the build (e.g. 90m). Defaults to no limit.").Default("0").Duration()
 	logFlags                    = exe.SetupLogFlags(app)
 	profFlags                   = exe.SetupProfileFlags(app)
 	timestampFile               = app.Flag("timestamp-file", "File that stores timestamps for this program.").String()
@@ -36,6 +45,21 @@ var (
 func main() {
 	var err error
 
+	if len(os.Args) > 1 && os.Args[1] == pxeServeCommand {
+		pxeServeMain(os.Args[2:])
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == diffCommand {
+		diffMain(os.Args[2:])
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == patchCommand {
+		patchMain(os.Args[2:])
+		return
+	}
+
 	app.Version(imagecustomizerlib.ToolVersion)
 	kingpin.MustParse(app.Parse(os.Args[1:]))
 	if *outputSplitPartitionsFormat == "" && *outputImageFormat == "" {
@@ -70,9 +94,45 @@ func main() {
 func customizeImage() error {
 	var err error
 
-	err = imagecustomizerlib.CustomizeImageWithConfigFile(*buildDir, *configFile, *imageFile,
+	// Stop the build gracefully (i.e. kill the running tools and clean-up the mounts and intermediate files) on
+	// SIGINT or SIGTERM. This replaces the safechroot package's handler, which exits the process immediately.
+	// A second signal uses the default behavior, which terminates the process.
+	signal.Reset(unix.SIGINT, unix.SIGTERM)
+	signalCtx, stop := signal.NotifyContext(context.Background(), unix.SIGINT, unix.SIGTERM)
+	defer stop()
+
+	go func() {
+		<-signalCtx.Done()
+		stop()
+	}()
+
+	ctx := signalCtx
+
+	if *timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *timeout)
+		defer cancel()
+	}
+
+	options := imagecustomizerlib.CustomizeImageOptions{
+		BuildResources: imagecustomizerlib.BuildResources{
+			MaxParallelWorkers: *maxParallelWorkers,
+			Niceness:           *niceness,
+			IoniceClass:        imagecustomizerlib.IoniceClass(*ioniceClass),
+		},
+	}
+
+	switch {
+	case *resume:
+		options.ResumeMode = imagecustomizerlib.ResumeModeResume
+
+	case *keepWorkDir:
+		options.ResumeMode = imagecustomizerlib.ResumeModeKeepWorkDir
+	}
+
+	err = imagecustomizerlib.CustomizeImageWithConfigFile(ctx, *buildDir, *configFile, *imageFile,
 		*rpmSources, *outputImageFile, *outputImageFormat, *outputSplitPartitionsFormat, *outputPXEArtifactsDir,
-		!*disableBaseImageRpmRepos, *enableShrinkFilesystems)
+		!*disableBaseImageRpmRepos, *enableShrinkFilesystems, options)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,53 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package main
+
+import (
+	"context"
+	"log"
+	"os/signal"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/exe"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/logger"
+	"github.com/microsoft/azurelinux/toolkit/tools/pkg/imagecustomizerlib"
+	"golang.org/x/sys/unix"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+const (
+	patchCommand = "patch"
+)
+
+var (
+	patchApp = kingpin.New("imagecustomizer "+patchCommand,
+		"Updates the packages of an existing image, without its customization config (e.g. for CVE patch releases)")
+
+	patchBuildDir                 = patchApp.Flag("build-dir", "Directory to run build out of.").Required().String()
+	patchImageFile                = patchApp.Flag("image-file", "Path of the existing image to patch.").Required().ExistingFile()
+	patchOutputImageFile          = patchApp.Flag("output-image-file", "Path to write the patched image to.").Required().String()
+	patchOutputImageFormat        = patchApp.Flag("output-image-format", "Format of output image. Supported: vhd, vhdx, qcow2, raw, iso.").Required().Enum("vhd", "vhd-fixed", "vhdx", "qcow2", "raw", "iso")
+	patchRpmSources               = patchApp.Flag("rpm-source", "Path to a RPM repo config file or a directory containing RPMs.").Strings()
+	patchDisableBaseImageRpmRepos = patchApp.Flag("disable-base-image-rpm-repos", "Disable the base image's RPM repos as an RPM source").Bool()
+	patchPackages                 = patchApp.Flag("package", "Name of an extra package to install (or update).").Strings()
+	patchLogFlags                 = exe.SetupLogFlags(patchApp)
+)
+
+func patchMain(args []string) {
+	patchApp.Version(imagecustomizerlib.ToolVersion)
+	kingpin.MustParse(patchApp.Parse(args))
+
+	logger.InitBestEffort(patchLogFlags)
+
+	// Stop the build gracefully on SIGINT or SIGTERM (see customizeImage).
+	signal.Reset(unix.SIGINT, unix.SIGTERM)
+	ctx, stop := signal.NotifyContext(context.Background(), unix.SIGINT, unix.SIGTERM)
+	defer stop()
+
+	err := imagecustomizerlib.PatchImage(ctx, *patchBuildDir, *patchImageFile, *patchRpmSources,
+		!*patchDisableBaseImageRpmRepos, *patchPackages, *patchOutputImageFile, *patchOutputImageFormat,
+		imagecustomizerlib.BuildResources{})
+	if err != nil {
+		log.Fatalf("image patch failed:\n%v", err)
+	}
+}
@@ -0,0 +1,44 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package main
+
+import (
+	"context"
+	"log"
+	"os/signal"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/exe"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/logger"
+	"github.com/microsoft/azurelinux/toolkit/tools/pkg/imagecustomizerlib"
+	"golang.org/x/sys/unix"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+const (
+	pxeServeCommand = "pxe-serve"
+)
+
+var (
+	pxeServeApp = kingpin.New("imagecustomizer "+pxeServeCommand,
+		"Serves a PXE artifacts directory over HTTP, for quick PXE testing")
+
+	pxeServeDir      = pxeServeApp.Flag("pxe-artifacts-dir", "The PXE artifacts directory to serve (see '--output-pxe-artifacts-dir').").Required().ExistingDir()
+	pxeServeAddress  = pxeServeApp.Flag("address", "The address to listen on.").Default(":8080").String()
+	pxeServeLogFlags = exe.SetupLogFlags(pxeServeApp)
+)
+
+func pxeServeMain(args []string) {
+	pxeServeApp.Version(imagecustomizerlib.ToolVersion)
+	kingpin.MustParse(pxeServeApp.Parse(args))
+
+	logger.InitBestEffort(pxeServeLogFlags)
+
+	ctx, stop := signal.NotifyContext(context.Background(), unix.SIGINT, unix.SIGTERM)
+	defer stop()
+
+	err := imagecustomizerlib.ServePxeArtifacts(ctx, *pxeServeDir, *pxeServeAddress)
+	if err != nil {
+		log.Fatalf("serving PXE artifacts failed:\n%v", err)
+	}
+}
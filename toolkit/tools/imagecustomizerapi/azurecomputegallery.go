@@ -0,0 +1,110 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerapi
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/google/uuid"
+)
+
+var (
+	// An image version is in the format: MajorVersion.MinorVersion.Patch
+	azureGalleryImageVersionRegex = regexp.MustCompile(`^\d+\.\d+\.\d+$`)
+	// Storage account names are 3 to 24 lowercase letters and digits.
+	azureStorageAccountRegex = regexp.MustCompile(`^[a-z0-9]{3,24}$`)
+	// Container names are 3 to 63 lowercase letters, digits and (non-consecutive) dashes, which start and end with a
+	// letter or a digit.
+	azureStorageContainerRegex = regexp.MustCompile(`^[a-z0-9](-?[a-z0-9])+$`)
+	// The names of the resource groups, galleries and image definitions.
+	azureResourceNameRegex = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9._()-]{0,89}$`)
+	// Region names (e.g. westus2).
+	azureRegionRegex = regexp.MustCompile(`^[a-z0-9]+$`)
+)
+
+// AzureComputeGallery configures the publishing of the output VHD as an image version of an Azure Compute Gallery
+// (formerly known as Shared Image Gallery). The VHD is uploaded to a storage account, using a managed identity.
+type AzureComputeGallery struct {
+	// The subscription of the gallery and the storage account.
+	SubscriptionId string `yaml:"subscriptionId"`
+	// The resource group of the gallery.
+	ResourceGroup string `yaml:"resourceGroup"`
+	// The name of the gallery.
+	GalleryName string `yaml:"galleryName"`
+	// The name of the gallery's image definition.
+	ImageDefinition string `yaml:"imageDefinition"`
+	// The version of the image (e.g. 1.0.0).
+	ImageVersion string `yaml:"imageVersion"`
+	// The regions to replicate the image version to. By default, the image version is only in the gallery's region.
+	TargetRegions []string `yaml:"targetRegions"`
+	// The storage account that the VHD is uploaded to.
+	StorageAccount string `yaml:"storageAccount"`
+	// The resource group of the storage account. Defaults to the gallery's resource group.
+	StorageResourceGroup string `yaml:"storageResourceGroup"`
+	// The blob container that the VHD is uploaded to.
+	StorageContainer string `yaml:"storageContainer"`
+	// The client ID of the user-assigned managed identity to sign in with. By default, the system-assigned managed
+	// identity is used.
+	ManagedIdentityClientId string `yaml:"managedIdentityClientId"`
+}
+
+func (g *AzureComputeGallery) IsValid() error {
+	_, err := uuid.Parse(g.SubscriptionId)
+	if err != nil {
+		return fmt.Errorf("invalid subscriptionId value (%s):\nmust be a GUID", g.SubscriptionId)
+	}
+
+	namedValues := []struct {
+		name     string
+		value    string
+		optional bool
+	}{
+		{name: "resourceGroup", value: g.ResourceGroup},
+		{name: "galleryName", value: g.GalleryName},
+		{name: "imageDefinition", value: g.ImageDefinition},
+		{name: "storageResourceGroup", value: g.StorageResourceGroup, optional: true},
+	}
+	for _, namedValue := range namedValues {
+		if namedValue.value == "" && namedValue.optional {
+			continue
+		}
+
+		if !azureResourceNameRegex.MatchString(namedValue.value) {
+			return fmt.Errorf("invalid %s value (%s):\nmust be a valid Azure resource name", namedValue.name,
+				namedValue.value)
+		}
+	}
+
+	if !azureGalleryImageVersionRegex.MatchString(g.ImageVersion) {
+		return fmt.Errorf("invalid imageVersion value (%s):\nmust be in the format: MajorVersion.MinorVersion.Patch",
+			g.ImageVersion)
+	}
+
+	for i, targetRegion := range g.TargetRegions {
+		if !azureRegionRegex.MatchString(targetRegion) {
+			return fmt.Errorf("invalid targetRegions value at index %d (%s):\nmust be an Azure region name (e.g. westus2)",
+				i, targetRegion)
+		}
+	}
+
+	if !azureStorageAccountRegex.MatchString(g.StorageAccount) {
+		return fmt.Errorf("invalid storageAccount value (%s):\nmust be 3 to 24 lowercase letters and digits",
+			g.StorageAccount)
+	}
+
+	if len(g.StorageContainer) < 3 || len(g.StorageContainer) > 63 ||
+		!azureStorageContainerRegex.MatchString(g.StorageContainer) {
+		return fmt.Errorf("invalid storageContainer value (%s):\nmust be a valid blob container name", g.StorageContainer)
+	}
+
+	if g.ManagedIdentityClientId != "" {
+		_, err = uuid.Parse(g.ManagedIdentityClientId)
+		if err != nil {
+			return fmt.Errorf("invalid managedIdentityClientId value (%s):\nmust be a GUID", g.ManagedIdentityClientId)
+		}
+	}
+
+	return nil
+}
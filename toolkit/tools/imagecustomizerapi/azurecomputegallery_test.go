@@ -0,0 +1,87 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerapi
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func validAzureComputeGallery() AzureComputeGallery {
+	return AzureComputeGallery{
+		SubscriptionId:          "00000000-0000-0000-0000-000000000001",
+		ResourceGroup:           "azurelinux-images",
+		GalleryName:             "azurelinux_gallery",
+		ImageDefinition:         "azurelinux-3.0-gen2",
+		ImageVersion:            "3.0.20240601",
+		TargetRegions:           []string{"westus2", "eastus"},
+		StorageAccount:          "azurelinuximages",
+		StorageContainer:        "vhds",
+		ManagedIdentityClientId: "00000000-0000-0000-0000-000000000002",
+	}
+}
+
+func TestAzureComputeGalleryIsValid(t *testing.T) {
+	gallery := validAzureComputeGallery()
+	err := gallery.IsValid()
+	assert.NoError(t, err)
+}
+
+func TestAzureComputeGalleryIsValidInvalidSubscriptionId(t *testing.T) {
+	gallery := validAzureComputeGallery()
+	gallery.SubscriptionId = "contoso"
+	err := gallery.IsValid()
+	assert.ErrorContains(t, err, "invalid subscriptionId value (contoso)")
+}
+
+func TestAzureComputeGalleryIsValidMissingGalleryName(t *testing.T) {
+	gallery := validAzureComputeGallery()
+	gallery.GalleryName = ""
+	err := gallery.IsValid()
+	assert.ErrorContains(t, err, "invalid galleryName value ()")
+}
+
+func TestAzureComputeGalleryIsValidInvalidImageVersion(t *testing.T) {
+	gallery := validAzureComputeGallery()
+	gallery.ImageVersion = "3.0"
+	err := gallery.IsValid()
+	assert.ErrorContains(t, err, "invalid imageVersion value (3.0)")
+}
+
+func TestAzureComputeGalleryIsValidInvalidTargetRegion(t *testing.T) {
+	gallery := validAzureComputeGallery()
+	gallery.TargetRegions = []string{"westus2", "West US"}
+	err := gallery.IsValid()
+	assert.ErrorContains(t, err, "invalid targetRegions value at index 1 (West US)")
+}
+
+func TestAzureComputeGalleryIsValidInvalidStorageAccount(t *testing.T) {
+	gallery := validAzureComputeGallery()
+	gallery.StorageAccount = "AzureLinuxImages"
+	err := gallery.IsValid()
+	assert.ErrorContains(t, err, "invalid storageAccount value (AzureLinuxImages)")
+}
+
+func TestAzureComputeGalleryIsValidInvalidStorageContainer(t *testing.T) {
+	gallery := validAzureComputeGallery()
+	gallery.StorageContainer = "vhds--daily"
+	err := gallery.IsValid()
+	assert.ErrorContains(t, err, "invalid storageContainer value (vhds--daily)")
+}
+
+func TestAzureComputeGalleryIsValidInvalidManagedIdentityClientId(t *testing.T) {
+	gallery := validAzureComputeGallery()
+	gallery.ManagedIdentityClientId = "identity"
+	err := gallery.IsValid()
+	assert.ErrorContains(t, err, "invalid managedIdentityClientId value (identity)")
+}
+
+func TestConfigIsValidInvalidAzureComputeGallery(t *testing.T) {
+	config := Config{
+		AzureComputeGallery: &AzureComputeGallery{},
+	}
+	err := config.IsValid()
+	assert.ErrorContains(t, err, "invalid 'azureComputeGallery' field")
+}
@@ -6,14 +6,25 @@ package imagecustomizerapi
 import "fmt"
 
 type Config struct {
-	Storage Storage `yaml:"storage"`
-	Iso     *Iso    `yaml:"iso"`
-	Pxe     *Pxe    `yaml:"pxe"`
-	OS      *OS     `yaml:"os"`
-	Scripts Scripts `yaml:"scripts"`
+	Preset              Preset               `yaml:"preset"`
+	Storage             Storage              `yaml:"storage"`
+	Iso                 *Iso                 `yaml:"iso"`
+	Pxe                 *Pxe                 `yaml:"pxe"`
+	Oci                 *Oci                 `yaml:"oci"`
+	Wsl                 *Wsl                 `yaml:"wsl"`
+	AzureComputeGallery *AzureComputeGallery `yaml:"azureComputeGallery"`
+	VulnerabilityScan   *VulnerabilityScan   `yaml:"vulnerabilityScan"`
+	PcrPolicy           *PcrPolicy           `yaml:"pcrPolicy"`
+	OS                  *OS                  `yaml:"os"`
+	Scripts             Scripts              `yaml:"scripts"`
 }
 
 func (c *Config) IsValid() (err error) {
+	err = c.Preset.IsValid()
+	if err != nil {
+		return err
+	}
+
 	err = c.Storage.IsValid()
 	if err != nil {
 		return err
@@ -35,6 +46,41 @@ func (c *Config) IsValid() (err error) {
 		}
 	}
 
+	if c.Oci != nil {
+		err = c.Oci.IsValid()
+		if err != nil {
+			return fmt.Errorf("invalid 'oci' field:\n%w", err)
+		}
+	}
+
+	if c.Wsl != nil {
+		err = c.Wsl.IsValid()
+		if err != nil {
+			return fmt.Errorf("invalid 'wsl' field:\n%w", err)
+		}
+	}
+
+	if c.AzureComputeGallery != nil {
+		err = c.AzureComputeGallery.IsValid()
+		if err != nil {
+			return fmt.Errorf("invalid 'azureComputeGallery' field:\n%w", err)
+		}
+	}
+
+	if c.VulnerabilityScan != nil {
+		err = c.VulnerabilityScan.IsValid()
+		if err != nil {
+			return fmt.Errorf("invalid 'vulnerabilityScan' field:\n%w", err)
+		}
+	}
+
+	if c.PcrPolicy != nil {
+		err = c.PcrPolicy.IsValid()
+		if err != nil {
+			return fmt.Errorf("invalid 'pcrPolicy' field:\n%w", err)
+		}
+	}
+
 	hasResetBootLoader := false
 	if c.OS != nil {
 		err = c.OS.IsValid()
@@ -103,6 +103,11 @@ func (d *Disk) IsValid() error {
 		}
 	}
 
+	err = d.validateRepart()
+	if err != nil {
+		return err
+	}
+
 	if d.MaxSize == nil && len(d.Partitions) <= 0 {
 		return fmt.Errorf("either disk must specify maxSize or last partition must have an end or size value")
 	}
@@ -150,6 +155,30 @@ func (d *Disk) IsValid() error {
 	return nil
 }
 
+// HasRepart returns true if systemd-repart is enabled for any of the disk's partitions.
+func (d *Disk) HasRepart() bool {
+	for _, partition := range d.Partitions {
+		if partition.Repart != nil && partition.Repart.Grow {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *Disk) validateRepart() error {
+	// systemd-repart only grows partitions into the free space that follows them. So, only the last partition can
+	// grow. This also ensures the size of every partition that isn't allowed to grow is pinned, since only the last
+	// partition may omit its end.
+	for i, partition := range d.Partitions {
+		if partition.Repart != nil && partition.Repart.Grow && i != len(d.Partitions)-1 {
+			return fmt.Errorf("partition (%s) cannot grow since it is not the last partition on the disk",
+				partition.Id)
+		}
+	}
+
+	return nil
+}
+
 func roundUp(size uint64, alignment uint64) uint64 {
 	div := size / alignment
 	mod := size % alignment
@@ -352,3 +352,53 @@ func TestDiskIsValidOmitStartNoEnd(t *testing.T) {
 	err := disk.IsValid()
 	assert.ErrorContains(t, err, "partition (b) omitted start value but previous partition (a) has no size or end value")
 }
+
+func TestDiskIsValidRepart(t *testing.T) {
+	disk := &Disk{
+		PartitionTableType: PartitionTableTypeGpt,
+		MaxSize:            ptrutils.PtrTo(DiskSize(4 * diskutils.MiB)),
+		Partitions: []Partition{
+			{
+				Id:    "a",
+				Start: ptrutils.PtrTo(DiskSize(1 * diskutils.MiB)),
+				End:   ptrutils.PtrTo(DiskSize(2 * diskutils.MiB)),
+			},
+			{
+				Id:    "b",
+				Start: ptrutils.PtrTo(DiskSize(2 * diskutils.MiB)),
+				Repart: &PartitionRepart{
+					Grow: true,
+				},
+			},
+		},
+	}
+
+	err := disk.IsValid()
+	assert.NoError(t, err)
+	assert.True(t, disk.HasRepart())
+}
+
+func TestDiskIsValidRepartGrowNotLast(t *testing.T) {
+	disk := &Disk{
+		PartitionTableType: PartitionTableTypeGpt,
+		MaxSize:            ptrutils.PtrTo(DiskSize(4 * diskutils.MiB)),
+		Partitions: []Partition{
+			{
+				Id:    "a",
+				Start: ptrutils.PtrTo(DiskSize(1 * diskutils.MiB)),
+				End:   ptrutils.PtrTo(DiskSize(2 * diskutils.MiB)),
+				Repart: &PartitionRepart{
+					Grow: true,
+				},
+			},
+			{
+				Id:    "b",
+				Start: ptrutils.PtrTo(DiskSize(2 * diskutils.MiB)),
+				End:   ptrutils.PtrTo(DiskSize(3 * diskutils.MiB)),
+			},
+		},
+	}
+
+	err := disk.IsValid()
+	assert.ErrorContains(t, err, "partition (a) cannot grow since it is not the last partition on the disk")
+}
@@ -5,12 +5,44 @@ package imagecustomizerapi
 
 import (
 	"fmt"
+	"path/filepath"
 )
 
 // Iso defines how the generated iso media should be configured.
 type Iso struct {
 	KernelCommandLine KernelCommandLine  `yaml:"kernelCommandLine"`
 	AdditionalFiles   AdditionalFileList `yaml:"additionalFiles"`
+	// Additional rootfs images (e.g. a minimal recovery OS) to place next to the rootfs image of the customized OS.
+	AdditionalRootfsImages IsoRootfsImageList `yaml:"additionalRootfsImages"`
+	// Places two OS image slots on the iso for A/B updates.
+	ABUpdate *IsoABUpdate `yaml:"abUpdate"`
+	// Implants a checksum into the iso and adds a grub menu entry that verifies the media before booting.
+	MediaCheck bool `yaml:"mediaCheck"`
+	// The directory (on the iso media) that holds the rootfs images.
+	// Defaults to IsoDefaultLiveOSDir.
+	LiveOSDir string `yaml:"liveOSDir"`
+	// The file name of the rootfs image of the customized OS.
+	// Defaults to IsoDefaultRootfsImageName.
+	LiveOSImageName string `yaml:"liveOSImageName"`
+	// Makes builds from the same inputs generate byte-identical isos, by using the SOURCE_DATE_EPOCH environment
+	// variable instead of the current time.
+	Reproducible bool `yaml:"reproducible"`
+	// The maximum size of the iso image (e.g. the capacity of the target media).
+	MaxSize *DiskSize `yaml:"maxSize"`
+	// The version (or glob pattern) of the kernel that boots the iso, when more than one kernel is installed.
+	KernelVersion string `yaml:"kernelVersion"`
+	// Files from the OS to place on the EFI system partition of the iso media, in addition to (or instead of) the
+	// shim and grub binaries of the OS.
+	BootloaderFiles IsoBootloaderFileList `yaml:"bootloaderFiles"`
+	// The chain of UEFI bootloaders that boots the iso.
+	// Defaults to IsoBootBackendShimGrub.
+	BootBackend IsoBootBackend `yaml:"bootBackend"`
+	// The release version and the tag of the iso. Added to the iso's file name and to the OS's release file.
+	ReleaseVersion string `yaml:"releaseVersion"`
+	Tag            string `yaml:"tag"`
+	// Decides how the files of the input iso are merged with the files generated for the new iso. The first rule that
+	// matches a file is used. Files that don't match any of the rules are replaced.
+	CarryOverRules IsoCarryOverRuleList `yaml:"carryOverRules"`
 }
 
 func (i *Iso) IsValid() error {
@@ -24,5 +56,96 @@ func (i *Iso) IsValid() error {
 		return fmt.Errorf("invalid additionalFiles:\n%w", err)
 	}
 
+	if i.LiveOSDir != "" {
+		err = isValidIsoLiveOSDir(i.LiveOSDir)
+		if err != nil {
+			return fmt.Errorf("invalid liveOSDir:\n%w", err)
+		}
+	}
+
+	liveOSImageName := IsoDefaultRootfsImageName
+	if i.LiveOSImageName != "" {
+		err = isValidIsoLiveOSFileName(i.LiveOSImageName)
+		if err != nil {
+			return fmt.Errorf("invalid liveOSImageName:\n%w", err)
+		}
+		liveOSImageName = i.LiveOSImageName
+	}
+
+	if i.MaxSize != nil && *i.MaxSize <= 0 {
+		return fmt.Errorf("invalid maxSize value (%d):\nmust be a positive non-zero number", *i.MaxSize)
+	}
+
+	if i.KernelVersion != "" {
+		_, err = filepath.Match(i.KernelVersion, "")
+		if err != nil {
+			return fmt.Errorf("invalid kernelVersion value (%s):\n%w", i.KernelVersion, err)
+		}
+	}
+
+	err = i.BootloaderFiles.IsValid()
+	if err != nil {
+		return fmt.Errorf("invalid bootloaderFiles:\n%w", err)
+	}
+
+	if i.ReleaseVersion != "" {
+		err = IsValidIsoImageNameElement(i.ReleaseVersion)
+		if err != nil {
+			return fmt.Errorf("invalid releaseVersion:\n%w", err)
+		}
+	}
+
+	if i.Tag != "" {
+		err = IsValidIsoImageNameElement(i.Tag)
+		if err != nil {
+			return fmt.Errorf("invalid tag:\n%w", err)
+		}
+	}
+
+	err = i.BootBackend.IsValid()
+	if err != nil {
+		return err
+	}
+
+	if i.BootBackend == IsoBootBackendCustom && !i.BootloaderFiles.hasFirstStageBootloader() {
+		return fmt.Errorf("bootBackend (%s) requires a bootloaderFiles entry with the destination (%s)",
+			IsoBootBackendCustom, IsoFirstStageBootloaderPath)
+	}
+
+	err = i.CarryOverRules.IsValid()
+	if err != nil {
+		return fmt.Errorf("invalid carryOverRules:\n%w", err)
+	}
+
+	err = i.AdditionalRootfsImages.IsValid()
+	if err != nil {
+		return fmt.Errorf("invalid additionalRootfsImages:\n%w", err)
+	}
+
+	for _, rootfsImage := range i.AdditionalRootfsImages {
+		if rootfsImage.Name == liveOSImageName {
+			return fmt.Errorf("additionalRootfsImages name (%s) is reserved for the rootfs image of the customized OS",
+				rootfsImage.Name)
+		}
+	}
+
+	if i.ABUpdate != nil {
+		err = i.ABUpdate.IsValid()
+		if err != nil {
+			return fmt.Errorf("invalid abUpdate:\n%w", err)
+		}
+
+		if liveOSImageName == IsoSlotBRootfsImageName {
+			return fmt.Errorf("liveOSImageName (%s) is reserved for slot B when abUpdate is specified", liveOSImageName)
+		}
+
+		for _, rootfsImage := range i.AdditionalRootfsImages {
+			if rootfsImage.Name == IsoSlotBRootfsImageName {
+				return fmt.Errorf("additionalRootfsImages name (%s) is reserved for slot B when abUpdate is specified",
+					rootfsImage.Name)
+			}
+		}
+	}
+
 	return nil
 }
@@ -6,6 +6,8 @@ package imagecustomizerapi
 import (
 	"testing"
 
+	"github.com/microsoft/azurelinux/toolkit/tools/imagegen/diskutils"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/ptrutils"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -19,3 +21,323 @@ func TestIsoIsValid(t *testing.T) {
 	err := iso.IsValid()
 	assert.ErrorContains(t, err, "invalid kernelCommandLine")
 }
+
+func TestIsoIsValidAdditionalRootfsImages(t *testing.T) {
+	iso := Iso{
+		AdditionalRootfsImages: IsoRootfsImageList{
+			{
+				Name:   "recovery.img",
+				Source: "recovery.img",
+				Title:  "Recovery",
+			},
+		},
+	}
+
+	err := iso.IsValid()
+	assert.NoError(t, err)
+}
+
+func TestIsoIsValidAdditionalRootfsImagesDuplicateName(t *testing.T) {
+	iso := Iso{
+		AdditionalRootfsImages: IsoRootfsImageList{
+			{
+				Name:   "recovery.img",
+				Source: "recovery-a.img",
+				Title:  "Recovery A",
+			},
+			{
+				Name:   "recovery.img",
+				Source: "recovery-b.img",
+				Title:  "Recovery B",
+			},
+		},
+	}
+
+	err := iso.IsValid()
+	assert.ErrorContains(t, err, "invalid additionalRootfsImages")
+	assert.ErrorContains(t, err, "duplicate name (recovery.img) at index 1")
+}
+
+func TestIsoIsValidAdditionalRootfsImagesReservedName(t *testing.T) {
+	iso := Iso{
+		AdditionalRootfsImages: IsoRootfsImageList{
+			{
+				Name:   "rootfs.img",
+				Source: "recovery.img",
+				Title:  "Recovery",
+			},
+		},
+	}
+
+	err := iso.IsValid()
+	assert.ErrorContains(t, err, "name (rootfs.img) is reserved")
+
+	// The default name is not reserved when the image of the customized OS is renamed.
+	iso.LiveOSImageName = "main.img"
+	err = iso.IsValid()
+	assert.NoError(t, err)
+
+	iso.AdditionalRootfsImages[0].Name = "main.img"
+	err = iso.IsValid()
+	assert.ErrorContains(t, err, "name (main.img) is reserved")
+}
+
+func TestIsoRootfsImageIsValidPathName(t *testing.T) {
+	image := IsoRootfsImage{
+		Name:   "images/recovery.img",
+		Source: "recovery.img",
+		Title:  "Recovery",
+	}
+
+	err := image.IsValid()
+	assert.ErrorContains(t, err, "must be a file name")
+
+	image.Name = "recovery image.img"
+	err = image.IsValid()
+	assert.ErrorContains(t, err, "must be a file name")
+}
+
+func TestIsoRootfsImageIsValidMissingSource(t *testing.T) {
+	image := IsoRootfsImage{
+		Name:  "recovery.img",
+		Title: "Recovery",
+	}
+
+	err := image.IsValid()
+	assert.ErrorContains(t, err, "source must not be empty")
+}
+
+func TestIsoRootfsImageIsValidBadKernelCommandLine(t *testing.T) {
+	image := IsoRootfsImage{
+		Name:   "recovery.img",
+		Source: "recovery.img",
+		Title:  "Recovery",
+		KernelCommandLine: KernelCommandLine{
+			ExtraCommandLine: "'",
+		},
+	}
+
+	err := image.IsValid()
+	assert.ErrorContains(t, err, "invalid kernelCommandLine")
+}
+
+func TestIsoIsValidABUpdateSlotBNameConflict(t *testing.T) {
+	iso := Iso{
+		AdditionalRootfsImages: IsoRootfsImageList{
+			{
+				Name:   "rootfs-b.img",
+				Source: "recovery.img",
+				Title:  "Recovery",
+			},
+		},
+		ABUpdate: &IsoABUpdate{},
+	}
+
+	err := iso.IsValid()
+	assert.ErrorContains(t, err, "name (rootfs-b.img) is reserved for slot B")
+}
+
+func TestIsoIsValidLiveOSDir(t *testing.T) {
+	iso := Iso{
+		LiveOSDir:       "images/live",
+		LiveOSImageName: "os.squashfs",
+	}
+
+	err := iso.IsValid()
+	assert.NoError(t, err)
+
+	iso.LiveOSDir = "/images"
+	err = iso.IsValid()
+	assert.ErrorContains(t, err, "invalid liveOSDir")
+
+	iso.LiveOSDir = "images/../live"
+	err = iso.IsValid()
+	assert.ErrorContains(t, err, "invalid liveOSDir")
+}
+
+func TestIsoIsValidLiveOSImageName(t *testing.T) {
+	iso := Iso{
+		LiveOSImageName: "live/os.img",
+	}
+
+	err := iso.IsValid()
+	assert.ErrorContains(t, err, "invalid liveOSImageName")
+
+	iso.LiveOSImageName = "rootfs-b.img"
+	iso.ABUpdate = &IsoABUpdate{}
+	err = iso.IsValid()
+	assert.ErrorContains(t, err, "liveOSImageName (rootfs-b.img) is reserved for slot B")
+}
+
+func TestIsoIsValidABUpdateSlotBSource(t *testing.T) {
+	iso := Iso{
+		ABUpdate: &IsoABUpdate{
+			SlotBSource: "images/slot-b.img",
+		},
+	}
+
+	err := iso.IsValid()
+	assert.NoError(t, err)
+
+	iso.ABUpdate.SlotBSource = "  "
+	err = iso.IsValid()
+	assert.ErrorContains(t, err, "slotBSource must not be blank")
+
+	iso.ABUpdate.SlotBSource = "images/"
+	err = iso.IsValid()
+	assert.ErrorContains(t, err, "slotBSource (images/) must be a file path")
+}
+
+func TestIsoIsValidMaxSize(t *testing.T) {
+	iso := Iso{
+		MaxSize: ptrutils.PtrTo(DiskSize(4 * diskutils.GiB)),
+	}
+
+	err := iso.IsValid()
+	assert.NoError(t, err)
+}
+
+func TestIsoIsValidMaxSizeZero(t *testing.T) {
+	iso := Iso{
+		MaxSize: ptrutils.PtrTo(DiskSize(0)),
+	}
+
+	err := iso.IsValid()
+	assert.ErrorContains(t, err, "invalid maxSize value (0)")
+}
+
+func TestIsoIsValidKernelVersion(t *testing.T) {
+	iso := Iso{
+		KernelVersion: "6.6.*-hci",
+	}
+
+	err := iso.IsValid()
+	assert.NoError(t, err)
+}
+
+func TestIsoIsValidKernelVersionBadPattern(t *testing.T) {
+	iso := Iso{
+		KernelVersion: "6.6.[",
+	}
+
+	err := iso.IsValid()
+	assert.ErrorContains(t, err, "invalid kernelVersion value (6.6.[)")
+}
+
+func TestIsoIsValidBootloaderFiles(t *testing.T) {
+	iso := Iso{
+		BootloaderFiles: IsoBootloaderFileList{
+			{
+				Source:      "/usr/share/vendor/shimx64.efi",
+				Destination: "EFI/BOOT/bootx64.efi",
+			},
+			{
+				Source:      "/usr/share/efi-drivers/ntfs_x64.efi",
+				Destination: "EFI/BOOT/drivers/ntfs_x64.efi",
+			},
+		},
+	}
+
+	err := iso.IsValid()
+	assert.NoError(t, err)
+}
+
+func TestIsoIsValidBootloaderFilesRelativeSource(t *testing.T) {
+	iso := Iso{
+		BootloaderFiles: IsoBootloaderFileList{
+			{
+				Source:      "shimx64.efi",
+				Destination: "EFI/BOOT/bootx64.efi",
+			},
+		},
+	}
+
+	err := iso.IsValid()
+	assert.ErrorContains(t, err, "invalid bootloaderFiles")
+	assert.ErrorContains(t, err, "source (shimx64.efi) must be an absolute path")
+}
+
+func TestIsoIsValidBootloaderFilesBadDestination(t *testing.T) {
+	iso := Iso{
+		BootloaderFiles: IsoBootloaderFileList{
+			{
+				Source:      "/usr/share/vendor/shimx64.efi",
+				Destination: "../bootx64.efi",
+			},
+		},
+	}
+
+	err := iso.IsValid()
+	assert.ErrorContains(t, err, "invalid destination")
+}
+
+func TestIsoIsValidBootloaderFilesDuplicateDestination(t *testing.T) {
+	iso := Iso{
+		BootloaderFiles: IsoBootloaderFileList{
+			{
+				Source:      "/usr/share/vendor/shimx64.efi",
+				Destination: "EFI/BOOT/bootx64.efi",
+			},
+			{
+				Source:      "/usr/share/other/shimx64.efi",
+				Destination: "efi/boot/BOOTX64.EFI",
+			},
+		},
+	}
+
+	err := iso.IsValid()
+	assert.ErrorContains(t, err, "duplicate destination (efi/boot/BOOTX64.EFI) at index 1")
+}
+
+func TestIsoIsValidBootBackendCustom(t *testing.T) {
+	iso := Iso{
+		BootBackend: IsoBootBackendCustom,
+		BootloaderFiles: IsoBootloaderFileList{
+			{
+				Source:      "/usr/lib/systemd/boot/efi/systemd-bootx64.efi",
+				Destination: "EFI/BOOT/BOOTX64.EFI",
+			},
+		},
+	}
+
+	err := iso.IsValid()
+	assert.NoError(t, err)
+}
+
+func TestIsoIsValidBootBackendCustomMissingFirstStage(t *testing.T) {
+	iso := Iso{
+		BootBackend: IsoBootBackendCustom,
+	}
+
+	err := iso.IsValid()
+	assert.ErrorContains(t, err, "bootBackend (custom) requires a bootloaderFiles entry with the destination "+
+		"(EFI/BOOT/bootx64.efi)")
+}
+
+func TestIsoIsValidReleaseVersionAndTag(t *testing.T) {
+	iso := Iso{
+		ReleaseVersion: "3.0.20240601",
+		Tag:            "livecd",
+	}
+
+	err := iso.IsValid()
+	assert.NoError(t, err)
+}
+
+func TestIsoIsValidBadReleaseVersion(t *testing.T) {
+	iso := Iso{
+		ReleaseVersion: "3.0/20240601",
+	}
+
+	err := iso.IsValid()
+	assert.ErrorContains(t, err, "invalid releaseVersion")
+}
+
+func TestIsoIsValidBadTag(t *testing.T) {
+	iso := Iso{
+		Tag: "live cd",
+	}
+
+	err := iso.IsValid()
+	assert.ErrorContains(t, err, "invalid tag")
+}
@@ -0,0 +1,33 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerapi
+
+import (
+	"fmt"
+	"strings"
+)
+
+// The name of the rootfs image of slot B of an A/B update iso.
+// Slot A uses the rootfs image of the customized OS.
+const IsoSlotBRootfsImageName = "rootfs-b.img"
+
+// IsoABUpdate configures the iso to carry two OS image slots (A and B) so that field updates can flash one slot
+// while running from the other.
+type IsoABUpdate struct {
+	// The path of a pre-built squashfs image to place in slot B.
+	// If not specified, slot B starts as a copy of slot A (i.e. the customized OS).
+	SlotBSource string `yaml:"slotBSource"`
+}
+
+func (u *IsoABUpdate) IsValid() error {
+	if u.SlotBSource != "" && strings.TrimSpace(u.SlotBSource) == "" {
+		return fmt.Errorf("slotBSource must not be blank")
+	}
+
+	if strings.HasSuffix(u.SlotBSource, "/") {
+		return fmt.Errorf("slotBSource (%s) must be a file path", u.SlotBSource)
+	}
+
+	return nil
+}
@@ -0,0 +1,35 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerapi
+
+import (
+	"fmt"
+)
+
+// IsoBootBackend is the chain of UEFI bootloaders that boots the iso. It decides which of the bootloader files are
+// required.
+type IsoBootBackend string
+
+const (
+	// IsoBootBackendDefault is the same as IsoBootBackendShimGrub.
+	IsoBootBackendDefault IsoBootBackend = ""
+	// IsoBootBackendShimGrub boots the shim, which boots grub. Both are required.
+	IsoBootBackendShimGrub IsoBootBackend = "shim-grub"
+	// IsoBootBackendGrub boots grub directly if the shim is missing. Secure boot is not supported without the shim.
+	IsoBootBackendGrub IsoBootBackend = "grub"
+	// IsoBootBackendCustom boots a first stage bootloader provided by iso.bootloaderFiles (e.g. systemd-boot). The
+	// grub binary is optional.
+	IsoBootBackendCustom IsoBootBackend = "custom"
+)
+
+func (b IsoBootBackend) IsValid() error {
+	switch b {
+	case IsoBootBackendDefault, IsoBootBackendShimGrub, IsoBootBackendGrub, IsoBootBackendCustom:
+		// All good.
+		return nil
+
+	default:
+		return fmt.Errorf("invalid bootBackend value (%v)", b)
+	}
+}
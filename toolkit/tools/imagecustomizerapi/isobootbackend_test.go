@@ -0,0 +1,20 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerapi
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsoBootBackendIsValid(t *testing.T) {
+	err := IsoBootBackendGrub.IsValid()
+	assert.NoError(t, err)
+}
+
+func TestIsoBootBackendIsValidBadValue(t *testing.T) {
+	err := IsoBootBackend("sd-boot").IsValid()
+	assert.ErrorContains(t, err, "invalid bootBackend value (sd-boot)")
+}
@@ -0,0 +1,78 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerapi
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// The path of the first stage bootloader on the EFI system partition. It is the file the UEFI firmware boots.
+	IsoFirstStageBootloaderPath = "EFI/BOOT/bootx64.efi"
+)
+
+type IsoBootloaderFileList []IsoBootloaderFile
+
+// IsoBootloaderFile places a file from the OS on the EFI system partition (efiboot.img) of the iso media. For example,
+// a custom-signed shim, a vendor grub build, or an extra EFI driver.
+type IsoBootloaderFile struct {
+	// The absolute path of the file in the OS.
+	Source string `yaml:"source"`
+
+	// The path of the file on the EFI system partition (e.g. EFI/BOOT/drivers/ntfs_x64.efi).
+	Destination string `yaml:"destination"`
+}
+
+func (l IsoBootloaderFileList) IsValid() error {
+	destinations := make(map[string]bool)
+	for i, bootloaderFile := range l {
+		err := bootloaderFile.IsValid()
+		if err != nil {
+			return fmt.Errorf("invalid value at index %d:\n%w", i, err)
+		}
+
+		// The EFI system partition is a FAT file system. So, file names are case insensitive.
+		destination := strings.ToLower(bootloaderFile.Destination)
+		if destinations[destination] {
+			return fmt.Errorf("duplicate destination (%s) at index %d", bootloaderFile.Destination, i)
+		}
+		destinations[destination] = true
+	}
+
+	return nil
+}
+
+func (f *IsoBootloaderFile) IsValid() error {
+	if f.Source == "" {
+		return fmt.Errorf("source must not be empty")
+	}
+
+	if !filepath.IsAbs(f.Source) {
+		return fmt.Errorf("source (%s) must be an absolute path", f.Source)
+	}
+
+	if f.Destination == "" {
+		return fmt.Errorf("destination must not be empty")
+	}
+
+	err := isValidIsoLiveOSDir(f.Destination)
+	if err != nil {
+		return fmt.Errorf("invalid destination:\n%w", err)
+	}
+
+	return nil
+}
+
+// hasFirstStageBootloader returns true if one of the files replaces the first stage bootloader.
+func (l IsoBootloaderFileList) hasFirstStageBootloader() bool {
+	for _, bootloaderFile := range l {
+		if strings.EqualFold(strings.TrimPrefix(bootloaderFile.Destination, "/"), IsoFirstStageBootloaderPath) {
+			return true
+		}
+	}
+
+	return false
+}
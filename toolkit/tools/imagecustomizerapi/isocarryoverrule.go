@@ -0,0 +1,92 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerapi
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// IsoCarryOverAction decides how a file of the input iso is merged with the files generated for the new iso.
+type IsoCarryOverAction string
+
+const (
+	// IsoCarryOverActionDefault is the same as IsoCarryOverActionReplace.
+	IsoCarryOverActionDefault IsoCarryOverAction = ""
+	// IsoCarryOverActionKeep carries the file over, even if a file with the same path was generated for the new iso.
+	IsoCarryOverActionKeep IsoCarryOverAction = "keep"
+	// IsoCarryOverActionReplace carries the file over, unless a file with the same path was generated for the new iso.
+	IsoCarryOverActionReplace IsoCarryOverAction = "replace"
+	// IsoCarryOverActionDelete doesn't carry the file over.
+	IsoCarryOverActionDelete IsoCarryOverAction = "delete"
+)
+
+func (a IsoCarryOverAction) IsValid() error {
+	switch a {
+	case IsoCarryOverActionDefault, IsoCarryOverActionKeep, IsoCarryOverActionReplace, IsoCarryOverActionDelete:
+		// All good.
+		return nil
+
+	default:
+		return fmt.Errorf("invalid action value (%v)", a)
+	}
+}
+
+type IsoCarryOverRuleList []IsoCarryOverRule
+
+// IsoCarryOverRule decides how the files of the input iso that match a glob pattern are merged with the files
+// generated for the new iso.
+type IsoCarryOverRule struct {
+	// The glob pattern of the files' paths on the iso media (e.g. /docs/*.pdf). A pattern that matches a directory
+	// matches all of the files under the directory.
+	Path   string             `yaml:"path"`
+	Action IsoCarryOverAction `yaml:"action"`
+}
+
+func (l IsoCarryOverRuleList) IsValid() error {
+	for i, rule := range l {
+		err := rule.IsValid()
+		if err != nil {
+			return fmt.Errorf("invalid value at index %d:\n%w", i, err)
+		}
+	}
+
+	return nil
+}
+
+func (r *IsoCarryOverRule) IsValid() error {
+	if !strings.HasPrefix(r.Path, "/") {
+		return fmt.Errorf("path (%s) must be an absolute path", r.Path)
+	}
+
+	_, err := filepath.Match(r.Path, "")
+	if err != nil {
+		return fmt.Errorf("invalid path value (%s):\n%w", r.Path, err)
+	}
+
+	err = r.Action.IsValid()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Match returns the first rule that matches the path of a file on the iso media, or nil if none of the rules match.
+func (l IsoCarryOverRuleList) Match(mediaPath string) *IsoCarryOverRule {
+	for i := range l {
+		rule := &l[i]
+
+		// Match the file's path and the paths of its parent directories.
+		for path := mediaPath; path != "/" && path != "."; path = filepath.Dir(path) {
+			matched, _ := filepath.Match(rule.Path, path)
+			if matched {
+				return rule
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,60 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerapi
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsoCarryOverRuleListIsValid(t *testing.T) {
+	rules := IsoCarryOverRuleList{
+		{Path: "/docs", Action: IsoCarryOverActionKeep},
+		{Path: "/*.txt", Action: IsoCarryOverActionDelete},
+		{Path: "/config/*"},
+	}
+	err := rules.IsValid()
+	assert.NoError(t, err)
+}
+
+func TestIsoCarryOverRuleListIsValidRelativePath(t *testing.T) {
+	rules := IsoCarryOverRuleList{
+		{Path: "/docs", Action: IsoCarryOverActionKeep},
+		{Path: "docs/*.txt", Action: IsoCarryOverActionDelete},
+	}
+	err := rules.IsValid()
+	assert.ErrorContains(t, err, "invalid value at index 1")
+	assert.ErrorContains(t, err, "path (docs/*.txt) must be an absolute path")
+}
+
+func TestIsoCarryOverRuleListIsValidBadPattern(t *testing.T) {
+	rules := IsoCarryOverRuleList{
+		{Path: "/docs/[a-", Action: IsoCarryOverActionKeep},
+	}
+	err := rules.IsValid()
+	assert.ErrorContains(t, err, "invalid path value (/docs/[a-)")
+}
+
+func TestIsoCarryOverRuleListIsValidBadAction(t *testing.T) {
+	rules := IsoCarryOverRuleList{
+		{Path: "/docs", Action: "merge"},
+	}
+	err := rules.IsValid()
+	assert.ErrorContains(t, err, "invalid action value (merge)")
+}
+
+func TestIsoCarryOverRuleListMatch(t *testing.T) {
+	rules := IsoCarryOverRuleList{
+		{Path: "/docs/draft-*", Action: IsoCarryOverActionDelete},
+		{Path: "/docs", Action: IsoCarryOverActionKeep},
+		{Path: "/*.txt", Action: IsoCarryOverActionReplace},
+	}
+
+	assert.Equal(t, &rules[0], rules.Match("/docs/draft-1.pdf"))
+	assert.Equal(t, &rules[0], rules.Match("/docs/draft-2/index.html"))
+	assert.Equal(t, &rules[1], rules.Match("/docs/guide.pdf"))
+	assert.Equal(t, &rules[2], rules.Match("/readme.txt"))
+	assert.Nil(t, rules.Match("/config/readme.txt"))
+}
@@ -0,0 +1,108 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerapi
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	// The default directory (on the iso media) that holds the rootfs images.
+	IsoDefaultLiveOSDir = "liveos"
+	// The default name of the rootfs image generated from the customized OS.
+	IsoDefaultRootfsImageName = "rootfs.img"
+)
+
+var (
+	// The names end up in kernel command-line args (rd.live.dir and rd.live.squashimg). So, they are restricted to
+	// characters that don't require quoting.
+	isoLiveOSFileNameRegex = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+)
+
+type IsoRootfsImageList []IsoRootfsImage
+
+// IsoRootfsImage defines an additional (pre-built) squashfs rootfs image to place on the iso media, along with a
+// grub menu entry that boots into it.
+type IsoRootfsImage struct {
+	// The file name of the image on the iso media.
+	Name string `yaml:"name"`
+
+	// The path of the squashfs image file on the build machine.
+	Source string `yaml:"source"`
+
+	// The title of the grub menu entry that boots into the image.
+	Title string `yaml:"title"`
+
+	// Kernel command line args to add to the grub menu entry.
+	KernelCommandLine KernelCommandLine `yaml:"kernelCommandLine"`
+}
+
+func (l IsoRootfsImageList) IsValid() error {
+	names := make(map[string]bool)
+	for i, image := range l {
+		err := image.IsValid()
+		if err != nil {
+			return fmt.Errorf("invalid value at index %d:\n%w", i, err)
+		}
+
+		if names[image.Name] {
+			return fmt.Errorf("duplicate name (%s) at index %d", image.Name, i)
+		}
+		names[image.Name] = true
+	}
+
+	return nil
+}
+
+func (i *IsoRootfsImage) IsValid() error {
+	if i.Name == "" {
+		return fmt.Errorf("name must not be empty")
+	}
+
+	err := isValidIsoLiveOSFileName(i.Name)
+	if err != nil {
+		return fmt.Errorf("invalid name:\n%w", err)
+	}
+
+	if i.Source == "" {
+		return fmt.Errorf("source must not be empty")
+	}
+
+	if i.Title == "" {
+		return fmt.Errorf("title must not be empty")
+	}
+
+	err = i.KernelCommandLine.IsValid()
+	if err != nil {
+		return fmt.Errorf("invalid kernelCommandLine: %w", err)
+	}
+
+	return nil
+}
+
+func isValidIsoLiveOSFileName(name string) error {
+	if name == "." || name == ".." || !isoLiveOSFileNameRegex.MatchString(name) {
+		return fmt.Errorf("(%s) must be a file name made of only letters, digits, '.', '_' and '-'", name)
+	}
+
+	return nil
+}
+
+// IsValidIsoImageNameElement checks that a value (e.g. a release version) can be added to the file name of the iso.
+func IsValidIsoImageNameElement(value string) error {
+	return isValidIsoLiveOSFileName(value)
+}
+
+func isValidIsoLiveOSDir(dir string) error {
+	for _, element := range strings.Split(dir, "/") {
+		err := isValidIsoLiveOSFileName(element)
+		if err != nil {
+			return fmt.Errorf("invalid path element in (%s):\n%w", dir, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,59 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerapi
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	// The grammar of an OCI image tag.
+	// See: https://github.com/opencontainers/distribution-spec/blob/main/spec.md#pulling-manifests
+	ociTagRegex = regexp.MustCompile(`^[a-zA-Z0-9_][a-zA-Z0-9._-]{0,127}$`)
+)
+
+// Oci configures the OCI container image that is created when the output image format is 'oci'.
+type Oci struct {
+	// The tag of the image in the OCI archive.
+	Tag string `yaml:"tag"`
+	// The labels of the image.
+	Labels map[string]string `yaml:"labels"`
+	// The default command of a container of the image.
+	Cmd []string `yaml:"cmd"`
+	// Whether the image is a bootable container image (i.e. a bootc image).
+	Bootc bool `yaml:"bootc"`
+	// The registry reference (e.g. registry.example.com/azurelinux/core:3.0) to push the image to.
+	PushReference string `yaml:"pushReference"`
+}
+
+func (o *Oci) IsValid() error {
+	if o.Tag != "" && !ociTagRegex.MatchString(o.Tag) {
+		return fmt.Errorf("invalid tag value (%s):\nmust be a valid OCI image tag", o.Tag)
+	}
+
+	for name := range o.Labels {
+		if name == "" {
+			return fmt.Errorf("invalid labels value:\nlabel name must not be empty")
+		}
+	}
+
+	if len(o.Cmd) > 0 && o.Cmd[0] == "" {
+		return fmt.Errorf("invalid cmd value:\nexecutable must not be empty")
+	}
+
+	if o.PushReference != "" {
+		if strings.ContainsAny(o.PushReference, " \t\n") {
+			return fmt.Errorf("invalid pushReference value (%s):\nmust not contain whitespace", o.PushReference)
+		}
+
+		if strings.Contains(o.PushReference, "://") {
+			return fmt.Errorf("invalid pushReference value (%s):\nmust be a registry reference without a transport "+
+				"(e.g. registry.example.com/azurelinux/core:3.0)", o.PushReference)
+		}
+	}
+
+	return nil
+}
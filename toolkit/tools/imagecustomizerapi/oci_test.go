@@ -0,0 +1,70 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerapi
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOciIsValid(t *testing.T) {
+	oci := Oci{
+		Tag:           "3.0.20240601",
+		Labels:        map[string]string{"org.opencontainers.image.vendor": "contoso"},
+		Cmd:           []string{"/bin/bash", "-l"},
+		Bootc:         true,
+		PushReference: "registry.example.com/azurelinux/core:3.0",
+	}
+	err := oci.IsValid()
+	assert.NoError(t, err)
+}
+
+func TestOciIsValidEmpty(t *testing.T) {
+	oci := Oci{}
+	err := oci.IsValid()
+	assert.NoError(t, err)
+}
+
+func TestOciIsValidInvalidTag(t *testing.T) {
+	oci := Oci{
+		Tag: "-latest",
+	}
+	err := oci.IsValid()
+	assert.ErrorContains(t, err, "invalid tag value (-latest)")
+}
+
+func TestOciIsValidEmptyLabelName(t *testing.T) {
+	oci := Oci{
+		Labels: map[string]string{"": "value"},
+	}
+	err := oci.IsValid()
+	assert.ErrorContains(t, err, "label name must not be empty")
+}
+
+func TestOciIsValidEmptyCmd(t *testing.T) {
+	oci := Oci{
+		Cmd: []string{"", "-l"},
+	}
+	err := oci.IsValid()
+	assert.ErrorContains(t, err, "executable must not be empty")
+}
+
+func TestOciIsValidPushReferenceWithTransport(t *testing.T) {
+	oci := Oci{
+		PushReference: "docker://registry.example.com/azurelinux/core:3.0",
+	}
+	err := oci.IsValid()
+	assert.ErrorContains(t, err, "must be a registry reference without a transport")
+}
+
+func TestConfigIsValidInvalidOci(t *testing.T) {
+	config := Config{
+		Oci: &Oci{
+			Tag: "a:b",
+		},
+	}
+	err := config.IsValid()
+	assert.ErrorContains(t, err, "invalid 'oci' field")
+}
@@ -23,6 +23,7 @@ type OS struct {
 	Services            Services            `yaml:"services"`
 	Modules             []Module            `yaml:"modules"`
 	Overlays            *[]Overlay          `yaml:"overlays"`
+	SyncEsp             bool                `yaml:"syncEsp"`
 }
 
 func (s *OS) IsValid() error {
@@ -226,3 +226,7 @@ func TestOSIsValidInvalidKernelCommandLine(t *testing.T) {
 	err := os.IsValid()
 	assert.ErrorContains(t, err, "invalid kernelCommandLine")
 }
+
+func TestOSValidSyncEsp(t *testing.T) {
+	testValidYamlValue[*OS](t, "{ \"syncEsp\": true }", &OS{SyncEsp: true})
+}
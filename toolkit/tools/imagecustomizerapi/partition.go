@@ -6,6 +6,8 @@ package imagecustomizerapi
 import (
 	"fmt"
 	"unicode"
+
+	"github.com/google/uuid"
 )
 
 type Partition struct {
@@ -22,6 +24,12 @@ type Partition struct {
 	Size PartitionSize `yaml:"size"`
 	// Type specifies the type of partition the partition is.
 	Type PartitionType `yaml:"type"`
+	// TypeUuid is the GPT partition type GUID to assign to the partition.
+	TypeUuid string `yaml:"typeUuid"`
+	// Attributes is the list of GPT attribute flags to set on the partition.
+	Attributes []PartitionAttribute `yaml:"attributes"`
+	// Repart configures how systemd-repart handles the partition on first boot.
+	Repart *PartitionRepart `yaml:"repart"`
 }
 
 func (p *Partition) IsValid() error {
@@ -43,6 +51,37 @@ func (p *Partition) IsValid() error {
 		return err
 	}
 
+	if p.TypeUuid != "" {
+		if p.Type != PartitionTypeDefault {
+			return fmt.Errorf("cannot specify both type and typeUuid on partition (%s)", p.Id)
+		}
+
+		_, err = uuid.Parse(p.TypeUuid)
+		if err != nil {
+			return fmt.Errorf("invalid typeUuid value (%s) on partition (%s):\n%w", p.TypeUuid, p.Id, err)
+		}
+	}
+
+	attributesSet := make(map[PartitionAttribute]bool)
+	for _, attribute := range p.Attributes {
+		err = attribute.IsValid()
+		if err != nil {
+			return fmt.Errorf("invalid attributes on partition (%s):\n%w", p.Id, err)
+		}
+
+		if attributesSet[attribute] {
+			return fmt.Errorf("duplicate attribute (%s) on partition (%s)", attribute, p.Id)
+		}
+		attributesSet[attribute] = true
+	}
+
+	if p.Repart != nil {
+		err = p.Repart.IsValid()
+		if err != nil {
+			return fmt.Errorf("invalid repart on partition (%s):\n%w", p.Id, err)
+		}
+	}
+
 	return nil
 }
 
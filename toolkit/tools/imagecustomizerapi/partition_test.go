@@ -167,3 +167,80 @@ func TestPartitionIsValidBadType(t *testing.T) {
 	assert.Error(t, err)
 	assert.ErrorContains(t, err, "unknown partition type")
 }
+
+func TestPartitionIsValidTypeUuid(t *testing.T) {
+	partition := Partition{
+		Id:       "a",
+		Start:    ptrutils.PtrTo(DiskSize(0)),
+		TypeUuid: "4f68bce3-e8cd-4db1-96e7-fbcaf984b709",
+		Attributes: []PartitionAttribute{
+			PartitionAttributeGrowFileSystem,
+			PartitionAttributeReadOnly,
+		},
+	}
+
+	err := partition.IsValid()
+	assert.NoError(t, err)
+}
+
+func TestPartitionIsValidBadTypeUuid(t *testing.T) {
+	partition := Partition{
+		Id:       "a",
+		Start:    ptrutils.PtrTo(DiskSize(0)),
+		TypeUuid: "not-a-uuid",
+	}
+
+	err := partition.IsValid()
+	assert.ErrorContains(t, err, "invalid typeUuid value (not-a-uuid)")
+}
+
+func TestPartitionIsValidTypeAndTypeUuid(t *testing.T) {
+	partition := Partition{
+		Id:       "a",
+		Start:    ptrutils.PtrTo(DiskSize(0)),
+		Type:     PartitionTypeESP,
+		TypeUuid: "c12a7328-f81f-11d2-ba4b-00a0c93ec93b",
+	}
+
+	err := partition.IsValid()
+	assert.ErrorContains(t, err, "cannot specify both type and typeUuid")
+}
+
+func TestPartitionIsValidBadAttribute(t *testing.T) {
+	partition := Partition{
+		Id:         "a",
+		Start:      ptrutils.PtrTo(DiskSize(0)),
+		Attributes: []PartitionAttribute{"bad"},
+	}
+
+	err := partition.IsValid()
+	assert.ErrorContains(t, err, "invalid partition attribute value (bad)")
+}
+
+func TestPartitionIsValidDuplicateAttribute(t *testing.T) {
+	partition := Partition{
+		Id:    "a",
+		Start: ptrutils.PtrTo(DiskSize(0)),
+		Attributes: []PartitionAttribute{
+			PartitionAttributeReadOnly,
+			PartitionAttributeReadOnly,
+		},
+	}
+
+	err := partition.IsValid()
+	assert.ErrorContains(t, err, "duplicate attribute (read-only)")
+}
+
+func TestPartitionIsValidRepartMaxSizeWithoutGrow(t *testing.T) {
+	partition := Partition{
+		Id:    "a",
+		Start: ptrutils.PtrTo(DiskSize(0)),
+		Repart: &PartitionRepart{
+			MaxSize: ptrutils.PtrTo(DiskSize(1 * diskutils.GiB)),
+		},
+	}
+
+	err := partition.IsValid()
+	assert.ErrorContains(t, err, "invalid repart on partition (a)")
+	assert.ErrorContains(t, err, "cannot specify 'maxSize' unless 'grow' is enabled")
+}
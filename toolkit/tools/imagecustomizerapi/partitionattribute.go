@@ -0,0 +1,46 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerapi
+
+import (
+	"fmt"
+)
+
+// PartitionAttribute is a GPT partition attribute flag.
+//
+// See, https://uapi-group.org/specifications/specs/discoverable_partitions_specification/
+type PartitionAttribute string
+
+const (
+	// PartitionAttributeRequired indicates the partition is required for the platform to function.
+	PartitionAttributeRequired PartitionAttribute = "required"
+
+	// PartitionAttributeNoBlockIoProtocol indicates the firmware must not produce an EFI_BLOCK_IO_PROTOCOL device
+	// for the partition.
+	PartitionAttributeNoBlockIoProtocol PartitionAttribute = "no-block-io-protocol"
+
+	// PartitionAttributeLegacyBiosBootable indicates the partition may be bootable by legacy BIOS firmware.
+	PartitionAttributeLegacyBiosBootable PartitionAttribute = "legacy-bios-bootable"
+
+	// PartitionAttributeGrowFileSystem requests that the file system be grown to the size of the partition on boot.
+	PartitionAttributeGrowFileSystem PartitionAttribute = "grow-file-system"
+
+	// PartitionAttributeReadOnly requests that the partition be mounted read-only.
+	PartitionAttributeReadOnly PartitionAttribute = "read-only"
+
+	// PartitionAttributeNoAuto requests that the partition not be automatically mounted.
+	PartitionAttributeNoAuto PartitionAttribute = "no-auto"
+)
+
+func (a PartitionAttribute) IsValid() error {
+	switch a {
+	case PartitionAttributeRequired, PartitionAttributeNoBlockIoProtocol, PartitionAttributeLegacyBiosBootable,
+		PartitionAttributeGrowFileSystem, PartitionAttributeReadOnly, PartitionAttributeNoAuto:
+		// All good.
+		return nil
+
+	default:
+		return fmt.Errorf("invalid partition attribute value (%v)", a)
+	}
+}
@@ -0,0 +1,20 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerapi
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPartitionAttributeIsValid(t *testing.T) {
+	err := PartitionAttributeGrowFileSystem.IsValid()
+	assert.NoError(t, err)
+}
+
+func TestPartitionAttributeIsValidBadValue(t *testing.T) {
+	err := PartitionAttribute("grow").IsValid()
+	assert.ErrorContains(t, err, "invalid partition attribute value (grow)")
+}
@@ -0,0 +1,30 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerapi
+
+import (
+	"fmt"
+)
+
+// PartitionRepart configures how systemd-repart treats a partition when the image is first booted.
+type PartitionRepart struct {
+	// Grow allows systemd-repart to grow the partition into the free space that follows it.
+	Grow bool `yaml:"grow"`
+	// MaxSize is the largest size the partition may be grown to.
+	MaxSize *DiskSize `yaml:"maxSize"`
+}
+
+func (r *PartitionRepart) IsValid() error {
+	if r.MaxSize != nil {
+		if !r.Grow {
+			return fmt.Errorf("cannot specify 'maxSize' unless 'grow' is enabled")
+		}
+
+		if *r.MaxSize <= 0 {
+			return fmt.Errorf("'maxSize' value (%d) must be a positive non-zero number", *r.MaxSize)
+		}
+	}
+
+	return nil
+}
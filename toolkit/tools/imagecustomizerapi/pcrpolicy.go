@@ -0,0 +1,34 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerapi
+
+import (
+	"fmt"
+	"regexp"
+)
+
+var (
+	// A boot phase path is a list of boot phases separated by ':' (e.g. 'enter-initrd:leave-initrd').
+	pcrPolicyPhaseRegex = regexp.MustCompile(`^[a-z0-9-]+(:[a-z0-9-]+)*$`)
+)
+
+// PcrPolicy configures the generation of the predicted PCR values of the image's unified kernel images (UKIs), so that
+// remote attestation services can be pre-provisioned with the expected measurements of the image.
+type PcrPolicy struct {
+	// The boot phase paths to predict the PCR values for. By default, the same phase paths as systemd-measure are used.
+	Phases []string `yaml:"phases"`
+	// The path of the (PEM) RSA private key to sign the PCR policies with. Relative paths are relative to the config
+	// file's directory. By default, the PCR policies are not signed.
+	PrivateKeyPath string `yaml:"privateKeyPath"`
+}
+
+func (p *PcrPolicy) IsValid() error {
+	for i, phase := range p.Phases {
+		if !pcrPolicyPhaseRegex.MatchString(phase) {
+			return fmt.Errorf("invalid phases item at index %d:\ninvalid phase path (%s)", i, phase)
+		}
+	}
+
+	return nil
+}
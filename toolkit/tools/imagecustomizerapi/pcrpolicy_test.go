@@ -0,0 +1,34 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerapi
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPcrPolicyIsValid(t *testing.T) {
+	pcrPolicy := PcrPolicy{
+		Phases:         []string{"enter-initrd", "enter-initrd:leave-initrd"},
+		PrivateKeyPath: "keys/pcr-policy.key",
+	}
+	err := pcrPolicy.IsValid()
+	assert.NoError(t, err)
+}
+
+func TestPcrPolicyIsValidEmpty(t *testing.T) {
+	pcrPolicy := PcrPolicy{}
+	err := pcrPolicy.IsValid()
+	assert.NoError(t, err)
+}
+
+func TestPcrPolicyIsValidBadPhase(t *testing.T) {
+	pcrPolicy := PcrPolicy{
+		Phases: []string{"enter-initrd", "enter-initrd::leave-initrd"},
+	}
+	err := pcrPolicy.IsValid()
+	assert.ErrorContains(t, err, "invalid phases item at index 1")
+	assert.ErrorContains(t, err, "invalid phase path (enter-initrd::leave-initrd)")
+}
@@ -0,0 +1,166 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerapi
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"os"
+	"reflect"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Preset is a named, pre-defined configuration that a config can build on top of.
+type Preset string
+
+const (
+	PresetNone           Preset = ""
+	PresetLiveDev        Preset = "live-dev"
+	PresetKiosk          Preset = "kiosk"
+	PresetNetbootMinimal Preset = "netboot-minimal"
+	PresetInstaller      Preset = "installer"
+)
+
+//go:embed presets
+var presetsFS embed.FS
+
+func (p Preset) IsValid() error {
+	switch p {
+	case PresetNone, PresetLiveDev, PresetKiosk, PresetNetbootMinimal, PresetInstaller:
+		// All good.
+		return nil
+
+	default:
+		return fmt.Errorf("invalid preset value (%s)", p)
+	}
+}
+
+// presetHeader is used to read the preset of a config before the rest of the config is parsed.
+type presetHeader struct {
+	Preset Preset `yaml:"preset"`
+}
+
+// UnmarshalConfigYamlFile reads a config file. If the config specifies a preset, then the preset's config is loaded
+// first and the config file's values are applied on top of it. That is, values specified in the config file override
+// the preset's values (with lists being replaced as a whole). Since the preset has been applied, the returned config's
+// Preset field is cleared.
+func UnmarshalConfigYamlFile(yamlFilePath string, config *Config) error {
+	yamlFile, err := os.ReadFile(yamlFilePath)
+	if err != nil {
+		return err
+	}
+
+	return UnmarshalConfigYaml(yamlFile, config)
+}
+
+// UnmarshalConfigYaml is the same as UnmarshalConfigYamlFile except that it reads the config from memory.
+func UnmarshalConfigYaml(yamlData []byte, config *Config) error {
+	var header presetHeader
+	err := yaml.Unmarshal(yamlData, &header)
+	if err != nil {
+		return err
+	}
+
+	err = header.Preset.IsValid()
+	if err != nil {
+		return err
+	}
+
+	err = loadPresetConfig(header.Preset, config)
+	if err != nil {
+		return err
+	}
+
+	err = UnmarshalYaml(yamlData, config)
+	if err != nil {
+		return err
+	}
+
+	config.Preset = PresetNone
+	return nil
+}
+
+// ApplyPreset returns the config that results from applying the config's preset, if any. This is used for configs
+// that were not read using UnmarshalConfigYaml (e.g. configs constructed in code). The config's non-zero values
+// override the preset's values (with lists being replaced as a whole). The passed in config is not modified.
+func ApplyPreset(config *Config) (*Config, error) {
+	if config.Preset == PresetNone {
+		return config, nil
+	}
+
+	err := config.Preset.IsValid()
+	if err != nil {
+		return nil, err
+	}
+
+	presetConfig := &Config{}
+	err = loadPresetConfig(config.Preset, presetConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	overrideNonZeroValues(reflect.ValueOf(presetConfig).Elem(), reflect.ValueOf(config).Elem())
+
+	presetConfig.Preset = PresetNone
+	return presetConfig, nil
+}
+
+// overrideNonZeroValues recursively copies the non-zero values of 'src' into 'dst'. Structs are merged field by field
+// while all other values (including lists and maps) are replaced as a whole.
+func overrideNonZeroValues(dst reflect.Value, src reflect.Value) {
+	switch src.Kind() {
+	case reflect.Struct:
+		for i := 0; i < src.NumField(); i++ {
+			if !dst.Field(i).CanSet() {
+				continue
+			}
+
+			overrideNonZeroValues(dst.Field(i), src.Field(i))
+		}
+
+	case reflect.Pointer:
+		switch {
+		case src.IsNil():
+			return
+
+		case dst.IsNil() || src.Elem().Kind() != reflect.Struct:
+			dst.Set(src)
+
+		default:
+			// Merge into a copy so that neither the preset nor the caller's config are shared.
+			merged := reflect.New(dst.Elem().Type())
+			merged.Elem().Set(dst.Elem())
+			overrideNonZeroValues(merged.Elem(), src.Elem())
+			dst.Set(merged)
+		}
+
+	default:
+		if !src.IsZero() {
+			dst.Set(src)
+		}
+	}
+}
+
+func loadPresetConfig(preset Preset, config *Config) error {
+	if preset == PresetNone {
+		return nil
+	}
+
+	presetData, err := presetsFS.ReadFile("presets/" + string(preset) + ".yaml")
+	if err != nil {
+		return fmt.Errorf("failed to read preset (%s):\n%w", preset, err)
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(presetData))
+	decoder.KnownFields(true)
+
+	err = decoder.Decode(config)
+	if err != nil {
+		return fmt.Errorf("failed to parse preset (%s):\n%w", preset, err)
+	}
+
+	return nil
+}
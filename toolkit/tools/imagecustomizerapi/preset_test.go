@@ -0,0 +1,133 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerapi
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPresetIsValid(t *testing.T) {
+	assert.NoError(t, PresetNone.IsValid())
+	assert.NoError(t, PresetLiveDev.IsValid())
+	assert.ErrorContains(t, Preset("bad").IsValid(), "invalid preset value (bad)")
+}
+
+func TestPresetsAreValid(t *testing.T) {
+	presets := []Preset{PresetLiveDev, PresetKiosk, PresetNetbootMinimal, PresetInstaller}
+	for _, preset := range presets {
+		config := Config{}
+		err := loadPresetConfig(preset, &config)
+		if !assert.NoError(t, err, "preset (%s)", preset) {
+			continue
+		}
+
+		assert.NoError(t, config.IsValid(), "preset (%s)", preset)
+		assert.NotNil(t, config.Iso, "preset (%s)", preset)
+	}
+}
+
+func TestUnmarshalConfigYamlPreset(t *testing.T) {
+	var config Config
+	err := UnmarshalConfigYaml([]byte(`
+preset: live-dev
+os:
+  hostname: testname
+`), &config)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, PresetNone, config.Preset)
+	assert.Equal(t, "testname", config.OS.Hostname)
+	assert.Equal(t, []string{"openssh-server"}, config.OS.Packages.Install)
+	assert.Equal(t, []string{"sshd"}, config.OS.Services.Enable)
+	assert.Equal(t, KernelExtraArguments("console=tty0 console=ttyS0,115200n8"),
+		config.Iso.KernelCommandLine.ExtraCommandLine)
+}
+
+func TestUnmarshalConfigYamlPresetOverride(t *testing.T) {
+	var config Config
+	err := UnmarshalConfigYaml([]byte(`
+preset: live-dev
+iso:
+  kernelCommandLine:
+    extraCommandLine: "console=ttyS1"
+os:
+  services:
+    enable:
+    - chronyd
+`), &config)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, KernelExtraArguments("console=ttyS1"), config.Iso.KernelCommandLine.ExtraCommandLine)
+	assert.Equal(t, []string{"chronyd"}, config.OS.Services.Enable)
+	assert.Equal(t, []string{"openssh-server"}, config.OS.Packages.Install)
+}
+
+func TestUnmarshalConfigYamlNoPreset(t *testing.T) {
+	var config Config
+	err := UnmarshalConfigYaml([]byte(`
+os:
+  hostname: testname
+`), &config)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, PresetNone, config.Preset)
+	assert.Nil(t, config.Iso)
+	assert.Equal(t, "testname", config.OS.Hostname)
+}
+
+func TestUnmarshalConfigYamlInvalidPreset(t *testing.T) {
+	var config Config
+	err := UnmarshalConfigYaml([]byte(`preset: bad`), &config)
+	assert.ErrorContains(t, err, "invalid preset value (bad)")
+}
+
+func TestApplyPreset(t *testing.T) {
+	config := &Config{
+		Preset: PresetLiveDev,
+		Iso: &Iso{
+			KernelCommandLine: KernelCommandLine{
+				ExtraCommandLine: "console=ttyS1",
+			},
+		},
+		OS: &OS{
+			Hostname: "testname",
+		},
+	}
+
+	resolved, err := ApplyPreset(config)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, PresetNone, resolved.Preset)
+	assert.Equal(t, KernelExtraArguments("console=ttyS1"), resolved.Iso.KernelCommandLine.ExtraCommandLine)
+	assert.Equal(t, "testname", resolved.OS.Hostname)
+	assert.Equal(t, []string{"openssh-server"}, resolved.OS.Packages.Install)
+	assert.Equal(t, []string{"sshd"}, resolved.OS.Services.Enable)
+
+	// The caller's config is left untouched.
+	assert.Equal(t, PresetLiveDev, config.Preset)
+	assert.Empty(t, config.OS.Packages.Install)
+}
+
+func TestApplyPresetNone(t *testing.T) {
+	config := &Config{}
+
+	resolved, err := ApplyPreset(config)
+	assert.NoError(t, err)
+	assert.Same(t, config, resolved)
+}
+
+func TestApplyPresetInvalid(t *testing.T) {
+	_, err := ApplyPreset(&Config{Preset: "bad"})
+	assert.ErrorContains(t, err, "invalid preset value (bad)")
+}
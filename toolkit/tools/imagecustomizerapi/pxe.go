@@ -7,14 +7,36 @@ import (
 	"fmt"
 	"net/url"
 	"strings"
+	"unicode"
 )
 
+// The protocols supported by dracut's livenet module.
 var PxeIsoDownloadProtocols = []string{"ftp://", "http://", "https://", "nfs://", "tftp://"}
 
 // Iso defines how the generated iso media should be configured.
 type Pxe struct {
 	IsoImageBaseUrl string `yaml:"isoImageBaseUrl"`
 	IsoImageFileUrl string `yaml:"isoImageFileUrl"`
+	// The image that is downloaded at boot time. When set to 'rootfs', IsoImageBaseUrl is the base URL of the PXE
+	// artifacts folder.
+	BootImage PxeBootImage `yaml:"bootImage"`
+	// The layout of the files in the PXE artifacts folder.
+	ArtifactsLayout PxeArtifactsLayout `yaml:"artifactsLayout"`
+	// Also packs the PXE artifacts into a single archive file, with a manifest of the files and their checksums.
+	ArtifactsArchive PxeArtifactsArchiveFormat `yaml:"artifactsArchive"`
+	// Also generates an iPXE boot script in the PXE artifacts folder.
+	IpxeScript *PxeIpxeScript `yaml:"ipxeScript"`
+	// Also generates a PXELINUX configuration (pxelinux.cfg/default) in the PXE artifacts folder.
+	PxelinuxConfig bool `yaml:"pxelinuxConfig"`
+	// Per-host (or per-class) kernel arguments, selected at boot time by the MAC address of the host.
+	HostOverrides []PxeHostOverride `yaml:"hostOverrides"`
+	// Provisioning files hosted in the PXE artifacts folder, and referenced from the kernel command line.
+	AnswerFiles []PxeAnswerFile `yaml:"answerFiles"`
+	// How the initrd image authenticates to the server (and which servers it trusts) when it downloads the boot image.
+	Download *PxeDownload `yaml:"download"`
+	// Also prepares the PXE artifacts folder for UEFI HTTP boot. IsoImageBaseUrl is the URL of the PXE artifacts
+	// folder, which must be the root of an HTTP server.
+	HttpBoot bool `yaml:"httpBoot"`
 }
 
 func IsValidPxeUrl(urlString string) error {
@@ -22,22 +44,41 @@ func IsValidPxeUrl(urlString string) error {
 		return nil
 	}
 
-	_, err := url.Parse(urlString)
+	// The URL ends up on the kernel command line, where white spaces separate the arguments.
+	if strings.ContainsFunc(urlString, unicode.IsSpace) {
+		return fmt.Errorf("invalid URL value (%s):\nURL must not contain white spaces", urlString)
+	}
+
+	parsedUrl, err := url.Parse(urlString)
 	if err != nil {
 		return fmt.Errorf("invalid URL value (%s):\n%w", urlString, err)
 	}
 
 	protocolFound := false
 	for _, protocol := range PxeIsoDownloadProtocols {
-		if strings.HasPrefix(urlString, protocol) {
+		if parsedUrl.Scheme+"://" == protocol {
 			protocolFound = true
 			break
 		}
 	}
-	if !protocolFound {
+	if !protocolFound || !strings.HasPrefix(urlString, parsedUrl.Scheme+"://") {
 		return fmt.Errorf("unsupported iso image URL protocol in (%s). One of (%v) is expected.", urlString, PxeIsoDownloadProtocols)
 	}
 
+	if parsedUrl.Host == "" {
+		return fmt.Errorf("invalid URL value (%s):\nURL must specify a host", urlString)
+	}
+
+	// dracut's livenet module downloads the URL as is. So, a query or a fragment would not be dropped when the iso
+	// image name is appended to a base URL.
+	if parsedUrl.RawQuery != "" || parsedUrl.ForceQuery {
+		return fmt.Errorf("invalid URL value (%s):\nURL must not have a query", urlString)
+	}
+
+	if parsedUrl.Fragment != "" || strings.Contains(urlString, "#") {
+		return fmt.Errorf("invalid URL value (%s):\nURL must not have a fragment", urlString)
+	}
+
 	return nil
 }
 
@@ -53,5 +94,89 @@ func (p *Pxe) IsValid() error {
 	if err != nil {
 		return fmt.Errorf("invalid 'isoImageFileUrl' field value (%s):\n%w", p.IsoImageFileUrl, err)
 	}
+	err = p.BootImage.IsValid()
+	if err != nil {
+		return err
+	}
+	if p.BootImage == PxeBootImageRootfs && p.IsoImageFileUrl != "" {
+		return fmt.Errorf("'isoImageFileUrl' cannot be specified when 'bootImage' is (%s)", p.BootImage)
+	}
+	err = p.ArtifactsLayout.IsValid()
+	if err != nil {
+		return err
+	}
+	err = p.ArtifactsArchive.IsValid()
+	if err != nil {
+		return err
+	}
+	macAddresses := make(map[string]bool)
+	for i, hostOverride := range p.HostOverrides {
+		err = hostOverride.IsValid()
+		if err != nil {
+			return fmt.Errorf("invalid 'hostOverrides' value at index %d:\n%w", i, err)
+		}
+
+		for _, macAddress := range hostOverride.MacAddresses {
+			// The error was checked by hostOverride.IsValid().
+			normalizedMacAddress, _ := ParsePxeHostMacAddress(macAddress)
+			if macAddresses[normalizedMacAddress] {
+				return fmt.Errorf("duplicate MAC address (%s) in 'hostOverrides' at index %d", macAddress, i)
+			}
+			macAddresses[normalizedMacAddress] = true
+		}
+	}
+	if len(p.AnswerFiles) > 0 && p.IsoImageBaseUrl == "" {
+		return fmt.Errorf("'answerFiles' requires 'isoImageBaseUrl' to be specified")
+	}
+	destinations := make(map[string]bool)
+	for i, answerFile := range p.AnswerFiles {
+		err = answerFile.IsValid()
+		if err != nil {
+			return fmt.Errorf("invalid 'answerFiles' value at index %d:\n%w", i, err)
+		}
+
+		if destinations[answerFile.Destination] {
+			return fmt.Errorf("duplicate destination (%s) in 'answerFiles' at index %d", answerFile.Destination, i)
+		}
+		destinations[answerFile.Destination] = true
+	}
+	if p.IpxeScript != nil {
+		err = p.IpxeScript.IsValid()
+		if err != nil {
+			return fmt.Errorf("invalid 'ipxeScript' field:\n%w", err)
+		}
+	}
+	if p.HttpBoot {
+		err = isValidPxeHttpBootUrl(p.IsoImageBaseUrl)
+		if err != nil {
+			return err
+		}
+	}
+	if p.Download != nil {
+		err = p.Download.IsValid()
+		if err != nil {
+			return fmt.Errorf("invalid 'download' field:\n%w", err)
+		}
+	}
+	return nil
+}
+
+func isValidPxeHttpBootUrl(isoImageBaseUrl string) error {
+	if isoImageBaseUrl == "" {
+		return fmt.Errorf("'httpBoot' requires 'isoImageBaseUrl' to be specified")
+	}
+
+	// The error was checked by IsValidPxeUrl().
+	parsedUrl, _ := url.Parse(isoImageBaseUrl)
+	if parsedUrl.Scheme != "http" && parsedUrl.Scheme != "https" {
+		return fmt.Errorf("'httpBoot' requires 'isoImageBaseUrl' (%s) to be an http or an https URL", isoImageBaseUrl)
+	}
+
+	// grub reads its configuration, the kernel and the initrd image from absolute paths on the server.
+	if strings.Trim(parsedUrl.Path, "/") != "" {
+		return fmt.Errorf("'httpBoot' requires 'isoImageBaseUrl' (%s) to be the root of the HTTP server",
+			isoImageBaseUrl)
+	}
+
 	return nil
 }
@@ -0,0 +1,79 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerapi
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsValidPxeUrl(t *testing.T) {
+	validUrls := []string{
+		"",
+		"http://192.168.0.1/liveos",
+		"https://hostname:8443/liveos/azl.iso",
+		"ftp://hostname/liveos",
+		"tftp://hostname/azl.iso",
+		"nfs://hostname/export/liveos",
+	}
+	for _, validUrl := range validUrls {
+		err := IsValidPxeUrl(validUrl)
+		assert.NoError(t, err, validUrl)
+	}
+}
+
+func TestIsValidPxeUrlBadValue(t *testing.T) {
+	badUrls := map[string]string{
+		"file:///liveos/azl.iso":           "unsupported iso image URL protocol in (file:///liveos/azl.iso)",
+		"HTTP://hostname/liveos":           "unsupported iso image URL protocol in (HTTP://hostname/liveos)",
+		"hostname/liveos":                  "unsupported iso image URL protocol in (hostname/liveos)",
+		"http:hostname/liveos":             "unsupported iso image URL protocol in (http:hostname/liveos)",
+		"http:///liveos":                   "URL must specify a host",
+		"http://hostname/live os":          "URL must not contain white spaces",
+		"http://hostname/liveos?token=abc": "URL must not have a query",
+		"http://hostname/liveos?":          "URL must not have a query",
+		"http://hostname/liveos#azl.iso":   "URL must not have a fragment",
+		"http://hostname:port/liveos":      "invalid URL value (http://hostname:port/liveos)",
+	}
+	for badUrl, expectedError := range badUrls {
+		err := IsValidPxeUrl(badUrl)
+		assert.ErrorContains(t, err, expectedError, badUrl)
+	}
+}
+
+func TestPxeIsValidBadIsoImageBaseUrl(t *testing.T) {
+	pxe := Pxe{
+		IsoImageBaseUrl: "http://hostname/liveos?token=abc",
+	}
+	err := pxe.IsValid()
+	assert.ErrorContains(t, err, "invalid 'isoImageBaseUrl' field value (http://hostname/liveos?token=abc)")
+	assert.ErrorContains(t, err, "URL must not have a query")
+}
+
+func TestPxeIsValidHttpBoot(t *testing.T) {
+	pxe := Pxe{
+		IsoImageBaseUrl: "https://hostname:8080/",
+		HttpBoot:        true,
+	}
+	err := pxe.IsValid()
+	assert.NoError(t, err)
+}
+
+func TestPxeIsValidHttpBootBadIsoImageBaseUrl(t *testing.T) {
+	badUrls := map[string]string{
+		"":                      "'httpBoot' requires 'isoImageBaseUrl' to be specified",
+		"tftp://hostname":       "to be an http or an https URL",
+		"http://hostname/pxe/":  "to be the root of the HTTP server",
+		"http://hostname/pxe-1": "to be the root of the HTTP server",
+	}
+	for badUrl, expectedError := range badUrls {
+		pxe := Pxe{
+			IsoImageBaseUrl: badUrl,
+			HttpBoot:        true,
+		}
+		err := pxe.IsValid()
+		assert.ErrorContains(t, err, expectedError, badUrl)
+	}
+}
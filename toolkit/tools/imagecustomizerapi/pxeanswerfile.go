@@ -0,0 +1,46 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerapi
+
+import (
+	"fmt"
+	"regexp"
+)
+
+var pxeKernelArgumentNameRegex = regexp.MustCompile(`^[A-Za-z0-9_.\-]+$`)
+
+// PxeAnswerFile is a provisioning file (e.g. a kickstart file) that is hosted in the PXE artifacts folder, and that is
+// referenced from the kernel command line.
+type PxeAnswerFile struct {
+	// The path of the file on the build machine. Relative paths are relative to the config file.
+	Source string `yaml:"source"`
+	// The path of the file in the PXE artifacts folder.
+	Destination string `yaml:"destination"`
+	// The name of the kernel argument that is set to the URL of the file (e.g. inst.ks). If empty, the file is only
+	// hosted.
+	KernelArgument string `yaml:"kernelArgument"`
+}
+
+func (f *PxeAnswerFile) IsValid() error {
+	if f.Source == "" {
+		return fmt.Errorf("source must not be empty")
+	}
+
+	if f.Destination == "" {
+		return fmt.Errorf("destination must not be empty")
+	}
+
+	// The destination is also part of a URL. So, keep it simple.
+	err := isValidIsoLiveOSDir(f.Destination)
+	if err != nil {
+		return fmt.Errorf("invalid destination:\n%w", err)
+	}
+
+	if f.KernelArgument != "" && !pxeKernelArgumentNameRegex.MatchString(f.KernelArgument) {
+		return fmt.Errorf("invalid kernelArgument (%s):\nmust be made of only letters, digits, '.', '_' and '-'",
+			f.KernelArgument)
+	}
+
+	return nil
+}
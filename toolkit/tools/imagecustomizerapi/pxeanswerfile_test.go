@@ -0,0 +1,62 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerapi
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPxeAnswerFileIsValid(t *testing.T) {
+	answerFile := PxeAnswerFile{
+		Source:         "files/host.ks",
+		Destination:    "ks/host.ks",
+		KernelArgument: "inst.ks",
+	}
+	err := answerFile.IsValid()
+	assert.NoError(t, err)
+}
+
+func TestPxeAnswerFileIsValidBadDestination(t *testing.T) {
+	answerFile := PxeAnswerFile{
+		Source:      "files/host.ks",
+		Destination: "../host.ks",
+	}
+	err := answerFile.IsValid()
+	assert.ErrorContains(t, err, "invalid destination")
+}
+
+func TestPxeAnswerFileIsValidBadKernelArgument(t *testing.T) {
+	answerFile := PxeAnswerFile{
+		Source:         "files/host.ks",
+		Destination:    "ks/host.ks",
+		KernelArgument: "inst.ks=",
+	}
+	err := answerFile.IsValid()
+	assert.ErrorContains(t, err, "invalid kernelArgument (inst.ks=)")
+}
+
+func TestPxeIsValidAnswerFilesWithoutIsoImageBaseUrl(t *testing.T) {
+	pxe := Pxe{
+		IsoImageFileUrl: "http://hostname/pxe/azl.iso",
+		AnswerFiles: []PxeAnswerFile{
+			{Source: "files/host.ks", Destination: "ks/host.ks"},
+		},
+	}
+	err := pxe.IsValid()
+	assert.ErrorContains(t, err, "'answerFiles' requires 'isoImageBaseUrl' to be specified")
+}
+
+func TestPxeIsValidDuplicateAnswerFileDestination(t *testing.T) {
+	pxe := Pxe{
+		IsoImageBaseUrl: "http://hostname/pxe",
+		AnswerFiles: []PxeAnswerFile{
+			{Source: "files/a.ks", Destination: "ks/host.ks"},
+			{Source: "files/b.ks", Destination: "ks/host.ks"},
+		},
+	}
+	err := pxe.IsValid()
+	assert.ErrorContains(t, err, "duplicate destination (ks/host.ks) in 'answerFiles' at index 1")
+}
@@ -0,0 +1,28 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerapi
+
+import (
+	"fmt"
+)
+
+// PxeArtifactsArchiveFormat is the format of the single file archive of the PXE artifacts.
+type PxeArtifactsArchiveFormat string
+
+const (
+	PxeArtifactsArchiveFormatNone  PxeArtifactsArchiveFormat = ""
+	PxeArtifactsArchiveFormatTarGz PxeArtifactsArchiveFormat = "tar.gz"
+	PxeArtifactsArchiveFormatZip   PxeArtifactsArchiveFormat = "zip"
+)
+
+func (f PxeArtifactsArchiveFormat) IsValid() error {
+	switch f {
+	case PxeArtifactsArchiveFormatNone, PxeArtifactsArchiveFormatTarGz, PxeArtifactsArchiveFormatZip:
+		// All good.
+		return nil
+
+	default:
+		return fmt.Errorf("invalid artifactsArchive value (%v)", f)
+	}
+}
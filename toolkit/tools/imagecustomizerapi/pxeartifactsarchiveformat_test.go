@@ -0,0 +1,20 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerapi
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPxeArtifactsArchiveFormatIsValid(t *testing.T) {
+	err := PxeArtifactsArchiveFormatTarGz.IsValid()
+	assert.NoError(t, err)
+}
+
+func TestPxeArtifactsArchiveFormatIsValidBadValue(t *testing.T) {
+	err := PxeArtifactsArchiveFormat("tar.xz").IsValid()
+	assert.ErrorContains(t, err, "invalid artifactsArchive value (tar.xz)")
+}
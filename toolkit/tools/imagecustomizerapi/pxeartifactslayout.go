@@ -0,0 +1,30 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerapi
+
+import (
+	"fmt"
+)
+
+// PxeArtifactsLayout is the layout of the files in the PXE artifacts folder.
+type PxeArtifactsLayout string
+
+const (
+	// The default. The PXE artifacts folder mirrors the iso media.
+	PxeArtifactsLayoutDefault PxeArtifactsLayout = ""
+	PxeArtifactsLayoutIso     PxeArtifactsLayout = "iso"
+	// The bootloaders at the root, and the kernel and the initrd image under a versioned folder.
+	PxeArtifactsLayoutTftp PxeArtifactsLayout = "tftp"
+)
+
+func (l PxeArtifactsLayout) IsValid() error {
+	switch l {
+	case PxeArtifactsLayoutDefault, PxeArtifactsLayoutIso, PxeArtifactsLayoutTftp:
+		// All good.
+		return nil
+
+	default:
+		return fmt.Errorf("invalid artifactsLayout value (%v)", l)
+	}
+}
@@ -0,0 +1,20 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerapi
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPxeArtifactsLayoutIsValid(t *testing.T) {
+	err := PxeArtifactsLayoutTftp.IsValid()
+	assert.NoError(t, err)
+}
+
+func TestPxeArtifactsLayoutIsValidBadValue(t *testing.T) {
+	err := PxeArtifactsLayout("http").IsValid()
+	assert.ErrorContains(t, err, "invalid artifactsLayout value (http)")
+}
@@ -0,0 +1,30 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerapi
+
+import (
+	"fmt"
+)
+
+// PxeBootImage is the image that dracut's livenet module downloads at boot time.
+type PxeBootImage string
+
+const (
+	// The default. The iso image.
+	PxeBootImageDefault PxeBootImage = ""
+	PxeBootImageIso     PxeBootImage = "iso"
+	// The rootfs (squashfs) image, without the iso image around it.
+	PxeBootImageRootfs PxeBootImage = "rootfs"
+)
+
+func (i PxeBootImage) IsValid() error {
+	switch i {
+	case PxeBootImageDefault, PxeBootImageIso, PxeBootImageRootfs:
+		// All good.
+		return nil
+
+	default:
+		return fmt.Errorf("invalid bootImage value (%v)", i)
+	}
+}
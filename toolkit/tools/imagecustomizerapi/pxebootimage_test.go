@@ -0,0 +1,29 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerapi
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPxeBootImageIsValid(t *testing.T) {
+	err := PxeBootImageRootfs.IsValid()
+	assert.NoError(t, err)
+}
+
+func TestPxeBootImageIsValidBadValue(t *testing.T) {
+	err := PxeBootImage("squashfs").IsValid()
+	assert.ErrorContains(t, err, "invalid bootImage value (squashfs)")
+}
+
+func TestPxeIsValidRootfsBootImageWithIsoImageFileUrl(t *testing.T) {
+	pxe := Pxe{
+		IsoImageFileUrl: "http://hostname-or-ip/iso-publish-path/my-liveos.iso",
+		BootImage:       PxeBootImageRootfs,
+	}
+	err := pxe.IsValid()
+	assert.ErrorContains(t, err, "'isoImageFileUrl' cannot be specified when 'bootImage' is (rootfs)")
+}
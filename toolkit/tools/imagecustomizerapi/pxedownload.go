@@ -0,0 +1,39 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerapi
+
+import (
+	"fmt"
+)
+
+// PxeDownload configures how the initrd image downloads the boot image (i.e. the iso or the rootfs image) when PXE
+// booting from an authenticated or an enterprise-TLS server. The settings are built into the initrd image.
+type PxeDownload struct {
+	// CA certificates (PEM) to trust, in addition to the ones of the OS.
+	CaCertificates []string `yaml:"caCertificates"`
+	// The client certificate (PEM) for mutual TLS.
+	ClientCertificate string `yaml:"clientCertificate"`
+	// The private key (PEM) of the client certificate.
+	ClientKey string `yaml:"clientKey"`
+	// A netrc file with the basic authentication credentials.
+	CredentialsFile string `yaml:"credentialsFile"`
+}
+
+func (d *PxeDownload) IsValid() error {
+	for i, caCertificate := range d.CaCertificates {
+		if caCertificate == "" {
+			return fmt.Errorf("invalid caCertificates value at index %d:\nmust not be empty", i)
+		}
+	}
+
+	if (d.ClientCertificate == "") != (d.ClientKey == "") {
+		return fmt.Errorf("clientCertificate and clientKey must be specified together")
+	}
+
+	if len(d.CaCertificates) == 0 && d.ClientCertificate == "" && d.CredentialsFile == "" {
+		return fmt.Errorf("at least one of caCertificates, clientCertificate or credentialsFile must be specified")
+	}
+
+	return nil
+}
@@ -0,0 +1,43 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerapi
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPxeDownloadIsValid(t *testing.T) {
+	download := PxeDownload{
+		CaCertificates:    []string{"files/enterprise-ca.pem"},
+		ClientCertificate: "files/client.pem",
+		ClientKey:         "files/client.key",
+		CredentialsFile:   "files/netrc",
+	}
+	err := download.IsValid()
+	assert.NoError(t, err)
+}
+
+func TestPxeDownloadIsValidEmpty(t *testing.T) {
+	download := PxeDownload{}
+	err := download.IsValid()
+	assert.ErrorContains(t, err, "at least one of caCertificates, clientCertificate or credentialsFile must be specified")
+}
+
+func TestPxeDownloadIsValidClientCertificateWithoutKey(t *testing.T) {
+	download := PxeDownload{
+		ClientCertificate: "files/client.pem",
+	}
+	err := download.IsValid()
+	assert.ErrorContains(t, err, "clientCertificate and clientKey must be specified together")
+}
+
+func TestPxeDownloadIsValidEmptyCaCertificate(t *testing.T) {
+	download := PxeDownload{
+		CaCertificates: []string{""},
+	}
+	err := download.IsValid()
+	assert.ErrorContains(t, err, "invalid caCertificates value at index 0")
+}
@@ -0,0 +1,55 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerapi
+
+import (
+	"fmt"
+	"net"
+)
+
+// PxeHostOverride adds kernel arguments for a host, or for a class of hosts, when PXE booting. The hosts are
+// identified by the MAC address of the network interface they boot from.
+type PxeHostOverride struct {
+	// A name for the host, or for the class of hosts. For documentation only.
+	Name string `yaml:"name"`
+	// The MAC addresses of the hosts (e.g. 52:54:00:12:34:56).
+	MacAddresses []string `yaml:"macAddresses"`
+	// The kernel arguments to add.
+	KernelCommandLine KernelCommandLine `yaml:"kernelCommandLine"`
+}
+
+func (o *PxeHostOverride) IsValid() error {
+	if len(o.MacAddresses) == 0 {
+		return fmt.Errorf("macAddresses must not be empty")
+	}
+
+	for i, macAddress := range o.MacAddresses {
+		_, err := ParsePxeHostMacAddress(macAddress)
+		if err != nil {
+			return fmt.Errorf("invalid macAddresses value at index %d:\n%w", i, err)
+		}
+	}
+
+	err := o.KernelCommandLine.IsValid()
+	if err != nil {
+		return fmt.Errorf("invalid kernelCommandLine:\n%w", err)
+	}
+
+	return nil
+}
+
+// ParsePxeHostMacAddress parses an Ethernet MAC address and returns it in the format of grub's 'net_default_mac'
+// variable (i.e. lowercase, colon separated).
+func ParsePxeHostMacAddress(macAddress string) (string, error) {
+	hardwareAddr, err := net.ParseMAC(macAddress)
+	if err != nil {
+		return "", fmt.Errorf("invalid MAC address (%s):\n%w", macAddress, err)
+	}
+
+	if len(hardwareAddr) != 6 {
+		return "", fmt.Errorf("invalid MAC address (%s):\nmust be a 6 byte Ethernet address", macAddress)
+	}
+
+	return hardwareAddr.String(), nil
+}
@@ -0,0 +1,54 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerapi
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPxeHostOverrideIsValid(t *testing.T) {
+	hostOverride := PxeHostOverride{
+		Name:         "serial-console-hosts",
+		MacAddresses: []string{"52:54:00:12:34:56", "52-54-00-AB-CD-EF"},
+		KernelCommandLine: KernelCommandLine{
+			ExtraCommandLine: "console=ttyS1,115200n8",
+		},
+	}
+	err := hostOverride.IsValid()
+	assert.NoError(t, err)
+}
+
+func TestPxeHostOverrideIsValidNoMacAddresses(t *testing.T) {
+	hostOverride := PxeHostOverride{}
+	err := hostOverride.IsValid()
+	assert.ErrorContains(t, err, "macAddresses must not be empty")
+}
+
+func TestPxeHostOverrideIsValidBadMacAddress(t *testing.T) {
+	hostOverride := PxeHostOverride{
+		MacAddresses: []string{"52:54:00:12:34:56", "02:00:5e:10:00:00:00:01"},
+	}
+	err := hostOverride.IsValid()
+	assert.ErrorContains(t, err, "invalid macAddresses value at index 1")
+	assert.ErrorContains(t, err, "must be a 6 byte Ethernet address")
+}
+
+func TestParsePxeHostMacAddress(t *testing.T) {
+	macAddress, err := ParsePxeHostMacAddress("52-54-00-AB-CD-EF")
+	assert.NoError(t, err)
+	assert.Equal(t, "52:54:00:ab:cd:ef", macAddress)
+}
+
+func TestPxeIsValidDuplicateHostOverrideMacAddress(t *testing.T) {
+	pxe := Pxe{
+		HostOverrides: []PxeHostOverride{
+			{MacAddresses: []string{"52:54:00:ab:cd:ef"}},
+			{MacAddresses: []string{"52-54-00-AB-CD-EF"}},
+		},
+	}
+	err := pxe.IsValid()
+	assert.ErrorContains(t, err, "duplicate MAC address (52-54-00-AB-CD-EF) in 'hostOverrides' at index 1")
+}
@@ -0,0 +1,47 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerapi
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+const (
+	PxeDefaultIpxeScriptName = "boot.ipxe"
+)
+
+// PxeIpxeScript defines the iPXE boot script generated in the PXE artifacts folder.
+type PxeIpxeScript struct {
+	// The file name of the script. Defaults to 'boot.ipxe'.
+	Name string `yaml:"name"`
+	// A Go text/template that replaces the default contents of the script.
+	Template string `yaml:"template"`
+}
+
+func (s *PxeIpxeScript) IsValid() error {
+	if s.Name != "" {
+		if strings.ContainsAny(s.Name, "/\\") || s.Name == "." || s.Name == ".." {
+			return fmt.Errorf("invalid name (%s):\nmust be a file name", s.Name)
+		}
+	}
+
+	if s.Template != "" {
+		_, err := template.New("ipxe").Option("missingkey=error").Parse(s.Template)
+		if err != nil {
+			return fmt.Errorf("invalid template:\n%w", err)
+		}
+	}
+
+	return nil
+}
+
+// GetName returns the file name of the script.
+func (s *PxeIpxeScript) GetName() string {
+	if s.Name == "" {
+		return PxeDefaultIpxeScriptName
+	}
+	return s.Name
+}
@@ -0,0 +1,54 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerapi
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPxeIpxeScriptIsValid(t *testing.T) {
+	script := PxeIpxeScript{
+		Name:     "azl.ipxe",
+		Template: "#!ipxe\nkernel {{.KernelPath}} {{.KernelArgs}}\ninitrd {{.InitrdPath}}\nboot\n",
+	}
+	err := script.IsValid()
+	assert.NoError(t, err)
+	assert.Equal(t, "azl.ipxe", script.GetName())
+}
+
+func TestPxeIpxeScriptIsValidDefaultName(t *testing.T) {
+	script := PxeIpxeScript{}
+	err := script.IsValid()
+	assert.NoError(t, err)
+	assert.Equal(t, PxeDefaultIpxeScriptName, script.GetName())
+}
+
+func TestPxeIpxeScriptIsValidBadName(t *testing.T) {
+	script := PxeIpxeScript{
+		Name: "scripts/boot.ipxe",
+	}
+	err := script.IsValid()
+	assert.ErrorContains(t, err, "invalid name (scripts/boot.ipxe)")
+}
+
+func TestPxeIpxeScriptIsValidBadTemplate(t *testing.T) {
+	script := PxeIpxeScript{
+		Template: "kernel {{.KernelPath}",
+	}
+	err := script.IsValid()
+	assert.ErrorContains(t, err, "invalid template")
+}
+
+func TestPxeIsValidBadIpxeScript(t *testing.T) {
+	pxe := Pxe{
+		IpxeScript: &PxeIpxeScript{
+			Name: "..",
+		},
+	}
+	err := pxe.IsValid()
+	assert.ErrorContains(t, err, "invalid 'ipxeScript' field")
+	assert.ErrorContains(t, err, "invalid name (..)")
+}
@@ -34,6 +34,8 @@ type Verity struct {
 	HashDeviceMountIdType MountIdentifierType `yaml:"hashDeviceMountIdType"`
 	// How to handle corruption.
 	CorruptionOption CorruptionOption `yaml:"corruptionOption"`
+	// Optionally, sign the root hash.
+	RootHashSignature *VerityRootHashSignature `yaml:"rootHashSignature"`
 
 	// The filesystem config that points to this verity device.
 	// Value is filled in by Storage.IsValid().
@@ -61,5 +63,11 @@ func (v *Verity) IsValid() error {
 		return fmt.Errorf("invalid corruptionOption:\n%w", err)
 	}
 
+	if v.RootHashSignature != nil {
+		if err := v.RootHashSignature.IsValid(); err != nil {
+			return fmt.Errorf("invalid rootHashSignature:\n%w", err)
+		}
+	}
+
 	return nil
 }
@@ -84,3 +84,36 @@ func TestVerityIsValidInvalidCorruptionOption(t *testing.T) {
 	assert.Error(t, err)
 	assert.ErrorContains(t, err, "invalid CorruptionOption value")
 }
+
+func TestVerityIsValidRootHashSignature(t *testing.T) {
+	validVerity := Verity{
+		Id:           "root",
+		Name:         "root",
+		DataDeviceId: "root",
+		HashDeviceId: "roothash",
+		RootHashSignature: &VerityRootHashSignature{
+			CertificatePath:   "verity.crt",
+			PrivateKeyPath:    "verity.key",
+			RequireSignatures: true,
+		},
+	}
+
+	err := validVerity.IsValid()
+	assert.NoError(t, err)
+}
+
+func TestVerityIsValidRootHashSignatureMissingPrivateKeyPath(t *testing.T) {
+	invalidVerity := Verity{
+		Id:           "root",
+		Name:         "root",
+		DataDeviceId: "root",
+		HashDeviceId: "roothash",
+		RootHashSignature: &VerityRootHashSignature{
+			CertificatePath: "verity.crt",
+		},
+	}
+
+	err := invalidVerity.IsValid()
+	assert.ErrorContains(t, err, "invalid rootHashSignature")
+	assert.ErrorContains(t, err, "'privateKeyPath' may not be empty")
+}
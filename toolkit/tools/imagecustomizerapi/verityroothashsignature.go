@@ -0,0 +1,31 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerapi
+
+import (
+	"fmt"
+)
+
+// VerityRootHashSignature configures the signing of a verity device's root hash, so that the kernel can check the
+// root hash against the certificates in its keyring.
+type VerityRootHashSignature struct {
+	// The path of the (PEM) certificate to sign the root hash with.
+	CertificatePath string `yaml:"certificatePath"`
+	// The path of the (PEM) private key of the certificate.
+	PrivateKeyPath string `yaml:"privateKeyPath"`
+	// Whether the kernel should refuse to open verity devices that don't have a valid root hash signature.
+	RequireSignatures bool `yaml:"requireSignatures"`
+}
+
+func (s *VerityRootHashSignature) IsValid() error {
+	if s.CertificatePath == "" {
+		return fmt.Errorf("'certificatePath' may not be empty")
+	}
+
+	if s.PrivateKeyPath == "" {
+		return fmt.Errorf("'privateKeyPath' may not be empty")
+	}
+
+	return nil
+}
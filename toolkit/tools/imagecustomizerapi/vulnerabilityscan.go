@@ -0,0 +1,33 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerapi
+
+import (
+	"fmt"
+)
+
+// VulnerabilityScan configures a vulnerability scan of the customized OS. The scanner is a program on the build host,
+// which is passed the list of the OS's rpm packages and returns the vulnerabilities it found.
+type VulnerabilityScan struct {
+	// The path of the scanner program. Relative paths are relative to the config file's directory.
+	Path string `yaml:"path"`
+	// Additional arguments to pass to the scanner.
+	Arguments []string `yaml:"arguments"`
+	// Fail the build if any of the found vulnerabilities have this severity or higher. By default, the vulnerabilities
+	// are only reported.
+	FailOnSeverity VulnerabilitySeverity `yaml:"failOnSeverity"`
+}
+
+func (v *VulnerabilityScan) IsValid() error {
+	if v.Path == "" {
+		return fmt.Errorf("path must have a value")
+	}
+
+	err := v.FailOnSeverity.IsValid()
+	if err != nil {
+		return fmt.Errorf("invalid failOnSeverity value:\n%w", err)
+	}
+
+	return nil
+}
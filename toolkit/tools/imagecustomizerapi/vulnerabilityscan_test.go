@@ -0,0 +1,51 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerapi
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVulnerabilityScanIsValid(t *testing.T) {
+	vulnerabilityScan := VulnerabilityScan{
+		Path:           "scripts/scan.sh",
+		Arguments:      []string{"--db", "/var/lib/cve-db"},
+		FailOnSeverity: VulnerabilitySeverityHigh,
+	}
+	err := vulnerabilityScan.IsValid()
+	assert.NoError(t, err)
+}
+
+func TestVulnerabilityScanIsValidMissingPath(t *testing.T) {
+	vulnerabilityScan := VulnerabilityScan{}
+	err := vulnerabilityScan.IsValid()
+	assert.ErrorContains(t, err, "path must have a value")
+}
+
+func TestVulnerabilityScanIsValidBadSeverity(t *testing.T) {
+	vulnerabilityScan := VulnerabilityScan{
+		Path:           "scripts/scan.sh",
+		FailOnSeverity: "severe",
+	}
+	err := vulnerabilityScan.IsValid()
+	assert.ErrorContains(t, err, "invalid failOnSeverity value")
+	assert.ErrorContains(t, err, "invalid VulnerabilitySeverity value (severe)")
+}
+
+func TestVulnerabilitySeverityRank(t *testing.T) {
+	assert.Less(t, VulnerabilitySeverity("negligible").Rank(), VulnerabilitySeverityLow.Rank())
+	assert.Less(t, VulnerabilitySeverityLow.Rank(), VulnerabilitySeverityMedium.Rank())
+	assert.Less(t, VulnerabilitySeverityMedium.Rank(), VulnerabilitySeverityHigh.Rank())
+	assert.Less(t, VulnerabilitySeverityHigh.Rank(), VulnerabilitySeverityCritical.Rank())
+}
+
+func TestConfigIsValidInvalidVulnerabilityScan(t *testing.T) {
+	config := Config{
+		VulnerabilityScan: &VulnerabilityScan{},
+	}
+	err := config.IsValid()
+	assert.ErrorContains(t, err, "invalid 'vulnerabilityScan' field")
+}
@@ -0,0 +1,51 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerapi
+
+import (
+	"fmt"
+)
+
+// VulnerabilitySeverity is the severity of a vulnerability, as reported by a vulnerability scanner.
+type VulnerabilitySeverity string
+
+const (
+	VulnerabilitySeverityDefault  VulnerabilitySeverity = ""
+	VulnerabilitySeverityLow      VulnerabilitySeverity = "low"
+	VulnerabilitySeverityMedium   VulnerabilitySeverity = "medium"
+	VulnerabilitySeverityHigh     VulnerabilitySeverity = "high"
+	VulnerabilitySeverityCritical VulnerabilitySeverity = "critical"
+)
+
+func (s VulnerabilitySeverity) IsValid() error {
+	switch s {
+	case VulnerabilitySeverityDefault,
+		VulnerabilitySeverityLow,
+		VulnerabilitySeverityMedium,
+		VulnerabilitySeverityHigh,
+		VulnerabilitySeverityCritical:
+		// All good.
+		return nil
+
+	default:
+		return fmt.Errorf("invalid VulnerabilitySeverity value (%v)", s)
+	}
+}
+
+// Rank orders the severities from lowest (1) to highest. Unknown severities (e.g. a scanner's 'negligible') are
+// ranked 0.
+func (s VulnerabilitySeverity) Rank() int {
+	switch s {
+	case VulnerabilitySeverityLow:
+		return 1
+	case VulnerabilitySeverityMedium:
+		return 2
+	case VulnerabilitySeverityHigh:
+		return 3
+	case VulnerabilitySeverityCritical:
+		return 4
+	default:
+		return 0
+	}
+}
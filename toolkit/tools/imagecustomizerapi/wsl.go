@@ -0,0 +1,48 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerapi
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/asaskevich/govalidator"
+)
+
+// Wsl configures the WSL distribution that is created when the output image format is 'wsl'. The settings are written
+// to the distribution's '/etc/wsl.conf' file.
+type Wsl struct {
+	// The user that WSL starts the distribution's shells as.
+	DefaultUser string `yaml:"defaultUser"`
+	// Whether WSL starts systemd as the distribution's init process.
+	Systemd bool `yaml:"systemd"`
+	// The hostname of the distribution. By default, WSL uses the Windows host's name.
+	Hostname string `yaml:"hostname"`
+	// The directory that the Windows drives are mounted under (e.g. /mnt/).
+	AutomountRoot string `yaml:"automountRoot"`
+	// Whether to leave out the Windows host's PATH from the distribution's PATH.
+	DisableWindowsPath bool `yaml:"disableWindowsPath"`
+}
+
+func (w *Wsl) IsValid() error {
+	if w.DefaultUser != "" && strings.ContainsAny(w.DefaultUser, " \t\n:/") {
+		return fmt.Errorf("invalid defaultUser value (%s):\nmust be a valid user name", w.DefaultUser)
+	}
+
+	if w.Hostname != "" {
+		if !govalidator.IsDNSName(w.Hostname) || strings.Contains(w.Hostname, "_") {
+			return fmt.Errorf("invalid hostname value (%s)", w.Hostname)
+		}
+	}
+
+	if w.AutomountRoot != "" {
+		if !filepath.IsAbs(w.AutomountRoot) || strings.ContainsAny(w.AutomountRoot, " \t\n") {
+			return fmt.Errorf("invalid automountRoot value (%s):\nmust be an absolute path without whitespace",
+				w.AutomountRoot)
+		}
+	}
+
+	return nil
+}
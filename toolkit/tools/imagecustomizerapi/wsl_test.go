@@ -0,0 +1,62 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerapi
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWslIsValid(t *testing.T) {
+	wsl := Wsl{
+		DefaultUser:        "azureuser",
+		Systemd:            true,
+		Hostname:           "azurelinux-dev",
+		AutomountRoot:      "/mnt/",
+		DisableWindowsPath: true,
+	}
+	err := wsl.IsValid()
+	assert.NoError(t, err)
+}
+
+func TestWslIsValidEmpty(t *testing.T) {
+	wsl := Wsl{}
+	err := wsl.IsValid()
+	assert.NoError(t, err)
+}
+
+func TestWslIsValidInvalidDefaultUser(t *testing.T) {
+	wsl := Wsl{
+		DefaultUser: "azure user",
+	}
+	err := wsl.IsValid()
+	assert.ErrorContains(t, err, "invalid defaultUser value (azure user)")
+}
+
+func TestWslIsValidInvalidHostname(t *testing.T) {
+	wsl := Wsl{
+		Hostname: "azurelinux_dev",
+	}
+	err := wsl.IsValid()
+	assert.ErrorContains(t, err, "invalid hostname value (azurelinux_dev)")
+}
+
+func TestWslIsValidRelativeAutomountRoot(t *testing.T) {
+	wsl := Wsl{
+		AutomountRoot: "mnt",
+	}
+	err := wsl.IsValid()
+	assert.ErrorContains(t, err, "invalid automountRoot value (mnt)")
+}
+
+func TestConfigIsValidInvalidWsl(t *testing.T) {
+	config := Config{
+		Wsl: &Wsl{
+			AutomountRoot: "mnt",
+		},
+	}
+	err := config.IsValid()
+	assert.ErrorContains(t, err, "invalid 'wsl' field")
+}
@@ -0,0 +1,81 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+// Parser for the image builder's configuration schemas.
+
+package configuration
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+const (
+	// ABRootPartitionIDA is the ID (and GPT name) of the generated root partition of slot A, which the image is
+	// installed to.
+	ABRootPartitionIDA = "rootfs-a"
+	// ABRootPartitionIDB is the ID (and GPT name) of the generated root partition of slot B, which is left empty for
+	// the update system to write to.
+	ABRootPartitionIDB = "rootfs-b"
+
+	defaultABRootFsType = "ext4"
+)
+
+// ABRoot generates an A/B (dual-root) layout on a disk, for update systems that write the next version of the OS to
+// the root partition that isn't running, and switch to it.
+// Two root partitions of "Size" MiBs each are added after the disk's [Partitions], and the image is installed to the
+// one of slot A. Each slot keeps its own kernel in its /boot directory, and the ESP's grub.cfg boots the slot that its
+// grubenv selects.
+type ABRoot struct {
+	Enable bool   `json:"Enable"`
+	Size   uint64 `json:"Size"`
+	// The file system type of the root partitions. Defaults to ext4.
+	FsType string `json:"FsType"`
+}
+
+// GetFsType returns the file system type of the root partitions.
+func (a *ABRoot) GetFsType() string {
+	if a.FsType == "" {
+		return defaultABRootFsType
+	}
+	return a.FsType
+}
+
+// IsValid returns an error if the ABRoot is not valid
+func (a *ABRoot) IsValid() (err error) {
+	if !a.Enable {
+		if a.Size != 0 || a.FsType != "" {
+			return fmt.Errorf("[Size] or [FsType] is set, but [Enable] is not")
+		}
+		return
+	}
+
+	if a.Size == 0 {
+		return fmt.Errorf("[Size] of the root partitions must be set")
+	}
+
+	switch a.GetFsType() {
+	case "ext2", "ext3", "ext4", "xfs", BtrfsFsType:
+	default:
+		return fmt.Errorf("invalid [FsType] (%s) for the root partitions", a.FsType)
+	}
+
+	return
+}
+
+// UnmarshalJSON Unmarshals an ABRoot entry
+func (a *ABRoot) UnmarshalJSON(b []byte) (err error) {
+	// Use an intermediate type which will use the default JSON unmarshal implementation
+	type IntermediateTypeABRoot ABRoot
+	err = json.Unmarshal(b, (*IntermediateTypeABRoot)(a))
+	if err != nil {
+		return fmt.Errorf("failed to parse [ABRoot]: %w", err)
+	}
+
+	// Now validate the resulting unmarshaled object
+	err = a.IsValid()
+	if err != nil {
+		return fmt.Errorf("failed to parse [ABRoot]: %w", err)
+	}
+	return
+}
@@ -0,0 +1,183 @@
+// Copyright Microsoft Corporation.
+// Licensed under the MIT License.
+
+package configuration
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestMain found in configuration_test.go.
+
+var (
+	validABRoot       = ABRoot{Enable: true, Size: 4096, FsType: "xfs"}
+	invalidABRootJSON = `{"Enable": true, "Size": "4096"}`
+
+	abRootConfigJSON = `{
+		"Disks": [
+			{
+				"PartitionTableType": "gpt",
+				"MaxSize": 8300,
+				"ABRoot": {"Enable": true, "Size": 4096},
+				"Partitions": [
+					{"ID": "MyEsp", "Flags": ["esp", "boot"], "Start": 1, "End": 9, "FsType": "fat32"},
+					{"ID": "MyVar", "Start": 9, "End": 100, "FsType": "ext4"}
+				]
+			}
+		],
+		"SystemConfigs": [
+			{
+				"Name": "ABRoot",
+				"BootType": "efi",
+				"Packages": ["core-packages-base-image"],
+				"KernelOptions": {"default": "kernel"},
+				"PartitionSettings": [
+					{"ID": "MyEsp", "MountPoint": "/boot/efi"},
+					{"ID": "MyVar", "MountPoint": "/var"},
+					{"ID": "rootfs-a", "MountPoint": "/"}
+				]
+			}
+		]
+	}`
+)
+
+// parseABRootConfig returns the parsed abRootConfigJSON, with its A/B root partitions, that the test can modify.
+func parseABRootConfig(t *testing.T) (testConfig Config) {
+	err := marshalJSONString(abRootConfigJSON, &testConfig)
+	assert.NoError(t, err)
+	return
+}
+
+func TestShouldSucceedParsingDefaultABRoot_ABRoot(t *testing.T) {
+	var checkedABRoot ABRoot
+
+	err := marshalJSONString("{}", &checkedABRoot)
+	assert.NoError(t, err)
+	assert.Equal(t, ABRoot{}, checkedABRoot)
+	assert.Equal(t, "ext4", checkedABRoot.GetFsType())
+}
+
+func TestShouldSucceedParsingValidABRoot_ABRoot(t *testing.T) {
+	var checkedABRoot ABRoot
+
+	assert.NoError(t, validABRoot.IsValid())
+	err := remarshalJSON(validABRoot, &checkedABRoot)
+	assert.NoError(t, err)
+	assert.Equal(t, validABRoot, checkedABRoot)
+	assert.Equal(t, "xfs", checkedABRoot.GetFsType())
+}
+
+func TestShouldFailParsingInvalidJSON_ABRoot(t *testing.T) {
+	var checkedABRoot ABRoot
+
+	err := marshalJSONString(invalidABRootJSON, &checkedABRoot)
+	assert.Error(t, err)
+	assert.Equal(t, "failed to parse [ABRoot]: json: cannot unmarshal string into Go struct field IntermediateTypeABRoot.Size of type uint64", err.Error())
+}
+
+func TestShouldFailMissingSize_ABRoot(t *testing.T) {
+	var checkedABRoot ABRoot
+
+	invalidABRoot := validABRoot
+	invalidABRoot.Size = 0
+	err := invalidABRoot.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "[Size] of the root partitions must be set", err.Error())
+
+	err = remarshalJSON(invalidABRoot, &checkedABRoot)
+	assert.Error(t, err)
+	assert.Equal(t, "failed to parse [ABRoot]: [Size] of the root partitions must be set", err.Error())
+}
+
+func TestShouldFailInvalidFsType_ABRoot(t *testing.T) {
+	invalidABRoot := validABRoot
+	invalidABRoot.FsType = "fat32"
+	err := invalidABRoot.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "invalid [FsType] (fat32) for the root partitions", err.Error())
+}
+
+func TestShouldFailSizeWithoutEnable_ABRoot(t *testing.T) {
+	invalidABRoot := validABRoot
+	invalidABRoot.Enable = false
+	err := invalidABRoot.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "[Size] or [FsType] is set, but [Enable] is not", err.Error())
+}
+
+func TestShouldSucceedAddingABRootPartitions_ABRoot(t *testing.T) {
+	var checkedConfig Config
+	testConfig := parseABRootConfig(t)
+
+	assert.Equal(t, []Partition{
+		{ID: "MyEsp", Flags: []PartitionFlag{"esp", "boot"}, Start: 1, End: 9, FsType: "fat32"},
+		{ID: "MyVar", Start: 9, End: 100, FsType: "ext4"},
+		{ID: "rootfs-a", Name: "rootfs-a", Start: 100, End: 4196, FsType: "ext4"},
+		{ID: "rootfs-b", Name: "rootfs-b", Start: 4196, End: 8292, FsType: "ext4"},
+	}, testConfig.Disks[0].Partitions)
+
+	// The partitions are only added once.
+	err := remarshalJSON(testConfig, &checkedConfig)
+	assert.NoError(t, err)
+	assert.Equal(t, testConfig.Disks[0].Partitions, checkedConfig.Disks[0].Partitions)
+}
+
+func TestShouldFailAddingABRootPartitionsAfterGrowingPartition_ABRoot(t *testing.T) {
+	disk := Disk{
+		PartitionTableType: "gpt",
+		MaxSize:            8300,
+		ABRoot:             ABRoot{Enable: true, Size: 4096},
+		Partitions:         []Partition{{ID: "MyVar", Start: 9, End: 0, FsType: "ext4"}},
+	}
+
+	err := disk.AddABRootPartitions()
+	assert.Error(t, err)
+	assert.Equal(t, "[Partition] (MyVar) has no [End], so the [ABRoot] partitions can't be added after it", err.Error())
+}
+
+func TestShouldFailABRootOnMbrDisk_ABRoot(t *testing.T) {
+	testConfig := parseABRootConfig(t)
+	testConfig.Disks[0].PartitionTableType = PartitionTableTypeMbr
+
+	err := testConfig.Disks[0].IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "invalid [Disk]: [ABRoot] requires a (gpt) [PartitionTableType], not (mbr)", err.Error())
+}
+
+func TestShouldFailABRootWithoutSlotARoot_ABRoot(t *testing.T) {
+	testConfig := parseABRootConfig(t)
+	testConfig.SystemConfigs[0].PartitionSettings[2].ID = "rootfs-b"
+
+	err := testConfig.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "invalid [Config]:\n[SystemConfig] (ABRoot) must mount the [ABRoot] partition (rootfs-a) at (/)", err.Error())
+}
+
+func TestShouldFailABRootMountingSlotB_ABRoot(t *testing.T) {
+	testConfig := parseABRootConfig(t)
+	testConfig.SystemConfigs[0].PartitionSettings[1].ID = "rootfs-b"
+
+	err := testConfig.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "invalid [Config]:\n[SystemConfig] (ABRoot) can't mount the [ABRoot] partition (rootfs-b), which is left for updates", err.Error())
+}
+
+func TestShouldFailABRootWithBootPartition_ABRoot(t *testing.T) {
+	testConfig := parseABRootConfig(t)
+	testConfig.SystemConfigs[0].PartitionSettings[1].MountPoint = "/boot"
+
+	err := testConfig.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "invalid [Config]:\n[SystemConfig] (ABRoot) has an [ABRoot], so it can't have a separate (/boot) partition", err.Error())
+}
+
+func TestShouldFailABRootWithLegacyBoot_ABRoot(t *testing.T) {
+	testConfig := parseABRootConfig(t)
+	testConfig.SystemConfigs[0].BootType = "legacy"
+
+	err := testConfig.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "invalid [Config]:\n[SystemConfig] (ABRoot) has an [ABRoot], so its [BootType] must be (efi)", err.Error())
+}
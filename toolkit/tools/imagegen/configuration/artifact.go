@@ -0,0 +1,89 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+// Parser for the image builder's configuration schemas.
+
+package configuration
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/sliceutils"
+)
+
+var (
+	// artifactTypes are the formats that roast converts a disk or a partition into
+	artifactTypes = []string{"raw", "ext4", "diff", "rdiff", "squashfs", "vhd", "vhdx", "qcow2", "vmdk", "ova", "initrd"}
+	// artifactCompressions are the formats that roast compresses an artifact with
+	artifactCompressions = []string{"gz", "xz", "tar.gz", "tar.xz"}
+)
+
+// Artifact [non-ISO image building only] defines the name, type
+// and optional compression of the output Azure Linux image.
+type Artifact struct {
+	Compression string `json:"Compression"`
+	Name        string `json:"Name"`
+	Type        string `json:"Type"`
+}
+
+// IsValid returns an error if the Artifact is not valid
+func (a *Artifact) IsValid() (err error) {
+	if strings.TrimSpace(a.Name) == "" {
+		return fmt.Errorf("missing [Name] field")
+	}
+
+	if strings.Contains(a.Name, "/") {
+		return fmt.Errorf("invalid [Name] (%s): must not have a '/'", a.Name)
+	}
+
+	if a.Type == "" && a.Compression == "" {
+		return fmt.Errorf("artifact (%s) must have a [Type] or a [Compression]", a.Name)
+	}
+
+	if a.Type != "" && !sliceutils.ContainsValue(artifactTypes, a.Type) {
+		return fmt.Errorf("invalid [Type] (%s) of artifact (%s): must be one of %v", a.Type, a.Name, artifactTypes)
+	}
+
+	if a.Compression != "" && !sliceutils.ContainsValue(artifactCompressions, a.Compression) {
+		return fmt.Errorf("invalid [Compression] (%s) of artifact (%s): must be one of %v", a.Compression, a.Name, artifactCompressions)
+	}
+
+	return
+}
+
+// UnmarshalJSON Unmarshals an Artifact entry
+func (a *Artifact) UnmarshalJSON(b []byte) (err error) {
+	// Use an intermediate type which will use the default JSON unmarshal implementation
+	type IntermediateTypeArtifact Artifact
+	err = json.Unmarshal(b, (*IntermediateTypeArtifact)(a))
+	if err != nil {
+		return fmt.Errorf("failed to parse [Artifact]: %w", err)
+	}
+
+	// Now validate the resulting unmarshaled object
+	err = a.IsValid()
+	if err != nil {
+		return fmt.Errorf("failed to parse [Artifact]: %w", err)
+	}
+	return
+}
+
+// artifactsAreValid returns an error if one of the artifacts is invalid, or if two artifacts would be converted into
+// the same output file.
+func artifactsAreValid(artifacts []Artifact) (err error) {
+	outputs := make(map[Artifact]bool)
+	for _, artifact := range artifacts {
+		err = artifact.IsValid()
+		if err != nil {
+			return
+		}
+
+		if outputs[artifact] {
+			return fmt.Errorf("duplicate artifact (%s) of [Type] (%s) and [Compression] (%s)", artifact.Name, artifact.Type, artifact.Compression)
+		}
+		outputs[artifact] = true
+	}
+	return
+}
@@ -0,0 +1,80 @@
+// Copyright Microsoft Corporation.
+// Licensed under the MIT License.
+
+package configuration
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestMain found in configuration_test.go.
+
+var validArtifacts = []Artifact{
+	{Name: "core", Type: "vhd"},
+	{Name: "core", Type: "qcow2"},
+	{Name: "core", Type: "vmdk"},
+	{Name: "core", Type: "raw", Compression: "xz"},
+	{Name: "rootfs", Compression: "tar.gz"},
+}
+
+func TestShouldSucceedParsingValidArtifacts_Artifact(t *testing.T) {
+	for _, validArtifact := range validArtifacts {
+		var checkedArtifact Artifact
+
+		assert.NoError(t, validArtifact.IsValid())
+		err := remarshalJSON(validArtifact, &checkedArtifact)
+		assert.NoError(t, err)
+		assert.Equal(t, validArtifact, checkedArtifact)
+	}
+
+	assert.NoError(t, artifactsAreValid(validArtifacts))
+}
+
+func TestShouldFailParsingArtifactWithoutFormat_Artifact(t *testing.T) {
+	var checkedArtifact Artifact
+
+	invalidArtifact := Artifact{Name: "core"}
+
+	err := invalidArtifact.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "artifact (core) must have a [Type] or a [Compression]", err.Error())
+
+	err = remarshalJSON(invalidArtifact, &checkedArtifact)
+	assert.Error(t, err)
+	assert.Equal(t, "failed to parse [Artifact]: artifact (core) must have a [Type] or a [Compression]", err.Error())
+}
+
+func TestShouldFailParsingArtifactWithoutName_Artifact(t *testing.T) {
+	invalidArtifact := Artifact{Type: "vhd"}
+
+	err := invalidArtifact.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "missing [Name] field", err.Error())
+}
+
+func TestShouldFailParsingInvalidType_Artifact(t *testing.T) {
+	invalidArtifact := Artifact{Name: "core", Type: "vdi"}
+
+	err := invalidArtifact.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "invalid [Type] (vdi) of artifact (core): must be one of [raw ext4 diff rdiff squashfs vhd vhdx qcow2 vmdk ova initrd]", err.Error())
+}
+
+func TestShouldFailParsingInvalidCompression_Artifact(t *testing.T) {
+	invalidArtifact := Artifact{Name: "core", Type: "raw", Compression: "zip"}
+
+	err := invalidArtifact.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "invalid [Compression] (zip) of artifact (core): must be one of [gz xz tar.gz tar.xz]", err.Error())
+}
+
+func TestShouldFailDuplicateArtifacts_Artifact(t *testing.T) {
+	err := artifactsAreValid([]Artifact{
+		{Name: "core", Type: "vhd"},
+		{Name: "core", Type: "vhd"},
+	})
+	assert.Error(t, err)
+	assert.Equal(t, "duplicate artifact (core) of [Type] (vhd) and [Compression] ()", err.Error())
+}
@@ -0,0 +1,168 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+// Parser for the image builder's configuration schemas.
+
+package configuration
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/file"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/jsonutils"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/logger"
+)
+
+// BatchConfig declares a family of images, which are built in one invocation of the image tools. The packages of the
+// batch config are the shared base of the images, and each image's config only lists the packages that it adds to the
+// base.
+type BatchConfig struct {
+	PackageLists []string     `json:"PackageLists"`
+	Packages     []string     `json:"Packages"`
+	Images       []BatchImage `json:"Images"`
+}
+
+// BatchImage is an image of a batch config.
+type BatchImage struct {
+	// The name of the image, which is also the name of its output directory.
+	Name string `json:"Name"`
+	// The path to the image's config.
+	Config string `json:"Config"`
+}
+
+// IsValid returns an error if the BatchImage is not valid
+func (b *BatchImage) IsValid() (err error) {
+	if strings.TrimSpace(b.Name) == "" {
+		return fmt.Errorf("missing [Name] field")
+	}
+
+	if strings.ContainsAny(b.Name, "/ \t\n") || b.Name == "." || b.Name == ".." {
+		return fmt.Errorf("invalid [Name] (%s): must be a valid directory name", b.Name)
+	}
+
+	if strings.TrimSpace(b.Config) == "" {
+		return fmt.Errorf("image (%s) is missing the [Config] field", b.Name)
+	}
+
+	return
+}
+
+// UnmarshalJSON Unmarshals a BatchImage entry
+func (b *BatchImage) UnmarshalJSON(data []byte) (err error) {
+	// Use an intermediate type which will use the default JSON unmarshal implementation
+	type IntermediateTypeBatchImage BatchImage
+	err = json.Unmarshal(data, (*IntermediateTypeBatchImage)(b))
+	if err != nil {
+		return fmt.Errorf("failed to parse [BatchImage]: %w", err)
+	}
+
+	// Now validate the resulting unmarshaled object
+	err = b.IsValid()
+	if err != nil {
+		return fmt.Errorf("failed to parse [BatchImage]: %w", err)
+	}
+	return
+}
+
+// IsValid returns an error if the BatchConfig is not valid
+func (b *BatchConfig) IsValid() (err error) {
+	if len(b.Images) == 0 {
+		return fmt.Errorf("at least one image must be declared in [Images]")
+	}
+
+	imageNames := make(map[string]bool)
+	for _, image := range b.Images {
+		err = image.IsValid()
+		if err != nil {
+			return fmt.Errorf("invalid [Images]:\n%w", err)
+		}
+
+		if imageNames[image.Name] {
+			return fmt.Errorf("invalid [Images]: duplicate image name (%s)", image.Name)
+		}
+		imageNames[image.Name] = true
+	}
+
+	for _, packageListPath := range b.PackageLists {
+		if strings.TrimSpace(packageListPath) == "" {
+			return fmt.Errorf("invalid [PackageLists]: empty path")
+		}
+	}
+
+	for _, pkg := range b.Packages {
+		if strings.TrimSpace(pkg) == "" {
+			return fmt.Errorf("invalid [Packages]: empty package name")
+		}
+	}
+
+	return
+}
+
+// UnmarshalJSON Unmarshals a BatchConfig entry
+func (b *BatchConfig) UnmarshalJSON(data []byte) (err error) {
+	// Use an intermediate type which will use the default JSON unmarshal implementation
+	type IntermediateTypeBatchConfig BatchConfig
+	err = json.Unmarshal(data, (*IntermediateTypeBatchConfig)(b))
+	if err != nil {
+		return fmt.Errorf("failed to parse [BatchConfig]:\n%w", err)
+	}
+
+	// Now validate the resulting unmarshaled object
+	err = b.IsValid()
+	if err != nil {
+		return fmt.Errorf("failed to parse [BatchConfig]:\n%w", err)
+	}
+	return
+}
+
+// LoadBatchWithAbsolutePaths loads the batch config schema from a JSON file found under the 'batchFilePath', and the
+// config of each of its images. The relative paths of the batch config are resolved using 'baseDirPath' as a starting
+// point, and so are those of the image configs, which are otherwise relative to each image config's directory. The
+// batch config's packages are added to each system config of the images, ahead of the image's own packages.
+func LoadBatchWithAbsolutePaths(batchFilePath, baseDirPath string) (batch BatchConfig, configs []Config, err error) {
+	logger.Log.Debugf("Reading batch config file from '%s'.", batchFilePath)
+
+	err = jsonutils.ReadJSONFile(batchFilePath, &batch)
+	if err != nil {
+		return
+	}
+
+	batchBaseDirPath, err := resolveBaseDirPath(baseDirPath, batchFilePath)
+	if err != nil {
+		err = fmt.Errorf("failed to resolve base directory path (%s) for batch config under (%s):\n%w", baseDirPath, batchFilePath, err)
+		return
+	}
+
+	for i, packageListPath := range batch.PackageLists {
+		batch.PackageLists[i] = file.GetAbsPathWithBase(batchBaseDirPath, packageListPath)
+	}
+
+	for i := range batch.Images {
+		image := &batch.Images[i]
+		image.Config = file.GetAbsPathWithBase(batchBaseDirPath, image.Config)
+
+		var config Config
+		config, err = LoadWithAbsolutePaths(image.Config, baseDirPath)
+		if err != nil {
+			err = fmt.Errorf("failed to load the config of image (%s) from (%s):\n%w", image.Name, image.Config, err)
+			return
+		}
+
+		batch.addBasePackages(&config)
+		configs = append(configs, config)
+	}
+
+	return
+}
+
+// addBasePackages adds the batch config's packages to each system config of an image's config.
+func (b *BatchConfig) addBasePackages(config *Config) {
+	for i := range config.SystemConfigs {
+		systemConfig := &config.SystemConfigs[i]
+		systemConfig.PackageLists = append(slices.Clone(b.PackageLists), systemConfig.PackageLists...)
+		systemConfig.Packages = append(slices.Clone(b.Packages), systemConfig.Packages...)
+	}
+}
@@ -0,0 +1,140 @@
+// Copyright Microsoft Corporation.
+// Licensed under the MIT License.
+
+package configuration
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestMain found in configuration_test.go.
+
+var validBatchConfig = BatchConfig{
+	PackageLists: []string{"packagelists/core-packages-image.json"},
+	Packages:     []string{"openssh-server"},
+	Images: []BatchImage{
+		{Name: "core-efi", Config: "core-efi.json"},
+		{Name: "core-legacy", Config: "core-legacy.json"},
+	},
+}
+
+func TestShouldSucceedParsingDefaultBatchConfig_BatchConfig(t *testing.T) {
+	var checkedBatchConfig BatchConfig
+
+	err := marshalJSONString(`{"Images": [{"Name": "core-efi", "Config": "core-efi.json"}]}`, &checkedBatchConfig)
+	assert.NoError(t, err)
+	assert.Equal(t, BatchConfig{Images: []BatchImage{{Name: "core-efi", Config: "core-efi.json"}}}, checkedBatchConfig)
+}
+
+func TestShouldSucceedParsingValidBatchConfig_BatchConfig(t *testing.T) {
+	var checkedBatchConfig BatchConfig
+
+	assert.NoError(t, validBatchConfig.IsValid())
+	err := remarshalJSON(validBatchConfig, &checkedBatchConfig)
+	assert.NoError(t, err)
+	assert.Equal(t, validBatchConfig, checkedBatchConfig)
+}
+
+func TestShouldFailParsingBatchConfigWithoutImages_BatchConfig(t *testing.T) {
+	var checkedBatchConfig BatchConfig
+
+	invalidBatchConfig := validBatchConfig
+	invalidBatchConfig.Images = nil
+
+	err := invalidBatchConfig.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "at least one image must be declared in [Images]", err.Error())
+
+	err = remarshalJSON(invalidBatchConfig, &checkedBatchConfig)
+	assert.Error(t, err)
+	assert.Equal(t, "failed to parse [BatchConfig]:\nat least one image must be declared in [Images]", err.Error())
+}
+
+func TestShouldFailParsingDuplicateImageNames_BatchConfig(t *testing.T) {
+	invalidBatchConfig := validBatchConfig
+	invalidBatchConfig.Images = []BatchImage{
+		{Name: "core-efi", Config: "core-efi.json"},
+		{Name: "core-efi", Config: "core-efi-fips.json"},
+	}
+
+	err := invalidBatchConfig.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "invalid [Images]: duplicate image name (core-efi)", err.Error())
+}
+
+func TestShouldFailParsingEmptyBasePackage_BatchConfig(t *testing.T) {
+	invalidBatchConfig := validBatchConfig
+	invalidBatchConfig.Packages = []string{" "}
+
+	err := invalidBatchConfig.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "invalid [Packages]: empty package name", err.Error())
+}
+
+func TestShouldFailParsingInvalidImageName_BatchImage(t *testing.T) {
+	var checkedImage BatchImage
+
+	for _, name := range []string{"..", "core/efi", "core efi"} {
+		invalidImage := BatchImage{Name: name, Config: "core-efi.json"}
+
+		err := invalidImage.IsValid()
+		assert.Error(t, err)
+		assert.Equal(t, "invalid [Name] ("+name+"): must be a valid directory name", err.Error())
+
+		err = remarshalJSON(invalidImage, &checkedImage)
+		assert.Error(t, err)
+		assert.Equal(t, "failed to parse [BatchImage]: invalid [Name] ("+name+"): must be a valid directory name", err.Error())
+	}
+}
+
+func TestShouldFailParsingImageWithoutConfig_BatchImage(t *testing.T) {
+	invalidImage := BatchImage{Name: "core-efi"}
+
+	err := invalidImage.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "image (core-efi) is missing the [Config] field", err.Error())
+}
+
+func TestShouldSucceedLoadingBatchConfig_BatchConfig(t *testing.T) {
+	imageConfigPath, err := filepath.Abs("testdata/test_configuration.json")
+	assert.NoError(t, err)
+
+	batchDir := t.TempDir()
+	batchFilePath := filepath.Join(batchDir, "batch.json")
+	err = os.WriteFile(batchFilePath, []byte(`{
+	"PackageLists": ["packagelists/base.json"],
+	"Packages": ["openssh-server"],
+	"Images": [{"Name": "test", "Config": "`+imageConfigPath+`"}]
+}`), 0o644)
+	assert.NoError(t, err)
+
+	batch, configs, err := LoadBatchWithAbsolutePaths(batchFilePath, "")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(batchDir, "packagelists/base.json")}, batch.PackageLists)
+	assert.Len(t, configs, 1)
+
+	// The base packages come first, and the image config's relative paths are relative to its own directory.
+	systemConfig := configs[0].SystemConfigs[0]
+	assert.Equal(t, []string{
+		filepath.Join(batchDir, "packagelists/base.json"),
+		filepath.Join(filepath.Dir(imageConfigPath), "path/to/packages.json"),
+		filepath.Join(filepath.Dir(imageConfigPath), "path/to/extraPackages.json"),
+	}, systemConfig.PackageLists)
+	assert.Equal(t, []string{"openssh-server", "additionalPkgName"}, systemConfig.Packages)
+	assert.Equal(t, []string{"openssh-server"}, configs[0].SystemConfigs[1].Packages)
+	assert.Equal(t, "SmallerDisk", configs[0].DefaultSystemConfig.Name)
+}
+
+func TestShouldFailLoadingBatchConfigWithMissingImageConfig_BatchConfig(t *testing.T) {
+	batchFilePath := filepath.Join(t.TempDir(), "batch.json")
+	err := os.WriteFile(batchFilePath, []byte(`{"Images": [{"Name": "missing", "Config": "missing.json"}]}`), 0o644)
+	assert.NoError(t, err)
+
+	_, _, err = LoadBatchWithAbsolutePaths(batchFilePath, "")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to load the config of image (missing)")
+}
@@ -17,14 +17,6 @@ import (
 	"github.com/microsoft/azurelinux/toolkit/tools/internal/logger"
 )
 
-// Artifact [non-ISO image building only] defines the name, type
-// and optional compression of the output Azure Linux image.
-type Artifact struct {
-	Compression string `json:"Compression"`
-	Name        string `json:"Name"`
-	Type        string `json:"Type"`
-}
-
 // RawBinary allow the users to specify a binary they would
 // like to copy byte-for-byte onto the disk.
 type RawBinary struct {
@@ -57,6 +49,10 @@ type Group struct {
 type RootEncryption struct {
 	Enable   bool   `json:"Enable"`
 	Password string `json:"Password"`
+	// Unlock the encrypted partitions at boot with a TPM2 token, instead of a keyfile in the initramfs.
+	TPM2 TPM2Unlock `json:"TPM2"`
+	// IDs of other partitions to encrypt (e.g. '/var' or '/home'), with the same password and TPM2 token as the root.
+	AdditionalPartitions []string `json:"AdditionalPartitions"`
 }
 
 // Config holds the parsed values of the configuration schemas as well as
@@ -64,6 +60,7 @@ type RootEncryption struct {
 type Config struct {
 	// Values representing the contents of the config JSON file.
 	Disks         []Disk         `json:"Disks"`
+	RaidArrays    []RaidArray    `json:"RaidArrays"`
 	SystemConfigs []SystemConfig `json:"SystemConfigs"`
 
 	// Computed values not present in the config JSON.
@@ -82,6 +79,63 @@ func (c *Config) GetDiskPartByID(ID string) (diskPart *Partition) {
 	return nil
 }
 
+// GetLogicalVolumeByID returns the logical volume with the desired ID and its volume group, nil if no logical volume
+// found
+func (c *Config) GetLogicalVolumeByID(ID string) (volumeGroup *VolumeGroup, logicalVolume *LogicalVolume) {
+	if ID == "" {
+		return nil, nil
+	}
+	for i, d := range c.Disks {
+		for j, v := range d.VolumeGroups {
+			for k, l := range v.LogicalVolumes {
+				if l.ID == ID {
+					return &c.Disks[i].VolumeGroups[j], &c.Disks[i].VolumeGroups[j].LogicalVolumes[k]
+				}
+			}
+		}
+	}
+	return nil, nil
+}
+
+// IsPhysicalVolume returns true if the partition with the ID is a physical volume of a volume group
+func (c *Config) IsPhysicalVolume(ID string) bool {
+	for _, d := range c.Disks {
+		for _, v := range d.VolumeGroups {
+			for _, partID := range v.PhysicalVolumes {
+				if partID == ID {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// GetRaidArrayByID returns the RAID array with the desired ID, nil if no RAID array found
+func (c *Config) GetRaidArrayByID(ID string) (raidArray *RaidArray) {
+	if ID == "" {
+		return nil
+	}
+	for i, r := range c.RaidArrays {
+		if r.ID == ID {
+			return &c.RaidArrays[i]
+		}
+	}
+	return nil
+}
+
+// IsRaidDevice returns true if the partition with the ID is a device of a RAID array
+func (c *Config) IsRaidDevice(ID string) bool {
+	for _, r := range c.RaidArrays {
+		for _, partID := range r.Devices {
+			if partID == ID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 // GetDiskByPartition returns the disk containing the provided partition
 func (c *Config) GetDiskContainingPartition(partition *Partition) (disk *Disk) {
 	ID := partition.ID
@@ -156,6 +210,17 @@ func checkDeviceMapperFlags(config *Config) (err error) {
 			if !rootDiskPart.HasFlag(PartitionFlagDeviceMapperRoot) {
 				return fmt.Errorf("[Partition] (%s) must include 'dmroot' device mapper root flag in [Flags] for [SystemConfig] (%s)'s root partition since it uses [Encryption]", rootDiskPart.ID, sysConfig.Name)
 			}
+
+			for _, partID := range sysConfig.Encryption.AdditionalPartitions {
+				part := config.GetDiskPartByID(partID)
+				if part == nil {
+					return fmt.Errorf("can't find a [Disk] [Partition] to match with [Encryption] [AdditionalPartitions] '%s'", partID)
+				}
+				if part.HasFlag(PartitionFlagDeviceMapperRoot) || part.HasFlag(PartitionFlagBoot) || part.HasFlag(PartitionFlagESP) ||
+					config.IsPhysicalVolume(partID) || config.IsRaidDevice(partID) {
+					return fmt.Errorf("[Partition] (%s) in [Encryption] [AdditionalPartitions] of [SystemConfig] (%s) can't be a root, boot, ESP, LVM physical volume or RAID device partition", partID, sysConfig.Name)
+				}
+			}
 		}
 		// There is currently a limitation in diskutils.CreatePartitions() which requires us to know our device-mapper
 		// partitions prior to parsing the systemconfigs. We won't know if a given systemconfig will require
@@ -179,9 +244,16 @@ func checkDeviceMapperFlags(config *Config) (err error) {
 func checkForMissingDiskPartitions(config *Config) (err error) {
 	for _, sysConfig := range config.SystemConfigs {
 		for _, partSetting := range sysConfig.PartitionSettings {
-			if config.GetDiskPartByID(partSetting.ID) == nil {
+			_, logicalVolume := config.GetLogicalVolumeByID(partSetting.ID)
+			if config.GetDiskPartByID(partSetting.ID) == nil && logicalVolume == nil && config.GetRaidArrayByID(partSetting.ID) == nil {
 				return fmt.Errorf("[SystemConfig] (%s) mounts a [Partition] (%s) which has no corresponding partition on a [Disk]", sysConfig.Name, partSetting.ID)
 			}
+			if config.IsPhysicalVolume(partSetting.ID) {
+				return fmt.Errorf("[SystemConfig] (%s) mounts a [Partition] (%s) which is a physical volume of a volume group", sysConfig.Name, partSetting.ID)
+			}
+			if config.IsRaidDevice(partSetting.ID) {
+				return fmt.Errorf("[SystemConfig] (%s) mounts a [Partition] (%s) which is a device of a RAID array", sysConfig.Name, partSetting.ID)
+			}
 		}
 	}
 	return
@@ -202,6 +274,236 @@ func checkDuplicatePartitionIDs(config *Config) (err error) {
 			}
 		}
 	}
+
+	// Logical volumes are mounted by their ID, like partitions, so their IDs must not collide with the partitions' IDs
+	volumeGroupUsed := make(map[string]int)
+	for i, disk := range config.Disks {
+		for _, volumeGroup := range disk.VolumeGroups {
+			otherDisk, alreadyUsed := volumeGroupUsed[volumeGroup.Name]
+			if alreadyUsed {
+				return fmt.Errorf("a [VolumeGroup] on a [Disk] (%d) shares a name (%s) with another volume group (on disk (%d))", otherDisk, volumeGroup.Name, i)
+			}
+			volumeGroupUsed[volumeGroup.Name] = i
+
+			for _, logicalVolume := range volumeGroup.LogicalVolumes {
+				id := logicalVolume.ID
+				if id == "" {
+					continue
+				}
+				otherDisk, alreadyUsed := idUsed[id]
+				if alreadyUsed {
+					return fmt.Errorf("a [LogicalVolume] on a [Disk] (%d) shares an ID (%s) with another partition or logical volume (on disk (%d))", i, id, otherDisk)
+				}
+				idUsed[id] = i
+			}
+		}
+	}
+
+	// RAID arrays are mounted by their ID too
+	raidArrayNameUsed := make(map[string]bool)
+	raidArrayIDUsed := make(map[string]bool)
+	for _, raidArray := range config.RaidArrays {
+		if raidArrayNameUsed[raidArray.Name] {
+			return fmt.Errorf("two [RaidArrays] are named (%s)", raidArray.Name)
+		}
+		raidArrayNameUsed[raidArray.Name] = true
+
+		otherDisk, alreadyUsed := idUsed[raidArray.ID]
+		if alreadyUsed {
+			return fmt.Errorf("a [RaidArray] (%s) shares an ID (%s) with another partition or logical volume (on disk (%d))", raidArray.Name, raidArray.ID, otherDisk)
+		}
+		if raidArrayIDUsed[raidArray.ID] {
+			return fmt.Errorf("two [RaidArrays] share an ID (%s)", raidArray.ID)
+		}
+		raidArrayIDUsed[raidArray.ID] = true
+	}
+	return
+}
+
+// checkRaidArrays checks that the devices of the RAID arrays are unformatted partitions on different disks, and that
+// the system configs can boot from them.
+func checkRaidArrays(config *Config) (err error) {
+	const (
+		rootMountPoint = "/"
+		bootMountPoint = "/boot"
+		espMountPoint  = "/boot/efi"
+	)
+
+	deviceArrays := make(map[string]string)
+	for _, raidArray := range config.RaidArrays {
+		arrayDisks := make(map[*Disk]bool)
+		for _, partID := range raidArray.Devices {
+			partition := config.GetDiskPartByID(partID)
+			if partition == nil {
+				return fmt.Errorf("RAID array (%s) has a device (%s) which has no corresponding [Partition] on a [Disk]", raidArray.Name, partID)
+			}
+			if partition.FsType != "" || len(partition.Flags) > 0 {
+				return fmt.Errorf("[Partition] (%s) is a device of RAID array (%s), so it can't have a [FsType] or [Flags]", partID, raidArray.Name)
+			}
+			if config.IsPhysicalVolume(partID) {
+				return fmt.Errorf("[Partition] (%s) is a device of RAID array (%s), so it can't be a physical volume of a volume group", partID, raidArray.Name)
+			}
+
+			otherRaidArray, alreadyUsed := deviceArrays[partID]
+			if alreadyUsed {
+				return fmt.Errorf("[Partition] (%s) is a device of two RAID arrays (%s) and (%s)", partID, otherRaidArray, raidArray.Name)
+			}
+			deviceArrays[partID] = raidArray.Name
+
+			// A RAID array with several devices on one disk doesn't survive that disk failing.
+			disk := config.GetDiskContainingPartition(partition)
+			if arrayDisks[disk] {
+				return fmt.Errorf("RAID array (%s) has two devices on the same [Disk], its devices must be on different disks", raidArray.Name)
+			}
+			arrayDisks[disk] = true
+		}
+	}
+
+	// Grub can't read the RAID arrays, so the kernel and the initramfs must be on a partition.
+	for _, sysConfig := range config.SystemConfigs {
+		hasBootPartition := false
+		for _, partSetting := range sysConfig.PartitionSettings {
+			isRaidArray := config.GetRaidArrayByID(partSetting.ID) != nil
+			switch partSetting.MountPoint {
+			case bootMountPoint, espMountPoint:
+				if isRaidArray {
+					return fmt.Errorf("[SystemConfig] (%s) mounts a RAID array (%s) at (%s), which must be a [Partition]", sysConfig.Name, partSetting.ID, partSetting.MountPoint)
+				}
+				hasBootPartition = hasBootPartition || partSetting.MountPoint == bootMountPoint
+			}
+		}
+
+		rootPartSetting := sysConfig.GetRootPartitionSetting()
+		if rootPartSetting != nil && config.GetRaidArrayByID(rootPartSetting.ID) != nil && !hasBootPartition {
+			return fmt.Errorf("[SystemConfig] (%s) mounts a RAID array (%s) at (%s), so it must have a separate (%s) partition", sysConfig.Name, rootPartSetting.ID, rootMountPoint, bootMountPoint)
+		}
+	}
+	return
+}
+
+// checkBtrfsSubvolumes checks that the system configs only mount the subvolumes that their btrfs partitions have, and
+// that they can boot from them.
+func checkBtrfsSubvolumes(config *Config) (err error) {
+	const (
+		rootMountPoint = "/"
+		bootMountPoint = "/boot"
+		espMountPoint  = "/boot/efi"
+	)
+
+	for _, sysConfig := range config.SystemConfigs {
+		hasBootPartition := false
+		partitionSubvolumes := make(map[string]map[string]bool)
+		for _, partSetting := range sysConfig.PartitionSettings {
+			if partSetting.MountPoint == bootMountPoint {
+				hasBootPartition = true
+			}
+
+			if partSetting.Subvolume != "" {
+				diskPart := config.GetDiskPartByID(partSetting.ID)
+				if diskPart == nil || !diskPart.HasSubvolume(partSetting.Subvolume) {
+					return fmt.Errorf("[SystemConfig] (%s) mounts a subvolume (%s) of [Partition] (%s), which isn't in its [Subvolumes]", sysConfig.Name, partSetting.Subvolume, partSetting.ID)
+				}
+
+				// Grub can't find the kernel in a subvolume.
+				if partSetting.MountPoint == bootMountPoint || partSetting.MountPoint == espMountPoint {
+					return fmt.Errorf("[SystemConfig] (%s) mounts a subvolume (%s) at (%s), which must be a [Partition]", sysConfig.Name, partSetting.Subvolume, partSetting.MountPoint)
+				}
+			}
+
+			// A partition can only be mounted several times by mounting different subvolumes of it.
+			if partitionSubvolumes[partSetting.ID] == nil {
+				partitionSubvolumes[partSetting.ID] = make(map[string]bool)
+			} else if partSetting.Subvolume == "" || partitionSubvolumes[partSetting.ID][partSetting.Subvolume] {
+				return fmt.Errorf("[SystemConfig] (%s) mounts [Partition] (%s) twice, without a different [Subvolume] each time", sysConfig.Name, partSetting.ID)
+			}
+			partitionSubvolumes[partSetting.ID][partSetting.Subvolume] = true
+		}
+
+		rootPartSetting := sysConfig.GetRootPartitionSetting()
+		if rootPartSetting != nil && rootPartSetting.Subvolume != "" && !hasBootPartition {
+			return fmt.Errorf("[SystemConfig] (%s) mounts a subvolume (%s) at (%s), so it must have a separate (%s) partition", sysConfig.Name, rootPartSetting.Subvolume, rootMountPoint, bootMountPoint)
+		}
+	}
+	return
+}
+
+// checkABRoot checks that only the first disk has an A/B layout, and that the system configs install to slot A and
+// boot it from the ESP.
+func checkABRoot(config *Config) (err error) {
+	const (
+		bootMountPoint = "/boot"
+		efiBootType    = "efi"
+	)
+
+	for i, disk := range config.Disks {
+		if disk.ABRoot.Enable && i != 0 {
+			return fmt.Errorf("[Disk] (%d) has an [ABRoot], but only the first [Disk] can have one", i)
+		}
+	}
+	if len(config.Disks) == 0 || !config.Disks[0].ABRoot.Enable {
+		return
+	}
+
+	for _, sysConfig := range config.SystemConfigs {
+		if len(sysConfig.PartitionSettings) == 0 {
+			continue
+		}
+
+		rootPartSetting := sysConfig.GetRootPartitionSetting()
+		if rootPartSetting == nil || rootPartSetting.ID != ABRootPartitionIDA {
+			return fmt.Errorf("[SystemConfig] (%s) must mount the [ABRoot] partition (%s) at (/)", sysConfig.Name, ABRootPartitionIDA)
+		}
+
+		for _, partSetting := range sysConfig.PartitionSettings {
+			if partSetting.ID == ABRootPartitionIDB {
+				return fmt.Errorf("[SystemConfig] (%s) can't mount the [ABRoot] partition (%s), which is left for updates", sysConfig.Name, ABRootPartitionIDB)
+			}
+			// Each slot boots its own kernel, from its /boot directory.
+			if partSetting.MountPoint == bootMountPoint {
+				return fmt.Errorf("[SystemConfig] (%s) has an [ABRoot], so it can't have a separate (%s) partition", sysConfig.Name, bootMountPoint)
+			}
+		}
+
+		if sysConfig.BootType != efiBootType {
+			return fmt.Errorf("[SystemConfig] (%s) has an [ABRoot], so its [BootType] must be (%s)", sysConfig.Name, efiBootType)
+		}
+
+		if sysConfig.Encryption.Enable {
+			return fmt.Errorf("[SystemConfig] (%s) has an [ABRoot], so it can't have [Encryption]", sysConfig.Name)
+		}
+	}
+	return
+}
+
+// checkGrowRootPartition checks that the system configs that grow their root partition on the first boot have a root
+// partition that can be grown, as the last partition on its disk.
+func checkGrowRootPartition(config *Config) (err error) {
+	for _, sysConfig := range config.SystemConfigs {
+		if !sysConfig.GrowRootPartition {
+			continue
+		}
+
+		rootPartSetting := sysConfig.GetRootPartitionSetting()
+		if rootPartSetting == nil {
+			return fmt.Errorf("[SystemConfig] (%s) has [GrowRootPartition], but no root ('/') [PartitionSetting]", sysConfig.Name)
+		}
+
+		if sysConfig.Encryption.Enable {
+			return fmt.Errorf("[SystemConfig] (%s) has [GrowRootPartition], so it can't have [Encryption]", sysConfig.Name)
+		}
+
+		// Logical volumes, RAID arrays and the partitions of the [ABRoot] aren't grown.
+		diskPart := config.GetDiskPartByID(rootPartSetting.ID)
+		if diskPart == nil {
+			return fmt.Errorf("[SystemConfig] (%s) has [GrowRootPartition], but its root (%s) isn't a [Partition]", sysConfig.Name, rootPartSetting.ID)
+		}
+		disk := config.GetDiskContainingPartition(diskPart)
+		for _, partition := range disk.Partitions {
+			if partition.Start > diskPart.Start {
+				return fmt.Errorf("[SystemConfig] (%s) has [GrowRootPartition], but its root [Partition] (%s) is followed by [Partition] (%s), so it can't grow", sysConfig.Name, diskPart.ID, partition.ID)
+			}
+		}
+	}
 	return
 }
 
@@ -211,6 +513,12 @@ func checkDuplicatePartitionIDs(config *Config) (err error) {
 func checkInvalidMountIdentifiers(config *Config) (err error) {
 	for _, sysConfig := range config.SystemConfigs {
 		for _, partSetting := range sysConfig.PartitionSettings {
+			_, logicalVolume := config.GetLogicalVolumeByID(partSetting.ID)
+			if logicalVolume != nil || config.GetRaidArrayByID(partSetting.ID) != nil {
+				// Logical volumes and RAID arrays are mounted by their device path, as they have no partition UUID or label.
+				continue
+			}
+
 			if partSetting.MountIdentifier == MountIdentifierPartLabel {
 				diskPart := config.GetDiskPartByID(partSetting.ID)
 				disk := config.GetDiskContainingPartition(diskPart)
@@ -228,6 +536,42 @@ func checkInvalidMountIdentifiers(config *Config) (err error) {
 	return
 }
 
+// checkMountOptions checks that the system configs' mount options and fsck policies are compatible with the file
+// systems of the partitions, logical volumes and RAID arrays that they mount.
+func checkMountOptions(config *Config) (err error) {
+	for _, sysConfig := range config.SystemConfigs {
+		for _, partSetting := range sysConfig.PartitionSettings {
+			fsType := config.getFsTypeByID(partSetting.ID)
+
+			err = mountOptionsAreCompatible(fsType, partSetting.MountOptions)
+			if err != nil {
+				return fmt.Errorf("[SystemConfig] (%s) mounts (%s) with invalid [MountOptions] (%s): %w", sysConfig.Name, partSetting.ID, partSetting.MountOptions, err)
+			}
+
+			// fsck doesn't check btrfs file systems, which are checked when they're mounted.
+			if fsType == BtrfsFsType && partSetting.FsckPolicy != FsckPolicyDefault && partSetting.FsckPolicy != FsckPolicySkip {
+				return fmt.Errorf("[SystemConfig] (%s) mounts (%s) with [FsckPolicy] (%s), but fsck doesn't check a (%s) file system", sysConfig.Name, partSetting.ID, partSetting.FsckPolicy, fsType)
+			}
+		}
+	}
+	return
+}
+
+// getFsTypeByID returns the file system type of the partition, logical volume or RAID array with the ID, or an empty
+// string if there's none.
+func (c *Config) getFsTypeByID(ID string) string {
+	if diskPart := c.GetDiskPartByID(ID); diskPart != nil {
+		return diskPart.FsType
+	}
+	if _, logicalVolume := c.GetLogicalVolumeByID(ID); logicalVolume != nil {
+		return logicalVolume.FsType
+	}
+	if raidArray := c.GetRaidArrayByID(ID); raidArray != nil {
+		return raidArray.FsType
+	}
+	return ""
+}
+
 // IsValid returns an error if the Config is not valid
 func (c *Config) IsValid() (err error) {
 	for _, disk := range c.Disks {
@@ -236,6 +580,12 @@ func (c *Config) IsValid() (err error) {
 		}
 	}
 
+	for _, raidArray := range c.RaidArrays {
+		if err = raidArray.IsValid(); err != nil {
+			return fmt.Errorf("invalid [RaidArrays]:\n%w", err)
+		}
+	}
+
 	// Check that we will be able to reliably find our disk partitions for each SystemConfig
 	err = checkForMissingDiskPartitions(c)
 	if err != nil {
@@ -247,6 +597,26 @@ func (c *Config) IsValid() (err error) {
 		return fmt.Errorf("invalid [Config]:\n%w", err)
 	}
 
+	err = checkRaidArrays(c)
+	if err != nil {
+		return fmt.Errorf("invalid [Config]:\n%w", err)
+	}
+
+	err = checkBtrfsSubvolumes(c)
+	if err != nil {
+		return fmt.Errorf("invalid [Config]:\n%w", err)
+	}
+
+	err = checkABRoot(c)
+	if err != nil {
+		return fmt.Errorf("invalid [Config]:\n%w", err)
+	}
+
+	err = checkGrowRootPartition(c)
+	if err != nil {
+		return fmt.Errorf("invalid [Config]:\n%w", err)
+	}
+
 	// Check the flags for the disks
 	err = checkDeviceMapperFlags(c)
 	if err != nil {
@@ -258,6 +628,11 @@ func (c *Config) IsValid() (err error) {
 		return fmt.Errorf("invalid [Config]:\n%w", err)
 	}
 
+	err = checkMountOptions(c)
+	if err != nil {
+		return fmt.Errorf("invalid [Config]:\n%w", err)
+	}
+
 	if len(c.SystemConfigs) == 0 {
 		return fmt.Errorf("config file must provide at least one system configuration inside the [SystemConfigs] field")
 	}
@@ -345,6 +720,12 @@ func (c *Config) convertToAbsolutePaths(baseDirPath string) {
 		convertPostInstallScriptsPaths(baseDirPath, systemConfig)
 		convertFinalizeImageScriptsPaths(baseDirPath, systemConfig)
 		convertSSHPubKeys(baseDirPath, systemConfig)
+		convertPresetFilesPaths(baseDirPath, systemConfig)
+		convertContainerImagesAuthFilePath(baseDirPath, systemConfig)
+		convertKernelModulesPaths(baseDirPath, systemConfig)
+		convertCACertificatesPaths(baseDirPath, systemConfig)
+		convertReposGPGKeysPaths(baseDirPath, systemConfig)
+		convertPackageSignaturesGPGKeysPaths(baseDirPath, systemConfig)
 	}
 }
 
@@ -395,6 +776,49 @@ func convertSSHPubKeys(baseDirPath string, systemConfig *SystemConfig) {
 	}
 }
 
+func convertPresetFilesPaths(baseDirPath string, systemConfig *SystemConfig) {
+	for i, presetFile := range systemConfig.Services.PresetFiles {
+		systemConfig.Services.PresetFiles[i] = file.GetAbsPathWithBase(baseDirPath, presetFile)
+	}
+}
+
+func convertContainerImagesAuthFilePath(baseDirPath string, systemConfig *SystemConfig) {
+	if systemConfig.ContainerImages.AuthFile != "" {
+		systemConfig.ContainerImages.AuthFile = file.GetAbsPathWithBase(baseDirPath, systemConfig.ContainerImages.AuthFile)
+	}
+}
+
+func convertKernelModulesPaths(baseDirPath string, systemConfig *SystemConfig) {
+	kernelModules := &systemConfig.KernelModules
+	if kernelModules.SigningKey != "" {
+		kernelModules.SigningKey = file.GetAbsPathWithBase(baseDirPath, kernelModules.SigningKey)
+		kernelModules.SigningCert = file.GetAbsPathWithBase(baseDirPath, kernelModules.SigningCert)
+	}
+	for i, module := range kernelModules.Modules {
+		kernelModules.Modules[i].Path = file.GetAbsPathWithBase(baseDirPath, module.Path)
+	}
+}
+
+func convertCACertificatesPaths(baseDirPath string, systemConfig *SystemConfig) {
+	for i, caCertificate := range systemConfig.CACertificates {
+		systemConfig.CACertificates[i] = file.GetAbsPathWithBase(baseDirPath, caCertificate)
+	}
+}
+
+func convertReposGPGKeysPaths(baseDirPath string, systemConfig *SystemConfig) {
+	for i, repo := range systemConfig.Repos {
+		for j, gpgKey := range repo.GPGKeys {
+			systemConfig.Repos[i].GPGKeys[j] = file.GetAbsPathWithBase(baseDirPath, gpgKey)
+		}
+	}
+}
+
+func convertPackageSignaturesGPGKeysPaths(baseDirPath string, systemConfig *SystemConfig) {
+	for i, gpgKey := range systemConfig.PackageSignatures.GPGKeys {
+		systemConfig.PackageSignatures.GPGKeys[i] = file.GetAbsPathWithBase(baseDirPath, gpgKey)
+	}
+}
+
 // resolveBaseDirPath returns an absolute path to the base directory or
 // the absolute path to the config file directory if `baseDirPath` is empty.
 func resolveBaseDirPath(baseDirPath, configFilePath string) (absoluteBaseDirPath string, err error) {
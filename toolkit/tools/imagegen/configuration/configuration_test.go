@@ -95,6 +95,17 @@ func TestShouldFailForUntaggedEncryptionDeviceMapperRoot(t *testing.T) {
 	assert.Equal(t, "failed to parse [Config]:\na config in [SystemConfigs] enables a device mapper based root (Encryption), but partitions are miss-configured:\n[Partition] (MyRootfs) must include 'dmroot' device mapper root flag in [Flags] for [SystemConfig] (SmallerDisk)'s root partition since it uses [Encryption]", err.Error())
 }
 
+func TestShouldFailForEncryptedBootPartition(t *testing.T) {
+	testConfig := expectedConfiguration
+
+	testConfig.SystemConfigs = append([]SystemConfig{}, testConfig.SystemConfigs...)
+	testConfig.SystemConfigs[0].Encryption.AdditionalPartitions = []string{"MyBoot"}
+
+	err := testConfig.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "a config in [SystemConfigs] enables a device mapper based root (Encryption), but partitions are miss-configured:\n[Partition] (MyBoot) in [Encryption] [AdditionalPartitions] of [SystemConfig] (SmallerDisk) can't be a root, boot, ESP, LVM physical volume or RAID device partition", err.Error())
+}
+
 func TestShouldFailDeviceMapperWithNoRootPartitions(t *testing.T) {
 	var checkedConfig Config
 	testConfig := expectedConfiguration
@@ -271,6 +282,38 @@ func TestShouldFailPartLabelWithNoName(t *testing.T) {
 	assert.Equal(t, "failed to parse [Config]:\ninvalid [Config]:\n[SystemConfig] (SmallerDisk) mounts a [Partition] (MyBoot) via PARTLABEL, but it has no [Name]", err.Error())
 }
 
+func TestShouldSucceedGrowingLastRootPartition(t *testing.T) {
+	var checkedConfig Config
+	testConfig := btrfsConfigWithSubvolumes()
+	testConfig.SystemConfigs[0].GrowRootPartition = true
+
+	assert.NoError(t, testConfig.IsValid())
+	err := remarshalJSON(testConfig, &checkedConfig)
+	assert.NoError(t, err)
+	assert.True(t, checkedConfig.SystemConfigs[0].GrowRootPartition)
+}
+
+func TestShouldFailGrowingRootPartitionBeforeAnotherPartition(t *testing.T) {
+	testConfig := btrfsConfigWithSubvolumes()
+	testConfig.SystemConfigs[0].GrowRootPartition = true
+	testConfig.Disks[0].Partitions[2].End = uint64(900)
+	testConfig.Disks[0].Partitions = append(testConfig.Disks[0].Partitions,
+		Partition{ID: "MyData", Start: uint64(900), End: uint64(1024), FsType: "ext4"})
+
+	err := testConfig.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "invalid [Config]:\n[SystemConfig] (Btrfs) has [GrowRootPartition], but its root [Partition] (MyBtrfs) is followed by [Partition] (MyData), so it can't grow", err.Error())
+}
+
+func TestShouldFailGrowingRaidRoot(t *testing.T) {
+	testConfig := copyRaidConfig()
+	testConfig.SystemConfigs[0].GrowRootPartition = true
+
+	err := testConfig.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "invalid [Config]:\n[SystemConfig] (Raid) has [GrowRootPartition], but its root (MyRoot) isn't a [Partition]", err.Error())
+}
+
 func TestShouldSucceedReturnPartitionIndexAndObjectForBootPartition(t *testing.T) {
 	actualConfiguration, err := Load("testdata/test_configuration.json")
 	assert.NoError(t, err)
@@ -0,0 +1,184 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+// Parser for the image builder's configuration schemas.
+
+package configuration
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	// ContainerRuntimeContainerd imports the container images into containerd
+	ContainerRuntimeContainerd = "containerd"
+	// ContainerRuntimeDocker loads the container images into docker
+	ContainerRuntimeDocker = "docker"
+
+	defaultContainerdNamespace = "k8s.io"
+)
+
+// containerdNamespaceRegex matches the names that containerd accepts for namespaces.
+var containerdNamespaceRegex = regexp.MustCompile(`^[A-Za-z0-9]+(?:[._-][A-Za-z0-9]+)*$`)
+
+// ContainerImages are the OCI images to preload into the image, so that its workloads don't need to pull them on the
+// first boot.
+// The images are pulled with skopeo when the image is built, and are imported into the container runtime's storage
+// by a service that runs once the runtime has started on the first boot.
+type ContainerImages struct {
+	// The runtime to import the images into: containerd (the default) or docker.
+	Runtime string `json:"Runtime"`
+	// The containerd namespace to import the images into. Defaults to k8s.io, which Kubernetes uses.
+	Namespace string `json:"Namespace"`
+	// A local registry credentials file (in the containers-auth.json format) to pull the images with.
+	AuthFile        string           `json:"AuthFile"`
+	RegistryMirrors []RegistryMirror `json:"RegistryMirrors"`
+	Images          []ContainerImage `json:"Images"`
+}
+
+// ContainerImage is an OCI image, which is referenced with its registry and its tag, and optionally its digest (e.g.
+// "mcr.microsoft.com/azurelinux/base/core:3.0").
+// "Architecture" selects the image of a multi-architecture image, and defaults to the build machine's architecture.
+type ContainerImage struct {
+	Reference    string `json:"Reference"`
+	Architecture string `json:"Architecture"`
+}
+
+// RegistryMirror is a list of registries that the images of "Registry" are pulled from first, when the image is
+// built.
+type RegistryMirror struct {
+	Registry string   `json:"Registry"`
+	Mirrors  []string `json:"Mirrors"`
+}
+
+// IsEmpty returns true if there are no images to preload.
+func (c *ContainerImages) IsEmpty() bool {
+	return len(c.Images) == 0
+}
+
+// GetRuntime returns the container runtime that the images are imported into.
+func (c *ContainerImages) GetRuntime() string {
+	if c.Runtime == "" {
+		return ContainerRuntimeContainerd
+	}
+	return c.Runtime
+}
+
+// GetNamespace returns the containerd namespace that the images are imported into.
+func (c *ContainerImages) GetNamespace() string {
+	if c.Namespace == "" {
+		return defaultContainerdNamespace
+	}
+	return c.Namespace
+}
+
+// IsValid returns an error if the ContainerImages are not valid
+func (c *ContainerImages) IsValid() (err error) {
+	if c.IsEmpty() {
+		if c.Runtime != "" || c.Namespace != "" || c.AuthFile != "" || len(c.RegistryMirrors) > 0 {
+			return fmt.Errorf("[Runtime], [Namespace], [AuthFile] or [RegistryMirrors] is set, but there are no [Images]")
+		}
+		return
+	}
+
+	switch c.GetRuntime() {
+	case ContainerRuntimeContainerd:
+		if !containerdNamespaceRegex.MatchString(c.GetNamespace()) {
+			return fmt.Errorf("invalid [Namespace] (%s)", c.Namespace)
+		}
+	case ContainerRuntimeDocker:
+		if c.Namespace != "" {
+			return fmt.Errorf("[Namespace] is only used by the (%s) [Runtime]", ContainerRuntimeContainerd)
+		}
+	default:
+		return fmt.Errorf("invalid [Runtime] (%s), must be (%s) or (%s)", c.Runtime, ContainerRuntimeContainerd, ContainerRuntimeDocker)
+	}
+
+	references := make(map[string]bool)
+	for _, image := range c.Images {
+		if err = image.IsValid(); err != nil {
+			return
+		}
+		if references[image.Reference] {
+			return fmt.Errorf("image (%s) is in [Images] twice", image.Reference)
+		}
+		references[image.Reference] = true
+	}
+
+	registries := make(map[string]bool)
+	for _, registryMirror := range c.RegistryMirrors {
+		if err = registryMirror.IsValid(); err != nil {
+			return
+		}
+		if registries[registryMirror.Registry] {
+			return fmt.Errorf("registry (%s) is in [RegistryMirrors] twice", registryMirror.Registry)
+		}
+		registries[registryMirror.Registry] = true
+	}
+
+	return
+}
+
+// IsValid returns an error if the ContainerImage is not valid
+func (i *ContainerImage) IsValid() (err error) {
+	// The images are pulled without any search registries, so that they are always pulled from the same registry.
+	registry, repository, found := strings.Cut(i.Reference, "/")
+	if !found || strings.ContainsAny(i.Reference, " \t\n") ||
+		(!strings.ContainsAny(registry, ".:") && registry != "localhost") {
+		return fmt.Errorf("invalid image reference (%s), must start with the image's registry, e.g. mcr.microsoft.com/azurelinux/base/core:3.0", i.Reference)
+	}
+
+	// The images are stored with their tags until they are imported, so they must have one. They may also be pinned
+	// with a digest after their tag.
+	name, _, _ := strings.Cut(repository, "@")
+	if !strings.Contains(name[strings.LastIndex(name, "/")+1:], ":") {
+		return fmt.Errorf("image (%s) must have a tag", i.Reference)
+	}
+
+	switch i.Architecture {
+	case "", "amd64", "arm64":
+	default:
+		return fmt.Errorf("invalid [Architecture] (%s) of image (%s), must be amd64 or arm64", i.Architecture, i.Reference)
+	}
+
+	return
+}
+
+// IsValid returns an error if the RegistryMirror is not valid
+func (r *RegistryMirror) IsValid() (err error) {
+	if r.Registry == "" || strings.Contains(r.Registry, "://") || strings.ContainsAny(r.Registry, " \t\n\"") {
+		return fmt.Errorf("invalid [Registry] (%s) of a registry mirror, must be a registry without a scheme, e.g. docker.io", r.Registry)
+	}
+
+	if len(r.Mirrors) == 0 {
+		return fmt.Errorf("registry (%s) must have at least one of [Mirrors]", r.Registry)
+	}
+
+	for _, mirror := range r.Mirrors {
+		if mirror == "" || strings.Contains(mirror, "://") || strings.ContainsAny(mirror, " \t\n\"") {
+			return fmt.Errorf("invalid mirror (%s) of registry (%s), must be a registry without a scheme", mirror, r.Registry)
+		}
+	}
+
+	return
+}
+
+// UnmarshalJSON Unmarshals a ContainerImages entry
+func (c *ContainerImages) UnmarshalJSON(b []byte) (err error) {
+	// Use an intermediate type which will use the default JSON unmarshal implementation
+	type IntermediateTypeContainerImages ContainerImages
+	err = json.Unmarshal(b, (*IntermediateTypeContainerImages)(c))
+	if err != nil {
+		return fmt.Errorf("failed to parse [ContainerImages]: %w", err)
+	}
+
+	// Now validate the resulting unmarshaled object
+	err = c.IsValid()
+	if err != nil {
+		return fmt.Errorf("failed to parse [ContainerImages]: %w", err)
+	}
+	return
+}
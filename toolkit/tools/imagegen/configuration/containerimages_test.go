@@ -0,0 +1,163 @@
+// Copyright Microsoft Corporation.
+// Licensed under the MIT License.
+
+package configuration
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestMain found in configuration_test.go.
+
+var (
+	validContainerImages = ContainerImages{
+		Runtime:  "containerd",
+		AuthFile: "registry/auth.json",
+		RegistryMirrors: []RegistryMirror{
+			{Registry: "docker.io", Mirrors: []string{"mirror.contoso.com/docker.io"}},
+		},
+		Images: []ContainerImage{
+			{Reference: "mcr.microsoft.com/azurelinux/base/core:3.0"},
+			{Reference: "docker.io/library/nginx:1.27@sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef", Architecture: "arm64"},
+			{Reference: "localhost:5000/edge/agent:v2"},
+		},
+	}
+	invalidContainerImagesJSON = `{"Images": ["mcr.microsoft.com/azurelinux/base/core:3.0"]}`
+)
+
+// copyValidContainerImages returns a copy of validContainerImages, whose lists the test can modify.
+func copyValidContainerImages() (containerImages ContainerImages) {
+	containerImages = validContainerImages
+	containerImages.Images = append([]ContainerImage(nil), validContainerImages.Images...)
+	containerImages.RegistryMirrors = append([]RegistryMirror(nil), validContainerImages.RegistryMirrors...)
+	return
+}
+
+func TestShouldSucceedParsingDefaultContainerImages_ContainerImages(t *testing.T) {
+	var checkedContainerImages ContainerImages
+
+	err := marshalJSONString("{}", &checkedContainerImages)
+	assert.NoError(t, err)
+	assert.Equal(t, ContainerImages{}, checkedContainerImages)
+	assert.True(t, checkedContainerImages.IsEmpty())
+	assert.Equal(t, ContainerRuntimeContainerd, checkedContainerImages.GetRuntime())
+	assert.Equal(t, "k8s.io", checkedContainerImages.GetNamespace())
+}
+
+func TestShouldSucceedParsingValidContainerImages_ContainerImages(t *testing.T) {
+	var checkedContainerImages ContainerImages
+
+	assert.NoError(t, validContainerImages.IsValid())
+	err := remarshalJSON(validContainerImages, &checkedContainerImages)
+	assert.NoError(t, err)
+	assert.Equal(t, validContainerImages, checkedContainerImages)
+	assert.False(t, checkedContainerImages.IsEmpty())
+}
+
+func TestShouldFailParsingInvalidJSON_ContainerImages(t *testing.T) {
+	var checkedContainerImages ContainerImages
+
+	err := marshalJSONString(invalidContainerImagesJSON, &checkedContainerImages)
+	assert.Error(t, err)
+	assert.Equal(t, "failed to parse [ContainerImages]: json: cannot unmarshal string into IntermediateTypeContainerImages.Images.0 of type configuration.ContainerImage", err.Error())
+}
+
+func TestShouldFailSettingsWithoutImages_ContainerImages(t *testing.T) {
+	var checkedContainerImages ContainerImages
+
+	invalidContainerImages := copyValidContainerImages()
+	invalidContainerImages.Images = nil
+
+	err := remarshalJSON(invalidContainerImages, &checkedContainerImages)
+	assert.Error(t, err)
+	assert.Equal(t, "failed to parse [ContainerImages]: [Runtime], [Namespace], [AuthFile] or [RegistryMirrors] is set, but there are no [Images]", err.Error())
+}
+
+func TestShouldFailInvalidRuntime_ContainerImages(t *testing.T) {
+	invalidContainerImages := copyValidContainerImages()
+	invalidContainerImages.Runtime = "podman"
+
+	err := invalidContainerImages.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "invalid [Runtime] (podman), must be (containerd) or (docker)", err.Error())
+}
+
+func TestShouldFailInvalidNamespace_ContainerImages(t *testing.T) {
+	invalidContainerImages := copyValidContainerImages()
+	invalidContainerImages.Namespace = "k8s io"
+
+	err := invalidContainerImages.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "invalid [Namespace] (k8s io)", err.Error())
+}
+
+func TestShouldFailNamespaceWithDocker_ContainerImages(t *testing.T) {
+	invalidContainerImages := copyValidContainerImages()
+	invalidContainerImages.Runtime = "docker"
+	invalidContainerImages.Namespace = "moby"
+
+	err := invalidContainerImages.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "[Namespace] is only used by the (containerd) [Runtime]", err.Error())
+}
+
+func TestShouldFailImageWithoutRegistry_ContainerImages(t *testing.T) {
+	invalidContainerImages := copyValidContainerImages()
+	invalidContainerImages.Images[0].Reference = "library/nginx:1.27"
+
+	err := invalidContainerImages.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "invalid image reference (library/nginx:1.27), must start with the image's registry, e.g. mcr.microsoft.com/azurelinux/base/core:3.0", err.Error())
+}
+
+func TestShouldFailImageWithoutTag_ContainerImages(t *testing.T) {
+	for _, reference := range []string{
+		"localhost:5000/edge/agent",
+		"docker.io/library/nginx@sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef",
+	} {
+		invalidContainerImages := copyValidContainerImages()
+		invalidContainerImages.Images[0].Reference = reference
+
+		err := invalidContainerImages.IsValid()
+		assert.Error(t, err)
+		assert.Equal(t, "image ("+reference+") must have a tag", err.Error())
+	}
+}
+
+func TestShouldFailDuplicateImage_ContainerImages(t *testing.T) {
+	invalidContainerImages := copyValidContainerImages()
+	invalidContainerImages.Images = append(invalidContainerImages.Images, invalidContainerImages.Images[0])
+
+	err := invalidContainerImages.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "image (mcr.microsoft.com/azurelinux/base/core:3.0) is in [Images] twice", err.Error())
+}
+
+func TestShouldFailInvalidArchitecture_ContainerImages(t *testing.T) {
+	invalidContainerImages := copyValidContainerImages()
+	invalidContainerImages.Images[0].Architecture = "x86_64"
+
+	err := invalidContainerImages.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "invalid [Architecture] (x86_64) of image (mcr.microsoft.com/azurelinux/base/core:3.0), must be amd64 or arm64", err.Error())
+}
+
+func TestShouldFailMirrorWithScheme_ContainerImages(t *testing.T) {
+	invalidContainerImages := copyValidContainerImages()
+	invalidContainerImages.RegistryMirrors[0] = RegistryMirror{Registry: "docker.io", Mirrors: []string{"https://mirror.contoso.com"}}
+
+	err := invalidContainerImages.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "invalid mirror (https://mirror.contoso.com) of registry (docker.io), must be a registry without a scheme", err.Error())
+}
+
+func TestShouldFailRegistryWithoutMirrors_ContainerImages(t *testing.T) {
+	invalidContainerImages := copyValidContainerImages()
+	invalidContainerImages.RegistryMirrors[0] = RegistryMirror{Registry: "docker.io"}
+
+	err := invalidContainerImages.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "registry (docker.io) must have at least one of [Mirrors]", err.Error())
+}
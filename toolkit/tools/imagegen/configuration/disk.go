@@ -23,6 +23,40 @@ type Disk struct {
 	Artifacts          []Artifact         `json:"Artifacts"`
 	Partitions         []Partition        `json:"Partitions"`
 	RawBinaries        []RawBinary        `json:"RawBinaries"`
+	VolumeGroups       []VolumeGroup      `json:"VolumeGroups"`
+	ABRoot             ABRoot             `json:"ABRoot"`
+}
+
+// AddABRootPartitions adds the root partitions of the A/B layout after the disk's last partition, if [ABRoot] is
+// enabled and they haven't been added yet.
+func (d *Disk) AddABRootPartitions() (err error) {
+	if !d.ABRoot.Enable {
+		return
+	}
+
+	lastPartitionEnd := uint64(0)
+	for _, part := range d.Partitions {
+		if part.ID == ABRootPartitionIDA || part.ID == ABRootPartitionIDB {
+			return
+		}
+
+		if part.End == 0 {
+			return fmt.Errorf("[Partition] (%s) has no [End], so the [ABRoot] partitions can't be added after it", part.ID)
+		}
+		lastPartitionEnd = max(lastPartitionEnd, part.End)
+	}
+
+	for _, partID := range []string{ABRootPartitionIDA, ABRootPartitionIDB} {
+		d.Partitions = append(d.Partitions, Partition{
+			ID:     partID,
+			Name:   partID,
+			Start:  lastPartitionEnd,
+			End:    lastPartitionEnd + d.ABRoot.Size,
+			FsType: d.ABRoot.GetFsType(),
+		})
+		lastPartitionEnd += d.ABRoot.Size
+	}
+	return
 }
 
 // checkOverlappingPartitions checks that start and end positions of the defined partitions don't overlap.
@@ -45,6 +79,41 @@ func checkOverlappingePartitions(disk *Disk) (err error) {
 	return
 }
 
+// checkVolumeGroups checks that the physical volumes of the volume groups are unformatted partitions of the disk, and
+// that each partition is in at most one volume group.
+func checkVolumeGroups(disk *Disk) (err error) {
+	volumeGroupNames := make(map[string]bool)
+	physicalVolumeGroups := make(map[string]string)
+	for _, volumeGroup := range disk.VolumeGroups {
+		if volumeGroupNames[volumeGroup.Name] {
+			return fmt.Errorf("two [VolumeGroups] are named (%s)", volumeGroup.Name)
+		}
+		volumeGroupNames[volumeGroup.Name] = true
+
+		for _, partID := range volumeGroup.PhysicalVolumes {
+			var partition *Partition
+			for i := range disk.Partitions {
+				if disk.Partitions[i].ID == partID {
+					partition = &disk.Partitions[i]
+				}
+			}
+			if partition == nil {
+				return fmt.Errorf("volume group (%s) has a physical volume (%s) which has no corresponding [Partition] on the [Disk]", volumeGroup.Name, partID)
+			}
+			if partition.FsType != "" || len(partition.Flags) > 0 {
+				return fmt.Errorf("[Partition] (%s) is a physical volume of volume group (%s), so it can't have a [FsType] or [Flags]", partID, volumeGroup.Name)
+			}
+
+			otherVolumeGroup, alreadyUsed := physicalVolumeGroups[partID]
+			if alreadyUsed {
+				return fmt.Errorf("[Partition] (%s) is a physical volume of two volume groups (%s) and (%s)", partID, otherVolumeGroup, volumeGroup.Name)
+			}
+			physicalVolumeGroups[partID] = volumeGroup.Name
+		}
+	}
+	return
+}
+
 // checkMaxSizeCorrectness checks that MaxSize is non-zero for cases in which it's used to clear disk space. This check
 // also confirms that the MaxSize defined is large enough to accomodate all partitions. No partition should have an
 // end position that exceeds the MaxSize
@@ -95,19 +164,34 @@ func (d *Disk) IsValid() (err error) {
 		return fmt.Errorf("invalid [Disk]: %w", err)
 	}
 
+	err = checkVolumeGroups(d)
+	if err != nil {
+		return fmt.Errorf("invalid [Disk]: %w", err)
+	}
+
+	if err = d.ABRoot.IsValid(); err != nil {
+		return fmt.Errorf("invalid [ABRoot]: %w", err)
+	}
+	if d.ABRoot.Enable && d.PartitionTableType != PartitionTableTypeGpt {
+		return fmt.Errorf("invalid [Disk]: [ABRoot] requires a (%s) [PartitionTableType], not (%s)", PartitionTableTypeGpt, d.PartitionTableType)
+	}
+
 	// if err = disk.PartitionTableType.IsValid(); err != nil {
 	// 	return
 	// }
-	// for _, artifact := range disk.Artifacts {
-	// 	if err = artifact.IsValid(); err != nil {
-	// 		return
-	// 	}
-	// }
+	if err = artifactsAreValid(d.Artifacts); err != nil {
+		return fmt.Errorf("invalid [Artifacts]: %w", err)
+	}
 	for _, partition := range d.Partitions {
 		if err = partition.IsValid(); err != nil {
 			return
 		}
 	}
+	for _, volumeGroup := range d.VolumeGroups {
+		if err = volumeGroup.IsValid(); err != nil {
+			return fmt.Errorf("invalid [VolumeGroup]: %w", err)
+		}
+	}
 	// for _, rawBinary := range disk.RawBinaries {
 	// 	if err = rawBinary.IsValid(); err != nil {
 	// 		return
@@ -125,6 +209,11 @@ func (d *Disk) UnmarshalJSON(b []byte) (err error) {
 		return fmt.Errorf("failed to parse [Disk]: %w", err)
 	}
 
+	err = d.AddABRootPartitions()
+	if err != nil {
+		return fmt.Errorf("failed to parse [Disk]: %w", err)
+	}
+
 	// Now validate the resulting unmarshalled object
 	err = d.IsValid()
 	if err != nil {
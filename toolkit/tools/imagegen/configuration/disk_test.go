@@ -150,3 +150,67 @@ func TestShouldFailMaxSizeInsufficient(t *testing.T) {
 	assert.Error(t, err)
 	assert.Equal(t, "failed to parse [Disk]: invalid [Disk]: the MaxSize of 512 is not large enough to accomodate defined partitions ending at 1024", err.Error())
 }
+
+func TestShouldSucceedParsingVolumeGroups_Disk(t *testing.T) {
+	var checkedDisk Disk
+	lvmDisk := validDisk
+
+	lvmDisk.Partitions = []Partition{
+		validDisk.Partitions[0],
+		{
+			ID:    "MyPV",
+			Start: uint64(9),
+			End:   uint64(1024),
+		},
+	}
+	lvmDisk.VolumeGroups = []VolumeGroup{validVolumeGroups[0]}
+
+	assert.NoError(t, lvmDisk.IsValid())
+	err := remarshalJSON(lvmDisk, &checkedDisk)
+	assert.NoError(t, err)
+	assert.Equal(t, lvmDisk, checkedDisk)
+}
+
+func TestShouldFailPhysicalVolumeWithFsType(t *testing.T) {
+	invalidDisk := validDisk
+
+	invalidDisk.VolumeGroups = []VolumeGroup{{
+		Name:            "rootvg",
+		PhysicalVolumes: []string{"MyRootfs"},
+		LogicalVolumes:  validVolumeGroups[0].LogicalVolumes,
+	}}
+
+	err := invalidDisk.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "invalid [Disk]: [Partition] (MyRootfs) is a physical volume of volume group (rootvg), so it can't have a [FsType] or [Flags]", err.Error())
+}
+
+func TestShouldFailMissingPhysicalVolume(t *testing.T) {
+	invalidDisk := validDisk
+
+	invalidDisk.VolumeGroups = []VolumeGroup{validVolumeGroups[0]}
+
+	err := invalidDisk.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "invalid [Disk]: volume group (rootvg) has a physical volume (MyPV) which has no corresponding [Partition] on the [Disk]", err.Error())
+}
+
+func TestShouldFailPhysicalVolumeInTwoVolumeGroups(t *testing.T) {
+	invalidDisk := validDisk
+
+	invalidDisk.Partitions = []Partition{
+		validDisk.Partitions[0],
+		{
+			ID:    "MyPV",
+			Start: uint64(9),
+			End:   uint64(1024),
+		},
+	}
+	otherVolumeGroup := validVolumeGroups[0]
+	otherVolumeGroup.Name = "othervg"
+	invalidDisk.VolumeGroups = []VolumeGroup{validVolumeGroups[0], otherVolumeGroup}
+
+	err := invalidDisk.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "invalid [Disk]: [Partition] (MyPV) is a physical volume of two volume groups (rootvg) and (othervg)", err.Error())
+}
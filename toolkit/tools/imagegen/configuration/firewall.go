@@ -0,0 +1,191 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+// Parser for the image builder's configuration schemas.
+
+package configuration
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/netip"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	// FirewallPolicyDrop drops the incoming packets that no rule accepts
+	FirewallPolicyDrop = "drop"
+	// FirewallPolicyAccept accepts the incoming packets that no rule drops
+	FirewallPolicyAccept = "accept"
+)
+
+// zoneNameRegex matches the zone names that can be used in the names of nftables chains.
+var zoneNameRegex = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]*$`)
+
+// Firewall generates an nftables ruleset for the image, which filters its incoming packets, and enables the nftables
+// service that loads it.
+// Established connections, the loopback interface and ICMP are always accepted. "OpenPorts" are accepted on every
+// interface, and the "OpenPorts" of each of the "Zones" only on its interfaces, or from its source addresses.
+// "Rules" are raw nftables rules, which are added to the end of the input chain.
+type Firewall struct {
+	Enable bool `json:"Enable"`
+	// The policy of incoming packets that no rule matches. Defaults to drop.
+	InputPolicy string         `json:"InputPolicy"`
+	OpenPorts   []string       `json:"OpenPorts"`
+	Zones       []FirewallZone `json:"Zones"`
+	Rules       []string       `json:"Rules"`
+}
+
+// FirewallZone opens ports for the packets that come in on its "Interfaces", or from its "Sources" (IP addresses, or
+// networks with their prefix lengths).
+type FirewallZone struct {
+	Name       string   `json:"Name"`
+	Interfaces []string `json:"Interfaces"`
+	Sources    []string `json:"Sources"`
+	OpenPorts  []string `json:"OpenPorts"`
+}
+
+// FirewallPort is a port, or a range of ports, of a protocol, which is written as "22/tcp" or "8000-8080/udp".
+type FirewallPort struct {
+	Protocol string
+	Start    uint16
+	End      uint16
+}
+
+// GetInputPolicy returns the policy of incoming packets that no rule matches.
+func (f *Firewall) GetInputPolicy() string {
+	if f.InputPolicy == "" {
+		return FirewallPolicyDrop
+	}
+	return f.InputPolicy
+}
+
+// ParseFirewallPort parses a port, or a range of ports, with its protocol (e.g. "22/tcp" or "8000-8080/udp").
+func ParseFirewallPort(port string) (firewallPort FirewallPort, err error) {
+	ports, protocol, found := strings.Cut(port, "/")
+	if !found || (protocol != "tcp" && protocol != "udp") {
+		err = fmt.Errorf("invalid port (%s), must be a port or a range of ports with a tcp or udp protocol, e.g. 22/tcp or 8000-8080/udp", port)
+		return
+	}
+
+	startPort, endPort, isRange := strings.Cut(ports, "-")
+	if !isRange {
+		endPort = startPort
+	}
+
+	start, startErr := strconv.ParseUint(startPort, 10, 16)
+	end, endErr := strconv.ParseUint(endPort, 10, 16)
+	if startErr != nil || endErr != nil || start == 0 || end < start {
+		err = fmt.Errorf("invalid port (%s), ports must be from 1 to 65535, with the lower port of a range first", port)
+		return
+	}
+
+	firewallPort = FirewallPort{Protocol: protocol, Start: uint16(start), End: uint16(end)}
+	return
+}
+
+// String returns the port, or range of ports, as it's written in nftables rules.
+func (p FirewallPort) String() string {
+	if p.Start == p.End {
+		return strconv.FormatUint(uint64(p.Start), 10)
+	}
+	return fmt.Sprintf("%d-%d", p.Start, p.End)
+}
+
+// IsValid returns an error if the Firewall is not valid
+func (f *Firewall) IsValid() (err error) {
+	if !f.Enable {
+		if f.InputPolicy != "" || len(f.OpenPorts) > 0 || len(f.Zones) > 0 || len(f.Rules) > 0 {
+			return fmt.Errorf("[InputPolicy], [OpenPorts], [Zones] or [Rules] is set, but [Enable] is not")
+		}
+		return
+	}
+
+	switch f.GetInputPolicy() {
+	case FirewallPolicyDrop, FirewallPolicyAccept:
+	default:
+		return fmt.Errorf("invalid [InputPolicy] (%s), must be (%s) or (%s)", f.InputPolicy, FirewallPolicyDrop, FirewallPolicyAccept)
+	}
+
+	if err = firewallPortsAreValid(f.OpenPorts); err != nil {
+		return
+	}
+
+	zoneNames := make(map[string]bool)
+	for _, zone := range f.Zones {
+		if err = zone.IsValid(); err != nil {
+			return
+		}
+		if zoneNames[zone.Name] {
+			return fmt.Errorf("two [Zones] are named (%s)", zone.Name)
+		}
+		zoneNames[zone.Name] = true
+	}
+
+	for _, rule := range f.Rules {
+		if strings.TrimSpace(rule) == "" || strings.ContainsAny(rule, "\n\r") {
+			return fmt.Errorf("invalid rule (%s), must be a single line", rule)
+		}
+	}
+
+	return
+}
+
+// IsValid returns an error if the FirewallZone is not valid
+func (z *FirewallZone) IsValid() (err error) {
+	if !zoneNameRegex.MatchString(z.Name) {
+		return fmt.Errorf("invalid zone name (%s), must start with a letter, and only have letters, digits and '_'", z.Name)
+	}
+
+	if len(z.Interfaces) == 0 && len(z.Sources) == 0 {
+		return fmt.Errorf("zone (%s) must have [Interfaces] or [Sources]", z.Name)
+	}
+
+	for _, iface := range z.Interfaces {
+		if err = interfaceNameIsValid(iface); err != nil {
+			return fmt.Errorf("invalid interface of zone (%s): %w", z.Name, err)
+		}
+	}
+
+	for _, source := range z.Sources {
+		_, prefixErr := netip.ParsePrefix(source)
+		_, addrErr := netip.ParseAddr(source)
+		if prefixErr != nil && addrErr != nil {
+			return fmt.Errorf("invalid source (%s) of zone (%s), must be an IP address, or a network with its prefix length", source, z.Name)
+		}
+	}
+
+	if err = firewallPortsAreValid(z.OpenPorts); err != nil {
+		return fmt.Errorf("invalid zone (%s): %w", z.Name, err)
+	}
+
+	return
+}
+
+func firewallPortsAreValid(ports []string) (err error) {
+	for _, port := range ports {
+		if _, err = ParseFirewallPort(port); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// UnmarshalJSON Unmarshals a Firewall entry
+func (f *Firewall) UnmarshalJSON(b []byte) (err error) {
+	// Use an intermediate type which will use the default JSON unmarshal implementation
+	type IntermediateTypeFirewall Firewall
+	err = json.Unmarshal(b, (*IntermediateTypeFirewall)(f))
+	if err != nil {
+		return fmt.Errorf("failed to parse [Firewall]: %w", err)
+	}
+
+	// Now validate the resulting unmarshaled object
+	err = f.IsValid()
+	if err != nil {
+		return fmt.Errorf("failed to parse [Firewall]: %w", err)
+	}
+	return
+}
@@ -0,0 +1,163 @@
+// Copyright Microsoft Corporation.
+// Licensed under the MIT License.
+
+package configuration
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestMain found in configuration_test.go.
+
+var (
+	validFirewall = Firewall{
+		Enable:    true,
+		OpenPorts: []string{"22/tcp", "443/tcp"},
+		Zones: []FirewallZone{
+			{
+				Name:       "management",
+				Interfaces: []string{"eth1"},
+				Sources:    []string{"10.0.0.0/8", "fd00::1"},
+				OpenPorts:  []string{"9090/tcp", "8000-8080/udp"},
+			},
+		},
+		Rules: []string{"tcp dport 25 reject"},
+	}
+	invalidFirewallJSON = `{"Enable": true, "OpenPorts": "22/tcp"}`
+)
+
+// copyValidFirewall returns a copy of validFirewall, whose zones the test can modify.
+func copyValidFirewall() (firewall Firewall) {
+	firewall = validFirewall
+	firewall.Zones = append([]FirewallZone(nil), validFirewall.Zones...)
+	return
+}
+
+func TestShouldSucceedParsingDefaultFirewall_Firewall(t *testing.T) {
+	var checkedFirewall Firewall
+
+	err := marshalJSONString("{}", &checkedFirewall)
+	assert.NoError(t, err)
+	assert.Equal(t, Firewall{}, checkedFirewall)
+	assert.Equal(t, FirewallPolicyDrop, checkedFirewall.GetInputPolicy())
+}
+
+func TestShouldSucceedParsingValidFirewall_Firewall(t *testing.T) {
+	var checkedFirewall Firewall
+
+	assert.NoError(t, validFirewall.IsValid())
+	err := remarshalJSON(validFirewall, &checkedFirewall)
+	assert.NoError(t, err)
+	assert.Equal(t, validFirewall, checkedFirewall)
+}
+
+func TestShouldFailParsingInvalidJSON_Firewall(t *testing.T) {
+	var checkedFirewall Firewall
+
+	err := marshalJSONString(invalidFirewallJSON, &checkedFirewall)
+	assert.Error(t, err)
+	assert.Equal(t, "failed to parse [Firewall]: json: cannot unmarshal string into Go struct field IntermediateTypeFirewall.OpenPorts of type []string", err.Error())
+}
+
+func TestShouldFailSettingsWithoutEnable_Firewall(t *testing.T) {
+	var checkedFirewall Firewall
+
+	invalidFirewall := copyValidFirewall()
+	invalidFirewall.Enable = false
+
+	err := remarshalJSON(invalidFirewall, &checkedFirewall)
+	assert.Error(t, err)
+	assert.Equal(t, "failed to parse [Firewall]: [InputPolicy], [OpenPorts], [Zones] or [Rules] is set, but [Enable] is not", err.Error())
+}
+
+func TestShouldFailInvalidInputPolicy_Firewall(t *testing.T) {
+	invalidFirewall := copyValidFirewall()
+	invalidFirewall.InputPolicy = "reject"
+
+	err := invalidFirewall.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "invalid [InputPolicy] (reject), must be (drop) or (accept)", err.Error())
+}
+
+func TestShouldSucceedParsingPorts_Firewall(t *testing.T) {
+	port, err := ParseFirewallPort("22/tcp")
+	assert.NoError(t, err)
+	assert.Equal(t, FirewallPort{Protocol: "tcp", Start: 22, End: 22}, port)
+	assert.Equal(t, "22", port.String())
+
+	port, err = ParseFirewallPort("8000-8080/udp")
+	assert.NoError(t, err)
+	assert.Equal(t, FirewallPort{Protocol: "udp", Start: 8000, End: 8080}, port)
+	assert.Equal(t, "8000-8080", port.String())
+}
+
+func TestShouldFailParsingInvalidPorts_Firewall(t *testing.T) {
+	for _, port := range []string{"22", "22/sctp"} {
+		_, err := ParseFirewallPort(port)
+		assert.Error(t, err)
+		assert.Equal(t, "invalid port ("+port+"), must be a port or a range of ports with a tcp or udp protocol, e.g. 22/tcp or 8000-8080/udp", err.Error())
+	}
+
+	for _, port := range []string{"ssh/tcp", "0/tcp", "65536/tcp", "8080-8000/udp"} {
+		_, err := ParseFirewallPort(port)
+		assert.Error(t, err)
+		assert.Equal(t, "invalid port ("+port+"), ports must be from 1 to 65535, with the lower port of a range first", err.Error())
+	}
+}
+
+func TestShouldFailInvalidZoneName_Firewall(t *testing.T) {
+	invalidFirewall := copyValidFirewall()
+	invalidFirewall.Zones[0].Name = "mgmt-net"
+
+	err := invalidFirewall.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "invalid zone name (mgmt-net), must start with a letter, and only have letters, digits and '_'", err.Error())
+}
+
+func TestShouldFailDuplicateZoneName_Firewall(t *testing.T) {
+	invalidFirewall := copyValidFirewall()
+	invalidFirewall.Zones = append(invalidFirewall.Zones, invalidFirewall.Zones[0])
+
+	err := invalidFirewall.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "two [Zones] are named (management)", err.Error())
+}
+
+func TestShouldFailZoneWithoutInterfacesOrSources_Firewall(t *testing.T) {
+	invalidFirewall := copyValidFirewall()
+	invalidFirewall.Zones[0].Interfaces = nil
+	invalidFirewall.Zones[0].Sources = nil
+
+	err := invalidFirewall.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "zone (management) must have [Interfaces] or [Sources]", err.Error())
+}
+
+func TestShouldFailInvalidZoneSource_Firewall(t *testing.T) {
+	invalidFirewall := copyValidFirewall()
+	invalidFirewall.Zones[0].Sources = []string{"10.0.0.0/33"}
+
+	err := invalidFirewall.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "invalid source (10.0.0.0/33) of zone (management), must be an IP address, or a network with its prefix length", err.Error())
+}
+
+func TestShouldFailInvalidZonePort_Firewall(t *testing.T) {
+	invalidFirewall := copyValidFirewall()
+	invalidFirewall.Zones[0].OpenPorts = []string{"9090"}
+
+	err := invalidFirewall.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "invalid zone (management): invalid port (9090), must be a port or a range of ports with a tcp or udp protocol, e.g. 22/tcp or 8000-8080/udp", err.Error())
+}
+
+func TestShouldFailMultiLineRule_Firewall(t *testing.T) {
+	invalidFirewall := copyValidFirewall()
+	invalidFirewall.Rules = []string{"tcp dport 25 reject\nflush ruleset"}
+
+	err := invalidFirewall.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "invalid rule (tcp dport 25 reject\nflush ruleset), must be a single line", err.Error())
+}
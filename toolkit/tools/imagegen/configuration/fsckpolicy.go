@@ -0,0 +1,67 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+// Parser for the image builder's configuration schemas.
+
+package configuration
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// FsckPolicy selects when fsck checks a partition's file system on boot, which is the pass number of its fstab entry
+type FsckPolicy string
+
+const (
+	// FsckPolicySkip never checks the file system (pass number 0)
+	FsckPolicySkip FsckPolicy = "skip"
+	// FsckPolicyFirst checks the file system first (pass number 1), which is only valid for the root file system
+	FsckPolicyFirst FsckPolicy = "first"
+	// FsckPolicyAfterRoot checks the file system after the root file system (pass number 2)
+	FsckPolicyAfterRoot FsckPolicy = "after-root"
+	// FsckPolicyDefault checks the root file system first and the others after it, except for the file systems that
+	// fsck doesn't check (e.g. btrfs)
+	FsckPolicyDefault FsckPolicy = ""
+)
+
+func (f FsckPolicy) String() string {
+	return fmt.Sprint(string(f))
+}
+
+// GetValidFsckPolicies returns a list of all the supported fsck policies
+func (f *FsckPolicy) GetValidFsckPolicies() (types []FsckPolicy) {
+	return []FsckPolicy{
+		FsckPolicySkip,
+		FsckPolicyFirst,
+		FsckPolicyAfterRoot,
+		FsckPolicyDefault,
+	}
+}
+
+// IsValid returns an error if the FsckPolicy is not valid
+func (f *FsckPolicy) IsValid() (err error) {
+	for _, valid := range f.GetValidFsckPolicies() {
+		if *f == valid {
+			return
+		}
+	}
+	return fmt.Errorf("invalid value for FsckPolicy (%s)", f)
+}
+
+// UnmarshalJSON Unmarshals an FsckPolicy entry
+func (f *FsckPolicy) UnmarshalJSON(b []byte) (err error) {
+	// Use an intermediate type which will use the default JSON unmarshal implementation
+	type IntermediateTypeFsckPolicy FsckPolicy
+	err = json.Unmarshal(b, (*IntermediateTypeFsckPolicy)(f))
+	if err != nil {
+		return fmt.Errorf("failed to parse [FsckPolicy]: %w", err)
+	}
+
+	// Now validate the resulting unmarshaled object
+	err = f.IsValid()
+	if err != nil {
+		return fmt.Errorf("failed to parse [FsckPolicy]: %w", err)
+	}
+	return
+}
@@ -0,0 +1,54 @@
+// Copyright Microsoft Corporation.
+// Licensed under the MIT License.
+
+package configuration
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestMain found in configuration_test.go.
+
+var (
+	validFsckPolicies = []FsckPolicy{
+		FsckPolicy("skip"),
+		FsckPolicy("first"),
+		FsckPolicy("after-root"),
+		FsckPolicy(""),
+	}
+	invalidFsckPolicy = FsckPolicy("2")
+)
+
+func TestShouldSucceedValidFsckPoliciesMatch_FsckPolicy(t *testing.T) {
+	var policy FsckPolicy
+	assert.Equal(t, len(validFsckPolicies), len(policy.GetValidFsckPolicies()))
+
+	for _, fsckPolicy := range validFsckPolicies {
+		assert.Contains(t, policy.GetValidFsckPolicies(), fsckPolicy)
+	}
+}
+
+func TestShouldSucceedParsingValidPolicies_FsckPolicy(t *testing.T) {
+	for _, validPolicy := range validFsckPolicies {
+		var checkedPolicy FsckPolicy
+
+		assert.NoError(t, validPolicy.IsValid())
+		err := remarshalJSON(validPolicy, &checkedPolicy)
+		assert.NoError(t, err)
+		assert.Equal(t, validPolicy, checkedPolicy)
+	}
+}
+
+func TestShouldFailParsingInvalidPolicy_FsckPolicy(t *testing.T) {
+	var checkedPolicy FsckPolicy
+
+	err := invalidFsckPolicy.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "invalid value for FsckPolicy (2)", err.Error())
+
+	err = remarshalJSON(invalidFsckPolicy, &checkedPolicy)
+	assert.Error(t, err)
+	assert.Equal(t, "failed to parse [FsckPolicy]: invalid value for FsckPolicy (2)", err.Error())
+}
@@ -0,0 +1,109 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+// Parser for the image builder's configuration schemas.
+
+package configuration
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// IdentityPolicy selects whether an identity of the image's instances is reset, so that each machine that boots the
+// image generates its own, or preserved in the image, so that all of them share it.
+type IdentityPolicy string
+
+const (
+	// IdentityPolicyReset removes the identity from the image, so that it's generated on the first boot
+	IdentityPolicyReset IdentityPolicy = "reset"
+	// IdentityPolicyPreserve keeps the identity in the image, generating it during the build if there's none
+	IdentityPolicyPreserve IdentityPolicy = "preserve"
+	// IdentityPolicyDefault selects the default policy, which is to reset the identity
+	IdentityPolicyDefault IdentityPolicy = ""
+)
+
+func (i IdentityPolicy) String() string {
+	return fmt.Sprint(string(i))
+}
+
+// GetValidIdentityPolicies returns a list of all the supported identity policies
+func (i *IdentityPolicy) GetValidIdentityPolicies() (types []IdentityPolicy) {
+	return []IdentityPolicy{
+		IdentityPolicyReset,
+		IdentityPolicyPreserve,
+		IdentityPolicyDefault,
+	}
+}
+
+// IsPreserved returns true if the identity is kept in the image.
+func (i IdentityPolicy) IsPreserved() bool {
+	return i == IdentityPolicyPreserve
+}
+
+// IsValid returns an error if the IdentityPolicy is not valid
+func (i *IdentityPolicy) IsValid() (err error) {
+	for _, valid := range i.GetValidIdentityPolicies() {
+		if *i == valid {
+			return
+		}
+	}
+	return fmt.Errorf("invalid value for IdentityPolicy (%s)", i)
+}
+
+// UnmarshalJSON Unmarshals an IdentityPolicy entry
+func (i *IdentityPolicy) UnmarshalJSON(b []byte) (err error) {
+	// Use an intermediate type which will use the default JSON unmarshal implementation
+	type IntermediateTypeIdentityPolicy IdentityPolicy
+	err = json.Unmarshal(b, (*IntermediateTypeIdentityPolicy)(i))
+	if err != nil {
+		return fmt.Errorf("failed to parse [IdentityPolicy]: %w", err)
+	}
+
+	// Now validate the resulting unmarshaled object
+	err = i.IsValid()
+	if err != nil {
+		return fmt.Errorf("failed to parse [IdentityPolicy]: %w", err)
+	}
+	return
+}
+
+// InstanceIdentity selects the policies of the identities that are unique to each instance of the image: the machine
+// ID and the SSH host keys. Golden images that are cloned to many machines reset them, while images of a single
+// machine may preserve them.
+type InstanceIdentity struct {
+	MachineID   IdentityPolicy `json:"MachineID"`
+	SSHHostKeys IdentityPolicy `json:"SSHHostKeys"`
+}
+
+// IsValid returns an error if the InstanceIdentity is not valid
+func (i *InstanceIdentity) IsValid() (err error) {
+	err = i.MachineID.IsValid()
+	if err != nil {
+		return fmt.Errorf("invalid [MachineID]: %w", err)
+	}
+
+	err = i.SSHHostKeys.IsValid()
+	if err != nil {
+		return fmt.Errorf("invalid [SSHHostKeys]: %w", err)
+	}
+
+	return
+}
+
+// UnmarshalJSON Unmarshals an InstanceIdentity entry
+func (i *InstanceIdentity) UnmarshalJSON(b []byte) (err error) {
+	// Use an intermediate type which will use the default JSON unmarshal implementation
+	type IntermediateTypeInstanceIdentity InstanceIdentity
+	err = json.Unmarshal(b, (*IntermediateTypeInstanceIdentity)(i))
+	if err != nil {
+		return fmt.Errorf("failed to parse [InstanceIdentity]: %w", err)
+	}
+
+	// Now validate the resulting unmarshaled object
+	err = i.IsValid()
+	if err != nil {
+		return fmt.Errorf("failed to parse [InstanceIdentity]: %w", err)
+	}
+	return
+}
@@ -0,0 +1,99 @@
+// Copyright Microsoft Corporation.
+// Licensed under the MIT License.
+
+package configuration
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestMain found in configuration_test.go.
+
+var (
+	validIdentityPolicies = []IdentityPolicy{
+		IdentityPolicy("reset"),
+		IdentityPolicy("preserve"),
+		IdentityPolicy(""),
+	}
+	invalidIdentityPolicy = IdentityPolicy("regenerate")
+	validInstanceIdentity = InstanceIdentity{
+		MachineID:   IdentityPolicyPreserve,
+		SSHHostKeys: IdentityPolicyReset,
+	}
+	validInstanceIdentityJSON   = `{"MachineID": "preserve", "SSHHostKeys": "reset"}`
+	invalidInstanceIdentityJSON = `{"MachineID": "preserve", "SSHHostKeys": "keep"}`
+)
+
+func TestShouldSucceedValidIdentityPoliciesMatch_IdentityPolicy(t *testing.T) {
+	var policy IdentityPolicy
+	assert.Equal(t, len(validIdentityPolicies), len(policy.GetValidIdentityPolicies()))
+
+	for _, identityPolicy := range validIdentityPolicies {
+		assert.Contains(t, policy.GetValidIdentityPolicies(), identityPolicy)
+	}
+}
+
+func TestShouldSucceedParsingValidPolicies_IdentityPolicy(t *testing.T) {
+	for _, validPolicy := range validIdentityPolicies {
+		var checkedPolicy IdentityPolicy
+
+		assert.NoError(t, validPolicy.IsValid())
+		err := remarshalJSON(validPolicy, &checkedPolicy)
+		assert.NoError(t, err)
+		assert.Equal(t, validPolicy, checkedPolicy)
+	}
+}
+
+func TestShouldSucceedCheckingPreservedPolicies_IdentityPolicy(t *testing.T) {
+	assert.True(t, IdentityPolicyPreserve.IsPreserved())
+	assert.False(t, IdentityPolicyReset.IsPreserved())
+	assert.False(t, IdentityPolicyDefault.IsPreserved())
+}
+
+func TestShouldFailParsingInvalidPolicy_IdentityPolicy(t *testing.T) {
+	var checkedPolicy IdentityPolicy
+
+	err := invalidIdentityPolicy.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "invalid value for IdentityPolicy (regenerate)", err.Error())
+
+	err = remarshalJSON(invalidIdentityPolicy, &checkedPolicy)
+	assert.Error(t, err)
+	assert.Equal(t, "failed to parse [IdentityPolicy]: invalid value for IdentityPolicy (regenerate)", err.Error())
+}
+
+func TestShouldSucceedParsingDefaultInstanceIdentity_InstanceIdentity(t *testing.T) {
+	var checkedInstanceIdentity InstanceIdentity
+
+	err := marshalJSONString("{}", &checkedInstanceIdentity)
+	assert.NoError(t, err)
+	assert.Equal(t, InstanceIdentity{}, checkedInstanceIdentity)
+	assert.False(t, checkedInstanceIdentity.MachineID.IsPreserved())
+	assert.False(t, checkedInstanceIdentity.SSHHostKeys.IsPreserved())
+}
+
+func TestShouldSucceedParsingValidJSON_InstanceIdentity(t *testing.T) {
+	var checkedInstanceIdentity InstanceIdentity
+
+	err := marshalJSONString(validInstanceIdentityJSON, &checkedInstanceIdentity)
+	assert.NoError(t, err)
+	assert.Equal(t, validInstanceIdentity, checkedInstanceIdentity)
+}
+
+func TestShouldFailParsingInvalidJSON_InstanceIdentity(t *testing.T) {
+	var checkedInstanceIdentity InstanceIdentity
+
+	err := marshalJSONString(invalidInstanceIdentityJSON, &checkedInstanceIdentity)
+	assert.Error(t, err)
+	assert.Equal(t, "failed to parse [InstanceIdentity]: failed to parse [IdentityPolicy]: invalid value for IdentityPolicy (keep)", err.Error())
+}
+
+func TestShouldFailInvalidSSHHostKeysPolicy_InstanceIdentity(t *testing.T) {
+	invalidInstanceIdentity := InstanceIdentity{SSHHostKeys: invalidIdentityPolicy}
+
+	err := invalidInstanceIdentity.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "invalid [SSHHostKeys]: invalid value for IdentityPolicy (regenerate)", err.Error())
+}
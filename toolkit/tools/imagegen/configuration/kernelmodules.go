@@ -0,0 +1,117 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+// Parser for the image builder's configuration schemas.
+
+package configuration
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const kernelModuleFileSuffix = ".ko"
+
+// kernelModuleNameRegex matches the names of kernel modules.
+var kernelModuleNameRegex = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// KernelModules are the out-of-tree kernel modules (e.g. GPU and NIC drivers) to install into the image. The modules
+// must be built against the image's kernel.
+type KernelModules struct {
+	// A local private key and its certificate to sign the modules with, for kernels that only load signed modules
+	// (e.g. with Secure Boot). The modules are signed with the kernel's sign-file tool, from the kernel's devel
+	// package.
+	SigningKey  string         `json:"SigningKey"`
+	SigningCert string         `json:"SigningCert"`
+	Modules     []KernelModule `json:"Modules"`
+}
+
+// KernelModule is a local, built kernel module (.ko) file, whose name is the module's name.
+// - Options are the parameters the module is loaded with (e.g. "debug=1 max_vfs=8").
+// - LoadAtBoot loads the module on every boot, even if no device needs it.
+// - AddToInitrd adds the module to the initramfs, for the devices that the root file system is mounted from.
+type KernelModule struct {
+	Path        string `json:"Path"`
+	Options     string `json:"Options"`
+	LoadAtBoot  bool   `json:"LoadAtBoot"`
+	AddToInitrd bool   `json:"AddToInitrd"`
+}
+
+// IsEmpty returns true if there are no kernel modules to install.
+func (k *KernelModules) IsEmpty() bool {
+	return len(k.Modules) == 0
+}
+
+// IsSigned returns true if the kernel modules are signed.
+func (k *KernelModules) IsSigned() bool {
+	return k.SigningKey != ""
+}
+
+// GetName returns the name of the kernel module.
+func (k *KernelModule) GetName() string {
+	return strings.TrimSuffix(filepath.Base(k.Path), kernelModuleFileSuffix)
+}
+
+// IsValid returns an error if the KernelModules are not valid
+func (k *KernelModules) IsValid() (err error) {
+	if k.IsEmpty() {
+		if k.SigningKey != "" || k.SigningCert != "" {
+			return fmt.Errorf("[SigningKey] or [SigningCert] is set, but there are no [Modules]")
+		}
+		return
+	}
+
+	if (k.SigningKey == "") != (k.SigningCert == "") {
+		return fmt.Errorf("[SigningKey] and [SigningCert] must be set together")
+	}
+
+	names := make(map[string]bool)
+	for _, module := range k.Modules {
+		if err = module.IsValid(); err != nil {
+			return
+		}
+
+		// The kernel treats '-' and '_' in module names as the same character.
+		name := strings.ReplaceAll(module.GetName(), "-", "_")
+		if names[name] {
+			return fmt.Errorf("two [Modules] are named (%s)", module.GetName())
+		}
+		names[name] = true
+	}
+
+	return
+}
+
+// IsValid returns an error if the KernelModule is not valid
+func (k *KernelModule) IsValid() (err error) {
+	if !strings.HasSuffix(k.Path, kernelModuleFileSuffix) || !kernelModuleNameRegex.MatchString(k.GetName()) {
+		return fmt.Errorf("invalid kernel module [Path] (%s), must be a (%s) file named after the module", k.Path,
+			kernelModuleFileSuffix)
+	}
+
+	if strings.ContainsAny(k.Options, "\n\r") {
+		return fmt.Errorf("invalid [Options] of kernel module (%s), must be a single line", k.GetName())
+	}
+
+	return
+}
+
+// UnmarshalJSON Unmarshals a KernelModules entry
+func (k *KernelModules) UnmarshalJSON(b []byte) (err error) {
+	// Use an intermediate type which will use the default JSON unmarshal implementation
+	type IntermediateTypeKernelModules KernelModules
+	err = json.Unmarshal(b, (*IntermediateTypeKernelModules)(k))
+	if err != nil {
+		return fmt.Errorf("failed to parse [KernelModules]: %w", err)
+	}
+
+	// Now validate the resulting unmarshaled object
+	err = k.IsValid()
+	if err != nil {
+		return fmt.Errorf("failed to parse [KernelModules]: %w", err)
+	}
+	return
+}
@@ -0,0 +1,110 @@
+// Copyright Microsoft Corporation.
+// Licensed under the MIT License.
+
+package configuration
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestMain found in configuration_test.go.
+
+var (
+	validKernelModules = KernelModules{
+		SigningKey:  "keys/signing_key.pem",
+		SigningCert: "keys/signing_key.x509",
+		Modules: []KernelModule{
+			{Path: "modules/nvidia.ko", Options: "NVreg_OpenRmEnableUnsupportedGpus=1", LoadAtBoot: true},
+			{Path: "modules/mlx5-vendor.ko", AddToInitrd: true},
+		},
+	}
+	invalidKernelModulesJSON = `{"Modules": ["modules/nvidia.ko"]}`
+)
+
+// copyValidKernelModules returns a copy of validKernelModules, whose modules the test can modify.
+func copyValidKernelModules() (kernelModules KernelModules) {
+	kernelModules = validKernelModules
+	kernelModules.Modules = append([]KernelModule(nil), validKernelModules.Modules...)
+	return
+}
+
+func TestShouldSucceedParsingDefaultKernelModules_KernelModules(t *testing.T) {
+	var checkedKernelModules KernelModules
+
+	err := marshalJSONString("{}", &checkedKernelModules)
+	assert.NoError(t, err)
+	assert.Equal(t, KernelModules{}, checkedKernelModules)
+	assert.True(t, checkedKernelModules.IsEmpty())
+	assert.False(t, checkedKernelModules.IsSigned())
+}
+
+func TestShouldSucceedParsingValidKernelModules_KernelModules(t *testing.T) {
+	var checkedKernelModules KernelModules
+
+	assert.NoError(t, validKernelModules.IsValid())
+	err := remarshalJSON(validKernelModules, &checkedKernelModules)
+	assert.NoError(t, err)
+	assert.Equal(t, validKernelModules, checkedKernelModules)
+	assert.True(t, checkedKernelModules.IsSigned())
+	assert.Equal(t, "nvidia", checkedKernelModules.Modules[0].GetName())
+	assert.Equal(t, "mlx5-vendor", checkedKernelModules.Modules[1].GetName())
+}
+
+func TestShouldFailParsingInvalidJSON_KernelModules(t *testing.T) {
+	var checkedKernelModules KernelModules
+
+	err := marshalJSONString(invalidKernelModulesJSON, &checkedKernelModules)
+	assert.Error(t, err)
+	assert.Equal(t, "failed to parse [KernelModules]: json: cannot unmarshal string into IntermediateTypeKernelModules.Modules.0 of type configuration.KernelModule", err.Error())
+}
+
+func TestShouldFailSigningWithoutModules_KernelModules(t *testing.T) {
+	var checkedKernelModules KernelModules
+
+	invalidKernelModules := copyValidKernelModules()
+	invalidKernelModules.Modules = nil
+
+	err := remarshalJSON(invalidKernelModules, &checkedKernelModules)
+	assert.Error(t, err)
+	assert.Equal(t, "failed to parse [KernelModules]: [SigningKey] or [SigningCert] is set, but there are no [Modules]", err.Error())
+}
+
+func TestShouldFailSigningKeyWithoutCert_KernelModules(t *testing.T) {
+	invalidKernelModules := copyValidKernelModules()
+	invalidKernelModules.SigningCert = ""
+
+	err := invalidKernelModules.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "[SigningKey] and [SigningCert] must be set together", err.Error())
+}
+
+func TestShouldFailInvalidPath_KernelModules(t *testing.T) {
+	for _, path := range []string{"modules/nvidia.ko.xz", "modules/nvidia", "modules/nv idia.ko", "modules/.ko"} {
+		invalidKernelModules := copyValidKernelModules()
+		invalidKernelModules.Modules[0].Path = path
+
+		err := invalidKernelModules.IsValid()
+		assert.Error(t, err)
+		assert.Equal(t, "invalid kernel module [Path] ("+path+"), must be a (.ko) file named after the module", err.Error())
+	}
+}
+
+func TestShouldFailDuplicateName_KernelModules(t *testing.T) {
+	invalidKernelModules := copyValidKernelModules()
+	invalidKernelModules.Modules[0].Path = "other/mlx5_vendor.ko"
+
+	err := invalidKernelModules.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "two [Modules] are named (mlx5-vendor)", err.Error())
+}
+
+func TestShouldFailMultiLineOptions_KernelModules(t *testing.T) {
+	invalidKernelModules := copyValidKernelModules()
+	invalidKernelModules.Modules[0].Options = "debug=1\ninstall nvidia /bin/sh"
+
+	err := invalidKernelModules.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "invalid [Options] of kernel module (nvidia), must be a single line", err.Error())
+}
@@ -0,0 +1,751 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+// Parser for the image builder's configuration schemas.
+
+package configuration
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/sliceutils"
+)
+
+// UnsupportedKickstartDirective is a kickstart directive, or an option of one, that isn't imported into the config.
+type UnsupportedKickstartDirective struct {
+	// The line number of the directive in the kickstart file.
+	Line      int    `json:"Line"`
+	Directive string `json:"Directive"`
+	Reason    string `json:"Reason"`
+}
+
+const (
+	// KickstartSystemConfigName is the name of the system config that a kickstart file is imported into.
+	KickstartSystemConfigName = "Kickstart"
+
+	kickstartPackagesSection = "%packages"
+	kickstartSectionEnd      = "%end"
+	kickstartDefaultFsType   = "ext4"
+	kickstartDefaultKernel   = "kernel"
+	kickstartRootUser        = "root"
+
+	// The partitions start after the partition table, like in the imager's own configs.
+	kickstartFirstPartitionStartMiB = 1
+	// The end of the disk is left for the backup GPT header, unless a partition grows to fill the disk.
+	kickstartDiskEndPaddingMiB = 1
+
+	kickstartUnsupportedDirective = "the directive isn't supported"
+	kickstartUnsupportedOption    = "the option isn't supported"
+)
+
+// kickstartIgnoredDirectives only control the installer itself (e.g. its user interface, or what it does once it's
+// done), so they have no meaning for an image.
+var kickstartIgnoredDirectives = map[string]bool{
+	"cmdline":   true,
+	"eula":      true,
+	"graphical": true,
+	"halt":      true,
+	"install":   true,
+	"poweroff":  true,
+	"reboot":    true,
+	"shutdown":  true,
+	"skipx":     true,
+	"text":      true,
+	"zerombr":   true,
+}
+
+// kickstartSections are the sections that run until their %end. Only the %packages section is imported.
+var kickstartSections = map[string]bool{
+	"%addon":                 true,
+	"%anaconda":              true,
+	"%onerror":               true,
+	kickstartPackagesSection: true,
+	"%post":                  true,
+	"%pre":                   true,
+	"%pre-install":           true,
+	"%traceback":             true,
+}
+
+// kickstartValueOptions are the options of each directive that take a value, which may be the next argument instead
+// of following an '='. The other options are flags.
+var kickstartValueOptions = map[string][]string{
+	"part": {
+		"--size", "--fstype", "--ondisk", "--ondrive", "--fsoptions", "--maxsize", "--label", "--onpart", "--usepart",
+		"--fsprofile", "--mkfsoptions", "--passphrase", "--escrowcert", "--cipher", "--luks-version", "--pbkdf",
+		"--pbkdf-memory", "--pbkdf-time", "--pbkdf-iterations",
+	},
+	"clearpart":  {"--drives", "--list", "--disklabel"},
+	"bootloader": {"--append", "--location", "--boot-drive", "--driveorder", "--timeout", "--password", "--md5pass"},
+	"user":       {"--name", "--password", "--groups", "--homedir", "--uid", "--gid", "--shell", "--gecos"},
+	"group":      {"--name", "--gid"},
+	"sshkey":     {"--username"},
+	"network": {
+		"--bootproto", "--device", "--ip", "--netmask", "--gateway", "--nameserver", "--hostname", "--onboot",
+		"--ipv6", "--ipv6gateway", "--mtu", "--ethtool", "--essid", "--wepkey", "--wpakey", "--bondslaves",
+		"--bondopts", "--vlanid", "--interfacename", "--teamslaves", "--teamconfig", "--bridgeslaves", "--bridgeopts",
+		"--dhcpclass", "--ipv4-dns-search", "--ipv6-dns-search",
+	},
+	kickstartPackagesSection: {"--instLangs", "--inst-langs", "--multilib", "--timeout", "--retries"},
+}
+
+// kickstartCommand is a kickstart directive, split into its options and its other arguments.
+type kickstartCommand struct {
+	name string
+	// The options, mapped to their values. Flags have an empty value.
+	options     map[string]string
+	optionNames []string
+	args        []string
+}
+
+type kickstartPartitionKind int
+
+const (
+	kickstartPartitionMounted kickstartPartitionKind = iota
+	kickstartPartitionESP
+	kickstartPartitionBiosBoot
+	kickstartPartitionSwap
+)
+
+type kickstartPartition struct {
+	line         int
+	kind         kickstartPartitionKind
+	mountPoint   string
+	fsType       string
+	mountOptions string
+	sizeMiB      uint64
+	grow         bool
+}
+
+type kickstartDisk struct {
+	name       string
+	partitions []kickstartPartition
+}
+
+type kickstartSSHKey struct {
+	line     int
+	userName string
+	key      string
+}
+
+// kickstartImporter imports the directives of a kickstart file, one at a time.
+type kickstartImporter struct {
+	systemConfig       SystemConfig
+	disks              []kickstartDisk
+	partitionTableType PartitionTableType
+	kernelRequested    bool
+	sshKeys            []kickstartSSHKey
+	unsupported        []UnsupportedKickstartDirective
+	line               int
+}
+
+// ImportKickstart converts a kickstart file into a config with a single system config. Only the partitioning, the
+// packages, the users and the network of the kickstart file are imported. The directives that aren't imported (e.g.
+// %post scripts) are returned, so that they can be reported, or moved to the config by hand.
+func ImportKickstart(kickstartFile string) (config Config, unsupported []UnsupportedKickstartDirective, err error) {
+	content, err := os.ReadFile(kickstartFile)
+	if err != nil {
+		return Config{}, nil, fmt.Errorf("failed to read kickstart file (%s):\n%w", kickstartFile, err)
+	}
+
+	return ParseKickstart(string(content))
+}
+
+// ParseKickstart converts the content of a kickstart file into a config, like ImportKickstart.
+func ParseKickstart(content string) (config Config, unsupported []UnsupportedKickstartDirective, err error) {
+	importer := kickstartImporter{
+		systemConfig:       SystemConfig{Name: KickstartSystemConfigName},
+		partitionTableType: PartitionTableTypeGpt,
+	}
+
+	lines := strings.Split(content, "\n")
+	for i := 0; i < len(lines); i++ {
+		importer.line = i + 1
+
+		var tokens []string
+		tokens, err = splitKickstartLine(lines[i])
+		if err != nil {
+			return Config{}, nil, fmt.Errorf("failed to parse kickstart line (%d):\n%w", importer.line, err)
+		}
+		if len(tokens) == 0 {
+			continue
+		}
+
+		if kickstartSections[tokens[0]] {
+			end := findKickstartSectionEnd(lines, i+1)
+			if end < 0 {
+				return Config{}, nil, fmt.Errorf("kickstart section (%s) on line (%d) has no (%s)", tokens[0],
+					importer.line, kickstartSectionEnd)
+			}
+
+			err = importer.importSection(tokens, lines[i+1:end])
+			if err != nil {
+				return Config{}, nil, fmt.Errorf("failed to import kickstart section (%s) on line (%d):\n%w", tokens[0],
+					importer.line, err)
+			}
+			i = end
+			continue
+		}
+
+		err = importer.importDirective(tokens)
+		if err != nil {
+			return Config{}, nil, fmt.Errorf("failed to import kickstart line (%d):\n%w", importer.line, err)
+		}
+	}
+
+	config, err = importer.buildConfig()
+	if err != nil {
+		return Config{}, nil, err
+	}
+
+	return config, importer.unsupported, nil
+}
+
+// splitKickstartLine splits a kickstart line into its arguments like a shell does, dropping its comment.
+func splitKickstartLine(line string) (tokens []string, err error) {
+	var (
+		token   strings.Builder
+		inToken bool
+		quote   rune
+		escaped bool
+	)
+
+scan:
+	for _, c := range line {
+		switch {
+		case escaped:
+			token.WriteRune(c)
+			escaped = false
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			} else if c == '\\' && quote == '"' {
+				escaped = true
+			} else {
+				token.WriteRune(c)
+			}
+		case c == '\\':
+			escaped = true
+			inToken = true
+		case c == '"' || c == '\'':
+			quote = c
+			inToken = true
+		case c == '#' && !inToken:
+			break scan
+		case unicode.IsSpace(c):
+			if inToken {
+				tokens = append(tokens, token.String())
+				token.Reset()
+				inToken = false
+			}
+		default:
+			token.WriteRune(c)
+			inToken = true
+		}
+	}
+
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated quote (%c)", quote)
+	}
+	if inToken {
+		tokens = append(tokens, token.String())
+	}
+	return
+}
+
+// findKickstartSectionEnd returns the index of the %end line of the section whose content starts at the index, or -1
+// if the section has no end.
+func findKickstartSectionEnd(lines []string, start int) int {
+	for i := start; i < len(lines); i++ {
+		if strings.TrimSpace(lines[i]) == kickstartSectionEnd {
+			return i
+		}
+	}
+	return -1
+}
+
+// parseKickstartCommand splits the arguments of a directive into its options and its other arguments.
+func parseKickstartCommand(tokens []string) (command kickstartCommand) {
+	command = kickstartCommand{
+		name:    tokens[0],
+		options: make(map[string]string),
+	}
+	valueOptions := kickstartValueOptions[command.name]
+
+	for i := 1; i < len(tokens); i++ {
+		if !strings.HasPrefix(tokens[i], "--") {
+			command.args = append(command.args, tokens[i])
+			continue
+		}
+
+		name, value, hasValue := strings.Cut(tokens[i], "=")
+		if !hasValue && sliceutils.ContainsValue(valueOptions, name) && i+1 < len(tokens) {
+			i++
+			value = tokens[i]
+		}
+
+		command.options[name] = value
+		command.optionNames = append(command.optionNames, name)
+	}
+	return
+}
+
+// hasOption returns true if the directive has the option.
+func (c *kickstartCommand) hasOption(name string) bool {
+	_, found := c.options[name]
+	return found
+}
+
+// report records a directive that isn't imported.
+func (i *kickstartImporter) report(line int, directive, reason string) {
+	i.unsupported = append(i.unsupported, UnsupportedKickstartDirective{
+		Line:      line,
+		Directive: directive,
+		Reason:    reason,
+	})
+}
+
+// reportUnsupportedOptions records the options of a directive that aren't imported.
+func (i *kickstartImporter) reportUnsupportedOptions(command kickstartCommand, supportedOptions ...string) {
+	for _, name := range command.optionNames {
+		if !sliceutils.ContainsValue(supportedOptions, name) {
+			i.report(i.line, fmt.Sprintf("%s %s", command.name, name), kickstartUnsupportedOption)
+		}
+	}
+}
+
+func (i *kickstartImporter) importDirective(tokens []string) (err error) {
+	if tokens[0] == "partition" {
+		tokens[0] = "part"
+	}
+	command := parseKickstartCommand(tokens)
+
+	switch command.name {
+	case "part":
+		return i.importPartition(command)
+	case "clearpart":
+		return i.importClearpart(command)
+	case "bootloader":
+		i.importBootloader(command)
+	case "user":
+		return i.importUser(command)
+	case "group":
+		return i.importGroup(command)
+	case "rootpw":
+		return i.importRootpw(command)
+	case "sshkey":
+		return i.importSSHKey(command)
+	case "network":
+		return i.importNetwork(command)
+	default:
+		if !kickstartIgnoredDirectives[command.name] {
+			i.report(i.line, command.name, kickstartUnsupportedDirective)
+		}
+	}
+	return
+}
+
+func (i *kickstartImporter) importSection(header []string, body []string) (err error) {
+	if header[0] != kickstartPackagesSection {
+		i.report(i.line, header[0], "the section isn't supported")
+		return
+	}
+
+	command := parseKickstartCommand(header)
+	i.reportUnsupportedOptions(command, "--excludedocs", "--instLangs", "--inst-langs")
+	if command.hasOption("--excludedocs") {
+		i.systemConfig.DisableRpmDocs = true
+	}
+	for _, name := range []string{"--instLangs", "--inst-langs"} {
+		if command.hasOption(name) {
+			i.systemConfig.OverrideRpmLocales = command.options[name]
+		}
+	}
+
+	for j, line := range body {
+		lineNumber := i.line + j + 1
+
+		var tokens []string
+		tokens, err = splitKickstartLine(line)
+		if err != nil {
+			return fmt.Errorf("failed to parse kickstart line (%d):\n%w", lineNumber, err)
+		}
+
+		for _, pkg := range tokens {
+			i.importPackage(lineNumber, pkg)
+		}
+	}
+	return
+}
+
+func (i *kickstartImporter) importPackage(line int, pkg string) {
+	switch {
+	case strings.HasPrefix(pkg, "@"):
+		i.report(line, pkg, "package groups aren't supported, list the group's packages instead")
+	case strings.ContainsAny(pkg, "*?["):
+		i.report(line, pkg, "package globs aren't supported, list the matching packages instead")
+	case strings.HasPrefix(pkg, "-"):
+		removedPkg := strings.TrimPrefix(pkg, "-")
+		if !sliceutils.ContainsValue(i.systemConfig.RemovePackages, removedPkg) {
+			i.systemConfig.RemovePackages = append(i.systemConfig.RemovePackages, removedPkg)
+		}
+	case pkg == kickstartDefaultKernel:
+		// Kernels are installed with [KernelOptions], not [Packages].
+		i.kernelRequested = true
+	default:
+		if !sliceutils.ContainsValue(i.systemConfig.Packages, pkg) {
+			i.systemConfig.Packages = append(i.systemConfig.Packages, pkg)
+		}
+	}
+}
+
+func (i *kickstartImporter) importPartition(command kickstartCommand) (err error) {
+	if len(command.args) != 1 {
+		return fmt.Errorf("expected a single mount point, got (%s)", strings.Join(command.args, " "))
+	}
+
+	mountPoint := command.args[0]
+	for _, prefix := range []string{"raid.", "pv.", "btrfs."} {
+		if strings.HasPrefix(mountPoint, prefix) {
+			i.report(i.line, fmt.Sprintf("%s %s", command.name, mountPoint),
+				"RAID members, LVM physical volumes and btrfs volumes aren't supported")
+			return
+		}
+	}
+
+	i.reportUnsupportedOptions(command, "--size", "--grow", "--fstype", "--ondisk", "--ondrive", "--fsoptions",
+		"--asprimary")
+
+	partition := kickstartPartition{
+		line:         i.line,
+		mountPoint:   mountPoint,
+		fsType:       command.options["--fstype"],
+		mountOptions: command.options["--fsoptions"],
+		grow:         command.hasOption("--grow"),
+	}
+
+	switch {
+	case mountPoint == efibootPartition || partition.fsType == EFIPartitionType:
+		partition.kind = kickstartPartitionESP
+		partition.fsType = "fat32"
+	case mountPoint == biosbootPartition || partition.fsType == biosbootPartition:
+		partition.kind = kickstartPartitionBiosBoot
+		partition.fsType = "fat32"
+	case mountPoint == "swap" || partition.fsType == "swap":
+		partition.kind = kickstartPartitionSwap
+		partition.fsType = "linux-swap"
+	case !strings.HasPrefix(mountPoint, "/"):
+		return fmt.Errorf("invalid mount point (%s)", mountPoint)
+	}
+
+	switch partition.fsType {
+	case "":
+		partition.fsType = kickstartDefaultFsType
+	case "vfat":
+		partition.fsType = "fat32"
+	case "fat32", "linux-swap", "ext2", "ext3", "ext4", "xfs", BtrfsFsType:
+	default:
+		i.report(i.line, fmt.Sprintf("%s --fstype=%s", command.name, partition.fsType),
+			"the file system type isn't supported")
+		return
+	}
+
+	if command.hasOption("--size") {
+		partition.sizeMiB, err = strconv.ParseUint(command.options["--size"], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid --size (%s) of partition (%s):\n%w", command.options["--size"], mountPoint, err)
+		}
+	} else if !partition.grow {
+		return fmt.Errorf("partition (%s) has no --size", mountPoint)
+	}
+
+	diskName := command.options["--ondisk"]
+	if diskName == "" {
+		diskName = command.options["--ondrive"]
+	}
+	i.addPartition(diskName, partition)
+	return
+}
+
+// addPartition adds a partition to its disk. The partitions without a disk are on the first disk.
+func (i *kickstartImporter) addPartition(diskName string, partition kickstartPartition) {
+	for j := range i.disks {
+		if i.disks[j].name == diskName || (diskName == "" && j == 0) {
+			i.disks[j].partitions = append(i.disks[j].partitions, partition)
+			return
+		}
+	}
+	i.disks = append(i.disks, kickstartDisk{name: diskName, partitions: []kickstartPartition{partition}})
+}
+
+func (i *kickstartImporter) importClearpart(command kickstartCommand) (err error) {
+	// The imager always partitions the whole disk, so only the partition table type is imported.
+	switch disklabel := command.options["--disklabel"]; disklabel {
+	case "", "gpt":
+		i.partitionTableType = PartitionTableTypeGpt
+	case "msdos", "mbr":
+		i.partitionTableType = PartitionTableTypeMbr
+	default:
+		return fmt.Errorf("invalid --disklabel (%s)", disklabel)
+	}
+	return
+}
+
+func (i *kickstartImporter) importBootloader(command kickstartCommand) {
+	// The location of the bootloader is set by the [BootType].
+	i.reportUnsupportedOptions(command, "--append", "--location")
+	i.systemConfig.KernelCommandLine.ExtraCommandLine = command.options["--append"]
+}
+
+func (i *kickstartImporter) importUser(command kickstartCommand) (err error) {
+	i.reportUnsupportedOptions(command, "--name", "--password", "--iscrypted", "--plaintext", "--groups", "--homedir",
+		"--uid", "--shell")
+
+	user := User{
+		Name:           command.options["--name"],
+		UID:            command.options["--uid"],
+		Password:       command.options["--password"],
+		PasswordHashed: command.hasOption("--iscrypted"),
+		HomeDirectory:  command.options["--homedir"],
+		StartupCommand: command.options["--shell"],
+	}
+	if user.Name == "" {
+		return fmt.Errorf("user has no --name")
+	}
+	if groups := command.options["--groups"]; groups != "" {
+		user.SecondaryGroups = strings.Split(groups, ",")
+	}
+
+	i.systemConfig.Users = append(i.systemConfig.Users, user)
+	return
+}
+
+func (i *kickstartImporter) importGroup(command kickstartCommand) (err error) {
+	i.reportUnsupportedOptions(command, "--name", "--gid")
+
+	group := Group{
+		Name: command.options["--name"],
+		GID:  command.options["--gid"],
+	}
+	if group.Name == "" {
+		return fmt.Errorf("group has no --name")
+	}
+
+	i.systemConfig.Groups = append(i.systemConfig.Groups, group)
+	return
+}
+
+func (i *kickstartImporter) importRootpw(command kickstartCommand) (err error) {
+	i.reportUnsupportedOptions(command, "--iscrypted", "--plaintext", "--lock")
+
+	// The root password of an image is locked, unless the config sets one.
+	if command.hasOption("--lock") {
+		return
+	}
+	if len(command.args) != 1 {
+		return fmt.Errorf("expected a single root password")
+	}
+
+	rootUser := i.getOrAddUser(kickstartRootUser)
+	rootUser.Password = command.args[0]
+	rootUser.PasswordHashed = command.hasOption("--iscrypted")
+	return
+}
+
+func (i *kickstartImporter) importSSHKey(command kickstartCommand) (err error) {
+	i.reportUnsupportedOptions(command, "--username")
+
+	if len(command.args) != 1 {
+		return fmt.Errorf("expected a single SSH public key")
+	}
+
+	// The keys are added once all of the users are imported, as the key of a user may come before the user.
+	i.sshKeys = append(i.sshKeys, kickstartSSHKey{
+		line:     i.line,
+		userName: command.options["--username"],
+		key:      command.args[0],
+	})
+	return
+}
+
+func (i *kickstartImporter) importNetwork(command kickstartCommand) (err error) {
+	// Activating a device only affects the installer.
+	i.reportUnsupportedOptions(command, "--bootproto", "--device", "--ip", "--netmask", "--gateway", "--nameserver",
+		"--hostname", "--onboot", "--activate", "--no-activate")
+
+	if hostname := command.options["--hostname"]; hostname != "" {
+		i.systemConfig.Hostname = hostname
+	}
+
+	network := Network{
+		BootProto: command.options["--bootproto"],
+		Device:    command.options["--device"],
+		Ip:        command.options["--ip"],
+		NetMask:   command.options["--netmask"],
+		GateWay:   command.options["--gateway"],
+		OnBoot:    true,
+	}
+	if network.BootProto == "" && network.Device == "" && network.Ip == "" {
+		// The directive only sets the hostname.
+		return
+	}
+
+	if nameServers := command.options["--nameserver"]; nameServers != "" {
+		network.NameServers = strings.Split(nameServers, ",")
+	}
+
+	if command.hasOption("--onboot") {
+		network.OnBoot, err = parseKickstartBool(command.options["--onboot"])
+		if err != nil {
+			return fmt.Errorf("invalid --onboot:\n%w", err)
+		}
+	}
+
+	i.systemConfig.Networks = append(i.systemConfig.Networks, network)
+	return
+}
+
+// parseKickstartBool parses the booleans of kickstart options (e.g. "yes" or "off").
+func parseKickstartBool(value string) (result bool, err error) {
+	switch strings.ToLower(value) {
+	case "yes", "on", "true", "1":
+		return true, nil
+	case "no", "off", "false", "0":
+		return false, nil
+	default:
+		return false, fmt.Errorf("invalid boolean (%s)", value)
+	}
+}
+
+// getOrAddUser returns the user with the name, adding it if there's none.
+func (i *kickstartImporter) getOrAddUser(name string) *User {
+	for j := range i.systemConfig.Users {
+		if i.systemConfig.Users[j].Name == name {
+			return &i.systemConfig.Users[j]
+		}
+	}
+
+	i.systemConfig.Users = append(i.systemConfig.Users, User{Name: name})
+	return &i.systemConfig.Users[len(i.systemConfig.Users)-1]
+}
+
+// buildConfig builds the config from the imported directives.
+func (i *kickstartImporter) buildConfig() (config Config, err error) {
+	for _, sshKey := range i.sshKeys {
+		userFound := sshKey.userName == kickstartRootUser
+		for _, user := range i.systemConfig.Users {
+			userFound = userFound || user.Name == sshKey.userName
+		}
+		if !userFound {
+			i.report(sshKey.line, "sshkey", fmt.Sprintf("user (%s) isn't defined", sshKey.userName))
+			continue
+		}
+
+		user := i.getOrAddUser(sshKey.userName)
+		user.SSHPubKeys = append(user.SSHPubKeys, sshKey.key)
+	}
+
+	i.systemConfig.BootType = LegacyPartitionType
+	for _, disk := range i.disks {
+		var newDisk Disk
+		newDisk, err = i.buildDisk(disk)
+		if err != nil {
+			return
+		}
+		config.Disks = append(config.Disks, newDisk)
+	}
+
+	if len(i.systemConfig.PartitionSettings) > 0 || i.kernelRequested {
+		i.systemConfig.KernelOptions = map[string]string{"default": kickstartDefaultKernel}
+	}
+
+	config.SystemConfigs = []SystemConfig{i.systemConfig}
+	return
+}
+
+// buildDisk builds a disk from its partitions, which are laid out in order. Only the last partition of a disk can grow
+// to fill it.
+func (i *kickstartImporter) buildDisk(disk kickstartDisk) (newDisk Disk, err error) {
+	newDisk.PartitionTableType = i.partitionTableType
+	start := uint64(kickstartFirstPartitionStartMiB)
+
+	for j, partition := range disk.partitions {
+		newPartition := Partition{
+			ID:     i.newPartitionID(partition),
+			Start:  start,
+			FsType: partition.fsType,
+		}
+		partitionSetting := PartitionSetting{
+			ID:              newPartition.ID,
+			MountIdentifier: MountIdentifierDefault,
+			MountPoint:      partition.mountPoint,
+			MountOptions:    partition.mountOptions,
+		}
+
+		switch partition.kind {
+		case kickstartPartitionESP:
+			var mountOptions string
+			partitionSetting.MountPoint, mountOptions, newPartition.Flags, err = BootPartitionConfig(EFIPartitionType,
+				i.partitionTableType)
+			if err != nil {
+				return
+			}
+			if partitionSetting.MountOptions == "" {
+				partitionSetting.MountOptions = mountOptions
+			}
+			i.systemConfig.BootType = EFIPartitionType
+		case kickstartPartitionBiosBoot:
+			partitionSetting.MountPoint, partitionSetting.MountOptions, newPartition.Flags, err = BootPartitionConfig(
+				LegacyPartitionType, i.partitionTableType)
+			if err != nil {
+				return
+			}
+		case kickstartPartitionSwap:
+			partitionSetting.MountPoint = ""
+		}
+
+		isLastPartition := j == len(disk.partitions)-1
+		if partition.grow && isLastPartition {
+			newPartition.End = 0
+			newDisk.MaxSize = start + max(partition.sizeMiB, 1)
+		} else {
+			if partition.grow {
+				i.report(partition.line, "part --grow",
+					fmt.Sprintf("only the last partition of a disk can grow, so partition (%s) has a fixed size",
+						partition.mountPoint))
+			}
+			newPartition.End = start + partition.sizeMiB
+			start = newPartition.End
+			newDisk.MaxSize = start + kickstartDiskEndPaddingMiB
+		}
+
+		newDisk.Partitions = append(newDisk.Partitions, newPartition)
+		i.systemConfig.PartitionSettings = append(i.systemConfig.PartitionSettings, partitionSetting)
+	}
+	return
+}
+
+// newPartitionID returns a unique ID for a partition, based on its mount point (e.g. "var-log" for "/var/log").
+func (i *kickstartImporter) newPartitionID(partition kickstartPartition) (id string) {
+	switch {
+	case partition.kind == kickstartPartitionESP:
+		id = "esp"
+	case partition.kind == kickstartPartitionBiosBoot:
+		id = biosbootPartition
+	case partition.kind == kickstartPartitionSwap:
+		id = "swap"
+	case partition.mountPoint == "/":
+		id = "rootfs"
+	default:
+		id = strings.ReplaceAll(strings.Trim(partition.mountPoint, "/"), "/", "-")
+	}
+
+	baseID := id
+	for suffix := 2; FindPartitionSettingByID(i.systemConfig.PartitionSettings, id) != nil; suffix++ {
+		id = fmt.Sprintf("%s%d", baseID, suffix)
+	}
+	return
+}
@@ -0,0 +1,228 @@
+// Copyright Microsoft Corporation.
+// Licensed under the MIT License.
+
+package configuration
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestMain found in configuration_test.go.
+
+const validKickstart = `# A kickstart file of a RHEL-based pipeline
+text
+reboot
+lang en_US.UTF-8
+zerombr
+clearpart --all --initlabel --disklabel=gpt
+
+part /boot/efi --fstype=efi --size=600 --ondisk=sda
+part /boot --fstype=xfs --size=1024 --ondisk=sda
+part swap --size 2048 --ondisk=sda
+part / --fstype=xfs --size=4096 --grow --fsoptions="noatime,nodev" --ondisk=sda
+part /data --fstype=ext4 --size=8192 --ondisk=sdb --encrypted
+
+bootloader --location=mbr --append="console=ttyS0 quiet" --timeout=5
+
+rootpw --iscrypted $6$salt$hash
+group --name=admins --gid=2000
+user --name=azureuser --groups=wheel,admins --uid=1000 --gid=2000 --password=secret --plaintext --shell=/bin/bash
+sshkey --username=azureuser "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5 azureuser@contoso"
+sshkey --username=nobody "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5 nobody@contoso"
+
+network --bootproto=static --device=eth0 --ip=10.0.0.5 --netmask=255.255.255.0 --gateway=10.0.0.1 --nameserver=10.0.0.2,10.0.0.3 --activate
+network --hostname=contoso-vm
+
+%packages --excludedocs --instLangs=en_US --nocore
+@core
+kernel
+openssh-server
+vim
+python3-*
+-man-db
+%end
+
+%post --log=/root/post.log
+echo "unbalanced quote
+%end
+`
+
+func TestShouldSucceedParsingKickstart_Kickstart(t *testing.T) {
+	config, unsupported, err := ParseKickstart(validKickstart)
+	assert.NoError(t, err)
+
+	assert.Equal(t, []UnsupportedKickstartDirective{
+		{Line: 4, Directive: "lang", Reason: "the directive isn't supported"},
+		{Line: 12, Directive: "part --encrypted", Reason: "the option isn't supported"},
+		{Line: 14, Directive: "bootloader --timeout", Reason: "the option isn't supported"},
+		{Line: 18, Directive: "user --gid", Reason: "the option isn't supported"},
+		{Line: 25, Directive: "%packages --nocore", Reason: "the option isn't supported"},
+		{Line: 26, Directive: "@core", Reason: "package groups aren't supported, list the group's packages instead"},
+		{Line: 30, Directive: "python3-*", Reason: "package globs aren't supported, list the matching packages instead"},
+		{Line: 34, Directive: "%post", Reason: "the section isn't supported"},
+		{Line: 20, Directive: "sshkey", Reason: "user (nobody) isn't defined"},
+	}, unsupported)
+
+	assert.Equal(t, []Disk{
+		{
+			PartitionTableType: PartitionTableTypeGpt,
+			MaxSize:            7769,
+			Partitions: []Partition{
+				{ID: "esp", Start: 1, End: 601, FsType: "fat32", Flags: []PartitionFlag{PartitionFlagESP, PartitionFlagBoot}},
+				{ID: "boot", Start: 601, End: 1625, FsType: "xfs"},
+				{ID: "swap", Start: 1625, End: 3673, FsType: "linux-swap"},
+				{ID: "rootfs", Start: 3673, End: 0, FsType: "xfs"},
+			},
+		},
+		{
+			PartitionTableType: PartitionTableTypeGpt,
+			MaxSize:            8194,
+			Partitions: []Partition{
+				{ID: "data", Start: 1, End: 8193, FsType: "ext4"},
+			},
+		},
+	}, config.Disks)
+
+	assert.Len(t, config.SystemConfigs, 1)
+	systemConfig := config.SystemConfigs[0]
+	assert.Equal(t, KickstartSystemConfigName, systemConfig.Name)
+	assert.Equal(t, "efi", systemConfig.BootType)
+	assert.Equal(t, []PartitionSetting{
+		{ID: "esp", MountIdentifier: MountIdentifierDefault, MountPoint: "/boot/efi", MountOptions: "umask=0077,nodev"},
+		{ID: "boot", MountIdentifier: MountIdentifierDefault, MountPoint: "/boot"},
+		{ID: "swap", MountIdentifier: MountIdentifierDefault},
+		{ID: "rootfs", MountIdentifier: MountIdentifierDefault, MountPoint: "/", MountOptions: "noatime,nodev"},
+		{ID: "data", MountIdentifier: MountIdentifierDefault, MountPoint: "/data"},
+	}, systemConfig.PartitionSettings)
+	assert.Equal(t, "console=ttyS0 quiet", systemConfig.KernelCommandLine.ExtraCommandLine)
+
+	assert.Equal(t, []string{"openssh-server", "vim"}, systemConfig.Packages)
+	assert.Equal(t, []string{"man-db"}, systemConfig.RemovePackages)
+	assert.Equal(t, map[string]string{"default": "kernel"}, systemConfig.KernelOptions)
+	assert.True(t, systemConfig.DisableRpmDocs)
+	assert.Equal(t, "en_US", systemConfig.OverrideRpmLocales)
+
+	assert.Equal(t, []Group{{Name: "admins", GID: "2000"}}, systemConfig.Groups)
+	assert.Equal(t, []User{
+		{Name: "root", Password: "$6$salt$hash", PasswordHashed: true},
+		{
+			Name:            "azureuser",
+			UID:             "1000",
+			Password:        "secret",
+			SecondaryGroups: []string{"wheel", "admins"},
+			StartupCommand:  "/bin/bash",
+			SSHPubKeys:      []string{"ssh-ed25519 AAAAC3NzaC1lZDI1NTE5 azureuser@contoso"},
+		},
+	}, systemConfig.Users)
+
+	assert.Equal(t, "contoso-vm", systemConfig.Hostname)
+	assert.Equal(t, []Network{{
+		BootProto:   "static",
+		Device:      "eth0",
+		Ip:          "10.0.0.5",
+		NetMask:     "255.255.255.0",
+		GateWay:     "10.0.0.1",
+		NameServers: []string{"10.0.0.2", "10.0.0.3"},
+		OnBoot:      true,
+	}}, systemConfig.Networks)
+
+	assert.NoError(t, config.IsValid())
+}
+
+func TestShouldSucceedImportingKickstartFile_Kickstart(t *testing.T) {
+	kickstartFile := filepath.Join(t.TempDir(), "ks.cfg")
+	err := os.WriteFile(kickstartFile, []byte(validKickstart), 0o644)
+	assert.NoError(t, err)
+
+	config, unsupported, err := ImportKickstart(kickstartFile)
+	assert.NoError(t, err)
+	assert.Len(t, config.SystemConfigs, 1)
+	assert.Len(t, unsupported, 9)
+}
+
+func TestShouldSucceedParsingLegacyKickstart_Kickstart(t *testing.T) {
+	kickstart := `clearpart --disklabel=msdos
+partition / --size=4096
+%packages
+core-packages-base-image
+%end
+`
+
+	config, unsupported, err := ParseKickstart(kickstart)
+	assert.NoError(t, err)
+	assert.Empty(t, unsupported)
+	assert.Equal(t, "legacy", config.SystemConfigs[0].BootType)
+	assert.Equal(t, PartitionTableTypeMbr, config.Disks[0].PartitionTableType)
+	assert.Equal(t, Partition{ID: "rootfs", Start: 1, End: 4097, FsType: "ext4"}, config.Disks[0].Partitions[0])
+	assert.NoError(t, config.IsValid())
+}
+
+func TestShouldSucceedReportingFixedSizeGrowingPartition_Kickstart(t *testing.T) {
+	kickstart := `part / --size=4096 --grow
+part /var --size=1024`
+
+	config, unsupported, err := ParseKickstart(kickstart)
+	assert.NoError(t, err)
+	assert.Equal(t, []UnsupportedKickstartDirective{{
+		Line:      1,
+		Directive: "part --grow",
+		Reason:    "only the last partition of a disk can grow, so partition (/) has a fixed size",
+	}}, unsupported)
+	assert.Equal(t, uint64(4097), config.Disks[0].Partitions[0].End)
+	assert.Equal(t, uint64(5122), config.Disks[0].MaxSize)
+}
+
+func TestShouldSucceedReportingUnsupportedVolumes_Kickstart(t *testing.T) {
+	kickstart := `part pv.01 --size=8192
+volgroup vg00 pv.01
+logvol / --vgname=vg00 --name=root --size=4096
+autopart`
+
+	config, unsupported, err := ParseKickstart(kickstart)
+	assert.NoError(t, err)
+	assert.Empty(t, config.Disks)
+	assert.Equal(t, []UnsupportedKickstartDirective{
+		{Line: 1, Directive: "part pv.01", Reason: "RAID members, LVM physical volumes and btrfs volumes aren't supported"},
+		{Line: 2, Directive: "volgroup", Reason: "the directive isn't supported"},
+		{Line: 3, Directive: "logvol", Reason: "the directive isn't supported"},
+		{Line: 4, Directive: "autopart", Reason: "the directive isn't supported"},
+	}, unsupported)
+}
+
+func TestShouldFailParsingPartitionWithoutSize_Kickstart(t *testing.T) {
+	_, _, err := ParseKickstart("part /var --fstype=xfs")
+	assert.Error(t, err)
+	assert.Equal(t, "failed to import kickstart line (1):\npartition (/var) has no --size", err.Error())
+}
+
+func TestShouldFailParsingUnterminatedQuote_Kickstart(t *testing.T) {
+	_, _, err := ParseKickstart(`bootloader --append="console=ttyS0`)
+	assert.Error(t, err)
+	assert.Equal(t, "failed to parse kickstart line (1):\nunterminated quote (\")", err.Error())
+}
+
+func TestShouldFailParsingUnterminatedSection_Kickstart(t *testing.T) {
+	_, _, err := ParseKickstart("%packages\nvim\n")
+	assert.Error(t, err)
+	assert.Equal(t, "kickstart section (%packages) on line (1) has no (%end)", err.Error())
+}
+
+func TestShouldFailParsingInvalidOnBoot_Kickstart(t *testing.T) {
+	_, _, err := ParseKickstart("network --device=eth0 --bootproto=dhcp --onboot=maybe")
+	assert.Error(t, err)
+	assert.Equal(t, "failed to import kickstart line (1):\ninvalid --onboot:\ninvalid boolean (maybe)", err.Error())
+}
+
+func TestShouldSucceedSplittingKickstartLine_Kickstart(t *testing.T) {
+	tokens, err := splitKickstartLine(`user --name=a --gecos="A \"quoted\" user" 'single # quoted' # comment`)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"user", "--name=a", `--gecos=A "quoted" user`, "single # quoted"}, tokens)
+
+	tokens, err = splitKickstartLine("   # only a comment")
+	assert.NoError(t, err)
+	assert.Empty(t, tokens)
+}
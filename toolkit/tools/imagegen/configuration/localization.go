@@ -0,0 +1,87 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+// Parser for the image builder's configuration schemas.
+
+package configuration
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+)
+
+var (
+	// localeRegex matches POSIX locale names: language[_territory][.codeset][@modifier], e.g. de_DE.UTF-8.
+	localeRegex = regexp.MustCompile(`^[A-Za-z]+(_[A-Za-z]+)?(\.[A-Za-z0-9-]+)?(@[A-Za-z0-9]+)?$`)
+	// timezoneRegex matches the names of the tz database's timezones, e.g. America/Los_Angeles or Etc/GMT+5.
+	timezoneRegex = regexp.MustCompile(`^[A-Za-z0-9_+-]+(/[A-Za-z0-9_+-]+)*$`)
+	// keymapRegex matches the names of the console's keymaps, e.g. de-latin1.
+	keymapRegex = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+)
+
+// builtinLocales are the locales that the glibc package includes. The other locales are in glibc-locales-all.
+var builtinLocales = map[string]bool{
+	"C":           true,
+	"POSIX":       true,
+	"C.UTF-8":     true,
+	"C.utf8":      true,
+	"en_US.UTF-8": true,
+	"en_US.utf8":  true,
+}
+
+// Localization is the system's locale, timezone and console keymap. Each is left as the packages set it if it's
+// empty.
+type Localization struct {
+	// The system locale (e.g. "de_DE.UTF-8"), which is set as LANG in /etc/locale.conf.
+	Locale string `json:"Locale"`
+	// The tz database's name of the timezone (e.g. "Europe/Berlin"), which /etc/localtime links to.
+	Timezone string `json:"Timezone"`
+	// The console's keymap (e.g. "de-latin1"), which is set in /etc/vconsole.conf.
+	Keymap string `json:"Keymap"`
+}
+
+// IsEmpty returns true if the Localization doesn't change any of the system's settings.
+func (l *Localization) IsEmpty() bool {
+	return l.Locale == "" && l.Timezone == "" && l.Keymap == ""
+}
+
+// IsBuiltinLocale returns true if the locale is included in the glibc package, so that it doesn't need the
+// glibc-locales-all package.
+func (l *Localization) IsBuiltinLocale() bool {
+	return l.Locale == "" || builtinLocales[l.Locale]
+}
+
+// IsValid returns an error if the Localization is not valid
+func (l *Localization) IsValid() (err error) {
+	if l.Locale != "" && !localeRegex.MatchString(l.Locale) {
+		return fmt.Errorf("invalid [Locale] (%s), must be a locale name, e.g. de_DE.UTF-8", l.Locale)
+	}
+
+	if l.Timezone != "" && !timezoneRegex.MatchString(l.Timezone) {
+		return fmt.Errorf("invalid [Timezone] (%s), must be the name of a timezone, e.g. Europe/Berlin", l.Timezone)
+	}
+
+	if l.Keymap != "" && !keymapRegex.MatchString(l.Keymap) {
+		return fmt.Errorf("invalid [Keymap] (%s), must be the name of a keymap, e.g. de-latin1", l.Keymap)
+	}
+
+	return
+}
+
+// UnmarshalJSON Unmarshals a Localization entry
+func (l *Localization) UnmarshalJSON(b []byte) (err error) {
+	// Use an intermediate type which will use the default JSON unmarshal implementation
+	type IntermediateTypeLocalization Localization
+	err = json.Unmarshal(b, (*IntermediateTypeLocalization)(l))
+	if err != nil {
+		return fmt.Errorf("failed to parse [Localization]: %w", err)
+	}
+
+	// Now validate the resulting unmarshaled object
+	err = l.IsValid()
+	if err != nil {
+		return fmt.Errorf("failed to parse [Localization]: %w", err)
+	}
+	return
+}
@@ -0,0 +1,101 @@
+// Copyright Microsoft Corporation.
+// Licensed under the MIT License.
+
+package configuration
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestMain found in configuration_test.go.
+
+var (
+	validLocalization = Localization{
+		Locale:   "de_DE.UTF-8",
+		Timezone: "Europe/Berlin",
+		Keymap:   "de-latin1",
+	}
+	invalidLocalizationJSON = `{"Locale": 1234}`
+)
+
+func TestShouldSucceedParsingDefaultLocalization_Localization(t *testing.T) {
+	var checkedLocalization Localization
+
+	err := marshalJSONString("{}", &checkedLocalization)
+	assert.NoError(t, err)
+	assert.Equal(t, Localization{}, checkedLocalization)
+	assert.True(t, checkedLocalization.IsEmpty())
+	assert.True(t, checkedLocalization.IsBuiltinLocale())
+}
+
+func TestShouldSucceedParsingValidLocalization_Localization(t *testing.T) {
+	var checkedLocalization Localization
+
+	assert.NoError(t, validLocalization.IsValid())
+	err := remarshalJSON(validLocalization, &checkedLocalization)
+	assert.NoError(t, err)
+	assert.Equal(t, validLocalization, checkedLocalization)
+	assert.False(t, checkedLocalization.IsEmpty())
+	assert.False(t, checkedLocalization.IsBuiltinLocale())
+}
+
+func TestShouldSucceedParsingValidNames_Localization(t *testing.T) {
+	for _, localization := range []Localization{
+		{Locale: "C.UTF-8"},
+		{Locale: "sr_RS@latin"},
+		{Timezone: "UTC"},
+		{Timezone: "Etc/GMT+5"},
+		{Timezone: "America/Argentina/Buenos_Aires"},
+		{Keymap: "us"},
+	} {
+		assert.NoError(t, localization.IsValid())
+	}
+}
+
+func TestShouldSucceedCheckingBuiltinLocales_Localization(t *testing.T) {
+	for _, locale := range []string{"C", "POSIX", "C.UTF-8", "en_US.UTF-8", "en_US.utf8"} {
+		localization := Localization{Locale: locale}
+		assert.True(t, localization.IsBuiltinLocale(), locale)
+	}
+}
+
+func TestShouldFailParsingInvalidJSON_Localization(t *testing.T) {
+	var checkedLocalization Localization
+
+	err := marshalJSONString(invalidLocalizationJSON, &checkedLocalization)
+	assert.Error(t, err)
+	assert.Equal(t, "failed to parse [Localization]: json: cannot unmarshal number into Go struct field IntermediateTypeLocalization.Locale of type string", err.Error())
+}
+
+func TestShouldFailInvalidLocale_Localization(t *testing.T) {
+	var checkedLocalization Localization
+
+	invalidLocalization := validLocalization
+	invalidLocalization.Locale = "de_DE UTF-8"
+
+	err := remarshalJSON(invalidLocalization, &checkedLocalization)
+	assert.Error(t, err)
+	assert.Equal(t, "failed to parse [Localization]: invalid [Locale] (de_DE UTF-8), must be a locale name, e.g. de_DE.UTF-8", err.Error())
+}
+
+func TestShouldFailInvalidTimezone_Localization(t *testing.T) {
+	for _, timezone := range []string{"../../etc/shadow", "Europe/", "/Europe/Berlin"} {
+		invalidLocalization := validLocalization
+		invalidLocalization.Timezone = timezone
+
+		err := invalidLocalization.IsValid()
+		assert.Error(t, err)
+		assert.Equal(t, "invalid [Timezone] ("+timezone+"), must be the name of a timezone, e.g. Europe/Berlin", err.Error())
+	}
+}
+
+func TestShouldFailInvalidKeymap_Localization(t *testing.T) {
+	invalidLocalization := validLocalization
+	invalidLocalization.Keymap = "de/latin1"
+
+	err := invalidLocalization.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "invalid [Keymap] (de/latin1), must be the name of a keymap, e.g. de-latin1", err.Error())
+}
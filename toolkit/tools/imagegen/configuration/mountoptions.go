@@ -0,0 +1,144 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+// Parser for the image builder's configuration schemas.
+
+package configuration
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/sliceutils"
+)
+
+const systemdMountOptionPrefix = "x-systemd."
+
+// systemdMountOptions are the fstab options of systemd's mount units, mapped to whether they require a value.
+var systemdMountOptions = map[string]bool{
+	"x-systemd.requires":            true,
+	"x-systemd.before":              true,
+	"x-systemd.after":               true,
+	"x-systemd.wanted-by":           true,
+	"x-systemd.required-by":         true,
+	"x-systemd.requires-mounts-for": true,
+	"x-systemd.wants-mounts-for":    true,
+	"x-systemd.device-bound":        false,
+	"x-systemd.automount":           false,
+	"x-systemd.idle-timeout":        true,
+	"x-systemd.device-timeout":      true,
+	"x-systemd.mount-timeout":       true,
+	"x-systemd.makefs":              false,
+	"x-systemd.growfs":              false,
+	"x-systemd.pcrfs":               false,
+	"x-systemd.rw-only":             false,
+}
+
+var (
+	extMountOptions = []string{
+		"acl", "noacl", "user_xattr", "nouser_xattr", "errors", "resgid", "resuid", "sb", "grpid", "nogrpid",
+		"bsdgroups", "sysvgroups", "bsddf", "minixdf",
+	}
+	ext3MountOptions = append([]string{
+		"data", "commit", "journal_dev", "journal_path", "barrier", "nobarrier",
+	}, extMountOptions...)
+	ext4MountOptions = append([]string{
+		"journal_checksum", "nojournal_checksum", "journal_async_commit", "journal_ioprio", "delalloc", "nodelalloc",
+		"auto_da_alloc", "noauto_da_alloc", "dioread_nolock", "dioread_lock", "init_itable", "noinit_itable",
+		"max_batch_time", "min_batch_time", "stripe", "inode_readahead_blks", "block_validity", "noblock_validity",
+		"discard", "nodiscard", "dax", "nombcache", "prjquota", "i_version",
+	}, ext3MountOptions...)
+	xfsMountOptions = []string{
+		"allocsize", "attr2", "noattr2", "discard", "nodiscard", "grpid", "bsdgroups", "nogrpid", "sysvgroups",
+		"filestreams", "ikeep", "noikeep", "inode32", "inode64", "largeio", "nolargeio", "logbufs", "logbsize", "logdev",
+		"noalign", "norecovery", "nouuid", "noquota", "uquota", "usrquota", "uqnoenforce", "quota", "pquota", "prjquota",
+		"pqnoenforce", "gquota", "grpquota", "gqnoenforce", "sunit", "swidth", "swalloc", "wsync", "dax",
+	}
+	btrfsMountOptions = []string{
+		"acl", "noacl", "autodefrag", "noautodefrag", "barrier", "nobarrier", "clear_cache", "commit", "compress",
+		"compress-force", "datacow", "nodatacow", "datasum", "nodatasum", "degraded", "device", "discard", "nodiscard",
+		"enospc_debug", "fatal_errors", "flushoncommit", "noflushoncommit", "max_inline", "metadata_ratio",
+		"norecovery", "rescan_uuid_tree", "rescue", "skip_balance", "space_cache", "nospace_cache", "ssd", "nossd",
+		"ssd_spread", "nossd_spread", "subvol", "subvolid", "thread_pool", "treelog", "notreelog",
+		"user_subvol_rm_allowed",
+	}
+	fatMountOptions = []string{
+		"uid", "gid", "umask", "dmask", "fmask", "allow_utime", "check", "codepage", "conv", "discard", "dos1xfloppy",
+		"errors", "fat", "iocharset", "nfs", "tz", "time_offset", "quiet", "rodir", "showexec", "sys_immutable",
+		"flush", "usefree", "dots", "nodots", "dotsOK", "shortname", "uni_xlate", "posix", "nonumtail", "utf8",
+	}
+
+	// fileSystemMountOptions are the mount options that are specific to a file system, mapped by the file system
+	// types. The options that are in none of the lists (e.g. noatime) are supported by all of the file systems.
+	fileSystemMountOptions = map[string][]string{
+		"ext2":      extMountOptions,
+		"ext3":      ext3MountOptions,
+		"ext4":      ext4MountOptions,
+		"xfs":       xfsMountOptions,
+		BtrfsFsType: btrfsMountOptions,
+		"fat16":     fatMountOptions,
+		"fat32":     fatMountOptions,
+		"vfat":      fatMountOptions,
+	}
+)
+
+// mountOptionsAreValid returns an error if the comma separated mount options can't be an fstab entry's options, or
+// if they have an unknown systemd option.
+func mountOptionsAreValid(mountOptions string) (err error) {
+	if mountOptions == "" {
+		return
+	}
+
+	if strings.ContainsAny(mountOptions, " \t\n") {
+		return fmt.Errorf("must not have whitespace")
+	}
+
+	for _, option := range strings.Split(mountOptions, ",") {
+		name, _, hasValue := strings.Cut(option, "=")
+		if name == "" {
+			return fmt.Errorf("empty option")
+		}
+
+		// A btrfs subvolume is mounted by its partition setting's [Subvolume], which the fstab entry also uses.
+		if name == "subvol" || name == "subvolid" {
+			return fmt.Errorf("option (%s) is set by [Subvolume]", name)
+		}
+
+		if strings.HasPrefix(name, systemdMountOptionPrefix) {
+			requiresValue, found := systemdMountOptions[name]
+			if !found {
+				return fmt.Errorf("unknown systemd option (%s)", name)
+			}
+			if requiresValue != hasValue {
+				if requiresValue {
+					return fmt.Errorf("systemd option (%s) requires a value", name)
+				}
+				return fmt.Errorf("systemd option (%s) doesn't take a value", name)
+			}
+		}
+	}
+	return
+}
+
+// mountOptionsAreCompatible returns an error if one of the mount options is specific to other file systems than the
+// file system type. The options of unknown file system types aren't checked.
+func mountOptionsAreCompatible(fsType, mountOptions string) (err error) {
+	supportedOptions, found := fileSystemMountOptions[fsType]
+	if !found || mountOptions == "" {
+		return
+	}
+
+	for _, option := range strings.Split(mountOptions, ",") {
+		name, _, _ := strings.Cut(option, "=")
+		if sliceutils.ContainsValue(supportedOptions, name) {
+			continue
+		}
+
+		for _, otherOptions := range fileSystemMountOptions {
+			if sliceutils.ContainsValue(otherOptions, name) {
+				return fmt.Errorf("option (%s) isn't supported by a (%s) file system", name, fsType)
+			}
+		}
+	}
+	return
+}
@@ -0,0 +1,80 @@
+// Copyright Microsoft Corporation.
+// Licensed under the MIT License.
+
+package configuration
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestMain found in configuration_test.go.
+
+func TestShouldSucceedValidMountOptions_MountOptions(t *testing.T) {
+	assert.NoError(t, mountOptionsAreValid(""))
+	assert.NoError(t, mountOptionsAreValid("defaults"))
+	assert.NoError(t, mountOptionsAreValid("noatime,nodev,nosuid,noexec"))
+	assert.NoError(t, mountOptionsAreValid("nofail,x-systemd.automount,x-systemd.idle-timeout=1min"))
+	assert.NoError(t, mountOptionsAreValid("x-systemd.requires=network-online.target,x-systemd.growfs"))
+}
+
+func TestShouldFailInvalidMountOptions_MountOptions(t *testing.T) {
+	err := mountOptionsAreValid("noatime, nodev")
+	assert.Error(t, err)
+	assert.Equal(t, "must not have whitespace", err.Error())
+
+	err = mountOptionsAreValid("noatime,")
+	assert.Error(t, err)
+	assert.Equal(t, "empty option", err.Error())
+
+	err = mountOptionsAreValid("=1")
+	assert.Error(t, err)
+	assert.Equal(t, "empty option", err.Error())
+
+	err = mountOptionsAreValid("subvol=@home")
+	assert.Error(t, err)
+	assert.Equal(t, "option (subvol) is set by [Subvolume]", err.Error())
+
+	err = mountOptionsAreValid("x-systemd.automounts")
+	assert.Error(t, err)
+	assert.Equal(t, "unknown systemd option (x-systemd.automounts)", err.Error())
+
+	err = mountOptionsAreValid("x-systemd.device-timeout")
+	assert.Error(t, err)
+	assert.Equal(t, "systemd option (x-systemd.device-timeout) requires a value", err.Error())
+
+	err = mountOptionsAreValid("x-systemd.growfs=1")
+	assert.Error(t, err)
+	assert.Equal(t, "systemd option (x-systemd.growfs) doesn't take a value", err.Error())
+}
+
+func TestShouldSucceedCompatibleMountOptions_MountOptions(t *testing.T) {
+	assert.NoError(t, mountOptionsAreCompatible("ext4", "noatime,data=ordered,commit=30,x-systemd.growfs"))
+	assert.NoError(t, mountOptionsAreCompatible("xfs", "noatime,inode64,logbufs=8"))
+	assert.NoError(t, mountOptionsAreCompatible("btrfs", "compress=zstd,ssd,noatime"))
+	assert.NoError(t, mountOptionsAreCompatible("fat32", "umask=0077,shortname=mixed"))
+	assert.NoError(t, mountOptionsAreCompatible("vfat", "uid=0,gid=0"))
+
+	// The options of unknown file systems aren't checked.
+	assert.NoError(t, mountOptionsAreCompatible("", "umask=0077"))
+	assert.NoError(t, mountOptionsAreCompatible("linux-swap", "compress=zstd"))
+}
+
+func TestShouldFailIncompatibleMountOptions_MountOptions(t *testing.T) {
+	err := mountOptionsAreCompatible("ext4", "noatime,compress=zstd")
+	assert.Error(t, err)
+	assert.Equal(t, "option (compress) isn't supported by a (ext4) file system", err.Error())
+
+	err = mountOptionsAreCompatible("ext2", "data=journal")
+	assert.Error(t, err)
+	assert.Equal(t, "option (data) isn't supported by a (ext2) file system", err.Error())
+
+	err = mountOptionsAreCompatible("xfs", "umask=0077")
+	assert.Error(t, err)
+	assert.Equal(t, "option (umask) isn't supported by a (xfs) file system", err.Error())
+
+	err = mountOptionsAreCompatible("fat32", "inode64")
+	assert.Error(t, err)
+	assert.Equal(t, "option (inode64) isn't supported by a (fat32) file system", err.Error())
+}
@@ -0,0 +1,326 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+// Parser for the image builder's configuration schemas.
+
+package configuration
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+)
+
+// NetworkRenderer is the network service that the image's network configuration is written for
+type NetworkRenderer string
+
+const (
+	// NetworkRendererNetworkd writes systemd-networkd .network and .netdev files
+	NetworkRendererNetworkd NetworkRenderer = "networkd"
+	// NetworkRendererNetworkManager writes NetworkManager keyfiles
+	NetworkRendererNetworkManager NetworkRenderer = "networkmanager"
+	// NetworkRendererDefault writes systemd-networkd files
+	NetworkRendererDefault NetworkRenderer = ""
+)
+
+const (
+	// maxInterfaceNameLength is the longest name that the kernel accepts for a network interface (IFNAMSIZ - 1).
+	maxInterfaceNameLength = 15
+	maxVlanID              = 4094
+)
+
+// validDHCPValues are the accepted values of [DHCP], as in systemd-networkd's DHCP= setting.
+var validDHCPValues = map[string]bool{
+	"":     true,
+	"no":   true,
+	"yes":  true,
+	"ipv4": true,
+	"ipv6": true,
+}
+
+// validBondModes are the bonding modes that both renderers support.
+var validBondModes = map[string]bool{
+	"balance-rr":    true,
+	"active-backup": true,
+	"balance-xor":   true,
+	"broadcast":     true,
+	"802.3ad":       true,
+	"balance-tlb":   true,
+	"balance-alb":   true,
+}
+
+// Networking is the declarative network configuration of the image, which is rendered into systemd-networkd files or
+// NetworkManager keyfiles when the image is built. Unlike [Networks], it doesn't depend on the machine that the image
+// is built or installed on.
+type Networking struct {
+	Renderer   NetworkRenderer    `json:"Renderer"`
+	Interfaces []NetworkInterface `json:"Interfaces"`
+	Vlans      []NetworkVlan      `json:"Vlans"`
+	Bonds      []NetworkBond      `json:"Bonds"`
+	Bridges    []NetworkBridge    `json:"Bridges"`
+	// The DNS servers that are used for all the interfaces.
+	DNS []string `json:"DNS"`
+}
+
+// NetworkAddressing is the addressing of a network interface, VLAN, bond or bridge.
+// "DHCP" is one of "yes", "ipv4", "ipv6" or "no" (the default). "Addresses" are static addresses with their prefix
+// lengths, e.g. "192.168.1.10/24".
+type NetworkAddressing struct {
+	DHCP      string   `json:"DHCP"`
+	Addresses []string `json:"Addresses"`
+	Gateway   string   `json:"Gateway"`
+	DNS       []string `json:"DNS"`
+}
+
+// NetworkInterface is a physical network interface, which is matched by its name, or by its MAC address.
+type NetworkInterface struct {
+	Name       string `json:"Name"`
+	MACAddress string `json:"MACAddress"`
+	NetworkAddressing
+}
+
+// NetworkVlan is a VLAN on a network interface or bond ("Link").
+type NetworkVlan struct {
+	Name string `json:"Name"`
+	ID   uint16 `json:"ID"`
+	Link string `json:"Link"`
+	NetworkAddressing
+}
+
+// NetworkBond bonds network interfaces together. "Mode" defaults to active-backup.
+type NetworkBond struct {
+	Name       string   `json:"Name"`
+	Mode       string   `json:"Mode"`
+	Interfaces []string `json:"Interfaces"`
+	NetworkAddressing
+}
+
+// NetworkBridge bridges network interfaces together.
+type NetworkBridge struct {
+	Name       string   `json:"Name"`
+	Interfaces []string `json:"Interfaces"`
+	NetworkAddressing
+}
+
+func (r NetworkRenderer) String() string {
+	return fmt.Sprint(string(r))
+}
+
+// GetValidNetworkRenderers returns a list of all the supported network renderers
+func (r *NetworkRenderer) GetValidNetworkRenderers() (renderers []NetworkRenderer) {
+	return []NetworkRenderer{
+		NetworkRendererNetworkd,
+		NetworkRendererNetworkManager,
+		NetworkRendererDefault,
+	}
+}
+
+// IsValid returns an error if the NetworkRenderer is not valid
+func (r *NetworkRenderer) IsValid() (err error) {
+	for _, valid := range r.GetValidNetworkRenderers() {
+		if *r == valid {
+			return
+		}
+	}
+	return fmt.Errorf("invalid value for NetworkRenderer (%s)", r)
+}
+
+// GetMode returns the bonding mode of the bond
+func (b *NetworkBond) GetMode() string {
+	if b.Mode == "" {
+		return "active-backup"
+	}
+	return b.Mode
+}
+
+// IsEmpty returns true if the Networking doesn't configure anything.
+func (n *Networking) IsEmpty() bool {
+	return len(n.Interfaces) == 0 && len(n.Vlans) == 0 && len(n.Bonds) == 0 && len(n.Bridges) == 0 && len(n.DNS) == 0
+}
+
+// HasAddressing returns true if the addressing configures any addresses.
+func (a *NetworkAddressing) HasAddressing() bool {
+	return (a.DHCP != "" && a.DHCP != "no") || len(a.Addresses) > 0 || a.Gateway != "" || len(a.DNS) > 0
+}
+
+// IsValid returns an error if the NetworkAddressing is not valid
+func (a *NetworkAddressing) IsValid() (err error) {
+	if !validDHCPValues[a.DHCP] {
+		return fmt.Errorf("invalid [DHCP] (%s), must be one of yes, ipv4, ipv6 or no", a.DHCP)
+	}
+
+	for _, address := range a.Addresses {
+		_, err = netip.ParsePrefix(address)
+		if err != nil {
+			return fmt.Errorf("invalid address (%s), must be an IP address with a prefix length, e.g. 192.168.1.10/24", address)
+		}
+	}
+
+	if a.Gateway != "" && net.ParseIP(a.Gateway) == nil {
+		return fmt.Errorf("invalid [Gateway] (%s)", a.Gateway)
+	}
+
+	return dnsServersAreValid(a.DNS)
+}
+
+// IsValid returns an error if the Networking is not valid
+func (n *Networking) IsValid() (err error) {
+	if err = n.Renderer.IsValid(); err != nil {
+		return fmt.Errorf("invalid [Renderer]: %w", err)
+	}
+
+	if err = dnsServersAreValid(n.DNS); err != nil {
+		return
+	}
+
+	names := make(map[string]string)
+	addName := func(kind, name string, addressing NetworkAddressing) error {
+		if err := interfaceNameIsValid(name); err != nil {
+			return fmt.Errorf("invalid %s name: %w", kind, err)
+		}
+		if _, found := names[name]; found {
+			return fmt.Errorf("%s (%s) has the same name as another interface, bond, bridge or VLAN", kind, name)
+		}
+		names[name] = kind
+
+		if err := addressing.IsValid(); err != nil {
+			return fmt.Errorf("invalid %s (%s): %w", kind, name, err)
+		}
+		return nil
+	}
+
+	for _, iface := range n.Interfaces {
+		if err = addName("interface", iface.Name, iface.NetworkAddressing); err != nil {
+			return
+		}
+		if iface.MACAddress != "" {
+			if _, err = net.ParseMAC(iface.MACAddress); err != nil {
+				return fmt.Errorf("invalid [MACAddress] (%s) of interface (%s)", iface.MACAddress, iface.Name)
+			}
+		}
+	}
+
+	for _, bond := range n.Bonds {
+		if err = addName("bond", bond.Name, bond.NetworkAddressing); err != nil {
+			return
+		}
+		if !validBondModes[bond.GetMode()] {
+			return fmt.Errorf("invalid [Mode] (%s) of bond (%s)", bond.Mode, bond.Name)
+		}
+		if len(bond.Interfaces) == 0 {
+			return fmt.Errorf("bond (%s) must have at least one of [Interfaces]", bond.Name)
+		}
+	}
+
+	for _, bridge := range n.Bridges {
+		if err = addName("bridge", bridge.Name, bridge.NetworkAddressing); err != nil {
+			return
+		}
+	}
+
+	for _, vlan := range n.Vlans {
+		if err = addName("VLAN", vlan.Name, vlan.NetworkAddressing); err != nil {
+			return
+		}
+		if vlan.ID == 0 || vlan.ID > maxVlanID {
+			return fmt.Errorf("invalid [ID] (%d) of VLAN (%s), must be from 1 to %d", vlan.ID, vlan.Name, maxVlanID)
+		}
+		linkKind := names[vlan.Link]
+		if linkKind != "interface" && linkKind != "bond" {
+			return fmt.Errorf("VLAN (%s) has a [Link] (%s) which isn't one of the [Interfaces] or [Bonds]", vlan.Name, vlan.Link)
+		}
+	}
+
+	return n.checkPorts(names)
+}
+
+// checkPorts checks that the interfaces of the bonds and bridges are only in one of them, and that they don't have
+// their own addressing, as they are only used by their bond or bridge.
+func (n *Networking) checkPorts(names map[string]string) (err error) {
+	controllers := make(map[string]string)
+	checkPort := func(controller, port string) error {
+		if err := interfaceNameIsValid(port); err != nil {
+			return fmt.Errorf("invalid interface of (%s): %w", controller, err)
+		}
+		if port == controller {
+			return fmt.Errorf("(%s) can't be one of its own [Interfaces]", controller)
+		}
+		if otherController, found := controllers[port]; found {
+			return fmt.Errorf("interface (%s) is in both (%s) and (%s)", port, otherController, controller)
+		}
+		controllers[port] = controller
+
+		if kind := names[port]; kind != "" && kind != "interface" && kind != "bond" {
+			return fmt.Errorf("(%s) is a %s, so it can't be one of the [Interfaces] of (%s)", port, kind, controller)
+		}
+		return nil
+	}
+
+	for _, bond := range n.Bonds {
+		for _, port := range bond.Interfaces {
+			if err = checkPort(bond.Name, port); err != nil {
+				return
+			}
+			if names[port] == "bond" {
+				return fmt.Errorf("(%s) is a bond, so it can't be one of the [Interfaces] of (%s)", port, bond.Name)
+			}
+		}
+	}
+
+	for _, bridge := range n.Bridges {
+		for _, port := range bridge.Interfaces {
+			if err = checkPort(bridge.Name, port); err != nil {
+				return
+			}
+		}
+	}
+
+	for _, iface := range n.Interfaces {
+		if controller, found := controllers[iface.Name]; found && iface.HasAddressing() {
+			return fmt.Errorf("interface (%s) is one of the [Interfaces] of (%s), so it can't have its own addressing", iface.Name, controller)
+		}
+	}
+	for _, bond := range n.Bonds {
+		if controller, found := controllers[bond.Name]; found && bond.HasAddressing() {
+			return fmt.Errorf("bond (%s) is one of the [Interfaces] of (%s), so it can't have its own addressing", bond.Name, controller)
+		}
+	}
+
+	return
+}
+
+func interfaceNameIsValid(name string) (err error) {
+	if name == "" || len(name) > maxInterfaceNameLength || name == "." || name == ".." ||
+		strings.ContainsAny(name, "/: \t\n") {
+		return fmt.Errorf("(%s) must have 1 to %d characters, without '/', ':' or whitespace", name, maxInterfaceNameLength)
+	}
+	return
+}
+
+func dnsServersAreValid(dnsServers []string) (err error) {
+	for _, dnsServer := range dnsServers {
+		if net.ParseIP(dnsServer) == nil {
+			return fmt.Errorf("invalid DNS server (%s)", dnsServer)
+		}
+	}
+	return
+}
+
+// UnmarshalJSON Unmarshals a Networking entry
+func (n *Networking) UnmarshalJSON(b []byte) (err error) {
+	// Use an intermediate type which will use the default JSON unmarshal implementation
+	type IntermediateTypeNetworking Networking
+	err = json.Unmarshal(b, (*IntermediateTypeNetworking)(n))
+	if err != nil {
+		return fmt.Errorf("failed to parse [Networking]: %w", err)
+	}
+
+	// Now validate the resulting unmarshaled object
+	err = n.IsValid()
+	if err != nil {
+		return fmt.Errorf("failed to parse [Networking]: %w", err)
+	}
+	return
+}
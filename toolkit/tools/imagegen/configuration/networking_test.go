@@ -0,0 +1,224 @@
+// Copyright Microsoft Corporation.
+// Licensed under the MIT License.
+
+package configuration
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestMain found in configuration_test.go.
+
+var (
+	validNetworking = Networking{
+		Interfaces: []NetworkInterface{
+			{Name: "eth0", NetworkAddressing: NetworkAddressing{DHCP: "ipv4"}},
+			{Name: "eth1", MACAddress: "00:15:5d:01:02:03"},
+		},
+		Bonds: []NetworkBond{
+			{
+				Name:       "bond0",
+				Mode:       "802.3ad",
+				Interfaces: []string{"eth1", "eth2"},
+				NetworkAddressing: NetworkAddressing{
+					Addresses: []string{"192.168.10.5/24", "fd00::5/64"},
+					Gateway:   "192.168.10.1",
+				},
+			},
+		},
+		Vlans: []NetworkVlan{
+			{Name: "vlan20", ID: 20, Link: "bond0", NetworkAddressing: NetworkAddressing{Addresses: []string{"10.20.0.5/16"}}},
+		},
+		Bridges: []NetworkBridge{
+			{Name: "br0", Interfaces: []string{"eth3"}, NetworkAddressing: NetworkAddressing{DHCP: "yes"}},
+		},
+		DNS: []string{"192.168.10.1", "fd00::1"},
+	}
+	invalidNetworkingJSON = `{"Vlans": [{"Name": "vlan20", "ID": "20"}]}`
+)
+
+// copyValidNetworking returns a copy of validNetworking, whose lists the test can modify.
+func copyValidNetworking() (networking Networking) {
+	networking = validNetworking
+	networking.Interfaces = append([]NetworkInterface(nil), validNetworking.Interfaces...)
+	networking.Bonds = append([]NetworkBond(nil), validNetworking.Bonds...)
+	networking.Vlans = append([]NetworkVlan(nil), validNetworking.Vlans...)
+	networking.Bridges = append([]NetworkBridge(nil), validNetworking.Bridges...)
+	return
+}
+
+func TestShouldSucceedParsingDefaultNetworking_Networking(t *testing.T) {
+	var checkedNetworking Networking
+
+	err := marshalJSONString("{}", &checkedNetworking)
+	assert.NoError(t, err)
+	assert.Equal(t, Networking{}, checkedNetworking)
+	assert.True(t, checkedNetworking.IsEmpty())
+}
+
+func TestShouldSucceedParsingValidNetworking_Networking(t *testing.T) {
+	var checkedNetworking Networking
+
+	assert.NoError(t, validNetworking.IsValid())
+	err := remarshalJSON(validNetworking, &checkedNetworking)
+	assert.NoError(t, err)
+	assert.Equal(t, validNetworking, checkedNetworking)
+	assert.False(t, checkedNetworking.IsEmpty())
+}
+
+func TestShouldSucceedParsingNetworkManagerRenderer_Networking(t *testing.T) {
+	var checkedNetworking Networking
+
+	err := marshalJSONString(`{"Renderer": "networkmanager", "DNS": ["1.1.1.1"]}`, &checkedNetworking)
+	assert.NoError(t, err)
+	assert.Equal(t, NetworkRendererNetworkManager, checkedNetworking.Renderer)
+}
+
+func TestShouldFailParsingInvalidJSON_Networking(t *testing.T) {
+	var checkedNetworking Networking
+
+	err := marshalJSONString(invalidNetworkingJSON, &checkedNetworking)
+	assert.Error(t, err)
+	assert.Equal(t, "failed to parse [Networking]: json: cannot unmarshal string into Go struct field IntermediateTypeNetworking.Vlans.0.ID of type uint16", err.Error())
+}
+
+func TestShouldFailParsingInvalidRenderer_Networking(t *testing.T) {
+	var checkedNetworking Networking
+
+	err := marshalJSONString(`{"Renderer": "netplan"}`, &checkedNetworking)
+	assert.Error(t, err)
+	assert.Equal(t, "failed to parse [Networking]: invalid [Renderer]: invalid value for NetworkRenderer (netplan)", err.Error())
+}
+
+func TestShouldFailInvalidInterfaceName_Networking(t *testing.T) {
+	invalidNetworking := copyValidNetworking()
+	invalidNetworking.Interfaces[0].Name = "ethernet-adapter0"
+
+	err := invalidNetworking.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "invalid interface name: (ethernet-adapter0) must have 1 to 15 characters, without '/', ':' or whitespace", err.Error())
+}
+
+func TestShouldFailDuplicateName_Networking(t *testing.T) {
+	invalidNetworking := copyValidNetworking()
+	invalidNetworking.Bridges[0].Name = "eth0"
+
+	err := invalidNetworking.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "bridge (eth0) has the same name as another interface, bond, bridge or VLAN", err.Error())
+}
+
+func TestShouldFailInvalidAddress_Networking(t *testing.T) {
+	invalidNetworking := copyValidNetworking()
+	invalidNetworking.Vlans[0].Addresses = []string{"10.20.0.5"}
+
+	err := invalidNetworking.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "invalid VLAN (vlan20): invalid address (10.20.0.5), must be an IP address with a prefix length, e.g. 192.168.1.10/24", err.Error())
+}
+
+func TestShouldFailInvalidDHCP_Networking(t *testing.T) {
+	invalidNetworking := copyValidNetworking()
+	invalidNetworking.Interfaces[0].DHCP = "true"
+
+	err := invalidNetworking.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "invalid interface (eth0): invalid [DHCP] (true), must be one of yes, ipv4, ipv6 or no", err.Error())
+}
+
+func TestShouldFailInvalidMACAddress_Networking(t *testing.T) {
+	invalidNetworking := copyValidNetworking()
+	invalidNetworking.Interfaces[1].MACAddress = "00:15:5d"
+
+	err := invalidNetworking.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "invalid [MACAddress] (00:15:5d) of interface (eth1)", err.Error())
+}
+
+func TestShouldFailInvalidDNS_Networking(t *testing.T) {
+	invalidNetworking := copyValidNetworking()
+	invalidNetworking.DNS = []string{"dns.example.com"}
+
+	err := invalidNetworking.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "invalid DNS server (dns.example.com)", err.Error())
+}
+
+func TestShouldFailInvalidVlanID_Networking(t *testing.T) {
+	invalidNetworking := copyValidNetworking()
+	invalidNetworking.Vlans[0].ID = 4095
+
+	err := invalidNetworking.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "invalid [ID] (4095) of VLAN (vlan20), must be from 1 to 4094", err.Error())
+}
+
+func TestShouldFailUnknownVlanLink_Networking(t *testing.T) {
+	invalidNetworking := copyValidNetworking()
+	invalidNetworking.Vlans[0].Link = "br0"
+
+	err := invalidNetworking.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "VLAN (vlan20) has a [Link] (br0) which isn't one of the [Interfaces] or [Bonds]", err.Error())
+}
+
+func TestShouldFailInvalidBondMode_Networking(t *testing.T) {
+	invalidNetworking := copyValidNetworking()
+	invalidNetworking.Bonds[0].Mode = "lacp"
+
+	err := invalidNetworking.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "invalid [Mode] (lacp) of bond (bond0)", err.Error())
+}
+
+func TestShouldFailBondWithoutInterfaces_Networking(t *testing.T) {
+	invalidNetworking := copyValidNetworking()
+	invalidNetworking.Bonds[0].Interfaces = nil
+
+	err := invalidNetworking.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "bond (bond0) must have at least one of [Interfaces]", err.Error())
+}
+
+func TestShouldFailInterfaceInBondAndBridge_Networking(t *testing.T) {
+	invalidNetworking := copyValidNetworking()
+	invalidNetworking.Bridges[0].Interfaces = []string{"eth2"}
+
+	err := invalidNetworking.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "interface (eth2) is in both (bond0) and (br0)", err.Error())
+}
+
+func TestShouldFailAddressedPort_Networking(t *testing.T) {
+	invalidNetworking := copyValidNetworking()
+	invalidNetworking.Interfaces[1].DHCP = "yes"
+
+	err := invalidNetworking.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "interface (eth1) is one of the [Interfaces] of (bond0), so it can't have its own addressing", err.Error())
+}
+
+func TestShouldFailVlanAsPort_Networking(t *testing.T) {
+	invalidNetworking := copyValidNetworking()
+	invalidNetworking.Bridges[0].Interfaces = []string{"vlan20"}
+
+	err := invalidNetworking.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "(vlan20) is a VLAN, so it can't be one of the [Interfaces] of (br0)", err.Error())
+}
+
+func TestShouldFailNetworkingWithNetworks_Networking(t *testing.T) {
+	testConfig := SystemConfig{
+		Name:          "Networking",
+		Packages:      []string{"core-packages-base-image"},
+		KernelOptions: map[string]string{"default": "kernel"},
+		Networking:    validNetworking,
+		Networks:      []Network{{BootProto: "dhcp", Device: "eth0"}},
+	}
+
+	err := testConfig.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "invalid [Networking]: can't be combined with [Networks]", err.Error())
+}
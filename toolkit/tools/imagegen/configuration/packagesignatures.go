@@ -0,0 +1,59 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+// Parser for the image builder's configuration schemas.
+
+package configuration
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// PackageSignatures requires all of the packages that are installed into the image to be signed by one of the GPG
+// keys, failing the build otherwise.
+type PackageSignatures struct {
+	Enforce bool `json:"Enforce"`
+	// Local GPG key files, which the packages' signatures are verified against.
+	GPGKeys []string `json:"GPGKeys"`
+}
+
+// IsValid returns an error if the PackageSignatures is not valid
+func (p *PackageSignatures) IsValid() (err error) {
+	if !p.Enforce {
+		if len(p.GPGKeys) > 0 {
+			return fmt.Errorf("[GPGKeys] are set, but [Enforce] is not")
+		}
+		return
+	}
+
+	if len(p.GPGKeys) == 0 {
+		return fmt.Errorf("[Enforce] is set, but no [GPGKeys] are set to verify the signatures against")
+	}
+
+	for _, gpgKey := range p.GPGKeys {
+		if strings.TrimSpace(gpgKey) == "" {
+			return fmt.Errorf("invalid [GPGKeys]: empty path")
+		}
+	}
+
+	return
+}
+
+// UnmarshalJSON Unmarshals a PackageSignatures entry
+func (p *PackageSignatures) UnmarshalJSON(b []byte) (err error) {
+	// Use an intermediate type which will use the default JSON unmarshal implementation
+	type IntermediateTypePackageSignatures PackageSignatures
+	err = json.Unmarshal(b, (*IntermediateTypePackageSignatures)(p))
+	if err != nil {
+		return fmt.Errorf("failed to parse [PackageSignatures]: %w", err)
+	}
+
+	// Now validate the resulting unmarshaled object
+	err = p.IsValid()
+	if err != nil {
+		return fmt.Errorf("failed to parse [PackageSignatures]: %w", err)
+	}
+	return
+}
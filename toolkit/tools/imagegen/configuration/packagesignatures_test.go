@@ -0,0 +1,69 @@
+// Copyright Microsoft Corporation.
+// Licensed under the MIT License.
+
+package configuration
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestMain found in configuration_test.go.
+
+var (
+	validPackageSignatures = PackageSignatures{
+		Enforce: true,
+		GPGKeys: []string{"keys/MICROSOFT-RPM-GPG-KEY", "keys/contoso.asc"},
+	}
+	invalidPackageSignaturesJSON = `{"Enforce": true, "GPGKeys": "keys/contoso.asc"}`
+)
+
+func TestShouldSucceedParsingDefaultPackageSignatures_PackageSignatures(t *testing.T) {
+	var checkedPackageSignatures PackageSignatures
+
+	err := marshalJSONString("{}", &checkedPackageSignatures)
+	assert.NoError(t, err)
+	assert.Equal(t, PackageSignatures{}, checkedPackageSignatures)
+}
+
+func TestShouldSucceedParsingValidPackageSignatures_PackageSignatures(t *testing.T) {
+	var checkedPackageSignatures PackageSignatures
+
+	assert.NoError(t, validPackageSignatures.IsValid())
+	err := remarshalJSON(validPackageSignatures, &checkedPackageSignatures)
+	assert.NoError(t, err)
+	assert.Equal(t, validPackageSignatures, checkedPackageSignatures)
+}
+
+func TestShouldFailParsingInvalidJSON_PackageSignatures(t *testing.T) {
+	var checkedPackageSignatures PackageSignatures
+
+	err := marshalJSONString(invalidPackageSignaturesJSON, &checkedPackageSignatures)
+	assert.Error(t, err)
+	assert.Equal(t, "failed to parse [PackageSignatures]: json: cannot unmarshal string into Go struct field IntermediateTypePackageSignatures.GPGKeys of type []string", err.Error())
+}
+
+func TestShouldFailEnforceWithoutGPGKeys_PackageSignatures(t *testing.T) {
+	var checkedPackageSignatures PackageSignatures
+
+	err := remarshalJSON(PackageSignatures{Enforce: true}, &checkedPackageSignatures)
+	assert.Error(t, err)
+	assert.Equal(t, "failed to parse [PackageSignatures]: [Enforce] is set, but no [GPGKeys] are set to verify the signatures against", err.Error())
+}
+
+func TestShouldFailGPGKeysWithoutEnforce_PackageSignatures(t *testing.T) {
+	invalidPackageSignatures := PackageSignatures{GPGKeys: []string{"keys/contoso.asc"}}
+
+	err := invalidPackageSignatures.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "[GPGKeys] are set, but [Enforce] is not", err.Error())
+}
+
+func TestShouldFailEmptyGPGKey_PackageSignatures(t *testing.T) {
+	invalidPackageSignatures := PackageSignatures{Enforce: true, GPGKeys: []string{" "}}
+
+	err := invalidPackageSignatures.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "invalid [GPGKeys]: empty path", err.Error())
+}
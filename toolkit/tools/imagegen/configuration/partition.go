@@ -8,6 +8,7 @@ package configuration
 import (
 	"encoding/json"
 	"fmt"
+	"regexp"
 	"unicode"
 	"unicode/utf16"
 
@@ -17,8 +18,14 @@ import (
 const (
 	EFIPartitionType    = "efi"
 	LegacyPartitionType = "legacy"
+
+	// BtrfsFsType is the file system type of the partitions that can have subvolumes.
+	BtrfsFsType = "btrfs"
 )
 
+// subvolumeNameRegex is the names that are accepted for btrfs subvolumes, e.g. "@" or "@home".
+var subvolumeNameRegex = regexp.MustCompile(`^[a-zA-Z0-9@_][a-zA-Z0-9@_.-]*$`)
+
 // Partition defines the size, name and file system type
 // for a partition.
 // "Start" and "End" fields define the offset from the beginning of the disk in MBs.
@@ -27,6 +34,7 @@ const (
 // partition on the disk.
 // "Grow" tells the logical volume to fill up any available space (**Only used for
 // kickstart-style unattended installation**)
+// "Subvolumes" are the btrfs subvolumes to create on a "btrfs" partition, which [PartitionSettings] can mount.
 type Partition struct {
 	FsType    string          `json:"FsType"`
 	Type      string          `json:"Type"`
@@ -37,6 +45,19 @@ type Partition struct {
 	Start     uint64          `json:"Start"`
 	Flags     []PartitionFlag `json:"Flags"`
 	Artifacts []Artifact      `json:"Artifacts"`
+	// GptAttributes holds the GPT attribute bit numbers to set on the partition.
+	GptAttributes []uint   `json:"GptAttributes"`
+	Subvolumes    []string `json:"Subvolumes"`
+}
+
+// HasSubvolume returns true if a given partition has a specific btrfs subvolume.
+func (p *Partition) HasSubvolume(subvolume string) bool {
+	for _, s := range p.Subvolumes {
+		if s == subvolume {
+			return true
+		}
+	}
+	return false
 }
 
 // HasFlag returns true if a given partition has a specific flag set.
@@ -96,9 +117,63 @@ func (p *Partition) IsValid() (err error) {
 		return err
 	}
 
+	for _, bit := range p.GptAttributes {
+		err = gptAttributeCheck(bit)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = subvolumesCheck(p.FsType, p.Subvolumes)
+	if err != nil {
+		return err
+	}
+
+	err = artifactsAreValid(p.Artifacts)
+	if err != nil {
+		return fmt.Errorf("invalid [Artifacts]: %w", err)
+	}
+
 	return nil
 }
 
+// subvolumesCheck makes sure that only btrfs partitions have subvolumes, and that the subvolumes have unique, valid
+// names.
+func subvolumesCheck(fsType string, subvolumes []string) (err error) {
+	if len(subvolumes) > 0 && fsType != BtrfsFsType {
+		return fmt.Errorf("[Subvolumes] are only valid for a (%s) [FsType], not (%s)", BtrfsFsType, fsType)
+	}
+
+	subvolumeUsed := make(map[string]bool)
+	for _, subvolume := range subvolumes {
+		if !subvolumeNameRegex.MatchString(subvolume) {
+			return fmt.Errorf("invalid subvolume name (%s), must only have letters, digits and '@_.-', and not start with '.' or '-'", subvolume)
+		}
+		if subvolumeUsed[subvolume] {
+			return fmt.Errorf("duplicate subvolume (%s) found in [Subvolumes]", subvolume)
+		}
+		subvolumeUsed[subvolume] = true
+	}
+	return
+}
+
+// gptAttributeCheck makes sure the GPT attribute bit is one that can be set on a partition.
+// Bits 0-2 are defined by the UEFI specification and bits 48-63 are partition type specific.
+// Bits 3-47 are reserved.
+func gptAttributeCheck(bit uint) (err error) {
+	const (
+		maxUefiAttributeBit    = 2
+		minTypeSpecificAttrBit = 48
+		maxTypeSpecificAttrBit = 63
+	)
+
+	if bit <= maxUefiAttributeBit || (bit >= minTypeSpecificAttrBit && bit <= maxTypeSpecificAttrBit) {
+		return nil
+	}
+
+	return fmt.Errorf("[GptAttributes] bit (%d) is reserved, only bits 0-2 and 48-63 may be set", bit)
+}
+
 // UnmarshalJSON Unmarshals a Partition entry
 func (p *Partition) UnmarshalJSON(b []byte) (err error) {
 	// Use an intermediate type which will use the default JSON unmarshal implementation
@@ -137,3 +137,67 @@ func TestShouldFailUnsupportedTypeName_Partition(t *testing.T) {
 	assert.Error(t, err)
 	assert.Equal(t, "unrecognized partition type (linux-root-aarch64), consider setting TypeUUID parameter explicitly or add a new entry to partition type table", err.Error())
 }
+
+func TestShouldSucceedValidGptAttributes_Partition(t *testing.T) {
+	partition := validPartition
+	partition.GptAttributes = []uint{0, 59, 60}
+
+	err := partition.IsValid()
+	assert.NoError(t, err)
+}
+
+func TestShouldFailReservedGptAttribute_Partition(t *testing.T) {
+	partition := validPartition
+	partition.GptAttributes = []uint{3}
+
+	err := partition.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "[GptAttributes] bit (3) is reserved, only bits 0-2 and 48-63 may be set", err.Error())
+}
+
+func TestShouldSucceedParsingSubvolumes_Partition(t *testing.T) {
+	var checkedPartition Partition
+	btrfsPartition := validPartition
+	btrfsPartition.FsType = "btrfs"
+	btrfsPartition.Subvolumes = []string{"@", "@home", "@var_log.1"}
+
+	err := remarshalJSON(btrfsPartition, &checkedPartition)
+	assert.NoError(t, err)
+	assert.Equal(t, btrfsPartition, checkedPartition)
+	assert.True(t, checkedPartition.HasSubvolume("@home"))
+	assert.False(t, checkedPartition.HasSubvolume("@var"))
+}
+
+func TestShouldFailSubvolumesWithoutBtrfs_Partition(t *testing.T) {
+	var checkedPartition Partition
+	invalidPartition := validPartition
+	invalidPartition.Subvolumes = []string{"@"}
+
+	err := invalidPartition.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "[Subvolumes] are only valid for a (btrfs) [FsType], not (ext4)", err.Error())
+
+	err = remarshalJSON(invalidPartition, &checkedPartition)
+	assert.Error(t, err)
+	assert.Equal(t, "failed to parse [Partition]: [Subvolumes] are only valid for a (btrfs) [FsType], not (ext4)", err.Error())
+}
+
+func TestShouldFailInvalidSubvolumeName_Partition(t *testing.T) {
+	invalidPartition := validPartition
+	invalidPartition.FsType = "btrfs"
+	invalidPartition.Subvolumes = []string{"@", "var/log"}
+
+	err := invalidPartition.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "invalid subvolume name (var/log), must only have letters, digits and '@_.-', and not start with '.' or '-'", err.Error())
+}
+
+func TestShouldFailDuplicateSubvolume_Partition(t *testing.T) {
+	invalidPartition := validPartition
+	invalidPartition.FsType = "btrfs"
+	invalidPartition.Subvolumes = []string{"@", "@home", "@"}
+
+	err := invalidPartition.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "duplicate subvolume (@) found in [Subvolumes]", err.Error())
+}
@@ -19,6 +19,11 @@ type PartitionSetting struct {
 	MountPoint       string          `json:"MountPoint"`
 	OverlayBaseImage string          `json:"OverlayBaseImage"`
 	RdiffBaseImage   string          `json:"RdiffBaseImage"`
+	// The btrfs subvolume of the partition to mount, instead of the file system's top level.
+	Subvolume string `json:"Subvolume"`
+	// Whether dump backs up the file system, which is the dump number of its fstab entry.
+	Dump       bool       `json:"Dump"`
+	FsckPolicy FsckPolicy `json:"FsckPolicy"`
 }
 
 var defaultPartitionSetting PartitionSetting = PartitionSetting{
@@ -33,7 +38,29 @@ func GetDefaultPartitionSetting() (defaultVal PartitionSetting) {
 
 // IsValid returns an error if the PartitionSetting is not valid
 func (p *PartitionSetting) IsValid() (err error) {
-	return nil
+	const rootMountPoint = "/"
+
+	err = mountOptionsAreValid(p.MountOptions)
+	if err != nil {
+		return fmt.Errorf("invalid [MountOptions] (%s): %w", p.MountOptions, err)
+	}
+
+	err = p.FsckPolicy.IsValid()
+	if err != nil {
+		return
+	}
+
+	// The partitions that aren't mounted have no fstab entry.
+	if p.MountPoint == "" && (p.Dump || p.FsckPolicy != FsckPolicyDefault) {
+		return fmt.Errorf("[Dump] and [FsckPolicy] require a [MountPoint]")
+	}
+
+	if p.FsckPolicy == FsckPolicyFirst && p.MountPoint != rootMountPoint {
+		return fmt.Errorf("[FsckPolicy] (%s) is only valid for the root ('%s') [MountPoint], not (%s)", p.FsckPolicy,
+			rootMountPoint, p.MountPoint)
+	}
+
+	return
 }
 
 // UnmarshalJSON Unmarshals a PartitionSetting entry
@@ -74,3 +101,13 @@ func FindMountpointPartitionSetting(partitionSettings []PartitionSetting, mountP
 	}
 	return nil
 }
+
+// FindPartitionSettingByID will search a list of partition settings for the partition setting with an ID.
+func FindPartitionSettingByID(partitionSettings []PartitionSetting, partID string) (partitionSetting *PartitionSetting) {
+	for i := range partitionSettings {
+		if partitionSettings[i].ID == partID {
+			return &partitionSettings[i]
+		}
+	}
+	return nil
+}
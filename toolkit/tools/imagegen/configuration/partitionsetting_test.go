@@ -49,3 +49,168 @@ func TestShouldFailParsingInvalidJSON_PartitionSetting(t *testing.T) {
 	assert.Error(t, err)
 	assert.Equal(t, "failed to parse [PartitionSetting]: json: cannot unmarshal number into Go struct field IntermediateTypePartitionSetting.RemoveDocs of type bool", err.Error())
 }
+
+// btrfsConfigWithSubvolumes returns a config that mounts the subvolumes of a btrfs partition, which the test can modify.
+func btrfsConfigWithSubvolumes() Config {
+	return Config{
+		Disks: []Disk{
+			{
+				PartitionTableType: "gpt",
+				MaxSize:            uint64(1024),
+				Partitions: []Partition{
+					{ID: "MyEsp", Flags: []PartitionFlag{"esp", "boot"}, Start: uint64(1), End: uint64(9), FsType: "fat32"},
+					{ID: "MyBoot", Start: uint64(9), End: uint64(509), FsType: "ext4"},
+					{ID: "MyBtrfs", Start: uint64(509), End: uint64(1024), FsType: "btrfs", Subvolumes: []string{"@", "@home", "@var"}},
+				},
+			},
+		},
+		SystemConfigs: []SystemConfig{
+			{
+				Name:          "Btrfs",
+				Packages:      []string{"core-packages-base-image"},
+				KernelOptions: map[string]string{"default": "kernel"},
+				PartitionSettings: []PartitionSetting{
+					{ID: "MyEsp", MountPoint: "/boot/efi"},
+					{ID: "MyBoot", MountPoint: "/boot"},
+					{ID: "MyBtrfs", MountPoint: "/", Subvolume: "@", MountOptions: "compress=zstd"},
+					{ID: "MyBtrfs", MountPoint: "/home", Subvolume: "@home", MountOptions: "compress=zstd"},
+					{ID: "MyBtrfs", MountPoint: "/var", Subvolume: "@var"},
+				},
+			},
+		},
+	}
+}
+
+func TestShouldSucceedMountingSubvolumes_PartitionSetting(t *testing.T) {
+	var checkedConfig Config
+	testConfig := btrfsConfigWithSubvolumes()
+
+	assert.NoError(t, testConfig.IsValid())
+	err := remarshalJSON(testConfig, &checkedConfig)
+	assert.NoError(t, err)
+	assert.Equal(t, testConfig.SystemConfigs[0].PartitionSettings, checkedConfig.SystemConfigs[0].PartitionSettings)
+}
+
+func TestShouldFailMountingMissingSubvolume_PartitionSetting(t *testing.T) {
+	testConfig := btrfsConfigWithSubvolumes()
+	testConfig.SystemConfigs[0].PartitionSettings[4].Subvolume = "@srv"
+
+	err := testConfig.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "invalid [Config]:\n[SystemConfig] (Btrfs) mounts a subvolume (@srv) of [Partition] (MyBtrfs), which isn't in its [Subvolumes]", err.Error())
+}
+
+func TestShouldFailMountingPartitionTwiceWithoutSubvolume_PartitionSetting(t *testing.T) {
+	testConfig := btrfsConfigWithSubvolumes()
+	testConfig.SystemConfigs[0].PartitionSettings[4].Subvolume = "@home"
+
+	err := testConfig.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "invalid [Config]:\n[SystemConfig] (Btrfs) mounts [Partition] (MyBtrfs) twice, without a different [Subvolume] each time", err.Error())
+
+	testConfig.SystemConfigs[0].PartitionSettings[4].Subvolume = ""
+
+	err = testConfig.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "invalid [Config]:\n[SystemConfig] (Btrfs) mounts [Partition] (MyBtrfs) twice, without a different [Subvolume] each time", err.Error())
+}
+
+func TestShouldFailSubvolumeRootWithoutBootPartition_PartitionSetting(t *testing.T) {
+	testConfig := btrfsConfigWithSubvolumes()
+	testConfig.SystemConfigs[0].PartitionSettings = append(testConfig.SystemConfigs[0].PartitionSettings[0:1],
+		testConfig.SystemConfigs[0].PartitionSettings[2:]...)
+
+	err := testConfig.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "invalid [Config]:\n[SystemConfig] (Btrfs) mounts a subvolume (@) at (/), so it must have a separate (/boot) partition", err.Error())
+}
+
+func TestShouldFailSubvolumeBootPartition_PartitionSetting(t *testing.T) {
+	testConfig := btrfsConfigWithSubvolumes()
+	testConfig.SystemConfigs[0].PartitionSettings[1] = PartitionSetting{ID: "MyBtrfs", MountPoint: "/boot", Subvolume: "@var"}
+	testConfig.SystemConfigs[0].PartitionSettings = testConfig.SystemConfigs[0].PartitionSettings[0:3]
+
+	err := testConfig.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "invalid [Config]:\n[SystemConfig] (Btrfs) mounts a subvolume (@var) at (/boot), which must be a [Partition]", err.Error())
+}
+
+func TestShouldSucceedParsingFstabFields_PartitionSetting(t *testing.T) {
+	var checkedPartitionSetting PartitionSetting
+	fstabPartitionSettingJSON := `{"ID": "MyVar", "MountPoint": "/var", "MountOptions": "noatime,nodev,nosuid,x-systemd.device-timeout=10s", "Dump": true, "FsckPolicy": "after-root"}`
+
+	err := marshalJSONString(fstabPartitionSettingJSON, &checkedPartitionSetting)
+	assert.NoError(t, err)
+	assert.Equal(t, "noatime,nodev,nosuid,x-systemd.device-timeout=10s", checkedPartitionSetting.MountOptions)
+	assert.True(t, checkedPartitionSetting.Dump)
+	assert.Equal(t, FsckPolicyAfterRoot, checkedPartitionSetting.FsckPolicy)
+}
+
+func TestShouldFailParsingInvalidMountOptions_PartitionSetting(t *testing.T) {
+	var checkedPartitionSetting PartitionSetting
+
+	err := marshalJSONString(`{"MountPoint": "/var", "MountOptions": "noatime,,nodev"}`, &checkedPartitionSetting)
+	assert.Error(t, err)
+	assert.Equal(t, "failed to parse [PartitionSetting]: invalid [MountOptions] (noatime,,nodev): empty option", err.Error())
+}
+
+func TestShouldFailParsingInvalidFsckPolicy_PartitionSetting(t *testing.T) {
+	var checkedPartitionSetting PartitionSetting
+
+	err := marshalJSONString(`{"MountPoint": "/var", "FsckPolicy": "2"}`, &checkedPartitionSetting)
+	assert.Error(t, err)
+	assert.Equal(t, "failed to parse [PartitionSetting]: failed to parse [FsckPolicy]: invalid value for FsckPolicy (2)", err.Error())
+}
+
+func TestShouldFailFstabFieldsWithoutMountPoint_PartitionSetting(t *testing.T) {
+	partitionSetting := PartitionSetting{ID: "MyData", Dump: true}
+
+	err := partitionSetting.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "[Dump] and [FsckPolicy] require a [MountPoint]", err.Error())
+
+	partitionSetting = PartitionSetting{ID: "MyData", FsckPolicy: FsckPolicySkip}
+
+	err = partitionSetting.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "[Dump] and [FsckPolicy] require a [MountPoint]", err.Error())
+}
+
+func TestShouldFailFsckFirstForNonRoot_PartitionSetting(t *testing.T) {
+	partitionSetting := PartitionSetting{ID: "MyVar", MountPoint: "/var", FsckPolicy: FsckPolicyFirst}
+
+	err := partitionSetting.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "[FsckPolicy] (first) is only valid for the root ('/') [MountPoint], not (/var)", err.Error())
+
+	partitionSetting.MountPoint = "/"
+	assert.NoError(t, partitionSetting.IsValid())
+}
+
+func TestShouldSucceedCompatibleMountOptions_PartitionSetting(t *testing.T) {
+	testConfig := btrfsConfigWithSubvolumes()
+	testConfig.SystemConfigs[0].PartitionSettings[0].MountOptions = "umask=0077,nodev,nosuid"
+	testConfig.SystemConfigs[0].PartitionSettings[1].MountOptions = "noatime,errors=remount-ro"
+	testConfig.SystemConfigs[0].PartitionSettings[1].FsckPolicy = FsckPolicyAfterRoot
+	testConfig.SystemConfigs[0].PartitionSettings[2].FsckPolicy = FsckPolicySkip
+
+	assert.NoError(t, testConfig.IsValid())
+}
+
+func TestShouldFailIncompatibleMountOptions_PartitionSetting(t *testing.T) {
+	testConfig := btrfsConfigWithSubvolumes()
+	testConfig.SystemConfigs[0].PartitionSettings[1].MountOptions = "noatime,compress=zstd"
+
+	err := testConfig.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "invalid [Config]:\n[SystemConfig] (Btrfs) mounts (MyBoot) with invalid [MountOptions] (noatime,compress=zstd): option (compress) isn't supported by a (ext4) file system", err.Error())
+}
+
+func TestShouldFailCheckingBtrfsWithFsck_PartitionSetting(t *testing.T) {
+	testConfig := btrfsConfigWithSubvolumes()
+	testConfig.SystemConfigs[0].PartitionSettings[2].FsckPolicy = FsckPolicyFirst
+
+	err := testConfig.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "invalid [Config]:\n[SystemConfig] (Btrfs) mounts (MyBtrfs) with [FsckPolicy] (first), but fsck doesn't check a (btrfs) file system", err.Error())
+}
@@ -0,0 +1,114 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+// Parser for the image builder's configuration schemas.
+
+package configuration
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// noProxyRegex matches the entries of a no_proxy list: hosts, domains (e.g. ".contoso.com"), IP addresses and
+// networks, optionally with a port.
+var noProxyRegex = regexp.MustCompile(`^[A-Za-z0-9.*:/_\[\]-]+$`)
+
+// Proxy is the system-wide proxy, which the image's shells, services (e.g. containerd) and tdnf use.
+type Proxy struct {
+	// The proxy URLs of HTTP and HTTPS requests, e.g. "http://proxy.contoso.com:3128".
+	HTTPProxy  string `json:"HTTPProxy"`
+	HTTPSProxy string `json:"HTTPSProxy"`
+	// The hosts, domains and networks that are reached without the proxy.
+	NoProxy []string `json:"NoProxy"`
+}
+
+// IsEmpty returns true if there's no proxy.
+func (p *Proxy) IsEmpty() bool {
+	return p.HTTPProxy == "" && p.HTTPSProxy == ""
+}
+
+// GetTdnfProxy returns the proxy that tdnf uses, which only has a single proxy for all of its repos.
+func (p *Proxy) GetTdnfProxy() string {
+	if p.HTTPSProxy != "" {
+		return p.HTTPSProxy
+	}
+	return p.HTTPProxy
+}
+
+// IsValid returns an error if the Proxy is not valid
+func (p *Proxy) IsValid() (err error) {
+	if p.IsEmpty() {
+		if len(p.NoProxy) > 0 {
+			return fmt.Errorf("[NoProxy] is set, but [HTTPProxy] and [HTTPSProxy] are not")
+		}
+		return
+	}
+
+	for _, proxy := range []struct {
+		field string
+		url   string
+	}{
+		{"HTTPProxy", p.HTTPProxy},
+		{"HTTPSProxy", p.HTTPSProxy},
+	} {
+		if proxy.url == "" {
+			continue
+		}
+		if err = proxyURLIsValid(proxy.url); err != nil {
+			return fmt.Errorf("invalid [%s] (%s): %w", proxy.field, proxy.url, err)
+		}
+	}
+
+	for _, noProxy := range p.NoProxy {
+		if !noProxyRegex.MatchString(noProxy) {
+			return fmt.Errorf("invalid [NoProxy] entry (%s), must be a host, a domain or a network", noProxy)
+		}
+	}
+
+	return
+}
+
+// proxyURLIsValid returns an error if the URL isn't the URL of an HTTP or HTTPS proxy.
+func proxyURLIsValid(proxyURL string) (err error) {
+	if strings.ContainsAny(proxyURL, " \t\n\"'\\") {
+		return fmt.Errorf("must not have whitespace, quotes or backslashes")
+	}
+
+	parsedURL, err := url.Parse(proxyURL)
+	if err != nil {
+		return fmt.Errorf("must be a URL, e.g. http://proxy.contoso.com:3128")
+	}
+
+	if (parsedURL.Scheme != "http" && parsedURL.Scheme != "https") || parsedURL.Host == "" ||
+		(parsedURL.Path != "" && parsedURL.Path != "/") || parsedURL.RawQuery != "" || parsedURL.Fragment != "" {
+		return fmt.Errorf("must be an http or https URL of a proxy, e.g. http://proxy.contoso.com:3128")
+	}
+
+	// The proxy is written to files that all users can read.
+	if parsedURL.User != nil {
+		return fmt.Errorf("must not have credentials, as all users can read the proxy")
+	}
+
+	return
+}
+
+// UnmarshalJSON Unmarshals a Proxy entry
+func (p *Proxy) UnmarshalJSON(b []byte) (err error) {
+	// Use an intermediate type which will use the default JSON unmarshal implementation
+	type IntermediateTypeProxy Proxy
+	err = json.Unmarshal(b, (*IntermediateTypeProxy)(p))
+	if err != nil {
+		return fmt.Errorf("failed to parse [Proxy]: %w", err)
+	}
+
+	// Now validate the resulting unmarshaled object
+	err = p.IsValid()
+	if err != nil {
+		return fmt.Errorf("failed to parse [Proxy]: %w", err)
+	}
+	return
+}
@@ -0,0 +1,115 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+// Parser for the image builder's configuration schemas.
+
+package configuration
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+)
+
+// RaidLevel is the RAID level of a software RAID array
+type RaidLevel string
+
+const (
+	// RaidLevel0 stripes the data across the devices, without redundancy
+	RaidLevel0 RaidLevel = "raid0"
+	// RaidLevel1 mirrors the data on all the devices
+	RaidLevel1 RaidLevel = "raid1"
+	// RaidLevel10 stripes the data across mirrored pairs of devices
+	RaidLevel10 RaidLevel = "raid10"
+)
+
+// raidNameRegex is the names that are accepted for RAID arrays. The array is created at /dev/md/<name>.
+var raidNameRegex = regexp.MustCompile(`^[a-zA-Z0-9_][a-zA-Z0-9_.-]*$`)
+
+// RaidArray is a software (mdadm) RAID array, which is created on partitions of different disks (as its devices).
+// [PartitionSettings] mount it by its ID, like a partition.
+type RaidArray struct {
+	ID    string    `json:"ID"`
+	Name  string    `json:"Name"`
+	Level RaidLevel `json:"Level"`
+	// The IDs of the partitions that the array is created on. They must not have a [FsType].
+	Devices []string `json:"Devices"`
+	FsType  string   `json:"FsType"`
+}
+
+func (r RaidLevel) String() string {
+	return fmt.Sprint(string(r))
+}
+
+// GetValidRaidLevels returns a list of all the supported RAID levels
+func (r *RaidLevel) GetValidRaidLevels() (levels []RaidLevel) {
+	return []RaidLevel{
+		RaidLevel0,
+		RaidLevel1,
+		RaidLevel10,
+	}
+}
+
+// GetMinDevices returns the least number of devices that a RAID array of the level must have
+func (r *RaidLevel) GetMinDevices() int {
+	if *r == RaidLevel10 {
+		return 4
+	}
+	return 2
+}
+
+// IsValid returns an error if the RaidLevel is not valid
+func (r *RaidLevel) IsValid() (err error) {
+	for _, valid := range r.GetValidRaidLevels() {
+		if *r == valid {
+			return
+		}
+	}
+	return fmt.Errorf("invalid value for RaidLevel (%s)", r)
+}
+
+// IsValid returns an error if the RaidArray is not valid
+func (r *RaidArray) IsValid() (err error) {
+	if r.ID == "" {
+		return fmt.Errorf("RAID array (%s) must have an [ID]", r.Name)
+	}
+
+	if !raidNameRegex.MatchString(r.Name) {
+		return fmt.Errorf("invalid [Name] (%s), must only have letters, digits and '_.-', and not start with '.' or '-'", r.Name)
+	}
+
+	if err = r.Level.IsValid(); err != nil {
+		return fmt.Errorf("invalid [Level]: %w", err)
+	}
+
+	if len(r.Devices) < r.Level.GetMinDevices() {
+		return fmt.Errorf("RAID array (%s) is (%s), so it must have at least %d [Devices]", r.Name, r.Level, r.Level.GetMinDevices())
+	}
+
+	devices := make(map[string]bool)
+	for _, partID := range r.Devices {
+		if devices[partID] {
+			return fmt.Errorf("RAID array (%s) has the device (%s) twice", r.Name, partID)
+		}
+		devices[partID] = true
+	}
+
+	return
+}
+
+// UnmarshalJSON Unmarshals a RaidArray entry
+func (r *RaidArray) UnmarshalJSON(b []byte) (err error) {
+	// Use an intermediate type which will use the default JSON unmarshal implementation
+	type IntermediateTypeRaidArray RaidArray
+	err = json.Unmarshal(b, (*IntermediateTypeRaidArray)(r))
+	if err != nil {
+		return fmt.Errorf("failed to parse [RaidArray]: %w", err)
+	}
+
+	// Now validate the resulting unmarshaled object
+	err = r.IsValid()
+	if err != nil {
+		return fmt.Errorf("failed to parse [RaidArray]: %w", err)
+	}
+	return
+}
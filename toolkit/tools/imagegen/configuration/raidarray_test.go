@@ -0,0 +1,224 @@
+// Copyright Microsoft Corporation.
+// Licensed under the MIT License.
+
+package configuration
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestMain found in configuration_test.go.
+
+var (
+	validRaidArrays = []RaidArray{
+		{ID: "MyRoot", Name: "root", Level: RaidLevel1, Devices: []string{"MyRaid0", "MyRaid1"}, FsType: "ext4"},
+		{ID: "MyData", Name: "data_0", Level: RaidLevel0, Devices: []string{"MyRaid0", "MyRaid1", "MyRaid2"}, FsType: "xfs"},
+		{ID: "MyHome", Name: "home", Level: RaidLevel10, Devices: []string{"MyRaid0", "MyRaid1", "MyRaid2", "MyRaid3"}, FsType: "ext4"},
+	}
+	invalidRaidArrayJSON = `{"ID": "MyRoot", "Name": "root", "Level": 1}`
+
+	raidConfig = Config{
+		Disks: []Disk{
+			{
+				PartitionTableType: "gpt",
+				MaxSize:            uint64(1024),
+				Partitions: []Partition{
+					{ID: "MyEsp", Flags: []PartitionFlag{"esp", "boot"}, Start: uint64(1), End: uint64(9), FsType: "fat32"},
+					{ID: "MyBoot", Start: uint64(9), End: uint64(509), FsType: "ext4"},
+					{ID: "MyRaid0", Start: uint64(509), End: uint64(1024)},
+				},
+			},
+			{
+				PartitionTableType: "gpt",
+				MaxSize:            uint64(1024),
+				Partitions: []Partition{
+					{ID: "MyRaid1", Start: uint64(509), End: uint64(1024)},
+				},
+			},
+		},
+		RaidArrays: []RaidArray{
+			{ID: "MyRoot", Name: "root", Level: RaidLevel1, Devices: []string{"MyRaid0", "MyRaid1"}, FsType: "ext4"},
+		},
+		SystemConfigs: []SystemConfig{
+			{
+				Name:          "Raid",
+				Packages:      []string{"core-packages-base-image"},
+				KernelOptions: map[string]string{"default": "kernel"},
+				PartitionSettings: []PartitionSetting{
+					{ID: "MyEsp", MountPoint: "/boot/efi"},
+					{ID: "MyBoot", MountPoint: "/boot"},
+					{ID: "MyRoot", MountPoint: "/"},
+				},
+			},
+		},
+	}
+)
+
+// copyRaidConfig returns a copy of raidConfig that the test can modify.
+func copyRaidConfig() (testConfig Config) {
+	testConfig = raidConfig
+	testConfig.Disks = append([]Disk{}, raidConfig.Disks...)
+	for i := range testConfig.Disks {
+		testConfig.Disks[i].Partitions = append([]Partition{}, raidConfig.Disks[i].Partitions...)
+	}
+	testConfig.RaidArrays = append([]RaidArray{}, raidConfig.RaidArrays...)
+	testConfig.SystemConfigs = append([]SystemConfig{}, raidConfig.SystemConfigs...)
+	testConfig.SystemConfigs[0].PartitionSettings = append([]PartitionSetting{}, raidConfig.SystemConfigs[0].PartitionSettings...)
+	return
+}
+
+func TestShouldSucceedParsingValidRaidArrays_RaidArray(t *testing.T) {
+	for _, validRaidArray := range validRaidArrays {
+		var checkedRaidArray RaidArray
+
+		assert.NoError(t, validRaidArray.IsValid())
+		err := remarshalJSON(validRaidArray, &checkedRaidArray)
+		assert.NoError(t, err)
+		assert.Equal(t, validRaidArray, checkedRaidArray)
+	}
+}
+
+func TestShouldFailParsingInvalidJSON_RaidArray(t *testing.T) {
+	var checkedRaidArray RaidArray
+
+	err := marshalJSONString(invalidRaidArrayJSON, &checkedRaidArray)
+	assert.Error(t, err)
+	assert.Equal(t, "failed to parse [RaidArray]: json: cannot unmarshal number into Go struct field IntermediateTypeRaidArray.Level of type configuration.RaidLevel", err.Error())
+}
+
+func TestShouldFailParsingInvalidLevel_RaidArray(t *testing.T) {
+	var checkedRaidArray RaidArray
+
+	invalidRaidArray := validRaidArrays[0]
+	invalidRaidArray.Level = "raid5"
+	err := invalidRaidArray.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "invalid [Level]: invalid value for RaidLevel (raid5)", err.Error())
+
+	err = remarshalJSON(invalidRaidArray, &checkedRaidArray)
+	assert.Error(t, err)
+	assert.Equal(t, "failed to parse [RaidArray]: invalid [Level]: invalid value for RaidLevel (raid5)", err.Error())
+}
+
+func TestShouldFailParsingTooFewDevices_RaidArray(t *testing.T) {
+	invalidRaidArray := validRaidArrays[0]
+	invalidRaidArray.Devices = []string{"MyRaid0"}
+	err := invalidRaidArray.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "RAID array (root) is (raid1), so it must have at least 2 [Devices]", err.Error())
+
+	invalidRaidArray = validRaidArrays[2]
+	invalidRaidArray.Devices = []string{"MyRaid0", "MyRaid1", "MyRaid2"}
+	err = invalidRaidArray.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "RAID array (home) is (raid10), so it must have at least 4 [Devices]", err.Error())
+}
+
+func TestShouldFailParsingDuplicateDevice_RaidArray(t *testing.T) {
+	invalidRaidArray := validRaidArrays[0]
+	invalidRaidArray.Devices = []string{"MyRaid0", "MyRaid0"}
+	err := invalidRaidArray.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "RAID array (root) has the device (MyRaid0) twice", err.Error())
+}
+
+func TestShouldFailParsingInvalidNameOrID_RaidArray(t *testing.T) {
+	invalidRaidArray := validRaidArrays[0]
+	invalidRaidArray.Name = "my/root"
+	err := invalidRaidArray.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "invalid [Name] (my/root), must only have letters, digits and '_.-', and not start with '.' or '-'", err.Error())
+
+	invalidRaidArray = validRaidArrays[0]
+	invalidRaidArray.ID = ""
+	err = invalidRaidArray.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "RAID array (root) must have an [ID]", err.Error())
+}
+
+func TestShouldSucceedParsingRaidConfig(t *testing.T) {
+	var checkedConfig Config
+	testConfig := copyRaidConfig()
+
+	assert.NoError(t, testConfig.IsValid())
+	err := remarshalJSON(testConfig, &checkedConfig)
+	assert.NoError(t, err)
+	assert.Equal(t, testConfig.RaidArrays, checkedConfig.RaidArrays)
+	assert.Equal(t, &checkedConfig.RaidArrays[0], checkedConfig.GetRaidArrayByID("MyRoot"))
+	assert.True(t, checkedConfig.IsRaidDevice("MyRaid1"))
+	assert.False(t, checkedConfig.IsRaidDevice("MyBoot"))
+}
+
+func TestShouldFailRaidDevicesOnSameDisk(t *testing.T) {
+	testConfig := copyRaidConfig()
+	testConfig.Disks[1].Partitions[0].ID = "MyRaid2"
+	testConfig.Disks[0].Partitions = append(testConfig.Disks[0].Partitions, Partition{ID: "MyRaid1", Start: uint64(1024)})
+
+	err := testConfig.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "invalid [Config]:\nRAID array (root) has two devices on the same [Disk], its devices must be on different disks", err.Error())
+}
+
+func TestShouldFailFormattedRaidDevice(t *testing.T) {
+	testConfig := copyRaidConfig()
+	testConfig.Disks[1].Partitions[0].FsType = "ext4"
+
+	err := testConfig.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "invalid [Config]:\n[Partition] (MyRaid1) is a device of RAID array (root), so it can't have a [FsType] or [Flags]", err.Error())
+}
+
+func TestShouldFailMissingRaidDevice(t *testing.T) {
+	testConfig := copyRaidConfig()
+	testConfig.Disks[1].Partitions[0].ID = "MyRaid2"
+
+	err := testConfig.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "invalid [Config]:\nRAID array (root) has a device (MyRaid1) which has no corresponding [Partition] on a [Disk]", err.Error())
+}
+
+func TestShouldFailMountingRaidDevice(t *testing.T) {
+	testConfig := copyRaidConfig()
+	testConfig.SystemConfigs[0].PartitionSettings = append(testConfig.SystemConfigs[0].PartitionSettings,
+		PartitionSetting{ID: "MyRaid1", MountPoint: "/data"})
+
+	err := testConfig.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "invalid [Config]:\n[SystemConfig] (Raid) mounts a [Partition] (MyRaid1) which is a device of a RAID array", err.Error())
+}
+
+func TestShouldFailDuplicatedRaidArrayID(t *testing.T) {
+	testConfig := copyRaidConfig()
+	testConfig.RaidArrays[0].ID = "MyBoot"
+	testConfig.SystemConfigs[0].PartitionSettings = testConfig.SystemConfigs[0].PartitionSettings[0:2]
+
+	err := testConfig.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "invalid [Config]:\na [RaidArray] (root) shares an ID (MyBoot) with another partition or logical volume (on disk (0))", err.Error())
+}
+
+func TestShouldFailRaidRootWithoutBootPartition(t *testing.T) {
+	testConfig := copyRaidConfig()
+	testConfig.SystemConfigs[0].PartitionSettings = testConfig.SystemConfigs[0].PartitionSettings[0:1]
+	testConfig.SystemConfigs[0].PartitionSettings = append(testConfig.SystemConfigs[0].PartitionSettings,
+		PartitionSetting{ID: "MyRoot", MountPoint: "/"})
+
+	err := testConfig.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "invalid [Config]:\n[SystemConfig] (Raid) mounts a RAID array (MyRoot) at (/), so it must have a separate (/boot) partition", err.Error())
+}
+
+func TestShouldFailRaidBootPartition(t *testing.T) {
+	testConfig := copyRaidConfig()
+	testConfig.SystemConfigs[0].PartitionSettings = []PartitionSetting{
+		{ID: "MyEsp", MountPoint: "/boot/efi"},
+		{ID: "MyRoot", MountPoint: "/boot"},
+		{ID: "MyBoot", MountPoint: "/"},
+	}
+
+	err := testConfig.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "invalid [Config]:\n[SystemConfig] (Raid) mounts a RAID array (MyRoot) at (/boot), which must be a [Partition]", err.Error())
+}
@@ -0,0 +1,161 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+// Parser for the image builder's configuration schemas.
+
+package configuration
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// repoIDRegex matches the IDs of tdnf repos, which are also the names of their repo files.
+var repoIDRegex = regexp.MustCompile(`^[A-Za-z0-9._:-]+$`)
+
+// Repo is a tdnf repo that is configured in the image, for the packages that are installed after it boots. Unlike the
+// [PackageRepos], the image's own packages aren't installed from it.
+type Repo struct {
+	// The ID of the repo, e.g. "contoso-extras", which is also the name of its repo file.
+	ID   string `json:"ID"`
+	Name string `json:"Name"` // Default value is the ID
+	// Exactly one of the URL of the repo and the URL of its metalink.
+	BaseURL  string `json:"BaseURL"`
+	Metalink string `json:"Metalink"`
+	// Local GPG key files, which are imported into the image's rpm database.
+	GPGKeys  []string `json:"GPGKeys"`
+	GPGCheck bool     `json:"GPGCheck"` // Default value is true
+	Enabled  bool     `json:"Enabled"`  // Default value is true
+	// The priority of the repo, where lower values have a higher priority. 0 leaves tdnf's default priority.
+	Priority int `json:"Priority"`
+}
+
+const (
+	repoDefaultGPGCheck = true
+	repoDefaultEnabled  = true
+)
+
+// GetName returns the name of the repo, which defaults to its ID.
+func (r *Repo) GetName() string {
+	if r.Name != "" {
+		return r.Name
+	}
+	return r.ID
+}
+
+// IsValid returns an error if the Repo is not valid
+func (r *Repo) IsValid() (err error) {
+	if !repoIDRegex.MatchString(r.ID) {
+		return fmt.Errorf("invalid [ID] (%s), must only have letters, digits and '.', '_', ':' or '-'", r.ID)
+	}
+
+	if strings.ContainsAny(r.Name, "\n") {
+		return fmt.Errorf("invalid [Name] (%s), must be a single line", r.Name)
+	}
+
+	if (r.BaseURL == "") == (r.Metalink == "") {
+		return fmt.Errorf("exactly one of [BaseURL] and [Metalink] must be set")
+	}
+
+	if r.BaseURL != "" {
+		err = repoURLIsValid(r.BaseURL, "http", "https", "file")
+		if err != nil {
+			return fmt.Errorf("invalid [BaseURL] (%s): %w", r.BaseURL, err)
+		}
+	}
+
+	if r.Metalink != "" {
+		err = repoURLIsValid(r.Metalink, "http", "https")
+		if err != nil {
+			return fmt.Errorf("invalid [Metalink] (%s): %w", r.Metalink, err)
+		}
+	}
+
+	if r.GPGCheck && len(r.GPGKeys) == 0 {
+		return fmt.Errorf("[GPGCheck] is enabled, but no [GPGKeys] are set")
+	}
+
+	for _, gpgKey := range r.GPGKeys {
+		if strings.TrimSpace(gpgKey) == "" {
+			return fmt.Errorf("invalid [GPGKeys]: empty path")
+		}
+	}
+
+	if r.Priority < 0 {
+		return fmt.Errorf("invalid [Priority] (%d), must not be negative", r.Priority)
+	}
+
+	return
+}
+
+// repoURLIsValid returns an error if the URL doesn't parse, or if its scheme isn't one of the schemes.
+func repoURLIsValid(repoURL string, schemes ...string) (err error) {
+	if strings.ContainsAny(repoURL, " \t\n") {
+		return fmt.Errorf("must not have whitespace")
+	}
+
+	parsedURL, err := url.ParseRequestURI(repoURL)
+	if err != nil {
+		return fmt.Errorf("failed to parse URL:\n%w", err)
+	}
+
+	for _, scheme := range schemes {
+		if parsedURL.Scheme == scheme {
+			return
+		}
+	}
+
+	return fmt.Errorf("must be a (%s) URL", strings.Join(schemes, ", "))
+}
+
+// UnmarshalJSON Unmarshals a Repo entry
+func (r *Repo) UnmarshalJSON(b []byte) (err error) {
+	// Use an intermediate type which will use the default JSON unmarshal implementation
+	type IntermediateTypeRepo Repo
+
+	// Set default values
+	r.GPGCheck = repoDefaultGPGCheck
+	r.Enabled = repoDefaultEnabled
+
+	err = json.Unmarshal(b, (*IntermediateTypeRepo)(r))
+	if err != nil {
+		return fmt.Errorf("failed to parse [Repo]: %w", err)
+	}
+
+	// Now validate the resulting unmarshaled object
+	err = r.IsValid()
+	if err != nil {
+		return fmt.Errorf("failed to parse [Repo]: %w", err)
+	}
+	return
+}
+
+// reposAreValid returns an error if two repos have the same ID, or if two different GPG key files have the same file
+// name, as all of the keys are installed into the same directory.
+func reposAreValid(repos []Repo) (err error) {
+	repoIDs := make(map[string]bool)
+	gpgKeyFileNames := make(map[string]string)
+	for _, repo := range repos {
+		if err = repo.IsValid(); err != nil {
+			return fmt.Errorf("(%s): %w", repo.ID, err)
+		}
+
+		if repoIDs[repo.ID] {
+			return fmt.Errorf("duplicate repo ID (%s)", repo.ID)
+		}
+		repoIDs[repo.ID] = true
+
+		for _, gpgKey := range repo.GPGKeys {
+			fileName := filepath.Base(gpgKey)
+			if otherKey, found := gpgKeyFileNames[fileName]; found && otherKey != gpgKey {
+				return fmt.Errorf("GPG keys (%s) and (%s) have the same file name", otherKey, gpgKey)
+			}
+			gpgKeyFileNames[fileName] = gpgKey
+		}
+	}
+	return
+}
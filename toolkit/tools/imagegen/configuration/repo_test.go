@@ -0,0 +1,162 @@
+// Copyright Microsoft Corporation.
+// Licensed under the MIT License.
+
+package configuration
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestMain found in configuration_test.go.
+
+var (
+	validRepo = Repo{
+		ID:       "contoso-extras",
+		Name:     "Contoso Extras",
+		BaseURL:  "https://packages.contoso.com/azurelinux/$releasever/extras/$basearch",
+		GPGKeys:  []string{"keys/contoso.asc"},
+		GPGCheck: true,
+		Enabled:  true,
+		Priority: 10,
+	}
+	validRepoJSON   = `{"ID": "contoso-mirror", "Metalink": "https://mirrors.contoso.com/metalink?repo=base&arch=$basearch", "GPGKeys": ["keys/contoso.asc"]}`
+	invalidRepoJSON = `{"ID": "contoso-extras", "BaseURL": "https://packages.contoso.com", "GPGKeys": "keys/contoso.asc"}`
+)
+
+func TestShouldSucceedParsingValidRepo_Repo(t *testing.T) {
+	var checkedRepo Repo
+
+	assert.NoError(t, validRepo.IsValid())
+	err := remarshalJSON(validRepo, &checkedRepo)
+	assert.NoError(t, err)
+	assert.Equal(t, validRepo, checkedRepo)
+	assert.Equal(t, "Contoso Extras", checkedRepo.GetName())
+}
+
+func TestShouldSucceedParsingDefaultValues_Repo(t *testing.T) {
+	var checkedRepo Repo
+
+	err := marshalJSONString(validRepoJSON, &checkedRepo)
+	assert.NoError(t, err)
+	assert.Equal(t, Repo{
+		ID:       "contoso-mirror",
+		Metalink: "https://mirrors.contoso.com/metalink?repo=base&arch=$basearch",
+		GPGKeys:  []string{"keys/contoso.asc"},
+		GPGCheck: true,
+		Enabled:  true,
+	}, checkedRepo)
+	assert.Equal(t, "contoso-mirror", checkedRepo.GetName())
+}
+
+func TestShouldSucceedParsingRepoWithoutGPGCheck_Repo(t *testing.T) {
+	var checkedRepo Repo
+
+	err := marshalJSONString(`{"ID": "local", "BaseURL": "file:///mnt/repo", "GPGCheck": false, "Enabled": false}`, &checkedRepo)
+	assert.NoError(t, err)
+	assert.False(t, checkedRepo.GPGCheck)
+	assert.False(t, checkedRepo.Enabled)
+}
+
+func TestShouldFailParsingInvalidJSON_Repo(t *testing.T) {
+	var checkedRepo Repo
+
+	err := marshalJSONString(invalidRepoJSON, &checkedRepo)
+	assert.Error(t, err)
+	assert.Equal(t, "failed to parse [Repo]: json: cannot unmarshal string into Go struct field IntermediateTypeRepo.GPGKeys of type []string", err.Error())
+}
+
+func TestShouldFailInvalidID_Repo(t *testing.T) {
+	invalidRepo := validRepo
+	invalidRepo.ID = "contoso/extras"
+
+	err := invalidRepo.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "invalid [ID] (contoso/extras), must only have letters, digits and '.', '_', ':' or '-'", err.Error())
+}
+
+func TestShouldFailBaseURLAndMetalink_Repo(t *testing.T) {
+	invalidRepo := validRepo
+	invalidRepo.Metalink = "https://mirrors.contoso.com/metalink"
+
+	err := invalidRepo.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "exactly one of [BaseURL] and [Metalink] must be set", err.Error())
+
+	invalidRepo.BaseURL = ""
+	invalidRepo.Metalink = ""
+
+	err = invalidRepo.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "exactly one of [BaseURL] and [Metalink] must be set", err.Error())
+}
+
+func TestShouldFailInvalidBaseURL_Repo(t *testing.T) {
+	invalidRepo := validRepo
+	invalidRepo.BaseURL = "ftp://packages.contoso.com/azurelinux"
+
+	err := invalidRepo.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "invalid [BaseURL] (ftp://packages.contoso.com/azurelinux): must be a (http, https, file) URL", err.Error())
+}
+
+func TestShouldFailFileMetalink_Repo(t *testing.T) {
+	invalidRepo := validRepo
+	invalidRepo.BaseURL = ""
+	invalidRepo.Metalink = "file:///mnt/metalink"
+
+	err := invalidRepo.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "invalid [Metalink] (file:///mnt/metalink): must be a (http, https) URL", err.Error())
+}
+
+func TestShouldFailUnparsableBaseURL_Repo(t *testing.T) {
+	invalidRepo := validRepo
+	invalidRepo.BaseURL = "packages.contoso.com"
+
+	err := invalidRepo.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "invalid [BaseURL] (packages.contoso.com): failed to parse URL:\nparse \"packages.contoso.com\": invalid URI for request", err.Error())
+}
+
+func TestShouldFailGPGCheckWithoutGPGKeys_Repo(t *testing.T) {
+	invalidRepo := validRepo
+	invalidRepo.GPGKeys = nil
+
+	err := invalidRepo.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "[GPGCheck] is enabled, but no [GPGKeys] are set", err.Error())
+}
+
+func TestShouldFailNegativePriority_Repo(t *testing.T) {
+	invalidRepo := validRepo
+	invalidRepo.Priority = -1
+
+	err := invalidRepo.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "invalid [Priority] (-1), must not be negative", err.Error())
+}
+
+func TestShouldSucceedSharingGPGKeys_Repo(t *testing.T) {
+	otherRepo := validRepo
+	otherRepo.ID = "contoso-tools"
+
+	assert.NoError(t, reposAreValid([]Repo{validRepo, otherRepo}))
+}
+
+func TestShouldFailDuplicateIDs_Repo(t *testing.T) {
+	err := reposAreValid([]Repo{validRepo, validRepo})
+	assert.Error(t, err)
+	assert.Equal(t, "duplicate repo ID (contoso-extras)", err.Error())
+}
+
+func TestShouldFailGPGKeysWithSameFileName_Repo(t *testing.T) {
+	otherRepo := validRepo
+	otherRepo.ID = "contoso-tools"
+	otherRepo.GPGKeys = []string{"tools/contoso.asc"}
+
+	err := reposAreValid([]Repo{validRepo, otherRepo})
+	assert.Error(t, err)
+	assert.Equal(t, "GPG keys (keys/contoso.asc) and (tools/contoso.asc) have the same file name", err.Error())
+}
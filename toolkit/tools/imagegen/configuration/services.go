@@ -0,0 +1,87 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+// Parser for the image builder's configuration schemas.
+
+package configuration
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+const presetFileExtension = ".preset"
+
+// Services controls the systemd units of the image, which are changed when the image is built, after its packages
+// and [AdditionalFiles] are installed.
+type Services struct {
+	// The systemd preset files to install in /etc/systemd/system-preset. They are applied to all the units (with
+	// 'systemctl preset-all') before [Enable], [Disable] and [Mask].
+	PresetFiles []string `json:"PresetFiles"`
+	Enable      []string `json:"Enable"`
+	Disable     []string `json:"Disable"`
+	Mask        []string `json:"Mask"`
+}
+
+// IsEmpty returns true if the Services don't change any units.
+func (s *Services) IsEmpty() bool {
+	return len(s.PresetFiles) == 0 && len(s.Enable) == 0 && len(s.Disable) == 0 && len(s.Mask) == 0
+}
+
+// IsValid returns an error if the Services are not valid
+func (s *Services) IsValid() (err error) {
+	presetFileNames := make(map[string]bool)
+	for _, presetFile := range s.PresetFiles {
+		presetFileName := filepath.Base(presetFile)
+		if filepath.Ext(presetFileName) != presetFileExtension {
+			return fmt.Errorf("preset file (%s) must have a (%s) extension", presetFile, presetFileExtension)
+		}
+		if presetFileNames[presetFileName] {
+			return fmt.Errorf("two [PresetFiles] are named (%s)", presetFileName)
+		}
+		presetFileNames[presetFileName] = true
+	}
+
+	unitLists := make(map[string]string)
+	for _, unitList := range []struct {
+		field string
+		units []string
+	}{
+		{"Enable", s.Enable},
+		{"Disable", s.Disable},
+		{"Mask", s.Mask},
+	} {
+		for _, unit := range unitList.units {
+			if unit == "" || strings.ContainsAny(unit, "/ \t\n") {
+				return fmt.Errorf("invalid unit name (%s) in [%s]", unit, unitList.field)
+			}
+
+			otherField, found := unitLists[unit]
+			if found {
+				return fmt.Errorf("unit (%s) is in both [%s] and [%s]", unit, otherField, unitList.field)
+			}
+			unitLists[unit] = unitList.field
+		}
+	}
+
+	return
+}
+
+// UnmarshalJSON Unmarshals a Services entry
+func (s *Services) UnmarshalJSON(b []byte) (err error) {
+	// Use an intermediate type which will use the default JSON unmarshal implementation
+	type IntermediateTypeServices Services
+	err = json.Unmarshal(b, (*IntermediateTypeServices)(s))
+	if err != nil {
+		return fmt.Errorf("failed to parse [Services]: %w", err)
+	}
+
+	// Now validate the resulting unmarshaled object
+	err = s.IsValid()
+	if err != nil {
+		return fmt.Errorf("failed to parse [Services]: %w", err)
+	}
+	return
+}
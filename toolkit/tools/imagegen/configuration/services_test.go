@@ -0,0 +1,94 @@
+// Copyright Microsoft Corporation.
+// Licensed under the MIT License.
+
+package configuration
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestMain found in configuration_test.go.
+
+var (
+	validServices = Services{
+		PresetFiles: []string{"presets/90-appliance.preset"},
+		Enable:      []string{"sshd.service", "chronyd"},
+		Disable:     []string{"tmp.mount"},
+		Mask:        []string{"systemd-networkd-wait-online.service"},
+	}
+	invalidServicesJSON = `{"Enable": "sshd.service"}`
+)
+
+func TestShouldSucceedParsingDefaultServices_Services(t *testing.T) {
+	var checkedServices Services
+
+	err := marshalJSONString("{}", &checkedServices)
+	assert.NoError(t, err)
+	assert.Equal(t, Services{}, checkedServices)
+	assert.True(t, checkedServices.IsEmpty())
+}
+
+func TestShouldSucceedParsingValidServices_Services(t *testing.T) {
+	var checkedServices Services
+
+	assert.NoError(t, validServices.IsValid())
+	err := remarshalJSON(validServices, &checkedServices)
+	assert.NoError(t, err)
+	assert.Equal(t, validServices, checkedServices)
+	assert.False(t, checkedServices.IsEmpty())
+}
+
+func TestShouldFailParsingInvalidJSON_Services(t *testing.T) {
+	var checkedServices Services
+
+	err := marshalJSONString(invalidServicesJSON, &checkedServices)
+	assert.Error(t, err)
+	assert.Equal(t, "failed to parse [Services]: json: cannot unmarshal string into Go struct field IntermediateTypeServices.Enable of type []string", err.Error())
+}
+
+func TestShouldFailParsingUnitInTwoLists_Services(t *testing.T) {
+	var checkedServices Services
+
+	invalidServices := validServices
+	invalidServices.Mask = []string{"tmp.mount"}
+
+	err := invalidServices.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "unit (tmp.mount) is in both [Disable] and [Mask]", err.Error())
+
+	err = remarshalJSON(invalidServices, &checkedServices)
+	assert.Error(t, err)
+	assert.Equal(t, "failed to parse [Services]: unit (tmp.mount) is in both [Disable] and [Mask]", err.Error())
+}
+
+func TestShouldFailParsingInvalidUnitName_Services(t *testing.T) {
+	invalidServices := validServices
+	invalidServices.Enable = []string{"sshd.service chronyd.service"}
+
+	err := invalidServices.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "invalid unit name (sshd.service chronyd.service) in [Enable]", err.Error())
+
+	invalidServices.Enable = []string{""}
+
+	err = invalidServices.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "invalid unit name () in [Enable]", err.Error())
+}
+
+func TestShouldFailParsingInvalidPresetFiles_Services(t *testing.T) {
+	invalidServices := validServices
+	invalidServices.PresetFiles = []string{"presets/90-appliance.conf"}
+
+	err := invalidServices.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "preset file (presets/90-appliance.conf) must have a (.preset) extension", err.Error())
+
+	invalidServices.PresetFiles = []string{"a/90-appliance.preset", "b/90-appliance.preset"}
+
+	err = invalidServices.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "two [PresetFiles] are named (90-appliance.preset)", err.Error())
+}
@@ -23,33 +23,57 @@ type SystemConfig struct {
 	BootType               string                    `json:"BootType"`
 	EnableGrubMkconfig     bool                      `json:"EnableGrubMkconfig"`
 	EnableSystemdFirstboot bool                      `json:"EnableSystemdFirstboot"`
+	InstanceIdentity       InstanceIdentity          `json:"InstanceIdentity"`
 	Hostname               string                    `json:"Hostname"`
 	Name                   string                    `json:"Name"`
 	PackageLists           []string                  `json:"PackageLists"`
 	Packages               []string                  `json:"Packages"`
+	RemovePackages         []string                  `json:"RemovePackages"`
+	PackageSignatures      PackageSignatures         `json:"PackageSignatures"`
 	KernelOptions          map[string]string         `json:"KernelOptions"`
 	KernelCommandLine      KernelCommandLine         `json:"KernelCommandLine"`
+	KernelModules          KernelModules             `json:"KernelModules"`
+	Localization           Localization              `json:"Localization"`
 	AdditionalFiles        map[string]FileConfigList `json:"AdditionalFiles"`
 	PartitionSettings      []PartitionSetting        `json:"PartitionSettings"`
 	PreInstallScripts      []InstallScript           `json:"PreInstallScripts"`
 	PostInstallScripts     []InstallScript           `json:"PostInstallScripts"`
 	FinalizeImageScripts   []InstallScript           `json:"FinalizeImageScripts"`
 	Networks               []Network                 `json:"Networks"`
+	Networking             Networking                `json:"Networking"`
+	Firewall               Firewall                  `json:"Firewall"`
+	ContainerImages        ContainerImages           `json:"ContainerImages"`
+	CACertificates         []string                  `json:"CACertificates"`
+	Proxy                  Proxy                     `json:"Proxy"`
 	PackageRepos           []PackageRepo             `json:"PackageRepos"`
+	Repos                  []Repo                    `json:"Repos"`
 	Groups                 []Group                   `json:"Groups"`
 	Users                  []User                    `json:"Users"`
 	Encryption             RootEncryption            `json:"Encryption"`
 	RemoveRpmDb            bool                      `json:"RemoveRpmDb"`
 	PreserveTdnfCache      bool                      `json:"PreserveTdnfCache"`
 	EnableHidepid          bool                      `json:"EnableHidepid"`
+	GrowRootPartition      bool                      `json:"GrowRootPartition"`
+	ReadOnlyRoot           bool                      `json:"ReadOnlyRoot"`
+	Services               Services                  `json:"Services"`
 	DisableRpmDocs         bool                      `json:"DisableRpmDocs"`
 	OverrideRpmLocales     string                    `json:"OverrideRpmLocales"`
 }
 
 const (
 	enableGrubMkconfigDefault bool = true
+
+	// HostnameMachineIDSpecifier is replaced by the machine ID in a hostname template.
+	HostnameMachineIDSpecifier = "%m"
+	// HostnameSerialSpecifier is replaced by the system's serial number in a hostname template.
+	HostnameSerialSpecifier = "%serial"
 )
 
+// IsHostnameTemplate returns true if the hostname is a template, which is only filled in on the first boot.
+func (s *SystemConfig) IsHostnameTemplate() bool {
+	return strings.Contains(s.Hostname, HostnameMachineIDSpecifier) || strings.Contains(s.Hostname, HostnameSerialSpecifier)
+}
+
 // GetRootPartitionSetting returns a pointer to the partition setting describing the disk which
 // will be mounted at "/", or nil if no partition is found
 func (s *SystemConfig) GetRootPartitionSetting() (rootPartitionSetting *PartitionSetting) {
@@ -67,6 +91,17 @@ func (s *SystemConfig) GetMountpointPartitionSetting(mountPoint string) (partiti
 	return FindMountpointPartitionSetting(s.PartitionSettings, mountPoint)
 }
 
+// hostnameIsValid returns true if the hostname, or each hostname that the template can be filled in with, is a DNS
+// name without '_'.
+func hostnameIsValid(hostname string) bool {
+	// The machine ID is 32 hex digits, and the serial number is cut to at most 32 characters.
+	const maxSpecifierLength = 32
+	filler := strings.Repeat("a", maxSpecifierLength)
+	hostname = strings.ReplaceAll(hostname, HostnameSerialSpecifier, filler)
+	hostname = strings.ReplaceAll(hostname, HostnameMachineIDSpecifier, filler)
+	return govalidator.IsDNSName(hostname) && !strings.Contains(hostname, "_")
+}
+
 func (s *SystemConfig) validateUsersAndGroups() (err error) {
 	groupMatchFunc := func(groupName interface{}, groupObj interface{}) bool {
 		return groupName == groupObj.(Group).Name
@@ -97,12 +132,45 @@ func (s *SystemConfig) validateUsersAndGroups() (err error) {
 	return err
 }
 
+// removePackagesAreValid returns an error if a package to remove is also installed by [Packages] or [KernelOptions].
+// The package lists are checked by the imageconfigvalidator tool, as their paths may not be valid at this point.
+func (s *SystemConfig) removePackagesAreValid() (err error) {
+	removePackages := make(map[string]bool)
+	for _, pkg := range s.RemovePackages {
+		if strings.TrimSpace(pkg) == "" || strings.ContainsAny(pkg, " \t\n") {
+			return fmt.Errorf("invalid package name (%s)", pkg)
+		}
+		if removePackages[pkg] {
+			return fmt.Errorf("duplicate package (%s)", pkg)
+		}
+		removePackages[pkg] = true
+	}
+
+	for _, pkg := range s.Packages {
+		if removePackages[pkg] {
+			return fmt.Errorf("package (%s) is also installed by [Packages]", pkg)
+		}
+	}
+
+	for name, kernelName := range s.KernelOptions {
+		// Skip comments
+		if name[0] == '_' {
+			continue
+		}
+		if removePackages[kernelName] {
+			return fmt.Errorf("package (%s) is the kernel of [KernelOptions] (%s)", kernelName, name)
+		}
+	}
+
+	return
+}
+
 // IsValid returns an error if the SystemConfig is not valid
 func (s *SystemConfig) IsValid() (err error) {
 	// IsDefault must be validated by a parent struct
 
 	// Validate HostName
-	if (!govalidator.IsDNSName(s.Hostname) || strings.Contains(s.Hostname, "_")) && s.Hostname != "" {
+	if !hostnameIsValid(s.Hostname) && s.Hostname != "" {
 		return fmt.Errorf("invalid [Hostname]: %s", s.Hostname)
 	}
 
@@ -122,6 +190,14 @@ func (s *SystemConfig) IsValid() (err error) {
 	// Additional package list validation must be done via the imageconfigvalidator tool since there is no guranatee that
 	// the paths are valid at this point.
 
+	if err = s.removePackagesAreValid(); err != nil {
+		return fmt.Errorf("invalid [RemovePackages]: %w", err)
+	}
+
+	if err = s.PackageSignatures.IsValid(); err != nil {
+		return fmt.Errorf("invalid [PackageSignatures]: %w", err)
+	}
+
 	// Enforce that any non-rootfs configuration has a default kernel.
 	if len(s.PartitionSettings) != 0 {
 		// Ensure that default option is always present
@@ -168,10 +244,40 @@ func (s *SystemConfig) IsValid() (err error) {
 		}
 	}
 
+	if s.ReadOnlyRoot {
+		if !mountPointUsed["/"] {
+			return fmt.Errorf("invalid [ReadOnlyRoot]: must have a partition mounted at '/'")
+		}
+		// The root file system can't be grown while it's mounted read-only.
+		if s.GrowRootPartition {
+			return fmt.Errorf("invalid [ReadOnlyRoot]: can't be combined with [GrowRootPartition]")
+		}
+	}
+
+	if err = s.Services.IsValid(); err != nil {
+		return fmt.Errorf("invalid [Services]: %w", err)
+	}
+
 	if err = s.KernelCommandLine.IsValid(); err != nil {
 		return fmt.Errorf("invalid [KernelCommandLine]: %w", err)
 	}
 
+	if err = s.KernelModules.IsValid(); err != nil {
+		return fmt.Errorf("invalid [KernelModules]: %w", err)
+	}
+
+	if err = s.InstanceIdentity.IsValid(); err != nil {
+		return fmt.Errorf("invalid [InstanceIdentity]: %w", err)
+	}
+
+	if s.EnableSystemdFirstboot && s.InstanceIdentity.MachineID.IsPreserved() {
+		return fmt.Errorf("invalid [InstanceIdentity]: [EnableSystemdFirstboot] requires the [MachineID] to be reset, as systemd only detects the first boot from an uninitialized machine ID")
+	}
+
+	if err = s.Localization.IsValid(); err != nil {
+		return fmt.Errorf("invalid [Localization]: %w", err)
+	}
+
 	for srcFile, fileConfigList := range s.AdditionalFiles {
 		err = fileConfigList.IsValid()
 		if err != nil {
@@ -192,6 +298,18 @@ func (s *SystemConfig) IsValid() (err error) {
 		repoNames[packageRepo.Name] = true
 	}
 
+	if err = reposAreValid(s.Repos); err != nil {
+		return fmt.Errorf("invalid [Repos]: %w", err)
+	}
+	// Both write the repo files of the installed repos into the image.
+	for _, repo := range s.Repos {
+		for _, packageRepo := range s.PackageRepos {
+			if packageRepo.Install && packageRepo.Name == repo.ID {
+				return fmt.Errorf("invalid [Repos]: repo ID (%s) is also the name of an installed [PackageRepos] entry", repo.ID)
+			}
+		}
+	}
+
 	//Validate PostInstallScripts
 
 	// Validate Networks
@@ -201,6 +319,32 @@ func (s *SystemConfig) IsValid() (err error) {
 		}
 	}
 
+	if err = s.Networking.IsValid(); err != nil {
+		return fmt.Errorf("invalid [Networking]: %w", err)
+	}
+	// Both write systemd-networkd files, which could configure the same interfaces.
+	if !s.Networking.IsEmpty() && len(s.Networks) > 0 {
+		return fmt.Errorf("invalid [Networking]: can't be combined with [Networks]")
+	}
+
+	if err = s.Firewall.IsValid(); err != nil {
+		return fmt.Errorf("invalid [Firewall]: %w", err)
+	}
+
+	if err = s.ContainerImages.IsValid(); err != nil {
+		return fmt.Errorf("invalid [ContainerImages]: %w", err)
+	}
+
+	for _, caCertificate := range s.CACertificates {
+		if strings.TrimSpace(caCertificate) == "" {
+			return fmt.Errorf("invalid [CACertificates]: empty path")
+		}
+	}
+
+	if err = s.Proxy.IsValid(); err != nil {
+		return fmt.Errorf("invalid [Proxy]: %w", err)
+	}
+
 	//Validate Groups
 	//Validate Users
 	for _, b := range s.Users {
@@ -215,6 +359,29 @@ func (s *SystemConfig) IsValid() (err error) {
 	}
 
 	//Validate Encryption
+	if err = s.Encryption.TPM2.IsValid(); err != nil {
+		return fmt.Errorf("invalid [Encryption]: invalid [TPM2]: %w", err)
+	}
+	if !s.Encryption.Enable {
+		if s.Encryption.TPM2.Enable || len(s.Encryption.AdditionalPartitions) > 0 {
+			return fmt.Errorf("invalid [Encryption]: [TPM2] and [AdditionalPartitions] require [Enable]")
+		}
+	} else {
+		// A TPM2 token needs LUKS2, which grub can't unlock, so grub must read the kernel and the initramfs from an
+		// unencrypted /boot partition.
+		if s.Encryption.TPM2.Enable && len(mountPointUsed) > 0 && !mountPointUsed["/boot"] {
+			return fmt.Errorf("invalid [Encryption]: [TPM2] requires a separate, unencrypted partition mounted at '/boot'")
+		}
+		for _, partID := range s.Encryption.AdditionalPartitions {
+			partitionSetting := FindPartitionSettingByID(s.PartitionSettings, partID)
+			if partitionSetting == nil {
+				return fmt.Errorf("invalid [Encryption]: [AdditionalPartitions] partition (%s) is not in [PartitionSettings]", partID)
+			}
+			if partitionSetting.MountPoint == "/" || partitionSetting.MountPoint == "/boot" || partitionSetting.MountPoint == "/boot/efi" {
+				return fmt.Errorf("invalid [Encryption]: [AdditionalPartitions] partition (%s) can't be mounted at (%s)", partID, partitionSetting.MountPoint)
+			}
+		}
+	}
 
 	// Validate locales
 
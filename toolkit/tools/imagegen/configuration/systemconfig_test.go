@@ -185,6 +185,123 @@ func TestShouldFailParsingInvalidHostName_SystemConfig(t *testing.T) {
 	assert.Equal(t, "failed to parse [SystemConfig]: invalid [Hostname]: abcd_efg", err.Error())
 }
 
+func TestShouldSucceedParsingHostnameTemplate_SystemConfig(t *testing.T) {
+	var checkedSystemConfig SystemConfig
+
+	templateHostnameConfig := validSystemConfig
+	templateHostnameConfig.Hostname = "edge-%serial"
+	assert.True(t, templateHostnameConfig.IsHostnameTemplate())
+
+	err := remarshalJSON(templateHostnameConfig, &checkedSystemConfig)
+	assert.NoError(t, err)
+	assert.Equal(t, templateHostnameConfig, checkedSystemConfig)
+
+	templateHostnameConfig.Hostname = "node-%m.contoso.com"
+	assert.True(t, templateHostnameConfig.IsHostnameTemplate())
+	assert.NoError(t, templateHostnameConfig.IsValid())
+
+	assert.False(t, validSystemConfig.IsHostnameTemplate())
+}
+
+func TestShouldFailParsingInvalidHostnameTemplate_SystemConfig(t *testing.T) {
+	badHostnameConfig := validSystemConfig
+
+	for _, hostname := range []string{"edge-%s", "edge_%serial", "%m%m"} {
+		badHostnameConfig.Hostname = hostname
+
+		err := badHostnameConfig.IsValid()
+		assert.Error(t, err)
+		assert.Equal(t, "invalid [Hostname]: "+hostname, err.Error())
+	}
+}
+
+func TestShouldFailParsingEmptyCACertificate_SystemConfig(t *testing.T) {
+	badCACertificatesConfig := validSystemConfig
+	badCACertificatesConfig.CACertificates = []string{"certs/contoso-root.pem", " "}
+
+	err := badCACertificatesConfig.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "invalid [CACertificates]: empty path", err.Error())
+}
+
+func TestShouldSucceedParsingRemovePackages_SystemConfig(t *testing.T) {
+	var checkedSystemConfig SystemConfig
+
+	removePackagesConfig := validSystemConfig
+	removePackagesConfig.RemovePackages = []string{"man-db", "python3-pip"}
+
+	assert.NoError(t, removePackagesConfig.IsValid())
+	err := remarshalJSON(removePackagesConfig, &checkedSystemConfig)
+	assert.NoError(t, err)
+	assert.Equal(t, removePackagesConfig, checkedSystemConfig)
+}
+
+func TestShouldFailParsingEmptyRemovePackage_SystemConfig(t *testing.T) {
+	badRemovePackagesConfig := validSystemConfig
+	badRemovePackagesConfig.RemovePackages = []string{"man-db", " "}
+
+	err := badRemovePackagesConfig.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "invalid [RemovePackages]: invalid package name ( )", err.Error())
+}
+
+func TestShouldFailParsingDuplicateRemovePackage_SystemConfig(t *testing.T) {
+	badRemovePackagesConfig := validSystemConfig
+	badRemovePackagesConfig.RemovePackages = []string{"man-db", "man-db"}
+
+	err := badRemovePackagesConfig.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "invalid [RemovePackages]: duplicate package (man-db)", err.Error())
+}
+
+func TestShouldFailParsingInstalledRemovePackage_SystemConfig(t *testing.T) {
+	badRemovePackagesConfig := validSystemConfig
+	badRemovePackagesConfig.Packages = []string{"man-db"}
+	badRemovePackagesConfig.RemovePackages = []string{"man-db"}
+
+	err := badRemovePackagesConfig.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "invalid [RemovePackages]: package (man-db) is also installed by [Packages]", err.Error())
+}
+
+func TestShouldFailParsingKernelRemovePackage_SystemConfig(t *testing.T) {
+	badRemovePackagesConfig := validSystemConfig
+	badRemovePackagesConfig.RemovePackages = []string{"kernel"}
+
+	err := badRemovePackagesConfig.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "invalid [RemovePackages]: package (kernel) is the kernel of [KernelOptions] (default)", err.Error())
+}
+
+func TestShouldFailPreservedMachineIDWithFirstboot_SystemConfig(t *testing.T) {
+	badInstanceIdentityConfig := validSystemConfig
+	badInstanceIdentityConfig.EnableSystemdFirstboot = true
+	badInstanceIdentityConfig.InstanceIdentity = InstanceIdentity{MachineID: IdentityPolicyPreserve}
+
+	err := badInstanceIdentityConfig.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "invalid [InstanceIdentity]: [EnableSystemdFirstboot] requires the [MachineID] to be reset, as systemd only detects the first boot from an uninitialized machine ID", err.Error())
+}
+
+func TestShouldFailParsingInvalidLocalization_SystemConfig(t *testing.T) {
+	badLocalizationConfig := validSystemConfig
+	badLocalizationConfig.Localization = Localization{Keymap: "de latin1"}
+
+	err := badLocalizationConfig.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "invalid [Localization]: invalid [Keymap] (de latin1), must be the name of a keymap, e.g. de-latin1", err.Error())
+}
+
+func TestShouldFailParsingRepoWithInstalledPackageRepoName_SystemConfig(t *testing.T) {
+	badReposConfig := validSystemConfig
+	badReposConfig.PackageRepos = []PackageRepo{{Name: "contoso-extras", BaseUrl: "https://repo1.com", Install: true}}
+	badReposConfig.Repos = []Repo{validRepo}
+
+	err := badReposConfig.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "invalid [Repos]: repo ID (contoso-extras) is also the name of an installed [PackageRepos] entry", err.Error())
+}
+
 func TestShouldFailParsingDuplicatePackageRepoNames(t *testing.T) {
 	var checkedSystemConfig SystemConfig
 
@@ -305,3 +422,92 @@ func TestShouldFaiInvalidAdditionalFiles_SystemConfig(t *testing.T) {
 	assert.Error(t, err)
 	assert.Equal(t, "invalid [AdditionalFiles]: (a.txt): list is empty", err.Error())
 }
+
+func TestShouldSucceedParsingTPM2Encryption_SystemConfig(t *testing.T) {
+	var checkedSystemConfig SystemConfig
+
+	tpm2Config := validSystemConfig
+	tpm2Config.Encryption = RootEncryption{
+		Enable:   true,
+		Password: "EncryptPassphrase123",
+		TPM2:     TPM2Unlock{Enable: true, PCRs: "7+11"},
+	}
+
+	assert.NoError(t, tpm2Config.IsValid())
+	err := remarshalJSON(tpm2Config, &checkedSystemConfig)
+	assert.NoError(t, err)
+	assert.Equal(t, tpm2Config, checkedSystemConfig)
+}
+
+func TestShouldFailParsingTPM2WithoutEncryption_SystemConfig(t *testing.T) {
+	var checkedSystemConfig SystemConfig
+
+	tpm2Config := validSystemConfig
+	tpm2Config.Encryption = RootEncryption{TPM2: TPM2Unlock{Enable: true}}
+
+	err := tpm2Config.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "invalid [Encryption]: [TPM2] and [AdditionalPartitions] require [Enable]", err.Error())
+
+	err = remarshalJSON(tpm2Config, &checkedSystemConfig)
+	assert.Error(t, err)
+	assert.Equal(t, "failed to parse [SystemConfig]: invalid [Encryption]: [TPM2] and [AdditionalPartitions] require [Enable]", err.Error())
+}
+
+func TestShouldFailParsingTPM2WithoutBootPartition_SystemConfig(t *testing.T) {
+	tpm2Config := validSystemConfig
+	tpm2Config.PartitionSettings = []PartitionSetting{validSystemConfig.PartitionSettings[1]}
+	tpm2Config.Encryption = RootEncryption{Enable: true, TPM2: TPM2Unlock{Enable: true}}
+
+	err := tpm2Config.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "invalid [Encryption]: [TPM2] requires a separate, unencrypted partition mounted at '/boot'", err.Error())
+}
+
+func TestShouldFailParsingInvalidAdditionalEncryptedPartitions_SystemConfig(t *testing.T) {
+	encryptedConfig := validSystemConfig
+	encryptedConfig.Encryption = RootEncryption{Enable: true, AdditionalPartitions: []string{"NotAPartition"}}
+
+	err := encryptedConfig.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "invalid [Encryption]: [AdditionalPartitions] partition (NotAPartition) is not in [PartitionSettings]", err.Error())
+
+	encryptedConfig.Encryption = RootEncryption{Enable: true, AdditionalPartitions: []string{"MyBoot"}}
+
+	err = encryptedConfig.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "invalid [Encryption]: [AdditionalPartitions] partition (MyBoot) can't be mounted at (/boot)", err.Error())
+}
+
+func TestShouldSucceedParsingReadOnlyRoot_SystemConfig(t *testing.T) {
+	var checkedSystemConfig SystemConfig
+
+	readOnlyConfig := validSystemConfig
+	readOnlyConfig.ReadOnlyRoot = true
+
+	assert.NoError(t, readOnlyConfig.IsValid())
+	err := remarshalJSON(readOnlyConfig, &checkedSystemConfig)
+	assert.NoError(t, err)
+	assert.Equal(t, readOnlyConfig, checkedSystemConfig)
+}
+
+func TestShouldFailParsingReadOnlyRootWithoutRoot_SystemConfig(t *testing.T) {
+	readOnlyConfig := validSystemConfig
+	readOnlyConfig.PartitionSettings = []PartitionSetting{validSystemConfig.PartitionSettings[0]}
+	readOnlyConfig.Encryption = RootEncryption{}
+	readOnlyConfig.ReadOnlyRoot = true
+
+	err := readOnlyConfig.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "invalid [ReadOnlyRoot]: must have a partition mounted at '/'", err.Error())
+}
+
+func TestShouldFailParsingReadOnlyRootWithGrowRootPartition_SystemConfig(t *testing.T) {
+	readOnlyConfig := validSystemConfig
+	readOnlyConfig.ReadOnlyRoot = true
+	readOnlyConfig.GrowRootPartition = true
+
+	err := readOnlyConfig.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "invalid [ReadOnlyRoot]: can't be combined with [GrowRootPartition]", err.Error())
+}
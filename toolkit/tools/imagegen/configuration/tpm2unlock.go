@@ -0,0 +1,74 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+// Parser for the image builder's configuration schemas.
+
+package configuration
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	// DefaultTPM2PCRs binds the TPM2 token to the Secure Boot state.
+	DefaultTPM2PCRs = "7"
+
+	maxTPM2PCR = 23
+)
+
+// TPM2Unlock enrolls a TPM2 token in the encrypted partitions, so that they are unlocked at boot without a password,
+// as long as the bound PCRs don't change. The password is kept as a fallback.
+type TPM2Unlock struct {
+	Enable bool `json:"Enable"`
+	// The PCRs that the token is bound to, separated by '+' (as in systemd-cryptenroll's --tpm2-pcrs), e.g. "7+11".
+	// Defaults to DefaultTPM2PCRs.
+	PCRs string `json:"PCRs"`
+}
+
+// GetPCRs returns the PCRs that the token is bound to.
+func (t *TPM2Unlock) GetPCRs() string {
+	if t.PCRs == "" {
+		return DefaultTPM2PCRs
+	}
+	return t.PCRs
+}
+
+// IsValid returns an error if the TPM2Unlock is not valid
+func (t *TPM2Unlock) IsValid() (err error) {
+	if t.PCRs == "" {
+		return
+	}
+
+	if !t.Enable {
+		return fmt.Errorf("[PCRs] is set, but [Enable] is not")
+	}
+
+	for _, pcr := range strings.Split(t.PCRs, "+") {
+		pcrIndex, err := strconv.Atoi(pcr)
+		if err != nil || pcrIndex < 0 || pcrIndex > maxTPM2PCR {
+			return fmt.Errorf("invalid PCR (%s) in [PCRs] (%s), must be a number from 0 to %d", pcr, t.PCRs, maxTPM2PCR)
+		}
+	}
+
+	return
+}
+
+// UnmarshalJSON Unmarshals a TPM2Unlock entry
+func (t *TPM2Unlock) UnmarshalJSON(b []byte) (err error) {
+	// Use an intermediate type which will use the default JSON unmarshal implementation
+	type IntermediateTypeTPM2Unlock TPM2Unlock
+	err = json.Unmarshal(b, (*IntermediateTypeTPM2Unlock)(t))
+	if err != nil {
+		return fmt.Errorf("failed to parse [TPM2]: %w", err)
+	}
+
+	// Now validate the resulting unmarshaled object
+	err = t.IsValid()
+	if err != nil {
+		return fmt.Errorf("failed to parse [TPM2]: %w", err)
+	}
+	return
+}
@@ -0,0 +1,71 @@
+// Copyright Microsoft Corporation.
+// Licensed under the MIT License.
+
+package configuration
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestMain found in configuration_test.go.
+
+var (
+	validTPM2Unlocks = []TPM2Unlock{
+		{},
+		{Enable: true},
+		{Enable: true, PCRs: "7"},
+		{Enable: true, PCRs: "0+7+11+23"},
+	}
+	invalidTPM2UnlockJSON = `{"Enable": "yes"}`
+)
+
+func TestShouldSucceedParsingValidTPM2Unlocks_TPM2Unlock(t *testing.T) {
+	for _, validTPM2Unlock := range validTPM2Unlocks {
+		var checkedTPM2Unlock TPM2Unlock
+
+		assert.NoError(t, validTPM2Unlock.IsValid())
+		err := remarshalJSON(validTPM2Unlock, &checkedTPM2Unlock)
+		assert.NoError(t, err)
+		assert.Equal(t, validTPM2Unlock, checkedTPM2Unlock)
+	}
+}
+
+func TestShouldSucceedDefaultingPCRs_TPM2Unlock(t *testing.T) {
+	assert.Equal(t, DefaultTPM2PCRs, (&TPM2Unlock{Enable: true}).GetPCRs())
+	assert.Equal(t, "7+11", (&TPM2Unlock{Enable: true, PCRs: "7+11"}).GetPCRs())
+}
+
+func TestShouldFailParsingInvalidPCRs_TPM2Unlock(t *testing.T) {
+	var checkedTPM2Unlock TPM2Unlock
+
+	invalidTPM2Unlock := TPM2Unlock{Enable: true, PCRs: "7+24"}
+	err := invalidTPM2Unlock.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "invalid PCR (24) in [PCRs] (7+24), must be a number from 0 to 23", err.Error())
+
+	err = remarshalJSON(invalidTPM2Unlock, &checkedTPM2Unlock)
+	assert.Error(t, err)
+	assert.Equal(t, "failed to parse [TPM2]: invalid PCR (24) in [PCRs] (7+24), must be a number from 0 to 23", err.Error())
+
+	invalidTPM2Unlock = TPM2Unlock{Enable: true, PCRs: "7,11"}
+	err = invalidTPM2Unlock.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "invalid PCR (7,11) in [PCRs] (7,11), must be a number from 0 to 23", err.Error())
+}
+
+func TestShouldFailParsingPCRsWithoutEnable_TPM2Unlock(t *testing.T) {
+	invalidTPM2Unlock := TPM2Unlock{PCRs: "7"}
+	err := invalidTPM2Unlock.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "[PCRs] is set, but [Enable] is not", err.Error())
+}
+
+func TestShouldFailParsingInvalidJSON_TPM2Unlock(t *testing.T) {
+	var checkedTPM2Unlock TPM2Unlock
+
+	err := marshalJSONString(invalidTPM2UnlockJSON, &checkedTPM2Unlock)
+	assert.Error(t, err)
+	assert.Equal(t, "failed to parse [TPM2]: json: cannot unmarshal string into Go struct field IntermediateTypeTPM2Unlock.Enable of type bool", err.Error())
+}
@@ -0,0 +1,177 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+// Parser for the image builder's configuration schemas.
+
+package configuration
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+)
+
+// LogicalVolumeType is the type of an LVM logical volume
+type LogicalVolumeType string
+
+const (
+	// LogicalVolumeTypeLinear is a regular logical volume
+	LogicalVolumeTypeLinear LogicalVolumeType = "linear"
+	// LogicalVolumeTypeThinPool is a thin pool, which holds the thin logical volumes. It has no file system.
+	LogicalVolumeTypeThinPool LogicalVolumeType = "thin-pool"
+	// LogicalVolumeTypeThin is a thin logical volume, which is allocated from a thin pool as it is written to
+	LogicalVolumeTypeThin LogicalVolumeType = "thin"
+	// LogicalVolumeTypeDefault is a regular logical volume
+	LogicalVolumeTypeDefault LogicalVolumeType = ""
+)
+
+// encryptedRootVolumeGroupName is the volume group that root encryption creates on the encrypted root partition.
+const encryptedRootVolumeGroupName = "cryptVG"
+
+// lvmNameRegex is the names that LVM accepts for volume groups and logical volumes.
+var lvmNameRegex = regexp.MustCompile(`^[a-zA-Z0-9+_.][a-zA-Z0-9+_.-]*$`)
+
+// VolumeGroup is an LVM volume group, which is created on some of the disk's partitions (as its physical volumes),
+// and is split into logical volumes.
+type VolumeGroup struct {
+	Name string `json:"Name"`
+	// The IDs of the partitions that are the physical volumes of the volume group. They must not have a [FsType].
+	PhysicalVolumes []string        `json:"PhysicalVolumes"`
+	LogicalVolumes  []LogicalVolume `json:"LogicalVolumes"`
+}
+
+// LogicalVolume is an LVM logical volume. [PartitionSettings] mount it by its ID, like a partition.
+// "Size" is in MiBs. A "Size" of 0 fills the rest of the volume group, so it's only allowed for the last non-thin
+// logical volume. A thin logical volume's size is its virtual size, which may be larger than its thin pool.
+type LogicalVolume struct {
+	ID     string            `json:"ID"`
+	Name   string            `json:"Name"`
+	Type   LogicalVolumeType `json:"Type"`
+	Size   uint64            `json:"Size"`
+	FsType string            `json:"FsType"`
+	// The name of the thin pool that a thin logical volume is allocated from.
+	ThinPool string `json:"ThinPool"`
+}
+
+func (t LogicalVolumeType) String() string {
+	return fmt.Sprint(string(t))
+}
+
+// GetValidLogicalVolumeTypes returns a list of all the supported logical volume types
+func (t *LogicalVolumeType) GetValidLogicalVolumeTypes() (types []LogicalVolumeType) {
+	return []LogicalVolumeType{
+		LogicalVolumeTypeLinear,
+		LogicalVolumeTypeThinPool,
+		LogicalVolumeTypeThin,
+		LogicalVolumeTypeDefault,
+	}
+}
+
+// IsValid returns an error if the LogicalVolumeType is not valid
+func (t *LogicalVolumeType) IsValid() (err error) {
+	for _, valid := range t.GetValidLogicalVolumeTypes() {
+		if *t == valid {
+			return
+		}
+	}
+	return fmt.Errorf("invalid value for LogicalVolumeType (%s)", t)
+}
+
+// IsValid returns an error if the LogicalVolume is not valid
+func (l *LogicalVolume) IsValid() (err error) {
+	if !lvmNameRegex.MatchString(l.Name) {
+		return fmt.Errorf("invalid [Name] (%s), must only have letters, digits and '+_.-', and not start with '-'", l.Name)
+	}
+
+	if err = l.Type.IsValid(); err != nil {
+		return fmt.Errorf("invalid [Type]: %w", err)
+	}
+
+	switch l.Type {
+	case LogicalVolumeTypeThinPool:
+		if l.ID != "" || l.FsType != "" {
+			return fmt.Errorf("thin pool (%s) can't have an [ID] or a [FsType], as it can't be mounted", l.Name)
+		}
+	case LogicalVolumeTypeThin:
+		if l.ThinPool == "" {
+			return fmt.Errorf("thin logical volume (%s) must have a [ThinPool]", l.Name)
+		}
+		if l.Size == 0 {
+			return fmt.Errorf("thin logical volume (%s) must have a [Size]", l.Name)
+		}
+	default:
+		if l.ThinPool != "" {
+			return fmt.Errorf("[ThinPool] is only valid for thin logical volumes, but logical volume (%s) is (%s)", l.Name, l.Type)
+		}
+	}
+
+	return
+}
+
+// IsValid returns an error if the VolumeGroup is not valid
+func (v *VolumeGroup) IsValid() (err error) {
+	if !lvmNameRegex.MatchString(v.Name) {
+		return fmt.Errorf("invalid [Name] (%s), must only have letters, digits and '+_.-', and not start with '-'", v.Name)
+	}
+
+	if v.Name == encryptedRootVolumeGroupName {
+		return fmt.Errorf("volume group name (%s) is reserved for the encrypted root ([Encryption])", v.Name)
+	}
+
+	if len(v.PhysicalVolumes) == 0 {
+		return fmt.Errorf("volume group (%s) must have at least one of [PhysicalVolumes]", v.Name)
+	}
+
+	names := make(map[string]bool)
+	thinPools := make(map[string]bool)
+	fillsVolumeGroup := ""
+	for _, logicalVolume := range v.LogicalVolumes {
+		if err = logicalVolume.IsValid(); err != nil {
+			return fmt.Errorf("invalid [LogicalVolume] in volume group (%s): %w", v.Name, err)
+		}
+
+		if names[logicalVolume.Name] {
+			return fmt.Errorf("volume group (%s) has two logical volumes named (%s)", v.Name, logicalVolume.Name)
+		}
+		names[logicalVolume.Name] = true
+
+		switch logicalVolume.Type {
+		case LogicalVolumeTypeThinPool:
+			thinPools[logicalVolume.Name] = true
+		case LogicalVolumeTypeThin:
+			// The thin pool must be created first.
+			if !thinPools[logicalVolume.ThinPool] {
+				return fmt.Errorf("thin logical volume (%s) is in thin pool (%s), which isn't a thin pool listed before it in volume group (%s)",
+					logicalVolume.Name, logicalVolume.ThinPool, v.Name)
+			}
+			continue
+		}
+
+		if fillsVolumeGroup != "" {
+			return fmt.Errorf("logical volume (%s) has no [Size], so it fills volume group (%s), but logical volume (%s) comes after it",
+				fillsVolumeGroup, v.Name, logicalVolume.Name)
+		}
+		if logicalVolume.Size == 0 {
+			fillsVolumeGroup = logicalVolume.Name
+		}
+	}
+
+	return
+}
+
+// UnmarshalJSON Unmarshals a VolumeGroup entry
+func (v *VolumeGroup) UnmarshalJSON(b []byte) (err error) {
+	// Use an intermediate type which will use the default JSON unmarshal implementation
+	type IntermediateTypeVolumeGroup VolumeGroup
+	err = json.Unmarshal(b, (*IntermediateTypeVolumeGroup)(v))
+	if err != nil {
+		return fmt.Errorf("failed to parse [VolumeGroup]: %w", err)
+	}
+
+	// Now validate the resulting unmarshaled object
+	err = v.IsValid()
+	if err != nil {
+		return fmt.Errorf("failed to parse [VolumeGroup]: %w", err)
+	}
+	return
+}
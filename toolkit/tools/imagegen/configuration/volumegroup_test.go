@@ -0,0 +1,150 @@
+// Copyright Microsoft Corporation.
+// Licensed under the MIT License.
+
+package configuration
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestMain found in configuration_test.go.
+
+var (
+	validVolumeGroups = []VolumeGroup{
+		{
+			Name:            "rootvg",
+			PhysicalVolumes: []string{"MyPV"},
+			LogicalVolumes: []LogicalVolume{
+				{ID: "MyRoot", Name: "root", Size: 4096, FsType: "ext4"},
+				{ID: "MyVar", Name: "var", Type: LogicalVolumeTypeLinear, FsType: "xfs"},
+			},
+		},
+		{
+			Name:            "data-vg",
+			PhysicalVolumes: []string{"MyPV1", "MyPV2"},
+			LogicalVolumes: []LogicalVolume{
+				{Name: "pool", Type: LogicalVolumeTypeThinPool, Size: 1024},
+				{ID: "MyData", Name: "data", Type: LogicalVolumeTypeThin, Size: 8192, FsType: "ext4", ThinPool: "pool"},
+				{ID: "MyLogs", Name: "logs", Type: LogicalVolumeTypeThin, Size: 2048, FsType: "ext4", ThinPool: "pool"},
+			},
+		},
+	}
+	invalidVolumeGroupJSON = `{"Name": "rootvg", "PhysicalVolumes": "MyPV"}`
+)
+
+func TestShouldSucceedParsingValidVolumeGroups_VolumeGroup(t *testing.T) {
+	for _, validVolumeGroup := range validVolumeGroups {
+		var checkedVolumeGroup VolumeGroup
+
+		assert.NoError(t, validVolumeGroup.IsValid())
+		err := remarshalJSON(validVolumeGroup, &checkedVolumeGroup)
+		assert.NoError(t, err)
+		assert.Equal(t, validVolumeGroup, checkedVolumeGroup)
+	}
+}
+
+func TestShouldFailParsingInvalidJSON_VolumeGroup(t *testing.T) {
+	var checkedVolumeGroup VolumeGroup
+
+	err := marshalJSONString(invalidVolumeGroupJSON, &checkedVolumeGroup)
+	assert.Error(t, err)
+	assert.Equal(t, "failed to parse [VolumeGroup]: json: cannot unmarshal string into Go struct field IntermediateTypeVolumeGroup.PhysicalVolumes of type []string", err.Error())
+}
+
+func TestShouldFailParsingInvalidName_VolumeGroup(t *testing.T) {
+	var checkedVolumeGroup VolumeGroup
+
+	invalidVolumeGroup := validVolumeGroups[0]
+	invalidVolumeGroup.Name = "-rootvg"
+	err := invalidVolumeGroup.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "invalid [Name] (-rootvg), must only have letters, digits and '+_.-', and not start with '-'", err.Error())
+
+	err = remarshalJSON(invalidVolumeGroup, &checkedVolumeGroup)
+	assert.Error(t, err)
+	assert.Equal(t, "failed to parse [VolumeGroup]: invalid [Name] (-rootvg), must only have letters, digits and '+_.-', and not start with '-'", err.Error())
+}
+
+func TestShouldFailParsingReservedName_VolumeGroup(t *testing.T) {
+	invalidVolumeGroup := validVolumeGroups[0]
+	invalidVolumeGroup.Name = "cryptVG"
+	err := invalidVolumeGroup.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "volume group name (cryptVG) is reserved for the encrypted root ([Encryption])", err.Error())
+}
+
+func TestShouldFailParsingNoPhysicalVolumes_VolumeGroup(t *testing.T) {
+	invalidVolumeGroup := validVolumeGroups[0]
+	invalidVolumeGroup.PhysicalVolumes = []string{}
+	err := invalidVolumeGroup.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "volume group (rootvg) must have at least one of [PhysicalVolumes]", err.Error())
+}
+
+func TestShouldFailParsingDuplicateLogicalVolumeNames_VolumeGroup(t *testing.T) {
+	invalidVolumeGroup := validVolumeGroups[0]
+	invalidVolumeGroup.LogicalVolumes = []LogicalVolume{
+		{ID: "MyRoot", Name: "root", Size: 4096, FsType: "ext4"},
+		{ID: "MyVar", Name: "root", FsType: "xfs"},
+	}
+	err := invalidVolumeGroup.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "volume group (rootvg) has two logical volumes named (root)", err.Error())
+}
+
+func TestShouldFailParsingLogicalVolumeAfterFillingOne_VolumeGroup(t *testing.T) {
+	invalidVolumeGroup := validVolumeGroups[0]
+	invalidVolumeGroup.LogicalVolumes = []LogicalVolume{
+		{ID: "MyVar", Name: "var", FsType: "xfs"},
+		{ID: "MyRoot", Name: "root", Size: 4096, FsType: "ext4"},
+	}
+	err := invalidVolumeGroup.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "logical volume (var) has no [Size], so it fills volume group (rootvg), but logical volume (root) comes after it", err.Error())
+}
+
+func TestShouldFailParsingThinVolumeBeforeItsPool_VolumeGroup(t *testing.T) {
+	invalidVolumeGroup := validVolumeGroups[1]
+	invalidVolumeGroup.LogicalVolumes = []LogicalVolume{
+		{ID: "MyData", Name: "data", Type: LogicalVolumeTypeThin, Size: 8192, FsType: "ext4", ThinPool: "pool"},
+		{Name: "pool", Type: LogicalVolumeTypeThinPool, Size: 1024},
+	}
+	err := invalidVolumeGroup.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "thin logical volume (data) is in thin pool (pool), which isn't a thin pool listed before it in volume group (data-vg)", err.Error())
+}
+
+func TestShouldFailParsingInvalidType_LogicalVolume(t *testing.T) {
+	invalidLogicalVolume := LogicalVolume{ID: "MyRoot", Name: "root", Type: "striped", FsType: "ext4"}
+	err := invalidLogicalVolume.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "invalid [Type]: invalid value for LogicalVolumeType (striped)", err.Error())
+}
+
+func TestShouldFailParsingMountableThinPool_LogicalVolume(t *testing.T) {
+	invalidLogicalVolume := LogicalVolume{ID: "MyPool", Name: "pool", Type: LogicalVolumeTypeThinPool, FsType: "ext4"}
+	err := invalidLogicalVolume.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "thin pool (pool) can't have an [ID] or a [FsType], as it can't be mounted", err.Error())
+}
+
+func TestShouldFailParsingThinVolumeWithoutPoolOrSize_LogicalVolume(t *testing.T) {
+	invalidLogicalVolume := LogicalVolume{ID: "MyData", Name: "data", Type: LogicalVolumeTypeThin, Size: 8192, FsType: "ext4"}
+	err := invalidLogicalVolume.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "thin logical volume (data) must have a [ThinPool]", err.Error())
+
+	invalidLogicalVolume = LogicalVolume{ID: "MyData", Name: "data", Type: LogicalVolumeTypeThin, FsType: "ext4", ThinPool: "pool"}
+	err = invalidLogicalVolume.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "thin logical volume (data) must have a [Size]", err.Error())
+}
+
+func TestShouldFailParsingThinPoolOnLinearVolume_LogicalVolume(t *testing.T) {
+	invalidLogicalVolume := LogicalVolume{ID: "MyRoot", Name: "root", Type: LogicalVolumeTypeLinear, FsType: "ext4", ThinPool: "pool"}
+	err := invalidLogicalVolume.IsValid()
+	assert.Error(t, err)
+	assert.Equal(t, "[ThinPool] is only valid for thin logical volumes, but logical volume (root) is (linear)", err.Error())
+}
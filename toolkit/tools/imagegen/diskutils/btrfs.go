@@ -0,0 +1,64 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+// Utility for btrfs subvolumes
+
+package diskutils
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/imagegen/configuration"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/logger"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/shell"
+	"golang.org/x/sys/unix"
+)
+
+// CreateBtrfsSubvolumes creates subvolumes in the top level of a btrfs file system
+// - devicePath is the device that the btrfs file system is on
+// - subvolumes are the names of the subvolumes to create
+func CreateBtrfsSubvolumes(devicePath string, subvolumes []string) (err error) {
+	if len(subvolumes) == 0 {
+		return
+	}
+
+	mountDir, err := os.MkdirTemp("", "btrfs-subvolumes-")
+	if err != nil {
+		err = fmt.Errorf("failed to create a temporary mount directory:\n%w", err)
+		return
+	}
+	defer os.Remove(mountDir)
+
+	// Note: safemount can't be used here since it depends on this package.
+	err = unix.Mount(devicePath, mountDir, configuration.BtrfsFsType, 0, "")
+	if err != nil {
+		err = fmt.Errorf("failed to mount btrfs file system (%s):\n%w", devicePath, err)
+		return
+	}
+
+	mounted := true
+	defer func() {
+		if mounted {
+			unix.Unmount(mountDir, unix.MNT_DETACH)
+		}
+	}()
+
+	for _, subvolume := range subvolumes {
+		_, stderr, err := shell.Execute("btrfs", "subvolume", "create", filepath.Join(mountDir, subvolume))
+		if err != nil {
+			return fmt.Errorf("failed to create btrfs subvolume (%s):\n%v\n%w", subvolume, stderr, err)
+		}
+	}
+
+	err = unix.Unmount(mountDir, 0)
+	if err != nil {
+		err = fmt.Errorf("failed to unmount btrfs file system (%s):\n%w", devicePath, err)
+		return
+	}
+	mounted = false
+
+	logger.Log.Infof("Created (%d) btrfs subvolumes on (%s)", len(subvolumes), devicePath)
+	return
+}
@@ -11,6 +11,7 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -263,8 +264,24 @@ func CreateSparseDisk(diskPath string, size uint64, perm os.FileMode) (err error
 
 // SetupLoopbackDevice creates a /dev/loop device for the given disk file
 func SetupLoopbackDevice(diskFilePath string) (devicePath string, err error) {
-	logger.Log.Debugf("Attaching Loopback: %v", diskFilePath)
-	stdout, stderr, err := shell.Execute("losetup", "--show", "-f", "-P", diskFilePath)
+	return setupLoopbackDeviceHelper(diskFilePath, false /*readOnly*/)
+}
+
+// SetupReadOnlyLoopbackDevice creates a read-only /dev/loop device for the given disk file
+func SetupReadOnlyLoopbackDevice(diskFilePath string) (devicePath string, err error) {
+	return setupLoopbackDeviceHelper(diskFilePath, true /*readOnly*/)
+}
+
+func setupLoopbackDeviceHelper(diskFilePath string, readOnly bool) (devicePath string, err error) {
+	logger.Log.Debugf("Attaching Loopback: %v (read-only: %v)", diskFilePath, readOnly)
+
+	args := []string{"--show", "-f", "-P"}
+	if readOnly {
+		args = append(args, "--read-only")
+	}
+	args = append(args, diskFilePath)
+
+	stdout, stderr, err := shell.Execute("losetup", args...)
 	if err != nil {
 		err = fmt.Errorf("failed to create loopback device using losetup:\n%v\n%w", stderr, err)
 		return
@@ -472,6 +489,7 @@ func CreatePartitions(diskDevPath string, disk configuration.Disk, rootEncryptio
 	}
 
 	// Partitions assumed to be defined in sorted order
+	var additionalDevices []EncryptedDevice
 	for idx, partition := range disk.Partitions {
 		partType, partitionNumber := obtainPartitionDetail(idx, usingExtendedPartition)
 		// Insert an extended partition
@@ -507,12 +525,37 @@ func CreatePartitions(diskDevPath string, disk configuration.Disk, rootEncryptio
 				return partDevPathMap, partIDToFsTypeMap, encryptedRoot, err
 			}
 			partDevPathMap[partition.ID] = GetEncryptedRootVolMapping()
+		} else if rootEncryption.Enable && slices.Contains(rootEncryption.AdditionalPartitions, partition.ID) {
+			var (
+				encryptedDevice EncryptedDevice
+				mappedDevPath   string
+			)
+			encryptedDevice, mappedDevPath, err = encryptPartition(partDevPath, partition, rootEncryption)
+			if err != nil {
+				err = fmt.Errorf("failed to encrypt partition (%s):\n%w", partition.ID, err)
+				return partDevPathMap, partIDToFsTypeMap, encryptedRoot, err
+			}
+			additionalDevices = append(additionalDevices, encryptedDevice)
+			partDevPathMap[partition.ID] = mappedDevPath
 		} else {
 			partDevPathMap[partition.ID] = partDevPath
 		}
 
+		err = CreateBtrfsSubvolumes(partDevPathMap[partition.ID], partition.Subvolumes)
+		if err != nil {
+			return partDevPathMap, partIDToFsTypeMap, encryptedRoot, err
+		}
+
 		partIDToFsTypeMap[partition.ID] = partFsType
 	}
+
+	encryptedRoot.AdditionalDevices = additionalDevices
+
+	err = CreateVolumeGroups(disk.VolumeGroups, partDevPathMap, partIDToFsTypeMap)
+	if err != nil {
+		err = fmt.Errorf("failed to create volume groups:\n%w", err)
+		return
+	}
 	return
 }
 
@@ -718,7 +761,15 @@ func InitializeSinglePartition(diskDevPath string, partitionNumber int,
 
 	// Set partition friendly name and partition type UUID (only for gpt)
 	if partitionTableType == configuration.PartitionTableTypeGpt {
-		setGptPartitionType(partition, timeoutInSeconds, diskDevPath, partitionNumberStr)
+		err = setGptPartitionType(partition, timeoutInSeconds, diskDevPath, partitionNumberStr)
+		if err != nil {
+			return "", err
+		}
+
+		err = setGptPartitionAttributes(partition, timeoutInSeconds, diskDevPath, partitionNumberStr)
+		if err != nil {
+			return "", err
+		}
 	}
 
 	// Set partition flags if necessary
@@ -765,28 +816,79 @@ func isDigit(c byte) bool {
 }
 
 func setGptPartitionType(partition configuration.Partition, timeoutInSeconds, diskDevPath, partitionNumberStr string) (err error) {
-	if supports, _ := PartedSupportsTypeCommand(); !supports {
-		logger.Log.Warn("parted version <3.6 does not support the 'type' session command - skipping this operation")
+	if partition.TypeUUID == "" && partition.Type == "" {
 		return
 	}
 
-	if partition.TypeUUID != "" || partition.Type != "" {
-		var typeUUID string
-		if partition.TypeUUID != "" {
-			typeUUID = partition.TypeUUID
-		} else {
-			typeUUID = configuration.PartitionTypeNameToUUID[partition.Type]
+	if partition.TypeUUID == "" {
+		// The type is derived from the partition's role. Failing to set it is not fatal.
+		if supports, _ := PartedSupportsTypeCommand(); !supports {
+			logger.Log.Warn("parted version <3.6 does not support the 'type' session command - skipping this operation")
+			return
 		}
+
+		typeUUID := configuration.PartitionTypeNameToUUID[partition.Type]
+		_, stderr, typeErr := shell.Execute("flock", "--timeout", timeoutInSeconds, diskDevPath, "parted", diskDevPath, "--script", "type", partitionNumberStr, typeUUID)
+		if typeErr != nil {
+			// Not-fatal
+			logger.Log.Warnf("failed to set partition type using parted: %v", stderr)
+		}
+		return
+	}
+
+	// The type UUID was explicitly requested. So, it must be set.
+	typeUUID := partition.TypeUUID
+
+	if supports, _ := PartedSupportsTypeCommand(); supports {
 		_, stderr, err := shell.Execute("flock", "--timeout", timeoutInSeconds, diskDevPath, "parted", diskDevPath, "--script", "type", partitionNumberStr, typeUUID)
 		if err != nil {
-			logger.Log.Warnf("failed to set partition type using parted: %v", stderr)
-			err = nil
-			// Not-fatal
+			return fmt.Errorf("failed to set partition type (%s) using parted:\n%v\n%w", typeUUID, stderr, err)
 		}
+		return nil
+	}
+
+	logger.Log.Debugf("parted version <3.6 does not support the 'type' session command - using sfdisk instead")
+
+	_, stderr, err := shell.Execute("flock", "--timeout", timeoutInSeconds, diskDevPath, "sfdisk", "--part-type", diskDevPath, partitionNumberStr, typeUUID)
+	if err != nil {
+		err = fmt.Errorf("failed to set partition type (%s) using sfdisk:\n%v\n%w", typeUUID, stderr, err)
+		return
 	}
 	return
 }
 
+func setGptPartitionAttributes(partition configuration.Partition, timeoutInSeconds, diskDevPath, partitionNumberStr string) (err error) {
+	if len(partition.GptAttributes) == 0 {
+		return
+	}
+
+	attributes := gptAttributesToSfdiskArg(partition.GptAttributes)
+	_, stderr, err := shell.Execute("flock", "--timeout", timeoutInSeconds, diskDevPath, "sfdisk", "--part-attrs", diskDevPath, partitionNumberStr, attributes)
+	if err != nil {
+		err = fmt.Errorf("failed to set partition attributes (%s) using sfdisk:\n%v\n%w", attributes, stderr, err)
+		return
+	}
+	return
+}
+
+// gptAttributesToSfdiskArg converts a list of GPT attribute bit numbers to the format accepted by 'sfdisk --part-attrs'.
+func gptAttributesToSfdiskArg(bits []uint) string {
+	attributes := []string(nil)
+	for _, bit := range bits {
+		switch bit {
+		case 0:
+			attributes = append(attributes, "RequiredPartition")
+		case 1:
+			attributes = append(attributes, "NoBlockIOProtocol")
+		case 2:
+			attributes = append(attributes, "LegacyBIOSBootable")
+		default:
+			attributes = append(attributes, fmt.Sprintf("GUID:%d", bit))
+		}
+	}
+	return strings.Join(attributes, ",")
+}
+
 // FormatSinglePartition formats the given partition to the type specified in the partition configuration
 func FormatSinglePartition(partDevPath string, partition configuration.Partition,
 ) (fsType string, err error) {
@@ -801,7 +903,7 @@ func FormatSinglePartition(partDevPath string, partition configuration.Partition
 	// This is due to a possible race condition in Linux/parted where the partition may not actually be ready after being newly created.
 	// To handle such cases, we can retry the command.
 	switch fsType {
-	case "fat32", "fat16", "vfat", "ext2", "ext3", "ext4", "xfs":
+	case "fat32", "fat16", "vfat", "ext2", "ext3", "ext4", "xfs", configuration.BtrfsFsType:
 		mkfsOptions := DefaultMkfsOptions[fsType]
 
 		if fsType == "fat32" || fsType == "fat16" {
@@ -46,3 +46,37 @@ func TestValidBlockDevicesOutputSizeVariance(t *testing.T) {
 	assert.NoError(t, err)
 	assert.EqualValues(t, expectedBlockDevicesOutput, blockDevices)
 }
+
+func TestGptAttributesToSfdiskArg(t *testing.T) {
+	arg := gptAttributesToSfdiskArg([]uint{0, 2, 59, 60})
+	assert.Equal(t, "RequiredPartition,LegacyBIOSBootable,GUID:59,GUID:60", arg)
+}
+
+func TestLogicalVolumeMapping(t *testing.T) {
+	mapping := GetLogicalVolumeMapping("data-vg", "var-log")
+	assert.Equal(t, "/dev/mapper/data--vg-var--log", mapping)
+
+	groupName, volumeName, ok := SplitLogicalVolumeMapping(mapping)
+	assert.True(t, ok)
+	assert.Equal(t, "data-vg", groupName)
+	assert.Equal(t, "var-log", volumeName)
+
+	groupName, volumeName, ok = SplitLogicalVolumeMapping("/dev/mapper/rootvg-root")
+	assert.True(t, ok)
+	assert.Equal(t, "rootvg", groupName)
+	assert.Equal(t, "root", volumeName)
+
+	assert.False(t, IsLogicalVolumeDevice("/dev/mapper/luks-1234"))
+	assert.False(t, IsLogicalVolumeDevice("/dev/mapper/novolume"))
+	assert.False(t, IsLogicalVolumeDevice("/dev/sda2"))
+}
+
+func TestRaidArrayDevicePath(t *testing.T) {
+	devicePath := GetRaidArrayDevicePath("root")
+	assert.Equal(t, "/dev/md/root", devicePath)
+	assert.True(t, IsRaidArrayDevice(devicePath))
+
+	assert.False(t, IsRaidArrayDevice("/dev/md/"))
+	assert.False(t, IsRaidArrayDevice("/dev/md0"))
+	assert.False(t, IsRaidArrayDevice("/dev/mapper/rootvg-root"))
+}
@@ -31,6 +31,27 @@ type EncryptedRootDevice struct {
 	Device      string
 	LuksUUID    string
 	HostKeyFile string
+	// The other encrypted partitions (see RootEncryption.AdditionalPartitions)
+	AdditionalDevices []EncryptedDevice
+	// Unlock the encrypted partitions with a TPM2 token instead of the keyfile
+	UnlockWithTPM2 bool
+}
+
+// EncryptedDevice holds settings for an encrypted partition that isn't the root
+type EncryptedDevice struct {
+	Device   string
+	LuksUUID string
+}
+
+// FindEncryptedDevice returns the encrypted partition that is mapped to a device path, or nil if the device path isn't
+// an additional encrypted partition
+func (e *EncryptedRootDevice) FindEncryptedDevice(mappedDevPath string) *EncryptedDevice {
+	for i, device := range e.AdditionalDevices {
+		if filepath.Join(mappingFilePath, GetLuksMappingName(device.LuksUUID)) == mappedDevPath {
+			return &e.AdditionalDevices[i]
+		}
+	}
+	return nil
 }
 
 // AddDefaultKeyfile adds a LUKS keyfile for initramfs unlock
@@ -44,21 +65,58 @@ func AddDefaultKeyfile(keyFileDir, devPath string, encrypt configuration.RootEnc
 		return
 	}
 
-	_, stderr, err := shell.ExecuteWithStdin(encrypt.Password, "cryptsetup", "luksAddKey", devPath, fullKeyPath)
+	err = AddKeyfile(devPath, fullKeyPath, encrypt)
 	if err != nil {
-		err = fmt.Errorf("failed to add keyfile to encrypted devce:\n%v\n%w", stderr, err)
 		return
 	}
 
 	err = os.Chmod(fullKeyPath, 000)
 	if err != nil {
-		err = fmt.Errorf("failed to change permissions on keyfile:\n%v\n%w", stderr, err)
+		err = fmt.Errorf("failed to change permissions on keyfile:\n%w", err)
 		return
 	}
 
 	return
 }
 
+// AddKeyfile adds a keyfile to the key slots of an encrypted LUKS device
+// - devPath is the path of the encrypted LUKS device
+// - keyFilePath is the keyfile to add
+// - encrypt is the encryption settings
+func AddKeyfile(devPath, keyFilePath string, encrypt configuration.RootEncryption) (err error) {
+	_, stderr, err := shell.ExecuteWithStdin(encrypt.Password, "cryptsetup", "luksAddKey", devPath, keyFilePath)
+	if err != nil {
+		err = fmt.Errorf("failed to add keyfile to encrypted devce:\n%v\n%w", stderr, err)
+		return
+	}
+
+	return
+}
+
+// EnrollTPM2 enrolls a TPM2 token in an encrypted LUKS2 device, with systemd-cryptenroll. The token is bound to the
+// TPM2 of the machine that runs the enrollment, so it only unlocks the device at boot if that is the machine that the
+// image is installed on (e.g. an ISO installer).
+// - devPath is the path of the encrypted LUKS device
+// - keyFilePath is a keyfile that unlocks the device
+// - tpm2 is the TPM2 settings
+func EnrollTPM2(devPath, keyFilePath string, tpm2 configuration.TPM2Unlock) (err error) {
+	cryptenrollArgs := []string{
+		"--tpm2-device=auto",
+		fmt.Sprintf("--tpm2-pcrs=%s", tpm2.GetPCRs()),
+		fmt.Sprintf("--unlock-key-file=%s", keyFilePath),
+		devPath,
+	}
+	_, stderr, err := shell.Execute("systemd-cryptenroll", cryptenrollArgs...)
+	if err != nil {
+		err = fmt.Errorf("failed to enroll a TPM2 token in encrypted device (%v) (is there a TPM2 device?):\n%v\n%w",
+			devPath, stderr, err)
+		return
+	}
+
+	logger.Log.Infof("Enrolled a TPM2 token in encrypted device (%v), bound to PCRs (%v)", devPath, tpm2.GetPCRs())
+	return
+}
+
 // CleanupEncryptedDisks performs cleanup work
 func CleanupEncryptedDisks(encryptedRoot EncryptedRootDevice, isOfflineInstall bool) (err error) {
 	err = deleteDefaultKeyFile(encryptedRoot.HostKeyFile)
@@ -119,25 +177,70 @@ func GetLuksMappingName(uuid string) (mappingName string) {
 // - partition is the configuration
 // - encrypt is the root encryption settings
 func encryptRootPartition(partDevPath string, partition configuration.Partition, encrypt configuration.RootEncryption) (encryptedRoot EncryptedRootDevice, err error) {
+	if encrypt.Enable == false {
+		err = fmt.Errorf("encryption not enabled for partition %v", partition.ID)
+		return
+	}
+
+	encryptedRoot.Device = partDevPath
+	encryptedRoot.UnlockWithTPM2 = encrypt.TPM2.Enable
+
+	uuid, mappedDevPath, err := formatAndOpenLuksPartition(partDevPath, partition, encrypt)
+	if err != nil {
+		return
+	}
+
+	encryptedRoot.LuksUUID = uuid
+
+	// Add the LVM
+	fullMappedPath, err := enableLVMForEncryptedRoot(mappedDevPath)
+	if err != nil {
+		err = fmt.Errorf("failed to enable LVM for encrypted root:\n%w", err)
+		return
+	}
+
+	err = mkfsEncryptedDevice(partDevPath, partition, fullMappedPath)
+	return
+}
+
+// encryptPartition encrypts a partition that isn't the root. Unlike the root, it has no LVM on top of LUKS.
+// - partDevPath is the path of the partition
+// - partition is the configuration
+// - encrypt is the root encryption settings
+func encryptPartition(partDevPath string, partition configuration.Partition, encrypt configuration.RootEncryption) (encryptedDevice EncryptedDevice, mappedDevPath string, err error) {
+	encryptedDevice.Device = partDevPath
+
+	encryptedDevice.LuksUUID, mappedDevPath, err = formatAndOpenLuksPartition(partDevPath, partition, encrypt)
+	if err != nil {
+		return
+	}
+
+	err = mkfsEncryptedDevice(partDevPath, partition, mappedDevPath)
+	return
+}
+
+// formatAndOpenLuksPartition encrypts a partition with LUKS and opens it under /dev/mapper. A TPM2 token requires
+// LUKS2, otherwise LUKS1 is used, since grub can unlock it.
+func formatAndOpenLuksPartition(partDevPath string, partition configuration.Partition, encrypt configuration.RootEncryption) (uuid string, mappedDevPath string, err error) {
 	const (
 		defaultCipher  = "aes-xts-plain64"
 		defaultKeySize = "256"
 		defaultHash    = "sha512"
 		defaultLuks    = "luks1"
+		tpm2Luks       = "luks2"
 	)
-	if encrypt.Enable == false {
-		err = fmt.Errorf("encryption not enabled for partition %v", partition.ID)
-		return
-	}
 
-	encryptedRoot.Device = partDevPath
+	luksType := defaultLuks
+	if encrypt.TPM2.Enable {
+		luksType = tpm2Luks
+	}
 
 	// Encrypt the partition
 	cryptsetupArgs := []string{
 		"--cipher", defaultCipher,
 		"--key-size", defaultKeySize,
 		"--hash", defaultHash,
-		"--type", defaultLuks,
+		"--type", luksType,
 		"luksFormat", partDevPath,
 	}
 	_, stderr, err := shell.ExecuteWithStdin(encrypt.Password, "cryptsetup", cryptsetupArgs...)
@@ -150,15 +253,13 @@ func encryptRootPartition(partDevPath string, partition configuration.Partition,
 	logger.Log.Infof("Encrypted partition %v", partition.ID)
 
 	// Open the partition
-	uuid, err := getPartUUID(partDevPath)
+	uuid, err = getPartUUID(partDevPath)
 	if err != nil || uuid == "" {
 		err = fmt.Errorf("failed to get UUID for partition (%v):\n%w", partDevPath, err)
 		return
 	}
 
-	encryptedRoot.LuksUUID = uuid
-
-	blockDevice := fmt.Sprintf("%v%v", mappingEncryptedPrefix, uuid)
+	blockDevice := GetLuksMappingName(uuid)
 
 	_, stderr, err = shell.ExecuteWithStdin(encrypt.Password, "cryptsetup", "-q", "open", partDevPath, blockDevice)
 	if err != nil {
@@ -166,23 +267,21 @@ func encryptRootPartition(partDevPath string, partition configuration.Partition,
 		return
 	}
 
-	// Add the LVM
-	fullMappedPath, err := enableLVMForEncryptedRoot(filepath.Join(mappingFilePath, blockDevice))
-	if err != nil {
-		err = fmt.Errorf("failed to enable LVM for encrypted root:\n%w", err)
-		return
-	}
+	mappedDevPath = filepath.Join(mappingFilePath, blockDevice)
+	return
+}
 
+func mkfsEncryptedDevice(partDevPath string, partition configuration.Partition, mappedDevPath string) (err error) {
 	mkfsOptions, ok := DefaultMkfsOptions[partition.FsType]
 	if !ok {
 		mkfsOptions = []string{}
 	}
 	mkfsArgs := []string{"-t", partition.FsType}
 	mkfsArgs = append(mkfsArgs, mkfsOptions...)
-	mkfsArgs = append(mkfsArgs, fullMappedPath)
+	mkfsArgs = append(mkfsArgs, mappedDevPath)
 
 	// Create the file system
-	_, stderr, err = shell.Execute("mkfs", mkfsArgs...)
+	_, stderr, err := shell.Execute("mkfs", mkfsArgs...)
 	if err != nil {
 		err = fmt.Errorf("failed to mkfs for partition (%v):\n%v\n%w", partDevPath, stderr, err)
 	}
@@ -8,7 +8,9 @@ package diskutils
 import (
 	"fmt"
 	"path/filepath"
+	"strings"
 
+	"github.com/microsoft/azurelinux/toolkit/tools/imagegen/configuration"
 	"github.com/microsoft/azurelinux/toolkit/tools/internal/logger"
 	"github.com/microsoft/azurelinux/toolkit/tools/internal/shell"
 )
@@ -33,6 +35,124 @@ func GetEncryptedRootVol() string {
 	return fmt.Sprintf("%s-%s", encryptGroupName, encryptVolName)
 }
 
+// GetLogicalVolumeMapping returns the device mapping path of a logical volume. Device mapper escapes the dashes in the
+// names by doubling them.
+func GetLogicalVolumeMapping(groupName, volumeName string) string {
+	escapedGroupName := strings.ReplaceAll(groupName, "-", "--")
+	escapedVolumeName := strings.ReplaceAll(volumeName, "-", "--")
+	return filepath.Join(mappingFilePath, fmt.Sprintf("%s-%s", escapedGroupName, escapedVolumeName))
+}
+
+// SplitLogicalVolumeMapping returns the volume group and the logical volume of a logical volume's device mapping path
+// (see GetLogicalVolumeMapping). ok is false if the device path isn't a logical volume's device mapping path.
+func SplitLogicalVolumeMapping(devicePath string) (groupName, volumeName string, ok bool) {
+	mappingName, found := strings.CutPrefix(devicePath, mappingFilePath)
+	if !found || strings.HasPrefix(mappingName, mappingEncryptedPrefix) {
+		return "", "", false
+	}
+
+	// The names are separated by the first dash that isn't doubled.
+	for i := 0; i < len(mappingName); i++ {
+		if mappingName[i] != '-' {
+			continue
+		}
+		if i+1 < len(mappingName) && mappingName[i+1] == '-' {
+			i++
+			continue
+		}
+
+		groupName = strings.ReplaceAll(mappingName[:i], "--", "-")
+		volumeName = strings.ReplaceAll(mappingName[i+1:], "--", "-")
+		return groupName, volumeName, groupName != "" && volumeName != ""
+	}
+
+	return "", "", false
+}
+
+// IsLogicalVolumeDevice checks if a given device is the device mapping path of an LVM logical volume
+// - devicePath is the device to check
+func IsLogicalVolumeDevice(devicePath string) bool {
+	_, _, ok := SplitLogicalVolumeMapping(devicePath)
+	return ok
+}
+
+// CreateVolumeGroups creates the volume groups of a disk on its partitions, and creates and formats their logical
+// volumes
+// - volumeGroups are the volume groups to create
+// - partDevPathMap maps the partition IDs to their device paths, and is updated with the logical volumes' IDs
+// - partIDToFsTypeMap maps the partition IDs to their file system types, and is updated with the logical volumes' IDs
+func CreateVolumeGroups(volumeGroups []configuration.VolumeGroup, partDevPathMap, partIDToFsTypeMap map[string]string,
+) (err error) {
+	for _, volumeGroup := range volumeGroups {
+		devicePaths := []string{}
+		for _, partID := range volumeGroup.PhysicalVolumes {
+			devicePath := partDevPathMap[partID]
+			err = createPhysicalVolume(devicePath)
+			if err != nil {
+				return
+			}
+			devicePaths = append(devicePaths, devicePath)
+		}
+
+		err = createVolumeGroup(volumeGroup.Name, devicePaths...)
+		if err != nil {
+			err = fmt.Errorf("failed to create volume group (%s):\n%w", volumeGroup.Name, err)
+			return
+		}
+
+		for _, logicalVolume := range volumeGroup.LogicalVolumes {
+			err = createConfiguredLogicalVolume(volumeGroup.Name, logicalVolume)
+			if err != nil {
+				err = fmt.Errorf("failed to create logical volume (%s) in volume group (%s):\n%w", logicalVolume.Name,
+					volumeGroup.Name, err)
+				return
+			}
+
+			if logicalVolume.Type == configuration.LogicalVolumeTypeThinPool {
+				continue
+			}
+
+			var fsType string
+			volumePath := GetLogicalVolumeMapping(volumeGroup.Name, logicalVolume.Name)
+			fsType, err = FormatSinglePartition(volumePath, configuration.Partition{ID: logicalVolume.ID, FsType: logicalVolume.FsType})
+			if err != nil {
+				err = fmt.Errorf("failed to format logical volume (%s):\n%w", logicalVolume.Name, err)
+				return
+			}
+
+			if logicalVolume.ID != "" {
+				partDevPathMap[logicalVolume.ID] = volumePath
+				partIDToFsTypeMap[logicalVolume.ID] = fsType
+			}
+		}
+
+		logger.Log.Infof("Created volume group (%s) with (%d) logical volumes", volumeGroup.Name, len(volumeGroup.LogicalVolumes))
+	}
+
+	return
+}
+
+// DeactivateVolumeGroups deactivates the volume groups of a disk, so that the disk can be detached
+// - volumeGroups are the volume groups to deactivate
+func DeactivateVolumeGroups(volumeGroups []configuration.VolumeGroup) (err error) {
+	if len(volumeGroups) == 0 {
+		return
+	}
+
+	vgchangeArgs := []string{"-a", "n"}
+	for _, volumeGroup := range volumeGroups {
+		vgchangeArgs = append(vgchangeArgs, volumeGroup.Name)
+	}
+
+	_, stderr, err := shell.Execute("vgchange", vgchangeArgs...)
+	if err != nil {
+		err = fmt.Errorf("failed to deactivate volume groups:\n%v\n%w", stderr, err)
+		return
+	}
+
+	return
+}
+
 func enableLVMForEncryptedRoot(devicePath string) (volumePath string, err error) {
 	const (
 		fullPhysicalVolume = "100%PVS"
@@ -70,8 +190,9 @@ func createPhysicalVolume(devicePath string) (err error) {
 	return
 }
 
-func createVolumeGroup(groupName, devicePath string) (err error) {
-	_, stderr, err := shell.Execute("vgcreate", "-qy", groupName, devicePath)
+func createVolumeGroup(groupName string, devicePaths ...string) (err error) {
+	vgcreateArgs := append([]string{"-qy", groupName}, devicePaths...)
+	_, stderr, err := shell.Execute("vgcreate", vgcreateArgs...)
 	if err != nil {
 		logger.Log.Warnf("Unable to create volume group %v: %v", groupName, stderr)
 		return
@@ -97,6 +218,41 @@ func createLogicalVolume(extents, groupName, volumeName string) (err error) {
 	return
 }
 
+// createConfiguredLogicalVolume creates a logical volume, or a thin pool, as configured.
+func createConfiguredLogicalVolume(groupName string, logicalVolume configuration.LogicalVolume) (err error) {
+	const (
+		fillVolumeGroup = "100%FREE"
+	)
+
+	lvCreateArgs := []string{"-y"}
+	switch logicalVolume.Type {
+	case configuration.LogicalVolumeTypeThin:
+		lvCreateArgs = append(lvCreateArgs,
+			"--type", "thin",
+			"--virtualsize", fmt.Sprintf("%dm", logicalVolume.Size),
+			"--thinpool", logicalVolume.ThinPool,
+		)
+	case configuration.LogicalVolumeTypeThinPool:
+		lvCreateArgs = append(lvCreateArgs, "--type", "thin-pool")
+		fallthrough
+	default:
+		if logicalVolume.Size == 0 {
+			lvCreateArgs = append(lvCreateArgs, "--extents", fillVolumeGroup)
+		} else {
+			lvCreateArgs = append(lvCreateArgs, "--size", fmt.Sprintf("%dm", logicalVolume.Size))
+		}
+	}
+	lvCreateArgs = append(lvCreateArgs, "-n", logicalVolume.Name, groupName)
+
+	_, stderr, err := shell.Execute("lvcreate", lvCreateArgs...)
+	if err != nil {
+		err = fmt.Errorf("failed to run lvcreate:\n%v\n%w", stderr, err)
+		return
+	}
+
+	return
+}
+
 func deactivateLVM() (err error) {
 	_, stderr, err := shell.Execute("vgchange", "-a", "n", encryptGroupName)
 	if err != nil {
@@ -0,0 +1,134 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+// Utility for software RAID arrays
+
+package diskutils
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/imagegen/configuration"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/logger"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/shell"
+)
+
+const (
+	raidDevicePath = "/dev/md/"
+
+	// MdadmConfPath is where the image's mdadm config is, which lists the RAID arrays to assemble at boot
+	MdadmConfPath = "/etc/mdadm.conf"
+)
+
+// GetRaidArrayDevicePath returns the device path of a RAID array
+func GetRaidArrayDevicePath(name string) string {
+	return filepath.Join(raidDevicePath, name)
+}
+
+// IsRaidArrayDevice checks if a given device is the device path of a RAID array
+// - devicePath is the device to check
+func IsRaidArrayDevice(devicePath string) bool {
+	name, found := strings.CutPrefix(devicePath, raidDevicePath)
+	return found && name != ""
+}
+
+// CreateRaidArrays creates the RAID arrays on their devices, and formats them
+// - raidArrays are the RAID arrays to create
+// - partDevPathMap maps the partition IDs to their device paths, and is updated with the RAID arrays' IDs
+// - partIDToFsTypeMap maps the partition IDs to their file system types, and is updated with the RAID arrays' IDs
+// Returns the contents of an mdadm config that assembles the RAID arrays.
+func CreateRaidArrays(raidArrays []configuration.RaidArray, partDevPathMap, partIDToFsTypeMap map[string]string,
+) (mdadmConf string, err error) {
+	for _, raidArray := range raidArrays {
+		var (
+			arrayUUID string
+			fsType    string
+		)
+
+		devicePath := GetRaidArrayDevicePath(raidArray.Name)
+		err = createRaidArray(devicePath, raidArray, partDevPathMap)
+		if err != nil {
+			err = fmt.Errorf("failed to create RAID array (%s):\n%w", raidArray.Name, err)
+			return
+		}
+
+		arrayUUID, err = getRaidArrayUUID(devicePath)
+		if err != nil {
+			return
+		}
+		mdadmConf += fmt.Sprintf("ARRAY %s metadata=1.2 UUID=%s\n", devicePath, arrayUUID)
+
+		fsType, err = FormatSinglePartition(devicePath, configuration.Partition{ID: raidArray.ID, FsType: raidArray.FsType})
+		if err != nil {
+			err = fmt.Errorf("failed to format RAID array (%s):\n%w", raidArray.Name, err)
+			return
+		}
+
+		partDevPathMap[raidArray.ID] = devicePath
+		partIDToFsTypeMap[raidArray.ID] = fsType
+
+		logger.Log.Infof("Created RAID array (%s) (%s) on (%d) devices", raidArray.Name, raidArray.Level, len(raidArray.Devices))
+	}
+
+	return
+}
+
+// StopRaidArrays stops the RAID arrays, so that the disks that they are on can be detached
+// - raidArrays are the RAID arrays to stop
+func StopRaidArrays(raidArrays []configuration.RaidArray) (err error) {
+	for _, raidArray := range raidArrays {
+		_, stderr, err := shell.Execute("mdadm", "--stop", GetRaidArrayDevicePath(raidArray.Name))
+		if err != nil {
+			return fmt.Errorf("failed to stop RAID array (%s):\n%v\n%w", raidArray.Name, stderr, err)
+		}
+	}
+
+	return
+}
+
+func createRaidArray(devicePath string, raidArray configuration.RaidArray, partDevPathMap map[string]string) (err error) {
+	// The home host is 'any', so that the array is assembled with the same name on the machine that the image boots on.
+	mdadmArgs := []string{
+		"--create", devicePath,
+		"--run",
+		"--metadata=1.2",
+		"--homehost=any",
+		fmt.Sprintf("--level=%s", raidArray.Level),
+		fmt.Sprintf("--raid-devices=%d", len(raidArray.Devices)),
+	}
+	for _, partID := range raidArray.Devices {
+		mdadmArgs = append(mdadmArgs, partDevPathMap[partID])
+	}
+
+	_, stderr, err := shell.Execute("mdadm", mdadmArgs...)
+	if err != nil {
+		err = fmt.Errorf("failed to run mdadm:\n%v\n%w", stderr, err)
+		return
+	}
+
+	return
+}
+
+func getRaidArrayUUID(devicePath string) (arrayUUID string, err error) {
+	const (
+		uuidKey = "MD_UUID="
+	)
+
+	stdout, stderr, err := shell.Execute("mdadm", "--detail", "--export", devicePath)
+	if err != nil {
+		err = fmt.Errorf("failed to get the details of RAID array (%s):\n%v\n%w", devicePath, stderr, err)
+		return
+	}
+
+	for _, line := range strings.Split(stdout, "\n") {
+		value, found := strings.CutPrefix(strings.TrimSpace(line), uuidKey)
+		if found {
+			return value, nil
+		}
+	}
+
+	err = fmt.Errorf("failed to find the UUID of RAID array (%s)", devicePath)
+	return
+}
@@ -0,0 +1,152 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package installutils
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/file"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/safechroot"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/shell"
+)
+
+// caAnchorsDir is where update-ca-trust picks up the certificates that the system trusts, in addition to the
+// ca-certificates package's.
+const caAnchorsDir = "/etc/pki/ca-trust/source/anchors"
+
+// caCertificateFileExtensions are the extensions of the PEM files that are picked up from a directory of
+// certificates.
+var caCertificateFileExtensions = []string{".pem", ".crt"}
+
+// installCACertificates adds the CA certificates, from PEM files or directories of PEM files, to the system's trust
+// store.
+func installCACertificates(installChroot *safechroot.Chroot, caCertificates []string) (err error) {
+	const (
+		certificateFileMode = 0644
+		squashErrors        = false
+	)
+
+	if len(caCertificates) == 0 {
+		return
+	}
+
+	ReportAction("Adding CA certificates to the trust store")
+
+	certificateFiles, err := getCACertificateFiles(caCertificates)
+	if err != nil {
+		return
+	}
+
+	fileNames := make(map[string]string)
+	for _, certificateFile := range certificateFiles {
+		err = checkCACertificateFile(certificateFile)
+		if err != nil {
+			return
+		}
+
+		fileName := filepath.Base(certificateFile)
+		if otherFile, found := fileNames[fileName]; found {
+			return fmt.Errorf("CA certificates (%s) and (%s) have the same file name", otherFile, certificateFile)
+		}
+		fileNames[fileName] = certificateFile
+
+		fileMode := os.FileMode(certificateFileMode)
+		err = installChroot.AddFiles(safechroot.FileToCopy{
+			Src:         certificateFile,
+			Dest:        filepath.Join(caAnchorsDir, fileName),
+			Permissions: &fileMode,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to copy CA certificate (%s):\n%w", certificateFile, err)
+		}
+	}
+
+	err = installChroot.UnsafeRun(func() error {
+		return shell.ExecuteLive(squashErrors, "update-ca-trust", "extract")
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update the trust store:\n%w", err)
+	}
+
+	return
+}
+
+// getCACertificateFiles returns the CA certificate files, with the directories replaced by their PEM files, in a
+// stable order.
+func getCACertificateFiles(caCertificates []string) (certificateFiles []string, err error) {
+	for _, caCertificate := range caCertificates {
+		isDir, err := file.IsDir(caCertificate)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find CA certificate (%s):\n%w", caCertificate, err)
+		}
+		if !isDir {
+			certificateFiles = append(certificateFiles, caCertificate)
+			continue
+		}
+
+		dirFiles, err := file.EnumerateDirFiles(caCertificate)
+		if err != nil {
+			return nil, err
+		}
+
+		var dirCertificateFiles []string
+		for _, dirFile := range dirFiles {
+			for _, extension := range caCertificateFileExtensions {
+				if strings.HasSuffix(dirFile, extension) {
+					dirCertificateFiles = append(dirCertificateFiles, dirFile)
+					break
+				}
+			}
+		}
+		if len(dirCertificateFiles) == 0 {
+			return nil, fmt.Errorf("CA certificate directory (%s) has no (%s) files", caCertificate,
+				strings.Join(caCertificateFileExtensions, ", "))
+		}
+
+		sort.Strings(dirCertificateFiles)
+		certificateFiles = append(certificateFiles, dirCertificateFiles...)
+	}
+
+	return
+}
+
+// checkCACertificateFile returns an error if the file isn't a PEM file of one or more certificates. update-ca-trust
+// silently skips the files that it can't read.
+func checkCACertificateFile(certificateFile string) (err error) {
+	data, err := os.ReadFile(certificateFile)
+	if err != nil {
+		return fmt.Errorf("failed to read CA certificate (%s):\n%w", certificateFile, err)
+	}
+
+	certificates := 0
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			return fmt.Errorf("CA certificate (%s) has a (%s) block, and may only have certificates", certificateFile,
+				block.Type)
+		}
+
+		_, err = x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return fmt.Errorf("failed to parse CA certificate (%s):\n%w", certificateFile, err)
+		}
+		certificates++
+	}
+
+	if certificates == 0 {
+		return fmt.Errorf("CA certificate (%s) isn't a PEM file of certificates", certificateFile)
+	}
+
+	return
+}
@@ -0,0 +1,100 @@
+// Copyright Microsoft Corporation.
+// Licensed under the MIT License.
+
+package installutils
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestMain found in installutils_test.go.
+
+// writeTestCACertificate writes a self-signed CA certificate into a PEM file.
+func writeTestCACertificate(t *testing.T, path string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Contoso Root CA"},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	certificate, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	err = os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certificate}), 0644)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+}
+
+func TestGetCACertificateFiles(t *testing.T) {
+	certificatesDir := t.TempDir()
+	for _, fileName := range []string{"b.crt", "a.pem", "README"} {
+		assert.NoError(t, os.WriteFile(filepath.Join(certificatesDir, fileName), nil, 0644))
+	}
+	certificateFile := filepath.Join(t.TempDir(), "contoso-root.pem")
+	assert.NoError(t, os.WriteFile(certificateFile, nil, 0644))
+
+	certificateFiles, err := getCACertificateFiles([]string{certificateFile, certificatesDir})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		certificateFile,
+		filepath.Join(certificatesDir, "a.pem"),
+		filepath.Join(certificatesDir, "b.crt"),
+	}, certificateFiles)
+}
+
+func TestGetCACertificateFilesFromDirWithoutCertificates(t *testing.T) {
+	certificatesDir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(certificatesDir, "README"), nil, 0644))
+
+	_, err := getCACertificateFiles([]string{certificatesDir})
+	assert.Error(t, err)
+	assert.Equal(t, "CA certificate directory ("+certificatesDir+") has no (.pem, .crt) files", err.Error())
+}
+
+func TestCheckCACertificateFile(t *testing.T) {
+	certificateFile := filepath.Join(t.TempDir(), "contoso-root.pem")
+	writeTestCACertificate(t, certificateFile)
+
+	assert.NoError(t, checkCACertificateFile(certificateFile))
+}
+
+func TestCheckCACertificateFileWithoutCertificates(t *testing.T) {
+	certificateFile := filepath.Join(t.TempDir(), "contoso-root.pem")
+	assert.NoError(t, os.WriteFile(certificateFile, []byte("not a certificate\n"), 0644))
+
+	err := checkCACertificateFile(certificateFile)
+	assert.Error(t, err)
+	assert.Equal(t, "CA certificate ("+certificateFile+") isn't a PEM file of certificates", err.Error())
+}
+
+func TestCheckCACertificateFileWithPrivateKey(t *testing.T) {
+	certificateFile := filepath.Join(t.TempDir(), "contoso-root.pem")
+	key := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: []byte("key")})
+	assert.NoError(t, os.WriteFile(certificateFile, key, 0644))
+
+	err := checkCACertificateFile(certificateFile)
+	assert.Error(t, err)
+	assert.Equal(t, "CA certificate ("+certificateFile+") has a (PRIVATE KEY) block, and may only have certificates", err.Error())
+}
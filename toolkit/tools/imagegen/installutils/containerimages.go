@@ -0,0 +1,194 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package installutils
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/imagegen/configuration"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/file"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/logger"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/resources"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/retry"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/safechroot"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/shell"
+)
+
+const (
+	// containerImageArchiveDir is where the pulled images are stored in the image, until they are imported on the
+	// first boot.
+	containerImageArchiveDir = "/var/lib/container-images/preload"
+
+	// SkopeoPackage is the package of the tool that pulls the container images, which must be installed in the
+	// environment that builds the image.
+	SkopeoPackage = "skopeo"
+)
+
+// unsafeArchiveNameChars are the characters of image references that aren't used in the names of their archives.
+var unsafeArchiveNameChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// preloadContainerImages pulls the container images into archives in the image, and installs and enables a service
+// that imports them into the container runtime on the first boot.
+func preloadContainerImages(installChroot *safechroot.Chroot, containerImages configuration.ContainerImages) (err error) {
+	const (
+		assetScriptFile   = "assets/containerimages/container-image-preload.sh"
+		assetServiceFile  = "assets/containerimages/container-image-preload.service"
+		scriptFile        = "usr/libexec/container-image-preload"
+		serviceFile       = "usr/lib/systemd/system/container-image-preload.service"
+		serviceName       = "container-image-preload.service"
+		runtimeKey        = "{{.Runtime}}"
+		runtimeServiceKey = "{{.RuntimeService}}"
+		namespaceKey      = "{{.Namespace}}"
+		archiveDirMode    = 0700
+		archiveFileMode   = 0600
+		scriptFileMode    = 0755
+		systemDirMode     = 0755
+		squashErrors      = false
+	)
+	var (
+		cmdline        configuration.KernelCommandLine
+		registriesConf string
+		namespace      string
+	)
+
+	if containerImages.IsEmpty() {
+		return
+	}
+
+	ReportAction("Preloading container images")
+
+	if len(containerImages.RegistryMirrors) > 0 {
+		var tempDir string
+		tempDir, err = os.MkdirTemp("", "containerimages")
+		if err != nil {
+			return fmt.Errorf("failed to create a directory for the registries config:\n%w", err)
+		}
+		defer os.RemoveAll(tempDir)
+
+		registriesConf = filepath.Join(tempDir, "registries.conf")
+		err = file.Write(renderRegistriesConf(containerImages.RegistryMirrors), registriesConf)
+		if err != nil {
+			return fmt.Errorf("failed to write the registries config:\n%w", err)
+		}
+	}
+
+	archiveDir := filepath.Join(installChroot.RootDir(), containerImageArchiveDir)
+	err = os.MkdirAll(archiveDir, archiveDirMode)
+	if err != nil {
+		return fmt.Errorf("failed to create (%s):\n%w", containerImageArchiveDir, err)
+	}
+
+	for i, image := range containerImages.Images {
+		archivePath := filepath.Join(archiveDir, getContainerImageArchiveName(i, image.Reference))
+		args := getSkopeoCopyArgs(image, archivePath, containerImages.AuthFile, registriesConf)
+
+		logger.Log.Infof("Pulling container image (%s)", image.Reference)
+		_, err = retry.RunWithDefaultDownloadBackoff(context.Background(), func() error {
+			// skopeo doesn't overwrite an archive that a failed attempt left behind.
+			os.Remove(archivePath)
+			_, stderr, err := shell.Execute("skopeo", args...)
+			if err != nil {
+				return fmt.Errorf("%v\n%w", stderr, err)
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to pull container image (%s):\n%w", image.Reference, err)
+		}
+
+		err = os.Chmod(archivePath, archiveFileMode)
+		if err != nil {
+			return fmt.Errorf("failed to set the permissions of the archive of (%s):\n%w", image.Reference, err)
+		}
+	}
+
+	err = file.CopyResourceFile(resources.ResourcesFS, assetScriptFile, filepath.Join(installChroot.RootDir(), scriptFile),
+		systemDirMode, scriptFileMode)
+	if err != nil {
+		return fmt.Errorf("failed to install (%s):\n%w", scriptFile, err)
+	}
+
+	serviceFinalPath := filepath.Join(installChroot.RootDir(), serviceFile)
+	err = file.CopyResourceFile(resources.ResourcesFS, assetServiceFile, serviceFinalPath, systemDirMode,
+		bootUsrConfigFileMode)
+	if err != nil {
+		return fmt.Errorf("failed to install (%s):\n%w", serviceFile, err)
+	}
+
+	containerRuntime := containerImages.GetRuntime()
+	if containerRuntime == configuration.ContainerRuntimeContainerd {
+		namespace = containerImages.GetNamespace()
+	}
+
+	for key, value := range map[string]string{
+		runtimeKey:        containerRuntime,
+		runtimeServiceKey: containerRuntime + ".service",
+		namespaceKey:      namespace,
+	} {
+		err = sed(key, value, cmdline.GetSedDelimeter(), serviceFinalPath)
+		if err != nil {
+			return fmt.Errorf("failed to set (%s) in (%s):\n%w", key, serviceFile, err)
+		}
+	}
+
+	err = installChroot.UnsafeRun(func() error {
+		return shell.ExecuteLive(squashErrors, "systemctl", "enable", serviceName)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to enable (%s):\n%w", serviceName, err)
+	}
+
+	return
+}
+
+// getSkopeoCopyArgs returns the skopeo arguments that pull an image into a docker archive, which both containerd and
+// docker can import, and which keeps the image's reference.
+func getSkopeoCopyArgs(image configuration.ContainerImage, archivePath, authFile, registriesConf string) (args []string) {
+	if registriesConf != "" {
+		args = append(args, "--registries-conf", registriesConf)
+	}
+	if image.Architecture != "" {
+		args = append(args, "--override-arch", image.Architecture)
+	}
+
+	args = append(args, "copy")
+	if authFile != "" {
+		args = append(args, "--authfile", authFile)
+	}
+
+	// A docker archive can't be tagged with a digest, so it's only tagged with the image's tag.
+	tag, _, _ := strings.Cut(image.Reference, "@")
+
+	args = append(args, "docker://"+image.Reference, fmt.Sprintf("docker-archive:%s:%s", archivePath, tag))
+	return
+}
+
+// getContainerImageArchiveName returns the name of the archive of an image, which is prefixed with its index, so that
+// the archives of different images never have the same name.
+func getContainerImageArchiveName(index int, reference string) string {
+	return fmt.Sprintf("%03d-%s.tar", index, unsafeArchiveNameChars.ReplaceAllString(reference, "_"))
+}
+
+// renderRegistriesConf renders a containers-registries.conf file, which pulls the images of each registry from its
+// mirrors first.
+func renderRegistriesConf(registryMirrors []configuration.RegistryMirror) string {
+	var builder strings.Builder
+
+	for i, registryMirror := range registryMirrors {
+		if i > 0 {
+			builder.WriteString("\n")
+		}
+		fmt.Fprintf(&builder, "[[registry]]\nprefix = %q\nlocation = %q\n", registryMirror.Registry, registryMirror.Registry)
+		for _, mirror := range registryMirror.Mirrors {
+			fmt.Fprintf(&builder, "\n[[registry.mirror]]\nlocation = %q\n", mirror)
+		}
+	}
+
+	return builder.String()
+}
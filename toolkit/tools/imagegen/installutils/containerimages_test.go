@@ -0,0 +1,67 @@
+// Copyright Microsoft Corporation.
+// Licensed under the MIT License.
+
+package installutils
+
+import (
+	"testing"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/imagegen/configuration"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestMain found in installutils_test.go.
+
+func TestGetSkopeoCopyArgs(t *testing.T) {
+	args := getSkopeoCopyArgs(configuration.ContainerImage{Reference: "mcr.microsoft.com/azurelinux/base/core:3.0"},
+		"/installroot/var/lib/container-images/preload/000-core.tar", "", "")
+	assert.Equal(t, []string{
+		"copy",
+		"docker://mcr.microsoft.com/azurelinux/base/core:3.0",
+		"docker-archive:/installroot/var/lib/container-images/preload/000-core.tar:mcr.microsoft.com/azurelinux/base/core:3.0",
+	}, args)
+
+	const digestReference = "docker.io/library/nginx:1.27@sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	args = getSkopeoCopyArgs(configuration.ContainerImage{Reference: digestReference, Architecture: "arm64"},
+		"/nginx.tar", "/tmp/containerimages/auth.json", "/tmp/registries.conf")
+	assert.Equal(t, []string{
+		"--registries-conf", "/tmp/registries.conf",
+		"--override-arch", "arm64",
+		"copy",
+		"--authfile", "/tmp/containerimages/auth.json",
+		"docker://" + digestReference,
+		"docker-archive:/nginx.tar:docker.io/library/nginx:1.27",
+	}, args)
+}
+
+func TestGetContainerImageArchiveName(t *testing.T) {
+	assert.Equal(t, "000-mcr.microsoft.com_azurelinux_base_core_3.0.tar",
+		getContainerImageArchiveName(0, "mcr.microsoft.com/azurelinux/base/core:3.0"))
+	assert.Equal(t, "012-localhost_5000_edge_agent_v2.tar", getContainerImageArchiveName(12, "localhost:5000/edge/agent:v2"))
+}
+
+func TestRenderRegistriesConf(t *testing.T) {
+	registriesConf := renderRegistriesConf([]configuration.RegistryMirror{
+		{Registry: "docker.io", Mirrors: []string{"mirror.contoso.com/docker.io", "backup.contoso.com"}},
+		{Registry: "mcr.microsoft.com", Mirrors: []string{"mirror.contoso.com/mcr"}},
+	})
+
+	assert.Equal(t, `[[registry]]
+prefix = "docker.io"
+location = "docker.io"
+
+[[registry.mirror]]
+location = "mirror.contoso.com/docker.io"
+
+[[registry.mirror]]
+location = "backup.contoso.com"
+
+[[registry]]
+prefix = "mcr.microsoft.com"
+location = "mcr.microsoft.com"
+
+[[registry.mirror]]
+location = "mirror.contoso.com/mcr"
+`, registriesConf)
+}
@@ -0,0 +1,162 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package installutils
+
+import (
+	"fmt"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/imagegen/configuration"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/file"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/safechroot"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/shell"
+)
+
+const (
+	firewallRulesetFile = "/etc/nftables/firewall.nft"
+	// nftablesConfFile is the file that nftables.service loads.
+	nftablesConfFile = "/etc/sysconfig/nftables.conf"
+
+	// The nftables package only lets root read its config.
+	nftablesFileMode = 0600
+	nftablesDirMode  = 0700
+)
+
+// installFirewall writes the firewall's nftables ruleset, includes it in the config that nftables.service loads, and
+// enables the service.
+func installFirewall(installChroot *safechroot.Chroot, firewall configuration.Firewall) (err error) {
+	const (
+		serviceName  = "nftables.service"
+		squashErrors = false
+	)
+
+	if !firewall.Enable {
+		return
+	}
+
+	ReportAction("Configuring the firewall")
+
+	rulesetPath := filepath.Join(installChroot.RootDir(), firewallRulesetFile)
+	err = os.MkdirAll(filepath.Dir(rulesetPath), nftablesDirMode)
+	if err != nil {
+		return fmt.Errorf("failed to create the directory of (%s):\n%w", firewallRulesetFile, err)
+	}
+
+	err = file.WriteWithPerm(renderFirewallRuleset(firewall), rulesetPath, nftablesFileMode)
+	if err != nil {
+		return fmt.Errorf("failed to write (%s):\n%w", firewallRulesetFile, err)
+	}
+
+	err = file.Append(fmt.Sprintf("\ninclude \"%s\"\n", firewallRulesetFile), filepath.Join(installChroot.RootDir(), nftablesConfFile))
+	if err != nil {
+		return fmt.Errorf("failed to include the firewall's ruleset in (%s):\n%w", nftablesConfFile, err)
+	}
+
+	err = installChroot.UnsafeRun(func() error {
+		return shell.ExecuteLive(squashErrors, "systemctl", "enable", serviceName)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to enable (%s):\n%w", serviceName, err)
+	}
+
+	return
+}
+
+// renderFirewallRuleset renders the firewall into an nftables ruleset, with an "inet firewall" table that is replaced
+// whenever the ruleset is loaded.
+func renderFirewallRuleset(firewall configuration.Firewall) string {
+	var builder strings.Builder
+
+	builder.WriteString("#!/usr/sbin/nft -f\n\n")
+	builder.WriteString("table inet firewall\n")
+	builder.WriteString("delete table inet firewall\n\n")
+	builder.WriteString("table inet firewall {\n")
+	builder.WriteString("\tchain input {\n")
+	fmt.Fprintf(&builder, "\t\ttype filter hook input priority filter; policy %s;\n\n", firewall.GetInputPolicy())
+	builder.WriteString("\t\tct state established,related accept\n")
+	builder.WriteString("\t\tct state invalid drop\n")
+	builder.WriteString("\t\tiif \"lo\" accept\n")
+	builder.WriteString("\t\tmeta l4proto { icmp, ipv6-icmp } accept\n")
+
+	writeFirewallPortRules(&builder, firewall.OpenPorts)
+
+	for _, zone := range firewall.Zones {
+		chain := firewallZoneChain(zone.Name)
+		if len(zone.Interfaces) > 0 {
+			quoted := make([]string, len(zone.Interfaces))
+			for i, iface := range zone.Interfaces {
+				quoted[i] = fmt.Sprintf("%q", iface)
+			}
+			fmt.Fprintf(&builder, "\t\tiifname %s jump %s\n", nftablesSet(quoted), chain)
+		}
+
+		var ipv4Sources, ipv6Sources []string
+		for _, source := range zone.Sources {
+			if isIPv4Source(source) {
+				ipv4Sources = append(ipv4Sources, source)
+			} else {
+				ipv6Sources = append(ipv6Sources, source)
+			}
+		}
+		if len(ipv4Sources) > 0 {
+			fmt.Fprintf(&builder, "\t\tip saddr %s jump %s\n", nftablesSet(ipv4Sources), chain)
+		}
+		if len(ipv6Sources) > 0 {
+			fmt.Fprintf(&builder, "\t\tip6 saddr %s jump %s\n", nftablesSet(ipv6Sources), chain)
+		}
+	}
+
+	for _, rule := range firewall.Rules {
+		fmt.Fprintf(&builder, "\t\t%s\n", rule)
+	}
+	builder.WriteString("\t}\n")
+
+	for _, zone := range firewall.Zones {
+		fmt.Fprintf(&builder, "\n\tchain %s {\n", firewallZoneChain(zone.Name))
+		writeFirewallPortRules(&builder, zone.OpenPorts)
+		builder.WriteString("\t}\n")
+	}
+	builder.WriteString("}\n")
+
+	return builder.String()
+}
+
+// writeFirewallPortRules writes a rule that accepts the open ports of each protocol.
+func writeFirewallPortRules(builder *strings.Builder, openPorts []string) {
+	portsByProtocol := make(map[string][]string)
+	for _, openPort := range openPorts {
+		// The ports are checked when the config is parsed.
+		port, _ := configuration.ParseFirewallPort(openPort)
+		portsByProtocol[port.Protocol] = append(portsByProtocol[port.Protocol], port.String())
+	}
+
+	for _, protocol := range []string{"tcp", "udp"} {
+		if len(portsByProtocol[protocol]) > 0 {
+			fmt.Fprintf(builder, "\t\t%s dport %s accept\n", protocol, nftablesSet(portsByProtocol[protocol]))
+		}
+	}
+}
+
+// nftablesSet returns a single value as it is, and multiple values as an anonymous set.
+func nftablesSet(values []string) string {
+	if len(values) == 1 {
+		return values[0]
+	}
+	return fmt.Sprintf("{ %s }", strings.Join(values, ", "))
+}
+
+func firewallZoneChain(zoneName string) string {
+	return "zone_" + zoneName
+}
+
+func isIPv4Source(source string) bool {
+	if prefix, err := netip.ParsePrefix(source); err == nil {
+		return prefix.Addr().Is4()
+	}
+	addr, err := netip.ParseAddr(source)
+	return err == nil && addr.Is4()
+}
@@ -0,0 +1,67 @@
+// Copyright Microsoft Corporation.
+// Licensed under the MIT License.
+
+package installutils
+
+import (
+	"testing"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/imagegen/configuration"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestMain found in installutils_test.go.
+
+func TestRenderFirewallRuleset(t *testing.T) {
+	firewall := configuration.Firewall{
+		Enable:    true,
+		OpenPorts: []string{"22/tcp", "53/udp", "443/tcp"},
+		Zones: []configuration.FirewallZone{
+			{
+				Name:       "management",
+				Interfaces: []string{"eth1", "eth2"},
+				Sources:    []string{"10.0.0.0/8", "fd00::/64"},
+				OpenPorts:  []string{"9090/tcp", "8000-8080/udp"},
+			},
+		},
+		Rules: []string{"tcp dport 25 reject"},
+	}
+
+	assert.Equal(t, `#!/usr/sbin/nft -f
+
+table inet firewall
+delete table inet firewall
+
+table inet firewall {
+	chain input {
+		type filter hook input priority filter; policy drop;
+
+		ct state established,related accept
+		ct state invalid drop
+		iif "lo" accept
+		meta l4proto { icmp, ipv6-icmp } accept
+		tcp dport { 22, 443 } accept
+		udp dport 53 accept
+		iifname { "eth1", "eth2" } jump zone_management
+		ip saddr 10.0.0.0/8 jump zone_management
+		ip6 saddr fd00::/64 jump zone_management
+		tcp dport 25 reject
+	}
+
+	chain zone_management {
+		tcp dport 9090 accept
+		udp dport 8000-8080 accept
+	}
+}
+`, renderFirewallRuleset(firewall))
+}
+
+func TestRenderFirewallRulesetAcceptPolicy(t *testing.T) {
+	firewall := configuration.Firewall{
+		Enable:      true,
+		InputPolicy: configuration.FirewallPolicyAccept,
+	}
+
+	assert.Contains(t, renderFirewallRuleset(firewall), "type filter hook input priority filter; policy accept;\n")
+}
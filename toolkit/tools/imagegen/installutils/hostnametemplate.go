@@ -0,0 +1,75 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package installutils
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/imagegen/configuration"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/file"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/resources"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/safechroot"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/shell"
+)
+
+// installHostnameTemplateService installs and enables a service that fills in the hostname template on the first boot,
+// once the machine ID and the serial number are known, and sets it as the static hostname. The service only runs
+// while there's no /etc/hostname, which it writes.
+func installHostnameTemplateService(installChroot *safechroot.Chroot, hostnameTemplate string) (err error) {
+	const (
+		assetScriptFile  = "assets/hostname/hostname-template.sh"
+		assetServiceFile = "assets/hostname/hostname-template.service"
+		scriptFile       = "usr/libexec/hostname-template"
+		serviceFile      = "usr/lib/systemd/system/hostname-template.service"
+		serviceName      = "hostname-template.service"
+		hostnameFile     = "etc/hostname"
+		templateKey      = "{{.Template}}"
+		scriptFileMode   = 0755
+		systemDirMode    = 0755
+		squashErrors     = false
+	)
+	var cmdline configuration.KernelCommandLine
+
+	ReportAction("Configuring the hostname template")
+
+	err = file.RemoveFileIfExists(filepath.Join(installChroot.RootDir(), hostnameFile))
+	if err != nil {
+		return fmt.Errorf("failed to remove (%s):\n%w", hostnameFile, err)
+	}
+
+	err = file.CopyResourceFile(resources.ResourcesFS, assetScriptFile, filepath.Join(installChroot.RootDir(), scriptFile),
+		systemDirMode, scriptFileMode)
+	if err != nil {
+		return fmt.Errorf("failed to install (%s):\n%w", scriptFile, err)
+	}
+
+	serviceFinalPath := filepath.Join(installChroot.RootDir(), serviceFile)
+	err = file.CopyResourceFile(resources.ResourcesFS, assetServiceFile, serviceFinalPath, systemDirMode,
+		bootUsrConfigFileMode)
+	if err != nil {
+		return fmt.Errorf("failed to install (%s):\n%w", serviceFile, err)
+	}
+
+	err = sed(templateKey, escapeSystemdSpecifiers(hostnameTemplate), cmdline.GetSedDelimeter(), serviceFinalPath)
+	if err != nil {
+		return fmt.Errorf("failed to set the hostname template in (%s):\n%w", serviceFile, err)
+	}
+
+	err = installChroot.UnsafeRun(func() error {
+		return shell.ExecuteLive(squashErrors, "systemctl", "enable", serviceName)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to enable (%s):\n%w", serviceName, err)
+	}
+
+	return
+}
+
+// escapeSystemdSpecifiers escapes the '%' characters of a unit file's value, so that systemd doesn't replace them with
+// its own specifiers (e.g. %m, which systemd also replaces with the machine ID, but only when the service starts).
+func escapeSystemdSpecifiers(value string) string {
+	return strings.ReplaceAll(value, "%", "%%")
+}
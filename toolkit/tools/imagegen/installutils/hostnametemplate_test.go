@@ -0,0 +1,17 @@
+// Copyright Microsoft Corporation.
+// Licensed under the MIT License.
+
+package installutils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestMain found in installutils_test.go.
+
+func TestEscapeSystemdSpecifiers(t *testing.T) {
+	assert.Equal(t, "edge-%%serial-%%m", escapeSystemdSpecifiers("edge-%serial-%m"))
+	assert.Equal(t, "edge-device", escapeSystemdSpecifiers("edge-device"))
+}
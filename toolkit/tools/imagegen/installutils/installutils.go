@@ -5,6 +5,7 @@ package installutils
 
 import (
 	"fmt"
+	"maps"
 	"os"
 	"os/exec"
 	"path"
@@ -146,7 +147,7 @@ func CreateMountPointPartitionMap(partDevPathMap, partIDToFsTypeMap map[string]s
 			if partitionSetting.OverlayBaseImage == "" {
 				mountPointDevPathMap[partitionSetting.MountPoint] = partDevPath
 				mountPointToFsTypeMap[partitionSetting.MountPoint] = partIDToFsTypeMap[partitionSetting.ID]
-				mountPointToMountArgsMap[partitionSetting.MountPoint] = partitionSetting.MountOptions
+				mountPointToMountArgsMap[partitionSetting.MountPoint] = getMountArgs(partitionSetting)
 			} else {
 				diffDiskBuild = true
 			}
@@ -156,6 +157,19 @@ func CreateMountPointPartitionMap(partDevPathMap, partIDToFsTypeMap map[string]s
 	return
 }
 
+// getMountArgs returns the mount options of a partition setting, including the btrfs subvolume that it mounts
+func getMountArgs(partitionSetting configuration.PartitionSetting) (mountArgs string) {
+	if partitionSetting.Subvolume == "" {
+		return partitionSetting.MountOptions
+	}
+
+	mountArgs = fmt.Sprintf("subvol=%s", partitionSetting.Subvolume)
+	if partitionSetting.MountOptions != "" {
+		mountArgs = fmt.Sprintf("%s,%s", mountArgs, partitionSetting.MountOptions)
+	}
+	return
+}
+
 // sortMountPoints will return a slice of mount points sorted either forward (for mounting)
 // or backwards (for unmounting)
 // - mountPointMap is the map of mountpoint to partition device path
@@ -487,10 +501,12 @@ func PackagelistContainsPackage(packageList []string, packageName string) (found
 // - partIDToFsTypeMap is a map of partition IDs to filesystem type
 // - encryptedRoot stores information about the encrypted root device if root encryption is enabled
 // - diffDiskBuild is a flag that denotes whether this is a diffdisk build or not
+// - rpmsDir is the directory of the RPM files that the packages are installed from, whose signatures are verified if
+// the config enforces them
 func PopulateInstallRoot(installChroot *safechroot.Chroot, packagesToInstall []string,
 	config configuration.SystemConfig, mountList []string, installMap, mountPointToFsTypeMap, mountPointToMountArgsMap,
 	partIDToDevPathMap, partIDToFsTypeMap map[string]string, encryptedRoot diskutils.EncryptedRootDevice,
-	diffDiskBuild bool,
+	diffDiskBuild bool, rpmsDir string,
 ) (err error) {
 	timestamp.StartEvent("populating install root", nil)
 	defer timestamp.StopEvent(nil)
@@ -574,7 +590,8 @@ func PopulateInstallRoot(installChroot *safechroot.Chroot, packagesToInstall []s
 	}
 
 	hostname := config.Hostname
-	if !config.IsRootFS() && mountPointToFsTypeMap[rootMountPoint] != overlay {
+	// A hostname template is only filled in on the first boot (see installHostnameTemplateService)
+	if !config.IsRootFS() && mountPointToFsTypeMap[rootMountPoint] != overlay && !config.IsHostnameTemplate() {
 		// Add /etc/hostname
 		err = updateHostname(installChroot.RootDir(), hostname)
 		if err != nil {
@@ -603,15 +620,105 @@ func PopulateInstallRoot(installChroot *safechroot.Chroot, packagesToInstall []s
 		}
 	}
 
+	// The signatures are verified before any of the packages are removed, as all of them were installed
+	err = verifyPackageSignatures(installRoot, rpmsDir, config.PackageSignatures)
+	if err != nil {
+		err = fmt.Errorf("failed to verify the package signatures:\n%w", err)
+		return
+	}
+
+	// Remove the packages that the package lists pulled in, but that the image doesn't need
+	removedPackages, err := removePackages(installRoot, config.RemovePackages)
+	if err != nil {
+		err = fmt.Errorf("failed to remove packages:\n%w", err)
+		return
+	}
+	if len(removedPackages) > 0 {
+		removePackagesFromManifest(installedPackages, removedPackages)
+		err = jsonutils.WriteJSONFile(packageManifestPath, installedPackages)
+		if err != nil {
+			return
+		}
+	}
+
 	timestamp.StopEvent(nil) // installing packages
 	timestamp.StartEvent("final image configuration", nil)
 
+	// The localization is configured before the additional files are copied, so that they can override it
+	err = configureLocalization(installChroot, config.Localization)
+	if err != nil {
+		err = fmt.Errorf("failed to configure the localization:\n%w", err)
+		return
+	}
+
 	// Copy additional files
 	err = copyAdditionalFiles(installChroot, config)
 	if err != nil {
 		return
 	}
 
+	err = installCACertificates(installChroot, config.CACertificates)
+	if err != nil {
+		err = fmt.Errorf("failed to add CA certificates:\n%w", err)
+		return
+	}
+
+	// The repos are configured after the CA certificates, which their URLs may need
+	err = installRepos(installChroot, config.Repos)
+	if err != nil {
+		err = fmt.Errorf("failed to configure repos:\n%w", err)
+		return
+	}
+
+	// The initramfs is regenerated with the kernel modules below
+	err = installKernelModules(installChroot, config.KernelModules)
+	if err != nil {
+		err = fmt.Errorf("failed to install kernel modules:\n%w", err)
+		return
+	}
+
+	// The firewall and the container image preloading are enabled before the services are configured, so that they
+	// can disable them
+	err = installFirewall(installChroot, config.Firewall)
+	if err != nil {
+		err = fmt.Errorf("failed to configure the firewall:\n%w", err)
+		return
+	}
+
+	err = preloadContainerImages(installChroot, config.ContainerImages)
+	if err != nil {
+		err = fmt.Errorf("failed to preload container images:\n%w", err)
+		return
+	}
+
+	// Enable, disable and mask the systemd units, which may be among the additional files
+	err = configureServices(installChroot, config.Services)
+	if err != nil {
+		err = fmt.Errorf("failed to configure services:\n%w", err)
+		return
+	}
+
+	if config.IsHostnameTemplate() && !config.IsRootFS() {
+		err = installHostnameTemplateService(installChroot, hostname)
+		if err != nil {
+			return
+		}
+	}
+
+	if config.GrowRootPartition && !config.IsRootFS() {
+		err = installGrowRootService(installChroot)
+		if err != nil {
+			return
+		}
+	}
+
+	if config.ReadOnlyRoot {
+		err = installRootOverlay(installChroot, config.PartitionSettings)
+		if err != nil {
+			return
+		}
+	}
+
 	if !config.IsRootFS() {
 		// Configure system files
 		err = configureSystemFiles(installChroot, hostname, config, mountList, installMap, mountPointToFsTypeMap,
@@ -622,18 +729,17 @@ func PopulateInstallRoot(installChroot *safechroot.Chroot, packagesToInstall []s
 	}
 
 	// Configure machine-id and other systemd state files
-	err = clearSystemdState(installChroot, config.EnableSystemdFirstboot)
+	err = clearSystemdState(installChroot, config.EnableSystemdFirstboot, config.InstanceIdentity.MachineID)
 	if err != nil {
 		err = fmt.Errorf("failed to clean systemd files:\n%w", err)
 		return
 	}
 
-	// Configure for encryption
-	if config.Encryption.Enable {
-		err = updateInitramfsForEncrypt(installChroot)
-		if err != nil {
-			return
-		}
+	// Configure for encryption, LVM, RAID, btrfs, the read-only root's overlays and the kernel modules
+	err = updateInitramfs(installChroot, config.Encryption, encryptedRoot, installMap, mountPointToFsTypeMap,
+		config.ReadOnlyRoot, config.KernelModules)
+	if err != nil {
+		return
 	}
 
 	if config.RemoveRpmDb {
@@ -649,6 +755,12 @@ func PopulateInstallRoot(installChroot *safechroot.Chroot, packagesToInstall []s
 		}
 	}
 
+	err = configureNetworking(installChroot, config.Networking)
+	if err != nil {
+		err = fmt.Errorf("failed to configure networking:\n%w", err)
+		return
+	}
+
 	timestamp.StopEvent(nil) // final image configuration
 
 	// Run post-install scripts from within the installroot chroot
@@ -657,6 +769,13 @@ func PopulateInstallRoot(installChroot *safechroot.Chroot, packagesToInstall []s
 		return
 	}
 
+	// The SSH host keys are reset or preserved after the post-install scripts, which may have generated them
+	err = configureSSHHostKeys(installChroot, config.InstanceIdentity.SSHHostKeys)
+	if err != nil {
+		err = fmt.Errorf("failed to configure the SSH host keys:\n%w", err)
+		return
+	}
+
 	// The system should be fully populated with packages, we can clear the tdnf cache now to free up space.
 	if !config.PreserveTdnfCache {
 		err = cleanupTdnfCache(installChroot)
@@ -664,6 +783,114 @@ func PopulateInstallRoot(installChroot *safechroot.Chroot, packagesToInstall []s
 			return
 		}
 	}
+
+	// The proxy is configured last, so that tdnf doesn't reach the build's repos through it
+	err = configureProxy(installChroot, config.Proxy)
+	if err != nil {
+		err = fmt.Errorf("failed to configure the proxy:\n%w", err)
+		return
+	}
+	return
+}
+
+// configureServices installs the systemd preset files and applies them, and then enables, disables and masks the
+// systemd units.
+func configureServices(installChroot *safechroot.Chroot, services configuration.Services) (err error) {
+	const (
+		presetDir    = "/etc/systemd/system-preset"
+		squashErrors = false
+	)
+
+	if services.IsEmpty() {
+		return
+	}
+
+	ReportAction("Configuring systemd services")
+
+	systemctl := func(args ...string) error {
+		return installChroot.UnsafeRun(func() error {
+			return shell.ExecuteLive(squashErrors, "systemctl", args...)
+		})
+	}
+
+	if len(services.PresetFiles) > 0 {
+		presetFileMode := os.FileMode(bootUsrConfigFileMode)
+		for _, presetFile := range services.PresetFiles {
+			err = installChroot.AddFiles(safechroot.FileToCopy{
+				Src:         presetFile,
+				Dest:        filepath.Join(presetDir, filepath.Base(presetFile)),
+				Permissions: &presetFileMode,
+			})
+			if err != nil {
+				return fmt.Errorf("failed to install preset file (%s):\n%w", presetFile, err)
+			}
+		}
+
+		err = systemctl("preset-all")
+		if err != nil {
+			return fmt.Errorf("failed to apply the preset files:\n%w", err)
+		}
+	}
+
+	for _, unitList := range []struct {
+		action string
+		units  []string
+	}{
+		{"enable", services.Enable},
+		{"disable", services.Disable},
+		{"mask", services.Mask},
+	} {
+		if len(unitList.units) == 0 {
+			continue
+		}
+
+		err = systemctl(append([]string{unitList.action}, unitList.units...)...)
+		if err != nil {
+			return fmt.Errorf("failed to %s units (%s):\n%w", unitList.action, strings.Join(unitList.units, ", "), err)
+		}
+	}
+
+	return
+}
+
+// installGrowRootService installs and enables a service that grows the root partition and its file system to fill
+// the disk on the first boot, so that a small image expands to the disk that it's written to.
+func installGrowRootService(installChroot *safechroot.Chroot) (err error) {
+	const (
+		assetScriptFile  = "assets/growroot/growroot.sh"
+		assetServiceFile = "assets/growroot/growroot.service"
+		scriptFile       = "usr/libexec/growroot"
+		serviceFile      = "usr/lib/systemd/system/growroot.service"
+		serviceName      = "growroot.service"
+		scriptFileMode   = 0755
+		systemDirMode    = 0755
+		squashErrors     = false
+	)
+
+	ReportAction("Configuring first boot root partition growth")
+
+	err = file.CopyResourceFile(resources.ResourcesFS, assetScriptFile, filepath.Join(installChroot.RootDir(), scriptFile),
+		systemDirMode, scriptFileMode)
+	if err != nil {
+		err = fmt.Errorf("failed to install (%s):\n%w", scriptFile, err)
+		return
+	}
+
+	err = file.CopyResourceFile(resources.ResourcesFS, assetServiceFile, filepath.Join(installChroot.RootDir(), serviceFile),
+		systemDirMode, bootUsrConfigFileMode)
+	if err != nil {
+		err = fmt.Errorf("failed to install (%s):\n%w", serviceFile, err)
+		return
+	}
+
+	err = installChroot.UnsafeRun(func() error {
+		return shell.ExecuteLive(squashErrors, "systemctl", "enable", serviceName)
+	})
+	if err != nil {
+		err = fmt.Errorf("failed to enable (%s):\n%w", serviceName, err)
+		return
+	}
+
 	return
 }
 
@@ -786,15 +1013,21 @@ func configureSystemFiles(installChroot *safechroot.Chroot, hostname string, con
 	mountList []string, mountPointMap, mountPointToFsTypeMap, mountPointToMountArgsMap, partIDToDevPathMap,
 	partIDToFsTypeMap map[string]string, encryptedRoot diskutils.EncryptedRootDevice,
 ) (err error) {
-	// Update hosts file
-	err = updateHosts(installChroot.RootDir(), hostname)
-	if err != nil {
-		return
+	// Update hosts file. A hostname template's hostname is only known on the first boot.
+	if !config.IsHostnameTemplate() {
+		err = updateHosts(installChroot.RootDir(), hostname)
+		if err != nil {
+			return
+		}
 	}
 
-	// Update fstab
+	// Update fstab. The root is only read-only once the image boots, as it's written to until then.
+	fstabMountArgsMap := mountPointToMountArgsMap
+	if config.ReadOnlyRoot {
+		fstabMountArgsMap = getReadOnlyRootMountArgs(mountPointToMountArgsMap)
+	}
 	err = UpdateFstab(installChroot.RootDir(), config.PartitionSettings, mountList, mountPointMap,
-		mountPointToFsTypeMap, mountPointToMountArgsMap, partIDToDevPathMap, partIDToFsTypeMap, config.EnableHidepid)
+		mountPointToFsTypeMap, fstabMountArgsMap, partIDToDevPathMap, partIDToFsTypeMap, config.EnableHidepid)
 	if err != nil {
 		return
 	}
@@ -808,6 +1041,69 @@ func configureSystemFiles(installChroot *safechroot.Chroot, hostname string, con
 	return
 }
 
+// getReadOnlyRootMountArgs returns a copy of the mount options of the mount points, with the root mounted read-only
+func getReadOnlyRootMountArgs(mountPointToMountArgsMap map[string]string) (readOnlyMountArgsMap map[string]string) {
+	const readOnlyMountArg = "ro"
+
+	readOnlyMountArgsMap = maps.Clone(mountPointToMountArgsMap)
+	if readOnlyMountArgsMap == nil {
+		readOnlyMountArgsMap = make(map[string]string)
+	}
+
+	if readOnlyMountArgsMap[rootMountPoint] == "" {
+		readOnlyMountArgsMap[rootMountPoint] = readOnlyMountArg
+	} else {
+		readOnlyMountArgsMap[rootMountPoint] = fmt.Sprintf("%s,%s", readOnlyMountArg, readOnlyMountArgsMap[rootMountPoint])
+	}
+	return
+}
+
+// getRootOverlayDirs returns the directories of a read-only root that are made writable by an overlay. The ones that
+// are mounted from their own partitions are writable already.
+func getRootOverlayDirs(partitionSettings []configuration.PartitionSetting) (overlayDirs []string) {
+	for _, dir := range []string{"/etc", "/var"} {
+		if configuration.FindMountpointPartitionSetting(partitionSettings, dir) == nil {
+			overlayDirs = append(overlayDirs, dir)
+		}
+	}
+	return
+}
+
+// installRootOverlay installs the dracut module that mounts the overlays of a read-only root, and the list of the
+// directories that it mounts them on.
+func installRootOverlay(installChroot *safechroot.Chroot, partitionSettings []configuration.PartitionSetting) (err error) {
+	const (
+		assetModuleDir   = "assets/dracut/rootoverlay"
+		dracutModuleDir  = "usr/lib/dracut/modules.d/90rootoverlay"
+		rootOverlayConf  = "etc/rootoverlay.conf"
+		moduleFileMode   = 0755
+		moduleDirMode    = 0755
+		moduleSetupFile  = "module-setup.sh"
+		overlayMountFile = "rootoverlay-mount.sh"
+	)
+
+	ReportAction("Configuring read-only root overlays")
+
+	for _, moduleFile := range []string{moduleSetupFile, overlayMountFile} {
+		err = file.CopyResourceFile(resources.ResourcesFS, filepath.Join(assetModuleDir, moduleFile),
+			filepath.Join(installChroot.RootDir(), dracutModuleDir, moduleFile), moduleDirMode, moduleFileMode)
+		if err != nil {
+			err = fmt.Errorf("failed to install dracut module file (%s):\n%w", moduleFile, err)
+			return
+		}
+	}
+
+	overlayDirs := getRootOverlayDirs(partitionSettings)
+	err = file.WriteLines(overlayDirs, filepath.Join(installChroot.RootDir(), rootOverlayConf))
+	if err != nil {
+		err = fmt.Errorf("failed to write (%s):\n%w", rootOverlayConf, err)
+		return
+	}
+
+	logger.Log.Infof("Read-only root has overlays on (%s)", strings.Join(overlayDirs, ", "))
+	return
+}
+
 // calculateTotalPackages will simulate installing the provided list of packages in the installRoot.
 // all packages that will be installed are returned in installedPackages, and a manifest with these packages
 // is generated under build/imagegen/$config_name/image_pkg_manifest.json
@@ -888,7 +1184,9 @@ func calculateTotalPackages(packages []string, installRoot string) (installedPac
 // correctly, and that random seed and credential files are removed if they exist.
 // - installChroot is the chroot to modify
 // - enableSystemdFirstboot will set the machine-id file to "uninitialized" if true, and "" if false
-func clearSystemdState(installChroot *safechroot.Chroot, enableSystemdFirstboot bool) (err error) {
+// - machineIDPolicy will instead keep a machine-id in the image if it's preserved, generating one if there's none
+func clearSystemdState(installChroot *safechroot.Chroot, enableSystemdFirstboot bool,
+	machineIDPolicy configuration.IdentityPolicy) (err error) {
 	const (
 		machineIDFile         = "/etc/machine-id"
 		machineIDFirstBootOn  = "uninitialized\n"
@@ -934,6 +1232,9 @@ func clearSystemdState(installChroot *safechroot.Chroot, enableSystemdFirstboot
 	// that systemd-firstboot would set should have already been configured by the imager tool. It is important to
 	// create an empty file so that read-only configurations will work as expected. If the user requests that firstboot
 	// be enabled we will set it to "uninitalized" as per option 3).
+	//
+	// If the user preserves the machine-id, option 5) is used instead: the image keeps a valid machine-id, which all
+	// of its instances share, and none of their boots is a first boot.
 
 	ReportAction("Configuring systemd state files for first boot")
 
@@ -952,7 +1253,9 @@ func clearSystemdState(installChroot *safechroot.Chroot, enableSystemdFirstboot
 		}
 	}
 
-	if enableSystemdFirstboot {
+	if machineIDPolicy.IsPreserved() {
+		err = preserveMachineID(installChroot, machineIDFile)
+	} else if enableSystemdFirstboot {
 		ReportAction("Enabling systemd firstboot")
 		err = file.Write(machineIDFirstBootOn, filepath.Join(installChroot.RootDir(), machineIDFile))
 	} else {
@@ -960,7 +1263,7 @@ func clearSystemdState(installChroot *safechroot.Chroot, enableSystemdFirstboot
 		err = file.Write(machineIDFirstbootOff, filepath.Join(installChroot.RootDir(), machineIDFile))
 	}
 	if err != nil {
-		err = fmt.Errorf("failed to write empty machine-id:\n%w", err)
+		err = fmt.Errorf("failed to write machine-id:\n%w", err)
 		return err
 	}
 
@@ -1038,14 +1341,83 @@ func AddImageIDFile(installChrootRootDir string, buildNumber string) (err error)
 	return
 }
 
-func updateInitramfsForEncrypt(installChroot *safechroot.Chroot) (err error) {
+// updateInitramfs regenerates the initramfs with the dracut modules and files that are needed to unlock the encrypted
+// partitions, to activate the logical volumes and assemble the RAID arrays that the system is mounted from, and to
+// mount a btrfs root and the overlays of a read-only root, and with the kernel modules that are added to it. It leaves
+// the initramfs as is if none are needed.
+func updateInitramfs(installChroot *safechroot.Chroot, encryption configuration.RootEncryption,
+	encryptedRoot diskutils.EncryptedRootDevice, installMap, mountPointToFsTypeMap map[string]string,
+	readOnlyRoot bool, kernelModules configuration.KernelModules,
+) (err error) {
+	const (
+		encryptDracutModules = "dm crypt crypt-gpg crypt-loop lvm"
+		tpm2DracutModules    = "tpm2-tss"
+		lvmDracutModules     = "dm lvm"
+		raidDracutModules    = "mdraid"
+		btrfsDracutModules   = "btrfs"
+		overlayDracutModules = "rootoverlay"
+		cryptTabPath         = "/etc/crypttab"
+	)
+
+	modules := []string{}
+	installFiles := []string{}
+
+	// The encryption modules already include LVM support.
+	if encryption.Enable {
+		modules = append(modules, encryptDracutModules)
+
+		// With a TPM2 token, the initramfs is on an unencrypted /boot partition, so it must not include the keyfile.
+		installFiles = append(installFiles, cryptTabPath)
+		if encryptedRoot.UnlockWithTPM2 {
+			modules = append(modules, tpm2DracutModules)
+		} else {
+			installFiles = append(installFiles, diskutils.DefaultKeyFilePath)
+		}
+	} else if hasMountedDevice(installMap, diskutils.IsLogicalVolumeDevice) {
+		modules = append(modules, lvmDracutModules)
+	}
+
+	if hasMountedDevice(installMap, diskutils.IsRaidArrayDevice) {
+		modules = append(modules, raidDracutModules)
+		installFiles = append(installFiles, diskutils.MdadmConfPath)
+	}
+
+	if mountPointToFsTypeMap[rootMountPoint] == configuration.BtrfsFsType {
+		modules = append(modules, btrfsDracutModules)
+	}
+
+	if readOnlyRoot {
+		modules = append(modules, overlayDracutModules)
+	}
+
+	// dracut adds the kernel modules from their dracut.conf.d config (see renderKernelModulesConfigs).
+	if len(modules) == 0 && !hasInitrdKernelModules(kernelModules) {
+		return
+	}
+
+	return regenerateInitramfs(installChroot, strings.Join(modules, " "), strings.Join(installFiles, " "))
+}
+
+// hasMountedDevice returns true if any of the mounted devices is of a kind
+// - isDeviceKind checks if a device path is of the kind
+func hasMountedDevice(installMap map[string]string, isDeviceKind func(devicePath string) bool) bool {
+	for _, devicePath := range installMap {
+		if isDeviceKind(devicePath) {
+			return true
+		}
+	}
+	return false
+}
+
+// regenerateInitramfs regenerates the image's initramfs with additional dracut modules and files
+// - modules are the dracut modules to add, separated by spaces. May be empty.
+// - installFiles are the files to install in the initramfs, separated by spaces. May be empty.
+func regenerateInitramfs(installChroot *safechroot.Chroot, modules, installFiles string) (err error) {
 	err = installChroot.UnsafeRun(func() (err error) {
 		const (
-			libModDir     = "/lib/modules"
-			dracutModules = "dm crypt crypt-gpg crypt-loop lvm"
-			initrdPrefix  = "/boot/initramfs-"
-			initrdSuffix  = ".img"
-			cryptTabPath  = "/etc/crypttab"
+			libModDir    = "/lib/modules"
+			initrdPrefix = "/boot/initramfs-"
+			initrdSuffix = ".img"
 		)
 
 		initrdPattern := fmt.Sprintf("%v*%v", initrdPrefix, initrdSuffix)
@@ -1069,19 +1441,20 @@ func updateInitramfsForEncrypt(installChroot *safechroot.Chroot) (err error) {
 		kernel := strings.TrimPrefix(initrdImage, initrdPrefix)
 		kernel = strings.TrimSuffix(kernel, initrdSuffix)
 
-		// Construct list of files to install in initramfs
-		installFiles := fmt.Sprintf("%v %v", cryptTabPath, diskutils.DefaultKeyFilePath)
-
 		// Regenerate initramfs via Dracut
 		dracutArgs := []string{
 			"-f",
 			"--no-hostonly",
 			"--fstab",
 			"--kmoddir", filepath.Join(libModDir, kernel),
-			"--add", dracutModules,
-			"-I", installFiles,
-			initrdImage, kernel,
 		}
+		if modules != "" {
+			dracutArgs = append(dracutArgs, "--add", modules)
+		}
+		if installFiles != "" {
+			dracutArgs = append(dracutArgs, "-I", installFiles)
+		}
+		dracutArgs = append(dracutArgs, initrdImage, kernel)
 		_, stderr, err := shell.Execute("dracut", dracutArgs...)
 
 		if err != nil {
@@ -1127,7 +1500,8 @@ func UpdateFstabFile(fullFstabPath string, partitionSettings []configuration.Par
 				return
 			}
 			err = addEntryToFstab(fullFstabPath, mountPoint, devicePath, mountPointToFsTypeMap[mountPoint],
-				mountPointToMountArgsMap[mountPoint], partSetting.MountIdentifier, !doPseudoFsMount)
+				mountPointToMountArgsMap[mountPoint], partSetting.MountIdentifier, partSetting.Dump, partSetting.FsckPolicy,
+				!doPseudoFsMount)
 			if err != nil {
 				return
 			}
@@ -1135,7 +1509,7 @@ func UpdateFstabFile(fullFstabPath string, partitionSettings []configuration.Par
 	}
 
 	if hidepidEnabled {
-		err = addEntryToFstab(fullFstabPath, "/proc", "proc", "proc", "rw,nosuid,nodev,noexec,relatime,hidepid=2", configuration.MountIdentifierNone, doPseudoFsMount)
+		err = addEntryToFstab(fullFstabPath, "/proc", "proc", "proc", "rw,nosuid,nodev,noexec,relatime,hidepid=2", configuration.MountIdentifierNone, false, configuration.FsckPolicyDefault, doPseudoFsMount)
 		if err != nil {
 			return
 		}
@@ -1146,7 +1520,7 @@ func UpdateFstabFile(fullFstabPath string, partitionSettings []configuration.Par
 		if fstype == "linux-swap" {
 			swapPartitionPath, exists := partIDToDevPathMap[partID]
 			if exists {
-				err = addEntryToFstab(fullFstabPath, "none", swapPartitionPath, "swap", "", "", doPseudoFsMount)
+				err = addEntryToFstab(fullFstabPath, "none", swapPartitionPath, "swap", "", "", false, configuration.FsckPolicyDefault, doPseudoFsMount)
 				if err != nil {
 					return
 				}
@@ -1157,16 +1531,15 @@ func UpdateFstabFile(fullFstabPath string, partitionSettings []configuration.Par
 	return
 }
 
-func addEntryToFstab(fullFstabPath, mountPoint, devicePath, fsType, mountArgs string, identifierType configuration.MountIdentifier, doPseudoFsMount bool) (err error) {
+func addEntryToFstab(fullFstabPath, mountPoint, devicePath, fsType, mountArgs string, identifierType configuration.MountIdentifier,
+	dump bool, fsckPolicy configuration.FsckPolicy, doPseudoFsMount bool,
+) (err error) {
 	const (
-		rootfsMountPoint = "/"
-		defaultOptions   = "defaults"
-		swapFsType       = "swap"
-		swapOptions      = "sw"
-		defaultDump      = "0"
-		disablePass      = "0"
-		rootPass         = "1"
-		defaultPass      = "2"
+		defaultOptions = "defaults"
+		swapFsType     = "swap"
+		swapOptions    = "sw"
+		disableDump    = "0"
+		enableDump     = "1"
 	)
 
 	var options string
@@ -1183,7 +1556,8 @@ func addEntryToFstab(fullFstabPath, mountPoint, devicePath, fsType, mountArgs st
 
 	// Get the block device
 	var device string
-	if diskutils.IsEncryptedDevice(devicePath) || doPseudoFsMount {
+	if diskutils.IsEncryptedDevice(devicePath) || diskutils.IsLogicalVolumeDevice(devicePath) ||
+		diskutils.IsRaidArrayDevice(devicePath) || doPseudoFsMount {
 		device = devicePath
 	} else {
 		device, err = FormatMountIdentifier(identifierType, devicePath)
@@ -1193,16 +1567,14 @@ func addEntryToFstab(fullFstabPath, mountPoint, devicePath, fsType, mountArgs st
 		}
 	}
 
-	// Note: Rootfs should always have a pass number of 1. All other mountpoints are either 0 or 2
-	pass := defaultPass
-	if mountPoint == rootfsMountPoint {
-		pass = rootPass
-	} else if doPseudoFsMount {
-		pass = disablePass
+	dumpNumber := disableDump
+	if dump {
+		dumpNumber = enableDump
 	}
+	pass := getFstabPass(mountPoint, fsType, fsckPolicy, doPseudoFsMount)
 
 	// Construct fstab entry and append to fstab file
-	newEntry := fmt.Sprintf("%v %v %v %v %v %v\n", device, mountPoint, fsType, options, defaultDump, pass)
+	newEntry := fmt.Sprintf("%v %v %v %v %v %v\n", device, mountPoint, fsType, options, dumpNumber, pass)
 	err = file.Append(newEntry, fullFstabPath)
 	if err != nil {
 		logger.Log.Warnf("Failed to append to fstab file")
@@ -1211,6 +1583,37 @@ func addEntryToFstab(fullFstabPath, mountPoint, devicePath, fsType, mountArgs st
 	return
 }
 
+// getFstabPass returns the pass number of an fstab entry, which is set by its fsck policy, or else derived from it.
+func getFstabPass(mountPoint, fsType string, fsckPolicy configuration.FsckPolicy, doPseudoFsMount bool) (pass string) {
+	const (
+		rootfsMountPoint = "/"
+		disablePass      = "0"
+		rootPass         = "1"
+		defaultPass      = "2"
+	)
+
+	switch fsckPolicy {
+	case configuration.FsckPolicySkip:
+		return disablePass
+	case configuration.FsckPolicyFirst:
+		return rootPass
+	case configuration.FsckPolicyAfterRoot:
+		return defaultPass
+	}
+
+	// Note: Rootfs should always have a pass number of 1. All other mountpoints are either 0 or 2
+	// btrfs is checked when it's mounted, not by fsck, so it always has a pass number of 0
+	pass = defaultPass
+	if fsType == configuration.BtrfsFsType {
+		pass = disablePass
+	} else if mountPoint == rootfsMountPoint {
+		pass = rootPass
+	} else if doPseudoFsMount {
+		pass = disablePass
+	}
+	return
+}
+
 func updateCrypttab(installRoot string, installMap map[string]string, encryptedRoot diskutils.EncryptedRootDevice) (err error) {
 	ReportAction("Configuring Crypttab")
 
@@ -1231,18 +1634,29 @@ func addEntryToCrypttab(installRoot string, devicePath string, encryptedRoot dis
 	const (
 		cryptTabPath = "/etc/crypttab"
 		Options      = "luks,discard"
+		tpm2Options  = "luks,discard,tpm2-device=auto"
+		noKeyFile    = "none"
 		uuidPrefix   = "UUID="
 	)
 
 	fullCryptTabPath := filepath.Join(installRoot, cryptTabPath)
-	// Encrypted root will always use UUID rather than the PartitionSetting.MountIdentifier
+	// Encrypted partitions will always use UUID rather than the PartitionSetting.MountIdentifier
 	uuid := encryptedRoot.LuksUUID
+	if encryptedDevice := encryptedRoot.FindEncryptedDevice(devicePath); encryptedDevice != nil {
+		uuid = encryptedDevice.LuksUUID
+	}
 	blockDevice := diskutils.GetLuksMappingName(uuid)
 	encryptedUUID := fmt.Sprintf("%v%v", uuidPrefix, uuid)
 	encryptionPassword := diskutils.DefaultKeyFilePath
+	options := Options
+	if encryptedRoot.UnlockWithTPM2 {
+		// systemd-cryptsetup unlocks the device with the enrolled TPM2 token, and falls back to asking for the password.
+		encryptionPassword = noKeyFile
+		options = tpm2Options
+	}
 
 	// Construct crypttab entry and append crypttab file
-	newEntry := fmt.Sprintf("%v %v %v %v\n", blockDevice, encryptedUUID, encryptionPassword, Options)
+	newEntry := fmt.Sprintf("%v %v %v %v\n", blockDevice, encryptedUUID, encryptionPassword, options)
 	err = file.Append(newEntry, fullCryptTabPath)
 	if err != nil {
 		logger.Log.Warnf("Failed to append crypttab")
@@ -1271,6 +1685,12 @@ func ConfigureDiskBootloader(bootType string, encryptionEnable bool, partitionSe
 	}
 	rootMountIdentifier := rootPartitionSetting.MountIdentifier
 
+	// The kernel mounts the root subvolume, instead of the btrfs file system's top level.
+	if rootPartitionSetting.Subvolume != "" {
+		rootFlags := fmt.Sprintf("rootflags=subvol=%s", rootPartitionSetting.Subvolume)
+		kernelCommandLine.ExtraCommandLine = strings.TrimSpace(fmt.Sprintf("%s %s", rootFlags, kernelCommandLine.ExtraCommandLine))
+	}
+
 	return ConfigureDiskBootloaderWithRootMountIdType(bootType, encryptionEnable, rootMountIdentifier, kernelCommandLine,
 		installChroot, diskDevPath, mountPointMap, encryptedRoot, enableGrubMkconfig, includeLegacyGrubCfg)
 }
@@ -1301,7 +1721,9 @@ func ConfigureDiskBootloaderWithRootMountIdType(bootType string, encryptionEnabl
 		return
 	}
 
-	err = InstallBootloader(installChroot, encryptionEnable, bootType, bootUUID, bootPrefix, diskDevPath)
+	// With a TPM2 token, grub reads an unencrypted /boot partition, and can't unlock the LUKS2 root anyway.
+	grubUnlocksRoot := encryptionEnable && !encryptedRoot.UnlockWithTPM2
+	err = InstallBootloader(installChroot, grubUnlocksRoot, bootType, bootUUID, bootPrefix, diskDevPath)
 	if err != nil {
 		err = fmt.Errorf("failed to install bootloader: %s", err)
 		return
@@ -1309,8 +1731,9 @@ func ConfigureDiskBootloaderWithRootMountIdType(bootType string, encryptionEnabl
 
 	// Add grub config to image
 	var rootDevice string
-	if encryptionEnable {
-		// Encrypted devices don't currently support identifiers
+	if encryptionEnable || diskutils.IsLogicalVolumeDevice(mountPointMap[rootMountPoint]) ||
+		diskutils.IsRaidArrayDevice(mountPointMap[rootMountPoint]) {
+		// Encrypted devices, logical volumes and RAID arrays don't currently support identifiers
 		rootDevice = mountPointMap[rootMountPoint]
 	} else {
 		var partIdentifier string
@@ -1444,7 +1867,7 @@ func installGrubTemplateFile(assetFile, targetFile, installRoot, rootDevice, boo
 	}
 
 	// Add in logical volumes to active
-	err = setGrubCfgLVM(installGrubDefFile, encryptedRoot.LuksUUID)
+	err = setGrubCfgLVM(installGrubDefFile, rootDevice, encryptedRoot.LuksUUID)
 	if err != nil {
 		logger.Log.Warnf("Failed to set lvm.lv in %s: %v", installGrubDefFile, err)
 		return
@@ -2004,7 +2427,7 @@ func SELinuxRelabelFiles(installChroot safechroot.ChrootInterface, mountPointToF
 		//     only supports the below cases:
 		for mount, fsType := range mountPointToFsTypeMap {
 			switch fsType {
-			case "ext2", "ext3", "ext4", "xfs":
+			case "ext2", "ext3", "ext4", "xfs", configuration.BtrfsFsType:
 				listOfMountsToLabel = append(listOfMountsToLabel, mount)
 			case "fat32", "fat16", "vfat":
 				logger.Log.Debugf("SELinux will not label mount at (%s) of type (%s), skipping", mount, fsType)
@@ -2337,6 +2760,73 @@ func installEfiBootloader(encryptEnabled bool, installRoot, bootUUID, bootPrefix
 	return
 }
 
+// ConfigureABRootBootloader replaces the ESP's grub.cfg with one that boots the root partition of the A/B slot that
+// the ESP's grubenv selects, and adds that grubenv. It's called after ConfigureDiskBootloader, which configures the
+// bootloader of slot A.
+// - installChroot is the chroot of slot A, with the ESP mounted at /boot/efi
+// - partIDToDevPathMap maps the partition IDs to their device paths, and includes the root partitions of both slots
+func ConfigureABRootBootloader(installChroot *safechroot.Chroot, partIDToDevPathMap map[string]string) (err error) {
+	const (
+		grubABAssetPath = "assets/efi/grub/grub-ab.cfg"
+		assetGrubEnv    = "assets/grub2/grubenv"
+		efiGrubDir      = "boot/efi/boot/grub2"
+		slotAUUIDKey    = "{{.SlotAUUID}}"
+		slotBUUIDKey    = "{{.SlotBUUID}}"
+	)
+	var cmdline configuration.KernelCommandLine
+
+	ReportAction("Configuring A/B root bootloader")
+
+	slotAUUID, err := GetUUID(partIDToDevPathMap[configuration.ABRootPartitionIDA])
+	if err != nil {
+		err = fmt.Errorf("failed to get the UUID of A/B root slot (a):\n%w", err)
+		return
+	}
+
+	slotBUUID, err := GetUUID(partIDToDevPathMap[configuration.ABRootPartitionIDB])
+	if err != nil {
+		err = fmt.Errorf("failed to get the UUID of A/B root slot (b):\n%w", err)
+		return
+	}
+
+	grubFinalPath := filepath.Join(installChroot.RootDir(), efiGrubDir, "grub.cfg")
+	err = file.CopyResourceFile(resources.ResourcesFS, grubABAssetPath, grubFinalPath, bootDirectoryDirMode,
+		bootDirectoryFileMode)
+	if err != nil {
+		err = fmt.Errorf("failed to copy A/B root grub.cfg:\n%w", err)
+		return
+	}
+
+	err = sed(slotAUUIDKey, slotAUUID, cmdline.GetSedDelimeter(), grubFinalPath)
+	if err != nil {
+		err = fmt.Errorf("failed to set the UUID of A/B root slot (a) in grub.cfg:\n%w", err)
+		return
+	}
+
+	err = sed(slotBUUIDKey, slotBUUID, cmdline.GetSedDelimeter(), grubFinalPath)
+	if err != nil {
+		err = fmt.Errorf("failed to set the UUID of A/B root slot (b) in grub.cfg:\n%w", err)
+		return
+	}
+
+	// Each slot has its /boot directory in its root partition.
+	err = setGrubCfgPrefixPath(filepath.Join(CombinedBootPartitionBootPrefix, "grub2"), grubFinalPath)
+	if err != nil {
+		return
+	}
+
+	// The grubenv selects the slot, and is edited by the update system with grub2-editenv.
+	grubEnvFinalPath := filepath.Join(installChroot.RootDir(), efiGrubDir, "grubenv")
+	err = file.CopyResourceFile(resources.ResourcesFS, assetGrubEnv, grubEnvFinalPath, bootDirectoryDirMode,
+		bootDirectoryFileMode)
+	if err != nil {
+		err = fmt.Errorf("failed to copy A/B root grubenv:\n%w", err)
+		return
+	}
+
+	return
+}
+
 func copyAdditionalFiles(installChroot *safechroot.Chroot, config configuration.SystemConfig) (err error) {
 	return copyAdditionalFilesHelper(installChroot, config.AdditionalFiles)
 }
@@ -2638,7 +3128,7 @@ func setGrubCfgCGroup(grubPath string, kernelCommandline configuration.KernelCom
 	return
 }
 
-func setGrubCfgLVM(grubPath, luksUUID string) (err error) {
+func setGrubCfgLVM(grubPath, rootDevice, luksUUID string) (err error) {
 	const (
 		lvmPrefix  = "rd.lvm.lv="
 		lvmPattern = "{{.LVM}}"
@@ -2648,6 +3138,8 @@ func setGrubCfgLVM(grubPath, luksUUID string) (err error) {
 	var lvm string
 	if luksUUID != "" {
 		lvm = fmt.Sprintf("%v%v", lvmPrefix, diskutils.GetEncryptedRootVolPath())
+	} else if groupName, volumeName, ok := diskutils.SplitLogicalVolumeMapping(rootDevice); ok {
+		lvm = fmt.Sprintf("%v%v/%v", lvmPrefix, groupName, volumeName)
 	}
 
 	logger.Log.Debugf("Adding lvm('%s') to '%s'", lvm, grubPath)
@@ -244,3 +244,47 @@ func TestPackagelistContainsPackageVersions(t *testing.T) {
 	assert.NoError(t, err)
 	assert.True(t, found)
 }
+
+func TestGetMountArgs(t *testing.T) {
+	assert.Equal(t, "", getMountArgs(configuration.PartitionSetting{}))
+	assert.Equal(t, "ro", getMountArgs(configuration.PartitionSetting{MountOptions: "ro"}))
+	assert.Equal(t, "subvol=@", getMountArgs(configuration.PartitionSetting{Subvolume: "@"}))
+	assert.Equal(t, "subvol=@home,compress=zstd", getMountArgs(configuration.PartitionSetting{Subvolume: "@home", MountOptions: "compress=zstd"}))
+}
+
+func TestGetReadOnlyRootMountArgs(t *testing.T) {
+	mountArgs := map[string]string{"/": "", "/boot/efi": "umask=0077"}
+
+	readOnlyMountArgs := getReadOnlyRootMountArgs(mountArgs)
+	assert.Equal(t, map[string]string{"/": "ro", "/boot/efi": "umask=0077"}, readOnlyMountArgs)
+	assert.Equal(t, "", mountArgs["/"])
+
+	readOnlyMountArgs = getReadOnlyRootMountArgs(map[string]string{"/": "subvol=@,compress=zstd"})
+	assert.Equal(t, map[string]string{"/": "ro,subvol=@,compress=zstd"}, readOnlyMountArgs)
+}
+
+func TestGetRootOverlayDirs(t *testing.T) {
+	partitionSettings := []configuration.PartitionSetting{
+		{ID: "boot", MountPoint: "/boot/efi"},
+		{ID: "rootfs", MountPoint: "/"},
+	}
+	assert.Equal(t, []string{"/etc", "/var"}, getRootOverlayDirs(partitionSettings))
+
+	partitionSettings = append(partitionSettings, configuration.PartitionSetting{ID: "var", MountPoint: "/var"})
+	assert.Equal(t, []string{"/etc"}, getRootOverlayDirs(partitionSettings))
+}
+
+func TestGetFstabPass(t *testing.T) {
+	const doPseudoFsMount = true
+
+	// The pass numbers that are derived from the mount point and the file system.
+	assert.Equal(t, "1", getFstabPass("/", "ext4", configuration.FsckPolicyDefault, !doPseudoFsMount))
+	assert.Equal(t, "2", getFstabPass("/var", "xfs", configuration.FsckPolicyDefault, !doPseudoFsMount))
+	assert.Equal(t, "0", getFstabPass("/", "btrfs", configuration.FsckPolicyDefault, !doPseudoFsMount))
+	assert.Equal(t, "0", getFstabPass("/proc", "proc", configuration.FsckPolicyDefault, doPseudoFsMount))
+
+	// The pass numbers that are set by the fsck policy.
+	assert.Equal(t, "0", getFstabPass("/", "ext4", configuration.FsckPolicySkip, !doPseudoFsMount))
+	assert.Equal(t, "1", getFstabPass("/", "xfs", configuration.FsckPolicyFirst, !doPseudoFsMount))
+	assert.Equal(t, "2", getFstabPass("/boot/efi", "vfat", configuration.FsckPolicyAfterRoot, !doPseudoFsMount))
+}
@@ -0,0 +1,108 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package installutils
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/microsoft/azurelinux/toolkit/tools/imagegen/configuration"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/file"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/logger"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/safechroot"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/shell"
+)
+
+const (
+	// sshHostKeysGlob matches the SSH host keys and their public keys. sshd-keygen.service generates the missing keys on
+	// boot, before sshd starts.
+	sshHostKeysGlob = "/etc/ssh/ssh_host_*"
+	sshKeygenPath   = "/usr/bin/ssh-keygen"
+)
+
+// machineIDRegex matches a valid machine-id: 32 lowercase hexadecimal characters.
+var machineIDRegex = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+// preserveMachineID keeps the image's machine-id, which all of its instances will share. If the image doesn't have
+// one yet (the file is empty or "uninitialized"), a new machine-id is generated.
+func preserveMachineID(installChroot *safechroot.Chroot, machineIDFile string) (err error) {
+	fullPath := filepath.Join(installChroot.RootDir(), machineIDFile)
+
+	content, err := os.ReadFile(fullPath)
+	if err != nil {
+		return fmt.Errorf("failed to read machine-id:\n%w", err)
+	}
+
+	if isMachineID(string(content)) {
+		ReportAction("Preserving the machine-id")
+		return
+	}
+
+	ReportAction("Generating a machine-id to preserve")
+	return file.Write(newMachineID()+"\n", fullPath)
+}
+
+// isMachineID returns true if the content of a machine-id file is a valid machine-id.
+func isMachineID(content string) bool {
+	return machineIDRegex.MatchString(strings.TrimSpace(content))
+}
+
+// newMachineID returns a random machine-id, formatted like systemd formats the ones that it generates: a version 4
+// UUID in lowercase hexadecimal, without dashes.
+func newMachineID() string {
+	return strings.ReplaceAll(uuid.New().String(), "-", "")
+}
+
+// configureSSHHostKeys removes the SSH host keys from the image, so that each of its instances generates its own on
+// its first boot, or preserves them, generating the missing keys so that all of its instances share them.
+func configureSSHHostKeys(installChroot *safechroot.Chroot, policy configuration.IdentityPolicy) (err error) {
+	const squashErrors = false
+
+	if !policy.IsPreserved() {
+		ReportAction("Removing the SSH host keys")
+		return removeSSHHostKeys(installChroot.RootDir())
+	}
+
+	exists, err := file.PathExists(filepath.Join(installChroot.RootDir(), sshKeygenPath))
+	if err != nil {
+		return fmt.Errorf("failed to check if (%s) exists:\n%w", sshKeygenPath, err)
+	}
+	if !exists {
+		logger.Log.Warnf("The SSH host keys are preserved, but (%s) isn't installed, so there are none to preserve",
+			sshKeygenPath)
+		return
+	}
+
+	ReportAction("Generating the SSH host keys to preserve")
+	err = installChroot.UnsafeRun(func() error {
+		return shell.ExecuteLive(squashErrors, sshKeygenPath, "-A")
+	})
+	if err != nil {
+		return fmt.Errorf("failed to generate the SSH host keys:\n%w", err)
+	}
+
+	return
+}
+
+// removeSSHHostKeys removes the SSH host keys, and their public keys, from the root.
+func removeSSHHostKeys(rootDir string) (err error) {
+	hostKeys, err := filepath.Glob(filepath.Join(rootDir, sshHostKeysGlob))
+	if err != nil {
+		return fmt.Errorf("failed to find the SSH host keys:\n%w", err)
+	}
+
+	for _, hostKey := range hostKeys {
+		logger.Log.Debugf("Removing SSH host key (%s)", hostKey)
+		err = file.RemoveFileIfExists(hostKey)
+		if err != nil {
+			return fmt.Errorf("failed to remove SSH host key (%s):\n%w", hostKey, err)
+		}
+	}
+
+	return
+}
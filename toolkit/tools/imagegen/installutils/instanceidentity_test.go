@@ -0,0 +1,52 @@
+// Copyright Microsoft Corporation.
+// Licensed under the MIT License.
+
+package installutils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestMain found in installutils_test.go.
+
+func TestIsMachineID(t *testing.T) {
+	assert.True(t, isMachineID("4c4c4544003f4b1080524ab04f4d4e32\n"))
+	assert.False(t, isMachineID(""))
+	assert.False(t, isMachineID("uninitialized\n"))
+	assert.False(t, isMachineID("4C4C4544003F4B1080524AB04F4D4E32"))
+	assert.False(t, isMachineID("4c4c4544-003f-4b10-8052-4ab04f4d4e32"))
+}
+
+func TestNewMachineID(t *testing.T) {
+	machineID := newMachineID()
+	assert.True(t, isMachineID(machineID))
+	assert.NotEqual(t, machineID, newMachineID())
+}
+
+func TestRemoveSSHHostKeys(t *testing.T) {
+	rootDir := t.TempDir()
+	sshDir := filepath.Join(rootDir, "etc/ssh")
+	assert.NoError(t, os.MkdirAll(sshDir, os.ModePerm))
+	for _, fileName := range []string{"ssh_host_ed25519_key", "ssh_host_ed25519_key.pub", "ssh_host_rsa_key",
+		"ssh_host_rsa_key.pub", "sshd_config", "moduli"} {
+		assert.NoError(t, os.WriteFile(filepath.Join(sshDir, fileName), nil, 0600))
+	}
+
+	assert.NoError(t, removeSSHHostKeys(rootDir))
+
+	entries, err := os.ReadDir(sshDir)
+	assert.NoError(t, err)
+	var fileNames []string
+	for _, entry := range entries {
+		fileNames = append(fileNames, entry.Name())
+	}
+	assert.Equal(t, []string{"moduli", "sshd_config"}, fileNames)
+}
+
+func TestRemoveSSHHostKeysWithoutSSH(t *testing.T) {
+	assert.NoError(t, removeSSHHostKeys(t.TempDir()))
+}
@@ -0,0 +1,188 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package installutils
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/imagegen/configuration"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/file"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/logger"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/safechroot"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/shell"
+)
+
+const (
+	kernelModulesDir = "/lib/modules"
+	// kernelModulesConfigName is the name of the modules-load.d, modprobe.d and dracut.conf.d files of the kernel
+	// modules.
+	kernelModulesConfigName = "kernel-modules.conf"
+)
+
+// installKernelModules installs the out-of-tree kernel modules into the image's kernel's "extra" modules, signs them,
+// updates the kernel's module dependencies, and writes the configs that load the modules on boot, set their
+// options and add them to the initramfs.
+func installKernelModules(installChroot *safechroot.Chroot, kernelModules configuration.KernelModules) (err error) {
+	const (
+		// The signing key is only in the image while the modules are signed.
+		signingKeyDir    = "/tmp/kernelmodulesigning"
+		signingKeyMode   = 0600
+		moduleFileMode   = 0644
+		hashAlgorithm    = "sha512"
+		configDirMode    = 0755
+		extraModulesDir  = "extra"
+		signFileToolPath = "build/scripts/sign-file"
+		squashErrors     = false
+	)
+
+	if kernelModules.IsEmpty() {
+		return
+	}
+
+	ReportAction("Installing kernel modules")
+
+	kernelVersion, err := getInstalledKernelVersion(installChroot.RootDir())
+	if err != nil {
+		return
+	}
+
+	kernelDir := filepath.Join(kernelModulesDir, kernelVersion)
+	modulePaths := make([]string, 0, len(kernelModules.Modules))
+	for _, module := range kernelModules.Modules {
+		modulePath := filepath.Join(kernelDir, extraModulesDir, module.GetName()+".ko")
+		fileMode := os.FileMode(moduleFileMode)
+
+		err = installChroot.AddFiles(safechroot.FileToCopy{
+			Src:         module.Path,
+			Dest:        modulePath,
+			Permissions: &fileMode,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to copy kernel module (%s):\n%w", module.GetName(), err)
+		}
+		modulePaths = append(modulePaths, modulePath)
+	}
+
+	if kernelModules.IsSigned() {
+		defer os.RemoveAll(filepath.Join(installChroot.RootDir(), signingKeyDir))
+
+		keyMode := os.FileMode(signingKeyMode)
+		signingKey := filepath.Join(signingKeyDir, filepath.Base(kernelModules.SigningKey))
+		signingCert := filepath.Join(signingKeyDir, filepath.Base(kernelModules.SigningCert))
+		err = installChroot.AddFiles(
+			safechroot.FileToCopy{Src: kernelModules.SigningKey, Dest: signingKey, Permissions: &keyMode},
+			safechroot.FileToCopy{Src: kernelModules.SigningCert, Dest: signingCert, Permissions: &keyMode},
+		)
+		if err != nil {
+			return fmt.Errorf("failed to copy the kernel modules' signing key:\n%w", err)
+		}
+
+		signFileTool := filepath.Join(kernelDir, signFileToolPath)
+		err = installChroot.UnsafeRun(func() error {
+			for _, modulePath := range modulePaths {
+				logger.Log.Infof("Signing kernel module (%s)", modulePath)
+				_, stderr, err := shell.Execute(signFileTool, hashAlgorithm, signingKey, signingCert, modulePath)
+				if err != nil {
+					return fmt.Errorf("failed to sign (%s):\n%v\n%w", modulePath, stderr, err)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to sign the kernel modules with (%s), which the kernel's devel package installs:\n%w",
+				signFileTool, err)
+		}
+	}
+
+	err = installChroot.UnsafeRun(func() error {
+		return shell.ExecuteLive(squashErrors, "depmod", "-a", kernelVersion)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update the dependencies of the kernel modules:\n%w", err)
+	}
+
+	for filePath, content := range renderKernelModulesConfigs(kernelModules) {
+		fullPath := filepath.Join(installChroot.RootDir(), filePath)
+		err = os.MkdirAll(filepath.Dir(fullPath), configDirMode)
+		if err != nil {
+			return fmt.Errorf("failed to create the directory of (%s):\n%w", filePath, err)
+		}
+
+		err = file.WriteWithPerm(content, fullPath, bootUsrConfigFileMode)
+		if err != nil {
+			return fmt.Errorf("failed to write (%s):\n%w", filePath, err)
+		}
+	}
+
+	return
+}
+
+// getInstalledKernelVersion returns the version of the kernel that is installed in the root directory.
+func getInstalledKernelVersion(rootDir string) (kernelVersion string, err error) {
+	entries, err := os.ReadDir(filepath.Join(rootDir, kernelModulesDir))
+	if err != nil {
+		return "", fmt.Errorf("failed to find the installed kernel:\n%w", err)
+	}
+
+	var kernelVersions []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			kernelVersions = append(kernelVersions, entry.Name())
+		}
+	}
+
+	// Assume only one kernel is installed, like regenerateInitramfs does.
+	if len(kernelVersions) != 1 {
+		return "", fmt.Errorf("unable to find one installed kernel in (%s): %v", kernelModulesDir, kernelVersions)
+	}
+
+	return kernelVersions[0], nil
+}
+
+// renderKernelModulesConfigs renders the modules-load.d, modprobe.d and dracut.conf.d configs of the kernel modules,
+// mapped by their paths. The dracut config keeps the modules in the initramfs when it is regenerated for a kernel
+// update.
+func renderKernelModulesConfigs(kernelModules configuration.KernelModules) (files map[string]string) {
+	var loadAtBoot, options, initrdDrivers []string
+
+	for _, module := range kernelModules.Modules {
+		name := module.GetName()
+		if module.LoadAtBoot {
+			loadAtBoot = append(loadAtBoot, name)
+		}
+		if module.Options != "" {
+			options = append(options, fmt.Sprintf("options %s %s", name, module.Options))
+		}
+		if module.AddToInitrd {
+			initrdDrivers = append(initrdDrivers, name)
+		}
+	}
+
+	files = make(map[string]string)
+	if len(loadAtBoot) > 0 {
+		files[filepath.Join("/etc/modules-load.d", kernelModulesConfigName)] = strings.Join(loadAtBoot, "\n") + "\n"
+	}
+	if len(options) > 0 {
+		files[filepath.Join("/etc/modprobe.d", kernelModulesConfigName)] = strings.Join(options, "\n") + "\n"
+	}
+	if len(initrdDrivers) > 0 {
+		files[filepath.Join("/etc/dracut.conf.d", kernelModulesConfigName)] =
+			fmt.Sprintf("add_drivers+=\" %s \"\n", strings.Join(initrdDrivers, " "))
+	}
+
+	return
+}
+
+// hasInitrdKernelModules returns true if any of the kernel modules is added to the initramfs.
+func hasInitrdKernelModules(kernelModules configuration.KernelModules) bool {
+	for _, module := range kernelModules.Modules {
+		if module.AddToInitrd {
+			return true
+		}
+	}
+	return false
+}
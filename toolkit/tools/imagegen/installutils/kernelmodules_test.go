@@ -0,0 +1,57 @@
+// Copyright Microsoft Corporation.
+// Licensed under the MIT License.
+
+package installutils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/imagegen/configuration"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestMain found in installutils_test.go.
+
+func TestRenderKernelModulesConfigs(t *testing.T) {
+	files := renderKernelModulesConfigs(configuration.KernelModules{
+		Modules: []configuration.KernelModule{
+			{Path: "/tmp/kernelmodules/nvidia.ko", Options: "NVreg_OpenRmEnableUnsupportedGpus=1", LoadAtBoot: true},
+			{Path: "/tmp/kernelmodules/nvidia-uvm.ko", LoadAtBoot: true},
+			{Path: "/tmp/kernelmodules/mlx5-vendor.ko", Options: "num_vfs=8", AddToInitrd: true},
+		},
+	})
+
+	assert.Equal(t, map[string]string{
+		"/etc/modules-load.d/kernel-modules.conf": "nvidia\nnvidia-uvm\n",
+		"/etc/modprobe.d/kernel-modules.conf":     "options nvidia NVreg_OpenRmEnableUnsupportedGpus=1\noptions mlx5-vendor num_vfs=8\n",
+		"/etc/dracut.conf.d/kernel-modules.conf":  "add_drivers+=\" mlx5-vendor \"\n",
+	}, files)
+}
+
+func TestRenderKernelModulesConfigsWithoutSettings(t *testing.T) {
+	files := renderKernelModulesConfigs(configuration.KernelModules{
+		Modules: []configuration.KernelModule{{Path: "/tmp/kernelmodules/nvidia.ko"}},
+	})
+
+	assert.Empty(t, files)
+}
+
+func TestGetInstalledKernelVersion(t *testing.T) {
+	rootDir := t.TempDir()
+	modulesDir := filepath.Join(rootDir, kernelModulesDir)
+
+	_, err := getInstalledKernelVersion(rootDir)
+	assert.Error(t, err)
+
+	assert.NoError(t, os.MkdirAll(filepath.Join(modulesDir, "6.6.47.1-1.azl3"), 0755))
+	kernelVersion, err := getInstalledKernelVersion(rootDir)
+	assert.NoError(t, err)
+	assert.Equal(t, "6.6.47.1-1.azl3", kernelVersion)
+
+	assert.NoError(t, os.MkdirAll(filepath.Join(modulesDir, "6.6.57.1-2.azl3"), 0755))
+	_, err = getInstalledKernelVersion(rootDir)
+	assert.Error(t, err)
+}
@@ -0,0 +1,78 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package installutils
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/imagegen/configuration"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/file"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/safechroot"
+)
+
+const (
+	localeConfFile   = "/etc/locale.conf"
+	vconsoleConfFile = "/etc/vconsole.conf"
+	localtimeFile    = "/etc/localtime"
+	zoneinfoDir      = "/usr/share/zoneinfo"
+)
+
+// configureLocalization writes the system's locale and console keymap into their systemd config files, and links
+// /etc/localtime to the timezone.
+func configureLocalization(installChroot *safechroot.Chroot, localization configuration.Localization) (err error) {
+	if localization.IsEmpty() {
+		return
+	}
+
+	ReportAction("Configuring the locale, timezone and keymap")
+
+	for filePath, content := range renderLocalizationFiles(localization) {
+		err = file.WriteWithPerm(content, filepath.Join(installChroot.RootDir(), filePath), bootUsrConfigFileMode)
+		if err != nil {
+			return fmt.Errorf("failed to write (%s):\n%w", filePath, err)
+		}
+	}
+
+	if localization.Timezone != "" {
+		zoneinfoFile := filepath.Join(zoneinfoDir, localization.Timezone)
+
+		// A missing timezone would leave a dangling link, which the system silently treats as UTC.
+		exists, err := file.PathExists(filepath.Join(installChroot.RootDir(), zoneinfoFile))
+		if err != nil {
+			return fmt.Errorf("failed to check if timezone (%s) exists:\n%w", localization.Timezone, err)
+		}
+		if !exists {
+			return fmt.Errorf("timezone (%s) isn't in (%s), which the tzdata package installs", localization.Timezone,
+				zoneinfoDir)
+		}
+
+		localtimePath := filepath.Join(installChroot.RootDir(), localtimeFile)
+		err = file.RemoveFileIfExists(localtimePath)
+		if err != nil {
+			return fmt.Errorf("failed to remove (%s):\n%w", localtimeFile, err)
+		}
+
+		// systemd expects a relative link, which it reads the timezone's name from.
+		err = os.Symlink(filepath.Join("..", zoneinfoFile), localtimePath)
+		if err != nil {
+			return fmt.Errorf("failed to link (%s) to timezone (%s):\n%w", localtimeFile, localization.Timezone, err)
+		}
+	}
+
+	return
+}
+
+// renderLocalizationFiles renders the locale.conf and vconsole.conf files of the localization, mapped by their paths.
+func renderLocalizationFiles(localization configuration.Localization) (files map[string]string) {
+	files = make(map[string]string)
+	if localization.Locale != "" {
+		files[localeConfFile] = fmt.Sprintf("LANG=%s\n", localization.Locale)
+	}
+	if localization.Keymap != "" {
+		files[vconsoleConfFile] = fmt.Sprintf("KEYMAP=%s\n", localization.Keymap)
+	}
+	return
+}
@@ -0,0 +1,33 @@
+// Copyright Microsoft Corporation.
+// Licensed under the MIT License.
+
+package installutils
+
+import (
+	"testing"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/imagegen/configuration"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestMain found in installutils_test.go.
+
+func TestRenderLocalizationFiles(t *testing.T) {
+	files := renderLocalizationFiles(configuration.Localization{
+		Locale:   "de_DE.UTF-8",
+		Timezone: "Europe/Berlin",
+		Keymap:   "de-latin1",
+	})
+
+	assert.Equal(t, map[string]string{
+		"/etc/locale.conf":   "LANG=de_DE.UTF-8\n",
+		"/etc/vconsole.conf": "KEYMAP=de-latin1\n",
+	}, files)
+}
+
+func TestRenderLocalizationFilesWithOnlyTimezone(t *testing.T) {
+	files := renderLocalizationFiles(configuration.Localization{Timezone: "UTC"})
+
+	assert.Empty(t, files)
+}
@@ -0,0 +1,332 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package installutils
+
+import (
+	"fmt"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/microsoft/azurelinux/toolkit/tools/imagegen/configuration"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/file"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/safechroot"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/shell"
+)
+
+const (
+	networkdConfigDir       = "/etc/systemd/network"
+	resolvedConfigFile      = "/etc/systemd/resolved.conf.d/10-networking.conf"
+	networkManagerKeyfile   = "/etc/NetworkManager/system-connections/%s.nmconnection"
+	networkManagerDNSConfig = "/etc/NetworkManager/conf.d/10-networking.conf"
+
+	// NetworkManager ignores keyfiles that can be read by other users.
+	networkManagerKeyfileMode = 0600
+	networkConfigDirMode      = 0755
+)
+
+// networkPort is a network interface, or bond, that is one of the interfaces of a bond or bridge.
+type networkPort struct {
+	controller string
+	// "bond" or "bridge"
+	kind string
+}
+
+// configureNetworking writes the image's declarative network configuration, and enables the service that applies it.
+func configureNetworking(installChroot *safechroot.Chroot, networking configuration.Networking) (err error) {
+	const (
+		squashErrors = false
+	)
+
+	var (
+		files    map[string]string
+		fileMode os.FileMode
+		services []string
+	)
+
+	if networking.IsEmpty() {
+		return
+	}
+
+	ReportAction("Configuring networking")
+
+	switch networking.Renderer {
+	case configuration.NetworkRendererNetworkManager:
+		files = renderNetworkManagerFiles(networking)
+		fileMode = networkManagerKeyfileMode
+		services = []string{"NetworkManager.service"}
+	default:
+		files = renderNetworkdFiles(networking)
+		fileMode = bootUsrConfigFileMode
+		services = []string{"systemd-networkd.service", "systemd-resolved.service"}
+	}
+
+	for filePath, content := range files {
+		fullPath := filepath.Join(installChroot.RootDir(), filePath)
+		err = os.MkdirAll(filepath.Dir(fullPath), networkConfigDirMode)
+		if err != nil {
+			return fmt.Errorf("failed to create the directory of (%s):\n%w", filePath, err)
+		}
+
+		err = file.WriteWithPerm(content, fullPath, fileMode)
+		if err != nil {
+			return fmt.Errorf("failed to write (%s):\n%w", filePath, err)
+		}
+	}
+
+	err = installChroot.UnsafeRun(func() error {
+		return shell.ExecuteLive(squashErrors, "systemctl", append([]string{"enable"}, services...)...)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to enable (%s):\n%w", strings.Join(services, ", "), err)
+	}
+
+	return
+}
+
+// getNetworkPorts maps the interfaces of the bonds and bridges to the bond or bridge that they are in.
+func getNetworkPorts(networking configuration.Networking) (ports map[string]networkPort) {
+	ports = make(map[string]networkPort)
+	for _, bond := range networking.Bonds {
+		for _, port := range bond.Interfaces {
+			ports[port] = networkPort{controller: bond.Name, kind: "bond"}
+		}
+	}
+	for _, bridge := range networking.Bridges {
+		for _, port := range bridge.Interfaces {
+			ports[port] = networkPort{controller: bridge.Name, kind: "bridge"}
+		}
+	}
+	return
+}
+
+// getUndeclaredPorts returns the interfaces of the bonds and bridges that aren't in [Interfaces], in order.
+func getUndeclaredPorts(networking configuration.Networking) (undeclared []string) {
+	declared := make(map[string]bool)
+	for _, iface := range networking.Interfaces {
+		declared[iface.Name] = true
+	}
+	for _, bond := range networking.Bonds {
+		declared[bond.Name] = true
+	}
+
+	addPorts := func(ports []string) {
+		for _, port := range ports {
+			if !declared[port] {
+				declared[port] = true
+				undeclared = append(undeclared, port)
+			}
+		}
+	}
+	for _, bond := range networking.Bonds {
+		addPorts(bond.Interfaces)
+	}
+	for _, bridge := range networking.Bridges {
+		addPorts(bridge.Interfaces)
+	}
+	return
+}
+
+// renderNetworkdFiles renders the networking into systemd-networkd .netdev and .network files, and a systemd-resolved
+// drop-in for the global DNS servers.
+// Returns the contents of the files, by their paths in the image.
+func renderNetworkdFiles(networking configuration.Networking) (files map[string]string) {
+	files = make(map[string]string)
+	ports := getNetworkPorts(networking)
+
+	vlansByLink := make(map[string][]string)
+	for _, vlan := range networking.Vlans {
+		vlansByLink[vlan.Link] = append(vlansByLink[vlan.Link], vlan.Name)
+	}
+
+	addNetDev := func(name, kind, section string) {
+		content := fmt.Sprintf("[NetDev]\nName=%s\nKind=%s\n", name, kind)
+		if section != "" {
+			content += "\n" + section
+		}
+		files[filepath.Join(networkdConfigDir, fmt.Sprintf("10-%s.netdev", name))] = content
+	}
+
+	addNetwork := func(name, macAddress string, addressing configuration.NetworkAddressing) {
+		var builder strings.Builder
+
+		builder.WriteString("[Match]\n")
+		if macAddress != "" {
+			fmt.Fprintf(&builder, "MACAddress=%s\n", macAddress)
+		} else {
+			fmt.Fprintf(&builder, "Name=%s\n", name)
+		}
+
+		builder.WriteString("\n[Network]\n")
+		if port, found := ports[name]; found {
+			// The bond or bridge is addressed, not its interfaces.
+			if port.kind == "bond" {
+				fmt.Fprintf(&builder, "Bond=%s\n", port.controller)
+			} else {
+				fmt.Fprintf(&builder, "Bridge=%s\n", port.controller)
+			}
+		} else {
+			if addressing.DHCP != "" {
+				fmt.Fprintf(&builder, "DHCP=%s\n", addressing.DHCP)
+			}
+			for _, address := range addressing.Addresses {
+				fmt.Fprintf(&builder, "Address=%s\n", address)
+			}
+			if addressing.Gateway != "" {
+				fmt.Fprintf(&builder, "Gateway=%s\n", addressing.Gateway)
+			}
+			for _, dnsServer := range addressing.DNS {
+				fmt.Fprintf(&builder, "DNS=%s\n", dnsServer)
+			}
+		}
+		for _, vlan := range vlansByLink[name] {
+			fmt.Fprintf(&builder, "VLAN=%s\n", vlan)
+		}
+
+		files[filepath.Join(networkdConfigDir, fmt.Sprintf("10-%s.network", name))] = builder.String()
+	}
+
+	for _, iface := range networking.Interfaces {
+		addNetwork(iface.Name, iface.MACAddress, iface.NetworkAddressing)
+	}
+	for _, port := range getUndeclaredPorts(networking) {
+		addNetwork(port, "", configuration.NetworkAddressing{})
+	}
+	for _, bond := range networking.Bonds {
+		addNetDev(bond.Name, "bond", fmt.Sprintf("[Bond]\nMode=%s\n", bond.GetMode()))
+		addNetwork(bond.Name, "", bond.NetworkAddressing)
+	}
+	for _, bridge := range networking.Bridges {
+		addNetDev(bridge.Name, "bridge", "")
+		addNetwork(bridge.Name, "", bridge.NetworkAddressing)
+	}
+	for _, vlan := range networking.Vlans {
+		addNetDev(vlan.Name, "vlan", fmt.Sprintf("[VLAN]\nId=%d\n", vlan.ID))
+		addNetwork(vlan.Name, "", vlan.NetworkAddressing)
+	}
+
+	if len(networking.DNS) > 0 {
+		files[resolvedConfigFile] = fmt.Sprintf("[Resolve]\nDNS=%s\n", strings.Join(networking.DNS, " "))
+	}
+
+	return
+}
+
+// renderNetworkManagerFiles renders the networking into NetworkManager keyfiles, one for each connection, and a
+// configuration file for the global DNS servers.
+// Returns the contents of the files, by their paths in the image.
+func renderNetworkManagerFiles(networking configuration.Networking) (files map[string]string) {
+	files = make(map[string]string)
+	ports := getNetworkPorts(networking)
+
+	addConnection := func(name, connectionType, macAddress, section string, addressing configuration.NetworkAddressing) {
+		var builder strings.Builder
+
+		fmt.Fprintf(&builder, "[connection]\nid=%s\nuuid=%s\ntype=%s\n", name, getConnectionUUID(name), connectionType)
+		if macAddress == "" {
+			fmt.Fprintf(&builder, "interface-name=%s\n", name)
+		}
+		port, isPort := ports[name]
+		if isPort {
+			fmt.Fprintf(&builder, "master=%s\nslave-type=%s\n", port.controller, port.kind)
+		}
+
+		if macAddress != "" {
+			fmt.Fprintf(&builder, "\n[ethernet]\nmac-address=%s\n", macAddress)
+		}
+		if section != "" {
+			builder.WriteString("\n" + section)
+		}
+
+		// The bond or bridge is addressed, not its interfaces.
+		if !isPort {
+			builder.WriteString(renderNetworkManagerIPSection("ipv4", addressing, netip.Addr.Is4))
+			builder.WriteString(renderNetworkManagerIPSection("ipv6", addressing, netip.Addr.Is6))
+		}
+
+		files[fmt.Sprintf(networkManagerKeyfile, name)] = builder.String()
+	}
+
+	for _, iface := range networking.Interfaces {
+		addConnection(iface.Name, "ethernet", iface.MACAddress, "", iface.NetworkAddressing)
+	}
+	for _, port := range getUndeclaredPorts(networking) {
+		addConnection(port, "ethernet", "", "", configuration.NetworkAddressing{})
+	}
+	for _, bond := range networking.Bonds {
+		addConnection(bond.Name, "bond", "", fmt.Sprintf("[bond]\nmode=%s\n", bond.GetMode()), bond.NetworkAddressing)
+	}
+	for _, bridge := range networking.Bridges {
+		addConnection(bridge.Name, "bridge", "", "", bridge.NetworkAddressing)
+	}
+	for _, vlan := range networking.Vlans {
+		// The parent is referenced by its connection, as an interface that is matched by its MAC address has no
+		// interface name.
+		section := fmt.Sprintf("[vlan]\nid=%d\nparent=%s\n", vlan.ID, getConnectionUUID(vlan.Link))
+		addConnection(vlan.Name, "vlan", "", section, vlan.NetworkAddressing)
+	}
+
+	if len(networking.DNS) > 0 {
+		files[networkManagerDNSConfig] = fmt.Sprintf("[global-dns-domain-*]\nservers=%s\n", strings.Join(networking.DNS, ","))
+	}
+
+	return
+}
+
+// renderNetworkManagerIPSection renders the [ipv4] or [ipv6] section of a keyfile, with the addresses, gateway and DNS
+// servers of the addressing that are in its address family.
+func renderNetworkManagerIPSection(family string, addressing configuration.NetworkAddressing,
+	inFamily func(netip.Addr) bool,
+) string {
+	var (
+		builder   strings.Builder
+		addresses []string
+		gateway   string
+		dns       []string
+	)
+
+	for _, address := range addressing.Addresses {
+		prefix, err := netip.ParsePrefix(address)
+		if err == nil && inFamily(prefix.Addr()) {
+			addresses = append(addresses, address)
+		}
+	}
+	if addr, err := netip.ParseAddr(addressing.Gateway); err == nil && inFamily(addr) {
+		gateway = addressing.Gateway
+	}
+	for _, dnsServer := range addressing.DNS {
+		if addr, err := netip.ParseAddr(dnsServer); err == nil && inFamily(addr) {
+			dns = append(dns, dnsServer)
+		}
+	}
+
+	method := "disabled"
+	switch {
+	case addressing.DHCP == "yes" || addressing.DHCP == family:
+		method = "auto"
+	case len(addresses) > 0:
+		method = "manual"
+	}
+
+	fmt.Fprintf(&builder, "\n[%s]\nmethod=%s\n", family, method)
+	for i, address := range addresses {
+		fmt.Fprintf(&builder, "address%d=%s\n", i+1, address)
+	}
+	if gateway != "" {
+		fmt.Fprintf(&builder, "gateway=%s\n", gateway)
+	}
+	if len(dns) > 0 {
+		fmt.Fprintf(&builder, "dns=%s;\n", strings.Join(dns, ";"))
+	}
+
+	return builder.String()
+}
+
+// getConnectionUUID returns the UUID of the NetworkManager connection of an interface, which is derived from its name
+// so that the image's keyfiles are the same every time that it's built.
+func getConnectionUUID(name string) string {
+	return uuid.NewSHA1(uuid.NameSpaceOID, []byte(name)).String()
+}
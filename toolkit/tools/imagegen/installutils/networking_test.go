@@ -0,0 +1,88 @@
+// Copyright Microsoft Corporation.
+// Licensed under the MIT License.
+
+package installutils
+
+import (
+	"testing"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/imagegen/configuration"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestMain found in installutils_test.go.
+
+var testNetworking = configuration.Networking{
+	Interfaces: []configuration.NetworkInterface{
+		{Name: "eth0", NetworkAddressing: configuration.NetworkAddressing{DHCP: "ipv4"}},
+		{Name: "eth1", MACAddress: "00:15:5d:01:02:03"},
+	},
+	Bonds: []configuration.NetworkBond{
+		{
+			Name:       "bond0",
+			Interfaces: []string{"eth1", "eth2"},
+			NetworkAddressing: configuration.NetworkAddressing{
+				Addresses: []string{"192.168.10.5/24", "fd00::5/64"},
+				Gateway:   "192.168.10.1",
+				DNS:       []string{"192.168.10.1"},
+			},
+		},
+	},
+	Vlans: []configuration.NetworkVlan{
+		{Name: "vlan20", ID: 20, Link: "bond0", NetworkAddressing: configuration.NetworkAddressing{Addresses: []string{"10.20.0.5/16"}}},
+	},
+	Bridges: []configuration.NetworkBridge{
+		{Name: "br0", Interfaces: []string{"eth3"}, NetworkAddressing: configuration.NetworkAddressing{DHCP: "yes"}},
+	},
+	DNS: []string{"1.1.1.1", "2606:4700:4700::1111"},
+}
+
+func TestRenderNetworkdFiles(t *testing.T) {
+	files := renderNetworkdFiles(testNetworking)
+
+	assert.Equal(t, map[string]string{
+		"/etc/systemd/network/10-eth0.network": "[Match]\nName=eth0\n\n[Network]\nDHCP=ipv4\n",
+		"/etc/systemd/network/10-eth1.network": "[Match]\nMACAddress=00:15:5d:01:02:03\n\n[Network]\nBond=bond0\n",
+		"/etc/systemd/network/10-eth2.network": "[Match]\nName=eth2\n\n[Network]\nBond=bond0\n",
+		"/etc/systemd/network/10-eth3.network": "[Match]\nName=eth3\n\n[Network]\nBridge=br0\n",
+		"/etc/systemd/network/10-bond0.netdev": "[NetDev]\nName=bond0\nKind=bond\n\n[Bond]\nMode=active-backup\n",
+		"/etc/systemd/network/10-bond0.network": "[Match]\nName=bond0\n\n[Network]\nAddress=192.168.10.5/24\n" +
+			"Address=fd00::5/64\nGateway=192.168.10.1\nDNS=192.168.10.1\nVLAN=vlan20\n",
+		"/etc/systemd/network/10-br0.netdev":              "[NetDev]\nName=br0\nKind=bridge\n",
+		"/etc/systemd/network/10-br0.network":             "[Match]\nName=br0\n\n[Network]\nDHCP=yes\n",
+		"/etc/systemd/network/10-vlan20.netdev":           "[NetDev]\nName=vlan20\nKind=vlan\n\n[VLAN]\nId=20\n",
+		"/etc/systemd/network/10-vlan20.network":          "[Match]\nName=vlan20\n\n[Network]\nAddress=10.20.0.5/16\n",
+		"/etc/systemd/resolved.conf.d/10-networking.conf": "[Resolve]\nDNS=1.1.1.1 2606:4700:4700::1111\n",
+	}, files)
+}
+
+func TestRenderNetworkManagerFiles(t *testing.T) {
+	const keyfileDir = "/etc/NetworkManager/system-connections/"
+
+	networking := testNetworking
+	networking.Renderer = configuration.NetworkRendererNetworkManager
+	files := renderNetworkManagerFiles(networking)
+
+	assert.Len(t, files, 8)
+	assert.Equal(t, "[connection]\nid=eth0\nuuid="+getConnectionUUID("eth0")+"\ntype=ethernet\ninterface-name=eth0\n"+
+		"\n[ipv4]\nmethod=auto\n\n[ipv6]\nmethod=disabled\n", files[keyfileDir+"eth0.nmconnection"])
+	assert.Equal(t, "[connection]\nid=eth1\nuuid="+getConnectionUUID("eth1")+"\ntype=ethernet\nmaster=bond0\nslave-type=bond\n"+
+		"\n[ethernet]\nmac-address=00:15:5d:01:02:03\n", files[keyfileDir+"eth1.nmconnection"])
+	assert.Equal(t, "[connection]\nid=eth3\nuuid="+getConnectionUUID("eth3")+"\ntype=ethernet\ninterface-name=eth3\n"+
+		"master=br0\nslave-type=bridge\n", files[keyfileDir+"eth3.nmconnection"])
+	assert.Equal(t, "[connection]\nid=bond0\nuuid="+getConnectionUUID("bond0")+"\ntype=bond\ninterface-name=bond0\n"+
+		"\n[bond]\nmode=active-backup\n"+
+		"\n[ipv4]\nmethod=manual\naddress1=192.168.10.5/24\ngateway=192.168.10.1\ndns=192.168.10.1;\n"+
+		"\n[ipv6]\nmethod=manual\naddress1=fd00::5/64\n", files[keyfileDir+"bond0.nmconnection"])
+	assert.Equal(t, "[connection]\nid=vlan20\nuuid="+getConnectionUUID("vlan20")+"\ntype=vlan\ninterface-name=vlan20\n"+
+		"\n[vlan]\nid=20\nparent="+getConnectionUUID("bond0")+"\n"+
+		"\n[ipv4]\nmethod=manual\naddress1=10.20.0.5/16\n\n[ipv6]\nmethod=disabled\n", files[keyfileDir+"vlan20.nmconnection"])
+	assert.Equal(t, "[global-dns-domain-*]\nservers=1.1.1.1,2606:4700:4700::1111\n",
+		files["/etc/NetworkManager/conf.d/10-networking.conf"])
+}
+
+func TestGetConnectionUUIDIsStable(t *testing.T) {
+	assert.Equal(t, getConnectionUUID("eth0"), getConnectionUUID("eth0"))
+	assert.NotEqual(t, getConnectionUUID("eth0"), getConnectionUUID("eth1"))
+}
@@ -0,0 +1,160 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package installutils
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/imagegen/configuration"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/jsonutils"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/rpm"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/shell"
+)
+
+// PackageSignatureReportRelativePath is the report of the signature verification of the image's packages, for
+// auditing.
+const PackageSignatureReportRelativePath = "image_pkg_signatures_installroot.json"
+
+// gpgPubkeyPackageName is the name of the pseudo-packages of the GPG keys that are imported into an rpm database.
+const gpgPubkeyPackageName = "gpg-pubkey"
+
+// PackageSignatureReport is the result of the signature verification of each of the image's packages.
+type PackageSignatureReport struct {
+	GPGKeys  []string                 `json:"GPGKeys"`
+	Packages []PackageSignatureResult `json:"Packages"`
+}
+
+// PackageSignatureResult is the result of the signature verification of a package.
+type PackageSignatureResult struct {
+	Package  string `json:"Package"`
+	RpmFile  string `json:"RpmFile"`
+	Verified bool   `json:"Verified"`
+	Error    string `json:"Error,omitempty"`
+}
+
+// verifyPackageSignatures verifies the signatures of the RPM files of all of the packages that are installed into the
+// install root against the GPG keys, and writes the results to the report. It returns an error if any of the packages
+// isn't signed by one of the keys, or if its RPM file isn't in the RPMs directory.
+func verifyPackageSignatures(installRoot, rpmsDir string, signatures configuration.PackageSignatures) (err error) {
+	if !signatures.Enforce {
+		return
+	}
+
+	ReportAction("Verifying the signatures of the installed packages")
+
+	installedPackages, err := getInstalledPackageNEVRAs(installRoot)
+	if err != nil {
+		return
+	}
+
+	rpmFiles, err := findRPMFiles(rpmsDir)
+	if err != nil {
+		return
+	}
+
+	var foundRPMFiles []string
+	for _, pkg := range installedPackages {
+		if rpmFile, found := rpmFiles[pkg+".rpm"]; found {
+			foundRPMFiles = append(foundRPMFiles, rpmFile)
+		}
+	}
+
+	signatureErrors, err := rpm.CheckRPMSignatures(foundRPMFiles, signatures.GPGKeys)
+	if err != nil {
+		return fmt.Errorf("failed to check the signatures of the installed packages:\n%w", err)
+	}
+
+	report, failedPackages := buildPackageSignatureReport(installedPackages, rpmFiles, signatureErrors,
+		signatures.GPGKeys)
+
+	reportPath := filepath.Join("/", PackageSignatureReportRelativePath)
+	err = jsonutils.WriteJSONFile(reportPath, report)
+	if err != nil {
+		return fmt.Errorf("failed to write the package signature report:\n%w", err)
+	}
+
+	if len(failedPackages) > 0 {
+		return fmt.Errorf("(%d) of the (%d) installed packages aren't signed by the [PackageSignatures] GPG keys:\n%s",
+			len(failedPackages), len(installedPackages), strings.Join(failedPackages, "\n"))
+	}
+
+	return
+}
+
+// getInstalledPackageNEVRAs returns the name-version-release.arch of each package that is installed into the install
+// root, which is also the name of its RPM file, in a stable order.
+func getInstalledPackageNEVRAs(installRoot string) (installedPackages []string, err error) {
+	const queryFormat = "%{NAME}-%{VERSION}-%{RELEASE}.%{ARCH}\n"
+
+	stdout, stderr, err := shell.Execute("rpm", "--root", installRoot, "-qa", "--qf", queryFormat)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the installed packages:\n%v\n%w", stderr, err)
+	}
+
+	for _, pkg := range strings.Split(stdout, "\n") {
+		pkg = strings.TrimSpace(pkg)
+		if pkg == "" || strings.HasPrefix(pkg, gpgPubkeyPackageName+"-") {
+			continue
+		}
+		installedPackages = append(installedPackages, pkg)
+	}
+	sort.Strings(installedPackages)
+
+	return
+}
+
+// findRPMFiles returns the paths of the RPM files in the directory (recursively), mapped by their file names.
+func findRPMFiles(rpmsDir string) (rpmFiles map[string]string, err error) {
+	rpmFiles = make(map[string]string)
+	err = filepath.WalkDir(rpmsDir, func(path string, d os.DirEntry, walkErr error) error {
+		if walkErr != nil {
+			return walkErr
+		}
+		if !d.IsDir() && filepath.Ext(path) == ".rpm" {
+			rpmFiles[filepath.Base(path)] = path
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to find RPM files in (%s):\n%w", rpmsDir, err)
+	}
+	return
+}
+
+// buildPackageSignatureReport builds the report of the signature verification of the installed packages, and returns
+// the packages that failed it, with the reason.
+func buildPackageSignatureReport(installedPackages []string, rpmFiles map[string]string,
+	signatureErrors map[string]error, gpgKeys []string,
+) (report PackageSignatureReport, failedPackages []string) {
+	for _, gpgKey := range gpgKeys {
+		report.GPGKeys = append(report.GPGKeys, filepath.Base(gpgKey))
+	}
+
+	for _, pkg := range installedPackages {
+		result := PackageSignatureResult{Package: pkg}
+
+		rpmFile, found := rpmFiles[pkg+".rpm"]
+		if !found {
+			result.Error = "RPM file not found"
+		} else {
+			result.RpmFile = filepath.Base(rpmFile)
+			if signatureErr, failed := signatureErrors[rpmFile]; failed {
+				result.Error = strings.TrimSpace(signatureErr.Error())
+			} else {
+				result.Verified = true
+			}
+		}
+
+		if !result.Verified {
+			failedPackages = append(failedPackages, fmt.Sprintf("%s: %s", pkg, result.Error))
+		}
+		report.Packages = append(report.Packages, result)
+	}
+
+	return
+}
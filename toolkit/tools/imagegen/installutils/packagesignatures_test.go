@@ -0,0 +1,63 @@
+// Copyright Microsoft Corporation.
+// Licensed under the MIT License.
+
+package installutils
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestMain found in installutils_test.go.
+
+func TestFindRPMFiles(t *testing.T) {
+	rpmsDir := t.TempDir()
+	for _, rpmFile := range []string{"x86_64/bash-5.2.15-3.azl3.x86_64.rpm", "noarch/tzdata-2024a-1.azl3.noarch.rpm",
+		"repodata/repomd.xml"} {
+		path := filepath.Join(rpmsDir, rpmFile)
+		assert.NoError(t, os.MkdirAll(filepath.Dir(path), os.ModePerm))
+		assert.NoError(t, os.WriteFile(path, nil, 0644))
+	}
+
+	rpmFiles, err := findRPMFiles(rpmsDir)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"bash-5.2.15-3.azl3.x86_64.rpm":  filepath.Join(rpmsDir, "x86_64/bash-5.2.15-3.azl3.x86_64.rpm"),
+		"tzdata-2024a-1.azl3.noarch.rpm": filepath.Join(rpmsDir, "noarch/tzdata-2024a-1.azl3.noarch.rpm"),
+	}, rpmFiles)
+}
+
+func TestBuildPackageSignatureReport(t *testing.T) {
+	installedPackages := []string{
+		"bash-5.2.15-3.azl3.x86_64",
+		"contoso-agent-1.0-1.x86_64",
+		"local-tool-0.1-1.x86_64",
+	}
+	rpmFiles := map[string]string{
+		"bash-5.2.15-3.azl3.x86_64.rpm":  "/mnt/cdrom/RPMS/x86_64/bash-5.2.15-3.azl3.x86_64.rpm",
+		"contoso-agent-1.0-1.x86_64.rpm": "/mnt/cdrom/RPMS/x86_64/contoso-agent-1.0-1.x86_64.rpm",
+	}
+	signatureErrors := map[string]error{
+		"/mnt/cdrom/RPMS/x86_64/contoso-agent-1.0-1.x86_64.rpm": fmt.Errorf("signature: NOKEY\n"),
+	}
+
+	report, failedPackages := buildPackageSignatureReport(installedPackages, rpmFiles, signatureErrors,
+		[]string{"/tmp/packagesignaturekeys/keys/MICROSOFT-RPM-GPG-KEY"})
+
+	assert.Equal(t, PackageSignatureReport{
+		GPGKeys: []string{"MICROSOFT-RPM-GPG-KEY"},
+		Packages: []PackageSignatureResult{
+			{Package: "bash-5.2.15-3.azl3.x86_64", RpmFile: "bash-5.2.15-3.azl3.x86_64.rpm", Verified: true},
+			{Package: "contoso-agent-1.0-1.x86_64", RpmFile: "contoso-agent-1.0-1.x86_64.rpm", Error: "signature: NOKEY"},
+			{Package: "local-tool-0.1-1.x86_64", Error: "RPM file not found"},
+		},
+	}, report)
+	assert.Equal(t, []string{
+		"contoso-agent-1.0-1.x86_64: signature: NOKEY",
+		"local-tool-0.1-1.x86_64: RPM file not found",
+	}, failedPackages)
+}
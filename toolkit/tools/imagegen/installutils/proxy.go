@@ -0,0 +1,96 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package installutils
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/imagegen/configuration"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/file"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/safechroot"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/tdnf"
+)
+
+const (
+	// proxyProfileFile sets the proxy in the users' shells, for the tools that they run (e.g. curl).
+	proxyProfileFile = "/etc/profile.d/proxy.sh"
+	// proxySystemdConfFile sets the proxy in the environment of all of the system's services (e.g. containerd).
+	proxySystemdConfFile = "/etc/systemd/system.conf.d/proxy.conf"
+	tdnfConfFile         = "/etc/tdnf/tdnf.conf"
+)
+
+// configureProxy sets the system-wide proxy in the users' shells, in the environment of the system's services and in
+// tdnf's config.
+func configureProxy(installChroot *safechroot.Chroot, proxy configuration.Proxy) (err error) {
+	const configDirMode = 0755
+
+	if proxy.IsEmpty() {
+		return
+	}
+
+	ReportAction("Configuring the proxy")
+
+	for filePath, content := range renderProxyFiles(proxy) {
+		fullPath := filepath.Join(installChroot.RootDir(), filePath)
+		err = os.MkdirAll(filepath.Dir(fullPath), configDirMode)
+		if err != nil {
+			return fmt.Errorf("failed to create the directory of (%s):\n%w", filePath, err)
+		}
+
+		err = file.WriteWithPerm(content, fullPath, bootUsrConfigFileMode)
+		if err != nil {
+			return fmt.Errorf("failed to write (%s):\n%w", filePath, err)
+		}
+	}
+
+	err = tdnf.AddProxyToConfig(filepath.Join(installChroot.RootDir(), tdnfConfFile), proxy.GetTdnfProxy())
+	if err != nil {
+		return fmt.Errorf("failed to set the proxy in (%s):\n%w", tdnfConfFile, err)
+	}
+
+	return
+}
+
+// renderProxyFiles renders the shell profile and the systemd config that set the proxy's environment variables,
+// mapped by their paths.
+func renderProxyFiles(proxy configuration.Proxy) (files map[string]string) {
+	var (
+		profile     strings.Builder
+		environment []string
+	)
+
+	for _, variable := range getProxyEnvironment(proxy) {
+		fmt.Fprintf(&profile, "export %s=\"%s\"\n", variable[0], variable[1])
+		environment = append(environment, fmt.Sprintf("\"%s=%s\"", variable[0], escapeSystemdSpecifiers(variable[1])))
+	}
+
+	return map[string]string{
+		proxyProfileFile:     profile.String(),
+		proxySystemdConfFile: fmt.Sprintf("[Manager]\nDefaultEnvironment=%s\n", strings.Join(environment, " ")),
+	}
+}
+
+// getProxyEnvironment returns the names and values of the proxy's environment variables. Each is set in lowercase, as
+// curl only reads http_proxy in lowercase, and in uppercase, as some other tools only read them in uppercase.
+func getProxyEnvironment(proxy configuration.Proxy) (variables [][2]string) {
+	for _, variable := range []struct {
+		name  string
+		value string
+	}{
+		{"http_proxy", proxy.HTTPProxy},
+		{"https_proxy", proxy.HTTPSProxy},
+		{"no_proxy", strings.Join(proxy.NoProxy, ",")},
+	} {
+		if variable.value == "" {
+			continue
+		}
+		variables = append(variables,
+			[2]string{variable.name, variable.value},
+			[2]string{strings.ToUpper(variable.name), variable.value})
+	}
+	return
+}
@@ -0,0 +1,44 @@
+// Copyright Microsoft Corporation.
+// Licensed under the MIT License.
+
+package installutils
+
+import (
+	"testing"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/imagegen/configuration"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestMain found in installutils_test.go.
+
+func TestRenderProxyFiles(t *testing.T) {
+	files := renderProxyFiles(configuration.Proxy{
+		HTTPProxy:  "http://proxy.contoso.com:3128",
+		HTTPSProxy: "http://proxy.contoso.com:3129",
+		NoProxy:    []string{"localhost", ".contoso.com"},
+	})
+
+	assert.Equal(t, map[string]string{
+		"/etc/profile.d/proxy.sh": `export http_proxy="http://proxy.contoso.com:3128"
+export HTTP_PROXY="http://proxy.contoso.com:3128"
+export https_proxy="http://proxy.contoso.com:3129"
+export HTTPS_PROXY="http://proxy.contoso.com:3129"
+export no_proxy="localhost,.contoso.com"
+export NO_PROXY="localhost,.contoso.com"
+`,
+		"/etc/systemd/system.conf.d/proxy.conf": `[Manager]
+DefaultEnvironment="http_proxy=http://proxy.contoso.com:3128" "HTTP_PROXY=http://proxy.contoso.com:3128" "https_proxy=http://proxy.contoso.com:3129" "HTTPS_PROXY=http://proxy.contoso.com:3129" "no_proxy=localhost,.contoso.com" "NO_PROXY=localhost,.contoso.com"
+`,
+	}, files)
+}
+
+func TestRenderProxyFilesWithEscapedURL(t *testing.T) {
+	files := renderProxyFiles(configuration.Proxy{HTTPSProxy: "http://proxy%2Dwest.contoso.com:3128"})
+
+	assert.Equal(t, "export https_proxy=\"http://proxy%2Dwest.contoso.com:3128\"\nexport HTTPS_PROXY=\"http://proxy%2Dwest.contoso.com:3128\"\n",
+		files["/etc/profile.d/proxy.sh"])
+	assert.Equal(t, "[Manager]\nDefaultEnvironment=\"https_proxy=http://proxy%%2Dwest.contoso.com:3128\" \"HTTPS_PROXY=http://proxy%%2Dwest.contoso.com:3128\"\n",
+		files["/etc/systemd/system.conf.d/proxy.conf"])
+}
@@ -0,0 +1,92 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package installutils
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/logger"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/packagerepo/repocloner"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/shell"
+)
+
+// removePackages removes the packages that the package lists pulled in, but that the image doesn't need (e.g. the
+// dependencies of a base image's package lists), returning the packages that were removed. Only the packages themselves
+// are removed: if another installed package requires one of them, the removal fails instead of also removing the
+// package that requires it.
+func removePackages(installRoot string, packagesToRemove []string) (removedPackages []string, err error) {
+	if len(packagesToRemove) == 0 {
+		return
+	}
+
+	ReportAction("Removing packages")
+
+	for _, pkg := range packagesToRemove {
+		_, _, queryErr := shell.Execute("rpm", "--root", installRoot, "-q", pkg)
+		if queryErr != nil {
+			logger.Log.Warnf("Package (%s) isn't installed, so it can't be removed", pkg)
+			continue
+		}
+		removedPackages = append(removedPackages, pkg)
+	}
+	if len(removedPackages) == 0 {
+		return
+	}
+
+	// Check the dependencies before removing anything, so that a failed removal doesn't leave some of the packages
+	// removed.
+	testArgs := append([]string{"--root", installRoot, "-e", "--test"}, removedPackages...)
+	_, stderr, err := shell.Execute("rpm", testArgs...)
+	if err != nil {
+		failedDependencies := getFailedDependencies(stderr)
+		if len(failedDependencies) > 0 {
+			return nil, fmt.Errorf("can't remove packages (%s), as other installed packages require them:\n%s",
+				strings.Join(removedPackages, ", "), strings.Join(failedDependencies, "\n"))
+		}
+		return nil, fmt.Errorf("failed to check the removal of packages (%s):\n%v\n%w",
+			strings.Join(removedPackages, ", "), stderr, err)
+	}
+
+	for _, pkg := range removedPackages {
+		ReportActionf("Removing: %s", pkg)
+	}
+
+	removeArgs := append([]string{"--root", installRoot, "-e"}, removedPackages...)
+	_, stderr, err = shell.Execute("rpm", removeArgs...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to remove packages (%s):\n%v\n%w", strings.Join(removedPackages, ", "), stderr,
+			err)
+	}
+
+	return
+}
+
+// getFailedDependencies returns the dependencies that rpm reports as broken by a removal, e.g.
+// "libfoo.so.1()(64bit) is needed by (installed) bar-1.0-1.azl3.x86_64".
+func getFailedDependencies(rpmStderr string) (failedDependencies []string) {
+	for _, line := range strings.Split(rpmStderr, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.Contains(line, " is needed by ") {
+			failedDependencies = append(failedDependencies, line)
+		}
+	}
+	return
+}
+
+// removePackagesFromManifest removes the removed packages from the image's package manifest.
+func removePackagesFromManifest(installedPackages *repocloner.RepoContents, removedPackages []string) {
+	removed := make(map[string]bool)
+	for _, pkg := range removedPackages {
+		removed[pkg] = true
+	}
+
+	var remainingPackages []*repocloner.RepoPackage
+	for _, pkg := range installedPackages.Repo {
+		if !removed[pkg.Name] {
+			remainingPackages = append(remainingPackages, pkg)
+		}
+	}
+	installedPackages.Repo = remainingPackages
+}
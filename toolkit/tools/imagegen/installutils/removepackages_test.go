@@ -0,0 +1,43 @@
+// Copyright Microsoft Corporation.
+// Licensed under the MIT License.
+
+package installutils
+
+import (
+	"testing"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/packagerepo/repocloner"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestMain found in installutils_test.go.
+
+func TestGetFailedDependencies(t *testing.T) {
+	stderr := "error: Failed dependencies:\n" +
+		"\tman-db is needed by (installed) man-pages-6.04-1.azl3.noarch\n" +
+		"\tlibpipeline.so.1()(64bit) is needed by (installed) man-db-2.12.0-1.azl3.x86_64\n"
+
+	assert.Equal(t, []string{
+		"man-db is needed by (installed) man-pages-6.04-1.azl3.noarch",
+		"libpipeline.so.1()(64bit) is needed by (installed) man-db-2.12.0-1.azl3.x86_64",
+	}, getFailedDependencies(stderr))
+}
+
+func TestGetFailedDependenciesOfOtherError(t *testing.T) {
+	assert.Empty(t, getFailedDependencies("error: package man-db is not installed\n"))
+}
+
+func TestRemovePackagesFromManifest(t *testing.T) {
+	installedPackages := &repocloner.RepoContents{
+		Repo: []*repocloner.RepoPackage{
+			{Name: "bash", Version: "5.2.15-3.azl3"},
+			{Name: "man-db", Version: "2.12.0-1.azl3"},
+			{Name: "python3-pip", Version: "24.2-1.azl3"},
+		},
+	}
+
+	removePackagesFromManifest(installedPackages, []string{"man-db", "python3-pip"})
+
+	assert.Equal(t, []*repocloner.RepoPackage{{Name: "bash", Version: "5.2.15-3.azl3"}}, installedPackages.Repo)
+}
@@ -0,0 +1,119 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package installutils
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/imagegen/configuration"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/file"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/safechroot"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/shell"
+)
+
+const (
+	repoFileDir   = "/etc/yum.repos.d"
+	rpmGPGKeysDir = "/etc/pki/rpm-gpg"
+)
+
+// installRepos writes the repo files of the repos into the image, and imports their GPG keys into its rpm database.
+func installRepos(installChroot *safechroot.Chroot, repos []configuration.Repo) (err error) {
+	const (
+		gpgKeyFileMode  = 0644
+		repoFileDirMode = 0755
+		squashErrors    = false
+	)
+
+	if len(repos) == 0 {
+		return
+	}
+
+	ReportAction("Configuring repos")
+
+	importedKeys := make(map[string]bool)
+	for _, repo := range repos {
+		for _, gpgKey := range repo.GPGKeys {
+			if importedKeys[gpgKey] {
+				continue
+			}
+			importedKeys[gpgKey] = true
+
+			keyPath := getRepoGPGKeyPath(gpgKey)
+			fileMode := os.FileMode(gpgKeyFileMode)
+			err = installChroot.AddFiles(safechroot.FileToCopy{
+				Src:         gpgKey,
+				Dest:        keyPath,
+				Permissions: &fileMode,
+			})
+			if err != nil {
+				return fmt.Errorf("failed to copy GPG key (%s):\n%w", gpgKey, err)
+			}
+
+			err = installChroot.UnsafeRun(func() error {
+				return shell.ExecuteLive(squashErrors, "rpm", "--import", keyPath)
+			})
+			if err != nil {
+				return fmt.Errorf("failed to import GPG key (%s):\n%w", gpgKey, err)
+			}
+		}
+
+		repoFile := filepath.Join(repoFileDir, repo.ID+".repo")
+		err = os.MkdirAll(filepath.Join(installChroot.RootDir(), repoFileDir), repoFileDirMode)
+		if err != nil {
+			return fmt.Errorf("failed to create the directory of (%s):\n%w", repoFile, err)
+		}
+
+		err = file.WriteWithPerm(renderRepoFile(repo), filepath.Join(installChroot.RootDir(), repoFile),
+			bootUsrConfigFileMode)
+		if err != nil {
+			return fmt.Errorf("failed to write repo file (%s):\n%w", repoFile, err)
+		}
+	}
+
+	return
+}
+
+// renderRepoFile renders the repo file of the repo.
+func renderRepoFile(repo configuration.Repo) string {
+	var builder strings.Builder
+
+	fmt.Fprintf(&builder, "[%s]\n", repo.ID)
+	fmt.Fprintf(&builder, "name=%s\n", repo.GetName())
+	if repo.BaseURL != "" {
+		fmt.Fprintf(&builder, "baseurl=%s\n", repo.BaseURL)
+	} else {
+		fmt.Fprintf(&builder, "metalink=%s\n", repo.Metalink)
+	}
+
+	if len(repo.GPGKeys) > 0 {
+		var gpgKeyURLs []string
+		for _, gpgKey := range repo.GPGKeys {
+			gpgKeyURLs = append(gpgKeyURLs, "file://"+getRepoGPGKeyPath(gpgKey))
+		}
+		fmt.Fprintf(&builder, "gpgkey=%s\n", strings.Join(gpgKeyURLs, " "))
+	}
+
+	fmt.Fprintf(&builder, "gpgcheck=%d\n", boolToInt(repo.GPGCheck))
+	fmt.Fprintf(&builder, "enabled=%d\n", boolToInt(repo.Enabled))
+	if repo.Priority != 0 {
+		fmt.Fprintf(&builder, "priority=%d\n", repo.Priority)
+	}
+
+	return builder.String()
+}
+
+// getRepoGPGKeyPath returns the path of a GPG key in the image.
+func getRepoGPGKeyPath(gpgKey string) string {
+	return filepath.Join(rpmGPGKeysDir, filepath.Base(gpgKey))
+}
+
+func boolToInt(value bool) int {
+	if value {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,47 @@
+// Copyright Microsoft Corporation.
+// Licensed under the MIT License.
+
+package installutils
+
+import (
+	"testing"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/imagegen/configuration"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestMain found in installutils_test.go.
+
+func TestRenderRepoFile(t *testing.T) {
+	repoFile := renderRepoFile(configuration.Repo{
+		ID:       "contoso-extras",
+		Name:     "Contoso Extras",
+		BaseURL:  "https://packages.contoso.com/azurelinux/$releasever/extras/$basearch",
+		GPGKeys:  []string{"/tmp/repogpgkeys/keys/contoso.asc", "/tmp/repogpgkeys/keys/contoso-2025.asc"},
+		GPGCheck: true,
+		Enabled:  true,
+		Priority: 10,
+	})
+
+	assert.Equal(t, "[contoso-extras]\n"+
+		"name=Contoso Extras\n"+
+		"baseurl=https://packages.contoso.com/azurelinux/$releasever/extras/$basearch\n"+
+		"gpgkey=file:///etc/pki/rpm-gpg/contoso.asc file:///etc/pki/rpm-gpg/contoso-2025.asc\n"+
+		"gpgcheck=1\n"+
+		"enabled=1\n"+
+		"priority=10\n", repoFile)
+}
+
+func TestRenderRepoFileWithMetalink(t *testing.T) {
+	repoFile := renderRepoFile(configuration.Repo{
+		ID:       "contoso-mirror",
+		Metalink: "https://mirrors.contoso.com/metalink?repo=base&arch=$basearch",
+	})
+
+	assert.Equal(t, "[contoso-mirror]\n"+
+		"name=contoso-mirror\n"+
+		"metalink=https://mirrors.contoso.com/metalink?repo=base&arch=$basearch\n"+
+		"gpgcheck=0\n"+
+		"enabled=0\n", repoFile)
+}
@@ -30,6 +30,7 @@ var (
 	outDir     = exe.OutputDirFlag(app, "Directory to download packages into.")
 
 	baseDirPath             = app.Flag("base-dir", "Base directory for relative file paths from the config. Defaults to config's directory.").ExistingDir()
+	batchConfig             = app.Flag("batch", "The input config is a batch config: download the packages of all of its images.").Bool()
 	existingRpmDir          = app.Flag("rpm-dir", "Directory that contains already built RPMs. Should contain top level directories for architecture.").Required().ExistingDir()
 	existingToolchainRpmDir = app.Flag("toolchain-rpms-dir", "Directory that contains already built toolchain RPMs. Should contain top level directories for architecture.").Required().ExistingDir()
 	tmpDir                  = app.Flag("tmp-dir", "Directory to store temporary files while downloading.").Required().String()
@@ -111,7 +112,7 @@ func main() {
 
 		timestamp.StopEvent(nil) // restore packages
 	} else {
-		err = cloneSystemConfigs(cloner, *configFile, *baseDirPath, *externalOnly, *inputGraph)
+		err = cloneSystemConfigs(cloner, *configFile, *baseDirPath, *batchConfig, *externalOnly, *inputGraph)
 	}
 
 	if err != nil {
@@ -141,24 +142,37 @@ func main() {
 	timestamp.StopEvent(nil) // finalize cloned packages
 }
 
-func cloneSystemConfigs(cloner repocloner.RepoCloner, configFile, baseDirPath string, externalOnly bool, inputGraph string) (err error) {
+func cloneSystemConfigs(cloner repocloner.RepoCloner, configFile, baseDirPath string, isBatchConfig, externalOnly bool, inputGraph string) (err error) {
 	timestamp.StartEvent("cloning system config", nil)
 	defer timestamp.StopEvent(nil)
 
 	const cloneDeps = true
 
-	cfg, err := configuration.LoadWithAbsolutePaths(configFile, baseDirPath)
-	if err != nil {
-		return
+	var cfgs []configuration.Config
+	if isBatchConfig {
+		// The packages of all of the batch's images are cloned together, so that the images share one package cache.
+		_, cfgs, err = configuration.LoadBatchWithAbsolutePaths(configFile, baseDirPath)
+	} else {
+		var cfg configuration.Config
+		cfg, err = configuration.LoadWithAbsolutePaths(configFile, baseDirPath)
+		cfgs = []configuration.Config{cfg}
 	}
-
-	packageVersionsInConfig, err := installutils.PackageNamesFromConfig(cfg)
 	if err != nil {
 		return
 	}
 
-	// Add kernel packages from KernelOptions
-	packageVersionsInConfig = append(packageVersionsInConfig, installutils.KernelPackages(cfg)...)
+	var packageVersionsInConfig []*pkgjson.PackageVer
+	for _, cfg := range cfgs {
+		var cfgPackageVersions []*pkgjson.PackageVer
+		cfgPackageVersions, err = installutils.PackageNamesFromConfig(cfg)
+		if err != nil {
+			return
+		}
+		packageVersionsInConfig = append(packageVersionsInConfig, cfgPackageVersions...)
+
+		// Add kernel packages from KernelOptions
+		packageVersionsInConfig = append(packageVersionsInConfig, installutils.KernelPackages(cfg)...)
+	}
 
 	if externalOnly {
 		packageVersionsInConfig, err = filterExternalPackagesOnly(packageVersionsInConfig, inputGraph)
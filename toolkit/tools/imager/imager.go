@@ -7,14 +7,17 @@ package main
 
 import (
 	"fmt"
+	"maps"
 	"os"
 	"path"
 	"path/filepath"
+	"slices"
 
 	"github.com/microsoft/azurelinux/toolkit/tools/imagegen/configuration"
 	"github.com/microsoft/azurelinux/toolkit/tools/imagegen/diskutils"
 	"github.com/microsoft/azurelinux/toolkit/tools/imagegen/installutils"
 	"github.com/microsoft/azurelinux/toolkit/tools/internal/customizationmacros"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/directory"
 	"github.com/microsoft/azurelinux/toolkit/tools/internal/exe"
 	"github.com/microsoft/azurelinux/toolkit/tools/internal/file"
 	"github.com/microsoft/azurelinux/toolkit/tools/internal/logger"
@@ -37,11 +40,13 @@ var (
 	baseDirPath      = app.Flag("base-dir", "Base directory for relative file paths from the config. Defaults to config's directory.").ExistingDir()
 	outputDir        = app.Flag("output-dir", "Path to directory to place final image.").ExistingDir()
 	imgContentFile   = app.Flag("output-image-contents", "File that stores list of packages used to compose the image.").String()
+	pkgSignatureFile = app.Flag("output-package-signatures", "File that stores the signature verification results of the image's packages, if the config enforces them.").String()
 	liveInstallFlag  = app.Flag("live-install", "Enable to perform a live install to the disk specified in config file.").Bool()
 	emitProgress     = app.Flag("emit-progress", "Write progress updates to stdout, such as percent complete and current action.").Bool()
 	timestampFile    = app.Flag("timestamp-file", "File that stores timestamps for this program.").String()
 	buildNumber      = app.Flag("build-number", "Build number to be used in the image.").String()
 	repoSnapshotTime = app.Flag("repo-snapshot-time", "Optional: Snapshot time to be added to the image tdnf.conf").String()
+	batchBuild       = app.Flag("batch", "The input config is a batch config: build each of its images into a sub-directory of the output directory named after the image.").Bool()
 	logFlags         = exe.SetupLogFlags(app)
 	profFlags        = exe.SetupProfileFlags(app)
 )
@@ -63,14 +68,42 @@ const (
 	// the install directory
 	sshPubKeysTempDirectory = "/tmp/sshpubkeys"
 
+	// presetFilesTempDirectory is the directory where installutils expects to pick up the systemd preset files to add
+	// into the install directory
+	presetFilesTempDirectory = "/tmp/presetfiles"
+
+	// containerImagesTempDirectory is the directory where installutils expects to pick up the registry credentials
+	// file that the container images are pulled with
+	containerImagesTempDirectory = "/tmp/containerimages"
+
+	// kernelModulesTempDirectory is the directory where installutils expects to pick up the kernel modules and their
+	// signing key to add into the install directory
+	kernelModulesTempDirectory = "/tmp/kernelmodules"
+
+	// caCertificatesTempDirectory is the directory where installutils expects to pick up the CA certificates to add
+	// into the install directory's trust store
+	caCertificatesTempDirectory = "/tmp/cacertificates"
+
+	// repoGPGKeysTempDirectory is the directory where installutils expects to pick up the GPG keys of the repos to
+	// import into the install directory
+	repoGPGKeysTempDirectory = "/tmp/repogpgkeys"
+
+	// packageSignatureKeysTempDirectory is the directory where installutils expects to pick up the GPG keys to verify
+	// the signatures of the install directory's packages against
+	packageSignatureKeysTempDirectory = "/tmp/packagesignaturekeys"
+
 	// kickstartPartitionFile is the file that includes the partitioning schema used by
 	// kickstart installation
 	kickstartPartitionFile = "/tmp/part-include"
+
+	// localRepoMountPoint is the directory of the local repo's RPMs, which the image's packages are installed from
+	localRepoMountPoint = "/mnt/cdrom/RPMS"
+
+	// defaultSystemConfig is the index of the system config that is built, as only one is currently processed
+	defaultSystemConfig = 0
 )
 
 func main() {
-	const defaultSystemConfig = 0
-
 	app.Version(exe.ToolkitVersion)
 	kingpin.MustParse(app.Parse(os.Args[1:]))
 	logger.InitBestEffort(logFlags)
@@ -88,6 +121,12 @@ func main() {
 		installutils.EnableEmittingProgress()
 	}
 
+	if *batchBuild {
+		err = buildBatch(*configFile, *baseDirPath, *outputDir, *buildDir, *imgContentFile, *pkgSignatureFile)
+		logger.PanicOnError(err, "Failed to build batch configuration")
+		return
+	}
+
 	// Parse Config
 	config, err := configuration.LoadWithAbsolutePaths(*configFile, *baseDirPath)
 	logger.PanicOnError(err, "Failed to load configuration file (%s) with base directory (%s)", *configFile, *baseDirPath)
@@ -113,17 +152,87 @@ func main() {
 		timestamp.StopEvent(nil) // applying kickstart
 	}
 
-	err = buildSystemConfig(systemConfig, config.Disks, *outputDir, *buildDir, *imgContentFile)
+	err = buildSystemConfig(systemConfig, config.Disks, config.RaidArrays, *outputDir, *buildDir, *imgContentFile, *pkgSignatureFile, "")
 	logger.PanicOnError(err, "Failed to build system configuration")
 }
 
-func buildSystemConfig(systemConfig configuration.SystemConfig, disks []configuration.Disk, outputDir, buildDir string, imgContentFile string) (err error) {
+// buildBatch builds each image of a batch config into a sub-directory, named after the image, of the output directory.
+// The images are installed from the same local repo, and their setup chroots are copied from a chroot template, so
+// that the worker tarball is only extracted once.
+func buildBatch(batchFile, baseDirPath, outputDir, buildDir, imgContentFile, pkgSignatureFile string) (err error) {
+	const chrootTemplateDirName = "chroottemplate"
+
+	if *liveInstallFlag {
+		return fmt.Errorf("the images of a batch config can't be installed to a disk (--live-install)")
+	}
+
+	batch, configs, err := configuration.LoadBatchWithAbsolutePaths(batchFile, baseDirPath)
+	if err != nil {
+		return fmt.Errorf("failed to load batch configuration file (%s) with base directory (%s):\n%w", batchFile, baseDirPath, err)
+	}
+
+	for i, image := range batch.Images {
+		if configs[i].SystemConfigs[defaultSystemConfig].IsKickStartBoot {
+			return fmt.Errorf("image (%s) is a kickstart installation, which can't be built in a batch", image.Name)
+		}
+	}
+
+	chrootTemplateDir := ""
+	if *tdnfTar != "" {
+		timestamp.StartEvent("creating chroot template", nil)
+		chrootTemplateDir = filepath.Join(buildDir, chrootTemplateDirName)
+		err = os.MkdirAll(chrootTemplateDir, os.ModePerm)
+		if err != nil {
+			return fmt.Errorf("failed to create the chroot template directory (%s):\n%w", chrootTemplateDir, err)
+		}
+		defer os.RemoveAll(chrootTemplateDir)
+
+		err = safechroot.ExtractWorkerTar(chrootTemplateDir, *tdnfTar)
+		if err != nil {
+			return fmt.Errorf("failed to extract the worker tar into the chroot template:\n%w", err)
+		}
+		timestamp.StopEvent(nil) // creating chroot template
+	}
+
+	for i, image := range batch.Images {
+		config := configs[i]
+		imageOutputDir := filepath.Join(outputDir, image.Name)
+		imageBuildDir := filepath.Join(buildDir, image.Name)
+		imageContentFile := batchImageFile(imgContentFile, image.Name)
+		imagePkgSignatureFile := batchImageFile(pkgSignatureFile, image.Name)
+
+		for _, dir := range []string{imageOutputDir, imageBuildDir, filepath.Dir(imageContentFile), filepath.Dir(imagePkgSignatureFile)} {
+			err = os.MkdirAll(dir, os.ModePerm)
+			if err != nil {
+				return fmt.Errorf("failed to create the directory (%s) of image (%s):\n%w", dir, image.Name, err)
+			}
+		}
+
+		logger.Log.Infof("Building image (%s) (%d/%d)", image.Name, i+1, len(batch.Images))
+		err = buildSystemConfig(config.SystemConfigs[defaultSystemConfig], config.Disks, config.RaidArrays, imageOutputDir, imageBuildDir, imageContentFile, imagePkgSignatureFile, chrootTemplateDir)
+		if err != nil {
+			return fmt.Errorf("failed to build image (%s):\n%w", image.Name, err)
+		}
+	}
+
+	return
+}
+
+// batchImageFile returns the path of an image's output file in a batch build, which is in a sub-directory, named after
+// the image, of the output file's directory.
+func batchImageFile(outputFile, imageName string) string {
+	if outputFile == "" {
+		return ""
+	}
+	return filepath.Join(filepath.Dir(outputFile), imageName, filepath.Base(outputFile))
+}
+
+func buildSystemConfig(systemConfig configuration.SystemConfig, disks []configuration.Disk, raidArrays []configuration.RaidArray, outputDir, buildDir string, imgContentFile, pkgSignatureFile, chrootTemplateDir string) (err error) {
 	logger.Log.Infof("Building system configuration (%s)", systemConfig.Name)
 	timestamp.StartEvent("building system config", nil)
 	defer timestamp.StopEvent(nil)
 
 	const (
-		localRepoMountPoint  = "/mnt/cdrom/RPMS"
 		repoFileMountPoint   = "/etc/yum.repos.d"
 		setupRoot            = "/setuproot"
 		installRoot          = "/installroot"
@@ -134,6 +243,11 @@ func buildSystemConfig(systemConfig configuration.SystemConfig, disks []configur
 		leaveChrootOnDisk    = false
 		grub2Package         = "grub2"
 		distroReleasePackage = "azurelinux-release"
+		mdadmPackage         = "mdadm"
+		btrfsPackage         = "btrfs-progs"
+		growpartPackage      = "cloud-utils-growpart"
+		networkdPackage      = "systemd-networkd"
+		resolvedPackage      = "systemd-resolved"
 	)
 
 	var (
@@ -142,6 +256,9 @@ func buildSystemConfig(systemConfig configuration.SystemConfig, disks []configur
 		diskDevPath            string
 		kernelPkg              string
 		encryptedRoot          diskutils.EncryptedRootDevice
+		volumeGroups           []configuration.VolumeGroup
+		abRoot                 configuration.ABRoot
+		raidDiskIndexes        []int
 		partIDToDevPathMap     map[string]string
 		partIDToFsTypeMap      map[string]string
 		mountPointToOverlayMap map[string]*installutils.Overlay
@@ -187,6 +304,8 @@ func buildSystemConfig(systemConfig configuration.SystemConfig, disks []configur
 		timestamp.StartEvent("creating raw disk", nil)
 
 		diskConfig := disks[defaultDiskIndex]
+		volumeGroups = diskConfig.VolumeGroups
+		abRoot = diskConfig.ABRoot
 		diskDevPath, partIDToDevPathMap, partIDToFsTypeMap, isLoopDevice, encryptedRoot, err = setupDisk(buildDir, defaultTempDiskName, *liveInstallFlag, diskConfig, systemConfig.Encryption)
 		if err != nil {
 			return
@@ -204,6 +323,56 @@ func buildSystemConfig(systemConfig configuration.SystemConfig, disks []configur
 			return
 		}
 
+		// Set up the other disks that the RAID arrays are on, and create the RAID arrays
+		if len(raidArrays) > 0 {
+			raidDiskIndexes = getRaidDiskIndexes(disks, raidArrays, defaultDiskIndex)
+			for _, diskIndex := range raidDiskIndexes {
+				var (
+					raidDiskDevPath      string
+					raidDiskIsLoopDevice bool
+					raidPartIDToDevPath  map[string]string
+					raidPartIDToFsType   map[string]string
+				)
+
+				// The root encryption only applies to the first disk.
+				raidDiskDevPath, raidPartIDToDevPath, raidPartIDToFsType, raidDiskIsLoopDevice, _, err = setupDisk(buildDir,
+					raidDiskName(diskIndex), *liveInstallFlag, disks[diskIndex], configuration.RootEncryption{})
+				if err != nil {
+					return
+				}
+
+				if raidDiskIsLoopDevice {
+					defer diskutils.DetachLoopbackDevice(raidDiskDevPath)
+					defer diskutils.BlockOnDiskIO(raidDiskDevPath)
+				}
+
+				maps.Copy(partIDToDevPathMap, raidPartIDToDevPath)
+				maps.Copy(partIDToFsTypeMap, raidPartIDToFsType)
+			}
+
+			err = setupRaidArrays(&systemConfig, raidArrays, partIDToDevPathMap, partIDToFsTypeMap, buildDir)
+			if err != nil {
+				return
+			}
+			packagesToInstall = append(packagesToInstall, mdadmPackage)
+		}
+
+		// The btrfs file systems are checked and managed with btrfs-progs
+		if slices.Contains(slices.Collect(maps.Values(partIDToFsTypeMap)), configuration.BtrfsFsType) {
+			packagesToInstall = append(packagesToInstall, btrfsPackage)
+		}
+
+		// The root partition is grown on the first boot with growpart
+		if systemConfig.GrowRootPartition {
+			packagesToInstall = append(packagesToInstall, growpartPackage)
+		}
+
+		// The declarative networking is applied by systemd-networkd, unless it's rendered for NetworkManager, which
+		// must be in the image's packages.
+		if !systemConfig.Networking.IsEmpty() && systemConfig.Networking.Renderer != configuration.NetworkRendererNetworkManager {
+			packagesToInstall = append(packagesToInstall, networkdPackage, resolvedPackage)
+		}
+
 		// Select the best kernel package for this environment
 		kernelPkg, err = installutils.SelectKernelPackage(systemConfig, *liveInstallFlag)
 		if err != nil {
@@ -241,8 +410,19 @@ func buildSystemConfig(systemConfig configuration.SystemConfig, disks []configur
 		}
 		extraMountPoints = append(extraMountPoints, additionalExtraMountPoints...)
 
-		setupChroot := safechroot.NewChroot(setupChrootDir, existingChrootDir)
-		err = setupChroot.Initialize(*tdnfTar, extraDirectories, extraMountPoints, true)
+		// A batch build copies the chroot template into the setup chroot, instead of extracting the worker tar again
+		setupChrootTar, isExistingChrootDir := *tdnfTar, existingChrootDir
+		if chrootTemplateDir != "" {
+			err = directory.CopyContents(chrootTemplateDir, setupChrootDir)
+			if err != nil {
+				err = fmt.Errorf("failed to copy the chroot template into the setup chroot:\n%w", err)
+				return
+			}
+			setupChrootTar, isExistingChrootDir = "", true
+		}
+
+		setupChroot := safechroot.NewChroot(setupChrootDir, isExistingChrootDir)
+		err = setupChroot.Initialize(setupChrootTar, extraDirectories, extraMountPoints, true)
 		if err != nil {
 			err = fmt.Errorf("failed to create setup chroot:\n%w", err)
 			return
@@ -260,8 +440,17 @@ func buildSystemConfig(systemConfig configuration.SystemConfig, disks []configur
 		timestamp.StopEvent(nil) // create offline install env
 
 		err = setupChroot.Run(func() error {
-			return buildImage(mountPointMap, mountPointToFsTypeMap, mountPointToMountArgsMap, partIDToDevPathMap, partIDToFsTypeMap, mountPointToOverlayMap, packagesToInstall, systemConfig, diskDevPath, encryptedRoot, diffDiskBuild, imgContentFile)
+			return buildImage(mountPointMap, mountPointToFsTypeMap, mountPointToMountArgsMap, partIDToDevPathMap, partIDToFsTypeMap, mountPointToOverlayMap, packagesToInstall, systemConfig, diskDevPath, encryptedRoot, abRoot, diffDiskBuild, imgContentFile)
 		})
+
+		// Extract the package signature report from the 'setuproot' chroot, even if the build failed, as it records
+		// the packages that failed the verification
+		if systemConfig.PackageSignatures.Enforce && pkgSignatureFile != "" {
+			reportErr := moveOutPackageSignatureReport(setupChroot, pkgSignatureFile)
+			if reportErr != nil && err == nil {
+				err = reportErr
+			}
+		}
 		if err != nil {
 			err = fmt.Errorf("failed to build image:\n%w", err)
 			return
@@ -297,9 +486,20 @@ func buildSystemConfig(systemConfig configuration.SystemConfig, disks []configur
 					return
 				}
 			}
+
+			for _, diskIndex := range raidDiskIndexes {
+				if disks[diskIndex].Artifacts != nil {
+					input := filepath.Join(buildDir, raidDiskName(diskIndex))
+					output := filepath.Join(outputDir, fmt.Sprintf("disk%d.raw", diskIndex))
+					err = file.Copy(input, output)
+					if err != nil {
+						return
+					}
+				}
+			}
 		}
 	} else {
-		err = buildImage(mountPointMap, mountPointToFsTypeMap, mountPointToMountArgsMap, partIDToDevPathMap, partIDToFsTypeMap, mountPointToOverlayMap, packagesToInstall, systemConfig, diskDevPath, encryptedRoot, diffDiskBuild, imgContentFile)
+		err = buildImage(mountPointMap, mountPointToFsTypeMap, mountPointToMountArgsMap, partIDToDevPathMap, partIDToFsTypeMap, mountPointToOverlayMap, packagesToInstall, systemConfig, diskDevPath, encryptedRoot, abRoot, diffDiskBuild, imgContentFile)
 		if err != nil {
 			err = fmt.Errorf("failed to build image:\n%w", err)
 			return
@@ -315,6 +515,21 @@ func buildSystemConfig(systemConfig configuration.SystemConfig, disks []configur
 		}
 	}
 
+	// Deactivate the volume groups and stop the RAID arrays, so that the loopback devices can be detached
+	if isOfflineInstall {
+		err = diskutils.DeactivateVolumeGroups(volumeGroups)
+		if err != nil {
+			err = fmt.Errorf("failed to deactivate volume groups:\n%w", err)
+			return
+		}
+
+		err = diskutils.StopRaidArrays(raidArrays)
+		if err != nil {
+			err = fmt.Errorf("failed to stop RAID arrays:\n%w", err)
+			return
+		}
+	}
+
 	return
 }
 
@@ -327,6 +542,30 @@ func setupDiskEncryption(systemConfig *configuration.SystemConfig, encryptedRoot
 			return
 		}
 
+		for _, encryptedDevice := range encryptedRoot.AdditionalDevices {
+			err = diskutils.AddKeyfile(encryptedDevice.Device, encryptedRoot.HostKeyFile, systemConfig.Encryption)
+			if err != nil {
+				err = fmt.Errorf("failed to add default keyfile:\n%w", err)
+				return
+			}
+		}
+
+		if systemConfig.Encryption.TPM2.Enable {
+			// The encrypted partitions are unlocked with the TPM2 token, so the keyfile is only used to enroll the token,
+			// and isn't copied into the image.
+			devices := []string{encryptedRoot.Device}
+			for _, encryptedDevice := range encryptedRoot.AdditionalDevices {
+				devices = append(devices, encryptedDevice.Device)
+			}
+			for _, device := range devices {
+				err = diskutils.EnrollTPM2(device, encryptedRoot.HostKeyFile, systemConfig.Encryption.TPM2)
+				if err != nil {
+					return
+				}
+			}
+			return
+		}
+
 		// Copy the default keyfile into the image
 		if len(systemConfig.AdditionalFiles) == 0 {
 			systemConfig.AdditionalFiles = make(map[string]configuration.FileConfigList)
@@ -365,6 +604,63 @@ func setupRootFS(outputDir, installRoot string) (extraMountPoints []*safechroot.
 	return
 }
 
+// getRaidDiskIndexes returns the indexes of the disks, other than the first disk, that the RAID arrays' devices are on
+func getRaidDiskIndexes(disks []configuration.Disk, raidArrays []configuration.RaidArray, firstDiskIndex int) (diskIndexes []int) {
+	for i, disk := range disks {
+		if i == firstDiskIndex {
+			continue
+		}
+
+		hasRaidDevice := false
+		for _, partition := range disk.Partitions {
+			for _, raidArray := range raidArrays {
+				hasRaidDevice = hasRaidDevice || slices.Contains(raidArray.Devices, partition.ID)
+			}
+		}
+		if hasRaidDevice {
+			diskIndexes = append(diskIndexes, i)
+		}
+	}
+	return
+}
+
+// raidDiskName returns the name of the raw disk file of a disk that a RAID array is on
+func raidDiskName(diskIndex int) string {
+	return fmt.Sprintf("disk%d.raw", diskIndex)
+}
+
+// setupRaidArrays creates the RAID arrays, and adds the mdadm config that assembles them to the image
+func setupRaidArrays(systemConfig *configuration.SystemConfig, raidArrays []configuration.RaidArray,
+	partIDToDevPathMap, partIDToFsTypeMap map[string]string, mdadmConfDir string,
+) (err error) {
+	const (
+		mdadmConfName = "mdadm.conf"
+	)
+
+	mdadmConf, err := diskutils.CreateRaidArrays(raidArrays, partIDToDevPathMap, partIDToFsTypeMap)
+	if err != nil {
+		err = fmt.Errorf("failed to create RAID arrays:\n%w", err)
+		return
+	}
+
+	hostMdadmConf := filepath.Join(mdadmConfDir, mdadmConfName)
+	err = file.Write(mdadmConf, hostMdadmConf)
+	if err != nil {
+		err = fmt.Errorf("failed to write mdadm config:\n%w", err)
+		return
+	}
+
+	// Copy the mdadm config into the image
+	if len(systemConfig.AdditionalFiles) == 0 {
+		systemConfig.AdditionalFiles = make(map[string]configuration.FileConfigList)
+	}
+
+	systemConfig.AdditionalFiles[hostMdadmConf] = configuration.FileConfigList{{Path: diskutils.MdadmConfPath}}
+	logger.Log.Infof("Adding mdadm config to systemConfig additional files")
+
+	return
+}
+
 func setupDisk(outputDir, diskName string, liveInstallFlag bool, diskConfig configuration.Disk, rootEncryption configuration.RootEncryption) (diskDevPath string, partIDToDevPathMap, partIDToFsTypeMap map[string]string, isLoopDevice bool, encryptedRoot diskutils.EncryptedRootDevice, err error) {
 	const (
 		realDiskType = "path"
@@ -496,12 +792,136 @@ func fixupExtraFilesIntoChroot(installChroot *safechroot.Chroot, config *configu
 		filesToCopy = append(filesToCopy, fileToCopy)
 	}
 
+	for i, presetFile := range config.Services.PresetFiles {
+		newFilePath := filepath.Join(presetFilesTempDirectory, presetFile)
+
+		fileToCopy := safechroot.FileToCopy{
+			Src:  presetFile,
+			Dest: newFilePath,
+		}
+
+		config.Services.PresetFiles[i] = newFilePath
+		filesToCopy = append(filesToCopy, fileToCopy)
+	}
+
+	if config.ContainerImages.AuthFile != "" {
+		newFilePath := filepath.Join(containerImagesTempDirectory, config.ContainerImages.AuthFile)
+
+		fileToCopy := safechroot.FileToCopy{
+			Src:  config.ContainerImages.AuthFile,
+			Dest: newFilePath,
+		}
+
+		config.ContainerImages.AuthFile = newFilePath
+		filesToCopy = append(filesToCopy, fileToCopy)
+	}
+
+	kernelModules := &config.KernelModules
+	if kernelModules.IsSigned() {
+		newKeyPath := filepath.Join(kernelModulesTempDirectory, kernelModules.SigningKey)
+		newCertPath := filepath.Join(kernelModulesTempDirectory, kernelModules.SigningCert)
+
+		filesToCopy = append(filesToCopy,
+			safechroot.FileToCopy{Src: kernelModules.SigningKey, Dest: newKeyPath},
+			safechroot.FileToCopy{Src: kernelModules.SigningCert, Dest: newCertPath},
+		)
+		kernelModules.SigningKey = newKeyPath
+		kernelModules.SigningCert = newCertPath
+	}
+
+	for i, module := range kernelModules.Modules {
+		newFilePath := filepath.Join(kernelModulesTempDirectory, module.Path)
+
+		fileToCopy := safechroot.FileToCopy{
+			Src:  module.Path,
+			Dest: newFilePath,
+		}
+
+		kernelModules.Modules[i].Path = newFilePath
+		filesToCopy = append(filesToCopy, fileToCopy)
+	}
+
+	for i, caCertificate := range config.CACertificates {
+		newPath := filepath.Join(caCertificatesTempDirectory, caCertificate)
+
+		// The CA certificates may be directories of certificates.
+		var isDir bool
+		isDir, err = file.IsDir(caCertificate)
+		if err != nil {
+			err = fmt.Errorf("failed to find CA certificate (%s):\n%w", caCertificate, err)
+			return
+		}
+		if isDir {
+			err = installChroot.AddDirs(safechroot.DirToCopy{
+				Src:                  caCertificate,
+				Dest:                 newPath,
+				NewDirPermissions:    0755,
+				ChildFilePermissions: 0644,
+			})
+			if err != nil {
+				return
+			}
+		} else {
+			filesToCopy = append(filesToCopy, safechroot.FileToCopy{
+				Src:  caCertificate,
+				Dest: newPath,
+			})
+		}
+
+		config.CACertificates[i] = newPath
+	}
+
+	for i, repo := range config.Repos {
+		for j, gpgKey := range repo.GPGKeys {
+			newFilePath := filepath.Join(repoGPGKeysTempDirectory, gpgKey)
+
+			fileToCopy := safechroot.FileToCopy{
+				Src:  gpgKey,
+				Dest: newFilePath,
+			}
+
+			config.Repos[i].GPGKeys[j] = newFilePath
+			filesToCopy = append(filesToCopy, fileToCopy)
+		}
+	}
+
+	for i, gpgKey := range config.PackageSignatures.GPGKeys {
+		newFilePath := filepath.Join(packageSignatureKeysTempDirectory, gpgKey)
+
+		fileToCopy := safechroot.FileToCopy{
+			Src:  gpgKey,
+			Dest: newFilePath,
+		}
+
+		config.PackageSignatures.GPGKeys[i] = newFilePath
+		filesToCopy = append(filesToCopy, fileToCopy)
+	}
+
 	err = installChroot.AddFiles(filesToCopy...)
 	return
 }
 
+// moveOutPackageSignatureReport moves the package signature report out of the setup chroot, if the package signatures
+// were verified.
+func moveOutPackageSignatureReport(setupChroot *safechroot.Chroot, reportFile string) (err error) {
+	exists, err := file.PathExists(filepath.Join(setupChroot.RootDir(), installutils.PackageSignatureReportRelativePath))
+	if err != nil {
+		return fmt.Errorf("failed to check if the package signature report exists:\n%w", err)
+	}
+	if !exists {
+		logger.Log.Warnf("No package signature report, as the build failed before the package signatures were verified")
+		return
+	}
+
+	err = setupChroot.MoveOutFile(installutils.PackageSignatureReportRelativePath, reportFile)
+	if err != nil {
+		return fmt.Errorf("failed to move out the package signature report:\n%w", err)
+	}
+	return
+}
+
 func cleanupExtraFiles() (err error) {
-	dirsToRemove := []string{additionalFilesTempDirectory, postInstallScriptTempDirectory, finalizeImageScriptTempDirectory, sshPubKeysTempDirectory}
+	dirsToRemove := []string{additionalFilesTempDirectory, postInstallScriptTempDirectory, finalizeImageScriptTempDirectory, sshPubKeysTempDirectory, presetFilesTempDirectory, containerImagesTempDirectory, kernelModulesTempDirectory, caCertificatesTempDirectory, repoGPGKeysTempDirectory, packageSignatureKeysTempDirectory}
 
 	for _, dir := range dirsToRemove {
 		logger.Log.Infof("Cleaning up directory %s", dir)
@@ -522,7 +942,7 @@ func cleanupExtraFilesInChroot(chroot *safechroot.Chroot) (err error) {
 	return
 }
 
-func buildImage(mountPointMap, mountPointToFsTypeMap, mountPointToMountArgsMap, partIDToDevPathMap, partIDToFsTypeMap map[string]string, mountPointToOverlayMap map[string]*installutils.Overlay, packagesToInstall []string, systemConfig configuration.SystemConfig, diskDevPath string, encryptedRoot diskutils.EncryptedRootDevice, diffDiskBuild bool, imgContentFile string) (err error) {
+func buildImage(mountPointMap, mountPointToFsTypeMap, mountPointToMountArgsMap, partIDToDevPathMap, partIDToFsTypeMap map[string]string, mountPointToOverlayMap map[string]*installutils.Overlay, packagesToInstall []string, systemConfig configuration.SystemConfig, diskDevPath string, encryptedRoot diskutils.EncryptedRootDevice, abRoot configuration.ABRoot, diffDiskBuild bool, imgContentFile string) (err error) {
 	timestamp.StartEvent("building image", nil)
 	defer timestamp.StopEvent(nil)
 	const (
@@ -553,6 +973,11 @@ func buildImage(mountPointMap, mountPointToFsTypeMap, mountPointToMountArgsMap,
 		setupChrootPackages = append(setupChrootPackages, toolingPackage.Name)
 	}
 
+	// The container images are pulled from the setup root
+	if !systemConfig.ContainerImages.IsEmpty() {
+		setupChrootPackages = append(setupChrootPackages, installutils.SkopeoPackage)
+	}
+
 	// Create new chroot for the new image
 	installChroot := safechroot.NewChroot(installRoot, existingChrootDir)
 	extraInstallMountPoints := []*safechroot.MountPoint{}
@@ -599,7 +1024,7 @@ func buildImage(mountPointMap, mountPointToFsTypeMap, mountPointToMountArgsMap,
 	// Populate image contents
 	err = installutils.PopulateInstallRoot(installChroot, packagesToInstall, systemConfig, mountList, mountPointMap,
 		mountPointToFsTypeMap, mountPointToMountArgsMap, partIDToDevPathMap, partIDToFsTypeMap, encryptedRoot,
-		diffDiskBuild)
+		diffDiskBuild, localRepoMountPoint)
 	if err != nil {
 		err = fmt.Errorf("failed to populate image contents:\n%w", err)
 		return
@@ -637,6 +1062,14 @@ func buildImage(mountPointMap, mountPointToFsTypeMap, mountPointToMountArgsMap,
 			err = fmt.Errorf("failed to configure boot loader:\n%w", err)
 			return
 		}
+
+		if abRoot.Enable {
+			err = installutils.ConfigureABRootBootloader(installChroot, partIDToDevPathMap)
+			if err != nil {
+				err = fmt.Errorf("failed to configure A/B root boot loader:\n%w", err)
+				return
+			}
+		}
 	}
 
 	// Preconfigure SELinux labels now since all the changes to the filesystem should be done
@@ -0,0 +1,28 @@
+#!/bin/sh
+# Imports the container images that were preloaded into the image into the container runtime's storage, and removes
+# their archives once they are imported.
+# Usage: container-image-preload <containerd|docker> [containerd namespace]
+set -e
+
+runtime=$1
+namespace=$2
+archivedir=/var/lib/container-images/preload
+
+for archive in "$archivedir"/*.tar; do
+	[ -e "$archive" ] || continue
+
+	case "$runtime" in
+	containerd)
+		ctr --namespace "$namespace" images import "$archive"
+		;;
+	docker)
+		docker load --input "$archive"
+		;;
+	*)
+		echo "Unsupported container runtime ($runtime)" >&2
+		exit 1
+		;;
+	esac
+
+	rm -f "$archive"
+done
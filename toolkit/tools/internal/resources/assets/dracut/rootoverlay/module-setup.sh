@@ -0,0 +1,20 @@
+#!/bin/bash
+# Mounts overlays on the directories of a read-only root that must be writable, like /etc and /var.
+
+check() {
+	# Only include the module when it's added explicitly.
+	return 255
+}
+
+depends() {
+	return 0
+}
+
+installkernel() {
+	instmods overlay
+}
+
+install() {
+	inst_simple /etc/rootoverlay.conf
+	inst_hook pre-pivot 50 "$moddir/rootoverlay-mount.sh"
+}
@@ -0,0 +1,20 @@
+#!/bin/sh
+# Mounts an overlay on each directory of the read-only root that /etc/rootoverlay.conf lists, so that it's writable.
+# The changes are kept in memory, in /run/rootoverlay, so they are lost on reboot.
+
+type warn > /dev/null 2>&1 || . /lib/dracut-lib.sh
+
+[ -f /etc/rootoverlay.conf ] || return 0
+
+while read -r dir; do
+	case "$dir" in
+	"" | \#*)
+		continue
+		;;
+	esac
+
+	overlaydir="/run/rootoverlay$dir"
+	mkdir -p "$overlaydir/upper" "$overlaydir/work"
+	mount -t overlay overlay -o "lowerdir=$NEWROOT$dir,upperdir=$overlaydir/upper,workdir=$overlaydir/work" "$NEWROOT$dir" ||
+		warn "Failed to mount an overlay on ($dir)"
+done < /etc/rootoverlay.conf
@@ -0,0 +1,37 @@
+#!/bin/sh
+# Grows the root partition to fill the rest of its disk, and then grows the root file system to fill the partition.
+set -e
+
+# The device of the root file system, without the btrfs subvolume.
+rootdev=$(findmnt -n -o SOURCE --nofsroot /)
+fstype=$(findmnt -n -o FSTYPE /)
+partname=$(basename "$(readlink -f "$rootdev")")
+disk=/dev/$(lsblk -n -d -o PKNAME "$rootdev")
+partnum=$(cat "/sys/class/block/$partname/partition")
+
+# growpart exits with 1 if the partition already fills the disk.
+rc=0
+growpart "$disk" "$partnum" || rc=$?
+if [ "$rc" -gt 1 ]; then
+	echo "Failed to grow partition ($partnum) of ($disk)" >&2
+	exit "$rc"
+fi
+
+case "$fstype" in
+ext2 | ext3 | ext4)
+	resize2fs "$rootdev"
+	;;
+xfs)
+	xfs_growfs /
+	;;
+btrfs)
+	btrfs filesystem resize max /
+	;;
+*)
+	echo "Can't grow a ($fstype) root file system" >&2
+	exit 1
+	;;
+esac
+
+mkdir -p /var/lib/growroot
+touch /var/lib/growroot/grown
@@ -0,0 +1,21 @@
+#!/bin/sh
+# Fills in the image's hostname template with the machine ID (%m) and the system's serial number (%serial), and sets
+# the result as the static hostname.
+# Usage: hostname-template <template>
+set -e
+
+template=$1
+machineid=$(cat /etc/machine-id)
+
+# Hostnames only have lowercase letters, digits and '-'. The serial number is cut to the length of a machine ID, which
+# the image's hostname template was validated with. Systems without a serial number use their machine ID.
+serial=$(cat /sys/class/dmi/id/product_serial 2>/dev/null || true)
+serial=$(printf '%s' "$serial" | tr 'A-Z' 'a-z' | tr -c 'a-z0-9' '-' | sed 's/^-*//' | cut -c 1-32 | sed 's/-*$//')
+if [ -z "$serial" ]; then
+	serial=$machineid
+fi
+
+hostname=$(printf '%s' "$template" | sed "s/%serial/$serial/g; s/%m/$machineid/g")
+
+echo "$hostname" > /etc/hostname
+echo "$hostname" > /proc/sys/kernel/hostname
@@ -588,6 +588,33 @@ func ValidateDirectoryRPMSignatures(rpmDir string, gpgKeyPaths []string) (err er
 	return nil
 }
 
+// CheckRPMSignatures checks the GPG signatures of the RPM files against the GPG keys, in an isolated RPM database.
+// Unlike ValidateDirectoryRPMSignatures, it checks all of the RPM files, and returns the error of each RPM file whose
+// signature is missing or invalid, mapped by its path.
+func CheckRPMSignatures(rpmFiles []string, gpgKeyPaths []string) (signatureErrors map[string]error, err error) {
+	rpmDbRoot, err := os.MkdirTemp("", "rpm-gpg-check-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary directory for RPM database:\n%w", err)
+	}
+	defer os.RemoveAll(rpmDbRoot)
+
+	err = importGPGKeysToRPMDb(rpmDbRoot, gpgKeyPaths)
+	if err != nil {
+		return nil, err
+	}
+
+	signatureErrors = make(map[string]error)
+	for _, rpmFile := range rpmFiles {
+		logger.Log.Debugf("Validating signature of: %s", filepath.Base(rpmFile))
+		signatureErr := checkRPMSignature(rpmFile, rpmDbRoot)
+		if signatureErr != nil {
+			signatureErrors[rpmFile] = signatureErr
+		}
+	}
+
+	return signatureErrors, nil
+}
+
 // QueryRPMProvides returns what an RPM file provides.
 // This includes any provides made by a generator and files provided by the rpm.
 func QueryRPMProvides(rpmFile string) (provides []string, err error) {
@@ -0,0 +1,143 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package safechroot
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/logger"
+	"github.com/moby/sys/mountinfo"
+	"golang.org/x/sys/unix"
+)
+
+// A Chroot can optionally run its commands in their own private mount namespaces. Then, any mounts that a command
+// leaves behind are destroyed when the command exits, instead of leaking into the host. Mount isolation is off by
+// default, since some callers intentionally mount file systems from within a chroot (e.g. the imager mounts the new
+// image's partitions) and expect them to remain visible outside of the command. Only the commands launched using the
+// Chroot's NewExecBuilder are isolated.
+//
+// When a process enters a new mount namespace, the mount propagation of its root directory (i.e. the chroot's root
+// directory) is changed to private, which requires the root directory to be a mount point. If it isn't, then the root
+// directory is bind mounted onto itself.
+//
+// In addition, when the chroot is closed, any mounts still below the chroot's root directory (that weren't there when
+// the chroot was initialized) are logged. Such mounts weren't made by the isolated commands. So, they are left alone
+// for their owner to unmount, and the chroot's directory isn't removed, since that would delete the contents of the
+// mounted file systems.
+
+// listMountPointsBelow returns the mount points at or below a directory, in the order they were mounted.
+func listMountPointsBelow(dir string) ([]string, error) {
+	mounts, err := mountinfo.GetMounts(mountinfo.PrefixFilter(dir))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list mounts below (%s):\n%w", dir, err)
+	}
+
+	mountPoints := []string(nil)
+	for _, mount := range mounts {
+		mountPoints = append(mountPoints, mount.Mountpoint)
+	}
+	return mountPoints, nil
+}
+
+// SetMountIsolation sets whether the commands launched using the Chroot's NewExecBuilder are run in private mount
+// namespaces. Must be called before Initialize.
+func (c *Chroot) SetMountIsolation(isolateMounts bool) {
+	c.isolateMounts = isolateMounts
+}
+
+// isMountIsolationSetup returns true if the chroot's commands can be run in private mount namespaces.
+func (c *Chroot) isMountIsolationSetup() bool {
+	return c.preexistingMountPoints != nil
+}
+
+// setupMountIsolation prepares the chroot so that its commands can be run in private mount namespaces.
+func (c *Chroot) setupMountIsolation() error {
+	rootDir, err := filepath.Abs(c.rootDir)
+	if err != nil {
+		return fmt.Errorf("failed to get absolute path of chroot directory (%s):\n%w", c.rootDir, err)
+	}
+
+	preexistingMountPoints, err := listMountPointsBelow(rootDir)
+	if err != nil {
+		return err
+	}
+
+	c.preexistingMountPoints = make(map[string]bool)
+	for _, mountPoint := range preexistingMountPoints {
+		c.preexistingMountPoints[mountPoint] = true
+	}
+
+	if !c.preexistingMountPoints[rootDir] {
+		logger.Log.Debugf("Bind mounting chroot directory (%s) onto itself", rootDir)
+
+		// Use a recursive bind, so that any existing mounts below the root directory stay visible.
+		err = unix.Mount(rootDir, rootDir, "", BindMountPointFlags|unix.MS_REC, "")
+		if err != nil {
+			return fmt.Errorf("failed to bind mount chroot directory (%s) onto itself:\n%w", rootDir, err)
+		}
+
+		c.isRootDirBindMounted = true
+	}
+
+	return nil
+}
+
+// checkForLeakedMounts logs any mounts below the chroot's root directory that are not owned by the chroot. Returns an
+// error if there are any and the chroot's directory is going to be removed.
+func (c *Chroot) checkForLeakedMounts(leaveOnDisk bool) error {
+	if !c.isMountIsolationSetup() {
+		return nil
+	}
+
+	rootDir, err := filepath.Abs(c.rootDir)
+	if err != nil {
+		return fmt.Errorf("failed to get absolute path of chroot directory (%s):\n%w", c.rootDir, err)
+	}
+
+	mountPoints, err := listMountPointsBelow(rootDir)
+	if err != nil {
+		return err
+	}
+
+	leakedCount := 0
+	for _, mountPoint := range mountPoints {
+		if mountPoint == rootDir || c.preexistingMountPoints[mountPoint] {
+			continue
+		}
+
+		logger.Log.Warnf("Found leaked mount (%s) in chroot (%s)", mountPoint, c.rootDir)
+		leakedCount++
+	}
+
+	if leakedCount > 0 && !leaveOnDisk {
+		return fmt.Errorf("chroot (%s) has (%d) leaked mounts:\nthey must be unmounted before the chroot is removed",
+			c.rootDir, leakedCount)
+	}
+
+	return nil
+}
+
+// teardownMountIsolation removes the bind mount of the chroot's root directory, if one was created.
+func (c *Chroot) teardownMountIsolation() error {
+	if !c.isRootDirBindMounted {
+		return nil
+	}
+
+	rootDir, err := filepath.Abs(c.rootDir)
+	if err != nil {
+		return fmt.Errorf("failed to get absolute path of chroot directory (%s):\n%w", c.rootDir, err)
+	}
+
+	logger.Log.Debugf("Unmounting chroot directory (%s) bind mount", rootDir)
+
+	// Use a lazy unmount, since the recursive bind mount holds copies of any existing mounts below the root directory.
+	err = unix.Unmount(rootDir, unix.MNT_DETACH)
+	if err != nil {
+		return fmt.Errorf("failed to unmount chroot directory (%s) bind mount:\n%w", c.rootDir, err)
+	}
+
+	c.isRootDirBindMounted = false
+	return nil
+}
@@ -0,0 +1,164 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package safechroot
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/buildpipeline"
+	"github.com/moby/sys/mountinfo"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/sys/unix"
+)
+
+func TestRunShouldNotLeakMounts(t *testing.T) {
+	if !buildpipeline.IsRegularBuild() {
+		// this test only apply to "regular build" pipeline
+		return
+	}
+
+	dir := filepath.Join(t.TempDir(), "TestRunShouldNotLeakMounts")
+	chroot := NewChroot(dir, isExistingDir)
+	chroot.SetMountIsolation(true)
+
+	err := chroot.Initialize(emptyPath, []string{"/mnt"}, hostProgramMountPoints(), true)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer chroot.Close(defaultLeaveOnDisk)
+
+	linkHostProgramDirs(t, chroot.RootDir())
+
+	err = chroot.Run(func() error {
+		return chroot.NewExecBuilder("mount", "-t", "tmpfs", "tmpfs", "/mnt").Execute()
+	})
+	assert.NoError(t, err)
+
+	isMounted, err := mountinfo.Mounted(filepath.Join(chroot.RootDir(), "mnt"))
+	assert.NoError(t, err)
+	assert.False(t, isMounted)
+}
+
+func TestRunShouldNotIsolateMountsByDefault(t *testing.T) {
+	if !buildpipeline.IsRegularBuild() {
+		// this test only apply to "regular build" pipeline
+		return
+	}
+
+	dir := filepath.Join(t.TempDir(), "TestRunShouldNotIsolateMountsByDefault")
+	chroot := NewChroot(dir, isExistingDir)
+
+	err := chroot.Initialize(emptyPath, []string{"/mnt"}, hostProgramMountPoints(), true)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer chroot.Close(defaultLeaveOnDisk)
+
+	linkHostProgramDirs(t, chroot.RootDir())
+
+	err = chroot.Run(func() error {
+		return chroot.NewExecBuilder("mount", "-t", "tmpfs", "tmpfs", "/mnt").Execute()
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// The mount must stay visible outside of the command, like the mounts the imager makes within its chroot.
+	mountPath := filepath.Join(chroot.RootDir(), "mnt")
+	isMounted, err := mountinfo.Mounted(mountPath)
+	assert.NoError(t, err)
+	assert.True(t, isMounted)
+
+	err = unix.Unmount(mountPath, 0)
+	assert.NoError(t, err)
+}
+
+func TestCloseShouldNotRemoveLeakedMounts(t *testing.T) {
+	if !buildpipeline.IsRegularBuild() {
+		// this test only apply to "regular build" pipeline
+		return
+	}
+
+	srcMount := filepath.Join(testDir, "testmount")
+	dir := filepath.Join(t.TempDir(), "TestCloseShouldNotRemoveLeakedMounts")
+	chroot := NewChroot(dir, isExistingDir)
+	chroot.SetMountIsolation(true)
+
+	err := chroot.Initialize(emptyPath, []string{"/leaked"}, []*MountPoint{}, true)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// Mount something into the chroot behind its back.
+	leakedMountPath := filepath.Join(chroot.RootDir(), "leaked")
+	err = unix.Mount(srcMount, leakedMountPath, "", BindMountPointFlags, "")
+	if !assert.NoError(t, err) {
+		chroot.Close(defaultLeaveOnDisk)
+		return
+	}
+
+	err = chroot.Close(defaultLeaveOnDisk)
+	assert.ErrorContains(t, err, "leaked mounts")
+
+	// The leaked mount is left for its owner to unmount and its source must not be deleted.
+	isMounted, err := mountinfo.Mounted(leakedMountPath)
+	assert.NoError(t, err)
+	assert.True(t, isMounted)
+
+	_, err = os.Stat(filepath.Join(srcMount, "testfile.txt"))
+	assert.NoError(t, err)
+
+	err = unix.Unmount(leakedMountPath, 0)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	err = chroot.Close(defaultLeaveOnDisk)
+	assert.NoError(t, err)
+
+	_, err = os.Stat(dir)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestCloseShouldKeepPreexistingMounts(t *testing.T) {
+	if !buildpipeline.IsRegularBuild() {
+		// this test only apply to "regular build" pipeline
+		return
+	}
+
+	srcMount := filepath.Join(testDir, "testmount")
+	dir := filepath.Join(t.TempDir(), "TestCloseShouldKeepPreexistingMounts")
+	preexistingMountPath := filepath.Join(dir, "preexisting")
+
+	err := os.MkdirAll(preexistingMountPath, os.ModePerm)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	err = unix.Mount(srcMount, preexistingMountPath, "", BindMountPointFlags, "")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer unix.Unmount(preexistingMountPath, 0)
+
+	chroot := NewChroot(dir, true)
+	chroot.SetMountIsolation(true)
+	err = chroot.Initialize(emptyPath, []string{}, []*MountPoint{}, true)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	err = chroot.Close(true)
+	assert.NoError(t, err)
+
+	isMounted, err := mountinfo.Mounted(preexistingMountPath)
+	assert.NoError(t, err)
+	assert.True(t, isMounted)
+
+	isMounted, err = mountinfo.Mounted(dir)
+	assert.NoError(t, err)
+	assert.False(t, isMounted)
+}
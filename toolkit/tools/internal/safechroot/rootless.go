@@ -0,0 +1,243 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package safechroot
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/logger"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/shell"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+)
+
+// A rootless chroot doesn't call chroot(2) or mount(2) in the current process, since both require root (or more
+// precisely, CAP_SYS_CHROOT and CAP_SYS_ADMIN). Instead, each command that is launched using the Chroot's
+// NewExecBuilder is wrapped in a call to 'unshare', which creates a new user namespace (where the current user is mapped
+// to root), mount namespace and pid namespace. Within those namespaces, the chroot's mount points are mounted and then
+// the command is run under 'chroot'. When the command exits, the namespaces (and so the mounts and any stray background
+// processes) are destroyed.
+//
+// Since the current process never enters the chroot, Go code within the 'Run' callback (and the commands launched using
+// the 'shell' package directly) still see the host's filesystem. So, a rootless chroot is only suitable for callers
+// that interact with the chroot purely through its commands.
+
+const (
+	rootlessShellProgram = "/bin/sh"
+
+	// The script that sets up the chroot inside the new namespaces.
+	// Positional arguments: <root-dir> <working-dir> <command> [args...]
+	// The mount commands of the chroot's mount points are inserted at '%s'.
+	rootlessScriptTemplate = `set -e
+root="$1"
+workdir="$2"
+shift 2
+%s
+exec chroot "$root" ` + rootlessShellProgram + ` -c 'cd "$0" && exec "$@"' "${workdir:-/}" "$@"
+`
+)
+
+var (
+	rootlessSupportOnce sync.Once
+	rootlessUnshareArgs []string
+	rootlessSupportErr  error
+)
+
+// NewRootlessChroot creates a new Chroot struct that doesn't require root.
+//
+// The commands run inside the chroot use user namespaces instead of chroot(2). If the kernel doesn't allow
+// unprivileged user namespaces, then Initialize falls back to a regular Chroot.
+//
+// Note: Only the commands launched using the Chroot's NewExecBuilder are run inside a rootless chroot. Go code within
+// the 'Run' callback still sees the host's filesystem.
+func NewRootlessChroot(rootDir string, isExistingDir bool) *Chroot {
+	c := NewChroot(rootDir, isExistingDir)
+	c.rootless = true
+	return c
+}
+
+// IsRootless returns true if the Chroot runs commands using user namespaces instead of chroot(2).
+func (c *Chroot) IsRootless() bool {
+	return c.rootless
+}
+
+// detectRootlessSupport returns the 'unshare' command line that creates the namespaces of a rootless chroot, or an
+// error if the kernel (or the host's configuration) forbids it.
+func detectRootlessSupport() ([]string, error) {
+	rootlessSupportOnce.Do(func() {
+		rootlessUnshareArgs, rootlessSupportErr = probeRootlessSupport()
+	})
+	return rootlessUnshareArgs, rootlessSupportErr
+}
+
+func probeRootlessSupport() ([]string, error) {
+	namespaceArgs := []string{
+		"--map-root-user", "--mount", "--propagation", "private", "--pid", "--fork", "--kill-child",
+	}
+
+	candidates := [][]string{
+		// Prefer mapping the user's subordinate ids (see subuid(5)) using newuidmap/newgidmap, so that packages that
+		// own files with other users and groups can be installed.
+		append([]string{"unshare", "--map-auto"}, namespaceArgs...),
+		// Otherwise, only the current user is mapped.
+		append([]string{"unshare"}, namespaceArgs...),
+	}
+
+	var err error
+	for _, candidate := range candidates {
+		// Mounting proc is the most restricted of the mounts (e.g. container runtimes tend to mask parts of the host's
+		// proc), so use it to check if the namespaces are usable.
+		args := append(append([]string(nil), candidate[1:]...), "--mount-proc", "true")
+		err = shell.NewExecBuilder(candidate[0], args...).
+			LogLevel(logrus.TraceLevel, logrus.DebugLevel).
+			ErrorStderrLines(1).
+			Execute()
+		if err == nil {
+			return candidate, nil
+		}
+
+		logger.Log.Debugf("Rootless chroot probe (%s) failed: %s", strings.Join(candidate, " "), err)
+	}
+
+	return nil, fmt.Errorf("unprivileged user namespaces are not available:\n%w", err)
+}
+
+// resolveRootless checks if the rootless chroot can be used. If not, the Chroot falls back to using chroot(2).
+func (c *Chroot) resolveRootless() {
+	if !c.rootless {
+		return
+	}
+
+	unshareArgs, err := detectRootlessSupport()
+	if err != nil {
+		logger.Log.Warnf("Falling back to a privileged chroot (%s):\n%s", c.rootDir, err)
+		c.rootless = false
+		return
+	}
+
+	c.unshareArgs = unshareArgs
+}
+
+// rootlessDefaultMountPoints returns a new copy of the default mount points used by a functional rootless chroot.
+// devtmpfs, sysfs and devpts can't be mounted from within a user namespace. So, the host's are bind mounted instead.
+func rootlessDefaultMountPoints() []*MountPoint {
+	return []*MountPoint{
+		&MountPoint{
+			source: "/dev",
+			target: "/dev",
+			flags:  unix.MS_BIND | unix.MS_REC,
+		},
+		&MountPoint{
+			target: "/proc",
+			fstype: "proc",
+		},
+		&MountPoint{
+			source: "/sys",
+			target: "/sys",
+			flags:  unix.MS_BIND | unix.MS_REC,
+		},
+		&MountPoint{
+			target: "/run",
+			fstype: "tmpfs",
+		},
+	}
+}
+
+// rootlessMountArgs returns the 'mount' command line for a mount point.
+func rootlessMountArgs(rootDir string, mountPoint *MountPoint) []string {
+	args := []string{"mount"}
+
+	options := []string(nil)
+	isBind := mountPoint.flags&unix.MS_BIND != 0
+	if isBind {
+		if mountPoint.flags&unix.MS_REC != 0 {
+			options = append(options, "rbind")
+		} else {
+			options = append(options, "bind")
+		}
+	}
+
+	flagOptions := []struct {
+		flag   uintptr
+		option string
+	}{
+		{unix.MS_RDONLY, "ro"},
+		{unix.MS_NOSUID, "nosuid"},
+		{unix.MS_NODEV, "nodev"},
+		{unix.MS_NOEXEC, "noexec"},
+	}
+	for _, flagOption := range flagOptions {
+		if mountPoint.flags&flagOption.flag != 0 {
+			options = append(options, flagOption.option)
+		}
+	}
+
+	if mountPoint.data != "" {
+		options = append(options, mountPoint.data)
+	}
+
+	if mountPoint.fstype != "" && !isBind {
+		args = append(args, "-t", mountPoint.fstype)
+	}
+
+	if len(options) > 0 {
+		args = append(args, "-o", strings.Join(options, ","))
+	}
+
+	source := mountPoint.source
+	if source == "" {
+		source = mountPoint.fstype
+	}
+
+	args = append(args, source, filepath.Join(rootDir, mountPoint.target))
+	return args
+}
+
+// rootlessCommandWrapper rewrites a command so that it runs inside the rootless chroot.
+func (c *Chroot) rootlessCommandWrapper(command string, args []string, workingDirectory string,
+) (string, []string, string) {
+	mountCommands := []string(nil)
+	for _, mountPoint := range c.mountPoints {
+		mountCommands = append(mountCommands, shellQuoteArgs(rootlessMountArgs(c.rootDir, mountPoint)))
+	}
+
+	script := fmt.Sprintf(rootlessScriptTemplate, strings.Join(mountCommands, "\n"))
+
+	wrappedArgs := append([]string(nil), c.unshareArgs[1:]...)
+	if c.sandbox.NoNetwork {
+		wrappedArgs = append(wrappedArgs, "--net")
+	}
+	wrappedArgs = append(wrappedArgs, "--", rootlessShellProgram, "-c", script, "sh", c.rootDir, workingDirectory,
+		command)
+	wrappedArgs = append(wrappedArgs, args...)
+
+	// The working directory is applied inside the chroot by the script.
+	return c.unshareArgs[0], wrappedArgs, ""
+}
+
+// rootlessRemove removes the chroot's root directory from within a user namespace, since the chroot may contain files
+// owned by the user's subordinate ids.
+func (c *Chroot) rootlessRemove() (err error) {
+	args := append(append([]string(nil), c.unshareArgs[1:]...), "--", "rm", "-rf", "--", c.rootDir)
+	err = shell.NewExecBuilder(c.unshareArgs[0], args...).
+		LogLevel(logrus.TraceLevel, logrus.DebugLevel).
+		ErrorStderrLines(1).
+		Execute()
+	if err != nil {
+		return fmt.Errorf("failed to remove rootless chroot (%s):\n%w", c.rootDir, err)
+	}
+	return nil
+}
+
+// shellQuoteArgs joins a command line into a string that can be safely passed to a POSIX shell.
+func shellQuoteArgs(args []string) string {
+	quotedArgs := make([]string, len(args))
+	for i, arg := range args {
+		quotedArgs[i] = "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+	}
+	return strings.Join(quotedArgs, " ")
+}
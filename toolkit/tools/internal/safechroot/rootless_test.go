@@ -0,0 +1,181 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package safechroot
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/buildpipeline"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/shell"
+	"github.com/moby/sys/mountinfo"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/sys/unix"
+)
+
+var hostProgramDirs = []string{"/bin", "/sbin", "/lib", "/lib64"}
+
+func TestRootlessMountArgs(t *testing.T) {
+	const rootDir = "/chroot"
+
+	assert.Equal(t, []string{"mount", "-o", "rbind", "/dev", "/chroot/dev"},
+		rootlessMountArgs(rootDir, NewMountPoint("/dev", "/dev", "", unix.MS_BIND|unix.MS_REC, "")))
+	assert.Equal(t, []string{"mount", "-o", "bind,ro", "/repo", "/chroot/repo"},
+		rootlessMountArgs(rootDir, NewMountPoint("/repo", "repo", "", BindMountPointFlags|unix.MS_RDONLY, "")))
+	assert.Equal(t, []string{"mount", "-t", "proc", "proc", "/chroot/proc"},
+		rootlessMountArgs(rootDir, NewMountPoint("", "/proc", "proc", 0, "")))
+	assert.Equal(t, []string{"mount", "-t", "overlay", "-o", "lowerdir=/a,upperdir=/b,workdir=/c", "overlay",
+		"/chroot/mnt"},
+		rootlessMountArgs(rootDir, NewMountPoint("overlay", "/mnt", "overlay", 0, "lowerdir=/a,upperdir=/b,workdir=/c")))
+}
+
+func TestShellQuoteArgs(t *testing.T) {
+	assert.Equal(t, `'mount' '-o' 'bind' '/it'\''s' '/a b'`, shellQuoteArgs([]string{"mount", "-o", "bind", "/it's", "/a b"}))
+}
+
+func TestRootlessCommandWrapper(t *testing.T) {
+	chroot := &Chroot{
+		rootDir:     "/chroot",
+		mountPoints: []*MountPoint{NewMountPoint("", "/proc", "proc", 0, "")},
+		rootless:    true,
+		unshareArgs: []string{"unshare", "--map-root-user", "--mount"},
+	}
+
+	command, args, workingDirectory := chroot.rootlessCommandWrapper("rpm", []string{"-qa"}, "/var")
+	assert.Equal(t, "unshare", command)
+	assert.Equal(t, "", workingDirectory)
+	if assert.Len(t, args, 11) {
+		assert.Equal(t, []string{"--map-root-user", "--mount", "--", rootlessShellProgram, "-c"}, args[:5])
+		assert.Contains(t, args[5], `'mount' '-t' 'proc' 'proc' '/chroot/proc'`)
+		assert.Equal(t, []string{"sh", "/chroot", "/var", "rpm", "-qa"}, args[6:])
+	}
+}
+
+func TestRootlessRunShouldRunCommandsInChroot(t *testing.T) {
+	if !buildpipeline.IsRegularBuild() {
+		// this test only apply to "regular build" pipeline
+		return
+	}
+
+	_, err := detectRootlessSupport()
+	if err != nil {
+		t.Skipf("rootless chroots are not supported: %s", err)
+	}
+
+	const expectedFile = "/custom-mount/testfile.txt"
+
+	extraMountPoints := append(hostProgramMountPoints(),
+		NewMountPoint(filepath.Join(testDir, "testmount"), "custom-mount", "", BindMountPointFlags, emptyPath))
+
+	dir := filepath.Join(t.TempDir(), "TestRootlessRunShouldRunCommandsInChroot")
+	chroot := NewRootlessChroot(dir, isExistingDir)
+
+	err = chroot.Initialize(emptyPath, []string{}, extraMountPoints, true)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer chroot.Close(defaultLeaveOnDisk)
+
+	assert.True(t, chroot.IsRootless())
+
+	linkHostProgramDirs(t, chroot.RootDir())
+
+	var userId, fileContents string
+	err = chroot.Run(func() (err error) {
+		userId, _, err = chroot.NewExecBuilder("id", "-u").ExecuteCaptureOuput()
+		if err != nil {
+			return
+		}
+
+		fileContents, _, err = chroot.NewExecBuilder("cat", expectedFile).ExecuteCaptureOuput()
+		return
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "0", strings.TrimSpace(userId))
+	assert.Equal(t, "testcontent", strings.TrimSpace(fileContents))
+
+	// The mounts only exist within the namespaces of the commands.
+	isMounted, err := mountinfo.Mounted(filepath.Join(chroot.RootDir(), "custom-mount"))
+	assert.NoError(t, err)
+	assert.False(t, isMounted)
+}
+
+func TestRootlessRunShouldIsolateCommandsFromHost(t *testing.T) {
+	if !buildpipeline.IsRegularBuild() {
+		// this test only apply to "regular build" pipeline
+		return
+	}
+
+	_, err := detectRootlessSupport()
+	if err != nil {
+		t.Skipf("rootless chroots are not supported: %s", err)
+	}
+
+	testTempDir := t.TempDir()
+
+	// A file on the host that isn't mounted into the chroot.
+	hostFile := filepath.Join(testTempDir, "hostfile.txt")
+	err = os.WriteFile(hostFile, []byte("hostcontent"), 0o644)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	dir := filepath.Join(testTempDir, "TestRootlessRunShouldIsolateCommandsFromHost")
+	chroot := NewRootlessChroot(dir, isExistingDir)
+
+	err = chroot.Initialize(emptyPath, []string{}, hostProgramMountPoints(), true)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer chroot.Close(defaultLeaveOnDisk)
+
+	linkHostProgramDirs(t, chroot.RootDir())
+
+	var chrootErr, hostErr error
+	var chrootPid string
+	err = chroot.Run(func() (err error) {
+		// The chroot's commands can't see the host's files.
+		_, _, chrootErr = chroot.NewExecBuilder("cat", hostFile).ExecuteCaptureOuput()
+
+		// And they run in their own pid namespace.
+		chrootPid, _, err = chroot.NewExecBuilder(rootlessShellProgram, "-c", "echo $$").ExecuteCaptureOuput()
+		if err != nil {
+			return
+		}
+
+		// Other commands are unaffected by the chroot.
+		_, _, hostErr = shell.Execute("cat", hostFile)
+		return
+	})
+	assert.NoError(t, err)
+	assert.Error(t, chrootErr)
+	assert.NoError(t, hostErr)
+	assert.Equal(t, "1", strings.TrimSpace(chrootPid))
+}
+
+// hostProgramMountPoints returns the mount points that borrow the host's programs, so that there is a shell inside the
+// test chroots.
+func hostProgramMountPoints() []*MountPoint {
+	mountPoints := []*MountPoint{
+		NewMountPoint("/usr", "/usr", "", BindMountPointFlags|unix.MS_REC, emptyPath),
+	}
+	for _, dir := range hostProgramDirs {
+		if _, err := os.Readlink(dir); err != nil {
+			mountPoints = append(mountPoints, NewMountPoint(dir, dir, "", BindMountPointFlags|unix.MS_REC, emptyPath))
+		}
+	}
+	return mountPoints
+}
+
+// linkHostProgramDirs recreates the host's merged-usr symlinks (e.g. /bin -> usr/bin) inside a test chroot.
+func linkHostProgramDirs(t *testing.T, rootDir string) {
+	for _, dir := range hostProgramDirs {
+		if target, err := os.Readlink(dir); err == nil {
+			err = os.Symlink(target, filepath.Join(rootDir, dir))
+			assert.NoError(t, err)
+		}
+	}
+}
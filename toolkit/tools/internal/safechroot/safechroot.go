@@ -76,6 +76,22 @@ type Chroot struct {
 
 	isExistingDir        bool
 	includeDefaultMounts bool
+
+	// rootless is true if the commands are run using user namespaces instead of chroot(2). See rootless.go.
+	rootless    bool
+	unshareArgs []string
+
+	// isolateMounts is true if the commands launched using NewExecBuilder are run in private mount namespaces. See
+	// mountisolation.go.
+	isolateMounts          bool
+	isRootDirBindMounted   bool
+	preexistingMountPoints map[string]bool
+
+	// sandbox constrains the commands run inside the chroot. See sandbox.go.
+	sandbox       Sandbox
+	seccompFilter []unix.SockFilter
+	cgroup        *os.File
+	cgroupPath    string
 }
 
 // inChrootMutex guards against multiple Chroots entering their respective Chroots
@@ -275,6 +291,8 @@ func (c *Chroot) Initialize(tarPath string, extraDirectories []string, extraMoun
 	activeChrootsMutex.Lock()
 	defer activeChrootsMutex.Unlock()
 
+	c.resolveRootless()
+
 	if c.isExistingDir {
 		_, err = os.Stat(c.rootDir)
 		if os.IsNotExist(err) {
@@ -303,6 +321,11 @@ func (c *Chroot) Initialize(tarPath string, extraDirectories []string, extraMoun
 	// overwrite an existing directory when isExistingDir is set to false.
 	defer func() {
 		if err != nil {
+			cleanupErr := c.removeCgroup()
+			if cleanupErr != nil {
+				logger.Log.Warnf("Failed to cleanup chroot (%s) cgroup during failed initialization:\n%s", c.rootDir, cleanupErr)
+			}
+
 			if buildpipeline.IsRegularBuild() {
 				// mount/unmount is only supported in regular pipeline
 				// Best effort cleanup in case mountpoint creation failed mid-way through. We will not try again so treat as final attempt.
@@ -320,9 +343,15 @@ func (c *Chroot) Initialize(tarPath string, extraDirectories []string, extraMoun
 		}
 	}()
 
+	err = c.setupSandbox()
+	if err != nil {
+		err = fmt.Errorf("failed to setup sandbox for chroot:\n%w", err)
+		return
+	}
+
 	// Extract a given tarball if necessary
 	if tarPath != "" {
-		err = extractWorkerTar(c.rootDir, tarPath)
+		err = ExtractWorkerTar(c.rootDir, tarPath)
 		if err != nil {
 			err = fmt.Errorf("failed to extract worker tar:\n%w", err)
 			return
@@ -350,7 +379,11 @@ func (c *Chroot) Initialize(tarPath string, extraDirectories []string, extraMoun
 		}
 
 		if includeDefaultMounts {
-			allMountPoints = append(allMountPoints, defaultMountPoints()...)
+			if c.rootless {
+				allMountPoints = append(allMountPoints, rootlessDefaultMountPoints()...)
+			} else {
+				allMountPoints = append(allMountPoints, defaultMountPoints()...)
+			}
 		}
 
 		for _, mountPoint := range extraMountPoints {
@@ -363,6 +396,14 @@ func (c *Chroot) Initialize(tarPath string, extraDirectories []string, extraMoun
 		c.mountPoints = allMountPoints
 		c.includeDefaultMounts = includeDefaultMounts
 
+		if c.isolateMounts && !c.rootless {
+			err = c.setupMountIsolation()
+			if err != nil {
+				err = fmt.Errorf("failed to setup mount isolation for chroot:\n%w", err)
+				return
+			}
+		}
+
 		// Mount with the original unsorted order. Assumes the order of mounts is important.
 		err = c.createMountPoints()
 		if err != nil {
@@ -507,6 +548,12 @@ func (c *Chroot) Run(toRun func() error) (err error) {
 func (c *Chroot) UnsafeRun(toRun func() error) (err error) {
 	const fsRoot = "/"
 
+	if c.rootless {
+		// The commands are moved into the chroot by NewExecBuilder. See rootless.go.
+		logger.Log.Debugf("Entering rootless Chroot: '%s'", c.rootDir)
+		return toRun()
+	}
+
 	originalRoot, err := os.Open(fsRoot)
 	if err != nil {
 		return
@@ -539,6 +586,34 @@ func (c *Chroot) UnsafeRun(toRun func() error) (err error) {
 	return
 }
 
+// NewExecBuilder initializes a new execution builder object for a command that is run inside the Chroot. Must be
+// called from within Run (or UnsafeRun).
+//
+// Unlike the commands launched using shell.NewExecBuilder, these commands are moved into the namespaces of a rootless
+// Chroot, are constrained by the Chroot's sandbox and are run in a private mount namespace, if the Chroot has mount
+// isolation enabled.
+func (c *Chroot) NewExecBuilder(command string, args ...string) shell.ExecBuilder {
+	b := shell.NewExecBuilder(command, args...)
+
+	unshareFlags := uintptr(0)
+	if c.rootless {
+		// The namespaces of a rootless chroot are created by 'unshare'.
+		b = b.CommandWrapper(c.rootlessCommandWrapper)
+	} else {
+		if c.isMountIsolationSetup() {
+			unshareFlags |= unix.CLONE_NEWNS
+		}
+
+		if c.sandbox.NoNetwork {
+			unshareFlags |= unix.CLONE_NEWNET
+		}
+	}
+
+	return b.Unshare(unshareFlags).
+		Cgroup(c.cgroup).
+		SeccompFilter(c.seccompFilter)
+}
+
 // RootDir returns the Chroot's root directory.
 func (c *Chroot) RootDir() string {
 	return c.rootDir
@@ -575,6 +650,12 @@ func (c *Chroot) Close(leaveOnDisk bool) (err error) {
 			logger.Log.Warnf("Failed to stop GPG components while tearing down the (%s) chroot: %s", c.rootDir, err)
 		}
 
+		// Kill any processes the chroot's commands left behind.
+		err = c.removeCgroup()
+		if err != nil {
+			logger.Log.Warnf("Failed to remove the (%s) chroot's cgroup: %s", c.rootDir, err)
+		}
+
 		// mount is only supported in regular pipeline
 		err = c.unmountAndRemove(leaveOnDisk, unmountTypeNormal)
 		if err != nil {
@@ -591,6 +672,11 @@ func (c *Chroot) Close(leaveOnDisk bool) (err error) {
 			activeChroots = newActiveChroots
 		}
 	} else {
+		err = c.removeCgroup()
+		if err != nil {
+			logger.Log.Warnf("Failed to remove the (%s) chroot's cgroup: %s", c.rootDir, err)
+		}
+
 		// release chroot dir
 		err = buildpipeline.ReleaseChrootDir(c.rootDir)
 	}
@@ -652,7 +738,12 @@ func cleanupAllChroots() {
 		logger.Log.Info("Cleaning up all active chroots")
 		for i := len(activeChroots) - 1; i >= 0; i-- {
 			logger.Log.Infof("Cleaning up chroot (%s)", activeChroots[i].rootDir)
-			err := activeChroots[i].unmountAndRemove(leaveChrootOnDisk, unmountTypeLazy)
+			err := activeChroots[i].removeCgroup()
+			if err != nil {
+				logger.Log.Warnf("Failed to remove the (%s) chroot's cgroup: %s", activeChroots[i].rootDir, err)
+			}
+
+			err = activeChroots[i].unmountAndRemove(leaveChrootOnDisk, unmountTypeLazy)
 			// Perform best effort cleanup: unmount as many chroots as possible,
 			// even if one fails.
 			if err != nil {
@@ -736,8 +827,23 @@ func (c *Chroot) unmountAndRemove(leaveOnDisk, lazyUnmount bool) (err error) {
 		}
 	}
 
+	// Verify that nothing else was left mounted inside the chroot.
+	err = c.checkForLeakedMounts(leaveOnDisk)
+	if err != nil {
+		return
+	}
+
+	err = c.teardownMountIsolation()
+	if err != nil {
+		return
+	}
+
 	if !leaveOnDisk {
-		err = os.RemoveAll(c.rootDir)
+		if c.rootless {
+			err = c.rootlessRemove()
+		} else {
+			err = os.RemoveAll(c.rootDir)
+		}
 	}
 
 	return
@@ -805,6 +911,11 @@ func (c *Chroot) createMountPoints() (err error) {
 			return fmt.Errorf("failed to create directory (%s)", fullPath)
 		}
 
+		if c.rootless {
+			// The mount points of a rootless chroot are mounted each time a command is run.
+			continue
+		}
+
 		err = unix.Mount(mountPoint.source, fullPath, mountPoint.fstype, mountPoint.flags, mountPoint.data)
 		if err != nil {
 			return fmt.Errorf("failed to mount (%s) to (%s):\n%w", mountPoint.source, fullPath, err)
@@ -816,8 +927,8 @@ func (c *Chroot) createMountPoints() (err error) {
 	return
 }
 
-// extractWorkerTar uses tar with gzip or pigz to setup a chroot directory using a rootfs tar
-func extractWorkerTar(chroot string, workerTar string) (err error) {
+// ExtractWorkerTar uses tar with gzip or pigz to setup a chroot directory using a rootfs tar
+func ExtractWorkerTar(chroot string, workerTar string) (err error) {
 	gzipTool, err := systemdependency.GzipTool()
 	if err != nil {
 		return err
@@ -841,6 +952,11 @@ func (c *Chroot) GetMountPoints() []*MountPoint {
 // E.g. when installing the azurelinux-repos-shared package, a GPG import occurs. This starts the gpg-agent process inside the chroot.
 // To be able to cleanly exit the setup chroot, we must stop it.
 func (c *Chroot) stopGPGComponents() (err error) {
+	if c.rootless {
+		// Each command of a rootless chroot runs in its own pid namespace, which kills all of its processes on exit.
+		return
+	}
+
 	if !c.includeDefaultMounts {
 		// gpgconf doesn't work if it doesn't have access to /proc.
 		return
@@ -0,0 +1,323 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package safechroot
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/file"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/logger"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/retry"
+	"golang.org/x/sys/unix"
+)
+
+// SeccompProfile selects the syscalls that the commands run inside a Chroot are forbidden from calling.
+type SeccompProfile string
+
+const (
+	// SeccompProfileNone doesn't apply a seccomp filter.
+	SeccompProfileNone SeccompProfile = ""
+	// SeccompProfileDefault forbids the syscalls that change the state of the build host's kernel (e.g. loading kernel
+	// modules, rebooting, setting the system clock).
+	SeccompProfileDefault SeccompProfile = "default"
+	// SeccompProfileStrict forbids the syscalls of SeccompProfileDefault plus the syscalls that can be used to inspect
+	// or tamper with other processes (e.g. ptrace) and the kernel keyrings.
+	SeccompProfileStrict SeccompProfile = "strict"
+)
+
+// Sandbox constrains the commands run inside a Chroot, so that a runaway command can't take down the build host.
+type Sandbox struct {
+	// MemoryMax is the maximum amount of memory (in bytes) that the commands may use. 0 means no limit.
+	MemoryMax uint64
+	// CpuMax is the maximum number of CPUs worth of time that the commands may use (e.g. 1.5). 0 means no limit.
+	CpuMax float64
+	// PidsMax is the maximum number of processes that the commands may have running at once. 0 means no limit.
+	PidsMax uint64
+	// NoNetwork runs the commands in a new network namespace, which has no network interfaces (not even loopback).
+	NoNetwork bool
+	// SeccompProfile selects the seccomp filter applied to the commands.
+	SeccompProfile SeccompProfile
+}
+
+const (
+	cgroupRootDir = "/sys/fs/cgroup"
+	cpuMaxPeriod  = 100000
+)
+
+var cgroupCounter atomic.Uint64
+
+// SetSandbox sets the constraints of the commands launched using the Chroot's NewExecBuilder. Must be called before
+// Initialize.
+func (c *Chroot) SetSandbox(sandbox Sandbox) {
+	c.sandbox = sandbox
+}
+
+// hasResourceLimits returns true if the sandbox requires a cgroup.
+func (s *Sandbox) hasResourceLimits() bool {
+	return s.MemoryMax > 0 || s.CpuMax > 0 || s.PidsMax > 0
+}
+
+// setupSandbox creates the resources needed to constrain the chroot's commands.
+func (c *Chroot) setupSandbox() (err error) {
+	if c.sandbox.CpuMax < 0 {
+		return fmt.Errorf("invalid sandbox CPU limit (%v)", c.sandbox.CpuMax)
+	}
+
+	c.seccompFilter, err = seccompFilter(c.sandbox.SeccompProfile)
+	if err != nil {
+		return err
+	}
+
+	if c.sandbox.hasResourceLimits() {
+		err = c.createCgroup()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// createCgroup creates a cgroup (v2) with the sandbox's resource limits.
+func (c *Chroot) createCgroup() error {
+	exists, err := file.PathExists(filepath.Join(cgroupRootDir, "cgroup.controllers"))
+	if err != nil {
+		return fmt.Errorf("failed to check for cgroup v2:\n%w", err)
+	}
+	if !exists {
+		return fmt.Errorf("resource limits require cgroup v2 to be mounted at (%s)", cgroupRootDir)
+	}
+
+	settings := cgroupSettings(c.sandbox)
+
+	// Enable the required controllers for the new cgroup.
+	for _, controller := range []string{"memory", "cpu", "pids"} {
+		if !cgroupSettingsUseController(settings, controller) {
+			continue
+		}
+
+		err = os.WriteFile(filepath.Join(cgroupRootDir, "cgroup.subtree_control"), []byte("+"+controller), 0)
+		if err != nil {
+			return fmt.Errorf("failed to enable cgroup controller (%s):\n%w", controller, err)
+		}
+	}
+
+	cgroupPath := filepath.Join(cgroupRootDir, fmt.Sprintf("safechroot-%d-%d", os.Getpid(), cgroupCounter.Add(1)))
+
+	logger.Log.Debugf("Creating cgroup (%s) for chroot (%s)", cgroupPath, c.rootDir)
+
+	err = os.Mkdir(cgroupPath, 0o755)
+	if err != nil {
+		return fmt.Errorf("failed to create cgroup (%s):\n%w", cgroupPath, err)
+	}
+	c.cgroupPath = cgroupPath
+
+	for _, setting := range settings {
+		err = os.WriteFile(filepath.Join(cgroupPath, setting.name), []byte(setting.value), 0)
+		if err != nil && setting.optional && errors.Is(err, os.ErrNotExist) {
+			logger.Log.Debugf("Skipping unsupported cgroup setting (%s)", setting.name)
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to set cgroup (%s) setting (%s) to (%s):\n%w", cgroupPath, setting.name,
+				setting.value, err)
+		}
+	}
+
+	c.cgroup, err = os.Open(cgroupPath)
+	if err != nil {
+		return fmt.Errorf("failed to open cgroup (%s):\n%w", cgroupPath, err)
+	}
+
+	return nil
+}
+
+type cgroupSetting struct {
+	name  string
+	value string
+	// Skip the setting if the kernel doesn't support it.
+	optional bool
+}
+
+// cgroupSettings returns the cgroup files that implement the sandbox's resource limits.
+func cgroupSettings(sandbox Sandbox) []cgroupSetting {
+	settings := []cgroupSetting(nil)
+
+	if sandbox.MemoryMax > 0 {
+		settings = append(settings,
+			cgroupSetting{name: "memory.max", value: strconv.FormatUint(sandbox.MemoryMax, 10)},
+			// Don't allow the memory limit to be sidestepped using swap.
+			cgroupSetting{name: "memory.swap.max", value: "0", optional: true},
+		)
+	}
+
+	if sandbox.CpuMax > 0 {
+		quota := max(int64(sandbox.CpuMax*cpuMaxPeriod), 1000)
+		settings = append(settings, cgroupSetting{name: "cpu.max", value: fmt.Sprintf("%d %d", quota, cpuMaxPeriod)})
+	}
+
+	if sandbox.PidsMax > 0 {
+		settings = append(settings, cgroupSetting{name: "pids.max", value: strconv.FormatUint(sandbox.PidsMax, 10)})
+	}
+
+	return settings
+}
+
+func cgroupSettingsUseController(settings []cgroupSetting, controller string) bool {
+	for _, setting := range settings {
+		if strings.HasPrefix(setting.name, controller+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// removeCgroup kills any processes left in the chroot's cgroup and then removes the cgroup.
+func (c *Chroot) removeCgroup() error {
+	const (
+		retryAttempts = 5
+		retryDuration = 100 * time.Millisecond
+	)
+
+	if c.cgroup != nil {
+		c.cgroup.Close()
+		c.cgroup = nil
+	}
+
+	if c.cgroupPath == "" {
+		return nil
+	}
+
+	// cgroup.kill requires Linux v5.14 or newer. So, errors are ignored.
+	err := os.WriteFile(filepath.Join(c.cgroupPath, "cgroup.kill"), []byte("1"), 0)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		logger.Log.Debugf("Failed to kill processes of cgroup (%s): %s", c.cgroupPath, err)
+	}
+
+	// The cgroup can only be removed once all of its processes have exited.
+	err = retry.Run(func() error {
+		return os.Remove(c.cgroupPath)
+	}, retryAttempts, retryDuration)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove cgroup (%s):\n%w", c.cgroupPath, err)
+	}
+
+	c.cgroupPath = ""
+	return nil
+}
+
+// seccompDeniedSyscalls returns the syscalls forbidden by a seccomp profile.
+func seccompDeniedSyscalls(profile SeccompProfile) ([]uintptr, error) {
+	defaultSyscalls := []uintptr{
+		unix.SYS_ACCT,
+		unix.SYS_ADJTIMEX,
+		unix.SYS_BPF,
+		unix.SYS_CLOCK_ADJTIME,
+		unix.SYS_CLOCK_SETTIME,
+		unix.SYS_DELETE_MODULE,
+		unix.SYS_FINIT_MODULE,
+		unix.SYS_INIT_MODULE,
+		unix.SYS_KEXEC_FILE_LOAD,
+		unix.SYS_KEXEC_LOAD,
+		unix.SYS_OPEN_BY_HANDLE_AT,
+		unix.SYS_PERF_EVENT_OPEN,
+		unix.SYS_QUOTACTL,
+		unix.SYS_REBOOT,
+		unix.SYS_SETTIMEOFDAY,
+		unix.SYS_SWAPOFF,
+		unix.SYS_SWAPON,
+		unix.SYS_SYSLOG,
+	}
+
+	strictSyscalls := []uintptr{
+		unix.SYS_ADD_KEY,
+		unix.SYS_KEYCTL,
+		unix.SYS_PROCESS_VM_READV,
+		unix.SYS_PROCESS_VM_WRITEV,
+		unix.SYS_PTRACE,
+		unix.SYS_REQUEST_KEY,
+	}
+
+	switch profile {
+	case SeccompProfileNone:
+		return nil, nil
+
+	case SeccompProfileDefault:
+		return defaultSyscalls, nil
+
+	case SeccompProfileStrict:
+		return append(defaultSyscalls, strictSyscalls...), nil
+
+	default:
+		return nil, fmt.Errorf("invalid seccomp profile (%s)", profile)
+	}
+}
+
+// seccompFilter builds the seccomp (BPF) program that implements a seccomp profile.
+// Forbidden syscalls fail with EPERM.
+func seccompFilter(profile SeccompProfile) ([]unix.SockFilter, error) {
+	const (
+		// The offsets of the fields of 'struct seccomp_data'.
+		seccompDataNrOffset   = 0
+		seccompDataArchOffset = 4
+
+		// The x32 ABI's syscall numbers have this bit set.
+		x32SyscallBit = 0x40000000
+
+		retDeny  = unix.SECCOMP_RET_ERRNO | uint32(unix.EPERM)
+		retAllow = unix.SECCOMP_RET_ALLOW
+	)
+
+	deniedSyscalls, err := seccompDeniedSyscalls(profile)
+	if err != nil {
+		return nil, err
+	}
+
+	if deniedSyscalls == nil {
+		return nil, nil
+	}
+
+	var auditArch uint32
+	switch runtime.GOARCH {
+	case "amd64":
+		auditArch = unix.AUDIT_ARCH_X86_64
+	case "arm64":
+		auditArch = unix.AUDIT_ARCH_AARCH64
+	default:
+		return nil, fmt.Errorf("seccomp profiles are not supported on (%s)", runtime.GOARCH)
+	}
+
+	filter := []unix.SockFilter{
+		// Deny syscalls of other architectures (e.g. 32-bit syscalls), since they use different syscall numbers.
+		{Code: unix.BPF_LD | unix.BPF_W | unix.BPF_ABS, K: seccompDataArchOffset},
+		{Code: unix.BPF_JMP | unix.BPF_JEQ | unix.BPF_K, Jt: 1, Jf: 0, K: auditArch},
+		{Code: unix.BPF_RET | unix.BPF_K, K: retDeny},
+		{Code: unix.BPF_LD | unix.BPF_W | unix.BPF_ABS, K: seccompDataNrOffset},
+	}
+
+	if runtime.GOARCH == "amd64" {
+		filter = append(filter,
+			unix.SockFilter{Code: unix.BPF_JMP | unix.BPF_JGE | unix.BPF_K, Jt: 0, Jf: 1, K: x32SyscallBit},
+			unix.SockFilter{Code: unix.BPF_RET | unix.BPF_K, K: retDeny},
+		)
+	}
+
+	for _, syscall := range deniedSyscalls {
+		filter = append(filter,
+			unix.SockFilter{Code: unix.BPF_JMP | unix.BPF_JEQ | unix.BPF_K, Jt: 0, Jf: 1, K: uint32(syscall)},
+			unix.SockFilter{Code: unix.BPF_RET | unix.BPF_K, K: retDeny},
+		)
+	}
+
+	filter = append(filter, unix.SockFilter{Code: unix.BPF_RET | unix.BPF_K, K: retAllow})
+	return filter, nil
+}
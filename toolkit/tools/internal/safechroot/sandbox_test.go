@@ -0,0 +1,143 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package safechroot
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/buildpipeline"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/file"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/shell"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCgroupSettings(t *testing.T) {
+	assert.Empty(t, cgroupSettings(Sandbox{}))
+	assert.Equal(t, []cgroupSetting{
+		{name: "memory.max", value: "1073741824"},
+		{name: "memory.swap.max", value: "0", optional: true},
+		{name: "cpu.max", value: "150000 100000"},
+		{name: "pids.max", value: "512"},
+	}, cgroupSettings(Sandbox{MemoryMax: 1 << 30, CpuMax: 1.5, PidsMax: 512}))
+}
+
+func TestSeccompFilterInvalidProfile(t *testing.T) {
+	_, err := seccompFilter("bad")
+	assert.ErrorContains(t, err, "invalid seccomp profile (bad)")
+}
+
+func TestSeccompFilterNone(t *testing.T) {
+	filter, err := seccompFilter(SeccompProfileNone)
+	assert.NoError(t, err)
+	assert.Nil(t, filter)
+}
+
+// newSandboxedTestChroot creates a chroot, which borrows the host's programs, with the provided sandbox.
+func newSandboxedTestChroot(t *testing.T, name string, sandbox Sandbox) (*Chroot, error) {
+	dir := filepath.Join(t.TempDir(), name)
+	chroot := NewChroot(dir, isExistingDir)
+	chroot.SetSandbox(sandbox)
+
+	err := chroot.Initialize(emptyPath, []string{}, hostProgramMountPoints(), true)
+	if err != nil {
+		return nil, err
+	}
+
+	linkHostProgramDirs(t, chroot.RootDir())
+	return chroot, nil
+}
+
+func TestRunShouldApplySeccompProfile(t *testing.T) {
+	if !buildpipeline.IsRegularBuild() {
+		// this test only apply to "regular build" pipeline
+		return
+	}
+
+	chroot, err := newSandboxedTestChroot(t, "TestRunShouldApplySeccompProfile",
+		Sandbox{SeccompProfile: SeccompProfileDefault})
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer chroot.Close(defaultLeaveOnDisk)
+
+	var stderr, unsandboxedStderr string
+	err = chroot.Run(func() (err error) {
+		// Commands that aren't launched using the chroot's exec builder aren't affected.
+		_, unsandboxedStderr, _ = shell.Execute("swapoff", "/does-not-exist")
+
+		// The filter makes this fail with EPERM instead of ENOENT.
+		_, stderr, err = chroot.NewExecBuilder("swapoff", "/does-not-exist").ExecuteCaptureOuput()
+		return
+	})
+	assert.Error(t, err)
+	assert.NotContains(t, stderr, "No such file or directory")
+	assert.Contains(t, unsandboxedStderr, "No such file or directory")
+
+	// Processes outside of the chroot aren't affected.
+	_, stderr, err = shell.Execute("swapoff", "/does-not-exist")
+	assert.Error(t, err)
+	assert.Contains(t, stderr, "No such file or directory")
+}
+
+func TestRunShouldDisableNetwork(t *testing.T) {
+	if !buildpipeline.IsRegularBuild() {
+		// this test only apply to "regular build" pipeline
+		return
+	}
+
+	chroot, err := newSandboxedTestChroot(t, "TestRunShouldDisableNetwork", Sandbox{NoNetwork: true})
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer chroot.Close(defaultLeaveOnDisk)
+
+	var netDevices string
+	err = chroot.Run(func() (err error) {
+		netDevices, _, err = chroot.NewExecBuilder("cat", "/proc/net/dev").ExecuteCaptureOuput()
+		return
+	})
+	assert.NoError(t, err)
+
+	// Skip the 2 header lines.
+	lines := strings.Split(strings.TrimSpace(netDevices), "\n")
+	if assert.Len(t, lines, 3) {
+		assert.Equal(t, "lo:", strings.Fields(lines[2])[0])
+	}
+}
+
+func TestRunShouldApplyResourceLimits(t *testing.T) {
+	if !buildpipeline.IsRegularBuild() {
+		// this test only apply to "regular build" pipeline
+		return
+	}
+
+	exists, err := file.PathExists(filepath.Join(cgroupRootDir, "cgroup.controllers"))
+	if !assert.NoError(t, err) || !exists {
+		t.Skip("cgroup v2 is not available")
+	}
+
+	chroot, err := newSandboxedTestChroot(t, "TestRunShouldApplyResourceLimits", Sandbox{PidsMax: 64})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	cgroupPath := chroot.cgroupPath
+
+	var cgroup string
+	err = chroot.Run(func() (err error) {
+		cgroup, _, err = chroot.NewExecBuilder("cat", "/proc/self/cgroup").ExecuteCaptureOuput()
+		return
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "0::/"+filepath.Base(cgroupPath), strings.TrimSpace(cgroup))
+
+	err = chroot.Close(defaultLeaveOnDisk)
+	assert.NoError(t, err)
+
+	_, err = os.Stat(cgroupPath)
+	assert.True(t, os.IsNotExist(err))
+}
@@ -15,11 +15,23 @@ type Loopback struct {
 	diskIdMaj    string
 	diskIdMin    string
 	isAttached   bool
+	readOnly     bool
 }
 
 func NewLoopback(diskFilePath string) (*Loopback, error) {
+	return newLoopback(diskFilePath, false /*readOnly*/)
+}
+
+// NewReadOnlyLoopback attaches the disk file to a read-only loopback device. This ensures that nothing (including
+// file system journal recovery) can modify the disk file while it is attached.
+func NewReadOnlyLoopback(diskFilePath string) (*Loopback, error) {
+	return newLoopback(diskFilePath, true /*readOnly*/)
+}
+
+func newLoopback(diskFilePath string, readOnly bool) (*Loopback, error) {
 	loopback := &Loopback{
 		diskFilePath: diskFilePath,
+		readOnly:     readOnly,
 	}
 
 	err := loopback.newLoopbackHelper()
@@ -33,7 +45,13 @@ func NewLoopback(diskFilePath string) (*Loopback, error) {
 
 func (l *Loopback) newLoopbackHelper() error {
 	// Try to create the mount.
-	devicePath, err := diskutils.SetupLoopbackDevice(l.diskFilePath)
+	var devicePath string
+	var err error
+	if l.readOnly {
+		devicePath, err = diskutils.SetupReadOnlyLoopbackDevice(l.diskFilePath)
+	} else {
+		devicePath, err = diskutils.SetupLoopbackDevice(l.diskFilePath)
+	}
 	if err != nil {
 		return err
 	}
@@ -67,6 +85,10 @@ func (l *Loopback) DiskFilePath() string {
 	return l.diskFilePath
 }
 
+func (l *Loopback) ReadOnly() bool {
+	return l.readOnly
+}
+
 func (l *Loopback) Close() {
 	err := l.close( /*async*/ true)
 	if err != nil {
@@ -5,15 +5,18 @@ package shell
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"io"
 	"math"
+	"os"
 	"os/exec"
 	"strings"
 	"sync"
 
 	"github.com/microsoft/azurelinux/toolkit/tools/internal/logger"
 	"github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
 )
 
 const (
@@ -28,6 +31,7 @@ const (
 type LogCallback func(line string)
 
 type ExecBuilder struct {
+	ctx                  context.Context
 	command              string
 	args                 []string
 	workingDirectory     string
@@ -39,6 +43,10 @@ type ExecBuilder struct {
 	stderrCallback       LogCallback
 	errorStderrLines     int
 	warnLogLines         int
+	unshareFlags         uintptr
+	cgroup               *os.File
+	seccompFilter        []unix.SockFilter
+	commandWrapper       CommandWrapper
 }
 
 // NewExecBuilder initializes a new execution builder object.
@@ -52,6 +60,13 @@ func NewExecBuilder(command string, args ...string) ExecBuilder {
 	return b
 }
 
+// Context sets the context of the command to be executed. When the context is cancelled (or its deadline expires), the
+// process and all of its children are killed.
+func (b ExecBuilder) Context(ctx context.Context) ExecBuilder {
+	b.ctx = ctx
+	return b
+}
+
 // WorkingDirectory sets the working directory for the command to be executed.
 func (b ExecBuilder) WorkingDirectory(path string) ExecBuilder {
 	b.workingDirectory = path
@@ -64,6 +79,31 @@ func (b ExecBuilder) EnvironmentVariables(environmentVariables []string) ExecBui
 	return b
 }
 
+// Unshare sets the namespaces (i.e. CLONE_NEW* flags) that the process is moved into before it is executed.
+func (b ExecBuilder) Unshare(flags uintptr) ExecBuilder {
+	b.unshareFlags = flags
+	return b
+}
+
+// Cgroup sets the cgroup (as an open cgroup directory) that the process is started in.
+func (b ExecBuilder) Cgroup(cgroup *os.File) ExecBuilder {
+	b.cgroup = cgroup
+	return b
+}
+
+// SeccompFilter sets the seccomp filter that is applied to the process.
+func (b ExecBuilder) SeccompFilter(filter []unix.SockFilter) ExecBuilder {
+	b.seccompFilter = filter
+	return b
+}
+
+// CommandWrapper sets a wrapper that rewrites the command, and the working directory it runs in, just before it is
+// executed.
+func (b ExecBuilder) CommandWrapper(wrapper CommandWrapper) ExecBuilder {
+	b.commandWrapper = wrapper
+	return b
+}
+
 // Stdin sets a string value to be passed to the process via stdin.
 func (b ExecBuilder) Stdin(value string) ExecBuilder {
 	b.stdinString = value
@@ -161,8 +201,23 @@ func (b ExecBuilder) executeHelper(captureOutput bool) (string, string, error) {
 	}
 
 	// Setup process.
-	cmd := exec.Command(b.command, b.args...)
-	cmd.Dir = b.workingDirectory
+	command, args, workingDirectory := b.command, b.args, b.workingDirectory
+	if b.commandWrapper != nil {
+		command, args, workingDirectory = b.commandWrapper(command, args, workingDirectory)
+	}
+
+	var cmd *exec.Cmd
+	if b.ctx != nil {
+		cmd = exec.CommandContext(b.ctx, command, args...)
+		cmd.Cancel = func() error {
+			// Kill the process's entire process group (see trackAndStartProcess), so that no child process is left
+			// holding on to the stdout and stderr pipes.
+			return unix.Kill(-cmd.Process.Pid, unix.SIGKILL)
+		}
+	} else {
+		cmd = exec.Command(command, args...)
+	}
+	cmd.Dir = workingDirectory
 	cmd.Env = b.environmentVariables
 
 	if b.stdinString != "" {
@@ -184,7 +239,7 @@ func (b ExecBuilder) executeHelper(captureOutput bool) (string, string, error) {
 	defer stderrPipe.Close()
 
 	// Start process.
-	err = trackAndStartProcess(cmd)
+	err = trackAndStartProcess(cmd, b.unshareFlags, b.cgroup, b.seccompFilter)
 	if err != nil {
 		err = fmt.Errorf("failed to start process:\n%w", err)
 		return "", "", err
@@ -213,6 +268,10 @@ func (b ExecBuilder) executeHelper(captureOutput bool) (string, string, error) {
 		close(errorChan)
 	}
 
+	if err != nil && b.ctx != nil && b.ctx.Err() != nil {
+		err = fmt.Errorf("%s was stopped:\n%w", b.command, context.Cause(b.ctx))
+	}
+
 	stdout := ""
 	stderr := ""
 	if captureOutput {
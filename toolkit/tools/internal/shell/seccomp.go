@@ -0,0 +1,62 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package shell
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// startWithSeccompFilter starts a process with a seccomp filter applied to it.
+//
+// Seccomp filters apply to a single thread and are inherited by the processes that the thread starts. So, the filter
+// is applied to a dedicated OS thread, which starts the process and is then discarded.
+func startWithSeccompFilter(cmd *exec.Cmd, filter []unix.SockFilter) error {
+	errChan := make(chan error, 1)
+
+	go func() {
+		// The thread is never unlocked, so that the Go runtime terminates it (along with its filter) when this
+		// goroutine exits.
+		runtime.LockOSThread()
+
+		err := installSeccompFilter(filter)
+		if err != nil {
+			errChan <- err
+			return
+		}
+
+		errChan <- cmd.Start()
+	}()
+
+	return <-errChan
+}
+
+// installSeccompFilter applies a seccomp filter to the current thread.
+func installSeccompFilter(filter []unix.SockFilter) error {
+	if os.Geteuid() != 0 {
+		// Unprivileged processes may only install a seccomp filter if they can't gain new privileges.
+		err := unix.Prctl(unix.PR_SET_NO_NEW_PRIVS, 1, 0, 0, 0)
+		if err != nil {
+			return fmt.Errorf("failed to set no-new-privs:\n%w", err)
+		}
+	}
+
+	program := unix.SockFprog{
+		Len:    uint16(len(filter)),
+		Filter: &filter[0],
+	}
+
+	err := unix.Prctl(unix.PR_SET_SECCOMP, unix.SECCOMP_MODE_FILTER, uintptr(unsafe.Pointer(&program)), 0, 0)
+	runtime.KeepAlive(filter)
+	if err != nil {
+		return fmt.Errorf("failed to install seccomp filter:\n%w", err)
+	}
+
+	return nil
+}
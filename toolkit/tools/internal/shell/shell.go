@@ -29,6 +29,9 @@ var (
 	currentEnv = os.Environ()
 )
 
+// CommandWrapper rewrites a command, and the working directory it runs in, before it is executed.
+type CommandWrapper func(command string, args []string, workingDirectory string) (string, []string, string)
+
 // SetEnvironment sets the default environment variables to be used for all processes launched from this package.
 func SetEnvironment(env []string) {
 	currentEnv = env
@@ -157,7 +160,13 @@ func MustExecuteLive(command string, args ...string) {
 	}
 }
 
-func trackAndStartProcess(cmd *exec.Cmd) (err error) {
+// trackAndStartProcess starts a process and tracks it until untrackProcess is called.
+//   - unshareFlags are the namespaces (i.e. CLONE_NEW* flags) that the process is moved into.
+//   - cgroup is an open cgroup directory that the process is started in. If nil, the process is started in the current
+//     process's cgroup.
+//   - seccompFilter is applied to the process, if it isn't empty.
+func trackAndStartProcess(cmd *exec.Cmd, unshareFlags uintptr, cgroup *os.File, seccompFilter []unix.SockFilter,
+) (err error) {
 	logger.Log.Debugf("Executing: %v", cmd.Args)
 
 	if cmd.Env == nil && len(currentEnv) > 0 {
@@ -172,9 +181,19 @@ func trackAndStartProcess(cmd *exec.Cmd) (err error) {
 	}
 
 	// Make the process, and any children it spawns, belong to a new process group
-	cmd.SysProcAttr = &unix.SysProcAttr{Setpgid: true}
+	// Also, move the process into any requested namespaces.
+	cmd.SysProcAttr = &unix.SysProcAttr{Setpgid: true, Unshareflags: unshareFlags}
 
-	err = cmd.Start()
+	if cgroup != nil {
+		cmd.SysProcAttr.UseCgroupFD = true
+		cmd.SysProcAttr.CgroupFD = int(cgroup.Fd())
+	}
+
+	if len(seccompFilter) > 0 {
+		err = startWithSeccompFilter(cmd, seccompFilter)
+	} else {
+		err = cmd.Start()
+	}
 	if err != nil {
 		return
 	}
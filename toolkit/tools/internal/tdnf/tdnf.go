@@ -233,3 +233,26 @@ func AddSnapshotToConfig(configFilePath, posixTime string) (err error) {
 	}
 	return nil
 }
+
+// AddProxyToConfig sets the proxy that tdnf reaches its repos through in a tdnf config file. It leaves a missing config
+// file as is, e.g. when tdnf isn't installed.
+func AddProxyToConfig(configFilePath, proxy string) (err error) {
+	if configFilePath == "" {
+		return fmt.Errorf("config file path cannot be empty")
+	}
+
+	if proxy == "" {
+		return fmt.Errorf("proxy cannot be empty")
+	}
+
+	exists, err := file.PathExists(configFilePath)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		logger.Log.Warnf("config file path (%s) does not exist, not adding the proxy", configFilePath)
+		return nil
+	}
+
+	return file.Append(fmt.Sprintf("proxy=%s\n", proxy), configFilePath)
+}
@@ -343,3 +343,25 @@ func TestAddSnapshotToConfig(t *testing.T) {
 		})
 	}
 }
+
+func TestAddProxyToConfig(t *testing.T) {
+	testConfigFilePath := path.Join("testdata", "tdnf.conf")
+	testConfigFileLines, err := file.ReadLines(testConfigFilePath)
+	assert.NoError(t, err)
+
+	configFilePath := path.Join(t.TempDir(), "tdnf.conf")
+	assert.NoError(t, file.Copy(testConfigFilePath, configFilePath))
+
+	err = AddProxyToConfig(configFilePath, "http://proxy.contoso.com:3128")
+	assert.NoError(t, err)
+
+	resultConfigFileLines, err := file.ReadLines(configFilePath)
+	assert.NoError(t, err)
+	assert.Equal(t, append(testConfigFileLines, "proxy=http://proxy.contoso.com:3128"), resultConfigFileLines)
+
+	// A missing config file is left as is.
+	assert.NoError(t, AddProxyToConfig(path.Join(t.TempDir(), "tdnf.conf"), "http://proxy.contoso.com:3128"))
+
+	assert.Error(t, AddProxyToConfig("", "http://proxy.contoso.com:3128"))
+	assert.Error(t, AddProxyToConfig(configFilePath, ""))
+}
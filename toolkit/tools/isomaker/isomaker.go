@@ -28,6 +28,10 @@ var (
 
 	imageTag = app.Flag("image-tag", "Tag (text) appended to the image name. Empty by default.").String()
 
+	isoBackend     = app.Flag("iso-backend", "The tool that generates the ISO image file. Defaults to 'mkisofs'.").Enum(string(isomakerlib.IsoBackendMkisofs), string(isomakerlib.IsoBackendXorriso))
+	isoBackendArgs = app.Flag("iso-backend-arg", "Additional argument passed as-is to the ISO backend (e.g. '-isohybrid-gpt-basdat'). Can be specified multiple times.").Strings()
+	hybridGpt      = app.Flag("hybrid-gpt", "Set this flag, if the ISO should also boot on UEFI machines when written as-is to a USB stick. Requires the 'xorriso' backend.").Bool()
+
 	logFlags = exe.SetupLogFlags(app)
 )
 
@@ -52,6 +56,16 @@ func main() {
 	if err != nil {
 		logger.PanicOnError(err)
 	}
+
+	err = isoMaker.SetBackend(isomakerlib.IsoBackend(*isoBackend))
+	if err != nil {
+		logger.PanicOnError(err)
+	}
+	isoMaker.SetExtraBackendArgs(*isoBackendArgs)
+	if *hybridGpt {
+		isoMaker.EnableHybridGpt()
+	}
+
 	err = isoMaker.Make()
 	if err != nil {
 		logger.PanicOnError(err)
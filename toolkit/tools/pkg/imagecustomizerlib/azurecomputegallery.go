@@ -0,0 +1,145 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/imagecustomizerapi"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/logger"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/shell"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// The Azure CLI's config directory (which holds its sign-in) of a build. A separate directory is used, so that the
+	// build host's own sign-in is left untouched.
+	azureCliConfigDirName = "azure-cli"
+)
+
+type azureComputeGalleryReport struct {
+	// The URL of the uploaded VHD.
+	BlobUrl string `json:"blobUrl"`
+	// The resource ID of the published image version.
+	ImageVersionId string `json:"imageVersionId"`
+}
+
+// publishToAzureComputeGallery uploads the VHD to the storage account, and publishes it as an image version of the
+// gallery. Returns the build report's section, with the image version's ID.
+func publishToAzureComputeGallery(ctx context.Context, buildDir string, gallery *imagecustomizerapi.AzureComputeGallery,
+	outputImageFile string,
+) (*azureComputeGalleryReport, error) {
+	logger.Log.Infof("Publishing image version (%s) of image definition (%s) to Azure Compute Gallery (%s)",
+		gallery.ImageVersion, gallery.ImageDefinition, gallery.GalleryName)
+
+	azureCliConfigDir := filepath.Join(buildDir, azureCliConfigDirName)
+	defer os.RemoveAll(azureCliConfigDir)
+
+	_, err := runAzureCli(ctx, azureCliConfigDir, azureLoginArgs(gallery)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign in to Azure using the managed identity:\n%w", err)
+	}
+
+	logger.Log.Infof("Uploading (%s) to storage account (%s)", outputImageFile, gallery.StorageAccount)
+
+	_, err = runAzureCli(ctx, azureCliConfigDir, azureBlobUploadArgs(gallery, outputImageFile)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to upload (%s) to storage account (%s):\n%w", outputImageFile,
+			gallery.StorageAccount, err)
+	}
+
+	logger.Log.Infof("Creating image version (%s)", gallery.ImageVersion)
+
+	imageVersionId, err := runAzureCli(ctx, azureCliConfigDir, azureImageVersionCreateArgs(gallery)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create image version (%s):\n%w", gallery.ImageVersion, err)
+	}
+
+	logger.Log.Infof("Published image version: %s", imageVersionId)
+
+	report := &azureComputeGalleryReport{
+		BlobUrl:        azureBlobUrl(gallery),
+		ImageVersionId: imageVersionId,
+	}
+	return report, nil
+}
+
+func runAzureCli(ctx context.Context, azureCliConfigDir string, args ...string) (string, error) {
+	stdout, _, err := shell.NewExecBuilder("az", args...).
+		Context(ctx).
+		EnvironmentVariables(append(os.Environ(), "AZURE_CONFIG_DIR="+azureCliConfigDir)).
+		LogLevel(logrus.DebugLevel, logrus.DebugLevel).
+		ErrorStderrLines(1).
+		ExecuteCaptureOuput()
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(stdout), nil
+}
+
+func azureLoginArgs(gallery *imagecustomizerapi.AzureComputeGallery) []string {
+	args := []string{"login", "--identity", "--output", "none"}
+	if gallery.ManagedIdentityClientId != "" {
+		args = append(args, "--username", gallery.ManagedIdentityClientId)
+	}
+	return args
+}
+
+func azureBlobUploadArgs(gallery *imagecustomizerapi.AzureComputeGallery, vhdFile string) []string {
+	return []string{
+		"storage", "blob", "upload",
+		"--subscription", gallery.SubscriptionId,
+		"--account-name", gallery.StorageAccount,
+		"--container-name", gallery.StorageContainer,
+		"--name", azureBlobName(gallery),
+		"--file", vhdFile,
+		// The VHDs of the images must be page blobs.
+		"--type", "page",
+		"--auth-mode", "login",
+		"--overwrite",
+		"--output", "none",
+	}
+}
+
+func azureImageVersionCreateArgs(gallery *imagecustomizerapi.AzureComputeGallery) []string {
+	storageResourceGroup := gallery.StorageResourceGroup
+	if storageResourceGroup == "" {
+		storageResourceGroup = gallery.ResourceGroup
+	}
+
+	storageAccountId := fmt.Sprintf("/subscriptions/%s/resourceGroups/%s/providers/Microsoft.Storage/storageAccounts/%s",
+		gallery.SubscriptionId, storageResourceGroup, gallery.StorageAccount)
+
+	args := []string{
+		"sig", "image-version", "create",
+		"--subscription", gallery.SubscriptionId,
+		"--resource-group", gallery.ResourceGroup,
+		"--gallery-name", gallery.GalleryName,
+		"--gallery-image-definition", gallery.ImageDefinition,
+		"--gallery-image-version", gallery.ImageVersion,
+		"--os-vhd-uri", azureBlobUrl(gallery),
+		"--os-vhd-storage-account", storageAccountId,
+	}
+	if len(gallery.TargetRegions) > 0 {
+		args = append(args, "--target-regions")
+		args = append(args, gallery.TargetRegions...)
+	}
+	args = append(args, "--query", "id", "--output", "tsv")
+
+	return args
+}
+
+func azureBlobName(gallery *imagecustomizerapi.AzureComputeGallery) string {
+	return fmt.Sprintf("%s-%s.vhd", gallery.ImageDefinition, gallery.ImageVersion)
+}
+
+func azureBlobUrl(gallery *imagecustomizerapi.AzureComputeGallery) string {
+	return fmt.Sprintf("https://%s.blob.core.windows.net/%s/%s", gallery.StorageAccount, gallery.StorageContainer,
+		azureBlobName(gallery))
+}
@@ -0,0 +1,68 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/imagecustomizerapi"
+	"github.com/stretchr/testify/assert"
+)
+
+func testAzureComputeGallery() *imagecustomizerapi.AzureComputeGallery {
+	return &imagecustomizerapi.AzureComputeGallery{
+		SubscriptionId:   "00000000-0000-0000-0000-000000000001",
+		ResourceGroup:    "azurelinux-images",
+		GalleryName:      "azurelinux_gallery",
+		ImageDefinition:  "azurelinux-3.0-gen2",
+		ImageVersion:     "3.0.20240601",
+		StorageAccount:   "azurelinuximages",
+		StorageContainer: "vhds",
+	}
+}
+
+func TestAzureImageVersionCreateArgs(t *testing.T) {
+	gallery := testAzureComputeGallery()
+	gallery.StorageResourceGroup = "azurelinux-storage"
+	gallery.TargetRegions = []string{"westus2", "eastus"}
+
+	assert.Equal(t, []string{
+		"sig", "image-version", "create",
+		"--subscription", "00000000-0000-0000-0000-000000000001",
+		"--resource-group", "azurelinux-images",
+		"--gallery-name", "azurelinux_gallery",
+		"--gallery-image-definition", "azurelinux-3.0-gen2",
+		"--gallery-image-version", "3.0.20240601",
+		"--os-vhd-uri", "https://azurelinuximages.blob.core.windows.net/vhds/azurelinux-3.0-gen2-3.0.20240601.vhd",
+		"--os-vhd-storage-account", "/subscriptions/00000000-0000-0000-0000-000000000001/resourceGroups/azurelinux-storage/providers/Microsoft.Storage/storageAccounts/azurelinuximages",
+		"--target-regions", "westus2", "eastus",
+		"--query", "id", "--output", "tsv",
+	}, azureImageVersionCreateArgs(gallery))
+}
+
+func TestAzureImageVersionCreateArgsDefaultStorageResourceGroup(t *testing.T) {
+	args := azureImageVersionCreateArgs(testAzureComputeGallery())
+	assert.Contains(t, args, "/subscriptions/00000000-0000-0000-0000-000000000001/resourceGroups/azurelinux-images/providers/Microsoft.Storage/storageAccounts/azurelinuximages")
+	assert.NotContains(t, args, "--target-regions")
+}
+
+func TestAzureLoginArgs(t *testing.T) {
+	gallery := testAzureComputeGallery()
+	assert.Equal(t, []string{"login", "--identity", "--output", "none"}, azureLoginArgs(gallery))
+
+	gallery.ManagedIdentityClientId = "00000000-0000-0000-0000-000000000002"
+	assert.Equal(t, []string{"login", "--identity", "--output", "none", "--username",
+		"00000000-0000-0000-0000-000000000002"}, azureLoginArgs(gallery))
+}
+
+func TestCreateImageCustomizerParametersAzureComputeGalleryWithoutFixedVhd(t *testing.T) {
+	config := &imagecustomizerapi.Config{
+		AzureComputeGallery: testAzureComputeGallery(),
+	}
+
+	_, err := createImageCustomizerParameters(tmpDir, "input.vhdx", testDir, config, false, nil, false, "",
+		"vhd", filepath.Join(tmpDir, "image.vhd"), "", CustomizeImageOptions{})
+	assert.ErrorContains(t, err, "'azureComputeGallery' can be specified only if the output format ('--output-image-format') is a fixed size vhd (vhd-fixed)")
+}
@@ -0,0 +1,162 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/moby/sys/mountinfo"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// The lock file that marks a build directory as being in use.
+	buildDirLockFileName = ".imagecustomizer.lock"
+)
+
+// buildWorkDir is the folder that holds a build's intermediate files.
+//
+// Many of the intermediate files (e.g. the raw copy of the image and the chroot folders) have fixed names within the
+// working folder. So, each build is given its own working folder under the build directory, which allows builds that
+// share a build directory to run concurrently.
+//
+// The exception is builds that keep their intermediate files for a later build to resume from. These must use the
+// build directory itself so that the later build can find the files. So, they take an exclusive lock on the build
+// directory instead.
+type buildWorkDir struct {
+	path   string
+	isTemp bool
+	lock   *buildDirLock
+}
+
+// createBuildWorkDir creates the working folder of a build under the build directory.
+func createBuildWorkDir(buildDir string, resumeMode ResumeMode) (*buildWorkDir, error) {
+	buildDirAbs, err := filepath.Abs(buildDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get absolute path of build directory (%s):\n%w", buildDir, err)
+	}
+
+	if resumeMode.keepWorkDir() {
+		lock, err := lockBuildDir(buildDirAbs)
+		if err != nil {
+			return nil, err
+		}
+
+		workDir := &buildWorkDir{
+			path: buildDirAbs,
+			lock: lock,
+		}
+		return workDir, nil
+	}
+
+	err = os.MkdirAll(buildDirAbs, os.ModePerm)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create build directory (%s):\n%w", buildDirAbs, err)
+	}
+
+	workDirPath, err := os.MkdirTemp(buildDirAbs, "build-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create working folder in build directory (%s):\n%w", buildDirAbs, err)
+	}
+
+	workDir := &buildWorkDir{
+		path:   workDirPath,
+		isTemp: true,
+	}
+	return workDir, nil
+}
+
+// Close removes the working folder, if it was created for this build, and releases the build directory lock, if one
+// is held.
+func (w *buildWorkDir) Close() error {
+	if w.isTemp {
+		// Don't delete the working folder if something is still mounted within it. Otherwise, the contents of the
+		// mounted file system would be deleted as well.
+		mounts, err := mountinfo.GetMounts(mountinfo.PrefixFilter(w.path))
+		if err != nil {
+			return fmt.Errorf("failed to list mounts within build working folder (%s):\n%w", w.path, err)
+		}
+		if len(mounts) > 0 {
+			return fmt.Errorf("not removing build working folder (%s) since (%s) is still mounted", w.path,
+				mounts[0].Mountpoint)
+		}
+
+		err = os.RemoveAll(w.path)
+		if err != nil {
+			return fmt.Errorf("failed to remove build working folder (%s):\n%w", w.path, err)
+		}
+	}
+
+	if w.lock != nil {
+		err := w.lock.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// buildDirLock is an exclusive lock on a build directory.
+type buildDirLock struct {
+	lockFile *os.File
+}
+
+// lockBuildDir creates the build directory, if needed, and takes an exclusive lock on it. Fails immediately if
+// another build holds the lock.
+//
+// The lock is an advisory file lock (flock). So, it is released by the kernel when the process exits, even if the
+// process crashes.
+func lockBuildDir(buildDir string) (*buildDirLock, error) {
+	err := os.MkdirAll(buildDir, os.ModePerm)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create build directory (%s):\n%w", buildDir, err)
+	}
+
+	lockFilePath := filepath.Join(buildDir, buildDirLockFileName)
+
+	lockFile, err := os.OpenFile(lockFilePath, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open build directory lock file (%s):\n%w", lockFilePath, err)
+	}
+
+	err = unix.Flock(int(lockFile.Fd()), unix.LOCK_EX|unix.LOCK_NB)
+	if err != nil {
+		lockFile.Close()
+
+		if errors.Is(err, unix.EWOULDBLOCK) {
+			return nil, fmt.Errorf("build directory (%s) is in use by another build that keeps its work directory:\n"+
+				"use a separate build directory for each concurrent build that keeps its work directory", buildDir)
+		}
+		return nil, fmt.Errorf("failed to lock build directory (%s):\n%w", buildDir, err)
+	}
+
+	// Record the owner to help with debugging. The lock file itself is left behind on release since removing it could
+	// race with another build that is opening it.
+	err = lockFile.Truncate(0)
+	if err == nil {
+		_, err = lockFile.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+	}
+	if err != nil {
+		lockFile.Close()
+		return nil, fmt.Errorf("failed to write build directory lock file (%s):\n%w", lockFilePath, err)
+	}
+
+	return &buildDirLock{lockFile: lockFile}, nil
+}
+
+// Close releases the lock.
+func (l *buildDirLock) Close() error {
+	// Closing the file releases the lock.
+	err := l.lockFile.Close()
+	if err != nil {
+		return fmt.Errorf("failed to release build directory lock:\n%w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,150 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLockBuildDir(t *testing.T) {
+	buildDir := filepath.Join(tmpDir, "TestLockBuildDir")
+	defer os.RemoveAll(buildDir)
+
+	lock, err := lockBuildDir(buildDir)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	_, err = lockBuildDir(buildDir)
+	assert.ErrorContains(t, err, "is in use by another build")
+
+	err = lock.Close()
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	lock, err = lockBuildDir(buildDir)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	err = lock.Close()
+	assert.NoError(t, err)
+}
+
+func TestCreateBuildWorkDirConcurrent(t *testing.T) {
+	buildDir := filepath.Join(tmpDir, "TestCreateBuildWorkDirConcurrent")
+	defer os.RemoveAll(buildDir)
+
+	const buildCount = 4
+
+	workDirs := make([]*buildWorkDir, buildCount)
+	errs := make([]error, buildCount)
+
+	var wg sync.WaitGroup
+	for i := 0; i < buildCount; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			workDirs[i], errs[i] = createBuildWorkDir(buildDir, ResumeModeDefault)
+		}(i)
+	}
+	wg.Wait()
+
+	paths := make(map[string]bool)
+	for i := 0; i < buildCount; i++ {
+		if !assert.NoError(t, errs[i]) {
+			return
+		}
+
+		assert.DirExists(t, workDirs[i].path)
+		assert.Equal(t, buildDir, filepath.Dir(workDirs[i].path))
+		paths[workDirs[i].path] = true
+	}
+	assert.Len(t, paths, buildCount)
+
+	// A build that keeps its work directory can run alongside the other builds.
+	resumableWorkDir, err := createBuildWorkDir(buildDir, ResumeModeKeepWorkDir)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, buildDir, resumableWorkDir.path)
+
+	// But not alongside another build that keeps its work directory.
+	_, err = createBuildWorkDir(buildDir, ResumeModeResume)
+	assert.ErrorContains(t, err, "is in use by another build")
+
+	err = resumableWorkDir.Close()
+	assert.NoError(t, err)
+	assert.DirExists(t, buildDir)
+
+	for i := 0; i < buildCount; i++ {
+		err = workDirs[i].Close()
+		assert.NoError(t, err)
+		assert.NoDirExists(t, workDirs[i].path)
+	}
+}
+
+func TestCustomizeImageConcurrentBuildsSharedBuildDir(t *testing.T) {
+	baseImage := checkSkipForCustomizeImage(t, baseImageTypeCoreEfi, baseImageVersionDefault)
+
+	testTmpDir := filepath.Join(tmpDir, "TestCustomizeImageConcurrentBuildsSharedBuildDir")
+	defer os.RemoveAll(testTmpDir)
+
+	buildDir := filepath.Join(testTmpDir, "build")
+	configFile := filepath.Join(testDir, "hostname-config.yaml")
+
+	const buildCount = 2
+
+	outImageFilePaths := make([]string, buildCount)
+	errs := make([]error, buildCount)
+
+	var wg sync.WaitGroup
+	for i := 0; i < buildCount; i++ {
+		outImageFilePaths[i] = filepath.Join(testTmpDir, fmt.Sprintf("image%d.raw", i))
+
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = CustomizeImageWithConfigFile(context.Background(), buildDir, configFile, baseImage, nil,
+				outImageFilePaths[i], "raw", "", "" /*outputPXEArtifactsDir*/, false, /*useBaseImageRpmRepos*/
+				false /*enableShrinkFilesystems*/, CustomizeImageOptions{})
+		}(i)
+	}
+	wg.Wait()
+
+	for i := 0; i < buildCount; i++ {
+		if !assert.NoError(t, errs[i]) {
+			return
+		}
+	}
+
+	// Each build's working folder has been cleaned-up.
+	entries, err := os.ReadDir(buildDir)
+	if assert.NoError(t, err) {
+		assert.Empty(t, entries)
+	}
+
+	for i := 0; i < buildCount; i++ {
+		// Check the output image has the customizations.
+		imageConnection, err := connectToCoreEfiImage(buildDir, outImageFilePaths[i])
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		actualHostname, err := os.ReadFile(filepath.Join(imageConnection.Chroot().RootDir(), "etc/hostname"))
+		imageConnection.Close()
+		if assert.NoError(t, err) {
+			assert.Equal(t, "testname", string(actualHostname))
+		}
+	}
+
+}
@@ -0,0 +1,52 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/jsonutils"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/logger"
+)
+
+const (
+	buildReportFileSuffix = "-build-report.json"
+)
+
+// buildReport describes the results of a build's optional steps (e.g. publishing the output image).
+type buildReport struct {
+	ToolVersion         string                     `json:"toolVersion"`
+	OutputImageFile     string                     `json:"outputImageFile"`
+	AzureComputeGallery *azureComputeGalleryReport `json:"azureComputeGallery,omitempty"`
+	VulnerabilityScan   *vulnerabilityScanReport   `json:"vulnerabilityScan,omitempty"`
+	IsoCarryOver        *isoCarryOverReport        `json:"isoCarryOver,omitempty"`
+}
+
+// writeBuildReport writes the build report next to the output image. The report is only written if any of the
+// optional steps ran.
+func writeBuildReport(ic *ImageCustomizerParameters) error {
+	if ic.azureComputeGalleryReport == nil && ic.vulnerabilityScanReport == nil && ic.isoCarryOverReport == nil {
+		return nil
+	}
+
+	report := buildReport{
+		ToolVersion:         ToolVersion,
+		OutputImageFile:     ic.outputImageFile,
+		AzureComputeGallery: ic.azureComputeGalleryReport,
+		VulnerabilityScan:   ic.vulnerabilityScanReport,
+		IsoCarryOver:        ic.isoCarryOverReport,
+	}
+
+	reportFile := filepath.Join(ic.outputImageDir, ic.outputImageBase+buildReportFileSuffix)
+
+	logger.Log.Infof("Writing build report: %s", reportFile)
+
+	err := jsonutils.WriteJSONFile(reportFile, &report)
+	if err != nil {
+		return fmt.Errorf("failed to write build report (%s):\n%w", reportFile, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,72 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/imagecustomizerapi"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/file"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/jsonutils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteBuildReport(t *testing.T) {
+	outputImageDir := filepath.Join(tmpDir, "TestWriteBuildReport")
+	defer os.RemoveAll(outputImageDir)
+
+	err := os.MkdirAll(outputImageDir, os.ModePerm)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	ic := &ImageCustomizerParameters{
+		outputImageFile: filepath.Join(outputImageDir, "image.vhd"),
+		outputImageDir:  outputImageDir,
+		outputImageBase: "image",
+		vulnerabilityScanReport: &vulnerabilityScanReport{
+			FailOnSeverity: imagecustomizerapi.VulnerabilitySeverityCritical,
+			Findings: []vulnerabilityFinding{
+				{Id: "CVE-2024-0001", Package: "openssl", Severity: imagecustomizerapi.VulnerabilitySeverityHigh},
+			},
+		},
+	}
+
+	err = writeBuildReport(ic)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	var report buildReport
+	err = jsonutils.ReadJSONFile(filepath.Join(outputImageDir, "image-build-report.json"), &report)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, ic.outputImageFile, report.OutputImageFile)
+	assert.Nil(t, report.AzureComputeGallery)
+	assert.Equal(t, ic.vulnerabilityScanReport, report.VulnerabilityScan)
+}
+
+func TestWriteBuildReportNoSections(t *testing.T) {
+	outputImageDir := filepath.Join(tmpDir, "TestWriteBuildReportNoSections")
+	defer os.RemoveAll(outputImageDir)
+
+	ic := &ImageCustomizerParameters{
+		outputImageFile: filepath.Join(outputImageDir, "image.vhd"),
+		outputImageDir:  outputImageDir,
+		outputImageBase: "image",
+	}
+
+	err := writeBuildReport(ic)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	exists, err := file.PathExists(filepath.Join(outputImageDir, "image-build-report.json"))
+	assert.NoError(t, err)
+	assert.False(t, exists)
+}
@@ -0,0 +1,97 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"fmt"
+	"runtime"
+	"strconv"
+)
+
+// IoniceClass is the I/O scheduling class used by the resource intensive build steps.
+type IoniceClass string
+
+const (
+	// IoniceClassDefault leaves the I/O scheduling class unchanged.
+	IoniceClassDefault    IoniceClass = ""
+	IoniceClassIdle       IoniceClass = "idle"
+	IoniceClassBestEffort IoniceClass = "best-effort"
+	IoniceClassRealtime   IoniceClass = "realtime"
+)
+
+const (
+	minNiceness = -20
+	maxNiceness = 19
+)
+
+// BuildResources controls how much of the build machine's resources the resource intensive build steps (copying the
+// file systems, creating the squashfs image, and running dracut) are allowed to use.
+type BuildResources struct {
+	// MaxParallelWorkers is the maximum number of parallel workers used by a build step. A value of 0 uses the number
+	// of CPUs of the build machine.
+	MaxParallelWorkers int
+	// Niceness is the CPU scheduling niceness (-20 to 19) of the build steps' processes. A value of 0 leaves the
+	// niceness unchanged.
+	Niceness int
+	// IoniceClass is the I/O scheduling class of the build steps' processes.
+	IoniceClass IoniceClass
+}
+
+func (c IoniceClass) IsValid() error {
+	switch c {
+	case IoniceClassDefault, IoniceClassIdle, IoniceClassBestEffort, IoniceClassRealtime:
+		return nil
+
+	default:
+		return fmt.Errorf("invalid ionice class value (%s)", c)
+	}
+}
+
+func (r *BuildResources) IsValid() error {
+	if r.MaxParallelWorkers < 0 {
+		return fmt.Errorf("invalid max parallel workers value (%d): must not be negative", r.MaxParallelWorkers)
+	}
+
+	if r.Niceness < minNiceness || r.Niceness > maxNiceness {
+		return fmt.Errorf("invalid niceness value (%d): must be between %d and %d", r.Niceness, minNiceness,
+			maxNiceness)
+	}
+
+	err := r.IoniceClass.IsValid()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// maxParallelWorkers returns the maximum number of parallel workers a build step may use.
+func (r *BuildResources) maxParallelWorkers() int {
+	if r.MaxParallelWorkers <= 0 {
+		return runtime.NumCPU()
+	}
+
+	return r.MaxParallelWorkers
+}
+
+// resourceLimitedCommand wraps a command with 'nice' and 'ionice', as needed, so that it runs with the configured CPU
+// and I/O scheduling priorities.
+func resourceLimitedCommand(resources BuildResources, program string, args ...string) (string, []string) {
+	command := []string(nil)
+	if resources.Niceness != 0 {
+		command = append(command, "nice", "-n", strconv.Itoa(resources.Niceness))
+	}
+
+	if resources.IoniceClass != IoniceClassDefault {
+		command = append(command, "ionice", "-c", string(resources.IoniceClass))
+	}
+
+	if len(command) <= 0 {
+		return program, args
+	}
+
+	command = append(command, program)
+	command = append(command, args...)
+	return command[0], command[1:]
+}
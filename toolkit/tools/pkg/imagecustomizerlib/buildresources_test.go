@@ -0,0 +1,44 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildResourcesIsValid(t *testing.T) {
+	resources := BuildResources{}
+	assert.NoError(t, resources.IsValid())
+
+	resources = BuildResources{MaxParallelWorkers: 4, Niceness: 19, IoniceClass: IoniceClassIdle}
+	assert.NoError(t, resources.IsValid())
+
+	resources = BuildResources{MaxParallelWorkers: -1}
+	assert.ErrorContains(t, resources.IsValid(), "invalid max parallel workers value (-1)")
+
+	resources = BuildResources{Niceness: 20}
+	assert.ErrorContains(t, resources.IsValid(), "invalid niceness value (20)")
+
+	resources = BuildResources{IoniceClass: "bad"}
+	assert.ErrorContains(t, resources.IsValid(), "invalid ionice class value (bad)")
+}
+
+func TestResourceLimitedCommand(t *testing.T) {
+	resources := BuildResources{}
+	assert.Equal(t, runtime.NumCPU(), resources.maxParallelWorkers())
+
+	program, args := resourceLimitedCommand(resources, "cp", "-a", "src", "dst")
+	assert.Equal(t, "cp", program)
+	assert.Equal(t, []string{"-a", "src", "dst"}, args)
+
+	resources = BuildResources{MaxParallelWorkers: 2, Niceness: 10, IoniceClass: IoniceClassIdle}
+	assert.Equal(t, 2, resources.maxParallelWorkers())
+
+	program, args = resourceLimitedCommand(resources, "cp", "-a", "src", "dst")
+	assert.Equal(t, "nice", program)
+	assert.Equal(t, []string{"-n", "10", "ionice", "-c", "idle", "cp", "-a", "src", "dst"}, args)
+}
@@ -0,0 +1,216 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/imagecustomizerapi"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/file"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/jsonutils"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/logger"
+)
+
+// ResumeMode controls whether the intermediate files of a failed build are kept and reused by the next build.
+type ResumeMode string
+
+const (
+	// ResumeModeDefault always starts the build from scratch and deletes the intermediate files when it is done.
+	ResumeModeDefault ResumeMode = ""
+	// ResumeModeKeepWorkDir keeps the outputs of the completed build phases in the build directory when the build
+	// fails, so that a later build can resume from them.
+	ResumeModeKeepWorkDir ResumeMode = "keep-workdir"
+	// ResumeModeResume resumes from the last successful build phase recorded in the build directory, if its inputs
+	// have not changed. Implies ResumeModeKeepWorkDir.
+	ResumeModeResume ResumeMode = "resume"
+)
+
+const (
+	// The file (in the build directory) that records the completed build phases.
+	buildStateFileName = "build-state.json"
+
+	// The phase that customizes the OS of the (raw) image. Its output is the customized raw image.
+	buildPhaseCustomizeOS = "customize-os"
+)
+
+// buildState records the build phases that have completed successfully.
+type buildState struct {
+	// The checksum of the build inputs that affect the phase outputs.
+	InputsChecksum string `json:"inputsChecksum"`
+	// The last phase that completed successfully.
+	CompletedPhase string `json:"completedPhase"`
+	// The output file of the completed phase (relative to the build directory).
+	OutputFile string `json:"outputFile"`
+	// The sha256 of the output file. Used to detect output files that were modified or truncated.
+	OutputSha256 string `json:"outputSha256"`
+	// The results of the vulnerability scan of the completed phase, for the build report.
+	VulnerabilityScan *vulnerabilityScanReport `json:"vulnerabilityScan,omitempty"`
+}
+
+// buildStateInputs holds the build inputs that affect the output of the OS customization phase. The output image format
+// and path are not included since they only affect the phases that follow.
+type buildStateInputs struct {
+	ToolVersion                 string                     `json:"toolVersion"`
+	Config                      *imagecustomizerapi.Config `json:"config"`
+	InputImageFile              string                     `json:"inputImageFile"`
+	InputImageSize              int64                      `json:"inputImageSize"`
+	InputImageModTime           int64                      `json:"inputImageModTime"`
+	RpmsSources                 []string                   `json:"rpmsSources"`
+	UseBaseImageRpmRepos        bool                       `json:"useBaseImageRpmRepos"`
+	EnableShrinkFilesystems     bool                       `json:"enableShrinkFilesystems"`
+	OutputSplitPartitionsFormat string                     `json:"outputSplitPartitionsFormat"`
+}
+
+func (m ResumeMode) IsValid() error {
+	switch m {
+	case ResumeModeDefault, ResumeModeKeepWorkDir, ResumeModeResume:
+		return nil
+
+	default:
+		return fmt.Errorf("invalid resume mode value (%s)", m)
+	}
+}
+
+func (m ResumeMode) keepWorkDir() bool {
+	return m == ResumeModeKeepWorkDir || m == ResumeModeResume
+}
+
+// calculateBuildInputsChecksum returns a checksum of the build inputs that affect the output of the OS customization
+// phase.
+//
+// The input image is identified by its path, size and modification time, instead of its contents, to avoid reading
+// the whole image. Changes to files that are referenced by the config (e.g. scripts) are not detected.
+func calculateBuildInputsChecksum(ic *ImageCustomizerParameters) (string, error) {
+	inputImageInfo, err := os.Stat(ic.inputImageFile)
+	if err != nil {
+		return "", fmt.Errorf("failed to stat input image file (%s):\n%w", ic.inputImageFile, err)
+	}
+
+	inputImageFileAbs, err := filepath.Abs(ic.inputImageFile)
+	if err != nil {
+		return "", err
+	}
+
+	inputs := buildStateInputs{
+		ToolVersion:                 ToolVersion,
+		Config:                      ic.config,
+		InputImageFile:              inputImageFileAbs,
+		InputImageSize:              inputImageInfo.Size(),
+		InputImageModTime:           inputImageInfo.ModTime().UnixNano(),
+		RpmsSources:                 ic.rpmsSources,
+		UseBaseImageRpmRepos:        ic.useBaseImageRpmRepos,
+		EnableShrinkFilesystems:     ic.enableShrinkFilesystems,
+		OutputSplitPartitionsFormat: ic.outputSplitPartitionsFormat,
+	}
+
+	inputsJson, err := json.Marshal(&inputs)
+	if err != nil {
+		return "", fmt.Errorf("failed to serialize build inputs:\n%w", err)
+	}
+
+	return fmt.Sprintf("%x", sha256.Sum256(inputsJson)), nil
+}
+
+// saveBuildState records that the OS customization phase has completed.
+func saveBuildState(ic *ImageCustomizerParameters, inputsChecksum string) error {
+	logger.Log.Infof("Recording build state")
+
+	outputFile, err := filepath.Rel(ic.buildDirAbs, ic.rawImageFile)
+	if err != nil {
+		return fmt.Errorf("failed to get path of (%s) relative to build directory:\n%w", ic.rawImageFile, err)
+	}
+
+	outputSha256, err := file.GenerateSHA256(ic.rawImageFile)
+	if err != nil {
+		return fmt.Errorf("failed to calculate the sha256 of (%s):\n%w", ic.rawImageFile, err)
+	}
+
+	state := buildState{
+		InputsChecksum:    inputsChecksum,
+		CompletedPhase:    buildPhaseCustomizeOS,
+		OutputFile:        outputFile,
+		OutputSha256:      outputSha256,
+		VulnerabilityScan: ic.vulnerabilityScanReport,
+	}
+
+	buildStateFilePath := filepath.Join(ic.buildDirAbs, buildStateFileName)
+	err = jsonutils.WriteJSONFile(buildStateFilePath, &state)
+	if err != nil {
+		return fmt.Errorf("failed to write build state file (%s):\n%w", buildStateFilePath, err)
+	}
+
+	return nil
+}
+
+// resumeFromBuildState checks if the OS customization phase of a previous build can be reused. If so, it points
+// ic.rawImageFile at the phase's output and returns true.
+func resumeFromBuildState(ic *ImageCustomizerParameters, inputsChecksum string) (bool, error) {
+	buildStateFilePath := filepath.Join(ic.buildDirAbs, buildStateFileName)
+
+	exists, err := file.PathExists(buildStateFilePath)
+	if err != nil {
+		return false, fmt.Errorf("failed to check if build state file (%s) exists:\n%w", buildStateFilePath, err)
+	}
+
+	if !exists {
+		logger.Log.Infof("No previous build state found: starting build from scratch")
+		return false, nil
+	}
+
+	var state buildState
+	err = jsonutils.ReadJSONFile(buildStateFilePath, &state)
+	if err != nil {
+		return false, fmt.Errorf("failed to read build state file (%s):\n%w", buildStateFilePath, err)
+	}
+
+	if state.CompletedPhase != buildPhaseCustomizeOS || state.InputsChecksum != inputsChecksum {
+		logger.Log.Infof("Build inputs have changed since the previous build: starting build from scratch")
+		return false, nil
+	}
+
+	outputFilePath := filepath.Join(ic.buildDirAbs, state.OutputFile)
+
+	exists, err = file.PathExists(outputFilePath)
+	if err != nil {
+		return false, fmt.Errorf("failed to check if (%s) exists:\n%w", outputFilePath, err)
+	}
+
+	if !exists {
+		logger.Log.Infof("Output of previous build phase (%s) is missing: starting build from scratch", outputFilePath)
+		return false, nil
+	}
+
+	outputSha256, err := file.GenerateSHA256(outputFilePath)
+	if err != nil {
+		return false, fmt.Errorf("failed to calculate the sha256 of (%s):\n%w", outputFilePath, err)
+	}
+
+	if outputSha256 != state.OutputSha256 {
+		logger.Log.Infof("Output of previous build phase (%s) was modified: starting build from scratch",
+			outputFilePath)
+		return false, nil
+	}
+
+	logger.Log.Infof("Resuming build after phase (%s)", state.CompletedPhase)
+
+	ic.rawImageFile = outputFilePath
+	ic.vulnerabilityScanReport = state.VulnerabilityScan
+	return true, nil
+}
+
+// removeBuildState removes the build state file.
+func removeBuildState(ic *ImageCustomizerParameters) error {
+	buildStateFilePath := filepath.Join(ic.buildDirAbs, buildStateFileName)
+
+	err := file.RemoveFileIfExists(buildStateFilePath)
+	if err != nil {
+		return fmt.Errorf("failed to remove build state file (%s):\n%w", buildStateFilePath, err)
+	}
+
+	return nil
+}
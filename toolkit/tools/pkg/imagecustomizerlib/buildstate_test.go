@@ -0,0 +1,134 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/imagecustomizerapi"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/file"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResumeFromBuildState(t *testing.T) {
+	buildDir := filepath.Join(tmpDir, "TestResumeFromBuildState")
+	defer os.RemoveAll(buildDir)
+
+	err := os.MkdirAll(buildDir, os.ModePerm)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	inputImageFile := filepath.Join(buildDir, "input.vhdx")
+	err = file.Write("input", inputImageFile)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	newParameters := func(config *imagecustomizerapi.Config) *ImageCustomizerParameters {
+		return &ImageCustomizerParameters{
+			buildDirAbs:    buildDir,
+			inputImageFile: inputImageFile,
+			config:         config,
+			rawImageFile:   filepath.Join(buildDir, BaseImageName),
+		}
+	}
+
+	config := &imagecustomizerapi.Config{
+		OS: &imagecustomizerapi.OS{
+			Hostname: "resume-test",
+		},
+	}
+
+	// Nothing to resume from.
+	ic := newParameters(config)
+	inputsChecksum, err := calculateBuildInputsChecksum(ic)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	resumed, err := resumeFromBuildState(ic, inputsChecksum)
+	if !assert.NoError(t, err) || !assert.False(t, resumed) {
+		return
+	}
+
+	// Complete the OS customization phase.
+	err = file.Write("customized", filepath.Join(buildDir, PartitionCustomizedImageName))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	ic.rawImageFile = filepath.Join(buildDir, PartitionCustomizedImageName)
+	err = saveBuildState(ic, inputsChecksum)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// Same inputs.
+	ic = newParameters(config)
+	resumed, err = resumeFromBuildState(ic, inputsChecksum)
+	if !assert.NoError(t, err) || !assert.True(t, resumed) {
+		return
+	}
+
+	assert.Equal(t, filepath.Join(buildDir, PartitionCustomizedImageName), ic.rawImageFile)
+
+	// Changed config.
+	changedConfig := &imagecustomizerapi.Config{
+		OS: &imagecustomizerapi.OS{
+			Hostname: "resume-test-2",
+		},
+	}
+
+	ic = newParameters(changedConfig)
+	changedInputsChecksum, err := calculateBuildInputsChecksum(ic)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.NotEqual(t, inputsChecksum, changedInputsChecksum)
+
+	resumed, err = resumeFromBuildState(ic, changedInputsChecksum)
+	if assert.NoError(t, err) {
+		assert.False(t, resumed)
+		assert.Equal(t, filepath.Join(buildDir, BaseImageName), ic.rawImageFile)
+	}
+
+	// Modified phase output.
+	err = file.Write("modified", filepath.Join(buildDir, PartitionCustomizedImageName))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	ic = newParameters(config)
+	resumed, err = resumeFromBuildState(ic, inputsChecksum)
+	if assert.NoError(t, err) {
+		assert.False(t, resumed)
+	}
+
+	// Build state removed.
+	err = removeBuildState(ic)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.NoFileExists(t, filepath.Join(buildDir, buildStateFileName))
+}
+
+func TestCreateImageCustomizerParametersIsoResume(t *testing.T) {
+	config := &imagecustomizerapi.Config{}
+
+	_, err := createImageCustomizerParameters(tmpDir, "input.iso", testDir, config, false, nil, false, "",
+		"iso", filepath.Join(tmpDir, "image.iso"), "", CustomizeImageOptions{ResumeMode: ResumeModeResume})
+	assert.ErrorContains(t, err, "resuming builds is not supported when the input image is an iso image")
+}
+
+func TestResumeModeIsValid(t *testing.T) {
+	assert.NoError(t, ResumeModeDefault.IsValid())
+	assert.NoError(t, ResumeModeKeepWorkDir.IsValid())
+	assert.NoError(t, ResumeModeResume.IsValid())
+	assert.ErrorContains(t, ResumeMode("restart").IsValid(), "invalid resume mode value (restart)")
+}
@@ -0,0 +1,90 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/safeloopback"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/safemount"
+)
+
+// cleanupManager tears down the resources (temporary folders, mounts, loop devices, etc.) acquired by a build
+// component.
+//
+// The teardown steps run in the reverse order of their registration (like defer statements). But, unlike defer
+// statements, the teardown can outlive the function that acquired the resources. For example, the contents of an
+// input iso are needed until the output image has been created.
+type cleanupManager struct {
+	tasks []cleanupTask
+}
+
+type cleanupTask struct {
+	// What is being cleaned-up. Used in error messages.
+	resource string
+	run      func() error
+}
+
+// add registers a teardown step for the specified resource.
+func (m *cleanupManager) add(resource string, run func() error) {
+	m.tasks = append(m.tasks, cleanupTask{resource: resource, run: run})
+}
+
+// addDir registers a folder (and its contents) to be removed.
+func (m *cleanupManager) addDir(dir string) {
+	m.add(dir, func() error {
+		return os.RemoveAll(dir)
+	})
+}
+
+// addMount registers a mount to be unmounted, unless it has been cleanly closed already.
+func (m *cleanupManager) addMount(mount *safemount.Mount) {
+	m.add(mount.Target(), func() error {
+		mount.Close()
+		return nil
+	})
+}
+
+// addLoopback registers a loop device to be detached, unless it has been cleanly closed already.
+func (m *cleanupManager) addLoopback(loopback *safeloopback.Loopback) {
+	m.add(loopback.DiskFilePath(), func() error {
+		loopback.Close()
+		return nil
+	})
+}
+
+// run runs all the teardown steps, even if some of them fail, and then forgets them. So, calling run again is a
+// no-op.
+func (m *cleanupManager) run() error {
+	var err error
+	for i := len(m.tasks) - 1; i >= 0; i-- {
+		task := m.tasks[i]
+
+		cleanupErr := task.run()
+		if cleanupErr != nil {
+			if err != nil {
+				err = fmt.Errorf("%w:\nfailed to clean-up (%s): %w", err, task.resource, cleanupErr)
+			} else {
+				err = fmt.Errorf("failed to clean-up (%s): %w", task.resource, cleanupErr)
+			}
+		}
+	}
+
+	m.tasks = nil
+	return err
+}
+
+// runDeferred runs all the teardown steps and adds any failure to the error pointed to by 'err'. It is meant to be
+// deferred by functions that have a named error return value.
+func (m *cleanupManager) runDeferred(err *error) {
+	cleanupErr := m.run()
+	if cleanupErr != nil {
+		if *err != nil {
+			*err = fmt.Errorf("%w:\n%w", *err, cleanupErr)
+		} else {
+			*err = cleanupErr
+		}
+	}
+}
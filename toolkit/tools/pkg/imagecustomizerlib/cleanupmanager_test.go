@@ -0,0 +1,74 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCleanupManagerRun(t *testing.T) {
+	testTmpDir := filepath.Join(tmpDir, "TestCleanupManagerRun")
+	defer os.RemoveAll(testTmpDir)
+
+	err := os.MkdirAll(filepath.Join(testTmpDir, "a/b"), os.ModePerm)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	order := []string(nil)
+	cleanups := cleanupManager{}
+	cleanups.addDir(filepath.Join(testTmpDir, "a"))
+	cleanups.add("first", func() error {
+		order = append(order, "first")
+		return fmt.Errorf("first failed")
+	})
+	cleanups.add("second", func() error {
+		order = append(order, "second")
+		return nil
+	})
+	cleanups.add("third", func() error {
+		order = append(order, "third")
+		return fmt.Errorf("third failed")
+	})
+
+	err = cleanups.run()
+	assert.ErrorContains(t, err, "failed to clean-up (third): third failed")
+	assert.ErrorContains(t, err, "failed to clean-up (first): first failed")
+
+	// All the steps run in reverse order, even when some of them fail.
+	assert.Equal(t, []string{"third", "second", "first"}, order)
+	assert.NoDirExists(t, filepath.Join(testTmpDir, "a"))
+
+	// The steps only run once.
+	err = cleanups.run()
+	assert.NoError(t, err)
+	assert.Len(t, order, 3)
+}
+
+func TestCleanupManagerRunDeferred(t *testing.T) {
+	failingCleanups := func() *cleanupManager {
+		cleanups := &cleanupManager{}
+		cleanups.add("resource", func() error {
+			return fmt.Errorf("cleanup failed")
+		})
+		return cleanups
+	}
+
+	err := error(nil)
+	failingCleanups().runDeferred(&err)
+	assert.EqualError(t, err, "failed to clean-up (resource): cleanup failed")
+
+	err = fmt.Errorf("build failed")
+	failingCleanups().runDeferred(&err)
+	assert.EqualError(t, err, "build failed:\nfailed to clean-up (resource): cleanup failed")
+
+	err = fmt.Errorf("build failed")
+	(&cleanupManager{}).runDeferred(&err)
+	assert.EqualError(t, err, "build failed")
+}
@@ -0,0 +1,274 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/imagegen/diskutils"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/logger"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/safechroot"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/safemount"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/shell"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// The directory (relative to the ESP's root) that holds the unified kernel images (UKIs).
+	espUkiDir = "EFI/Linux"
+)
+
+var (
+	// Matches the kernel files that are versioned by the kernel version (e.g. 'vmlinuz-6.6.47.1-1.azl3' and
+	// 'initramfs-6.6.47.1-1.azl3.img').
+	espKernelFileRegex = regexp.MustCompile(`^(vmlinuz|initramfs|initrd\.img|config|System\.map)-(.+?)(\.img)?$`)
+)
+
+// syncEsp keeps the ESP in sync with the kernel and bootloader updates that were applied to the image:
+//   - Boot files that were written to the ESP's mount directory, while the ESP was not mounted over it, are copied
+//     into the ESP. For example, this can happen when the ESP is not listed in the image's fstab file or when a
+//     script unmounts it before updating the kernel or the bootloader packages.
+//   - Kernel files and unified kernel images on the ESP that belong to kernels that are no longer installed are
+//     removed.
+func syncEsp(syncEspEnabled bool, buildDir string, imageConnection *ImageConnection) error {
+	if !syncEspEnabled {
+		return nil
+	}
+
+	logger.Log.Infof("Syncing ESP contents")
+
+	imageChroot := imageConnection.Chroot()
+
+	diskPartitions, err := diskutils.GetDiskPartitions(imageConnection.Loopback().DevicePath())
+	if err != nil {
+		return fmt.Errorf("failed to read image partitions:\n%w", err)
+	}
+
+	espMount, parentMount, err := findEspMounts(imageChroot.GetMountPoints(), diskPartitions)
+	if err != nil {
+		return fmt.Errorf("cannot sync ESP:\n%w", err)
+	}
+
+	// A non-recursive bind mount of the file system that holds the ESP's mount directory (e.g. the rootfs or a
+	// separate /boot partition) exposes the files hidden underneath the ESP mount.
+	parentBindDir, err := os.MkdirTemp(buildDir, "tmp-esp-parent-bind-")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary mount folder for (%s):\n%w", parentMount.GetTarget(), err)
+	}
+	defer os.RemoveAll(parentBindDir)
+
+	parentBindMount, err := safemount.NewMount(filepath.Join(imageChroot.RootDir(), parentMount.GetTarget()),
+		parentBindDir, "" /*fstype*/, unix.MS_BIND, "" /*data*/, false /*makeAndDelete*/)
+	if err != nil {
+		return fmt.Errorf("failed to bind mount (%s):\n%w", parentMount.GetTarget(), err)
+	}
+	defer parentBindMount.Close()
+
+	espRelativePath, err := filepath.Rel(parentMount.GetTarget(), espMount.GetTarget())
+	if err != nil {
+		return fmt.Errorf("failed to get ESP mount path relative to (%s):\n%w", parentMount.GetTarget(), err)
+	}
+
+	shadowedEspDir := filepath.Join(parentBindDir, espRelativePath)
+	espDir := filepath.Join(imageChroot.RootDir(), espMount.GetTarget())
+
+	synced, err := syncShadowedEspFiles(shadowedEspDir, espDir, espMount.GetTarget())
+	if err != nil {
+		return err
+	}
+
+	err = parentBindMount.CleanClose()
+	if err != nil {
+		return err
+	}
+
+	kernelVersions, err := getInstalledKernelVersions(imageChroot.RootDir())
+	if err != nil {
+		return err
+	}
+
+	pruned, err := pruneStaleEspKernelFiles(espDir, kernelVersions)
+	if err != nil {
+		return err
+	}
+
+	if !synced && !pruned {
+		logger.Log.Debugf("ESP is already in sync")
+	}
+
+	return nil
+}
+
+// findEspMounts returns the mount of the ESP and the mount of the file system that holds the ESP's mount directory.
+func findEspMounts(mountPoints []*safechroot.MountPoint, diskPartitions []diskutils.PartitionInfo,
+) (*safechroot.MountPoint, *safechroot.MountPoint, error) {
+	espPaths := []string(nil)
+	for _, diskPartition := range diskPartitions {
+		if diskPartition.PartitionTypeUuid == diskutils.EfiSystemPartitionTypeUuid {
+			espPaths = append(espPaths, diskPartition.Path)
+		}
+	}
+
+	var espMount *safechroot.MountPoint
+	for _, mountPoint := range mountPoints {
+		if slices.Contains(espPaths, mountPoint.GetSource()) {
+			espMount = mountPoint
+			break
+		}
+	}
+
+	if espMount == nil {
+		return nil, nil, fmt.Errorf("ESP is not mounted")
+	}
+
+	var parentMount *safechroot.MountPoint
+	for _, mountPoint := range mountPoints {
+		if mountPoint == espMount || !isParentMountTarget(mountPoint.GetTarget(), espMount.GetTarget()) {
+			continue
+		}
+
+		if parentMount == nil || len(mountPoint.GetTarget()) > len(parentMount.GetTarget()) {
+			parentMount = mountPoint
+		}
+	}
+
+	if parentMount == nil {
+		return nil, nil, fmt.Errorf("failed to find the file system that holds the ESP mount directory (%s)",
+			espMount.GetTarget())
+	}
+
+	return espMount, parentMount, nil
+}
+
+func isParentMountTarget(parentTarget string, target string) bool {
+	parentTarget = filepath.Clean(parentTarget)
+	target = filepath.Clean(target)
+
+	if parentTarget == "/" {
+		return target != "/"
+	}
+
+	return strings.HasPrefix(target, parentTarget+"/")
+}
+
+// syncShadowedEspFiles copies the contents of the ESP's mount directory (as seen without the ESP mounted over it) into
+// the ESP and then removes them from the underlying file system. Files that already exist on the ESP are overwritten.
+// Returns whether any files were copied.
+func syncShadowedEspFiles(shadowedEspDir string, espDir string, espMountPath string) (bool, error) {
+	entries, err := os.ReadDir(shadowedEspDir)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to read directory (%s):\n%w", shadowedEspDir, err)
+	}
+
+	if len(entries) <= 0 {
+		return false, nil
+	}
+
+	for _, entry := range entries {
+		logger.Log.Infof("Syncing (%s) to ESP", filepath.Join(espMountPath, entry.Name()))
+	}
+
+	// Notes:
+	// The ESP is a FAT32 file system. So, ownership, permissions and symlinks can't be preserved.
+	err = shell.NewExecBuilder("cp", "--recursive", "--dereference", "--preserve=timestamps",
+		shadowedEspDir+"/.", espDir).
+		LogLevel(logrus.TraceLevel, logrus.DebugLevel).
+		ErrorStderrLines(1).
+		Execute()
+	if err != nil {
+		return false, fmt.Errorf("failed to copy files to ESP:\n%w", err)
+	}
+
+	// Remove the now stale copies from the underlying file system, but keep the mount directory.
+	for _, entry := range entries {
+		err = os.RemoveAll(filepath.Join(shadowedEspDir, entry.Name()))
+		if err != nil {
+			return false, fmt.Errorf("failed to remove shadowed ESP file (%s):\n%w", entry.Name(), err)
+		}
+	}
+
+	return true, nil
+}
+
+// pruneStaleEspKernelFiles removes the kernel files (e.g. vmlinuz-<ver>) and unified kernel images on the ESP that
+// don't belong to any of the installed kernels. Returns whether any files were removed.
+func pruneStaleEspKernelFiles(espDir string, kernelVersions []string) (bool, error) {
+	if len(kernelVersions) <= 0 {
+		// Never leave the ESP without a kernel.
+		return false, nil
+	}
+
+	staleFiles := []string(nil)
+	err := filepath.WalkDir(espDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		relativePath, err := filepath.Rel(espDir, path)
+		if err != nil {
+			return err
+		}
+
+		if isStaleEspKernelFile(relativePath, kernelVersions) {
+			staleFiles = append(staleFiles, relativePath)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to scan ESP for stale kernel files:\n%w", err)
+	}
+
+	for _, staleFile := range staleFiles {
+		logger.Log.Infof("Removing stale kernel file (%s) from ESP", staleFile)
+
+		err = os.Remove(filepath.Join(espDir, staleFile))
+		if err != nil {
+			return false, fmt.Errorf("failed to remove stale kernel file (%s) from ESP:\n%w", staleFile, err)
+		}
+	}
+
+	return len(staleFiles) > 0, nil
+}
+
+func isStaleEspKernelFile(relativePath string, kernelVersions []string) bool {
+	fileName := filepath.Base(relativePath)
+
+	if filepath.Dir(relativePath) == espUkiDir && strings.HasSuffix(fileName, ".efi") {
+		for _, kernelVersion := range kernelVersions {
+			if strings.Contains(fileName, kernelVersion) {
+				return false
+			}
+		}
+
+		return true
+	}
+
+	match := espKernelFileRegex.FindStringSubmatch(fileName)
+	if match == nil {
+		return false
+	}
+
+	// Rescue kernels are not tied to an installed kernel.
+	kernelVersion := match[2]
+	if strings.Contains(kernelVersion, "rescue") {
+		return false
+	}
+
+	return !slices.Contains(kernelVersions, kernelVersion)
+}
@@ -0,0 +1,192 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/imagegen/diskutils"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/safechroot"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSyncShadowedEspFiles(t *testing.T) {
+	testTempDir := filepath.Join(tmpDir, "TestSyncShadowedEspFiles")
+	defer os.RemoveAll(testTempDir)
+
+	shadowedEspDir := filepath.Join(testTempDir, "bootfs/efi")
+	espDir := filepath.Join(testTempDir, "esp")
+
+	err := os.MkdirAll(filepath.Join(shadowedEspDir, "EFI/BOOT"), os.ModePerm)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	err = os.MkdirAll(filepath.Join(espDir, "EFI/BOOT"), os.ModePerm)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	err = os.WriteFile(filepath.Join(shadowedEspDir, "EFI/BOOT/grubx64.efi"), []byte("new"), 0o644)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	err = os.WriteFile(filepath.Join(espDir, "EFI/BOOT/grubx64.efi"), []byte("old"), 0o644)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	err = os.WriteFile(filepath.Join(espDir, "EFI/BOOT/bootx64.efi"), []byte("shim"), 0o644)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	synced, err := syncShadowedEspFiles(shadowedEspDir, espDir, "/boot/efi")
+	assert.NoError(t, err)
+	assert.True(t, synced)
+
+	grubContents, err := os.ReadFile(filepath.Join(espDir, "EFI/BOOT/grubx64.efi"))
+	assert.NoError(t, err)
+	assert.Equal(t, "new", string(grubContents))
+
+	shimContents, err := os.ReadFile(filepath.Join(espDir, "EFI/BOOT/bootx64.efi"))
+	assert.NoError(t, err)
+	assert.Equal(t, "shim", string(shimContents))
+
+	// The mount directory is kept, but its contents are removed.
+	entries, err := os.ReadDir(shadowedEspDir)
+	assert.NoError(t, err)
+	assert.Empty(t, entries)
+
+	// Nothing left to sync.
+	synced, err = syncShadowedEspFiles(shadowedEspDir, espDir, "/boot/efi")
+	assert.NoError(t, err)
+	assert.False(t, synced)
+}
+
+func TestSyncShadowedEspFilesMissingDir(t *testing.T) {
+	synced, err := syncShadowedEspFiles(filepath.Join(tmpDir, "TestSyncShadowedEspFilesMissingDir"), tmpDir,
+		"/boot/efi")
+	assert.NoError(t, err)
+	assert.False(t, synced)
+}
+
+func TestFindEspMountsSeparateBootPartition(t *testing.T) {
+	mountPoints := []*safechroot.MountPoint{
+		safechroot.NewPreDefaultsMountPoint("/dev/loop0p3", "/", "ext4", 0, ""),
+		safechroot.NewMountPoint("/dev/loop0p2", "/boot", "ext4", 0, ""),
+		safechroot.NewMountPoint("/dev/loop0p1", "/boot/efi", "vfat", 0, ""),
+	}
+
+	diskPartitions := []diskutils.PartitionInfo{
+		{Path: "/dev/loop0", Type: "disk"},
+		{Path: "/dev/loop0p1", Type: "part", PartitionTypeUuid: diskutils.EfiSystemPartitionTypeUuid},
+		{Path: "/dev/loop0p2", Type: "part"},
+		{Path: "/dev/loop0p3", Type: "part"},
+	}
+
+	espMount, parentMount, err := findEspMounts(mountPoints, diskPartitions)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, "/boot/efi", espMount.GetTarget())
+	assert.Equal(t, "/boot", parentMount.GetTarget())
+}
+
+func TestFindEspMountsCustomMountPath(t *testing.T) {
+	mountPoints := []*safechroot.MountPoint{
+		safechroot.NewPreDefaultsMountPoint("/dev/loop0p2", "/", "ext4", 0, ""),
+		safechroot.NewMountPoint("/dev/loop0p1", "/efi", "vfat", 0, ""),
+	}
+
+	diskPartitions := []diskutils.PartitionInfo{
+		{Path: "/dev/loop0p1", Type: "part", PartitionTypeUuid: diskutils.EfiSystemPartitionTypeUuid},
+		{Path: "/dev/loop0p2", Type: "part"},
+	}
+
+	espMount, parentMount, err := findEspMounts(mountPoints, diskPartitions)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, "/efi", espMount.GetTarget())
+	assert.Equal(t, "/", parentMount.GetTarget())
+}
+
+func TestFindEspMountsNotMounted(t *testing.T) {
+	mountPoints := []*safechroot.MountPoint{
+		safechroot.NewPreDefaultsMountPoint("/dev/loop0p2", "/", "ext4", 0, ""),
+	}
+
+	diskPartitions := []diskutils.PartitionInfo{
+		{Path: "/dev/loop0p1", Type: "part", PartitionTypeUuid: diskutils.EfiSystemPartitionTypeUuid},
+		{Path: "/dev/loop0p2", Type: "part"},
+	}
+
+	_, _, err := findEspMounts(mountPoints, diskPartitions)
+	assert.ErrorContains(t, err, "ESP is not mounted")
+}
+
+func TestPruneStaleEspKernelFiles(t *testing.T) {
+	testTempDir := filepath.Join(tmpDir, "TestPruneStaleEspKernelFiles")
+	defer os.RemoveAll(testTempDir)
+
+	files := []string{
+		"vmlinuz-6.6.1.1-1.azl3",
+		"initramfs-6.6.1.1-1.azl3.img",
+		"vmlinuz-6.6.2.1-1.azl3",
+		"initramfs-6.6.2.1-1.azl3.img",
+		"vmlinuz-0-rescue-1234",
+		"EFI/BOOT/grubx64.efi",
+		"EFI/Linux/azurelinux-6.6.1.1-1.azl3.efi",
+		"EFI/Linux/azurelinux-6.6.2.1-1.azl3.efi",
+	}
+
+	for _, file := range files {
+		path := filepath.Join(testTempDir, file)
+
+		err := os.MkdirAll(filepath.Dir(path), os.ModePerm)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		err = os.WriteFile(path, []byte(file), 0o644)
+		if !assert.NoError(t, err) {
+			return
+		}
+	}
+
+	pruned, err := pruneStaleEspKernelFiles(testTempDir, []string{"6.6.2.1-1.azl3"})
+	assert.NoError(t, err)
+	assert.True(t, pruned)
+
+	expectedExists := map[string]bool{
+		"vmlinuz-6.6.1.1-1.azl3":                  false,
+		"initramfs-6.6.1.1-1.azl3.img":            false,
+		"vmlinuz-6.6.2.1-1.azl3":                  true,
+		"initramfs-6.6.2.1-1.azl3.img":            true,
+		"vmlinuz-0-rescue-1234":                   true,
+		"EFI/BOOT/grubx64.efi":                    true,
+		"EFI/Linux/azurelinux-6.6.1.1-1.azl3.efi": false,
+		"EFI/Linux/azurelinux-6.6.2.1-1.azl3.efi": true,
+	}
+	for file, exists := range expectedExists {
+		_, err := os.Stat(filepath.Join(testTempDir, file))
+		assert.Equal(t, exists, err == nil, "file (%s)", file)
+	}
+
+	// Nothing left to prune.
+	pruned, err = pruneStaleEspKernelFiles(testTempDir, []string{"6.6.2.1-1.azl3"})
+	assert.NoError(t, err)
+	assert.False(t, pruned)
+
+	// Without any installed kernel, nothing is removed.
+	pruned, err = pruneStaleEspKernelFiles(testTempDir, nil)
+	assert.NoError(t, err)
+	assert.False(t, pruned)
+}
@@ -4,6 +4,7 @@
 package imagecustomizerlib
 
 import (
+	"context"
 	"os"
 	"path/filepath"
 	"testing"
@@ -47,8 +48,8 @@ func TestCustomizeImageHostname(t *testing.T) {
 	outImageFilePath := filepath.Join(buildDir, "image.qcow2")
 
 	// Customize image.
-	err := CustomizeImageWithConfigFile(buildDir, configFile, baseImage, nil, outImageFilePath, "raw", "",
-		"" /*outputPXEArtifactsDir*/, false /*useBaseImageRpmRepos*/, false /*enableShrinkFilesystems*/)
+	err := CustomizeImageWithConfigFile(context.Background(), buildDir, configFile, baseImage, nil, outImageFilePath, "raw", "",
+		"" /*outputPXEArtifactsDir*/, false /*useBaseImageRpmRepos*/, false /*enableShrinkFilesystems*/, CustomizeImageOptions{})
 	if !assert.NoError(t, err) {
 		return
 	}
@@ -11,7 +11,7 @@ import (
 
 func doOsCustomizations(buildDir string, baseConfigPath string, config *imagecustomizerapi.Config,
 	imageConnection *ImageConnection, rpmsSources []string, useBaseImageRpmRepos bool, partitionsCustomized bool,
-	imageUuid string) error {
+	imageUuid string, buildResources BuildResources) error {
 	var err error
 
 	imageChroot := imageConnection.Chroot()
@@ -23,12 +23,22 @@ func doOsCustomizations(buildDir string, baseConfigPath string, config *imagecus
 		return err
 	}
 
+	kernelVersions, err := getInstalledKernelVersions(imageChroot.RootDir())
+	if err != nil {
+		return err
+	}
+
 	err = addRemoveAndUpdatePackages(buildDir, baseConfigPath, config.OS, imageChroot, rpmsSources,
 		useBaseImageRpmRepos)
 	if err != nil {
 		return err
 	}
 
+	kernelsUpdated, err := handleKernelUpdates(kernelVersions, imageChroot)
+	if err != nil {
+		return err
+	}
+
 	err = UpdateHostname(config.OS.Hostname, imageChroot)
 	if err != nil {
 		return err
@@ -59,7 +69,17 @@ func doOsCustomizations(buildDir string, baseConfigPath string, config *imagecus
 		return err
 	}
 
-	err = addCustomizerRelease(imageChroot, ToolVersion, buildTime, imageUuid)
+	err = enableRepart(config.Storage.Disks, imageChroot)
+	if err != nil {
+		return err
+	}
+
+	releaseInfo, err := getIsoReleaseInfo(config.Iso)
+	if err != nil {
+		return err
+	}
+
+	err = addCustomizerRelease(imageChroot, ToolVersion, buildTime, imageUuid, releaseInfo)
 	if err != nil {
 		return err
 	}
@@ -85,8 +105,8 @@ func doOsCustomizations(buildDir string, baseConfigPath string, config *imagecus
 		return err
 	}
 
-	if partitionsCustomized || overlayUpdated || verityUpdated {
-		err = regenerateInitrd(imageChroot)
+	if partitionsCustomized || overlayUpdated || verityUpdated || kernelsUpdated {
+		err = regenerateInitrd(imageChroot, buildResources)
 		if err != nil {
 			return err
 		}
@@ -97,6 +117,11 @@ func doOsCustomizations(buildDir string, baseConfigPath string, config *imagecus
 		return err
 	}
 
+	err = syncEsp(config.OS.SyncEsp, buildDir, imageConnection)
+	if err != nil {
+		return err
+	}
+
 	err = restoreResolvConf(resolvConf, imageChroot)
 	if err != nil {
 		return err
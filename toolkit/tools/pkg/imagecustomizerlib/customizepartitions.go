@@ -4,14 +4,15 @@
 package imagecustomizerlib
 
 import (
+	"context"
 	"path/filepath"
 
 	"github.com/microsoft/azurelinux/toolkit/tools/imagecustomizerapi"
 	"github.com/microsoft/azurelinux/toolkit/tools/internal/logger"
 )
 
-func customizePartitions(buildDir string, baseConfigPath string, config *imagecustomizerapi.Config,
-	buildImageFile string,
+func customizePartitions(ctx context.Context, buildDir string, baseConfigPath string, config *imagecustomizerapi.Config,
+	buildImageFile string, buildResources BuildResources,
 ) (bool, string, map[string]string, error) {
 	switch {
 	case config.CustomizePartitions():
@@ -21,8 +22,8 @@ func customizePartitions(buildDir string, baseConfigPath string, config *imagecu
 
 		// If there is no known way to create the new partition layout from the old one,
 		// then fallback to creating the new partitions from scratch and doing a file copy.
-		partIdToPartUuid, err := customizePartitionsUsingFileCopy(buildDir, baseConfigPath, config,
-			buildImageFile, newBuildImageFile)
+		partIdToPartUuid, err := customizePartitionsUsingFileCopy(ctx, buildDir, baseConfigPath, config,
+			buildImageFile, newBuildImageFile, buildResources)
 		if err != nil {
 			return false, "", nil, err
 		}
@@ -4,6 +4,7 @@
 package imagecustomizerlib
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/microsoft/azurelinux/toolkit/tools/imagecustomizerapi"
@@ -12,8 +13,8 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
-func customizePartitionsUsingFileCopy(buildDir string, baseConfigPath string, config *imagecustomizerapi.Config,
-	buildImageFile string, newBuildImageFile string,
+func customizePartitionsUsingFileCopy(ctx context.Context, buildDir string, baseConfigPath string, config *imagecustomizerapi.Config,
+	buildImageFile string, newBuildImageFile string, buildResources BuildResources,
 ) (map[string]string, error) {
 	existingImageConnection, err := connectToExistingImage(buildImageFile, buildDir, "imageroot", false)
 	if err != nil {
@@ -24,7 +25,7 @@ func customizePartitionsUsingFileCopy(buildDir string, baseConfigPath string, co
 	diskConfig := config.Storage.Disks[0]
 
 	installOSFunc := func(imageChroot *safechroot.Chroot) error {
-		return copyFilesIntoNewDisk(existingImageConnection.Chroot(), imageChroot)
+		return copyFilesIntoNewDisk(ctx, existingImageConnection.Chroot(), imageChroot, buildResources)
 	}
 
 	partIdToPartUuid, err := createNewImage(newBuildImageFile, diskConfig, config.Storage.FileSystems,
@@ -41,22 +42,35 @@ func customizePartitionsUsingFileCopy(buildDir string, baseConfigPath string, co
 	return partIdToPartUuid, nil
 }
 
-func copyFilesIntoNewDisk(existingImageChroot *safechroot.Chroot, newImageChroot *safechroot.Chroot) error {
-	err := copyPartitionFiles(existingImageChroot.RootDir()+"/.", newImageChroot.RootDir())
+func copyFilesIntoNewDisk(ctx context.Context, existingImageChroot *safechroot.Chroot, newImageChroot *safechroot.Chroot,
+	buildResources BuildResources,
+) error {
+	err := copyPartitionFiles(ctx, existingImageChroot.RootDir()+"/.", newImageChroot.RootDir(), buildResources)
 	if err != nil {
 		return fmt.Errorf("failed to copy files into new partition layout:\n%w", err)
 	}
 	return nil
 }
 
-func copyPartitionFiles(sourceRoot, targetRoot string) error {
+const (
+	copyPartitionFilesPreserveAttrs = "mode,ownership,timestamps,links,xattr"
+)
+
+func copyPartitionFiles(ctx context.Context, sourceRoot, targetRoot string, buildResources BuildResources) error {
 	// Notes:
-	// `-a` ensures unix permissions, extended attributes (including SELinux), and sub-directories (-r) are copied.
+	// `--preserve` ensures unix permissions, hard links, and extended attributes are copied. The extended attributes
+	// include the file capabilities (security.capability), the SELinux labels (security.selinux), and the POSIX ACLs.
+	// Unlike `-a`, listing the attributes explicitly makes cp fail if any of them can't be preserved, instead of
+	// silently dropping them.
 	// `--no-dereference` ensures that symlinks are copied as symlinks.
-	copyArgs := []string{"--verbose", "--no-clobber", "-a", "--no-dereference", "--sparse", "always",
-		sourceRoot, targetRoot}
+	// `--sparse always` keeps the holes of sparse files. Together with preserving `links` (i.e. hard links), this keeps
+	// the copy (and the squashfs created from it) from growing beyond the size of the source tree.
+	copyArgs := []string{"--verbose", "--no-clobber", "--recursive", "--no-dereference",
+		"--preserve=" + copyPartitionFilesPreserveAttrs, "--sparse", "always", sourceRoot, targetRoot}
 
-	err := shell.NewExecBuilder("cp", copyArgs...).
+	program, args := resourceLimitedCommand(buildResources, "cp", copyArgs...)
+	err := shell.NewExecBuilder(program, args...).
+		Context(ctx).
 		LogLevel(logrus.TraceLevel, logrus.DebugLevel).
 		ErrorStderrLines(1).
 		Execute()
@@ -0,0 +1,194 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/file"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	fileCapabilityXattr = "security.capability"
+	testUserXattr       = "user.imagecustomizer.test"
+)
+
+// The 'security.capability' value of a file that has 'cap_net_raw=ep' set (e.g. /usr/bin/ping).
+var testNetRawFileCapability = []byte{
+	0x01, 0x00, 0x00, 0x02, // VFS_CAP_REVISION_2 | VFS_CAP_FLAGS_EFFECTIVE
+	0x00, 0x20, 0x00, 0x00, // permitted[0]: CAP_NET_RAW
+	0x00, 0x00, 0x00, 0x00, // inheritable[0]
+	0x00, 0x00, 0x00, 0x00, // permitted[1]
+	0x00, 0x00, 0x00, 0x00, // inheritable[1]
+}
+
+func TestCopyPartitionFilesPreservesXattrs(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("Test must be run as root because it sets file capabilities")
+	}
+
+	testTmpDir := filepath.Join(tmpDir, "TestCopyPartitionFilesPreservesXattrs")
+	defer os.RemoveAll(testTmpDir)
+
+	sourceDir := filepath.Join(testTmpDir, "source")
+	targetDir := filepath.Join(testTmpDir, "target")
+
+	err := createTestRootfsWithCapabilities(sourceDir)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	err = os.MkdirAll(targetDir, os.ModePerm)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	err = copyPartitionFiles(context.Background(), sourceDir+"/.", targetDir, BuildResources{})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assertTestRootfsCapabilities(t, targetDir)
+}
+
+func TestCopyPartitionFilesPreservesHardLinksAndSparseFiles(t *testing.T) {
+	testTmpDir := filepath.Join(tmpDir, "TestCopyPartitionFilesPreservesHardLinksAndSparseFiles")
+	defer os.RemoveAll(testTmpDir)
+
+	sourceDir := filepath.Join(testTmpDir, "source")
+	targetDir := filepath.Join(testTmpDir, "target")
+
+	for _, dir := range []string{"usr/share/locale/en", "usr/share/locale/en_US", "var/lib"} {
+		err := os.MkdirAll(filepath.Join(sourceDir, dir), os.ModePerm)
+		if !assert.NoError(t, err) {
+			return
+		}
+	}
+
+	err := file.Write("messages", filepath.Join(sourceDir, "usr/share/locale/en/messages.mo"))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	err = os.Link(filepath.Join(sourceDir, "usr/share/locale/en/messages.mo"),
+		filepath.Join(sourceDir, "usr/share/locale/en_US/messages.mo"))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// A 64 MiB file that only has data in its last block.
+	sparseFileSize := int64(64 * 1024 * 1024)
+	sparseFile, err := os.Create(filepath.Join(sourceDir, "var/lib/sparse.img"))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	_, err = sparseFile.WriteAt([]byte("data"), sparseFileSize-4)
+	sparseFile.Close()
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	err = os.MkdirAll(targetDir, os.ModePerm)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	err = copyPartitionFiles(context.Background(), sourceDir+"/.", targetDir, BuildResources{})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	var enStat, enUsStat, sparseStat unix.Stat_t
+	err = unix.Lstat(filepath.Join(targetDir, "usr/share/locale/en/messages.mo"), &enStat)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	err = unix.Lstat(filepath.Join(targetDir, "usr/share/locale/en_US/messages.mo"), &enUsStat)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, enStat.Ino, enUsStat.Ino)
+	assert.Equal(t, uint64(2), uint64(enStat.Nlink))
+
+	err = unix.Lstat(filepath.Join(targetDir, "var/lib/sparse.img"), &sparseStat)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, sparseFileSize, sparseStat.Size)
+	assert.Less(t, sparseStat.Blocks*statBlockSize, sparseFileSize/2)
+}
+
+func TestCopyPartitionFilesStopped(t *testing.T) {
+	testTmpDir := filepath.Join(tmpDir, "TestCopyPartitionFilesStopped")
+	defer os.RemoveAll(testTmpDir)
+
+	sourceDir := filepath.Join(testTmpDir, "source")
+	targetDir := filepath.Join(testTmpDir, "target")
+
+	for _, dir := range []string{sourceDir, targetDir} {
+		err := os.MkdirAll(dir, os.ModePerm)
+		if !assert.NoError(t, err) {
+			return
+		}
+	}
+
+	err := file.Write("data", filepath.Join(sourceDir, "file"))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err = copyPartitionFiles(ctx, sourceDir+"/.", targetDir, BuildResources{})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.NoFileExists(t, filepath.Join(targetDir, "file"))
+}
+
+// createTestRootfsWithCapabilities creates a folder that holds a file with
+// both file capabilities and a user extended attribute.
+func createTestRootfsWithCapabilities(rootDir string) error {
+	err := os.MkdirAll(filepath.Join(rootDir, "usr/bin"), os.ModePerm)
+	if err != nil {
+		return err
+	}
+
+	pingPath := filepath.Join(rootDir, "usr/bin/ping")
+	err = file.Write("#!/bin/sh\n", pingPath)
+	if err != nil {
+		return err
+	}
+
+	err = unix.Setxattr(pingPath, fileCapabilityXattr, testNetRawFileCapability, 0)
+	if err != nil {
+		return err
+	}
+
+	return unix.Setxattr(pingPath, testUserXattr, []byte("value"), 0)
+}
+
+func assertTestRootfsCapabilities(t *testing.T, rootDir string) {
+	pingPath := filepath.Join(rootDir, "usr/bin/ping")
+
+	capability := make([]byte, 64)
+	size, err := unix.Getxattr(pingPath, fileCapabilityXattr, capability)
+	if assert.NoError(t, err) {
+		assert.Equal(t, testNetRawFileCapability, capability[:size])
+	}
+
+	userXattr := make([]byte, 64)
+	size, err = unix.Getxattr(pingPath, testUserXattr, userXattr)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "value", string(userXattr[:size]))
+	}
+}
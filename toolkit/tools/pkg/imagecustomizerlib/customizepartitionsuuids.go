@@ -139,7 +139,7 @@ func resetPartitionUuid(device string, partNum int) (string, error) {
 func fixPartitionUuidsInFstabFile(partitions []diskutils.PartitionInfo, newUuids []string, newPartUuids []string,
 	buildDir string,
 ) error {
-	rootfsPartition, err := findRootfsPartition(partitions, buildDir)
+	rootfsPartition, err := findRootfsPartition(partitions, buildDir, false /*readOnly*/)
 	if err != nil {
 		return err
 	}
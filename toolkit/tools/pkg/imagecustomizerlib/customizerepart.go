@@ -0,0 +1,147 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/imagecustomizerapi"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/file"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/logger"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/safechroot"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/shell"
+)
+
+const (
+	repartDefinitionsDir   = "/usr/lib/repart.d"
+	repartServiceName      = "systemd-repart.service"
+	repartServiceUnitPath  = "/usr/lib/systemd/system/" + repartServiceName
+	repartWantedByTarget   = "sysinit.target"
+	repartTypeLinuxGeneric = "linux-generic"
+	repartTypeEsp          = "esp"
+	repartTypeBiosGrub     = "21686148-6449-6e6f-744e-656564454649"
+)
+
+// repartDefinition is a single systemd-repart partition definition file.
+type repartDefinition struct {
+	fileName string
+	content  string
+}
+
+// enableRepart writes the systemd-repart partition definitions for the disk layout into the image and enables the
+// systemd-repart service, so that the partitions marked for growth are expanded on first boot.
+func enableRepart(disks []imagecustomizerapi.Disk, imageChroot *safechroot.Chroot) error {
+	if len(disks) <= 0 || !disks[0].HasRepart() {
+		return nil
+	}
+
+	logger.Log.Infof("Enabling systemd-repart")
+
+	// Multiple disks are not supported by the API. So, the first disk is the OS disk.
+	definitions, err := generateRepartDefinitions(disks[0])
+	if err != nil {
+		return err
+	}
+
+	serviceExists, err := file.PathExists(filepath.Join(imageChroot.RootDir(), repartServiceUnitPath))
+	if err != nil {
+		return fmt.Errorf("failed to check if (%s) exists:\n%w", repartServiceUnitPath, err)
+	}
+
+	if !serviceExists {
+		return fmt.Errorf("cannot enable systemd-repart:\n(%s) not found in the image", repartServiceUnitPath)
+	}
+
+	for _, definition := range definitions {
+		definitionPath := filepath.Join(imageChroot.RootDir(), repartDefinitionsDir, definition.fileName)
+		err = file.Write(definition.content, definitionPath)
+		if err != nil {
+			return fmt.Errorf("failed to write systemd-repart definition (%s):\n%w", definitionPath, err)
+		}
+	}
+
+	err = imageChroot.UnsafeRun(func() error {
+		return shell.ExecuteLiveWithErr(1, "systemctl", "add-wants", repartWantedByTarget, repartServiceName)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to enable (%s):\n%w", repartServiceName, err)
+	}
+
+	return nil
+}
+
+// generateRepartDefinitions creates one systemd-repart definition per partition.
+// systemd-repart assigns existing partitions to definitions in order, so every partition requires a definition even
+// if it is not going to be grown.
+func generateRepartDefinitions(disk imagecustomizerapi.Disk) ([]repartDefinition, error) {
+	definitions := []repartDefinition(nil)
+	for i, partition := range disk.Partitions {
+		repartType, err := partitionToRepartType(partition)
+		if err != nil {
+			return nil, err
+		}
+
+		lines := []string{
+			"[Partition]",
+			fmt.Sprintf("Type=%s", repartType),
+		}
+
+		if partition.Label != "" {
+			lines = append(lines, fmt.Sprintf("Label=%s", partition.Label))
+		}
+
+		end, hasEnd := partition.GetEnd()
+		grow := partition.Repart != nil && partition.Repart.Grow
+
+		switch {
+		case grow:
+			// Also grow the file system to fill the grown partition.
+			lines = append(lines, "GrowFileSystem=yes")
+			if hasEnd {
+				lines = append(lines, fmt.Sprintf("SizeMinBytes=%d", end-*partition.Start))
+			}
+			if partition.Repart.MaxSize != nil {
+				lines = append(lines, fmt.Sprintf("SizeMaxBytes=%d", *partition.Repart.MaxSize))
+			}
+
+		case hasEnd:
+			size := end - *partition.Start
+			lines = append(lines, fmt.Sprintf("SizeMinBytes=%d", size))
+			lines = append(lines, fmt.Sprintf("SizeMaxBytes=%d", size))
+
+		default:
+			return nil, fmt.Errorf("partition (%s) must have a fixed size when systemd-repart is enabled", partition.Id)
+		}
+
+		definition := repartDefinition{
+			fileName: fmt.Sprintf("%02d-%s.conf", (i+1)*10, partition.Id),
+			content:  strings.Join(lines, "\n") + "\n",
+		}
+		definitions = append(definitions, definition)
+	}
+
+	return definitions, nil
+}
+
+func partitionToRepartType(partition imagecustomizerapi.Partition) (string, error) {
+	if partition.TypeUuid != "" {
+		return partition.TypeUuid, nil
+	}
+
+	switch partition.Type {
+	case imagecustomizerapi.PartitionTypeESP:
+		return repartTypeEsp, nil
+
+	case imagecustomizerapi.PartitionTypeBiosGrub:
+		return repartTypeBiosGrub, nil
+
+	case imagecustomizerapi.PartitionTypeDefault:
+		return repartTypeLinuxGeneric, nil
+
+	default:
+		return "", fmt.Errorf("unknown partition type (%s)", partition.Type)
+	}
+}
@@ -0,0 +1,70 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"testing"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/imagecustomizerapi"
+	"github.com/microsoft/azurelinux/toolkit/tools/imagegen/diskutils"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/ptrutils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerateRepartDefinitions(t *testing.T) {
+	disk := imagecustomizerapi.Disk{
+		PartitionTableType: imagecustomizerapi.PartitionTableTypeGpt,
+		MaxSize:            ptrutils.PtrTo(imagecustomizerapi.DiskSize(4 * diskutils.GiB)),
+		Partitions: []imagecustomizerapi.Partition{
+			{
+				Id:    "esp",
+				Start: ptrutils.PtrTo(imagecustomizerapi.DiskSize(1 * diskutils.MiB)),
+				End:   ptrutils.PtrTo(imagecustomizerapi.DiskSize(9 * diskutils.MiB)),
+				Type:  imagecustomizerapi.PartitionTypeESP,
+			},
+			{
+				Id:       "rootfs",
+				Label:    "root",
+				Start:    ptrutils.PtrTo(imagecustomizerapi.DiskSize(9 * diskutils.MiB)),
+				TypeUuid: "4f68bce3-e8cd-4db1-96e7-fbcaf984b709",
+				Repart: &imagecustomizerapi.PartitionRepart{
+					Grow:    true,
+					MaxSize: ptrutils.PtrTo(imagecustomizerapi.DiskSize(20 * diskutils.GiB)),
+				},
+			},
+		},
+	}
+
+	definitions, err := generateRepartDefinitions(disk)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	expected := []repartDefinition{
+		{
+			fileName: "10-esp.conf",
+			content:  "[Partition]\nType=esp\nSizeMinBytes=8388608\nSizeMaxBytes=8388608\n",
+		},
+		{
+			fileName: "20-rootfs.conf",
+			content:  "[Partition]\nType=4f68bce3-e8cd-4db1-96e7-fbcaf984b709\nLabel=root\nGrowFileSystem=yes\nSizeMaxBytes=21474836480\n",
+		},
+	}
+	assert.Equal(t, expected, definitions)
+}
+
+func TestGenerateRepartDefinitionsUnpinnedPartition(t *testing.T) {
+	disk := imagecustomizerapi.Disk{
+		PartitionTableType: imagecustomizerapi.PartitionTableTypeGpt,
+		Partitions: []imagecustomizerapi.Partition{
+			{
+				Id:    "rootfs",
+				Start: ptrutils.PtrTo(imagecustomizerapi.DiskSize(1 * diskutils.MiB)),
+			},
+		},
+	}
+
+	_, err := generateRepartDefinitions(disk)
+	assert.ErrorContains(t, err, "partition (rootfs) must have a fixed size when systemd-repart is enabled")
+}
@@ -4,14 +4,19 @@
 package imagecustomizerlib
 
 import (
+	"encoding/base64"
 	"fmt"
+	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/microsoft/azurelinux/toolkit/tools/imagecustomizerapi"
 	"github.com/microsoft/azurelinux/toolkit/tools/imagegen/diskutils"
 	"github.com/microsoft/azurelinux/toolkit/tools/internal/file"
 	"github.com/microsoft/azurelinux/toolkit/tools/internal/logger"
 	"github.com/microsoft/azurelinux/toolkit/tools/internal/safechroot"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/shell"
+	"github.com/sirupsen/logrus"
 )
 
 func enableVerityPartition(verity []imagecustomizerapi.Verity, imageChroot *safechroot.Chroot,
@@ -106,8 +111,8 @@ func prepareGrubConfigForVerity(imageChroot *safechroot.Chroot) error {
 	return nil
 }
 
-func updateGrubConfigForVerity(rootfsVerity imagecustomizerapi.Verity, rootHash string, grubCfgFullPath string,
-	partIdToPartUuid map[string]string, partitions []diskutils.PartitionInfo,
+func updateGrubConfigForVerity(rootfsVerity imagecustomizerapi.Verity, rootHash string, rootHashSignature string,
+	grubCfgFullPath string, partIdToPartUuid map[string]string, partitions []diskutils.PartitionInfo,
 ) error {
 	var err error
 
@@ -123,7 +128,7 @@ func updateGrubConfigForVerity(rootfsVerity imagecustomizerapi.Verity, rootHash
 		return err
 	}
 
-	formattedCorruptionOption, err := systemdFormatCorruptionOption(rootfsVerity.CorruptionOption)
+	formattedVerityOptions, err := systemdFormatVerityOptions(rootfsVerity.CorruptionOption, rootHashSignature)
 	if err != nil {
 		return err
 	}
@@ -133,7 +138,12 @@ func updateGrubConfigForVerity(rootfsVerity imagecustomizerapi.Verity, rootHash
 		fmt.Sprintf("roothash=%s", rootHash),
 		fmt.Sprintf("systemd.verity_root_data=%s", formattedDataPartition),
 		fmt.Sprintf("systemd.verity_root_hash=%s", formattedHashPartition),
-		fmt.Sprintf("systemd.verity_root_options=%s", formattedCorruptionOption),
+		fmt.Sprintf("systemd.verity_root_options=%s", formattedVerityOptions),
+	}
+
+	if rootfsVerity.RootHashSignature != nil && rootfsVerity.RootHashSignature.RequireSignatures {
+		// Make the kernel refuse to open verity devices that don't have a valid root hash signature.
+		newArgs = append(newArgs, "dm_verity.require_signatures=1")
 	}
 
 	grub2Config, err := file.Read(grubCfgFullPath)
@@ -147,7 +157,8 @@ func updateGrubConfigForVerity(rootfsVerity imagecustomizerapi.Verity, rootHash
 	grubMkconfigEnabled := isGrubMkconfigConfig(grub2Config)
 
 	grub2Config, err = updateKernelCommandLineArgs(grub2Config, []string{"rd.systemd.verity", "roothash",
-		"systemd.verity_root_data", "systemd.verity_root_hash", "systemd.verity_root_options"}, newArgs)
+		"systemd.verity_root_data", "systemd.verity_root_hash", "systemd.verity_root_options",
+		"dm_verity.require_signatures"}, newArgs)
 	if err != nil {
 		return fmt.Errorf("failed to set verity kernel command line args:\n%w", err)
 	}
@@ -246,6 +257,65 @@ func systemdFormatCorruptionOption(corruptionOption imagecustomizerapi.Corruptio
 	}
 }
 
+// systemdFormatVerityOptions formats the value of the 'systemd.verity_root_options' kernel command-line arg.
+func systemdFormatVerityOptions(corruptionOption imagecustomizerapi.CorruptionOption, rootHashSignature string,
+) (string, error) {
+	formattedCorruptionOption, err := systemdFormatCorruptionOption(corruptionOption)
+	if err != nil {
+		return "", err
+	}
+
+	options := []string(nil)
+	if formattedCorruptionOption != "" {
+		options = append(options, formattedCorruptionOption)
+	}
+	if rootHashSignature != "" {
+		// The signature is passed inline, since the boot partition isn't available to the initramfs when the verity
+		// device is opened.
+		options = append(options, "root-hash-signature=base64:"+rootHashSignature)
+	}
+
+	return strings.Join(options, ","), nil
+}
+
+// signVerityRootHash creates a PKCS#7 signature of the root hash, in the format that the kernel verifies against the
+// certificates in its keyring (see CONFIG_DM_VERITY_VERIFY_ROOTHASH_SIG). Returns the signature, base64 encoded.
+func signVerityRootHash(buildDir string, baseConfigPath string,
+	rootHashSignature *imagecustomizerapi.VerityRootHashSignature, rootHash string,
+) (string, error) {
+	logger.Log.Infof("Signing verity root hash")
+
+	certificatePath := file.GetAbsPathWithBase(baseConfigPath, rootHashSignature.CertificatePath)
+	privateKeyPath := file.GetAbsPathWithBase(baseConfigPath, rootHashSignature.PrivateKeyPath)
+
+	rootHashFile := filepath.Join(buildDir, "verity-roothash")
+	signatureFile := filepath.Join(buildDir, "verity-roothash.p7s")
+	defer os.Remove(rootHashFile)
+	defer os.Remove(signatureFile)
+
+	// The kernel verifies the signature against the hex string of the root hash, without a trailing newline.
+	err := file.Write(rootHash, rootHashFile)
+	if err != nil {
+		return "", fmt.Errorf("failed to write verity root hash file:\n%w", err)
+	}
+
+	err = shell.NewExecBuilder("openssl", "smime", "-sign", "-nocerts", "-noattr", "-binary", "-in", rootHashFile,
+		"-signer", certificatePath, "-inkey", privateKeyPath, "-outform", "der", "-out", signatureFile).
+		LogLevel(logrus.DebugLevel, logrus.DebugLevel).
+		ErrorStderrLines(1).
+		Execute()
+	if err != nil {
+		return "", fmt.Errorf("failed to sign verity root hash:\n%w", err)
+	}
+
+	signature, err := os.ReadFile(signatureFile)
+	if err != nil {
+		return "", fmt.Errorf("failed to read verity root hash signature:\n%w", err)
+	}
+
+	return base64.StdEncoding.EncodeToString(signature), nil
+}
+
 func validateVerityDependencies(imageChroot *safechroot.Chroot) error {
 	requiredRpms := []string{"lvm2"}
 
@@ -4,7 +4,10 @@
 package imagecustomizerlib
 
 import (
+	"context"
+	"encoding/base64"
 	"fmt"
+	"os"
 	"path/filepath"
 	"regexp"
 	"testing"
@@ -38,8 +41,8 @@ func testCustomizeImageVerityHelper(t *testing.T, testName string, imageType bas
 	configFile := filepath.Join(testDir, "verity-config.yaml")
 
 	// Customize image.
-	err := CustomizeImageWithConfigFile(buildDir, configFile, baseImage, nil, outImageFilePath, "raw", "",
-		"" /*outputPXEArtifactsDir*/, true /*useBaseImageRpmRepos*/, false /*enableShrinkFilesystems*/)
+	err := CustomizeImageWithConfigFile(context.Background(), buildDir, configFile, baseImage, nil, outImageFilePath, "raw", "",
+		"" /*outputPXEArtifactsDir*/, true /*useBaseImageRpmRepos*/, false /*enableShrinkFilesystems*/, CustomizeImageOptions{})
 	if !assert.NoError(t, err) {
 		return
 	}
@@ -120,8 +123,8 @@ func testCustomizeImageVerityShrinkExtractHelper(t *testing.T, testName string,
 	config.Storage.FileSystems[hashPartitionNum-1].Type = "ext4"
 
 	// Customize image, shrink partitions, and split the partitions into individual files.
-	err = CustomizeImage(buildDir, testDir, &config, baseImage, nil, outImageFilePath, "", "raw",
-		"" /*outputPXEArtifactsDir*/, true /*useBaseImageRpmRepos*/, true /*enableShrinkFilesystems*/)
+	err = CustomizeImage(context.Background(), buildDir, testDir, &config, baseImage, nil, outImageFilePath, "", "raw",
+		"" /*outputPXEArtifactsDir*/, true /*useBaseImageRpmRepos*/, true /*enableShrinkFilesystems*/, CustomizeImageOptions{})
 	if !assert.NoError(t, err) {
 		return
 	}
@@ -191,3 +194,78 @@ func verifyVerity(t *testing.T, bootPath string, rootDevice string, hashDevice s
 	err = shell.ExecuteLive(false, "veritysetup", "verify", rootDevice, hashDevice, roothash)
 	assert.NoError(t, err)
 }
+
+func TestSystemdFormatVerityOptions(t *testing.T) {
+	options, err := systemdFormatVerityOptions(imagecustomizerapi.CorruptionOptionDefault, "")
+	assert.NoError(t, err)
+	assert.Equal(t, "", options)
+
+	options, err = systemdFormatVerityOptions(imagecustomizerapi.CorruptionOptionPanic, "")
+	assert.NoError(t, err)
+	assert.Equal(t, "panic-on-corruption", options)
+
+	options, err = systemdFormatVerityOptions(imagecustomizerapi.CorruptionOptionPanic, "MIIB")
+	assert.NoError(t, err)
+	assert.Equal(t, "panic-on-corruption,root-hash-signature=base64:MIIB", options)
+
+	options, err = systemdFormatVerityOptions(imagecustomizerapi.CorruptionOptionIoError, "MIIB")
+	assert.NoError(t, err)
+	assert.Equal(t, "root-hash-signature=base64:MIIB", options)
+}
+
+func TestSignVerityRootHash(t *testing.T) {
+	testTempDir := filepath.Join(tmpDir, "TestSignVerityRootHash")
+	defer os.RemoveAll(testTempDir)
+
+	err := os.MkdirAll(testTempDir, os.ModePerm)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// Create a signing certificate.
+	err = shell.ExecuteLive(true /*squashErrors*/, "openssl", "req", "-x509", "-newkey", "rsa:2048", "-nodes",
+		"-subj", "/CN=verity", "-days", "1", "-keyout", filepath.Join(testTempDir, "verity.key"),
+		"-out", filepath.Join(testTempDir, "verity.crt"))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	rootHashSignature := &imagecustomizerapi.VerityRootHashSignature{
+		CertificatePath: "verity.crt",
+		PrivateKeyPath:  "verity.key",
+	}
+	rootHash := "8f2f7ba2d5fa6e4d9b0b4d3e5c2a1f0e9d8c7b6a5f4e3d2c1b0a9f8e7d6c5b4a"
+
+	signature, err := signVerityRootHash(testTempDir, testTempDir, rootHashSignature, rootHash)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	signatureBytes, err := base64.StdEncoding.DecodeString(signature)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// Verify the signature against the root hash.
+	signatureFile := filepath.Join(testTempDir, "roothash.p7s")
+	rootHashFile := filepath.Join(testTempDir, "roothash")
+
+	err = os.WriteFile(signatureFile, signatureBytes, 0o644)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	err = file.Write(rootHash, rootHashFile)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	err = shell.ExecuteLive(false /*squashErrors*/, "openssl", "smime", "-verify", "-binary", "-inform", "der",
+		"-in", signatureFile, "-content", rootHashFile, "-certfile", filepath.Join(testTempDir, "verity.crt"),
+		"-noverify", "-out", "/dev/null")
+	assert.NoError(t, err)
+
+	// The temporary files must be cleaned up.
+	assert.NoFileExists(t, filepath.Join(testTempDir, "verity-roothash"))
+	assert.NoFileExists(t, filepath.Join(testTempDir, "verity-roothash.p7s"))
+}
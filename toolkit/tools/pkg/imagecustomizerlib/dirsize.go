@@ -0,0 +1,296 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+const (
+	// st_blocks is always expressed in 512-byte units, regardless of the file
+	// system block size.
+	statBlockSize = 512
+
+	// the number of entries to report when listing the largest directories.
+	maxSizeBreakdownEntries = 10
+
+	// the block size used when formatting ext file systems (see
+	// diskutils.DefaultMkfsOptions).
+	extBlockSize = 4096
+
+	// ext4 stores symbolic link targets shorter than this inside the inode
+	// itself, so they don't occupy any data blocks.
+	extInlineSymlinkMaxSize = 60
+)
+
+// dirSizeInfo holds the size information of a directory tree.
+type dirSizeInfo struct {
+	// apparentSize is the sum of the file sizes (as reported by 'du --apparent-size').
+	apparentSize uint64
+	// diskSize is the sum of the allocated blocks (as reported by 'du').
+	diskSize uint64
+	// extAlignedSize is the sum of the file sizes, each rounded up to a whole
+	// number of ext file system blocks. This approximates the data blocks
+	// needed to copy the tree to a new ext file system, independent of the
+	// file system the tree currently lives on.
+	extAlignedSize uint64
+	// entryCount is the number of unique inodes (files, directories, links,
+	// etc.) in the tree.
+	entryCount uint64
+	// topLevelDiskSizes holds the on-disk size of each top-level entry under
+	// the root directory (keyed by the entry name).
+	topLevelDiskSizes map[string]uint64
+}
+
+// dirSizeCounters holds the sizes accumulated while walking a directory tree.
+type dirSizeCounters struct {
+	apparentSize   uint64
+	diskSize       uint64
+	extAlignedSize uint64
+	entryCount     uint64
+}
+
+func (c *dirSizeCounters) add(other dirSizeCounters) {
+	c.apparentSize += other.apparentSize
+	c.diskSize += other.diskSize
+	c.extAlignedSize += other.extAlignedSize
+	c.entryCount += other.entryCount
+}
+
+type inodeKey struct {
+	dev uint64
+	ino uint64
+}
+
+// dirSizeWalker accumulates the sizes of files across concurrent walks while
+// making sure hard-linked files are only counted once.
+type dirSizeWalker struct {
+	mutex      sync.Mutex
+	seenInodes map[inodeKey]bool
+}
+
+// getDirSize
+//
+//   - given a folder, it walks its contents concurrently and calculates the
+//     apparent and on-disk sizes of the tree, along with a per top-level entry
+//     breakdown.
+//   - hard links are counted once (like 'du').
+//   - symbolic links are not followed.
+//
+// inputs:
+//
+//   - 'rootDir':
+//     root folder to calculate its size.
+//   - 'maxParallelWorkers':
+//     the maximum number of top-level entries walked concurrently.
+//
+// outputs:
+//
+//   - returns the size information.
+func getDirSize(rootDir string, maxParallelWorkers int) (dirSizeInfo, error) {
+	info := dirSizeInfo{
+		topLevelDiskSizes: make(map[string]uint64),
+	}
+
+	walker := &dirSizeWalker{
+		seenInodes: make(map[inodeKey]bool),
+	}
+
+	// Account for the root directory itself.
+	totals, err := walker.entrySize(rootDir, nil)
+	if err != nil {
+		return dirSizeInfo{}, err
+	}
+
+	entries, err := os.ReadDir(rootDir)
+	if err != nil {
+		return dirSizeInfo{}, fmt.Errorf("failed to read directory (%s):\n%w", rootDir, err)
+	}
+
+	type walkResult struct {
+		name     string
+		counters dirSizeCounters
+		err      error
+	}
+
+	results := make([]walkResult, len(entries))
+	jobSemaphore := make(chan struct{}, maxParallelWorkers)
+	wg := sync.WaitGroup{}
+
+	for i, entry := range entries {
+		wg.Add(1)
+		go func(i int, entry fs.DirEntry) {
+			defer wg.Done()
+			jobSemaphore <- struct{}{}
+			defer func() { <-jobSemaphore }()
+
+			counters, err := walker.treeSize(filepath.Join(rootDir, entry.Name()))
+			results[i] = walkResult{
+				name:     entry.Name(),
+				counters: counters,
+				err:      err,
+			}
+		}(i, entry)
+	}
+
+	wg.Wait()
+
+	errs := []error(nil)
+	for _, result := range results {
+		if result.err != nil {
+			errs = append(errs, result.err)
+			continue
+		}
+
+		totals.add(result.counters)
+		info.topLevelDiskSizes[result.name] = result.counters.diskSize
+	}
+
+	err = errors.Join(errs...)
+	if err != nil {
+		return dirSizeInfo{}, fmt.Errorf("failed to calculate the size of (%s):\n%w", rootDir, err)
+	}
+
+	info.apparentSize = totals.apparentSize
+	info.diskSize = totals.diskSize
+	info.extAlignedSize = totals.extAlignedSize
+	info.entryCount = totals.entryCount
+
+	return info, nil
+}
+
+// topLevelBreakdown
+//
+//   - returns a human readable list of the largest top-level entries, sorted
+//     by their on-disk size in descending order.
+//
+// inputs:
+//
+//   - 'maxEntries':
+//     the maximum number of entries to list.
+func (info *dirSizeInfo) topLevelBreakdown(maxEntries int) string {
+	names := make([]string, 0, len(info.topLevelDiskSizes))
+	for name := range info.topLevelDiskSizes {
+		names = append(names, name)
+	}
+
+	sort.Slice(names, func(i, j int) bool {
+		sizeI := info.topLevelDiskSizes[names[i]]
+		sizeJ := info.topLevelDiskSizes[names[j]]
+		if sizeI != sizeJ {
+			return sizeI > sizeJ
+		}
+		return names[i] < names[j]
+	})
+
+	if len(names) > maxEntries {
+		names = names[:maxEntries]
+	}
+
+	lines := []string(nil)
+	for _, name := range names {
+		lines = append(lines, fmt.Sprintf("  %s: %s", name, humanReadableDiskSize(int64(info.topLevelDiskSizes[name]))))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func (w *dirSizeWalker) treeSize(path string) (dirSizeCounters, error) {
+	counters := dirSizeCounters{}
+	err := filepath.WalkDir(path, func(entryPath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		entryCounters, err := w.entrySize(entryPath, d)
+		if err != nil {
+			return err
+		}
+
+		counters.add(entryCounters)
+		return nil
+	})
+	if err != nil {
+		return dirSizeCounters{}, err
+	}
+
+	return counters, nil
+}
+
+func (w *dirSizeWalker) entrySize(path string, d fs.DirEntry) (dirSizeCounters, error) {
+	var fileInfo fs.FileInfo
+	var err error
+	if d != nil {
+		fileInfo, err = d.Info()
+	} else {
+		fileInfo, err = os.Lstat(path)
+	}
+	if err != nil {
+		return dirSizeCounters{}, fmt.Errorf("failed to stat (%s):\n%w", path, err)
+	}
+
+	apparentSize := uint64(fileInfo.Size())
+	diskSize := apparentSize
+
+	stat, ok := fileInfo.Sys().(*syscall.Stat_t)
+	if ok {
+		if stat.Nlink > 1 && !fileInfo.IsDir() {
+			key := inodeKey{dev: uint64(stat.Dev), ino: stat.Ino}
+
+			w.mutex.Lock()
+			seen := w.seenInodes[key]
+			w.seenInodes[key] = true
+			w.mutex.Unlock()
+
+			if seen {
+				return dirSizeCounters{}, nil
+			}
+		}
+
+		apparentSize = uint64(stat.Size)
+		diskSize = uint64(stat.Blocks) * statBlockSize
+	}
+
+	return dirSizeCounters{
+		apparentSize:   apparentSize,
+		diskSize:       diskSize,
+		extAlignedSize: extAlignedEntrySize(fileInfo.Mode(), apparentSize),
+		entryCount:     1,
+	}, nil
+}
+
+// extAlignedEntrySize returns the number of bytes of data blocks an entry of
+// the specified type and size would occupy on an ext file system.
+func extAlignedEntrySize(mode fs.FileMode, size uint64) uint64 {
+	switch {
+	case mode.IsDir():
+		// Directories always occupy at least one block.
+		return max(alignUp(size, extBlockSize), extBlockSize)
+
+	case mode&fs.ModeSymlink != 0:
+		if size < extInlineSymlinkMaxSize {
+			return 0
+		}
+		return alignUp(size, extBlockSize)
+
+	case mode.IsRegular():
+		return alignUp(size, extBlockSize)
+
+	default:
+		// Device nodes, fifos and sockets don't have any data blocks.
+		return 0
+	}
+}
+
+func alignUp(value uint64, alignment uint64) uint64 {
+	return (value + alignment - 1) / alignment * alignment
+}
@@ -0,0 +1,98 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetDirSize(t *testing.T) {
+	testTempDir := filepath.Join(tmpDir, "TestGetDirSize")
+	defer os.RemoveAll(testTempDir)
+
+	err := os.MkdirAll(filepath.Join(testTempDir, "big"), os.ModePerm)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	err = os.MkdirAll(filepath.Join(testTempDir, "small"), os.ModePerm)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	bigFilePath := filepath.Join(testTempDir, "big/a.bin")
+	err = os.WriteFile(bigFilePath, []byte(strings.Repeat("a", 64*1024)), 0o644)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	err = os.WriteFile(filepath.Join(testTempDir, "small/b.txt"), []byte("b"), 0o644)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// Hard links must only be counted once.
+	err = os.Link(bigFilePath, filepath.Join(testTempDir, "small/a-link.bin"))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	err = os.Symlink("a.bin", filepath.Join(testTempDir, "big/a-symlink.bin"))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	info, err := getDirSize(testTempDir, 4)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.GreaterOrEqual(t, info.apparentSize, uint64(64*1024+1))
+	assert.Less(t, info.apparentSize, uint64(2*64*1024))
+	assert.Len(t, info.topLevelDiskSizes, 2)
+	assert.Contains(t, info.topLevelDiskSizes, "big")
+	assert.Contains(t, info.topLevelDiskSizes, "small")
+	assert.GreaterOrEqual(t, info.diskSize, info.topLevelDiskSizes["big"]+info.topLevelDiskSizes["small"])
+
+	// root, big, small, a.bin, a-symlink.bin, b.txt (the hard link is not counted).
+	assert.Equal(t, uint64(6), info.entryCount)
+	// 3 directories (1 block each), a.bin (16 blocks), b.txt (1 block), and an
+	// inline symlink.
+	assert.Equal(t, uint64((3+16+1)*extBlockSize), info.extAlignedSize)
+}
+
+func TestGetDirSizeMissingDir(t *testing.T) {
+	_, err := getDirSize(filepath.Join(tmpDir, "TestGetDirSizeMissingDir"), 4)
+	assert.ErrorContains(t, err, "failed to stat")
+}
+
+func TestDirSizeInfoTopLevelBreakdown(t *testing.T) {
+	info := dirSizeInfo{
+		topLevelDiskSizes: map[string]uint64{
+			"usr":  3 * 1024 * 1024,
+			"etc":  1024,
+			"var":  2 * 1024 * 1024,
+			"boot": 2 * 1024 * 1024,
+		},
+	}
+
+	breakdown := info.topLevelBreakdown(3)
+	assert.Equal(t, "  usr: 3 MiB\n  boot: 2 MiB\n  var: 2 MiB", breakdown)
+}
+
+func TestExtAlignedEntrySize(t *testing.T) {
+	assert.Equal(t, uint64(0), extAlignedEntrySize(0, 0))
+	assert.Equal(t, uint64(extBlockSize), extAlignedEntrySize(0, 1))
+	assert.Equal(t, uint64(2*extBlockSize), extAlignedEntrySize(0, extBlockSize+1))
+	assert.Equal(t, uint64(extBlockSize), extAlignedEntrySize(fs.ModeDir, 0))
+	assert.Equal(t, uint64(0), extAlignedEntrySize(fs.ModeSymlink, 20))
+	assert.Equal(t, uint64(extBlockSize), extAlignedEntrySize(fs.ModeSymlink, 100))
+	assert.Equal(t, uint64(0), extAlignedEntrySize(fs.ModeDevice, 0))
+}
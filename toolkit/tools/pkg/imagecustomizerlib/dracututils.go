@@ -8,6 +8,7 @@ import (
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
 
 	"github.com/microsoft/azurelinux/toolkit/tools/internal/file"
 	"github.com/microsoft/azurelinux/toolkit/tools/internal/safechroot"
@@ -18,8 +19,30 @@ const (
 	PxeDracutMinPackageRelease = 7
 	PxeDracutDistroName        = "azl"
 	PxeDracutMinDistroVersion  = 3
+
+	// The first dracut version whose livenet module downloads the boot image
+	// when systemd-networkd manages the network. Older versions rely on the
+	// Azure Linux download service instead.
+	pxeDracutNativeDownloadMinVersion = 103
+
+	// The script of dracut's livenet module that downloads the boot image.
+	pxeLivenetRootBinary = "livenetroot"
+	// The download service Azure Linux adds to the livenet module of older
+	// dracut versions (see 'rd.live.azldownloader').
+	pxeAzlDownloaderBinary = "azl-liveos-artifacts-download"
+	// The tool dracut's url-lib module downloads with. dracut leaves the
+	// livenet module out of the initrd image if it is not installed.
+	pxeDownloadBinary = "curl"
 )
 
+// pxeInitrdCapability is something the initrd image needs to PXE boot, along
+// with how to provide it.
+type pxeInitrdCapability struct {
+	binaryName  string
+	description string
+	remediation string
+}
+
 type DracutPackageInformation struct {
 	PackageVersion uint32 `yaml:"packageVersion"`
 	PackageRelease uint32 `yaml:"packageRelease"`
@@ -98,15 +121,18 @@ func getDracutVersion(rootfsSourceDir string) (dracutPackageInfo *DracutPackageI
 
 func verifyDracutPXESupport(packageInfo *DracutPackageInformation) error {
 	if packageInfo == nil {
-		return fmt.Errorf("no dracut package information provided")
+		return fmt.Errorf("no dracut package information provided:\n" +
+			"re-create the iso from a full disk image, so that the dracut version of the OS can be recorded")
 	}
 
 	if packageInfo.DistroName != PxeDracutDistroName {
-		return fmt.Errorf("did not find required Azure Linux distro (%s) - found (%s)", PxeDracutDistroName, packageInfo.DistroName)
+		return fmt.Errorf("did not find required Azure Linux distro (%s) - found (%s):\n%s", PxeDracutDistroName,
+			packageInfo.DistroName, pxeDracutRemediation())
 	}
 
 	if packageInfo.DistroVersion < PxeDracutMinDistroVersion {
-		return fmt.Errorf("did not find required Azure Linux distro version (%d) - found (%d)", PxeDracutMinDistroVersion, packageInfo.DistroVersion)
+		return fmt.Errorf("did not find required Azure Linux distro version (%d) - found (%d):\n%s",
+			PxeDracutMinDistroVersion, packageInfo.DistroVersion, pxeDracutRemediation())
 	}
 
 	// Note that, theoretically, an new distro version could still have an older package version.
@@ -114,15 +140,84 @@ func verifyDracutPXESupport(packageInfo *DracutPackageInformation) error {
 	// We need to check the package version number.
 
 	if packageInfo.PackageVersion < PxeDracutMinVersion {
-		return fmt.Errorf("did not find required Dracut package version (%d-%d) - found (%d-%d)",
-			PxeDracutMinVersion, PxeDracutMinPackageRelease, packageInfo.PackageVersion, packageInfo.PackageRelease)
+		return fmt.Errorf("did not find required Dracut package version (%d-%d) - found (%d-%d):\n%s",
+			PxeDracutMinVersion, PxeDracutMinPackageRelease, packageInfo.PackageVersion, packageInfo.PackageRelease,
+			pxeDracutRemediation())
 	} else if packageInfo.PackageVersion > PxeDracutMinVersion {
 		return nil
 	}
 
 	if packageInfo.PackageRelease < PxeDracutMinPackageRelease {
-		return fmt.Errorf("did not find required Dracut package release (%d-%d) - found (%d-%d)",
-			PxeDracutMinVersion, PxeDracutMinPackageRelease, packageInfo.PackageVersion, packageInfo.PackageRelease)
+		return fmt.Errorf("did not find required Dracut package release (%d-%d) - found (%d-%d):\n%s",
+			PxeDracutMinVersion, PxeDracutMinPackageRelease, packageInfo.PackageVersion, packageInfo.PackageRelease,
+			pxeDracutRemediation())
+	}
+	return nil
+}
+
+func pxeDracutRemediation() string {
+	return fmt.Sprintf("update the dracut package of the image to (%d-%d.%s%d) or later", PxeDracutMinVersion,
+		PxeDracutMinPackageRelease, PxeDracutDistroName, PxeDracutMinDistroVersion)
+}
+
+// verifyInitrdPXESupport
+//
+//   - checks that the initrd image holds what it needs to download the boot
+//     image (i.e. the iso or the rootfs image) when PXE booting. dracut skips
+//     the modules whose dependencies are missing with only a warning, so the
+//     dracut version alone is not enough.
+//   - reports each missing capability along with how to provide it.
+//
+// inputs:
+//
+//   - 'initrdImagePath':
+//     path to the (gzip compressed cpio) initrd image.
+//   - 'packageInfo':
+//     the dracut package that generated the initrd image.
+func verifyInitrdPXESupport(initrdImagePath string, packageInfo *DracutPackageInformation) error {
+	capabilities := []pxeInitrdCapability{
+		{
+			binaryName:  pxeLivenetRootBinary,
+			description: "the dracut livenet module",
+			remediation: "make sure the dracut livenet module is installed in the image",
+		},
+		{
+			binaryName:  pxeDownloadBinary,
+			description: "the download tool of the dracut livenet module",
+			remediation: "install the curl package in the image",
+		},
+	}
+
+	if packageInfo != nil && packageInfo.PackageVersion < pxeDracutNativeDownloadMinVersion {
+		capabilities = append(capabilities, pxeInitrdCapability{
+			binaryName:  pxeAzlDownloaderBinary,
+			description: "the Azure Linux livenet download service",
+			remediation: pxeDracutRemediation(),
+		})
 	}
+
+	binaryNames := []string(nil)
+	for _, capability := range capabilities {
+		binaryNames = append(binaryNames, capability.binaryName)
+	}
+
+	foundBinaries, err := initrdFindBinaries(initrdImagePath, binaryNames)
+	if err != nil {
+		return fmt.Errorf("failed to search initrd image (%s) for the PXE boot tools:\n%w", initrdImagePath, err)
+	}
+
+	missingCapabilities := []string(nil)
+	for _, capability := range capabilities {
+		if !foundBinaries[capability.binaryName] {
+			missingCapabilities = append(missingCapabilities, fmt.Sprintf("- %s (%s) is missing: %s",
+				capability.description, capability.binaryName, capability.remediation))
+		}
+	}
+
+	if len(missingCapabilities) > 0 {
+		return fmt.Errorf("the initrd image (%s) cannot download the boot image when PXE booting:\n%s",
+			initrdImagePath, strings.Join(missingCapabilities, "\n"))
+	}
+
 	return nil
 }
@@ -0,0 +1,70 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerifyDracutPXESupportRemediation(t *testing.T) {
+	err := verifyDracutPXESupport(&DracutPackageInformation{
+		PackageVersion: 102,
+		PackageRelease: 6,
+		DistroName:     "azl",
+		DistroVersion:  3,
+	})
+	assert.ErrorContains(t, err, "did not find required Dracut package release (102-7) - found (102-6)")
+	assert.ErrorContains(t, err, "update the dracut package of the image to (102-7.azl3) or later")
+}
+
+func TestVerifyInitrdPXESupport(t *testing.T) {
+	testTmpDir := filepath.Join(tmpDir, "TestVerifyInitrdPXESupport")
+	defer os.RemoveAll(testTmpDir)
+
+	err := os.MkdirAll(testTmpDir, os.ModePerm)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	dracut102 := &DracutPackageInformation{PackageVersion: 102, PackageRelease: 7, DistroName: "azl", DistroVersion: 3}
+	dracut103 := &DracutPackageInformation{PackageVersion: 103, PackageRelease: 1, DistroName: "azl", DistroVersion: 3}
+
+	initrdPath := filepath.Join(testTmpDir, "initrd.img")
+	err = createTestInitrd(initrdPath, []string{"usr/sbin/livenetroot", "usr/bin/curl",
+		"usr/sbin/azl-liveos-artifacts-download"})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	err = verifyInitrdPXESupport(initrdPath, dracut102)
+	assert.NoError(t, err)
+
+	// Newer dracut versions download the boot image without the Azure Linux
+	// download service.
+	err = createTestInitrd(initrdPath, []string{"usr/sbin/livenetroot", "usr/bin/curl"})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	err = verifyInitrdPXESupport(initrdPath, dracut103)
+	assert.NoError(t, err)
+
+	err = verifyInitrdPXESupport(initrdPath, dracut102)
+	assert.ErrorContains(t, err, "the Azure Linux livenet download service (azl-liveos-artifacts-download) is missing")
+	assert.NotContains(t, err.Error(), "(curl) is missing")
+
+	err = createTestInitrd(initrdPath, []string{"usr/sbin/livenetroot", "usr/share/doc/curl"})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	err = verifyInitrdPXESupport(initrdPath, dracut103)
+	assert.ErrorContains(t, err, "cannot download the boot image when PXE booting")
+	assert.ErrorContains(t, err, "(curl) is missing: install the curl package in the image")
+	assert.NotContains(t, err.Error(), "(livenetroot) is missing")
+}
@@ -95,6 +95,168 @@ func removeCommandAll(inputGrubCfgContent string, command string) (outputGrubCfg
 	return outputGrubCfgContent, nil
 }
 
+// A 'menuentry' block within a grub config file.
+type grubMenuEntry struct {
+	// The index of the start of the 'menuentry' keyword.
+	Start int
+	// The index right after the '{' that opens the block.
+	BodyStart int
+	// The index right after the '}' that closes the block.
+	End int
+	// The title of the menu entry.
+	Title string
+	// The value of the '--id' option, if present.
+	Id string
+	// The indexes of the start and the end of the '--id' option's value, if present.
+	IdStart int
+	IdEnd   int
+}
+
+// Finds all the top-level 'menuentry' blocks within a grub config file.
+func findMenuEntries(inputGrubCfgContent string) ([]grubMenuEntry, error) {
+	grubTokens, err := grub.TokenizeConfig(inputGrubCfgContent)
+	if err != nil {
+		return nil, err
+	}
+
+	menuEntries := []grubMenuEntry(nil)
+	lineStart := true
+	depth := 0
+	inHeader := false
+	entry := grubMenuEntry{}
+
+	for i, token := range grubTokens {
+		switch {
+		case depth == 0 && !inHeader && lineStart && grub.IsTokenKeyword(token, "menuentry"):
+			inHeader = true
+			entry = grubMenuEntry{Start: token.Loc.Start.Index}
+
+		case inHeader && token.Type == grub.WORD && token.RawContent == "--id" && i+1 < len(grubTokens):
+			entry.Id = grubTokenValue(grubTokens[i+1])
+			entry.IdStart = grubTokens[i+1].Loc.Start.Index
+			entry.IdEnd = grubTokens[i+1].Loc.End.Index
+
+		case inHeader && entry.Title == "" && token.Type == grub.WORD && !strings.HasPrefix(token.RawContent, "-") &&
+			grubTokens[i-1].RawContent != "--id":
+			entry.Title = grubTokenValue(token)
+
+		case token.Type == grub.LBRACE:
+			if inHeader && depth == 0 {
+				entry.BodyStart = token.Loc.End.Index
+				inHeader = false
+			}
+			depth++
+
+		case token.Type == grub.RBRACE:
+			depth--
+			if depth < 0 {
+				return nil, fmt.Errorf("unbalanced '}' in grub config")
+			}
+			if depth == 0 && entry.BodyStart != 0 {
+				entry.End = token.Loc.End.Index
+				menuEntries = append(menuEntries, entry)
+				entry = grubMenuEntry{}
+			}
+		}
+
+		lineStart = token.Type == grub.NEWLINE || token.Type == grub.SEMICOLON
+	}
+
+	if depth != 0 || inHeader {
+		return nil, fmt.Errorf("unterminated 'menuentry' block in grub config")
+	}
+
+	return menuEntries, nil
+}
+
+func grubTokenValue(token grub.Token) string {
+	builder := strings.Builder{}
+	for _, subWord := range token.SubWords {
+		builder.WriteString(subWord.Value)
+	}
+	return builder.String()
+}
+
+// Removes all the 'menuentry' blocks whose id starts with the provided prefix.
+func removeMenuEntriesWithIdPrefix(inputGrubCfgContent string, idPrefix string) (outputGrubCfgContent string, err error) {
+	menuEntries, err := findMenuEntries(inputGrubCfgContent)
+	if err != nil {
+		return "", err
+	}
+
+	outputGrubCfgContent = inputGrubCfgContent
+	// loop from last to first so that the captured locations are not
+	// invalidated as reconstructing outputGrubCfgContent.
+	for i := len(menuEntries) - 1; i >= 0; i-- {
+		menuEntry := menuEntries[i]
+		if !strings.HasPrefix(menuEntry.Id, idPrefix) {
+			continue
+		}
+
+		end := menuEntry.End
+		if end < len(outputGrubCfgContent) && outputGrubCfgContent[end] == '\n' {
+			end++
+		}
+		outputGrubCfgContent = outputGrubCfgContent[:menuEntry.Start] + outputGrubCfgContent[end:]
+	}
+
+	return outputGrubCfgContent, nil
+}
+
+// Sets the id of the first 'menuentry' block of a grub config file, replacing its existing id (if any).
+func setFirstMenuEntryId(inputGrubCfgContent string, id string) (outputGrubCfgContent string, err error) {
+	menuEntries, err := findMenuEntries(inputGrubCfgContent)
+	if err != nil {
+		return "", err
+	}
+	if len(menuEntries) < 1 {
+		return "", fmt.Errorf("failed to find a 'menuentry' block in grub config")
+	}
+
+	menuEntry := menuEntries[0]
+	if menuEntry.Id != "" {
+		outputGrubCfgContent = inputGrubCfgContent[:menuEntry.IdStart] + grub.QuoteString(id) +
+			inputGrubCfgContent[menuEntry.IdEnd:]
+		return outputGrubCfgContent, nil
+	}
+
+	// Insert the '--id' option right before the '{' that opens the block.
+	braceIndex := menuEntry.BodyStart - 1
+	outputGrubCfgContent = inputGrubCfgContent[:braceIndex] + "--id " + grub.QuoteString(id) + " " +
+		inputGrubCfgContent[braceIndex:]
+	return outputGrubCfgContent, nil
+}
+
+// Creates a copy of the first 'menuentry' block of a grub config file with a new title and id, and with the extra
+// kernel command-line args appended to its linux command. The new block is appended to the end of the grub config.
+func appendMenuEntryCopy(inputGrubCfgContent string, title string, id string, extraCommandLine string,
+) (outputGrubCfgContent string, err error) {
+	menuEntries, err := findMenuEntries(inputGrubCfgContent)
+	if err != nil {
+		return "", err
+	}
+	if len(menuEntries) < 1 {
+		return "", fmt.Errorf("failed to find a 'menuentry' block in grub config")
+	}
+
+	template := menuEntries[0]
+	menuEntry := fmt.Sprintf("menuentry %s --id %s {", grub.QuoteString(title), grub.QuoteString(id)) +
+		inputGrubCfgContent[template.BodyStart:template.End]
+
+	menuEntry, err = appendKernelCommandLineArgsAll(menuEntry, extraCommandLine, true /*allowMultiple*/, false /*requireKernelOpts*/)
+	if err != nil {
+		return "", fmt.Errorf("failed to append kernel args to menuentry (%s):\n%w", title, err)
+	}
+
+	outputGrubCfgContent = inputGrubCfgContent
+	if !strings.HasSuffix(outputGrubCfgContent, "\n") {
+		outputGrubCfgContent += "\n"
+	}
+	outputGrubCfgContent += menuEntry + "\n"
+
+	return outputGrubCfgContent, nil
+}
+
 func replaceToken(inputGrubCfgContent string, oldToken string, newToken string) (outputGrubCfgContent string, err error) {
 
 	// escape special characters that would interfer with defining the regular
@@ -799,7 +961,7 @@ func getGrub2ConfigFilePath(imageChroot safechroot.ChrootInterface) string {
 }
 
 // Regenerates the initramfs file.
-func regenerateInitrd(imageChroot *safechroot.Chroot) error {
+func regenerateInitrd(imageChroot *safechroot.Chroot, buildResources BuildResources) error {
 	logger.Log.Infof("Regenerate initramfs file")
 
 	err := imageChroot.UnsafeRun(func() error {
@@ -809,11 +971,15 @@ func regenerateInitrd(imageChroot *safechroot.Chroot) error {
 			return fmt.Errorf("failed to search for mkinitrd command:\n%w", err)
 		}
 
+		var program string
+		var args []string
 		if mkinitrdExists {
-			return shell.ExecuteLiveWithErr(1, "mkinitrd")
+			program, args = resourceLimitedCommand(buildResources, "mkinitrd")
 		} else {
-			return shell.ExecuteLiveWithErr(1, "dracut", "--force", "--regenerate-all")
+			program, args = resourceLimitedCommand(buildResources, "dracut", "--force", "--regenerate-all")
 		}
+
+		return shell.ExecuteLiveWithErr(1, program, args...)
 	})
 	if err != nil {
 		return fmt.Errorf("failed to rebuild initramfs file:\n%w", err)
@@ -0,0 +1,116 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testMenuEntriesGrubCfg = `set timeout=0
+menuentry "Azure Linux" {
+	search --label CDROM --set root
+	linux /boot/vmlinuz root=live:LABEL=CDROM rd.live.squashimg=rootfs.img
+	initrd /boot/initrd.img
+}
+menuentry "Old Recovery" --id azl-liveos-old.img {
+	linux /boot/vmlinuz root=live:LABEL=CDROM rd.live.squashimg=old.img
+	initrd /boot/initrd.img
+}
+`
+
+func TestFindMenuEntries(t *testing.T) {
+	menuEntries, err := findMenuEntries(testMenuEntriesGrubCfg)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	if !assert.Len(t, menuEntries, 2) {
+		return
+	}
+
+	assert.Equal(t, "", menuEntries[0].Id)
+	assert.Equal(t, "azl-liveos-old.img", menuEntries[1].Id)
+	assert.Equal(t, "menuentry \"Azure Linux\" {",
+		testMenuEntriesGrubCfg[menuEntries[0].Start:menuEntries[0].BodyStart])
+	assert.Equal(t, "}", testMenuEntriesGrubCfg[menuEntries[1].End-1:menuEntries[1].End])
+}
+
+func TestFindMenuEntriesUnterminated(t *testing.T) {
+	_, err := findMenuEntries("menuentry \"Azure Linux\" {\n\tlinux /boot/vmlinuz\n")
+	assert.ErrorContains(t, err, "unterminated 'menuentry' block")
+}
+
+func TestRemoveMenuEntriesWithIdPrefix(t *testing.T) {
+	grubCfg, err := removeMenuEntriesWithIdPrefix(testMenuEntriesGrubCfg, "azl-liveos-")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	expected := `set timeout=0
+menuentry "Azure Linux" {
+	search --label CDROM --set root
+	linux /boot/vmlinuz root=live:LABEL=CDROM rd.live.squashimg=rootfs.img
+	initrd /boot/initrd.img
+}
+`
+	assert.Equal(t, expected, grubCfg)
+}
+
+func TestAppendMenuEntryCopy(t *testing.T) {
+	grubCfg, err := removeMenuEntriesWithIdPrefix(testMenuEntriesGrubCfg, "azl-liveos-")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	grubCfg, err = appendMenuEntryCopy(grubCfg, "Recovery Mode", "azl-liveos-recovery.img",
+		"rd.live.squashimg=recovery.img console=ttyS0")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	expected := `set timeout=0
+menuentry "Azure Linux" {
+	search --label CDROM --set root
+	linux /boot/vmlinuz root=live:LABEL=CDROM rd.live.squashimg=rootfs.img
+	initrd /boot/initrd.img
+}
+menuentry "Recovery Mode" --id azl-liveos-recovery.img {
+	search --label CDROM --set root
+	linux /boot/vmlinuz root=live:LABEL=CDROM rd.live.squashimg=rootfs.img rd.live.squashimg=recovery.img console=ttyS0 
+	initrd /boot/initrd.img
+}
+`
+	assert.Equal(t, expected, grubCfg)
+}
+
+func TestAppendMenuEntryCopyNoMenuEntry(t *testing.T) {
+	_, err := appendMenuEntryCopy("set timeout=0\n", "Recovery", "azl-liveos-recovery.img", "")
+	assert.ErrorContains(t, err, "failed to find a 'menuentry' block")
+}
+
+func TestSetFirstMenuEntryId(t *testing.T) {
+	grubCfg, err := setFirstMenuEntryId(testMenuEntriesGrubCfg, "azl-slot-a")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	menuEntries, err := findMenuEntries(grubCfg)
+	if !assert.NoError(t, err) || !assert.Len(t, menuEntries, 2) {
+		return
+	}
+
+	assert.Equal(t, "azl-slot-a", menuEntries[0].Id)
+	assert.Equal(t, "azl-liveos-old.img", menuEntries[1].Id)
+	assert.Equal(t, "menuentry \"Azure Linux\" --id azl-slot-a {", grubCfg[menuEntries[0].Start:menuEntries[0].BodyStart])
+
+	// Replace the existing id.
+	grubCfg, err = setFirstMenuEntryId(grubCfg, "azl-slot-a2")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Contains(t, grubCfg, "menuentry \"Azure Linux\" --id azl-slot-a2 {\n")
+}
@@ -0,0 +1,148 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"bytes"
+	"debug/elf"
+	"debug/pe"
+	"encoding/binary"
+	"fmt"
+)
+
+const (
+	// The PE section of a grub EFI image that holds the embedded modules.
+	grubEfiModsSectionName = "mods"
+	// grub's GRUB_MODULE_MAGIC ("mimg").
+	grubModuleMagic = 0x676d696d
+
+	// grub's OBJ_TYPE_* values of the embedded objects.
+	grubObjTypeElf    = 0
+	grubObjTypePrefix = 3
+
+	// The section of a grub module (ELF object) that holds its name.
+	grubModNameSectionName = ".modname"
+)
+
+// grubEfiImageInfo is what grub-mkimage embedded in a grub EFI image.
+type grubEfiImageInfo struct {
+	modules []string
+	// The prefix (i.e. where grub looks for its configuration and its
+	// modules). Empty if the image has no prefix.
+	prefix string
+}
+
+func (i *grubEfiImageInfo) hasModule(name string) bool {
+	for _, module := range i.modules {
+		if module == name {
+			return true
+		}
+	}
+	return false
+}
+
+// readGrubEfiImageInfo returns the modules and the prefix embedded in a
+// (64-bit) grub EFI image.
+func readGrubEfiImageInfo(grubEfiImagePath string) (*grubEfiImageInfo, error) {
+	peFile, err := pe.Open(grubEfiImagePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open grub EFI image (%s):\n%w", grubEfiImagePath, err)
+	}
+	defer peFile.Close()
+
+	modsSection := peFile.Section(grubEfiModsSectionName)
+	if modsSection == nil {
+		return nil, fmt.Errorf("grub EFI image (%s) has no (%s) section", grubEfiImagePath, grubEfiModsSectionName)
+	}
+
+	modsData, err := modsSection.Data()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the (%s) section of grub EFI image (%s):\n%w", grubEfiModsSectionName,
+			grubEfiImagePath, err)
+	}
+
+	info, err := parseGrubModsSection(modsData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the (%s) section of grub EFI image (%s):\n%w", grubEfiModsSectionName,
+			grubEfiImagePath, err)
+	}
+
+	return info, nil
+}
+
+// parseGrubModsSection parses the objects grub-mkimage embeds in a 64-bit
+// grub image: a 'grub_module_info64' header followed by the objects, each with
+// a 'grub_module_header'.
+func parseGrubModsSection(data []byte) (*grubEfiImageInfo, error) {
+	const (
+		moduleInfoSize   = 24
+		moduleHeaderSize = 8
+	)
+
+	if len(data) < moduleInfoSize {
+		return nil, fmt.Errorf("section is too small (%d bytes)", len(data))
+	}
+
+	magic := binary.LittleEndian.Uint32(data[0:4])
+	if magic != grubModuleMagic {
+		return nil, fmt.Errorf("invalid module info magic (0x%x)", magic)
+	}
+
+	offset := binary.LittleEndian.Uint64(data[8:16])
+	size := binary.LittleEndian.Uint64(data[16:24])
+	if size > uint64(len(data)) || offset > size {
+		return nil, fmt.Errorf("invalid module info (offset=%d, size=%d, section size=%d)", offset, size, len(data))
+	}
+
+	info := &grubEfiImageInfo{}
+	for pos := offset; pos < size; {
+		if size-pos < moduleHeaderSize {
+			return nil, fmt.Errorf("truncated object header at offset (%d)", pos)
+		}
+
+		objType := binary.LittleEndian.Uint32(data[pos : pos+4])
+		objSize := uint64(binary.LittleEndian.Uint32(data[pos+4 : pos+8]))
+		if objSize < moduleHeaderSize || objSize > size-pos {
+			return nil, fmt.Errorf("invalid object size (%d) at offset (%d)", objSize, pos)
+		}
+
+		objData := data[pos+moduleHeaderSize : pos+objSize]
+
+		switch objType {
+		case grubObjTypeElf:
+			moduleName, err := getGrubModuleName(objData)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read the name of the module at offset (%d):\n%w", pos, err)
+			}
+			info.modules = append(info.modules, moduleName)
+
+		case grubObjTypePrefix:
+			info.prefix = string(bytes.TrimRight(objData, "\x00"))
+		}
+
+		pos += objSize
+	}
+
+	return info, nil
+}
+
+func getGrubModuleName(moduleData []byte) (string, error) {
+	elfFile, err := elf.NewFile(bytes.NewReader(moduleData))
+	if err != nil {
+		return "", err
+	}
+	defer elfFile.Close()
+
+	modNameSection := elfFile.Section(grubModNameSectionName)
+	if modNameSection == nil {
+		return "", fmt.Errorf("module has no (%s) section", grubModNameSectionName)
+	}
+
+	modName, err := modNameSection.Data()
+	if err != nil {
+		return "", err
+	}
+
+	return string(bytes.TrimRight(modName, "\x00")), nil
+}
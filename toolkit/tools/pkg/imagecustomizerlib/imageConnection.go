@@ -33,6 +33,19 @@ func (c *ImageConnection) ConnectLoopback(diskFilePath string) error {
 	return nil
 }
 
+func (c *ImageConnection) ConnectReadOnlyLoopback(diskFilePath string) error {
+	if c.loopback != nil {
+		return fmt.Errorf("loopback already connected")
+	}
+
+	loopback, err := safeloopback.NewReadOnlyLoopback(diskFilePath)
+	if err != nil {
+		return fmt.Errorf("failed to mount raw disk (%s) as a read-only loopback device:\n%w", diskFilePath, err)
+	}
+	c.loopback = loopback
+	return nil
+}
+
 func (c *ImageConnection) ConnectChroot(rootDir string, isExistingDir bool, extraDirectories []string,
 	extraMountPoints []*safechroot.MountPoint, includeDefaultMounts bool,
 ) error {
@@ -4,6 +4,7 @@
 package imagecustomizerlib
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
@@ -18,6 +19,7 @@ import (
 	"github.com/microsoft/azurelinux/toolkit/tools/internal/safeloopback"
 	"github.com/microsoft/azurelinux/toolkit/tools/internal/safemount"
 	"github.com/microsoft/azurelinux/toolkit/tools/internal/shell"
+	"github.com/sirupsen/logrus"
 	"golang.org/x/sys/unix"
 )
 
@@ -31,6 +33,8 @@ const (
 	ImageFormatQCow2    = "qcow2"
 	ImageFormatIso      = "iso"
 	ImageFormatRaw      = "raw"
+	ImageFormatOci      = "oci"
+	ImageFormatWsl      = "wsl"
 
 	// qemu-specific formats
 	QemuFormatVpc = "vpc"
@@ -58,6 +62,9 @@ type ImageCustomizerParameters struct {
 	inputImageFormat string
 	inputIsIso       bool
 
+	// whether a LiveOS iso input is converted into a bootable disk image
+	convertIsoToDisk bool
+
 	// configurations
 	configPath                  string
 	config                      *imagecustomizerapi.Config
@@ -66,6 +73,8 @@ type ImageCustomizerParameters struct {
 	rpmsSources                 []string
 	enableShrinkFilesystems     bool
 	outputSplitPartitionsFormat string
+	buildResources              BuildResources
+	resumeMode                  ResumeMode
 
 	// intermediate writeable image
 	rawImageFile string
@@ -77,13 +86,19 @@ type ImageCustomizerParameters struct {
 	outputImageDir        string
 	outputImageBase       string
 	outputPXEArtifactsDir string
+
+	// build report sections
+	azureComputeGalleryReport *azureComputeGalleryReport
+	vulnerabilityScanReport   *vulnerabilityScanReport
+	isoCarryOverReport        *isoCarryOverReport
 }
 
 func createImageCustomizerParameters(buildDir string,
 	inputImageFile string,
 	configPath string, config *imagecustomizerapi.Config,
 	useBaseImageRpmRepos bool, rpmsSources []string, enableShrinkFilesystems bool, outputSplitPartitionsFormat string,
-	outputImageFormat string, outputImageFile string, outputPXEArtifactsDir string) (*ImageCustomizerParameters, error) {
+	outputImageFormat string, outputImageFile string, outputPXEArtifactsDir string, options CustomizeImageOptions,
+) (*ImageCustomizerParameters, error) {
 
 	ic := &ImageCustomizerParameters{}
 
@@ -107,7 +122,12 @@ func createImageCustomizerParameters(buildDir string,
 	ic.config = config
 	ic.customizeOSPartitions = config.CustomizePartitions() || config.OS != nil ||
 		len(config.Scripts.PostCustomization) > 0 ||
-		len(config.Scripts.FinalizeCustomization) > 0
+		len(config.Scripts.FinalizeCustomization) > 0 ||
+		// The bootloader files are read from the OS. So, the OS of an input iso must be expanded.
+		(config.Iso != nil && len(config.Iso.BootloaderFiles) > 0) ||
+		// The PXE download settings are built into the initrd image. So, the initrd image of an input iso must be
+		// re-generated.
+		(config.Pxe != nil && config.Pxe.Download != nil)
 
 	ic.useBaseImageRpmRepos = useBaseImageRpmRepos
 	ic.rpmsSources = rpmsSources
@@ -119,6 +139,21 @@ func createImageCustomizerParameters(buildDir string,
 	if err != nil {
 		return nil, err
 	}
+
+	err = options.BuildResources.IsValid()
+	if err != nil {
+		return nil, fmt.Errorf("invalid build resources:\n%w", err)
+	}
+
+	ic.buildResources = options.BuildResources
+
+	err = options.ResumeMode.IsValid()
+	if err != nil {
+		return nil, err
+	}
+
+	ic.resumeMode = options.ResumeMode
+
 	// intermediate writeable image
 	ic.rawImageFile = filepath.Join(buildDirAbs, BaseImageName)
 
@@ -137,10 +172,58 @@ func createImageCustomizerParameters(buildDir string,
 		}
 	}
 
+	if config.Oci != nil && ic.outputImageFormat != ImageFormatOci {
+		return nil, fmt.Errorf("'oci' can be specified only if the output format ('--output-image-format') is an oci image")
+	}
+
+	if config.AzureComputeGallery != nil && ic.outputImageFormat != ImageFormatVhdFixed {
+		return nil, fmt.Errorf("'azureComputeGallery' can be specified only if the output format ('--output-image-format') is a fixed size vhd (%s)",
+			ImageFormatVhdFixed)
+	}
+
+	if config.Wsl != nil && ic.outputImageFormat != ImageFormatWsl {
+		return nil, fmt.Errorf("'wsl' can be specified only if the output format ('--output-image-format') is a wsl distribution")
+	}
+
+	if config.PcrPolicy != nil &&
+		(ic.outputIsIso || ic.outputImageFormat == ImageFormatOci || ic.outputImageFormat == ImageFormatWsl) {
+		return nil, fmt.Errorf("'pcrPolicy' can't be specified if the output format ('--output-image-format') is an iso image, an oci image or a wsl distribution")
+	}
+
 	if ic.outputPXEArtifactsDir != "" && !ic.outputIsIso {
 		return nil, fmt.Errorf("the output PXE artifacts directory ('--output-pxe-artifacts-dir') can be specified only if the output format is an iso image.")
 	}
 
+	if config.Pxe != nil && config.Pxe.ArtifactsArchive != imagecustomizerapi.PxeArtifactsArchiveFormatNone &&
+		ic.outputPXEArtifactsDir == "" {
+		return nil, fmt.Errorf("'pxe.artifactsArchive' requires the output PXE artifacts directory ('--output-pxe-artifacts-dir') to be specified")
+	}
+
+	if config.Pxe != nil && config.Pxe.IpxeScript != nil && ic.outputPXEArtifactsDir == "" {
+		return nil, fmt.Errorf("'pxe.ipxeScript' requires the output PXE artifacts directory ('--output-pxe-artifacts-dir') to be specified")
+	}
+
+	if config.Pxe != nil && config.Pxe.PxelinuxConfig && ic.outputPXEArtifactsDir == "" {
+		return nil, fmt.Errorf("'pxe.pxelinuxConfig' requires the output PXE artifacts directory ('--output-pxe-artifacts-dir') to be specified")
+	}
+
+	if config.Pxe != nil && config.Pxe.HttpBoot && ic.outputPXEArtifactsDir == "" {
+		return nil, fmt.Errorf("'pxe.httpBoot' requires the output PXE artifacts directory ('--output-pxe-artifacts-dir') to be specified")
+	}
+
+	if config.Pxe != nil && config.Pxe.ArtifactsLayout != imagecustomizerapi.PxeArtifactsLayoutDefault &&
+		ic.outputPXEArtifactsDir == "" {
+		return nil, fmt.Errorf("'pxe.artifactsLayout' requires the output PXE artifacts directory ('--output-pxe-artifacts-dir') to be specified")
+	}
+
+	if config.Pxe != nil && len(config.Pxe.HostOverrides) > 0 && ic.outputPXEArtifactsDir == "" {
+		return nil, fmt.Errorf("'pxe.hostOverrides' requires the output PXE artifacts directory ('--output-pxe-artifacts-dir') to be specified")
+	}
+
+	if config.Pxe != nil && len(config.Pxe.AnswerFiles) > 0 && ic.outputPXEArtifactsDir == "" {
+		return nil, fmt.Errorf("'pxe.answerFiles' requires the output PXE artifacts directory ('--output-pxe-artifacts-dir') to be specified")
+	}
+
 	if ic.inputIsIso {
 		// When the input is an iso image, there's only one file system: the
 		// suqash file system and it has no empty space since it's a read-only
@@ -156,34 +239,50 @@ func createImageCustomizerParameters(buildDir string,
 			return nil, fmt.Errorf("extracting partitions is not supported when the input image is an iso image")
 		}
 
-		// While re-creating a disk image from the iso is technically possible,
-		// we are choosing to not implement it until there is a need.
+		// When generating a disk image from an iso, the squashfs contents are
+		// always expanded into a writeable image, which is then made bootable
+		// as a disk.
 		if !ic.outputIsIso {
-			return nil, fmt.Errorf("generating a non-iso image from an iso image is not supported")
+			// A LiveOS iso's grub configuration boots from the iso media. So,
+			// the bootloader must be re-installed for disk boot.
+			if config.OS == nil || config.OS.ResetBootLoaderType != imagecustomizerapi.ResetBootLoaderTypeHard {
+				return nil, fmt.Errorf("'os.resetBootLoaderType' must be set to (%s) when generating a non-iso image from "+
+					"an iso image", imagecustomizerapi.ResetBootLoaderTypeHard)
+			}
+
+			ic.convertIsoToDisk = true
+			ic.customizeOSPartitions = true
+		}
+
+		// When the input image is an iso, the storage configuration defines
+		// the layout of the writeable image the squashfs contents are
+		// expanded into. So, the partitions are not customized again
+		// afterwards.
+		if len(config.Storage.Verity) > 0 {
+			return nil, fmt.Errorf("verity is not supported when the input image is an iso image")
 		}
 
-		// While defining a storage configuration can work when the input image is
-		// an iso, there is no obvious point of moving content between partitions
-		// where all partitions get collapsed into the squashfs at the end.
-		if config.CustomizePartitions() {
-			return nil, fmt.Errorf("cannot customize partitions when the input is an iso")
+		// The build state only records the customized raw image. But an iso
+		// build also needs the contents of the input iso.
+		if ic.resumeMode.keepWorkDir() {
+			return nil, fmt.Errorf("resuming builds is not supported when the input image is an iso image")
 		}
 	}
 
 	return ic, nil
 }
 
-func CustomizeImageWithConfigFile(buildDir string, configFile string, imageFile string,
+func CustomizeImageWithConfigFile(ctx context.Context, buildDir string, configFile string, imageFile string,
 	rpmsSources []string, outputImageFile string, outputImageFormat string,
 	outputSplitPartitionsFormat string, outputPXEArtifactsDir string,
-	useBaseImageRpmRepos bool, enableShrinkFilesystems bool,
+	useBaseImageRpmRepos bool, enableShrinkFilesystems bool, options CustomizeImageOptions,
 ) error {
 	var err error
 
 	logVersionsOfToolDeps()
 
 	var config imagecustomizerapi.Config
-	err = imagecustomizerapi.UnmarshalYamlFile(configFile, &config)
+	err = imagecustomizerapi.UnmarshalConfigYamlFile(configFile, &config)
 	if err != nil {
 		return err
 	}
@@ -195,8 +294,8 @@ func CustomizeImageWithConfigFile(buildDir string, configFile string, imageFile
 		return fmt.Errorf("failed to get absolute path of config file directory:\n%w", err)
 	}
 
-	err = CustomizeImage(buildDir, absBaseConfigPath, &config, imageFile, rpmsSources, outputImageFile, outputImageFormat,
-		outputSplitPartitionsFormat, outputPXEArtifactsDir, useBaseImageRpmRepos, enableShrinkFilesystems)
+	err = CustomizeImage(ctx, buildDir, absBaseConfigPath, &config, imageFile, rpmsSources, outputImageFile, outputImageFormat,
+		outputSplitPartitionsFormat, outputPXEArtifactsDir, useBaseImageRpmRepos, enableShrinkFilesystems, options)
 	if err != nil {
 		return err
 	}
@@ -213,23 +312,57 @@ func cleanUp(ic *ImageCustomizerParameters) error {
 	return nil
 }
 
-func CustomizeImage(buildDir string, baseConfigPath string, config *imagecustomizerapi.Config, imageFile string,
+// CustomizeImageOptions holds the optional settings of CustomizeImage and CustomizeImageWithConfigFile. The zero value
+// of each field selects its default behavior.
+type CustomizeImageOptions struct {
+	// BuildResources limits the build machine's resources used by the resource intensive build steps.
+	BuildResources BuildResources
+	// ResumeMode controls whether the outputs of the completed build phases are kept and reused by a later build.
+	ResumeMode ResumeMode
+}
+
+func CustomizeImage(ctx context.Context, buildDir string, baseConfigPath string, config *imagecustomizerapi.Config, imageFile string,
 	rpmsSources []string, outputImageFile string, outputImageFormat string, outputSplitPartitionsFormat string,
 	outputPXEArtifactsDir string, useBaseImageRpmRepos bool, enableShrinkFilesystems bool,
+	options CustomizeImageOptions,
 ) error {
-	err := validateConfig(baseConfigPath, config, rpmsSources, useBaseImageRpmRepos)
+	// Configs read from a file have their preset applied while being parsed. But configs constructed in code don't.
+	config, err := imagecustomizerapi.ApplyPreset(config)
+	if err != nil {
+		return fmt.Errorf("failed to apply preset:\n%w", err)
+	}
+
+	err = validateConfig(baseConfigPath, config, rpmsSources, useBaseImageRpmRepos)
 	if err != nil {
 		return fmt.Errorf("invalid image config:\n%w", err)
 	}
 
-	imageCustomizerParameters, err := createImageCustomizerParameters(buildDir, imageFile,
+	// The working folder must be kept until all the intermediate files have been cleaned-up.
+	workDir, err := createBuildWorkDir(buildDir, options.ResumeMode)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		closeErr := workDir.Close()
+		if closeErr != nil {
+			logger.Log.Warnf("Failed to clean-up build working folder:\n%v", closeErr)
+		}
+	}()
+
+	imageCustomizerParameters, err := createImageCustomizerParameters(workDir.path, imageFile,
 		baseConfigPath, config,
 		useBaseImageRpmRepos, rpmsSources, enableShrinkFilesystems, outputSplitPartitionsFormat,
-		outputImageFormat, outputImageFile, outputPXEArtifactsDir)
+		outputImageFormat, outputImageFile, outputPXEArtifactsDir, options)
 	if err != nil {
 		return fmt.Errorf("failed to create image customizer parameters object:\n%w", err)
 	}
+
 	defer func() {
+		if err != nil && imageCustomizerParameters.resumeMode.keepWorkDir() {
+			logger.Log.Infof("Keeping the outputs of the completed build phases in (%s)", buildDir)
+			return
+		}
+
 		cleanupErr := cleanUp(imageCustomizerParameters)
 		if cleanupErr != nil {
 			if err != nil {
@@ -256,9 +389,36 @@ func CustomizeImage(buildDir string, baseConfigPath string, config *imagecustomi
 		return err
 	}
 
-	inputIsoArtifacts, err := convertInputImageToWriteableFormat(imageCustomizerParameters)
-	if err != nil {
-		return fmt.Errorf("failed to convert input image to a raw image:\n%w", err)
+	resumed := false
+	inputsChecksum := ""
+	if imageCustomizerParameters.resumeMode.keepWorkDir() {
+		inputsChecksum, err = calculateBuildInputsChecksum(imageCustomizerParameters)
+		if err != nil {
+			return err
+		}
+	}
+
+	if imageCustomizerParameters.resumeMode == ResumeModeResume {
+		resumed, err = resumeFromBuildState(imageCustomizerParameters, inputsChecksum)
+		if err != nil {
+			return err
+		}
+	}
+
+	if !resumed {
+		// Don't trust the outputs of a previous build, since they are about to be overwritten.
+		err = removeBuildState(imageCustomizerParameters)
+		if err != nil {
+			return err
+		}
+	}
+
+	var inputIsoArtifacts *LiveOSIsoBuilder
+	if !resumed {
+		inputIsoArtifacts, err = convertInputImageToWriteableFormat(ctx, imageCustomizerParameters)
+		if err != nil {
+			return fmt.Errorf("failed to convert input image to a raw image:\n%w", err)
+		}
 	}
 	defer func() {
 		if inputIsoArtifacts != nil {
@@ -273,26 +433,74 @@ func CustomizeImage(buildDir string, baseConfigPath string, config *imagecustomi
 		}
 	}()
 
-	err = customizeOSContents(imageCustomizerParameters)
+	if !resumed {
+		err = checkBuildStopped(ctx)
+		if err != nil {
+			return err
+		}
+
+		err = customizeOSContents(ctx, imageCustomizerParameters)
+		if err != nil {
+			return fmt.Errorf("failed to customize raw image:\n%w", err)
+		}
+
+		if imageCustomizerParameters.resumeMode.keepWorkDir() {
+			err = saveBuildState(imageCustomizerParameters, inputsChecksum)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	err = checkBuildStopped(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to customize raw image:\n%w", err)
+		return err
 	}
 
-	err = convertWriteableFormatToOutputImage(imageCustomizerParameters, inputIsoArtifacts)
+	err = convertWriteableFormatToOutputImage(ctx, imageCustomizerParameters, inputIsoArtifacts)
 	if err != nil {
 		return fmt.Errorf("failed to convert customized raw image to output format:\n%w", err)
 	}
 
+	if imageCustomizerParameters.config.PcrPolicy != nil {
+		err = generatePcrPolicy(imageCustomizerParameters)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = writeBuildReport(imageCustomizerParameters)
+	if err != nil {
+		return err
+	}
+
+	err = removeBuildState(imageCustomizerParameters)
+	if err != nil {
+		return err
+	}
+
 	logger.Log.Infof("Success!")
 
 	return nil
 }
 
-func convertInputImageToWriteableFormat(ic *ImageCustomizerParameters) (*LiveOSIsoBuilder, error) {
+// checkBuildStopped returns an error if the build has been cancelled (or has timed out). It is called between the
+// build steps that don't support cancellation themselves.
+func checkBuildStopped(ctx context.Context) error {
+	err := context.Cause(ctx)
+	if err != nil {
+		return fmt.Errorf("build was stopped:\n%w", err)
+	}
+
+	return nil
+}
+
+func convertInputImageToWriteableFormat(ctx context.Context, ic *ImageCustomizerParameters) (*LiveOSIsoBuilder, error) {
 	logger.Log.Infof("Converting input image to a writeable format")
 
 	if ic.inputIsIso {
-		inputIsoArtifacts, err := createIsoBuilderFromIsoImage(ic.buildDir, ic.buildDirAbs, ic.inputImageFile)
+		inputIsoArtifacts, err := createIsoBuilderFromIsoImage(ctx, ic.buildDir, ic.buildDirAbs, ic.inputImageFile,
+			ic.buildResources)
 		if err != nil {
 			return nil, fmt.Errorf("failed to load input iso artifacts:\n%w", err)
 		}
@@ -302,16 +510,28 @@ func convertInputImageToWriteableFormat(ic *ImageCustomizerParameters) (*LiveOSI
 		// it. If no OS customizations are defined, we can skip this step and
 		// just re-use the existing squashfs.
 		if ic.customizeOSPartitions {
-			err = inputIsoArtifacts.createWriteableImageFromSquashfs(ic.buildDir, ic.rawImageFile)
+			err = inputIsoArtifacts.createWriteableImageFromSquashfs(ctx, ic.buildDir, ic.rawImageFile,
+				ic.config.Storage)
 			if err != nil {
-				return nil, fmt.Errorf("failed to create writeable image:\n%w", err)
+				err = fmt.Errorf("failed to create writeable image:\n%w", err)
+
+				// The caller only owns the builder if it is returned.
+				cleanupErr := inputIsoArtifacts.cleanUp()
+				if cleanupErr != nil {
+					err = fmt.Errorf("%w:\nfailed to clean-up iso builder state:\n%w", err, cleanupErr)
+				}
+				return nil, err
 			}
 		}
 
 		return inputIsoArtifacts, nil
 	} else {
 		logger.Log.Infof("Creating raw base image: %s", ic.rawImageFile)
-		err := shell.ExecuteLiveWithErr(1, "qemu-img", "convert", "-O", "raw", ic.inputImageFile, ic.rawImageFile)
+		err := shell.NewExecBuilder("qemu-img", "convert", "-O", "raw", ic.inputImageFile, ic.rawImageFile).
+			Context(ctx).
+			LogLevel(logrus.DebugLevel, logrus.DebugLevel).
+			ErrorStderrLines(1).
+			Execute()
 		if err != nil {
 			return nil, fmt.Errorf("failed to convert image file to raw format:\n%w", err)
 		}
@@ -320,7 +540,7 @@ func convertInputImageToWriteableFormat(ic *ImageCustomizerParameters) (*LiveOSI
 	}
 }
 
-func customizeOSContents(ic *ImageCustomizerParameters) error {
+func customizeOSContents(ctx context.Context, ic *ImageCustomizerParameters) error {
 	// If there are OS customizations, then we proceed as usual.
 	// If there are no OS customizations, and the input is an iso, we just
 	// return because this function is mainly about OS customizations.
@@ -354,12 +574,21 @@ func customizeOSContents(ic *ImageCustomizerParameters) error {
 	}
 
 	// Customize the partitions.
-	partitionsCustomized, newRawImageFile, partIdToPartUuid, err := customizePartitions(ic.buildDirAbs,
-		ic.configPath, ic.config, ic.rawImageFile)
-	if err != nil {
-		return err
+	// When the input is an iso, the partition layout was already applied while
+	// creating the writeable image from the squashfs.
+	// Also, the initrd of a LiveOS iso is built for booting from the iso media.
+	// So, it must be regenerated when converting the iso to a disk image.
+	partitionsCustomized := ic.inputIsIso && (ic.config.CustomizePartitions() || ic.convertIsoToDisk)
+	partIdToPartUuid := map[string]string(nil)
+	if !ic.inputIsIso {
+		var newRawImageFile string
+		partitionsCustomized, newRawImageFile, partIdToPartUuid, err = customizePartitions(ctx, ic.buildDirAbs,
+			ic.configPath, ic.config, ic.rawImageFile, ic.buildResources)
+		if err != nil {
+			return err
+		}
+		ic.rawImageFile = newRawImageFile
 	}
-	ic.rawImageFile = newRawImageFile
 
 	// Create a uuid for the image
 	imageUuid, imageUuidStr, err := createUuid()
@@ -367,9 +596,20 @@ func customizeOSContents(ic *ImageCustomizerParameters) error {
 		return err
 	}
 
+	err = checkBuildStopped(ctx)
+	if err != nil {
+		return err
+	}
+
 	// Customize the raw image file.
-	err = customizeImageHelper(ic.buildDirAbs, ic.configPath, ic.config, ic.rawImageFile, ic.rpmsSources,
-		ic.useBaseImageRpmRepos, partitionsCustomized, imageUuidStr)
+	ic.vulnerabilityScanReport, err = customizeImageHelper(ctx, ic.buildDirAbs, ic.configPath, ic.config,
+		ic.rawImageFile, ic.rpmsSources, ic.useBaseImageRpmRepos, partitionsCustomized, imageUuidStr,
+		ic.buildResources)
+	if err != nil {
+		return err
+	}
+
+	err = checkBuildStopped(ctx)
 	if err != nil {
 		return err
 	}
@@ -408,7 +648,7 @@ func customizeOSContents(ic *ImageCustomizerParameters) error {
 	return nil
 }
 
-func convertWriteableFormatToOutputImage(ic *ImageCustomizerParameters, inputIsoArtifacts *LiveOSIsoBuilder) error {
+func convertWriteableFormatToOutputImage(ctx context.Context, ic *ImageCustomizerParameters, inputIsoArtifacts *LiveOSIsoBuilder) error {
 	logger.Log.Infof("Converting customized OS partitions into the final image")
 
 	// Create final output image file if requested.
@@ -416,21 +656,43 @@ func convertWriteableFormatToOutputImage(ic *ImageCustomizerParameters, inputIso
 	case ImageFormatVhd, ImageFormatVhdFixed, ImageFormatVhdx, ImageFormatQCow2, ImageFormatRaw:
 		logger.Log.Infof("Writing: %s", ic.outputImageFile)
 
-		err := convertImageFile(ic.rawImageFile, ic.outputImageFile, ic.outputImageFormat)
+		err := convertImageFile(ctx, ic.rawImageFile, ic.outputImageFile, ic.outputImageFormat)
 		if err != nil {
 			return err
 		}
 
+		if ic.config.AzureComputeGallery != nil {
+			ic.azureComputeGalleryReport, err = publishToAzureComputeGallery(ctx, ic.buildDirAbs,
+				ic.config.AzureComputeGallery, ic.outputImageFile)
+			if err != nil {
+				return fmt.Errorf("failed to publish image to Azure Compute Gallery:\n%w", err)
+			}
+		}
+
+	case ImageFormatOci:
+		err := createOciImage(ctx, ic.buildDirAbs, ic.rawImageFile, ic.config.Oci, ic.outputImageFile)
+		if err != nil {
+			return fmt.Errorf("failed to create OCI image:\n%w", err)
+		}
+
+	case ImageFormatWsl:
+		err := createWslDistribution(ctx, ic.buildDirAbs, ic.rawImageFile, ic.config.Wsl, ic.outputImageFile)
+		if err != nil {
+			return fmt.Errorf("failed to create WSL distribution:\n%w", err)
+		}
+
 	case ImageFormatIso:
 		if ic.customizeOSPartitions || inputIsoArtifacts == nil {
-			err := createLiveOSIsoImage(ic.buildDir, ic.configPath, inputIsoArtifacts, ic.config.Iso, ic.config.Pxe, ic.rawImageFile,
-				ic.outputImageDir, ic.outputImageBase, ic.outputPXEArtifactsDir)
+			var err error
+			ic.isoCarryOverReport, err = createLiveOSIsoImage(ctx, ic.buildDir, ic.configPath, inputIsoArtifacts, ic.config.Iso, ic.config.Pxe, ic.rawImageFile,
+				ic.outputImageDir, ic.outputImageBase, ic.outputPXEArtifactsDir, ic.buildResources)
 			if err != nil {
 				return fmt.Errorf("failed to create LiveOS iso image:\n%w", err)
 			}
 		} else {
-			err := inputIsoArtifacts.createImageFromUnchangedOS(ic.configPath, ic.config.Iso, ic.config.Pxe,
-				ic.outputImageDir, ic.outputImageBase, ic.outputPXEArtifactsDir)
+			var err error
+			ic.isoCarryOverReport, err = inputIsoArtifacts.createImageFromUnchangedOS(ctx, ic.configPath, ic.config.Iso,
+				ic.config.Pxe, ic.outputImageDir, ic.outputImageBase, ic.outputPXEArtifactsDir)
 			if err != nil {
 				return fmt.Errorf("failed to create LiveOS iso image:\n%w", err)
 			}
@@ -440,7 +702,7 @@ func convertWriteableFormatToOutputImage(ic *ImageCustomizerParameters, inputIso
 	return nil
 }
 
-func convertImageFile(inputPath string, outputPath string, format string) error {
+func convertImageFile(ctx context.Context, inputPath string, outputPath string, format string) error {
 	qemuImageFormat, qemuOptions := toQemuImageFormat(format)
 
 	qemuImgArgs := []string{"convert", "-O", qemuImageFormat}
@@ -449,7 +711,11 @@ func convertImageFile(inputPath string, outputPath string, format string) error
 	}
 	qemuImgArgs = append(qemuImgArgs, inputPath, outputPath)
 
-	err := shell.ExecuteLiveWithErr(1, "qemu-img", qemuImgArgs...)
+	err := shell.NewExecBuilder("qemu-img", qemuImgArgs...).
+		Context(ctx).
+		LogLevel(logrus.DebugLevel, logrus.DebugLevel).
+		ErrorStderrLines(1).
+		Execute()
 	if err != nil {
 		return fmt.Errorf("failed to convert image file to format: %s:\n%w", format, err)
 	}
@@ -459,11 +725,12 @@ func convertImageFile(inputPath string, outputPath string, format string) error
 
 func validateImageFormat(imageFormat string) error {
 	switch imageFormat {
-	case ImageFormatVhd, ImageFormatVhdFixed, ImageFormatVhdx, ImageFormatRaw, ImageFormatQCow2:
+	case ImageFormatVhd, ImageFormatVhdFixed, ImageFormatVhdx, ImageFormatRaw, ImageFormatQCow2, ImageFormatOci,
+		ImageFormatWsl:
 		return nil
 
 	default:
-		return fmt.Errorf("unsupported image format (supported: vhd, vhd-fixed, vhdx, raw, qcow2): %s", imageFormat)
+		return fmt.Errorf("unsupported image format (supported: vhd, vhd-fixed, vhdx, raw, qcow2, oci, wsl): %s", imageFormat)
 	}
 }
 
@@ -520,6 +787,50 @@ func validateConfig(baseConfigPath string, config *imagecustomizerapi.Config, rp
 		return err
 	}
 
+	if config.VulnerabilityScan != nil {
+		scannerPath := vulnerabilityScannerPath(baseConfigPath, config.VulnerabilityScan)
+
+		_, err = os.Stat(scannerPath)
+		if err != nil {
+			return fmt.Errorf("couldn't read vulnerability scanner file (%s):\n%w", config.VulnerabilityScan.Path, err)
+		}
+	}
+
+	err = validateVerityRootHashSignatures(baseConfigPath, config.Storage.Verity)
+	if err != nil {
+		return err
+	}
+
+	if config.PcrPolicy != nil && config.PcrPolicy.PrivateKeyPath != "" {
+		privateKeyPath := file.GetAbsPathWithBase(baseConfigPath, config.PcrPolicy.PrivateKeyPath)
+
+		_, err = os.Stat(privateKeyPath)
+		if err != nil {
+			return fmt.Errorf("couldn't read PCR policy private key file (%s):\n%w", config.PcrPolicy.PrivateKeyPath, err)
+		}
+	}
+
+	return nil
+}
+
+func validateVerityRootHashSignatures(baseConfigPath string, verityList []imagecustomizerapi.Verity) error {
+	for _, verity := range verityList {
+		if verity.RootHashSignature == nil {
+			continue
+		}
+
+		for _, path := range []string{verity.RootHashSignature.CertificatePath, verity.RootHashSignature.PrivateKeyPath} {
+			isFile, err := file.IsFile(file.GetAbsPathWithBase(baseConfigPath, path))
+			if err != nil {
+				return fmt.Errorf("invalid verity rootHashSignature file (%s):\n%w", path, err)
+			}
+
+			if !isFile {
+				return fmt.Errorf("invalid verity rootHashSignature file (%s):\nnot a file", path)
+			}
+		}
+	}
+
 	return nil
 }
 
@@ -554,6 +865,18 @@ func validateIsoConfig(baseConfigPath string, config *imagecustomizerapi.Iso) er
 		return err
 	}
 
+	if config.ABUpdate != nil && config.ABUpdate.SlotBSource != "" {
+		slotBSourceFullPath := file.GetAbsPathWithBase(baseConfigPath, config.ABUpdate.SlotBSource)
+		isFile, err := file.IsFile(slotBSourceFullPath)
+		if err != nil {
+			return fmt.Errorf("invalid abUpdate slotBSource file (%s):\n%w", config.ABUpdate.SlotBSource, err)
+		}
+
+		if !isFile {
+			return fmt.Errorf("invalid abUpdate slotBSource file (%s):\nnot a file", config.ABUpdate.SlotBSource)
+		}
+	}
+
 	return nil
 }
 
@@ -667,36 +990,45 @@ func validatePackageLists(baseConfigPath string, config *imagecustomizerapi.OS,
 	return nil
 }
 
-func customizeImageHelper(buildDir string, baseConfigPath string, config *imagecustomizerapi.Config,
-	rawImageFile string, rpmsSources []string, useBaseImageRpmRepos bool, partitionsCustomized bool,
-	imageUuidStr string,
-) error {
+func customizeImageHelper(ctx context.Context, buildDir string, baseConfigPath string,
+	config *imagecustomizerapi.Config, rawImageFile string, rpmsSources []string, useBaseImageRpmRepos bool,
+	partitionsCustomized bool, imageUuidStr string, buildResources BuildResources,
+) (*vulnerabilityScanReport, error) {
 	logger.Log.Debugf("Customizing OS")
 
 	imageConnection, err := connectToExistingImage(rawImageFile, buildDir, "imageroot", true)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer imageConnection.Close()
 
 	// Do the actual customizations.
 	err = doOsCustomizations(buildDir, baseConfigPath, config, imageConnection, rpmsSources,
-		useBaseImageRpmRepos, partitionsCustomized, imageUuidStr)
+		useBaseImageRpmRepos, partitionsCustomized, imageUuidStr, buildResources)
 
 	// Out of disk space errors can be difficult to diagnose.
 	// So, warn about any partitions with low free space.
 	warnOnLowFreeSpace(buildDir, imageConnection)
 
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	var vulnerabilityScanReport *vulnerabilityScanReport
+	if config.VulnerabilityScan != nil {
+		vulnerabilityScanReport, err = scanForVulnerabilities(ctx, baseConfigPath, config.VulnerabilityScan,
+			imageConnection.Chroot().RootDir())
+		if err != nil {
+			return nil, fmt.Errorf("vulnerability scan failed:\n%w", err)
+		}
 	}
 
 	err = imageConnection.CleanClose()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	return nil
+	return vulnerabilityScanReport, nil
 }
 
 func extractPartitionsHelper(rawImageFile string, outputDir string, outputBasename string, outputSplitPartitionsFormat string, imageUuid [UuidSize]byte) error {
@@ -792,6 +1124,14 @@ func customizeVerityImageHelper(buildDir string, baseConfigPath string, config *
 	}
 	rootHash = rootHashMatches[1]
 
+	rootHashSignature := ""
+	if rootfsVerity.RootHashSignature != nil {
+		rootHashSignature, err = signVerityRootHash(buildDir, baseConfigPath, rootfsVerity.RootHashSignature, rootHash)
+		if err != nil {
+			return err
+		}
+	}
+
 	systemBootPartition, err := findSystemBootPartition(diskPartitions)
 	if err != nil {
 		return err
@@ -814,7 +1154,8 @@ func customizeVerityImageHelper(buildDir string, baseConfigPath string, config *
 		return fmt.Errorf("failed to stat file (%s):\n%w", grubCfgFullPath, err)
 	}
 
-	err = updateGrubConfigForVerity(rootfsVerity, rootHash, grubCfgFullPath, partIdToPartUuid, diskPartitions)
+	err = updateGrubConfigForVerity(rootfsVerity, rootHash, rootHashSignature, grubCfgFullPath, partIdToPartUuid,
+		diskPartitions)
 	if err != nil {
 		return err
 	}
@@ -5,6 +5,7 @@ package imagecustomizerlib
 
 import (
 	"bytes"
+	"context"
 	"encoding/binary"
 	"fmt"
 	"os"
@@ -54,9 +55,9 @@ func TestCustomizeImageEmptyConfig(t *testing.T) {
 	outImageFilePath := filepath.Join(buildDir, "image.vhd")
 
 	// Customize image.
-	err = CustomizeImage(buildDir, buildDir, &imagecustomizerapi.Config{}, baseImage, nil, outImageFilePath,
+	err = CustomizeImage(context.Background(), buildDir, buildDir, &imagecustomizerapi.Config{}, baseImage, nil, outImageFilePath,
 		"vhd", "",
-		"" /*outputPXEArtifactsDir*/, false /*useBaseImageRpmRepos*/, false /*enableShrinkFilesystems*/)
+		"" /*outputPXEArtifactsDir*/, false /*useBaseImageRpmRepos*/, false /*enableShrinkFilesystems*/, CustomizeImageOptions{})
 	if !assert.NoError(t, err) {
 		return
 	}
@@ -155,6 +156,32 @@ func TestValidateConfigdditionalFilesIsDir(t *testing.T) {
 	assert.Error(t, err)
 }
 
+func TestValidateConfigABUpdateSlotBSource(t *testing.T) {
+	err := validateConfig(testDir, &imagecustomizerapi.Config{
+		Iso: &imagecustomizerapi.Iso{
+			ABUpdate: &imagecustomizerapi.IsoABUpdate{
+				SlotBSource: "files/a.txt",
+			},
+		}}, nil, true)
+	assert.NoError(t, err)
+
+	err = validateConfig(testDir, &imagecustomizerapi.Config{
+		Iso: &imagecustomizerapi.Iso{
+			ABUpdate: &imagecustomizerapi.IsoABUpdate{
+				SlotBSource: "files/missing-slot-b.img",
+			},
+		}}, nil, true)
+	assert.ErrorContains(t, err, "invalid abUpdate slotBSource file (files/missing-slot-b.img)")
+
+	err = validateConfig(testDir, &imagecustomizerapi.Config{
+		Iso: &imagecustomizerapi.Iso{
+			ABUpdate: &imagecustomizerapi.IsoABUpdate{
+				SlotBSource: "files",
+			},
+		}}, nil, true)
+	assert.ErrorContains(t, err, "invalid abUpdate slotBSource file (files):\nnot a file")
+}
+
 func TestValidateConfigScript(t *testing.T) {
 	err := validateScripts(testDir, &imagecustomizerapi.Scripts{
 		PostCustomization: []imagecustomizerapi.Script{
@@ -199,8 +226,8 @@ func TestCustomizeImageKernelCommandLineAdd(t *testing.T) {
 		},
 	}
 
-	err = CustomizeImage(buildDir, buildDir, config, baseImage, nil, outImageFilePath, "raw", "",
-		"" /*outputPXEArtifactsDir*/, false /*useBaseImageRpmRepos*/, false /*enableShrinkFilesystems*/)
+	err = CustomizeImage(context.Background(), buildDir, buildDir, config, baseImage, nil, outImageFilePath, "raw", "",
+		"" /*outputPXEArtifactsDir*/, false /*useBaseImageRpmRepos*/, false /*enableShrinkFilesystems*/, CustomizeImageOptions{})
 	if !assert.NoError(t, err) {
 		return
 	}
@@ -278,3 +305,43 @@ func isZstFile(firstBytes []byte) bool {
 	// 0x184D2A50-0x184D2A5F are skippable ztd frames.
 	return magicNumber == 0xFD2FB528 || (magicNumber >= 0x184D2A50 && magicNumber <= 0x184D2A5F)
 }
+
+func TestCreateImageCustomizerParametersIsoToDisk(t *testing.T) {
+	config := &imagecustomizerapi.Config{
+		OS: &imagecustomizerapi.OS{
+			ResetBootLoaderType: imagecustomizerapi.ResetBootLoaderTypeHard,
+		},
+	}
+
+	ic, err := createImageCustomizerParameters(tmpDir, "input.iso", testDir, config, false, nil, false, "",
+		"vhdx", filepath.Join(tmpDir, "image.vhdx"), "", CustomizeImageOptions{})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.True(t, ic.inputIsIso)
+	assert.True(t, ic.convertIsoToDisk)
+	assert.True(t, ic.customizeOSPartitions)
+}
+
+func TestCreateImageCustomizerParametersIsoToDiskNoResetBootLoader(t *testing.T) {
+	config := &imagecustomizerapi.Config{}
+
+	_, err := createImageCustomizerParameters(tmpDir, "input.iso", testDir, config, false, nil, false, "",
+		"vhdx", filepath.Join(tmpDir, "image.vhdx"), "", CustomizeImageOptions{})
+	assert.ErrorContains(t, err, "'os.resetBootLoaderType' must be set to (hard-reset) when generating a non-iso image from an iso image")
+}
+
+func TestCreateImageCustomizerParametersIsoToIso(t *testing.T) {
+	config := &imagecustomizerapi.Config{}
+
+	ic, err := createImageCustomizerParameters(tmpDir, "input.iso", testDir, config, false, nil, false, "",
+		"iso", filepath.Join(tmpDir, "image.iso"), "", CustomizeImageOptions{})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.True(t, ic.inputIsIso)
+	assert.False(t, ic.convertIsoToDisk)
+	assert.False(t, ic.customizeOSPartitions)
+}
@@ -0,0 +1,432 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/imagegen/installutils"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/file"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/logger"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/safeloopback"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/safemount"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/shell"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// The raw copies of the non-raw images that are being compared.
+	diffImageName      = "diff-image.raw"
+	diffOtherImageName = "diff-other-image.raw"
+
+	// The directory whose files are compared as the config files of the images.
+	diffConfigFilesDir = "/etc"
+)
+
+// ImageDiff holds the differences between two images (the image and the other image).
+type ImageDiff struct {
+	// The rpm packages that were added, removed or changed. The values are the packages' versions.
+	Packages []ValueDiff `json:"packages"`
+	// The files under /etc that were added, removed or changed. The values are the files' SHA-256 hashes (or the
+	// targets of symlinks).
+	ConfigFiles []ValueDiff `json:"configFiles"`
+	// The installed kernel versions. Nil if they are the same.
+	KernelVersions *ValueDiff `json:"kernelVersions,omitempty"`
+	// The kernel command line of the default boot entry. Nil if it is the same.
+	KernelCommandLine *ValueDiff `json:"kernelCommandLine,omitempty"`
+	// The systemd units whose enablement state (e.g. enabled, disabled, masked) was added, removed or changed.
+	SystemdUnits []ValueDiff `json:"systemdUnits"`
+}
+
+// ValueDiff is a difference of a named item between the two images. An empty value means that the item is missing
+// from the image.
+type ValueDiff struct {
+	Name       string `json:"name"`
+	Value      string `json:"value"`
+	OtherValue string `json:"otherValue"`
+}
+
+// HasDifferences returns whether the images differ in any of the compared items.
+func (d *ImageDiff) HasDifferences() bool {
+	return len(d.Packages) > 0 || len(d.ConfigFiles) > 0 || d.KernelVersions != nil ||
+		d.KernelCommandLine != nil || len(d.SystemdUnits) > 0
+}
+
+// imageDiffSnapshot holds the items of an image that are compared.
+type imageDiffSnapshot struct {
+	packages          map[string]string
+	configFiles       map[string]string
+	kernelVersions    []string
+	kernelCommandLine string
+	systemdUnits      map[string]string
+}
+
+// DiffImages compares two existing images (or isos), and returns their differences.
+//
+// Both images are connected to in the read-only verification mode (see connectToExistingImageReadOnly). So, comparing
+// images never modifies them.
+func DiffImages(ctx context.Context, buildDir string, imageFile string, otherImageFile string) (*ImageDiff, error) {
+	err := os.MkdirAll(buildDir, os.ModePerm)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create build directory (%s):\n%w", buildDir, err)
+	}
+
+	buildDirAbs, err := filepath.Abs(buildDir)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot, err := readImageDiffSnapshot(ctx, buildDirAbs, imageFile, diffImageName, "diffroot")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image (%s):\n%w", imageFile, err)
+	}
+
+	otherSnapshot, err := readImageDiffSnapshot(ctx, buildDirAbs, otherImageFile, diffOtherImageName,
+		"diffotherroot")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image (%s):\n%w", otherImageFile, err)
+	}
+
+	return diffImageSnapshots(snapshot, otherSnapshot), nil
+}
+
+func readImageDiffSnapshot(ctx context.Context, buildDirAbs string, imageFile string, rawImageName string,
+	chrootDirName string,
+) (*imageDiffSnapshot, error) {
+	logger.Log.Infof("Reading image: %s", imageFile)
+
+	imageFormat := strings.TrimLeft(filepath.Ext(imageFile), ".")
+	if imageFormat == ImageFormatIso {
+		return readIsoDiffSnapshot(ctx, buildDirAbs, imageFile)
+	}
+
+	rawImageFile := filepath.Join(buildDirAbs, rawImageName)
+	defer os.Remove(rawImageFile)
+
+	imageConnection, err := connectToImageFileReadOnly(imageFile, rawImageFile, buildDirAbs, chrootDirName)
+	if err != nil {
+		return nil, err
+	}
+	defer imageConnection.Close()
+
+	rootDir := imageConnection.Chroot().RootDir()
+
+	snapshot, err := readRootDirDiffSnapshot(ctx, rootDir, filepath.Join(rootDir, installutils.GrubCfgFile))
+	if err != nil {
+		return nil, err
+	}
+
+	err = imageConnection.CleanClose()
+	if err != nil {
+		return nil, err
+	}
+
+	return snapshot, nil
+}
+
+// readIsoDiffSnapshot mounts the iso and its LiveOS rootfs image read-only, and reads the rootfs's items. The kernel
+// command line is read from the iso's grub.cfg file.
+func readIsoDiffSnapshot(ctx context.Context, buildDirAbs string, isoImageFile string,
+) (snapshot *imageDiffSnapshot, err error) {
+	cleanups := &cleanupManager{}
+	defer cleanups.runDeferred(&err)
+
+	isoMountDir, err := os.MkdirTemp(buildDirAbs, "tmp-iso-mount-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary mount folder for iso:\n%w", err)
+	}
+	cleanups.addDir(isoMountDir)
+
+	isoImageLoopDevice, err := safeloopback.NewReadOnlyLoopback(isoImageFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create loop device for (%s):\n%w", isoImageFile, err)
+	}
+	cleanups.addLoopback(isoImageLoopDevice)
+
+	isoImageMount, err := safemount.NewMount(isoImageLoopDevice.DevicePath(), isoMountDir,
+		"iso9660" /*fstype*/, unix.MS_RDONLY /*flags*/, "" /*data*/, false /*makeAndDelete*/)
+	if err != nil {
+		return nil, err
+	}
+	cleanups.addMount(isoImageMount)
+
+	liveOSDir := defaultLiveOSDir
+	liveOSImage := defaultLiveOSImage
+
+	savedConfigs, err := loadSavedConfigs(filepath.Join(isoMountDir, savedConfigsDir, savedConfigsFileName))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load saved configurations of iso:\n%w", err)
+	}
+
+	if savedConfigs != nil {
+		if savedConfigs.Iso.LiveOSDir != "" {
+			liveOSDir = savedConfigs.Iso.LiveOSDir
+		}
+		if savedConfigs.Iso.LiveOSImageName != "" {
+			liveOSImage = savedConfigs.Iso.LiveOSImageName
+		}
+	}
+
+	squashfsImageFile := filepath.Join(isoMountDir, liveOSDir, liveOSImage)
+
+	squashMountDir, err := os.MkdirTemp(buildDirAbs, "tmp-squashfs-mount-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary mount folder for squashfs:\n%w", err)
+	}
+	cleanups.addDir(squashMountDir)
+
+	squashfsLoopDevice, err := safeloopback.NewReadOnlyLoopback(squashfsImageFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create loop device for (%s):\n%w", squashfsImageFile, err)
+	}
+	cleanups.addLoopback(squashfsLoopDevice)
+
+	squashfsMount, err := safemount.NewMount(squashfsLoopDevice.DevicePath(), squashMountDir,
+		"squashfs" /*fstype*/, unix.MS_RDONLY /*flags*/, "" /*data*/, false /*makeAndDelete*/)
+	if err != nil {
+		return nil, err
+	}
+	cleanups.addMount(squashfsMount)
+
+	isoGrubCfgPath := filepath.Join(isoMountDir, grubCfgDir, isoGrubCfg)
+
+	snapshot, err = readRootDirDiffSnapshot(ctx, squashMountDir, isoGrubCfgPath)
+	if err != nil {
+		return nil, err
+	}
+
+	err = cleanups.run()
+	if err != nil {
+		return nil, err
+	}
+
+	return snapshot, nil
+}
+
+func readRootDirDiffSnapshot(ctx context.Context, rootDir string, grubCfgPath string,
+) (*imageDiffSnapshot, error) {
+	packages, err := readDiffPackages(ctx, rootDir)
+	if err != nil {
+		return nil, err
+	}
+
+	configFiles, err := hashDiffConfigFiles(filepath.Join(rootDir, diffConfigFilesDir))
+	if err != nil {
+		return nil, err
+	}
+
+	kernelVersions, err := getInstalledKernelVersions(rootDir)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(kernelVersions)
+
+	kernelCommandLine, err := readDiffKernelCommandLine(grubCfgPath)
+	if err != nil {
+		return nil, err
+	}
+
+	systemdUnits, err := readDiffSystemdUnits(ctx, rootDir)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := &imageDiffSnapshot{
+		packages:          packages,
+		configFiles:       configFiles,
+		kernelVersions:    kernelVersions,
+		kernelCommandLine: kernelCommandLine,
+		systemdUnits:      systemdUnits,
+	}
+	return snapshot, nil
+}
+
+func readDiffPackages(ctx context.Context, rootDir string) (map[string]string, error) {
+	rpms, err := listInstalledRpms(ctx, rootDir)
+	if err != nil {
+		return nil, err
+	}
+
+	return diffPackageVersions(rpms), nil
+}
+
+// diffPackageVersions returns the versions of the packages, by name. Packages that are installed more than once (e.g.
+// kernels) have all of their versions listed.
+func diffPackageVersions(rpms []installedRpm) map[string]string {
+	versionsByName := make(map[string][]string)
+	for _, rpm := range rpms {
+		versionsByName[rpm.Name] = append(versionsByName[rpm.Name], rpm.fullVersion())
+	}
+
+	packages := make(map[string]string, len(versionsByName))
+	for name, versions := range versionsByName {
+		sort.Strings(versions)
+		packages[name] = strings.Join(versions, ", ")
+	}
+	return packages
+}
+
+// hashDiffConfigFiles returns the SHA-256 hashes of the regular files under configDir, and the targets of its
+// symlinks. The paths are relative to the image's root directory.
+func hashDiffConfigFiles(configDir string) (map[string]string, error) {
+	configFiles := make(map[string]string)
+
+	err := filepath.WalkDir(configDir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		relativePath, err := filepath.Rel(configDir, path)
+		if err != nil {
+			return err
+		}
+		imagePath := filepath.Join(diffConfigFilesDir, relativePath)
+
+		switch {
+		case entry.Type().IsRegular():
+			hash, err := file.GenerateSHA256(path)
+			if err != nil {
+				return err
+			}
+			configFiles[imagePath] = "sha256:" + hash
+
+		case entry.Type()&fs.ModeSymlink != 0:
+			// Symlinks are not followed, since they would be resolved against the build host's root.
+			target, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			configFiles[imagePath] = "symlink:" + target
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash config files (%s):\n%w", diffConfigFilesDir, err)
+	}
+
+	return configFiles, nil
+}
+
+// readDiffKernelCommandLine returns the args of the first non-recovery 'linux' command of the grub.cfg file. Images
+// that don't boot using grub (e.g. UKI images) have an empty kernel command line.
+func readDiffKernelCommandLine(grubCfgPath string) (string, error) {
+	grubCfgContent, err := file.Read(grubCfgPath)
+	if errors.Is(err, os.ErrNotExist) {
+		logger.Log.Debugf("No grub.cfg file (%s) to read the kernel command line from", grubCfgPath)
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read grub.cfg file (%s):\n%w", grubCfgPath, err)
+	}
+
+	return parseDiffKernelCommandLine(grubCfgContent)
+}
+
+func parseDiffKernelCommandLine(grubCfgContent string) (string, error) {
+	linuxLines, err := FindNonRecoveryLinuxLine(grubCfgContent)
+	if err != nil {
+		return "", fmt.Errorf("failed to find kernel command line in grub.cfg file:\n%w", err)
+	}
+	if len(linuxLines) == 0 {
+		return "", nil
+	}
+
+	args := []string(nil)
+	if len(linuxLines[0].Tokens) > 2 {
+		// Skip the "linux" command and the kernel binary path arg.
+		for _, token := range linuxLines[0].Tokens[2:] {
+			args = append(args, token.RawContent)
+		}
+	}
+
+	return strings.Join(args, " "), nil
+}
+
+func readDiffSystemdUnits(ctx context.Context, rootDir string) (map[string]string, error) {
+	stdout, _, err := shell.NewExecBuilder("systemctl", "--root="+rootDir, "list-unit-files", "--no-legend",
+		"--no-pager").
+		Context(ctx).
+		LogLevel(logrus.TraceLevel, logrus.DebugLevel).
+		ErrorStderrLines(1).
+		ExecuteCaptureOuput()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list systemd unit files:\n%w", err)
+	}
+
+	return parseDiffSystemdUnits(stdout), nil
+}
+
+// parseDiffSystemdUnits parses the output of 'systemctl list-unit-files', which has the columns: unit, state and
+// (on newer versions of systemd) vendor preset.
+func parseDiffSystemdUnits(systemctlOutput string) map[string]string {
+	units := make(map[string]string)
+	for _, line := range strings.Split(systemctlOutput, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+
+		units[fields[0]] = fields[1]
+	}
+	return units
+}
+
+func diffImageSnapshots(snapshot *imageDiffSnapshot, otherSnapshot *imageDiffSnapshot) *ImageDiff {
+	diff := &ImageDiff{
+		Packages:     diffValueMaps(snapshot.packages, otherSnapshot.packages),
+		ConfigFiles:  diffValueMaps(snapshot.configFiles, otherSnapshot.configFiles),
+		SystemdUnits: diffValueMaps(snapshot.systemdUnits, otherSnapshot.systemdUnits),
+	}
+
+	kernelVersions := strings.Join(snapshot.kernelVersions, ", ")
+	otherKernelVersions := strings.Join(otherSnapshot.kernelVersions, ", ")
+	if kernelVersions != otherKernelVersions {
+		diff.KernelVersions = &ValueDiff{
+			Name:       "kernelVersions",
+			Value:      kernelVersions,
+			OtherValue: otherKernelVersions,
+		}
+	}
+
+	if snapshot.kernelCommandLine != otherSnapshot.kernelCommandLine {
+		diff.KernelCommandLine = &ValueDiff{
+			Name:       "kernelCommandLine",
+			Value:      snapshot.kernelCommandLine,
+			OtherValue: otherSnapshot.kernelCommandLine,
+		}
+	}
+
+	return diff
+}
+
+// diffValueMaps returns the items that are only in one of the maps, or whose values differ, sorted by name.
+func diffValueMaps(values map[string]string, otherValues map[string]string) []ValueDiff {
+	diffs := []ValueDiff{}
+	for name, value := range values {
+		otherValue := otherValues[name]
+		if value != otherValue {
+			diffs = append(diffs, ValueDiff{Name: name, Value: value, OtherValue: otherValue})
+		}
+	}
+
+	for name, otherValue := range otherValues {
+		if _, found := values[name]; !found {
+			diffs = append(diffs, ValueDiff{Name: name, OtherValue: otherValue})
+		}
+	}
+
+	sort.Slice(diffs, func(i, j int) bool {
+		return diffs[i].Name < diffs[j].Name
+	})
+	return diffs
+}
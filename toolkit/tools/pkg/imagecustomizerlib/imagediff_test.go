@@ -0,0 +1,145 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/file"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffImagesSameImage(t *testing.T) {
+	baseImage := checkSkipForCustomizeImage(t, baseImageTypeCoreEfi, baseImageVersionDefault)
+
+	buildDir := filepath.Join(tmpDir, "TestDiffImagesSameImage")
+	defer os.RemoveAll(buildDir)
+
+	diff, err := DiffImages(context.Background(), buildDir, baseImage, baseImage)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.False(t, diff.HasDifferences())
+}
+
+func TestDiffPackageVersions(t *testing.T) {
+	rpms := []installedRpm{
+		{Name: "bash", Epoch: "0", Version: "5.2.15", Release: "3.azl3", Arch: "x86_64"},
+		{Name: "kernel", Epoch: "0", Version: "6.6.47.1", Release: "1.azl3", Arch: "x86_64"},
+		{Name: "kernel", Epoch: "0", Version: "6.6.44.1", Release: "1.azl3", Arch: "x86_64"},
+	}
+
+	packages := diffPackageVersions(rpms)
+	assert.Equal(t, map[string]string{
+		"bash":   "0:5.2.15-3.azl3.x86_64",
+		"kernel": "0:6.6.44.1-1.azl3.x86_64, 0:6.6.47.1-1.azl3.x86_64",
+	}, packages)
+}
+
+func TestParseDiffSystemdUnits(t *testing.T) {
+	systemctlOutput := "sshd.service                 enabled         disabled\n" +
+		"systemd-networkd.service     masked          enabled\n" +
+		"getty@.service               static\n"
+
+	units := parseDiffSystemdUnits(systemctlOutput)
+	assert.Equal(t, map[string]string{
+		"sshd.service":             "enabled",
+		"systemd-networkd.service": "masked",
+		"getty@.service":           "static",
+	}, units)
+}
+
+func TestParseDiffKernelCommandLine(t *testing.T) {
+	grubCfgContent := `
+menuentry "Azure Linux" {
+	linux /vmlinuz-6.6.47.1-1.azl3 root=PARTUUID=1234 ro console=ttyS0 "quoted arg"
+}
+menuentry "Azure Linux (recovery mode)" {
+	linux /vmlinuz-6.6.47.1-1.azl3 root=PARTUUID=1234 ro single
+}
+`
+
+	commandLine, err := parseDiffKernelCommandLine(grubCfgContent)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, `root=PARTUUID=1234 ro console=ttyS0 "quoted arg"`, commandLine)
+}
+
+func TestReadDiffKernelCommandLineMissingGrubCfg(t *testing.T) {
+	commandLine, err := readDiffKernelCommandLine(filepath.Join(tmpDir, "TestReadDiffKernelCommandLine", "grub.cfg"))
+	assert.NoError(t, err)
+	assert.Equal(t, "", commandLine)
+}
+
+func TestHashDiffConfigFiles(t *testing.T) {
+	configDir := filepath.Join(tmpDir, "TestHashDiffConfigFiles", "etc")
+	defer os.RemoveAll(filepath.Dir(configDir))
+
+	err := os.MkdirAll(filepath.Join(configDir, "ssh"), os.ModePerm)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	err = file.Write("", filepath.Join(configDir, "ssh/sshd_config"))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// The symlink's target must not be resolved against the build host's root.
+	err = os.Symlink("/usr/lib/os-release", filepath.Join(configDir, "os-release"))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	configFiles, err := hashDiffConfigFiles(configDir)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, map[string]string{
+		"/etc/ssh/sshd_config": "sha256:e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+		"/etc/os-release":      "symlink:/usr/lib/os-release",
+	}, configFiles)
+}
+
+func TestDiffImageSnapshots(t *testing.T) {
+	snapshot := &imageDiffSnapshot{
+		packages:          map[string]string{"bash": "0:5.2.15-3.azl3.x86_64", "vim": "0:9.1-1.azl3.x86_64"},
+		configFiles:       map[string]string{"/etc/hostname": "sha256:aa"},
+		kernelVersions:    []string{"6.6.47.1-1.azl3"},
+		kernelCommandLine: "ro console=ttyS0",
+		systemdUnits:      map[string]string{"sshd.service": "enabled"},
+	}
+	otherSnapshot := &imageDiffSnapshot{
+		packages:          map[string]string{"bash": "0:5.2.15-4.azl3.x86_64", "nano": "0:7.2-1.azl3.x86_64"},
+		configFiles:       map[string]string{"/etc/hostname": "sha256:aa"},
+		kernelVersions:    []string{"6.6.47.1-1.azl3"},
+		kernelCommandLine: "ro console=ttyS0 selinux=1",
+		systemdUnits:      map[string]string{"sshd.service": "disabled"},
+	}
+
+	diff := diffImageSnapshots(snapshot, otherSnapshot)
+	assert.True(t, diff.HasDifferences())
+	assert.Equal(t, []ValueDiff{
+		{Name: "bash", Value: "0:5.2.15-3.azl3.x86_64", OtherValue: "0:5.2.15-4.azl3.x86_64"},
+		{Name: "nano", OtherValue: "0:7.2-1.azl3.x86_64"},
+		{Name: "vim", Value: "0:9.1-1.azl3.x86_64"},
+	}, diff.Packages)
+	assert.Empty(t, diff.ConfigFiles)
+	assert.Nil(t, diff.KernelVersions)
+	assert.Equal(t, &ValueDiff{
+		Name:       "kernelCommandLine",
+		Value:      "ro console=ttyS0",
+		OtherValue: "ro console=ttyS0 selinux=1",
+	}, diff.KernelCommandLine)
+	assert.Equal(t, []ValueDiff{
+		{Name: "sshd.service", Value: "enabled", OtherValue: "disabled"},
+	}, diff.SystemdUnits)
+}
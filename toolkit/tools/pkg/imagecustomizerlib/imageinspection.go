@@ -0,0 +1,126 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/file"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/logger"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/shell"
+)
+
+const (
+	// The raw copy of a non-raw image that is being inspected.
+	inspectImageName = "inspect-image.raw"
+)
+
+// ImageInspection holds the details read from an existing image.
+type ImageInspection struct {
+	// The contents of the image's os-release file.
+	OsRelease string
+	// The versions of the kernels installed in the image.
+	KernelVersions []string
+}
+
+// InspectImage reads the details of an existing (non-iso) image.
+//
+// The image is connected to in the read-only verification mode (see connectToExistingImageReadOnly). Raw images are
+// attached in place. So, inspecting an image never modifies it, even if one of its file systems was not cleanly
+// unmounted.
+func InspectImage(buildDir string, imageFile string) (*ImageInspection, error) {
+	imageFormat := strings.TrimLeft(filepath.Ext(imageFile), ".")
+	if imageFormat == ImageFormatIso {
+		return nil, fmt.Errorf("inspecting an iso image is not supported")
+	}
+
+	err := os.MkdirAll(buildDir, os.ModePerm)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create build directory (%s):\n%w", buildDir, err)
+	}
+
+	buildDirAbs, err := filepath.Abs(buildDir)
+	if err != nil {
+		return nil, err
+	}
+
+	rawImageFile := filepath.Join(buildDirAbs, inspectImageName)
+	defer os.Remove(rawImageFile)
+
+	imageConnection, err := connectToImageFileReadOnly(imageFile, rawImageFile, buildDirAbs, "inspectroot")
+	if err != nil {
+		return nil, err
+	}
+	defer imageConnection.Close()
+
+	inspection, err := inspectImageContents(imageConnection.Chroot().RootDir())
+	if err != nil {
+		return nil, err
+	}
+
+	err = imageConnection.CleanClose()
+	if err != nil {
+		return nil, err
+	}
+
+	return inspection, nil
+}
+
+// connectToImageFileReadOnly connects to an existing (non-iso) image in the read-only verification mode. Since
+// loopback devices require a raw image, non-raw images are first converted to a raw copy (rawImageFile), which the
+// caller is responsible for removing.
+func connectToImageFileReadOnly(imageFile string, rawImageFile string, buildDirAbs string, chrootDirName string,
+) (*ImageConnection, error) {
+	imageFormat := strings.TrimLeft(filepath.Ext(imageFile), ".")
+	if imageFormat == ImageFormatRaw {
+		rawImageFile = imageFile
+	} else {
+		logger.Log.Infof("Creating raw copy of image: %s", rawImageFile)
+		err := shell.ExecuteLiveWithErr(1, "qemu-img", "convert", "-O", "raw", imageFile, rawImageFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert image file to raw format:\n%w", err)
+		}
+	}
+
+	return connectToExistingImageReadOnly(rawImageFile, buildDirAbs, chrootDirName)
+}
+
+func inspectImageContents(rootDir string) (*ImageInspection, error) {
+	osRelease, err := readOsReleaseFile(rootDir)
+	if err != nil {
+		return nil, err
+	}
+
+	kernelVersions, err := getInstalledKernelVersions(rootDir)
+	if err != nil {
+		return nil, err
+	}
+
+	inspection := &ImageInspection{
+		OsRelease:      osRelease,
+		KernelVersions: kernelVersions,
+	}
+	return inspection, nil
+}
+
+// readOsReleaseFile reads the os-release file of the OS under rootDir. /etc/os-release is usually a symlink to
+// /usr/lib/os-release. Since symlinks would be resolved against the build host's root, the symlink isn't followed.
+func readOsReleaseFile(rootDir string) (string, error) {
+	osReleasePath := filepath.Join(rootDir, "/etc/os-release")
+
+	fileInfo, err := os.Lstat(osReleasePath)
+	if err != nil || !fileInfo.Mode().IsRegular() {
+		osReleasePath = filepath.Join(rootDir, "/usr/lib/os-release")
+	}
+
+	osRelease, err := file.Read(osReleasePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read os-release file:\n%w", err)
+	}
+
+	return osRelease, nil
+}
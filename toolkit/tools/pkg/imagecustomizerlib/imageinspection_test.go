@@ -0,0 +1,72 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/file"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInspectImage(t *testing.T) {
+	baseImage := checkSkipForCustomizeImage(t, baseImageTypeCoreEfi, baseImageVersionDefault)
+
+	buildDir := filepath.Join(tmpDir, "TestInspectImage")
+	defer os.RemoveAll(buildDir)
+
+	inspection, err := InspectImage(buildDir, baseImage)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Contains(t, inspection.OsRelease, "ID=")
+	assert.NotEmpty(t, inspection.KernelVersions)
+}
+
+func TestInspectImageIso(t *testing.T) {
+	buildDir := filepath.Join(tmpDir, "TestInspectImageIso")
+	defer os.RemoveAll(buildDir)
+
+	_, err := InspectImage(buildDir, "image.iso")
+	assert.ErrorContains(t, err, "inspecting an iso image is not supported")
+}
+
+func TestInspectImageContents(t *testing.T) {
+	rootDir := filepath.Join(tmpDir, "TestInspectImageContents")
+	defer os.RemoveAll(rootDir)
+
+	for _, dir := range []string{"/etc", "/usr/lib", "/lib/modules/6.6.47.1-1.azl3"} {
+		err := os.MkdirAll(filepath.Join(rootDir, dir), os.ModePerm)
+		if !assert.NoError(t, err) {
+			return
+		}
+	}
+
+	err := file.Write("ID=azurelinux\nVERSION_ID=\"3.0\"\n", filepath.Join(rootDir, "/usr/lib/os-release"))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// The symlink's target must be resolved under the rootDir, not the build host's root.
+	err = os.Symlink("/usr/lib/os-release", filepath.Join(rootDir, "/etc/os-release"))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	err = file.Write("", filepath.Join(rootDir, "/lib/modules/6.6.47.1-1.azl3/modules.dep"))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	inspection, err := inspectImageContents(rootDir)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, "ID=azurelinux\nVERSION_ID=\"3.0\"\n", inspection.OsRelease)
+	assert.Equal(t, []string{"6.6.47.1-1.azl3"}, inspection.KernelVersions)
+}
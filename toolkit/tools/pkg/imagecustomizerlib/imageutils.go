@@ -23,7 +23,24 @@ func connectToExistingImage(imageFilePath string, buildDir string, chrootDirName
 ) (*ImageConnection, error) {
 	imageConnection := NewImageConnection()
 
-	err := connectToExistingImageHelper(imageConnection, imageFilePath, buildDir, chrootDirName, includeDefaultMounts)
+	err := connectToExistingImageHelper(imageConnection, imageFilePath, buildDir, chrootDirName, includeDefaultMounts,
+		false /*readOnly*/)
+	if err != nil {
+		imageConnection.Close()
+		return nil, err
+	}
+	return imageConnection, nil
+}
+
+// connectToExistingImageReadOnly connects to an existing image in a verification-only mode. The image is attached to
+// a read-only loopback device and all its partitions are mounted read-only with journal recovery suppressed. So,
+// inspecting the image can never modify it.
+func connectToExistingImageReadOnly(imageFilePath string, buildDir string, chrootDirName string,
+) (*ImageConnection, error) {
+	imageConnection := NewImageConnection()
+
+	err := connectToExistingImageHelper(imageConnection, imageFilePath, buildDir, chrootDirName,
+		false /*includeDefaultMounts*/, true /*readOnly*/)
 	if err != nil {
 		imageConnection.Close()
 		return nil, err
@@ -32,16 +49,21 @@ func connectToExistingImage(imageFilePath string, buildDir string, chrootDirName
 }
 
 func connectToExistingImageHelper(imageConnection *ImageConnection, imageFilePath string,
-	buildDir string, chrootDirName string, includeDefaultMounts bool,
+	buildDir string, chrootDirName string, includeDefaultMounts bool, readOnly bool,
 ) error {
 	// Connect to image file using loopback device.
-	err := imageConnection.ConnectLoopback(imageFilePath)
+	var err error
+	if readOnly {
+		err = imageConnection.ConnectReadOnlyLoopback(imageFilePath)
+	} else {
+		err = imageConnection.ConnectLoopback(imageFilePath)
+	}
 	if err != nil {
 		return err
 	}
 
 	// Look for all the partitions on the image.
-	mountPoints, err := findPartitions(buildDir, imageConnection.Loopback().DevicePath())
+	mountPoints, err := findPartitions(buildDir, imageConnection.Loopback().DevicePath(), readOnly)
 	if err != nil {
 		return fmt.Errorf("failed to find disk partitions:\n%w", err)
 	}
@@ -231,7 +253,7 @@ func createImageBoilerplate(imageConnection *ImageConnection, filename string, b
 	}
 
 	// Read back the fstab file.
-	mountPoints, err := findMountsFromFstabFile(tmpFstabFile, diskPartitions)
+	mountPoints, err := findMountsFromFstabFile(tmpFstabFile, diskPartitions, false /*readOnly*/)
 	if err != nil {
 		return nil, "", err
 	}
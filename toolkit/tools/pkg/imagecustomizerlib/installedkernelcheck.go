@@ -7,32 +7,92 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/file"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/logger"
 	"github.com/microsoft/azurelinux/toolkit/tools/internal/safechroot"
 )
 
 // Check if the user accidentally uninstalled the kernel package without installing a substitute package.
 func checkForInstalledKernel(imageChroot *safechroot.Chroot) error {
-	kernelModulesDir := filepath.Join(imageChroot.RootDir(), "/lib/modules")
+	kernelVersions, err := getInstalledKernelVersions(imageChroot.RootDir())
+	if err != nil {
+		return err
+	}
+
+	if len(kernelVersions) <= 0 {
+		return fmt.Errorf("no installed kernel found")
+	}
+
+	return nil
+}
+
+// Returns the versions of the kernels installed in the image.
+func getInstalledKernelVersions(rootDir string) ([]string, error) {
+	kernelModulesDir := filepath.Join(rootDir, "/lib/modules")
 
 	kernels, err := os.ReadDir(kernelModulesDir)
 	if err != nil {
-		return fmt.Errorf("failed to read installed kernels list:\n%w", err)
+		return nil, fmt.Errorf("failed to read installed kernels list:\n%w", err)
 	}
 
+	kernelVersions := []string(nil)
 	for _, kernel := range kernels {
 		// There is a bug in Azure Linux 2.0, where uninstalling the kernel package doesn't remove the directory
 		// /lib/modules/<ver>. Instead the directory is just emptied. So, ensure the directory isn't empty.
 		files, err := os.ReadDir(filepath.Join(kernelModulesDir, kernel.Name()))
 		if err != nil {
-			return fmt.Errorf("failed to read installed kernel (%s) module directory:\n%w", kernel.Name(), err)
+			return nil, fmt.Errorf("failed to read installed kernel (%s) module directory:\n%w", kernel.Name(), err)
 		}
 
 		if len(files) > 0 {
-			// Found at least 1 kernel.
-			return nil
+			kernelVersions = append(kernelVersions, kernel.Name())
+		}
+	}
+
+	return kernelVersions, nil
+}
+
+// handleKernelUpdates checks if the package operations changed the installed kernels (e.g. a kernel update). If so,
+// the grub.cfg file of grub-mkconfig based images is regenerated. Otherwise, its menu entries would still boot the
+// previous kernels. Returns whether the installed kernels changed, in which case the initramfs files also need to be
+// regenerated.
+func handleKernelUpdates(previousKernelVersions []string, imageChroot *safechroot.Chroot) (bool, error) {
+	kernelVersions, err := getInstalledKernelVersions(imageChroot.RootDir())
+	if err != nil {
+		return false, err
+	}
+
+	// An image without a kernel is reported by checkForInstalledKernel.
+	if slices.Equal(previousKernelVersions, kernelVersions) || len(kernelVersions) <= 0 {
+		return false, nil
+	}
+
+	logger.Log.Infof("Installed kernels changed from (%s) to (%s)", strings.Join(previousKernelVersions, ", "),
+		strings.Join(kernelVersions, ", "))
+
+	// Images that don't boot using grub (e.g. UKI images) don't have a grub.cfg file.
+	grubCfgExists, err := file.PathExists(getGrub2ConfigFilePath(imageChroot))
+	if err != nil {
+		return false, fmt.Errorf("failed to check if grub.cfg file exists:\n%w", err)
+	}
+
+	if grubCfgExists {
+		bootCustomizer, err := NewBootCustomizer(imageChroot)
+		if err != nil {
+			return false, err
+		}
+
+		// The grub.cfg file of non-grub-mkconfig images boots the kernel that the kernel package's scripts select.
+		if bootCustomizer.IsGrubMkconfigImage() {
+			err = bootCustomizer.WriteToFile(imageChroot)
+			if err != nil {
+				return false, err
+			}
 		}
 	}
 
-	return fmt.Errorf("no installed kernel found")
+	return true, nil
 }
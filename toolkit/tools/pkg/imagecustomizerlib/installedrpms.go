@@ -0,0 +1,72 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/shell"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	installedRpmsQueryFormat = "%{NAME}\t%{EPOCHNUM}\t%{VERSION}\t%{RELEASE}\t%{ARCH}\n"
+)
+
+// installedRpm is an rpm package that is installed in an image.
+type installedRpm struct {
+	Name    string `json:"name"`
+	Epoch   string `json:"epoch"`
+	Version string `json:"version"`
+	Release string `json:"release"`
+	Arch    string `json:"arch"`
+}
+
+// fullVersion returns the package's version in the format: epoch:version-release.arch
+func (r *installedRpm) fullVersion() string {
+	return fmt.Sprintf("%s:%s-%s.%s", r.Epoch, r.Version, r.Release, r.Arch)
+}
+
+// listInstalledRpms lists the rpm packages that are installed in the OS under rootDir. The build host's rpm is used.
+// So, the OS doesn't need to be chroot-able (e.g. it can be mounted read-only).
+func listInstalledRpms(ctx context.Context, rootDir string) ([]installedRpm, error) {
+	stdout, _, err := shell.NewExecBuilder("rpm", "--root", rootDir, "-qa", "--queryformat",
+		installedRpmsQueryFormat).
+		Context(ctx).
+		LogLevel(logrus.TraceLevel, logrus.DebugLevel).
+		ErrorStderrLines(1).
+		ExecuteCaptureOuput()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list installed rpm packages:\n%w", err)
+	}
+
+	return parseInstalledRpms(stdout)
+}
+
+// parseInstalledRpms parses the output of 'rpm -qa' (see installedRpmsQueryFormat).
+func parseInstalledRpms(rpmOutput string) ([]installedRpm, error) {
+	rpms := []installedRpm(nil)
+	for _, line := range strings.Split(rpmOutput, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		fields := strings.Split(line, "\t")
+		if len(fields) != 5 {
+			return nil, fmt.Errorf("unexpected rpm query output line (%s)", line)
+		}
+
+		rpms = append(rpms, installedRpm{
+			Name:    fields[0],
+			Epoch:   fields[1],
+			Version: fields[2],
+			Release: fields[3],
+			Arch:    fields[4],
+		})
+	}
+
+	return rpms, nil
+}
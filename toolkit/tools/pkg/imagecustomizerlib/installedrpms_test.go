@@ -0,0 +1,32 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseInstalledRpms(t *testing.T) {
+	rpmOutput := "bash\t0\t5.2.15\t3.azl3\tx86_64\n" +
+		"ca-certificates\t0\t3.0.0\t8.azl3\tnoarch\n" +
+		"\n"
+
+	rpms, err := parseInstalledRpms(rpmOutput)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, []installedRpm{
+		{Name: "bash", Epoch: "0", Version: "5.2.15", Release: "3.azl3", Arch: "x86_64"},
+		{Name: "ca-certificates", Epoch: "0", Version: "3.0.0", Release: "8.azl3", Arch: "noarch"},
+	}, rpms)
+	assert.Equal(t, "0:5.2.15-3.azl3.x86_64", rpms[0].fullVersion())
+}
+
+func TestParseInstalledRpmsBadLine(t *testing.T) {
+	_, err := parseInstalledRpms("bash 0:5.2.15-3.azl3.x86_64\n")
+	assert.ErrorContains(t, err, "unexpected rpm query output line (bash 0:5.2.15-3.azl3.x86_64)")
+}
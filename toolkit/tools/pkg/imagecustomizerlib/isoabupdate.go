@@ -0,0 +1,147 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/imagecustomizerapi"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/file"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/jsonutils"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/logger"
+)
+
+const (
+	isoSlotsManifestFileName = "slots.json"
+	isoSlotsManifestVersion  = 1
+
+	isoSlotA       = "a"
+	isoSlotB       = "b"
+	isoSlotBTitle  = "Azure Linux (slot B)"
+	isoDefaultSlot = isoSlotA
+
+	// The id of the grub menu entry that boots into slot A (i.e. the default
+	// menu entry). It intentionally does not start with
+	// additionalRootfsMenuEntryIdPrefix, so that it survives the removal of
+	// the additional rootfs menu entries when the iso is customized again.
+	isoSlotAMenuEntryId = "azl-slot-a"
+)
+
+// `isoSlotsManifest` describes the OS image slots of an A/B update iso. It is
+// placed on the iso media next to the slot images.
+type isoSlotsManifest struct {
+	Version     int           `json:"version"`     // Example: 1
+	DefaultSlot string        `json:"defaultslot"` // Example: a
+	Slots       []isoSlotInfo `json:"slots"`
+}
+
+type isoSlotInfo struct {
+	Name string `json:"name"` // Example: b
+	// The path of the slot's squashfs image on the iso media.
+	Image string `json:"image"` // Example: /liveos/rootfs-b.img
+	// The id of the grub menu entry that boots into the slot.
+	MenuEntryId string `json:"menuentryid"` // Example: azl-liveos-rootfs-b.img
+	Sha256      string `json:"sha256"`
+}
+
+// newSlotBRootfsImage returns the additional rootfs image that represents
+// slot B of an A/B update iso.
+func newSlotBRootfsImage(baseConfigPath string, abUpdateConfig *imagecustomizerapi.IsoABUpdate) isoRootfsImage {
+	// An empty squashfsImagePath indicates that slot B starts as a copy of
+	// slot A.
+	squashfsImagePath := ""
+	if abUpdateConfig.SlotBSource != "" {
+		squashfsImagePath = file.GetAbsPathWithBase(baseConfigPath, abUpdateConfig.SlotBSource)
+	} else {
+		logger.Log.Infof("Slot B starts as a copy of slot A, which doubles the size of the OS images on the iso")
+	}
+
+	return isoRootfsImage{
+		squashfsImagePath: squashfsImagePath,
+		name:              imagecustomizerapi.IsoSlotBRootfsImageName,
+		title:             isoSlotBTitle,
+	}
+}
+
+// liveOSGeneratedFileNames returns the names of the files the iso build
+// generates under the liveOSDir: the rootfs images and, for an A/B update iso,
+// the slots manifest.
+func (b *LiveOSIsoBuilder) liveOSGeneratedFileNames() []string {
+	fileNames := []string{b.liveOSImage}
+	for _, rootfsImage := range b.artifacts.additionalRootfsImages {
+		fileNames = append(fileNames, rootfsImage.name)
+	}
+
+	if b.findSlotBRootfsImage() != nil {
+		fileNames = append(fileNames, isoSlotsManifestFileName)
+	}
+
+	return fileNames
+}
+
+// findSlotBRootfsImage returns the slot B rootfs image if the iso is an A/B
+// update iso. Otherwise, it returns nil.
+func (b *LiveOSIsoBuilder) findSlotBRootfsImage() *isoRootfsImage {
+	for i := range b.artifacts.additionalRootfsImages {
+		if b.artifacts.additionalRootfsImages[i].name == imagecustomizerapi.IsoSlotBRootfsImageName {
+			return &b.artifacts.additionalRootfsImages[i]
+		}
+	}
+	return nil
+}
+
+// writeSlotsManifest
+//
+//	creates the slots manifest of an A/B update iso.
+//
+// inputs:
+//   - 'liveOSDir':
+//     the directory (on the iso media) that holds the slot images.
+//   - 'slotAImageName':
+//     the file name of the slot A image on the iso media.
+//   - 'slotAImagePath':
+//     path to the squashfs image of slot A on the build machine.
+//   - 'slotBImagePath':
+//     path to the squashfs image of slot B on the build machine.
+//   - 'manifestPath':
+//     path of the manifest file to create.
+func writeSlotsManifest(liveOSDir string, slotAImageName string, slotAImagePath string, slotBImagePath string,
+	manifestPath string) error {
+	slotASha256, err := file.GenerateSHA256(slotAImagePath)
+	if err != nil {
+		return fmt.Errorf("failed to calculate the sha256 of slot A image (%s):\n%w", slotAImagePath, err)
+	}
+
+	slotBSha256, err := file.GenerateSHA256(slotBImagePath)
+	if err != nil {
+		return fmt.Errorf("failed to calculate the sha256 of slot B image (%s):\n%w", slotBImagePath, err)
+	}
+
+	manifest := isoSlotsManifest{
+		Version:     isoSlotsManifestVersion,
+		DefaultSlot: isoDefaultSlot,
+		Slots: []isoSlotInfo{
+			{
+				Name:        isoSlotA,
+				Image:       filepath.Join("/", liveOSDir, slotAImageName),
+				MenuEntryId: isoSlotAMenuEntryId,
+				Sha256:      slotASha256,
+			},
+			{
+				Name:        isoSlotB,
+				Image:       filepath.Join("/", liveOSDir, imagecustomizerapi.IsoSlotBRootfsImageName),
+				MenuEntryId: additionalRootfsMenuEntryIdPrefix + imagecustomizerapi.IsoSlotBRootfsImageName,
+				Sha256:      slotBSha256,
+			},
+		},
+	}
+
+	err = jsonutils.WriteJSONFile(manifestPath, &manifest)
+	if err != nil {
+		return fmt.Errorf("failed to write slots manifest (%s):\n%w", manifestPath, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,76 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/imagecustomizerapi"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/jsonutils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteSlotsManifest(t *testing.T) {
+	testTempDir := filepath.Join(tmpDir, "TestWriteSlotsManifest")
+	defer os.RemoveAll(testTempDir)
+
+	err := os.MkdirAll(testTempDir, os.ModePerm)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	slotAImagePath := filepath.Join(testTempDir, "rootfs.img")
+	err = os.WriteFile(slotAImagePath, []byte("a"), 0o644)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	slotBImagePath := filepath.Join(testTempDir, "rootfs-b.img")
+	err = os.WriteFile(slotBImagePath, []byte("b"), 0o644)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	manifestPath := filepath.Join(testTempDir, isoSlotsManifestFileName)
+	err = writeSlotsManifest("liveos", "rootfs.img", slotAImagePath, slotBImagePath, manifestPath)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	var manifest isoSlotsManifest
+	err = jsonutils.ReadJSONFile(manifestPath, &manifest)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, isoSlotsManifest{
+		Version:     1,
+		DefaultSlot: "a",
+		Slots: []isoSlotInfo{
+			{
+				Name:        "a",
+				Image:       "/liveos/rootfs.img",
+				MenuEntryId: "azl-slot-a",
+				Sha256:      "ca978112ca1bbdcafac231b39a23dc4da786eff8147c4e72b9807785afee48bb",
+			},
+			{
+				Name:        "b",
+				Image:       "/liveos/rootfs-b.img",
+				MenuEntryId: "azl-liveos-rootfs-b.img",
+				Sha256:      "3e23e8160039594a33894f6564e1b1348bbd7a0088d42c4acb73eeaed59c009d",
+			},
+		},
+	}, manifest)
+}
+
+func TestNewSlotBRootfsImage(t *testing.T) {
+	rootfsImage := newSlotBRootfsImage("/config", &imagecustomizerapi.IsoABUpdate{})
+	assert.Equal(t, "", rootfsImage.squashfsImagePath)
+	assert.Equal(t, "rootfs-b.img", rootfsImage.name)
+
+	rootfsImage = newSlotBRootfsImage("/config", &imagecustomizerapi.IsoABUpdate{SlotBSource: "slot-b.img"})
+	assert.Equal(t, "/config/slot-b.img", rootfsImage.squashfsImagePath)
+}
@@ -0,0 +1,77 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"fmt"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/imagecustomizerapi"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/logger"
+)
+
+// isoBootBackendPolicy lists which of the bootloader files a boot backend requires.
+type isoBootBackendPolicy struct {
+	// the shim (or whatever replaces it) is booted by the UEFI firmware.
+	shimRequired bool
+	// if the shim is missing, grub is booted by the UEFI firmware instead.
+	grubAsFirstStage bool
+	// grub is booted by the shim.
+	grubRequired bool
+}
+
+var isoBootBackendPolicies = map[imagecustomizerapi.IsoBootBackend]isoBootBackendPolicy{
+	imagecustomizerapi.IsoBootBackendDefault:  {shimRequired: true, grubRequired: true},
+	imagecustomizerapi.IsoBootBackendShimGrub: {shimRequired: true, grubRequired: true},
+	imagecustomizerapi.IsoBootBackendGrub:     {grubAsFirstStage: true, grubRequired: true},
+	// the first stage bootloader is provided by iso.bootloaderFiles.
+	imagecustomizerapi.IsoBootBackendCustom: {shimRequired: true},
+}
+
+// checkBootloaderFiles
+//
+//   - ensures the bootloader files required by the iso's boot backend were
+//     found, and warns about the optional ones that are missing.
+//   - if grub is booted directly, grub is staged as the first stage
+//     bootloader.
+//
+// outputs:
+//   - the following may be updated:
+//     b.artifacts.bootx64EfiPath
+func (b *LiveOSIsoBuilder) checkBootloaderFiles() error {
+	policy, found := isoBootBackendPolicies[b.bootBackend]
+	if !found {
+		return fmt.Errorf("unsupported iso bootBackend (%s)", b.bootBackend)
+	}
+
+	if b.artifacts.grubx64EfiPath == "" {
+		if policy.grubRequired {
+			return fmt.Errorf("failed to find the grub efi file (%s or %s):\n"+
+				"this file is provided by either the (grub2-efi-binary) or the (grub2-efi-binary-noprefix) package",
+				grubx64Binary, grubx64NoPrefixBinary)
+		}
+
+		logger.Log.Warnf("Did not find the grub efi file (%s or %s): the iso's first stage bootloader must not "+
+			"chain-load grub", grubx64Binary, grubx64NoPrefixBinary)
+	}
+
+	if b.artifacts.bootx64EfiPath == "" {
+		if policy.shimRequired {
+			if b.bootBackend == imagecustomizerapi.IsoBootBackendCustom {
+				return fmt.Errorf("failed to find the first stage bootloader (%s):\n"+
+					"bootBackend (%s) requires it to be provided by iso.bootloaderFiles",
+					imagecustomizerapi.IsoFirstStageBootloaderPath, b.bootBackend)
+			}
+
+			return fmt.Errorf("failed to find the boot efi file (%s):\n"+
+				"this file is provided by the (shim) package",
+				bootx64Binary)
+		}
+
+		logger.Log.Warnf("Did not find the boot efi file (%s): grub will be booted directly and secure boot is not "+
+			"supported", bootx64Binary)
+		b.artifacts.bootx64EfiPath = b.artifacts.grubx64EfiPath
+	}
+
+	return nil
+}
@@ -0,0 +1,54 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"testing"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/imagecustomizerapi"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckBootloaderFiles(t *testing.T) {
+	newBuilder := func(bootBackend imagecustomizerapi.IsoBootBackend, bootx64EfiPath string, grubx64EfiPath string,
+	) *LiveOSIsoBuilder {
+		return &LiveOSIsoBuilder{
+			bootBackend: bootBackend,
+			artifacts: IsoArtifacts{
+				bootx64EfiPath: bootx64EfiPath,
+				grubx64EfiPath: grubx64EfiPath,
+			},
+		}
+	}
+
+	b := newBuilder(imagecustomizerapi.IsoBootBackendDefault, "/a/bootx64.efi", "/a/grubx64.efi")
+	err := b.checkBootloaderFiles()
+	assert.NoError(t, err)
+
+	b = newBuilder(imagecustomizerapi.IsoBootBackendShimGrub, "", "/a/grubx64.efi")
+	err = b.checkBootloaderFiles()
+	assert.ErrorContains(t, err, "failed to find the boot efi file (bootx64.efi)")
+
+	b = newBuilder(imagecustomizerapi.IsoBootBackendShimGrub, "/a/bootx64.efi", "")
+	err = b.checkBootloaderFiles()
+	assert.ErrorContains(t, err, "failed to find the grub efi file")
+
+	// grub is booted directly when the shim is missing.
+	b = newBuilder(imagecustomizerapi.IsoBootBackendGrub, "", "/a/grubx64.efi")
+	err = b.checkBootloaderFiles()
+	assert.NoError(t, err)
+	assert.Equal(t, "/a/grubx64.efi", b.artifacts.bootx64EfiPath)
+
+	b = newBuilder(imagecustomizerapi.IsoBootBackendGrub, "", "")
+	err = b.checkBootloaderFiles()
+	assert.ErrorContains(t, err, "failed to find the grub efi file")
+
+	b = newBuilder(imagecustomizerapi.IsoBootBackendCustom, "/a/systemd-bootx64.efi", "")
+	err = b.checkBootloaderFiles()
+	assert.NoError(t, err)
+
+	b = newBuilder(imagecustomizerapi.IsoBootBackendCustom, "", "")
+	err = b.checkBootloaderFiles()
+	assert.ErrorContains(t, err, "failed to find the first stage bootloader (EFI/BOOT/bootx64.efi)")
+}
@@ -0,0 +1,136 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"path/filepath"
+	"sort"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/imagecustomizerapi"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/logger"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/safechroot"
+)
+
+// isoCarryOverReport records which of the input iso's files were carried over to the new iso, for the build report.
+type isoCarryOverReport struct {
+	Files []isoCarryOverDecision `json:"files"`
+}
+
+type isoCarryOverDecision struct {
+	// The path of the file on the iso media.
+	Path   string                                `json:"path"`
+	Action imagecustomizerapi.IsoCarryOverAction `json:"action"`
+	// The path pattern of the rule that matched the file, if any.
+	Rule        string `json:"rule,omitempty"`
+	CarriedOver bool   `json:"carriedOver"`
+}
+
+// carryOverIsoFiles merges the files of the input iso into the files generated for the new iso, following the iso
+// configuration's carry-over rules.
+//
+// inputs:
+//
+//   - 'rules':
+//     the carry-over rules of the iso configuration.
+//   - 'inputFiles':
+//     the files of the input iso (local-build-path -> iso-media-path).
+//   - 'newFiles':
+//     the files generated for the new iso (local-build-path -> iso-media-path).
+//     The carried over files are added to it.
+//   - 'configFiles':
+//     the files requested by the iso configuration (i.e. iso.additionalFiles).
+//   - 'carriedOverFiles':
+//     the subset of newFiles (local-build-path) that come from the input iso.
+//     The carried over files are added to it.
+//
+// outputs:
+//
+//   - returns the carry-over decision of each of the input iso's files.
+func carryOverIsoFiles(rules imagecustomizerapi.IsoCarryOverRuleList, inputFiles map[string]string,
+	newFiles map[string]string, configFiles []safechroot.FileToCopy, carriedOverFiles map[string]bool,
+) *isoCarryOverReport {
+	// The media paths that the new iso has files for.
+	newMediaPaths := make(map[string]string)
+	for sourceFile, targetFile := range newFiles {
+		newMediaPaths[normalizeIsoMediaPath(targetFile)] = sourceFile
+	}
+
+	configMediaPaths := make(map[string]bool)
+	for _, configFile := range configFiles {
+		configMediaPaths[normalizeIsoMediaPath(configFile.Dest)] = true
+	}
+
+	// Process the files in a stable order, so that the report is reproducible.
+	inputSourceFiles := make([]string, 0, len(inputFiles))
+	for inputSourceFile := range inputFiles {
+		inputSourceFiles = append(inputSourceFiles, inputSourceFile)
+	}
+	sort.Slice(inputSourceFiles, func(i, j int) bool {
+		return inputFiles[inputSourceFiles[i]] < inputFiles[inputSourceFiles[j]]
+	})
+
+	report := &isoCarryOverReport{
+		Files: []isoCarryOverDecision{},
+	}
+	for _, inputSourceFile := range inputSourceFiles {
+		mediaPath := normalizeIsoMediaPath(inputFiles[inputSourceFile])
+
+		decision := isoCarryOverDecision{
+			Path:   mediaPath,
+			Action: imagecustomizerapi.IsoCarryOverActionReplace,
+		}
+
+		rule := rules.Match(mediaPath)
+		if rule != nil {
+			decision.Rule = rule.Path
+			if rule.Action != imagecustomizerapi.IsoCarryOverActionDefault {
+				decision.Action = rule.Action
+			}
+		}
+
+		newSourceFile, hasNewFile := newMediaPaths[mediaPath]
+		hasConfigFile := configMediaPaths[mediaPath]
+
+		switch decision.Action {
+		case imagecustomizerapi.IsoCarryOverActionKeep:
+			// The files requested by the iso configuration always take precedence, since the user explicitly asked for
+			// them.
+			decision.CarriedOver = !hasConfigFile
+			if decision.CarriedOver && hasNewFile {
+				delete(newFiles, newSourceFile)
+				delete(carriedOverFiles, newSourceFile)
+			}
+
+		case imagecustomizerapi.IsoCarryOverActionReplace:
+			decision.CarriedOver = !hasNewFile && !hasConfigFile
+
+		case imagecustomizerapi.IsoCarryOverActionDelete:
+			decision.CarriedOver = false
+		}
+
+		if decision.CarriedOver {
+			newFiles[inputSourceFile] = inputFiles[inputSourceFile]
+			carriedOverFiles[inputSourceFile] = true
+		}
+
+		logger.Log.Debugf("Input iso file (%s): action (%s), carried over (%t)", mediaPath, decision.Action,
+			decision.CarriedOver)
+
+		report.Files = append(report.Files, decision)
+	}
+
+	return report
+}
+
+func isoCarryOverRules(isoConfig *imagecustomizerapi.Iso) imagecustomizerapi.IsoCarryOverRuleList {
+	if isoConfig == nil {
+		return nil
+	}
+	return isoConfig.CarryOverRules
+}
+
+// normalizeIsoMediaPath returns the path of a file on the iso media as an absolute path.
+func normalizeIsoMediaPath(mediaPath string) string {
+	return filepath.Join("/", mediaPath)
+}
@@ -0,0 +1,99 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"testing"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/imagecustomizerapi"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/safechroot"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCarryOverIsoFilesDefault(t *testing.T) {
+	inputFiles := map[string]string{
+		"/input/boot/grub2/fonts/unicode.pf2": "/boot/grub2/fonts/unicode.pf2",
+		"/input/docs/guide.pdf":               "/docs/guide.pdf",
+		"/input/readme.txt":                   "/readme.txt",
+	}
+	newFiles := map[string]string{
+		"/new/boot/grub2/fonts/unicode.pf2": "/boot/grub2/fonts/unicode.pf2",
+	}
+	configFiles := []safechroot.FileToCopy{
+		{Src: "/config/readme.txt", Dest: "readme.txt"},
+	}
+	carriedOverFiles := map[string]bool{}
+
+	report := carryOverIsoFiles(nil, inputFiles, newFiles, configFiles, carriedOverFiles)
+
+	// Input files are only carried over if the new iso doesn't have a file with the same path.
+	assert.Equal(t, map[string]string{
+		"/new/boot/grub2/fonts/unicode.pf2": "/boot/grub2/fonts/unicode.pf2",
+		"/input/docs/guide.pdf":             "/docs/guide.pdf",
+	}, newFiles)
+	assert.Equal(t, map[string]bool{
+		"/input/docs/guide.pdf": true,
+	}, carriedOverFiles)
+	assert.Equal(t, []isoCarryOverDecision{
+		{Path: "/boot/grub2/fonts/unicode.pf2", Action: imagecustomizerapi.IsoCarryOverActionReplace},
+		{Path: "/docs/guide.pdf", Action: imagecustomizerapi.IsoCarryOverActionReplace, CarriedOver: true},
+		{Path: "/readme.txt", Action: imagecustomizerapi.IsoCarryOverActionReplace},
+	}, report.Files)
+}
+
+func TestCarryOverIsoFilesRules(t *testing.T) {
+	rules := imagecustomizerapi.IsoCarryOverRuleList{
+		{Path: "/boot/grub2/fonts", Action: imagecustomizerapi.IsoCarryOverActionKeep},
+		{Path: "/docs/draft-*", Action: imagecustomizerapi.IsoCarryOverActionDelete},
+		{Path: "/*.txt", Action: imagecustomizerapi.IsoCarryOverActionKeep},
+	}
+	inputFiles := map[string]string{
+		"/input/boot/grub2/fonts/unicode.pf2": "/boot/grub2/fonts/unicode.pf2",
+		"/input/docs/draft-guide.pdf":         "/docs/draft-guide.pdf",
+		"/input/docs/guide.pdf":               "/docs/guide.pdf",
+		"/input/readme.txt":                   "/readme.txt",
+	}
+	newFiles := map[string]string{
+		"/new/boot/grub2/fonts/unicode.pf2": "/boot/grub2/fonts/unicode.pf2",
+	}
+	configFiles := []safechroot.FileToCopy{
+		{Src: "/config/readme.txt", Dest: "/readme.txt"},
+	}
+	carriedOverFiles := map[string]bool{}
+
+	report := carryOverIsoFiles(rules, inputFiles, newFiles, configFiles, carriedOverFiles)
+
+	// Kept files replace the new iso's files. But, the files of the iso configuration always take precedence.
+	assert.Equal(t, map[string]string{
+		"/input/boot/grub2/fonts/unicode.pf2": "/boot/grub2/fonts/unicode.pf2",
+		"/input/docs/guide.pdf":               "/docs/guide.pdf",
+	}, newFiles)
+	assert.Equal(t, map[string]bool{
+		"/input/boot/grub2/fonts/unicode.pf2": true,
+		"/input/docs/guide.pdf":               true,
+	}, carriedOverFiles)
+	assert.Equal(t, []isoCarryOverDecision{
+		{
+			Path:        "/boot/grub2/fonts/unicode.pf2",
+			Action:      imagecustomizerapi.IsoCarryOverActionKeep,
+			Rule:        "/boot/grub2/fonts",
+			CarriedOver: true,
+		},
+		{
+			Path:   "/docs/draft-guide.pdf",
+			Action: imagecustomizerapi.IsoCarryOverActionDelete,
+			Rule:   "/docs/draft-*",
+		},
+		{
+			Path:        "/docs/guide.pdf",
+			Action:      imagecustomizerapi.IsoCarryOverActionReplace,
+			CarriedOver: true,
+		},
+		{
+			Path:   "/readme.txt",
+			Action: imagecustomizerapi.IsoCarryOverActionKeep,
+			Rule:   "/*.txt",
+		},
+	}, report.Files)
+}
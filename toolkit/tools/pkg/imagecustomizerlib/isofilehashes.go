@@ -0,0 +1,194 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/file"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/logger"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/safechroot"
+)
+
+// isoMediaPath returns the normalized (absolute) path of a file on the iso
+// media.
+func isoMediaPath(dest string) string {
+	return filepath.Join("/", dest)
+}
+
+const (
+	// The paths (on the iso media) of the files isomaker generates on its own.
+	isoMakerEfiBootImageMediaPath = "/boot/grub2/efiboot.img"
+	isoMakerConfigMediaDir        = "/config"
+)
+
+// isIsoMakerGeneratedMediaPath returns true if the file on the iso media is
+// generated by isomaker itself (as opposed to being passed to it).
+func isIsoMakerGeneratedMediaPath(mediaPath string) bool {
+	return mediaPath == isoMakerEfiBootImageMediaPath ||
+		strings.HasPrefix(mediaPath, isoMakerConfigMediaDir+"/")
+}
+
+// isGeneratedIsoMediaPath returns true if the file on the iso media is
+// (re-)generated on every customization and hence is never carried over from
+// an input iso.
+//
+// 'liveOSGeneratedFiles' holds the names of the files generated under the
+// 'liveOSDir' (i.e. the rootfs images and the slots manifest). Other files
+// under the 'liveOSDir' are user files. If nil (i.e. an iso created before the
+// generated files were recorded), all the files under the 'liveOSDir' are
+// considered generated.
+func isGeneratedIsoMediaPath(mediaPath string, liveOSDir string, liveOSGeneratedFiles []string) bool {
+	if mediaPath == isoMediaPath(filepath.Join(savedConfigsDir, savedConfigsFileName)) {
+		return true
+	}
+
+	if filepath.Dir(mediaPath) != isoMediaPath(liveOSDir) {
+		return false
+	}
+
+	return liveOSGeneratedFiles == nil || slices.Contains(liveOSGeneratedFiles, filepath.Base(mediaPath))
+}
+
+// generateIsoFileHashes
+//
+//	calculates the sha256 of each of the files that will be copied to the iso
+//	media and that can be carried over when the iso is customized again.
+//
+// inputs:
+//   - 'isoFiles':
+//     the files to copy to the iso media. If more than one file has the same
+//     destination, the last one wins.
+//   - 'liveOSDir':
+//     the directory (on the iso media) that holds the rootfs images.
+//   - 'liveOSGeneratedFiles':
+//     the names of the files generated under the 'liveOSDir'.
+//
+// outputs:
+//   - map of iso media path -> sha256.
+func generateIsoFileHashes(isoFiles []safechroot.FileToCopy, liveOSDir string, liveOSGeneratedFiles []string,
+) (map[string]string, error) {
+	hashes := make(map[string]string)
+
+	for _, isoFile := range isoFiles {
+		mediaPath := isoMediaPath(isoFile.Dest)
+		if isGeneratedIsoMediaPath(mediaPath, liveOSDir, liveOSGeneratedFiles) {
+			continue
+		}
+
+		if isoFile.Content != nil {
+			rawHash := sha256.Sum256([]byte(*isoFile.Content))
+			hashes[mediaPath] = hex.EncodeToString(rawHash[:])
+			continue
+		}
+
+		fileInfo, err := os.Lstat(isoFile.Src)
+		if err != nil {
+			return nil, fmt.Errorf("failed to stat (%s):\n%w", isoFile.Src, err)
+		}
+
+		if fileInfo.Mode()&os.ModeSymlink != 0 {
+			// Symlinks are not verified.
+			delete(hashes, mediaPath)
+			continue
+		}
+
+		hash, err := file.GenerateSHA256(isoFile.Src)
+		if err != nil {
+			return nil, fmt.Errorf("failed to calculate the sha256 of (%s):\n%w", isoFile.Src, err)
+		}
+
+		hashes[mediaPath] = hash
+	}
+
+	return hashes, nil
+}
+
+// verifyIsoFileHashes
+//
+//	verifies that the files carried over from an input iso match the sha256
+//	values recorded when that iso was created.
+//
+//	Since the recorded values are stored on the same media, this detects
+//	corruption (e.g. a bad copy or a failing disk) but not deliberate
+//	modifications.
+//
+// inputs:
+//   - 'isoExpansionFolder':
+//     folder holding the extracted contents of the input iso.
+//   - 'additionalFiles':
+//     map of local-build-path -> iso-media-path of the files to carry over.
+//   - 'hashes':
+//     map of iso-media-path -> sha256 recorded in the saved configs of the
+//     input iso.
+func verifyIsoFileHashes(isoExpansionFolder string, additionalFiles map[string]string, hashes map[string]string) error {
+	if len(hashes) == 0 {
+		logger.Log.Debugf("Input iso has no recorded file hashes, skipping verification of carried over files")
+		return nil
+	}
+
+	for localPath, mediaPath := range additionalFiles {
+		mediaPath = isoMediaPath(mediaPath)
+
+		fileInfo, err := os.Lstat(localPath)
+		if err != nil {
+			return fmt.Errorf("failed to stat (%s):\n%w", localPath, err)
+		}
+
+		isSymlink := fileInfo.Mode()&os.ModeSymlink != 0
+
+		expectedHash, exists := hashes[mediaPath]
+		if !exists {
+			// Symlinks and the files isomaker generates on its own are not
+			// recorded.
+			if isSymlink || isIsoMakerGeneratedMediaPath(mediaPath) {
+				continue
+			}
+
+			return fmt.Errorf("file (%s) on the input iso has no recorded sha256:\n"+
+				"the iso media may be corrupted", mediaPath)
+		}
+
+		if isSymlink {
+			return fmt.Errorf("file (%s) on the input iso was recorded as a regular file but is a symlink:\n"+
+				"the iso media may be corrupted", mediaPath)
+		}
+
+		hash, err := file.GenerateSHA256(localPath)
+		if err != nil {
+			return fmt.Errorf("failed to calculate the sha256 of (%s):\n%w", localPath, err)
+		}
+
+		if hash != expectedHash {
+			return fmt.Errorf("file (%s) on the input iso does not match its recorded sha256 (expected: %s, actual: %s):\n"+
+				"the iso media may be corrupted", mediaPath, expectedHash, hash)
+		}
+	}
+
+	missing := []string(nil)
+	for mediaPath := range hashes {
+		exists, err := file.PathExists(filepath.Join(isoExpansionFolder, mediaPath))
+		if err != nil {
+			return fmt.Errorf("failed to check if (%s) exists:\n%w", mediaPath, err)
+		}
+		if !exists {
+			missing = append(missing, mediaPath)
+		}
+	}
+
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return fmt.Errorf("files (%s) recorded on the input iso are missing:\n"+
+			"the iso media may be corrupted", strings.Join(missing, ", "))
+	}
+
+	return nil
+}
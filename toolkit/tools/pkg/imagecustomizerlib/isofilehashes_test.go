@@ -0,0 +1,134 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/safechroot"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testSha256A = "ca978112ca1bbdcafac231b39a23dc4da786eff8147c4e72b9807785afee48bb"
+	testSha256B = "3e23e8160039594a33894f6564e1b1348bbd7a0088d42c4acb73eeaed59c009d"
+)
+
+func TestGenerateIsoFileHashes(t *testing.T) {
+	testTempDir := filepath.Join(tmpDir, "TestGenerateIsoFileHashes")
+	defer os.RemoveAll(testTempDir)
+
+	err := os.MkdirAll(testTempDir, os.ModePerm)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	srcFile := filepath.Join(testTempDir, "a.txt")
+	err = os.WriteFile(srcFile, []byte("a"), 0o644)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	symlinkFile := filepath.Join(testTempDir, "link")
+	err = os.Symlink("a.txt", symlinkFile)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	content := "b"
+	hashes, err := generateIsoFileHashes([]safechroot.FileToCopy{
+		{Src: srcFile, Dest: "files/a.txt"},
+		{Content: &content, Dest: "/files/b.txt"},
+		{Src: symlinkFile, Dest: "/files/link", NoDereference: true},
+		{Src: srcFile, Dest: "/liveos/rootfs.img"},
+		{Src: srcFile, Dest: "/liveos/rootfs-b.img"},
+		{Src: srcFile, Dest: "/liveos/user.txt"},
+		{Src: srcFile, Dest: "/azl-image-customizer/saved-configs.yaml"},
+	}, "liveos", []string{"rootfs.img", "rootfs-b.img"})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, map[string]string{
+		"/files/a.txt":     testSha256A,
+		"/files/b.txt":     testSha256B,
+		"/liveos/user.txt": testSha256A,
+	}, hashes)
+}
+
+func TestIsGeneratedIsoMediaPath(t *testing.T) {
+	liveOSGeneratedFiles := []string{"rootfs.img", "rootfs-b.img", "slots.json"}
+
+	assert.True(t, isGeneratedIsoMediaPath("/azl-image-customizer/saved-configs.yaml", "liveos", liveOSGeneratedFiles))
+	assert.True(t, isGeneratedIsoMediaPath("/liveos/rootfs.img", "liveos", liveOSGeneratedFiles))
+	assert.True(t, isGeneratedIsoMediaPath("/liveos/slots.json", "liveos", liveOSGeneratedFiles))
+	assert.False(t, isGeneratedIsoMediaPath("/liveos/user.txt", "liveos", liveOSGeneratedFiles))
+	assert.False(t, isGeneratedIsoMediaPath("/liveos/sub/rootfs.img", "liveos", liveOSGeneratedFiles))
+	assert.False(t, isGeneratedIsoMediaPath("/boot/rootfs.img", "liveos", liveOSGeneratedFiles))
+
+	// Isos created before the generated files were recorded.
+	assert.True(t, isGeneratedIsoMediaPath("/liveos/user.txt", "liveos", nil))
+}
+
+func TestVerifyIsoFileHashes(t *testing.T) {
+	testTempDir := filepath.Join(tmpDir, "TestVerifyIsoFileHashes")
+	defer os.RemoveAll(testTempDir)
+
+	err := os.MkdirAll(filepath.Join(testTempDir, "files"), os.ModePerm)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	fileA := filepath.Join(testTempDir, "files/a.txt")
+	err = os.WriteFile(fileA, []byte("a"), 0o644)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	additionalFiles := map[string]string{
+		fileA: "/files/a.txt",
+	}
+
+	// No recorded hashes.
+	err = verifyIsoFileHashes(testTempDir, additionalFiles, nil)
+	assert.NoError(t, err)
+
+	// Matching hash.
+	err = verifyIsoFileHashes(testTempDir, additionalFiles, map[string]string{"/files/a.txt": testSha256A})
+	assert.NoError(t, err)
+
+	// Mismatched hash.
+	err = verifyIsoFileHashes(testTempDir, additionalFiles, map[string]string{"/files/a.txt": testSha256B})
+	assert.ErrorContains(t, err, "file (/files/a.txt) on the input iso does not match its recorded sha256")
+
+	// Missing file.
+	err = verifyIsoFileHashes(testTempDir, additionalFiles,
+		map[string]string{"/files/a.txt": testSha256A, "/files/b.txt": testSha256B})
+	assert.ErrorContains(t, err, "files (/files/b.txt) recorded on the input iso are missing")
+
+	// Unrecorded file.
+	fileC := filepath.Join(testTempDir, "files/c.txt")
+	err = os.WriteFile(fileC, []byte("c"), 0o644)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	additionalFiles[fileC] = "/files/c.txt"
+
+	err = verifyIsoFileHashes(testTempDir, additionalFiles, map[string]string{"/files/a.txt": testSha256A})
+	assert.ErrorContains(t, err, "file (/files/c.txt) on the input iso has no recorded sha256")
+
+	// Unrecorded files are accepted when the input iso has no recorded hashes.
+	err = verifyIsoFileHashes(testTempDir, additionalFiles, nil)
+	assert.NoError(t, err)
+
+	// Files generated by isomaker are not recorded.
+	delete(additionalFiles, fileC)
+	additionalFiles[fileC] = "/boot/grub2/efiboot.img"
+
+	err = verifyIsoFileHashes(testTempDir, additionalFiles, map[string]string{"/files/a.txt": testSha256A})
+	assert.NoError(t, err)
+}
@@ -0,0 +1,92 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"slices"
+
+	"github.com/cavaliercoder/go-cpio"
+	"github.com/klauspost/pgzip"
+)
+
+const (
+	// The tool dracut's dmsquash-live module runs to verify the iso media
+	// when 'rd.live.check' is set. It is provided by the isomd5sum package.
+	mediaCheckBinary = "checkisomd5"
+)
+
+// verifyInitrdSupportsMediaCheck returns an error if the initrd image does
+// not contain the tool needed to verify the iso media on boot. Without it,
+// 'rd.live.check' is silently ignored.
+func verifyInitrdSupportsMediaCheck(initrdImagePath string) error {
+	found, err := initrdContainsBinary(initrdImagePath, mediaCheckBinary)
+	if err != nil {
+		return fmt.Errorf("failed to search initrd image (%s) for (%s):\n%w", initrdImagePath, mediaCheckBinary, err)
+	}
+
+	if !found {
+		return fmt.Errorf("mediaCheck requires (%s) in the initrd image:\n"+
+			"install the isomd5sum package in the image", mediaCheckBinary)
+	}
+
+	return nil
+}
+
+// initrdContainsBinary returns true if the (gzip compressed cpio) initrd
+// image holds an executable with the specified name under a 'bin' or 'sbin'
+// directory.
+func initrdContainsBinary(initrdImagePath string, binaryName string) (bool, error) {
+	foundBinaries, err := initrdFindBinaries(initrdImagePath, []string{binaryName})
+	if err != nil {
+		return false, err
+	}
+
+	return foundBinaries[binaryName], nil
+}
+
+// initrdFindBinaries returns which of the specified executables the (gzip
+// compressed cpio) initrd image holds under a 'bin' or 'sbin' directory. The
+// image is read only once.
+func initrdFindBinaries(initrdImagePath string, binaryNames []string) (map[string]bool, error) {
+	initrdFile, err := os.Open(initrdImagePath)
+	if err != nil {
+		return nil, err
+	}
+	defer initrdFile.Close()
+
+	gzipReader, err := pgzip.NewReader(initrdFile)
+	if err != nil {
+		return nil, err
+	}
+	defer gzipReader.Close()
+
+	cpioReader := cpio.NewReader(gzipReader)
+
+	foundBinaries := make(map[string]bool)
+	for len(foundBinaries) < len(binaryNames) {
+		hdr, err := cpioReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		dirName := path.Base(path.Dir(hdr.Name))
+		if dirName != "bin" && dirName != "sbin" {
+			continue
+		}
+
+		fileName := path.Base(hdr.Name)
+		if slices.Contains(binaryNames, fileName) {
+			foundBinaries[fileName] = true
+		}
+	}
+
+	return foundBinaries, nil
+}
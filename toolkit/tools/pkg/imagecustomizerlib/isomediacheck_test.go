@@ -0,0 +1,159 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cavaliercoder/go-cpio"
+	"github.com/klauspost/pgzip"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/file"
+	"github.com/stretchr/testify/assert"
+)
+
+const testMediaCheckGrubCfg = `set timeout=0
+menuentry "Azure Linux" {
+	search -n -u 4e1e7d2a-2d5b-4b5e-9b0e-3d4f5a6b7c8d -s
+	linux /boot/vmlinuz-6.6.47.1-1.azl3 root=/dev/sda2 ro
+	initrd /boot/initramfs-6.6.47.1-1.azl3.img
+}
+`
+
+func TestUpdateGrubCfgMediaCheck(t *testing.T) {
+	testTmpDir := filepath.Join(tmpDir, "TestUpdateGrubCfgMediaCheck")
+	defer os.RemoveAll(testTmpDir)
+
+	err := os.MkdirAll(testTmpDir, os.ModePerm)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	isoGrubCfgPath := filepath.Join(testTmpDir, "grub.cfg")
+	pxeGrubCfgPath := filepath.Join(testTmpDir, "grub-pxe.cfg")
+
+	err = file.Write(testMediaCheckGrubCfg, isoGrubCfgPath)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	isoBuilder := &LiveOSIsoBuilder{
+		mediaCheck:  true,
+		liveOSDir:   defaultLiveOSDir,
+		liveOSImage: defaultLiveOSImage,
+	}
+
+	savedConfigs := &SavedConfigs{
+		Pxe: PxeSavedConfigs{
+			IsoImageBaseUrl: "http://hostname-1/iso-publish-path",
+		},
+		OS: OSSavedConfigs{
+			DracutPackageInfo: &DracutPackageInformation{
+				PackageVersion: PxeDracutMinVersion + 1,
+				DistroName:     PxeDracutDistroName,
+				DistroVersion:  PxeDracutMinDistroVersion,
+			},
+		},
+	}
+
+	err = isoBuilder.updateGrubCfg(isoGrubCfgPath, pxeGrubCfgPath, savedConfigs, "image.iso")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	isoGrubCfg, err := file.Read(isoGrubCfgPath)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	menuEntries, err := findMenuEntries(isoGrubCfg)
+	if !assert.NoError(t, err) || !assert.Len(t, menuEntries, 2) {
+		return
+	}
+
+	mainMenuEntry := isoGrubCfg[menuEntries[0].Start:menuEntries[0].End]
+	mediaCheckMenuEntry := isoGrubCfg[menuEntries[1].Start:menuEntries[1].End]
+
+	assert.Equal(t, mediaCheckMenuEntryId, menuEntries[1].Id)
+	assert.NotContains(t, mainMenuEntry, mediaCheckKernelArgs)
+	assert.Contains(t, mediaCheckMenuEntry, mediaCheckKernelArgs)
+
+	pxeGrubCfg, err := file.Read(pxeGrubCfgPath)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.NotContains(t, pxeGrubCfg, mediaCheckMenuEntryId)
+	assert.NotContains(t, pxeGrubCfg, mediaCheckKernelArgs)
+}
+
+func TestVerifyInitrdSupportsMediaCheck(t *testing.T) {
+	testTmpDir := filepath.Join(tmpDir, "TestVerifyInitrdSupportsMediaCheck")
+	defer os.RemoveAll(testTmpDir)
+
+	err := os.MkdirAll(testTmpDir, os.ModePerm)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	initrdPath := filepath.Join(testTmpDir, "initrd.img")
+	err = createTestInitrd(initrdPath, []string{"usr/lib/dracut/modules.txt", "usr/bin/checkisomd5"})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	err = verifyInitrdSupportsMediaCheck(initrdPath)
+	assert.NoError(t, err)
+}
+
+func TestVerifyInitrdSupportsMediaCheckMissing(t *testing.T) {
+	testTmpDir := filepath.Join(tmpDir, "TestVerifyInitrdSupportsMediaCheckMissing")
+	defer os.RemoveAll(testTmpDir)
+
+	err := os.MkdirAll(testTmpDir, os.ModePerm)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	initrdPath := filepath.Join(testTmpDir, "initrd.img")
+	err = createTestInitrd(initrdPath, []string{"usr/lib/dracut/modules.txt", "usr/share/doc/checkisomd5"})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	err = verifyInitrdSupportsMediaCheck(initrdPath)
+	assert.ErrorContains(t, err, "mediaCheck requires (checkisomd5) in the initrd image")
+}
+
+// createTestInitrd writes a gzip compressed cpio archive that holds an
+// (empty) regular file for each of the specified paths.
+func createTestInitrd(initrdPath string, filePaths []string) error {
+	initrdFile, err := os.Create(initrdPath)
+	if err != nil {
+		return err
+	}
+	defer initrdFile.Close()
+
+	gzipWriter := pgzip.NewWriter(initrdFile)
+	cpioWriter := cpio.NewWriter(gzipWriter)
+
+	for _, filePath := range filePaths {
+		err = cpioWriter.WriteHeader(&cpio.Header{
+			Name: strings.TrimPrefix(filePath, "/"),
+			Mode: cpio.ModeRegular | 0o755,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	err = cpioWriter.Close()
+	if err != nil {
+		return err
+	}
+
+	return gzipWriter.Close()
+}
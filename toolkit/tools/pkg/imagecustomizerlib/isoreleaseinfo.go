@@ -0,0 +1,55 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/imagecustomizerapi"
+)
+
+const (
+	// The environment variables that override the release version and the tag of the iso (e.g. to let a CI pipeline
+	// stamp its builds without editing the config).
+	isoReleaseVersionEnvVar = "IMAGE_CUSTOMIZER_ISO_RELEASE_VERSION"
+	isoTagEnvVar            = "IMAGE_CUSTOMIZER_ISO_TAG"
+)
+
+// isoReleaseInfo identifies a release of an iso. It is added to the file name of the iso and to the OS's release file.
+type isoReleaseInfo struct {
+	releaseVersion string
+	tag            string
+}
+
+// getIsoReleaseInfo returns the release version and the tag of the iso, from the iso configuration or from the
+// environment variables that override it.
+func getIsoReleaseInfo(isoConfig *imagecustomizerapi.Iso) (isoReleaseInfo, error) {
+	var info isoReleaseInfo
+	if isoConfig != nil {
+		info.releaseVersion = isoConfig.ReleaseVersion
+		info.tag = isoConfig.Tag
+	}
+
+	releaseVersion, found := os.LookupEnv(isoReleaseVersionEnvVar)
+	if found {
+		err := imagecustomizerapi.IsValidIsoImageNameElement(releaseVersion)
+		if err != nil {
+			return isoReleaseInfo{}, fmt.Errorf("invalid %s environment variable value:\n%w", isoReleaseVersionEnvVar,
+				err)
+		}
+		info.releaseVersion = releaseVersion
+	}
+
+	tag, found := os.LookupEnv(isoTagEnvVar)
+	if found {
+		err := imagecustomizerapi.IsValidIsoImageNameElement(tag)
+		if err != nil {
+			return isoReleaseInfo{}, fmt.Errorf("invalid %s environment variable value:\n%w", isoTagEnvVar, err)
+		}
+		info.tag = tag
+	}
+
+	return info, nil
+}
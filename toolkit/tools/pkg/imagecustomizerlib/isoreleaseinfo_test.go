@@ -0,0 +1,44 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"testing"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/imagecustomizerapi"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetIsoReleaseInfo(t *testing.T) {
+	isoConfig := &imagecustomizerapi.Iso{
+		ReleaseVersion: "3.0.20240601",
+		Tag:            "livecd",
+	}
+
+	info, err := getIsoReleaseInfo(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, isoReleaseInfo{}, info)
+
+	info, err = getIsoReleaseInfo(isoConfig)
+	assert.NoError(t, err)
+	assert.Equal(t, isoReleaseInfo{releaseVersion: "3.0.20240601", tag: "livecd"}, info)
+
+	t.Setenv(isoReleaseVersionEnvVar, "3.0.20240701")
+	info, err = getIsoReleaseInfo(isoConfig)
+	assert.NoError(t, err)
+	assert.Equal(t, isoReleaseInfo{releaseVersion: "3.0.20240701", tag: "livecd"}, info)
+
+	t.Setenv(isoTagEnvVar, "live cd")
+	_, err = getIsoReleaseInfo(isoConfig)
+	assert.ErrorContains(t, err, "invalid IMAGE_CUSTOMIZER_ISO_TAG environment variable value")
+}
+
+func TestGetImageNameFromImageBaseName(t *testing.T) {
+	assert.Equal(t, "azl.iso", getImageNameFromImageBaseName("azl", isoReleaseInfo{}).name)
+	assert.Equal(t, "azl-3.0.20240601.iso",
+		getImageNameFromImageBaseName("azl", isoReleaseInfo{releaseVersion: "3.0.20240601"}).name)
+	assert.Equal(t, "azl-livecd.iso", getImageNameFromImageBaseName("azl", isoReleaseInfo{tag: "livecd"}).name)
+	assert.Equal(t, "azl-3.0.20240601-livecd.iso",
+		getImageNameFromImageBaseName("azl", isoReleaseInfo{releaseVersion: "3.0.20240601", tag: "livecd"}).name)
+}
@@ -0,0 +1,246 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/imagecustomizerapi"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/safechroot"
+)
+
+const (
+	// the number of rootfs directories to suggest for removal when the iso is
+	// too large.
+	maxIsoSizeSuggestedDirs = 5
+)
+
+// isoSizeContributor is a group of files on the iso media.
+type isoSizeContributor struct {
+	name string
+	size uint64
+	// how to reduce the size of the group. Empty if there isn't a config
+	// option that affects it.
+	suggestion string
+}
+
+// checkIsoSizeBudget
+//
+//   - fails if the iso image is larger than the maximum size specified in the
+//     iso configuration.
+//   - the error breaks down the size of the iso by the groups of files placed
+//     on it, and suggests how to reduce the size of the largest groups.
+//   - the oversized iso image is deleted.
+//
+// inputs:
+//
+//   - 'isoImagePath':
+//     path to the generated iso image.
+//   - 'configAdditionalFiles':
+//     the additional files requested by the iso configuration.
+func (b *LiveOSIsoBuilder) checkIsoSizeBudget(isoImagePath string, configAdditionalFiles []safechroot.FileToCopy) error {
+	isoImageInfo, err := os.Stat(isoImagePath)
+	if err != nil {
+		return fmt.Errorf("failed to stat iso image (%s):\n%w", isoImagePath, err)
+	}
+
+	isoSize := uint64(isoImageInfo.Size())
+	if isoSize <= b.maxIsoSize {
+		return nil
+	}
+
+	contributors, err := b.getIsoSizeContributors(isoSize, configAdditionalFiles)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(isoImagePath)
+	if err != nil {
+		return fmt.Errorf("failed to remove oversized iso image (%s):\n%w", isoImagePath, err)
+	}
+
+	return fmt.Errorf("iso image size (%s) exceeds iso maxSize (%s) by (%s):\n"+
+		"iso size breakdown:\n%s\n"+
+		"suggestions:\n%s",
+		humanReadableDiskSize(int64(isoSize)), imagecustomizerapi.DiskSize(b.maxIsoSize).HumanReadable(),
+		humanReadableDiskSize(int64(isoSize-b.maxIsoSize)), isoSizeBreakdown(contributors),
+		isoSizeSuggestions(contributors))
+}
+
+// getIsoSizeContributors
+//
+//   - returns the groups of files placed on the iso media by the builder, with
+//     their sizes, sorted by size in descending order.
+//
+// inputs:
+//
+//   - 'isoSize':
+//     the size of the iso image. The part that is not accounted for by the
+//     files is reported as the iso file system overhead.
+//   - 'configAdditionalFiles':
+//     the additional files requested by the iso configuration.
+func (b *LiveOSIsoBuilder) getIsoSizeContributors(isoSize uint64, configAdditionalFiles []safechroot.FileToCopy,
+) ([]isoSizeContributor, error) {
+	squashfsImageSize, err := getFileSize(b.artifacts.squashfsImagePath)
+	if err != nil {
+		return nil, err
+	}
+
+	rootfsSuggestion := "remove unneeded packages (os.packages.remove) or files (e.g. using scripts) from the OS"
+	if b.artifacts.rootfsSizeInfo != nil {
+		largestDirs := b.artifacts.rootfsSizeInfo.topLevelBreakdown(maxIsoSizeSuggestedDirs)
+		rootfsSuggestion += fmt.Sprintf("; the largest OS directories (before compression) are:\n  %s",
+			strings.ReplaceAll(largestDirs, "\n", "\n  "))
+	}
+
+	additionalRootfsImagesSize := uint64(0)
+	for _, rootfsImage := range b.artifacts.additionalRootfsImages {
+		if rootfsImage.squashfsImagePath == "" {
+			// slot B of an A/B update iso starts as a copy of slot A.
+			additionalRootfsImagesSize += squashfsImageSize
+			continue
+		}
+
+		size, err := getFileSize(rootfsImage.squashfsImagePath)
+		if err != nil {
+			return nil, err
+		}
+		additionalRootfsImagesSize += size
+	}
+
+	initrdImageSize, err := getFileSize(b.artifacts.initrdImagePath)
+	if err != nil {
+		return nil, err
+	}
+
+	configAdditionalFilesSize := uint64(0)
+	for _, additionalFile := range configAdditionalFiles {
+		if additionalFile.Content != nil {
+			configAdditionalFilesSize += uint64(len(*additionalFile.Content))
+			continue
+		}
+
+		// The additional files are copied with their symbolic links followed.
+		fileInfo, err := os.Stat(additionalFile.Src)
+		if err != nil {
+			return nil, fmt.Errorf("failed to stat (%s):\n%w", additionalFile.Src, err)
+		}
+		configAdditionalFilesSize += uint64(fileInfo.Size())
+	}
+
+	carriedOverFilesSize := uint64(0)
+	bootFilesSize := uint64(0)
+	for sourceFile := range b.artifacts.additionalFiles {
+		size, err := getFileSize(sourceFile)
+		if err != nil {
+			return nil, err
+		}
+
+		if b.artifacts.carriedOverFiles[sourceFile] {
+			carriedOverFilesSize += size
+		} else {
+			bootFilesSize += size
+		}
+	}
+
+	contributors := []isoSizeContributor{
+		{
+			name:       fmt.Sprintf("rootfs image (%s)", b.liveOSImage),
+			size:       squashfsImageSize,
+			suggestion: rootfsSuggestion,
+		},
+		{
+			name: "additional rootfs images (iso.additionalRootfsImages and iso.abUpdate)",
+			size: additionalRootfsImagesSize,
+			suggestion: "remove unneeded iso.additionalRootfsImages, or iso.abUpdate (which doubles the space used by " +
+				"the OS)",
+		},
+		{
+			name: "initrd image",
+			size: initrdImageSize,
+		},
+		{
+			name: "additional files (iso.additionalFiles)",
+			size: configAdditionalFilesSize,
+			suggestion: "remove large files from iso.additionalFiles, or move them into the OS (os.additionalFiles) " +
+				"where they are compressed",
+		},
+		{
+			name: "files carried over from the input iso",
+			size: carriedOverFilesSize,
+			suggestion: "customize the original (non-iso) image instead, since the files of the input iso " +
+				"(e.g. its iso.additionalFiles) are carried over to the output iso",
+		},
+		{
+			name: "boot files (bootloaders, kernel and grub configuration)",
+			size: bootFilesSize,
+		},
+	}
+
+	filesSize := uint64(0)
+	for _, contributor := range contributors {
+		filesSize += contributor.size
+	}
+
+	if isoSize > filesSize {
+		contributors = append(contributors, isoSizeContributor{
+			name: "iso file system and boot images",
+			size: isoSize - filesSize,
+		})
+	}
+
+	sort.SliceStable(contributors, func(i, j int) bool {
+		return contributors[i].size > contributors[j].size
+	})
+
+	return contributors, nil
+}
+
+// isoSizeBreakdown returns a human readable list of the (non-empty) groups of
+// files on the iso media.
+func isoSizeBreakdown(contributors []isoSizeContributor) string {
+	lines := []string(nil)
+	for _, contributor := range contributors {
+		if contributor.size == 0 {
+			continue
+		}
+
+		lines = append(lines, fmt.Sprintf("  %s: %s", contributor.name, humanReadableDiskSize(int64(contributor.size))))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// isoSizeSuggestions returns a human readable list of the ways to reduce the
+// size of the (non-empty) groups of files on the iso media, largest group
+// first.
+func isoSizeSuggestions(contributors []isoSizeContributor) string {
+	lines := []string(nil)
+	for _, contributor := range contributors {
+		if contributor.size == 0 || contributor.suggestion == "" {
+			continue
+		}
+
+		lines = append(lines, fmt.Sprintf("  - %s: %s", contributor.name, contributor.suggestion))
+	}
+
+	if len(lines) == 0 {
+		return "  - increase iso maxSize"
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// getFileSize returns the size of a file. Symbolic links are not followed.
+func getFileSize(path string) (uint64, error) {
+	fileInfo, err := os.Lstat(path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to stat (%s):\n%w", path, err)
+	}
+
+	return uint64(fileInfo.Size()), nil
+}
@@ -0,0 +1,99 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/imagegen/diskutils"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/file"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/safechroot"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckIsoSizeBudget(t *testing.T) {
+	testTmpDir := filepath.Join(tmpDir, "TestCheckIsoSizeBudget")
+	defer os.RemoveAll(testTmpDir)
+
+	err := os.MkdirAll(testTmpDir, os.ModePerm)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	files := map[string]int64{
+		"rootfs.img":      3 * diskutils.MiB,
+		"initrd.img":      1 * diskutils.MiB,
+		"grubx64.efi":     2 * diskutils.KiB,
+		"input-file.bin":  512 * diskutils.KiB,
+		"config-file.bin": 2 * diskutils.MiB,
+		"image.iso":       7 * diskutils.MiB,
+	}
+	for name, size := range files {
+		err = file.Write("", filepath.Join(testTmpDir, name))
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		err = os.Truncate(filepath.Join(testTmpDir, name), size)
+		if !assert.NoError(t, err) {
+			return
+		}
+	}
+
+	isoImagePath := filepath.Join(testTmpDir, "image.iso")
+	b := &LiveOSIsoBuilder{
+		artifacts: IsoArtifacts{
+			squashfsImagePath: filepath.Join(testTmpDir, "rootfs.img"),
+			initrdImagePath:   filepath.Join(testTmpDir, "initrd.img"),
+			additionalFiles: map[string]string{
+				filepath.Join(testTmpDir, "grubx64.efi"):    "/efi/boot/grubx64.efi",
+				filepath.Join(testTmpDir, "input-file.bin"): "/input-file.bin",
+			},
+			carriedOverFiles: map[string]bool{
+				filepath.Join(testTmpDir, "input-file.bin"): true,
+			},
+		},
+		liveOSImage: defaultLiveOSImage,
+		maxIsoSize:  8 * diskutils.MiB,
+	}
+
+	configAdditionalFiles := []safechroot.FileToCopy{
+		{Src: filepath.Join(testTmpDir, "config-file.bin"), Dest: "/config-file.bin"},
+	}
+
+	// Within budget.
+	err = b.checkIsoSizeBudget(isoImagePath, configAdditionalFiles)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.FileExists(t, isoImagePath)
+
+	// Over budget.
+	b.maxIsoSize = 6 * diskutils.MiB
+
+	err = b.checkIsoSizeBudget(isoImagePath, configAdditionalFiles)
+	assert.ErrorContains(t, err, "iso image size (7 MiB) exceeds iso maxSize (6 MiB) by (1 MiB)")
+	assert.ErrorContains(t, err, "iso size breakdown:\n"+
+		"  rootfs image (rootfs.img): 3 MiB\n"+
+		"  additional files (iso.additionalFiles): 2 MiB\n"+
+		"  initrd image: 1 MiB\n"+
+		"  files carried over from the input iso: 512 KiB\n"+
+		"  iso file system and boot images: 510 KiB\n"+
+		"  boot files (bootloaders, kernel and grub configuration): 2 KiB\n"+
+		"suggestions:\n")
+	assert.ErrorContains(t, err, "  - rootfs image (rootfs.img): remove unneeded packages")
+	assert.ErrorContains(t, err, "  - files carried over from the input iso: customize the original")
+
+	// The suggestions follow the breakdown's order.
+	if err != nil {
+		assert.Less(t, strings.Index(err.Error(), "- additional files"),
+			strings.Index(err.Error(), "- files carried over"))
+	}
+
+	assert.NoFileExists(t, isoImagePath)
+}
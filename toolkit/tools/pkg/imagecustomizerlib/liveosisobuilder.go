@@ -4,6 +4,7 @@
 package imagecustomizerlib
 
 import (
+	"context"
 	"fmt"
 	"io/fs"
 	"net/url"
@@ -12,6 +13,7 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/microsoft/azurelinux/toolkit/tools/imagecustomizerapi"
 	"github.com/microsoft/azurelinux/toolkit/tools/imagegen/configuration"
@@ -23,6 +25,7 @@ import (
 	"github.com/microsoft/azurelinux/toolkit/tools/internal/safemount"
 	"github.com/microsoft/azurelinux/toolkit/tools/internal/shell"
 	"github.com/microsoft/azurelinux/toolkit/tools/pkg/isomakerlib"
+	"github.com/sirupsen/logrus"
 	"golang.org/x/sys/unix"
 )
 
@@ -51,8 +54,18 @@ const (
 	// kernel arguments template
 	kernelArgsLiveOSTemplate = " rd.shell rd.live.image rd.live.dir=%s rd.live.squashimg=%s rd.live.overlay=1 rd.live.overlay.overlayfs rd.live.overlay.nouserconfirmprompt "
 
-	liveOSDir   = "liveos"
-	liveOSImage = "rootfs.img"
+	defaultLiveOSDir   = imagecustomizerapi.IsoDefaultLiveOSDir
+	defaultLiveOSImage = imagecustomizerapi.IsoDefaultRootfsImageName
+
+	// prefix of the ids of the grub menu entries that boot into the
+	// additional rootfs images.
+	additionalRootfsMenuEntryIdPrefix = "azl-liveos-"
+
+	// the grub menu entry that verifies the iso media (using the checksum
+	// implanted by implantisomd5) before booting.
+	mediaCheckMenuEntryId    = "azl-media-check"
+	mediaCheckMenuEntryTitle = "Verify media and boot"
+	mediaCheckKernelArgs     = "rd.live.check"
 
 	// location on output iso where some of the input mic configuration will be
 	// saved for future iso-to-iso customizations.
@@ -62,14 +75,43 @@ const (
 	// customizations.
 	savedConfigsFileName = "saved-configs.yaml"
 
+	// the dracut configuration file added to the rootfs of a LiveOS iso.
+	liveOSDracutConfigFile = "/etc/dracut.conf.d/20-live-cd.conf"
+
 	dracutConfig = `add_dracutmodules+=" dmsquash-live livenet "
 add_drivers+=" overlay "
 hostonly="no"
 `
-	// the total size of a collection of files is multiplied by the
-	// expansionSafetyFactor to estimate a disk size sufficient to hold those
-	// files.
-	expansionSafetyFactor = 1.5
+	// the size of each inode of an ext4 file system and the number of bytes
+	// of file system per inode (see mke2fs.conf defaults). Together, they
+	// determine the space taken by the inode tables and the maximum number of
+	// files the file system can hold.
+	extInodeSize  = 256
+	extInodeRatio = 16384
+	// number of inodes ext4 reserves for its own use.
+	extReservedInodes = 11
+	// the fraction of an ext4 file system taken by metadata other than the
+	// inode tables and the journal (i.e. block/inode bitmaps, group
+	// descriptors, reserved GDT blocks, and extent tree blocks).
+	extMetadataOverheadFraction = 0.04
+
+	// the end of the boot partition of the writeable image created from a
+	// squashfs, and the space reserved at the end of that image for the
+	// backup GPT header.
+	writeableImageBootPartitionEnd = 9 * diskutils.MiB
+	writeableImageGptFooterSize    = 1 * diskutils.MiB
+	// if copying the squashfs contents runs out of space, the writeable image
+	// is re-created with its size grown by writeableImageGrowthFraction, up to
+	// writeableImageMaxAttempts times in total.
+	writeableImageGrowthFraction = 0.25
+	writeableImageMaxAttempts    = 3
+	// the copy is considered to have failed for lack of space if the free
+	// space left on the rootfs partition is below this threshold.
+	writeableImageFullThreshold = 1 * diskutils.MiB
+
+	// upper bound estimate of the size of the generated initrd image. It is
+	// used only when checking the free disk space before the build starts.
+	initrdImageSizeEstimate = 256 * diskutils.MiB
 )
 
 type IsoWorkingDirs struct {
@@ -98,31 +140,117 @@ type IsoArtifacts struct {
 	initrdImagePath      string
 	squashfsImagePath    string
 	additionalFiles      map[string]string // local-build-path -> iso-media-path
+	// the subset of additionalFiles (local-build-path) that come from the input iso.
+	carriedOverFiles map[string]bool
+	// additional (pre-built) rootfs images to place next to squashfsImagePath.
+	additionalRootfsImages []isoRootfsImage
+	// the size of the rootfs that squashfsImagePath was created from, if known.
+	rootfsSizeInfo *dirSizeInfo
+	// the additional files to place on the EFI system partition (efiboot.img).
+	efiBootFiles []safechroot.FileToCopy
+}
+
+// `isoRootfsImage` holds an additional rootfs image to place on the iso media
+// along with the grub menu entry that boots into it.
+type isoRootfsImage struct {
+	squashfsImagePath string
+	name              string
+	title             string
+	extraCommandLine  imagecustomizerapi.KernelExtraArguments
 }
 
 type LiveOSIsoBuilder struct {
 	workingDirs IsoWorkingDirs
 	artifacts   IsoArtifacts
-	cleanupDirs []string
+	// the resources (e.g. working folders) that live as long as the builder.
+	cleanups cleanupManager
+	// if true, a checksum is implanted into the iso and a grub menu entry
+	// that verifies the media is added.
+	mediaCheck bool
+	// the directory (on the iso media) that holds the rootfs images, and the
+	// file name of the rootfs image of the customized OS.
+	liveOSDir   string
+	liveOSImage string
+	// the resource limits of the resource intensive build steps.
+	buildResources BuildResources
+	// if set, the iso is reproducible and this timestamp is used instead of
+	// the current time.
+	sourceDateEpoch *time.Time
+	// the maximum size of the iso image, in bytes. 0 means no limit.
+	maxIsoSize uint64
+	// the version (or glob pattern) of the kernel that boots the iso. Only
+	// needed when more than one kernel is installed.
+	kernelVersionPattern string
+	// the files from the OS to place on the EFI system partition of the iso
+	// media.
+	bootloaderFiles imagecustomizerapi.IsoBootloaderFileList
+	// the chain of UEFI bootloaders that boots the iso.
+	bootBackend imagecustomizerapi.IsoBootBackend
+	// the release version and the tag added to the iso's file name.
+	releaseInfo isoReleaseInfo
+	// the format of the archive of the PXE artifacts. Empty if no archive is
+	// requested.
+	pxeArtifactsArchive imagecustomizerapi.PxeArtifactsArchiveFormat
+	// the iPXE boot script to generate in the PXE artifacts folder. nil if
+	// no script is requested.
+	pxeIpxeScript *imagecustomizerapi.PxeIpxeScript
+	// whether to generate a PXELINUX configuration in the PXE artifacts
+	// folder.
+	pxelinuxConfig bool
+	// whether to prepare the PXE artifacts folder for UEFI HTTP boot.
+	pxeHttpBoot bool
+	// the layout of the files in the PXE artifacts folder.
+	pxeArtifactsLayout imagecustomizerapi.PxeArtifactsLayout
+	// the image that dracut's livenet module downloads when PXE booting.
+	pxeBootImage imagecustomizerapi.PxeBootImage
+	// the per-host (or per-class) kernel arguments for PXE booting.
+	pxeHostOverrides []imagecustomizerapi.PxeHostOverride
+	// the answer files to host in the PXE artifacts folder.
+	pxeAnswerFiles []pxeAnswerFile
+	// the URL of the PXE artifacts folder on the server (i.e. the
+	// isoImageBaseUrl of this run). Used to reference the answer files.
+	pxeArtifactsBaseUrl string
+	// how the initrd image authenticates to the server when it downloads the
+	// boot image. nil if anonymous.
+	pxeDownload *pxeDownload
 }
 
-func (b *LiveOSIsoBuilder) addCleanupDir(dirName string) {
-	b.cleanupDirs = append(b.cleanupDirs, dirName)
+// newIsoWorkingDirs
+//
+//	creates a uniquely named working folder for a LiveOSIsoBuilder under
+//	the build folder. This way, the input iso builder and the output iso
+//	builder of the same build (and IsoMaker runs from different builders)
+//	never share intermediate files.
+//
+// inputs:
+//   - 'buildDir':
+//     path build directory (can be shared with other tools).
+//
+// outputs:
+//
+//	buildDir (might be shared with other build tools)
+//	 |--tmp-iso-<random>   (LiveOSIsoBuilder specific)
+//	    |--<various mount points>
+//	    |--artifacts        (extracted and generated artifacts)
+//	    |--isomaker-tmp     (used exclusively by isomaker)
+func newIsoWorkingDirs(buildDir string) (IsoWorkingDirs, error) {
+	isoBuildDir, err := os.MkdirTemp(buildDir, "tmp-iso-")
+	if err != nil {
+		return IsoWorkingDirs{}, fmt.Errorf("failed to create iso build folder under (%s):\n%w", buildDir, err)
+	}
+
+	workingDirs := IsoWorkingDirs{
+		isoBuildDir:     isoBuildDir,
+		isoArtifactsDir: filepath.Join(isoBuildDir, "artifacts"),
+		// IsoMaker needs its own folder to work in (it starts by deleting and re-creating it).
+		isomakerBuildDir: filepath.Join(isoBuildDir, "isomaker-tmp"),
+	}
+	return workingDirs, nil
 }
 
+// cleanUp tears down all the resources that are owned by the builder. It is safe to call it multiple times.
 func (b *LiveOSIsoBuilder) cleanUp() error {
-	var err error
-	for i := len(b.cleanupDirs) - 1; i >= 0; i-- {
-		cleanupErr := os.RemoveAll(b.cleanupDirs[i])
-		if cleanupErr != nil {
-			if err != nil {
-				err = fmt.Errorf("%w:\nfailed to remove (%s): %w", err, b.cleanupDirs[i], cleanupErr)
-			} else {
-				err = fmt.Errorf("failed to clean-up (%s): %w", b.cleanupDirs[i], cleanupErr)
-			}
-		}
-	}
-	return err
+	return b.cleanups.run()
 }
 
 type isoImageNameInfo struct {
@@ -132,14 +260,24 @@ type isoImageNameInfo struct {
 	name           string // derived from the other fields.
 }
 
-func getImageNameFromImageBaseName(isoOutputBaseName string) isoImageNameInfo {
+func getImageNameFromImageBaseName(isoOutputBaseName string, releaseInfo isoReleaseInfo) isoImageNameInfo {
 	// isoMaker constructs the final image name as follows:
-	// {isoOutputBaseName}{releaseVersion}{imageNameTag}.iso
+	// {isoOutputBaseName}-{releaseVersion}{imageNameTag}.iso
+	// where the '-' is omitted if both releaseVersion and imageNameTag are
+	// empty.
 	var info isoImageNameInfo
 	info.baseName = isoOutputBaseName
-	info.releaseVersion = ""
-	info.tag = ""
-	info.name = info.baseName + info.releaseVersion + info.tag + ".iso"
+	info.releaseVersion = releaseInfo.releaseVersion
+	info.tag = releaseInfo.tag
+	if info.releaseVersion != "" && info.tag != "" {
+		info.tag = "-" + info.tag
+	}
+
+	suffix := ""
+	if info.releaseVersion != "" || info.tag != "" {
+		suffix = "-" + info.releaseVersion + info.tag
+	}
+	info.name = info.baseName + suffix + ".iso"
 	return info
 }
 
@@ -156,7 +294,7 @@ func getImageNameFromImageBaseName(isoOutputBaseName string) isoImageNameInfo {
 //
 // output:
 //   - writeableRootfsDir will hold the contents of sourceDir.
-func (b *LiveOSIsoBuilder) populateWriteableRootfsDir(sourceDir, writeableRootfsDir string) error {
+func (b *LiveOSIsoBuilder) populateWriteableRootfsDir(ctx context.Context, sourceDir, writeableRootfsDir string) error {
 
 	logger.Log.Debugf("Creating writeable rootfs")
 
@@ -165,7 +303,7 @@ func (b *LiveOSIsoBuilder) populateWriteableRootfsDir(sourceDir, writeableRootfs
 		return fmt.Errorf("failed to create folder %s:\n%w", writeableRootfsDir, err)
 	}
 
-	err = copyPartitionFiles(sourceDir+"/.", writeableRootfsDir)
+	err = copyPartitionFiles(ctx, sourceDir+"/.", writeableRootfsDir, b.buildResources)
 	if err != nil {
 		return fmt.Errorf("failed to copy rootfs contents to a writeable folder (%s):\n%w", writeableRootfsDir, err)
 	}
@@ -173,66 +311,6 @@ func (b *LiveOSIsoBuilder) populateWriteableRootfsDir(sourceDir, writeableRootfs
 	return nil
 }
 
-// stageIsoMakerInitrdArtifacts
-//
-//	IsoMaker looks for the vmlinuz/bootloader files inside the initrd image
-//	file under specific directory structure.
-//	This function stages those artifacts and places them under the same
-//	directory structure expected by IsoMaker.
-//	Later,  we run 'dracut' which takes this directory structure and embeds
-//	it into the initrd image.
-//	Finaly, the IsoMaker will read the initrd image and find the artifacts
-//	it needs to copy to the final iso media.
-//	Something to consider in the future: change IsoMaker so that it can pick
-//	those artifacts from the build machine directly.
-//
-// inputs:
-//   - 'writeableRootfsDir':
-//     path to an existing folder holding the contents of the rootfs.
-//   - 'isoMakerArtifactsStagingDir'
-//     path to a folder where the extracted artifacts will stored under.
-//
-// outputs:
-//
-//	the artifacts will be stored in 'isoMakerArtifactsStagingDir'.
-func (b *LiveOSIsoBuilder) stageIsoMakerInitrdArtifacts(writeableRootfsDir, isoMakerArtifactsStagingDir string) error {
-
-	logger.Log.Debugf("Staging isomaker artifacts into writeable image")
-
-	targetBootloadersInChroot := filepath.Join(isoMakerArtifactsStagingDir, "/efi/EFI/BOOT")
-	targetBootloadersDir := filepath.Join(writeableRootfsDir, targetBootloadersInChroot)
-
-	err := os.MkdirAll(targetBootloadersDir, os.ModePerm)
-	if err != nil {
-		return fmt.Errorf("failed to create %s\n%w", targetBootloadersDir, err)
-	}
-
-	sourceBoot64EfiPath := b.artifacts.bootx64EfiPath
-	targetBoot64EfiPath := filepath.Join(targetBootloadersDir, bootx64Binary)
-	err = file.Copy(sourceBoot64EfiPath, targetBoot64EfiPath)
-	if err != nil {
-		return fmt.Errorf("failed to stage bootloader file (bootx64.efi):\n%w", err)
-	}
-
-	sourceGrub64EfiPath := b.artifacts.grubx64EfiPath
-	targetGrub64EfiPath := filepath.Join(targetBootloadersDir, grubx64Binary)
-	err = file.Copy(sourceGrub64EfiPath, targetGrub64EfiPath)
-	if err != nil {
-		return fmt.Errorf("failed to stage bootloader file (grubx64.efi):\n%w", err)
-	}
-
-	targetVmlinuzLocalDir := filepath.Join(writeableRootfsDir, isoMakerArtifactsStagingDir)
-
-	sourceVmlinuzPath := b.artifacts.vmlinuzPath
-	targetVmlinuzPath := filepath.Join(targetVmlinuzLocalDir, "vmlinuz")
-	err = file.Copy(sourceVmlinuzPath, targetVmlinuzPath)
-	if err != nil {
-		return fmt.Errorf("failed to stage vmlinuz:\n%w", err)
-	}
-
-	return nil
-}
-
 // prepareRootfsForDracut
 //
 //	ensures two things:
@@ -260,7 +338,7 @@ func (b *LiveOSIsoBuilder) prepareRootfsForDracut(writeableRootfsDir string) err
 		return fmt.Errorf("failed to delete fstab:\n%w", err)
 	}
 
-	targetConfigFile := filepath.Join(writeableRootfsDir, "/etc/dracut.conf.d/20-live-cd.conf")
+	targetConfigFile := filepath.Join(writeableRootfsDir, liveOSDracutConfigFile)
 	err = file.Write(dracutConfig, targetConfigFile)
 	if err != nil {
 		return fmt.Errorf("failed to create %s:\n%w", targetConfigFile, err)
@@ -292,17 +370,22 @@ func (b *LiveOSIsoBuilder) prepareRootfsForDracut(writeableRootfsDir string) err
 //     kernel argument specified by the user in this run.
 //   - newPxeIsoImageUrl:
 //     PXE ISO image URL specified by the user in this run.
+//   - newPxeBootImage:
+//     PXE boot image specified by the user in this run.
 //   - newOSDracutVersion:
 //     Dracut package version of the rootfs provided by the user.
 //
 // outputs:
 // - returns a SavedConfigs objects with the new merged values.
 func updateSavedConfigs(savedConfigsFilePath string, newKernelArgs imagecustomizerapi.KernelExtraArguments,
-	newPxeIsoImageBaseUrl string, newPxeIsoImageFileUrl string, newDracutPackageInfo *DracutPackageInformation) (updatedSavedConfigs *SavedConfigs, err error) {
+	newPxeIsoImageBaseUrl string, newPxeIsoImageFileUrl string, newPxeBootImage imagecustomizerapi.PxeBootImage,
+	newDracutPackageInfo *DracutPackageInformation,
+) (updatedSavedConfigs *SavedConfigs, err error) {
 	updatedSavedConfigs = &SavedConfigs{}
 	updatedSavedConfigs.Iso.KernelCommandLine.ExtraCommandLine = newKernelArgs
 	updatedSavedConfigs.Pxe.IsoImageBaseUrl = newPxeIsoImageBaseUrl
 	updatedSavedConfigs.Pxe.IsoImageFileUrl = newPxeIsoImageFileUrl
+	updatedSavedConfigs.Pxe.BootImage = newPxeBootImage
 	updatedSavedConfigs.OS.DracutPackageInfo = newDracutPackageInfo
 
 	savedConfigs, err := loadSavedConfigs(savedConfigsFilePath)
@@ -339,6 +422,10 @@ func updateSavedConfigs(savedConfigsFilePath string, newKernelArgs imagecustomiz
 			updatedSavedConfigs.Pxe.IsoImageBaseUrl = ""
 		}
 
+		if newPxeBootImage == imagecustomizerapi.PxeBootImageDefault {
+			updatedSavedConfigs.Pxe.BootImage = savedConfigs.Pxe.BootImage
+		}
+
 		// newOSDracutVersion can be nil if the input is an ISO and the
 		// configuration does not specify OS changes.
 		// In such cases, the rootfs is intentionally not expanded (to save
@@ -372,6 +459,18 @@ func (b *LiveOSIsoBuilder) updateGrubCfg(isoGrubCfgFileName string, pxeGrubCfgFi
 		return fmt.Errorf("failed to update the search command in the iso grub.cfg:\n%w", err)
 	}
 
+	// Remove the menu entries of the additional rootfs images that might have
+	// been carried over from an input iso. They are re-created below.
+	inputContentString, err = removeMenuEntriesWithIdPrefix(inputContentString, additionalRootfsMenuEntryIdPrefix)
+	if err != nil {
+		return fmt.Errorf("failed to remove the additional rootfs menu entries from the iso grub.cfg:\n%w", err)
+	}
+
+	inputContentString, err = removeMenuEntriesWithIdPrefix(inputContentString, mediaCheckMenuEntryId)
+	if err != nil {
+		return fmt.Errorf("failed to remove the media check menu entry from the iso grub.cfg:\n%w", err)
+	}
+
 	grubMkconfigEnabled := isGrubMkconfigConfig(inputContentString)
 	if !grubMkconfigEnabled {
 		var oldLinuxPath string
@@ -419,7 +518,7 @@ func (b *LiveOSIsoBuilder) updateGrubCfg(isoGrubCfgFileName string, pxeGrubCfgFi
 		return fmt.Errorf("failed to set SELinux mode:\n%w", err)
 	}
 
-	liveosKernelArgs := fmt.Sprintf(kernelArgsLiveOSTemplate, liveOSDir, liveOSImage)
+	liveosKernelArgs := fmt.Sprintf(kernelArgsLiveOSTemplate, b.liveOSDir, b.liveOSImage)
 	additionalKernelCommandline := liveosKernelArgs + " " + string(savedConfigs.Iso.KernelCommandLine.ExtraCommandLine)
 
 	inputContentString, err = appendKernelCommandLineArgsAll(inputContentString, additionalKernelCommandline,
@@ -428,6 +527,40 @@ func (b *LiveOSIsoBuilder) updateGrubCfg(isoGrubCfgFileName string, pxeGrubCfgFi
 		return fmt.Errorf("failed to update the kernel arguments with the LiveOS configuration and user configuration in the iso grub.cfg:\n%w", err)
 	}
 
+	// Give the menu entry of slot A a stable id, so that A/B update tooling
+	// can select it the same way it selects slot B.
+	if b.findSlotBRootfsImage() != nil {
+		inputContentString, err = setFirstMenuEntryId(inputContentString, isoSlotAMenuEntryId)
+		if err != nil {
+			return fmt.Errorf("failed to set the id of the slot A menu entry in the iso grub.cfg:\n%w", err)
+		}
+	}
+
+	// Add a menu entry for each additional rootfs image. Each entry is a copy
+	// of the first (main) menu entry that selects a different squashfs image.
+	// Note that dracut uses the last occurrence of rd.live.squashimg.
+	for _, rootfsImage := range b.artifacts.additionalRootfsImages {
+		rootfsImageKernelArgs := fmt.Sprintf("rd.live.squashimg=%s %s", rootfsImage.name, rootfsImage.extraCommandLine)
+		inputContentString, err = appendMenuEntryCopy(inputContentString, rootfsImage.title,
+			additionalRootfsMenuEntryIdPrefix+rootfsImage.name, rootfsImageKernelArgs)
+		if err != nil {
+			return fmt.Errorf("failed to add the menu entry for rootfs image (%s) to the iso grub.cfg:\n%w", rootfsImage.name, err)
+		}
+	}
+
+	// The PXE flow downloads the iso into memory, where the implanted
+	// checksum cannot be verified by dracut. So, the media check menu entry
+	// is not added to the PXE grub.cfg.
+	pxeContentString := inputContentString
+
+	if b.mediaCheck {
+		inputContentString, err = appendMenuEntryCopy(inputContentString, mediaCheckMenuEntryTitle, mediaCheckMenuEntryId,
+			mediaCheckKernelArgs)
+		if err != nil {
+			return fmt.Errorf("failed to add the media check menu entry to the iso grub.cfg:\n%w", err)
+		}
+	}
+
 	err = file.Write(inputContentString, isoGrubCfgFileName)
 	if err != nil {
 		return fmt.Errorf("failed to write %s:\n%w", isoGrubCfgFileName, err)
@@ -443,11 +576,16 @@ func (b *LiveOSIsoBuilder) updateGrubCfg(isoGrubCfgFileName string, pxeGrubCfgFi
 		// for the PXE bootable ISO, MIC just reports that information to the user
 		// and does not terminate the ISO creation process. No error is reported
 		// because MIC does not know if the user is interested only in the ISO image,
-		// or also in the PXE artifacts.
-		logger.Log.Infof("cannot generate grub.cfg for PXE booting.\n%v", err)
+		// or also in the PXE artifacts. If the configuration has PXE settings,
+		// the user likely is.
+		if savedConfigs.Pxe.IsoImageBaseUrl != "" || savedConfigs.Pxe.IsoImageFileUrl != "" {
+			logger.Log.Warnf("Cannot generate grub.cfg for PXE booting. The iso is not PXE bootable:\n%v", err)
+		} else {
+			logger.Log.Infof("Cannot generate grub.cfg for PXE booting. The iso is not PXE bootable:\n%v", err)
+		}
 	} else {
-		err = generatePxeGrubCfg(inputContentString, savedConfigs.Pxe.IsoImageBaseUrl, savedConfigs.Pxe.IsoImageFileUrl,
-			outputImageBase, pxeGrubCfgFileName)
+		err = generatePxeGrubCfg(pxeContentString, savedConfigs.Pxe.IsoImageBaseUrl, savedConfigs.Pxe.IsoImageFileUrl,
+			savedConfigs.Pxe.BootImage, b.liveOSDir, outputImageBase, b.releaseInfo, pxeGrubCfgFileName)
 		if err != nil {
 			return fmt.Errorf("failed to create grub configuration for PXE booting.\n%w", err)
 		}
@@ -474,9 +612,17 @@ func (b *LiveOSIsoBuilder) updateGrubCfg(isoGrubCfgFileName string, pxeGrubCfgFi
 //   - pxeIsoImageFileUrl:
 //     url to the iso image to download at boot time.
 //     This parameter cannot be set if pxeIsoImageBaseUrl is also set.
+//   - bootImage:
+//     the image to download at boot time. If set to 'rootfs', the rootfs
+//     image is downloaded (instead of the iso image) from under
+//     pxeIsoImageBaseUrl, which is then the url of the PXE artifacts folder.
+//   - liveOSDir:
+//     the folder (on the iso media) that holds the rootfs images.
 //   - outputImageBase:
 //     the generated iso name. This value will be used only if the pxeIsoImageFileUrl
 //     is empty.
+//   - releaseInfo:
+//     the release version and the tag added to the generated iso name.
 //   - pxeGrubCfgFileName:
 //     path of file to hold the PXE grub configuration.
 //
@@ -486,7 +632,9 @@ func (b *LiveOSIsoBuilder) updateGrubCfg(isoGrubCfgFileName string, pxeGrubCfgFi
 // generates:
 //   - grub configuration file for PXE booting.
 func generatePxeGrubCfg(inputContentString string, pxeIsoImageBaseUrl string, pxeIsoImageFileUrl string,
-	outputImageBase string, pxeGrubCfgFileName string) error {
+	bootImage imagecustomizerapi.PxeBootImage, liveOSDir string, outputImageBase string, releaseInfo isoReleaseInfo,
+	pxeGrubCfgFileName string,
+) error {
 	if pxeIsoImageBaseUrl != "" && pxeIsoImageFileUrl != "" {
 		return fmt.Errorf("cannot set both iso image base url and full image url at the same time.")
 	}
@@ -497,18 +645,29 @@ func generatePxeGrubCfg(inputContentString string, pxeIsoImageBaseUrl string, px
 		return fmt.Errorf("failed to remove the 'search' commands from PXE grub.cfg:\n%w", err)
 	}
 
-	// If the specified URL is not a full path to an iso, append the generated
-	// iso file name to it.
-	if pxeIsoImageFileUrl == "" {
-		pxeIsoImageFileUrl, err = url.JoinPath(pxeIsoImageBaseUrl, getImageNameFromImageBaseName(outputImageBase).name)
+	if bootImage == imagecustomizerapi.PxeBootImageRootfs {
+		if pxeIsoImageFileUrl != "" {
+			return fmt.Errorf("cannot set the full iso image url when the PXE boot image is (%s).", bootImage)
+		}
+
+		inputContentString, err = setPxeRootfsImageUrlAll(inputContentString, pxeIsoImageBaseUrl, liveOSDir)
 		if err != nil {
-			return fmt.Errorf("failed to concatenate URL (%s) and (%s)\n%w", pxeIsoImageBaseUrl, outputImageBase, err)
+			return fmt.Errorf("failed to update the root kernel argument with the PXE rootfs image url in the PXE grub.cfg:\n%w", err)
+		}
+	} else {
+		// If the specified URL is not a full path to an iso, append the generated
+		// iso file name to it.
+		if pxeIsoImageFileUrl == "" {
+			pxeIsoImageFileUrl, err = url.JoinPath(pxeIsoImageBaseUrl, getImageNameFromImageBaseName(outputImageBase, releaseInfo).name)
+			if err != nil {
+				return fmt.Errorf("failed to concatenate URL (%s) and (%s)\n%w", pxeIsoImageBaseUrl, outputImageBase, err)
+			}
+		}
+		rootValue := fmt.Sprintf(rootValuePxeTemplate, pxeIsoImageFileUrl)
+		inputContentString, _, err = replaceKernelCommandLineArgValueAll(inputContentString, "root", rootValue, true /*allowMultiple*/)
+		if err != nil {
+			return fmt.Errorf("failed to update the root kernel argument with the PXE iso image url in the PXE grub.cfg:\n%w", err)
 		}
-	}
-	rootValue := fmt.Sprintf(rootValuePxeTemplate, pxeIsoImageFileUrl)
-	inputContentString, _, err = replaceKernelCommandLineArgValueAll(inputContentString, "root", rootValue, true /*allowMultiple*/)
-	if err != nil {
-		return fmt.Errorf("failed to update the root kernel argument with the PXE iso image url in the PXE grub.cfg:\n%w", err)
 	}
 
 	inputContentString, err = appendKernelCommandLineArgsAll(inputContentString, pxeKernelsArgs,
@@ -565,6 +724,7 @@ func containsGrubNoPrefix(filePaths []string) bool {
 func (b *LiveOSIsoBuilder) extractBootDirFiles(writeableRootfsDir string) error {
 
 	b.artifacts.additionalFiles = make(map[string]string)
+	b.artifacts.carriedOverFiles = make(map[string]bool)
 
 	// the following files will be re-created - no need to copy them only to
 	// have them overwritten.
@@ -659,6 +819,10 @@ func (b *LiveOSIsoBuilder) extractBootDirFiles(writeableRootfsDir string) error
 			scheduleAdditionalFile = false
 		}
 		if strings.HasPrefix(targetFileName, vmLinuzPrefix) {
+			if targetFileName != vmLinuzPrefix+b.artifacts.kernelVersion {
+				logger.Log.Debugf("Not copying %s. Kernel is not used by the iso.", sourcePath)
+				continue
+			}
 			targetPath = filepath.Join(filepath.Dir(targetPath), "vmlinuz")
 			b.artifacts.vmlinuzPath = targetPath
 			// isomaker will extract this from initrd and copy it to include it
@@ -679,16 +843,66 @@ func (b *LiveOSIsoBuilder) extractBootDirFiles(writeableRootfsDir string) error
 		}
 	}
 
-	if b.artifacts.bootx64EfiPath == "" {
-		return fmt.Errorf("failed to find the boot efi file (%s):\n"+
-			"this file is provided by the (shim) package",
-			bootx64Binary)
+	err = b.extractBootloaderFiles(writeableRootfsDir)
+	if err != nil {
+		return err
 	}
 
-	if b.artifacts.grubx64EfiPath == "" {
-		return fmt.Errorf("failed to find the grub efi file (%s or %s):\n"+
-			"this file is provided by either the (grub2-efi-binary) or the (grub2-efi-binary-noprefix) package",
-			grubx64Binary, grubx64NoPrefixBinary)
+	err = b.checkBootloaderFiles()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// extractBootloaderFiles
+//
+//	copies the bootloader files requested by the iso configuration from the
+//	rootfs. The files that replace the shim or the grub binaries of the OS
+//	are staged in their place. The rest are placed on the EFI system
+//	partition by isomaker.
+//
+// inputs:
+//   - writeableRootfsDir:
+//     A writeable folder where the rootfs content is.
+//
+// outputs:
+//   - the following are populated:
+//     b.artifacts.bootx64EfiPath (if replaced)
+//     b.artifacts.grubx64EfiPath (if replaced)
+//     b.artifacts.efiBootFiles
+func (b *LiveOSIsoBuilder) extractBootloaderFiles(writeableRootfsDir string) error {
+	const espDir = "esp"
+
+	b.artifacts.efiBootFiles = nil
+
+	for _, bootloaderFile := range b.bootloaderFiles {
+		sourcePath := filepath.Join(writeableRootfsDir, bootloaderFile.Source)
+		targetPath := filepath.Join(b.workingDirs.isoArtifactsDir, espDir, bootloaderFile.Destination)
+
+		err := file.NewFileCopyBuilder(sourcePath, targetPath).
+			Run()
+		if err != nil {
+			return fmt.Errorf("failed to extract bootloader file (%s):\n%w", bootloaderFile.Source, err)
+		}
+
+		// The EFI system partition is a FAT file system. So, file names are
+		// case insensitive.
+		destination := strings.TrimPrefix(bootloaderFile.Destination, "/")
+		switch {
+		case strings.EqualFold(destination, imagecustomizerapi.IsoFirstStageBootloaderPath):
+			b.artifacts.bootx64EfiPath = targetPath
+		case strings.EqualFold(destination, filepath.Join("EFI/BOOT", grubx64Binary)):
+			b.artifacts.grubx64EfiPath = targetPath
+		default:
+			b.artifacts.efiBootFiles = append(b.artifacts.efiBootFiles, safechroot.FileToCopy{
+				Src:  targetPath,
+				Dest: destination,
+			})
+		}
+
+		logger.Log.Debugf("Extracted bootloader file (%s) for (%s)", bootloaderFile.Source, destination)
 	}
 
 	return nil
@@ -716,7 +930,7 @@ func (b *LiveOSIsoBuilder) findKernelVersion(writeableRootfsDir string) error {
 
 	// Filter out directories that are empty.
 	// Some versions of Azure Linux 2.0 don't cleanup properly when the kernel package is uninstalled.
-	filteredKernelDirs := []fs.DirEntry(nil)
+	kernelVersions := []string(nil)
 	for _, kernelDir := range kernelDirs {
 		kernelPath := filepath.Join(kernelParentPath, kernelDir.Name())
 		empty, err := file.IsDirEmpty(kernelPath)
@@ -725,17 +939,47 @@ func (b *LiveOSIsoBuilder) findKernelVersion(writeableRootfsDir string) error {
 		}
 
 		if !empty {
-			filteredKernelDirs = append(filteredKernelDirs, kernelDir)
+			kernelVersions = append(kernelVersions, kernelDir.Name())
 		}
 	}
 
-	if len(filteredKernelDirs) == 0 {
+	if len(kernelVersions) == 0 {
 		return fmt.Errorf("did not find any kernels installed under (%s)", kernelModulesDir)
 	}
-	if len(filteredKernelDirs) > 1 {
-		return fmt.Errorf("unsupported scenario: found more than one kernel under (%s)", kernelModulesDir)
+
+	if b.kernelVersionPattern != "" {
+		matchingKernelVersions := []string(nil)
+		for _, kernelVersion := range kernelVersions {
+			match, err := filepath.Match(b.kernelVersionPattern, kernelVersion)
+			if err != nil {
+				return fmt.Errorf("invalid iso kernelVersion (%s):\n%w", b.kernelVersionPattern, err)
+			}
+
+			if match {
+				matchingKernelVersions = append(matchingKernelVersions, kernelVersion)
+			}
+		}
+
+		if len(matchingKernelVersions) == 0 {
+			return fmt.Errorf("iso kernelVersion (%s) does not match any of the installed kernels (%s)",
+				b.kernelVersionPattern, strings.Join(kernelVersions, ", "))
+		}
+		if len(matchingKernelVersions) > 1 {
+			return fmt.Errorf("iso kernelVersion (%s) matches more than one of the installed kernels (%s):\n"+
+				"use a more specific kernelVersion",
+				b.kernelVersionPattern, strings.Join(matchingKernelVersions, ", "))
+		}
+
+		kernelVersions = matchingKernelVersions
+	}
+
+	if len(kernelVersions) > 1 {
+		return fmt.Errorf("found more than one kernel installed under (%s) (%s):\n"+
+			"use iso kernelVersion to select the kernel that boots the iso",
+			kernelModulesDir, strings.Join(kernelVersions, ", "))
 	}
-	b.artifacts.kernelVersion = filteredKernelDirs[0].Name()
+
+	b.artifacts.kernelVersion = kernelVersions[0]
 	logger.Log.Debugf("Found installed kernel version (%s)", b.artifacts.kernelVersion)
 	return nil
 }
@@ -744,10 +988,6 @@ func (b *LiveOSIsoBuilder) findKernelVersion(writeableRootfsDir string) error {
 //
 //	given a rootfs, this function:
 //	- extracts the kernel version, and the files under the boot folder.
-//	- stages bootloaders and vmlinuz to a specific folder structure.
-//	This folder structure is to be included later in the initrd image when
-//	it gets generated. IsoMaker extracts those artifacts from the initrd
-//	image file and uses them.
 //	-prepares the rootfs to run dracut (dracut will generate the initrd later).
 //	- creates the squashfs.
 //
@@ -755,10 +995,6 @@ func (b *LiveOSIsoBuilder) findKernelVersion(writeableRootfsDir string) error {
 //   - 'inputSavedConfigsFilePath':
 //   - writeableRootfsDir:
 //     A writeable folder where the rootfs content is.
-//   - 'isoMakerArtifactsStagingDir':
-//     The folder where the artifacts needed by isoMaker will be staged before
-//     'dracut' is run. 'dracut' will include this folder as-is and place it in
-//     the initrd image.
 //   - 'extraCommandLine':
 //     extra kernel command line arguments to add to grub.
 //   - 'pxeIsoImageBaseUrl':
@@ -774,8 +1010,8 @@ func (b *LiveOSIsoBuilder) findKernelVersion(writeableRootfsDir string) error {
 //   - customized writeableRootfsDir (new files, deleted files, etc)
 //   - extracted artifacts
 func (b *LiveOSIsoBuilder) prepareLiveOSDir(inputSavedConfigsFilePath string, writeableRootfsDir string,
-	isoMakerArtifactsStagingDir string, extraCommandLine imagecustomizerapi.KernelExtraArguments, pxeIsoImageBaseUrl string,
-	pxeIsoImageFileUrl string, outputImageBase string) error {
+	extraCommandLine imagecustomizerapi.KernelExtraArguments, pxeIsoImageBaseUrl string, pxeIsoImageFileUrl string,
+	outputImageBase string) error {
 
 	logger.Log.Debugf("Creating LiveOS squashfs image")
 
@@ -806,21 +1042,19 @@ func (b *LiveOSIsoBuilder) prepareLiveOSDir(inputSavedConfigsFilePath string, wr
 	}
 
 	updatedSavedConfigs, err := updateSavedConfigs(b.artifacts.savedConfigsFilePath, extraCommandLine, pxeIsoImageBaseUrl,
-		pxeIsoImageFileUrl, b.artifacts.dracutPackageInfo)
+		pxeIsoImageFileUrl, b.pxeBootImage, b.artifacts.dracutPackageInfo)
 	if err != nil {
 		return fmt.Errorf("failed to combine saved configurations with new configuration:\n%w", err)
 	}
 
+	// The boot image may come from a previous run.
+	b.pxeBootImage = updatedSavedConfigs.Pxe.BootImage
+
 	err = b.updateGrubCfg(b.artifacts.isoGrubCfgPath, b.artifacts.pxeGrubCfgPath, updatedSavedConfigs, outputImageBase)
 	if err != nil {
 		return fmt.Errorf("failed to update grub.cfg:\n%w", err)
 	}
 
-	err = b.stageIsoMakerInitrdArtifacts(writeableRootfsDir, isoMakerArtifactsStagingDir)
-	if err != nil {
-		return fmt.Errorf("failed to stage isomaker initrd artifacts:\n%w", err)
-	}
-
 	err = b.prepareRootfsForDracut(writeableRootfsDir)
 	if err != nil {
 		return fmt.Errorf("failed to prepare rootfs for dracut:\n%w", err)
@@ -840,11 +1074,11 @@ func (b *LiveOSIsoBuilder) prepareLiveOSDir(inputSavedConfigsFilePath string, wr
 // output
 //   - creates a squashfs image and stores its path in
 //     b.artifacts.squashfsImagePath
-func (b *LiveOSIsoBuilder) createSquashfsImage(writeableRootfsDir string) error {
+func (b *LiveOSIsoBuilder) createSquashfsImage(ctx context.Context, writeableRootfsDir string) error {
 
 	logger.Log.Debugf("Creating squashfs of %s", writeableRootfsDir)
 
-	squashfsImagePath := filepath.Join(b.workingDirs.isoArtifactsDir, liveOSImage)
+	squashfsImagePath := filepath.Join(b.workingDirs.isoArtifactsDir, defaultLiveOSImage)
 
 	exists, err := file.PathExists(squashfsImagePath)
 	if err == nil && exists {
@@ -854,8 +1088,23 @@ func (b *LiveOSIsoBuilder) createSquashfsImage(writeableRootfsDir string) error
 		}
 	}
 
-	mksquashfsParams := []string{writeableRootfsDir, squashfsImagePath}
-	err = shell.ExecuteLive(false, "mksquashfs", mksquashfsParams...)
+	// '-xattrs' keeps the file capabilities and SELinux labels of the rootfs.
+	mksquashfsParams := []string{writeableRootfsDir, squashfsImagePath, "-xattrs",
+		"-processors", strconv.Itoa(b.buildResources.maxParallelWorkers())}
+	env := []string(nil)
+	if b.sourceDateEpoch != nil {
+		// '-reproducible' keeps the order of the files independent of the parallel compression. And, SOURCE_DATE_EPOCH
+		// replaces the filesystem's timestamp and clamps the timestamps of the newer files.
+		mksquashfsParams = append(mksquashfsParams, "-reproducible")
+		env = isomakerlib.SourceDateEpochEnvironment(*b.sourceDateEpoch)
+	}
+
+	program, args := resourceLimitedCommand(b.buildResources, "mksquashfs", mksquashfsParams...)
+	err = shell.NewExecBuilder(program, args...).
+		Context(ctx).
+		EnvironmentVariables(env).
+		LogLevel(logrus.DebugLevel, logrus.WarnLevel).
+		Execute()
 	if err != nil {
 		return fmt.Errorf("failed to create squashfs:\n%w", err)
 	}
@@ -873,16 +1122,10 @@ func (b *LiveOSIsoBuilder) createSquashfsImage(writeableRootfsDir string) error
 //   - rootfsSourceDir:
 //     local folder (on the build machine) of the rootfs to be used when
 //     creating the initrd image.
-//   - artifactsSourceDir:
-//     source directory (on the build machine) holding an artifacts tree to
-//     include in the initrd image.
-//   - artifactsTargetDir:
-//     target directory (within the initrd image) where the contents of the
-//     artifactsSourceDir tree will be copied to.
 //
 // outputs:
 // - creates an initrd.img and stores its path in b.artifacts.initrdImagePath.
-func (b *LiveOSIsoBuilder) generateInitrdImage(rootfsSourceDir, artifactsSourceDir, artifactsTargetDir string) error {
+func (b *LiveOSIsoBuilder) generateInitrdImage(ctx context.Context, rootfsSourceDir string) error {
 
 	logger.Log.Debugf("Generating initrd")
 
@@ -910,10 +1153,25 @@ func (b *LiveOSIsoBuilder) generateInitrdImage(rootfsSourceDir, artifactsSourceD
 		dracutParams := []string{
 			initrdPathInChroot,
 			"--kver", b.artifacts.kernelVersion,
-			"--filesystems", "squashfs",
-			"--include", artifactsSourceDir, artifactsTargetDir}
+			"--filesystems", "squashfs"}
+
+		if b.pxeDownload != nil {
+			dracutParams = append(dracutParams, "--include", pxeDownloadStagingDir, "/")
+		}
+
+		env := []string(nil)
+		if b.sourceDateEpoch != nil {
+			// Sorts the initrd's files and sets their timestamps to SOURCE_DATE_EPOCH.
+			dracutParams = append(dracutParams, "--reproducible")
+			env = isomakerlib.SourceDateEpochEnvironment(*b.sourceDateEpoch)
+		}
 
-		return shell.ExecuteLive(true /*squashErrors*/, "dracut", dracutParams...)
+		program, args := resourceLimitedCommand(b.buildResources, "dracut", dracutParams...)
+		return shell.NewExecBuilder(program, args...).
+			Context(ctx).
+			EnvironmentVariables(env).
+			LogLevel(logrus.DebugLevel, logrus.DebugLevel).
+			Execute()
 	})
 	if err != nil {
 		return fmt.Errorf("failed to run dracut:\n%w", err)
@@ -956,38 +1214,55 @@ func (b *LiveOSIsoBuilder) generateInitrdImage(rootfsSourceDir, artifactsSourceD
 //     `LiveOSIsoBuilder.workingDirs.isoArtifactsDir` folder.
 //   - the paths to individual artifaces are found in the
 //     `LiveOSIsoBuilder.artifacts` data structure.
-func (b *LiveOSIsoBuilder) prepareArtifactsFromFullImage(inputSavedConfigsFilePath string, rawImageFile string, extraCommandLine imagecustomizerapi.KernelExtraArguments,
+func (b *LiveOSIsoBuilder) prepareArtifactsFromFullImage(ctx context.Context, inputSavedConfigsFilePath string, rawImageFile string, extraCommandLine imagecustomizerapi.KernelExtraArguments,
 	pxeIsoImageBaseUrl string, pxeIsoImageFileUrl string, outputImageBase string) error {
 
 	logger.Log.Infof("Preparing iso artifacts")
 
 	logger.Log.Debugf("Connecting to raw image (%s)", rawImageFile)
-	rawImageConnection, err := connectToExistingImage(rawImageFile, b.workingDirs.isoBuildDir, "readonly-rootfs-mount", false /*includeDefaultMounts*/)
+	rawImageConnection, err := connectToExistingImageReadOnly(rawImageFile, b.workingDirs.isoBuildDir, "readonly-rootfs-mount")
 	if err != nil {
 		return err
 	}
 	defer rawImageConnection.Close()
 
+	rootfsSizeInfo, err := getDirSize(rawImageConnection.Chroot().RootDir(), b.buildResources.maxParallelWorkers())
+	if err != nil {
+		return fmt.Errorf("failed to calculate the rootfs size while checking for free disk space:\n%w", err)
+	}
+
+	err = checkIsoBuildDiskSpace(b.workingDirs.isoBuildDir, rootfsSizeInfo, getAvailableDiskSpaceInBytes)
+	if err != nil {
+		return err
+	}
+
+	b.artifacts.rootfsSizeInfo = &rootfsSizeInfo
+
 	writeableRootfsDir := filepath.Join(b.workingDirs.isoBuildDir, "writeable-rootfs")
-	err = b.populateWriteableRootfsDir(rawImageConnection.Chroot().RootDir(), writeableRootfsDir)
+	err = b.populateWriteableRootfsDir(ctx, rawImageConnection.Chroot().RootDir(), writeableRootfsDir)
 	if err != nil {
 		return fmt.Errorf("failed to copy the contents of rootfs from image (%s) to local folder (%s):\n%w", rawImageFile, writeableRootfsDir, err)
 	}
 
-	isoMakerArtifactsStagingDir := "/boot-staging"
-	err = b.prepareLiveOSDir(inputSavedConfigsFilePath, writeableRootfsDir, isoMakerArtifactsStagingDir,
-		extraCommandLine, pxeIsoImageBaseUrl, pxeIsoImageFileUrl, outputImageBase)
+	err = b.prepareLiveOSDir(inputSavedConfigsFilePath, writeableRootfsDir, extraCommandLine, pxeIsoImageBaseUrl,
+		pxeIsoImageFileUrl, outputImageBase)
 	if err != nil {
 		return fmt.Errorf("failed to convert rootfs folder to a LiveOS folder:\n%w", err)
 	}
 
-	err = b.createSquashfsImage(writeableRootfsDir)
+	err = b.createSquashfsImage(ctx, writeableRootfsDir)
 	if err != nil {
 		return fmt.Errorf("failed to create squashfs image:\n%w", err)
 	}
 
-	isoMakerArtifactsDirInInitrd := "/boot"
-	err = b.generateInitrdImage(writeableRootfsDir, isoMakerArtifactsStagingDir, isoMakerArtifactsDirInInitrd)
+	if b.pxeDownload != nil {
+		err = stagePxeDownloadConfig(writeableRootfsDir, b.pxeDownload)
+		if err != nil {
+			return fmt.Errorf("failed to stage the PXE download settings:\n%w", err)
+		}
+	}
+
+	err = b.generateInitrdImage(ctx, writeableRootfsDir)
 	if err != nil {
 		return fmt.Errorf("failed to generate initrd image:\n%w", err)
 	}
@@ -995,6 +1270,33 @@ func (b *LiveOSIsoBuilder) prepareArtifactsFromFullImage(inputSavedConfigsFilePa
 	return nil
 }
 
+// selectIsoBackend
+//
+//	selects the tool that generates the iso image file. The native iso
+//	writer is used when the external tool isn't installed on the build host.
+//
+// inputs:
+//   - reproducible:
+//     whether the iso build is reproducible (which requires xorriso instead
+//     of mkisofs).
+func selectIsoBackend(reproducible bool) (isomakerlib.IsoBackend, error) {
+	backend := isomakerlib.IsoBackendMkisofs
+	if reproducible {
+		backend = isomakerlib.IsoBackendXorriso
+	}
+
+	exists, err := file.CommandExists(string(backend))
+	if err != nil {
+		return "", fmt.Errorf("failed to check if (%s) is installed:\n%w", backend, err)
+	}
+	if exists {
+		return isomakerlib.IsoBackendDefault, nil
+	}
+
+	logger.Log.Infof("(%s) is not installed, using the native iso writer", backend)
+	return isomakerlib.IsoBackendNative, nil
+}
+
 // createIsoImage
 //
 //	creates an LiveOS ISO image.
@@ -1012,7 +1314,11 @@ func (b *LiveOSIsoBuilder) prepareArtifactsFromFullImage(inputSavedConfigsFilePa
 //
 // ouptuts:
 //   - create a LiveOS ISO.
-func (b *LiveOSIsoBuilder) createIsoImage(additionalIsoFiles []safechroot.FileToCopy, isoOutputDir, isoOutputBaseName string) (isoImagePath string, err error) {
+//   - isoMediaFiles:
+//     the files placed on the iso media by this function. This does not
+//     include the files isomaker generates or extracts from the initrd image
+//     (i.e. the kernel, the initrd, the iso grub.cfg and the bootloaders).
+func (b *LiveOSIsoBuilder) createIsoImage(ctx context.Context, additionalIsoFiles []safechroot.FileToCopy, isoOutputDir, isoOutputBaseName string) (isoImagePath string, isoMediaFiles []safechroot.FileToCopy, err error) {
 	baseDirPath := ""
 
 	// unattended install is where the ISO OS configures a persistent storage
@@ -1031,8 +1337,19 @@ func (b *LiveOSIsoBuilder) createIsoImage(additionalIsoFiles []safechroot.FileTo
 	enableRpmRepo := false
 	isoRepoDirPath := ""
 
+	if b.mediaCheck {
+		err = verifyInitrdSupportsMediaCheck(b.artifacts.initrdImagePath)
+		if err != nil {
+			return "", nil, err
+		}
+	}
+
+	// The files requested by the iso configuration. Used to break down the
+	// iso size.
+	configAdditionalFiles := additionalIsoFiles
+
 	// Construct the output image full path
-	isoImageNameInfo := getImageNameFromImageBaseName(isoOutputBaseName)
+	isoImageNameInfo := getImageNameFromImageBaseName(isoOutputBaseName, b.releaseInfo)
 	isoImagePath = filepath.Join(isoOutputDir, isoImageNameInfo.name)
 
 	// empty target system config since LiveOS does not install the OS
@@ -1042,10 +1359,45 @@ func (b *LiveOSIsoBuilder) createIsoImage(additionalIsoFiles []safechroot.FileTo
 	// Add the squashfs file
 	squashfsImageToCopy := safechroot.FileToCopy{
 		Src:  b.artifacts.squashfsImagePath,
-		Dest: filepath.Join(liveOSDir, liveOSImage),
+		Dest: filepath.Join(b.liveOSDir, b.liveOSImage),
 	}
 	additionalIsoFiles = append(additionalIsoFiles, squashfsImageToCopy)
 
+	// Add the additional squashfs files
+	for _, rootfsImage := range b.artifacts.additionalRootfsImages {
+		rootfsImageToCopy := safechroot.FileToCopy{
+			Src:  rootfsImage.squashfsImagePath,
+			Dest: filepath.Join(b.liveOSDir, rootfsImage.name),
+		}
+		if rootfsImageToCopy.Src == "" {
+			// slot B of an A/B update iso starts as a copy of slot A.
+			rootfsImageToCopy.Src = b.artifacts.squashfsImagePath
+		}
+		additionalIsoFiles = append(additionalIsoFiles, rootfsImageToCopy)
+	}
+
+	// Add the slots manifest file
+	slotBRootfsImage := b.findSlotBRootfsImage()
+	if slotBRootfsImage != nil {
+		slotBImagePath := slotBRootfsImage.squashfsImagePath
+		if slotBImagePath == "" {
+			slotBImagePath = b.artifacts.squashfsImagePath
+		}
+
+		slotsManifestPath := filepath.Join(b.workingDirs.isoArtifactsDir, isoSlotsManifestFileName)
+		err = writeSlotsManifest(b.liveOSDir, b.liveOSImage, b.artifacts.squashfsImagePath, slotBImagePath,
+			slotsManifestPath)
+		if err != nil {
+			return "", nil, err
+		}
+
+		fileToCopy := safechroot.FileToCopy{
+			Src:  slotsManifestPath,
+			Dest: filepath.Join(b.liveOSDir, isoSlotsManifestFileName),
+		}
+		additionalIsoFiles = append(additionalIsoFiles, fileToCopy)
+	}
+
 	// Add /boot/* files
 	for sourceFile, targetFile := range b.artifacts.additionalFiles {
 		fileToCopy := safechroot.FileToCopy{
@@ -1059,7 +1411,7 @@ func (b *LiveOSIsoBuilder) createIsoImage(additionalIsoFiles []safechroot.FileTo
 	// Add the iso saved config file
 	exists, err := file.PathExists(b.artifacts.savedConfigsFilePath)
 	if err != nil {
-		return "", fmt.Errorf("failed to check if (%s) exists:\n%w", b.artifacts.savedConfigsFilePath, err)
+		return "", nil, fmt.Errorf("failed to check if (%s) exists:\n%w", b.artifacts.savedConfigsFilePath, err)
 	}
 	if exists {
 		fileToCopy := safechroot.FileToCopy{
@@ -1072,7 +1424,7 @@ func (b *LiveOSIsoBuilder) createIsoImage(additionalIsoFiles []safechroot.FileTo
 	// Add the grub-pxe.cfg file
 	exists, err = file.PathExists(b.artifacts.pxeGrubCfgPath)
 	if err != nil {
-		return "", fmt.Errorf("failed to check if (%s) exists:\n%w", b.artifacts.pxeGrubCfgPath, err)
+		return "", nil, fmt.Errorf("failed to check if (%s) exists:\n%w", b.artifacts.pxeGrubCfgPath, err)
 	}
 	if exists {
 		fileToCopy := safechroot.FileToCopy{
@@ -1082,38 +1434,85 @@ func (b *LiveOSIsoBuilder) createIsoImage(additionalIsoFiles []safechroot.FileTo
 		additionalIsoFiles = append(additionalIsoFiles, fileToCopy)
 	}
 
+	// Record the media layout and the hashes of the files that may be carried
+	// over when this iso is customized again.
+	err = b.saveIsoMediaConfigs(additionalIsoFiles)
+	if err != nil {
+		return "", nil, err
+	}
+
 	err = os.MkdirAll(isoOutputDir, os.ModePerm)
 	if err != nil {
-		return "", err
+		return "", nil, err
+	}
+
+	isoBackend, err := selectIsoBackend(b.sourceDateEpoch != nil)
+	if err != nil {
+		return "", nil, err
+	}
+
+	isoMaker, err := isomakerlib.NewIsoMakerWithOptions(isomakerlib.IsoMakerOptions{
+		UnattendedInstall:  unattendedInstall,
+		EnableBiosBoot:     enableBiosBoot,
+		EnableRpmRepo:      enableRpmRepo,
+		Config:             targetSystemConfig,
+		BaseDirPath:        baseDirPath,
+		BuildDirPath:       b.workingDirs.isomakerBuildDir,
+		ReleaseVersion:     isoImageNameInfo.releaseVersion,
+		ResourcesDirPath:   isoResourcesDir,
+		AdditionalIsoFiles: additionalIsoFiles,
+		OsFilesPath:        isoBootDir,
+		InitrdPath:         b.artifacts.initrdImagePath,
+		GrubCfgPath:        b.artifacts.isoGrubCfgPath,
+		IsoRepoDirPath:     isoRepoDirPath,
+		OutputDir:          isoOutputDir,
+		ImageNameBase:      isoOutputBaseName,
+		ImageNameTag:       isoImageNameInfo.tag,
+		SourceDateEpoch:    b.sourceDateEpoch,
+		EfiBootFiles:       b.artifacts.efiBootFiles,
+		SkipGrubEfi:        b.artifacts.grubx64EfiPath == "",
+		Backend:            isoBackend,
+		BootFiles: &isomakerlib.IsoBootFiles{
+			BootBootloaderPath: b.artifacts.bootx64EfiPath,
+			GrubBootloaderPath: b.artifacts.grubx64EfiPath,
+			KernelPath:         b.artifacts.vmlinuzPath,
+		},
+	})
+	if err != nil {
+		return "", nil, err
 	}
 
-	isoMaker, err := isomakerlib.NewIsoMakerWithConfig(
-		unattendedInstall,
-		enableBiosBoot,
-		enableRpmRepo,
-		baseDirPath,
-		b.workingDirs.isomakerBuildDir,
-		isoImageNameInfo.releaseVersion,
-		isoResourcesDir,
-		additionalIsoFiles,
-		targetSystemConfig,
-		isoBootDir,
-		b.artifacts.initrdImagePath,
-		b.artifacts.isoGrubCfgPath,
-		isoRepoDirPath,
-		isoOutputDir,
-		isoOutputBaseName,
-		isoImageNameInfo.tag)
+	// The iso maker doesn't support cancellation. So, at least don't start it if the build has been stopped.
+	err = checkBuildStopped(ctx)
 	if err != nil {
-		return "", err
+		return "", nil, err
 	}
 
 	err = isoMaker.Make()
 	if err != nil {
-		return "", err
+		return "", nil, err
+	}
+
+	if b.maxIsoSize != 0 {
+		err = b.checkIsoSizeBudget(isoImagePath, configAdditionalFiles)
+		if err != nil {
+			return "", nil, err
+		}
+	}
+
+	if b.mediaCheck {
+		logger.Log.Infof("Implanting media checksum into (%s)", isoImagePath)
+
+		err = shell.NewExecBuilder("implantisomd5", isoImagePath).
+			Context(ctx).
+			LogLevel(logrus.DebugLevel, logrus.WarnLevel).
+			Execute()
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to implant media checksum into (%s):\n%w", isoImagePath, err)
+		}
 	}
 
-	return isoImagePath, nil
+	return isoImagePath, additionalIsoFiles, nil
 }
 
 // micIsoConfigToIsoMakerConfig
@@ -1157,6 +1556,171 @@ func micIsoConfigToIsoMakerConfig(baseConfigPath string, isoConfig *imagecustomi
 	return additionalIsoFiles, isoConfig.KernelCommandLine.ExtraCommandLine, nil
 }
 
+// applyIsoConfig
+//
+//	applies the parts of the user provided iso configuration that affect the
+//	layout of the iso media and its grub menu entries.
+//
+// inputs:
+//
+//   - 'baseConfigPath'
+//     path to the folder where the mic configuration was loaded from.
+//   - 'isoConfig'
+//     user provided configuration for the iso image.
+func (b *LiveOSIsoBuilder) applyIsoConfig(baseConfigPath string, isoConfig *imagecustomizerapi.Iso) (err error) {
+	b.artifacts.additionalRootfsImages, err = micIsoConfigToRootfsImages(baseConfigPath, isoConfig)
+	if err != nil {
+		return err
+	}
+
+	if isoConfig == nil {
+		b.mediaCheck = false
+		b.sourceDateEpoch = nil
+		b.maxIsoSize = 0
+		b.kernelVersionPattern = ""
+		b.bootloaderFiles = nil
+		b.bootBackend = imagecustomizerapi.IsoBootBackendDefault
+		// The environment variables may still set the release info.
+		b.releaseInfo, err = getIsoReleaseInfo(nil)
+		if err != nil {
+			return err
+		}
+		return nil
+	}
+
+	b.mediaCheck = isoConfig.MediaCheck
+	b.kernelVersionPattern = isoConfig.KernelVersion
+	b.bootloaderFiles = isoConfig.BootloaderFiles
+	b.bootBackend = isoConfig.BootBackend
+
+	b.releaseInfo, err = getIsoReleaseInfo(isoConfig)
+	if err != nil {
+		return err
+	}
+
+	b.maxIsoSize = 0
+	if isoConfig.MaxSize != nil {
+		b.maxIsoSize = uint64(*isoConfig.MaxSize)
+	}
+
+	b.sourceDateEpoch = nil
+	if isoConfig.Reproducible {
+		sourceDateEpoch, err := getSourceDateEpoch()
+		if err != nil {
+			return err
+		}
+		b.sourceDateEpoch = &sourceDateEpoch
+	}
+
+	if isoConfig.LiveOSDir != "" {
+		b.liveOSDir = isoConfig.LiveOSDir
+	}
+
+	if isoConfig.LiveOSImageName != "" {
+		b.liveOSImage = isoConfig.LiveOSImageName
+	}
+
+	return nil
+}
+
+// applyPxeConfig
+//
+//	applies the PXE configuration to the builder.
+//
+// inputs:
+//
+//   - 'baseConfigPath'
+//     path to the folder where the mic configuration was loaded from.
+//     This path will be used to construct absolute paths for build machine
+//     file references defined in the config.
+//   - 'pxeConfig'
+//     user provided configuration for the PXE flow.
+func (b *LiveOSIsoBuilder) applyPxeConfig(baseConfigPath string, pxeConfig *imagecustomizerapi.Pxe) {
+	if pxeConfig == nil {
+		b.pxeArtifactsArchive = imagecustomizerapi.PxeArtifactsArchiveFormatNone
+		b.pxeIpxeScript = nil
+		b.pxelinuxConfig = false
+		b.pxeHttpBoot = false
+		b.pxeArtifactsLayout = imagecustomizerapi.PxeArtifactsLayoutDefault
+		b.pxeBootImage = imagecustomizerapi.PxeBootImageDefault
+		b.pxeHostOverrides = nil
+		b.pxeAnswerFiles = nil
+		b.pxeArtifactsBaseUrl = ""
+		b.pxeDownload = nil
+		return
+	}
+
+	b.pxeArtifactsArchive = pxeConfig.ArtifactsArchive
+	b.pxeIpxeScript = pxeConfig.IpxeScript
+	b.pxelinuxConfig = pxeConfig.PxelinuxConfig
+	b.pxeHttpBoot = pxeConfig.HttpBoot
+	b.pxeArtifactsLayout = pxeConfig.ArtifactsLayout
+	b.pxeBootImage = pxeConfig.BootImage
+	b.pxeHostOverrides = pxeConfig.HostOverrides
+	b.pxeAnswerFiles = micPxeConfigToAnswerFiles(baseConfigPath, pxeConfig)
+	b.pxeArtifactsBaseUrl = pxeConfig.IsoImageBaseUrl
+	b.pxeDownload = micPxeConfigToDownload(baseConfigPath, pxeConfig)
+}
+
+// micIsoConfigToRootfsImages
+//
+//	converts the additional rootfs images of imagecustomizerapi.Iso to their
+//	build representation.
+//
+// inputs:
+//
+//   - 'baseConfigPath'
+//     path to the folder where the mic configuration was loaded from.
+//     This path will be used to construct absolute paths for build machine
+//     file references defined in the config.
+//   - 'isoConfig'
+//     user provided configuration for the iso image.
+//
+// outputs:
+//   - 'rootfsImages'
+//     list of additional rootfs images to place on the iso media.
+func micIsoConfigToRootfsImages(baseConfigPath string, isoConfig *imagecustomizerapi.Iso) (rootfsImages []isoRootfsImage, err error) {
+	if isoConfig == nil {
+		return nil, nil
+	}
+
+	for _, rootfsImage := range isoConfig.AdditionalRootfsImages {
+		absSourceFile := file.GetAbsPathWithBase(baseConfigPath, rootfsImage.Source)
+
+		exists, err := file.PathExists(absSourceFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check if rootfs image (%s) exists:\n%w", absSourceFile, err)
+		}
+		if !exists {
+			return nil, fmt.Errorf("rootfs image (%s) does not exist", absSourceFile)
+		}
+
+		rootfsImages = append(rootfsImages, isoRootfsImage{
+			squashfsImagePath: absSourceFile,
+			name:              rootfsImage.Name,
+			title:             rootfsImage.Title,
+			extraCommandLine:  rootfsImage.KernelCommandLine.ExtraCommandLine,
+		})
+	}
+
+	if isoConfig.ABUpdate != nil {
+		slotBRootfsImage := newSlotBRootfsImage(baseConfigPath, isoConfig.ABUpdate)
+		if slotBRootfsImage.squashfsImagePath != "" {
+			exists, err := file.PathExists(slotBRootfsImage.squashfsImagePath)
+			if err != nil {
+				return nil, fmt.Errorf("failed to check if slot B image (%s) exists:\n%w", slotBRootfsImage.squashfsImagePath, err)
+			}
+			if !exists {
+				return nil, fmt.Errorf("slot B image (%s) does not exist", slotBRootfsImage.squashfsImagePath)
+			}
+		}
+
+		rootfsImages = append(rootfsImages, slotBRootfsImage)
+	}
+
+	return rootfsImages, nil
+}
+
 // createLiveOSIsoImage
 //
 //	main function to create a LiveOS ISO image from a raw full disk image file.
@@ -1190,16 +1754,19 @@ func micIsoConfigToIsoMakerConfig(baseConfigPath string, isoConfig *imagecustomi
 //   - 'outputPXEArtifactsDir'
 //     optional directory path where the PXE artifacts will be exported to if
 //     specified.
+//   - 'buildResources'
+//     the resource limits of the build steps.
 //
 // outputs:
 //
 //	creates a LiveOS ISO image.
-func createLiveOSIsoImage(buildDir, baseConfigPath string, inputIsoArtifacts *LiveOSIsoBuilder, isoConfig *imagecustomizerapi.Iso,
-	pxeConfig *imagecustomizerapi.Pxe, rawImageFile, outputImageDir, outputImageBase string, outputPXEArtifactsDir string) (err error) {
+func createLiveOSIsoImage(ctx context.Context, buildDir, baseConfigPath string, inputIsoArtifacts *LiveOSIsoBuilder, isoConfig *imagecustomizerapi.Iso,
+	pxeConfig *imagecustomizerapi.Pxe, rawImageFile, outputImageDir, outputImageBase string, outputPXEArtifactsDir string,
+	buildResources BuildResources) (carryOverReport *isoCarryOverReport, err error) {
 
 	additionalIsoFiles, extraCommandLine, err := micIsoConfigToIsoMakerConfig(baseConfigPath, isoConfig)
 	if err != nil {
-		return fmt.Errorf("failed to convert iso configuration to isomaker format:\n%w", err)
+		return nil, fmt.Errorf("failed to convert iso configuration to isomaker format:\n%w", err)
 	}
 
 	pxeIsoImageBaseUrl := ""
@@ -1212,38 +1779,23 @@ func createLiveOSIsoImage(buildDir, baseConfigPath string, inputIsoArtifacts *Li
 		pxeIsoImageFileUrl = pxeConfig.IsoImageFileUrl
 	}
 
-	isoBuildDir := filepath.Join(buildDir, "tmp")
-	isoArtifactsDir := filepath.Join(isoBuildDir, "artifacts")
-	// IsoMaker needs its own folder to work in (it starts by deleting and re-creating it).
-	isomakerBuildDir := filepath.Join(isoBuildDir, "isomaker-tmp")
+	workingDirs, err := newIsoWorkingDirs(buildDir)
+	if err != nil {
+		return nil, err
+	}
 
 	isoBuilder := &LiveOSIsoBuilder{
-		//
-		// buildDir (might be shared with other build tools)
-		//  |--tmp   (LiveOSIsoBuilder specific)
-		//     |--<various mount points>
-		//     |--artifacts        (extracted and generated artifacts)
-		//     |--isomaker-tmp     (used exclusively by isomaker)
-		//
-		workingDirs: IsoWorkingDirs{
-			isoBuildDir:      isoBuildDir,
-			isoArtifactsDir:  isoArtifactsDir,
-			isomakerBuildDir: isomakerBuildDir,
-		},
+		workingDirs: workingDirs,
 		artifacts: IsoArtifacts{
-			savedConfigsFilePath: filepath.Join(isoArtifactsDir, savedConfigsDir, savedConfigsFileName),
+			savedConfigsFilePath: filepath.Join(workingDirs.isoArtifactsDir, savedConfigsDir, savedConfigsFileName),
 		},
+		liveOSDir:      defaultLiveOSDir,
+		liveOSImage:    defaultLiveOSImage,
+		buildResources: buildResources,
 	}
-	defer func() {
-		cleanupErr := os.RemoveAll(isoBuilder.workingDirs.isoBuildDir)
-		if cleanupErr != nil {
-			if err != nil {
-				err = fmt.Errorf("%w:\nfailed to clean-up (%s): %w", err, isoBuilder.workingDirs.isoBuildDir, cleanupErr)
-			} else {
-				err = fmt.Errorf("failed to clean-up (%s): %w", isoBuilder.workingDirs.isoBuildDir, cleanupErr)
-			}
-		}
-	}()
+	defer isoBuilder.cleanups.runDeferred(&err)
+
+	isoBuilder.cleanups.addDir(workingDirs.isoBuildDir)
 
 	// if there is an input iso, make sure to pick-up it's saved kernel args
 	// file.
@@ -1252,39 +1804,40 @@ func createLiveOSIsoImage(buildDir, baseConfigPath string, inputIsoArtifacts *Li
 		inputSavedConfigsFilePath = inputIsoArtifacts.artifacts.savedConfigsFilePath
 	}
 
-	err = isoBuilder.prepareArtifactsFromFullImage(inputSavedConfigsFilePath, rawImageFile, extraCommandLine, pxeIsoImageBaseUrl, pxeIsoImageFileUrl, outputImageBase)
+	// Unless overridden by the configuration, keep the media layout of the
+	// input iso.
+	if inputIsoArtifacts != nil {
+		isoBuilder.liveOSDir = inputIsoArtifacts.liveOSDir
+		isoBuilder.liveOSImage = inputIsoArtifacts.liveOSImage
+	}
+
+	err = isoBuilder.applyIsoConfig(baseConfigPath, isoConfig)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	isoBuilder.applyPxeConfig(baseConfigPath, pxeConfig)
+
+	err = isoBuilder.prepareArtifactsFromFullImage(ctx, inputSavedConfigsFilePath, rawImageFile, extraCommandLine, pxeIsoImageBaseUrl, pxeIsoImageFileUrl, outputImageBase)
+	if err != nil {
+		return nil, err
 	}
 
 	// If we started from an input iso (not an input vhd(x)/qcow), then there
 	// might be additional files that are not defined in the current user
-	// configuration. Below, we loop through the files we have captured so far
-	// and append any file that was in the input iso and is not included
-	// already. This also ensures that no file from the input iso overwrites
-	// a newer version that has just been created.
+	// configuration. The iso configuration's carry-over rules decide which of
+	// them are merged with the files that have just been created.
 	if inputIsoArtifacts != nil {
-		for inputSourceFile, inputTargetFile := range inputIsoArtifacts.artifacts.additionalFiles {
-			found := false
-			for _, targetFile := range isoBuilder.artifacts.additionalFiles {
-				if inputTargetFile == targetFile {
-					found = true
-					break
-				}
-			}
-
-			if !found {
-				isoBuilder.artifacts.additionalFiles[inputSourceFile] = inputTargetFile
-			}
-		}
+		carryOverReport = carryOverIsoFiles(isoCarryOverRules(isoConfig), inputIsoArtifacts.artifacts.additionalFiles,
+			isoBuilder.artifacts.additionalFiles, additionalIsoFiles, isoBuilder.artifacts.carriedOverFiles)
 	}
 
-	err = isoBuilder.createIsoImageAndPXEFolder(additionalIsoFiles, outputImageDir, outputImageBase, outputPXEArtifactsDir)
+	err = isoBuilder.createIsoImageAndPXEFolder(ctx, additionalIsoFiles, outputImageDir, outputImageBase, outputPXEArtifactsDir)
 	if err != nil {
-		return fmt.Errorf("failed to generate iso image and/or PXE artifacts folder\n%w", err)
+		return nil, fmt.Errorf("failed to generate iso image and/or PXE artifacts folder\n%w", err)
 	}
 
-	return nil
+	return carryOverReport, nil
 }
 
 // extractIsoImageContents
@@ -1305,32 +1858,36 @@ func createLiveOSIsoImage(buildDir, baseConfigPath string, inputIsoArtifacts *Li
 //
 //   - creates a local folder with the same structure and contents as the provided
 //     iso image.
-func extractIsoImageContents(buildDir string, isoImageFile string, isoExpansionFolder string) (err error) {
+func extractIsoImageContents(ctx context.Context, buildDir string, isoImageFile string, isoExpansionFolder string,
+	buildResources BuildResources) (err error) {
+	cleanups := &cleanupManager{}
+	defer cleanups.runDeferred(&err)
+
 	mountDir, err := os.MkdirTemp(buildDir, "tmp-iso-mount-")
 	if err != nil {
 		return fmt.Errorf("failed to create temporary mount folder for iso:\n%w", err)
 	}
-	defer os.RemoveAll(mountDir)
+	cleanups.addDir(mountDir)
 
 	isoImageLoopDevice, err := safeloopback.NewLoopback(isoImageFile)
 	if err != nil {
 		return fmt.Errorf("failed to create loop device for (%s):\n%w", isoImageFile, err)
 	}
-	defer isoImageLoopDevice.Close()
+	cleanups.addLoopback(isoImageLoopDevice)
 
 	isoImageMount, err := safemount.NewMount(isoImageLoopDevice.DevicePath(), mountDir,
 		"iso9660" /*fstype*/, unix.MS_RDONLY /*flags*/, "" /*data*/, false /*makeAndDelete*/)
 	if err != nil {
 		return err
 	}
-	defer isoImageMount.Close()
+	cleanups.addMount(isoImageMount)
 
 	err = os.MkdirAll(isoExpansionFolder, os.ModePerm)
 	if err != nil {
 		return fmt.Errorf("failed to create folder %s:\n%w", isoExpansionFolder, err)
 	}
 
-	err = copyPartitionFiles(mountDir+"/.", isoExpansionFolder)
+	err = copyPartitionFiles(ctx, mountDir+"/.", isoExpansionFolder, buildResources)
 	if err != nil {
 		return fmt.Errorf("failed to copy iso image contents to a writeable folder (%s):\n%w", isoExpansionFolder, err)
 	}
@@ -1362,35 +1919,29 @@ func extractIsoImageContents(buildDir string, isoImageFile string, isoExpansionF
 //     the absolute path of 'buildDir'.
 //   - 'isoImageFile'
 //     the source iso image file to extract/scan.
+//   - 'buildResources'
+//     the resource limits of the build steps.
 //
 // outputs:
 //
 //   - returns an instance of LiveOSIsoBuilder populated with all the paths of the
 //     extracted contents.
-func createIsoBuilderFromIsoImage(buildDir string, buildDirAbs string, isoImageFile string) (isoBuilder *LiveOSIsoBuilder, err error) {
+func createIsoBuilderFromIsoImage(ctx context.Context, buildDir string, buildDirAbs string, isoImageFile string,
+	buildResources BuildResources) (isoBuilder *LiveOSIsoBuilder, err error) {
 
-	isoBuildDir := filepath.Join(buildDir, "tmp")
-	isoArtifactsDir := filepath.Join(isoBuildDir, "artifacts")
-	// IsoMaker needs its own folder to work in (it starts by deleting and re-creating it).
-	isomakerBuildDir := filepath.Join(isoBuildDir, "isomaker-tmp")
+	workingDirs, err := newIsoWorkingDirs(buildDir)
+	if err != nil {
+		return nil, err
+	}
 
 	isoBuilder = &LiveOSIsoBuilder{
-		//
-		// buildDir (might be shared with other build tools)
-		//  |--tmp   (LiveOSIsoBuilder specific)
-		//     |--<various mount points>
-		//     |--artifacts        (extracted and generated artifacts)
-		//     |--isomaker-tmp     (used exclusively by isomaker)
-		//
-		workingDirs: IsoWorkingDirs{
-			isoBuildDir:     isoBuildDir,
-			isoArtifactsDir: isoArtifactsDir,
-			// IsoMaker needs its own folder to work in (it starts by deleting and re-creating it).
-			isomakerBuildDir: isomakerBuildDir,
-		},
+		workingDirs: workingDirs,
 		artifacts: IsoArtifacts{
-			savedConfigsFilePath: filepath.Join(isoArtifactsDir, savedConfigsDir, savedConfigsFileName),
+			savedConfigsFilePath: filepath.Join(workingDirs.isoArtifactsDir, savedConfigsDir, savedConfigsFileName),
 		},
+		liveOSDir:      defaultLiveOSDir,
+		liveOSImage:    defaultLiveOSImage,
+		buildResources: buildResources,
 	}
 	defer func() {
 		if err != nil {
@@ -1401,21 +1952,16 @@ func createIsoBuilderFromIsoImage(buildDir string, buildDirAbs string, isoImageF
 		}
 	}()
 
-	// create iso build folder
-	err = os.MkdirAll(isoBuildDir, os.ModePerm)
-	if err != nil {
-		return isoBuilder, fmt.Errorf("failed to create folder %s:\n%w", isoBuildDir, err)
-	}
-	isoBuilder.addCleanupDir(isoBuildDir)
+	isoBuilder.cleanups.addDir(workingDirs.isoBuildDir)
 
 	// extract iso contents
 	isoExpansionFolder, err := os.MkdirTemp(buildDirAbs, "expanded-input-iso-")
 	if err != nil {
 		return isoBuilder, fmt.Errorf("failed to create a temporary iso expansion folder for iso:\n%w", err)
 	}
-	isoBuilder.addCleanupDir(isoExpansionFolder)
+	isoBuilder.cleanups.addDir(isoExpansionFolder)
 
-	err = extractIsoImageContents(buildDir, isoImageFile, isoExpansionFolder)
+	err = extractIsoImageContents(ctx, buildDir, isoImageFile, isoExpansionFolder, buildResources)
 	if err != nil {
 		return isoBuilder, fmt.Errorf("failed to extract iso contents from input iso file:\n%w", err)
 	}
@@ -1425,10 +1971,35 @@ func createIsoBuilderFromIsoImage(buildDir string, buildDirAbs string, isoImageF
 		return isoBuilder, fmt.Errorf("failed to enumerate expanded iso files under %s:\n%w", isoExpansionFolder, err)
 	}
 
+	// The saved configs hold the media layout of the input iso. They need to
+	// be loaded before the rootfs image can be located.
+	savedConfigs, err := loadSavedConfigs(filepath.Join(isoExpansionFolder, savedConfigsDir, savedConfigsFileName))
+	if err != nil {
+		return isoBuilder, fmt.Errorf("failed to load saved configurations of input iso:\n%w", err)
+	}
+
+	if savedConfigs != nil {
+		if savedConfigs.Iso.LiveOSDir != "" {
+			isoBuilder.liveOSDir = savedConfigs.Iso.LiveOSDir
+		}
+		if savedConfigs.Iso.LiveOSImageName != "" {
+			isoBuilder.liveOSImage = savedConfigs.Iso.LiveOSImageName
+		}
+	}
+
+	liveOSImageMediaPath := filepath.Join("/", isoBuilder.liveOSDir, isoBuilder.liveOSImage)
+
+	liveOSGeneratedFiles := []string(nil)
+	if savedConfigs != nil {
+		liveOSGeneratedFiles = savedConfigs.Iso.LiveOSGeneratedFiles
+	}
+
 	isoBuilder.artifacts.additionalFiles = make(map[string]string)
+	isoBuilder.artifacts.carriedOverFiles = make(map[string]bool)
 
 	for _, isoFile := range isoFiles {
 		fileName := filepath.Base(isoFile)
+		mediaPath := strings.TrimPrefix(isoFile, isoExpansionFolder)
 
 		scheduleAdditionalFile := true
 
@@ -1456,11 +2027,6 @@ func createIsoBuilderFromIsoImage(buildDir string, buildDirAbs string, isoImageF
 			isoBuilder.artifacts.pxeGrubCfgPath = filepath.Join(filepath.Dir(isoBuilder.artifacts.isoGrubCfgPath), pxeGrubCfg)
 			// grub.cfg is passed as a parameter to isomaker.
 			scheduleAdditionalFile = false
-		case liveOSImage:
-			isoBuilder.artifacts.squashfsImagePath = isoFile
-			// the squashfs image file is added to the additional file list
-			// by a different part of the code
-			scheduleAdditionalFile = false
 		case initrdImage:
 			isoBuilder.artifacts.initrdImagePath = isoFile
 			// initrd.img is passed as a parameter to isomaker.
@@ -1469,6 +2035,19 @@ func createIsoBuilderFromIsoImage(buildDir string, buildDirAbs string, isoImageF
 			isoBuilder.artifacts.savedConfigsFilePath = isoFile
 			scheduleAdditionalFile = false
 		}
+		if mediaPath == liveOSImageMediaPath {
+			isoBuilder.artifacts.squashfsImagePath = isoFile
+			// the squashfs image file is added to the additional file list
+			// by a different part of the code
+			scheduleAdditionalFile = false
+		} else if isGeneratedIsoMediaPath(mediaPath, isoBuilder.liveOSDir, liveOSGeneratedFiles) {
+			// additional rootfs images (and the slots manifest) are not
+			// carried over from the input iso since their grub menu entries
+			// are not carried over either. They need to be specified again in
+			// the iso configuration. User files under the liveOSDir are
+			// carried over.
+			scheduleAdditionalFile = false
+		}
 		if strings.HasPrefix(fileName, vmLinuzPrefix) {
 			isoBuilder.artifacts.vmlinuzPath = isoFile
 			// isomaker will extract this from initrd and copy it to include it
@@ -1478,7 +2057,16 @@ func createIsoBuilderFromIsoImage(buildDir string, buildDirAbs string, isoImageF
 		}
 
 		if scheduleAdditionalFile {
-			isoBuilder.artifacts.additionalFiles[isoFile] = strings.TrimPrefix(isoFile, isoExpansionFolder)
+			isoBuilder.artifacts.additionalFiles[isoFile] = mediaPath
+			isoBuilder.artifacts.carriedOverFiles[isoFile] = true
+		}
+	}
+
+	if savedConfigs != nil {
+		err = verifyIsoFileHashes(isoExpansionFolder, isoBuilder.artifacts.additionalFiles,
+			savedConfigs.Iso.AdditionalFilesSha256)
+		if err != nil {
+			return isoBuilder, fmt.Errorf("failed to verify the files of the input iso:\n%w", err)
 		}
 	}
 
@@ -1514,14 +2102,15 @@ func createIsoBuilderFromIsoImage(buildDir string, buildDirAbs string, isoImageF
 // outputs:
 //
 //   - creates an iso image.
-func (b *LiveOSIsoBuilder) createImageFromUnchangedOS(baseConfigPath string, isoConfig *imagecustomizerapi.Iso,
-	pxeConfig *imagecustomizerapi.Pxe, outputImageDir string, outputImageBase string, outputPXEArtifactsDir string) error {
+func (b *LiveOSIsoBuilder) createImageFromUnchangedOS(ctx context.Context, baseConfigPath string, isoConfig *imagecustomizerapi.Iso,
+	pxeConfig *imagecustomizerapi.Pxe, outputImageDir string, outputImageBase string, outputPXEArtifactsDir string,
+) (*isoCarryOverReport, error) {
 
 	logger.Log.Infof("Creating LiveOS iso image using unchanged OS partitions")
 
 	additionalIsoFiles, extraCommandLine, err := micIsoConfigToIsoMakerConfig(baseConfigPath, isoConfig)
 	if err != nil {
-		return fmt.Errorf("failed to convert iso configuration to isomaker configuration format:\n%w", err)
+		return nil, fmt.Errorf("failed to convert iso configuration to isomaker configuration format:\n%w", err)
 	}
 
 	pxeIsoImageBaseUrl := ""
@@ -1534,10 +2123,15 @@ func (b *LiveOSIsoBuilder) createImageFromUnchangedOS(baseConfigPath string, iso
 		pxeIsoImageFileUrl = pxeConfig.IsoImageFileUrl
 	}
 
+	pxeBootImage := imagecustomizerapi.PxeBootImageDefault
+	if pxeConfig != nil {
+		pxeBootImage = pxeConfig.BootImage
+	}
+
 	updatedSavedConfigs, err := updateSavedConfigs(b.artifacts.savedConfigsFilePath, extraCommandLine, pxeIsoImageBaseUrl,
-		pxeIsoImageFileUrl, b.artifacts.dracutPackageInfo)
+		pxeIsoImageFileUrl, pxeBootImage, b.artifacts.dracutPackageInfo)
 	if err != nil {
-		return fmt.Errorf("failed to combine saved configurations with new configuration:\n%w", err)
+		return nil, fmt.Errorf("failed to combine saved configurations with new configuration:\n%w", err)
 	}
 
 	// Need to populate the dracut package information from the saved copy
@@ -1545,17 +2139,35 @@ func (b *LiveOSIsoBuilder) createImageFromUnchangedOS(baseConfigPath string, iso
 	// such information.
 	b.artifacts.dracutPackageInfo = updatedSavedConfigs.OS.DracutPackageInfo
 
+	err = b.applyIsoConfig(baseConfigPath, isoConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	b.applyPxeConfig(baseConfigPath, pxeConfig)
+
+	// The boot image may come from a previous run.
+	b.pxeBootImage = updatedSavedConfigs.Pxe.BootImage
+
+	// All of the files are from the input iso. The iso configuration's
+	// carry-over rules decide which of them are kept.
+	inputFiles := b.artifacts.additionalFiles
+	b.artifacts.additionalFiles = make(map[string]string)
+	b.artifacts.carriedOverFiles = make(map[string]bool)
+	carryOverReport := carryOverIsoFiles(isoCarryOverRules(isoConfig), inputFiles, b.artifacts.additionalFiles,
+		additionalIsoFiles, b.artifacts.carriedOverFiles)
+
 	err = b.updateGrubCfg(b.artifacts.isoGrubCfgPath, b.artifacts.pxeGrubCfgPath, updatedSavedConfigs, outputImageBase)
 	if err != nil {
-		return fmt.Errorf("failed to update grub.cfg:\n%w", err)
+		return nil, fmt.Errorf("failed to update grub.cfg:\n%w", err)
 	}
 
-	err = b.createIsoImageAndPXEFolder(additionalIsoFiles, outputImageDir, outputImageBase, outputPXEArtifactsDir)
+	err = b.createIsoImageAndPXEFolder(ctx, additionalIsoFiles, outputImageDir, outputImageBase, outputPXEArtifactsDir)
 	if err != nil {
-		return fmt.Errorf("failed to generate iso image and/or PXE artifacts folder\n%w", err)
+		return nil, fmt.Errorf("failed to generate iso image and/or PXE artifacts folder\n%w", err)
 	}
 
-	return nil
+	return carryOverReport, nil
 }
 
 // createIsoImageAndPXEFolder
@@ -1581,9 +2193,9 @@ func (b *LiveOSIsoBuilder) createImageFromUnchangedOS(baseConfigPath string, iso
 //
 //   - create an iso image.
 //   - creates a folder with PXE artifacts.
-func (b *LiveOSIsoBuilder) createIsoImageAndPXEFolder(additionalIsoFiles []safechroot.FileToCopy, outputImageDir string,
+func (b *LiveOSIsoBuilder) createIsoImageAndPXEFolder(ctx context.Context, additionalIsoFiles []safechroot.FileToCopy, outputImageDir string,
 	outputImageBase string, outputPXEArtifactsDir string) error {
-	isoImagePath, err := b.createIsoImage(additionalIsoFiles, outputImageDir, outputImageBase)
+	isoImagePath, isoMediaFiles, err := b.createIsoImage(ctx, additionalIsoFiles, outputImageDir, outputImageBase)
 	if err != nil {
 		return err
 	}
@@ -1593,10 +2205,59 @@ func (b *LiveOSIsoBuilder) createIsoImageAndPXEFolder(additionalIsoFiles []safec
 		if err != nil {
 			return fmt.Errorf("cannot generate the PXE artifacts folder.\n%w", err)
 		}
-		err = populatePXEArtifactsDir(isoImagePath, b.workingDirs.isoBuildDir, outputPXEArtifactsDir, outputImageBase)
+
+		err = verifyInitrdPXESupport(b.artifacts.initrdImagePath, b.artifacts.dracutPackageInfo)
+		if err != nil {
+			return fmt.Errorf("cannot generate the PXE artifacts folder.\n%w", err)
+		}
+
+		logger.Log.Infof("Copying PXE artifacts to (%s)", outputPXEArtifactsDir)
+
+		// Ensure output folder is clean.
+		err = os.RemoveAll(outputPXEArtifactsDir)
+		if err != nil {
+			return fmt.Errorf("failed to remove (%s):\n%w", outputPXEArtifactsDir, err)
+		}
+
+		canStage, err := b.canStagePXEArtifacts()
 		if err != nil {
 			return err
 		}
+
+		if canStage {
+			err = b.stagePXEArtifactsDir(isoMediaFiles, outputPXEArtifactsDir)
+		} else {
+			// The builder doesn't know where some of the iso media files come
+			// from (e.g. the kernel of an input iso is only in its initrd). So,
+			// fall back to extracting them from the iso image.
+			err = extractPXEArtifactsFromIso(ctx, isoImagePath, b.workingDirs.isoBuildDir, outputPXEArtifactsDir,
+				b.buildResources)
+		}
+		if err != nil {
+			return err
+		}
+
+		err = populatePXEArtifactsDir(isoImagePath, outputPXEArtifactsDir, outputImageBase, b.releaseInfo,
+			b.pxeBootImage, b.liveOSDir, b.liveOSImage, b.pxeArtifactsBaseUrl, b.pxeAnswerFiles, b.pxeHostOverrides,
+			b.pxeArtifactsLayout, b.pxelinuxConfig, b.pxeHttpBoot)
+		if err != nil {
+			return err
+		}
+
+		if b.pxeIpxeScript != nil {
+			err = writePxeIpxeScript(outputPXEArtifactsDir, b.pxeIpxeScript)
+			if err != nil {
+				return err
+			}
+		}
+
+		if b.pxeArtifactsArchive != imagecustomizerapi.PxeArtifactsArchiveFormatNone {
+			_, err = createPxeArtifactsArchive(outputPXEArtifactsDir,
+				getImageNameFromImageBaseName(outputImageBase, b.releaseInfo), b.pxeArtifactsArchive, b.sourceDateEpoch)
+			if err != nil {
+				return err
+			}
+		}
 	}
 
 	return nil
@@ -1604,160 +2265,301 @@ func (b *LiveOSIsoBuilder) createIsoImageAndPXEFolder(additionalIsoFiles []safec
 
 // populatePXEArtifactsDir
 //
-//   - This function takes in an liveos iso, and extracts its artifacts unto a
-//     folder for easier copying to a PXE server later by the user.
-//   - It also renames the liveos iso grub-pxe.cfg to grub.cfg.
+//   - This function takes in a PXE artifacts folder staged from a liveos iso
+//     (see stagePXEArtifactsDir and extractPXEArtifactsFromIso), and applies
+//     the PXE configuration to it.
+//   - It also places the liveos iso in the folder, if it is the image that is
+//     downloaded at boot time.
 //
 // inputs:
 //
 //   - 'isoImagePath':
 //     path to a liveos iso image.
-//   - 'buildDir'
-//     path to a directory to hold intermediate files.
 //   - 'outputPXEArtifactsDir'
-//     path to the output directory where the extract artifacts will be saved to.
+//     path to the staged PXE artifacts folder.
 //   - 'outputImageBase':
 //     base name of the image to generate. The generated name will be on the
 //     form: {outputImageDir}/{outputImageBase}.iso
+//   - 'releaseInfo':
+//     the release version and the tag added to the generated iso name.
+//   - 'bootImage':
+//     the image that is downloaded at boot time. The iso image is placed in
+//     the PXE artifacts folder only if it is the boot image.
+//   - 'liveOSDir', 'liveOSImage':
+//     the location of the main rootfs image on the iso media.
+//   - 'pxeArtifactsBaseUrl':
+//     the URL of the PXE artifacts folder on the server.
+//   - 'answerFiles':
+//     the answer files to host in the PXE artifacts folder.
+//   - 'hostOverrides':
+//     the per-host (or per-class) kernel arguments.
+//   - 'layout':
+//     the layout of the files in the PXE artifacts folder.
+//   - 'pxelinuxConfig':
+//     whether to also generate a PXELINUX configuration from the PXE
+//     grub.cfg.
 //
 // outputs:
 //
 //   - creates a folder with PXE artifacts.
-func populatePXEArtifactsDir(isoImagePath string, buildDir string, outputPXEArtifactsDir string, outputImageBase string) error {
-
-	logger.Log.Infof("Copying PXE artifacts to (%s)", outputPXEArtifactsDir)
+func populatePXEArtifactsDir(isoImagePath string, outputPXEArtifactsDir string, outputImageBase string,
+	releaseInfo isoReleaseInfo, bootImage imagecustomizerapi.PxeBootImage, liveOSDir string, liveOSImage string,
+	pxeArtifactsBaseUrl string, answerFiles []pxeAnswerFile, hostOverrides []imagecustomizerapi.PxeHostOverride,
+	layout imagecustomizerapi.PxeArtifactsLayout, pxelinuxConfig bool, httpBoot bool,
+) error {
+	isoImageNameInfo := getImageNameFromImageBaseName(outputImageBase, releaseInfo)
 
-	// Ensure output folder is clean.
-	err := os.RemoveAll(outputPXEArtifactsDir)
-	if err != nil {
-		return fmt.Errorf("failed to remove (%s):\n%w", outputPXEArtifactsDir, err)
+	var err error
+	if len(answerFiles) > 0 {
+		err = applyPxeAnswerFiles(outputPXEArtifactsDir, pxeArtifactsBaseUrl, answerFiles)
+		if err != nil {
+			return err
+		}
 	}
 
-	// Extract all files from the iso image file.
-	err = extractIsoImageContents(buildDir, isoImagePath, outputPXEArtifactsDir)
-	if err != nil {
-		return err
+	if len(hostOverrides) > 0 {
+		err = applyPxeHostOverrides(outputPXEArtifactsDir, hostOverrides)
+		if err != nil {
+			return err
+		}
 	}
 
-	// Replace the iso grub.cfg with the PXE grub.cfg
-	isoGrubCfgPath := filepath.Join(outputPXEArtifactsDir, grubCfgDir, isoGrubCfg)
-	pxeGrubCfgPath := filepath.Join(outputPXEArtifactsDir, grubCfgDir, pxeGrubCfg)
-	err = file.Copy(pxeGrubCfgPath, isoGrubCfgPath)
-	if err != nil {
-		return fmt.Errorf("failed to copy (%s) to (%s) while populating the PXE artifacts directory:\n%w", pxeGrubCfgPath, isoGrubCfgPath, err)
+	if layout == imagecustomizerapi.PxeArtifactsLayoutTftp {
+		err = applyPxeTftpLayout(outputPXEArtifactsDir, isoImageNameInfo)
+		if err != nil {
+			return err
+		}
 	}
 
-	err = os.RemoveAll(pxeGrubCfgPath)
-	if err != nil {
-		return fmt.Errorf("failed to remove file (%s):\n%w", pxeGrubCfgPath, err)
+	if pxelinuxConfig {
+		err = writePxelinuxConfig(outputPXEArtifactsDir)
+		if err != nil {
+			return err
+		}
 	}
 
-	// Move bootloader files from under '<pxe-folder>/efi/boot' to '<pxe-folder>/'
-	bootloaderSrcDir := filepath.Join(outputPXEArtifactsDir, isoBootloadersDir)
-	bootloaderFiles := []string{bootx64Binary, grubx64Binary}
-	for _, bootloaderFile := range bootloaderFiles {
-		sourcePath := filepath.Join(bootloaderSrcDir, bootloaderFile)
-		targetPath := filepath.Join(outputPXEArtifactsDir, bootloaderFile)
-		err = file.Move(sourcePath, targetPath)
+	if httpBoot {
+		err = prepareUefiHttpBoot(outputPXEArtifactsDir, pxeArtifactsBaseUrl)
 		if err != nil {
-			return fmt.Errorf("failed to move boot loader file from (%s) to (%s) while generated the PXE artifacts folder:\n%w", sourcePath, targetPath, err)
+			return err
 		}
 	}
 
-	// Remove the empty 'pxe-folder>/efi' folder.
-	isoEFIDir := filepath.Join(outputPXEArtifactsDir, "efi")
-	err = os.RemoveAll(isoEFIDir)
-	if err != nil {
-		return fmt.Errorf("failed to remove folder (%s):\n%w", isoEFIDir, err)
+	// The image that dracut livenet module will download. The rootfs image
+	// is already in the PXE folder (under the liveOSDir).
+	liveImagePath := filepath.Join(liveOSDir, liveOSImage)
+	if bootImage != imagecustomizerapi.PxeBootImageRootfs {
+		// The iso image file itself must be placed in the PXE folder because
+		// dracut livenet module will download it.
+		liveImagePath = isoImageNameInfo.name
+		artifactsIsoImagePath := filepath.Join(outputPXEArtifactsDir, isoImageNameInfo.name)
+		err = file.Copy(isoImagePath, artifactsIsoImagePath)
+		if err != nil {
+			return fmt.Errorf("failed to copy (%s) while populating the PXE artifacts directory:\n%w", isoImagePath, err)
+		}
 	}
 
-	// The iso image file itself must be placed in the PXE folder because
-	// dracut livenet module will download it.
-	artifactsIsoImagePath := filepath.Join(outputPXEArtifactsDir, getImageNameFromImageBaseName(outputImageBase).name)
-	err = file.Copy(isoImagePath, artifactsIsoImagePath)
+	err = warnPxeArtifactsSizes(outputPXEArtifactsDir, liveImagePath)
 	if err != nil {
-		return fmt.Errorf("failed to copy (%s) while populating the PXE artifacts directory:\n%w", isoImagePath, err)
+		return err
 	}
 
 	return nil
 }
 
-// getSizeOnDiskInBytes
+// estimateIsoBuildSpaceInBytes
 //
-//   - given a folder, it calculates the total size in bytes of its contents.
+//   - given the size of the rootfs contents, it estimates the disk space
+//     needed in the build directory to produce the LiveOS iso.
+//   - the estimate accounts for a writeable copy of the rootfs, the squashfs
+//     image (assuming no compression to stay on the safe side), the initrd
+//     image, and the iso image (which holds both the squashfs and the initrd
+//     images).
 //
 // inputs:
 //
-//   - 'rootDir':
-//     root folder to calculate its size.
+//   - 'rootfsSizeInBytes':
+//     the size of the rootfs contents.
 //
 // outputs:
 //
-//   - returns the size in bytes.
-func getSizeOnDiskInBytes(rootDir string) (size uint64, err error) {
-	logger.Log.Debugf("Calculating total size for (%s)", rootDir)
+//   - returns the estimated size in bytes.
+func estimateIsoBuildSpaceInBytes(rootfsSizeInBytes uint64) uint64 {
+	rootfsCopySize := rootfsSizeInBytes
+	squashfsSize := rootfsSizeInBytes
+	initrdSize := uint64(initrdImageSizeEstimate)
+	isoSize := squashfsSize + initrdSize
 
-	duStdout, _, err := shell.Execute("du", "-s", rootDir)
+	return rootfsCopySize + squashfsSize + initrdSize + isoSize
+}
+
+// availableDiskSpaceFunc returns the number of bytes available on the file
+// system holding the specified path.
+type availableDiskSpaceFunc func(path string) (uint64, error)
+
+// getAvailableDiskSpaceInBytes
+//
+//   - given a path, it returns the number of bytes available to unprivileged
+//     users on the file system holding that path.
+func getAvailableDiskSpaceInBytes(path string) (size uint64, err error) {
+	var stat unix.Statfs_t
+	err = unix.Statfs(path, &stat)
 	if err != nil {
-		return 0, fmt.Errorf("failed to find the size of the specified folder using 'du' for (%s):\n%w", rootDir, err)
+		return 0, fmt.Errorf("failed to read file system stats (%s):\n%w", path, err)
 	}
 
-	// parse and get count and unit
-	diskSizeRegex := regexp.MustCompile(`^(\d+)\s+`)
-	matches := diskSizeRegex.FindStringSubmatch(duStdout)
-	if matches == nil || len(matches) < 2 {
-		return 0, fmt.Errorf("failed to parse 'du -s' output (%s).", duStdout)
-	}
+	return stat.Bavail * uint64(stat.Bsize), nil
+}
+
+// checkIsoBuildDiskSpace
+//
+//   - estimates the disk space needed to build the LiveOS iso and fails if the
+//     file system holding the build directory does not have enough free space.
+//   - this allows the build to fail early instead of running out of space
+//     while copying the rootfs or creating the squashfs image.
+//
+// inputs:
+//
+//   - 'isoBuildDir':
+//     path to the folder where intermediate files will be placed.
+//   - 'rootfsSizeInfo':
+//     the size of the rootfs to be converted to a LiveOS iso.
+//   - 'getAvailableSpace':
+//     returns the free space of the file system holding the build directory
+//     (i.e. getAvailableDiskSpaceInBytes).
+func checkIsoBuildDiskSpace(isoBuildDir string, rootfsSizeInfo dirSizeInfo,
+	getAvailableSpace availableDiskSpaceFunc,
+) error {
+	rootfsSize := rootfsSizeInfo.diskSize
+
+	requiredSize := estimateIsoBuildSpaceInBytes(rootfsSize)
 
-	sizeInKbsString := matches[1]
-	sizeInKbs, err := strconv.ParseUint(sizeInKbsString, 10, 64)
+	availableSize, err := getAvailableSpace(isoBuildDir)
 	if err != nil {
-		return 0, fmt.Errorf("failed to parse disk size (%d):\n%w", sizeInKbs, err)
+		return fmt.Errorf("failed to check available disk space for the iso build directory:\n%w", err)
 	}
 
-	return sizeInKbs * diskutils.KiB, nil
+	logger.Log.Debugf("Disk space required for the iso build is estimated at (%s), (%s) available on (%s)",
+		humanReadableDiskSize(int64(requiredSize)), humanReadableDiskSize(int64(availableSize)), isoBuildDir)
+
+	if availableSize < requiredSize {
+		return fmt.Errorf("not enough free disk space to build the iso:\n"+
+			"build directory (%s) has (%s) available, but an estimated (%s) is needed for a rootfs of (%s)\n"+
+			"largest rootfs directories:\n%s",
+			isoBuildDir, humanReadableDiskSize(int64(availableSize)), humanReadableDiskSize(int64(requiredSize)),
+			humanReadableDiskSize(int64(rootfsSize)), rootfsSizeInfo.topLevelBreakdown(maxSizeBreakdownEntries))
+	}
+
+	return nil
+}
+
+// extJournalSizeInBytes
+//
+//   - returns the size of the journal mkfs.ext4 creates by default for a
+//     file system of the specified size (see ext2fs_default_journal_size).
+func extJournalSizeInBytes(fileSystemSizeInBytes uint64) uint64 {
+	blocks := fileSystemSizeInBytes / extBlockSize
+
+	var journalBlocks uint64
+	switch {
+	case blocks < 2048:
+		journalBlocks = 0
+	case blocks < 32768:
+		journalBlocks = 1024
+	case blocks < 256*1024:
+		journalBlocks = 4096
+	case blocks < 512*1024:
+		journalBlocks = 8192
+	case blocks < 4096*1024:
+		journalBlocks = 16384
+	case blocks < 8192*1024:
+		journalBlocks = 32768
+	case blocks < 16384*1024:
+		journalBlocks = 65536
+	case blocks < 32768*1024:
+		journalBlocks = 131072
+	default:
+		journalBlocks = 262144
+	}
+
+	return journalBlocks * extBlockSize
 }
 
-// getDiskSizeEstimateInMBs
+// estimateExtFileSystemSizeInBytes
 //
-//   - given a folder, it calculates the size of a disk image that can hold
-//     all of its contents.
-//   - The amount of disk space a file occupies depends on the block size of the
-//     host file system. If many files are smaller than a block size, there will
-//     be a lot of waste. If files are very large, there will be very little
-//     waste. It is hard to predict how much disk space a set of a files will
-//     occupy without enumerating the sizes of all the files and knowing the
-//     target block size. In this function, we use an optimistic approach which
-//     calculates the required disk space by multiplying the total file size by
-//     a safety factor - i.e. safe that it will be able t hold all the contents.
+//   - given the size information of a directory tree, it estimates the size
+//     of an ext4 file system that can hold a copy of that tree.
+//   - the estimate is based on the data blocks each file occupies on the
+//     target file system (and not on the source file system, which may use a
+//     different block size or compression), plus the space taken by the inode
+//     tables, the journal, and the other file system metadata.
+//   - the file system is also made large enough for mkfs to allocate an inode
+//     for every file in the tree.
 //
 // inputs:
 //
-//   - 'rootDir':
-//     root folder to calculate its size.
-//   - 'safetyFactor':
-//     a multiplier used with the total number of bytes calculated.
+//   - 'sizeInfo':
+//     the size information of the directory tree to be copied.
 //
 // outputs:
 //
-//   - returns the size in mega bytes.
-func getDiskSizeEstimateInMBs(rootDir string, safetyFactor float64) (size uint64, err error) {
+//   - returns the estimated file system size in bytes.
+func estimateExtFileSystemSizeInBytes(sizeInfo dirSizeInfo) uint64 {
+	usableFraction := 1 - float64(extInodeSize)/float64(extInodeRatio) - extMetadataOverheadFraction
+
+	// The journal size depends on the file system size. So, estimate the size
+	// without the journal first.
+	sizeInBytes := uint64(float64(sizeInfo.extAlignedSize) / usableFraction)
+	journalSize := extJournalSizeInBytes(sizeInBytes)
+	sizeInBytes = uint64(float64(sizeInfo.extAlignedSize+journalSize) / usableFraction)
+
+	// mkfs allocates one inode per extInodeRatio bytes of file system.
+	minSizeForInodes := (sizeInfo.entryCount + extReservedInodes) * extInodeRatio
+	sizeInBytes = max(sizeInBytes, minSizeForInodes)
 
-	sizeInBytes, err := getSizeOnDiskInBytes(rootDir)
+	return alignUp(sizeInBytes, diskutils.MiB)
+}
+
+// estimateWriteableImageSizeInBytes
+//
+//   - given the size information of a directory tree, it estimates the size
+//     of a disk image, with a boot partition and an ext4 rootfs partition,
+//     that can hold all of its contents.
+//
+// inputs:
+//
+//   - 'sizeInfo':
+//     the size information of the directory tree to be copied.
+//
+// outputs:
+//
+//   - returns the estimated disk size in bytes.
+func estimateWriteableImageSizeInBytes(sizeInfo dirSizeInfo) uint64 {
+	return writeableImageBootPartitionEnd + estimateExtFileSystemSizeInBytes(sizeInfo) + writeableImageGptFooterSize
+}
+
+// isFileSystemFull
+//
+//   - checks whether the file system holding the specified path has run out
+//     of blocks or inodes.
+func isFileSystemFull(path string) (bool, error) {
+	var stat unix.Statfs_t
+	err := unix.Statfs(path, &stat)
 	if err != nil {
-		return 0, fmt.Errorf("failed to get folder size on disk while estimating total disk size:\n%w", err)
+		return false, fmt.Errorf("failed to read file system stats (%s):\n%w", path, err)
 	}
 
-	sizeInMBs := sizeInBytes/diskutils.MiB + 1
-	estimatedSizeInMBs := uint64(float64(sizeInMBs) * safetyFactor)
-	return estimatedSizeInMBs, nil
+	freeSize := stat.Bfree * uint64(stat.Bsize)
+	return freeSize < writeableImageFullThreshold || stat.Ffree == 0, nil
 }
 
 // createWriteableImageFromSquashfs
 //
-//   - given a squashfs image file, it creates a writeable image with two
-//     partitions, and copies the contents of the squashfs unto that writeable
-//     image.
+//   - given a squashfs image file, it creates a writeable image, and copies
+//     the contents of the squashfs unto that writeable image.
+//   - if the storage configuration defines a disk, the writeable image uses
+//     that disk layout. Otherwise, it has a boot partition and an ext4 rootfs
+//     partition sized to fit the squashfs contents.
 //   - the squashfs image file must be extracted from a previously created
 //     LiveOS iso and is specified by the LiveOSIsoBuilder.artifacts.squashfsImagePath.
 //
@@ -1768,50 +2570,127 @@ func getDiskSizeEstimateInMBs(rootDir string, safetyFactor float64) (size uint64
 //   - 'rawImageFile':
 //     the name of the raw image to create and populate with the contents of
 //     the squashfs.
+//   - 'storage':
+//     the storage configuration holding the optional disk layout.
 //
 // outputs:
 //
 //   - creates the specified writeable image.
-func (b *LiveOSIsoBuilder) createWriteableImageFromSquashfs(buildDir, rawImageFile string) error {
+func (b *LiveOSIsoBuilder) createWriteableImageFromSquashfs(ctx context.Context, buildDir, rawImageFile string,
+	storage imagecustomizerapi.Storage,
+) (err error) {
 
 	logger.Log.Infof("Creating writeable image from squashfs (%s)", b.artifacts.squashfsImagePath)
 
+	cleanups := &cleanupManager{}
+	defer cleanups.runDeferred(&err)
+
 	// mount squash fs
 	squashMountDir, err := os.MkdirTemp(buildDir, "tmp-squashfs-mount-")
 	if err != nil {
 		return fmt.Errorf("failed to create temporary mount folder for squashfs:\n%w", err)
 	}
-	defer os.RemoveAll(squashMountDir)
+	cleanups.addDir(squashMountDir)
 
 	squashfsLoopDevice, err := safeloopback.NewLoopback(b.artifacts.squashfsImagePath)
 	if err != nil {
 		return fmt.Errorf("failed to create loop device for (%s):\n%w", b.artifacts.squashfsImagePath, err)
 	}
-	defer squashfsLoopDevice.Close()
+	cleanups.addLoopback(squashfsLoopDevice)
 
 	isoImageMount, err := safemount.NewMount(squashfsLoopDevice.DevicePath(), squashMountDir,
 		"squashfs" /*fstype*/, 0 /*flags*/, "" /*data*/, false /*makeAndDelete*/)
 	if err != nil {
 		return err
 	}
-	defer isoImageMount.Close()
+	cleanups.addMount(isoImageMount)
 
 	// estimate the new disk size
-	safeDiskSizeMB, err := getDiskSizeEstimateInMBs(squashMountDir, expansionSafetyFactor)
+	sizeInfo, err := getDirSize(squashMountDir, b.buildResources.maxParallelWorkers())
 	if err != nil {
 		return fmt.Errorf("failed to calculate the disk size of %s:\n%w", squashMountDir, err)
 	}
 
-	logger.Log.Debugf("safeDiskSizeMB = %d", safeDiskSizeMB)
+	diskSizeInBytes := estimateWriteableImageSizeInBytes(sizeInfo)
+
+	logger.Log.Debugf("Estimated writeable image size is (%s) for (%d) files of (%s)",
+		humanReadableDiskSize(int64(diskSizeInBytes)), sizeInfo.entryCount,
+		humanReadableDiskSize(int64(sizeInfo.apparentSize)))
+
+	// The writeable image takes the place of the rootfs copy of the iso build.
+	// So, fail early (i.e. before the writeable image is created) if the
+	// customized OS cannot be turned back into an iso.
+	err = checkIsoBuildDiskSpace(buildDir, sizeInfo, getAvailableDiskSpaceInBytes)
+	if err != nil {
+		return err
+	}
+
+	if len(storage.Disks) > 0 {
+		// Use the disk layout specified in the configuration.
+		diskConfig := storage.Disks[0]
+		if diskConfig.MaxSize != nil && uint64(*diskConfig.MaxSize) < diskSizeInBytes {
+			logger.Log.Warnf("Disk size (%s) is smaller than the estimated size (%s) needed to hold the squashfs contents",
+				humanReadableDiskSize(int64(*diskConfig.MaxSize)), humanReadableDiskSize(int64(diskSizeInBytes)))
+		}
+
+		_, err = createWriteableImage(ctx, buildDir, rawImageFile, squashMountDir, diskConfig, storage.FileSystems,
+			b.buildResources)
+		if err != nil {
+			return err
+		}
+	} else {
+		for attempt := 1; ; attempt++ {
+			diskConfig, fileSystemConfigs := defaultWriteableImageLayout(diskSizeInBytes)
+
+			outOfSpace, err := createWriteableImage(ctx, buildDir, rawImageFile, squashMountDir, diskConfig,
+				fileSystemConfigs, b.buildResources)
+			if err == nil {
+				break
+			}
+
+			if !outOfSpace || attempt >= writeableImageMaxAttempts {
+				return err
+			}
+
+			diskSizeInBytes = alignUp(uint64(float64(diskSizeInBytes)*(1+writeableImageGrowthFraction)), diskutils.MiB)
 
+			logger.Log.Infof("Writeable image ran out of space, retrying with a size of (%s)",
+				humanReadableDiskSize(int64(diskSizeInBytes)))
+		}
+	}
+
+	err = isoImageMount.CleanClose()
+	if err != nil {
+		return err
+	}
+
+	err = squashfsLoopDevice.CleanClose()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// defaultWriteableImageLayout
+//
+//   - returns the disk layout of the writeable image created from a squashfs
+//     when no layout is specified in the configuration: a boot partition and
+//     an ext4 rootfs partition.
+//
+// inputs:
+//
+//   - 'diskSizeInBytes':
+//     the size of the image to create.
+func defaultWriteableImageLayout(diskSizeInBytes uint64) (imagecustomizerapi.Disk, []imagecustomizerapi.FileSystem) {
 	// define a disk layout with a boot partition and a rootfs partition
-	maxDiskSizeMB := imagecustomizerapi.DiskSize(safeDiskSizeMB * diskutils.MiB)
+	maxDiskSize := imagecustomizerapi.DiskSize(alignUp(diskSizeInBytes, diskutils.MiB))
 	bootPartitionStart := imagecustomizerapi.DiskSize(1 * diskutils.MiB)
-	bootPartitionEnd := imagecustomizerapi.DiskSize(9 * diskutils.MiB)
+	bootPartitionEnd := imagecustomizerapi.DiskSize(writeableImageBootPartitionEnd)
 
 	diskConfig := imagecustomizerapi.Disk{
 		PartitionTableType: imagecustomizerapi.PartitionTableTypeGpt,
-		MaxSize:            &maxDiskSizeMB,
+		MaxSize:            &maxDiskSize,
 		Partitions: []imagecustomizerapi.Partition{
 			{
 				Id:    "esp",
@@ -1846,35 +2725,69 @@ func (b *LiveOSIsoBuilder) createWriteableImageFromSquashfs(buildDir, rawImageFi
 		},
 	}
 
+	return diskConfig, fileSystemConfigs
+}
+
+// createWriteableImage
+//
+//   - creates a writeable image with the specified disk layout, and copies
+//     the contents of the specified folder unto that writeable image.
+//
+// inputs:
+//
+//   - 'buildDir':
+//     path build directory (can be shared with other tools).
+//   - 'rawImageFile':
+//     the name of the raw image to create.
+//   - 'sourceDir':
+//     the folder whose contents will be copied to the image.
+//   - 'diskConfig':
+//     the partition layout of the image.
+//   - 'fileSystemConfigs':
+//     the file systems and mount points of the image partitions.
+//
+// outputs:
+//
+//   - creates the specified writeable image.
+//   - returns whether the copy failed because the rootfs ran out of space.
+func createWriteableImage(ctx context.Context, buildDir, rawImageFile, sourceDir string, diskConfig imagecustomizerapi.Disk,
+	fileSystemConfigs []imagecustomizerapi.FileSystem, buildResources BuildResources,
+) (bool, error) {
+	outOfSpace := false
+
 	// populate the newly created disk image with content from the squash fs
 	installOSFunc := func(imageChroot *safechroot.Chroot) error {
-		// At the point when this copy will be executed, both the boot and the
-		// root partitions will be mounted, and the files of /boot/efi will
-		// land on the the boot partition, while the rest will be on the rootfs
-		// partition.
-		err := copyPartitionFiles(squashMountDir+"/.", imageChroot.RootDir())
+		// At the point when this copy will be executed, all the partitions
+		// will be mounted, and the files of /boot/efi will land on the the
+		// boot partition, while the rest will be on the rootfs partition (or
+		// on any other partition mounted underneath it).
+		err := copyPartitionFiles(ctx, sourceDir+"/.", imageChroot.RootDir(), buildResources)
 		if err != nil {
+			full, fullErr := isFileSystemFull(imageChroot.RootDir())
+			if fullErr != nil {
+				logger.Log.Warnf("Failed to check free space of writeable image:\n%v", fullErr)
+			}
+			outOfSpace = full
 			return fmt.Errorf("failed to copy squashfs contents to a writeable disk:\n%w", err)
 		}
-		return err
-	}
 
-	// create the new raw disk image
-	writeableChrootDir := "writeable-raw-image"
-	_, err = createNewImage(rawImageFile, diskConfig, fileSystemConfigs, buildDir, writeableChrootDir, installOSFunc)
-	if err != nil {
-		return fmt.Errorf("failed to copy squashfs into new writeable image (%s):\n%w", rawImageFile, err)
-	}
+		// The LiveOS dracut configuration only applies to initrd images
+		// booting from an iso. If the writeable image is converted back into
+		// an iso, it will be added back.
+		err = os.Remove(filepath.Join(imageChroot.RootDir(), liveOSDracutConfigFile))
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove LiveOS dracut config (%s):\n%w", liveOSDracutConfigFile, err)
+		}
 
-	err = isoImageMount.CleanClose()
-	if err != nil {
-		return err
+		return nil
 	}
 
-	err = squashfsLoopDevice.CleanClose()
+	// create the new raw disk image
+	writeableChrootDir := "writeable-raw-image"
+	_, err := createNewImage(rawImageFile, diskConfig, fileSystemConfigs, buildDir, writeableChrootDir, installOSFunc)
 	if err != nil {
-		return err
+		return outOfSpace, fmt.Errorf("failed to copy squashfs into new writeable image (%s):\n%w", rawImageFile, err)
 	}
 
-	return nil
+	return false, nil
 }
@@ -4,16 +4,21 @@
 package imagecustomizerlib
 
 import (
+	"context"
+	"fmt"
 	"net/url"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"strings"
 	"testing"
 
 	"github.com/microsoft/azurelinux/toolkit/tools/imagecustomizerapi"
+	"github.com/microsoft/azurelinux/toolkit/tools/imagegen/diskutils"
 	"github.com/microsoft/azurelinux/toolkit/tools/internal/file"
 	"github.com/microsoft/azurelinux/toolkit/tools/internal/safeloopback"
 	"github.com/microsoft/azurelinux/toolkit/tools/internal/safemount"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/shell"
 	"github.com/stretchr/testify/assert"
 	"golang.org/x/sys/unix"
 )
@@ -46,8 +51,8 @@ func TestCustomizeImageLiveCd1(t *testing.T) {
 	configFile := filepath.Join(testDir, "iso-files-and-args-config.yaml")
 
 	// Customize vhdx to ISO, with OS changes.
-	err = CustomizeImageWithConfigFile(buildDir, configFile, baseImage, nil, outImageFilePath, "iso", "", /*outputSplitPartitionsFormat*/
-		pxeArtifactsPathVhdxToIso, true /*useBaseImageRpmRepos*/, false /*enableShrinkFilesystems*/)
+	err = CustomizeImageWithConfigFile(context.Background(), buildDir, configFile, baseImage, nil, outImageFilePath, "iso", "", /*outputSplitPartitionsFormat*/
+		pxeArtifactsPathVhdxToIso, true /*useBaseImageRpmRepos*/, false /*enableShrinkFilesystems*/, CustomizeImageOptions{})
 	assert.NoError(t, err)
 
 	// Attach ISO.
@@ -126,8 +131,8 @@ func TestCustomizeImageLiveCd1(t *testing.T) {
 			},
 		},
 	}
-	err = CustomizeImage(buildDir, testDir, &config, outImageFilePath, nil, outImageFilePath, "iso", "", /*outputSplitPartitionsFormat*/
-		pxeArtifactsPathIsoToIso, false /*useBaseImageRpmRepos*/, false /*enableShrinkFilesystems*/)
+	err = CustomizeImage(context.Background(), buildDir, testDir, &config, outImageFilePath, nil, outImageFilePath, "iso", "", /*outputSplitPartitionsFormat*/
+		pxeArtifactsPathIsoToIso, false /*useBaseImageRpmRepos*/, false /*enableShrinkFilesystems*/, CustomizeImageOptions{})
 	assert.NoError(t, err)
 
 	// Attach ISO.
@@ -207,22 +212,22 @@ func TestCustomizeImageLiveCd2(t *testing.T) {
 
 	// Customize vhdx with ISO prereqs.
 	configFile := filepath.Join(testDir, "iso-os-prereqs-config.yaml")
-	err := CustomizeImageWithConfigFile(buildDir, configFile, baseImage, nil, outImageFilePath, "raw", "",
-		"" /*outputPXEArtifactsDir*/, true /*useBaseImageRpmRepos*/, false /*enableShrinkFilesystems*/)
+	err := CustomizeImageWithConfigFile(context.Background(), buildDir, configFile, baseImage, nil, outImageFilePath, "raw", "",
+		"" /*outputPXEArtifactsDir*/, true /*useBaseImageRpmRepos*/, false /*enableShrinkFilesystems*/, CustomizeImageOptions{})
 	assert.NoError(t, err)
 
 	// Customize image to ISO, with no OS changes.
 	config := imagecustomizerapi.Config{
 		Iso: &imagecustomizerapi.Iso{},
 	}
-	err = CustomizeImage(buildDir, testDir, &config, outImageFilePath, nil, outIsoFilePath, "iso", "",
-		"" /*outputPXEArtifactsDir*/, false /*useBaseImageRpmRepos*/, false /*enableShrinkFilesystems*/)
+	err = CustomizeImage(context.Background(), buildDir, testDir, &config, outImageFilePath, nil, outIsoFilePath, "iso", "",
+		"" /*outputPXEArtifactsDir*/, false /*useBaseImageRpmRepos*/, false /*enableShrinkFilesystems*/, CustomizeImageOptions{})
 	assert.NoError(t, err)
 
 	// Customize ISO to ISO, with OS changes.
 	configFile = filepath.Join(testDir, "addfiles-config.yaml")
-	err = CustomizeImageWithConfigFile(buildDir, configFile, outIsoFilePath, nil, outIsoFilePath, "iso", "",
-		"" /*outputPXEArtifactsDir*/, true /*useBaseImageRpmRepos*/, false /*enableShrinkFilesystems*/)
+	err = CustomizeImageWithConfigFile(context.Background(), buildDir, configFile, outIsoFilePath, nil, outIsoFilePath, "iso", "",
+		"" /*outputPXEArtifactsDir*/, true /*useBaseImageRpmRepos*/, false /*enableShrinkFilesystems*/, CustomizeImageOptions{})
 	assert.NoError(t, err)
 
 	// Attach ISO.
@@ -241,7 +246,7 @@ func TestCustomizeImageLiveCd2(t *testing.T) {
 	defer isoImageMount.Close()
 
 	// Attach squashfs file.
-	squashfsPath := filepath.Join(isoMountDir, liveOSDir, liveOSImage)
+	squashfsPath := filepath.Join(isoMountDir, defaultLiveOSDir, defaultLiveOSImage)
 	squashfsLoopDevice, err := safeloopback.NewLoopback(squashfsPath)
 	if !assert.NoError(t, err) {
 		return
@@ -279,8 +284,8 @@ func TestCustomizeImageLiveCdIsoNoShimEfi(t *testing.T) {
 	}
 
 	// Customize image.
-	err := CustomizeImage(buildDir, testDir, config, baseImage, nil, outImageFilePath, "iso", "",
-		"" /*outputPXEArtifactsDir*/, true /*useBaseImageRpmRepos*/, false /*enableShrinkFilesystems*/)
+	err := CustomizeImage(context.Background(), buildDir, testDir, config, baseImage, nil, outImageFilePath, "iso", "",
+		"" /*outputPXEArtifactsDir*/, true /*useBaseImageRpmRepos*/, false /*enableShrinkFilesystems*/, CustomizeImageOptions{})
 	assert.Error(t, err)
 	assert.ErrorContains(t, err, "failed to find the boot efi file")
 }
@@ -302,8 +307,282 @@ func TestCustomizeImageLiveCdIsoNoGrubEfi(t *testing.T) {
 	}
 
 	// Customize image.
-	err := CustomizeImage(buildDir, testDir, config, baseImage, nil, outImageFilePath, "iso", "",
-		"" /*outputPXEArtifactsDir*/, true /*useBaseImageRpmRepos*/, false /*enableShrinkFilesystems*/)
+	err := CustomizeImage(context.Background(), buildDir, testDir, config, baseImage, nil, outImageFilePath, "iso", "",
+		"" /*outputPXEArtifactsDir*/, true /*useBaseImageRpmRepos*/, false /*enableShrinkFilesystems*/, CustomizeImageOptions{})
 	assert.Error(t, err)
 	assert.ErrorContains(t, err, "failed to find the grub efi file")
 }
+
+func TestCreateSquashfsImagePreservesXattrs(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("Test must be run as root because it sets file capabilities")
+	}
+
+	for _, tool := range []string{"mksquashfs", "unsquashfs"} {
+		if _, err := exec.LookPath(tool); err != nil {
+			t.Skipf("(%s) is not installed", tool)
+		}
+	}
+
+	testTmpDir := filepath.Join(tmpDir, "TestCreateSquashfsImagePreservesXattrs")
+	defer os.RemoveAll(testTmpDir)
+
+	sourceDir := filepath.Join(testTmpDir, "source")
+	writeableRootfsDir := filepath.Join(testTmpDir, "rootfs")
+	isoArtifactsDir := filepath.Join(testTmpDir, "artifacts")
+	extractedDir := filepath.Join(testTmpDir, "extracted")
+
+	err := createTestRootfsWithCapabilities(sourceDir)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	err = os.MkdirAll(isoArtifactsDir, os.ModePerm)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	isoBuilder := &LiveOSIsoBuilder{
+		workingDirs: IsoWorkingDirs{
+			isoArtifactsDir: isoArtifactsDir,
+		},
+	}
+
+	err = isoBuilder.populateWriteableRootfsDir(context.Background(), sourceDir, writeableRootfsDir)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	err = isoBuilder.createSquashfsImage(context.Background(), writeableRootfsDir)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	err = shell.ExecuteLive(false, "unsquashfs", "-d", extractedDir, isoBuilder.artifacts.squashfsImagePath)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assertTestRootfsCapabilities(t, extractedDir)
+}
+
+func TestNewIsoWorkingDirsUnique(t *testing.T) {
+	buildDir := filepath.Join(tmpDir, "TestNewIsoWorkingDirsUnique")
+	defer os.RemoveAll(buildDir)
+
+	err := os.MkdirAll(buildDir, os.ModePerm)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	workingDirs1, err := newIsoWorkingDirs(buildDir)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	workingDirs2, err := newIsoWorkingDirs(buildDir)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.NotEqual(t, workingDirs1.isoBuildDir, workingDirs2.isoBuildDir)
+	assert.DirExists(t, workingDirs1.isoBuildDir)
+	assert.Equal(t, buildDir, filepath.Dir(workingDirs1.isoBuildDir))
+	assert.Equal(t, filepath.Join(workingDirs1.isoBuildDir, "isomaker-tmp"), workingDirs1.isomakerBuildDir)
+}
+
+func TestExtractBootloaderFiles(t *testing.T) {
+	testDir := filepath.Join(tmpDir, "TestExtractBootloaderFiles")
+	defer os.RemoveAll(testDir)
+
+	rootfsDir := filepath.Join(testDir, "rootfs")
+	vendorDir := filepath.Join(rootfsDir, "usr/share/vendor")
+	err := os.MkdirAll(vendorDir, os.ModePerm)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	for _, fileName := range []string{"shimx64.efi", "ntfs_x64.efi"} {
+		err = file.Write(fileName, filepath.Join(vendorDir, fileName))
+		if !assert.NoError(t, err) {
+			return
+		}
+	}
+
+	b := &LiveOSIsoBuilder{
+		workingDirs: IsoWorkingDirs{
+			isoArtifactsDir: filepath.Join(testDir, "artifacts"),
+		},
+		bootloaderFiles: imagecustomizerapi.IsoBootloaderFileList{
+			{
+				Source:      "/usr/share/vendor/shimx64.efi",
+				Destination: "EFI/BOOT/BOOTX64.EFI",
+			},
+			{
+				Source:      "/usr/share/vendor/ntfs_x64.efi",
+				Destination: "EFI/BOOT/drivers/ntfs_x64.efi",
+			},
+		},
+	}
+
+	err = b.extractBootloaderFiles(rootfsDir)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.FileExists(t, b.artifacts.bootx64EfiPath)
+	assert.Equal(t, "", b.artifacts.grubx64EfiPath)
+	if assert.Len(t, b.artifacts.efiBootFiles, 1) {
+		assert.Equal(t, "EFI/BOOT/drivers/ntfs_x64.efi", b.artifacts.efiBootFiles[0].Dest)
+		assert.FileExists(t, b.artifacts.efiBootFiles[0].Src)
+	}
+
+	b.bootloaderFiles = imagecustomizerapi.IsoBootloaderFileList{
+		{
+			Source:      "/usr/share/vendor/missing.efi",
+			Destination: "EFI/BOOT/missing.efi",
+		},
+	}
+
+	err = b.extractBootloaderFiles(rootfsDir)
+	assert.ErrorContains(t, err, "failed to extract bootloader file (/usr/share/vendor/missing.efi)")
+}
+
+func TestFindKernelVersion(t *testing.T) {
+	rootfsDir := filepath.Join(tmpDir, "TestFindKernelVersion")
+	defer os.RemoveAll(rootfsDir)
+
+	for _, kernelVersion := range []string{"6.6.51.1-5.azl3", "6.6.51.1-5-hci.azl3"} {
+		kernelDir := filepath.Join(rootfsDir, "usr/lib/modules", kernelVersion)
+		err := os.MkdirAll(kernelDir, os.ModePerm)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		err = file.Write("", filepath.Join(kernelDir, "modules.dep"))
+		if !assert.NoError(t, err) {
+			return
+		}
+	}
+
+	// Empty kernel directories are ignored.
+	err := os.MkdirAll(filepath.Join(rootfsDir, "usr/lib/modules/5.15.0.1-1.cm2"), os.ModePerm)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	b := &LiveOSIsoBuilder{}
+	err = b.findKernelVersion(rootfsDir)
+	assert.ErrorContains(t, err, "found more than one kernel installed")
+	assert.ErrorContains(t, err, "use iso kernelVersion")
+
+	b.kernelVersionPattern = "*-hci.*"
+	err = b.findKernelVersion(rootfsDir)
+	assert.NoError(t, err)
+	assert.Equal(t, "6.6.51.1-5-hci.azl3", b.artifacts.kernelVersion)
+
+	b.kernelVersionPattern = "6.6.51.1-5.azl3"
+	err = b.findKernelVersion(rootfsDir)
+	assert.NoError(t, err)
+	assert.Equal(t, "6.6.51.1-5.azl3", b.artifacts.kernelVersion)
+
+	b.kernelVersionPattern = "6.6.*"
+	err = b.findKernelVersion(rootfsDir)
+	assert.ErrorContains(t, err, "matches more than one of the installed kernels")
+
+	b.kernelVersionPattern = "5.15.*"
+	err = b.findKernelVersion(rootfsDir)
+	assert.ErrorContains(t, err, "does not match any of the installed kernels")
+}
+
+func TestEstimateIsoBuildSpaceInBytes(t *testing.T) {
+	rootfsSize := uint64(2 * diskutils.GiB)
+	estimate := estimateIsoBuildSpaceInBytes(rootfsSize)
+	assert.Equal(t, 3*rootfsSize+2*uint64(initrdImageSizeEstimate), estimate)
+}
+
+func TestCheckIsoBuildDiskSpace(t *testing.T) {
+	rootfsSizeInfo := dirSizeInfo{
+		diskSize: 1 * diskutils.GiB,
+		topLevelDiskSizes: map[string]uint64{
+			"usr": 900 * diskutils.MiB,
+			"var": 100 * diskutils.MiB,
+		},
+	}
+	requiredSize := estimateIsoBuildSpaceInBytes(rootfsSizeInfo.diskSize)
+
+	availableSpace := func(size uint64) availableDiskSpaceFunc {
+		return func(path string) (uint64, error) {
+			return size, nil
+		}
+	}
+
+	err := checkIsoBuildDiskSpace("/build", rootfsSizeInfo, availableSpace(requiredSize))
+	assert.NoError(t, err)
+
+	err = checkIsoBuildDiskSpace("/build", rootfsSizeInfo, availableSpace(requiredSize-1))
+	assert.ErrorContains(t, err, "not enough free disk space to build the iso")
+	assert.ErrorContains(t, err, "usr: 900 MiB")
+
+	err = checkIsoBuildDiskSpace("/build", rootfsSizeInfo, func(path string) (uint64, error) {
+		return 0, fmt.Errorf("statfs failed")
+	})
+	assert.ErrorContains(t, err, "failed to check available disk space")
+}
+
+func TestExtJournalSizeInBytes(t *testing.T) {
+	assert.Equal(t, uint64(0), extJournalSizeInBytes(4*diskutils.MiB))
+	assert.Equal(t, uint64(4*diskutils.MiB), extJournalSizeInBytes(64*diskutils.MiB))
+	assert.Equal(t, uint64(64*diskutils.MiB), extJournalSizeInBytes(4*diskutils.GiB))
+	assert.Equal(t, uint64(1*diskutils.GiB), extJournalSizeInBytes(256*diskutils.GiB))
+}
+
+func TestEstimateExtFileSystemSizeInBytes(t *testing.T) {
+	// Data heavy tree: the size is dominated by the file contents.
+	dataHeavySize := estimateExtFileSystemSizeInBytes(dirSizeInfo{
+		extAlignedSize: 1 * diskutils.GiB,
+		entryCount:     1000,
+	})
+	assert.Greater(t, dataHeavySize, uint64(1*diskutils.GiB+32*diskutils.MiB))
+	assert.Less(t, dataHeavySize, uint64(1*diskutils.GiB+200*diskutils.MiB))
+	assert.Equal(t, uint64(0), dataHeavySize%diskutils.MiB)
+
+	// File count heavy tree: the size is dominated by the number of inodes.
+	fileCountHeavySize := estimateExtFileSystemSizeInBytes(dirSizeInfo{
+		extAlignedSize: 100000 * extBlockSize,
+		entryCount:     100000,
+	})
+	assert.Equal(t, alignUp((100000+extReservedInodes)*extInodeRatio, diskutils.MiB), fileCountHeavySize)
+
+	// Empty tree.
+	emptySize := estimateExtFileSystemSizeInBytes(dirSizeInfo{})
+	assert.Equal(t, uint64(1*diskutils.MiB), emptySize)
+}
+
+func TestEstimateWriteableImageSizeInBytes(t *testing.T) {
+	sizeInfo := dirSizeInfo{
+		extAlignedSize: 512 * diskutils.MiB,
+		entryCount:     5000,
+	}
+
+	diskSize := estimateWriteableImageSizeInBytes(sizeInfo)
+	assert.Equal(t, writeableImageBootPartitionEnd+estimateExtFileSystemSizeInBytes(sizeInfo)+writeableImageGptFooterSize,
+		diskSize)
+}
+
+func TestDefaultWriteableImageLayout(t *testing.T) {
+	diskConfig, fileSystemConfigs := defaultWriteableImageLayout(1 * diskutils.GiB)
+
+	err := diskConfig.IsValid()
+	assert.NoError(t, err)
+	assert.Equal(t, imagecustomizerapi.DiskSize(1*diskutils.GiB), *diskConfig.MaxSize)
+	assert.Len(t, diskConfig.Partitions, 2)
+	assert.Equal(t, imagecustomizerapi.PartitionTypeESP, diskConfig.Partitions[0].Type)
+	assert.Equal(t, imagecustomizerapi.DiskSize(writeableImageBootPartitionEnd), *diskConfig.Partitions[1].Start)
+
+	if assert.Len(t, fileSystemConfigs, 2) {
+		assert.Equal(t, "/boot/efi", fileSystemConfigs[0].MountPoint.Path)
+		assert.Equal(t, imagecustomizerapi.FileSystemTypeExt4, fileSystemConfigs[1].Type)
+		assert.Equal(t, "/", fileSystemConfigs[1].MountPoint.Path)
+	}
+}
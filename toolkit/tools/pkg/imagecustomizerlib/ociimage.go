@@ -0,0 +1,513 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"time"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/imagecustomizerapi"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/file"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/logger"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/safechroot"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/shell"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	ociLayoutVersion = "1.0.0"
+
+	ociMediaTypeImageIndex     = "application/vnd.oci.image.index.v1+json"
+	ociMediaTypeImageManifest  = "application/vnd.oci.image.manifest.v1+json"
+	ociMediaTypeImageConfig    = "application/vnd.oci.image.config.v1+json"
+	ociMediaTypeImageLayerGzip = "application/vnd.oci.image.layer.v1.tar+gzip"
+
+	ociRefNameAnnotation = "org.opencontainers.image.ref.name"
+	ociDefaultTag        = "latest"
+
+	// The label that marks an image as a bootable container image.
+	ociBootcLabel = "containers.bootc"
+
+	ociLayoutDirName = "oci-layout"
+	ociLayerFileName = "oci-layer.tar"
+)
+
+// ociDescriptor references a blob of an OCI image.
+// See: https://github.com/opencontainers/image-spec/blob/main/descriptor.md
+type ociDescriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+type ociIndex struct {
+	SchemaVersion int             `json:"schemaVersion"`
+	MediaType     string          `json:"mediaType"`
+	Manifests     []ociDescriptor `json:"manifests"`
+}
+
+type ociManifest struct {
+	SchemaVersion int             `json:"schemaVersion"`
+	MediaType     string          `json:"mediaType"`
+	Config        ociDescriptor   `json:"config"`
+	Layers        []ociDescriptor `json:"layers"`
+}
+
+// ociImageConfig is the configuration of an OCI image.
+// See: https://github.com/opencontainers/image-spec/blob/main/config.md
+type ociImageConfig struct {
+	Created      string             `json:"created"`
+	Architecture string             `json:"architecture"`
+	OS           string             `json:"os"`
+	Config       ociContainerConfig `json:"config"`
+	RootFS       ociRootFS          `json:"rootfs"`
+	History      []ociHistory       `json:"history"`
+}
+
+type ociContainerConfig struct {
+	Cmd    []string          `json:"Cmd,omitempty"`
+	Labels map[string]string `json:"Labels,omitempty"`
+}
+
+type ociRootFS struct {
+	Type    string   `json:"type"`
+	DiffIDs []string `json:"diff_ids"`
+}
+
+type ociHistory struct {
+	Created   string `json:"created"`
+	CreatedBy string `json:"created_by"`
+}
+
+// createOciImage packages the OS of the customized image as a single layer OCI image, writes it to an OCI archive
+// (i.e. a tar file of an OCI image layout) and, if requested, pushes it to a registry.
+func createOciImage(ctx context.Context, buildDir string, rawImageFile string, ociConfig *imagecustomizerapi.Oci,
+	outputImageFile string,
+) error {
+	if ociConfig == nil {
+		ociConfig = &imagecustomizerapi.Oci{}
+	}
+
+	layerFile := filepath.Join(buildDir, ociLayerFileName)
+	defer os.Remove(layerFile)
+
+	err := createOciLayerFile(ctx, buildDir, rawImageFile, ociConfig.Bootc, layerFile)
+	if err != nil {
+		return err
+	}
+
+	created := time.Now().UTC()
+	if os.Getenv(sourceDateEpochEnvVar) != "" {
+		created, err = getSourceDateEpoch()
+		if err != nil {
+			return err
+		}
+	}
+
+	labels := make(map[string]string)
+	for name, value := range ociConfig.Labels {
+		labels[name] = value
+	}
+	if ociConfig.Bootc {
+		labels[ociBootcLabel] = "1"
+	}
+
+	imageConfig := ociImageConfig{
+		Created:      created.Format(time.RFC3339),
+		Architecture: runtime.GOARCH,
+		OS:           "linux",
+		Config: ociContainerConfig{
+			Cmd:    ociConfig.Cmd,
+			Labels: labels,
+		},
+		History: []ociHistory{{
+			Created:   created.Format(time.RFC3339),
+			CreatedBy: fmt.Sprintf("imagecustomizer %s", ToolVersion),
+		}},
+	}
+
+	tag := ociConfig.Tag
+	if tag == "" {
+		tag = ociDefaultTag
+	}
+
+	logger.Log.Infof("Writing: %s", outputImageFile)
+
+	err = writeOciImageArchive(buildDir, layerFile, imageConfig, created, tag, outputImageFile)
+	if err != nil {
+		return err
+	}
+
+	if ociConfig.PushReference != "" {
+		err = pushOciImage(ctx, outputImageFile, ociConfig.PushReference)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// createOciLayerFile writes the files of the customized image's OS to the (uncompressed) layer file.
+func createOciLayerFile(ctx context.Context, buildDir string, rawImageFile string, bootc bool, layerFile string) error {
+	logger.Log.Infof("Creating OCI image layer")
+
+	addFiles := addRootfsFilesFunc(nil)
+	if bootc {
+		addFiles = func(imageChroot *safechroot.Chroot) error {
+			return addBootcKernelsToOciLayer(ctx, imageChroot.RootDir(), layerFile)
+		}
+	}
+
+	err := createRootfsTarFile(ctx, buildDir, rawImageFile, "ociroot", layerFile, addFiles)
+	if err != nil {
+		return fmt.Errorf("failed to create OCI image layer:\n%w", err)
+	}
+
+	return nil
+}
+
+// addBootcKernelsToOciLayer adds the kernel of each installed kernel version to '/usr/lib/modules/<version>/vmlinuz'
+// in the layer, which is where bootable container tools look for it. The kernels are usually only installed under
+// '/boot'.
+func addBootcKernelsToOciLayer(ctx context.Context, rootDir string, layerFile string) error {
+	kernelVersions, err := getInstalledKernelVersions(rootDir)
+	if err != nil {
+		return err
+	}
+
+	if len(kernelVersions) == 0 {
+		return fmt.Errorf("a bootable container image requires an installed kernel")
+	}
+
+	for _, kernelVersion := range kernelVersions {
+		modulesKernelPath := filepath.Join("usr/lib/modules", kernelVersion, "vmlinuz")
+
+		exists, err := file.PathExists(filepath.Join(rootDir, modulesKernelPath))
+		if err != nil {
+			return err
+		}
+		if exists {
+			continue
+		}
+
+		bootKernelPath := filepath.Join("boot", "vmlinuz-"+kernelVersion)
+
+		exists, err = file.PathExists(filepath.Join(rootDir, bootKernelPath))
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return fmt.Errorf("failed to find the kernel of version (%s) for the bootable container image", kernelVersion)
+		}
+
+		err = appendFileToTarFile(ctx, layerFile, rootDir, bootKernelPath, modulesKernelPath)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeOciImageArchive creates an OCI image layout (with one image, made of the layer file) under the build directory,
+// and writes it to an OCI archive.
+func writeOciImageArchive(buildDir string, layerFile string, imageConfig ociImageConfig, modTime time.Time,
+	tag string, outputImageFile string,
+) error {
+	layoutDir := filepath.Join(buildDir, ociLayoutDirName)
+
+	err := os.RemoveAll(layoutDir)
+	if err != nil {
+		return fmt.Errorf("failed to remove OCI image layout (%s):\n%w", layoutDir, err)
+	}
+	defer os.RemoveAll(layoutDir)
+
+	blobsDir := filepath.Join(layoutDir, "blobs", "sha256")
+
+	err = os.MkdirAll(blobsDir, os.ModePerm)
+	if err != nil {
+		return fmt.Errorf("failed to create OCI image layout (%s):\n%w", layoutDir, err)
+	}
+
+	layerDescriptor, diffId, err := writeOciLayerBlob(blobsDir, layerFile)
+	if err != nil {
+		return err
+	}
+
+	imageConfig.RootFS = ociRootFS{
+		Type:    "layers",
+		DiffIDs: []string{diffId},
+	}
+
+	configDescriptor, err := writeOciJsonBlob(blobsDir, ociMediaTypeImageConfig, imageConfig)
+	if err != nil {
+		return err
+	}
+
+	manifest := ociManifest{
+		SchemaVersion: 2,
+		MediaType:     ociMediaTypeImageManifest,
+		Config:        configDescriptor,
+		Layers:        []ociDescriptor{layerDescriptor},
+	}
+
+	manifestDescriptor, err := writeOciJsonBlob(blobsDir, ociMediaTypeImageManifest, manifest)
+	if err != nil {
+		return err
+	}
+
+	manifestDescriptor.Annotations = map[string]string{
+		ociRefNameAnnotation: tag,
+	}
+
+	index := ociIndex{
+		SchemaVersion: 2,
+		MediaType:     ociMediaTypeImageIndex,
+		Manifests:     []ociDescriptor{manifestDescriptor},
+	}
+
+	err = writeOciJsonFile(filepath.Join(layoutDir, "index.json"), index)
+	if err != nil {
+		return err
+	}
+
+	err = writeOciJsonFile(filepath.Join(layoutDir, "oci-layout"), map[string]string{
+		"imageLayoutVersion": ociLayoutVersion,
+	})
+	if err != nil {
+		return err
+	}
+
+	err = writeOciArchive(layoutDir, modTime, outputImageFile)
+	if err != nil {
+		return fmt.Errorf("failed to write OCI archive (%s):\n%w", outputImageFile, err)
+	}
+
+	return nil
+}
+
+// writeOciLayerBlob compresses the layer file into a blob. It returns the blob's descriptor and the digest of the
+// uncompressed layer (i.e. its diff ID).
+func writeOciLayerBlob(blobsDir string, layerFile string) (ociDescriptor, string, error) {
+	layer, err := os.Open(layerFile)
+	if err != nil {
+		return ociDescriptor{}, "", fmt.Errorf("failed to open OCI image layer (%s):\n%w", layerFile, err)
+	}
+	defer layer.Close()
+
+	blobFile := filepath.Join(blobsDir, "layer.tmp")
+	blob, err := os.Create(blobFile)
+	if err != nil {
+		return ociDescriptor{}, "", fmt.Errorf("failed to create OCI image layer blob:\n%w", err)
+	}
+	defer blob.Close()
+	defer os.Remove(blobFile)
+
+	blobHash := sha256.New()
+	blobCounter := &countingWriter{writer: io.MultiWriter(blob, blobHash)}
+	layerHash := sha256.New()
+
+	gzipWriter := gzip.NewWriter(blobCounter)
+
+	_, err = io.Copy(io.MultiWriter(gzipWriter, layerHash), layer)
+	if err != nil {
+		return ociDescriptor{}, "", fmt.Errorf("failed to compress OCI image layer (%s):\n%w", layerFile, err)
+	}
+
+	err = gzipWriter.Close()
+	if err != nil {
+		return ociDescriptor{}, "", fmt.Errorf("failed to compress OCI image layer (%s):\n%w", layerFile, err)
+	}
+
+	err = blob.Close()
+	if err != nil {
+		return ociDescriptor{}, "", fmt.Errorf("failed to write OCI image layer blob:\n%w", err)
+	}
+
+	descriptor := ociDescriptor{
+		MediaType: ociMediaTypeImageLayerGzip,
+		Digest:    ociDigest(blobHash),
+		Size:      blobCounter.count,
+	}
+
+	err = os.Rename(blobFile, ociBlobPath(blobsDir, descriptor.Digest))
+	if err != nil {
+		return ociDescriptor{}, "", fmt.Errorf("failed to write OCI image layer blob:\n%w", err)
+	}
+
+	return descriptor, ociDigest(layerHash), nil
+}
+
+// writeOciJsonBlob writes a JSON document to a blob, and returns the blob's descriptor.
+func writeOciJsonBlob(blobsDir string, mediaType string, value interface{}) (ociDescriptor, error) {
+	content, err := json.Marshal(value)
+	if err != nil {
+		return ociDescriptor{}, fmt.Errorf("failed to marshal (%s):\n%w", mediaType, err)
+	}
+
+	blobHash := sha256.New()
+	blobHash.Write(content)
+
+	descriptor := ociDescriptor{
+		MediaType: mediaType,
+		Digest:    ociDigest(blobHash),
+		Size:      int64(len(content)),
+	}
+
+	err = os.WriteFile(ociBlobPath(blobsDir, descriptor.Digest), content, 0o644)
+	if err != nil {
+		return ociDescriptor{}, fmt.Errorf("failed to write (%s) blob:\n%w", mediaType, err)
+	}
+
+	return descriptor, nil
+}
+
+func writeOciJsonFile(path string, value interface{}) error {
+	content, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to marshal (%s):\n%w", path, err)
+	}
+
+	err = os.WriteFile(path, content, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to write (%s):\n%w", path, err)
+	}
+
+	return nil
+}
+
+// writeOciArchive writes the files of an OCI image layout to a tar file, in lexical order. The files are given the
+// modification time, so that the archive only depends on the image.
+func writeOciArchive(layoutDir string, modTime time.Time, outputImageFile string) error {
+	paths := []string(nil)
+	err := filepath.WalkDir(layoutDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if path != layoutDir {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	sort.Strings(paths)
+
+	archive, err := os.Create(outputImageFile)
+	if err != nil {
+		return err
+	}
+	defer archive.Close()
+
+	tarWriter := tar.NewWriter(archive)
+
+	for _, path := range paths {
+		err = addFileToOciArchive(tarWriter, layoutDir, path, modTime)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = tarWriter.Close()
+	if err != nil {
+		return err
+	}
+
+	return archive.Close()
+}
+
+func addFileToOciArchive(tarWriter *tar.Writer, layoutDir string, path string, modTime time.Time) error {
+	fileInfo, err := os.Lstat(path)
+	if err != nil {
+		return err
+	}
+
+	relativePath, err := filepath.Rel(layoutDir, path)
+	if err != nil {
+		return err
+	}
+
+	header, err := tar.FileInfoHeader(fileInfo, "")
+	if err != nil {
+		return err
+	}
+	header.Name = filepath.ToSlash(relativePath)
+	header.ModTime = modTime
+	header.Uid = 0
+	header.Gid = 0
+	header.Uname = ""
+	header.Gname = ""
+
+	err = tarWriter.WriteHeader(header)
+	if err != nil {
+		return err
+	}
+
+	if !fileInfo.Mode().IsRegular() {
+		return nil
+	}
+
+	source, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	_, err = io.Copy(tarWriter, source)
+	return err
+}
+
+// pushOciImage pushes the image of an OCI archive to a registry. The registry's credentials are read from the
+// authentication file of the containers tools (e.g. the one written by 'podman login').
+func pushOciImage(ctx context.Context, ociArchiveFile string, pushReference string) error {
+	logger.Log.Infof("Pushing OCI image to (%s)", pushReference)
+
+	err := shell.NewExecBuilder("skopeo", "copy", "oci-archive:"+ociArchiveFile, "docker://"+pushReference).
+		Context(ctx).
+		LogLevel(logrus.DebugLevel, logrus.DebugLevel).
+		ErrorStderrLines(1).
+		Execute()
+	if err != nil {
+		return fmt.Errorf("failed to push OCI image to (%s):\n%w", pushReference, err)
+	}
+
+	return nil
+}
+
+func ociDigest(blobHash hash.Hash) string {
+	return "sha256:" + hex.EncodeToString(blobHash.Sum(nil))
+}
+
+func ociBlobPath(blobsDir string, digest string) string {
+	return filepath.Join(blobsDir, digest[len("sha256:"):])
+}
+
+// countingWriter counts the bytes written to the underlying writer.
+type countingWriter struct {
+	writer io.Writer
+	count  int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.writer.Write(p)
+	w.count += int64(n)
+	return n, err
+}
@@ -0,0 +1,185 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/imagecustomizerapi"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteOciImageArchive(t *testing.T) {
+	testDir := filepath.Join(tmpDir, "TestWriteOciImageArchive")
+	defer os.RemoveAll(testDir)
+
+	err := os.MkdirAll(testDir, os.ModePerm)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	layerFile := filepath.Join(testDir, ociLayerFileName)
+	err = writeTestTarFile(layerFile, map[string]string{"etc/os-release": "NAME=\"Microsoft Azure Linux\"\n"})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	modTime := time.Unix(1700000000, 0).UTC()
+	imageConfig := ociImageConfig{
+		Created:      modTime.Format(time.RFC3339),
+		Architecture: "amd64",
+		OS:           "linux",
+		Config: ociContainerConfig{
+			Cmd:    []string{"/bin/bash"},
+			Labels: map[string]string{ociBootcLabel: "1"},
+		},
+	}
+
+	archiveFile := filepath.Join(testDir, "image.oci")
+	err = writeOciImageArchive(testDir, layerFile, imageConfig, modTime, "3.0", archiveFile)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// The OCI image layout is removed once the archive is written.
+	assert.NoDirExists(t, filepath.Join(testDir, ociLayoutDirName))
+
+	archiveFiles, err := readTestTarFile(archiveFile)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, `{"imageLayoutVersion":"1.0.0"}`, archiveFiles["oci-layout"])
+
+	// Each blob is named after its digest.
+	for name, content := range archiveFiles {
+		digest, found := strings.CutPrefix(name, "blobs/sha256/")
+		if found {
+			contentHash := sha256.Sum256([]byte(content))
+			assert.Equal(t, hex.EncodeToString(contentHash[:]), digest)
+		}
+	}
+
+	var index ociIndex
+	err = json.Unmarshal([]byte(archiveFiles["index.json"]), &index)
+	if !assert.NoError(t, err) || !assert.Len(t, index.Manifests, 1) {
+		return
+	}
+	assert.Equal(t, ociMediaTypeImageManifest, index.Manifests[0].MediaType)
+	assert.Equal(t, "3.0", index.Manifests[0].Annotations[ociRefNameAnnotation])
+
+	var manifest ociManifest
+	err = json.Unmarshal([]byte(archiveFiles[ociTestBlobName(index.Manifests[0].Digest)]), &manifest)
+	if !assert.NoError(t, err) || !assert.Len(t, manifest.Layers, 1) {
+		return
+	}
+	assert.Equal(t, ociMediaTypeImageLayerGzip, manifest.Layers[0].MediaType)
+
+	layerBlob := archiveFiles[ociTestBlobName(manifest.Layers[0].Digest)]
+	assert.Equal(t, int64(len(layerBlob)), manifest.Layers[0].Size)
+
+	var config ociImageConfig
+	err = json.Unmarshal([]byte(archiveFiles[ociTestBlobName(manifest.Config.Digest)]), &config)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "2023-11-14T22:13:20Z", config.Created)
+	assert.Equal(t, []string{"/bin/bash"}, config.Config.Cmd)
+	assert.Equal(t, "1", config.Config.Labels[ociBootcLabel])
+
+	// The diff ID is the digest of the uncompressed layer.
+	gzipReader, err := gzip.NewReader(strings.NewReader(layerBlob))
+	if !assert.NoError(t, err) {
+		return
+	}
+	layer, err := io.ReadAll(gzipReader)
+	if !assert.NoError(t, err) {
+		return
+	}
+	layerHash := sha256.Sum256(layer)
+	assert.Equal(t, []string{"sha256:" + hex.EncodeToString(layerHash[:])}, config.RootFS.DiffIDs)
+}
+
+func TestCreateImageCustomizerParametersOciWithoutOciFormat(t *testing.T) {
+	config := &imagecustomizerapi.Config{
+		Oci: &imagecustomizerapi.Oci{
+			Tag: "3.0",
+		},
+	}
+
+	_, err := createImageCustomizerParameters(tmpDir, "input.vhdx", testDir, config, false, nil, false, "",
+		"vhdx", filepath.Join(tmpDir, "image.vhdx"), "", CustomizeImageOptions{})
+	assert.ErrorContains(t, err, "'oci' can be specified only if the output format ('--output-image-format') is an oci image")
+}
+
+func ociTestBlobName(digest string) string {
+	return "blobs/sha256/" + digest[len("sha256:"):]
+}
+
+func writeTestTarFile(path string, files map[string]string) error {
+	tarFile, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer tarFile.Close()
+
+	tarWriter := tar.NewWriter(tarFile)
+	for name, content := range files {
+		err = tarWriter.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(content))})
+		if err != nil {
+			return err
+		}
+
+		_, err = tarWriter.Write([]byte(content))
+		if err != nil {
+			return err
+		}
+	}
+
+	err = tarWriter.Close()
+	if err != nil {
+		return err
+	}
+
+	return tarFile.Close()
+}
+
+func readTestTarFile(path string) (map[string]string, error) {
+	tarFile, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer tarFile.Close()
+
+	files := make(map[string]string)
+	tarReader := tar.NewReader(tarFile)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			return files, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		content, err := io.ReadAll(tarReader)
+		if err != nil {
+			return nil, err
+		}
+		files[header.Name] = string(content)
+	}
+}
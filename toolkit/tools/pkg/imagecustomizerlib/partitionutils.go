@@ -20,6 +20,7 @@ import (
 	"github.com/microsoft/azurelinux/toolkit/tools/internal/safemount"
 	"github.com/microsoft/azurelinux/toolkit/tools/internal/shell"
 	"github.com/microsoft/azurelinux/toolkit/tools/internal/sliceutils"
+	"golang.org/x/sys/unix"
 )
 
 var (
@@ -29,7 +30,7 @@ var (
 	partitionNumberRegex = regexp.MustCompile(`^/dev/loop\d+p(\d+)$`)
 )
 
-func findPartitions(buildDir string, diskDevice string) ([]*safechroot.MountPoint, error) {
+func findPartitions(buildDir string, diskDevice string, readOnly bool) ([]*safechroot.MountPoint, error) {
 	var err error
 
 	diskPartitions, err := diskutils.GetDiskPartitions(diskDevice)
@@ -37,12 +38,12 @@ func findPartitions(buildDir string, diskDevice string) ([]*safechroot.MountPoin
 		return nil, err
 	}
 
-	rootfsPartition, err := findRootfsPartition(diskPartitions, buildDir)
+	rootfsPartition, err := findRootfsPartition(diskPartitions, buildDir, readOnly)
 	if err != nil {
 		return nil, fmt.Errorf("failed to find rootfs partition:\n%w", err)
 	}
 
-	mountPoints, err := findMountsFromRootfs(rootfsPartition, diskPartitions, buildDir)
+	mountPoints, err := findMountsFromRootfs(rootfsPartition, diskPartitions, buildDir, readOnly)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read fstab entries from rootfs partition:\n%w", err)
 	}
@@ -123,7 +124,8 @@ func findBootPartitionFromEsp(efiSystemPartition *diskutils.PartitionInfo, diskP
 // Searches for the partition that contains the /etc/fstab file.
 // While technically it is possible to place /etc on a different partition, doing so is fairly difficult and requires
 // a custom initramfs module.
-func findRootfsPartition(diskPartitions []diskutils.PartitionInfo, buildDir string) (*diskutils.PartitionInfo, error) {
+func findRootfsPartition(diskPartitions []diskutils.PartitionInfo, buildDir string, readOnly bool,
+) (*diskutils.PartitionInfo, error) {
 	logger.Log.Debugf("Searching for rootfs partition")
 
 	tmpDir := filepath.Join(buildDir, tmpParitionDirName)
@@ -148,8 +150,9 @@ func findRootfsPartition(diskPartitions []diskutils.PartitionInfo, buildDir stri
 		}
 
 		// Temporarily mount the partition.
-		partitionMount, err := safemount.NewMount(diskPartition.Path, tmpDir, diskPartition.FileSystemType, 0,
-			"", true)
+		mountFlags, mountData := getPartitionMountFlagsAndData(diskPartition.FileSystemType, readOnly)
+		partitionMount, err := safemount.NewMount(diskPartition.Path, tmpDir, diskPartition.FileSystemType,
+			mountFlags, mountData, true)
 		if err != nil {
 			return nil, fmt.Errorf("failed to mount partition (%s):\n%w", diskPartition.Path, err)
 		}
@@ -184,15 +187,16 @@ func findRootfsPartition(diskPartitions []diskutils.PartitionInfo, buildDir stri
 }
 
 func findMountsFromRootfs(rootfsPartition *diskutils.PartitionInfo, diskPartitions []diskutils.PartitionInfo,
-	buildDir string,
+	buildDir string, readOnly bool,
 ) ([]*safechroot.MountPoint, error) {
 	logger.Log.Debugf("Reading fstab entries")
 
 	tmpDir := filepath.Join(buildDir, tmpParitionDirName)
 
 	// Temporarily mount the rootfs partition so that the fstab file can be read.
-	rootfsPartitionMount, err := safemount.NewMount(rootfsPartition.Path, tmpDir, rootfsPartition.FileSystemType, 0, "",
-		true)
+	mountFlags, mountData := getPartitionMountFlagsAndData(rootfsPartition.FileSystemType, readOnly)
+	rootfsPartitionMount, err := safemount.NewMount(rootfsPartition.Path, tmpDir, rootfsPartition.FileSystemType,
+		mountFlags, mountData, true)
 	if err != nil {
 		return nil, fmt.Errorf("failed to mount rootfs partition (%s):\n%w", rootfsPartition.Path, err)
 	}
@@ -201,7 +205,7 @@ func findMountsFromRootfs(rootfsPartition *diskutils.PartitionInfo, diskPartitio
 	// Read the fstab file.
 	fstabPath := filepath.Join(tmpDir, "/etc/fstab")
 
-	mountPoints, err := findMountsFromFstabFile(fstabPath, diskPartitions)
+	mountPoints, err := findMountsFromFstabFile(fstabPath, diskPartitions, readOnly)
 	if err != nil {
 		return nil, err
 	}
@@ -215,7 +219,7 @@ func findMountsFromRootfs(rootfsPartition *diskutils.PartitionInfo, diskPartitio
 	return mountPoints, nil
 }
 
-func findMountsFromFstabFile(fstabPath string, diskPartitions []diskutils.PartitionInfo,
+func findMountsFromFstabFile(fstabPath string, diskPartitions []diskutils.PartitionInfo, readOnly bool,
 ) ([]*safechroot.MountPoint, error) {
 	// Read the fstab file.
 	fstabEntries, err := diskutils.ReadFstabFile(fstabPath)
@@ -223,7 +227,7 @@ func findMountsFromFstabFile(fstabPath string, diskPartitions []diskutils.Partit
 		return nil, err
 	}
 
-	mountPoints, err := fstabEntriesToMountPoints(fstabEntries, diskPartitions)
+	mountPoints, err := fstabEntriesToMountPoints(fstabEntries, diskPartitions, readOnly)
 	if err != nil {
 		return nil, fmt.Errorf("failed to find mount info for fstab file entries:\n%w", err)
 	}
@@ -232,6 +236,7 @@ func findMountsFromFstabFile(fstabPath string, diskPartitions []diskutils.Partit
 }
 
 func fstabEntriesToMountPoints(fstabEntries []diskutils.FstabEntry, diskPartitions []diskutils.PartitionInfo,
+	readOnly bool,
 ) ([]*safechroot.MountPoint, error) {
 	filteredFstabEntries := filterOutSpecialPartitions(fstabEntries)
 
@@ -244,17 +249,25 @@ func fstabEntriesToMountPoints(fstabEntries []diskutils.FstabEntry, diskPartitio
 			return nil, err
 		}
 
+		mountFlags := uintptr(fstabEntry.VfsOptions)
+		mountData := fstabEntry.FsOptions
+		if readOnly {
+			readOnlyFlags, readOnlyData := getPartitionMountFlagsAndData(fstabEntry.FsType, readOnly)
+			mountFlags |= readOnlyFlags
+			mountData = appendMountData(mountData, readOnlyData)
+		}
+
 		var mountPoint *safechroot.MountPoint
 		if fstabEntry.Target == "/" {
 			mountPoint = safechroot.NewPreDefaultsMountPoint(
 				source, fstabEntry.Target, fstabEntry.FsType,
-				uintptr(fstabEntry.VfsOptions), fstabEntry.FsOptions)
+				mountFlags, mountData)
 
 			foundRoot = true
 		} else {
 			mountPoint = safechroot.NewMountPoint(
 				source, fstabEntry.Target, fstabEntry.FsType,
-				uintptr(fstabEntry.VfsOptions), fstabEntry.FsOptions)
+				mountFlags, mountData)
 		}
 
 		mountPoints = append(mountPoints, mountPoint)
@@ -267,6 +280,37 @@ func fstabEntriesToMountPoints(fstabEntries []diskutils.FstabEntry, diskPartitio
 	return mountPoints, nil
 }
 
+// getPartitionMountFlagsAndData returns the mount flags and data to use when mounting a partition. When readOnly is
+// set, the partition is mounted strictly read-only. That is, journal recovery is also suppressed, since a plain
+// read-only mount of ext3/ext4 and xfs file systems still replays the journal and writes to the disk.
+func getPartitionMountFlagsAndData(fsType string, readOnly bool) (uintptr, string) {
+	if !readOnly {
+		return 0, ""
+	}
+
+	switch fsType {
+	case "ext3", "ext4":
+		return unix.MS_RDONLY, "noload"
+
+	case "xfs":
+		return unix.MS_RDONLY, "norecovery"
+
+	default:
+		return unix.MS_RDONLY, ""
+	}
+}
+
+func appendMountData(mountData string, extraMountData string) string {
+	switch {
+	case extraMountData == "":
+		return mountData
+	case mountData == "":
+		return extraMountData
+	default:
+		return mountData + "," + extraMountData
+	}
+}
+
 func filterOutSpecialPartitions(fstabEntries []diskutils.FstabEntry) []diskutils.FstabEntry {
 	filteredFstabEntries := []diskutils.FstabEntry(nil)
 	for _, fstabEntry := range fstabEntries {
@@ -0,0 +1,36 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/sys/unix"
+)
+
+func TestGetPartitionMountFlagsAndData(t *testing.T) {
+	flags, data := getPartitionMountFlagsAndData("ext4", false)
+	assert.Equal(t, uintptr(0), flags)
+	assert.Equal(t, "", data)
+
+	flags, data = getPartitionMountFlagsAndData("ext4", true)
+	assert.Equal(t, uintptr(unix.MS_RDONLY), flags)
+	assert.Equal(t, "noload", data)
+
+	flags, data = getPartitionMountFlagsAndData("xfs", true)
+	assert.Equal(t, uintptr(unix.MS_RDONLY), flags)
+	assert.Equal(t, "norecovery", data)
+
+	flags, data = getPartitionMountFlagsAndData("vfat", true)
+	assert.Equal(t, uintptr(unix.MS_RDONLY), flags)
+	assert.Equal(t, "", data)
+}
+
+func TestAppendMountData(t *testing.T) {
+	assert.Equal(t, "", appendMountData("", ""))
+	assert.Equal(t, "noload", appendMountData("", "noload"))
+	assert.Equal(t, "umask=0077", appendMountData("umask=0077", ""))
+	assert.Equal(t, "errors=remount-ro,noload", appendMountData("errors=remount-ro", "noload"))
+}
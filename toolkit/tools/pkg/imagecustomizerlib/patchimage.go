@@ -0,0 +1,53 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/imagecustomizerapi"
+)
+
+// PatchImage updates the packages of an existing image (e.g. for a monthly CVE patch release), without the image's
+// customization config.
+//
+// All of the image's packages are updated to the latest versions available in the RPM sources, and the extra packages
+// are installed (or updated). If the kernel is updated, the initramfs files and the grub.cfg file are regenerated.
+// The image's partitions and the rest of its OS are left as-is.
+func PatchImage(ctx context.Context, buildDir string, imageFile string, rpmsSources []string,
+	useBaseImageRpmRepos bool, packages []string, outputImageFile string, outputImageFormat string,
+	buildResources BuildResources,
+) error {
+	logVersionsOfToolDeps()
+
+	buildDirAbs, err := filepath.Abs(buildDir)
+	if err != nil {
+		return fmt.Errorf("failed to get absolute path of build directory:\n%w", err)
+	}
+
+	config := patchImageConfig(packages)
+
+	// The patch config doesn't reference any files. So, the build directory stands in as the config's directory.
+	err = CustomizeImage(ctx, buildDir, buildDirAbs, config, imageFile, rpmsSources, outputImageFile,
+		outputImageFormat, "" /*outputSplitPartitionsFormat*/, "" /*outputPXEArtifactsDir*/, useBaseImageRpmRepos,
+		false /*enableShrinkFilesystems*/, CustomizeImageOptions{BuildResources: buildResources})
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func patchImageConfig(packages []string) *imagecustomizerapi.Config {
+	return &imagecustomizerapi.Config{
+		OS: &imagecustomizerapi.OS{
+			Packages: imagecustomizerapi.Packages{
+				UpdateExistingPackages: true,
+				Install:                packages,
+			},
+		},
+	}
+}
@@ -0,0 +1,38 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPatchImageConfig(t *testing.T) {
+	config := patchImageConfig([]string{"openssh-server"})
+
+	err := config.IsValid()
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.True(t, config.OS.Packages.UpdateExistingPackages)
+	assert.Equal(t, []string{"openssh-server"}, config.OS.Packages.Install)
+	assert.Nil(t, config.Storage.Disks)
+}
+
+func TestPatchImageNoRpmSources(t *testing.T) {
+	testTmpDir := filepath.Join(tmpDir, "TestPatchImageNoRpmSources")
+	defer os.RemoveAll(testTmpDir)
+
+	buildDir := filepath.Join(testTmpDir, "build")
+	outImageFilePath := filepath.Join(testTmpDir, "image.raw")
+
+	err := PatchImage(context.Background(), buildDir, "image.vhdx", nil /*rpmsSources*/, false, /*useBaseImageRpmRepos*/
+		nil /*packages*/, outImageFilePath, "raw", BuildResources{})
+	assert.ErrorContains(t, err, "have packages to install or update but no RPM sources were specified")
+}
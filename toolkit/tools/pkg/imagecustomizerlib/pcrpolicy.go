@@ -0,0 +1,420 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"debug/pe"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/imagegen/diskutils"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/file"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/jsonutils"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/logger"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/safeloopback"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/safemount"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	pcrPolicyFileSuffix = "-pcr-policy.json"
+
+	// The PCR that systemd-stub measures the UKI's sections into and that systemd-pcrphase measures the boot phases
+	// into.
+	pcrKernelBoot = 11
+
+	// TPM2_CC_PolicyPCR
+	tpm2CommandCodePolicyPcr = 0x0000017f
+	// TPM2_ALG_SHA256
+	tpm2AlgSha256 = 0x000b
+	// The size of the PCR bitmap of a TPMS_PCR_SELECTION (i.e. 24 PCRs).
+	tpm2PcrSelectSize = 3
+)
+
+var (
+	// The UKI sections that systemd-stub measures, in the order that it measures them. The '.pcrsig' section is not
+	// measured.
+	ukiMeasuredSections = []string{".linux", ".osrel", ".cmdline", ".initrd", ".splash", ".dtb", ".uname", ".sbat",
+		".pcrpkey"}
+
+	// The boot phase paths that systemd-measure predicts the PCR values for by default.
+	defaultPcrPolicyPhases = []string{
+		"enter-initrd",
+		"enter-initrd:leave-initrd",
+		"enter-initrd:leave-initrd:sysinit",
+		"enter-initrd:leave-initrd:sysinit:ready",
+	}
+)
+
+// pcrPolicyFile describes the expected measurements of the image's UKIs.
+type pcrPolicyFile struct {
+	Ukis []ukiPcrPolicy `json:"ukis"`
+}
+
+type ukiPcrPolicy struct {
+	// The path of the UKI, relative to the ESP's root.
+	Path string `json:"path"`
+	// The verity root hash on the UKI's kernel command line, if any.
+	VerityRootHash string `json:"verityRootHash,omitempty"`
+	// The measurements of the UKI's sections, in the systemd-pcrlock format.
+	Pcrlock pcrlock `json:"pcrlock"`
+	// The predicted values of the PCR after each of the boot phase paths.
+	PredictedPcrs []predictedPcr `json:"predictedPcrs"`
+	// The signed PCR policies, in the format of the UKI '.pcrsig' section (i.e. the output of 'systemd-measure sign').
+	SignedPolicies *signedPcrPolicies `json:"signedPolicies,omitempty"`
+}
+
+type pcrlock struct {
+	Records []pcrlockRecord `json:"records"`
+}
+
+type pcrlockRecord struct {
+	Pcr     int             `json:"pcr"`
+	Digests []pcrlockDigest `json:"digests"`
+}
+
+type pcrlockDigest struct {
+	HashAlg string `json:"hashAlg"`
+	Digest  string `json:"digest"`
+}
+
+type predictedPcr struct {
+	Phases string `json:"phases"`
+	Pcr    int    `json:"pcr"`
+	Sha256 string `json:"sha256"`
+}
+
+type signedPcrPolicies struct {
+	Sha256 []signedPcrPolicy `json:"sha256"`
+}
+
+type signedPcrPolicy struct {
+	Pcrs []int `json:"pcrs"`
+	// The sha256 fingerprint of the public key (hex).
+	Pkfp string `json:"pkfp"`
+	// The TPM2 policy digest (hex).
+	Pol string `json:"pol"`
+	// The signature of the policy digest (base64).
+	Sig string `json:"sig"`
+}
+
+// generatePcrPolicy writes the predicted PCR values (and optionally, the signed PCR policies) of the UKIs on the
+// image's ESP next to the output image.
+func generatePcrPolicy(ic *ImageCustomizerParameters) error {
+	logger.Log.Infof("Generating PCR policy")
+
+	pcrPolicy := ic.config.PcrPolicy
+
+	var privateKey *rsa.PrivateKey
+	if pcrPolicy.PrivateKeyPath != "" {
+		var err error
+		privateKey, err = readPcrPolicyPrivateKey(file.GetAbsPathWithBase(ic.configPath, pcrPolicy.PrivateKeyPath))
+		if err != nil {
+			return err
+		}
+	}
+
+	phases := pcrPolicy.Phases
+	if len(phases) == 0 {
+		phases = defaultPcrPolicyPhases
+	}
+
+	ukis, err := readEspUkis(ic.buildDirAbs, ic.rawImageFile)
+	if err != nil {
+		return err
+	}
+
+	if len(ukis) == 0 {
+		return fmt.Errorf("failed to generate PCR policy:\nno unified kernel images found in the ESP's (%s) directory",
+			espUkiDir)
+	}
+
+	policyFile := pcrPolicyFile{}
+	for _, uki := range ukis {
+		sections, err := readUkiSections(uki.contents)
+		if err != nil {
+			return fmt.Errorf("failed to read UKI (%s):\n%w", uki.path, err)
+		}
+
+		ukiPolicy, err := predictUkiPcrPolicy(uki.path, sections, phases, privateKey)
+		if err != nil {
+			return fmt.Errorf("failed to generate PCR policy of UKI (%s):\n%w", uki.path, err)
+		}
+
+		policyFile.Ukis = append(policyFile.Ukis, ukiPolicy)
+	}
+
+	policyFilePath := filepath.Join(ic.outputImageDir, ic.outputImageBase+pcrPolicyFileSuffix)
+
+	logger.Log.Infof("Writing PCR policy: %s", policyFilePath)
+
+	err = jsonutils.WriteJSONFile(policyFilePath, &policyFile)
+	if err != nil {
+		return fmt.Errorf("failed to write PCR policy (%s):\n%w", policyFilePath, err)
+	}
+
+	return nil
+}
+
+type espUki struct {
+	// The path of the UKI, relative to the ESP's root.
+	path     string
+	contents []byte
+}
+
+// readEspUkis reads the UKIs in the ESP of the image.
+func readEspUkis(buildDir string, rawImageFile string) ([]espUki, error) {
+	loopback, err := safeloopback.NewReadOnlyLoopback(rawImageFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to image file to read UKIs:\n%w", err)
+	}
+	defer loopback.Close()
+
+	diskPartitions, err := diskutils.GetDiskPartitions(loopback.DevicePath())
+	if err != nil {
+		return nil, err
+	}
+
+	systemBootPartition, err := findSystemBootPartition(diskPartitions)
+	if err != nil {
+		return nil, err
+	}
+
+	espMountDir := filepath.Join(buildDir, tmpParitionDirName)
+	espMount, err := safemount.NewMount(systemBootPartition.Path, espMountDir, systemBootPartition.FileSystemType,
+		unix.MS_RDONLY, "", true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to mount ESP (%s):\n%w", systemBootPartition.Path, err)
+	}
+	defer espMount.Close()
+
+	ukiPaths, err := filepath.Glob(filepath.Join(espMountDir, espUkiDir, "*.efi"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list UKIs:\n%w", err)
+	}
+
+	ukis := []espUki(nil)
+	for _, ukiPath := range ukiPaths {
+		contents, err := os.ReadFile(ukiPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read UKI (%s):\n%w", ukiPath, err)
+		}
+
+		ukis = append(ukis, espUki{
+			path:     filepath.Join(espUkiDir, filepath.Base(ukiPath)),
+			contents: contents,
+		})
+	}
+
+	err = espMount.CleanClose()
+	if err != nil {
+		return nil, err
+	}
+
+	err = loopback.CleanClose()
+	if err != nil {
+		return nil, err
+	}
+
+	return ukis, nil
+}
+
+// readUkiSections reads the sections of a UKI (PE file) that systemd-stub measures.
+func readUkiSections(ukiContents []byte) (map[string][]byte, error) {
+	peFile, err := pe.NewFile(bytes.NewReader(ukiContents))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse PE file:\n%w", err)
+	}
+	defer peFile.Close()
+
+	sections := make(map[string][]byte)
+	for _, section := range peFile.Sections {
+		if !slices.Contains(ukiMeasuredSections, section.Name) {
+			continue
+		}
+
+		data, err := section.Data()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read PE section (%s):\n%w", section.Name, err)
+		}
+
+		// systemd-stub measures the section's virtual size. The raw data is padded to the PE file's alignment.
+		if uint32(len(data)) > section.VirtualSize {
+			data = data[:section.VirtualSize]
+		}
+
+		sections[section.Name] = data
+	}
+
+	if _, hasLinux := sections[".linux"]; !hasLinux {
+		return nil, fmt.Errorf("PE file is not a UKI (missing .linux section)")
+	}
+
+	return sections, nil
+}
+
+// predictUkiPcrPolicy predicts the values of PCR 11 after the UKI is booted, in the same way as 'systemd-measure
+// calculate'. If a private key is provided, then the PCR policies are signed in the same way as 'systemd-measure sign'.
+func predictUkiPcrPolicy(ukiPath string, sections map[string][]byte, phases []string, privateKey *rsa.PrivateKey,
+) (ukiPcrPolicy, error) {
+	ukiPolicy := ukiPcrPolicy{
+		Path: ukiPath,
+	}
+
+	if cmdline, hasCmdline := sections[".cmdline"]; hasCmdline {
+		ukiPolicy.VerityRootHash = findVerityRootHash(string(cmdline))
+	}
+
+	// systemd-stub measures each section's name (including the NUL terminator) followed by its contents.
+	record := pcrlockRecord{
+		Pcr: pcrKernelBoot,
+	}
+	pcrValue := make([]byte, sha256.Size)
+	for _, sectionName := range ukiMeasuredSections {
+		data, hasSection := sections[sectionName]
+		if !hasSection {
+			continue
+		}
+
+		for _, measurement := range [][]byte{append([]byte(sectionName), 0), data} {
+			digest := sha256.Sum256(measurement)
+			record.Digests = append(record.Digests, pcrlockDigest{
+				HashAlg: "sha256",
+				Digest:  hex.EncodeToString(digest[:]),
+			})
+			pcrValue = extendPcr(pcrValue, digest[:])
+		}
+	}
+	ukiPolicy.Pcrlock.Records = []pcrlockRecord{record}
+
+	var publicKeyFingerprint string
+	if privateKey != nil {
+		publicKeyDer, err := x509.MarshalPKIXPublicKey(&privateKey.PublicKey)
+		if err != nil {
+			return ukiPolicy, fmt.Errorf("failed to serialize public key:\n%w", err)
+		}
+
+		fingerprint := sha256.Sum256(publicKeyDer)
+		publicKeyFingerprint = hex.EncodeToString(fingerprint[:])
+		ukiPolicy.SignedPolicies = &signedPcrPolicies{}
+	}
+
+	for _, phasePath := range phases {
+		// systemd-pcrphase measures each phase's name (without a NUL terminator).
+		phasePcrValue := pcrValue
+		for _, phase := range strings.Split(phasePath, ":") {
+			digest := sha256.Sum256([]byte(phase))
+			phasePcrValue = extendPcr(phasePcrValue, digest[:])
+		}
+
+		ukiPolicy.PredictedPcrs = append(ukiPolicy.PredictedPcrs, predictedPcr{
+			Phases: phasePath,
+			Pcr:    pcrKernelBoot,
+			Sha256: hex.EncodeToString(phasePcrValue),
+		})
+
+		if privateKey == nil {
+			continue
+		}
+
+		policyDigest := calculatePcrPolicyDigest(pcrKernelBoot, phasePcrValue)
+		hashedPolicyDigest := sha256.Sum256(policyDigest)
+
+		signature, err := rsa.SignPKCS1v15(rand.Reader, privateKey, crypto.SHA256, hashedPolicyDigest[:])
+		if err != nil {
+			return ukiPolicy, fmt.Errorf("failed to sign PCR policy:\n%w", err)
+		}
+
+		ukiPolicy.SignedPolicies.Sha256 = append(ukiPolicy.SignedPolicies.Sha256, signedPcrPolicy{
+			Pcrs: []int{pcrKernelBoot},
+			Pkfp: publicKeyFingerprint,
+			Pol:  hex.EncodeToString(policyDigest),
+			Sig:  base64.StdEncoding.EncodeToString(signature),
+		})
+	}
+
+	return ukiPolicy, nil
+}
+
+// extendPcr returns the value of a (sha256) PCR after it is extended with the digest.
+func extendPcr(pcrValue []byte, digest []byte) []byte {
+	newValue := sha256.Sum256(append(slices.Clone(pcrValue), digest...))
+	return newValue[:]
+}
+
+// calculatePcrPolicyDigest calculates the digest of a TPM2 policy session after a TPM2_PolicyPCR command that checks a
+// single (sha256) PCR's value.
+func calculatePcrPolicyDigest(pcr int, pcrValue []byte) []byte {
+	pcrDigest := sha256.Sum256(pcrValue)
+
+	// TPML_PCR_SELECTION with a single TPMS_PCR_SELECTION.
+	pcrSelect := make([]byte, tpm2PcrSelectSize)
+	pcrSelect[pcr/8] |= 1 << (pcr % 8)
+
+	buffer := bytes.Buffer{}
+	buffer.Write(make([]byte, sha256.Size))
+	binary.Write(&buffer, binary.BigEndian, uint32(tpm2CommandCodePolicyPcr))
+	binary.Write(&buffer, binary.BigEndian, uint32(1))
+	binary.Write(&buffer, binary.BigEndian, uint16(tpm2AlgSha256))
+	buffer.WriteByte(tpm2PcrSelectSize)
+	buffer.Write(pcrSelect)
+	buffer.Write(pcrDigest[:])
+
+	policyDigest := sha256.Sum256(buffer.Bytes())
+	return policyDigest[:]
+}
+
+// findVerityRootHash returns the value of the 'roothash' arg of a kernel command line.
+func findVerityRootHash(cmdline string) string {
+	for _, arg := range strings.Fields(cmdline) {
+		value, found := strings.CutPrefix(arg, "roothash=")
+		if found {
+			return value
+		}
+	}
+	return ""
+}
+
+func readPcrPolicyPrivateKey(privateKeyPath string) (*rsa.PrivateKey, error) {
+	privateKeyPem, err := os.ReadFile(privateKeyPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read PCR policy private key (%s):\n%w", privateKeyPath, err)
+	}
+
+	block, _ := pem.Decode(privateKeyPem)
+	if block == nil {
+		return nil, fmt.Errorf("failed to parse PCR policy private key (%s):\nnot a PEM file", privateKeyPath)
+	}
+
+	var privateKey any
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		privateKey, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		privateKey, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse PCR policy private key (%s):\n%w", privateKeyPath, err)
+	}
+
+	rsaPrivateKey, isRsa := privateKey.(*rsa.PrivateKey)
+	if !isRsa {
+		return nil, fmt.Errorf("PCR policy private key (%s) is not an RSA key", privateKeyPath)
+	}
+
+	return rsaPrivateKey, nil
+}
@@ -0,0 +1,178 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testUkiSections() map[string][]byte {
+	return map[string][]byte{
+		".linux":   []byte("kernel"),
+		".osrel":   []byte("ID=azurelinux\nVERSION_ID=3.0\n"),
+		".cmdline": []byte("console=ttyS0 roothash=0123abcd"),
+		".initrd":  []byte("initrd"),
+	}
+}
+
+func TestPredictUkiPcrPolicy(t *testing.T) {
+	ukiPolicy, err := predictUkiPcrPolicy("EFI/Linux/azl.efi", testUkiSections(), defaultPcrPolicyPhases, nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, "EFI/Linux/azl.efi", ukiPolicy.Path)
+	assert.Equal(t, "0123abcd", ukiPolicy.VerityRootHash)
+	assert.Nil(t, ukiPolicy.SignedPolicies)
+
+	// The section names and the section contents are measured separately.
+	if assert.Len(t, ukiPolicy.Pcrlock.Records, 1) {
+		assert.Equal(t, pcrKernelBoot, ukiPolicy.Pcrlock.Records[0].Pcr)
+		assert.Len(t, ukiPolicy.Pcrlock.Records[0].Digests, 8)
+	}
+
+	// The expected values were calculated by 'systemd-measure calculate'.
+	assert.Equal(t, []predictedPcr{
+		{
+			Phases: "enter-initrd",
+			Pcr:    11,
+			Sha256: "eba4650cd86c9be5288fd8f91a97d7e09bcf13bdbce106730de455fa839f4b7c",
+		},
+		{
+			Phases: "enter-initrd:leave-initrd",
+			Pcr:    11,
+			Sha256: "6ab93db7b7968a994862bb148158648aff7d0d190bf46f0de3cb8af3c02ecd73",
+		},
+		{
+			Phases: "enter-initrd:leave-initrd:sysinit",
+			Pcr:    11,
+			Sha256: "8c662f551df5013240dc109c7646e681ba10d26d874aa456d24994c0a329db2b",
+		},
+		{
+			Phases: "enter-initrd:leave-initrd:sysinit:ready",
+			Pcr:    11,
+			Sha256: "944311fbb1c244b7185d3510bdc20bb0c66791c17b088d4491a83497f5608eb0",
+		},
+	}, ukiPolicy.PredictedPcrs)
+}
+
+func TestPredictUkiPcrPolicySigned(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	phases := []string{"enter-initrd"}
+
+	ukiPolicy, err := predictUkiPcrPolicy("EFI/Linux/azl.efi", testUkiSections(), phases, privateKey)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	if !assert.NotNil(t, ukiPolicy.SignedPolicies) || !assert.Len(t, ukiPolicy.SignedPolicies.Sha256, 1) {
+		return
+	}
+
+	signedPolicy := ukiPolicy.SignedPolicies.Sha256[0]
+	assert.Equal(t, []int{pcrKernelBoot}, signedPolicy.Pcrs)
+
+	publicKeyDer, err := x509.MarshalPKIXPublicKey(&privateKey.PublicKey)
+	if !assert.NoError(t, err) {
+		return
+	}
+	fingerprint := sha256.Sum256(publicKeyDer)
+	assert.Equal(t, hex.EncodeToString(fingerprint[:]), signedPolicy.Pkfp)
+
+	pcrValue, err := hex.DecodeString(ukiPolicy.PredictedPcrs[0].Sha256)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, hex.EncodeToString(calculatePcrPolicyDigest(pcrKernelBoot, pcrValue)), signedPolicy.Pol)
+
+	policyDigest, err := hex.DecodeString(signedPolicy.Pol)
+	if !assert.NoError(t, err) {
+		return
+	}
+	signature, err := base64.StdEncoding.DecodeString(signedPolicy.Sig)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	hashedPolicyDigest := sha256.Sum256(policyDigest)
+	err = rsa.VerifyPKCS1v15(&privateKey.PublicKey, crypto.SHA256, hashedPolicyDigest[:], signature)
+	assert.NoError(t, err)
+}
+
+func TestFindVerityRootHash(t *testing.T) {
+	assert.Equal(t, "0123abcd", findVerityRootHash("console=ttyS0 roothash=0123abcd ro\n"))
+	assert.Equal(t, "", findVerityRootHash("console=ttyS0 ro"))
+}
+
+func TestReadPcrPolicyPrivateKey(t *testing.T) {
+	testTempDir := filepath.Join(tmpDir, "TestReadPcrPolicyPrivateKey")
+	defer os.RemoveAll(testTempDir)
+
+	err := os.MkdirAll(testTempDir, os.ModePerm)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	rsaPrivateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	ecdsaPrivateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	pkcs8RsaPrivateKey, err := x509.MarshalPKCS8PrivateKey(rsaPrivateKey)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	pkcs8EcdsaPrivateKey, err := x509.MarshalPKCS8PrivateKey(ecdsaPrivateKey)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	writeKey := func(name string, blockType string, der []byte) string {
+		path := filepath.Join(testTempDir, name)
+		err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600)
+		assert.NoError(t, err)
+		return path
+	}
+
+	pkcs1Path := writeKey("pkcs1.key", "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(rsaPrivateKey))
+	pkcs8Path := writeKey("pkcs8.key", "PRIVATE KEY", pkcs8RsaPrivateKey)
+	ecdsaPath := writeKey("ecdsa.key", "PRIVATE KEY", pkcs8EcdsaPrivateKey)
+
+	privateKey, err := readPcrPolicyPrivateKey(pkcs1Path)
+	if assert.NoError(t, err) {
+		assert.True(t, rsaPrivateKey.Equal(privateKey))
+	}
+
+	privateKey, err = readPcrPolicyPrivateKey(pkcs8Path)
+	if assert.NoError(t, err) {
+		assert.True(t, rsaPrivateKey.Equal(privateKey))
+	}
+
+	_, err = readPcrPolicyPrivateKey(ecdsaPath)
+	assert.ErrorContains(t, err, "is not an RSA key")
+}
@@ -0,0 +1,97 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strings"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/imagecustomizerapi"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/file"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/grub"
+)
+
+// pxeAnswerFile is the build representation of imagecustomizerapi.PxeAnswerFile.
+type pxeAnswerFile struct {
+	// The absolute path of the file on the build machine.
+	sourcePath     string
+	destination    string
+	kernelArgument string
+}
+
+func micPxeConfigToAnswerFiles(baseConfigPath string, pxeConfig *imagecustomizerapi.Pxe) []pxeAnswerFile {
+	answerFiles := []pxeAnswerFile(nil)
+	for _, answerFile := range pxeConfig.AnswerFiles {
+		answerFiles = append(answerFiles, pxeAnswerFile{
+			sourcePath:     file.GetAbsPathWithBase(baseConfigPath, answerFile.Source),
+			destination:    answerFile.Destination,
+			kernelArgument: answerFile.KernelArgument,
+		})
+	}
+	return answerFiles
+}
+
+// applyPxeAnswerFiles
+//
+//   - copies the answer files into a populated PXE artifacts folder.
+//   - sets the kernel arguments of the answer files, in the PXE grub.cfg, to
+//     the URLs of the answer files. The URLs are derived from the base URL of
+//     the PXE artifacts folder. So, they always match where the files are
+//     hosted.
+//
+// inputs:
+//
+//   - 'outputPXEArtifactsDir':
+//     path to the populated PXE artifacts folder.
+//   - 'pxeArtifactsBaseUrl':
+//     the URL of the PXE artifacts folder on the server.
+//   - 'answerFiles':
+//     the answer files.
+func applyPxeAnswerFiles(outputPXEArtifactsDir string, pxeArtifactsBaseUrl string, answerFiles []pxeAnswerFile) error {
+	kernelArgs := []string(nil)
+	for _, answerFile := range answerFiles {
+		targetPath := filepath.Join(outputPXEArtifactsDir, answerFile.destination)
+		err := file.Copy(answerFile.sourcePath, targetPath)
+		if err != nil {
+			return fmt.Errorf("failed to copy answer file (%s) to (%s):\n%w", answerFile.sourcePath, targetPath, err)
+		}
+
+		if answerFile.kernelArgument == "" {
+			continue
+		}
+
+		answerFileUrl, err := url.JoinPath(pxeArtifactsBaseUrl, answerFile.destination)
+		if err != nil {
+			return fmt.Errorf("failed to concatenate URL (%s) and (%s)\n%w", pxeArtifactsBaseUrl,
+				answerFile.destination, err)
+		}
+
+		kernelArgs = append(kernelArgs, grub.QuoteString(answerFile.kernelArgument+"="+answerFileUrl))
+	}
+
+	if len(kernelArgs) == 0 {
+		return nil
+	}
+
+	pxeGrubCfgPath := filepath.Join(outputPXEArtifactsDir, grubCfgDir, isoGrubCfg)
+	pxeGrubCfgContent, err := file.Read(pxeGrubCfgPath)
+	if err != nil {
+		return fmt.Errorf("failed to read PXE grub.cfg (%s):\n%w", pxeGrubCfgPath, err)
+	}
+
+	pxeGrubCfgContent, err = appendKernelCommandLineArgsAll(pxeGrubCfgContent, strings.Join(kernelArgs, " "),
+		true /*allowMultiple*/, false /*requireKernelOpts*/)
+	if err != nil {
+		return fmt.Errorf("failed to append the answer files kernel arguments in the PXE grub.cfg:\n%w", err)
+	}
+
+	err = file.Write(pxeGrubCfgContent, pxeGrubCfgPath)
+	if err != nil {
+		return fmt.Errorf("failed to write PXE grub.cfg (%s):\n%w", pxeGrubCfgPath, err)
+	}
+
+	return nil
+}
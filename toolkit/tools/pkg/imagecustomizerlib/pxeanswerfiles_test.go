@@ -0,0 +1,57 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/file"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApplyPxeAnswerFiles(t *testing.T) {
+	testDir := filepath.Join(tmpDir, "TestApplyPxeAnswerFiles")
+	defer os.RemoveAll(testDir)
+
+	pxeDir := filepath.Join(testDir, "pxe-artifacts")
+	err := os.MkdirAll(filepath.Join(pxeDir, grubCfgDir), os.ModePerm)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	err = file.Write(testPxeGrubCfg, filepath.Join(pxeDir, grubCfgDir, isoGrubCfg))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	kickstartPath := filepath.Join(testDir, "host.ks")
+	err = file.Write("kickstart", kickstartPath)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	answerFiles := []pxeAnswerFile{
+		{sourcePath: kickstartPath, destination: "ks/host.ks", kernelArgument: "inst.ks"},
+		{sourcePath: kickstartPath, destination: "ks/hosted-only.ks"},
+	}
+	err = applyPxeAnswerFiles(pxeDir, "http://192.168.0.1/pxe/", answerFiles)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.FileExists(t, filepath.Join(pxeDir, "ks/host.ks"))
+	assert.FileExists(t, filepath.Join(pxeDir, "ks/hosted-only.ks"))
+
+	bootEntries, err := readPxeBootEntries(pxeDir)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	for _, bootEntry := range bootEntries {
+		assert.Contains(t, bootEntry.KernelArgs, "inst.ks=http://192.168.0.1/pxe/ks/host.ks")
+		assert.NotContains(t, bootEntry.KernelArgs, "hosted-only.ks")
+	}
+}
@@ -0,0 +1,320 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/imagecustomizerapi"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/file"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/jsonutils"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/logger"
+)
+
+const (
+	// The manifest of the PXE artifacts. It is placed at the root of the PXE artifacts folder (and of its archive).
+	pxeArtifactsManifestFileName = "pxe-manifest.json"
+)
+
+// pxeArtifactsManifest describes the contents of a PXE artifacts folder.
+type pxeArtifactsManifest struct {
+	ToolVersion    string                     `json:"toolVersion"`
+	IsoImage       string                     `json:"isoImage"`
+	ReleaseVersion string                     `json:"releaseVersion,omitempty"`
+	Tag            string                     `json:"tag,omitempty"`
+	Files          []pxeArtifactsManifestFile `json:"files"`
+}
+
+type pxeArtifactsManifestFile struct {
+	// The path of the file, relative to the PXE artifacts folder.
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	Sha256 string `json:"sha256"`
+}
+
+// createPxeArtifactsArchive
+//
+//   - writes a manifest (with the checksums of the files) to the PXE
+//     artifacts folder.
+//   - packs the PXE artifacts folder into a single archive file, placed next
+//     to the folder. The archive's name is derived from the iso's name, so
+//     that it carries the iso's release version and tag.
+//   - writes the sha256 of the archive to '<archive>.sha256', in the format
+//     of the 'sha256sum' tool.
+//
+// inputs:
+//
+//   - 'outputPXEArtifactsDir':
+//     path to the populated PXE artifacts folder.
+//   - 'isoImageNameInfo':
+//     the name of the iso image in the PXE artifacts folder.
+//   - 'format':
+//     the format of the archive.
+//   - 'sourceDateEpoch':
+//     if set, the timestamp of the files in the archive. Otherwise, the
+//     files' modification times are used.
+//
+// outputs:
+//
+//   - returns the path of the archive.
+func createPxeArtifactsArchive(outputPXEArtifactsDir string, isoImageNameInfo isoImageNameInfo,
+	format imagecustomizerapi.PxeArtifactsArchiveFormat, sourceDateEpoch *time.Time,
+) (string, error) {
+	err := writePxeArtifactsManifest(outputPXEArtifactsDir, isoImageNameInfo)
+	if err != nil {
+		return "", err
+	}
+
+	outputPXEArtifactsDir = filepath.Clean(outputPXEArtifactsDir)
+	archiveName := fmt.Sprintf("%s-pxe.%s", strings.TrimSuffix(isoImageNameInfo.name, ".iso"), format)
+	archivePath := filepath.Join(filepath.Dir(outputPXEArtifactsDir), archiveName)
+
+	logger.Log.Infof("Creating PXE artifacts archive (%s)", archivePath)
+
+	archiveFile, err := os.Create(archivePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to create PXE artifacts archive (%s):\n%w", archivePath, err)
+	}
+	defer archiveFile.Close()
+
+	switch format {
+	case imagecustomizerapi.PxeArtifactsArchiveFormatTarGz:
+		err = writePxeArtifactsTarGz(archiveFile, outputPXEArtifactsDir, sourceDateEpoch)
+	case imagecustomizerapi.PxeArtifactsArchiveFormatZip:
+		err = writePxeArtifactsZip(archiveFile, outputPXEArtifactsDir, sourceDateEpoch)
+	default:
+		err = fmt.Errorf("unsupported format (%s)", format)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to write PXE artifacts archive (%s):\n%w", archivePath, err)
+	}
+
+	err = archiveFile.Close()
+	if err != nil {
+		return "", fmt.Errorf("failed to write PXE artifacts archive (%s):\n%w", archivePath, err)
+	}
+
+	archiveSha256, err := file.GenerateSHA256(archivePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to calculate the sha256 of (%s):\n%w", archivePath, err)
+	}
+
+	checksumFilePath := archivePath + ".sha256"
+	err = file.Write(fmt.Sprintf("%s  %s\n", archiveSha256, archiveName), checksumFilePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to write checksum file (%s):\n%w", checksumFilePath, err)
+	}
+
+	return archivePath, nil
+}
+
+// writePxeArtifactsManifest writes the manifest of the files in the PXE artifacts folder.
+func writePxeArtifactsManifest(outputPXEArtifactsDir string, isoImageNameInfo isoImageNameInfo) error {
+	manifest := pxeArtifactsManifest{
+		ToolVersion:    ToolVersion,
+		IsoImage:       isoImageNameInfo.name,
+		ReleaseVersion: isoImageNameInfo.releaseVersion,
+		Tag:            strings.TrimPrefix(isoImageNameInfo.tag, "-"),
+		Files:          []pxeArtifactsManifestFile{},
+	}
+
+	manifestPath := filepath.Join(outputPXEArtifactsDir, pxeArtifactsManifestFileName)
+
+	err := filepath.WalkDir(outputPXEArtifactsDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !d.Type().IsRegular() || path == manifestPath {
+			return nil
+		}
+
+		relativePath, err := filepath.Rel(outputPXEArtifactsDir, path)
+		if err != nil {
+			return err
+		}
+
+		fileInfo, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		fileSha256, err := file.GenerateSHA256(path)
+		if err != nil {
+			return fmt.Errorf("failed to calculate the sha256 of (%s):\n%w", path, err)
+		}
+
+		manifest.Files = append(manifest.Files, pxeArtifactsManifestFile{
+			Path:   filepath.ToSlash(relativePath),
+			Size:   fileInfo.Size(),
+			Sha256: fileSha256,
+		})
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to enumerate PXE artifacts (%s):\n%w", outputPXEArtifactsDir, err)
+	}
+
+	err = jsonutils.WriteJSONFile(manifestPath, &manifest)
+	if err != nil {
+		return fmt.Errorf("failed to write PXE artifacts manifest (%s):\n%w", manifestPath, err)
+	}
+
+	return nil
+}
+
+// walkPxeArtifacts calls 'fn' for each file, folder and symbolic link under the PXE artifacts folder (excluding the
+// folder itself), in lexical order.
+func walkPxeArtifacts(outputPXEArtifactsDir string,
+	fn func(path string, relativePath string, fileInfo fs.FileInfo, linkTarget string) error,
+) error {
+	return filepath.WalkDir(outputPXEArtifactsDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if path == outputPXEArtifactsDir {
+			return nil
+		}
+
+		relativePath, err := filepath.Rel(outputPXEArtifactsDir, path)
+		if err != nil {
+			return err
+		}
+
+		fileInfo, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		linkTarget := ""
+		switch {
+		case fileInfo.Mode()&fs.ModeSymlink != 0:
+			linkTarget, err = os.Readlink(path)
+			if err != nil {
+				return err
+			}
+
+		case !fileInfo.IsDir() && !fileInfo.Mode().IsRegular():
+			return fmt.Errorf("unsupported file type (%s)", path)
+		}
+
+		return fn(path, filepath.ToSlash(relativePath), fileInfo, linkTarget)
+	})
+}
+
+func writePxeArtifactsTarGz(w io.Writer, outputPXEArtifactsDir string, sourceDateEpoch *time.Time) error {
+	gzipWriter := gzip.NewWriter(w)
+	tarWriter := tar.NewWriter(gzipWriter)
+
+	err := walkPxeArtifacts(outputPXEArtifactsDir,
+		func(path string, relativePath string, fileInfo fs.FileInfo, linkTarget string) error {
+			header, err := tar.FileInfoHeader(fileInfo, linkTarget)
+			if err != nil {
+				return err
+			}
+
+			header.Name = relativePath
+			if fileInfo.IsDir() {
+				header.Name += "/"
+			}
+
+			// Don't leak the build machine's users.
+			header.Uid, header.Gid, header.Uname, header.Gname = 0, 0, "", ""
+
+			if sourceDateEpoch != nil {
+				header.ModTime = *sourceDateEpoch
+				header.AccessTime = time.Time{}
+				header.ChangeTime = time.Time{}
+			}
+
+			err = tarWriter.WriteHeader(header)
+			if err != nil {
+				return err
+			}
+
+			if !fileInfo.Mode().IsRegular() {
+				return nil
+			}
+
+			return copyFileTo(tarWriter, path)
+		})
+	if err != nil {
+		return err
+	}
+
+	err = tarWriter.Close()
+	if err != nil {
+		return err
+	}
+
+	return gzipWriter.Close()
+}
+
+func writePxeArtifactsZip(w io.Writer, outputPXEArtifactsDir string, sourceDateEpoch *time.Time) error {
+	zipWriter := zip.NewWriter(w)
+
+	err := walkPxeArtifacts(outputPXEArtifactsDir,
+		func(path string, relativePath string, fileInfo fs.FileInfo, linkTarget string) error {
+			header, err := zip.FileInfoHeader(fileInfo)
+			if err != nil {
+				return err
+			}
+
+			header.Name = relativePath
+			if fileInfo.IsDir() {
+				header.Name += "/"
+			} else {
+				header.Method = zip.Deflate
+			}
+
+			if sourceDateEpoch != nil {
+				header.Modified = *sourceDateEpoch
+			}
+
+			entryWriter, err := zipWriter.CreateHeader(header)
+			if err != nil {
+				return err
+			}
+
+			switch {
+			case linkTarget != "":
+				// By convention, the contents of a symbolic link entry are the link's target.
+				_, err = entryWriter.Write([]byte(linkTarget))
+				return err
+
+			case fileInfo.Mode().IsRegular():
+				return copyFileTo(entryWriter, path)
+
+			default:
+				return nil
+			}
+		})
+	if err != nil {
+		return err
+	}
+
+	return zipWriter.Close()
+}
+
+// copyFileTo copies the contents of a file to a writer.
+func copyFileTo(w io.Writer, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(w, f)
+	return err
+}
@@ -0,0 +1,140 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/imagecustomizerapi"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/file"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/jsonutils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreatePxeArtifactsArchive(t *testing.T) {
+	testDir := filepath.Join(tmpDir, "TestCreatePxeArtifactsArchive")
+	defer os.RemoveAll(testDir)
+
+	pxeDir := filepath.Join(testDir, "pxe-artifacts")
+	err := os.MkdirAll(filepath.Join(pxeDir, "boot/grub2"), os.ModePerm)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	pxeFiles := map[string]string{
+		"bootx64.efi":                 "shim",
+		"boot/grub2/grub.cfg":         "grub config",
+		"azl-3.0.20240601-livecd.iso": "iso",
+	}
+	for path, content := range pxeFiles {
+		err = file.Write(content, filepath.Join(pxeDir, path))
+		if !assert.NoError(t, err) {
+			return
+		}
+	}
+
+	isoImageNameInfo := getImageNameFromImageBaseName("azl",
+		isoReleaseInfo{releaseVersion: "3.0.20240601", tag: "livecd"})
+	sourceDateEpoch := time.Unix(1700000000, 0).UTC()
+
+	archivePath, err := createPxeArtifactsArchive(pxeDir, isoImageNameInfo,
+		imagecustomizerapi.PxeArtifactsArchiveFormatTarGz, &sourceDateEpoch)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, filepath.Join(testDir, "azl-3.0.20240601-livecd-pxe.tar.gz"), archivePath)
+
+	var manifest pxeArtifactsManifest
+	err = jsonutils.ReadJSONFile(filepath.Join(pxeDir, pxeArtifactsManifestFileName), &manifest)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "azl-3.0.20240601-livecd.iso", manifest.IsoImage)
+	assert.Equal(t, "3.0.20240601", manifest.ReleaseVersion)
+	assert.Equal(t, "livecd", manifest.Tag)
+	if assert.Len(t, manifest.Files, 3) {
+		// The files are listed in lexical order.
+		assert.Equal(t, "azl-3.0.20240601-livecd.iso", manifest.Files[0].Path)
+		assert.Equal(t, "boot/grub2/grub.cfg", manifest.Files[1].Path)
+		assert.Equal(t, int64(len("grub config")), manifest.Files[1].Size)
+		assert.Len(t, manifest.Files[1].Sha256, 64)
+	}
+
+	archiveSha256, err := file.GenerateSHA256(archivePath)
+	if !assert.NoError(t, err) {
+		return
+	}
+	checksumFileContents, err := file.Read(archivePath + ".sha256")
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, archiveSha256+"  azl-3.0.20240601-livecd-pxe.tar.gz\n", checksumFileContents)
+
+	archiveFile, err := os.Open(archivePath)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer archiveFile.Close()
+
+	gzipReader, err := gzip.NewReader(archiveFile)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	tarContents := make(map[string]string)
+	tarReader := tar.NewReader(gzipReader)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		assert.Equal(t, sourceDateEpoch, header.ModTime.UTC())
+
+		if header.Typeflag == tar.TypeReg {
+			contents, err := io.ReadAll(tarReader)
+			if !assert.NoError(t, err) {
+				return
+			}
+			tarContents[header.Name] = string(contents)
+		}
+	}
+
+	for path, content := range pxeFiles {
+		assert.Equal(t, content, tarContents[path])
+	}
+	assert.Contains(t, tarContents, pxeArtifactsManifestFileName)
+
+	archivePath, err = createPxeArtifactsArchive(pxeDir, isoImageNameInfo,
+		imagecustomizerapi.PxeArtifactsArchiveFormatZip, nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.True(t, strings.HasSuffix(archivePath, "-pxe.zip"))
+
+	zipReader, err := zip.OpenReader(archivePath)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer zipReader.Close()
+
+	zipFiles := []string(nil)
+	for _, zipFile := range zipReader.File {
+		zipFiles = append(zipFiles, zipFile.Name)
+	}
+	assert.Contains(t, zipFiles, "boot/grub2/grub.cfg")
+	assert.Contains(t, zipFiles, "boot/")
+	assert.Contains(t, zipFiles, pxeArtifactsManifestFileName)
+}
@@ -0,0 +1,139 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/imagegen/diskutils"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/file"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/logger"
+)
+
+const (
+	// The folder (under the PXE artifacts folder) that holds the versioned
+	// kernel/initrd folders in the TFTP layout.
+	pxeTftpImagesDir = "images"
+
+	// With the default block size of 512 bytes and no block number rollover,
+	// a TFTP transfer can't exceed 32MiB. Even when the client and the server
+	// negotiate larger blocks, transfers of this size are slow and fragile.
+	pxeTftpMaxPracticalFileSize = 32 * diskutils.MiB
+)
+
+// applyPxeTftpLayout
+//
+//   - moves the kernel and the initrd image of a populated PXE artifacts
+//     folder to a versioned folder (images/<iso-name>/), following the common
+//     dnsmasq/tftpd conventions. The bootloaders stay at the root.
+//   - updates the PXE grub.cfg to load them from their new location.
+//
+// inputs:
+//
+//   - 'outputPXEArtifactsDir':
+//     path to the populated PXE artifacts folder.
+//   - 'isoImageNameInfo':
+//     the name of the iso image. Its release version and tag make the folder
+//     name unique across builds.
+func applyPxeTftpLayout(outputPXEArtifactsDir string, isoImageNameInfo isoImageNameInfo) error {
+	versionedDir := path.Join("/", pxeTftpImagesDir, strings.TrimSuffix(isoImageNameInfo.name, ".iso"))
+	kernelPath := path.Join(versionedDir, path.Base(isoKernelPath))
+	initrdPath := path.Join(versionedDir, path.Base(isoInitrdPath))
+
+	logger.Log.Infof("Moving kernel and initrd image to (%s)", filepath.Join(outputPXEArtifactsDir, versionedDir))
+
+	filesToMove := [][2]string{
+		{isoKernelPath, kernelPath},
+		{isoInitrdPath, initrdPath},
+	}
+	for _, fileToMove := range filesToMove {
+		sourcePath := filepath.Join(outputPXEArtifactsDir, fileToMove[0])
+		targetPath := filepath.Join(outputPXEArtifactsDir, fileToMove[1])
+		err := file.Move(sourcePath, targetPath)
+		if err != nil {
+			return fmt.Errorf("failed to move (%s) to (%s) while applying the TFTP layout:\n%w", sourcePath, targetPath, err)
+		}
+	}
+
+	pxeGrubCfgPath := filepath.Join(outputPXEArtifactsDir, grubCfgDir, isoGrubCfg)
+	pxeGrubCfgContent, err := file.Read(pxeGrubCfgPath)
+	if err != nil {
+		return fmt.Errorf("failed to read PXE grub.cfg (%s):\n%w", pxeGrubCfgPath, err)
+	}
+
+	pxeGrubCfgContent, _, err = setLinuxOrInitrdPathAll(pxeGrubCfgContent, linuxCommand, kernelPath, true /*allowMultiple*/)
+	if err != nil {
+		return fmt.Errorf("failed to update the kernel path in the PXE grub.cfg:\n%w", err)
+	}
+
+	pxeGrubCfgContent, _, err = setLinuxOrInitrdPathAll(pxeGrubCfgContent, initrdCommand, initrdPath, true /*allowMultiple*/)
+	if err != nil {
+		return fmt.Errorf("failed to update the initrd path in the PXE grub.cfg:\n%w", err)
+	}
+
+	err = file.Write(pxeGrubCfgContent, pxeGrubCfgPath)
+	if err != nil {
+		return fmt.Errorf("failed to write PXE grub.cfg (%s):\n%w", pxeGrubCfgPath, err)
+	}
+
+	return nil
+}
+
+// warnPxeArtifactsSizes
+//
+//   - warns if the initrd image, or the live image (i.e. the iso image or the
+//     rootfs image, when it is downloaded over TFTP), are too large to be
+//     practically downloaded over TFTP.
+//
+// inputs:
+//
+//   - 'outputPXEArtifactsDir':
+//     path to the populated PXE artifacts folder.
+//   - 'liveImagePath':
+//     the path of the live image, relative to the PXE artifacts folder.
+func warnPxeArtifactsSizes(outputPXEArtifactsDir string, liveImagePath string) error {
+	bootEntries, err := readPxeBootEntries(outputPXEArtifactsDir)
+	if err != nil {
+		// The checks are only advisory. So, don't fail the build.
+		logger.Log.Debugf("Skipping the PXE artifacts size checks:\n%v", err)
+		return nil
+	}
+
+	bootEntry := bootEntries[0]
+
+	initrdPath := filepath.Join(outputPXEArtifactsDir, bootEntry.InitrdPath)
+	err = warnIfTooLargeForTftp(initrdPath, "initrd image",
+		"Consider booting over HTTP (e.g. UEFI HTTP boot or iPXE).")
+	if err != nil {
+		return err
+	}
+
+	if strings.HasPrefix(bootEntry.IsoImageUrl, "tftp://") {
+		err = warnIfTooLargeForTftp(filepath.Join(outputPXEArtifactsDir, liveImagePath), "live image",
+			"Consider downloading it over HTTP (see pxe.isoImageBaseUrl and pxe.isoImageFileUrl).")
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func warnIfTooLargeForTftp(filePath string, description string, suggestion string) error {
+	fileInfo, err := os.Stat(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to get the size of (%s):\n%w", filePath, err)
+	}
+
+	if fileInfo.Size() > pxeTftpMaxPracticalFileSize {
+		logger.Log.Warnf("The %s (%s) is %d MiB, which is impractical to download over TFTP. %s", description,
+			filePath, fileInfo.Size()/diskutils.MiB, suggestion)
+	}
+
+	return nil
+}
@@ -0,0 +1,59 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/file"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApplyPxeTftpLayout(t *testing.T) {
+	testDir := filepath.Join(tmpDir, "TestApplyPxeTftpLayout")
+	defer os.RemoveAll(testDir)
+
+	err := os.MkdirAll(filepath.Join(testDir, grubCfgDir), os.ModePerm)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	testFiles := map[string]string{
+		filepath.Join(grubCfgDir, isoGrubCfg): testPxeGrubCfg,
+		isoKernelPath:                         "kernel",
+		isoInitrdPath:                         "initrd",
+	}
+	for path, content := range testFiles {
+		err = file.Write(content, filepath.Join(testDir, path))
+		if !assert.NoError(t, err) {
+			return
+		}
+	}
+
+	isoImageNameInfo := getImageNameFromImageBaseName("azl", isoReleaseInfo{releaseVersion: "3.0.20240601"})
+	err = applyPxeTftpLayout(testDir, isoImageNameInfo)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.NoFileExists(t, filepath.Join(testDir, isoKernelPath))
+	assert.NoFileExists(t, filepath.Join(testDir, isoInitrdPath))
+	assert.FileExists(t, filepath.Join(testDir, "images/azl-3.0.20240601/vmlinuz"))
+	assert.FileExists(t, filepath.Join(testDir, "images/azl-3.0.20240601/initrd.img"))
+
+	bootEntries, err := readPxeBootEntries(testDir)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	for _, bootEntry := range bootEntries {
+		assert.Equal(t, "images/azl-3.0.20240601/vmlinuz", bootEntry.KernelPath)
+		assert.Equal(t, "images/azl-3.0.20240601/initrd.img", bootEntry.InitrdPath)
+	}
+
+	err = warnPxeArtifactsSizes(testDir, isoImageNameInfo.name)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,154 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/file"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/safechroot"
+)
+
+// canStagePXEArtifacts
+//
+//   - checks whether the builder knows the source of each file that the PXE
+//     artifacts folder needs. isomaker extracts the kernel and the
+//     bootloaders from the initrd image. So, their sources are only known
+//     when the artifacts come from the OS (i.e. not from an input iso whose
+//     OS is unchanged).
+func (b *LiveOSIsoBuilder) canStagePXEArtifacts() (bool, error) {
+	if b.artifacts.bootx64EfiPath == "" || b.artifacts.vmlinuzPath == "" || b.artifacts.initrdImagePath == "" ||
+		b.artifacts.pxeGrubCfgPath == "" {
+		return false, nil
+	}
+
+	exists, err := file.PathExists(b.artifacts.pxeGrubCfgPath)
+	if err != nil {
+		return false, fmt.Errorf("failed to check if (%s) exists:\n%w", b.artifacts.pxeGrubCfgPath, err)
+	}
+
+	return exists, nil
+}
+
+// stagePXEArtifactsDir
+//
+//   - populates the PXE artifacts folder directly from the builder's
+//     artifacts, instead of extracting the iso image (which requires loop
+//     devices).
+//   - the folder ends up with the same layout as extractPXEArtifactsFromIso
+//     produces: the PXE grub.cfg replaces the iso grub.cfg, and the
+//     bootloaders are placed at the root of the folder.
+//
+// inputs:
+//
+//   - 'isoMediaFiles':
+//     the files placed on the iso media (see createIsoImage).
+//   - 'outputPXEArtifactsDir':
+//     path to the output directory where the artifacts will be saved to.
+func (b *LiveOSIsoBuilder) stagePXEArtifactsDir(isoMediaFiles []safechroot.FileToCopy, outputPXEArtifactsDir string) error {
+	pxeGrubCfgMediaPath := filepath.Join("/", grubCfgDir, pxeGrubCfg)
+	isoEfiDirPrefix := filepath.Dir(isoBootloadersDir) + "/"
+
+	filesToCopy := []safechroot.FileToCopy(nil)
+	for _, isoMediaFile := range isoMediaFiles {
+		mediaPath := filepath.Join("/", isoMediaFile.Dest)
+		if mediaPath == pxeGrubCfgMediaPath || strings.HasPrefix(mediaPath, isoEfiDirPrefix) {
+			// The PXE grub.cfg is placed below under its final name, and the
+			// efi folder of the iso media is not used by PXE.
+			continue
+		}
+		filesToCopy = append(filesToCopy, isoMediaFile)
+	}
+
+	filesToCopy = append(filesToCopy,
+		safechroot.FileToCopy{Src: b.artifacts.vmlinuzPath, Dest: isoKernelPath},
+		safechroot.FileToCopy{Src: b.artifacts.initrdImagePath, Dest: isoInitrdPath},
+		safechroot.FileToCopy{Src: b.artifacts.pxeGrubCfgPath, Dest: filepath.Join(grubCfgDir, isoGrubCfg)},
+		safechroot.FileToCopy{Src: b.artifacts.bootx64EfiPath, Dest: bootx64Binary},
+	)
+
+	// grub is optional when the iso uses a custom boot backend.
+	if b.artifacts.grubx64EfiPath != "" {
+		filesToCopy = append(filesToCopy, safechroot.FileToCopy{Src: b.artifacts.grubx64EfiPath, Dest: grubx64Binary})
+	}
+
+	err := safechroot.AddFilesToDestination(outputPXEArtifactsDir, filesToCopy...)
+	if err != nil {
+		return fmt.Errorf("failed to stage the PXE artifacts folder (%s):\n%w", outputPXEArtifactsDir, err)
+	}
+
+	return nil
+}
+
+// extractPXEArtifactsFromIso
+//
+//   - populates the PXE artifacts folder by extracting a liveos iso image.
+//   - It also renames the liveos iso grub-pxe.cfg to grub.cfg, and moves the
+//     bootloaders to the root of the folder.
+//
+// inputs:
+//
+//   - 'isoImagePath':
+//     path to a liveos iso image.
+//   - 'buildDir'
+//     path to a directory to hold intermediate files.
+//   - 'outputPXEArtifactsDir'
+//     path to the output directory where the extract artifacts will be saved to.
+func extractPXEArtifactsFromIso(ctx context.Context, isoImagePath string, buildDir string, outputPXEArtifactsDir string,
+	buildResources BuildResources,
+) error {
+	// Extract all files from the iso image file.
+	err := extractIsoImageContents(ctx, buildDir, isoImagePath, outputPXEArtifactsDir, buildResources)
+	if err != nil {
+		return err
+	}
+
+	// Replace the iso grub.cfg with the PXE grub.cfg
+	isoGrubCfgPath := filepath.Join(outputPXEArtifactsDir, grubCfgDir, isoGrubCfg)
+	pxeGrubCfgPath := filepath.Join(outputPXEArtifactsDir, grubCfgDir, pxeGrubCfg)
+	err = file.Copy(pxeGrubCfgPath, isoGrubCfgPath)
+	if err != nil {
+		return fmt.Errorf("failed to copy (%s) to (%s) while populating the PXE artifacts directory:\n%w", pxeGrubCfgPath, isoGrubCfgPath, err)
+	}
+
+	err = os.RemoveAll(pxeGrubCfgPath)
+	if err != nil {
+		return fmt.Errorf("failed to remove file (%s):\n%w", pxeGrubCfgPath, err)
+	}
+
+	// Move bootloader files from under '<pxe-folder>/efi/boot' to '<pxe-folder>/'
+	bootloaderSrcDir := filepath.Join(outputPXEArtifactsDir, isoBootloadersDir)
+	bootloaderFiles := []string{bootx64Binary, grubx64Binary}
+	for _, bootloaderFile := range bootloaderFiles {
+		sourcePath := filepath.Join(bootloaderSrcDir, bootloaderFile)
+		targetPath := filepath.Join(outputPXEArtifactsDir, bootloaderFile)
+
+		// grub is optional when the iso uses a custom boot backend.
+		exists, err := file.PathExists(sourcePath)
+		if err != nil {
+			return fmt.Errorf("failed to check if (%s) exists:\n%w", sourcePath, err)
+		}
+		if !exists && bootloaderFile == grubx64Binary {
+			continue
+		}
+
+		err = file.Move(sourcePath, targetPath)
+		if err != nil {
+			return fmt.Errorf("failed to move boot loader file from (%s) to (%s) while generated the PXE artifacts folder:\n%w", sourcePath, targetPath, err)
+		}
+	}
+
+	// Remove the empty 'pxe-folder>/efi' folder.
+	isoEFIDir := filepath.Join(outputPXEArtifactsDir, "efi")
+	err = os.RemoveAll(isoEFIDir)
+	if err != nil {
+		return fmt.Errorf("failed to remove folder (%s):\n%w", isoEFIDir, err)
+	}
+
+	return nil
+}
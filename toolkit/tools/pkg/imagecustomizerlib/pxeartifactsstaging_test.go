@@ -0,0 +1,106 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/file"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/safechroot"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStagePXEArtifactsDir(t *testing.T) {
+	testDir := filepath.Join(tmpDir, "TestStagePXEArtifactsDir")
+	defer os.RemoveAll(testDir)
+
+	artifactsDir := filepath.Join(testDir, "artifacts")
+	err := os.MkdirAll(artifactsDir, os.ModePerm)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	sourceFiles := map[string]string{
+		"bootx64.efi":  "shim",
+		"grubx64.efi":  "grub",
+		"vmlinuz":      "kernel",
+		"initrd.img":   "initrd",
+		"grub.cfg":     "iso grub config",
+		"grub-pxe.cfg": "pxe grub config",
+		"rootfs.img":   "rootfs",
+		"extra.efi":    "extra efi file",
+	}
+	for name, content := range sourceFiles {
+		err = file.Write(content, filepath.Join(artifactsDir, name))
+		if !assert.NoError(t, err) {
+			return
+		}
+	}
+
+	b := &LiveOSIsoBuilder{
+		artifacts: IsoArtifacts{
+			bootx64EfiPath:  filepath.Join(artifactsDir, "bootx64.efi"),
+			grubx64EfiPath:  filepath.Join(artifactsDir, "grubx64.efi"),
+			vmlinuzPath:     filepath.Join(artifactsDir, "vmlinuz"),
+			initrdImagePath: filepath.Join(artifactsDir, "initrd.img"),
+			isoGrubCfgPath:  filepath.Join(artifactsDir, "grub.cfg"),
+			pxeGrubCfgPath:  filepath.Join(artifactsDir, "grub-pxe.cfg"),
+		},
+	}
+
+	canStage, err := b.canStagePXEArtifacts()
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.True(t, canStage)
+
+	isoMediaFiles := []safechroot.FileToCopy{
+		{Src: filepath.Join(artifactsDir, "rootfs.img"), Dest: "/liveos/rootfs.img"},
+		{Src: filepath.Join(artifactsDir, "grub-pxe.cfg"), Dest: "/boot/grub2/grub-pxe.cfg"},
+		{Src: filepath.Join(artifactsDir, "extra.efi"), Dest: "/efi/boot/extra.efi"},
+	}
+
+	pxeDir := filepath.Join(testDir, "pxe-artifacts")
+	err = b.stagePXEArtifactsDir(isoMediaFiles, pxeDir)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	expectedFiles := map[string]string{
+		"bootx64.efi":         "shim",
+		"grubx64.efi":         "grub",
+		"boot/vmlinuz":        "kernel",
+		"boot/initrd.img":     "initrd",
+		"boot/grub2/grub.cfg": "pxe grub config",
+		"liveos/rootfs.img":   "rootfs",
+	}
+	for path, expectedContent := range expectedFiles {
+		content, err := file.Read(filepath.Join(pxeDir, path))
+		if !assert.NoError(t, err, path) {
+			return
+		}
+		assert.Equal(t, expectedContent, content, path)
+	}
+
+	assert.NoFileExists(t, filepath.Join(pxeDir, "boot/grub2/grub-pxe.cfg"))
+	assert.NoDirExists(t, filepath.Join(pxeDir, "efi"))
+}
+
+func TestCanStagePXEArtifactsFromInputIso(t *testing.T) {
+	// The kernel of an input iso is only known to isomaker (inside the
+	// initrd image).
+	b := &LiveOSIsoBuilder{
+		artifacts: IsoArtifacts{
+			bootx64EfiPath:  "/iso/efi/boot/bootx64.efi",
+			initrdImagePath: "/iso/boot/initrd.img",
+			pxeGrubCfgPath:  "/iso/boot/grub2/grub-pxe.cfg",
+		},
+	}
+
+	canStage, err := b.canStagePXEArtifacts()
+	assert.NoError(t, err)
+	assert.False(t, canStage)
+}
@@ -0,0 +1,155 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/file"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/grub"
+)
+
+// pxeBootEntry holds what a network bootloader needs to boot the LiveOS.
+type pxeBootEntry struct {
+	// The title of the grub menu entry.
+	Title string
+	// The path of the kernel, relative to the root of the PXE artifacts folder.
+	KernelPath string
+	// The file name of the kernel.
+	KernelName string
+	// The path of the initrd image, relative to the root of the PXE artifacts folder.
+	InitrdPath string
+	// The file name of the initrd image.
+	InitrdName string
+	// The kernel command line (including the root=live:<url> argument).
+	KernelArgs string
+	// The URL the live image (i.e. the iso image, or the rootfs image) is downloaded from.
+	IsoImageUrl string
+}
+
+// getPxeBootEntries
+//
+//   - extracts the kernel, the initrd image and the kernel command line of
+//     each menu entry of the PXE grub.cfg.
+//   - the first entry is the default one.
+//
+// inputs:
+//
+//   - 'pxeGrubCfgContent':
+//     the contents of the PXE grub.cfg.
+//
+// outputs:
+//
+//   - returns the boot entries, in the order of the menu entries.
+func getPxeBootEntries(pxeGrubCfgContent string) ([]pxeBootEntry, error) {
+	menuEntries, err := findMenuEntries(pxeGrubCfgContent)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(menuEntries) == 0 {
+		return nil, fmt.Errorf("no 'menuentry' found in the PXE grub.cfg")
+	}
+
+	bootEntries := []pxeBootEntry(nil)
+	for _, menuEntry := range menuEntries {
+		bootEntry, err := getPxeBootEntry(pxeGrubCfgContent, menuEntry)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the PXE grub.cfg menu entry (%s):\n%w", menuEntry.Title, err)
+		}
+
+		bootEntries = append(bootEntries, bootEntry)
+	}
+
+	return bootEntries, nil
+}
+
+func getPxeBootEntry(pxeGrubCfgContent string, menuEntry grubMenuEntry) (pxeBootEntry, error) {
+	// The body of the menu entry, without the closing '}'.
+	menuEntryBody := pxeGrubCfgContent[menuEntry.BodyStart : menuEntry.End-1]
+
+	linuxLines, err := findLinuxOrInitrdLineAll(menuEntryBody, linuxCommand, false /*allowMultiple*/)
+	if err != nil {
+		return pxeBootEntry{}, err
+	}
+
+	initrdLines, err := findLinuxOrInitrdLineAll(menuEntryBody, initrdCommand, false /*allowMultiple*/)
+	if err != nil {
+		return pxeBootEntry{}, err
+	}
+
+	linuxValues, err := getPxeGrubTokenValues(linuxLines[0].Tokens[1:])
+	if err != nil {
+		return pxeBootEntry{}, fmt.Errorf("failed to read the '%s' command:\n%w", linuxCommand, err)
+	}
+
+	initrdValues, err := getPxeGrubTokenValues(initrdLines[0].Tokens[1:])
+	if err != nil {
+		return pxeBootEntry{}, fmt.Errorf("failed to read the '%s' command:\n%w", initrdCommand, err)
+	}
+
+	if len(initrdValues) != 1 {
+		return pxeBootEntry{}, fmt.Errorf("the '%s' command must specify exactly one initrd image", initrdCommand)
+	}
+
+	kernelArgs := linuxValues[1:]
+	isoImageUrl := ""
+	for _, kernelArg := range kernelArgs {
+		value, found := strings.CutPrefix(kernelArg, "root=")
+		if found {
+			isoImageUrl = strings.TrimPrefix(value, "live:")
+		}
+	}
+
+	entry := pxeBootEntry{
+		Title:       menuEntry.Title,
+		KernelPath:  strings.TrimPrefix(linuxValues[0], "/"),
+		KernelName:  path.Base(linuxValues[0]),
+		InitrdPath:  strings.TrimPrefix(initrdValues[0], "/"),
+		InitrdName:  path.Base(initrdValues[0]),
+		KernelArgs:  strings.Join(kernelArgs, " "),
+		IsoImageUrl: isoImageUrl,
+	}
+	return entry, nil
+}
+
+// getPxeGrubTokenValues returns the values of the grub tokens. Tokens with variable expansions (e.g. $a) are rejected
+// since other network bootloaders can't evaluate them. The exception is the per-host kernel arguments, which are
+// specific to grub, and are dropped.
+func getPxeGrubTokenValues(tokens []grub.Token) ([]string, error) {
+	values := []string(nil)
+	for _, token := range tokens {
+		if token.RawContent == "$"+pxeHostArgsVarName {
+			continue
+		}
+
+		for _, subWord := range token.SubWords {
+			if subWord.Type == grub.VAR_EXPANSION || subWord.Type == grub.QUOTED_VAR_EXPANSION {
+				return nil, fmt.Errorf("grub variables are not supported (%s)", token.RawContent)
+			}
+		}
+
+		values = append(values, grubTokenValue(token))
+	}
+	return values, nil
+}
+
+// readPxeBootEntries reads the boot entries from the PXE grub.cfg of a populated PXE artifacts folder.
+func readPxeBootEntries(outputPXEArtifactsDir string) ([]pxeBootEntry, error) {
+	pxeGrubCfgPath := filepath.Join(outputPXEArtifactsDir, grubCfgDir, isoGrubCfg)
+	pxeGrubCfgContent, err := file.Read(pxeGrubCfgPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read PXE grub.cfg (%s):\n%w", pxeGrubCfgPath, err)
+	}
+
+	bootEntries, err := getPxeBootEntries(pxeGrubCfgContent)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find the boot entries in the PXE grub.cfg (%s):\n%w", pxeGrubCfgPath, err)
+	}
+
+	return bootEntries, nil
+}
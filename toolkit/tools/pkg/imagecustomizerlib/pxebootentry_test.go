@@ -0,0 +1,65 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testPxeGrubCfg = `set timeout=0
+
+menuentry "Azure Linux" {
+    linux /boot/vmlinuz root=live:http://192.168.0.1/liveos/azl.iso rd.live.image "console=ttyS0,115200n8" ip=dhcp rd.live.azldownloader=enable
+    initrd /boot/initrd.img
+}
+
+menuentry "Azure Linux (debug)" {
+    linux /boot/vmlinuz root=live:http://192.168.0.1/liveos/azl.iso rd.debug
+    initrd /boot/initrd.img
+}
+`
+)
+
+func TestGetPxeBootEntries(t *testing.T) {
+	entries, err := getPxeBootEntries(testPxeGrubCfg)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, []pxeBootEntry{
+		{
+			Title:       "Azure Linux",
+			KernelPath:  "boot/vmlinuz",
+			KernelName:  "vmlinuz",
+			InitrdPath:  "boot/initrd.img",
+			InitrdName:  "initrd.img",
+			KernelArgs:  "root=live:http://192.168.0.1/liveos/azl.iso rd.live.image console=ttyS0,115200n8 ip=dhcp rd.live.azldownloader=enable",
+			IsoImageUrl: "http://192.168.0.1/liveos/azl.iso",
+		},
+		{
+			Title:       "Azure Linux (debug)",
+			KernelPath:  "boot/vmlinuz",
+			KernelName:  "vmlinuz",
+			InitrdPath:  "boot/initrd.img",
+			InitrdName:  "initrd.img",
+			KernelArgs:  "root=live:http://192.168.0.1/liveos/azl.iso rd.debug",
+			IsoImageUrl: "http://192.168.0.1/liveos/azl.iso",
+		},
+	}, entries)
+}
+
+func TestGetPxeBootEntriesVarExpansion(t *testing.T) {
+	grubCfg := "menuentry \"Azure Linux\" {\n    linux /boot/vmlinuz $kernelopts\n    initrd /boot/initrd.img\n}\n"
+	_, err := getPxeBootEntries(grubCfg)
+	assert.ErrorContains(t, err, "failed to read the PXE grub.cfg menu entry (Azure Linux)")
+	assert.ErrorContains(t, err, "grub variables are not supported ($kernelopts)")
+}
+
+func TestGetPxeBootEntriesNoMenuEntry(t *testing.T) {
+	_, err := getPxeBootEntries("set timeout=0\n")
+	assert.ErrorContains(t, err, "no 'menuentry' found in the PXE grub.cfg")
+}
@@ -0,0 +1,77 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"fmt"
+	"net/url"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/grub"
+)
+
+// setPxeRootfsImageUrlAll
+//
+//   - sets the 'root' kernel argument of each linux command to the URL of the
+//     rootfs (squashfs) image that the command boots. So, dracut's livenet
+//     module downloads the rootfs image directly, instead of the iso image
+//     around it.
+//   - the rootfs image of a linux command is selected by its last
+//     'rd.live.squashimg' argument (which is what dracut uses).
+//
+// inputs:
+//
+//   - 'inputGrubCfgContent':
+//     the PXE grub.cfg content.
+//   - 'pxeArtifactsBaseUrl':
+//     the URL of the PXE artifacts folder on the server.
+//   - 'liveOSDir':
+//     the folder (relative to the PXE artifacts folder) that holds the rootfs
+//     images.
+//
+// outputs:
+//
+//   - returns the updated grub.cfg content.
+func setPxeRootfsImageUrlAll(inputGrubCfgContent string, pxeArtifactsBaseUrl string, liveOSDir string) (string, error) {
+	lines, err := findLinuxOrInitrdLineAll(inputGrubCfgContent, linuxCommand, true /*allowMultiple*/)
+	if err != nil {
+		return "", err
+	}
+
+	outputGrubCfgContent := inputGrubCfgContent
+	// loop from last to first so that the captured locations are not
+	// invalidated as reconstructing outputGrubCfgContent.
+	for i := len(lines) - 1; i >= 0; i-- {
+		// Skip the "linux" command and the kernel binary path arg.
+		args, err := ParseCommandLineArgs(lines[i].Tokens[2:])
+		if err != nil {
+			return "", err
+		}
+
+		squashImg, err := findKernelCommandLineArgValue(args, "rd.live.squashimg")
+		if err != nil {
+			return "", err
+		}
+		if squashImg == "" {
+			return "", fmt.Errorf("failed to find kernel arg (rd.live.squashimg)")
+		}
+
+		rootfsImageUrl, err := url.JoinPath(pxeArtifactsBaseUrl, liveOSDir, squashImg)
+		if err != nil {
+			return "", fmt.Errorf("failed to concatenate URL (%s) and (%s/%s)\n%w", pxeArtifactsBaseUrl, liveOSDir,
+				squashImg, err)
+		}
+
+		rootArgs := findMatchingCommandLineArgs(args, []string{"root"})
+		if len(rootArgs) != 1 {
+			return "", fmt.Errorf("expected exactly one kernel arg (root), found (%d)", len(rootArgs))
+		}
+
+		newArg := grub.QuoteString("root=" + fmt.Sprintf(rootValuePxeTemplate, rootfsImageUrl))
+		start := rootArgs[0].Token.Loc.Start.Index
+		end := rootArgs[0].Token.Loc.End.Index
+		outputGrubCfgContent = outputGrubCfgContent[:start] + newArg + outputGrubCfgContent[end:]
+	}
+
+	return outputGrubCfgContent, nil
+}
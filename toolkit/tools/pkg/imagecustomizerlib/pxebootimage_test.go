@@ -0,0 +1,104 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/imagecustomizerapi"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/file"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testIsoGrubCfg = `set timeout=0
+
+menuentry "Azure Linux" {
+    search --label CDROM --set root
+    linux /boot/vmlinuz root=live:LABEL=CDROM rd.live.image rd.live.dir=liveos rd.live.squashimg=rootfs.img
+    initrd /boot/initrd.img
+}
+
+menuentry "Azure Linux (recovery)" --id rootfs-image-recovery.img {
+    search --label CDROM --set root
+    linux /boot/vmlinuz root=live:LABEL=CDROM rd.live.image rd.live.dir=liveos rd.live.squashimg=rootfs.img rd.live.squashimg=recovery.img
+    initrd /boot/initrd.img
+}
+`
+)
+
+func TestGeneratePxeGrubCfgRootfsBootImage(t *testing.T) {
+	testDir := filepath.Join(tmpDir, "TestGeneratePxeGrubCfgRootfsBootImage")
+	defer os.RemoveAll(testDir)
+
+	err := os.MkdirAll(testDir, os.ModePerm)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	pxeGrubCfgPath := filepath.Join(testDir, pxeGrubCfg)
+	err = generatePxeGrubCfg(testIsoGrubCfg, "nfs://192.168.0.1/export/pxe", "", imagecustomizerapi.PxeBootImageRootfs,
+		"liveos", "azl", isoReleaseInfo{}, pxeGrubCfgPath)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	pxeGrubCfgContent, err := file.Read(pxeGrubCfgPath)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	bootEntries, err := getPxeBootEntries(pxeGrubCfgContent)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	if !assert.Len(t, bootEntries, 2) {
+		return
+	}
+	assert.Equal(t, "nfs://192.168.0.1/export/pxe/liveos/rootfs.img", bootEntries[0].IsoImageUrl)
+	assert.Equal(t, "nfs://192.168.0.1/export/pxe/liveos/recovery.img", bootEntries[1].IsoImageUrl)
+	assert.NotContains(t, pxeGrubCfgContent, "search")
+	assert.Contains(t, bootEntries[0].KernelArgs, pxeKernelsArgs)
+}
+
+func TestGeneratePxeGrubCfgIsoBootImage(t *testing.T) {
+	testDir := filepath.Join(tmpDir, "TestGeneratePxeGrubCfgIsoBootImage")
+	defer os.RemoveAll(testDir)
+
+	err := os.MkdirAll(testDir, os.ModePerm)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	pxeGrubCfgPath := filepath.Join(testDir, pxeGrubCfg)
+	err = generatePxeGrubCfg(testIsoGrubCfg, "http://192.168.0.1/pxe", "", imagecustomizerapi.PxeBootImageDefault,
+		"liveos", "azl", isoReleaseInfo{}, pxeGrubCfgPath)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	pxeGrubCfgContent, err := file.Read(pxeGrubCfgPath)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	bootEntries, err := getPxeBootEntries(pxeGrubCfgContent)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	for _, bootEntry := range bootEntries {
+		assert.Equal(t, "http://192.168.0.1/pxe/azl.iso", bootEntry.IsoImageUrl)
+	}
+}
+
+func TestGeneratePxeGrubCfgRootfsBootImageWithIsoImageFileUrl(t *testing.T) {
+	err := generatePxeGrubCfg(testIsoGrubCfg, "", "http://192.168.0.1/pxe/azl.iso",
+		imagecustomizerapi.PxeBootImageRootfs, "liveos", "azl", isoReleaseInfo{},
+		filepath.Join(tmpDir, "TestGeneratePxeGrubCfgRootfsBootImageWithIsoImageFileUrl", pxeGrubCfg))
+	assert.ErrorContains(t, err, "cannot set the full iso image url when the PXE boot image is (rootfs)")
+}
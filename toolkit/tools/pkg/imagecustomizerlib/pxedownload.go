@@ -0,0 +1,211 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/imagecustomizerapi"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/file"
+)
+
+const (
+	// The folder (in the rootfs) where the PXE download settings are staged
+	// for dracut. It is created after the squashfs image. So, the settings
+	// (which may hold secrets) are placed in the initrd image only.
+	pxeDownloadStagingDir = "/pxe-download-staging"
+	// The folder (in the initrd image) that holds the PXE download settings.
+	// It is the CURL_HOME of the services that download the boot image. So,
+	// curl reads its '.curlrc' file.
+	pxeDownloadConfigDir = "/etc/azl-image-customizer/pxe-download"
+
+	// The CA bundle of the OS, which curl trusts by default.
+	osCaBundlePath = "/etc/pki/tls/certs/ca-bundle.crt"
+
+	pxeDownloadCaBundleFileName          = "ca-bundle.crt"
+	pxeDownloadClientCertificateFileName = "client.crt"
+	pxeDownloadClientKeyFileName         = "client.key"
+	pxeDownloadCredentialsFileName       = "netrc"
+	pxeDownloadCurlrcFileName            = ".curlrc"
+	pxeDownloadDropInFileName            = "50-pxe-download.conf"
+)
+
+// The initrd services that run dracut's livenetroot, which downloads the boot
+// image with curl. The Azure Linux download service (see
+// 'rd.live.azldownloader') runs it on older dracut versions, and the dracut
+// initqueue runs it on newer ones.
+var pxeDownloadServices = []string{"azl-liveos-artifacts-download.service", "dracut-initqueue.service"}
+
+// pxeDownload is the build representation of imagecustomizerapi.PxeDownload.
+type pxeDownload struct {
+	// The absolute paths of the files on the build machine.
+	caCertificatePaths    []string
+	clientCertificatePath string
+	clientKeyPath         string
+	credentialsFilePath   string
+}
+
+func micPxeConfigToDownload(baseConfigPath string, pxeConfig *imagecustomizerapi.Pxe) *pxeDownload {
+	if pxeConfig.Download == nil {
+		return nil
+	}
+
+	download := &pxeDownload{}
+	for _, caCertificate := range pxeConfig.Download.CaCertificates {
+		download.caCertificatePaths = append(download.caCertificatePaths,
+			file.GetAbsPathWithBase(baseConfigPath, caCertificate))
+	}
+	if pxeConfig.Download.ClientCertificate != "" {
+		download.clientCertificatePath = file.GetAbsPathWithBase(baseConfigPath, pxeConfig.Download.ClientCertificate)
+		download.clientKeyPath = file.GetAbsPathWithBase(baseConfigPath, pxeConfig.Download.ClientKey)
+	}
+	if pxeConfig.Download.CredentialsFile != "" {
+		download.credentialsFilePath = file.GetAbsPathWithBase(baseConfigPath, pxeConfig.Download.CredentialsFile)
+	}
+	return download
+}
+
+// stagePxeDownloadConfig
+//
+//   - stages the PXE download settings (i.e. the curl configuration and the
+//     files it references) under pxeDownloadStagingDir, for dracut to
+//     include in the initrd image.
+//   - the CA certificates are appended to the CA bundle of the OS, since
+//     curl trusts only one CA bundle.
+//   - systemd drop-ins point the CURL_HOME of the download services to the
+//     curl configuration.
+//
+// inputs:
+//
+//   - 'writeableRootfsDir':
+//     the rootfs folder that dracut runs in.
+//   - 'download':
+//     the PXE download settings.
+func stagePxeDownloadConfig(writeableRootfsDir string, download *pxeDownload) error {
+	stagingDir := filepath.Join(writeableRootfsDir, pxeDownloadStagingDir)
+	configDir := filepath.Join(stagingDir, pxeDownloadConfigDir)
+
+	err := os.MkdirAll(configDir, 0o700)
+	if err != nil {
+		return fmt.Errorf("failed to create folder (%s):\n%w", configDir, err)
+	}
+
+	curlOptions := []string(nil)
+
+	if len(download.caCertificatePaths) > 0 {
+		caBundle := strings.Builder{}
+
+		osCaBundleFullPath := filepath.Join(writeableRootfsDir, osCaBundlePath)
+		exists, err := file.PathExists(osCaBundleFullPath)
+		if err != nil {
+			return fmt.Errorf("failed to check if (%s) exists:\n%w", osCaBundleFullPath, err)
+		}
+		if exists {
+			err = appendPxeDownloadCaCertificate(&caBundle, osCaBundleFullPath)
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, caCertificatePath := range download.caCertificatePaths {
+			err = appendPxeDownloadCaCertificate(&caBundle, caCertificatePath)
+			if err != nil {
+				return err
+			}
+		}
+
+		caBundlePath := filepath.Join(configDir, pxeDownloadCaBundleFileName)
+		err = file.WriteWithPerm(caBundle.String(), caBundlePath, 0o644)
+		if err != nil {
+			return fmt.Errorf("failed to write CA bundle (%s):\n%w", caBundlePath, err)
+		}
+
+		curlOptions = append(curlOptions, curlrcOption("cacert", pxeDownloadCaBundleFileName))
+	}
+
+	if download.clientCertificatePath != "" {
+		err = copyPxeDownloadFile(download.clientCertificatePath, configDir, pxeDownloadClientCertificateFileName, 0o644)
+		if err != nil {
+			return err
+		}
+
+		err = copyPxeDownloadFile(download.clientKeyPath, configDir, pxeDownloadClientKeyFileName, 0o600)
+		if err != nil {
+			return err
+		}
+
+		curlOptions = append(curlOptions,
+			curlrcOption("cert", pxeDownloadClientCertificateFileName),
+			curlrcOption("key", pxeDownloadClientKeyFileName))
+	}
+
+	if download.credentialsFilePath != "" {
+		err = copyPxeDownloadFile(download.credentialsFilePath, configDir, pxeDownloadCredentialsFileName, 0o600)
+		if err != nil {
+			return err
+		}
+
+		curlOptions = append(curlOptions, curlrcOption("netrc-file", pxeDownloadCredentialsFileName))
+	}
+
+	curlrcPath := filepath.Join(configDir, pxeDownloadCurlrcFileName)
+	err = file.WriteWithPerm(strings.Join(curlOptions, "\n")+"\n", curlrcPath, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to write curl configuration (%s):\n%w", curlrcPath, err)
+	}
+
+	dropInContent := fmt.Sprintf("[Service]\nEnvironment=CURL_HOME=%s\n", pxeDownloadConfigDir)
+	for _, service := range pxeDownloadServices {
+		dropInDir := filepath.Join(stagingDir, "/etc/systemd/system", service+".d")
+		err = os.MkdirAll(dropInDir, os.ModePerm)
+		if err != nil {
+			return fmt.Errorf("failed to create folder (%s):\n%w", dropInDir, err)
+		}
+
+		dropInPath := filepath.Join(dropInDir, pxeDownloadDropInFileName)
+		err = file.WriteWithPerm(dropInContent, dropInPath, 0o644)
+		if err != nil {
+			return fmt.Errorf("failed to write systemd drop-in (%s):\n%w", dropInPath, err)
+		}
+	}
+
+	return nil
+}
+
+func appendPxeDownloadCaCertificate(caBundle *strings.Builder, caCertificatePath string) error {
+	caCertificate, err := file.Read(caCertificatePath)
+	if err != nil {
+		return fmt.Errorf("failed to read CA certificate (%s):\n%w", caCertificatePath, err)
+	}
+
+	if !strings.Contains(caCertificate, "-----BEGIN CERTIFICATE-----") {
+		return fmt.Errorf("CA certificate (%s) is not a PEM certificate", caCertificatePath)
+	}
+
+	caBundle.WriteString(caCertificate)
+	if !strings.HasSuffix(caCertificate, "\n") {
+		caBundle.WriteString("\n")
+	}
+	return nil
+}
+
+func copyPxeDownloadFile(sourcePath string, configDir string, fileName string, fileMode os.FileMode) error {
+	targetPath := filepath.Join(configDir, fileName)
+	err := file.NewFileCopyBuilder(sourcePath, targetPath).
+		SetFileMode(fileMode).
+		Run()
+	if err != nil {
+		return fmt.Errorf("failed to copy (%s) to (%s):\n%w", sourcePath, targetPath, err)
+	}
+	return nil
+}
+
+// curlrcOption returns a '.curlrc' line that sets an option to a file in the
+// PXE download settings folder.
+func curlrcOption(name string, fileName string) string {
+	return fmt.Sprintf("%s = \"%s\"", name, filepath.Join(pxeDownloadConfigDir, fileName))
+}
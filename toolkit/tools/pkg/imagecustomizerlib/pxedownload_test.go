@@ -0,0 +1,108 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/imagecustomizerapi"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/file"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testPxeDownloadOsCa         = "-----BEGIN CERTIFICATE-----\nos\n-----END CERTIFICATE-----\n"
+	testPxeDownloadEnterpriseCa = "-----BEGIN CERTIFICATE-----\nenterprise\n-----END CERTIFICATE-----"
+)
+
+func TestStagePxeDownloadConfig(t *testing.T) {
+	testDir := filepath.Join(tmpDir, "TestStagePxeDownloadConfig")
+	defer os.RemoveAll(testDir)
+
+	rootfsDir := filepath.Join(testDir, "rootfs")
+	filesDir := filepath.Join(testDir, "files")
+	for _, dir := range []string{filepath.Join(rootfsDir, filepath.Dir(osCaBundlePath)), filesDir} {
+		err := os.MkdirAll(dir, os.ModePerm)
+		if !assert.NoError(t, err) {
+			return
+		}
+	}
+
+	testFiles := map[string]string{
+		filepath.Join(rootfsDir, osCaBundlePath):  testPxeDownloadOsCa,
+		filepath.Join(filesDir, "enterprise.pem"): testPxeDownloadEnterpriseCa,
+		filepath.Join(filesDir, "client.pem"):     "client certificate",
+		filepath.Join(filesDir, "client.key"):     "client key",
+		filepath.Join(filesDir, "netrc"):          "machine pxe.example.com login user password secret",
+	}
+	for path, content := range testFiles {
+		err := file.Write(content, path)
+		if !assert.NoError(t, err) {
+			return
+		}
+	}
+
+	pxeConfig := &imagecustomizerapi.Pxe{
+		Download: &imagecustomizerapi.PxeDownload{
+			CaCertificates:    []string{"enterprise.pem"},
+			ClientCertificate: "client.pem",
+			ClientKey:         "client.key",
+			CredentialsFile:   "netrc",
+		},
+	}
+
+	err := stagePxeDownloadConfig(rootfsDir, micPxeConfigToDownload(filesDir, pxeConfig))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	configDir := filepath.Join(rootfsDir, pxeDownloadStagingDir, pxeDownloadConfigDir)
+
+	caBundle, err := file.Read(filepath.Join(configDir, "ca-bundle.crt"))
+	if assert.NoError(t, err) {
+		assert.Equal(t, testPxeDownloadOsCa+testPxeDownloadEnterpriseCa+"\n", caBundle)
+	}
+
+	curlrc, err := file.Read(filepath.Join(configDir, ".curlrc"))
+	if assert.NoError(t, err) {
+		assert.Equal(t, "cacert = \"/etc/azl-image-customizer/pxe-download/ca-bundle.crt\"\n"+
+			"cert = \"/etc/azl-image-customizer/pxe-download/client.crt\"\n"+
+			"key = \"/etc/azl-image-customizer/pxe-download/client.key\"\n"+
+			"netrc-file = \"/etc/azl-image-customizer/pxe-download/netrc\"\n", curlrc)
+	}
+
+	keyInfo, err := os.Stat(filepath.Join(configDir, "client.key"))
+	if assert.NoError(t, err) {
+		assert.Equal(t, os.FileMode(0o600), keyInfo.Mode().Perm())
+	}
+
+	for _, service := range pxeDownloadServices {
+		dropIn, err := file.Read(filepath.Join(rootfsDir, pxeDownloadStagingDir, "etc/systemd/system", service+".d",
+			"50-pxe-download.conf"))
+		if assert.NoError(t, err, service) {
+			assert.Equal(t, "[Service]\nEnvironment=CURL_HOME=/etc/azl-image-customizer/pxe-download\n", dropIn)
+		}
+	}
+}
+
+func TestStagePxeDownloadConfigBadCaCertificate(t *testing.T) {
+	testDir := filepath.Join(tmpDir, "TestStagePxeDownloadConfigBadCaCertificate")
+	defer os.RemoveAll(testDir)
+
+	err := os.MkdirAll(testDir, os.ModePerm)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	caPath := filepath.Join(testDir, "enterprise.der")
+	err = file.Write("not a pem file", caPath)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	err = stagePxeDownloadConfig(filepath.Join(testDir, "rootfs"), &pxeDownload{caCertificatePaths: []string{caPath}})
+	assert.ErrorContains(t, err, "is not a PEM certificate")
+}
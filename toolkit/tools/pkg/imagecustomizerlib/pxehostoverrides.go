@@ -0,0 +1,104 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/imagecustomizerapi"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/file"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/grub"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/logger"
+)
+
+const (
+	// The folder (next to the PXE grub.cfg) that holds the per-host grub config fragments.
+	pxeHostsDir = "pxe-hosts"
+	// The grub variable that the per-host grub config fragments set.
+	pxeHostArgsVarName = "pxe_host_args"
+
+	// Loads the grub config fragment of the booting host (if any). 'net_default_mac' is set by grub when it boots from
+	// the network.
+	pxeHostOverridesGrubCfgHeader = `# Per-host kernel arguments.
+set ` + pxeHostArgsVarName + `=""
+if [ -n "$net_default_mac" ]; then
+    if [ -f "$prefix/` + pxeHostsDir + `/$net_default_mac.cfg" ]; then
+        source "$prefix/` + pxeHostsDir + `/$net_default_mac.cfg"
+    fi
+fi
+
+`
+)
+
+// applyPxeHostOverrides
+//
+//   - writes a grub config fragment for each host, named after the host's MAC
+//     address, that sets the host's extra kernel arguments.
+//   - updates the PXE grub.cfg of a populated PXE artifacts folder to load
+//     the fragment of the booting host, and to pass the host's extra kernel
+//     arguments to the kernel.
+//
+// inputs:
+//
+//   - 'outputPXEArtifactsDir':
+//     path to the populated PXE artifacts folder.
+//   - 'hostOverrides':
+//     the per-host (or per-class) kernel arguments.
+func applyPxeHostOverrides(outputPXEArtifactsDir string, hostOverrides []imagecustomizerapi.PxeHostOverride) error {
+	hostsDir := filepath.Join(outputPXEArtifactsDir, grubCfgDir, pxeHostsDir)
+	err := os.MkdirAll(hostsDir, os.ModePerm)
+	if err != nil {
+		return fmt.Errorf("failed to create folder (%s):\n%w", hostsDir, err)
+	}
+
+	for _, hostOverride := range hostOverrides {
+		for _, macAddress := range hostOverride.MacAddresses {
+			normalizedMacAddress, err := imagecustomizerapi.ParsePxeHostMacAddress(macAddress)
+			if err != nil {
+				return err
+			}
+
+			fragmentPath := filepath.Join(hostsDir, normalizedMacAddress+".cfg")
+			err = file.Write(generatePxeHostGrubCfgFragment(hostOverride), fragmentPath)
+			if err != nil {
+				return fmt.Errorf("failed to write PXE host grub config (%s):\n%w", fragmentPath, err)
+			}
+		}
+	}
+
+	logger.Log.Infof("Wrote PXE host grub configs to (%s)", hostsDir)
+
+	pxeGrubCfgPath := filepath.Join(outputPXEArtifactsDir, grubCfgDir, isoGrubCfg)
+	pxeGrubCfgContent, err := file.Read(pxeGrubCfgPath)
+	if err != nil {
+		return fmt.Errorf("failed to read PXE grub.cfg (%s):\n%w", pxeGrubCfgPath, err)
+	}
+
+	pxeGrubCfgContent, err = appendKernelCommandLineArgsAll(pxeGrubCfgContent, "$"+pxeHostArgsVarName,
+		true /*allowMultiple*/, false /*requireKernelOpts*/)
+	if err != nil {
+		return fmt.Errorf("failed to append the per-host kernel arguments in the PXE grub.cfg:\n%w", err)
+	}
+
+	err = file.Write(pxeHostOverridesGrubCfgHeader+pxeGrubCfgContent, pxeGrubCfgPath)
+	if err != nil {
+		return fmt.Errorf("failed to write PXE grub.cfg (%s):\n%w", pxeGrubCfgPath, err)
+	}
+
+	return nil
+}
+
+func generatePxeHostGrubCfgFragment(hostOverride imagecustomizerapi.PxeHostOverride) string {
+	builder := strings.Builder{}
+	if hostOverride.Name != "" {
+		fmt.Fprintf(&builder, "# %s\n", strings.ReplaceAll(hostOverride.Name, "\n", " "))
+	}
+
+	hostArgs := strings.TrimSpace(string(hostOverride.KernelCommandLine.ExtraCommandLine))
+	fmt.Fprintf(&builder, "set %s=%s\n", pxeHostArgsVarName, grub.ForceQuoteString(hostArgs))
+	return builder.String()
+}
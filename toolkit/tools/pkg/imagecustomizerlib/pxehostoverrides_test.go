@@ -0,0 +1,88 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/imagecustomizerapi"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/file"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApplyPxeHostOverrides(t *testing.T) {
+	testDir := filepath.Join(tmpDir, "TestApplyPxeHostOverrides")
+	defer os.RemoveAll(testDir)
+
+	err := os.MkdirAll(filepath.Join(testDir, grubCfgDir), os.ModePerm)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	pxeGrubCfgPath := filepath.Join(testDir, grubCfgDir, isoGrubCfg)
+	err = file.Write(testPxeGrubCfg, pxeGrubCfgPath)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	hostOverrides := []imagecustomizerapi.PxeHostOverride{
+		{
+			Name:         "serial-console-hosts",
+			MacAddresses: []string{"52:54:00:12:34:56", "52-54-00-AB-CD-EF"},
+			KernelCommandLine: imagecustomizerapi.KernelCommandLine{
+				ExtraCommandLine: "console=ttyS1,115200n8 rd.info",
+			},
+		},
+		{
+			MacAddresses: []string{"02:00:00:00:00:01"},
+			KernelCommandLine: imagecustomizerapi.KernelCommandLine{
+				ExtraCommandLine: "foo=\"a b\"",
+			},
+		},
+	}
+
+	err = applyPxeHostOverrides(testDir, hostOverrides)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	expectedFragments := map[string]string{
+		"52:54:00:12:34:56.cfg": "# serial-console-hosts\nset pxe_host_args=\"console=ttyS1,115200n8 rd.info\"\n",
+		"52:54:00:ab:cd:ef.cfg": "# serial-console-hosts\nset pxe_host_args=\"console=ttyS1,115200n8 rd.info\"\n",
+		"02:00:00:00:00:01.cfg": "set pxe_host_args=\"foo=\\\"a b\\\"\"\n",
+	}
+	for name, expectedContent := range expectedFragments {
+		content, err := file.Read(filepath.Join(testDir, grubCfgDir, pxeHostsDir, name))
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, expectedContent, content, name)
+	}
+
+	pxeGrubCfgContent, err := file.Read(pxeGrubCfgPath)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.True(t, strings.HasPrefix(pxeGrubCfgContent, pxeHostOverridesGrubCfgHeader))
+
+	linuxLines, err := findLinuxOrInitrdLineAll(pxeGrubCfgContent, linuxCommand, true /*allowMultiple*/)
+	if !assert.NoError(t, err) {
+		return
+	}
+	for _, linuxLine := range linuxLines {
+		lastToken := linuxLine.Tokens[len(linuxLine.Tokens)-1]
+		assert.Equal(t, "$pxe_host_args", lastToken.RawContent)
+	}
+
+	// The per-host kernel arguments are dropped from the other network bootloaders' configs.
+	bootEntries, err := getPxeBootEntries(pxeGrubCfgContent)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "root=live:http://192.168.0.1/liveos/azl.iso rd.debug", bootEntries[1].KernelArgs)
+}
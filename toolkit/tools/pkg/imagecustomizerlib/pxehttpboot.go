@@ -0,0 +1,130 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/file"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/logger"
+)
+
+const (
+	// The vendor class that UEFI HTTP boot clients send, and that the DHCP
+	// server must echo for the boot file to be accepted as a URL.
+	uefiHttpBootVendorClass = "HTTPClient"
+
+	// Loads the PXE grub.cfg when grub's prefix isn't the PXE grub.cfg folder.
+	uefiHttpBootRedirectGrubCfg = `# Generated for UEFI HTTP boot.
+set prefix=($root)` + grubCfgDir + `
+configfile $prefix/` + isoGrubCfg + `
+`
+)
+
+// The grub modules that a grub image must have to boot over UEFI HTTP boot.
+var uefiHttpBootGrubModules = []string{"efinet", "http"}
+
+// prepareUefiHttpBoot
+//
+//   - validates that the grub image of a populated PXE artifacts folder can
+//     boot over UEFI HTTP boot (i.e. it has the 'efinet' and 'http' modules).
+//   - if grub's prefix isn't the folder of the PXE grub.cfg, writes a grub.cfg
+//     under the prefix that loads the PXE grub.cfg.
+//   - logs the DHCP settings that UEFI HTTP boot clients need.
+//
+// inputs:
+//
+//   - 'outputPXEArtifactsDir':
+//     path to the populated PXE artifacts folder.
+//   - 'pxeArtifactsBaseUrl':
+//     the URL of the PXE artifacts folder on the HTTP server.
+func prepareUefiHttpBoot(outputPXEArtifactsDir string, pxeArtifactsBaseUrl string) error {
+	grubEfiImagePath := filepath.Join(outputPXEArtifactsDir, grubx64Binary)
+	exists, err := file.PathExists(grubEfiImagePath)
+	if err != nil {
+		return fmt.Errorf("failed to check if (%s) exists:\n%w", grubEfiImagePath, err)
+	}
+	if !exists {
+		return fmt.Errorf("UEFI HTTP boot requires (%s) in the PXE artifacts folder", grubx64Binary)
+	}
+
+	grubInfo, err := readGrubEfiImageInfo(grubEfiImagePath)
+	if err != nil {
+		return err
+	}
+
+	var missingModules []string
+	for _, module := range uefiHttpBootGrubModules {
+		if !grubInfo.hasModule(module) {
+			missingModules = append(missingModules, module)
+		}
+	}
+	if len(missingModules) > 0 {
+		return fmt.Errorf("UEFI HTTP boot requires the grub image (%s) to have the (%s) modules, but (%s) are missing:\n"+
+			"provide a grub image built with them (see 'iso.bootloaderFiles')", grubx64Binary,
+			strings.Join(uefiHttpBootGrubModules, ", "), strings.Join(missingModules, ", "))
+	}
+
+	err = writeUefiHttpBootRedirectGrubCfg(outputPXEArtifactsDir, grubInfo.prefix)
+	if err != nil {
+		return err
+	}
+
+	bootFileUrl, err := url.JoinPath(pxeArtifactsBaseUrl, bootx64Binary)
+	if err != nil {
+		return fmt.Errorf("failed to concatenate URL (%s) and (%s):\n%w", pxeArtifactsBaseUrl, bootx64Binary, err)
+	}
+
+	logger.Log.Infof("UEFI HTTP boot: configure the DHCP server to answer clients with vendor class (%s) with:",
+		uefiHttpBootVendorClass)
+	logger.Log.Infof("  DHCPv4: option 60 (vendor class) = (%s), option 67 (boot file) = (%s)",
+		uefiHttpBootVendorClass, bootFileUrl)
+	logger.Log.Infof("  DHCPv6: option 16 (vendor class) = (%s), option 59 (boot file URL) = (%s)",
+		uefiHttpBootVendorClass, bootFileUrl)
+
+	return nil
+}
+
+// writeUefiHttpBootRedirectGrubCfg writes a grub.cfg under grub's prefix that
+// loads the PXE grub.cfg. When booting over HTTP, grub resolves its prefix
+// against the folder of the URL it was loaded from (i.e. the root of the PXE
+// artifacts folder).
+func writeUefiHttpBootRedirectGrubCfg(outputPXEArtifactsDir string, grubPrefix string) error {
+	if strings.HasPrefix(grubPrefix, "(") {
+		return fmt.Errorf("UEFI HTTP boot doesn't support a grub image with a device in its prefix (%s)", grubPrefix)
+	}
+
+	prefixDir := path.Join("/", grubPrefix)
+	if prefixDir == grubCfgDir {
+		return nil
+	}
+
+	redirectGrubCfgPath := filepath.Join(outputPXEArtifactsDir, prefixDir, isoGrubCfg)
+	exists, err := file.PathExists(redirectGrubCfgPath)
+	if err != nil {
+		return fmt.Errorf("failed to check if (%s) exists:\n%w", redirectGrubCfgPath, err)
+	}
+	if exists {
+		return fmt.Errorf("UEFI HTTP boot requires writing (%s), but it already exists", redirectGrubCfgPath)
+	}
+
+	err = os.MkdirAll(filepath.Dir(redirectGrubCfgPath), os.ModePerm)
+	if err != nil {
+		return fmt.Errorf("failed to create folder (%s):\n%w", filepath.Dir(redirectGrubCfgPath), err)
+	}
+
+	err = file.Write(uefiHttpBootRedirectGrubCfg, redirectGrubCfgPath)
+	if err != nil {
+		return fmt.Errorf("failed to write grub.cfg (%s):\n%w", redirectGrubCfgPath, err)
+	}
+
+	logger.Log.Infof("Wrote grub.cfg for grub prefix (%s) to (%s)", grubPrefix, redirectGrubCfgPath)
+
+	return nil
+}
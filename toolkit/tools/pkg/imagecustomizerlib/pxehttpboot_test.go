@@ -0,0 +1,207 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/file"
+	"github.com/stretchr/testify/assert"
+)
+
+// buildTestGrubModule builds a minimal ELF relocatable object with a
+// '.modname' section, like the grub modules.
+func buildTestGrubModule(t *testing.T, moduleName string) []byte {
+	shstrtab := []byte("\x00.shstrtab\x00" + grubModNameSectionName + "\x00")
+	modName := []byte(moduleName + "\x00")
+
+	headerSize := binary.Size(elf.Header64{})
+	sectionHeaderSize := binary.Size(elf.Section64{})
+	shstrtabOffset := headerSize
+	modNameOffset := shstrtabOffset + len(shstrtab)
+	sectionHeadersOffset := modNameOffset + len(modName)
+
+	header := elf.Header64{
+		Type:      uint16(elf.ET_REL),
+		Machine:   uint16(elf.EM_X86_64),
+		Version:   uint32(elf.EV_CURRENT),
+		Shoff:     uint64(sectionHeadersOffset),
+		Ehsize:    uint16(headerSize),
+		Shentsize: uint16(sectionHeaderSize),
+		Shnum:     3,
+		Shstrndx:  1,
+	}
+	copy(header.Ident[:], elf.ELFMAG)
+	header.Ident[elf.EI_CLASS] = byte(elf.ELFCLASS64)
+	header.Ident[elf.EI_DATA] = byte(elf.ELFDATA2LSB)
+	header.Ident[elf.EI_VERSION] = byte(elf.EV_CURRENT)
+
+	sections := []elf.Section64{
+		{},
+		{
+			Name:      1,
+			Type:      uint32(elf.SHT_STRTAB),
+			Off:       uint64(shstrtabOffset),
+			Size:      uint64(len(shstrtab)),
+			Addralign: 1,
+		},
+		{
+			Name:      uint32(len("\x00.shstrtab\x00")),
+			Type:      uint32(elf.SHT_PROGBITS),
+			Off:       uint64(modNameOffset),
+			Size:      uint64(len(modName)),
+			Addralign: 1,
+		},
+	}
+
+	buffer := bytes.Buffer{}
+	err := binary.Write(&buffer, binary.LittleEndian, header)
+	assert.NoError(t, err)
+	buffer.Write(shstrtab)
+	buffer.Write(modName)
+	err = binary.Write(&buffer, binary.LittleEndian, sections)
+	assert.NoError(t, err)
+	return buffer.Bytes()
+}
+
+// buildTestGrubModsSection builds the 'mods' section of a grub image with
+// the given modules and prefix.
+func buildTestGrubModsSection(t *testing.T, moduleNames []string, prefix string) []byte {
+	objects := bytes.Buffer{}
+	writeObject := func(objType uint32, data []byte) {
+		// grub aligns the objects to 4 bytes.
+		paddedSize := (len(data) + 3) &^ 3
+		binary.Write(&objects, binary.LittleEndian, objType)
+		binary.Write(&objects, binary.LittleEndian, uint32(8+paddedSize))
+		objects.Write(data)
+		objects.Write(make([]byte, paddedSize-len(data)))
+	}
+
+	for _, moduleName := range moduleNames {
+		writeObject(grubObjTypeElf, buildTestGrubModule(t, moduleName))
+	}
+	if prefix != "" {
+		writeObject(grubObjTypePrefix, []byte(prefix+"\x00"))
+	}
+
+	section := bytes.Buffer{}
+	binary.Write(&section, binary.LittleEndian, uint32(grubModuleMagic))
+	binary.Write(&section, binary.LittleEndian, uint32(0))
+	binary.Write(&section, binary.LittleEndian, uint64(24))
+	binary.Write(&section, binary.LittleEndian, uint64(24+objects.Len()))
+	section.Write(objects.Bytes())
+	return section.Bytes()
+}
+
+func TestParseGrubModsSection(t *testing.T) {
+	data := buildTestGrubModsSection(t, []string{"efinet", "tftp", "http"}, "/boot/grub2")
+
+	info, err := parseGrubModsSection(data)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, []string{"efinet", "tftp", "http"}, info.modules)
+	assert.Equal(t, "/boot/grub2", info.prefix)
+	assert.True(t, info.hasModule("http"))
+	assert.False(t, info.hasModule("iso9660"))
+}
+
+func TestParseGrubModsSectionNoPrefix(t *testing.T) {
+	data := buildTestGrubModsSection(t, []string{"efinet"}, "")
+
+	info, err := parseGrubModsSection(data)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, []string{"efinet"}, info.modules)
+	assert.Equal(t, "", info.prefix)
+}
+
+func TestParseGrubModsSectionBadMagic(t *testing.T) {
+	data := buildTestGrubModsSection(t, []string{"efinet"}, "")
+	data[0] = 0
+
+	_, err := parseGrubModsSection(data)
+	assert.ErrorContains(t, err, "invalid module info magic")
+}
+
+func TestParseGrubModsSectionTruncated(t *testing.T) {
+	data := buildTestGrubModsSection(t, []string{"efinet"}, "")
+
+	_, err := parseGrubModsSection(data[:len(data)-8])
+	assert.ErrorContains(t, err, "invalid module info")
+}
+
+func TestWriteUefiHttpBootRedirectGrubCfg(t *testing.T) {
+	testDir := filepath.Join(tmpDir, "TestWriteUefiHttpBootRedirectGrubCfg")
+	defer os.RemoveAll(testDir)
+
+	err := os.MkdirAll(testDir, os.ModePerm)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// The grub prefix is already the folder of the PXE grub.cfg.
+	err = writeUefiHttpBootRedirectGrubCfg(testDir, grubCfgDir)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NoFileExists(t, filepath.Join(testDir, grubCfgDir, isoGrubCfg))
+
+	err = writeUefiHttpBootRedirectGrubCfg(testDir, "/EFI/BOOT")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	redirectGrubCfg, err := file.Read(filepath.Join(testDir, "EFI/BOOT", isoGrubCfg))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Contains(t, redirectGrubCfg, "set prefix=($root)/boot/grub2\n")
+	assert.Contains(t, redirectGrubCfg, "configfile $prefix/grub.cfg\n")
+
+	err = writeUefiHttpBootRedirectGrubCfg(testDir, "/EFI/BOOT")
+	assert.ErrorContains(t, err, "already exists")
+
+	err = writeUefiHttpBootRedirectGrubCfg(testDir, "(hd0,gpt1)/EFI/BOOT")
+	assert.ErrorContains(t, err, "doesn't support a grub image with a device in its prefix")
+}
+
+func TestPrepareUefiHttpBootMissingGrub(t *testing.T) {
+	testDir := filepath.Join(tmpDir, "TestPrepareUefiHttpBootMissingGrub")
+	defer os.RemoveAll(testDir)
+
+	err := os.MkdirAll(testDir, os.ModePerm)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	err = prepareUefiHttpBoot(testDir, "http://192.168.0.1/")
+	assert.ErrorContains(t, err, "UEFI HTTP boot requires (grubx64.efi) in the PXE artifacts folder")
+}
+
+func TestPrepareUefiHttpBootInvalidGrub(t *testing.T) {
+	testDir := filepath.Join(tmpDir, "TestPrepareUefiHttpBootInvalidGrub")
+	defer os.RemoveAll(testDir)
+
+	err := os.MkdirAll(testDir, os.ModePerm)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	err = file.Write("not a PE image", filepath.Join(testDir, grubx64Binary))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	err = prepareUefiHttpBoot(testDir, "http://192.168.0.1/")
+	assert.ErrorContains(t, err, "failed to open grub EFI image")
+}
@@ -0,0 +1,81 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/imagecustomizerapi"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/file"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/logger"
+)
+
+const (
+	defaultIpxeScriptTemplate = `#!ipxe
+
+kernel {{.KernelPath}}
+initrd {{.InitrdPath}}
+imgargs {{.KernelName}} initrd={{.InitrdName}} {{.KernelArgs}}
+boot
+`
+)
+
+// writePxeIpxeScript
+//
+//   - generates an iPXE boot script from the PXE grub.cfg of a populated PXE
+//     artifacts folder, and places it at the root of that folder.
+//
+// inputs:
+//
+//   - 'outputPXEArtifactsDir':
+//     path to the populated PXE artifacts folder.
+//   - 'ipxeScript':
+//     the name and the (optional) template of the script.
+func writePxeIpxeScript(outputPXEArtifactsDir string, ipxeScript *imagecustomizerapi.PxeIpxeScript) error {
+	bootEntries, err := readPxeBootEntries(outputPXEArtifactsDir)
+	if err != nil {
+		return err
+	}
+
+	// iPXE scripts have no menu by default. So, boot the default entry.
+	scriptContent, err := generateIpxeScript(bootEntries[0], ipxeScript.Template)
+	if err != nil {
+		return err
+	}
+
+	scriptPath := filepath.Join(outputPXEArtifactsDir, ipxeScript.GetName())
+
+	logger.Log.Infof("Writing iPXE boot script (%s)", scriptPath)
+
+	err = file.Write(scriptContent, scriptPath)
+	if err != nil {
+		return fmt.Errorf("failed to write iPXE boot script (%s):\n%w", scriptPath, err)
+	}
+
+	return nil
+}
+
+// generateIpxeScript renders the iPXE boot script for the boot entry. If 'scriptTemplate' is empty, the default
+// template is used.
+func generateIpxeScript(bootEntry pxeBootEntry, scriptTemplate string) (string, error) {
+	if scriptTemplate == "" {
+		scriptTemplate = defaultIpxeScriptTemplate
+	}
+
+	tmpl, err := template.New("ipxe").Option("missingkey=error").Parse(scriptTemplate)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse iPXE boot script template:\n%w", err)
+	}
+
+	builder := strings.Builder{}
+	err = tmpl.Execute(&builder, bootEntry)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate iPXE boot script:\n%w", err)
+	}
+
+	return builder.String(), nil
+}
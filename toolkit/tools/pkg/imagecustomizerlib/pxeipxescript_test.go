@@ -0,0 +1,68 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/imagecustomizerapi"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/file"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWritePxeIpxeScript(t *testing.T) {
+	testDir := filepath.Join(tmpDir, "TestWritePxeIpxeScript")
+	defer os.RemoveAll(testDir)
+
+	err := os.MkdirAll(filepath.Join(testDir, grubCfgDir), os.ModePerm)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	err = file.Write(testPxeGrubCfg, filepath.Join(testDir, grubCfgDir, isoGrubCfg))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// Default name and template.
+	err = writePxeIpxeScript(testDir, &imagecustomizerapi.PxeIpxeScript{})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	script, err := file.Read(filepath.Join(testDir, imagecustomizerapi.PxeDefaultIpxeScriptName))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, "#!ipxe\n\n"+
+		"kernel boot/vmlinuz\n"+
+		"initrd boot/initrd.img\n"+
+		"imgargs vmlinuz initrd=initrd.img root=live:http://192.168.0.1/liveos/azl.iso rd.live.image "+
+		"console=ttyS0,115200n8 ip=dhcp rd.live.azldownloader=enable\n"+
+		"boot\n", script)
+
+	// Custom name and template.
+	err = writePxeIpxeScript(testDir, &imagecustomizerapi.PxeIpxeScript{
+		Name:     "azl.ipxe",
+		Template: "#!ipxe\nchain {{.IsoImageUrl}}\n",
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	script, err = file.Read(filepath.Join(testDir, "azl.ipxe"))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "#!ipxe\nchain http://192.168.0.1/liveos/azl.iso\n", script)
+
+	// Unknown template field.
+	err = writePxeIpxeScript(testDir, &imagecustomizerapi.PxeIpxeScript{
+		Template: "{{.Kernel}}",
+	})
+	assert.ErrorContains(t, err, "failed to generate iPXE boot script")
+}
@@ -0,0 +1,75 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/file"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/logger"
+)
+
+const (
+	pxelinuxCfgDir         = "pxelinux.cfg"
+	pxelinuxCfgDefault     = "default"
+	pxelinuxCfgLabelPrefix = "liveos"
+)
+
+// writePxelinuxConfig
+//
+//   - generates a PXELINUX configuration from the PXE grub.cfg of a populated
+//     PXE artifacts folder, and places it under '<pxe-folder>/pxelinux.cfg/default'.
+//   - each grub menu entry becomes a label with matching KERNEL, INITRD and
+//     APPEND lines. The first menu entry is the default label.
+//
+// inputs:
+//
+//   - 'outputPXEArtifactsDir':
+//     path to the populated PXE artifacts folder.
+func writePxelinuxConfig(outputPXEArtifactsDir string) error {
+	bootEntries, err := readPxeBootEntries(outputPXEArtifactsDir)
+	if err != nil {
+		return err
+	}
+
+	configDir := filepath.Join(outputPXEArtifactsDir, pxelinuxCfgDir)
+	err = os.MkdirAll(configDir, os.ModePerm)
+	if err != nil {
+		return fmt.Errorf("failed to create folder (%s):\n%w", configDir, err)
+	}
+
+	configPath := filepath.Join(configDir, pxelinuxCfgDefault)
+
+	logger.Log.Infof("Writing PXELINUX configuration (%s)", configPath)
+
+	err = file.Write(generatePxelinuxConfig(bootEntries), configPath)
+	if err != nil {
+		return fmt.Errorf("failed to write PXELINUX configuration (%s):\n%w", configPath, err)
+	}
+
+	return nil
+}
+
+func generatePxelinuxConfig(bootEntries []pxeBootEntry) string {
+	builder := strings.Builder{}
+	fmt.Fprintf(&builder, "DEFAULT %s0\n", pxelinuxCfgLabelPrefix)
+	builder.WriteString("PROMPT 0\n")
+	builder.WriteString("TIMEOUT 0\n")
+
+	for i, bootEntry := range bootEntries {
+		builder.WriteString("\n")
+		fmt.Fprintf(&builder, "LABEL %s%d\n", pxelinuxCfgLabelPrefix, i)
+		if bootEntry.Title != "" {
+			fmt.Fprintf(&builder, "  MENU LABEL %s\n", bootEntry.Title)
+		}
+		fmt.Fprintf(&builder, "  KERNEL %s\n", bootEntry.KernelPath)
+		fmt.Fprintf(&builder, "  INITRD %s\n", bootEntry.InitrdPath)
+		fmt.Fprintf(&builder, "  APPEND %s\n", bootEntry.KernelArgs)
+	}
+
+	return builder.String()
+}
@@ -0,0 +1,55 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/file"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWritePxelinuxConfig(t *testing.T) {
+	testDir := filepath.Join(tmpDir, "TestWritePxelinuxConfig")
+	defer os.RemoveAll(testDir)
+
+	err := os.MkdirAll(filepath.Join(testDir, grubCfgDir), os.ModePerm)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	err = file.Write(testPxeGrubCfg, filepath.Join(testDir, grubCfgDir, isoGrubCfg))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	err = writePxelinuxConfig(testDir)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	config, err := file.Read(filepath.Join(testDir, pxelinuxCfgDir, pxelinuxCfgDefault))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, "DEFAULT liveos0\n"+
+		"PROMPT 0\n"+
+		"TIMEOUT 0\n"+
+		"\n"+
+		"LABEL liveos0\n"+
+		"  MENU LABEL Azure Linux\n"+
+		"  KERNEL boot/vmlinuz\n"+
+		"  INITRD boot/initrd.img\n"+
+		"  APPEND root=live:http://192.168.0.1/liveos/azl.iso rd.live.image console=ttyS0,115200n8 ip=dhcp "+
+		"rd.live.azldownloader=enable\n"+
+		"\n"+
+		"LABEL liveos1\n"+
+		"  MENU LABEL Azure Linux (debug)\n"+
+		"  KERNEL boot/vmlinuz\n"+
+		"  INITRD boot/initrd.img\n"+
+		"  APPEND root=live:http://192.168.0.1/liveos/azl.iso rd.debug\n", config)
+}
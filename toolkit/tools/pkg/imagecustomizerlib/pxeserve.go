@@ -0,0 +1,151 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/file"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/logger"
+)
+
+const (
+	pxeServeShutdownTimeout = 5 * time.Second
+)
+
+// The MIME types of the files in the PXE artifacts folder. The system's MIME database (if any) doesn't know about most
+// of them.
+var pxeArtifactsMimeTypes = map[string]string{
+	".cfg":  "text/plain; charset=utf-8",
+	".efi":  "application/efi",
+	".img":  "application/octet-stream",
+	".ipxe": "text/plain; charset=utf-8",
+	".iso":  "application/x-iso9660-image",
+	".json": "application/json",
+}
+
+// ServePxeArtifacts
+//
+//   - serves a PXE artifacts folder over HTTP, until the context is
+//     cancelled. Each request is logged.
+//   - this is meant for quick testing (e.g. booting a test VM against the
+//     artifacts). It is not a production PXE server.
+//
+// inputs:
+//
+//   - 'pxeArtifactsDir':
+//     path to the PXE artifacts folder.
+//   - 'address':
+//     the address to listen on (e.g. ':8080').
+func ServePxeArtifacts(ctx context.Context, pxeArtifactsDir string, address string) error {
+	isDir, err := file.IsDir(pxeArtifactsDir)
+	if err != nil {
+		return fmt.Errorf("failed to check PXE artifacts folder (%s):\n%w", pxeArtifactsDir, err)
+	}
+	if !isDir {
+		return fmt.Errorf("PXE artifacts folder (%s) is not a folder", pxeArtifactsDir)
+	}
+
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return fmt.Errorf("failed to listen on (%s):\n%w", address, err)
+	}
+
+	server := &http.Server{
+		Handler: newPxeArtifactsHandler(pxeArtifactsDir),
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.Serve(listener)
+	}()
+
+	logger.Log.Infof("Serving PXE artifacts (%s) on (http://%s)", pxeArtifactsDir, listener.Addr())
+
+	select {
+	case err = <-serveErr:
+		return fmt.Errorf("failed to serve PXE artifacts:\n%w", err)
+
+	case <-ctx.Done():
+	}
+
+	logger.Log.Infof("Stopping PXE artifacts server")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), pxeServeShutdownTimeout)
+	defer cancel()
+
+	err = server.Shutdown(shutdownCtx)
+	if err != nil {
+		return fmt.Errorf("failed to stop PXE artifacts server:\n%w", err)
+	}
+
+	err = <-serveErr
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("failed to serve PXE artifacts:\n%w", err)
+	}
+
+	return nil
+}
+
+func newPxeArtifactsHandler(pxeArtifactsDir string) http.Handler {
+	fileServer := http.FileServer(http.Dir(pxeArtifactsDir))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &pxeResponseRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		// http.FileServer keeps the Content-Type if it is already set.
+		recorder.Header().Set("Content-Type", getPxeArtifactMimeType(r.URL.Path))
+
+		fileServer.ServeHTTP(recorder, r)
+
+		logger.Log.Infof("%s %s %s %d %d %s", r.RemoteAddr, r.Method, r.URL.Path, recorder.status, recorder.size,
+			time.Since(start).Round(time.Millisecond))
+	})
+}
+
+// getPxeArtifactMimeType returns the MIME type of a file in the PXE artifacts folder.
+func getPxeArtifactMimeType(urlPath string) string {
+	if strings.HasSuffix(urlPath, "/") {
+		// Folder listing.
+		return "text/html; charset=utf-8"
+	}
+
+	if path.Base(path.Dir(urlPath)) == pxelinuxCfgDir {
+		return "text/plain; charset=utf-8"
+	}
+
+	mimeType, found := pxeArtifactsMimeTypes[path.Ext(urlPath)]
+	if !found {
+		// For example, the kernel (vmlinuz).
+		return "application/octet-stream"
+	}
+
+	return mimeType
+}
+
+// pxeResponseRecorder records the status and the size of a response, for logging.
+type pxeResponseRecorder struct {
+	http.ResponseWriter
+	status int
+	size   int64
+}
+
+func (r *pxeResponseRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *pxeResponseRecorder) Write(data []byte) (int, error) {
+	n, err := r.ResponseWriter.Write(data)
+	r.size += int64(n)
+	return n, err
+}
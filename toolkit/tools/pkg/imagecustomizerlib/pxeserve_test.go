@@ -0,0 +1,102 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/file"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPxeArtifactsHandler(t *testing.T) {
+	testDir := filepath.Join(tmpDir, "TestPxeArtifactsHandler")
+	defer os.RemoveAll(testDir)
+
+	err := os.MkdirAll(filepath.Join(testDir, "boot/grub2"), os.ModePerm)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	err = os.MkdirAll(filepath.Join(testDir, pxelinuxCfgDir), os.ModePerm)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	testFiles := map[string]string{
+		"bootx64.efi":          "shim",
+		"boot/vmlinuz":         "kernel",
+		"boot/grub2/grub.cfg":  "grub config",
+		"pxelinux.cfg/default": "DEFAULT liveos0\n",
+		"azl.iso":              "iso",
+	}
+	for path, content := range testFiles {
+		err = file.Write(content, filepath.Join(testDir, path))
+		if !assert.NoError(t, err) {
+			return
+		}
+	}
+
+	server := httptest.NewServer(newPxeArtifactsHandler(testDir))
+	defer server.Close()
+
+	expectedMimeTypes := map[string]string{
+		"/bootx64.efi":          "application/efi",
+		"/boot/vmlinuz":         "application/octet-stream",
+		"/boot/grub2/grub.cfg":  "text/plain; charset=utf-8",
+		"/pxelinux.cfg/default": "text/plain; charset=utf-8",
+		"/azl.iso":              "application/x-iso9660-image",
+	}
+	for urlPath, expectedMimeType := range expectedMimeTypes {
+		response, err := http.Get(server.URL + urlPath)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		body, err := io.ReadAll(response.Body)
+		response.Body.Close()
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		assert.Equal(t, http.StatusOK, response.StatusCode, urlPath)
+		assert.Equal(t, expectedMimeType, response.Header.Get("Content-Type"), urlPath)
+		assert.Equal(t, testFiles[urlPath[1:]], string(body), urlPath)
+	}
+
+	response, err := http.Get(server.URL + "/missing.efi")
+	if !assert.NoError(t, err) {
+		return
+	}
+	response.Body.Close()
+	assert.Equal(t, http.StatusNotFound, response.StatusCode)
+}
+
+func TestServePxeArtifactsMissingDir(t *testing.T) {
+	err := ServePxeArtifacts(context.Background(), filepath.Join(tmpDir, "TestServePxeArtifactsMissingDir"),
+		"127.0.0.1:0")
+	assert.ErrorContains(t, err, "failed to check PXE artifacts folder")
+}
+
+func TestServePxeArtifactsCancel(t *testing.T) {
+	testDir := filepath.Join(tmpDir, "TestServePxeArtifactsCancel")
+	defer os.RemoveAll(testDir)
+
+	err := os.MkdirAll(testDir, os.ModePerm)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err = ServePxeArtifacts(ctx, testDir, "127.0.0.1:0")
+	assert.NoError(t, err)
+}
@@ -12,7 +12,8 @@ import (
 	"github.com/microsoft/azurelinux/toolkit/tools/internal/safechroot"
 )
 
-func addCustomizerRelease(imageChroot *safechroot.Chroot, toolVersion string, buildTime string, imageUuid string) error {
+func addCustomizerRelease(imageChroot *safechroot.Chroot, toolVersion string, buildTime string, imageUuid string,
+	releaseInfo isoReleaseInfo) error {
 	var err error
 
 	logger.Log.Infof("Creating image customizer release file")
@@ -22,8 +23,15 @@ func addCustomizerRelease(imageChroot *safechroot.Chroot, toolVersion string, bu
 		fmt.Sprintf("%s=\"%s\"", "TOOL_VERSION", toolVersion),
 		fmt.Sprintf("%s=\"%s\"", "BUILD_DATE", buildTime),
 		fmt.Sprintf("%s=\"%s\"", "IMAGE_UUID", imageUuid),
-		"",
 	}
+	if releaseInfo.releaseVersion != "" {
+		lines = append(lines, fmt.Sprintf("%s=\"%s\"", "RELEASE_VERSION", releaseInfo.releaseVersion))
+	}
+	if releaseInfo.tag != "" {
+		lines = append(lines, fmt.Sprintf("%s=\"%s\"", "IMAGE_TAG", releaseInfo.tag))
+	}
+	lines = append(lines, "")
+
 	err = file.WriteLines(lines, customizerReleaseFilePath)
 	if err != nil {
 		return fmt.Errorf("error writing customizer release file (%s): %w", customizerReleaseFilePath, err)
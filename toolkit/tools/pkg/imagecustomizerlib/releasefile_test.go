@@ -34,7 +34,12 @@ func TestAddCustomizerRelease(t *testing.T) {
 	_, expectedUuid, err := createUuid()
 	assert.NoError(t, err)
 
-	err = addCustomizerRelease(chroot, expectedVersion, expectedDate, expectedUuid)
+	releaseInfo := isoReleaseInfo{
+		releaseVersion: "3.0.20240601",
+		tag:            "livecd",
+	}
+
+	err = addCustomizerRelease(chroot, expectedVersion, expectedDate, expectedUuid, releaseInfo)
 	assert.NoError(t, err)
 
 	releaseFilePath := filepath.Join(chroot.RootDir(), "etc/image-customizer-release")
@@ -61,4 +66,6 @@ func TestAddCustomizerRelease(t *testing.T) {
 	assert.Equal(t, expectedVersion, config["TOOL_VERSION"])
 	assert.Equal(t, expectedDate, config["BUILD_DATE"])
 	assert.Equal(t, expectedUuid, config["IMAGE_UUID"])
+	assert.Equal(t, "3.0.20240601", config["RELEASE_VERSION"])
+	assert.Equal(t, "livecd", config["IMAGE_TAG"])
 }
@@ -0,0 +1,34 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+const (
+	// The environment variable that holds the timestamp (in seconds since the Unix epoch) of a reproducible build.
+	// See: https://reproducible-builds.org/specs/source-date-epoch/
+	sourceDateEpochEnvVar = "SOURCE_DATE_EPOCH"
+)
+
+// getSourceDateEpoch returns the timestamp that a reproducible build uses instead of the current time. It is read from
+// the SOURCE_DATE_EPOCH environment variable. If the variable is not set, then the Unix epoch is used.
+func getSourceDateEpoch() (time.Time, error) {
+	value := os.Getenv(sourceDateEpochEnvVar)
+	if value == "" {
+		return time.Unix(0, 0).UTC(), nil
+	}
+
+	seconds, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || seconds < 0 {
+		return time.Time{}, fmt.Errorf("invalid %s value (%s):\nmust be the number of seconds since the Unix epoch",
+			sourceDateEpochEnvVar, value)
+	}
+
+	return time.Unix(seconds, 0).UTC(), nil
+}
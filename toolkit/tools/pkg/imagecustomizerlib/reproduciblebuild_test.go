@@ -0,0 +1,39 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"testing"
+	"time"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/pkg/isomakerlib"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetSourceDateEpoch(t *testing.T) {
+	t.Setenv(sourceDateEpochEnvVar, "")
+	sourceDateEpoch, err := getSourceDateEpoch()
+	if assert.NoError(t, err) {
+		assert.Equal(t, int64(0), sourceDateEpoch.Unix())
+	}
+
+	t.Setenv(sourceDateEpochEnvVar, "1700000000")
+	sourceDateEpoch, err = getSourceDateEpoch()
+	if assert.NoError(t, err) {
+		assert.Equal(t, int64(1700000000), sourceDateEpoch.Unix())
+	}
+
+	t.Setenv(sourceDateEpochEnvVar, "yesterday")
+	_, err = getSourceDateEpoch()
+	assert.ErrorContains(t, err, "invalid SOURCE_DATE_EPOCH value (yesterday)")
+
+	t.Setenv(sourceDateEpochEnvVar, "-1")
+	_, err = getSourceDateEpoch()
+	assert.ErrorContains(t, err, "invalid SOURCE_DATE_EPOCH value (-1)")
+}
+
+func TestSourceDateEpochEnvironment(t *testing.T) {
+	env := isomakerlib.SourceDateEpochEnvironment(time.Unix(1700000000, 0))
+	assert.Contains(t, env, "SOURCE_DATE_EPOCH=1700000000")
+}
@@ -4,6 +4,7 @@
 package imagecustomizerlib
 
 import (
+	"context"
 	"os"
 	"path/filepath"
 	"testing"
@@ -29,8 +30,8 @@ func TestCustomizeImageResolvConfDelete(t *testing.T) {
 		},
 	}
 
-	err := CustomizeImage(buildDir, testDir, &config, baseImage, nil, outImageFilePath, "raw", "",
-		"" /*outputPXEArtifactsDir*/, false /*useBaseImageRpmRepos*/, false /*enableShrinkFilesystems*/)
+	err := CustomizeImage(context.Background(), buildDir, testDir, &config, baseImage, nil, outImageFilePath, "raw", "",
+		"" /*outputPXEArtifactsDir*/, false /*useBaseImageRpmRepos*/, false /*enableShrinkFilesystems*/, CustomizeImageOptions{})
 	if !assert.NoError(t, err) {
 		return
 	}
@@ -62,7 +63,7 @@ func TestCustomizeImageResolvConfRestoreFile(t *testing.T) {
 		return
 	}
 
-	err = convertImageFile(baseImage, outImageFilePath, "raw")
+	err = convertImageFile(context.Background(), baseImage, outImageFilePath, "raw")
 	if !assert.NoError(t, err) {
 		return
 	}
@@ -92,8 +93,8 @@ func TestCustomizeImageResolvConfRestoreFile(t *testing.T) {
 		OS: &imagecustomizerapi.OS{},
 	}
 
-	err = CustomizeImage(buildDir, testDir, &config, outImageFilePath, nil, outImageFilePath, "raw", "",
-		"" /*outputPXEArtifactsDir*/, false /*useBaseImageRpmRepos*/, false /*enableShrinkFilesystems*/)
+	err = CustomizeImage(context.Background(), buildDir, testDir, &config, outImageFilePath, nil, outImageFilePath, "raw", "",
+		"" /*outputPXEArtifactsDir*/, false /*useBaseImageRpmRepos*/, false /*enableShrinkFilesystems*/, CustomizeImageOptions{})
 	if !assert.NoError(t, err) {
 		return
 	}
@@ -133,7 +134,7 @@ func TestCustomizeImageResolvConfRestoreSymlink(t *testing.T) {
 		return
 	}
 
-	err = convertImageFile(baseImage, outImageFilePath, "raw")
+	err = convertImageFile(context.Background(), baseImage, outImageFilePath, "raw")
 	if !assert.NoError(t, err) {
 		return
 	}
@@ -162,8 +163,8 @@ func TestCustomizeImageResolvConfRestoreSymlink(t *testing.T) {
 		OS: &imagecustomizerapi.OS{},
 	}
 
-	err = CustomizeImage(buildDir, testDir, &config, outImageFilePath, nil, outImageFilePath, "raw", "",
-		"" /*outputPXEArtifactsDir*/, false /*useBaseImageRpmRepos*/, false /*enableShrinkFilesystems*/)
+	err = CustomizeImage(context.Background(), buildDir, testDir, &config, outImageFilePath, nil, outImageFilePath, "raw", "",
+		"" /*outputPXEArtifactsDir*/, false /*useBaseImageRpmRepos*/, false /*enableShrinkFilesystems*/, CustomizeImageOptions{})
 	if !assert.NoError(t, err) {
 		return
 	}
@@ -200,8 +201,8 @@ func TestCustomizeImageResolvConfNewSymlink(t *testing.T) {
 		},
 	}
 
-	err := CustomizeImage(buildDir, testDir, &config, baseImage, nil, outImageFilePath, "raw", "",
-		"" /*outputPXEArtifactsDir*/, false /*useBaseImageRpmRepos*/, false /*enableShrinkFilesystems*/)
+	err := CustomizeImage(context.Background(), buildDir, testDir, &config, baseImage, nil, outImageFilePath, "raw", "",
+		"" /*outputPXEArtifactsDir*/, false /*useBaseImageRpmRepos*/, false /*enableShrinkFilesystems*/, CustomizeImageOptions{})
 	if !assert.NoError(t, err) {
 		return
 	}
@@ -0,0 +1,81 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/safechroot"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/shell"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	// Escape the special characters of the (basic) regular expressions and of the replacements of tar's '--transform'
+	// option, as well as the '|' delimiter.
+	tarTransformRegexEscaper = strings.NewReplacer(`\`, `\\`, `.`, `\.`, `[`, `\[`, `]`, `\]`, `*`, `\*`, `^`, `\^`,
+		`$`, `\$`, `|`, `\|`)
+	tarTransformReplacementEscaper = strings.NewReplacer(`\`, `\\`, `&`, `\&`, `|`, `\|`)
+)
+
+// addRootfsFilesFunc adds files to a rootfs tar file, while the OS is still mounted.
+type addRootfsFilesFunc func(imageChroot *safechroot.Chroot) error
+
+// createRootfsTarFile writes the files of the customized image's OS (including its mounted partitions) to an
+// uncompressed tar file. The file owners, permissions, extended attributes (including the SELinux labels) and ACLs are
+// kept.
+func createRootfsTarFile(ctx context.Context, buildDir string, rawImageFile string, chrootDirName string,
+	tarFile string, addFiles addRootfsFilesFunc,
+) error {
+	imageConnection, err := connectToExistingImageReadOnly(rawImageFile, buildDir, chrootDirName)
+	if err != nil {
+		return err
+	}
+	defer imageConnection.Close()
+
+	rootDir := imageConnection.Chroot().RootDir()
+
+	err = shell.NewExecBuilder("tar", "--create", "--file", tarFile, "--directory", rootDir, "--sort=name",
+		"--numeric-owner", "--xattrs", "--xattrs-include=*", "--acls", "--selinux", "--sparse", ".").
+		Context(ctx).
+		LogLevel(logrus.DebugLevel, logrus.DebugLevel).
+		ErrorStderrLines(1).
+		Execute()
+	if err != nil {
+		return fmt.Errorf("failed to write the OS files to (%s):\n%w", tarFile, err)
+	}
+
+	if addFiles != nil {
+		err = addFiles(imageConnection.Chroot())
+		if err != nil {
+			return err
+		}
+	}
+
+	err = imageConnection.CleanClose()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// appendFileToTarFile appends the file 'path' (relative to 'dir') to a tar file, as 'archivePath'. When the tar file
+// is extracted, the appended file replaces any earlier file of the same path.
+func appendFileToTarFile(ctx context.Context, tarFile string, dir string, path string, archivePath string) error {
+	err := shell.NewExecBuilder("tar", "--append", "--file", tarFile, "--directory", dir, "--numeric-owner",
+		"--transform", fmt.Sprintf("s|^%s$|%s|", tarTransformRegexEscaper.Replace(path),
+			tarTransformReplacementEscaper.Replace(archivePath)), path).
+		Context(ctx).
+		LogLevel(logrus.DebugLevel, logrus.DebugLevel).
+		ErrorStderrLines(1).
+		Execute()
+	if err != nil {
+		return fmt.Errorf("failed to add (%s) to (%s):\n%w", archivePath, tarFile, err)
+	}
+
+	return nil
+}
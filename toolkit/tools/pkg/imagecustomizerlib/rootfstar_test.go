@@ -0,0 +1,52 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/file"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAppendFileToTarFile(t *testing.T) {
+	testDir := filepath.Join(tmpDir, "TestAppendFileToTarFile")
+	defer os.RemoveAll(testDir)
+
+	rootDir := filepath.Join(testDir, "root")
+	err := os.MkdirAll(filepath.Join(rootDir, "boot"), os.ModePerm)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	err = file.Write("kernel", filepath.Join(rootDir, "boot/vmlinuz-6.6.1+azl.x86_64"))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	tarFile := filepath.Join(testDir, "rootfs.tar")
+	err = writeTestTarFile(tarFile, map[string]string{"etc/fstab": "/dev/sda1 / ext4 defaults 0 1\n"})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// The special characters of the paths are matched literally.
+	err = appendFileToTarFile(context.Background(), tarFile, rootDir, "boot/vmlinuz-6.6.1+azl.x86_64",
+		"usr/lib/modules/6.6.1+azl.x86_64/vmlinuz")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	files, err := readTestTarFile(tarFile)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, map[string]string{
+		"etc/fstab": "/dev/sda1 / ext4 defaults 0 1\n",
+		"usr/lib/modules/6.6.1+azl.x86_64/vmlinuz": "kernel",
+	}, files)
+}
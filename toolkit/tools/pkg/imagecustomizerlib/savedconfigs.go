@@ -10,6 +10,7 @@ import (
 
 	"github.com/microsoft/azurelinux/toolkit/tools/imagecustomizerapi"
 	"github.com/microsoft/azurelinux/toolkit/tools/internal/file"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/safechroot"
 )
 
 // 'SavedConfigs' is a subset of the Image Customizer input configurations that
@@ -29,6 +30,18 @@ import (
 
 type IsoSavedConfigs struct {
 	KernelCommandLine imagecustomizerapi.KernelCommandLine `yaml:"kernelCommandLine"`
+	// The sha256 of the files on the iso media that are carried over when the
+	// iso is customized again (iso-media-path -> sha256). Used to detect
+	// corrupted media.
+	AdditionalFilesSha256 map[string]string `yaml:"additionalFilesSha256,omitempty"`
+	// The media layout of the iso. Used to locate the rootfs image when the
+	// iso is customized again.
+	LiveOSDir       string `yaml:"liveOSDir,omitempty"`
+	LiveOSImageName string `yaml:"liveOSImageName,omitempty"`
+	// The names of the files generated under the liveOSDir (i.e. the rootfs
+	// images and the slots manifest). Used to tell them apart from the user
+	// files placed under the liveOSDir.
+	LiveOSGeneratedFiles []string `yaml:"liveOSGeneratedFiles,omitempty"`
 }
 
 func (i *IsoSavedConfigs) IsValid() error {
@@ -41,8 +54,9 @@ func (i *IsoSavedConfigs) IsValid() error {
 }
 
 type PxeSavedConfigs struct {
-	IsoImageBaseUrl string `yaml:"isoImageBaseUrl"`
-	IsoImageFileUrl string `yaml:"isoImageFileUrl"`
+	IsoImageBaseUrl string                          `yaml:"isoImageBaseUrl"`
+	IsoImageFileUrl string                          `yaml:"isoImageFileUrl"`
+	BootImage       imagecustomizerapi.PxeBootImage `yaml:"bootImage,omitempty"`
 }
 
 func (p *PxeSavedConfigs) IsValid() error {
@@ -57,6 +71,10 @@ func (p *PxeSavedConfigs) IsValid() error {
 	if err != nil {
 		return err
 	}
+	err = p.BootImage.IsValid()
+	if err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -125,3 +143,38 @@ func loadSavedConfigs(savedConfigsFilePath string) (savedConfigs *SavedConfigs,
 
 	return savedConfigs, nil
 }
+
+// saveIsoMediaConfigs
+//
+//	stores the media layout of the iso and the sha256 of the files that will
+//	be copied to the iso media in the saved configs file.
+//
+// inputs:
+//   - 'isoFiles':
+//     the files to copy to the iso media.
+func (b *LiveOSIsoBuilder) saveIsoMediaConfigs(isoFiles []safechroot.FileToCopy) error {
+	savedConfigs, err := loadSavedConfigs(b.artifacts.savedConfigsFilePath)
+	if err != nil {
+		return fmt.Errorf("failed to load saved configurations (%s):\n%w", b.artifacts.savedConfigsFilePath, err)
+	}
+	if savedConfigs == nil {
+		return nil
+	}
+
+	savedConfigs.Iso.LiveOSDir = b.liveOSDir
+	savedConfigs.Iso.LiveOSImageName = b.liveOSImage
+	savedConfigs.Iso.LiveOSGeneratedFiles = b.liveOSGeneratedFileNames()
+
+	savedConfigs.Iso.AdditionalFilesSha256, err = generateIsoFileHashes(isoFiles, b.liveOSDir,
+		savedConfigs.Iso.LiveOSGeneratedFiles)
+	if err != nil {
+		return fmt.Errorf("failed to calculate the hashes of the iso files:\n%w", err)
+	}
+
+	err = savedConfigs.persistSavedConfigs(b.artifacts.savedConfigsFilePath)
+	if err != nil {
+		return fmt.Errorf("failed to save iso media configs:\n%w", err)
+	}
+
+	return nil
+}
@@ -100,17 +100,63 @@ func partitionToImager(partition imagecustomizerapi.Partition, fileSystems []ima
 		return configuration.Partition{}, err
 	}
 
+	imagerGptAttributes, err := partitionAttributesToImager(partition.Attributes)
+	if err != nil {
+		return configuration.Partition{}, err
+	}
+
 	imagerPartition := configuration.Partition{
-		ID:     partition.Id,
-		FsType: string(fileSystem.Type),
-		Name:   partition.Label,
-		Start:  uint64(imagerStart),
-		End:    uint64(imagerEnd),
-		Flags:  imagerFlags,
+		ID:            partition.Id,
+		FsType:        string(fileSystem.Type),
+		TypeUUID:      partition.TypeUuid,
+		Name:          partition.Label,
+		Start:         uint64(imagerStart),
+		End:           uint64(imagerEnd),
+		Flags:         imagerFlags,
+		GptAttributes: imagerGptAttributes,
 	}
 	return imagerPartition, nil
 }
 
+func partitionAttributesToImager(attributes []imagecustomizerapi.PartitionAttribute) ([]uint, error) {
+	imagerGptAttributes := []uint(nil)
+	for _, attribute := range attributes {
+		imagerGptAttribute, err := partitionAttributeToImager(attribute)
+		if err != nil {
+			return nil, err
+		}
+
+		imagerGptAttributes = append(imagerGptAttributes, imagerGptAttribute)
+	}
+
+	return imagerGptAttributes, nil
+}
+
+func partitionAttributeToImager(attribute imagecustomizerapi.PartitionAttribute) (uint, error) {
+	switch attribute {
+	case imagecustomizerapi.PartitionAttributeRequired:
+		return 0, nil
+
+	case imagecustomizerapi.PartitionAttributeNoBlockIoProtocol:
+		return 1, nil
+
+	case imagecustomizerapi.PartitionAttributeLegacyBiosBootable:
+		return 2, nil
+
+	case imagecustomizerapi.PartitionAttributeGrowFileSystem:
+		return 59, nil
+
+	case imagecustomizerapi.PartitionAttributeReadOnly:
+		return 60, nil
+
+	case imagecustomizerapi.PartitionAttributeNoAuto:
+		return 63, nil
+
+	default:
+		return 0, fmt.Errorf("unknown partition attribute (%s)", attribute)
+	}
+}
+
 func toImagerPartitionFlags(partitionType imagecustomizerapi.PartitionType) ([]configuration.PartitionFlag, error) {
 	switch partitionType {
 	case imagecustomizerapi.PartitionTypeESP:
@@ -14,8 +14,8 @@ func logVersionsOfToolDeps() {
 	versionFlags := map[string][]string{
 		"--version": {
 			"qemu-img", "rpm", "dd", "lsblk", "losetup", "sfdisk", "udevadm",
-			"flock", "blkid", "sed", "createrepo", "genisoimage", "parted", "mkfs",
-			"fsck", "fatlabel", "zstd", "veritysetup", "grub-install",
+			"flock", "blkid", "sed", "createrepo", "genisoimage", "xorriso", "parted", "mkfs",
+			"fsck", "fatlabel", "zstd", "veritysetup", "grub-install", "tar", "skopeo",
 		},
 		"-version": {
 			"mksquashfs",
@@ -27,7 +27,7 @@ func logVersionsOfToolDeps() {
 			"mkfs.ext4", "mkfs.xfs", "e2fsck", "xfs_repair", "xfs_admin",
 		},
 		"": {
-			"mkfs.vfat", "resize2fs", "tune2fs",
+			"mkfs.vfat", "resize2fs", "tune2fs", "implantisomd5",
 		},
 	}
 
@@ -0,0 +1,192 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/imagecustomizerapi"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/logger"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/shell"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// The environment variable that holds the path of the OS's root directory, for scanners that scan the files
+	// themselves.
+	vulnerabilityScanRootDirEnvVar = "IMAGE_CUSTOMIZER_ROOT_DIR"
+)
+
+// vulnerabilityScanner finds the known vulnerabilities of an OS's packages.
+type vulnerabilityScanner interface {
+	scan(ctx context.Context, input *vulnerabilityScanInput) ([]vulnerabilityFinding, error)
+}
+
+// vulnerabilityScanInput is the description of the OS that is passed to the scanner.
+type vulnerabilityScanInput struct {
+	// The contents of the OS's os-release file.
+	OsRelease string `json:"osRelease"`
+	// The rpm packages installed in the OS.
+	Packages []installedRpm `json:"packages"`
+}
+
+// vulnerabilityScanOutput is the result returned by the scanner.
+type vulnerabilityScanOutput struct {
+	Findings []vulnerabilityFinding `json:"findings"`
+}
+
+type vulnerabilityFinding struct {
+	// The ID of the vulnerability (e.g. CVE-2024-1234).
+	Id string `json:"id"`
+	// The name of the vulnerable package.
+	Package string `json:"package"`
+	// The severity of the vulnerability. Scanners may return severities that are unknown to the image customizer.
+	Severity imagecustomizerapi.VulnerabilitySeverity `json:"severity"`
+	// The version of the package that fixes the vulnerability, if any.
+	FixedVersion string `json:"fixedVersion,omitempty"`
+}
+
+type vulnerabilityScanReport struct {
+	FailOnSeverity imagecustomizerapi.VulnerabilitySeverity `json:"failOnSeverity,omitempty"`
+	Findings       []vulnerabilityFinding                   `json:"findings"`
+}
+
+// commandVulnerabilityScanner runs a scanner program on the build host. The program is passed the scan input (as
+// JSON) on stdin, and must write the scan output (as JSON) to stdout.
+type commandVulnerabilityScanner struct {
+	path         string
+	args         []string
+	workingDir   string
+	imageRootDir string
+}
+
+func (s *commandVulnerabilityScanner) scan(ctx context.Context, input *vulnerabilityScanInput,
+) ([]vulnerabilityFinding, error) {
+	inputJson, err := json.Marshal(input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize vulnerability scan input:\n%w", err)
+	}
+
+	stdout, _, err := shell.NewExecBuilder(s.path, s.args...).
+		Context(ctx).
+		WorkingDirectory(s.workingDir).
+		EnvironmentVariables(append(os.Environ(), vulnerabilityScanRootDirEnvVar+"="+s.imageRootDir)).
+		Stdin(string(inputJson)).
+		LogLevel(logrus.TraceLevel, logrus.DebugLevel).
+		ErrorStderrLines(1).
+		ExecuteCaptureOuput()
+	if err != nil {
+		return nil, fmt.Errorf("vulnerability scanner (%s) failed:\n%w", s.path, err)
+	}
+
+	var output vulnerabilityScanOutput
+	err = json.Unmarshal([]byte(stdout), &output)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse output of vulnerability scanner (%s):\n%w", s.path, err)
+	}
+
+	// Scanners differ in the case of their severities (e.g. HIGH vs. high).
+	for i := range output.Findings {
+		finding := &output.Findings[i]
+		finding.Severity = imagecustomizerapi.VulnerabilitySeverity(strings.ToLower(string(finding.Severity)))
+	}
+
+	return output.Findings, nil
+}
+
+// scanForVulnerabilities scans the customized OS for vulnerabilities. Returns the build report's section. If any of
+// the vulnerabilities have the 'failOnSeverity' severity (or higher), an error is also returned.
+func scanForVulnerabilities(ctx context.Context, baseConfigPath string,
+	vulnerabilityScan *imagecustomizerapi.VulnerabilityScan, imageRootDir string,
+) (*vulnerabilityScanReport, error) {
+	logger.Log.Infof("Scanning for vulnerabilities")
+
+	osRelease, err := readOsReleaseFile(imageRootDir)
+	if err != nil {
+		return nil, err
+	}
+
+	packages, err := listInstalledRpms(ctx, imageRootDir)
+	if err != nil {
+		return nil, err
+	}
+
+	input := &vulnerabilityScanInput{
+		OsRelease: osRelease,
+		Packages:  packages,
+	}
+
+	scanner := &commandVulnerabilityScanner{
+		path:         vulnerabilityScannerPath(baseConfigPath, vulnerabilityScan),
+		args:         vulnerabilityScan.Arguments,
+		workingDir:   baseConfigPath,
+		imageRootDir: imageRootDir,
+	}
+
+	return scanForVulnerabilitiesHelper(ctx, scanner, input, vulnerabilityScan.FailOnSeverity)
+}
+
+// vulnerabilityScannerPath returns the path of the scanner program. Relative paths are relative to the config file's
+// directory.
+func vulnerabilityScannerPath(baseConfigPath string, vulnerabilityScan *imagecustomizerapi.VulnerabilityScan) string {
+	if filepath.IsAbs(vulnerabilityScan.Path) {
+		return vulnerabilityScan.Path
+	}
+	return filepath.Join(baseConfigPath, vulnerabilityScan.Path)
+}
+
+func scanForVulnerabilitiesHelper(ctx context.Context, scanner vulnerabilityScanner, input *vulnerabilityScanInput,
+	failOnSeverity imagecustomizerapi.VulnerabilitySeverity,
+) (*vulnerabilityScanReport, error) {
+	findings, err := scanner.scan(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Log.Infof("Found (%d) vulnerabilities in (%d) packages", len(findings), len(input.Packages))
+
+	report := &vulnerabilityScanReport{
+		FailOnSeverity: failOnSeverity,
+		Findings:       findings,
+	}
+	if report.Findings == nil {
+		report.Findings = []vulnerabilityFinding{}
+	}
+
+	err = checkVulnerabilityFindings(findings, failOnSeverity)
+	if err != nil {
+		return report, err
+	}
+
+	return report, nil
+}
+
+// checkVulnerabilityFindings returns an error if any of the findings have the 'failOnSeverity' severity (or higher).
+func checkVulnerabilityFindings(findings []vulnerabilityFinding,
+	failOnSeverity imagecustomizerapi.VulnerabilitySeverity,
+) error {
+	if failOnSeverity == imagecustomizerapi.VulnerabilitySeverityDefault {
+		return nil
+	}
+
+	failedCount := 0
+	for _, finding := range findings {
+		if finding.Severity.Rank() >= failOnSeverity.Rank() {
+			logger.Log.Errorf("Vulnerability (%s) of package (%s) has severity (%s)", finding.Id, finding.Package,
+				finding.Severity)
+			failedCount++
+		}
+	}
+
+	if failedCount > 0 {
+		return fmt.Errorf("found (%d) vulnerabilities with severity (%s) or higher", failedCount, failOnSeverity)
+	}
+
+	return nil
+}
@@ -0,0 +1,153 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/imagecustomizerapi"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/file"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeVulnerabilityScanner struct {
+	findings []vulnerabilityFinding
+	input    *vulnerabilityScanInput
+}
+
+func (s *fakeVulnerabilityScanner) scan(ctx context.Context, input *vulnerabilityScanInput,
+) ([]vulnerabilityFinding, error) {
+	s.input = input
+	return s.findings, nil
+}
+
+func TestScanForVulnerabilitiesHelper(t *testing.T) {
+	scanner := &fakeVulnerabilityScanner{
+		findings: []vulnerabilityFinding{
+			{Id: "CVE-2024-0001", Package: "openssl", Severity: imagecustomizerapi.VulnerabilitySeverityMedium},
+			{Id: "CVE-2024-0002", Package: "curl", Severity: "negligible"},
+		},
+	}
+	input := &vulnerabilityScanInput{
+		OsRelease: "ID=azurelinux\n",
+		Packages: []installedRpm{
+			{Name: "openssl", Epoch: "0", Version: "3.3.0", Release: "1.azl3", Arch: "x86_64"},
+		},
+	}
+
+	report, err := scanForVulnerabilitiesHelper(context.Background(), scanner, input,
+		imagecustomizerapi.VulnerabilitySeverityHigh)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, input, scanner.input)
+	assert.Equal(t, imagecustomizerapi.VulnerabilitySeverityHigh, report.FailOnSeverity)
+	assert.Equal(t, scanner.findings, report.Findings)
+}
+
+func TestScanForVulnerabilitiesHelperFailOnSeverity(t *testing.T) {
+	scanner := &fakeVulnerabilityScanner{
+		findings: []vulnerabilityFinding{
+			{Id: "CVE-2024-0001", Package: "openssl", Severity: imagecustomizerapi.VulnerabilitySeverityCritical},
+			{Id: "CVE-2024-0002", Package: "curl", Severity: imagecustomizerapi.VulnerabilitySeverityHigh},
+			{Id: "CVE-2024-0003", Package: "vim", Severity: imagecustomizerapi.VulnerabilitySeverityLow},
+		},
+	}
+
+	report, err := scanForVulnerabilitiesHelper(context.Background(), scanner, &vulnerabilityScanInput{},
+		imagecustomizerapi.VulnerabilitySeverityHigh)
+	assert.ErrorContains(t, err, "found (2) vulnerabilities with severity (high) or higher")
+	assert.Len(t, report.Findings, 3)
+}
+
+func TestScanForVulnerabilitiesHelperNoFindings(t *testing.T) {
+	scanner := &fakeVulnerabilityScanner{}
+
+	report, err := scanForVulnerabilitiesHelper(context.Background(), scanner, &vulnerabilityScanInput{},
+		imagecustomizerapi.VulnerabilitySeverityLow)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// The report always lists the findings, even if there are none.
+	assert.Equal(t, []vulnerabilityFinding{}, report.Findings)
+}
+
+func TestCommandVulnerabilityScanner(t *testing.T) {
+	testTmpDir := filepath.Join(tmpDir, "TestCommandVulnerabilityScanner")
+	defer os.RemoveAll(testTmpDir)
+
+	err := os.MkdirAll(testTmpDir, os.ModePerm)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// The scanner copies its input and environment to files, and returns a finding.
+	scannerPath := filepath.Join(testTmpDir, "scan.sh")
+	scannerContent := `#!/bin/sh
+set -e
+cat > "$1"
+echo "$IMAGE_CUSTOMIZER_ROOT_DIR" > root-dir.txt
+echo '{"findings": [{"id": "CVE-2024-0001", "package": "openssl", "severity": "HIGH", "fixedVersion": "3.3.1-1.azl3"}]}'
+`
+	err = file.WriteWithPerm(scannerContent, scannerPath, 0o755)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	scanner := &commandVulnerabilityScanner{
+		path:         scannerPath,
+		args:         []string{"input.json"},
+		workingDir:   testTmpDir,
+		imageRootDir: "/tmp/imageroot",
+	}
+	input := &vulnerabilityScanInput{
+		OsRelease: "ID=azurelinux\n",
+		Packages: []installedRpm{
+			{Name: "openssl", Epoch: "0", Version: "3.3.0", Release: "1.azl3", Arch: "x86_64"},
+		},
+	}
+
+	findings, err := scanner.scan(context.Background(), input)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, []vulnerabilityFinding{
+		{
+			Id:           "CVE-2024-0001",
+			Package:      "openssl",
+			Severity:     imagecustomizerapi.VulnerabilitySeverityHigh,
+			FixedVersion: "3.3.1-1.azl3",
+		},
+	}, findings)
+
+	inputJson, err := file.Read(filepath.Join(testTmpDir, "input.json"))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, `{"osRelease":"ID=azurelinux\n","packages":[{"name":"openssl","epoch":"0","version":"3.3.0",`+
+		`"release":"1.azl3","arch":"x86_64"}]}`, inputJson)
+
+	rootDir, err := file.Read(filepath.Join(testTmpDir, "root-dir.txt"))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "/tmp/imageroot\n", rootDir)
+}
+
+func TestCustomizeImageVulnerabilityScanMissingScanner(t *testing.T) {
+	config := &imagecustomizerapi.Config{
+		VulnerabilityScan: &imagecustomizerapi.VulnerabilityScan{
+			Path: "scripts/missing-scanner.sh",
+		},
+	}
+
+	err := validateConfig(testDir, config, nil, false)
+	assert.ErrorContains(t, err, "couldn't read vulnerability scanner file (scripts/missing-scanner.sh)")
+}
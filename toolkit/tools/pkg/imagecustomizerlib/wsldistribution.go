@@ -0,0 +1,196 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/imagecustomizerapi"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/file"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/logger"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/safechroot"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/userutils"
+)
+
+const (
+	wslRootfsFileName = "wsl-rootfs.tar"
+	wslFilesDirName   = "wslfiles"
+
+	wslConfPath  = "etc/wsl.conf"
+	wslFstabPath = "etc/fstab"
+
+	// WSL mounts the distribution's root file system itself. So, the fstab entries of the image's partitions must not
+	// be mounted.
+	wslFstabContent = "# The root file system of a WSL distribution is mounted by WSL.\n"
+)
+
+// createWslDistribution packages the OS of the customized image as a rootfs tarball, which 'wsl --import' can import
+// as a WSL distribution.
+func createWslDistribution(ctx context.Context, buildDir string, rawImageFile string,
+	wslConfig *imagecustomizerapi.Wsl, outputImageFile string,
+) error {
+	logger.Log.Infof("Creating WSL distribution")
+
+	rootfsFile := filepath.Join(buildDir, wslRootfsFileName)
+	defer os.Remove(rootfsFile)
+
+	filesDir := filepath.Join(buildDir, wslFilesDirName)
+	defer os.RemoveAll(filesDir)
+
+	wslFiles, err := writeWslFiles(filesDir, wslConfig)
+	if err != nil {
+		return err
+	}
+
+	addFiles := func(imageChroot *safechroot.Chroot) error {
+		if wslConfig != nil && wslConfig.DefaultUser != "" {
+			userExists, err := userutils.UserExists(wslConfig.DefaultUser, imageChroot)
+			if err != nil {
+				return err
+			}
+			if !userExists {
+				return fmt.Errorf("the WSL default user (%s) doesn't exist in the image", wslConfig.DefaultUser)
+			}
+		}
+
+		for _, wslFile := range wslFiles {
+			err := appendFileToTarFile(ctx, rootfsFile, filesDir, wslFile, wslFile)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	err = createRootfsTarFile(ctx, buildDir, rawImageFile, "wslroot", rootfsFile, addFiles)
+	if err != nil {
+		return fmt.Errorf("failed to create WSL rootfs:\n%w", err)
+	}
+
+	logger.Log.Infof("Writing: %s", outputImageFile)
+
+	err = compressFileGzip(rootfsFile, outputImageFile)
+	if err != nil {
+		return fmt.Errorf("failed to compress WSL rootfs (%s):\n%w", outputImageFile, err)
+	}
+
+	return nil
+}
+
+// writeWslFiles writes the files that replace the image's files in the WSL distribution. It returns their paths,
+// relative to 'filesDir'.
+func writeWslFiles(filesDir string, wslConfig *imagecustomizerapi.Wsl) ([]string, error) {
+	err := os.RemoveAll(filesDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to remove (%s):\n%w", filesDir, err)
+	}
+
+	err = os.MkdirAll(filepath.Join(filesDir, "etc"), os.ModePerm)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create (%s):\n%w", filesDir, err)
+	}
+
+	paths := []string{wslFstabPath}
+
+	err = file.WriteWithPerm(wslFstabContent, filepath.Join(filesDir, wslFstabPath), 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to write (%s):\n%w", wslFstabPath, err)
+	}
+
+	if wslConfig != nil {
+		paths = append(paths, wslConfPath)
+
+		err = file.WriteWithPerm(wslConfContent(wslConfig), filepath.Join(filesDir, wslConfPath), 0o644)
+		if err != nil {
+			return nil, fmt.Errorf("failed to write (%s):\n%w", wslConfPath, err)
+		}
+	}
+
+	return paths, nil
+}
+
+// wslConfContent returns the '/etc/wsl.conf' file of the WSL config. The unset settings are left out, so that WSL's
+// defaults apply.
+// See: https://learn.microsoft.com/windows/wsl/wsl-config#wslconf
+func wslConfContent(wslConfig *imagecustomizerapi.Wsl) string {
+	builder := strings.Builder{}
+	builder.WriteString("# Generated by the Azure Linux Image Customizer.\n")
+
+	writeSection := func(name string, settings ...string) {
+		if len(settings) == 0 {
+			return
+		}
+
+		builder.WriteString(fmt.Sprintf("\n[%s]\n", name))
+		for _, setting := range settings {
+			builder.WriteString(setting + "\n")
+		}
+	}
+
+	bootSettings := []string(nil)
+	if wslConfig.Systemd {
+		bootSettings = append(bootSettings, "systemd=true")
+	}
+	writeSection("boot", bootSettings...)
+
+	userSettings := []string(nil)
+	if wslConfig.DefaultUser != "" {
+		userSettings = append(userSettings, "default="+wslConfig.DefaultUser)
+	}
+	writeSection("user", userSettings...)
+
+	networkSettings := []string(nil)
+	if wslConfig.Hostname != "" {
+		networkSettings = append(networkSettings, "hostname="+wslConfig.Hostname)
+	}
+	writeSection("network", networkSettings...)
+
+	automountSettings := []string(nil)
+	if wslConfig.AutomountRoot != "" {
+		automountSettings = append(automountSettings, "root="+wslConfig.AutomountRoot)
+	}
+	writeSection("automount", automountSettings...)
+
+	interopSettings := []string(nil)
+	if wslConfig.DisableWindowsPath {
+		interopSettings = append(interopSettings, "appendWindowsPath=false")
+	}
+	writeSection("interop", interopSettings...)
+
+	return builder.String()
+}
+
+func compressFileGzip(inputFile string, outputFile string) error {
+	input, err := os.Open(inputFile)
+	if err != nil {
+		return err
+	}
+	defer input.Close()
+
+	output, err := os.Create(outputFile)
+	if err != nil {
+		return err
+	}
+	defer output.Close()
+
+	gzipWriter := gzip.NewWriter(output)
+
+	_, err = io.Copy(gzipWriter, input)
+	if err != nil {
+		return err
+	}
+
+	err = gzipWriter.Close()
+	if err != nil {
+		return err
+	}
+
+	return output.Close()
+}
@@ -0,0 +1,66 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/imagecustomizerapi"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/file"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWslConfContent(t *testing.T) {
+	wslConfig := &imagecustomizerapi.Wsl{
+		DefaultUser:        "azureuser",
+		Systemd:            true,
+		AutomountRoot:      "/mnt/",
+		DisableWindowsPath: true,
+	}
+
+	expected := `# Generated by the Azure Linux Image Customizer.
+
+[boot]
+systemd=true
+
+[user]
+default=azureuser
+
+[automount]
+root=/mnt/
+
+[interop]
+appendWindowsPath=false
+`
+	assert.Equal(t, expected, wslConfContent(wslConfig))
+}
+
+func TestWslConfContentEmpty(t *testing.T) {
+	assert.Equal(t, "# Generated by the Azure Linux Image Customizer.\n", wslConfContent(&imagecustomizerapi.Wsl{}))
+}
+
+func TestWriteWslFiles(t *testing.T) {
+	filesDir := filepath.Join(tmpDir, "TestWriteWslFiles")
+	defer os.RemoveAll(filesDir)
+
+	paths, err := writeWslFiles(filesDir, nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, []string{wslFstabPath}, paths)
+
+	paths, err = writeWslFiles(filesDir, &imagecustomizerapi.Wsl{Hostname: "azurelinux-dev"})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, []string{wslFstabPath, wslConfPath}, paths)
+
+	wslConf, err := file.Read(filepath.Join(filesDir, wslConfPath))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Contains(t, wslConf, "[network]\nhostname=azurelinux-dev\n")
+}
@@ -0,0 +1,71 @@
+// Copyright Microsoft Corporation.
+// Licensed under the MIT License.
+
+package isomakerlib
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/file"
+)
+
+// IsoBootFiles are the paths (on the build machine) of the boot files of an architecture. When they are specified, the
+// ISO maker copies them directly, instead of extracting them from the '/boot' folder of the initrd. So, the initrd
+// doesn't need to embed them.
+type IsoBootFiles struct {
+	BootBootloaderPath string // First stage bootloader (boot<arch>64.efi, e.g. shim). Required.
+	GrubBootloaderPath string // Second stage bootloader (grub<arch>64.efi). Required, unless grub is skipped.
+	KernelPath         string // Kernel (vmlinuz). Required.
+}
+
+// isValid returns an error if a required boot file is missing.
+func (f *IsoBootFiles) isValid(skipGrubEfi bool) error {
+	if f.BootBootloaderPath == "" {
+		return fmt.Errorf("ISO boot files require the first stage bootloader (BootBootloaderPath)")
+	}
+	if f.GrubBootloaderPath == "" && !skipGrubEfi {
+		return fmt.Errorf("ISO boot files require the grub bootloader (GrubBootloaderPath), unless grub is skipped")
+	}
+	if f.KernelPath == "" {
+		return fmt.Errorf("ISO boot files require the kernel (KernelPath)")
+	}
+	return nil
+}
+
+// isoBootFilesSource is where the boot files of an architecture are taken from.
+type isoBootFilesSource struct {
+	initrdPath string        // The initrd, which embeds the boot files under '/boot' unless 'bootFiles' is set.
+	bootFiles  *IsoBootFiles // The boot files on the build machine.
+}
+
+// bootFilesSource returns where the boot files of the build host's architecture are taken from.
+func (im *IsoMaker) bootFilesSource() isoBootFilesSource {
+	return isoBootFilesSource{initrdPath: im.initrdPath, bootFiles: im.bootFiles}
+}
+
+// copyBootBootloader copies the first stage bootloader (boot<arch>64.efi) to 'destPath'.
+func (s isoBootFilesSource) copyBootBootloader(archInfo isoBootArchInfo, destPath string) error {
+	if s.bootFiles != nil {
+		return file.Copy(s.bootFiles.BootBootloaderPath, destPath)
+	}
+	return extractFileFromInitrd(s.initrdPath, filepath.Join(initrdEFIBootDirectoryPath, archInfo.bootBootloaderFile),
+		destPath)
+}
+
+// copyGrubBootloader copies the grub bootloader (grub<arch>64.efi) to 'destPath'.
+func (s isoBootFilesSource) copyGrubBootloader(archInfo isoBootArchInfo, destPath string) error {
+	if s.bootFiles != nil {
+		return file.Copy(s.bootFiles.GrubBootloaderPath, destPath)
+	}
+	return extractFileFromInitrd(s.initrdPath, filepath.Join(initrdEFIBootDirectoryPath, archInfo.grubBootloaderFile),
+		destPath)
+}
+
+// copyKernel copies the kernel (vmlinuz) to 'destPath'.
+func (s isoBootFilesSource) copyKernel(destPath string) error {
+	if s.bootFiles != nil {
+		return file.Copy(s.bootFiles.KernelPath, destPath)
+	}
+	return extractFileFromInitrd(s.initrdPath, bootKernelFile, destPath)
+}
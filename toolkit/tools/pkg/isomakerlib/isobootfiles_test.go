@@ -0,0 +1,72 @@
+// Copyright Microsoft Corporation.
+// Licensed under the MIT License.
+
+package isomakerlib
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsoBootFilesSourceBuildMachine(t *testing.T) {
+	testDir := t.TempDir()
+
+	bootFiles := &IsoBootFiles{
+		BootBootloaderPath: filepath.Join(testDir, "shim.efi"),
+		GrubBootloaderPath: filepath.Join(testDir, "grub.efi"),
+		KernelPath:         filepath.Join(testDir, "vmlinuz-6.6"),
+	}
+	for _, path := range []string{bootFiles.BootBootloaderPath, bootFiles.GrubBootloaderPath, bootFiles.KernelPath} {
+		err := os.WriteFile(path, []byte(filepath.Base(path)), 0o644)
+		if !assert.NoError(t, err) {
+			return
+		}
+	}
+
+	// The initrd doesn't exist, so the files can only come from the build machine.
+	source := isoBootFilesSource{initrdPath: filepath.Join(testDir, "missing-initrd.img"), bootFiles: bootFiles}
+	archInfo := isoBootArchInfos[IsoArchX86_64]
+	outputDir := filepath.Join(testDir, "out")
+
+	err := source.copyBootBootloader(archInfo, filepath.Join(outputDir, "EFI/BOOT/bootx64.efi"))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	err = source.copyGrubBootloader(archInfo, filepath.Join(outputDir, "EFI/BOOT/grubx64.efi"))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	err = source.copyKernel(filepath.Join(outputDir, "vmlinuz"))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	expectedFiles := map[string]string{
+		"EFI/BOOT/bootx64.efi": "shim.efi",
+		"EFI/BOOT/grubx64.efi": "grub.efi",
+		"vmlinuz":              "vmlinuz-6.6",
+	}
+	for path, expectedContent := range expectedFiles {
+		content, err := os.ReadFile(filepath.Join(outputDir, path))
+		if assert.NoError(t, err, path) {
+			assert.Equal(t, expectedContent, string(content), path)
+		}
+	}
+}
+
+func TestIsoMakerOptionsIsValidBootFiles(t *testing.T) {
+	options := validTestIsoMakerOptions()
+	options.BootFiles = &IsoBootFiles{BootBootloaderPath: "/bootx64.efi", KernelPath: "/vmlinuz"}
+	assert.ErrorContains(t, options.IsValid(), "require the grub bootloader (GrubBootloaderPath)")
+
+	options.SkipGrubEfi = true
+	assert.NoError(t, options.IsValid())
+
+	options.BootFiles.KernelPath = ""
+	assert.ErrorContains(t, options.IsValid(), "require the kernel (KernelPath)")
+}
@@ -0,0 +1,49 @@
+// Copyright Microsoft Corporation.
+// Licensed under the MIT License.
+
+package isomakerlib
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+)
+
+const (
+	// The GPT partition type of an EFI system partition.
+	espGptPartitionType = "C12A7328-F81F-11D2-BA4B-00A0C93EC93B"
+
+	// xorriso supports up to 8 appended partitions. The first partition is the ISO file system itself.
+	hybridGptFirstEspPartition = 2
+	hybridGptMaxEspPartitions  = 8 - hybridGptFirstEspPartition + 1
+)
+
+// EnableHybridGpt makes the ISO image also bootable when it is written as-is (e.g. with 'dd') to a USB stick, on UEFI
+// machines. The ISO image gets a GPT (with a protective MBR) that holds a copy of each efiboot.img as an EFI system
+// partition.
+//
+// Requires the 'xorriso' backend (see IsoBackendXorriso).
+func (im *IsoMaker) EnableHybridGpt() {
+	im.hybridGpt = true
+}
+
+// hybridGptArgs returns the xorriso arguments that add the GPT of a hybrid ISO image (see EnableHybridGpt).
+func (im *IsoMaker) hybridGptArgs() ([]string, error) {
+	efiBootImgPaths := im.efiBootImgPathsRelativeToIsoRoot()
+	if len(efiBootImgPaths) > hybridGptMaxEspPartitions {
+		return nil, fmt.Errorf("too many EFI boot images (%d) for a hybrid ISO image, the maximum is (%d)",
+			len(efiBootImgPaths), hybridGptMaxEspPartitions)
+	}
+
+	// Leave room for the partition table before the ISO file system, so that its first partition starts at a
+	// 16 sectors offset (like 'isohybrid' does).
+	args := []string{"-partition_offset", "16"}
+
+	for i, efiBootImgPath := range efiBootImgPaths {
+		args = append(args, "-append_partition", strconv.Itoa(hybridGptFirstEspPartition+i), espGptPartitionType,
+			filepath.Join(im.buildDirPath, efiBootImgPath))
+	}
+
+	args = append(args, "-appended_part_as_gpt")
+	return args, nil
+}
@@ -0,0 +1,51 @@
+// Copyright Microsoft Corporation.
+// Licensed under the MIT License.
+
+package isomakerlib
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHybridGptArgs(t *testing.T) {
+	isoMaker := &IsoMaker{
+		buildDirPath: "/build",
+		additionalBootArchs: []IsoArchBootFiles{
+			{Arch: IsoArchAarch64},
+		},
+	}
+
+	args, err := isoMaker.hybridGptArgs()
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, []string{
+		"-partition_offset", "16",
+		"-append_partition", "2", espGptPartitionType, "/build/boot/grub2/efiboot.img",
+		"-append_partition", "3", espGptPartitionType, "/build/boot/grub2/efiboot-aarch64.img",
+		"-appended_part_as_gpt",
+	}, args)
+}
+
+func TestHybridGptBackend(t *testing.T) {
+	isoMaker := &IsoMaker{}
+	isoMaker.EnableHybridGpt()
+
+	backend, err := isoMaker.resolveBackend(false /*multiExtentFiles*/)
+	assert.NoError(t, err)
+	assert.Equal(t, IsoBackendXorriso, backend)
+
+	isoMaker.backend = IsoBackendMkisofs
+	_, err = isoMaker.resolveBackend(false /*multiExtentFiles*/)
+	assert.ErrorContains(t, err, "hybrid ISO images require the (xorriso) backend")
+
+	options := validTestIsoMakerOptions()
+	options.HybridGpt = true
+	assert.NoError(t, options.IsValid())
+
+	options.Backend = IsoBackendNative
+	assert.ErrorContains(t, options.IsValid(), "hybrid ISO images require the (xorriso) backend")
+}
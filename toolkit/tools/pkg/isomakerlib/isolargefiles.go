@@ -0,0 +1,76 @@
+// Copyright Microsoft Corporation.
+// Licensed under the MIT License.
+
+package isomakerlib
+
+import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/logger"
+)
+
+const (
+	// The size of the largest file that ISO9660 levels 1 and 2 can store. Larger files (e.g. the rootfs image of a
+	// large LiveOS ISO) must be stored as multiple extents, which requires ISO9660 level 3.
+	isoMaxSingleExtentFileSize = 0xFFFFFFFF
+)
+
+// largestFile returns the path and the size of the largest regular file under a folder.
+func largestFile(dirPath string) (string, int64, error) {
+	largestPath := ""
+	largestSize := int64(0)
+
+	err := filepath.WalkDir(dirPath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		if info.Size() > largestSize {
+			largestPath = path
+			largestSize = info.Size()
+		}
+
+		return nil
+	})
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to find the largest file under (%s):\n%w", dirPath, err)
+	}
+
+	return largestPath, largestSize, nil
+}
+
+// needsMultiExtentFiles returns whether the ISO image contains files that are too large for a single extent.
+//
+// Such files are stored as multiple extents (ISO9660 level 3). 'mkisofs' (genisoimage) doesn't support those, and
+// its UDF bridge alternative ('-udf -allow-limited-size') truncates the files seen through ISO9660, which is what the
+// Linux kernel reads. So, the 'xorriso' or the 'native' backend is required.
+func (im *IsoMaker) needsMultiExtentFiles() (bool, error) {
+	largestPath, largestSize, err := largestFile(im.buildDirPath)
+	if err != nil {
+		return false, err
+	}
+
+	if largestSize <= isoMaxSingleExtentFileSize {
+		return false, nil
+	}
+
+	relativePath, err := filepath.Rel(im.buildDirPath, largestPath)
+	if err != nil {
+		relativePath = largestPath
+	}
+
+	logger.Log.Warnf("ISO file (%s) is larger than 4 GiB (%d bytes), so it is stored as multiple extents (ISO9660 "+
+		"level 3), which some older ISO readers don't support", relativePath, largestSize)
+	return true, nil
+}
@@ -0,0 +1,76 @@
+// Copyright Microsoft Corporation.
+// Licensed under the MIT License.
+
+package isomakerlib
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNeedsMultiExtentFiles(t *testing.T) {
+	buildDir := t.TempDir()
+
+	err := os.MkdirAll(filepath.Join(buildDir, "liveos"), os.ModePerm)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	err = os.WriteFile(filepath.Join(buildDir, "small.txt"), []byte("small"), 0o644)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	isoMaker := &IsoMaker{buildDirPath: buildDir}
+
+	multiExtentFiles, err := isoMaker.needsMultiExtentFiles()
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.False(t, multiExtentFiles)
+
+	// A sparse file, so that the test doesn't need 4 GiB of disk space.
+	rootfsPath := filepath.Join(buildDir, "liveos", "rootfs.img")
+	rootfs, err := os.Create(rootfsPath)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer rootfs.Close()
+
+	err = rootfs.Truncate(isoMaxSingleExtentFileSize + 1)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	largestPath, largestSize, err := largestFile(buildDir)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, rootfsPath, largestPath)
+	assert.Equal(t, int64(isoMaxSingleExtentFileSize+1), largestSize)
+
+	multiExtentFiles, err = isoMaker.needsMultiExtentFiles()
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.True(t, multiExtentFiles)
+}
+
+func TestResolveBackendMultiExtentFiles(t *testing.T) {
+	isoMaker := &IsoMaker{}
+
+	backend, err := isoMaker.resolveBackend(true /*multiExtentFiles*/)
+	assert.NoError(t, err)
+	assert.Equal(t, IsoBackendXorriso, backend)
+
+	isoMaker.backend = IsoBackendMkisofs
+	_, err = isoMaker.resolveBackend(true /*multiExtentFiles*/)
+	assert.ErrorContains(t, err, "ISO files larger than 4 GiB require the (xorriso) or the (native) backend")
+
+	backend, err = isoMaker.resolveBackend(false /*multiExtentFiles*/)
+	assert.NoError(t, err)
+	assert.Equal(t, IsoBackendMkisofs, backend)
+}
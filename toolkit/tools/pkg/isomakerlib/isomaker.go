@@ -6,11 +6,14 @@ package isomakerlib
 import (
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
 	"path"
 	"path/filepath"
 	"runtime"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/cavaliercoder/go-cpio"
 	"github.com/klauspost/pgzip"
@@ -24,6 +27,8 @@ import (
 	"github.com/microsoft/azurelinux/toolkit/tools/internal/safeloopback"
 	"github.com/microsoft/azurelinux/toolkit/tools/internal/safemount"
 	"github.com/microsoft/azurelinux/toolkit/tools/internal/shell"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
 )
 
 const (
@@ -35,29 +40,78 @@ const (
 	defaultImageNameBase            = "azure-linux"
 	defaultOSFilesPath              = "isolinux"
 	repoSnapshotFilePath            = "repo-snapshot-time.txt"
+	bootKernelFile                  = "boot/vmlinuz"
+
+	// The environment variable that reproducible builds use to pass the build timestamp to the tools they run.
+	// See: https://reproducible-builds.org/specs/source-date-epoch/
+	sourceDateEpochEnvVar = "SOURCE_DATE_EPOCH"
+)
+
+// IsoBackend is the tool that generates the ISO image file.
+type IsoBackend string
+
+const (
+	// IsoBackendDefault uses 'mkisofs', or 'xorriso' for reproducible builds, hybrid ISO images and files larger than
+	// 4 GiB.
+	IsoBackendDefault IsoBackend = ""
+	// IsoBackendMkisofs uses 'mkisofs' (genisoimage).
+	IsoBackendMkisofs IsoBackend = "mkisofs"
+	// IsoBackendXorriso uses 'xorriso' in its mkisofs emulation mode. Required for hybrid (e.g. GPT) layouts.
+	IsoBackendXorriso IsoBackend = "xorriso"
+	// IsoBackendNative uses the built-in ISO writer, so that no external tool is needed. Only supports UEFI boot,
+	// without extra backend arguments.
+	IsoBackendNative IsoBackend = "native"
 )
 
+// IsValid returns an error if the backend is not supported.
+func (b IsoBackend) IsValid() error {
+	switch b {
+	case IsoBackendDefault, IsoBackendMkisofs, IsoBackendXorriso, IsoBackendNative:
+		return nil
+
+	default:
+		return fmt.Errorf("invalid ISO backend (%s)", b)
+	}
+}
+
 // IsoMaker builds ISO images and populates them with packages and files required by the installer.
 type IsoMaker struct {
-	enableBiosBoot     bool                    // Flag deciding whether to include BIOS bootloaders or not in the generated ISO image.
-	enableRpmRepo      bool                    // Flag deciding whether to include the contents of the Rpm repo folder in the generated ISO image.
-	unattendedInstall  bool                    // Flag deciding if the installer should run in unattended mode.
-	config             configuration.Config    // Configuration for the built ISO image and its installer.
-	configSubDirNumber int                     // Current number for the subdirectories storing files mentioned in the config.
-	baseDirPath        string                  // Base directory for config's relative paths.
-	buildDirPath       string                  // Path to the temporary build directory.
-	efiBootImgPath     string                  // Path to the efiboot.img file needed to boot the ISO installer.
-	fetchedRepoDirPath string                  // Path to the directory containing an RPM repository with all packages required by the ISO installer.
-	initrdPath         string                  // Path to ISO's initrd file.
-	grubCfgPath        string                  // Path to ISO's grub.cfg file. If provided, overrides the grub.cfg from the resourcesDirPath location.
-	outputDirPath      string                  // Path to the output ISO directory.
-	releaseVersion     string                  // Current Azure Linux release version.
-	resourcesDirPath   string                  // Path to the 'resources' directory.
-	additionalIsoFiles []safechroot.FileToCopy // Additional files to copy to the ISO media (absolute-source-path -> iso-root-relative-path).
-	imageNameBase      string                  // Base name of the ISO to generate (no path, and no file extension).
-	imageNameTag       string                  // Optional user-supplied tag appended to the generated ISO's name.
-	repoSnapshotTime   string                  // tdnf repo snapshot time
-	osFilesPath        string
+	enableBiosBoot      bool                    // Flag deciding whether to include BIOS bootloaders or not in the generated ISO image.
+	enableRpmRepo       bool                    // Flag deciding whether to include the contents of the Rpm repo folder in the generated ISO image.
+	unattendedInstall   bool                    // Flag deciding if the installer should run in unattended mode.
+	config              configuration.Config    // Configuration for the built ISO image and its installer.
+	configSubDirNumber  int                     // Current number for the subdirectories storing files mentioned in the config.
+	baseDirPath         string                  // Base directory for config's relative paths.
+	buildDirPath        string                  // Path to the temporary build directory.
+	efiBootImgPath      string                  // Path to the efiboot.img file needed to boot the ISO installer.
+	fetchedRepoDirPath  string                  // Path to the directory containing an RPM repository with all packages required by the ISO installer.
+	initrdPath          string                  // Path to ISO's initrd file.
+	grubCfgPath         string                  // Path to ISO's grub.cfg file. If provided, overrides the grub.cfg from the resourcesDirPath location.
+	outputDirPath       string                  // Path to the output ISO directory.
+	releaseVersion      string                  // Current Azure Linux release version.
+	resourcesDirPath    string                  // Path to the 'resources' directory.
+	additionalIsoFiles  []safechroot.FileToCopy // Additional files to copy to the ISO media (absolute-source-path -> iso-root-relative-path).
+	imageNameBase       string                  // Base name of the ISO to generate (no path, and no file extension).
+	imageNameTag        string                  // Optional user-supplied tag appended to the generated ISO's name.
+	repoSnapshotTime    string                  // tdnf repo snapshot time
+	osFilesPath         string
+	sourceDateEpoch     *time.Time              // If set, the ISO image is reproducible and this timestamp replaces the current time.
+	efiBootFiles        []safechroot.FileToCopy // Additional files to copy to efiboot.img (absolute-source-path -> efiboot.img-relative-path).
+	skipGrubEfi         bool                    // Flag deciding whether to skip the grub<arch>64.efi bootloader, for first stage bootloaders that don't chain-load grub.
+	backend             IsoBackend              // The tool that generates the ISO image file.
+	extraBackendArgs    []string                // Additional arguments passed as-is to the backend (e.g. '-isohybrid-gpt-basdat').
+	additionalBootArchs []IsoArchBootFiles      // The boot files of the other architectures of a multi-architecture ISO image.
+	rpmRepoGpgKeyPaths  []string                // GPG keys that the RPMs of the ISO RPMs repo must be signed with.
+	bootFiles           *IsoBootFiles           // The boot files on the build machine. If nil, they are extracted from the initrd.
+	efiBootImgSize      uint64                  // Size (in bytes) of the efiboot.img files. If 0, the images are sized to fit their files.
+	efiBootImgLabel     string                  // FAT volume label of the efiboot.img files. If empty, mkdosfs's default is used.
+	hybridGpt           bool                    // Flag deciding whether to add a GPT with the EFI system partitions, for USB sticks (see EnableHybridGpt).
+	zisofsPaths         []string                // ISO paths (files or folders) whose files are transparently compressed (zisofs).
+	zisofsCompressed    bool                    // Whether any file was compressed (see compressZisofsPaths).
+	dryRun              bool                    // Flag deciding whether to only stage the ISO files (see Manifest), without generating the ISO image.
+	progressCallback    IsoMakerProgressFunc    // Called when a stage of Make starts.
+	progressStep        int                     // Current stage number of Make.
+	manifest            []IsoManifestEntry      // Files and folders of the last generated ISO image.
 
 	isoMakerCleanUpTasks []func() error // List of clean-up tasks to perform at the end of the ISO generation process.
 }
@@ -100,42 +154,72 @@ func NewIsoMaker(unattendedInstall bool, baseDirPath, buildDirPath, releaseVersi
 	return isoMaker, nil
 }
 
+// NewIsoMakerWithConfig returns a new ISO maker for an already loaded config.
+//
+// Deprecated: use NewIsoMakerWithOptions, which can take new inputs without breaking its callers.
 func NewIsoMakerWithConfig(unattendedInstall, enableBiosBoot, enableRpmRepo bool, baseDirPath, buildDirPath, releaseVersion, resourcesDirPath string, additionalIsoFiles []safechroot.FileToCopy, config configuration.Config, osFilesPath, initrdPath, grubCfgPath, isoRepoDirPath, outputDir, imageNameBase, imageNameTag string) (isoMaker *IsoMaker, err error) {
+	return NewIsoMakerWithOptions(IsoMakerOptions{
+		UnattendedInstall:  unattendedInstall,
+		EnableBiosBoot:     enableBiosBoot,
+		EnableRpmRepo:      enableRpmRepo,
+		Config:             config,
+		BaseDirPath:        baseDirPath,
+		BuildDirPath:       buildDirPath,
+		ReleaseVersion:     releaseVersion,
+		ResourcesDirPath:   resourcesDirPath,
+		AdditionalIsoFiles: additionalIsoFiles,
+		OsFilesPath:        osFilesPath,
+		InitrdPath:         initrdPath,
+		GrubCfgPath:        grubCfgPath,
+		IsoRepoDirPath:     isoRepoDirPath,
+		OutputDir:          outputDir,
+		ImageNameBase:      imageNameBase,
+		ImageNameTag:       imageNameTag,
+	})
+}
 
-	if imageNameBase == "" {
-		imageNameBase = defaultImageNameBase
-	}
+// EnableReproducibleBuild makes builds from the same inputs generate byte-identical ISO images. The timestamps of the
+// ISO image (and of the files on it) are set to 'sourceDateEpoch' instead of the current time.
+//
+// Requires the 'xorriso' (see IsoBackendXorriso) or the 'native' backend.
+func (im *IsoMaker) EnableReproducibleBuild(sourceDateEpoch time.Time) {
+	im.sourceDateEpoch = &sourceDateEpoch
+}
 
-	if osFilesPath == "" {
-		osFilesPath = defaultOSFilesPath
-	}
+// SetEfiBootFiles sets the files to copy to the EFI system partition (efiboot.img) of the ISO image, in addition to
+// the shim and grub binaries. For example, extra EFI drivers, the MOK manager (mmx64.efi), an EFI shell or vendor
+// diagnostics.
+//
+// The efiboot.img is grown to fit the files. Files under EFI/BOOT are also copied to the efi/boot folder of the ISO
+// media (see applyRufusWorkaround).
+func (im *IsoMaker) SetEfiBootFiles(efiBootFiles []safechroot.FileToCopy) {
+	im.efiBootFiles = efiBootFiles
+}
 
-	err = verifyConfig(config, unattendedInstall)
+// SetBackend sets the tool that generates the ISO image file.
+func (im *IsoMaker) SetBackend(backend IsoBackend) error {
+	err := backend.IsValid()
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	isoMaker = &IsoMaker{
-		enableBiosBoot:     enableBiosBoot,
-		enableRpmRepo:      enableRpmRepo,
-		unattendedInstall:  unattendedInstall,
-		config:             config,
-		baseDirPath:        baseDirPath,
-		buildDirPath:       buildDirPath,
-		initrdPath:         initrdPath,
-		grubCfgPath:        grubCfgPath,
-		releaseVersion:     releaseVersion,
-		resourcesDirPath:   resourcesDirPath,
-		additionalIsoFiles: additionalIsoFiles,
-		fetchedRepoDirPath: isoRepoDirPath,
-		outputDirPath:      outputDir,
-		imageNameBase:      imageNameBase,
-		imageNameTag:       imageNameTag,
-		osFilesPath:        osFilesPath,
-		repoSnapshotTime:   "",
-	}
+	im.backend = backend
+	return nil
+}
 
-	return isoMaker, nil
+// SetExtraBackendArgs sets additional arguments to pass as-is to the backend, for advanced layouts that the ISO maker
+// doesn't generate itself. For example, '-isohybrid-gpt-basdat' or '-partition_offset 16' with the 'xorriso' backend.
+//
+// The arguments are placed after the arguments generated by the ISO maker, and before the input directory. Paths in
+// them must be relative to the input directory.
+func (im *IsoMaker) SetExtraBackendArgs(extraBackendArgs []string) {
+	im.extraBackendArgs = extraBackendArgs
+}
+
+// SkipGrubEfiBootloader makes the ISO image boot only the first stage bootloader (boot<arch>64.efi) from the initrd,
+// without the grub<arch>64.efi bootloader. For example, when the first stage bootloader is systemd-boot.
+func (im *IsoMaker) SkipGrubEfiBootloader() {
+	im.skipGrubEfi = true
 }
 
 // Make builds the ISO image to 'buildDirPath' with the packages included in the config JSON.
@@ -156,21 +240,46 @@ func (im *IsoMaker) Make() (err error) {
 		return err
 	}
 
+	im.progressStep = 0
+	im.manifest = nil
+
+	im.reportProgress(IsoMakerStagePrepareWorkDir)
 	err = im.prepareWorkDirectory()
 	if err != nil {
 		return err
 	}
 
+	im.reportProgress(IsoMakerStageCreateRpmRepo)
 	err = im.createIsoRpmsRepo()
 	if err != nil {
 		return err
 	}
 
+	im.reportProgress(IsoMakerStagePrepareBootloaders)
 	err = im.prepareIsoBootLoaderFilesAndFolders()
 	if err != nil {
 		return err
 	}
 
+	im.zisofsCompressed, err = im.compressZisofsPaths()
+	if err != nil {
+		return err
+	}
+
+	im.manifest, err = buildIsoManifest(im.buildDirPath)
+	if err != nil {
+		return err
+	}
+
+	if im.dryRun {
+		logIsoManifest(im.manifest, logrus.InfoLevel)
+		logger.Log.Infof("Dry run: skipping the generation of '%s'.", im.buildIsoImageFilePath())
+		return nil
+	}
+
+	logIsoManifest(im.manifest, logrus.DebugLevel)
+
+	im.reportProgress(IsoMakerStageBuildIsoImage)
 	err = im.buildIsoImage()
 	if err != nil {
 		return err
@@ -184,6 +293,26 @@ func (im *IsoMaker) buildIsoImage() error {
 
 	logger.Log.Infof("Generating ISO image under '%s'.", isoImageFilePath)
 
+	multiExtentFiles, err := im.needsMultiExtentFiles()
+	if err != nil {
+		return err
+	}
+
+	if im.backend == IsoBackendNative {
+		if im.enableBiosBoot {
+			return fmt.Errorf("the (%s) ISO backend doesn't support BIOS boot", IsoBackendNative)
+		}
+		if len(im.extraBackendArgs) > 0 {
+			return fmt.Errorf("the (%s) ISO backend doesn't support extra backend arguments", IsoBackendNative)
+		}
+		if im.hybridGpt {
+			return fmt.Errorf("hybrid ISO images require the (%s) backend", IsoBackendXorriso)
+		}
+
+		return writeNativeIsoImage(im.buildDirPath, im.efiBootImgPathsRelativeToIsoRoot(), DefaultVolumeId,
+			im.sourceDateEpoch, im.zisofsCompressed, isoImageFilePath)
+	}
+
 	// For detailed parameter explanation see: https://linux.die.net/man/8/mkisofs.
 	// Mkisofs requires all argument paths to be relative to the input directory.
 	mkisofsArgs := []string{}
@@ -198,15 +327,128 @@ func (im *IsoMaker) buildIsoImage() error {
 			"-b", filepath.Join(im.osFilesPath, "isolinux.bin"), "-c", filepath.Join(im.osFilesPath, "boot.cat"), "-no-emul-boot", "-boot-load-size", "4", "-boot-info-table")
 	}
 
-	mkisofsArgs = append(mkisofsArgs,
-		// UEFI bootloader.
-		"-eltorito-alt-boot", "-e", efiBootImgPathRelativeToIsoRoot, "-no-emul-boot",
+	// UEFI bootloaders (one per architecture).
+	for _, efiBootImgPath := range im.efiBootImgPathsRelativeToIsoRoot() {
+		mkisofsArgs = append(mkisofsArgs,
+			"-eltorito-alt-boot", "-e", efiBootImgPath, "-no-emul-boot")
+	}
+
+	if multiExtentFiles {
+		// Files larger than 4 GiB.
+		mkisofsArgs = append(mkisofsArgs, "-iso-level", "3")
+	}
+
+	if im.zisofsCompressed {
+		// Transparently compressed files (see compressZisofsPaths).
+		mkisofsArgs = append(mkisofsArgs, "-z")
+	}
+
+	if im.hybridGpt {
+		hybridGptArgs, err := im.hybridGptArgs()
+		if err != nil {
+			return err
+		}
+		mkisofsArgs = append(mkisofsArgs, hybridGptArgs...)
+	}
+
+	mkisofsArgs = append(mkisofsArgs, im.extraBackendArgs...)
+
+	// Directory to convert to an ISO.
+	mkisofsArgs = append(mkisofsArgs, im.buildDirPath)
+
+	backend, err := im.resolveBackend(multiExtentFiles)
+	if err != nil {
+		return err
+	}
+
+	if backend == IsoBackendMkisofs {
+		// Note: mkisofs has a noisy stderr.
+		return shell.ExecuteLive(true /*squashErrors*/, "mkisofs", mkisofsArgs...)
+	}
+
+	var env []string
+	if im.sourceDateEpoch != nil {
+		// The files on the ISO keep their modification times.
+		err = setFileTimes(im.buildDirPath, *im.sourceDateEpoch)
+		if err != nil {
+			return err
+		}
 
-		// Directory to convert to an ISO.
-		im.buildDirPath)
+		// Unlike mkisofs (genisoimage), xorriso uses SOURCE_DATE_EPOCH for the volume timestamps and UUID instead of
+		// the current time.
+		env = SourceDateEpochEnvironment(*im.sourceDateEpoch)
+	}
 
-	// Note: mkisofs has a noisy stderr.
-	return shell.ExecuteLive(true /*squashErrors*/, "mkisofs", mkisofsArgs...)
+	xorrisoArgs := append([]string{"-as", "mkisofs"}, mkisofsArgs...)
+	return shell.NewExecBuilder("xorriso", xorrisoArgs...).
+		EnvironmentVariables(env).
+		LogLevel(logrus.DebugLevel, logrus.DebugLevel).
+		Execute()
+}
+
+// resolveBackend returns the backend that generates the ISO image file.
+//
+// 'multiExtentFiles' is whether the ISO image contains files larger than 4 GiB (see needsMultiExtentFiles).
+func (im *IsoMaker) resolveBackend(multiExtentFiles bool) (IsoBackend, error) {
+	switch im.backend {
+	case IsoBackendDefault:
+		if im.sourceDateEpoch != nil || multiExtentFiles || im.hybridGpt {
+			return IsoBackendXorriso, nil
+		}
+		return IsoBackendMkisofs, nil
+
+	case IsoBackendMkisofs:
+		if im.sourceDateEpoch != nil {
+			return "", fmt.Errorf("reproducible ISO builds require the (%s) or the (%s) backend", IsoBackendXorriso,
+				IsoBackendNative)
+		}
+		if multiExtentFiles {
+			return "", fmt.Errorf("ISO files larger than 4 GiB require the (%s) or the (%s) backend",
+				IsoBackendXorriso, IsoBackendNative)
+		}
+		if im.hybridGpt {
+			return "", fmt.Errorf("hybrid ISO images require the (%s) backend", IsoBackendXorriso)
+		}
+		return IsoBackendMkisofs, nil
+
+	default:
+		return im.backend, nil
+	}
+}
+
+// SourceDateEpochEnvironment returns the environment of the tools run by a reproducible build. That is, the current
+// environment with SOURCE_DATE_EPOCH set to 'sourceDateEpoch'.
+func SourceDateEpochEnvironment(sourceDateEpoch time.Time) []string {
+	env := []string(nil)
+	for _, envVar := range shell.CurrentEnvironment() {
+		if !strings.HasPrefix(envVar, sourceDateEpochEnvVar+"=") {
+			env = append(env, envVar)
+		}
+	}
+
+	return append(env, fmt.Sprintf("%s=%d", sourceDateEpochEnvVar, sourceDateEpoch.Unix()))
+}
+
+// setFileTimes sets the access and modification times of all the files and directories under 'rootDir' (including
+// 'rootDir' itself). Symlinks are not followed.
+func setFileTimes(rootDir string, fileTime time.Time) error {
+	times := []unix.Timespec{
+		unix.NsecToTimespec(fileTime.UnixNano()),
+		unix.NsecToTimespec(fileTime.UnixNano()),
+	}
+
+	err := filepath.WalkDir(rootDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		return unix.UtimesNanoAt(unix.AT_FDCWD, path, times, unix.AT_SYMLINK_NOFOLLOW)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to set file times under (%s):\n%w", rootDir, err)
+	}
+
+	return nil
 }
 
 // prepareIsoBootLoaderFilesAndFolders copies the files required by the ISO's bootloader
@@ -226,6 +468,11 @@ func (im *IsoMaker) prepareIsoBootLoaderFilesAndFolders() (err error) {
 		return err
 	}
 
+	err = im.setUpAdditionalBootArchs()
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -241,20 +488,48 @@ func (im *IsoMaker) copyInitrd() error {
 // setUpIsoGrub2BootLoader prepares an efiboot.img containing Grub2,
 // which is booted in case of an UEFI boot of the ISO image.
 func (im *IsoMaker) setUpIsoGrub2Bootloader() (err error) {
+	logger.Log.Info("Preparing ISO's bootloaders.")
+
+	return im.createEfiBootImg(im.efiBootImgPath, im.bootFilesSource(), isoBootArchInfos[hostIsoBootArch()],
+		im.efiBootFiles)
+}
+
+// createEfiBootImg creates an EFI system partition image with the shim and grub binaries of an architecture.
+func (im *IsoMaker) createEfiBootImg(efiBootImgPath string, bootFilesSource isoBootFilesSource, archInfo isoBootArchInfo,
+	efiBootFiles []safechroot.FileToCopy,
+) (err error) {
 	const (
-		blockSizeInBytes     = 1024 * 1024
-		numberOfBlocksToCopy = 3
+		blockSizeInBytes = 1024 * 1024
+		// Fits the shim and grub binaries.
+		defaultNumberOfBlocks = 3
 	)
 
-	logger.Log.Info("Preparing ISO's bootloaders.")
+	efiBootFilesSize, err := getFilesSize(efiBootFiles)
+	if err != nil {
+		return err
+	}
+
+	numberOfBlocksToCopy := defaultNumberOfBlocks
+	if im.efiBootImgSize != 0 {
+		if uint64(efiBootFilesSize) >= im.efiBootImgSize {
+			return fmt.Errorf("efiboot.img size (%d bytes) is too small for the additional EFI files (%d bytes)",
+				im.efiBootImgSize, efiBootFilesSize)
+		}
+
+		// Round up to whole blocks.
+		numberOfBlocksToCopy = int((im.efiBootImgSize + blockSizeInBytes - 1) / blockSizeInBytes)
+	} else if efiBootFilesSize > 0 {
+		// Round up, and leave one block for the file system's metadata (directories, FAT tables, etc.).
+		numberOfBlocksToCopy += int((efiBootFilesSize+blockSizeInBytes-1)/blockSizeInBytes) + 1
+	}
 
 	ddArgs := []string{
 		"if=/dev/zero",                                // Zero device to read a stream of zeroed bytes from.
-		fmt.Sprintf("of=%s", im.efiBootImgPath),       // Output file.
+		fmt.Sprintf("of=%s", efiBootImgPath),          // Output file.
 		fmt.Sprintf("bs=%d", blockSizeInBytes),        // Size of one copied block. Used together with "count".
 		fmt.Sprintf("count=%d", numberOfBlocksToCopy), // Number of blocks to copy to the output file.
 	}
-	logger.Log.Debugf("Creating an empty '%s' file of %d bytes.", im.efiBootImgPath, blockSizeInBytes*numberOfBlocksToCopy)
+	logger.Log.Debugf("Creating an empty '%s' file of %d bytes.", efiBootImgPath, blockSizeInBytes*numberOfBlocksToCopy)
 
 	// Note: dd has a noisy stderr.
 	err = shell.ExecuteLive(true /*squashErrors*/, "dd", ddArgs...)
@@ -262,37 +537,51 @@ func (im *IsoMaker) setUpIsoGrub2Bootloader() (err error) {
 		return err
 	}
 
-	logger.Log.Debugf("Formatting '%s' as an MS-DOS filesystem.", im.efiBootImgPath)
-	err = shell.ExecuteLive(false /*squashErrors*/, "mkdosfs", im.efiBootImgPath)
+	mkdosfsArgs := []string{efiBootImgPath}
+	if im.efiBootImgLabel != "" {
+		mkdosfsArgs = append([]string{"-n", im.efiBootImgLabel}, mkdosfsArgs...)
+	}
+	if im.sourceDateEpoch != nil {
+		// Use a fixed volume ID and creation time, instead of random and current ones.
+		mkdosfsArgs = append([]string{"--invariant"}, mkdosfsArgs...)
+	}
+
+	logger.Log.Debugf("Formatting '%s' as an MS-DOS filesystem.", efiBootImgPath)
+	err = shell.ExecuteLive(false /*squashErrors*/, "mkdosfs", mkdosfsArgs...)
 	if err != nil {
 		return err
 	}
 
 	efiBootImgTempMountDir := filepath.Join(im.buildDirPath, "efiboot_temp")
 
-	logger.Log.Debugf("Mounting '%s' to '%s' to copy EFI modules required to boot grub2.", im.efiBootImgPath, efiBootImgTempMountDir)
-	loopback, err := safeloopback.NewLoopback(im.efiBootImgPath)
+	logger.Log.Debugf("Mounting '%s' to '%s' to copy EFI modules required to boot grub2.", efiBootImgPath, efiBootImgTempMountDir)
+	loopback, err := safeloopback.NewLoopback(efiBootImgPath)
 	if err != nil {
-		return fmt.Errorf("failed to connect efiboot.img:\n%w", err)
+		return fmt.Errorf("failed to connect (%s):\n%w", efiBootImgPath, err)
 	}
 	defer loopback.Close()
 
 	mount, err := safemount.NewMount(loopback.DevicePath(), efiBootImgTempMountDir, "vfat", 0, "",
 		true /*makeAndDeleteDir*/)
 	if err != nil {
-		return fmt.Errorf("failed to mount efiboot.img:\n%w", err)
+		return fmt.Errorf("failed to mount (%s):\n%w", efiBootImgPath, err)
 	}
 	defer mount.Close()
 
 	logger.Log.Debug("Copying EFI modules into efiboot.img.")
 	// Copy Shim (boot<arch>64.efi) and grub2 (grub<arch>64.efi)
-	if runtime.GOARCH == "arm64" {
-		err = im.copyShimFromInitrd(efiBootImgTempMountDir, "bootaa64.efi", "grubaa64.efi")
-		if err != nil {
-			return err
-		}
-	} else {
-		err = im.copyShimFromInitrd(efiBootImgTempMountDir, "bootx64.efi", "grubx64.efi")
+	err = im.copyEfiBootloaders(efiBootImgTempMountDir, bootFilesSource, archInfo)
+	if err != nil {
+		return err
+	}
+
+	err = im.copyEfiBootFiles(efiBootImgTempMountDir, efiBootFiles)
+	if err != nil {
+		return err
+	}
+
+	if im.sourceDateEpoch != nil {
+		err = setFileTimes(efiBootImgTempMountDir, *im.sourceDateEpoch)
 		if err != nil {
 			return err
 		}
@@ -300,35 +589,37 @@ func (im *IsoMaker) setUpIsoGrub2Bootloader() (err error) {
 
 	err = mount.CleanClose()
 	if err != nil {
-		return fmt.Errorf("failed to unmount efiboot.img:\n%w", err)
+		return fmt.Errorf("failed to unmount (%s):\n%w", efiBootImgPath, err)
 	}
 
 	err = loopback.CleanClose()
 	if err != nil {
-		return fmt.Errorf("failed to disconnect efiboot.img:\n%w", err)
+		return fmt.Errorf("failed to disconnect (%s):\n%w", efiBootImgPath, err)
 	}
 
 	return nil
 }
 
-func (im *IsoMaker) copyShimFromInitrd(efiBootImgTempMountDir, bootBootloaderFile, grubBootloaderFile string) (err error) {
+func (im *IsoMaker) copyEfiBootloaders(efiBootImgTempMountDir string, bootFilesSource isoBootFilesSource,
+	archInfo isoBootArchInfo,
+) (err error) {
 	bootDirPath := filepath.Join(efiBootImgTempMountDir, "EFI", "BOOT")
 
-	initrdBootBootloaderFilePath := filepath.Join(initrdEFIBootDirectoryPath, bootBootloaderFile)
-	buildDirBootEFIFilePath := filepath.Join(bootDirPath, bootBootloaderFile)
-	err = im.extractFromInitrdAndCopy(initrdBootBootloaderFilePath, buildDirBootEFIFilePath)
+	buildDirBootEFIFilePath := filepath.Join(bootDirPath, archInfo.bootBootloaderFile)
+	err = bootFilesSource.copyBootBootloader(archInfo, buildDirBootEFIFilePath)
 	if err != nil {
 		return err
 	}
 
-	initrdGrubBootloaderFilePath := filepath.Join(initrdEFIBootDirectoryPath, grubBootloaderFile)
-	buildDirGrubEFIFilePath := filepath.Join(bootDirPath, grubBootloaderFile)
-	err = im.extractFromInitrdAndCopy(initrdGrubBootloaderFilePath, buildDirGrubEFIFilePath)
-	if err != nil {
-		return err
+	if !im.skipGrubEfi {
+		buildDirGrubEFIFilePath := filepath.Join(bootDirPath, archInfo.grubBootloaderFile)
+		err = bootFilesSource.copyGrubBootloader(archInfo, buildDirGrubEFIFilePath)
+		if err != nil {
+			return err
+		}
 	}
 
-	err = im.applyRufusWorkaround(bootBootloaderFile, grubBootloaderFile)
+	err = im.applyRufusWorkaround(bootFilesSource, archInfo)
 	if err != nil {
 		return err
 	}
@@ -347,19 +638,23 @@ func (im *IsoMaker) copyShimFromInitrd(efiBootImgTempMountDir, bootBootloaderFil
 // Rufus prioritizes the presence of an EFI folder on the ISO disk over extraction of the efi*.img archive.
 // So to workaround the limitation, create an EFI folder and make a duplicate copy of the bootloader files
 // in EFI/Boot so Rufus doesn't attempt to extract the efi*.img in the first place.
-func (im *IsoMaker) applyRufusWorkaround(bootBootloaderFile, grubBootloaderFile string) (err error) {
+func (im *IsoMaker) applyRufusWorkaround(bootFilesSource isoBootFilesSource, archInfo isoBootArchInfo) (err error) {
 	const buildDirBootEFIDirectoryPath = "efi/boot"
 
-	initrdBootloaderFilePath := filepath.Join(initrdEFIBootDirectoryPath, bootBootloaderFile)
-	buildDirBootEFIUsbFilePath := filepath.Join(im.buildDirPath, buildDirBootEFIDirectoryPath, bootBootloaderFile)
-	err = im.extractFromInitrdAndCopy(initrdBootloaderFilePath, buildDirBootEFIUsbFilePath)
+	buildDirBootEFIUsbFilePath := filepath.Join(im.buildDirPath, buildDirBootEFIDirectoryPath,
+		archInfo.bootBootloaderFile)
+	err = bootFilesSource.copyBootBootloader(archInfo, buildDirBootEFIUsbFilePath)
 	if err != nil {
 		return err
 	}
 
-	initrdGrubEFIFilePath := filepath.Join(initrdEFIBootDirectoryPath, grubBootloaderFile)
-	buildDirGrubEFIUsbFilePath := filepath.Join(im.buildDirPath, buildDirBootEFIDirectoryPath, grubBootloaderFile)
-	err = im.extractFromInitrdAndCopy(initrdGrubEFIFilePath, buildDirGrubEFIUsbFilePath)
+	if im.skipGrubEfi {
+		return nil
+	}
+
+	buildDirGrubEFIUsbFilePath := filepath.Join(im.buildDirPath, buildDirBootEFIDirectoryPath,
+		archInfo.grubBootloaderFile)
+	err = bootFilesSource.copyGrubBootloader(archInfo, buildDirGrubEFIUsbFilePath)
 	if err != nil {
 		return err
 	}
@@ -367,17 +662,94 @@ func (im *IsoMaker) applyRufusWorkaround(bootBootloaderFile, grubBootloaderFile
 	return nil
 }
 
+// copyEfiBootFiles copies the user-specified EFI files to the mounted efiboot.img. The ones under EFI/BOOT are also
+// copied to the efi/boot folder of the ISO media, for the same reason as applyRufusWorkaround.
+func (im *IsoMaker) copyEfiBootFiles(efiBootImgTempMountDir string, efiBootFiles []safechroot.FileToCopy) error {
+	if len(efiBootFiles) == 0 {
+		return nil
+	}
+
+	logger.Log.Debug("Copying additional EFI files into efiboot.img.")
+
+	err := safechroot.AddFilesToDestination(efiBootImgTempMountDir, efiBootFiles...)
+	if err != nil {
+		return fmt.Errorf("failed to copy additional EFI files into efiboot.img:\n%w", err)
+	}
+
+	const (
+		efiBootImgBootDirectoryPath  = "EFI/BOOT/"
+		buildDirBootEFIDirectoryPath = "efi/boot/"
+	)
+
+	for _, efiBootFile := range efiBootFiles {
+		dest := strings.TrimPrefix(efiBootFile.Dest, "/")
+		if len(dest) <= len(efiBootImgBootDirectoryPath) ||
+			!strings.EqualFold(dest[:len(efiBootImgBootDirectoryPath)], efiBootImgBootDirectoryPath) {
+			continue
+		}
+
+		usbFile := efiBootFile
+		usbFile.Dest = buildDirBootEFIDirectoryPath + dest[len(efiBootImgBootDirectoryPath):]
+
+		err = safechroot.AddFilesToDestination(im.buildDirPath, usbFile)
+		if err != nil {
+			return fmt.Errorf("failed to copy additional EFI files to the iso media:\n%w", err)
+		}
+	}
+
+	return nil
+}
+
+// validateEfiBootImgLabel returns an error if the label is not a valid FAT volume label.
+func validateEfiBootImgLabel(label string) error {
+	const (
+		maxLabelLength = 11
+		// Characters that FAT doesn't allow in short names, and so in volume labels.
+		invalidLabelChars = "\"*+,./:;<=>?[\\]|"
+	)
+
+	if len(label) > maxLabelLength {
+		return fmt.Errorf("invalid efiboot.img label (%s): must be at most %d characters long", label, maxLabelLength)
+	}
+
+	for _, c := range label {
+		if c < ' ' || c > '~' || strings.ContainsRune(invalidLabelChars, c) {
+			return fmt.Errorf("invalid efiboot.img label (%s): invalid character (%q)", label, c)
+		}
+	}
+
+	return nil
+}
+
+// getFilesSize returns the total size of the specified files.
+func getFilesSize(files []safechroot.FileToCopy) (int64, error) {
+	totalSize := int64(0)
+	for _, f := range files {
+		if f.Content != nil {
+			totalSize += int64(len(*f.Content))
+			continue
+		}
+
+		fileInfo, err := os.Stat(f.Src)
+		if err != nil {
+			return 0, fmt.Errorf("failed to stat (%s):\n%w", f.Src, err)
+		}
+		totalSize += fileInfo.Size()
+	}
+
+	return totalSize, nil
+}
+
 // createVmlinuzImage builds the 'vmlinuz' file containing the Linux kernel
 // ran by the ISO bootloader.
 func (im *IsoMaker) createVmlinuzImage() error {
-	const bootKernelFile = "boot/vmlinuz"
-
 	vmlinuzFilePath := filepath.Join(im.buildDirPath, im.osFilesPath, "vmlinuz")
 
-	// In order to select the correct kernel for isolinux, open the initrd archive
-	// and extract the vmlinuz file in it. An initrd is a gzip of a cpio archive.
+	// Unless the kernel is specified (see IsoBootFiles), in order to select the
+	// correct kernel for isolinux, open the initrd archive and extract the
+	// vmlinuz file in it. An initrd is a gzip of a cpio archive.
 	//
-	return im.extractFromInitrdAndCopy(bootKernelFile, vmlinuzFilePath)
+	return im.bootFilesSource().copyKernel(vmlinuzFilePath)
 }
 
 // createIsoRpmsRepo initializes the RPMs repo on the ISO image
@@ -402,6 +774,11 @@ func (im *IsoMaker) createIsoRpmsRepo() (err error) {
 		return err
 	}
 
+	err = im.finalizeIsoRpmsRepo(isoRpmsRepoDirPath)
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -572,9 +949,58 @@ func (im *IsoMaker) copyAndRenameConfigFiles() (err error) {
 // copyIsoAdditionalFiles copies user-specified files to the iso media. Such
 // files can be used by custom initrd/LiveOS images that will look for them
 // on the iso media.
+//
+// The files keep their mode (or get their 'Permissions') and their owner, which
+// the ISO backends record as Rock Ridge attributes.
 func (im *IsoMaker) copyIsoAdditionalFiles() (err error) {
 	logger.Log.Debugf("Copying ISO additional files")
-	return safechroot.AddFilesToDestination(im.buildDirPath, im.additionalIsoFiles...)
+
+	for _, additionalIsoFile := range im.additionalIsoFiles {
+		destPath := filepath.Join(im.buildDirPath, additionalIsoFile.Dest)
+		err = os.MkdirAll(filepath.Dir(destPath), os.ModePerm)
+		if err != nil {
+			return fmt.Errorf("failed to create folder for ISO file (%s):\n%w", additionalIsoFile.Dest, err)
+		}
+
+		err = safechroot.AddFilesToDestination(im.buildDirPath, additionalIsoFile)
+		if err != nil {
+			return err
+		}
+
+		if additionalIsoFile.Src != "" {
+			err = copyFileOwner(additionalIsoFile.Src, destPath)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// copyFileOwner sets the owner of a copied file to the owner of its source. Only root can give away files, so this
+// is a no-op for other users.
+func copyFileOwner(srcPath string, destPath string) error {
+	if os.Geteuid() != 0 {
+		return nil
+	}
+
+	srcInfo, err := os.Lstat(srcPath)
+	if err != nil {
+		return fmt.Errorf("failed to stat (%s):\n%w", srcPath, err)
+	}
+
+	stat, ok := srcInfo.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil
+	}
+
+	err = os.Lchown(destPath, int(stat.Uid), int(stat.Gid))
+	if err != nil {
+		return fmt.Errorf("failed to set the owner of (%s):\n%w", destPath, err)
+	}
+
+	return nil
 }
 
 func (im *IsoMaker) addSnapshotTimeFile(configFilesAbsDirPath string) (err error) {
@@ -865,12 +1291,13 @@ func recursiveCopyDereferencingLinks(source string, target string) (err error) {
 	return nil
 }
 
-func (im *IsoMaker) extractFromInitrdAndCopy(srcFileName, destFilePath string) (err error) {
+// extractFileFromInitrd copies a file of an initrd to 'destFilePath'.
+func extractFileFromInitrd(initrdPath, srcFileName, destFilePath string) (err error) {
 	// Setup a series of io readers: initrd file -> parallelized gzip -> cpio
 
-	logger.Log.Debugf("Searching for (%s) in initrd (%s) and copying to (%s)", srcFileName, im.initrdPath, destFilePath)
+	logger.Log.Debugf("Searching for (%s) in initrd (%s) and copying to (%s)", srcFileName, initrdPath, destFilePath)
 
-	initrdFile, err := os.Open(im.initrdPath)
+	initrdFile, err := os.Open(initrdPath)
 	if err != nil {
 		return err
 	}
@@ -887,7 +1314,7 @@ func (im *IsoMaker) extractFromInitrdAndCopy(srcFileName, destFilePath string) (
 		var hdr *cpio.Header
 		hdr, err = cpioReader.Next()
 		if err == io.EOF {
-			return fmt.Errorf("did not find (%s) in initrd (%s)", srcFileName, im.initrdPath)
+			return fmt.Errorf("did not find (%s) in initrd (%s)", srcFileName, initrdPath)
 		}
 		if err != nil {
 			return err
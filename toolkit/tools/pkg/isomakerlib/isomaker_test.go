@@ -0,0 +1,104 @@
+// Copyright Microsoft Corporation.
+// Licensed under the MIT License.
+
+package isomakerlib
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/safechroot"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdditionalIsoFilesRockRidgeAttributes(t *testing.T) {
+	const (
+		testUid = 1234
+		testGid = 5678
+	)
+
+	testDir := t.TempDir()
+	buildDir := filepath.Join(testDir, "iso-root")
+
+	scriptPath := filepath.Join(testDir, "install.sh")
+	err := os.WriteFile(scriptPath, []byte("#!/bin/sh\n"), 0o750)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	isRoot := os.Geteuid() == 0
+	if isRoot {
+		err = os.Chown(scriptPath, testUid, testGid)
+		if !assert.NoError(t, err) {
+			return
+		}
+	}
+
+	content := "key=value\n"
+	contentPermissions := os.FileMode(0o600)
+	isoMaker := &IsoMaker{
+		buildDirPath: buildDir,
+		additionalIsoFiles: []safechroot.FileToCopy{
+			{Src: scriptPath, Dest: "scripts/install.sh"},
+			{Content: &content, Dest: "config/settings.conf", Permissions: &contentPermissions},
+		},
+	}
+
+	err = isoMaker.copyIsoAdditionalFiles()
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	err = os.MkdirAll(filepath.Join(buildDir, filepath.Dir(efiBootImgPathRelativeToIsoRoot)), os.ModePerm)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	err = os.WriteFile(filepath.Join(buildDir, efiBootImgPathRelativeToIsoRoot), []byte("efi"), 0o644)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	isoImagePath := filepath.Join(testDir, "test.iso")
+	err = writeNativeIsoImage(buildDir, []string{efiBootImgPathRelativeToIsoRoot}, DefaultVolumeId, nil,
+		false /*zisofs*/, isoImagePath)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	image, err := os.ReadFile(isoImagePath)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// The Rock Ridge 'PX' entry of a file precedes the 'NM' entry with its name.
+	rockRidgeAttributes := func(name string) (mode uint32, uid uint32, gid uint32) {
+		nmEntry := append([]byte{'N', 'M', byte(5 + len(name)), 1, 0}, name...)
+		nmIndex := bytes.Index(image, nmEntry)
+		if !assert.GreaterOrEqual(t, nmIndex, 0, name) {
+			return 0, 0, 0
+		}
+
+		pxIndex := bytes.LastIndex(image[:nmIndex], []byte{'P', 'X', 36, 1})
+		if !assert.GreaterOrEqual(t, pxIndex, 0, name) {
+			return 0, 0, 0
+		}
+
+		px := image[pxIndex:]
+		return binary.LittleEndian.Uint32(px[4:]), binary.LittleEndian.Uint32(px[20:]),
+			binary.LittleEndian.Uint32(px[28:])
+	}
+
+	mode, uid, gid := rockRidgeAttributes("install.sh")
+	assert.Equal(t, uint32(0o100750), mode)
+	if isRoot {
+		assert.Equal(t, uint32(testUid), uid)
+		assert.Equal(t, uint32(testGid), gid)
+	}
+
+	mode, _, _ = rockRidgeAttributes("settings.conf")
+	assert.Equal(t, uint32(0o100600), mode)
+}
@@ -0,0 +1,203 @@
+// Copyright Microsoft Corporation.
+// Licensed under the MIT License.
+
+package isomakerlib
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/imagegen/configuration"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/safechroot"
+)
+
+// IsoMakerOptions holds the inputs of an ISO maker created with NewIsoMakerWithOptions. New inputs are added as new
+// fields, so that the zero value keeps the previous behavior and existing callers keep building.
+type IsoMakerOptions struct {
+	UnattendedInstall  bool                    // Whether the installer should run in unattended mode.
+	EnableBiosBoot     bool                    // Whether to include the BIOS bootloaders in the ISO image.
+	EnableRpmRepo      bool                    // Whether to include the RPM repo folder (with regenerated metadata) in the ISO image.
+	Config             configuration.Config    // Configuration for the built ISO image and its installer.
+	BaseDirPath        string                  // Base directory for config's relative paths.
+	BuildDirPath       string                  // Path to the temporary build directory. Required.
+	ReleaseVersion     string                  // Current Azure Linux release version.
+	ResourcesDirPath   string                  // Path to the 'resources' directory.
+	AdditionalIsoFiles []safechroot.FileToCopy // Additional files to copy to the ISO media.
+	OsFilesPath        string                  // Folder (relative to the ISO root) of the BIOS bootloader files. Defaults to 'isolinux'.
+	InitrdPath         string                  // Path to ISO's initrd file. Required.
+	GrubCfgPath        string                  // Path to ISO's grub.cfg file. If provided, overrides the grub.cfg from the resources.
+	IsoRepoDirPath     string                  // Path to the RPM repository with the packages required by the ISO installer.
+	OutputDir          string                  // Path to the output ISO directory. Required.
+	ImageNameBase      string                  // Base name of the ISO to generate. Defaults to 'azure-linux'.
+	ImageNameTag       string                  // Optional tag appended to the generated ISO's name.
+
+	SourceDateEpoch  *time.Time              // If set, the ISO image is reproducible (see EnableReproducibleBuild).
+	EfiBootFiles     []safechroot.FileToCopy // Additional files to copy to efiboot.img (see SetEfiBootFiles).
+	SkipGrubEfi      bool                    // Whether to skip the grub<arch>64.efi bootloader (see SkipGrubEfiBootloader).
+	Backend          IsoBackend              // The tool that generates the ISO image file.
+	ExtraBackendArgs []string                // Additional arguments passed as-is to the backend (see SetExtraBackendArgs).
+
+	// GPG keys that the RPMs of the ISO RPMs repo (see EnableRpmRepo) must be signed with. If empty, the signatures
+	// aren't verified.
+	RpmRepoGpgKeyPaths []string
+
+	// The kernel and the EFI bootloaders of the build host's architecture (see IsoBootFiles). If nil, they are
+	// extracted from the initrd.
+	BootFiles *IsoBootFiles
+
+	// The boot files of the other architectures of a multi-architecture ISO image (see IsoArchBootFiles). The build
+	// host's architecture is always included.
+	AdditionalBootArchs []IsoArchBootFiles
+
+	// Size (in bytes, rounded up to whole MiBs) of the EFI system partition images (efiboot.img). If 0, each image is
+	// sized to fit its files.
+	EfiBootImgSize uint64
+	// FAT volume label (up to 11 characters) of the EFI system partition images. If empty, mkdosfs's default is used.
+	EfiBootImgLabel string
+
+	// If true, the ISO image can also be written as-is to a USB stick and booted on UEFI machines (see
+	// IsoMaker.EnableHybridGpt).
+	HybridGpt bool
+
+	// Paths (relative to the ISO root) of files or folders whose files are transparently compressed with zisofs, to
+	// reduce the ISO image size (e.g. documentation). The Linux kernel decompresses them when it reads them. The files
+	// under 'boot' and the BIOS bootloader folder are never compressed, since the bootloaders can't read them.
+	ZisofsPaths []string
+
+	// If true, Make stages the ISO files and lists them (see IsoMaker.Manifest), without generating the ISO image.
+	DryRun bool
+	// Called when a stage of Make starts.
+	ProgressCallback IsoMakerProgressFunc
+}
+
+// IsValid returns an error if the options are incomplete or inconsistent.
+func (o *IsoMakerOptions) IsValid() error {
+	requiredPaths := []struct {
+		name  string
+		value string
+	}{
+		{"BuildDirPath", o.BuildDirPath},
+		{"InitrdPath", o.InitrdPath},
+		{"OutputDir", o.OutputDir},
+	}
+	for _, requiredPath := range requiredPaths {
+		if requiredPath.value == "" {
+			return fmt.Errorf("ISO maker option (%s) must be specified", requiredPath.name)
+		}
+	}
+
+	err := o.Backend.IsValid()
+	if err != nil {
+		return err
+	}
+
+	if len(o.RpmRepoGpgKeyPaths) > 0 && !o.EnableRpmRepo {
+		return fmt.Errorf("ISO maker option (RpmRepoGpgKeyPaths) requires (EnableRpmRepo)")
+	}
+
+	for _, zisofsPath := range o.ZisofsPaths {
+		if !filepath.IsLocal(zisofsPath) {
+			return fmt.Errorf("invalid ISO path to compress (%s): must be a path under the ISO root", zisofsPath)
+		}
+	}
+
+	if o.BootFiles != nil {
+		err = o.BootFiles.isValid(o.SkipGrubEfi)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = validateAdditionalBootArchs(o.AdditionalBootArchs, o.SkipGrubEfi)
+	if err != nil {
+		return err
+	}
+
+	err = validateEfiBootImgLabel(o.EfiBootImgLabel)
+	if err != nil {
+		return err
+	}
+
+	switch o.Backend {
+	case IsoBackendMkisofs:
+		if o.SourceDateEpoch != nil {
+			return fmt.Errorf("reproducible ISO builds require the (%s) or the (%s) backend", IsoBackendXorriso,
+				IsoBackendNative)
+		}
+		if o.HybridGpt {
+			return fmt.Errorf("hybrid ISO images require the (%s) backend", IsoBackendXorriso)
+		}
+
+	case IsoBackendNative:
+		if o.HybridGpt {
+			return fmt.Errorf("hybrid ISO images require the (%s) backend", IsoBackendXorriso)
+		}
+		if o.EnableBiosBoot {
+			return fmt.Errorf("the (%s) ISO backend doesn't support BIOS boot", IsoBackendNative)
+		}
+		if len(o.ExtraBackendArgs) > 0 {
+			return fmt.Errorf("the (%s) ISO backend doesn't support extra backend arguments", IsoBackendNative)
+		}
+	}
+
+	return nil
+}
+
+// NewIsoMakerWithOptions returns a new ISO maker for an already loaded config.
+func NewIsoMakerWithOptions(options IsoMakerOptions) (*IsoMaker, error) {
+	err := options.IsValid()
+	if err != nil {
+		return nil, fmt.Errorf("invalid ISO maker options:\n%w", err)
+	}
+
+	imageNameBase := options.ImageNameBase
+	if imageNameBase == "" {
+		imageNameBase = defaultImageNameBase
+	}
+
+	osFilesPath := options.OsFilesPath
+	if osFilesPath == "" {
+		osFilesPath = defaultOSFilesPath
+	}
+
+	err = verifyConfig(options.Config, options.UnattendedInstall)
+	if err != nil {
+		return nil, err
+	}
+
+	isoMaker := &IsoMaker{
+		enableBiosBoot:      options.EnableBiosBoot,
+		enableRpmRepo:       options.EnableRpmRepo,
+		unattendedInstall:   options.UnattendedInstall,
+		config:              options.Config,
+		baseDirPath:         options.BaseDirPath,
+		buildDirPath:        options.BuildDirPath,
+		initrdPath:          options.InitrdPath,
+		grubCfgPath:         options.GrubCfgPath,
+		releaseVersion:      options.ReleaseVersion,
+		resourcesDirPath:    options.ResourcesDirPath,
+		additionalIsoFiles:  options.AdditionalIsoFiles,
+		fetchedRepoDirPath:  options.IsoRepoDirPath,
+		outputDirPath:       options.OutputDir,
+		imageNameBase:       imageNameBase,
+		imageNameTag:        options.ImageNameTag,
+		osFilesPath:         osFilesPath,
+		sourceDateEpoch:     options.SourceDateEpoch,
+		efiBootFiles:        options.EfiBootFiles,
+		skipGrubEfi:         options.SkipGrubEfi,
+		backend:             options.Backend,
+		extraBackendArgs:    options.ExtraBackendArgs,
+		additionalBootArchs: options.AdditionalBootArchs,
+		bootFiles:           options.BootFiles,
+		rpmRepoGpgKeyPaths:  options.RpmRepoGpgKeyPaths,
+		zisofsPaths:         options.ZisofsPaths,
+		efiBootImgSize:      options.EfiBootImgSize,
+		efiBootImgLabel:     options.EfiBootImgLabel,
+		hybridGpt:           options.HybridGpt,
+		dryRun:              options.DryRun,
+		progressCallback:    options.ProgressCallback,
+	}
+
+	return isoMaker, nil
+}
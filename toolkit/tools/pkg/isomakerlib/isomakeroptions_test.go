@@ -0,0 +1,73 @@
+// Copyright Microsoft Corporation.
+// Licensed under the MIT License.
+
+package isomakerlib
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func validTestIsoMakerOptions() IsoMakerOptions {
+	return IsoMakerOptions{
+		BuildDirPath: "/build",
+		InitrdPath:   "/build/initrd.img",
+		OutputDir:    "/out",
+	}
+}
+
+func TestNewIsoMakerWithOptionsDefaults(t *testing.T) {
+	isoMaker, err := NewIsoMakerWithOptions(validTestIsoMakerOptions())
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, defaultImageNameBase, isoMaker.imageNameBase)
+	assert.Equal(t, defaultOSFilesPath, isoMaker.osFilesPath)
+	assert.Equal(t, IsoBackendDefault, isoMaker.backend)
+}
+
+func TestNewIsoMakerWithOptionsMissingBuildDir(t *testing.T) {
+	options := validTestIsoMakerOptions()
+	options.BuildDirPath = ""
+
+	_, err := NewIsoMakerWithOptions(options)
+	assert.ErrorContains(t, err, "ISO maker option (BuildDirPath) must be specified")
+}
+
+func TestIsoMakerOptionsIsValidBackend(t *testing.T) {
+	options := validTestIsoMakerOptions()
+	options.Backend = "genisoimage"
+	assert.ErrorContains(t, options.IsValid(), "invalid ISO backend (genisoimage)")
+
+	sourceDateEpoch := time.Unix(0, 0)
+	options = validTestIsoMakerOptions()
+	options.Backend = IsoBackendMkisofs
+	options.SourceDateEpoch = &sourceDateEpoch
+	assert.ErrorContains(t, options.IsValid(), "reproducible ISO builds require the (xorriso) or the (native) backend")
+
+	options.Backend = IsoBackendXorriso
+	options.ExtraBackendArgs = []string{"-isohybrid-gpt-basdat"}
+	assert.NoError(t, options.IsValid())
+
+	options.Backend = IsoBackendNative
+	assert.ErrorContains(t, options.IsValid(), "doesn't support extra backend arguments")
+
+	options.ExtraBackendArgs = nil
+	options.EnableBiosBoot = true
+	assert.ErrorContains(t, options.IsValid(), "doesn't support BIOS boot")
+}
+
+func TestIsoMakerOptionsIsValidEfiBootImgLabel(t *testing.T) {
+	options := validTestIsoMakerOptions()
+	options.EfiBootImgLabel = "ESP-AZL"
+	assert.NoError(t, options.IsValid())
+
+	options.EfiBootImgLabel = "AZURELINUXESP"
+	assert.ErrorContains(t, options.IsValid(), "must be at most 11 characters long")
+
+	options.EfiBootImgLabel = "ESP/AZL"
+	assert.ErrorContains(t, options.IsValid(), "invalid character ('/')")
+}
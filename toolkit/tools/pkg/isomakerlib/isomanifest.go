@@ -0,0 +1,126 @@
+// Copyright Microsoft Corporation.
+// Licensed under the MIT License.
+
+package isomakerlib
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/logger"
+	"github.com/sirupsen/logrus"
+)
+
+// IsoMakerStage is a stage of the ISO generation, reported to the IsoMakerProgressFunc.
+type IsoMakerStage string
+
+const (
+	// IsoMakerStagePrepareWorkDir creates the ISO build directory.
+	IsoMakerStagePrepareWorkDir IsoMakerStage = "prepare-work-dir"
+	// IsoMakerStageCreateRpmRepo copies the installer's RPM repo and config files.
+	IsoMakerStageCreateRpmRepo IsoMakerStage = "create-rpm-repo"
+	// IsoMakerStagePrepareBootloaders creates the bootloader files, the efiboot.img files and copies the additional
+	// ISO files.
+	IsoMakerStagePrepareBootloaders IsoMakerStage = "prepare-bootloaders"
+	// IsoMakerStageBuildIsoImage writes the ISO image file. Skipped by dry runs.
+	IsoMakerStageBuildIsoImage IsoMakerStage = "build-iso-image"
+)
+
+// IsoMakerProgressFunc is called when the ISO maker starts a stage. 'step' starts at 1.
+type IsoMakerProgressFunc func(stage IsoMakerStage, step int, totalSteps int)
+
+// IsoManifestEntry is a file or a folder placed on the ISO image.
+type IsoManifestEntry struct {
+	Path       string      // Path relative to the ISO root.
+	Size       int64       // Size in bytes. 0 for folders and symlinks.
+	Mode       fs.FileMode // Type and permissions.
+	LinkTarget string      // Target of a symlink.
+}
+
+// Manifest returns the files and folders placed on the ISO image by the last call to Make, sorted by path. Dry runs
+// (see IsoMakerOptions.DryRun) also fill it.
+func (im *IsoMaker) Manifest() []IsoManifestEntry {
+	return im.manifest
+}
+
+// reportProgress calls the progress callback (if any).
+func (im *IsoMaker) reportProgress(stage IsoMakerStage) {
+	if im.progressCallback == nil {
+		return
+	}
+
+	totalSteps := 4
+	if im.dryRun {
+		totalSteps = 3
+	}
+
+	im.progressStep++
+	im.progressCallback(stage, im.progressStep, totalSteps)
+}
+
+// buildIsoManifest lists the files and folders of the ISO build directory.
+func buildIsoManifest(buildDirPath string) ([]IsoManifestEntry, error) {
+	manifest := []IsoManifestEntry(nil)
+
+	// WalkDir visits the entries in lexical order.
+	err := filepath.WalkDir(buildDirPath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == buildDirPath {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		relativePath, err := filepath.Rel(buildDirPath, path)
+		if err != nil {
+			return err
+		}
+
+		entry := IsoManifestEntry{
+			Path: filepath.ToSlash(relativePath),
+			Mode: info.Mode(),
+		}
+
+		switch {
+		case info.Mode().IsRegular():
+			entry.Size = info.Size()
+
+		case info.Mode()&fs.ModeSymlink != 0:
+			entry.LinkTarget, err = os.Readlink(path)
+			if err != nil {
+				return err
+			}
+		}
+
+		manifest = append(manifest, entry)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the ISO files under (%s):\n%w", buildDirPath, err)
+	}
+
+	return manifest, nil
+}
+
+// logIsoManifest logs the files and folders of the ISO image, and their total size.
+func logIsoManifest(manifest []IsoManifestEntry, level logrus.Level) {
+	totalSize := int64(0)
+	for _, entry := range manifest {
+		totalSize += entry.Size
+
+		if entry.LinkTarget != "" {
+			logger.Log.Logf(level, "ISO file: %s %s -> %s", entry.Mode, entry.Path, entry.LinkTarget)
+		} else {
+			logger.Log.Logf(level, "ISO file: %s %12d %s", entry.Mode, entry.Size, entry.Path)
+		}
+	}
+
+	logger.Log.Infof("ISO files: %d entries, %d bytes", len(manifest), totalSize)
+}
@@ -0,0 +1,74 @@
+// Copyright Microsoft Corporation.
+// Licensed under the MIT License.
+
+package isomakerlib
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildIsoManifest(t *testing.T) {
+	buildDir := t.TempDir()
+
+	err := os.MkdirAll(filepath.Join(buildDir, "boot"), os.ModePerm)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	err = os.WriteFile(filepath.Join(buildDir, "boot", "vmlinuz"), []byte("kernel"), 0o644)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	err = os.Symlink("boot/vmlinuz", filepath.Join(buildDir, "vmlinuz"))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	manifest, err := buildIsoManifest(buildDir)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	if !assert.Len(t, manifest, 3) {
+		return
+	}
+
+	assert.Equal(t, "boot", manifest[0].Path)
+	assert.True(t, manifest[0].Mode.IsDir())
+
+	assert.Equal(t, IsoManifestEntry{Path: "boot/vmlinuz", Size: 6, Mode: 0o644}, manifest[1])
+
+	assert.Equal(t, "vmlinuz", manifest[2].Path)
+	assert.NotZero(t, manifest[2].Mode&fs.ModeSymlink)
+	assert.Equal(t, "boot/vmlinuz", manifest[2].LinkTarget)
+}
+
+func TestReportProgress(t *testing.T) {
+	type progress struct {
+		stage      IsoMakerStage
+		step       int
+		totalSteps int
+	}
+
+	reported := []progress(nil)
+	isoMaker := &IsoMaker{
+		dryRun: true,
+		progressCallback: func(stage IsoMakerStage, step int, totalSteps int) {
+			reported = append(reported, progress{stage, step, totalSteps})
+		},
+	}
+
+	isoMaker.reportProgress(IsoMakerStagePrepareWorkDir)
+	isoMaker.reportProgress(IsoMakerStageCreateRpmRepo)
+
+	assert.Equal(t, []progress{
+		{IsoMakerStagePrepareWorkDir, 1, 3},
+		{IsoMakerStageCreateRpmRepo, 2, 3},
+	}, reported)
+}
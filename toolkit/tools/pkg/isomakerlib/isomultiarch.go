@@ -0,0 +1,160 @@
+// Copyright Microsoft Corporation.
+// Licensed under the MIT License.
+
+package isomakerlib
+
+import (
+	"fmt"
+	"path/filepath"
+	"runtime"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/imagegen/installutils"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/file"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/logger"
+)
+
+const (
+	IsoArchX86_64  = "x86_64"
+	IsoArchAarch64 = "aarch64"
+
+	// Loads the grub.cfg of the booting architecture. Prepended to the grub.cfg of the build host's architecture, which
+	// every architecture's grub loads first (they all have the same prefix).
+	multiArchGrubCfgTemplate = `# Multi-architecture ISO: load the grub.cfg of the booting architecture.
+if [ "$grub_cpu" = "%s" ]; then
+    configfile "$prefix/%s/grub.cfg"
+fi
+
+`
+)
+
+// IsoArchBootFiles are the boot files of an additional architecture of a multi-architecture ISO image.
+//
+// Each architecture gets its own EFI system partition image (El Torito boot entry), with the architecture's shim and
+// grub binaries. The firmware boots the entry that has its architecture's default bootloader. The architecture's
+// kernel and initrd are placed under '<osFilesPath>/<arch>/', and its grub.cfg under '/boot/grub2/<arch>/'. So, the
+// grub.cfg must reference the kernel and the initrd at those paths.
+type IsoArchBootFiles struct {
+	Arch        string // The architecture ('x86_64' or 'aarch64'). Must differ from the build host's architecture.
+	InitrdPath  string // Path to the architecture's initrd file.
+	GrubCfgPath string // Path to the architecture's grub.cfg file.
+	// The architecture's kernel and EFI bootloaders. If nil, they are extracted from the initrd.
+	BootFiles *IsoBootFiles
+}
+
+type isoBootArchInfo struct {
+	bootBootloaderFile string
+	grubBootloaderFile string
+	// grub's name of the architecture (i.e. the value of $grub_cpu).
+	grubCpu string
+}
+
+var isoBootArchInfos = map[string]isoBootArchInfo{
+	IsoArchX86_64:  {bootBootloaderFile: "bootx64.efi", grubBootloaderFile: "grubx64.efi", grubCpu: "x86_64"},
+	IsoArchAarch64: {bootBootloaderFile: "bootaa64.efi", grubBootloaderFile: "grubaa64.efi", grubCpu: "arm64"},
+}
+
+// hostIsoBootArch returns the architecture of the build host, which is the primary architecture of the ISO image.
+func hostIsoBootArch() string {
+	if runtime.GOARCH == "arm64" {
+		return IsoArchAarch64
+	}
+	return IsoArchX86_64
+}
+
+// validateAdditionalBootArchs returns an error if the additional architectures are incomplete, unknown, or duplicated.
+func validateAdditionalBootArchs(archs []IsoArchBootFiles, skipGrubEfi bool) error {
+	seen := map[string]bool{hostIsoBootArch(): true}
+	for _, arch := range archs {
+		_, found := isoBootArchInfos[arch.Arch]
+		if !found {
+			return fmt.Errorf("unsupported ISO boot architecture (%s)", arch.Arch)
+		}
+		if seen[arch.Arch] {
+			return fmt.Errorf("ISO boot architecture (%s) is specified more than once (the build host's architecture "+
+				"is always included)", arch.Arch)
+		}
+		seen[arch.Arch] = true
+
+		if arch.InitrdPath == "" || arch.GrubCfgPath == "" {
+			return fmt.Errorf("ISO boot architecture (%s) requires both an initrd and a grub.cfg", arch.Arch)
+		}
+
+		if arch.BootFiles != nil {
+			err := arch.BootFiles.isValid(skipGrubEfi)
+			if err != nil {
+				return fmt.Errorf("invalid ISO boot architecture (%s):\n%w", arch.Arch, err)
+			}
+		}
+	}
+	return nil
+}
+
+// efiBootImgPathRelativeToIsoRootForArch returns the path of the EFI system partition image of an additional
+// architecture.
+func efiBootImgPathRelativeToIsoRootForArch(arch string) string {
+	return fmt.Sprintf("boot/grub2/efiboot-%s.img", arch)
+}
+
+// efiBootImgPathsRelativeToIsoRoot returns the paths of the EFI system partition images, the build host's
+// architecture first.
+func (im *IsoMaker) efiBootImgPathsRelativeToIsoRoot() []string {
+	paths := []string{efiBootImgPathRelativeToIsoRoot}
+	for _, arch := range im.additionalBootArchs {
+		paths = append(paths, efiBootImgPathRelativeToIsoRootForArch(arch.Arch))
+	}
+	return paths
+}
+
+// setUpAdditionalBootArchs prepares the EFI system partition image, the kernel, the initrd and the grub.cfg of each
+// additional architecture.
+func (im *IsoMaker) setUpAdditionalBootArchs() error {
+	if len(im.additionalBootArchs) == 0 {
+		return nil
+	}
+
+	grubCfgPath := filepath.Join(im.buildDirPath, installutils.GrubCfgFile)
+	grubCfgContent, err := file.Read(grubCfgPath)
+	if err != nil {
+		return fmt.Errorf("failed to read ISO grub.cfg (%s):\n%w", grubCfgPath, err)
+	}
+
+	dispatch := ""
+	for _, arch := range im.additionalBootArchs {
+		logger.Log.Infof("Preparing ISO's (%s) boot files.", arch.Arch)
+
+		archInfo := isoBootArchInfos[arch.Arch]
+
+		efiBootImgPath := filepath.Join(im.buildDirPath, efiBootImgPathRelativeToIsoRootForArch(arch.Arch))
+		bootFilesSource := isoBootFilesSource{initrdPath: arch.InitrdPath, bootFiles: arch.BootFiles}
+		err = im.createEfiBootImg(efiBootImgPath, bootFilesSource, archInfo, nil /*efiBootFiles*/)
+		if err != nil {
+			return fmt.Errorf("failed to create the (%s) efiboot image:\n%w", arch.Arch, err)
+		}
+
+		archOsFilesDir := filepath.Join(im.buildDirPath, im.osFilesPath, arch.Arch)
+		err = bootFilesSource.copyKernel(filepath.Join(archOsFilesDir, "vmlinuz"))
+		if err != nil {
+			return err
+		}
+
+		err = file.Copy(arch.InitrdPath, filepath.Join(archOsFilesDir, "initrd.img"))
+		if err != nil {
+			return fmt.Errorf("failed to copy the (%s) initrd:\n%w", arch.Arch, err)
+		}
+
+		archGrubCfgPath := filepath.Join(filepath.Dir(grubCfgPath), arch.Arch, "grub.cfg")
+		err = file.Copy(arch.GrubCfgPath, archGrubCfgPath)
+		if err != nil {
+			return fmt.Errorf("failed to copy the (%s) grub.cfg:\n%w", arch.Arch, err)
+		}
+
+		dispatch += fmt.Sprintf(multiArchGrubCfgTemplate, archInfo.grubCpu, arch.Arch)
+	}
+
+	err = file.Write(dispatch+grubCfgContent, grubCfgPath)
+	if err != nil {
+		return fmt.Errorf("failed to write ISO grub.cfg (%s):\n%w", grubCfgPath, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,58 @@
+// Copyright Microsoft Corporation.
+// Licensed under the MIT License.
+
+package isomakerlib
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func otherTestIsoBootArch() string {
+	if hostIsoBootArch() == IsoArchX86_64 {
+		return IsoArchAarch64
+	}
+	return IsoArchX86_64
+}
+
+func TestValidateAdditionalBootArchs(t *testing.T) {
+	arch := IsoArchBootFiles{
+		Arch:        otherTestIsoBootArch(),
+		InitrdPath:  "/initrd.img",
+		GrubCfgPath: "/grub.cfg",
+	}
+	assert.NoError(t, validateAdditionalBootArchs([]IsoArchBootFiles{arch}, false /*skipGrubEfi*/))
+
+	assert.ErrorContains(t, validateAdditionalBootArchs([]IsoArchBootFiles{arch, arch}, false /*skipGrubEfi*/),
+		"is specified more than once")
+
+	hostArch := arch
+	hostArch.Arch = hostIsoBootArch()
+	assert.ErrorContains(t, validateAdditionalBootArchs([]IsoArchBootFiles{hostArch}, false /*skipGrubEfi*/),
+		"is specified more than once")
+
+	unknownArch := arch
+	unknownArch.Arch = "riscv64"
+	assert.ErrorContains(t, validateAdditionalBootArchs([]IsoArchBootFiles{unknownArch}, false /*skipGrubEfi*/),
+		"unsupported ISO boot architecture (riscv64)")
+
+	noGrubCfg := arch
+	noGrubCfg.GrubCfgPath = ""
+	assert.ErrorContains(t, validateAdditionalBootArchs([]IsoArchBootFiles{noGrubCfg}, false /*skipGrubEfi*/),
+		"requires both an initrd and a grub.cfg")
+
+	noGrubEfi := arch
+	noGrubEfi.BootFiles = &IsoBootFiles{BootBootloaderPath: "/bootaa64.efi", KernelPath: "/vmlinuz"}
+	assert.ErrorContains(t, validateAdditionalBootArchs([]IsoArchBootFiles{noGrubEfi}, false /*skipGrubEfi*/),
+		"require the grub bootloader (GrubBootloaderPath)")
+	assert.NoError(t, validateAdditionalBootArchs([]IsoArchBootFiles{noGrubEfi}, true /*skipGrubEfi*/))
+}
+
+func TestEfiBootImgPathsRelativeToIsoRoot(t *testing.T) {
+	isoMaker := &IsoMaker{
+		additionalBootArchs: []IsoArchBootFiles{{Arch: IsoArchAarch64}},
+	}
+	assert.Equal(t, []string{"boot/grub2/efiboot.img", "boot/grub2/efiboot-aarch64.img"},
+		isoMaker.efiBootImgPathsRelativeToIsoRoot())
+}
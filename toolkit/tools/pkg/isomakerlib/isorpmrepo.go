@@ -0,0 +1,77 @@
+// Copyright Microsoft Corporation.
+// Licensed under the MIT License.
+
+package isomakerlib
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/file"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/logger"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/packagerepo/repoutils"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/rpm"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/shell"
+)
+
+const (
+	isoRpmRepoDataDir = "repodata"
+)
+
+// finalizeIsoRpmsRepo turns the RPMs copied to the ISO media into a self-contained offline install source. That is,
+// it verifies the RPMs' GPG signatures (if keys are specified) and (re)generates the repo's metadata.
+func (im *IsoMaker) finalizeIsoRpmsRepo(isoRpmsRepoDirPath string) error {
+	if len(im.rpmRepoGpgKeyPaths) > 0 {
+		err := rpm.ValidateDirectoryRPMSignatures(isoRpmsRepoDirPath, im.rpmRepoGpgKeyPaths)
+		if err != nil {
+			return fmt.Errorf("failed to verify the ISO RPMs repo:\n%w", err)
+		}
+	}
+
+	return im.generateIsoRpmsRepoMetadata(isoRpmsRepoDirPath)
+}
+
+// generateIsoRpmsRepoMetadata runs createrepo over the ISO RPMs repo. If createrepo isn't installed, the repo's
+// existing metadata (if any) is kept.
+func (im *IsoMaker) generateIsoRpmsRepoMetadata(isoRpmsRepoDirPath string) error {
+	repoDataPath := filepath.Join(isoRpmsRepoDirPath, isoRpmRepoDataDir)
+
+	createRepoCmd, err := repoutils.FindCreateRepoCommand()
+	if err != nil {
+		repoDataExists, existsErr := file.DirExists(repoDataPath)
+		if existsErr != nil {
+			return fmt.Errorf("failed to check if (%s) exists:\n%w", repoDataPath, existsErr)
+		}
+		if !repoDataExists {
+			return fmt.Errorf("failed to generate the ISO RPMs repo metadata:\n%w", err)
+		}
+
+		logger.Log.Warnf("Keeping the existing ISO RPMs repo metadata, since it can't be regenerated: %s", err)
+		return nil
+	}
+
+	// Stale metadata (e.g. for a different set of RPMs) is replaced.
+	err = os.RemoveAll(repoDataPath)
+	if err != nil {
+		return fmt.Errorf("failed to remove (%s):\n%w", repoDataPath, err)
+	}
+
+	args := []string{"--compatibility"}
+	if im.sourceDateEpoch != nil && createRepoCmd == "createrepo_c" {
+		// Use the build timestamp instead of the current time for the metadata's revision and timestamps.
+		args = append(args, "--revision", strconv.FormatInt(im.sourceDateEpoch.Unix(), 10),
+			"--set-timestamp-to-revision")
+	}
+	args = append(args, isoRpmsRepoDirPath)
+
+	logger.Log.Debugf("Generating the ISO RPMs repo metadata under '%s'.", repoDataPath)
+
+	_, stderr, err := shell.Execute(createRepoCmd, args...)
+	if err != nil {
+		return fmt.Errorf("failed to generate the ISO RPMs repo metadata:\n%v\n%w", stderr, err)
+	}
+
+	return nil
+}
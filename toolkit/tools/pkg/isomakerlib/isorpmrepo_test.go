@@ -0,0 +1,48 @@
+// Copyright Microsoft Corporation.
+// Licensed under the MIT License.
+
+package isomakerlib
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerateIsoRpmsRepoMetadataWithoutCreateRepo(t *testing.T) {
+	// No createrepo command can be found.
+	t.Setenv("PATH", t.TempDir())
+
+	repoDir := t.TempDir()
+	isoMaker := &IsoMaker{}
+
+	err := isoMaker.generateIsoRpmsRepoMetadata(repoDir)
+	assert.ErrorContains(t, err, "failed to generate the ISO RPMs repo metadata")
+
+	repoMdPath := filepath.Join(repoDir, isoRpmRepoDataDir, "repomd.xml")
+	err = os.MkdirAll(filepath.Dir(repoMdPath), os.ModePerm)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	err = os.WriteFile(repoMdPath, []byte("<repomd/>"), 0o644)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// The pre-generated metadata is kept.
+	err = isoMaker.generateIsoRpmsRepoMetadata(repoDir)
+	assert.NoError(t, err)
+	assert.FileExists(t, repoMdPath)
+}
+
+func TestIsoMakerOptionsIsValidRpmRepoGpgKeys(t *testing.T) {
+	options := validTestIsoMakerOptions()
+	options.RpmRepoGpgKeyPaths = []string{"/etc/pki/rpm-gpg/MICROSOFT-RPM-GPG-KEY"}
+	assert.ErrorContains(t, options.IsValid(), "ISO maker option (RpmRepoGpgKeyPaths) requires (EnableRpmRepo)")
+
+	options.EnableRpmRepo = true
+	assert.NoError(t, options.IsValid())
+}
@@ -0,0 +1,1278 @@
+// Copyright Microsoft Corporation.
+// Licensed under the MIT License.
+
+package isomakerlib
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+	"unicode/utf16"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/logger"
+)
+
+// A native ISO 9660 writer, with the Rock Ridge (POSIX names and attributes) and Joliet (Windows names) extensions, and
+// an El Torito EFI boot entry. It covers the simple case (a UEFI bootable media) so that ISO images can be built
+// without 'mkisofs' or 'xorriso' on the build host.
+//
+// See: ECMA-119, the System Use Sharing Protocol (SUSP) and Rock Ridge Interchange Protocol (RRIP) 1.10, the Joliet
+// specification and the El Torito specification.
+
+const (
+	isoSectorSize        = 2048
+	isoSystemAreaSectors = 16
+	// mkisofs and xorriso pad the image with 150 sectors, to work around readahead errors on some readers.
+	isoPaddingSectors = 150
+	// The largest (sector aligned) size of a file extent. Larger files are split into multiple extents.
+	isoMaxExtentSize = 0xFFFFF800
+
+	isoDirRecordBaseSize = 33
+	isoDirRecordMaxSize  = 255
+	isoMaxFileNameLen    = 30
+	isoMaxDirNameLen     = 31
+	jolietMaxNameLen     = 64
+
+	isoDirFlagDirectory   = 0x02
+	isoDirFlagMultiExtent = 0x80
+
+	suspCeEntryLen = 28
+
+	elToritoPlatformEfi = 0xEF
+	elToritoSectorSize  = 512
+	// The boot catalog is one sector: the validation entry, the default entry, a section header and the section
+	// entries.
+	elToritoMaxEntries = isoSectorSize/32 - 2
+)
+
+var (
+	isoStandardId        = []byte("CD001")
+	elToritoSystemId     = "EL TORITO SPECIFICATION"
+	jolietEscapeSequence = []byte{0x25, 0x2F, 0x45} // UCS-2 level 3.
+
+	rripExtensionId          = "RRIP_1991A"
+	rripExtensionDescription = "THE ROCK RIDGE INTERCHANGE PROTOCOL PROVIDES SUPPORT FOR POSIX FILE SYSTEM SEMANTICS"
+	rripExtensionSource      = "PLEASE CONTACT DISC PUBLISHER FOR SPECIFICATION SOURCE.  SEE PUBLISHER IDENTIFIER IN " +
+		"PRIMARY VOLUME DESCRIPTOR FOR CONTACT INFORMATION."
+)
+
+// isoNode is a file, a folder or a symlink of the ISO image.
+type isoNode struct {
+	name          string
+	sourcePath    string
+	info          fs.FileInfo
+	symlinkTarget string
+	// The zisofs header of a compressed file.
+	zisofs   *zisofsHeader
+	parent   *isoNode
+	children []*isoNode
+
+	isoName    string
+	jolietName []byte
+
+	// Layout.
+	dataLba          uint32
+	isoDirLba        uint32
+	isoDirSize       uint32
+	isoPathNumber    uint16
+	jolietDirLba     uint32
+	jolietDirSize    uint32
+	jolietPathNumber uint16
+	isoRecords       []*isoDirRecord
+	jolietRecords    []*isoDirRecord
+}
+
+func (n *isoNode) isDir() bool {
+	return n.info.IsDir()
+}
+
+// isoDirRecord is a directory record of the ISO (or Joliet) tree.
+type isoDirRecord struct {
+	target      *isoNode
+	identifier  []byte
+	extentIndex int
+	joliet      bool
+	// The system use entries stored in the record.
+	systemUse []byte
+	// The system use entries stored in a continuation area. nil if everything fits in the record.
+	continuation *isoContinuation
+}
+
+// isoContinuation is a SUSP continuation area.
+type isoContinuation struct {
+	sector  uint32
+	offset  uint32
+	entries []byte
+}
+
+type isoWriter struct {
+	volumeId     string
+	bootImages   []string
+	creationTime time.Time
+	// If set, the zisofs compressed files (see compressZisofsFile) get Rock Ridge 'ZF' entries.
+	zisofs bool
+	// If set, replaces the timestamps of the files.
+	fileTime *time.Time
+
+	root          *isoNode
+	files         []*isoNode
+	isoDirs       []*isoNode
+	jolietDirs    []*isoNode
+	continuations [][]byte
+
+	bootNodes           []*isoNode
+	bootCatalogLba      uint32
+	isoPathTableSize    uint32
+	isoLPathTableLba    uint32
+	isoMPathTableLba    uint32
+	jolietPathTableSize uint32
+	jolietLPathTableLba uint32
+	jolietMPathTableLba uint32
+	continuationLba     uint32
+	volumeSectors       uint32
+}
+
+// writeNativeIsoImage writes an ISO image of a folder, that boots 'bootImages' (the paths of EFI system partition
+// images, relative to the folder) on UEFI systems. The first image is the default El Torito boot entry. The other
+// images (e.g. of other architectures) are additional boot entries.
+//
+// If 'sourceDateEpoch' is set, it replaces the current time and the timestamps of the files, so that the ISO image is
+// reproducible. If 'zisofs' is set, the zisofs compressed files are marked as such, so that Linux decompresses them.
+func writeNativeIsoImage(sourceDir string, bootImages []string, volumeId string, sourceDateEpoch *time.Time,
+	zisofs bool, isoImagePath string,
+) error {
+	writer := &isoWriter{
+		volumeId:     volumeId,
+		bootImages:   bootImages,
+		creationTime: time.Now().UTC(),
+		zisofs:       zisofs,
+	}
+	if sourceDateEpoch != nil {
+		epoch := sourceDateEpoch.UTC()
+		writer.creationTime = epoch
+		writer.fileTime = &epoch
+	}
+
+	err := writer.scan(sourceDir)
+	if err != nil {
+		return err
+	}
+
+	err = writer.layout()
+	if err != nil {
+		return err
+	}
+
+	err = writer.write(isoImagePath)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (w *isoWriter) scan(sourceDir string) error {
+	info, err := os.Lstat(sourceDir)
+	if err != nil {
+		return fmt.Errorf("failed to stat ISO source folder (%s):\n%w", sourceDir, err)
+	}
+
+	w.root = &isoNode{sourcePath: sourceDir, info: info}
+	w.root.parent = w.root
+
+	err = w.scanDir(w.root)
+	if err != nil {
+		return err
+	}
+
+	if len(w.bootImages) == 0 {
+		return fmt.Errorf("no EFI boot image specified")
+	}
+	if len(w.bootImages) > elToritoMaxEntries {
+		return fmt.Errorf("too many EFI boot images (%d), the maximum is (%d)", len(w.bootImages), elToritoMaxEntries)
+	}
+
+	for _, bootImage := range w.bootImages {
+		bootNode := w.findNode(path.Clean(filepath.ToSlash(bootImage)))
+		if bootNode == nil || !bootNode.info.Mode().IsRegular() {
+			return fmt.Errorf("EFI boot image (%s) not found in ISO source folder (%s)", bootImage, sourceDir)
+		}
+		w.bootNodes = append(w.bootNodes, bootNode)
+	}
+
+	return nil
+}
+
+func (w *isoWriter) scanDir(dir *isoNode) error {
+	entries, err := os.ReadDir(dir.sourcePath)
+	if err != nil {
+		return fmt.Errorf("failed to read folder (%s):\n%w", dir.sourcePath, err)
+	}
+
+	usedIsoNames := make(map[string]bool)
+	usedJolietNames := make(map[string]bool)
+
+	// os.ReadDir sorts the entries by name. So, the mangled names are assigned deterministically.
+	for _, entry := range entries {
+		sourcePath := filepath.Join(dir.sourcePath, entry.Name())
+		info, err := os.Lstat(sourcePath)
+		if err != nil {
+			return fmt.Errorf("failed to stat (%s):\n%w", sourcePath, err)
+		}
+
+		node := &isoNode{
+			name:       entry.Name(),
+			sourcePath: sourcePath,
+			info:       info,
+			parent:     dir,
+		}
+
+		switch {
+		case info.IsDir():
+		case info.Mode().IsRegular():
+			w.files = append(w.files, node)
+
+			if w.zisofs {
+				node.zisofs, err = readZisofsHeader(sourcePath)
+				if err != nil {
+					return fmt.Errorf("failed to read (%s):\n%w", sourcePath, err)
+				}
+			}
+
+		case info.Mode()&fs.ModeSymlink != 0:
+			node.symlinkTarget, err = os.Readlink(sourcePath)
+			if err != nil {
+				return fmt.Errorf("failed to read symlink (%s):\n%w", sourcePath, err)
+			}
+
+		default:
+			return fmt.Errorf("the native ISO writer doesn't support the type of file (%s)", sourcePath)
+		}
+
+		node.isoName = uniqueIsoName(node.name, info.IsDir(), usedIsoNames)
+
+		if node.symlinkTarget == "" {
+			jolietName, err := jolietIdentifier(node.name, info.IsDir())
+			if err != nil {
+				return fmt.Errorf("invalid Joliet name for (%s):\n%w", sourcePath, err)
+			}
+			if usedJolietNames[string(jolietName)] {
+				return fmt.Errorf("Joliet name of (%s) collides with another file", sourcePath)
+			}
+			usedJolietNames[string(jolietName)] = true
+			node.jolietName = jolietName
+		}
+
+		dir.children = append(dir.children, node)
+
+		if info.IsDir() {
+			err = w.scanDir(node)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (w *isoWriter) findNode(relativePath string) *isoNode {
+	node := w.root
+	for _, name := range strings.Split(relativePath, "/") {
+		var found *isoNode
+		for _, child := range node.children {
+			if child.name == name {
+				found = child
+				break
+			}
+		}
+		if found == nil {
+			return nil
+		}
+		node = found
+	}
+	return node
+}
+
+func (w *isoWriter) layout() error {
+	w.isoDirs = sortedDirsBreadthFirst(w.root, func(a, b *isoNode) bool {
+		return compareIsoIdentifiers(a.isoName, b.isoName) < 0
+	})
+	for i, dir := range w.isoDirs {
+		dir.isoPathNumber = uint16(i + 1)
+	}
+
+	w.jolietDirs = sortedDirsBreadthFirst(w.root, func(a, b *isoNode) bool {
+		return bytes.Compare(a.jolietName, b.jolietName) < 0
+	})
+	for i, dir := range w.jolietDirs {
+		dir.jolietPathNumber = uint16(i + 1)
+	}
+
+	if len(w.isoDirs) > 0xFFFF {
+		return fmt.Errorf("the native ISO writer supports up to (%d) folders", 0xFFFF)
+	}
+
+	// Build the directory records. The continuation areas are allocated as the records are built.
+	for _, dir := range w.isoDirs {
+		err := w.buildIsoRecords(dir)
+		if err != nil {
+			return err
+		}
+	}
+	for _, dir := range w.jolietDirs {
+		w.buildJolietRecords(dir)
+	}
+
+	lba := uint32(isoSystemAreaSectors)
+
+	// Primary, boot record, Joliet and terminator volume descriptors.
+	lba += 4
+
+	w.bootCatalogLba = lba
+	lba += 1
+
+	w.isoPathTableSize = pathTableSize(w.isoDirs, isoPathIdentifier)
+	w.isoLPathTableLba = lba
+	lba += sectorCount(uint64(w.isoPathTableSize))
+	w.isoMPathTableLba = lba
+	lba += sectorCount(uint64(w.isoPathTableSize))
+
+	w.jolietPathTableSize = pathTableSize(w.jolietDirs, jolietPathIdentifier)
+	w.jolietLPathTableLba = lba
+	lba += sectorCount(uint64(w.jolietPathTableSize))
+	w.jolietMPathTableLba = lba
+	lba += sectorCount(uint64(w.jolietPathTableSize))
+
+	for _, dir := range w.isoDirs {
+		dir.isoDirSize = dirExtentSize(dir.isoRecords)
+		dir.isoDirLba = lba
+		lba += dir.isoDirSize / isoSectorSize
+	}
+
+	w.continuationLba = lba
+	lba += uint32(len(w.continuations))
+
+	for _, dir := range w.jolietDirs {
+		dir.jolietDirSize = dirExtentSize(dir.jolietRecords)
+		dir.jolietDirLba = lba
+		lba += dir.jolietDirSize / isoSectorSize
+	}
+
+	for _, node := range w.files {
+		size := uint64(node.info.Size())
+		if size == 0 {
+			continue
+		}
+
+		sectors := uint64(lba) + uint64(sectorCount(size))
+		if sectors > 0xFFFFFFFF {
+			return fmt.Errorf("ISO image is too large")
+		}
+
+		node.dataLba = lba
+		lba = uint32(sectors)
+	}
+
+	lba += isoPaddingSectors
+	w.volumeSectors = lba
+
+	return nil
+}
+
+// sortedDirsBreadthFirst returns the folders in the path table order: by level, then by parent, then by name.
+func sortedDirsBreadthFirst(root *isoNode, less func(a, b *isoNode) bool) []*isoNode {
+	dirs := []*isoNode{root}
+	for i := 0; i < len(dirs); i++ {
+		var subdirs []*isoNode
+		for _, child := range dirs[i].children {
+			if child.isDir() {
+				subdirs = append(subdirs, child)
+			}
+		}
+		sort.SliceStable(subdirs, func(a, b int) bool { return less(subdirs[a], subdirs[b]) })
+		dirs = append(dirs, subdirs...)
+	}
+	return dirs
+}
+
+func (w *isoWriter) buildIsoRecords(dir *isoNode) error {
+	children := append([]*isoNode(nil), dir.children...)
+	sort.SliceStable(children, func(a, b int) bool {
+		return compareIsoIdentifiers(children[a].isoName, children[b].isoName) < 0
+	})
+
+	var records []*isoDirRecord
+
+	selfEntries := [][]byte{}
+	if dir == w.root {
+		selfEntries = append(selfEntries, suspSpEntry())
+	}
+	selfEntries = append(selfEntries, w.rripPxEntry(dir), w.rripTfEntry(dir))
+	if dir == w.root {
+		selfEntries = append(selfEntries, suspErEntry())
+	}
+
+	parentEntries := [][]byte{w.rripPxEntry(dir.parent), w.rripTfEntry(dir.parent)}
+
+	for _, special := range []struct {
+		target     *isoNode
+		identifier byte
+		entries    [][]byte
+	}{
+		{dir, 0, selfEntries},
+		{dir.parent, 1, parentEntries},
+	} {
+		record, err := w.newIsoRecord(special.target, []byte{special.identifier}, 0, special.entries)
+		if err != nil {
+			return err
+		}
+		records = append(records, record)
+	}
+
+	for _, child := range children {
+		identifier := child.isoName
+		if !child.isDir() {
+			identifier += ";1"
+		}
+
+		entries := [][]byte{w.rripPxEntry(child), w.rripTfEntry(child)}
+		entries = append(entries, rripNmEntries(child.name)...)
+		if child.zisofs != nil {
+			entries = append(entries, rripZfEntry(child.zisofs))
+		}
+		if child.symlinkTarget != "" {
+			slEntries, err := rripSlEntries(child.symlinkTarget)
+			if err != nil {
+				return fmt.Errorf("failed to encode symlink (%s):\n%w", child.sourcePath, err)
+			}
+			entries = append(entries, slEntries...)
+		}
+
+		for extentIndex := 0; extentIndex < extentCount(child); extentIndex++ {
+			record, err := w.newIsoRecord(child, []byte(identifier), extentIndex, entries)
+			if err != nil {
+				return fmt.Errorf("failed to build directory record of (%s):\n%w", child.sourcePath, err)
+			}
+			records = append(records, record)
+		}
+	}
+
+	dir.isoRecords = records
+	return nil
+}
+
+// newIsoRecord builds a directory record. The system use entries that don't fit in the record are moved to a
+// continuation area.
+func (w *isoWriter) newIsoRecord(target *isoNode, identifier []byte, extentIndex int, entries [][]byte,
+) (*isoDirRecord, error) {
+	record := &isoDirRecord{
+		target:      target,
+		identifier:  identifier,
+		extentIndex: extentIndex,
+	}
+
+	available := isoDirRecordMaxSize - isoDirRecordHeaderSize(identifier)
+
+	totalSize := 0
+	for _, entry := range entries {
+		totalSize += len(entry)
+	}
+
+	if totalSize <= available {
+		record.systemUse = bytes.Join(entries, nil)
+		return record, nil
+	}
+
+	i := 0
+	for ; i < len(entries); i++ {
+		if len(record.systemUse)+len(entries[i])+suspCeEntryLen > available {
+			break
+		}
+		record.systemUse = append(record.systemUse, entries[i]...)
+	}
+
+	continuationEntries := bytes.Join(entries[i:], nil)
+	continuation, err := w.allocateContinuation(continuationEntries)
+	if err != nil {
+		return nil, err
+	}
+
+	record.continuation = continuation
+	return record, nil
+}
+
+func (w *isoWriter) allocateContinuation(entries []byte) (*isoContinuation, error) {
+	if len(entries) > isoSectorSize {
+		return nil, fmt.Errorf("system use entries are too large (%d bytes)", len(entries))
+	}
+
+	last := len(w.continuations) - 1
+	if last < 0 || len(w.continuations[last])+len(entries) > isoSectorSize {
+		w.continuations = append(w.continuations, nil)
+		last++
+	}
+
+	continuation := &isoContinuation{
+		sector:  uint32(last),
+		offset:  uint32(len(w.continuations[last])),
+		entries: entries,
+	}
+	w.continuations[last] = append(w.continuations[last], entries...)
+	return continuation, nil
+}
+
+func (w *isoWriter) buildJolietRecords(dir *isoNode) {
+	var children []*isoNode
+	for _, child := range dir.children {
+		if child.jolietName != nil {
+			children = append(children, child)
+		}
+	}
+	sort.SliceStable(children, func(a, b int) bool {
+		return bytes.Compare(children[a].jolietName, children[b].jolietName) < 0
+	})
+
+	records := []*isoDirRecord{
+		{target: dir, identifier: []byte{0}, joliet: true},
+		{target: dir.parent, identifier: []byte{1}, joliet: true},
+	}
+	for _, child := range children {
+		for extentIndex := 0; extentIndex < extentCount(child); extentIndex++ {
+			records = append(records, &isoDirRecord{
+				target:      child,
+				identifier:  child.jolietName,
+				extentIndex: extentIndex,
+				joliet:      true,
+			})
+		}
+	}
+
+	dir.jolietRecords = records
+}
+
+func (w *isoWriter) write(isoImagePath string) error {
+	isoFile, err := os.Create(isoImagePath)
+	if err != nil {
+		return fmt.Errorf("failed to create ISO image (%s):\n%w", isoImagePath, err)
+	}
+	defer isoFile.Close()
+
+	out := &isoSectorWriter{writer: bufio.NewWriterSize(isoFile, 1024*1024)}
+
+	out.write(make([]byte, isoSystemAreaSectors*isoSectorSize))
+	out.writeSector(w.volumeDescriptor(false))
+	out.writeSector(w.bootRecordVolumeDescriptor())
+	out.writeSector(w.volumeDescriptor(true))
+	out.writeSector(volumeDescriptorSetTerminator())
+	out.writeSector(w.bootCatalog())
+
+	out.writeSector(w.pathTable(w.isoDirs, binary.LittleEndian, false))
+	out.writeSector(w.pathTable(w.isoDirs, binary.BigEndian, false))
+	out.writeSector(w.pathTable(w.jolietDirs, binary.LittleEndian, true))
+	out.writeSector(w.pathTable(w.jolietDirs, binary.BigEndian, true))
+
+	for _, dir := range w.isoDirs {
+		out.checkLba(dir.isoDirLba)
+		out.writeSector(w.dirExtent(dir.isoRecords))
+	}
+
+	out.checkLba(w.continuationLba)
+	for _, continuation := range w.continuations {
+		out.writeSector(continuation)
+	}
+
+	for _, dir := range w.jolietDirs {
+		out.checkLba(dir.jolietDirLba)
+		out.writeSector(w.dirExtent(dir.jolietRecords))
+	}
+
+	for _, node := range w.files {
+		if node.info.Size() == 0 {
+			continue
+		}
+
+		out.checkLba(node.dataLba)
+		if out.err != nil {
+			break
+		}
+
+		err = out.writeFile(node.sourcePath, node.info.Size())
+		if err != nil {
+			return err
+		}
+	}
+
+	out.write(make([]byte, isoPaddingSectors*isoSectorSize))
+	out.checkLba(w.volumeSectors)
+
+	if out.err != nil {
+		return fmt.Errorf("failed to write ISO image (%s):\n%w", isoImagePath, out.err)
+	}
+
+	err = out.writer.Flush()
+	if err != nil {
+		return fmt.Errorf("failed to write ISO image (%s):\n%w", isoImagePath, err)
+	}
+
+	err = isoFile.Close()
+	if err != nil {
+		return fmt.Errorf("failed to close ISO image (%s):\n%w", isoImagePath, err)
+	}
+
+	logger.Log.Debugf("Wrote ISO image (%s) with the native ISO writer (%d sectors)", isoImagePath, w.volumeSectors)
+
+	return nil
+}
+
+func (w *isoWriter) volumeDescriptor(joliet bool) []byte {
+	descriptor := make([]byte, isoSectorSize)
+
+	descriptor[0] = 1
+	if joliet {
+		descriptor[0] = 2
+	}
+	copy(descriptor[1:6], isoStandardId)
+	descriptor[6] = 1
+
+	putString := func(field []byte, value string) {
+		if joliet {
+			putJolietString(field, value)
+		} else {
+			putPaddedString(field, value)
+		}
+	}
+
+	putString(descriptor[8:40], "LINUX")
+	putString(descriptor[40:72], w.volumeId)
+	putBothUint32(descriptor[80:88], w.volumeSectors)
+	if joliet {
+		copy(descriptor[88:120], jolietEscapeSequence)
+	}
+	putBothUint16(descriptor[120:124], 1)
+	putBothUint16(descriptor[124:128], 1)
+	putBothUint16(descriptor[128:132], isoSectorSize)
+
+	if joliet {
+		putBothUint32(descriptor[132:140], w.jolietPathTableSize)
+		binary.LittleEndian.PutUint32(descriptor[140:144], w.jolietLPathTableLba)
+		binary.BigEndian.PutUint32(descriptor[148:152], w.jolietMPathTableLba)
+	} else {
+		putBothUint32(descriptor[132:140], w.isoPathTableSize)
+		binary.LittleEndian.PutUint32(descriptor[140:144], w.isoLPathTableLba)
+		binary.BigEndian.PutUint32(descriptor[148:152], w.isoMPathTableLba)
+	}
+
+	rootRecord := &isoDirRecord{target: w.root, identifier: []byte{0}, joliet: joliet}
+	w.putDirRecord(descriptor[156:190], rootRecord)
+
+	for _, field := range [][2]int{{190, 318}, {318, 446}, {446, 574}, {574, 702}, {702, 739}, {739, 776}, {776, 813}} {
+		putString(descriptor[field[0]:field[1]], "")
+	}
+
+	putVolumeDateTime(descriptor[813:830], &w.creationTime)
+	putVolumeDateTime(descriptor[830:847], &w.creationTime)
+	putVolumeDateTime(descriptor[847:864], nil)
+	putVolumeDateTime(descriptor[864:881], nil)
+	descriptor[881] = 1
+
+	return descriptor
+}
+
+func (w *isoWriter) bootRecordVolumeDescriptor() []byte {
+	descriptor := make([]byte, isoSectorSize)
+	descriptor[0] = 0
+	copy(descriptor[1:6], isoStandardId)
+	descriptor[6] = 1
+	copy(descriptor[7:39], elToritoSystemId)
+	binary.LittleEndian.PutUint32(descriptor[71:75], w.bootCatalogLba)
+	return descriptor
+}
+
+func volumeDescriptorSetTerminator() []byte {
+	descriptor := make([]byte, isoSectorSize)
+	descriptor[0] = 255
+	copy(descriptor[1:6], isoStandardId)
+	descriptor[6] = 1
+	return descriptor
+}
+
+func (w *isoWriter) bootCatalog() []byte {
+	catalog := make([]byte, isoSectorSize)
+
+	// Validation entry.
+	validation := catalog[0:32]
+	validation[0] = 1
+	validation[1] = elToritoPlatformEfi
+	validation[30] = 0x55
+	validation[31] = 0xAA
+
+	var sum uint16
+	for i := 0; i < len(validation); i += 2 {
+		sum += binary.LittleEndian.Uint16(validation[i:])
+	}
+	binary.LittleEndian.PutUint16(validation[28:30], -sum)
+
+	// Default entry.
+	putElToritoBootEntry(catalog[32:64], w.bootNodes[0])
+
+	// The other images are the entries of a single (final) section.
+	if len(w.bootNodes) > 1 {
+		header := catalog[64:96]
+		header[0] = 0x91
+		header[1] = elToritoPlatformEfi
+		binary.LittleEndian.PutUint16(header[2:4], uint16(len(w.bootNodes)-1))
+
+		for i, bootNode := range w.bootNodes[1:] {
+			putElToritoBootEntry(catalog[96+32*i:128+32*i], bootNode)
+		}
+	}
+
+	return catalog
+}
+
+// putElToritoBootEntry writes a bootable, no emulation, boot entry.
+func putElToritoBootEntry(entry []byte, bootNode *isoNode) {
+	entry[0] = 0x88
+
+	// The firmware loads the whole image, unless it doesn't fit in the 16-bit sector count. In that case, the count is
+	// left to 0, which UEFI firmwares treat as "up to the end of the media" (the FAT file system of the image knows its
+	// own size).
+	bootSectors := (uint64(bootNode.info.Size()) + elToritoSectorSize - 1) / elToritoSectorSize
+	if bootSectors <= 0xFFFF {
+		binary.LittleEndian.PutUint16(entry[6:8], uint16(bootSectors))
+	}
+	binary.LittleEndian.PutUint32(entry[8:12], bootNode.dataLba)
+}
+
+func pathTableSize(dirs []*isoNode, identifier func(*isoNode) []byte) uint32 {
+	size := 0
+	for _, dir := range dirs {
+		nameLen := len(identifier(dir))
+		size += 8 + nameLen + nameLen%2
+	}
+	return uint32(size)
+}
+
+func isoPathIdentifier(dir *isoNode) []byte {
+	if dir.parent == dir {
+		return []byte{0}
+	}
+	return []byte(dir.isoName)
+}
+
+func jolietPathIdentifier(dir *isoNode) []byte {
+	if dir.parent == dir {
+		return []byte{0}
+	}
+	return dir.jolietName
+}
+
+func (w *isoWriter) pathTable(dirs []*isoNode, byteOrder binary.ByteOrder, joliet bool) []byte {
+	table := bytes.Buffer{}
+	for _, dir := range dirs {
+		identifier := isoPathIdentifier(dir)
+		lba := dir.isoDirLba
+		parentNumber := dir.parent.isoPathNumber
+		if joliet {
+			identifier = jolietPathIdentifier(dir)
+			lba = dir.jolietDirLba
+			parentNumber = dir.parent.jolietPathNumber
+		}
+
+		record := make([]byte, 8+len(identifier)+len(identifier)%2)
+		record[0] = byte(len(identifier))
+		byteOrder.PutUint32(record[2:6], lba)
+		byteOrder.PutUint16(record[6:8], parentNumber)
+		copy(record[8:], identifier)
+		table.Write(record)
+	}
+	return table.Bytes()
+}
+
+// dirExtentSize returns the size of a folder's extent. A directory record can't span two sectors.
+func dirExtentSize(records []*isoDirRecord) uint32 {
+	sectors := uint32(1)
+	offset := 0
+	for _, record := range records {
+		recordLen := record.length()
+		if offset+recordLen > isoSectorSize {
+			sectors++
+			offset = 0
+		}
+		offset += recordLen
+	}
+	return sectors * isoSectorSize
+}
+
+func (w *isoWriter) dirExtent(records []*isoDirRecord) []byte {
+	extent := []byte{}
+	sector := make([]byte, 0, isoSectorSize)
+	for _, record := range records {
+		recordLen := record.length()
+		if len(sector)+recordLen > isoSectorSize {
+			extent = append(extent, sector...)
+			extent = append(extent, make([]byte, isoSectorSize-len(sector))...)
+			sector = sector[:0]
+		}
+
+		buffer := make([]byte, recordLen)
+		w.putDirRecord(buffer, record)
+		sector = append(sector, buffer...)
+	}
+	return append(extent, sector...)
+}
+
+func isoDirRecordHeaderSize(identifier []byte) int {
+	return isoDirRecordBaseSize + len(identifier) + (len(identifier)+1)%2
+}
+
+func (r *isoDirRecord) length() int {
+	length := isoDirRecordHeaderSize(r.identifier) + len(r.systemUse)
+	if r.continuation != nil {
+		length += suspCeEntryLen
+	}
+	// A directory record has an even size.
+	return length + length%2
+}
+
+func (w *isoWriter) putDirRecord(buffer []byte, record *isoDirRecord) {
+	node := record.target
+
+	var lba uint32
+	var size uint64
+	var flags byte
+	switch {
+	case node.isDir():
+		lba, size = node.isoDirLba, uint64(node.isoDirSize)
+		if record.joliet {
+			lba, size = node.jolietDirLba, uint64(node.jolietDirSize)
+		}
+		flags = isoDirFlagDirectory
+
+	case node.info.Mode().IsRegular():
+		lba, size, flags = fileExtent(node, record.extentIndex)
+	}
+
+	buffer[0] = byte(record.length())
+	putBothUint32(buffer[2:10], lba)
+	putBothUint32(buffer[10:18], uint32(size))
+	putRecordDateTime(buffer[18:25], w.nodeTime(node))
+	buffer[25] = flags
+	putBothUint16(buffer[28:32], 1)
+	buffer[32] = byte(len(record.identifier))
+	copy(buffer[33:], record.identifier)
+
+	systemUse := buffer[isoDirRecordHeaderSize(record.identifier):]
+	copy(systemUse, record.systemUse)
+	if record.continuation != nil {
+		ce := systemUse[len(record.systemUse):]
+		ce[0], ce[1], ce[2], ce[3] = 'C', 'E', suspCeEntryLen, 1
+		putBothUint32(ce[4:12], w.continuationLba+record.continuation.sector)
+		putBothUint32(ce[12:20], record.continuation.offset)
+		putBothUint32(ce[20:28], uint32(len(record.continuation.entries)))
+	}
+}
+
+func extentCount(node *isoNode) int {
+	if !node.info.Mode().IsRegular() || node.info.Size() == 0 {
+		return 1
+	}
+	return int((uint64(node.info.Size()) + isoMaxExtentSize - 1) / isoMaxExtentSize)
+}
+
+// fileExtent returns the location, the size and the flags of one of the extents of a file.
+func fileExtent(node *isoNode, extentIndex int) (uint32, uint64, byte) {
+	offset := uint64(extentIndex) * isoMaxExtentSize
+	size := uint64(node.info.Size()) - offset
+	flags := byte(0)
+	if size > isoMaxExtentSize {
+		size = isoMaxExtentSize
+		flags = isoDirFlagMultiExtent
+	}
+
+	lba := node.dataLba
+	if size > 0 {
+		lba += uint32(offset / isoSectorSize)
+	}
+	return lba, size, flags
+}
+
+func (w *isoWriter) nodeTime(node *isoNode) time.Time {
+	if w.fileTime != nil {
+		return *w.fileTime
+	}
+	return node.info.ModTime().UTC()
+}
+
+func suspSpEntry() []byte {
+	return []byte{'S', 'P', 7, 1, 0xBE, 0xEF, 0}
+}
+
+func suspErEntry() []byte {
+	entry := []byte{'E', 'R', 0, 1, byte(len(rripExtensionId)), byte(len(rripExtensionDescription)),
+		byte(len(rripExtensionSource)), 1}
+	entry = append(entry, rripExtensionId...)
+	entry = append(entry, rripExtensionDescription...)
+	entry = append(entry, rripExtensionSource...)
+	entry[2] = byte(len(entry))
+	return entry
+}
+
+func (w *isoWriter) rripPxEntry(node *isoNode) []byte {
+	var mode, uid, gid uint32
+	if stat, ok := node.info.Sys().(*syscall.Stat_t); ok {
+		mode, uid, gid = stat.Mode, stat.Uid, stat.Gid
+	} else {
+		mode = uint32(node.info.Mode().Perm())
+	}
+
+	links := uint32(1)
+	if node.isDir() {
+		links = 2
+		for _, child := range node.children {
+			if child.isDir() {
+				links++
+			}
+		}
+	}
+
+	entry := make([]byte, 36)
+	entry[0], entry[1], entry[2], entry[3] = 'P', 'X', 36, 1
+	putBothUint32(entry[4:12], mode)
+	putBothUint32(entry[12:20], links)
+	putBothUint32(entry[20:28], uid)
+	putBothUint32(entry[28:36], gid)
+	return entry
+}
+
+// rripZfEntry encodes the zisofs compression of a file.
+func rripZfEntry(header *zisofsHeader) []byte {
+	entry := make([]byte, 16)
+	entry[0], entry[1], entry[2], entry[3] = 'Z', 'F', 16, 1
+	entry[4], entry[5] = 'p', 'z'
+	entry[6] = header.headerSizeDiv4
+	entry[7] = header.blockSizeLog2
+	putBothUint32(entry[8:16], header.uncompressedSize)
+	return entry
+}
+
+func (w *isoWriter) rripTfEntry(node *isoNode) []byte {
+	const (
+		tfModify     = 0x02
+		tfAccess     = 0x04
+		tfAttributes = 0x08
+	)
+
+	entry := make([]byte, 5+3*7)
+	entry[0], entry[1], entry[2], entry[3] = 'T', 'F', byte(len(entry)), 1
+	entry[4] = tfModify | tfAccess | tfAttributes
+
+	nodeTime := w.nodeTime(node)
+	for i := 0; i < 3; i++ {
+		putRecordDateTime(entry[5+i*7:12+i*7], nodeTime)
+	}
+	return entry
+}
+
+// rripNmEntries encodes a name as NM entries. Long names are split over multiple entries.
+func rripNmEntries(name string) [][]byte {
+	const (
+		nmContinue   = 0x01
+		nmMaxNameLen = isoDirRecordMaxSize - 5
+	)
+
+	var entries [][]byte
+	for {
+		part, flags := name, byte(0)
+		if len(part) > nmMaxNameLen {
+			part, flags = name[:nmMaxNameLen], nmContinue
+		}
+
+		entry := []byte{'N', 'M', byte(5 + len(part)), 1, flags}
+		entries = append(entries, append(entry, part...))
+
+		name = name[len(part):]
+		if name == "" {
+			return entries
+		}
+	}
+}
+
+// rripSlEntries encodes a symlink target as SL entries. Long targets are split over multiple entries.
+func rripSlEntries(target string) ([][]byte, error) {
+	const (
+		slComponentContinue = 0x01
+		slComponentCurrent  = 0x02
+		slComponentParent   = 0x04
+		slComponentRoot     = 0x08
+
+		slMaxComponentsSize = isoDirRecordMaxSize - isoDirRecordBaseSize - 1 - 5
+	)
+
+	var components [][]byte
+	if strings.HasPrefix(target, "/") {
+		components = append(components, []byte{slComponentRoot, 0})
+	}
+	for _, part := range strings.Split(target, "/") {
+		switch part {
+		case "":
+			continue
+
+		case ".":
+			components = append(components, []byte{slComponentCurrent, 0})
+
+		case "..":
+			components = append(components, []byte{slComponentParent, 0})
+
+		default:
+			if len(part) > slMaxComponentsSize-2 {
+				return nil, fmt.Errorf("symlink target component is too long (%d bytes)", len(part))
+			}
+			components = append(components, append([]byte{0, byte(len(part))}, part...))
+		}
+	}
+
+	var entries [][]byte
+	var current []byte
+	for _, component := range components {
+		if len(current)+len(component) > slMaxComponentsSize {
+			entries = append(entries, current)
+			current = nil
+		}
+		current = append(current, component...)
+	}
+	entries = append(entries, current)
+
+	for i, components := range entries {
+		flags := byte(0)
+		if i < len(entries)-1 {
+			flags = slComponentContinue
+		}
+		entry := []byte{'S', 'L', byte(5 + len(components)), 1, flags}
+		entries[i] = append(entry, components...)
+	}
+	return entries, nil
+}
+
+// uniqueIsoName returns an ISO 9660 (level 2) name for a file or a folder, that is unique within its folder.
+func uniqueIsoName(name string, isDir bool, usedNames map[string]bool) string {
+	mapped := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '_' || r == '.':
+			return r
+		default:
+			return '_'
+		}
+	}, name)
+
+	base, ext := mapped, ""
+	maxLen := isoMaxDirNameLen
+	if isDir {
+		base = strings.ReplaceAll(base, ".", "_")
+	} else {
+		maxLen = isoMaxFileNameLen - 1
+		if index := strings.LastIndex(mapped, "."); index >= 0 {
+			base, ext = mapped[:index], mapped[index+1:]
+		}
+		base = strings.ReplaceAll(base, ".", "_")
+		if len(ext) > maxLen/2 {
+			ext = ext[:maxLen/2]
+		}
+	}
+
+	for counter := 0; ; counter++ {
+		suffix := ""
+		if counter > 0 {
+			suffix = fmt.Sprintf("_%d", counter)
+		}
+
+		candidateBase := base
+		if len(candidateBase)+len(suffix)+len(ext) > maxLen {
+			candidateBase = candidateBase[:maxLen-len(suffix)-len(ext)]
+		}
+
+		candidate := candidateBase + suffix
+		if !isDir {
+			candidate += "." + ext
+		}
+
+		if !usedNames[candidate] {
+			usedNames[candidate] = true
+			return candidate
+		}
+	}
+}
+
+// compareIsoIdentifiers compares two ISO 9660 names: by name, then by extension, each padded with spaces.
+func compareIsoIdentifiers(a string, b string) int {
+	aBase, aExt, _ := strings.Cut(a, ".")
+	bBase, bExt, _ := strings.Cut(b, ".")
+
+	result := comparePadded(aBase, bBase)
+	if result != 0 {
+		return result
+	}
+	return comparePadded(aExt, bExt)
+}
+
+func comparePadded(a string, b string) int {
+	length := max(len(a), len(b))
+	return strings.Compare(a+strings.Repeat(" ", length-len(a)), b+strings.Repeat(" ", length-len(b)))
+}
+
+// jolietIdentifier returns the Joliet (UCS-2, big endian) name of a file or a folder.
+func jolietIdentifier(name string, isDir bool) ([]byte, error) {
+	for _, r := range name {
+		if strings.ContainsRune("*/:;?\\", r) || r < 0x20 {
+			return nil, fmt.Errorf("name (%s) has a character that Joliet doesn't support (%q)", name, r)
+		}
+	}
+
+	units := utf16.Encode([]rune(name))
+	if len(units) > jolietMaxNameLen {
+		// Like mkisofs, truncate the name. Linux and grub use the (complete) Rock Ridge name.
+		logger.Log.Warnf("Truncating the Joliet name of (%s) to (%d) characters", name, jolietMaxNameLen)
+		units = units[:jolietMaxNameLen]
+		if utf16.IsSurrogate(rune(units[len(units)-1])) {
+			units = units[:len(units)-1]
+		}
+	}
+	if !isDir {
+		units = append(units, ';', '1')
+	}
+
+	identifier := make([]byte, 2*len(units))
+	for i, unit := range units {
+		binary.BigEndian.PutUint16(identifier[2*i:], unit)
+	}
+	return identifier, nil
+}
+
+func sectorCount(size uint64) uint32 {
+	return uint32((size + isoSectorSize - 1) / isoSectorSize)
+}
+
+func putBothUint16(buffer []byte, value uint16) {
+	binary.LittleEndian.PutUint16(buffer[0:2], value)
+	binary.BigEndian.PutUint16(buffer[2:4], value)
+}
+
+func putBothUint32(buffer []byte, value uint32) {
+	binary.LittleEndian.PutUint32(buffer[0:4], value)
+	binary.BigEndian.PutUint32(buffer[4:8], value)
+}
+
+func putPaddedString(field []byte, value string) {
+	for i := range field {
+		field[i] = ' '
+	}
+	copy(field, strings.ToUpper(value))
+}
+
+func putJolietString(field []byte, value string) {
+	for i := 0; i+1 < len(field); i += 2 {
+		field[i], field[i+1] = 0, ' '
+	}
+	units := utf16.Encode([]rune(value))
+	for i, unit := range units {
+		if 2*i+1 >= len(field) {
+			break
+		}
+		binary.BigEndian.PutUint16(field[2*i:], unit)
+	}
+}
+
+// putRecordDateTime writes a 7-byte (directory record) UTC timestamp.
+func putRecordDateTime(buffer []byte, t time.Time) {
+	t = t.UTC()
+	year := t.Year() - 1900
+	if year < 0 {
+		year = 0
+	} else if year > 255 {
+		year = 255
+	}
+	buffer[0] = byte(year)
+	buffer[1] = byte(t.Month())
+	buffer[2] = byte(t.Day())
+	buffer[3] = byte(t.Hour())
+	buffer[4] = byte(t.Minute())
+	buffer[5] = byte(t.Second())
+	buffer[6] = 0
+}
+
+// putVolumeDateTime writes a 17-byte (volume descriptor) UTC timestamp. A nil time is written as "not specified".
+func putVolumeDateTime(buffer []byte, t *time.Time) {
+	if t == nil {
+		copy(buffer, "0000000000000000")
+		buffer[16] = 0
+		return
+	}
+
+	utc := t.UTC()
+	copy(buffer, fmt.Sprintf("%04d%02d%02d%02d%02d%02d%02d", utc.Year(), utc.Month(), utc.Day(), utc.Hour(),
+		utc.Minute(), utc.Second(), utc.Nanosecond()/10000000))
+	buffer[16] = 0
+}
+
+// isoSectorWriter writes sector aligned data, and keeps the first error.
+type isoSectorWriter struct {
+	writer  *bufio.Writer
+	written uint64
+	err     error
+}
+
+func (s *isoSectorWriter) write(data []byte) {
+	if s.err != nil {
+		return
+	}
+	_, s.err = s.writer.Write(data)
+	s.written += uint64(len(data))
+}
+
+// writeSector writes data padded to the next sector boundary.
+func (s *isoSectorWriter) writeSector(data []byte) {
+	s.write(data)
+	s.pad()
+}
+
+func (s *isoSectorWriter) pad() {
+	if remainder := s.written % isoSectorSize; remainder != 0 {
+		s.write(make([]byte, isoSectorSize-remainder))
+	}
+}
+
+func (s *isoSectorWriter) checkLba(lba uint32) {
+	if s.err == nil && s.written != uint64(lba)*isoSectorSize {
+		s.err = fmt.Errorf("internal error: expected sector (%d), at offset (%d)", lba, s.written)
+	}
+}
+
+func (s *isoSectorWriter) writeFile(sourcePath string, size int64) error {
+	source, err := os.Open(sourcePath)
+	if err != nil {
+		return fmt.Errorf("failed to open (%s):\n%w", sourcePath, err)
+	}
+	defer source.Close()
+
+	copied, err := io.CopyN(s.writer, source, size)
+	s.written += uint64(copied)
+	if err != nil {
+		return fmt.Errorf("failed to copy (%s) to the ISO image (the file may have changed):\n%w", sourcePath, err)
+	}
+
+	s.pad()
+	return s.err
+}
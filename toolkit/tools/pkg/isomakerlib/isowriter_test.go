@@ -0,0 +1,221 @@
+// Copyright Microsoft Corporation.
+// Licensed under the MIT License.
+
+package isomakerlib
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/logger"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMain(m *testing.M) {
+	logger.InitStderrLog()
+	os.Exit(m.Run())
+}
+
+func TestWriteNativeIsoImage(t *testing.T) {
+	testDir := t.TempDir()
+	sourceDir := filepath.Join(testDir, "iso-root")
+
+	longName := strings.Repeat("long-file-name-", 4) + ".txt"
+	testFiles := map[string]string{
+		"boot/grub2/efiboot.img": strings.Repeat("\xAA", 5000),
+		"boot/grub2/grub.cfg":    "menuentry test {}\n",
+		"boot/vmlinuz":           "kernel",
+		"liveos/rootfs.img":      "rootfs",
+		"empty":                  "",
+		longName:                 "long",
+	}
+	for path, content := range testFiles {
+		fullPath := filepath.Join(sourceDir, path)
+		err := os.MkdirAll(filepath.Dir(fullPath), os.ModePerm)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		err = os.WriteFile(fullPath, []byte(content), 0o644)
+		if !assert.NoError(t, err) {
+			return
+		}
+	}
+
+	err := os.Symlink("boot/vmlinuz", filepath.Join(sourceDir, "vmlinuz"))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	sourceDateEpoch := time.Unix(1700000000, 0)
+	isoImagePath := filepath.Join(testDir, "test.iso")
+	err = writeNativeIsoImage(sourceDir, []string{efiBootImgPathRelativeToIsoRoot}, DefaultVolumeId, &sourceDateEpoch,
+		false /*zisofs*/, isoImagePath)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	image, err := os.ReadFile(isoImagePath)
+	if !assert.NoError(t, err) {
+		return
+	}
+	if !assert.Zero(t, len(image)%isoSectorSize) {
+		return
+	}
+
+	sector := func(lba uint32) []byte {
+		return image[lba*isoSectorSize : (lba+1)*isoSectorSize]
+	}
+
+	// Primary volume descriptor.
+	primary := sector(16)
+	assert.Equal(t, byte(1), primary[0])
+	assert.Equal(t, "CD001", string(primary[1:6]))
+	assert.Equal(t, DefaultVolumeId, strings.TrimRight(string(primary[40:72]), " "))
+	assert.Equal(t, uint32(len(image)/isoSectorSize), binary.LittleEndian.Uint32(primary[80:84]))
+	assert.Equal(t, "2023111422132000", string(primary[813:829]))
+
+	// The root folder starts with the SUSP 'SP' entry.
+	rootLba := binary.LittleEndian.Uint32(primary[156+2 : 156+6])
+	rootDir := sector(rootLba)
+	assert.Equal(t, []byte{'S', 'P', 7, 1, 0xBE, 0xEF}, rootDir[34:40])
+
+	// Boot record and El Torito boot catalog.
+	bootRecord := sector(17)
+	assert.Equal(t, byte(0), bootRecord[0])
+	assert.Equal(t, elToritoSystemId, strings.TrimRight(string(bootRecord[7:39]), "\x00"))
+
+	catalog := sector(binary.LittleEndian.Uint32(bootRecord[71:75]))
+	assert.Equal(t, byte(elToritoPlatformEfi), catalog[1])
+	assert.Equal(t, []byte{0x55, 0xAA}, catalog[30:32])
+	var sum uint16
+	for i := 0; i < 32; i += 2 {
+		sum += binary.LittleEndian.Uint16(catalog[i:])
+	}
+	assert.Zero(t, sum)
+
+	assert.Equal(t, byte(0x88), catalog[32])
+	assert.Equal(t, uint16(10), binary.LittleEndian.Uint16(catalog[38:40]))
+	bootImageLba := binary.LittleEndian.Uint32(catalog[40:44])
+	assert.Equal(t, testFiles["boot/grub2/efiboot.img"], string(image[bootImageLba*isoSectorSize:][:5000]))
+
+	// Joliet volume descriptor.
+	joliet := sector(18)
+	assert.Equal(t, byte(2), joliet[0])
+	assert.Equal(t, jolietEscapeSequence, joliet[88:91])
+
+	assert.Equal(t, byte(255), sector(19)[0])
+
+	// The file names (Rock Ridge) and the file contents are in the image.
+	for _, expected := range []string{longName, "rootfs.img", "menuentry test {}"} {
+		assert.True(t, bytes.Contains(image, []byte(expected)), expected)
+	}
+
+	// The build is reproducible.
+	otherIsoImagePath := filepath.Join(testDir, "other.iso")
+	err = writeNativeIsoImage(sourceDir, []string{efiBootImgPathRelativeToIsoRoot}, DefaultVolumeId, &sourceDateEpoch,
+		false /*zisofs*/, otherIsoImagePath)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	otherImage, err := os.ReadFile(otherIsoImagePath)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, image, otherImage)
+}
+
+func TestWriteNativeIsoImageMissingBootImage(t *testing.T) {
+	testDir := t.TempDir()
+
+	err := writeNativeIsoImage(testDir, []string{efiBootImgPathRelativeToIsoRoot}, DefaultVolumeId, nil,
+		false /*zisofs*/, filepath.Join(t.TempDir(), "test.iso"))
+	assert.ErrorContains(t, err, "EFI boot image (boot/grub2/efiboot.img) not found")
+}
+
+func TestUniqueIsoName(t *testing.T) {
+	usedNames := map[string]bool{}
+	assert.Equal(t, "VMLINUZ.", uniqueIsoName("vmlinuz", false, usedNames))
+	assert.Equal(t, "INITRD.IMG", uniqueIsoName("initrd.img", false, usedNames))
+	assert.Equal(t, "INITRD_1.IMG", uniqueIsoName("INITRD.img", false, usedNames))
+	assert.Equal(t, "GRUB2", uniqueIsoName("grub2", true, usedNames))
+	assert.Equal(t, "EFI_BOOT", uniqueIsoName("efi.boot", true, usedNames))
+	assert.Equal(t, "A_B_C.TAR", uniqueIsoName("a-b.c.tar", false, usedNames))
+
+	longName := uniqueIsoName(strings.Repeat("x", 40)+".json", false, usedNames)
+	assert.Equal(t, strings.Repeat("X", 25)+".JSON", longName)
+}
+
+func TestCompareIsoIdentifiers(t *testing.T) {
+	assert.Negative(t, compareIsoIdentifiers("A.B", "A_C."))
+	assert.Negative(t, compareIsoIdentifiers("BOOT", "EFI"))
+	assert.Zero(t, compareIsoIdentifiers("A.B", "A.B"))
+}
+
+func TestRripSlEntries(t *testing.T) {
+	entries, err := rripSlEntries("/usr/../lib")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	expected := []byte{'S', 'L', 19, 1, 0,
+		0x08, 0,
+		0, 3, 'u', 's', 'r',
+		0x04, 0,
+		0, 3, 'l', 'i', 'b'}
+	assert.Equal(t, [][]byte{expected}, entries)
+}
+
+func TestWriteNativeIsoImageMultipleBootImages(t *testing.T) {
+	testDir := t.TempDir()
+	sourceDir := filepath.Join(testDir, "iso-root")
+
+	bootImages := []string{efiBootImgPathRelativeToIsoRoot, efiBootImgPathRelativeToIsoRootForArch(IsoArchAarch64)}
+	for i, bootImage := range bootImages {
+		fullPath := filepath.Join(sourceDir, bootImage)
+		err := os.MkdirAll(filepath.Dir(fullPath), os.ModePerm)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		err = os.WriteFile(fullPath, bytes.Repeat([]byte{byte(i + 1)}, 1024*(i+1)), 0o644)
+		if !assert.NoError(t, err) {
+			return
+		}
+	}
+
+	isoImagePath := filepath.Join(testDir, "test.iso")
+	err := writeNativeIsoImage(sourceDir, bootImages, DefaultVolumeId, nil, false /*zisofs*/, isoImagePath)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	image, err := os.ReadFile(isoImagePath)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	bootRecord := image[17*isoSectorSize:]
+	catalog := image[binary.LittleEndian.Uint32(bootRecord[71:75])*isoSectorSize:]
+
+	// Default entry.
+	assert.Equal(t, byte(0x88), catalog[32])
+	assert.Equal(t, uint16(2), binary.LittleEndian.Uint16(catalog[38:40]))
+
+	// Final section header, with one entry.
+	assert.Equal(t, byte(0x91), catalog[64])
+	assert.Equal(t, byte(elToritoPlatformEfi), catalog[65])
+	assert.Equal(t, uint16(1), binary.LittleEndian.Uint16(catalog[66:68]))
+
+	// Section entry.
+	assert.Equal(t, byte(0x88), catalog[96])
+	assert.Equal(t, uint16(4), binary.LittleEndian.Uint16(catalog[102:104]))
+	lba := binary.LittleEndian.Uint32(catalog[104:108])
+	assert.Equal(t, bytes.Repeat([]byte{2}, 2048), image[lba*isoSectorSize:][:2048])
+}
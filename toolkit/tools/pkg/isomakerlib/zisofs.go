@@ -0,0 +1,267 @@
+// Copyright Microsoft Corporation.
+// Licensed under the MIT License.
+
+package isomakerlib
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/logger"
+)
+
+// zisofs is the transparent file compression of ISO9660 (Rock Ridge 'ZF' entries), supported by the Linux kernel. A
+// compressed file starts with a header and a table of block pointers, followed by the zlib streams of the file's
+// blocks. The format is the one of 'mkzftree', which 'mkisofs -z' and 'xorriso -as mkisofs -z' expect.
+const (
+	zisofsHeaderSize    = 16
+	zisofsBlockSizeLog2 = 15
+	zisofsBlockSize     = 1 << zisofsBlockSizeLog2
+	// The uncompressed size is stored as a 32-bit number.
+	zisofsMaxFileSize = 0xFFFFFFFF
+)
+
+var zisofsMagic = []byte{0x37, 0xE4, 0x53, 0x96, 0xC9, 0xDB, 0xD6, 0x07}
+
+// zisofsHeader is the header of a zisofs compressed file.
+type zisofsHeader struct {
+	uncompressedSize uint32
+	headerSizeDiv4   byte
+	blockSizeLog2    byte
+}
+
+// readZisofsHeader returns the zisofs header of a file, or nil if the file isn't zisofs compressed.
+func readZisofsHeader(path string) (*zisofsHeader, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	header := make([]byte, zisofsHeaderSize)
+	_, err = io.ReadFull(file, header)
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if !bytes.Equal(header[:len(zisofsMagic)], zisofsMagic) {
+		return nil, nil
+	}
+
+	return &zisofsHeader{
+		uncompressedSize: binary.LittleEndian.Uint32(header[8:12]),
+		headerSizeDiv4:   header[12],
+		blockSizeLog2:    header[13],
+	}, nil
+}
+
+// compressZisofsFile compresses a file in place. The file keeps its mode, owner and modification time. Returns false
+// (and leaves the file as-is) if the file is empty, too large, already compressed, or doesn't shrink.
+func compressZisofsFile(path string) (bool, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return false, fmt.Errorf("failed to stat (%s):\n%w", path, err)
+	}
+
+	if info.Size() == 0 || info.Size() > zisofsMaxFileSize {
+		return false, nil
+	}
+
+	header, err := readZisofsHeader(path)
+	if err != nil {
+		return false, fmt.Errorf("failed to read (%s):\n%w", path, err)
+	}
+	if header != nil {
+		return false, nil
+	}
+
+	compressedPath := path + ".zisofs"
+	compressedSize, err := writeZisofsFile(path, info.Size(), compressedPath)
+	if err != nil {
+		os.Remove(compressedPath)
+		return false, fmt.Errorf("failed to compress (%s):\n%w", path, err)
+	}
+
+	if compressedSize >= info.Size() {
+		return false, os.Remove(compressedPath)
+	}
+
+	err = os.Chmod(compressedPath, info.Mode())
+	if err == nil {
+		err = copyFileOwner(path, compressedPath)
+	}
+	if err == nil {
+		err = os.Chtimes(compressedPath, info.ModTime(), info.ModTime())
+	}
+	if err == nil {
+		err = os.Rename(compressedPath, path)
+	}
+	if err != nil {
+		os.Remove(compressedPath)
+		return false, fmt.Errorf("failed to replace (%s) with its compressed version:\n%w", path, err)
+	}
+
+	return true, nil
+}
+
+// writeZisofsFile writes the zisofs compressed version of a file and returns its size.
+func writeZisofsFile(sourcePath string, size int64, compressedPath string) (int64, error) {
+	source, err := os.Open(sourcePath)
+	if err != nil {
+		return 0, err
+	}
+	defer source.Close()
+
+	compressed, err := os.Create(compressedPath)
+	if err != nil {
+		return 0, err
+	}
+	defer compressed.Close()
+
+	blockCount := (size + zisofsBlockSize - 1) / zisofsBlockSize
+	pointersSize := (blockCount + 1) * 4
+
+	header := make([]byte, zisofsHeaderSize)
+	copy(header, zisofsMagic)
+	binary.LittleEndian.PutUint32(header[8:12], uint32(size))
+	header[12] = zisofsHeaderSize / 4
+	header[13] = zisofsBlockSizeLog2
+
+	_, err = compressed.Write(header)
+	if err != nil {
+		return 0, err
+	}
+
+	// The block pointers are written once the blocks' sizes are known.
+	_, err = compressed.Seek(pointersSize, io.SeekCurrent)
+	if err != nil {
+		return 0, err
+	}
+
+	pointers := make([]byte, pointersSize)
+	offset := int64(zisofsHeaderSize) + pointersSize
+	binary.LittleEndian.PutUint32(pointers, uint32(offset))
+
+	block := make([]byte, zisofsBlockSize)
+	zeroBlock := make([]byte, zisofsBlockSize)
+	compressedBlock := bytes.Buffer{}
+	for i := int64(0); i < blockCount; i++ {
+		n, err := io.ReadFull(source, block)
+		if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+			return 0, err
+		}
+
+		// A block of zeros is stored as an empty block.
+		if !bytes.Equal(block[:n], zeroBlock[:n]) {
+			compressedBlock.Reset()
+			zlibWriter, err := zlib.NewWriterLevel(&compressedBlock, zlib.BestCompression)
+			if err != nil {
+				return 0, err
+			}
+
+			_, err = zlibWriter.Write(block[:n])
+			if err != nil {
+				return 0, err
+			}
+
+			err = zlibWriter.Close()
+			if err != nil {
+				return 0, err
+			}
+
+			_, err = compressed.Write(compressedBlock.Bytes())
+			if err != nil {
+				return 0, err
+			}
+			offset += int64(compressedBlock.Len())
+		}
+
+		binary.LittleEndian.PutUint32(pointers[(i+1)*4:], uint32(offset))
+	}
+
+	_, err = compressed.WriteAt(pointers, zisofsHeaderSize)
+	if err != nil {
+		return 0, err
+	}
+
+	err = compressed.Close()
+	if err != nil {
+		return 0, err
+	}
+
+	return offset, nil
+}
+
+// compressZisofsPaths compresses the files under the ISO paths selected for zisofs compression (see
+// IsoMakerOptions.ZisofsPaths). Returns whether any file was compressed.
+//
+// The files under 'boot' (e.g. the EFI boot images, the kernel and the initrd) and under the BIOS bootloader folder are
+// skipped, since the firmware and the bootloaders can't read compressed files.
+func (im *IsoMaker) compressZisofsPaths() (bool, error) {
+	if len(im.zisofsPaths) == 0 {
+		return false, nil
+	}
+
+	skippedDirs := []string{"boot", im.osFilesPath}
+
+	compressedAny := false
+	compressedSize := int64(0)
+	uncompressedSize := int64(0)
+	for _, zisofsPath := range im.zisofsPaths {
+		rootPath := filepath.Join(im.buildDirPath, zisofsPath)
+		err := filepath.Walk(rootPath, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if !info.Mode().IsRegular() {
+				return nil
+			}
+
+			relativePath, err := filepath.Rel(im.buildDirPath, path)
+			if err != nil {
+				return err
+			}
+
+			for _, skippedDir := range skippedDirs {
+				if strings.HasPrefix(relativePath, skippedDir+string(filepath.Separator)) {
+					logger.Log.Debugf("Not compressing boot file (%s).", relativePath)
+					return nil
+				}
+			}
+
+			compressed, err := compressZisofsFile(path)
+			if err != nil {
+				return err
+			}
+
+			if compressed {
+				compressedInfo, err := os.Stat(path)
+				if err != nil {
+					return err
+				}
+
+				compressedAny = true
+				uncompressedSize += info.Size()
+				compressedSize += compressedInfo.Size()
+			}
+
+			return nil
+		})
+		if err != nil {
+			return false, fmt.Errorf("failed to compress ISO path (%s):\n%w", zisofsPath, err)
+		}
+	}
+
+	logger.Log.Infof("Compressed ISO files (zisofs) from %d to %d bytes.", uncompressedSize, compressedSize)
+	return compressedAny, nil
+}
@@ -0,0 +1,181 @@
+// Copyright Microsoft Corporation.
+// Licensed under the MIT License.
+
+package isomakerlib
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// decompressZisofs decompresses the contents of a zisofs compressed file.
+func decompressZisofs(t *testing.T, compressed []byte) []byte {
+	size := binary.LittleEndian.Uint32(compressed[8:12])
+	headerSize := int(compressed[12]) * 4
+	blockSize := 1 << compressed[13]
+	blockCount := (int(size) + blockSize - 1) / blockSize
+
+	decompressed := []byte(nil)
+	for i := 0; i < blockCount; i++ {
+		start := binary.LittleEndian.Uint32(compressed[headerSize+i*4:])
+		end := binary.LittleEndian.Uint32(compressed[headerSize+(i+1)*4:])
+		if start == end {
+			decompressed = append(decompressed, make([]byte, min(blockSize, int(size)-len(decompressed)))...)
+			continue
+		}
+
+		zlibReader, err := zlib.NewReader(bytes.NewReader(compressed[start:end]))
+		if !assert.NoError(t, err) {
+			return nil
+		}
+
+		block, err := io.ReadAll(zlibReader)
+		if !assert.NoError(t, err) {
+			return nil
+		}
+		decompressed = append(decompressed, block...)
+	}
+
+	return decompressed
+}
+
+func TestCompressZisofsFile(t *testing.T) {
+	testDir := t.TempDir()
+
+	// Compressible blocks, a block of zeros, and a partial last block.
+	content := []byte(strings.Repeat("Azure Linux documentation. ", 2*zisofsBlockSize/27))
+	content = append(content, make([]byte, zisofsBlockSize)...)
+	content = append(content, []byte("end")...)
+
+	docPath := filepath.Join(testDir, "doc.txt")
+	err := os.WriteFile(docPath, content, 0o640)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	modTime := time.Unix(1700000000, 0)
+	err = os.Chtimes(docPath, modTime, modTime)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	compressed, err := compressZisofsFile(docPath)
+	if !assert.NoError(t, err) || !assert.True(t, compressed) {
+		return
+	}
+
+	info, err := os.Stat(docPath)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Less(t, info.Size(), int64(len(content)))
+	assert.Equal(t, os.FileMode(0o640), info.Mode())
+	assert.True(t, modTime.Equal(info.ModTime()))
+
+	header, err := readZisofsHeader(docPath)
+	if !assert.NoError(t, err) || !assert.NotNil(t, header) {
+		return
+	}
+	assert.Equal(t, zisofsHeader{uint32(len(content)), 4, zisofsBlockSizeLog2}, *header)
+
+	compressedContent, err := os.ReadFile(docPath)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, content, decompressZisofs(t, compressedContent))
+
+	// Already compressed files are left as-is.
+	compressed, err = compressZisofsFile(docPath)
+	assert.NoError(t, err)
+	assert.False(t, compressed)
+
+	// So are the files that don't shrink.
+	tinyPath := filepath.Join(testDir, "tiny.txt")
+	err = os.WriteFile(tinyPath, []byte("tiny"), 0o644)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	compressed, err = compressZisofsFile(tinyPath)
+	assert.NoError(t, err)
+	assert.False(t, compressed)
+
+	tinyContent, err := os.ReadFile(tinyPath)
+	assert.NoError(t, err)
+	assert.Equal(t, "tiny", string(tinyContent))
+	assert.NoFileExists(t, tinyPath+".zisofs")
+}
+
+func TestCompressZisofsPathsNativeIso(t *testing.T) {
+	testDir := t.TempDir()
+	buildDir := filepath.Join(testDir, "iso-root")
+
+	content := strings.Repeat("license text\n", 1000)
+	testFiles := map[string]string{
+		"docs/LICENSE":                   content,
+		"boot/grub2/grub.cfg":            content,
+		efiBootImgPathRelativeToIsoRoot:  content,
+		defaultOSFilesPath + "/boot.msg": content,
+	}
+	for path, fileContent := range testFiles {
+		fullPath := filepath.Join(buildDir, path)
+		err := os.MkdirAll(filepath.Dir(fullPath), os.ModePerm)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		err = os.WriteFile(fullPath, []byte(fileContent), 0o644)
+		if !assert.NoError(t, err) {
+			return
+		}
+	}
+
+	isoMaker := &IsoMaker{
+		buildDirPath: buildDir,
+		osFilesPath:  defaultOSFilesPath,
+		zisofsPaths:  []string{".", "docs"},
+	}
+
+	compressed, err := isoMaker.compressZisofsPaths()
+	if !assert.NoError(t, err) || !assert.True(t, compressed) {
+		return
+	}
+
+	// Only the files that aren't read by the bootloaders are compressed.
+	for path, fileContent := range testFiles {
+		header, err := readZisofsHeader(filepath.Join(buildDir, path))
+		if !assert.NoError(t, err, path) {
+			return
+		}
+
+		if path == "docs/LICENSE" {
+			assert.Equal(t, &zisofsHeader{uint32(len(fileContent)), 4, zisofsBlockSizeLog2}, header, path)
+		} else {
+			assert.Nil(t, header, path)
+		}
+	}
+
+	isoImagePath := filepath.Join(testDir, "test.iso")
+	err = writeNativeIsoImage(buildDir, []string{efiBootImgPathRelativeToIsoRoot}, DefaultVolumeId, nil,
+		true /*zisofs*/, isoImagePath)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	image, err := os.ReadFile(isoImagePath)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	zfEntry := rripZfEntry(&zisofsHeader{uint32(len(content)), 4, zisofsBlockSizeLog2})
+	assert.Equal(t, 1, bytes.Count(image, zfEntry))
+}
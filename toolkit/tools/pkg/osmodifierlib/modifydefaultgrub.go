@@ -20,6 +20,7 @@ var grubArgs = []string{
 	"systemd.verity_root_data",
 	"systemd.verity_root_hash",
 	"systemd.verity_root_options",
+	"dm_verity.require_signatures",
 	"selinux",
 	"enforcing",
 }
@@ -4,6 +4,8 @@
 package formats
 
 import (
+	"path/filepath"
+
 	"github.com/microsoft/azurelinux/toolkit/tools/internal/shell"
 	"github.com/microsoft/azurelinux/toolkit/tools/internal/systemdependency"
 )
@@ -25,7 +27,8 @@ func (t *TarGzip) Convert(input, output string, isInputFile bool) (err error) {
 	}
 
 	if isInputFile {
-		err = shell.ExecuteLive(squashErrors, "tar", "--xattrs", "--selinux", "-I", tool, "-cf", output, input)
+		// Archive the file (e.g. a RAW disk) by its name rather than its path, and keep its holes
+		err = shell.ExecuteLive(squashErrors, "tar", "--xattrs", "--selinux", "--sparse", "-I", tool, "-cf", output, "-C", filepath.Dir(input), filepath.Base(input))
 	} else {
 		err = shell.ExecuteLive(squashErrors, "tar", "--xattrs", "--selinux", "-I", tool, "-cf", output, "-C", input, ".")
 	}
@@ -3,7 +3,11 @@
 
 package formats
 
-import "github.com/microsoft/azurelinux/toolkit/tools/internal/shell"
+import (
+	"path/filepath"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/shell"
+)
 
 // TarXzType represents the tar.xz format
 const TarXzType = "tar.xz"
@@ -15,7 +19,14 @@ type TarXz struct {
 // Convert converts the image in the tar.xz format
 func (t *TarXz) Convert(input, output string, isInputFile bool) (err error) {
 	const squashErrors = false
-	err = shell.ExecuteLive(squashErrors, "tar", "-cJf", output, input)
+
+	if isInputFile {
+		// Archive the file (e.g. a RAW disk) by its name rather than its path, and keep its holes
+		err = shell.ExecuteLive(squashErrors, "tar", "--sparse", "-cJf", output, "-C", filepath.Dir(input), filepath.Base(input))
+	} else {
+		err = shell.ExecuteLive(squashErrors, "tar", "-cJf", output, "-C", input, ".")
+	}
+
 	return
 }
 
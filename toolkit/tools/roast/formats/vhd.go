@@ -5,7 +5,10 @@ package formats
 
 import (
 	"fmt"
+	"os"
 
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/file"
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/logger"
 	"github.com/microsoft/azurelinux/toolkit/tools/internal/shell"
 )
 
@@ -15,6 +18,9 @@ const (
 
 	// VhdxType represents the vhdx virtual drive format
 	VhdxType = "vhdx"
+
+	// fixedVhdSizeAlignment is the multiple that the virtual size of a fixed VHD must be, for Azure to accept it
+	fixedVhdSizeAlignment = 1024 * 1024
 )
 
 // Vhd implements Converter interface to convert a RAW image into a VHD(x) file
@@ -33,6 +39,17 @@ func (v *Vhd) Convert(input, output string, isInputFile bool) (err error) {
 		return fmt.Errorf("vhd conversion requires a RAW file as an input")
 	}
 
+	if !v.generation2 {
+		var isAlignedCopy bool
+		input, isAlignedCopy, err = alignRawFile(input, output, fixedVhdSizeAlignment)
+		if err != nil {
+			return fmt.Errorf("failed to align the size of the RAW file for a fixed VHD:\n%w", err)
+		}
+		if isAlignedCopy {
+			defer os.Remove(input)
+		}
+	}
+
 	var format string
 	args := []string{"convert", input, output}
 
@@ -64,3 +81,33 @@ func NewVhd(generation2 bool) *Vhd {
 		generation2: generation2,
 	}
 }
+
+// alignRawFile returns a RAW file with the input's content, whose size is a multiple of the alignment. If the input's
+// size isn't, the returned file is a copy of the input next to the output, padded with zeros, which the caller removes.
+func alignRawFile(input, output string, alignment int64) (alignedFile string, isCopy bool, err error) {
+	inputInfo, err := os.Stat(input)
+	if err != nil {
+		return
+	}
+
+	if inputInfo.Size()%alignment == 0 {
+		return input, false, nil
+	}
+
+	alignedFile = output + ".aligned.raw"
+	alignedSize := (inputInfo.Size()/alignment + 1) * alignment
+	logger.Log.Infof("Padding (%s) from (%d) to (%d) bytes", input, inputInfo.Size(), alignedSize)
+
+	err = file.Copy(input, alignedFile)
+	if err != nil {
+		return
+	}
+
+	err = os.Truncate(alignedFile, alignedSize)
+	if err != nil {
+		os.Remove(alignedFile)
+		return
+	}
+
+	return alignedFile, true, nil
+}
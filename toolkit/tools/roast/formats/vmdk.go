@@ -0,0 +1,45 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package formats
+
+import (
+	"fmt"
+
+	"github.com/microsoft/azurelinux/toolkit/tools/internal/shell"
+)
+
+const (
+	// VmdkType represents the vmdk virtual drive format
+	VmdkType = "vmdk"
+)
+
+// Vmdk implements Converter interface to convert a RAW image into a vmdk file
+type Vmdk struct {
+}
+
+// Convert converts the image in the stream optimized vmdk format, which is compressed, and which VMware imports (e.g.
+// into a vSphere content library) without any further conversion
+func (v *Vmdk) Convert(input, output string, isInputFile bool) (err error) {
+	const (
+		outputFormat = "vmdk"
+		squashErrors = false
+	)
+
+	if !isInputFile {
+		return fmt.Errorf("vmdk conversion requires a RAW file as an input")
+	}
+
+	err = shell.ExecuteLive(squashErrors, "qemu-img", "convert", "-f", "raw", "-O", outputFormat, "-o", "subformat=streamOptimized,adapter_type=lsilogic", input, output)
+	return
+}
+
+// Extension returns the filetype extension produced by this converter.
+func (v *Vmdk) Extension() string {
+	return VmdkType
+}
+
+// NewVmdk returns a new vmdk format encoder
+func NewVmdk() *Vmdk {
+	return &Vmdk{}
+}
@@ -56,6 +56,8 @@ var (
 	imageTag = app.Flag("image-tag", "Tag (text) appended to the image name. Empty by default.").String()
 
 	timestampFile = app.Flag("timestamp-file", "File that stores timestamps for this program.").String()
+
+	batchConfig = app.Flag("batch", "The config is a batch config: convert each of its images from a sub-directory of the input directory, named after the image, into the same sub-directory of the output directory.").Bool()
 )
 
 func main() {
@@ -96,6 +98,14 @@ func main() {
 		logger.Log.Panicf("Error when creating output directory. Error: %s", err)
 	}
 
+	if *batchConfig {
+		err = generateBatchArtifacts(*workers, inDirPath, outDirPath, *releaseVersion, *imageTag, tmpDirPath, *configFile)
+		if err != nil {
+			logger.Log.Panic(err)
+		}
+		return
+	}
+
 	config, err := configuration.Load(*configFile)
 	if err != nil {
 		logger.Log.Panicf("Failed loading image configuration. Error: %s", err)
@@ -107,6 +117,35 @@ func main() {
 	}
 }
 
+// generateBatchArtifacts converts the artifacts of each image of a batch config. The input and output of each image
+// are in sub-directories, named after the image, of the input and output directories.
+func generateBatchArtifacts(workers int, inDir, outDir, releaseVersion, imageTag, tmpDir, batchFile string) (err error) {
+	// Only the artifacts of the configs are used, which have no relative paths.
+	const baseDirPath = ""
+
+	batch, configs, err := configuration.LoadBatchWithAbsolutePaths(batchFile, baseDirPath)
+	if err != nil {
+		return fmt.Errorf("failed loading batch configuration:\n%w", err)
+	}
+
+	for i, image := range batch.Images {
+		logger.Log.Infof("Converting the artifacts of image (%s)", image.Name)
+
+		imageOutDir := filepath.Join(outDir, image.Name)
+		err = os.MkdirAll(imageOutDir, os.ModePerm)
+		if err != nil {
+			return fmt.Errorf("failed to create the output directory of image (%s):\n%w", image.Name, err)
+		}
+
+		err = generateImageArtifacts(workers, filepath.Join(inDir, image.Name), imageOutDir, releaseVersion, imageTag, tmpDir, configs[i])
+		if err != nil {
+			return fmt.Errorf("failed to convert the artifacts of image (%s):\n%w", image.Name, err)
+		}
+	}
+
+	return
+}
+
 func generateImageArtifacts(workers int, inDir, outDir, releaseVersion, imageTag, tmpDir string, config configuration.Config) (err error) {
 	const defaultSystemConfig = 0
 	timestamp.StartEvent("generate artifacts", nil)
@@ -222,42 +261,20 @@ func artifactConverterWorker(convertRequests chan *convertRequest, convertedResu
 			originalPath: req.inputPath,
 		}
 
-		workingArtifactPath := req.inputPath
-		isInputFile := req.isInputFile
-
-		if req.artifact.Type != "" {
-			const appendExtension = false
-			outputFile, err := convertArtifact(fullArtifactName, tmpDir, req.artifact.Type, imageTag, workingArtifactPath, isInputFile, appendExtension)
-			if err != nil {
-				logger.Log.Errorf("Failed to convert artifact (%s) to type (%s). Error: %s", req.artifact.Name, req.artifact.Type, err)
-				convertedResults <- result
-				continue
-			}
-			isInputFile = true
-			workingArtifactPath = outputFile
+		// Each artifact is converted in its own directory, as the artifacts of the same name (e.g. a raw disk, and the
+		// same raw disk compressed) would otherwise overwrite each other's intermediate files.
+		artifactTmpDir, err := os.MkdirTemp(tmpDir, "artifact-")
+		if err != nil {
+			logger.Log.Errorf("Failed to create a temporary directory for artifact (%s). Error: %s", req.artifact.Name, err)
+			convertedResults <- result
+			continue
 		}
 
-		if req.artifact.Compression != "" {
-			const appendExtension = true
-			outputFile, err := convertArtifact(fullArtifactName, tmpDir, req.artifact.Compression, imageTag, workingArtifactPath, isInputFile, appendExtension)
-			if err != nil {
-				logger.Log.Errorf("Failed to compress (%s) using (%s). Error: %s", workingArtifactPath, req.artifact.Compression, err)
-				convertedResults <- result
-				continue
-			}
-			workingArtifactPath = outputFile
-		}
+		result.convertedFile = convertRequestedArtifact(req, fullArtifactName, artifactTmpDir, imageTag, outDir)
 
-		if workingArtifactPath == req.inputPath {
-			logger.Log.Errorf("Artifact (%s) has no type or compression", req.artifact.Name)
-		} else {
-			finalFile := filepath.Join(outDir, filepath.Base(workingArtifactPath))
-			err := file.Move(workingArtifactPath, finalFile)
-			if err != nil {
-				logger.Log.Errorf("Failed to move (%s) to (%s). Error: %s", workingArtifactPath, finalFile, err)
-			} else {
-				result.convertedFile = finalFile
-			}
+		err = os.RemoveAll(artifactTmpDir)
+		if err != nil {
+			logger.Log.Warnf("Failed to remove the temporary directory (%s). Error: %s", artifactTmpDir, err)
 		}
 
 		convertedResults <- result
@@ -265,6 +282,48 @@ func artifactConverterWorker(convertRequests chan *convertRequest, convertedResu
 	}
 }
 
+// convertRequestedArtifact converts the input of a request into its artifact's type, compresses it, and moves it into
+// the output directory. It returns the artifact's file, or an empty string if the conversion failed.
+func convertRequestedArtifact(req *convertRequest, fullArtifactName, tmpDir, imageTag, outDir string) (convertedFile string) {
+	workingArtifactPath := req.inputPath
+	isInputFile := req.isInputFile
+
+	if req.artifact.Type != "" {
+		const appendExtension = false
+		outputFile, err := convertArtifact(fullArtifactName, tmpDir, req.artifact.Type, imageTag, workingArtifactPath, isInputFile, appendExtension)
+		if err != nil {
+			logger.Log.Errorf("Failed to convert artifact (%s) to type (%s). Error: %s", req.artifact.Name, req.artifact.Type, err)
+			return
+		}
+		isInputFile = true
+		workingArtifactPath = outputFile
+	}
+
+	if req.artifact.Compression != "" {
+		const appendExtension = true
+		outputFile, err := convertArtifact(fullArtifactName, tmpDir, req.artifact.Compression, imageTag, workingArtifactPath, isInputFile, appendExtension)
+		if err != nil {
+			logger.Log.Errorf("Failed to compress (%s) using (%s). Error: %s", workingArtifactPath, req.artifact.Compression, err)
+			return
+		}
+		workingArtifactPath = outputFile
+	}
+
+	if workingArtifactPath == req.inputPath {
+		logger.Log.Errorf("Artifact (%s) has no type or compression", req.artifact.Name)
+		return
+	}
+
+	finalFile := filepath.Join(outDir, filepath.Base(workingArtifactPath))
+	err := file.Move(workingArtifactPath, finalFile)
+	if err != nil {
+		logger.Log.Errorf("Failed to move (%s) to (%s). Error: %s", workingArtifactPath, finalFile, err)
+		return
+	}
+
+	return finalFile
+}
+
 func convertArtifact(artifactName, outDir, format, imageTag, input string, isInputFile, appendExtension bool) (outputFile string, err error) {
 	typeConverter, err := converterFactory(format)
 	if err != nil {
@@ -325,6 +384,8 @@ func converterFactory(formatType string) (converter formats.Converter, err error
 		converter = formats.NewOva()
 	case formats.QcowType:
 		converter = formats.NewQcow()
+	case formats.VmdkType:
+		converter = formats.NewVmdk()
 	default:
 		err = fmt.Errorf("unsupported output format: %s", formatType)
 	}
@@ -14,7 +14,7 @@ import (
 	"github.com/microsoft/azurelinux/toolkit/tools/internal/file"
 	"github.com/microsoft/azurelinux/toolkit/tools/internal/logger"
 	"github.com/microsoft/azurelinux/toolkit/tools/internal/safechroot"
-	"github.com/microsoft/azurelinux/toolkit/tools/internal/shell"
+	"github.com/sirupsen/logrus"
 
 	"gopkg.in/alecthomas/kingpin.v2"
 )
@@ -31,6 +31,13 @@ var (
 
 	workerTar      = app.Flag("worker-chroot", "Full path to worker_chroot.tar.gz").Required().ExistingFile()
 	workerManifest = app.Flag("worker-manifest", "Full path to the worker manifest file").Required().ExistingFile()
+	rootless       = app.Flag("rootless", "Use user namespaces instead of chroot(2), so that root is not required. Falls back to a regular chroot if the kernel doesn't allow unprivileged user namespaces.").Bool()
+
+	sandboxMemoryMax      = app.Flag("sandbox-memory-max", "Maximum memory (in MiB) that the package installs may use. 0 means no limit.").Uint64()
+	sandboxCpuMax         = app.Flag("sandbox-cpu-max", "Maximum number of CPUs worth of time that the package installs may use (e.g. 1.5). 0 means no limit.").Float64()
+	sandboxPidsMax        = app.Flag("sandbox-pids-max", "Maximum number of processes that the package installs may have running at once. 0 means no limit.").Uint64()
+	sandboxNoNetwork      = app.Flag("sandbox-no-network", "Run the package installs without network access.").Bool()
+	sandboxSeccompProfile = app.Flag("sandbox-seccomp-profile", "Seccomp profile applied to the package installs. Supported: default, strict.").Enum(string(safechroot.SeccompProfileDefault), string(safechroot.SeccompProfileStrict))
 
 	logFlags = exe.SetupLogFlags(app)
 )
@@ -40,14 +47,24 @@ func main() {
 	kingpin.MustParse(app.Parse(os.Args[1:]))
 	logger.InitBestEffort(logFlags)
 
-	err := validateWorker(*toolchainRpmsDir, *tmpDir, *workerTar, *workerManifest)
+	sandbox := safechroot.Sandbox{
+		MemoryMax:      *sandboxMemoryMax * 1024 * 1024,
+		CpuMax:         *sandboxCpuMax,
+		PidsMax:        *sandboxPidsMax,
+		NoNetwork:      *sandboxNoNetwork,
+		SeccompProfile: safechroot.SeccompProfile(*sandboxSeccompProfile),
+	}
+
+	err := validateWorker(*toolchainRpmsDir, *tmpDir, *workerTar, *workerManifest, *rootless, sandbox)
 
 	if err != nil {
 		logger.Log.Fatalf("Failed to validate worker. Error: %s", err)
 	}
 }
 
-func validateWorker(rpmsDir, chrootDir, workerTarPath, manifestPath string) (err error) {
+func validateWorker(rpmsDir, chrootDir, workerTarPath, manifestPath string, rootless bool,
+	sandbox safechroot.Sandbox,
+) (err error) {
 	const (
 		chrootToolchainRpmsDir = "/toolchainrpms"
 		isExistingDir          = false
@@ -71,7 +88,13 @@ func validateWorker(rpmsDir, chrootDir, workerTarPath, manifestPath string) (err
 
 	logger.Log.Infof("Creating chroot environment to validate '%s' against '%s'", workerTarPath, manifestPath)
 
-	chroot = safechroot.NewChroot(chrootDir, isExistingDir)
+	if rootless {
+		chroot = safechroot.NewRootlessChroot(chrootDir, isExistingDir)
+	} else {
+		chroot = safechroot.NewChroot(chrootDir, isExistingDir)
+	}
+	// Constrain the packages' scriptlets.
+	chroot.SetSandbox(sandbox)
 	rpmMount := safechroot.NewMountPoint(rpmsDir, chrootToolchainRpmsDir, "", safechroot.BindMountPointFlags, "")
 	extraDirectories := []string{chrootToolchainRpmsDir}
 	rpmMounts := []*safechroot.MountPoint{rpmMount}
@@ -105,7 +128,9 @@ func validateWorker(rpmsDir, chrootDir, workerTarPath, manifestPath string) (err
 				rpmPath,
 			}
 			logger.Log.Infof("Validating %s", filepath.Base(rpmPath))
-			stdout, stderr, err := shell.Execute("rpm", args...)
+			stdout, stderr, err := chroot.NewExecBuilder("rpm", args...).
+				LogLevel(logrus.TraceLevel, logrus.DebugLevel).
+				ExecuteCaptureOuput()
 
 			logger.Log.Debug(stdout)
 